@@ -19,7 +19,7 @@ CREATE TABLE IF NOT EXISTS scans (
     configuration JSONB,
     nmap_arguments VARCHAR(500),
     CONSTRAINT valid_status CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
-    CONSTRAINT valid_scan_scanner CHECK (scanner IN ('nmap', 'masscan', 'dns'))
+    CONSTRAINT valid_scan_scanner CHECK (scanner IN ('nmap', 'masscan', 'dns', 'native', 'pipeline', 'naabu'))
 );
 
 -- Scan results table
@@ -293,7 +293,7 @@ CREATE TABLE IF NOT EXISTS web_scans (
     error_message TEXT,
     configuration JSONB,
     CONSTRAINT valid_web_scan_status CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
-    CONSTRAINT valid_web_scan_tool CHECK (tool IN ('ffuf', 'gowitness', 'testssl'))
+    CONSTRAINT valid_web_scan_tool CHECK (tool IN ('ffuf', 'gowitness', 'testssl', 'credcheck', 'sqlmap'))
 );
 
 -- Web scan results table (unified for all web scanning tools)
@@ -343,7 +343,7 @@ CREATE INDEX idx_web_scan_results_severity ON web_scan_results(severity);
 CREATE INDEX idx_web_scan_logs_scan_id ON web_scan_logs(scan_id);
 
 -- Comments for web scanning tables
-COMMENT ON TABLE web_scans IS 'Stores web scanning jobs (ffuf, gowitness, testssl.sh)';
+COMMENT ON TABLE web_scans IS 'Stores web scanning jobs (ffuf, gowitness, testssl.sh, credcheck)';
 COMMENT ON TABLE web_scan_results IS 'Stores results from web scanning tools';
 COMMENT ON TABLE web_scan_logs IS 'Stores execution logs for web scans';
 
@@ -367,6 +367,9 @@ CREATE TABLE IF NOT EXISTS recon_scans (
     CONSTRAINT valid_recon_scan_status CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
     CONSTRAINT valid_recon_scan_type CHECK (scan_type IN ('subdomain', 'whois', 'dns', 'tech'))
 );
+ALTER TABLE recon_scans DROP CONSTRAINT IF EXISTS valid_recon_scan_type;
+ALTER TABLE recon_scans ADD CONSTRAINT valid_recon_scan_type
+    CHECK (scan_type IN ('subdomain', 'whois', 'dns', 'tech', 'code_leaks', 'emails', 'recon_full'));
 
 -- Subdomain results table
 CREATE TABLE IF NOT EXISTS subdomain_results (
@@ -567,3 +570,692 @@ COMMENT ON TABLE api_parameters IS 'Stores discovered API parameters';
 COMMENT ON TABLE graphql_schemas IS 'Stores GraphQL introspection results';
 COMMENT ON TABLE swagger_specs IS 'Stores discovered OpenAPI/Swagger specifications';
 COMMENT ON TABLE api_scan_logs IS 'Stores execution logs for API scans';
+
+-- Platform backup/restore jobs (network-service admin API)
+CREATE TABLE IF NOT EXISTS platform_backups (
+    id UUID PRIMARY KEY,
+    type VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    object_key VARCHAR(500) NOT NULL,
+    size_bytes BIGINT DEFAULT 0,
+    source_id UUID,
+    error_message TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_platform_backups_created_at ON platform_backups(created_at DESC);
+
+COMMENT ON TABLE platform_backups IS 'Tracks logical database backups and restores';
+
+-- Central configuration overrides, hot-reloaded by the services (network-service admin API)
+CREATE TABLE IF NOT EXISTS platform_config (
+    service VARCHAR(50) NOT NULL,
+    key VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    updated_by VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (service, key)
+);
+
+COMMENT ON TABLE platform_config IS 'Per-service configuration overrides (service * applies to all services)';
+
+-- Feature flags (network-service admin API), evaluated per tenant with percentage rollouts
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key VARCHAR(100) PRIMARY KEY,
+    description TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT false,
+    rollout_percentage INTEGER DEFAULT 100,
+    enabled_tenants TEXT[] DEFAULT '{}',
+    disabled_tenants TEXT[] DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO feature_flags (key, description) VALUES
+    ('agent_mode', 'Remote scanning agents'),
+    ('sqlmap', 'SQLMap integration')
+ON CONFLICT (key) DO NOTHING;
+
+COMMENT ON TABLE feature_flags IS 'Feature flags with per-tenant overrides and percentage rollout';
+
+-- Remote scanning agents (network-service); scans with agent_id run on the agent
+CREATE TABLE IF NOT EXISTS scan_agents (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    hostname VARCHAR(255) DEFAULT '',
+    os VARCHAR(50) DEFAULT '',
+    arch VARCHAR(50) DEFAULT '',
+    version VARCHAR(50) DEFAULT '',
+    nmap_version VARCHAR(100) DEFAULT '',
+    last_seen_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS agent_id UUID REFERENCES scan_agents(id) ON DELETE SET NULL;
+
+COMMENT ON TABLE scan_agents IS 'Windows/macOS agents that run nmap scans inside internal networks';
+
+-- Platform users (gateway), provisioned on first SSO login
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    auth_source VARCHAR(20) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    groups TEXT[] DEFAULT '{}',
+    active BOOLEAN DEFAULT true,
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (auth_source, external_id)
+);
+
+COMMENT ON TABLE users IS 'Users authenticated through OIDC or LDAP single sign-on';
+
+-- SCIM provisioning: users created ahead of their first login, and teams
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_managed BOOLEAN DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_external_id VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS teams (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    external_id VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_user ON team_members(user_id);
+
+COMMENT ON TABLE teams IS 'Teams provisioned through the SCIM Groups API';
+
+-- Login sessions with rotating refresh tokens (gateway)
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL DEFAULT '',
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    previous_token_hash VARCHAR(64),
+    user_agent TEXT DEFAULT '',
+    ip_address VARCHAR(64) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_previous ON user_sessions(previous_token_hash);
+
+COMMENT ON TABLE user_sessions IS 'Revocable login sessions; only SHA-256 hashes of refresh tokens are stored';
+
+-- Finding notifications: dedup by fingerprint and pending digest items
+CREATE TABLE IF NOT EXISTS notification_fingerprints (
+    fingerprint VARCHAR(64) PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    first_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_notified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    occurrences INTEGER DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    finding JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_digest_pending ON notification_digest_items(source, created_at) WHERE sent_at IS NULL;
+
+-- Remediation status of findings and automatic fix verification (web service)
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open';
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMP;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verify_after TIMESTAMP;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verification_scan_id UUID;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_vulnerabilities_verify ON vulnerabilities(verify_after) WHERE status = 'fixed';
+
+-- Gateway API usage per tenant and API key (daily counters and monthly rollups)
+CREATE TABLE IF NOT EXISTS api_usage_daily (
+    day DATE NOT NULL,
+    tenant VARCHAR(100) NOT NULL,
+    api_key VARCHAR(100) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    scans_created BIGINT NOT NULL DEFAULT 0,
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, tenant, api_key)
+);
+
+CREATE TABLE IF NOT EXISTS api_usage_monthly (
+    month DATE NOT NULL,
+    tenant VARCHAR(100) NOT NULL,
+    api_key VARCHAR(100) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    scans_created BIGINT NOT NULL DEFAULT 0,
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (month, tenant, api_key)
+);
+
+-- Gateway maintenance mode, shared by every replica: scans can't be started
+-- on the listed services (every service when empty) while enabled
+CREATE TABLE IF NOT EXISTS gateway_maintenance (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL DEFAULT '',
+    retry_after INTEGER NOT NULL DEFAULT 0,
+    services TEXT[] NOT NULL DEFAULT '{}',
+    started_at TIMESTAMP,
+    started_by VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+-- Domain ownership challenges (DNS TXT or well-known file) checked by the gateway
+CREATE TABLE IF NOT EXISTS domain_verifications (
+    domain VARCHAR(255) PRIMARY KEY,
+    token VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    method VARCHAR(10),
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    verified_at TIMESTAMP,
+    last_checked_at TIMESTAMP,
+    last_error TEXT,
+    CONSTRAINT valid_domain_verification_status CHECK (status IN ('pending', 'verified', 'failed'))
+);
+
+-- Honeypot/tarpit heuristics on network scan results
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS honeypot_score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS honeypot_reasons JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_scan_results_honeypot ON scan_results(scan_id) WHERE honeypot_score >= 50;
+
+-- Reputation (Spamhaus, AbuseIPDB) of scanned hosts and resolved subdomain IPs
+CREATE TABLE IF NOT EXISTS ip_reputation (
+    ip VARCHAR(45) PRIMARY KEY,
+    malicious BOOLEAN NOT NULL DEFAULT false,
+    spamhaus_lists TEXT[] NOT NULL DEFAULT '{}',
+    abuse_score INTEGER,
+    abuse_reports INTEGER,
+    abuse_last_reported_at TIMESTAMP,
+    country VARCHAR(8),
+    isp TEXT,
+    usage_type TEXT,
+    error TEXT,
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ip_reputation_malicious ON ip_reputation(malicious) WHERE malicious;
+
+-- Client-ready write-ups of findings, per language, merged into reports
+CREATE TABLE IF NOT EXISTS finding_knowledge (
+    finding_id VARCHAR(255) NOT NULL,
+    language VARCHAR(10) NOT NULL DEFAULT 'en',
+    title TEXT NOT NULL,
+    severity VARCHAR(20),
+    description TEXT NOT NULL DEFAULT '',
+    impact TEXT NOT NULL DEFAULT '',
+    remediation TEXT NOT NULL DEFAULT '',
+    reference_urls TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (finding_id, language)
+);
+
+-- Asset tagging rules (conditions -> tags/criticality) and the tags they gave hosts and subdomains
+CREATE TABLE IF NOT EXISTS asset_tag_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    conditions JSONB NOT NULL DEFAULT '[]',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    criticality VARCHAR(20),
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS asset_tags (
+    asset VARCHAR(500) NOT NULL,
+    tag VARCHAR(100) NOT NULL,
+    rule_id UUID NOT NULL REFERENCES asset_tag_rules(id) ON DELETE CASCADE,
+    criticality VARCHAR(20),
+    source VARCHAR(20) NOT NULL,
+    scan_id UUID,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (asset, tag, rule_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_tags_tag ON asset_tags(tag);
+
+-- Mentions of recon targets in GitHub/GitLab code, commits, gists and snippets
+CREATE TABLE IF NOT EXISTS code_leak_results (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
+    platform VARCHAR(20) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    repository VARCHAR(512),
+    path TEXT,
+    url TEXT NOT NULL,
+    query TEXT,
+    severity VARCHAR(20) NOT NULL,
+    secrets JSONB,
+    snippet TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(scan_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_code_leak_results_scan_id ON code_leak_results(scan_id);
+
+-- Email addresses harvested for recon targets and their breach exposure (HaveIBeenPwned)
+CREATE TABLE IF NOT EXISTS email_results (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
+    email VARCHAR(320) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    sources TEXT[],
+    confidence INTEGER,
+    name VARCHAR(255),
+    position VARCHAR(255),
+    breach_checked BOOLEAN DEFAULT false,
+    breaches JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(scan_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_results_scan_id ON email_results(scan_id);
+
+-- Request counters nuclei reports during a vulnerability scan (-stats), for GET /api/vulnerabilities/:id/stats
+ALTER TABLE vulnerability_scans ADD COLUMN IF NOT EXISTS run_stats JSONB;
+
+-- Template a network scan was created from (template_id), for GET /api/templates/analytics
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES scan_templates(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_scans_template_id ON scans(template_id);
+
+-- Wildcard DNS record a recon subdomain only resolves through (e.g. *.example.com)
+ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS wildcard VARCHAR(500);
+
+-- Hosts that stopped answering mid-scan (network-service): results after "since" are unreliable
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS possibly_blocked JSONB;
+
+-- Confidence score of network scan results: error rates, timeouts, retransmissions and coverage
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS quality JSONB;
+
+-- Targets of a vulnerability scan, each run in its own nuclei process (web-service)
+CREATE TABLE IF NOT EXISTS vulnerability_scan_targets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scan_id UUID REFERENCES vulnerability_scans(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    target TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    findings INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    run_stats JSONB,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    UNIQUE(scan_id, target),
+    CONSTRAINT valid_vuln_target_status CHECK (status IN ('pending', 'running', 'done', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_vuln_scan_targets_scan_id ON vulnerability_scan_targets(scan_id);
+
+-- API keys created through the gateway (/api/auth/api-keys); keys from API_KEYS are not stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    prefix VARCHAR(16) NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+COMMENT ON TABLE api_keys IS 'Gateway API keys; only SHA-256 hashes of the keys are stored';
+
+-- Pre/post scan hooks of templates and projects (network-service), and their results on each scan
+CREATE TABLE IF NOT EXISTS scan_hooks (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    stage VARCHAR(10) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    url TEXT,
+    script VARCHAR(255),
+    template_id UUID REFERENCES scan_templates(id) ON DELETE CASCADE,
+    project VARCHAR(100),
+    timeout_seconds INTEGER NOT NULL DEFAULT 30,
+    required BOOLEAN NOT NULL DEFAULT false,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_hooks_template ON scan_hooks(template_id);
+
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hook_results JSONB;
+
+-- Scans waiting for a free scanner slot are 'queued'
+ALTER TABLE scans DROP CONSTRAINT IF EXISTS valid_status;
+ALTER TABLE scans ADD CONSTRAINT valid_status CHECK (status IN ('pending', 'queued', 'running', 'completed', 'failed', 'cancelled'));
+
+-- Leases electing the replica that runs each background job
+CREATE TABLE IF NOT EXISTS job_leases (
+    job VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Hosts a network scan skipped after they used up their host_timeout budget
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS skipped_hosts JSONB;
+
+-- How each DoH/DoT resolver of a DNS scan answered
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS resolver_health JSONB;
+
+-- How each DoH/DoT resolver of a recon scan answered
+ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS resolver_health JSONB;
+
+-- What started each scan: manual, schedule:<id>, workflow:<id> or ci:<API key name>
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+ALTER TABLE vulnerability_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+ALTER TABLE web_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+ALTER TABLE api_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+CREATE INDEX IF NOT EXISTS idx_scans_origin ON scans(origin);
+CREATE INDEX IF NOT EXISTS idx_vulnerability_scans_origin ON vulnerability_scans(origin);
+CREATE INDEX IF NOT EXISTS idx_web_scans_origin ON web_scans(origin);
+CREATE INDEX IF NOT EXISTS idx_recon_scans_origin ON recon_scans(origin);
+CREATE INDEX IF NOT EXISTS idx_api_scans_origin ON api_scans(origin);
+
+-- Result fields cut to their size limit, with where their full content is kept
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS truncated JSONB;
+ALTER TABLE web_scan_results ADD COLUMN IF NOT EXISTS truncated JSONB;
+
+-- Project (X-Tenant-ID) each scan and template belongs to
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE scan_templates ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE vulnerability_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE vulnerability_templates ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE web_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE api_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_scans_project_id ON scans(project_id);
+CREATE INDEX IF NOT EXISTS idx_scan_templates_project_id ON scan_templates(project_id);
+CREATE INDEX IF NOT EXISTS idx_vulnerability_scans_project_id ON vulnerability_scans(project_id);
+CREATE INDEX IF NOT EXISTS idx_vulnerability_templates_project_id ON vulnerability_templates(project_id);
+CREATE INDEX IF NOT EXISTS idx_web_scans_project_id ON web_scans(project_id);
+CREATE INDEX IF NOT EXISTS idx_recon_scans_project_id ON recon_scans(project_id);
+CREATE INDEX IF NOT EXISTS idx_api_scans_project_id ON api_scans(project_id);
+
+-- Projects known to the gateway
+CREATE TABLE IF NOT EXISTS projects (
+    id VARCHAR(63) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO projects (id, name, description) VALUES ('default', 'Default', 'Scans created without a project')
+ON CONFLICT (id) DO NOTHING;
+
+-- Owner team, contact and business unit of the assets matching each target
+CREATE TABLE IF NOT EXISTS asset_owners (
+    id UUID PRIMARY KEY,
+    target VARCHAR(500) NOT NULL UNIQUE,
+    owner_team VARCHAR(255) NOT NULL,
+    contact_email VARCHAR(255) NOT NULL DEFAULT '',
+    business_unit VARCHAR(255) NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Gowitness scans of the web ports a network scan found newly opened on known
+-- assets, shown in the asset's timeline
+CREATE TABLE IF NOT EXISTS asset_screenshots (
+    id UUID PRIMARY KEY,
+    asset VARCHAR(255) NOT NULL,
+    hostname VARCHAR(255) NOT NULL DEFAULT '',
+    urls TEXT[] NOT NULL,
+    network_scan_id UUID NOT NULL,
+    web_scan_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_asset_screenshots_asset ON asset_screenshots(asset);
+CREATE INDEX IF NOT EXISTS idx_asset_screenshots_hostname ON asset_screenshots(hostname);
+
+-- Network scans checked for newly opened web ports
+CREATE TABLE IF NOT EXISTS screenshot_checks (
+    network_scan_id UUID PRIMARY KEY,
+    web_scan_id UUID,
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Slack and Discord channels posted scans with high-severity findings
+CREATE TABLE IF NOT EXISTS chat_channels (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    severities TEXT[] NOT NULL DEFAULT '{critical,high}',
+    tools TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- CIDRs and domains scans may (allow) or may never (deny) target; an empty
+-- project_id applies to every project
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id UUID PRIMARY KEY,
+    action VARCHAR(10) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    project_id VARCHAR(63) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, action, value)
+);
+
+-- TLS configuration testssl.sh results must meet; an empty project_id
+-- applies to every project without a policy of its own
+CREATE TABLE IF NOT EXISTS tls_policies (
+    project_id VARCHAR(63) PRIMARY KEY,
+    min_protocol VARCHAR(10) NOT NULL DEFAULT '',
+    banned_ciphers JSONB NOT NULL DEFAULT '[]',
+    min_rsa_bits INTEGER NOT NULL DEFAULT 0,
+    min_ecc_bits INTEGER NOT NULL DEFAULT 0,
+    max_cert_days INTEGER NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Saved finding searches and the findings each matched (gateway)
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL,
+    project_id VARCHAR(63) NOT NULL DEFAULT '',
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    last_checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    finding_id UUID NOT NULL,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (search_id, finding_id)
+);
+
+-- Comment threads on scans and findings (gateway)
+CREATE TABLE IF NOT EXISTS comments (
+    id UUID PRIMARY KEY,
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    subject VARCHAR(20) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    subject_id UUID NOT NULL,
+    scan_id UUID,
+    author_id VARCHAR(255) NOT NULL DEFAULT '',
+    author_name VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    mentions TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_subject ON comments(subject, source, subject_id);
+CREATE INDEX IF NOT EXISTS idx_comments_scan ON comments(source, scan_id);
+CREATE INDEX IF NOT EXISTS idx_comments_mentions ON comments USING GIN(mentions);
+
+-- Open/closed transitions of each host:port across network scans
+CREATE TABLE IF NOT EXISTS port_state_history (
+    host VARCHAR(255) NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    state VARCHAR(10) NOT NULL,
+    scan_id UUID NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, port, protocol, scan_id)
+);
+CREATE INDEX IF NOT EXISTS idx_port_state_history_changed ON port_state_history(host, port, protocol, changed_at);
+
+-- Scans left unfinished by a replica that stopped are 'interrupted'; the
+-- replica running each scan and the request that created it allow retries
+ALTER TABLE scans DROP CONSTRAINT IF EXISTS valid_status;
+ALTER TABLE scans ADD CONSTRAINT valid_status CHECK (status IN ('pending', 'queued', 'running', 'completed', 'failed', 'cancelled', 'interrupted'));
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS runner VARCHAR(255);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS request JSONB;
+
+-- Community template packs and the templates they imported (deleted with
+-- the pack); ffuf configurations are listed by the web service
+CREATE TABLE IF NOT EXISTS template_packs (
+    id UUID PRIMARY KEY,
+    name VARCHAR(63) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    publisher VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    source_url TEXT,
+    key_id VARCHAR(255),
+    digest VARCHAR(64) NOT NULL,
+    imported_by VARCHAR(255) NOT NULL DEFAULT '',
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, name)
+);
+ALTER TABLE scan_templates ADD COLUMN IF NOT EXISTS pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE;
+ALTER TABLE vulnerability_templates ADD COLUMN IF NOT EXISTS pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE;
+CREATE TABLE IF NOT EXISTS web_scan_templates (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    tool VARCHAR(50) NOT NULL,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    configuration JSONB NOT NULL DEFAULT '{}',
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_web_scan_templates_project_id ON web_scan_templates(project_id);
+
+-- Unparsed nmap XML and masscan JSON of each run, for evidence bundles
+CREATE TABLE IF NOT EXISTS scan_raw_output (
+    id BIGSERIAL PRIMARY KEY,
+    scan_id UUID NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+    tool VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    content BYTEA NOT NULL,
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_scan_raw_output_scan ON scan_raw_output(scan_id);
+
+-- Nuclei noise profiles: informational findings suppressed or collapsed
+-- into the technologies of their asset at ingest
+CREATE TABLE IF NOT EXISTS nuclei_noise_profiles (
+    name VARCHAR(63) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    severities TEXT[] NOT NULL DEFAULT '{info}',
+    suppress_templates TEXT[] NOT NULL DEFAULT '{}',
+    suppress_tags TEXT[] NOT NULL DEFAULT '{}',
+    collapse_templates TEXT[] NOT NULL DEFAULT '{}',
+    collapse_tags TEXT[] NOT NULL DEFAULT '{}',
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS suppressed VARCHAR(20);
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS suppressed_by VARCHAR(255);
+CREATE TABLE IF NOT EXISTS asset_technologies (
+    host TEXT NOT NULL,
+    technology VARCHAR(255) NOT NULL,
+    template_id VARCHAR(255) NOT NULL,
+    scan_id UUID NOT NULL,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (host, technology)
+);
+
+-- Every source that found a recon subdomain (subfinder, crtsh, certspotter, amass), in the order they ran
+ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS sources TEXT[];
+
+-- What a recon subdomain answered when probed with httpx (HTTPS values when both schemes answer)
+ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS content_length INTEGER;
+ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS web_server VARCHAR(255);
+
+-- recon_full scans: the recon scans they run as steps and the web service's gowitness scan of the alive hosts
+ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE;
+ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS screenshot_scan_id UUID;
+CREATE INDEX IF NOT EXISTS idx_recon_scans_parent_id ON recon_scans(parent_id);
+
+-- HTML reports of completed scans published to object storage, indexed per project (network service)
+CREATE TABLE IF NOT EXISTS hosted_reports (
+    id UUID PRIMARY KEY,
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    kind VARCHAR(20) NOT NULL,
+    scan_id UUID NOT NULL,
+    scan_name VARCHAR(255) NOT NULL DEFAULT '',
+    target TEXT NOT NULL DEFAULT '',
+    object_key VARCHAR(500) NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    scan_completed_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, scan_id)
+);
+CREATE INDEX IF NOT EXISTS idx_hosted_reports_project ON hosted_reports(project_id, scan_completed_at DESC);
+
+-- Read tokens of the hosted reports of each project, for readers without an account
+CREATE TABLE IF NOT EXISTS hosted_report_tokens (
+    project_id VARCHAR(63) PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Point up to which each exporter has synced, shared by every replica
+CREATE TABLE IF NOT EXISTS exporter_watermarks (
+    exporter VARCHAR(100) PRIMARY KEY,
+    synced_at TIMESTAMP NOT NULL
+);
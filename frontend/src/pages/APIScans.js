@@ -18,12 +18,12 @@ function APIScans() {
 
   const loadScans = async () => {
     try {
-      const params = new URLSearchParams();
+      const params = new URLSearchParams({ limit: 100 });
       if (filterType !== 'all') params.append('type', filterType);
       if (filterStatus !== 'all') params.append('status', filterStatus);
 
       const response = await api.get(`/apiscans/?${params.toString()}`);
-      setScans(response.data || []);
+      setScans(response.data?.items || []);
     } catch (error) {
       console.error('Error loading API scans:', error);
     } finally {
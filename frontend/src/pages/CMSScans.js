@@ -13,8 +13,8 @@ function CMSScans() {
 
   const loadScans = async () => {
     try {
-      const response = await api.get('/cmsscans/');
-      setScans(response.data || []);
+      const response = await api.get('/cmsscans/', { params: { limit: 100 } });
+      setScans(response.data?.items || []);
       setError('');
     } catch (error) {
       console.error('Error loading scans:', error);
@@ -14,8 +14,8 @@ function CloudScans() {
 
   const loadScans = async () => {
     try {
-      const response = await api.get('/cloudscans/');
-      setScans(response.data || []);
+      const response = await api.get('/cloudscans/', { params: { limit: 100 } });
+      setScans(response.data?.items || []);
       setError('');
     } catch (error) {
       console.error('Error loading scans:', error);
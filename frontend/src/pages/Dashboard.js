@@ -47,24 +47,26 @@ function Dashboard() {
   }, []);
 
   const loadAllData = async () => {
+    // The newest scans of each service; the lists return 20 by default
+    const latest = { limit: 100 };
     try {
       const [networkRes, webRes, vulnRes, reconRes, apiRes, cmsRes, cloudRes] = await Promise.all([
-        api.get('/scans/'),
-        api.get('/webscans/'),
-        api.get('/vulnerabilities/'),
-        api.get('/recon/'),
-        api.get('/apiscans/').catch(() => ({ data: [] })),
-        api.get('/cmsscans/').catch(() => ({ data: [] })),
-        api.get('/cloudscans/').catch(() => ({ data: [] }))
+        api.get('/scans/', { params: latest }),
+        api.get('/webscans/', { params: latest }),
+        api.get('/vulnerabilities/', { params: latest }),
+        api.get('/recon/', { params: latest }),
+        api.get('/apiscans/', { params: latest }).catch(() => ({ data: {} })),
+        api.get('/cmsscans/', { params: latest }).catch(() => ({ data: {} })),
+        api.get('/cloudscans/', { params: latest }).catch(() => ({ data: {} }))
       ]);
 
-      const networkData = networkRes.data || [];
-      const webData = webRes.data || [];
-      const vulnData = vulnRes.data || [];
-      const reconData = reconRes.data || [];
-      const apiData = apiRes.data || [];
-      const cmsData = cmsRes.data || [];
-      const cloudData = cloudRes.data || [];
+      const networkData = networkRes.data?.items || [];
+      const webData = webRes.data?.items || [];
+      const vulnData = vulnRes.data?.items || [];
+      const reconData = reconRes.data?.items || [];
+      const apiData = apiRes.data?.items || [];
+      const cmsData = cmsRes.data?.items || [];
+      const cloudData = cloudRes.data?.items || [];
 
       setNetworkScans(networkData.slice(0, 5));
       setWebScans(webData.slice(0, 5));
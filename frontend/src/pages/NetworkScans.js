@@ -18,11 +18,11 @@ function NetworkScans() {
 
   const loadScans = async () => {
     try {
-      const params = {};
+      const params = { limit: 100 };
       if (filter !== 'all') params.status = filter;
       if (filterScanner !== 'all') params.scanner = filterScanner;
       const response = await api.get('/scans/', { params });
-      setScans(response.data);
+      setScans(response.data.items || []);
     } catch (error) {
       console.error('Error loading scans:', error);
     } finally {
@@ -95,6 +95,7 @@ function NetworkScans() {
           <select value={filter} onChange={(e) => setFilter(e.target.value)}>
             <option value="all">All Status</option>
             <option value="pending">Pending</option>
+            <option value="queued">Queued</option>
             <option value="running">Running</option>
             <option value="completed">Completed</option>
             <option value="failed">Failed</option>
@@ -160,7 +161,7 @@ function NetworkScans() {
                     <Link to={`/scan/${scan.id}`} className="btn btn-sm btn-secondary">
                       View
                     </Link>
-                    {(scan.status === 'pending' || scan.status === 'running') && (
+                    {(scan.status === 'pending' || scan.status === 'queued' || scan.status === 'running') && (
                       <button
                         className="btn btn-sm btn-warning"
                         onClick={() => cancelScan(scan.id)}
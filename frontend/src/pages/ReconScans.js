@@ -18,12 +18,12 @@ function ReconScans() {
 
   const loadScans = async () => {
     try {
-      const params = new URLSearchParams();
+      const params = new URLSearchParams({ limit: 100 });
       if (filterType !== 'all') params.append('type', filterType);
       if (filterStatus !== 'all') params.append('status', filterStatus);
-      const queryString = params.toString() ? `?${params.toString()}` : '';
+      const queryString = `?${params.toString()}`;
       const response = await api.get(`/recon/${queryString}`);
-      setScans(response.data || []);
+      setScans(response.data?.items || []);
       setError('');
     } catch (error) {
       console.error('Error loading scans:', error);
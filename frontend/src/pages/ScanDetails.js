@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, useNavigate, Link } from 'react-router-dom';
 import { format } from 'date-fns';
-import api from '../services/api';
+import api, { API_URL } from '../services/api';
 import './ScanDetails.css';
 
 function ScanDetails() {
@@ -12,11 +12,48 @@ function ScanDetails() {
   const [logs, setLogs] = useState([]);
   const [activeTab, setActiveTab] = useState('results');
   const [loading, setLoading] = useState(true);
+  const [redaction, setRedaction] = useState('none');
+  const [comments, setComments] = useState([]);
+  const [newComment, setNewComment] = useState('');
+  const [includeComments, setIncludeComments] = useState(false);
 
   useEffect(() => {
     loadScanData();
-    const interval = setInterval(loadScanData, 3000);
-    return () => clearInterval(interval);
+
+    // Live updates come from the scan's event stream; polling is the
+    // fallback when the browser or a proxy can't keep it open
+    let interval = null;
+    if (!window.EventSource) {
+      interval = setInterval(loadScanData, 3000);
+      return () => clearInterval(interval);
+    }
+
+    const source = new EventSource(`${API_URL}/api/scans/${id}/stream`);
+    source.addEventListener('status', (event) => {
+      const progress = JSON.parse(event.data);
+      setScan((current) => (current ? { ...current, ...progress } : current));
+    });
+    source.addEventListener('log', (event) => {
+      const log = JSON.parse(event.data);
+      setLogs((current) => (current.some((l) => l.id === log.id) ? current : [...current, log]));
+    });
+    source.addEventListener('done', () => {
+      source.close();
+      loadScanData();
+    });
+    source.onerror = () => {
+      source.close();
+      if (!interval) {
+        interval = setInterval(loadScanData, 3000);
+      }
+    };
+
+    return () => {
+      source.close();
+      if (interval) {
+        clearInterval(interval);
+      }
+    };
   }, [id]);
 
   const loadScanData = async () => {
@@ -30,6 +67,7 @@ function ScanDetails() {
       setScan(scanRes.data);
       setResults(resultsRes.data);
       setLogs(logsRes.data);
+      loadComments();
     } catch (error) {
       console.error('Error loading scan data:', error);
     } finally {
@@ -37,9 +75,51 @@ function ScanDetails() {
     }
   };
 
+  // Comments live in the gateway and need its database; without it the
+  // thread is just empty
+  const loadComments = async () => {
+    try {
+      const response = await api.get(`/comments/scans/network/${id}`);
+      setComments(response.data.comments || []);
+    } catch (error) {
+      setComments([]);
+    }
+  };
+
+  const addComment = async (e) => {
+    e.preventDefault();
+    if (!newComment.trim()) return;
+
+    try {
+      await api.post(`/comments/scans/network/${id}`, { body: newComment });
+      setNewComment('');
+      loadComments();
+    } catch (error) {
+      console.error('Error adding comment:', error);
+      alert(error.response?.data?.error || 'Failed to add comment');
+    }
+  };
+
+  const deleteComment = async (commentId) => {
+    if (!window.confirm('Delete this comment?')) return;
+
+    try {
+      await api.delete(`/comments/${commentId}`);
+      loadComments();
+    } catch (error) {
+      console.error('Error deleting comment:', error);
+      alert(error.response?.data?.error || 'Failed to delete comment');
+    }
+  };
+
   const downloadReport = async (format) => {
     try {
+      const params = redaction !== 'none' ? { redact: redaction } : {};
+      if (includeComments && format !== 'csv') {
+        params.comments = true;
+      }
       const response = await api.get(`/reports/${id}/${format}`, {
+        params,
         responseType: format === 'html' ? 'text' : 'blob'
       });
 
@@ -89,6 +169,18 @@ function ScanDetails() {
     }
   };
 
+  const retryScan = async () => {
+    if (!scan) return;
+
+    try {
+      await api.post(`/scans/${id}/retry`);
+      loadScanData();
+    } catch (error) {
+      console.error('Error retrying scan:', error);
+      alert('Failed to retry scan');
+    }
+  };
+
   if (loading) {
     return <div className="loading">Loading scan details...</div>;
   }
@@ -121,11 +213,16 @@ function ScanDetails() {
           </div>
         </div>
         <div className="header-actions">
-          {(scan.status === 'pending' || scan.status === 'running') && (
+          {(scan.status === 'pending' || scan.status === 'queued' || scan.status === 'running') && (
             <button className="btn btn-warning" onClick={cancelScan}>
               Cancel Scan
             </button>
           )}
+          {(scan.status === 'failed' || scan.status === 'interrupted') && (
+            <button className="btn btn-warning" onClick={retryScan}>
+              Retry Scan
+            </button>
+          )}
           <button className="btn btn-primary" onClick={replicateScan}>
             Replicate Scan
           </button>
@@ -133,14 +230,16 @@ function ScanDetails() {
       </div>
 
       {/* Progress */}
-      {(scan.status === 'running' || scan.status === 'pending') && (
+      {(scan.status === 'running' || scan.status === 'pending' || scan.status === 'queued') && (
         <div className="progress-section card">
           <div className="progress-bar-large">
             <div className="progress-fill" style={{ width: `${scan.progress}%` }}></div>
             <span className="progress-text">{scan.progress}%</span>
           </div>
           <p className="progress-status">
-            {scan.status === 'pending' ? 'Waiting to start...' : 'Scanning in progress...'}
+            {scan.status === 'pending' && 'Waiting to start...'}
+            {scan.status === 'queued' && 'Queued, waiting for a free scanner slot...'}
+            {scan.status === 'running' && 'Scanning in progress...'}
           </p>
         </div>
       )}
@@ -167,12 +266,32 @@ function ScanDetails() {
           </div>
           <div className="stat-label">OS Detected</div>
         </div>
+        {scan.quality && (
+          <div className="stat-card" title={scan.quality.reasons.join('\n')}>
+            <div className={`stat-value quality-${scan.quality.grade}`}>{scan.quality.score}</div>
+            <div className="stat-label">Confidence ({scan.quality.grade})</div>
+          </div>
+        )}
       </div>
 
       {scan.error_message && (
         <div className="error-message">{scan.error_message}</div>
       )}
 
+      {scan.quality && scan.quality.grade !== 'clean' && (
+        <div className="quality-warning">
+          Degraded scan: {scan.quality.reasons.join('; ')}. Results may be incomplete rather than clean.
+        </div>
+      )}
+
+      {scan.skipped_hosts?.length > 0 && (
+        <div className="quality-warning" title={scan.skipped_hosts.map(h => h.host).join('\n')}>
+          {scan.skipped_hosts.length} hosts skipped after hitting the host timeout:{' '}
+          {scan.skipped_hosts.slice(0, 10).map(h => h.hostname || h.host).join(', ')}
+          {scan.skipped_hosts.length > 10 && ` and ${scan.skipped_hosts.length - 10} more`}. Their ports were not fully scanned.
+        </div>
+      )}
+
       <div className="tabs">
         <button
           className={`tab ${activeTab === 'results' ? 'active' : ''}`}
@@ -186,6 +305,12 @@ function ScanDetails() {
         >
           Logs ({logs.length})
         </button>
+        <button
+          className={`tab ${activeTab === 'comments' ? 'active' : ''}`}
+          onClick={() => setActiveTab('comments')}
+        >
+          Comments ({comments.length})
+        </button>
       </div>
 
       {activeTab === 'results' && (
@@ -193,6 +318,24 @@ function ScanDetails() {
           <div className="results-header">
             <h2>Scan Results</h2>
             <div className="download-buttons">
+              <select
+                className="redaction-select"
+                value={redaction}
+                onChange={(e) => setRedaction(e.target.value)}
+                title="Redaction profile"
+              >
+                <option value="none">Full evidence</option>
+                <option value="client">Client (no raw requests)</option>
+                <option value="third-party">Third party (masked)</option>
+              </select>
+              <label className="include-comments" title="Include comments in JSON and HTML reports">
+                <input
+                  type="checkbox"
+                  checked={includeComments}
+                  onChange={(e) => setIncludeComments(e.target.checked)}
+                />
+                Comments
+              </label>
               <button className="btn btn-secondary" onClick={() => downloadReport('json')}>
                 Download JSON
               </button>
@@ -307,6 +450,39 @@ function ScanDetails() {
           )}
         </div>
       )}
+
+      {activeTab === 'comments' && (
+        <div className="comments-section">
+          <h2>Comments</h2>
+          {comments.length === 0 ? (
+            <div className="empty-state">No comments yet</div>
+          ) : (
+            <div className="comments-list">
+              {comments.map(comment => (
+                <div key={comment.id} className="comment card">
+                  <div className="comment-meta">
+                    <strong>{comment.author_name}</strong>
+                    <span>{format(new Date(comment.created_at), 'yyyy-MM-dd HH:mm')}{comment.edited && ' (edited)'}</span>
+                    <button className="btn-link" onClick={() => deleteComment(comment.id)}>Delete</button>
+                  </div>
+                  <div className="comment-body">{comment.body}</div>
+                </div>
+              ))}
+            </div>
+          )}
+          <form className="comment-form" onSubmit={addComment}>
+            <textarea
+              value={newComment}
+              onChange={(e) => setNewComment(e.target.value)}
+              placeholder="Add a comment (markdown, @username to mention)"
+              rows={3}
+            />
+            <button type="submit" className="btn btn-primary" disabled={!newComment.trim()}>
+              Comment
+            </button>
+          </form>
+        </div>
+      )}
     </div>
   );
 }
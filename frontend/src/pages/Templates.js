@@ -17,11 +17,76 @@ function Templates() {
     is_default: false
   });
   const [error, setError] = useState('');
+  const [packs, setPacks] = useState([]);
+  const [packURL, setPackURL] = useState('');
+  const [packMessage, setPackMessage] = useState('');
 
   useEffect(() => {
     loadTemplates();
+    loadPacks();
   }, []);
 
+  const loadPacks = async () => {
+    try {
+      const response = await api.get('/templates/packs');
+      setPacks(response.data || []);
+    } catch (error) {
+      console.error('Error loading template packs:', error);
+    }
+  };
+
+  const importPack = async (request) => {
+    setPackMessage('');
+    try {
+      const response = await request;
+      setPackMessage(`Imported ${response.data.name} ${response.data.version}`);
+      setPackURL('');
+      loadPacks();
+      loadTemplates();
+    } catch (error) {
+      setPackMessage(error.response?.data?.error || 'Failed to import template pack');
+    }
+  };
+
+  const handleImportURL = (e) => {
+    e.preventDefault();
+    if (packURL) importPack(api.post('/templates/packs', { url: packURL }));
+  };
+
+  const handleImportFile = (e) => {
+    const file = e.target.files[0];
+    if (!file) return;
+    const form = new FormData();
+    form.append('file', file);
+    importPack(api.post('/templates/packs', form));
+    e.target.value = '';
+  };
+
+  const handleUpdatePack = async (pack) => {
+    setPackMessage('');
+    try {
+      const response = await api.post(`/templates/packs/${pack.id}/update`);
+      setPackMessage(response.data.updated
+        ? `${pack.name} updated from ${response.data.from_version} to ${response.data.pack.version}`
+        : `${pack.name} is up to date`);
+      loadPacks();
+      loadTemplates();
+    } catch (error) {
+      setPackMessage(error.response?.data?.error || 'Failed to update template pack');
+    }
+  };
+
+  const handleDeletePack = async (pack) => {
+    if (!window.confirm(`Delete the pack ${pack.name} and its templates?`)) return;
+    try {
+      await api.delete(`/templates/packs/${pack.id}`);
+      loadPacks();
+      loadTemplates();
+    } catch (error) {
+      setPackMessage(error.response?.data?.error || 'Failed to delete template pack');
+    }
+  };
+
   const loadTemplates = async () => {
     try {
       const response = await api.get('/templates/');
@@ -162,6 +227,63 @@ function Templates() {
         </div>
       </div>
 
+      <div className="card template-packs">
+        <h3>Community Packs</h3>
+        <form className="pack-import" onSubmit={handleImportURL}>
+          <input
+            type="url"
+            value={packURL}
+            onChange={(e) => setPackURL(e.target.value)}
+            placeholder="https://packs.example.com/pack.json"
+          />
+          <button type="submit" className="btn btn-primary btn-sm">Import URL</button>
+          <label className="btn btn-secondary btn-sm">
+            Upload file
+            <input type="file" accept=".json,.yaml,.yml" onChange={handleImportFile} hidden />
+          </label>
+        </form>
+        {packMessage && <div className="pack-message">{packMessage}</div>}
+        {packs.length > 0 && (
+          <table>
+            <thead>
+              <tr>
+                <th>Pack</th>
+                <th>Version</th>
+                <th>Publisher</th>
+                <th>Signed</th>
+                <th>Templates</th>
+                <th>Source</th>
+                <th>Actions</th>
+              </tr>
+            </thead>
+            <tbody>
+              {packs.map(pack => (
+                <tr key={pack.id}>
+                  <td><span className="template-name">{pack.name}</span></td>
+                  <td>{pack.version}</td>
+                  <td>{pack.publisher || '-'}</td>
+                  <td>{pack.signed ? `✓ ${pack.key_id}` : 'unsigned'}</td>
+                  <td>{pack.nmap_templates} nmap, {pack.nuclei_templates} nuclei, {pack.ffuf_templates} ffuf</td>
+                  <td className="description-cell" title={pack.source_url || 'uploaded'}>{pack.source_url || 'uploaded'}</td>
+                  <td>
+                    <div className="actions-cell">
+                      {pack.source_url && (
+                        <button className="btn btn-secondary btn-sm" onClick={() => handleUpdatePack(pack)}>
+                          Update
+                        </button>
+                      )}
+                      <button className="btn btn-danger btn-sm" onClick={() => handleDeletePack(pack)}>
+                        Delete
+                      </button>
+                    </div>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+      </div>
+
       {filteredTemplates.length === 0 ? (
         <div className="card empty-state">
           <h3>No templates found</h3>
@@ -192,6 +314,7 @@ function Templates() {
                   <tr key={template.id}>
                     <td>
                       <span className="template-name">{template.name}</span>
+                      {template.pack_id && <span className="pack-badge">pack</span>}
                     </td>
                     <td className="description-cell" title={template.description}>
                       {template.description || '-'}
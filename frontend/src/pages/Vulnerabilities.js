@@ -24,12 +24,13 @@ function Vulnerabilities() {
 
   const loadScans = async () => {
     try {
-      const params = filter !== 'all' ? { status: filter } : {};
+      const params = filter !== 'all' ? { status: filter, limit: 100 } : { limit: 100 };
       const response = await goApi.get('/vulnerabilities/', { params });
-      setScans(response.data || []);
+      const items = response.data?.items || [];
+      setScans(items);
 
       // Load stats for completed scans
-      const statsPromises = (response.data || [])
+      const statsPromises = items
         .filter(s => s.status === 'completed')
         .map(async (scan) => {
           try {
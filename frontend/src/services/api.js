@@ -1,7 +1,7 @@
 import axios from 'axios';
 
 // Use empty string for relative URLs (nginx will proxy /api/ to gateway)
-const API_URL = process.env.REACT_APP_API_URL || '';
+export const API_URL = process.env.REACT_APP_API_URL || '';
 
 const api = axios.create({
   baseURL: `${API_URL}/api`,
@@ -9,8 +9,10 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/security-scanner/api-service/internal/database"
 	"github.com/security-scanner/api-service/internal/handlers"
+	"github.com/security-scanner/api-service/internal/middleware"
 	"github.com/security-scanner/api-service/internal/scanner"
 	"github.com/security-scanner/api-service/pkg/config"
+	"github.com/security-scanner/shared/pkg/internalauth"
 )
 
 func main() {
@@ -41,6 +43,13 @@ func main() {
 	// Initialize handlers
 	h := handlers.New(db, scannerManager)
 
+	// Scans of targets outside the scope rules are rejected
+	scopeChecker, err := db.Scope()
+	if err != nil {
+		log.Fatalf("Failed to initialize scope rules: %v", err)
+	}
+	h.SetScope(scopeChecker)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:       "Security Scanner - API Discovery Service",
@@ -49,6 +58,7 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(middleware.Errors()) // first, so it sees every error
 	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
@@ -60,8 +70,10 @@ func main() {
 	// Health check
 	app.Get("/health", h.HealthCheck)
 
-	// API routes
-	api := app.Group("/api")
+	// API routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalauth.LogStatus(cfg.InternalAuthSecret)
+	api := app.Group("/api", middleware.InternalAuth(cfg.InternalAuthSecret))
 
 	// API Scans
 	apiScans := api.Group("/apiscans")
@@ -78,6 +90,9 @@ func main() {
 	apiScans.Get("/:id/graphql", h.GetGraphQLSchemas)
 	apiScans.Get("/:id/swagger", h.GetSwaggerSpecs)
 
+	// Scan types and their config, for forms and config validation
+	api.Get("/capabilities", h.GetCapabilities)
+
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Port)
 	if err := app.Listen(":" + cfg.Port); err != nil {
@@ -6,6 +6,7 @@ require (
 	github.com/gofiber/fiber/v2 v2.52.0
 	github.com/google/uuid v1.5.0
 	github.com/lib/pq v1.10.9
+	github.com/security-scanner/shared v0.0.0
 )
 
 require (
@@ -20,3 +21,6 @@ require (
 	github.com/valyala/tcplisten v1.0.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
 )
+
+// Shared models, clients and database helpers (services/shared)
+replace github.com/security-scanner/shared => ../shared
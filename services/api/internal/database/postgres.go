@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/security-scanner/api-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/scope"
 )
 
 type Database struct {
@@ -17,39 +22,10 @@ type Database struct {
 }
 
 func New(connectionString string) (*Database, error) {
-	// Retry logic with exponential backoff
-	maxRetries := 10
-	var db *sql.DB
-	var err error
-
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", connectionString)
-		if err != nil {
-			waitTime := time.Duration(1<<uint(i)) * time.Second
-			if waitTime > 30*time.Second {
-				waitTime = 30 * time.Second
-			}
-			fmt.Printf("Failed to open database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
-		}
-
-		err = db.Ping()
-		if err == nil {
-			break
-		}
-
-		db.Close()
-		waitTime := time.Duration(1<<uint(i)) * time.Second
-		if waitTime > 30*time.Second {
-			waitTime = 30 * time.Second
-		}
-		fmt.Printf("Failed to ping database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-		time.Sleep(waitTime)
-	}
-
+	// Retries while the database starts, like every service
+	db, err := shareddb.OpenSQL("postgres", connectionString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
 
 	db.SetMaxOpenConns(25)
@@ -63,30 +39,41 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// Scope creates the scope rule table, managed through the gateway, and
+// returns the checker scans' targets must pass
+func (d *Database) Scope() (*scope.Checker, error) {
+	if _, err := d.db.Exec(scope.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}), nil
+}
+
 // ==================== API Scans ====================
 
 func (d *Database) CreateAPIScan(scan *models.APIScan) error {
 	query := `
-		INSERT INTO api_scans (id, name, target, scan_type, status, progress, config, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO api_scans (id, name, target, scan_type, status, progress, config, created_at, origin, project_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 	_, err := d.db.Exec(query,
 		scan.ID, scan.Name, scan.Target, scan.ScanType, scan.Status,
-		scan.Progress, scan.Config, scan.CreatedAt,
+		scan.Progress, scan.Config, scan.CreatedAt, scan.Origin, scan.ProjectID,
 	)
 	return err
 }
 
 func (d *Database) GetAPIScan(id uuid.UUID) (*models.APIScan, error) {
 	query := `
-		SELECT id, name, target, scan_type, status, progress, config, error,
+		SELECT id, name, target, scan_type, status, progress, config, error, origin, project_id,
 		       created_at, started_at, completed_at
 		FROM api_scans WHERE id = $1
 	`
 	var scan models.APIScan
 	err := d.db.QueryRow(query, id).Scan(
 		&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status,
-		&scan.Progress, &scan.Config, &scan.Error,
+		&scan.Progress, &scan.Config, &scan.Error, &scan.Origin, &scan.ProjectID,
 		&scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -95,19 +82,38 @@ func (d *Database) GetAPIScan(id uuid.UUID) (*models.APIScan, error) {
 	return &scan, err
 }
 
-func (d *Database) ListAPIScans(scanType string, status string, limit int) ([]models.APIScan, error) {
-	query := `
-		SELECT id, name, target, scan_type, status, progress, config, error,
-		       created_at, started_at, completed_at
-		FROM api_scans
+// ListAPIScans returns the page of the scans matching the non-empty filters
+// and how many match; originFilter must have been checked with
+// origin.ValidFilter
+func (d *Database) ListAPIScans(scanType string, status string, originFilter string, projectScope string, page pagination.Params) ([]models.APIScan, int, error) {
+	originCondition, originValue := "$3 = ''", ""
+	if originFilter != "" {
+		var err error
+		originCondition, originValue, err = origin.Filter("origin", 3, originFilter)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	where := `
 		WHERE ($1 = '' OR scan_type = $1)
 		  AND ($2 = '' OR status = $2)
-		ORDER BY created_at DESC
-		LIMIT $3
+		  AND (` + originCondition + `)
+		  AND ($4 = '' OR project_id = $4)
 	`
-	rows, err := d.db.Query(query, scanType, status, limit)
+	args := []interface{}{scanType, status, originValue, projectScope}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM api_scans`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, name, target, scan_type, status, progress, config, error, origin, project_id,
+		       created_at, started_at, completed_at
+		FROM api_scans` + where + page.OrderBy()
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -116,14 +122,14 @@ func (d *Database) ListAPIScans(scanType string, status string, limit int) ([]mo
 		var scan models.APIScan
 		if err := rows.Scan(
 			&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status,
-			&scan.Progress, &scan.Config, &scan.Error,
+			&scan.Progress, &scan.Config, &scan.Error, &scan.Origin, &scan.ProjectID,
 			&scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt,
 		); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		scans = append(scans, scan)
 	}
-	return scans, nil
+	return scans, total, nil
 }
 
 func (d *Database) UpdateAPIScanStatus(id uuid.UUID, status string, progress int, scanError *string) error {
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+// Options of APIScanConfig used by every scan type
+var (
+	timeoutOption = capabilities.Field{Name: "timeout", Type: capabilities.Integer, Minimum: capabilities.Bound(1), Description: "Seconds the tool may run"}
+	headersOption = capabilities.Field{Name: "headers", Type: capabilities.Object, Description: "Headers sent with every request, by name"}
+	stringList    = &capabilities.Field{Type: capabilities.String}
+)
+
+var (
+	kiterunnerOptions = []capabilities.Field{
+		{Name: "kiterunner_wordlist", Type: capabilities.String, Default: "routes-large", Description: "routes-large, routes-small or the absolute path of a .kite file"},
+	}
+	arjunOptions = []capabilities.Field{
+		{Name: "arjun_methods", Type: capabilities.Array, Default: []string{"GET", "POST"}, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"GET", "POST", "JSON", "XML"}}},
+		{Name: "arjun_wordlist", Type: capabilities.String, Description: "Path of a parameter wordlist"},
+		{Name: "arjun_threads", Type: capabilities.Integer, Default: 10, Minimum: capabilities.Bound(1)},
+	}
+	graphqlOptions = []capabilities.Field{
+		{Name: "graphql_endpoints", Type: capabilities.Array, Items: stringList, Description: "Paths checked instead of the common GraphQL ones"},
+	}
+	swaggerOptions = []capabilities.Field{
+		{Name: "swagger_endpoints", Type: capabilities.Array, Items: stringList, Description: "Paths checked instead of the common OpenAPI/Swagger ones"},
+	}
+)
+
+// apiCapabilities describes CreateAPIScanRequest for each scan type
+var apiCapabilities = capabilities.New("api", []capabilities.ScanType{
+	apiScanType("kiterunner", "API Route Discovery", "Kiterunner brute force of API routes", kiterunnerOptions),
+	apiScanType("arjun", "Parameter Discovery", "Hidden HTTP parameters with Arjun", arjunOptions),
+	apiScanType("graphql", "GraphQL Introspection", "GraphQL endpoints and their introspected schemas", graphqlOptions),
+	apiScanType("swagger", "OpenAPI Discovery", "OpenAPI/Swagger specifications and the endpoints they list", swaggerOptions),
+	apiScanType("full", "Full API Scan", "Every API scan type in turn",
+		append(append(append(append([]capabilities.Field{}, kiterunnerOptions...), arjunOptions...), graphqlOptions...), swaggerOptions...)),
+})
+
+// apiScanType describes a scan type whose config holds options and the
+// general ones
+func apiScanType(name, title, description string, options []capabilities.Field) capabilities.ScanType {
+	return capabilities.ScanType{
+		Name:        name,
+		Title:       title,
+		Description: description,
+		Tool:        name,
+		Endpoint:    "POST /api/apiscans",
+		Fields: []capabilities.Field{
+			{Name: "name", Type: capabilities.String, Required: true},
+			{Name: "target", Type: capabilities.String, Required: true, Format: "uri", Description: "Base URL of the API"},
+			{Name: "scan_type", Type: capabilities.String, Required: true, Default: name, Enum: []string{name}},
+			{Name: "config", Type: capabilities.Object, Fields: append(options, timeoutOption, headersOption)},
+		},
+	}
+}
+
+// GetCapabilities describes the scan types and their config, for UIs and
+// the CLI to render forms and validate configurations
+func (h *Handlers) GetCapabilities(c *fiber.Ctx) error {
+	return c.JSON(apiCapabilities)
+}
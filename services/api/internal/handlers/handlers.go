@@ -9,11 +9,17 @@ import (
 	"github.com/security-scanner/api-service/internal/database"
 	"github.com/security-scanner/api-service/internal/models"
 	"github.com/security-scanner/api-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/apierror"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
 )
 
 type Handlers struct {
 	db      *database.Database
 	scanner *scanner.Manager
+	scope   *scope.Checker
 }
 
 func New(db *database.Database, scannerManager *scanner.Manager) *Handlers {
@@ -23,6 +29,11 @@ func New(db *database.Database, scannerManager *scanner.Manager) *Handlers {
 	}
 }
 
+// SetScope rejects scans of targets outside the scope rules
+func (h *Handlers) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
 // CreateAPIScan creates a new API scan
 func (h *Handlers) CreateAPIScan(c *fiber.Ctx) error {
 	var req models.CreateAPIScanRequest
@@ -52,6 +63,9 @@ func (h *Handlers) CreateAPIScan(c *fiber.Ctx) error {
 	if !validTypes[req.ScanType] {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan_type. Must be one of: kiterunner, arjun, graphql, swagger, full"})
 	}
+	if status, body := scopeError(h.scope, project.FromRequest(c.Get(project.Header)), req.Target); status != 0 {
+		return c.Status(status).JSON(body)
+	}
 
 	scan := &models.APIScan{
 		ID:        uuid.New(),
@@ -61,6 +75,8 @@ func (h *Handlers) CreateAPIScan(c *fiber.Ctx) error {
 		Status:    "pending",
 		Progress:  0,
 		Config:    req.Config,
+		Origin:    origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)),
+		ProjectID: project.FromRequest(c.Get(project.Header)),
 		CreatedAt: time.Now(),
 	}
 
@@ -70,28 +86,49 @@ func (h *Handlers) CreateAPIScan(c *fiber.Ctx) error {
 
 	// Start scan
 	if err := h.scanner.StartScan(scan); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to start scan: " + err.Error()})
+		return c.Status(500).JSON(apierror.New(apierror.CodeToolFailure, "Failed to start scan", err.Error()))
 	}
 
 	return c.Status(201).JSON(scan)
 }
 
-// ListAPIScans lists all API scans
+// apiScanSortFields are the fields ListAPIScans sorts by, and their columns
+var apiScanSortFields = map[string]string{
+	"created_at":   "created_at",
+	"started_at":   "started_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"target":       "target",
+	"scan_type":    "scan_type",
+	"status":       "status",
+	"progress":     "progress",
+}
+
+// ListAPIScans lists a page of API scans (?page=, ?limit=, ?sort=)
 func (h *Handlers) ListAPIScans(c *fiber.Ctx) error {
 	scanType := c.Query("type", "")
 	status := c.Query("status", "")
-	limit := c.QueryInt("limit", 100)
+	originFilter := c.Query("origin", "")
+	if originFilter != "" {
+		if err := origin.ValidFilter(originFilter); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), apiScanSortFields, "-created_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	scans, err := h.db.ListAPIScans(scanType, status, limit)
+	scans, total, err := h.db.ListAPIScans(scanType, status, originFilter, project.Scope(c.Query("project"), c.Get(project.Header)), page)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to list scans: " + err.Error()})
 	}
 
-	if scans == nil {
-		scans = []models.APIScan{}
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
 	}
-
-	return c.JSON(scans)
+	return c.JSON(result)
 }
 
 // GetAPIScan gets a specific API scan
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/scope"
+)
+
+// scopeError checks the targets of a scan against the scope rules of its
+// project, returning the response to answer when they fail
+func scopeError(checker *scope.Checker, project string, targets ...string) (int, fiber.Map) {
+	err := checker.Check(context.Background(), project, targets...)
+	var outOfScope *scope.Error
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &outOfScope):
+		return 403, outOfScope.Response()
+	default:
+		log.Printf("Failed to check scan scope: %v", err)
+		return 503, fiber.Map{"error": "Failed to check the scan against the scope rules"}
+	}
+}
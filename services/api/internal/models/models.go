@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
 // APIScan represents an API discovery scan
@@ -17,6 +18,8 @@ type APIScan struct {
 	Progress    int             `json:"progress"`
 	Config      json.RawMessage `json:"config,omitempty"`
 	Error       *string         `json:"error,omitempty"`
+	Origin      string          `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID   string          `json:"project_id"`
 	CreatedAt   time.Time       `json:"created_at"`
 	StartedAt   *time.Time      `json:"started_at,omitempty"`
 	CompletedAt *time.Time      `json:"completed_at,omitempty"`
@@ -118,14 +121,8 @@ type SwaggerParam struct {
 	Required bool    `json:"required"`
 }
 
-// ScanLog represents a log entry for a scan
-type ScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"` // info, warning, error, debug
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
-}
+// ScanLog represents a log entry for a scan (shared by all services)
+type ScanLog = shared.ScanLog
 
 // CreateAPIScanRequest represents a request to create an API scan
 type CreateAPIScanRequest struct {
@@ -14,6 +14,9 @@ type Config struct {
 	FfufPath       string
 	NucleiPath     string
 	WordlistsPath  string
+
+	// API requests must be signed by the gateway with this secret (disabled when empty)
+	InternalAuthSecret string
 }
 
 func Load() *Config {
@@ -27,6 +30,8 @@ func Load() *Config {
 		FfufPath:       getEnv("FFUF_PATH", "/usr/local/bin/ffuf"),
 		NucleiPath:     getEnv("NUCLEI_PATH", "/usr/local/bin/nuclei"),
 		WordlistsPath:  getEnv("WORDLISTS_PATH", "/usr/share/wordlists"),
+
+		InternalAuthSecret: getEnv("INTERNAL_AUTH_SECRET", ""),
 	}
 }
 
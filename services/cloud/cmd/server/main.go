@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -8,7 +9,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/security-scanner/cloud-service/internal/database"
 	"github.com/security-scanner/cloud-service/internal/handlers"
+	"github.com/security-scanner/cloud-service/internal/middleware"
 	"github.com/security-scanner/cloud-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/internalauth"
 )
 
 func getEnv(key, defaultValue string) string {
@@ -30,6 +34,8 @@ func main() {
 	trivyPath := getEnv("TRIVY_PATH", "/usr/local/bin/trivy")
 	prowlerPath := getEnv("PROWLER_PATH", "/usr/local/bin/prowler")
 	scoutsuitePath := getEnv("SCOUTSUITE_PATH", "/usr/local/bin/scout")
+	gitleaksPath := getEnv("GITLEAKS_PATH", "/usr/local/bin/gitleaks")
+	trufflehogPath := getEnv("TRUFFLEHOG_PATH", "/usr/local/bin/trufflehog")
 
 	// Connect to database
 	db, err := database.New(dbHost, dbPort, dbUser, dbPassword, dbName)
@@ -41,41 +47,86 @@ func main() {
 	log.Println("Connected to database successfully")
 
 	// Create scan manager
-	manager := scanner.NewScanManager(db, trivyPath, prowlerPath, scoutsuitePath)
+	manager := scanner.NewScanManager(db, trivyPath, prowlerPath, scoutsuitePath, gitleaksPath, trufflehogPath)
+
+	// Scans with high-severity findings are posted to Slack and Discord
+	chatChannels, err := chat.FromEnv(getEnv("SLACK_WEBHOOK_URL", ""), getEnv("DISCORD_WEBHOOK_URL", ""),
+		getEnv("CHAT_NOTIFY_SEVERITIES", "critical,high"))
+	if err != nil {
+		log.Fatalf("Invalid chat notification configuration: %v", err)
+	}
+	chatNotifier, err := db.ChatNotifier(chatChannels, getEnv("REPORT_BASE_URL", "http://localhost:3000"))
+	if err != nil {
+		log.Fatalf("Failed to initialize chat notifications: %v", err)
+	}
+	manager.SetChat(chatNotifier)
+
+	// Prowler and ScoutSuite scans re-run on schedule; each run flags the
+	// checks failing since the previous one as configuration drift
+	leases, err := db.Leases()
+	if err != nil {
+		log.Fatalf("Failed to initialize job leases: %v", err)
+	}
+	scheduler := scanner.NewScheduler(db, manager)
+	scheduler.SetLeases(leases)
+	go scheduler.Start(context.Background())
+
+	// Uploaded source archives (and repositories cloned for gitleaks) are
+	// extracted here, one directory per scan, and removed when the scan ends
+	sources, err := scanner.NewSources(getEnv("SOURCES_PATH", "/root/sources"))
+	if err != nil {
+		log.Fatalf("Failed to initialize source scans: %v", err)
+	}
+	manager.SetSources(sources)
 
 	// Create handlers
 	h := handlers.NewHandler(db, manager)
+	h.SetScheduler(scheduler)
+	h.SetSources(sources)
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	r.Use(middleware.Errors())
 
 	// CORS configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
 		AllowCredentials: true,
 	}))
 
 	// Health check
 	r.GET("/health", h.HealthCheck)
 
-	// API routes
-	api := r.Group("/api")
+	// API routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalAuthSecret := getEnv("INTERNAL_AUTH_SECRET", "")
+	internalauth.LogStatus(internalAuthSecret)
+	api := r.Group("/api", middleware.InternalAuth(internalAuthSecret))
 	{
 		// Cloud Scans
 		cloudScans := api.Group("/cloudscans")
 		{
 			cloudScans.GET("/", h.GetScans)
+			cloudScans.GET("/findings", h.ListFindings) // normalized, for the gateway's /api/findings
+			cloudScans.GET("/schedules", h.GetSchedules)
+			cloudScans.POST("/schedules", h.CreateSchedule)
+			cloudScans.PUT("/schedules/:id", h.UpdateSchedule)
+			cloudScans.DELETE("/schedules/:id", h.DeleteSchedule)
+			cloudScans.POST("/schedules/:id/run", h.RunSchedule)
 			cloudScans.GET("/:id", h.GetScan)
 			cloudScans.POST("/", h.CreateScan)
+			cloudScans.POST("/source", h.CreateSourceScan)
 			cloudScans.DELETE("/:id", h.DeleteScan)
 			cloudScans.POST("/:id/cancel", h.CancelScan)
 			cloudScans.GET("/:id/findings", h.GetScanFindings)
 			cloudScans.GET("/:id/vulnerabilities", h.GetScanVulnerabilities)
 			cloudScans.GET("/:id/results", h.GetScanResults)
+			cloudScans.GET("/:id/secrets", h.GetScanSecrets)
+			cloudScans.GET("/:id/stats", h.GetScanStats)
 			cloudScans.GET("/:id/logs", h.GetScanLogs)
 		}
 
@@ -100,6 +151,9 @@ func main() {
 
 		// Tools info
 		api.GET("/tools", h.GetAvailableTools)
+
+		// Scan types and their config, for forms and config validation
+		api.GET("/capabilities", h.GetCapabilities)
 	}
 
 	// Start server
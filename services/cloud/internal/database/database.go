@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,6 +10,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/security-scanner/cloud-service/internal/models"
+	"github.com/security-scanner/shared/pkg/chat"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
 type Database struct {
@@ -19,39 +23,10 @@ func New(host, port, user, password, dbname string) (*Database, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
-	// Retry logic with exponential backoff
-	maxRetries := 10
-	var db *sql.DB
-	var err error
-
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", connStr)
-		if err != nil {
-			waitTime := time.Duration(1<<uint(i)) * time.Second
-			if waitTime > 30*time.Second {
-				waitTime = 30 * time.Second
-			}
-			fmt.Printf("Failed to open database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
-		}
-
-		err = db.Ping()
-		if err == nil {
-			break
-		}
-
-		db.Close()
-		waitTime := time.Duration(1<<uint(i)) * time.Second
-		if waitTime > 30*time.Second {
-			waitTime = 30 * time.Second
-		}
-		fmt.Printf("Failed to ping database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-		time.Sleep(waitTime)
-	}
-
+	// Retries while the database starts, like every service
+	db, err := shareddb.OpenSQL("postgres", connStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
 
 	database := &Database{db: db}
@@ -74,11 +49,16 @@ func (d *Database) initSchema() error {
 		progress INTEGER DEFAULT 0,
 		config JSONB,
 		summary JSONB,
+		origin VARCHAR(150) NOT NULL DEFAULT 'manual',
+		project_id VARCHAR(63) NOT NULL DEFAULT 'default',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		completed_at TIMESTAMP
 	);
 
+	ALTER TABLE cloud_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual';
+	ALTER TABLE cloud_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
 	CREATE TABLE IF NOT EXISTS cloud_findings (
 		id UUID PRIMARY KEY,
 		scan_id UUID REFERENCES cloud_scans(id) ON DELETE CASCADE,
@@ -123,10 +103,49 @@ func (d *Database) initSchema() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	ALTER TABLE cloud_findings ADD COLUMN IF NOT EXISTS drift BOOLEAN NOT NULL DEFAULT false;
+
+	CREATE TABLE IF NOT EXISTS cloud_schedules (
+		id UUID PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		provider VARCHAR(50) NOT NULL,
+		scan_type VARCHAR(50) NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		config JSONB,
+		interval_hours INTEGER NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT true,
+		project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+		last_scan_id UUID,
+		last_run_at TIMESTAMP,
+		next_run_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS secret_results (
+		id UUID PRIMARY KEY,
+		scan_id UUID REFERENCES cloud_scans(id) ON DELETE CASCADE,
+		tool VARCHAR(20) NOT NULL,
+		rule VARCHAR(255) NOT NULL,
+		file TEXT NOT NULL DEFAULT '',
+		line INTEGER NOT NULL DEFAULT 0,
+		commit_sha VARCHAR(64) NOT NULL DEFAULT '',
+		entropy REAL NOT NULL DEFAULT 0,
+		redacted TEXT NOT NULL,
+		match TEXT NOT NULL DEFAULT '',
+		fingerprint VARCHAR(32) NOT NULL,
+		verified BOOLEAN NOT NULL DEFAULT false,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_cloud_findings_scan_id ON cloud_findings(scan_id);
 	CREATE INDEX IF NOT EXISTS idx_cloud_findings_severity ON cloud_findings(severity);
 	CREATE INDEX IF NOT EXISTS idx_vulnerability_results_scan_id ON vulnerability_results(scan_id);
 	CREATE INDEX IF NOT EXISTS idx_cloud_scan_logs_scan_id ON cloud_scan_logs(scan_id);
+	CREATE INDEX IF NOT EXISTS idx_secret_results_scan_id ON secret_results(scan_id, fingerprint);
+	CREATE INDEX IF NOT EXISTS idx_cloud_scans_origin ON cloud_scans(origin);
+	CREATE INDEX IF NOT EXISTS idx_cloud_scans_project_id ON cloud_scans(project_id);
+	CREATE INDEX IF NOT EXISTS idx_cloud_schedules_next_run ON cloud_schedules(next_run_at) WHERE enabled;
 	`
 
 	_, err := d.db.Exec(schema)
@@ -137,15 +156,37 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// Leases creates the job lease table and returns the leases electing which
+// replica runs each background job
+func (d *Database) Leases() (*shareddb.Leases, error) {
+	if _, err := d.db.Exec(shareddb.LeaseSchemaSQL); err != nil {
+		return nil, err
+	}
+	return shareddb.NewLeases(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}), nil
+}
+
+// ChatNotifier creates the chat channel table, managed through the gateway,
+// and returns a notifier posting to its channels and to the env ones
+func (d *Database) ChatNotifier(env []chat.Channel, reportBaseURL string) (*chat.Notifier, error) {
+	if _, err := d.db.Exec(chat.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return chat.NewNotifier(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}, env, reportBaseURL), nil
+}
+
 // Scan operations
 func (d *Database) CreateScan(scan *models.CloudScan) error {
 	configJSON, _ := json.Marshal(scan.Config)
 	summaryJSON, _ := json.Marshal(scan.Summary)
 
 	_, err := d.db.Exec(`
-		INSERT INTO cloud_scans (id, name, provider, scan_type, target, status, progress, config, summary, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, scan.ID, scan.Name, scan.Provider, scan.ScanType, scan.Target, scan.Status, scan.Progress, configJSON, summaryJSON, scan.CreatedAt, scan.UpdatedAt)
+		INSERT INTO cloud_scans (id, name, provider, scan_type, target, status, progress, config, summary, origin, project_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, scan.ID, scan.Name, scan.Provider, scan.ScanType, scan.Target, scan.Status, scan.Progress, configJSON, summaryJSON, scan.Origin, scan.ProjectID, scan.CreatedAt, scan.UpdatedAt)
 
 	return err
 }
@@ -156,9 +197,9 @@ func (d *Database) GetScan(id uuid.UUID) (*models.CloudScan, error) {
 	var completedAt sql.NullTime
 
 	err := d.db.QueryRow(`
-		SELECT id, name, provider, scan_type, target, status, progress, config, summary, created_at, updated_at, completed_at
+		SELECT id, name, provider, scan_type, target, status, progress, config, summary, origin, project_id, created_at, updated_at, completed_at
 		FROM cloud_scans WHERE id = $1
-	`, id).Scan(&scan.ID, &scan.Name, &scan.Provider, &scan.ScanType, &scan.Target, &scan.Status, &scan.Progress, &configJSON, &summaryJSON, &scan.CreatedAt, &scan.UpdatedAt, &completedAt)
+	`, id).Scan(&scan.ID, &scan.Name, &scan.Provider, &scan.ScanType, &scan.Target, &scan.Status, &scan.Progress, &configJSON, &summaryJSON, &scan.Origin, &scan.ProjectID, &scan.CreatedAt, &scan.UpdatedAt, &completedAt)
 
 	if err != nil {
 		return nil, err
@@ -179,7 +220,7 @@ func (d *Database) GetScan(id uuid.UUID) (*models.CloudScan, error) {
 
 func (d *Database) GetAllScans() ([]models.CloudScan, error) {
 	rows, err := d.db.Query(`
-		SELECT id, name, provider, scan_type, target, status, progress, config, summary, created_at, updated_at, completed_at
+		SELECT id, name, provider, scan_type, target, status, progress, config, summary, origin, project_id, created_at, updated_at, completed_at
 		FROM cloud_scans ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -193,7 +234,7 @@ func (d *Database) GetAllScans() ([]models.CloudScan, error) {
 		var configJSON, summaryJSON []byte
 		var completedAt sql.NullTime
 
-		if err := rows.Scan(&scan.ID, &scan.Name, &scan.Provider, &scan.ScanType, &scan.Target, &scan.Status, &scan.Progress, &configJSON, &summaryJSON, &scan.CreatedAt, &scan.UpdatedAt, &completedAt); err != nil {
+		if err := rows.Scan(&scan.ID, &scan.Name, &scan.Provider, &scan.ScanType, &scan.Target, &scan.Status, &scan.Progress, &configJSON, &summaryJSON, &scan.Origin, &scan.ProjectID, &scan.CreatedAt, &scan.UpdatedAt, &completedAt); err != nil {
 			continue
 		}
 
@@ -237,16 +278,16 @@ func (d *Database) DeleteScan(id uuid.UUID) error {
 // Finding operations
 func (d *Database) SaveFinding(finding *models.CloudFinding) error {
 	_, err := d.db.Exec(`
-		INSERT INTO cloud_findings (id, scan_id, provider, service, region, resource_id, resource_arn, title, description, severity, status, compliance, remediation, source, raw_data, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-	`, finding.ID, finding.ScanID, finding.Provider, finding.Service, finding.Region, finding.ResourceID, finding.ResourceARN, finding.Title, finding.Description, finding.Severity, finding.Status, pq.Array(finding.Compliance), finding.Remediation, finding.Source, finding.RawData, finding.CreatedAt)
+		INSERT INTO cloud_findings (id, scan_id, provider, service, region, resource_id, resource_arn, title, description, severity, status, compliance, remediation, source, drift, raw_data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	`, finding.ID, finding.ScanID, finding.Provider, finding.Service, finding.Region, finding.ResourceID, finding.ResourceARN, finding.Title, finding.Description, finding.Severity, finding.Status, pq.Array(finding.Compliance), finding.Remediation, finding.Source, finding.Drift, finding.RawData, finding.CreatedAt)
 
 	return err
 }
 
 func (d *Database) GetFindings(scanID uuid.UUID) ([]models.CloudFinding, error) {
 	rows, err := d.db.Query(`
-		SELECT id, scan_id, provider, service, region, resource_id, resource_arn, title, description, severity, status, compliance, remediation, source, raw_data, created_at
+		SELECT id, scan_id, provider, service, region, resource_id, resource_arn, title, description, severity, status, compliance, remediation, source, drift, raw_data, created_at
 		FROM cloud_findings WHERE scan_id = $1 ORDER BY
 			CASE severity
 				WHEN 'CRITICAL' THEN 1
@@ -264,7 +305,7 @@ func (d *Database) GetFindings(scanID uuid.UUID) ([]models.CloudFinding, error)
 	var findings []models.CloudFinding
 	for rows.Next() {
 		var f models.CloudFinding
-		if err := rows.Scan(&f.ID, &f.ScanID, &f.Provider, &f.Service, &f.Region, &f.ResourceID, &f.ResourceARN, &f.Title, &f.Description, &f.Severity, &f.Status, pq.Array(&f.Compliance), &f.Remediation, &f.Source, &f.RawData, &f.CreatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.ScanID, &f.Provider, &f.Service, &f.Region, &f.ResourceID, &f.ResourceARN, &f.Title, &f.Description, &f.Severity, &f.Status, pq.Array(&f.Compliance), &f.Remediation, &f.Source, &f.Drift, &f.RawData, &f.CreatedAt); err != nil {
 			continue
 		}
 		findings = append(findings, f)
@@ -273,6 +314,42 @@ func (d *Database) GetFindings(scanID uuid.UUID) ([]models.CloudFinding, error)
 	return findings, nil
 }
 
+// ListFindings returns the failed checks and vulnerabilities of every scan,
+// or of one scan, as normalized findings
+func (d *Database) ListFindings(scanID *uuid.UUID, projectScope string) ([]models.Finding, error) {
+	query := `
+		SELECT id, scan_id, source, title, severity,
+			COALESCE(NULLIF(resource_arn, ''), NULLIF(resource_id, ''), service || COALESCE('/' || NULLIF(region, ''), '')), '', created_at
+		FROM cloud_findings WHERE status <> 'PASS' AND ($1::uuid IS NULL OR scan_id = $1)
+		  AND ($2 = '' OR scan_id IN (SELECT id FROM cloud_scans WHERE project_id = $2))
+		UNION ALL
+		SELECT id, scan_id, 'trivy', COALESCE(pkg_name || ': ', '') || COALESCE(NULLIF(title, ''), vulnerability_id), severity,
+			target, vulnerability_id, created_at
+		FROM vulnerability_results WHERE ($1::uuid IS NULL OR scan_id = $1)
+		  AND ($2 = '' OR scan_id IN (SELECT id FROM cloud_scans WHERE project_id = $2))
+	`
+	rows, err := d.db.Query(query, scanID, projectScope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []models.Finding{}
+	for rows.Next() {
+		f := models.Finding{Source: "cloudscans"}
+		var severity string
+		var target sql.NullString
+		if err := rows.Scan(&f.ID, &f.ScanID, &f.Tool, &f.Title, &severity, &target, &f.Identifier, &f.CreatedAt); err != nil {
+			continue
+		}
+		f.Target = target.String
+		f.Severity = shared.NormalizeSeverity(severity)
+		findings = append(findings, f)
+	}
+
+	return findings, nil
+}
+
 // Vulnerability operations
 func (d *Database) SaveVulnerability(vuln *models.VulnerabilityResult) error {
 	_, err := d.db.Exec(`
@@ -344,6 +421,26 @@ func (d *Database) GetLogs(scanID uuid.UUID) ([]models.ScanLog, error) {
 	return logs, nil
 }
 
+// CountFailedBySeverity counts the checks of source (prowler, scoutsuite,
+// ...) that didn't pass in a scan, by normalized severity
+func (d *Database) CountFailedBySeverity(scanID uuid.UUID, source string) map[string]int {
+	counts := map[string]int{}
+	rows, err := d.db.Query(`SELECT severity, COUNT(*) FROM cloud_findings
+		WHERE scan_id = $1 AND source = $2 AND status <> 'PASS' GROUP BY severity`, scanID, source)
+	if err != nil {
+		return counts
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var severity string
+		var count int
+		if rows.Scan(&severity, &count) == nil {
+			counts[shared.NormalizeSeverity(severity)] += count
+		}
+	}
+	return counts
+}
+
 // Summary calculation
 func (d *Database) CalculateSummary(scanID uuid.UUID) *models.CloudScanSummary {
 	summary := &models.CloudScanSummary{}
@@ -355,6 +452,7 @@ func (d *Database) CalculateSummary(scanID uuid.UUID) *models.CloudScanSummary {
 	d.db.QueryRow(`SELECT COUNT(*) FROM cloud_findings WHERE scan_id = $1 AND severity = 'LOW'`, scanID).Scan(&summary.Low)
 	d.db.QueryRow(`SELECT COUNT(*) FROM cloud_findings WHERE scan_id = $1 AND severity = 'INFO'`, scanID).Scan(&summary.Info)
 	d.db.QueryRow(`SELECT COUNT(*) FROM cloud_findings WHERE scan_id = $1 AND status = 'PASS'`, scanID).Scan(&summary.Passed)
+	d.db.QueryRow(`SELECT COUNT(*) FROM cloud_findings WHERE scan_id = $1 AND drift`, scanID).Scan(&summary.Drift)
 
 	// Add vulnerabilities
 	var vulnCritical, vulnHigh, vulnMedium, vulnLow int
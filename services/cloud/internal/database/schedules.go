@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/models"
+	shared "github.com/security-scanner/shared/pkg/models"
+)
+
+const scheduleColumns = `id, name, provider, scan_type, target, config, interval_hours, enabled, project_id,
+	last_scan_id, last_run_at, next_run_at, created_at, updated_at`
+
+type scheduleRow interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSchedule(row scheduleRow) (*models.CloudSchedule, error) {
+	var s models.CloudSchedule
+	var configJSON []byte
+	var lastScanID uuid.NullUUID
+	var lastRunAt sql.NullTime
+	err := row.Scan(&s.ID, &s.Name, &s.Provider, &s.ScanType, &s.Target, &configJSON, &s.IntervalHours, &s.Enabled,
+		&s.ProjectID, &lastScanID, &lastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if configJSON != nil {
+		json.Unmarshal(configJSON, &s.Config)
+	}
+	if lastScanID.Valid {
+		s.LastScanID = &lastScanID.UUID
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+	return &s, nil
+}
+
+// Schedule operations
+func (d *Database) CreateSchedule(s *models.CloudSchedule) error {
+	configJSON, _ := json.Marshal(s.Config)
+	_, err := d.db.Exec(`
+		INSERT INTO cloud_schedules (id, name, provider, scan_type, target, config, interval_hours, enabled, project_id, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, s.ID, s.Name, s.Provider, s.ScanType, s.Target, configJSON, s.IntervalHours, s.Enabled, s.ProjectID, s.NextRunAt, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+// GetSchedules returns the schedules of a project, or of every project when
+// projectScope is empty
+func (d *Database) GetSchedules(projectScope string) ([]models.CloudSchedule, error) {
+	rows, err := d.db.Query(`SELECT `+scheduleColumns+` FROM cloud_schedules
+		WHERE $1 = '' OR project_id = $1 ORDER BY created_at DESC`, projectScope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.CloudSchedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			continue
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, nil
+}
+
+func (d *Database) GetSchedule(id uuid.UUID) (*models.CloudSchedule, error) {
+	return scanSchedule(d.db.QueryRow(`SELECT `+scheduleColumns+` FROM cloud_schedules WHERE id = $1`, id))
+}
+
+// UpdateSchedule changes the interval and state of a schedule; its next run
+// is moved to the new interval after the last one
+func (d *Database) UpdateSchedule(id uuid.UUID, intervalHours int, enabled bool) error {
+	_, err := d.db.Exec(`
+		UPDATE cloud_schedules SET interval_hours = $1, enabled = $2, updated_at = NOW(),
+			next_run_at = CASE WHEN interval_hours = $1 THEN next_run_at
+				ELSE COALESCE(last_run_at, created_at) + make_interval(hours => $1) END
+		WHERE id = $3
+	`, intervalHours, enabled, id)
+	return err
+}
+
+func (d *Database) DeleteSchedule(id uuid.UUID) error {
+	_, err := d.db.Exec(`DELETE FROM cloud_schedules WHERE id = $1`, id)
+	return err
+}
+
+// DueSchedules returns the enabled schedules whose next run is due
+func (d *Database) DueSchedules() ([]models.CloudSchedule, error) {
+	rows, err := d.db.Query(`SELECT ` + scheduleColumns + ` FROM cloud_schedules
+		WHERE enabled AND next_run_at <= NOW() ORDER BY next_run_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.CloudSchedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			continue
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, nil
+}
+
+// MarkScheduleRun records the scan a schedule started and when it runs next
+func (d *Database) MarkScheduleRun(id, scanID uuid.UUID, next time.Time) error {
+	_, err := d.db.Exec(`
+		UPDATE cloud_schedules SET last_scan_id = $1, last_run_at = NOW(), next_run_at = $2, updated_at = NOW()
+		WHERE id = $3
+	`, scanID, next, id)
+	return err
+}
+
+// PreviousScan returns the latest completed scan with the same origin as
+// scan (the previous run of its schedule), or nil
+func (d *Database) PreviousScan(scan *models.CloudScan) (*uuid.UUID, error) {
+	var id uuid.UUID
+	err := d.db.QueryRow(`
+		SELECT id FROM cloud_scans
+		WHERE origin = $1 AND id <> $2 AND status = 'completed' AND created_at < $3
+		ORDER BY created_at DESC LIMIT 1
+	`, scan.Origin, scan.ID, scan.CreatedAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// failedCheckMatch matches a failed check of f in scan $2: same tool,
+// check, service, region and resource
+const failedCheckMatch = `
+	SELECT 1 FROM cloud_findings p
+	WHERE p.scan_id = $2 AND p.status <> 'PASS' AND p.source = f.source AND p.title = f.title
+	  AND COALESCE(p.service, '') = COALESCE(f.service, '') AND COALESCE(p.region, '') = COALESCE(f.region, '')
+	  AND COALESCE(p.resource_id, '') = COALESCE(f.resource_id, '')`
+
+// MarkDrift flags the failed checks of scanID that didn't fail in
+// previousID, and counts them and the checks that failed in previousID but
+// no longer do
+func (d *Database) MarkDrift(scanID, previousID uuid.UUID) (drifted, resolved int, err error) {
+	res, err := d.db.Exec(`
+		UPDATE cloud_findings f SET drift = true
+		WHERE f.scan_id = $1 AND f.status <> 'PASS' AND NOT EXISTS (`+failedCheckMatch+`)
+	`, scanID, previousID)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, _ := res.RowsAffected()
+	drifted = int(n)
+
+	// The same match, the other way round
+	err = d.db.QueryRow(`
+		SELECT COUNT(*) FROM cloud_findings f
+		WHERE f.scan_id = $1 AND f.status <> 'PASS' AND NOT EXISTS (`+failedCheckMatch+`)
+	`, previousID, scanID).Scan(&resolved)
+	return drifted, resolved, err
+}
+
+// CountDriftBySeverity counts the drift findings of a scan by normalized
+// severity
+func (d *Database) CountDriftBySeverity(scanID uuid.UUID) map[string]int {
+	counts := map[string]int{}
+	rows, err := d.db.Query(`SELECT severity, COUNT(*) FROM cloud_findings
+		WHERE scan_id = $1 AND drift GROUP BY severity`, scanID)
+	if err != nil {
+		return counts
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var severity string
+		var count int
+		if rows.Scan(&severity, &count) == nil {
+			counts[shared.NormalizeSeverity(severity)] += count
+		}
+	}
+	return counts
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/models"
+)
+
+// topSecretFiles is how many files SecretStats lists
+const topSecretFiles = 10
+
+// SecretFilter narrows the secrets of a scan
+type SecretFilter struct {
+	Rule     string
+	File     string // substring of the path
+	Verified *bool
+}
+
+// SaveSecretResults stores the occurrences of the secrets found by a scan
+func (d *Database) SaveSecretResults(results []models.SecretResult) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO secret_results (id, scan_id, tool, rule, file, line, commit_sha, entropy, redacted, match, fingerprint, verified, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range results {
+		if _, err := stmt.Exec(r.ID, r.ScanID, r.Tool, r.Rule, r.File, r.Line, r.Commit, r.Entropy, r.Redacted, r.Match, r.Fingerprint, r.Verified, r.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSecretResults returns the secret occurrences of a scan, verified
+// secrets first, then by file and line
+func (d *Database) GetSecretResults(scanID uuid.UUID, filter SecretFilter) ([]models.SecretResult, error) {
+	rows, err := d.db.Query(`
+		SELECT id, scan_id, tool, rule, file, line, commit_sha, entropy, redacted, match, fingerprint, verified, created_at
+		FROM secret_results
+		WHERE scan_id = $1 AND ($2 = '' OR rule = $2) AND ($3 = '' OR file ILIKE '%' || $3 || '%')
+		  AND ($4::boolean IS NULL OR verified = $4)
+		ORDER BY verified DESC, file, line
+	`, scanID, filter.Rule, filter.File, filter.Verified)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SecretResult{}
+	for rows.Next() {
+		var r models.SecretResult
+		if err := rows.Scan(&r.ID, &r.ScanID, &r.Tool, &r.Rule, &r.File, &r.Line, &r.Commit, &r.Entropy, &r.Redacted, &r.Match, &r.Fingerprint, &r.Verified, &r.CreatedAt); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// SecretStats counts the secrets of a scan, by rule and by file
+func (d *Database) SecretStats(scanID uuid.UUID) (*models.SecretStats, error) {
+	stats := &models.SecretStats{ByRule: map[string]int{}, TopFiles: []models.FileCount{}}
+	err := d.db.QueryRow(`
+		SELECT COUNT(DISTINCT fingerprint), COUNT(*), COUNT(DISTINCT fingerprint) FILTER (WHERE verified),
+			COUNT(DISTINCT file), COUNT(DISTINCT NULLIF(commit_sha, ''))
+		FROM secret_results WHERE scan_id = $1
+	`, scanID).Scan(&stats.Secrets, &stats.Occurrences, &stats.Verified, &stats.Files, &stats.Commits)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`SELECT rule, COUNT(DISTINCT fingerprint) FROM secret_results
+		WHERE scan_id = $1 GROUP BY rule`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rule string
+		var count int
+		if rows.Scan(&rule, &count) == nil {
+			stats.ByRule[rule] = count
+		}
+	}
+
+	files, err := d.db.Query(`SELECT file, COUNT(*) FROM secret_results
+		WHERE scan_id = $1 GROUP BY file ORDER BY COUNT(*) DESC, file LIMIT $2`, scanID, topSecretFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer files.Close()
+	for files.Next() {
+		var f models.FileCount
+		if files.Scan(&f.File, &f.Count) == nil {
+			stats.TopFiles = append(stats.TopFiles, f)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+var (
+	stringList = &capabilities.Field{Type: capabilities.String}
+
+	accountOptions = []capabilities.Field{
+		{Name: "aws_profile", Type: capabilities.String},
+		{Name: "aws_regions", Type: capabilities.Array, Items: stringList},
+		{Name: "aws_services", Type: capabilities.Array, Items: stringList},
+		{Name: "azure_subscription", Type: capabilities.String},
+		{Name: "azure_tenant_id", Type: capabilities.String},
+		{Name: "gcp_project", Type: capabilities.String},
+	}
+	trivyTargetOptions = []capabilities.Field{
+		{Name: "trivy_target", Type: capabilities.String, Description: "Image name, filesystem path or repository URL; the scan's target when empty"},
+		{Name: "trivy_target_type", Type: capabilities.String, Default: "image", Enum: []string{"image", "fs", "repo", "config"}},
+	}
+	trivyScanOptions = []capabilities.Field{
+		{Name: "trivy_severities", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}}},
+		{Name: "trivy_ignore_unfixed", Type: capabilities.Boolean, Default: false},
+	}
+	repoOptions = []capabilities.Field{
+		{Name: "trivy_branch", Type: capabilities.String, Description: "Branch to scan, the default one when empty"},
+		{Name: "trivy_commit", Type: capabilities.String, Description: "Commit to scan"},
+	}
+	trivyOptions   = concat(trivyTargetOptions, trivyScanOptions, repoOptions)
+	secretsOptions = []capabilities.Field{
+		{Name: "secrets_tool", Type: capabilities.String, Default: "gitleaks", Enum: []string{"gitleaks", "trufflehog"}},
+	}
+	prowlerOptions = []capabilities.Field{
+		{Name: "prowler_checks", Type: capabilities.Array, Items: stringList},
+		{Name: "prowler_compliance", Type: capabilities.String, Description: "Compliance framework, e.g. cis, pci or hipaa"},
+	}
+	scoutsuiteOptions = []capabilities.Field{
+		{Name: "scoutsuite_services", Type: capabilities.Array, Items: stringList},
+		{Name: "scoutsuite_rules", Type: capabilities.Array, Items: stringList},
+	}
+	bucketOptions = []capabilities.Field{
+		{Name: "bucket_providers", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"aws", "gcp", "azure"}}, Description: "The scan's provider, or all of them for provider all"},
+		{Name: "bucket_keywords", Type: capabilities.Array, Items: stringList, Description: "Extra words for the name permutations"},
+		{Name: "bucket_max_names", Type: capabilities.Integer, Default: 600, Minimum: capabilities.Bound(1)},
+		{Name: "bucket_write_test", Type: capabilities.Boolean, Default: false, Description: "Upload and delete a test object in each existing bucket"},
+	}
+)
+
+var cloudProviders = []string{"aws", "azure", "gcp", "docker"}
+
+// cloudCapabilities describes CreateCloudScanRequest for each scan type
+var cloudCapabilities = capabilities.New("cloud", []capabilities.ScanType{
+	cloudScanType("trivy", "trivy", "Trivy Scan", "Vulnerabilities and misconfigurations of an image, filesystem or repository", cloudProviders, false, trivyOptions),
+	cloudScanType("image", "trivy", "Container Image Scan", "Trivy scan of the container image named by target", cloudProviders, true, nil),
+	cloudScanType("repo", "trivy", "Git Repository Scan", "Trivy scan of the dependencies, secrets and IaC of the Git repository at target (http(s) URL)", cloudProviders, true, concat(trivyScanOptions, repoOptions)),
+	cloudScanType("secrets", "secrets", "Secrets Scan", "Committed credentials in the history of the Git repository at target (http(s) URL), merged by secret and masked", cloudProviders, true, secretsOptions),
+	sourceScanType,
+	cloudScanType("config", "trivy", "IaC Scan", "Trivy misconfiguration scan of the infrastructure as code at target", cloudProviders, true, nil),
+	cloudScanType("prowler", "prowler", "Prowler Audit", "Security best practices and compliance checks of a cloud account", cloudProviders, false, concat(accountOptions, prowlerOptions)),
+	cloudScanType("scoutsuite", "scoutsuite", "ScoutSuite Audit", "Configuration audit of a cloud account", cloudProviders, false, concat(accountOptions, scoutsuiteOptions)),
+	cloudScanType("buckets", "buckets", "Bucket Enumeration", "Public S3, GCS and Azure Blob storage named after the target", append(cloudProviders, "all"), true, bucketOptions),
+	cloudScanType("full", "full", "Full Cloud Scan", "ScoutSuite, Prowler and Trivy in turn", cloudProviders, false,
+		concat(accountOptions, scoutsuiteOptions, prowlerOptions, trivyOptions)),
+})
+
+// sourceScanType describes the multipart upload of CreateSourceScan
+var sourceScanType = capabilities.ScanType{
+	Name:        "source",
+	Title:       "Source Archive Scan",
+	Description: "Trivy scan of the dependencies, secrets and IaC of an uploaded .zip, .tar, .tar.gz or .tgz archive, or secrets scan of it",
+	Tool:        "trivy",
+	Endpoint:    "POST /api/cloudscans/source",
+	Fields: []capabilities.Field{
+		{Name: "file", Type: capabilities.String, Required: true, Format: "binary", Description: "Multipart upload, at most 100 MB"},
+		{Name: "name", Type: capabilities.String, Description: "The archive's name when empty"},
+		{Name: "scan_type", Type: capabilities.String, Default: "source", Enum: []string{"source", "secrets"}},
+		{Name: "secrets_tool", Type: capabilities.String, Default: "gitleaks", Enum: []string{"gitleaks", "trufflehog"}, Description: "secrets: the tool to run"},
+		{Name: "provider", Type: capabilities.String, Default: "docker", Enum: cloudProviders},
+		{Name: "trivy_severities", Type: capabilities.String, Description: "Comma-separated, e.g. CRITICAL,HIGH"},
+		{Name: "trivy_ignore_unfixed", Type: capabilities.Boolean, Default: false},
+	},
+}
+
+// cloudScanType describes a scan type whose config holds options and the
+// general ones
+func cloudScanType(name, tool, title, description string, providers []string, targetRequired bool, options []capabilities.Field) capabilities.ScanType {
+	return capabilities.ScanType{
+		Name:        name,
+		Title:       title,
+		Description: description,
+		Tool:        tool,
+		Endpoint:    "POST /api/cloudscans",
+		Fields: []capabilities.Field{
+			{Name: "name", Type: capabilities.String, Required: true},
+			{Name: "provider", Type: capabilities.String, Required: true, Enum: providers},
+			{Name: "scan_type", Type: capabilities.String, Required: true, Default: name, Enum: []string{name}},
+			{Name: "target", Type: capabilities.String, Required: targetRequired},
+			{Name: "config", Type: capabilities.Object, Fields: concat(options, []capabilities.Field{
+				{Name: "timeout", Type: capabilities.Integer, Minimum: capabilities.Bound(1), Description: "Seconds the tool may run"},
+			})},
+			{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no cloud or registry is contacted"},
+		},
+	}
+}
+
+func concat(lists ...[]capabilities.Field) []capabilities.Field {
+	fields := []capabilities.Field{}
+	for _, list := range lists {
+		fields = append(fields, list...)
+	}
+	return fields
+}
+
+// GetCapabilities describes the scan types and their config, for UIs and
+// the CLI to render forms and validate configurations
+func (h *Handler) GetCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, cloudCapabilities)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,11 +10,17 @@ import (
 	"github.com/security-scanner/cloud-service/internal/database"
 	"github.com/security-scanner/cloud-service/internal/models"
 	"github.com/security-scanner/cloud-service/internal/scanner"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
 )
 
 type Handler struct {
-	db      *database.Database
-	manager *scanner.ScanManager
+	db        *database.Database
+	manager   *scanner.ScanManager
+	scheduler *scanner.Scheduler
+	sources   *scanner.Sources
 }
 
 func NewHandler(db *database.Database, manager *scanner.ScanManager) *Handler {
@@ -23,10 +30,69 @@ func NewHandler(db *database.Database, manager *scanner.ScanManager) *Handler {
 	}
 }
 
-// GetScans returns all cloud scans
+// cloudScanSortFields are the fields GetScans sorts by
+var cloudScanSortFields = map[string]string{
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"provider":     "provider",
+	"scan_type":    "scan_type",
+	"target":       "target",
+	"status":       "status",
+	"progress":     "progress",
+}
+
+// cloudScanLess orders scans by a field of cloudScanSortFields, ascending
+func cloudScanLess(field string) func(a, b models.CloudScan) bool {
+	switch field {
+	case "updated_at":
+		return func(a, b models.CloudScan) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case "completed_at":
+		return func(a, b models.CloudScan) bool {
+			var at, bt time.Time
+			if a.CompletedAt != nil {
+				at = *a.CompletedAt
+			}
+			if b.CompletedAt != nil {
+				bt = *b.CompletedAt
+			}
+			return at.Before(bt)
+		}
+	case "name":
+		return func(a, b models.CloudScan) bool { return a.Name < b.Name }
+	case "provider":
+		return func(a, b models.CloudScan) bool { return a.Provider < b.Provider }
+	case "scan_type":
+		return func(a, b models.CloudScan) bool { return a.ScanType < b.ScanType }
+	case "target":
+		return func(a, b models.CloudScan) bool { return a.Target < b.Target }
+	case "status":
+		return func(a, b models.CloudScan) bool { return a.Status < b.Status }
+	case "progress":
+		return func(a, b models.CloudScan) bool { return a.Progress < b.Progress }
+	default:
+		return func(a, b models.CloudScan) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	}
+}
+
+// GetScans returns a page of cloud scans (?page=, ?limit=, ?sort=)
 func (h *Handler) GetScans(c *gin.Context) {
-	// Optional filter by provider
+	// Optional filters by provider, origin and project
 	provider := c.Query("provider")
+	scope := project.Scope(c.Query("project"), c.GetHeader(project.Header))
+	originFilter := c.Query("origin")
+	if originFilter != "" {
+		if err := origin.ValidFilter(originFilter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), cloudScanSortFields, "-created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	scans, err := h.db.GetAllScans()
 	if err != nil {
@@ -34,21 +100,23 @@ func (h *Handler) GetScans(c *gin.Context) {
 		return
 	}
 
-	// Filter by provider if specified
-	if provider != "" {
+	// Filter by provider, origin and project if specified
+	if provider != "" || originFilter != "" || scope != "" {
 		var filtered []models.CloudScan
 		for _, scan := range scans {
-			if scan.Provider == provider {
+			if (provider == "" || scan.Provider == provider) && (originFilter == "" || origin.Match(scan.Origin, originFilter)) &&
+				project.Match(scan.ProjectID, scope) {
 				filtered = append(filtered, scan)
 			}
 		}
 		scans = filtered
 	}
 
-	if scans == nil {
-		scans = []models.CloudScan{}
+	result := pagination.Slice(scans, page, cloudScanLess(page.Field))
+	for key, value := range result.Headers(c.Request.URL.RequestURI()) {
+		c.Header(key, value)
 	}
-	c.JSON(http.StatusOK, scans)
+	c.JSON(http.StatusOK, result)
 }
 
 // GetScan returns a single cloud scan
@@ -83,6 +151,10 @@ func (h *Handler) CreateScan(c *gin.Context) {
 		"gcp":    true,
 		"docker": true,
 	}
+	// Bucket enumeration can check every cloud at once
+	if req.ScanType == "buckets" && req.Provider == "all" {
+		validProviders["all"] = true
+	}
 	if !validProviders[req.Provider] {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider. Must be: aws, azure, gcp, or docker"})
 		return
@@ -93,15 +165,56 @@ func (h *Handler) CreateScan(c *gin.Context) {
 		"trivy":      true,
 		"prowler":    true,
 		"scoutsuite": true,
+		"buckets":    true,
 		"image":      true,
 		"config":     true,
+		"repo":       true,
+		"secrets":    true,
 		"full":       true,
 	}
 	if !validTypes[req.ScanType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan type. Must be: trivy, prowler, scoutsuite, image, config, or full"})
+		msg := "Invalid scan type. Must be: trivy, prowler, scoutsuite, buckets, image, config, repo, secrets, or full"
+		if req.ScanType == "source" {
+			msg = "Source scans are created by uploading an archive to POST /api/cloudscans/source"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if req.ScanType == "buckets" && req.Target == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target is required for bucket enumeration (organization names or domains)"})
 		return
 	}
 
+	if (req.ScanType == "repo" || req.ScanType == "secrets") && !validRepoURL(req.Target) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target must be the http(s) URL of a Git repository, without credentials, for " + req.ScanType + " scans"})
+		return
+	}
+	if req.Config != nil {
+		if !validSecretsTool(req.Config.SecretsTool) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid secrets_tool. Must be: gitleaks or trufflehog"})
+			return
+		}
+		// Set by the server for uploaded archives only
+		req.Config.SourceArchive = ""
+		repo := req.Config.TrivyTarget
+		if repo == "" {
+			repo = req.Target
+		}
+		if req.Config.TrivyTargetType == "repo" && !validRepoURL(repo) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "trivy_target must be the http(s) URL of a Git repository, without credentials, for repo scans"})
+			return
+		}
+	}
+
+	// Simulated scans are flagged in their config so their findings are never
+	// mistaken for real ones
+	if req.Simulate {
+		if req.Config == nil {
+			req.Config = &models.CloudScanConfig{}
+		}
+		req.Config.Simulate = true
+	}
+
 	scan := &models.CloudScan{
 		ID:        uuid.New(),
 		Name:      req.Name,
@@ -111,6 +224,8 @@ func (h *Handler) CreateScan(c *gin.Context) {
 		Status:    "pending",
 		Progress:  0,
 		Config:    req.Config,
+		Origin:    origin.FromRequest(c.GetHeader(origin.Header), c.GetHeader(origin.UserIDHeader)),
+		ProjectID: project.FromRequest(c.GetHeader(project.Header)),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -169,8 +284,9 @@ func (h *Handler) GetScanFindings(c *gin.Context) {
 		return
 	}
 
-	// Optional severity filter
+	// Optional severity and drift filters
 	severity := c.Query("severity")
+	driftOnly := c.Query("drift") == "true"
 
 	findings, err := h.db.GetFindings(id)
 	if err != nil {
@@ -178,11 +294,11 @@ func (h *Handler) GetScanFindings(c *gin.Context) {
 		return
 	}
 
-	// Filter by severity if specified
-	if severity != "" {
+	// Filter by severity and drift if specified
+	if severity != "" || driftOnly {
 		var filtered []models.CloudFinding
 		for _, f := range findings {
-			if f.Severity == severity {
+			if (severity == "" || f.Severity == severity) && (!driftOnly || f.Drift) {
 				filtered = append(filtered, f)
 			}
 		}
@@ -195,6 +311,26 @@ func (h *Handler) GetScanFindings(c *gin.Context) {
 	c.JSON(http.StatusOK, findings)
 }
 
+// ListFindings returns the findings of every scan as normalized findings,
+// which the gateway lists with the other services' findings (?severity=,
+// ?target=, ?scan_id=, ?limit=)
+func (h *Handler) ListFindings(c *gin.Context) {
+	filter, err := shared.ParseFindingFilter(c.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	findings, err := h.db.ListFindings(filter.ScanID, project.Scope(c.Query("project"), c.GetHeader(project.Header)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch findings"})
+		return
+	}
+	list := filter.Apply(findings)
+	c.Header(pagination.HeaderTotalCount, strconv.Itoa(list.Total))
+	c.JSON(http.StatusOK, list)
+}
+
 // GetScanVulnerabilities returns vulnerabilities for a scan
 func (h *Handler) GetScanVulnerabilities(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -234,11 +370,17 @@ func (h *Handler) GetScanResults(c *gin.Context) {
 		vulns = []models.VulnerabilityResult{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	results := gin.H{
 		"findings":        findings,
 		"vulnerabilities": vulns,
 		"summary":         summary,
-	})
+	}
+	// The occurrences of each secret, also listed by /:id/secrets
+	if scan, err := h.db.GetScan(id); err == nil && scan.ScanType == "secrets" {
+		secrets, _ := h.db.GetSecretResults(id, database.SecretFilter{})
+		results["secrets"] = secrets
+	}
+	c.JSON(http.StatusOK, results)
 }
 
 // GetScanLogs returns scan logs
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/models"
+	"github.com/security-scanner/cloud-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// maxScheduleHours bounds the interval of a schedule to a year
+const maxScheduleHours = 24 * 365
+
+// scheduleScanTypes are the scan types schedules re-run: the account audits
+// whose checks can drift
+var scheduleScanTypes = map[string]bool{"prowler": true, "scoutsuite": true, "full": true}
+
+// SetScheduler runs schedules on demand
+func (h *Handler) SetScheduler(s *scanner.Scheduler) {
+	h.scheduler = s
+}
+
+// GetSchedules returns the cloud schedules of the request's project
+func (h *Handler) GetSchedules(c *gin.Context) {
+	schedules, err := h.db.GetSchedules(project.Scope(c.Query("project"), c.GetHeader(project.Header)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules, "total": len(schedules)})
+}
+
+// CreateSchedule creates a schedule re-running a Prowler or ScoutSuite scan
+// of an account every interval_hours; the first run starts right away
+func (h *Handler) CreateSchedule(c *gin.Context) {
+	var req models.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Provider != "aws" && req.Provider != "azure" && req.Provider != "gcp" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider. Must be: aws, azure, or gcp"})
+		return
+	}
+	if !scheduleScanTypes[req.ScanType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan type. Must be: prowler, scoutsuite, or full"})
+		return
+	}
+	if req.IntervalHours < 1 || req.IntervalHours > maxScheduleHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval_hours must be between 1 and 8760"})
+		return
+	}
+	if req.Config != nil && req.Config.Simulate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Simulated scans cannot be scheduled"})
+		return
+	}
+
+	now := time.Now()
+	schedule := &models.CloudSchedule{
+		ID:            uuid.New(),
+		Name:          req.Name,
+		Provider:      req.Provider,
+		ScanType:      req.ScanType,
+		Target:        req.Target,
+		Config:        req.Config,
+		IntervalHours: req.IntervalHours,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+		ProjectID:     project.FromRequest(c.GetHeader(project.Header)),
+		NextRunAt:     now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := h.db.CreateSchedule(schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule changes the interval of a schedule or pauses and resumes it
+func (h *Handler) UpdateSchedule(c *gin.Context) {
+	schedule, ok := h.schedule(c)
+	if !ok {
+		return
+	}
+	var req models.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	interval, enabled := schedule.IntervalHours, schedule.Enabled
+	if req.IntervalHours != nil {
+		interval = *req.IntervalHours
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	if interval < 1 || interval > maxScheduleHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval_hours must be between 1 and 8760"})
+		return
+	}
+	if err := h.db.UpdateSchedule(schedule.ID, interval, enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+		return
+	}
+	updated, err := h.db.GetSchedule(schedule.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteSchedule deletes a schedule; the scans it started are kept
+func (h *Handler) DeleteSchedule(c *gin.Context) {
+	schedule, ok := h.schedule(c)
+	if !ok {
+		return
+	}
+	if err := h.db.DeleteSchedule(schedule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
+}
+
+// RunSchedule starts a run of a schedule now, compared with the previous
+// run like any other
+func (h *Handler) RunSchedule(c *gin.Context) {
+	schedule, ok := h.schedule(c)
+	if !ok {
+		return
+	}
+	if schedule.LastScanID != nil && h.manager.IsScanRunning(*schedule.LastScanID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The previous run of this schedule is still running"})
+		return
+	}
+	scan, err := h.scheduler.Run(schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start scan"})
+		return
+	}
+	c.JSON(http.StatusCreated, scan)
+}
+
+// schedule reads the schedule of the :id parameter, writing the error
+// response when it is invalid or unknown
+func (h *Handler) schedule(c *gin.Context) (*models.CloudSchedule, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return nil, false
+	}
+	schedule, err := h.db.GetSchedule(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return nil, false
+	}
+	return schedule, true
+}
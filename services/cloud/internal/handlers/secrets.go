@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/database"
+)
+
+// GetScanSecrets returns every place a secrets scan found a secret in,
+// masked (?rule=, ?file= path substring, ?verified=true|false)
+func (h *Handler) GetScanSecrets(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan ID"})
+		return
+	}
+
+	filter := database.SecretFilter{Rule: c.Query("rule"), File: c.Query("file")}
+	if v := c.Query("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "verified must be true or false"})
+			return
+		}
+		filter.Verified = &verified
+	}
+
+	secrets, err := h.db.GetSecretResults(id, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch secrets"})
+		return
+	}
+	c.JSON(http.StatusOK, secrets)
+}
+
+// GetScanStats returns the counts of a scan's findings by severity, source
+// and service, and for secrets scans the counts of secrets by rule and file
+func (h *Handler) GetScanStats(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan ID"})
+		return
+	}
+	scan, err := h.db.GetScan(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	}
+
+	findings, err := h.db.GetFindings(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch findings"})
+		return
+	}
+	bySource := map[string]int{}
+	byService := map[string]int{}
+	for _, f := range findings {
+		if f.Status == "PASS" {
+			continue
+		}
+		bySource[f.Source]++
+		byService[f.Service]++
+	}
+
+	stats := gin.H{
+		"summary":             h.db.CalculateSummary(id),
+		"findings_by_source":  bySource,
+		"findings_by_service": byService,
+	}
+	if scan.ScanType == "secrets" {
+		secrets, err := h.db.SecretStats(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count secrets"})
+			return
+		}
+		stats["secrets"] = secrets
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/models"
+	"github.com/security-scanner/cloud-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// SetSources enables source scans of uploaded archives
+func (h *Handler) SetSources(s *scanner.Sources) {
+	h.sources = s
+}
+
+// validRepoURL reports whether target is a remote Git repository Trivy or
+// the secrets tools can clone. Credentials in the URL are refused: they would
+// show in the scan's target and logs.
+func validRepoURL(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && u.User == nil &&
+		strings.Trim(u.Path, "/") != ""
+}
+
+// validSecretsTool reports whether tool is a secrets tool, or empty for the
+// default one
+func validSecretsTool(tool string) bool {
+	return tool == "" || tool == "gitleaks" || tool == "trufflehog"
+}
+
+// CreateSourceScan scans an uploaded source archive (multipart "file":
+// .zip, .tar, .tar.gz or .tgz): with Trivy for vulnerable dependencies,
+// secrets and misconfigurations (scan_type source, the default), or with
+// gitleaks or trufflehog for secrets (scan_type secrets). The extracted
+// sources are removed when the scan ends.
+func (h *Handler) CreateSourceScan(c *gin.Context) {
+	if h.sources == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Source scans are not available"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if file.Size > scanner.MaxSourceArchiveSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Source archive is too large"})
+		return
+	}
+
+	name := c.PostForm("name")
+	if name == "" {
+		name = file.Filename
+	}
+	provider := c.DefaultPostForm("provider", "docker")
+	if provider != "aws" && provider != "azure" && provider != "gcp" && provider != "docker" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider. Must be: aws, azure, gcp, or docker"})
+		return
+	}
+	scanType := c.DefaultPostForm("scan_type", "source")
+	if scanType != "source" && scanType != "secrets" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan type. Must be: source or secrets"})
+		return
+	}
+	config := &models.CloudScanConfig{
+		SourceArchive:      filepath.Base(file.Filename),
+		SecretsTool:        c.PostForm("secrets_tool"),
+		TrivyIgnoreUnfixed: c.PostForm("trivy_ignore_unfixed") == "true",
+	}
+	if !validSecretsTool(config.SecretsTool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid secrets_tool. Must be: gitleaks or trufflehog"})
+		return
+	}
+	if severities := c.PostForm("trivy_severities"); severities != "" {
+		config.TrivySeverities = strings.Split(strings.ToUpper(severities), ",")
+	}
+
+	scan := &models.CloudScan{
+		ID:        uuid.New(),
+		Name:      name,
+		Provider:  provider,
+		ScanType:  scanType,
+		Target:    config.SourceArchive,
+		Status:    "pending",
+		Progress:  0,
+		Config:    config,
+		Origin:    origin.FromRequest(c.GetHeader(origin.Header), c.GetHeader(origin.UserIDHeader)),
+		ProjectID: project.FromRequest(c.GetHeader(project.Header)),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer src.Close()
+	if err := h.sources.Extract(scan.ID, file.Filename, src, file.Size); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, scanner.ErrUnsupportedArchive) {
+			status = http.StatusUnsupportedMediaType
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.CreateScan(scan); err != nil {
+		h.sources.Remove(scan.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scan"})
+		return
+	}
+
+	h.manager.StartScan(scan)
+
+	c.JSON(http.StatusCreated, scan)
+}
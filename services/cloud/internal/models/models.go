@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
 // CloudScan represents a cloud security scan
@@ -11,12 +12,14 @@ type CloudScan struct {
 	ID           uuid.UUID         `json:"id"`
 	Name         string            `json:"name"`
 	Provider     string            `json:"provider"`     // aws, azure, gcp, docker
-	ScanType     string            `json:"scan_type"`    // scoutsuite, prowler, trivy, full
-	Target       string            `json:"target"`       // account, subscription, project, or image
+	ScanType     string            `json:"scan_type"`    // scoutsuite, prowler, trivy, image, config, repo, source, secrets, buckets, full
+	Target       string            `json:"target"`       // account, subscription, project, image, repository URL, archive name, or organization names/domains
 	Status       string            `json:"status"`       // pending, running, completed, failed, cancelled
 	Progress     int               `json:"progress"`
 	Config       *CloudScanConfig  `json:"config,omitempty"`
 	Summary      *CloudScanSummary `json:"summary,omitempty"`
+	Origin       string            `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID    string            `json:"project_id"`
 	CreatedAt    time.Time         `json:"created_at"`
 	UpdatedAt    time.Time         `json:"updated_at"`
 	CompletedAt  *time.Time        `json:"completed_at,omitempty"`
@@ -41,6 +44,8 @@ type CloudScanConfig struct {
 	TrivyTargetType   string   `json:"trivy_target_type,omitempty"`  // image, fs, repo, config
 	TrivySeverities   []string `json:"trivy_severities,omitempty"`   // CRITICAL, HIGH, MEDIUM, LOW
 	TrivyIgnoreUnfixed bool    `json:"trivy_ignore_unfixed,omitempty"`
+	TrivyBranch        string  `json:"trivy_branch,omitempty"`  // repo: branch to scan, the default one when empty
+	TrivyCommit        string  `json:"trivy_commit,omitempty"`  // repo: commit to scan
 
 	// ScoutSuite Configuration
 	ScoutSuiteServices []string `json:"scoutsuite_services,omitempty"`
@@ -50,8 +55,21 @@ type CloudScanConfig struct {
 	ProwlerChecks    []string `json:"prowler_checks,omitempty"`
 	ProwlerCompliance string  `json:"prowler_compliance,omitempty"` // cis, pci, hipaa, etc.
 
+	// Bucket enumeration Configuration
+	BucketProviders []string `json:"bucket_providers,omitempty"`  // aws, gcp, azure
+	BucketKeywords  []string `json:"bucket_keywords,omitempty"`   // extra words for name permutations
+	BucketMaxNames  int      `json:"bucket_max_names,omitempty"`
+	BucketWriteTest bool     `json:"bucket_write_test,omitempty"` // upload and delete a test object
+
+	// Secrets scan Configuration
+	SecretsTool string `json:"secrets_tool,omitempty"` // gitleaks (default) or trufflehog
+
+	// Uploaded sources (source and secrets scans), set by the server
+	SourceArchive string `json:"source_archive,omitempty"` // name of the uploaded archive
+
 	// General
-	Timeout int `json:"timeout,omitempty"` // seconds
+	Timeout  int  `json:"timeout,omitempty"`  // seconds
+	Simulate bool `json:"simulate,omitempty"` // synthetic results, no cloud or registry is contacted
 }
 
 // CloudScanSummary contains scan summary
@@ -63,6 +81,7 @@ type CloudScanSummary struct {
 	Low           int `json:"low"`
 	Info          int `json:"info"`
 	Passed        int `json:"passed"`
+	Drift         int `json:"drift"` // failed checks that passed in the schedule's previous run
 }
 
 // CloudFinding represents a security finding
@@ -80,7 +99,8 @@ type CloudFinding struct {
 	Status      string     `json:"status"`   // FAIL, PASS, WARNING
 	Compliance  []string   `json:"compliance,omitempty"`
 	Remediation string     `json:"remediation,omitempty"`
-	Source      string     `json:"source"` // scoutsuite, prowler, trivy
+	Source      string     `json:"source"` // scoutsuite, prowler, trivy, buckets
+	Drift       bool       `json:"drift"`  // failing now, not in the previous run of the scan's schedule
 	RawData     string     `json:"raw_data,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
@@ -103,15 +123,49 @@ type VulnerabilityResult struct {
 	CreatedAt       time.Time `json:"created_at"`
 }
 
-// ScanLog represents a log entry
-type ScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"` // info, warning, error
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
+// SecretResult is a place gitleaks or trufflehog found a secret in. The
+// secret itself is never stored: Redacted and Match keep it masked, and the
+// fingerprint tells the occurrences of the same secret apart.
+type SecretResult struct {
+	ID          uuid.UUID `json:"id"`
+	ScanID      uuid.UUID `json:"scan_id"`
+	Tool        string    `json:"tool"` // gitleaks, trufflehog
+	Rule        string    `json:"rule"` // gitleaks rule or trufflehog detector
+	File        string    `json:"file"`
+	Line        int       `json:"line,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	Entropy     float64   `json:"entropy,omitempty"`
+	Redacted    string    `json:"redacted"`
+	Match       string    `json:"match,omitempty"` // the matched text, with the secret masked
+	Fingerprint string    `json:"fingerprint"`
+	Verified    bool      `json:"verified"` // trufflehog confirmed the credential works
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SecretStats summarizes the secrets found by a scan
+type SecretStats struct {
+	Secrets     int            `json:"secrets"`     // distinct secrets
+	Occurrences int            `json:"occurrences"` // places they were found in
+	Verified    int            `json:"verified"`    // distinct secrets confirmed to work
+	Files       int            `json:"files"`
+	Commits     int            `json:"commits"`
+	ByRule      map[string]int `json:"by_rule"` // distinct secrets per rule
+	TopFiles    []FileCount    `json:"top_files"`
+}
+
+// FileCount is how many secret occurrences a file has
+type FileCount struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
 }
 
+// ScanLog represents a log entry (shared by all services)
+type ScanLog = shared.ScanLog
+
+// Finding is a finding in the model every service lists them with (shared
+// by all services)
+type Finding = shared.Finding
+
 // CreateCloudScanRequest represents the request to create a scan
 type CreateCloudScanRequest struct {
 	Name     string           `json:"name" binding:"required"`
@@ -119,4 +173,42 @@ type CreateCloudScanRequest struct {
 	ScanType string           `json:"scan_type" binding:"required"`
 	Target   string           `json:"target"`
 	Config   *CloudScanConfig `json:"config,omitempty"`
+	Simulate bool             `json:"simulate,omitempty"`
+}
+
+// CloudSchedule re-runs a Prowler or ScoutSuite scan of an account every
+// interval. Each run is compared with the previous one: checks failing for
+// the first time are flagged as configuration drift.
+type CloudSchedule struct {
+	ID            uuid.UUID        `json:"id"`
+	Name          string           `json:"name"`
+	Provider      string           `json:"provider"`  // aws, azure, gcp
+	ScanType      string           `json:"scan_type"` // prowler, scoutsuite
+	Target        string           `json:"target"`
+	Config        *CloudScanConfig `json:"config,omitempty"`
+	IntervalHours int              `json:"interval_hours"`
+	Enabled       bool             `json:"enabled"`
+	ProjectID     string           `json:"project_id"`
+	LastScanID    *uuid.UUID       `json:"last_scan_id,omitempty"`
+	LastRunAt     *time.Time       `json:"last_run_at,omitempty"`
+	NextRunAt     time.Time        `json:"next_run_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// CreateScheduleRequest represents the request to create a schedule
+type CreateScheduleRequest struct {
+	Name          string           `json:"name" binding:"required"`
+	Provider      string           `json:"provider" binding:"required"`
+	ScanType      string           `json:"scan_type" binding:"required"`
+	Target        string           `json:"target"`
+	Config        *CloudScanConfig `json:"config,omitempty"`
+	IntervalHours int              `json:"interval_hours" binding:"required"`
+	Enabled       *bool            `json:"enabled,omitempty"` // defaults to true
+}
+
+// UpdateScheduleRequest changes the interval of a schedule or pauses it
+type UpdateScheduleRequest struct {
+	IntervalHours *int  `json:"interval_hours,omitempty"`
+	Enabled       *bool `json:"enabled,omitempty"`
 }
@@ -0,0 +1,404 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/database"
+	"github.com/security-scanner/cloud-service/internal/models"
+)
+
+// bucketAffixes are combined with the organization's words to build candidate names
+var bucketAffixes = []string{
+	"backup", "backups", "dev", "development", "prod", "production", "staging", "stage", "test", "qa",
+	"data", "assets", "static", "media", "files", "uploads", "logs", "private", "public", "www", "web",
+	"cdn", "images", "img", "db", "database", "archive", "internal", "config", "terraform", "releases",
+}
+
+// azureContainers are container names tried on storage accounts that exist
+var azureContainers = []string{"public", "files", "images", "backup", "backups", "data", "uploads", "assets", "media", "static", "logs"}
+
+var (
+	bucketNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9.\-]{1,61}[a-z0-9]$`)
+	azureAccountFilter = regexp.MustCompile(`[^a-z0-9]`)
+)
+
+const (
+	defaultBucketMaxNames = 600
+	bucketWorkers         = 20
+)
+
+// BucketScanner enumerates S3, GCS and Azure Blob storage named after the
+// target organization and checks whether anonymous users can list or write them
+type BucketScanner struct {
+	db     *database.Database
+	client *http.Client
+}
+
+// NewBucketScanner creates a new bucket enumeration scanner
+func NewBucketScanner(db *database.Database) *BucketScanner {
+	return &BucketScanner{
+		db: db,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// Redirects carry the bucket region, they are handled explicitly
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// IsAvailable always returns true, the scan only needs network access
+func (s *BucketScanner) IsAvailable() bool {
+	return true
+}
+
+// bucketResult is the outcome of probing one bucket
+type bucketResult struct {
+	provider string
+	service  string
+	name     string
+	url      string
+	region   string
+	exists   bool
+	listable bool
+	writable bool
+	objects  int
+}
+
+// Scan generates bucket names from scan.Target (comma separated organization
+// names or domains) and probes each configured provider
+func (s *BucketScanner) Scan(ctx context.Context, scan *models.CloudScan, config *models.CloudScanConfig) error {
+	if config == nil {
+		config = &models.CloudScanConfig{}
+	}
+	s.db.UpdateScanStatus(scan.ID, "running", 5, nil)
+
+	providers := config.BucketProviders
+	if len(providers) == 0 {
+		if scan.Provider == "aws" || scan.Provider == "gcp" || scan.Provider == "azure" {
+			providers = []string{scan.Provider}
+		} else {
+			providers = []string{"aws", "gcp", "azure"}
+		}
+	}
+
+	maxNames := config.BucketMaxNames
+	if maxNames <= 0 {
+		maxNames = defaultBucketMaxNames
+	}
+	names := BucketNames(scan.Target, config.BucketKeywords, maxNames)
+	if len(names) == 0 {
+		return fmt.Errorf("no bucket names could be generated from target %q", scan.Target)
+	}
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Checking %d bucket names on %s", len(names), strings.Join(providers, ", ")))
+	if config.BucketWriteTest {
+		s.db.AddLog(scan.ID, "warning", "Write test enabled: a test object is uploaded to and deleted from each existing bucket")
+	}
+
+	type job struct {
+		provider string
+		name     string
+	}
+	jobs := make(chan job)
+	results := make(chan bucketResult)
+	var wg sync.WaitGroup
+	for i := 0; i < bucketWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				switch j.provider {
+				case "aws":
+					results <- s.checkS3(ctx, j.name, config.BucketWriteTest)
+				case "gcp":
+					results <- s.checkGCS(ctx, j.name, config.BucketWriteTest)
+				case "azure":
+					for _, r := range s.checkAzure(ctx, j.name) {
+						results <- r
+					}
+				}
+			}
+		}()
+	}
+
+	var queue []job
+	seen := map[string]bool{}
+	for _, provider := range providers {
+		for _, name := range names {
+			if provider == "azure" {
+				// Storage account names are 3-24 lowercase letters and digits
+				name = azureAccountFilter.ReplaceAllString(name, "")
+				if len(name) < 3 || len(name) > 24 || seen[name] {
+					continue
+				}
+				seen[name] = true
+			}
+			queue = append(queue, job{provider: provider, name: name})
+		}
+	}
+	total := len(queue)
+
+	go func() {
+		defer close(jobs)
+		for _, j := range queue {
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	checked, found, exposed := 0, 0, 0
+	for r := range results {
+		checked++
+		if checked%50 == 0 {
+			s.db.UpdateScanStatus(scan.ID, "running", 5+90*checked/total, nil)
+		}
+		if !r.exists {
+			continue
+		}
+		found++
+		if r.listable || r.writable {
+			exposed++
+		}
+		s.saveFinding(scan, r)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Bucket enumeration complete: %d existing buckets, %d publicly exposed", found, exposed))
+	return nil
+}
+
+func (s *BucketScanner) saveFinding(scan *models.CloudScan, r bucketResult) {
+	finding := &models.CloudFinding{
+		ID:         uuid.New(),
+		ScanID:     scan.ID,
+		Provider:   r.provider,
+		Service:    r.service,
+		Region:     r.region,
+		ResourceID: r.name,
+		Source:     "buckets",
+		RawData:    r.url,
+		CreatedAt:  time.Now(),
+	}
+	if r.provider == "aws" {
+		finding.ResourceARN = "arn:aws:s3:::" + r.name
+	}
+
+	switch {
+	case r.writable:
+		finding.Title = fmt.Sprintf("Publicly writable %s bucket", bucketLabel(r.service))
+		finding.Description = fmt.Sprintf("Anonymous users can upload objects to %s.", r.url)
+		finding.Severity = "CRITICAL"
+		finding.Status = "FAIL"
+		finding.Remediation = "Remove anonymous write permissions from the bucket policy and ACLs, and enable public access blocking."
+	case r.listable:
+		finding.Title = fmt.Sprintf("Publicly listable %s bucket", bucketLabel(r.service))
+		finding.Description = fmt.Sprintf("Anonymous users can list the contents of %s (%d objects in the first page).", r.url, r.objects)
+		finding.Severity = "HIGH"
+		finding.Status = "FAIL"
+		finding.Remediation = "Remove anonymous read/list permissions and enable public access blocking unless the contents are meant to be public."
+	default:
+		finding.Title = fmt.Sprintf("%s bucket exists", bucketLabel(r.service))
+		finding.Description = fmt.Sprintf("%s exists and denies anonymous listing. Confirm it belongs to the organization.", r.url)
+		finding.Severity = "INFO"
+		finding.Status = "PASS"
+	}
+
+	if err := s.db.SaveFinding(finding); err != nil {
+		s.db.AddLog(scan.ID, "warning", "Failed to save finding: "+err.Error())
+		return
+	}
+	if finding.Status == "FAIL" {
+		s.db.AddLog(scan.ID, "warning", finding.Title+": "+r.url)
+	}
+}
+
+// checkS3 probes an S3 bucket; 404 means it does not exist, 403 that it is
+// private and 200 that anyone can list it
+func (s *BucketScanner) checkS3(ctx context.Context, name string, writeTest bool) bucketResult {
+	r := bucketResult{provider: "aws", service: "s3", name: name, url: "https://s3.amazonaws.com/" + name + "/"}
+
+	resp, body, err := s.request(ctx, http.MethodGet, r.url, nil, nil)
+	if err != nil {
+		return r
+	}
+	if region := resp.Header.Get("x-amz-bucket-region"); region != "" {
+		r.region = region
+		if resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusTemporaryRedirect {
+			r.url = "https://s3." + region + ".amazonaws.com/" + name + "/"
+			resp, body, err = s.request(ctx, http.MethodGet, r.url, nil, nil)
+			if err != nil {
+				r.exists = true
+				return r
+			}
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return r
+	case http.StatusOK:
+		r.exists, r.listable = true, true
+		r.objects = bytes.Count(body, []byte("<Key>"))
+	default:
+		r.exists = resp.StatusCode == http.StatusForbidden || r.region != ""
+	}
+
+	if r.exists && writeTest {
+		object := r.url + "security-scanner-write-test-" + uuid.New().String() + ".txt"
+		resp, _, err := s.request(ctx, http.MethodPut, object, []byte("security scanner write test"), nil)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			r.writable = true
+			s.request(ctx, http.MethodDelete, object, nil, nil)
+		}
+	}
+	return r
+}
+
+// checkGCS probes a Google Cloud Storage bucket through the JSON API
+func (s *BucketScanner) checkGCS(ctx context.Context, name string, writeTest bool) bucketResult {
+	r := bucketResult{provider: "gcp", service: "gcs", name: name, url: "https://storage.googleapis.com/" + name + "/"}
+
+	resp, body, err := s.request(ctx, http.MethodGet, "https://storage.googleapis.com/storage/v1/b/"+name+"/o?maxResults=100", nil, nil)
+	if err != nil {
+		return r
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		r.exists, r.listable = true, true
+		r.objects = bytes.Count(body, []byte(`"kind": "storage#object"`))
+	case http.StatusUnauthorized, http.StatusForbidden:
+		r.exists = true
+	default:
+		return r
+	}
+
+	if writeTest {
+		object := "security-scanner-write-test-" + uuid.New().String() + ".txt"
+		resp, _, err := s.request(ctx, http.MethodPost,
+			"https://storage.googleapis.com/upload/storage/v1/b/"+name+"/o?uploadType=media&name="+object,
+			[]byte("security scanner write test"), map[string]string{"Content-Type": "text/plain"})
+		if err == nil && resp.StatusCode == http.StatusOK {
+			r.writable = true
+			s.request(ctx, http.MethodDelete, "https://storage.googleapis.com/storage/v1/b/"+name+"/o/"+object, nil, nil)
+		}
+	}
+	return r
+}
+
+// checkAzure resolves the storage account and tries to list common
+// containers. Azure only allows anonymous reads, so there is no write test.
+func (s *BucketScanner) checkAzure(ctx context.Context, account string) []bucketResult {
+	host := account + ".blob.core.windows.net"
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+	cancel()
+	if err != nil {
+		return nil
+	}
+
+	var results []bucketResult
+	for _, container := range azureContainers {
+		url := "https://" + host + "/" + container
+		resp, body, err := s.request(ctx, http.MethodGet, url+"?restype=container&comp=list&maxresults=100", nil, nil)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		results = append(results, bucketResult{
+			provider: "azure", service: "blob", name: account + "/" + container, url: url,
+			exists: true, listable: true, objects: bytes.Count(body, []byte("<Blob>")),
+		})
+	}
+	if len(results) == 0 {
+		results = append(results, bucketResult{provider: "azure", service: "blob", name: account, url: "https://" + host, exists: true})
+	}
+	return results
+}
+
+func (s *BucketScanner) request(ctx context.Context, method, url string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp, data, nil
+}
+
+// BucketNames builds candidate bucket names from organization names or
+// domains, e.g. "example.com" gives example, example-com, example-backup,
+// dev.example, ... at most limit names, most likely first
+func BucketNames(target string, keywords []string, limit int) []string {
+	var bases []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(target), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	}) {
+		part = strings.TrimPrefix(strings.TrimPrefix(part, "https://"), "http://")
+		part = strings.TrimPrefix(strings.TrimSuffix(part, "/"), "www.")
+		bases = append(bases, part)
+		if label, _, ok := strings.Cut(part, "."); ok {
+			bases = append(bases, label, strings.ReplaceAll(part, ".", "-"), strings.ReplaceAll(part, ".", ""))
+		}
+	}
+
+	affixes := append(append([]string{}, keywords...), bucketAffixes...)
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] && bucketNamePattern.MatchString(name) && !strings.Contains(name, "..") {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, base := range bases {
+		add(base)
+	}
+	for _, affix := range affixes {
+		for _, base := range bases {
+			for _, sep := range []string{"-", ".", ""} {
+				add(base + sep + affix)
+				add(affix + sep + base)
+			}
+		}
+	}
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	return names
+}
+
+func bucketLabel(service string) string {
+	switch service {
+	case "s3":
+		return "S3"
+	case "gcs":
+		return "GCS"
+	default:
+		return "Azure Blob"
+	}
+}
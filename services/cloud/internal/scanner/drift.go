@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/security-scanner/cloud-service/internal/models"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/origin"
+)
+
+// isScheduled tells whether a scan was started by a schedule, whose runs
+// are compared with each other
+func isScheduled(scan *models.CloudScan) bool {
+	return strings.HasPrefix(scan.Origin, origin.Schedule+":")
+}
+
+// detectDrift flags the checks of a scheduled scan that fail for the first
+// time since the schedule's previous run. The first run is the baseline.
+func (m *ScanManager) detectDrift(scan *models.CloudScan) {
+	previous, err := m.db.PreviousScan(scan)
+	if err != nil {
+		m.db.AddLog(scan.ID, "warning", "Drift detection skipped: "+err.Error())
+		return
+	}
+	if previous == nil {
+		m.db.AddLog(scan.ID, "info", "First run of the schedule: recorded as the baseline for drift detection")
+		return
+	}
+
+	drifted, resolved, err := m.db.MarkDrift(scan.ID, *previous)
+	if err != nil {
+		m.db.AddLog(scan.ID, "warning", "Drift detection failed: "+err.Error())
+		return
+	}
+	level := "info"
+	if drifted > 0 {
+		level = "warning"
+	}
+	m.db.AddLog(scan.ID, level, fmt.Sprintf("Configuration drift since scan %s: %d newly failing checks, %d resolved",
+		previous, drifted, resolved))
+	if drifted > 0 {
+		m.notifyDrift(scan)
+	}
+}
+
+// notifyDrift posts the newly failing checks of a scheduled scan by
+// severity, apart from the scan's usual notification
+func (m *ScanManager) notifyDrift(scan *models.CloudScan) {
+	if m.chat == nil {
+		return
+	}
+	target := scan.Provider
+	if scan.Target != "" {
+		target += ": " + scan.Target
+	}
+	m.chat.Notify(chat.Summary{
+		Service:    "cloud-service",
+		Tool:       "drift",
+		ScanID:     scan.ID.String(),
+		ScanName:   "Configuration drift: " + scan.Name,
+		Target:     target,
+		Counts:     m.db.CountDriftBySeverity(scan.ID),
+		ReportPath: "/cloud-scans/" + scan.ID.String(),
+	})
+}
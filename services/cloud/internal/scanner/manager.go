@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/security-scanner/cloud-service/internal/database"
 	"github.com/security-scanner/cloud-service/internal/models"
+	"github.com/security-scanner/shared/pkg/chat"
 )
 
 // ScanManager coordinates cloud security scanning operations
@@ -15,21 +16,40 @@ type ScanManager struct {
 	trivy          *TrivyScanner
 	prowler        *ProwlerScanner
 	scoutsuite     *ScoutSuiteScanner
+	buckets        *BucketScanner
+	secrets        *SecretsScanner
+	simulator      *Simulator
+	chat           *chat.Notifier
+	sources        *Sources
 	activeScans    map[uuid.UUID]context.CancelFunc
 	activeScansMux sync.Mutex
 }
 
 // NewScanManager creates a new scan manager
-func NewScanManager(db *database.Database, trivyPath, prowlerPath, scoutsuitePath string) *ScanManager {
+func NewScanManager(db *database.Database, trivyPath, prowlerPath, scoutsuitePath, gitleaksPath, trufflehogPath string) *ScanManager {
 	return &ScanManager{
 		db:          db,
 		trivy:       NewTrivyScanner(db, trivyPath),
 		prowler:     NewProwlerScanner(db, prowlerPath),
 		scoutsuite:  NewScoutSuiteScanner(db, scoutsuitePath),
+		buckets:     NewBucketScanner(db),
+		secrets:     NewSecretsScanner(db, gitleaksPath, trufflehogPath),
+		simulator:   NewSimulator(db),
 		activeScans: make(map[uuid.UUID]context.CancelFunc),
 	}
 }
 
+// SetChat posts scans whose Prowler checks failed at a channel's severities
+// to Slack and Discord
+func (m *ScanManager) SetChat(n *chat.Notifier) {
+	m.chat = n
+}
+
+// SetSources runs source scans on the archives extracted by s
+func (m *ScanManager) SetSources(s *Sources) {
+	m.sources = s
+}
+
 // StartScan initiates a new cloud security scan
 func (m *ScanManager) StartScan(scan *models.CloudScan) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -50,20 +70,37 @@ func (m *ScanManager) runScan(ctx context.Context, scan *models.CloudScan) {
 
 	var err error
 
-	switch scan.ScanType {
-	case "trivy":
+	switch {
+	case scan.Config != nil && scan.Config.Simulate:
+		// Synthetic results, nothing is scanned
+		err = m.simulator.Scan(ctx, scan)
+	case scan.ScanType == "trivy":
 		err = m.trivy.Scan(ctx, scan, scan.Config)
-	case "prowler":
+	case scan.ScanType == "prowler":
 		err = m.prowler.Scan(ctx, scan, scan.Config)
-	case "scoutsuite":
+	case scan.ScanType == "scoutsuite":
 		err = m.scoutsuite.Scan(ctx, scan, scan.Config)
-	case "image":
+	case scan.ScanType == "buckets":
+		err = m.buckets.Scan(ctx, scan, scan.Config)
+	case scan.ScanType == "image":
 		// Shortcut for container image scanning
 		err = m.trivy.ScanImage(ctx, scan, scan.Target)
-	case "config":
+	case scan.ScanType == "config":
 		// Shortcut for IaC scanning
 		err = m.trivy.ScanConfig(ctx, scan, scan.Target)
-	case "full":
+	case scan.ScanType == "repo":
+		// Shortcut for Git repository scanning
+		err = m.trivy.ScanRepository(ctx, scan, scan.Target)
+	case scan.ScanType == "source" && m.sources != nil:
+		// Uploaded sources are only kept for the scan
+		err = m.trivy.ScanFilesystem(ctx, scan, m.sources.Dir(scan.ID))
+		m.sources.Remove(scan.ID)
+	case scan.ScanType == "secrets" && m.sources != nil:
+		// Repositories are cloned, and archives extracted, into the scan's
+		// sources directory
+		err = m.secrets.Scan(ctx, scan, m.sources.Dir(scan.ID))
+		m.sources.Remove(scan.ID)
+	case scan.ScanType == "full":
 		err = m.runFullScan(ctx, scan)
 	default:
 		m.db.AddLog(scan.ID, "error", "Unknown scan type: "+scan.ScanType)
@@ -86,11 +123,37 @@ func (m *ScanManager) runScan(ctx context.Context, scan *models.CloudScan) {
 		return
 	}
 
+	// Runs of a schedule are compared with the previous one
+	if isScheduled(scan) {
+		m.detectDrift(scan)
+	}
+
 	// Calculate summary
 	summary := m.db.CalculateSummary(scan.ID)
 
 	m.db.AddLog(scan.ID, "info", "Scan completed successfully")
 	m.db.UpdateScanStatus(scan.ID, "completed", 100, summary)
+	m.notifyChat(scan)
+}
+
+// notifyChat posts the failed Prowler checks of a completed scan by severity
+func (m *ScanManager) notifyChat(scan *models.CloudScan) {
+	if m.chat == nil {
+		return
+	}
+	target := scan.Provider
+	if scan.Target != "" {
+		target += ": " + scan.Target
+	}
+	m.chat.Notify(chat.Summary{
+		Service:    "cloud-service",
+		Tool:       "prowler",
+		ScanID:     scan.ID.String(),
+		ScanName:   scan.Name,
+		Target:     target,
+		Counts:     m.db.CountFailedBySeverity(scan.ID, "prowler"),
+		ReportPath: "/cloud-scans/" + scan.ID.String(),
+	})
 }
 
 func (m *ScanManager) runFullScan(ctx context.Context, scan *models.CloudScan) error {
@@ -211,9 +274,13 @@ func (m *ScanManager) IsScanRunning(scanID uuid.UUID) bool {
 
 // GetAvailableTools returns a list of available scanning tools
 func (m *ScanManager) GetAvailableTools() map[string]bool {
+	secrets := m.secrets.Tools()
 	return map[string]bool{
 		"trivy":      m.trivy.IsAvailable(),
 		"prowler":    m.prowler.IsAvailable(),
 		"scoutsuite": m.scoutsuite.IsAvailable(),
+		"buckets":    m.buckets.IsAvailable(),
+		"gitleaks":   secrets["gitleaks"],
+		"trufflehog": secrets["trufflehog"],
 	}
 }
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/database"
+	"github.com/security-scanner/cloud-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/origin"
+)
+
+// Scheduler starts the scans of the cloud schedules when due
+type Scheduler struct {
+	db      *database.Database
+	manager *ScanManager
+	leases  *shareddb.Leases
+}
+
+// NewScheduler returns a scheduler starting scans through manager
+func NewScheduler(db *database.Database, manager *ScanManager) *Scheduler {
+	return &Scheduler{db: db, manager: manager}
+}
+
+// SetLeases makes Start run schedules only on the replica holding the job's
+// lease
+func (s *Scheduler) SetLeases(leases *shareddb.Leases) {
+	s.leases = leases
+}
+
+// Start checks for due schedules every minute until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if s.leases.Acquire(ctx, "cloud:schedules", 3*time.Minute) {
+			s.runDue()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runDue() {
+	schedules, err := s.db.DueSchedules()
+	if err != nil {
+		log.Printf("⚠️ Failed to list due cloud schedules: %v", err)
+		return
+	}
+	for i := range schedules {
+		schedule := &schedules[i]
+		// A run still going delays the next one, so that runs never overlap
+		if schedule.LastScanID != nil && s.manager.IsScanRunning(*schedule.LastScanID) {
+			continue
+		}
+		if _, err := s.Run(schedule); err != nil {
+			log.Printf("⚠️ Failed to run cloud schedule %s: %v", schedule.ID, err)
+		}
+	}
+}
+
+// Run starts a scan of a schedule now and moves its next run an interval
+// later
+func (s *Scheduler) Run(schedule *models.CloudSchedule) (*models.CloudScan, error) {
+	now := time.Now()
+	scan := &models.CloudScan{
+		ID:        uuid.New(),
+		Name:      schedule.Name + " (" + now.Format("2006-01-02 15:04") + ")",
+		Provider:  schedule.Provider,
+		ScanType:  schedule.ScanType,
+		Target:    schedule.Target,
+		Status:    "pending",
+		Config:    schedule.Config,
+		Origin:    origin.Of(origin.Schedule, schedule.ID.String()),
+		ProjectID: schedule.ProjectID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.CreateScan(scan); err != nil {
+		return nil, err
+	}
+	next := now.Add(time.Duration(schedule.IntervalHours) * time.Hour)
+	if err := s.db.MarkScheduleRun(schedule.ID, scan.ID, next); err != nil {
+		log.Printf("⚠️ Failed to record run of cloud schedule %s: %v", schedule.ID, err)
+	}
+	s.db.AddLog(scan.ID, "info", "Started by schedule "+schedule.Name)
+	s.manager.StartScan(scan)
+	return scan, nil
+}
@@ -0,0 +1,436 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/cloud-service/internal/database"
+	"github.com/security-scanner/cloud-service/internal/models"
+)
+
+// maxSecretOccurrences is how many places of a secret a finding lists
+const maxSecretOccurrences = 20
+
+// SecretsScanner looks for committed credentials in Git repositories and
+// source archives with gitleaks or trufflehog. Findings never hold a secret:
+// the hits of each secret are merged into one finding under its fingerprint
+// and only a masked value is kept.
+type SecretsScanner struct {
+	db             *database.Database
+	gitleaksPath   string
+	trufflehogPath string
+}
+
+// secretHit is a secret found by either tool, before it is masked
+type secretHit struct {
+	Rule     string
+	File     string
+	Line     int
+	Commit   string
+	Secret   string
+	Match    string
+	Entropy  float64
+	Verified bool // trufflehog confirmed the credential works
+}
+
+// SecretOccurrence is a place a secret was found
+type SecretOccurrence struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// SecretFinding is the raw data of a secret finding
+type SecretFinding struct {
+	Fingerprint string             `json:"fingerprint"`
+	Rule        string             `json:"rule"`
+	Redacted    string             `json:"redacted"`
+	Match       string             `json:"match,omitempty"` // the matched text, with the secret masked
+	Entropy     float64            `json:"entropy,omitempty"`
+	Verified    bool               `json:"verified,omitempty"`
+	Occurrences []SecretOccurrence `json:"occurrences"`
+	Total       int                `json:"total"` // occurrences, including those not listed
+}
+
+// gitleaksFinding is an entry of gitleaks' JSON report
+type gitleaksFinding struct {
+	RuleID    string  `json:"RuleID"`
+	File      string  `json:"File"`
+	StartLine int     `json:"StartLine"`
+	Commit    string  `json:"Commit"`
+	Secret    string  `json:"Secret"`
+	Match     string  `json:"Match"`
+	Entropy   float64 `json:"Entropy"`
+}
+
+// trufflehogResult is a line of trufflehog's JSON output
+type trufflehogResult struct {
+	SourceMetadata struct {
+		Data struct {
+			Git *struct {
+				Commit string `json:"commit"`
+				File   string `json:"file"`
+				Line   int    `json:"line"`
+			} `json:"Git"`
+			Filesystem *struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"Filesystem"`
+		} `json:"Data"`
+	} `json:"SourceMetadata"`
+	DetectorName string `json:"DetectorName"`
+	Verified     bool   `json:"Verified"`
+	Raw          string `json:"Raw"`
+}
+
+// NewSecretsScanner creates a new secrets scanner
+func NewSecretsScanner(db *database.Database, gitleaksPath, trufflehogPath string) *SecretsScanner {
+	if gitleaksPath == "" {
+		gitleaksPath = "/usr/local/bin/gitleaks"
+	}
+	if trufflehogPath == "" {
+		trufflehogPath = "/usr/local/bin/trufflehog"
+	}
+	return &SecretsScanner{
+		db:             db,
+		gitleaksPath:   gitleaksPath,
+		trufflehogPath: trufflehogPath,
+	}
+}
+
+// Scan looks for secrets in dir, the extracted archive of an upload, or in
+// the history of the repository at the scan's target when the scan has no
+// archive; gitleaks clones it into dir first
+func (s *SecretsScanner) Scan(ctx context.Context, scan *models.CloudScan, dir string) error {
+	tool := "gitleaks"
+	archive := false
+	if scan.Config != nil {
+		if scan.Config.SecretsTool != "" {
+			tool = scan.Config.SecretsTool
+		}
+		archive = scan.Config.SourceArchive != ""
+	}
+
+	timeout := 30 * time.Minute
+	if scan.Config != nil && scan.Config.Timeout > 0 {
+		timeout = time.Duration(scan.Config.Timeout) * time.Second
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Starting %s secret scan...", tool))
+	s.db.UpdateScanStatus(scan.ID, "running", 10, nil)
+
+	var hits []secretHit
+	var err error
+	switch tool {
+	case "gitleaks":
+		if !archive {
+			if err := s.clone(scanCtx, scan, dir); err != nil {
+				return err
+			}
+		}
+		hits, err = s.gitleaks(scanCtx, scan, dir, archive)
+	case "trufflehog":
+		hits, err = s.trufflehog(scanCtx, scan, dir, archive)
+	default:
+		return fmt.Errorf("unknown secrets tool: %s", tool)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.db.UpdateScanStatus(scan.ID, "running", 80, nil)
+	for i := range hits {
+		hits[i].File = strings.TrimPrefix(strings.TrimPrefix(hits[i].File, dir), "/")
+	}
+	if err := s.db.SaveSecretResults(secretResults(scan.ID, tool, hits)); err != nil {
+		s.db.AddLog(scan.ID, "error", fmt.Sprintf("Failed to save secret occurrences: %v", err))
+	}
+	findings := groupSecrets(hits)
+	saved := 0
+	for _, f := range findings {
+		if err := s.db.SaveFinding(secretCloudFinding(scan, tool, f)); err == nil {
+			saved++
+		}
+	}
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("%s found %d secrets (%d hits)", tool, saved, len(hits)))
+	s.db.UpdateScanStatus(scan.ID, "running", 90, nil)
+	return nil
+}
+
+// clone clones the repository at the scan's target with its whole history
+func (s *SecretsScanner) clone(ctx context.Context, scan *models.CloudScan, dir string) error {
+	s.db.AddLog(scan.ID, "info", "Cloning "+scan.Target)
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--no-tags", scan.Target, dir)
+	// Fail instead of waiting for credentials of private repositories
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		s.db.AddLog(scan.ID, "error", fmt.Sprintf("git clone failed: %v: %s", err, strings.TrimSpace(string(out))))
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SecretsScanner) gitleaks(ctx context.Context, scan *models.CloudScan, dir string, archive bool) ([]secretHit, error) {
+	report, err := os.CreateTemp("", "gitleaks-*.json")
+	if err != nil {
+		return nil, err
+	}
+	report.Close()
+	// The report holds the secrets in clear
+	defer os.Remove(report.Name())
+
+	args := []string{"detect", "--source", dir, "--report-format", "json", "--report-path", report.Name(), "--exit-code", "0", "--no-banner"}
+	if archive {
+		// Uploaded files, not a repository's history
+		args = append(args, "--no-git")
+	}
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Running: gitleaks %s", strings.Join(args, " ")))
+	s.db.UpdateScanStatus(scan.ID, "running", 30, nil)
+
+	cmd := exec.CommandContext(ctx, s.gitleaksPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		s.db.AddLog(scan.ID, "error", fmt.Sprintf("gitleaks failed: %v, stderr: %s", err, lastLines(stderr.String(), 5)))
+		return nil, fmt.Errorf("gitleaks scan failed: %w", err)
+	}
+
+	data, err := os.ReadFile(report.Name())
+	if err != nil {
+		return nil, err
+	}
+	var findings []gitleaksFinding
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &findings); err != nil {
+			return nil, fmt.Errorf("invalid gitleaks report: %w", err)
+		}
+	}
+	hits := make([]secretHit, 0, len(findings))
+	for _, f := range findings {
+		hits = append(hits, secretHit{
+			Rule:    f.RuleID,
+			File:    f.File,
+			Line:    f.StartLine,
+			Commit:  f.Commit,
+			Secret:  f.Secret,
+			Match:   f.Match,
+			Entropy: f.Entropy,
+		})
+	}
+	return hits, nil
+}
+
+func (s *SecretsScanner) trufflehog(ctx context.Context, scan *models.CloudScan, dir string, archive bool) ([]secretHit, error) {
+	args := []string{"git", scan.Target}
+	if archive {
+		args = []string{"filesystem", dir}
+	}
+	args = append(args, "--json", "--no-update")
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Running: trufflehog %s", strings.Join(args, " ")))
+	s.db.UpdateScanStatus(scan.ID, "running", 30, nil)
+
+	// Results are read from stdout and never logged: they hold the secrets
+	cmd := exec.CommandContext(ctx, s.trufflehogPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("trufflehog execution failed: %w", err)
+	}
+
+	var hits []secretHit
+	lines := bufio.NewScanner(stdout)
+	lines.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for lines.Scan() {
+		var r trufflehogResult
+		if json.Unmarshal(lines.Bytes(), &r) != nil || r.Raw == "" {
+			continue
+		}
+		hit := secretHit{Rule: r.DetectorName, Secret: r.Raw, Verified: r.Verified}
+		if git := r.SourceMetadata.Data.Git; git != nil {
+			hit.File, hit.Line, hit.Commit = git.File, git.Line, git.Commit
+		} else if fs := r.SourceMetadata.Data.Filesystem; fs != nil {
+			hit.File, hit.Line = fs.File, fs.Line
+		}
+		hits = append(hits, hit)
+	}
+
+	if err := cmd.Wait(); err != nil && len(hits) == 0 {
+		s.db.AddLog(scan.ID, "error", fmt.Sprintf("trufflehog failed: %v, stderr: %s", err, lastLines(stderr.String(), 5)))
+		return nil, fmt.Errorf("trufflehog scan failed: %w", err)
+	}
+	return hits, nil
+}
+
+// secretFingerprint identifies a secret across files and commits without
+// keeping it
+func secretFingerprint(rule, secret string) string {
+	sum := sha256.Sum256([]byte(rule + "\x00" + secret))
+	return hex.EncodeToString(sum[:16])
+}
+
+// maskSecret keeps enough of a secret to recognise it, never the whole value
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", 8) + value[len(value)-4:]
+}
+
+// maskMatch replaces the secret in the text a rule matched
+func maskMatch(match, secret, redacted string) string {
+	if match == "" || secret == "" {
+		return ""
+	}
+	return strings.ReplaceAll(match, secret, redacted)
+}
+
+// secretResults are the occurrences of the secrets in hits, masked
+func secretResults(scanID uuid.UUID, tool string, hits []secretHit) []models.SecretResult {
+	now := time.Now()
+	results := make([]models.SecretResult, 0, len(hits))
+	for _, h := range hits {
+		redacted := maskSecret(h.Secret)
+		results = append(results, models.SecretResult{
+			ID:          uuid.New(),
+			ScanID:      scanID,
+			Tool:        tool,
+			Rule:        h.Rule,
+			File:        h.File,
+			Line:        h.Line,
+			Commit:      h.Commit,
+			Entropy:     h.Entropy,
+			Redacted:    redacted,
+			Match:       maskMatch(h.Match, h.Secret, redacted),
+			Fingerprint: secretFingerprint(h.Rule, h.Secret),
+			Verified:    h.Verified,
+			CreatedAt:   now,
+		})
+	}
+	return results
+}
+
+// groupSecrets merges the hits of each secret into one masked finding
+func groupSecrets(hits []secretHit) []SecretFinding {
+	byFingerprint := map[string]*SecretFinding{}
+	var order []string
+	for _, h := range hits {
+		fp := secretFingerprint(h.Rule, h.Secret)
+		f, ok := byFingerprint[fp]
+		if !ok {
+			f = &SecretFinding{
+				Fingerprint: fp,
+				Rule:        h.Rule,
+				Redacted:    maskSecret(h.Secret),
+				Entropy:     h.Entropy,
+			}
+			f.Match = maskMatch(h.Match, h.Secret, f.Redacted)
+			byFingerprint[fp] = f
+			order = append(order, fp)
+		}
+		f.Verified = f.Verified || h.Verified
+		f.Total++
+		if len(f.Occurrences) < maxSecretOccurrences {
+			f.Occurrences = append(f.Occurrences, SecretOccurrence{File: h.File, Line: h.Line, Commit: h.Commit})
+		}
+	}
+
+	findings := make([]SecretFinding, 0, len(order))
+	for _, fp := range order {
+		f := byFingerprint[fp]
+		sort.SliceStable(f.Occurrences, func(i, j int) bool {
+			if f.Occurrences[i].File != f.Occurrences[j].File {
+				return f.Occurrences[i].File < f.Occurrences[j].File
+			}
+			return f.Occurrences[i].Line < f.Occurrences[j].Line
+		})
+		findings = append(findings, *f)
+	}
+	return findings
+}
+
+// secretCloudFinding is the cloud finding of a secret; verified credentials
+// are critical, every other secret high
+func secretCloudFinding(scan *models.CloudScan, tool string, f SecretFinding) *models.CloudFinding {
+	severity := "HIGH"
+	if f.Verified {
+		severity = "CRITICAL"
+	}
+
+	var places []string
+	for _, o := range f.Occurrences {
+		place := o.File
+		if o.Line > 0 {
+			place += fmt.Sprintf(":%d", o.Line)
+		}
+		if o.Commit != "" {
+			place += fmt.Sprintf(" (commit %.12s)", o.Commit)
+		}
+		places = append(places, "- "+place)
+	}
+	if f.Total > len(f.Occurrences) {
+		places = append(places, fmt.Sprintf("- ... and %d more", f.Total-len(f.Occurrences)))
+	}
+
+	description := fmt.Sprintf("Rule: %s\nSecret: %s\nFingerprint: %s\nFound %d times:\n%s",
+		f.Rule, f.Redacted, f.Fingerprint, f.Total, strings.Join(places, "\n"))
+	if f.Verified {
+		description = "The credential was verified to work.\n" + description
+	}
+	raw, _ := json.Marshal(f)
+
+	resource := ""
+	if len(f.Occurrences) > 0 {
+		resource = f.Occurrences[0].File
+	}
+	return &models.CloudFinding{
+		ID:          uuid.New(),
+		ScanID:      scan.ID,
+		Provider:    scan.Provider,
+		Service:     "secrets",
+		ResourceID:  resource,
+		Title:       fmt.Sprintf("Secret found: %s", f.Rule),
+		Description: description,
+		Severity:    severity,
+		Status:      "FAIL",
+		Remediation: "Revoke and rotate the credential, then remove it from the code and, for repositories, from the history.",
+		Source:      tool,
+		RawData:     string(raw),
+		CreatedAt:   time.Now(),
+	}
+}
+
+// lastLines returns the last n lines of a tool's output
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Tools reports which of the secrets tools are available
+func (s *SecretsScanner) Tools() map[string]bool {
+	tools := map[string]bool{}
+	for name, path := range map[string]string{"gitleaks": s.gitleaksPath, "trufflehog": s.trufflehogPath} {
+		_, err := os.Stat(path)
+		tools[name] = err == nil
+	}
+	return tools
+}
@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Limits of uploaded source archives, so an archive can't fill the disk
+const (
+	MaxSourceArchiveSize = 100 << 20 // bytes uploaded
+	maxSourceSize        = 500 << 20 // bytes extracted
+	maxSourceFiles       = 50000
+)
+
+// ErrUnsupportedArchive is returned by Extract for archives that are not
+// .zip, .tar, .tar.gz or .tgz
+var ErrUnsupportedArchive = errors.New("unsupported archive: upload a .zip, .tar, .tar.gz or .tgz file")
+
+// Sources keeps the source archives uploaded for Trivy filesystem scans,
+// extracted under a directory per scan until the scan ends
+type Sources struct {
+	root string
+}
+
+// NewSources prepares root for extracted sources. Directories left by scans
+// interrupted by a restart are removed: those scans never resume.
+func NewSources(root string) (*Sources, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sources directory: %w", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if _, err := uuid.Parse(e.Name()); err == nil {
+			os.RemoveAll(filepath.Join(root, e.Name()))
+		}
+	}
+	return &Sources{root: root}, nil
+}
+
+// Dir is where the sources of scanID are extracted
+func (s *Sources) Dir(scanID uuid.UUID) string {
+	return filepath.Join(s.root, scanID.String())
+}
+
+// Remove deletes the sources of scanID
+func (s *Sources) Remove(scanID uuid.UUID) {
+	if err := os.RemoveAll(s.Dir(scanID)); err != nil {
+		log.Printf("Failed to remove sources of scan %s: %v", scanID, err)
+	}
+}
+
+// Extract extracts the archive named name (its extension picks the format)
+// into the directory of scanID. Only regular files and directories are
+// extracted, and paths that would leave the directory are kept inside it.
+func (s *Sources) Extract(scanID uuid.UUID, name string, archive io.ReaderAt, size int64) error {
+	dir := s.Dir(scanID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	x := &extraction{dir: dir}
+	lower := strings.ToLower(name)
+	var err error
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		err = x.zip(archive, size)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(io.NewSectionReader(archive, 0, size))
+		if err == nil {
+			err = x.tar(gz)
+			gz.Close()
+		}
+	case strings.HasSuffix(lower, ".tar"):
+		err = x.tar(io.NewSectionReader(archive, 0, size))
+	default:
+		err = ErrUnsupportedArchive
+	}
+	if err == nil && x.files == 0 {
+		err = errors.New("the archive has no files")
+	}
+	if err != nil {
+		s.Remove(scanID)
+	}
+	return err
+}
+
+// extraction tracks the files extracted from an archive against the limits
+type extraction struct {
+	dir   string
+	files int
+	size  int64
+}
+
+// path is where an archive entry goes, or "" when it would escape dir
+func (x *extraction) path(name string) string {
+	name = filepath.Clean("/" + filepath.FromSlash(name))
+	if name == "/" {
+		return ""
+	}
+	return filepath.Join(x.dir, name)
+}
+
+func (x *extraction) file(name string, r io.Reader) error {
+	path := x.path(name)
+	if path == "" {
+		return nil
+	}
+	x.files++
+	if x.files > maxSourceFiles {
+		return fmt.Errorf("the archive has more than %d files", maxSourceFiles)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, maxSourceSize-x.size+1))
+	x.size += n
+	if x.size > maxSourceSize {
+		return fmt.Errorf("the archive extracts to more than %d MB", maxSourceSize>>20)
+	}
+	return err
+}
+
+func (x *extraction) zip(archive io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, entry := range zr.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = x.file(entry.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extraction) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := x.file(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -120,10 +120,20 @@ func (s *TrivyScanner) Scan(ctx context.Context, scan *models.CloudScan, config
 	}
 
 	// Add security checks
-	if targetType == "config" || targetType == "fs" {
+	if targetType == "config" || targetType == "fs" || targetType == "repo" {
 		args = append(args, "--scanners", "vuln,config,secret")
 	}
 
+	// Repository revision, the default branch otherwise
+	if targetType == "repo" && config != nil {
+		if config.TrivyBranch != "" {
+			args = append(args, "--branch", config.TrivyBranch)
+		}
+		if config.TrivyCommit != "" {
+			args = append(args, "--commit", config.TrivyCommit)
+		}
+	}
+
 	args = append(args, target)
 
 	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Running: trivy %s", strings.Join(args, " ")))
@@ -228,7 +238,8 @@ func (s *TrivyScanner) processResults(scanID uuid.UUID, provider, target string,
 			}
 		}
 
-		// Process secrets as findings
+		// Process secrets as findings; a committed secret is at least high
+		// severity whatever the rule says, since it has to be rotated
 		for _, secret := range result.Secrets {
 			finding := &models.CloudFinding{
 				ID:          uuid.New(),
@@ -237,8 +248,8 @@ func (s *TrivyScanner) processResults(scanID uuid.UUID, provider, target string,
 				Service:     "secrets",
 				ResourceID:  result.Target,
 				Title:       fmt.Sprintf("Secret found: %s", secret.Title),
-				Description: fmt.Sprintf("Category: %s\nLine: %d-%d\nMatch: %s", secret.Category, secret.StartLine, secret.EndLine, secret.Match),
-				Severity:    strings.ToUpper(secret.Severity),
+				Description: fmt.Sprintf("Category: %s\nRule: %s (%s)\nLine: %d-%d\nMatch: %s", secret.Category, secret.RuleID, secret.Severity, secret.StartLine, secret.EndLine, secret.Match),
+				Severity:    secretSeverity(secret.Severity),
 				Status:      "FAIL",
 				Source:      "trivy",
 				CreatedAt:   time.Now(),
@@ -273,6 +284,16 @@ func (s *TrivyScanner) ScanFilesystem(ctx context.Context, scan *models.CloudSca
 	return s.Scan(ctx, scan, scan.Config)
 }
 
+// ScanRepository scans a remote Git repository
+func (s *TrivyScanner) ScanRepository(ctx context.Context, scan *models.CloudScan, repoURL string) error {
+	if scan.Config == nil {
+		scan.Config = &models.CloudScanConfig{}
+	}
+	scan.Config.TrivyTargetType = "repo"
+	scan.Config.TrivyTarget = repoURL
+	return s.Scan(ctx, scan, scan.Config)
+}
+
 // ScanConfig scans infrastructure as code
 func (s *TrivyScanner) ScanConfig(ctx context.Context, scan *models.CloudScan, path string) error {
 	if scan.Config == nil {
@@ -283,6 +304,15 @@ func (s *TrivyScanner) ScanConfig(ctx context.Context, scan *models.CloudScan, p
 	return s.Scan(ctx, scan, scan.Config)
 }
 
+// secretSeverity is the severity of a secret hit: Trivy's when critical,
+// high otherwise
+func secretSeverity(severity string) string {
+	if strings.ToUpper(severity) == "CRITICAL" {
+		return "CRITICAL"
+	}
+	return "HIGH"
+}
+
 // IsAvailable checks if Trivy is available
 func (s *TrivyScanner) IsAvailable() bool {
 	_, err := os.Stat(s.trivyPath)
@@ -8,7 +8,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/security-scanner/cms-service/internal/database"
 	"github.com/security-scanner/cms-service/internal/handlers"
+	"github.com/security-scanner/cms-service/internal/middleware"
 	"github.com/security-scanner/cms-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/internalauth"
 )
 
 func getEnv(key, defaultValue string) string {
@@ -45,32 +48,56 @@ func main() {
 	// Create scan manager
 	manager := scanner.NewScanManager(db, whatwebPath, cmseekPath, wpscanPath, joomscanPath, droopescanPath)
 
+	// Scans with high-severity findings are posted to Slack and Discord
+	chatChannels, err := chat.FromEnv(getEnv("SLACK_WEBHOOK_URL", ""), getEnv("DISCORD_WEBHOOK_URL", ""),
+		getEnv("CHAT_NOTIFY_SEVERITIES", "critical,high"))
+	if err != nil {
+		log.Fatalf("Invalid chat notification configuration: %v", err)
+	}
+	chatNotifier, err := db.ChatNotifier(chatChannels, getEnv("REPORT_BASE_URL", "http://localhost:3000"))
+	if err != nil {
+		log.Fatalf("Failed to initialize chat notifications: %v", err)
+	}
+	manager.SetChat(chatNotifier)
+
 	// Create handlers
 	h := handlers.NewHandler(db, manager)
 
+	// Scans of targets outside the scope rules are rejected
+	scopeChecker, err := db.Scope()
+	if err != nil {
+		log.Fatalf("Failed to initialize scope rules: %v", err)
+	}
+	h.SetScope(scopeChecker)
+
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	r.Use(middleware.Errors())
 
 	// CORS configuration
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
 		AllowCredentials: true,
 	}))
 
 	// Health check
 	r.GET("/health", h.HealthCheck)
 
-	// API routes
-	api := r.Group("/api")
+	// API routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalAuthSecret := getEnv("INTERNAL_AUTH_SECRET", "")
+	internalauth.LogStatus(internalAuthSecret)
+	api := r.Group("/api", middleware.InternalAuth(internalAuthSecret))
 	{
 		// CMS Scans
 		cmsScans := api.Group("/cmsscans")
 		{
 			cmsScans.GET("/", h.GetScans)
+			cmsScans.GET("/findings", h.ListFindings) // normalized, for the gateway's /api/findings
 			cmsScans.GET("/:id", h.GetScan)
 			cmsScans.POST("/", h.CreateScan)
 			cmsScans.DELETE("/:id", h.DeleteScan)
@@ -82,6 +109,9 @@ func main() {
 
 		// Tools info
 		api.GET("/tools", h.GetAvailableTools)
+
+		// Scan types and their config, for forms and config validation
+		api.GET("/capabilities", h.GetCapabilities)
 	}
 
 	// Start server
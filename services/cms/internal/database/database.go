@@ -1,14 +1,24 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/security-scanner/cms-service/internal/models"
+	"github.com/security-scanner/shared/pkg/chat"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
 )
 
 type Database struct {
@@ -19,39 +29,10 @@ func New(host, port, user, password, dbname string) (*Database, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
-	// Retry logic with exponential backoff
-	maxRetries := 10
-	var db *sql.DB
-	var err error
-
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", connStr)
-		if err != nil {
-			waitTime := time.Duration(1<<uint(i)) * time.Second
-			if waitTime > 30*time.Second {
-				waitTime = 30 * time.Second
-			}
-			fmt.Printf("Failed to open database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
-		}
-
-		err = db.Ping()
-		if err == nil {
-			break
-		}
-
-		db.Close()
-		waitTime := time.Duration(1<<uint(i)) * time.Second
-		if waitTime > 30*time.Second {
-			waitTime = 30 * time.Second
-		}
-		fmt.Printf("Failed to ping database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-		time.Sleep(waitTime)
-	}
-
+	// Retries while the database starts, like every service
+	db, err := shareddb.OpenSQL("postgres", connStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
 
 	database := &Database{db: db}
@@ -72,9 +53,13 @@ func (d *Database) createTables() error {
 			status VARCHAR(50) DEFAULT 'pending',
 			progress INT DEFAULT 0,
 			config JSONB,
+			origin VARCHAR(150) NOT NULL DEFAULT 'manual',
+			project_id VARCHAR(63) NOT NULL DEFAULT 'default',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`ALTER TABLE cms_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual'`,
+		`ALTER TABLE cms_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default'`,
 		`CREATE TABLE IF NOT EXISTS cms_results (
 			id UUID PRIMARY KEY,
 			scan_id UUID REFERENCES cms_scans(id) ON DELETE CASCADE,
@@ -120,6 +105,8 @@ func (d *Database) createTables() error {
 		`CREATE INDEX IF NOT EXISTS idx_cms_technologies_scan_id ON cms_technologies(scan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_cms_wpscan_results_scan_id ON cms_wpscan_results(scan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_cms_scan_logs_scan_id ON cms_scan_logs(scan_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_cms_scans_origin ON cms_scans(origin)`,
+		`CREATE INDEX IF NOT EXISTS idx_cms_scans_project_id ON cms_scans(project_id)`,
 	}
 
 	for _, query := range queries {
@@ -142,19 +129,19 @@ func (d *Database) CreateScan(scan *models.CMSScan) error {
 		}
 	}
 
-	query := `INSERT INTO cms_scans (id, name, target, scan_type, status, progress, config, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	_, err = d.db.Exec(query, scan.ID, scan.Name, scan.Target, scan.ScanType, scan.Status, scan.Progress, configJSON, scan.CreatedAt, scan.UpdatedAt)
+	query := `INSERT INTO cms_scans (id, name, target, scan_type, status, progress, config, origin, project_id, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = d.db.Exec(query, scan.ID, scan.Name, scan.Target, scan.ScanType, scan.Status, scan.Progress, configJSON, scan.Origin, scan.ProjectID, scan.CreatedAt, scan.UpdatedAt)
 	return err
 }
 
 func (d *Database) GetScan(id uuid.UUID) (*models.CMSScan, error) {
-	query := `SELECT id, name, target, scan_type, status, progress, config, created_at, updated_at FROM cms_scans WHERE id = $1`
+	query := `SELECT id, name, target, scan_type, status, progress, config, origin, project_id, created_at, updated_at FROM cms_scans WHERE id = $1`
 	row := d.db.QueryRow(query, id)
 
 	var scan models.CMSScan
 	var configJSON []byte
-	err := row.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress, &configJSON, &scan.CreatedAt, &scan.UpdatedAt)
+	err := row.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress, &configJSON, &scan.Origin, &scan.ProjectID, &scan.CreatedAt, &scan.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -167,11 +154,39 @@ func (d *Database) GetScan(id uuid.UUID) (*models.CMSScan, error) {
 	return &scan, nil
 }
 
-func (d *Database) GetAllScans() ([]models.CMSScan, error) {
-	query := `SELECT id, name, target, scan_type, status, progress, config, created_at, updated_at FROM cms_scans ORDER BY created_at DESC`
-	rows, err := d.db.Query(query)
+// GetAllScans returns the page of the scans, only those matching
+// originFilter and of projectScope when set, and how many match;
+// originFilter must have been checked with origin.ValidFilter
+func (d *Database) GetAllScans(originFilter, projectScope string, page pagination.Params) ([]models.CMSScan, int, error) {
+	where := ""
+	args := []interface{}{}
+	conditions := []string{}
+	if originFilter != "" {
+		condition, value, err := origin.Filter("origin", len(args)+1, originFilter)
+		if err != nil {
+			return nil, 0, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, value)
+	}
+	if projectScope != "" {
+		conditions = append(conditions, project.Filter("project_id", len(args)+1))
+		args = append(args, projectScope)
+	}
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM cms_scans`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, name, target, scan_type, status, progress, config, origin, project_id, created_at, updated_at FROM cms_scans` +
+		where + page.OrderBy()
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -179,9 +194,9 @@ func (d *Database) GetAllScans() ([]models.CMSScan, error) {
 	for rows.Next() {
 		var scan models.CMSScan
 		var configJSON []byte
-		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress, &configJSON, &scan.CreatedAt, &scan.UpdatedAt)
+		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress, &configJSON, &scan.Origin, &scan.ProjectID, &scan.CreatedAt, &scan.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		if len(configJSON) > 0 {
 			scan.Config = &models.CMSScanConfig{}
@@ -190,7 +205,7 @@ func (d *Database) GetAllScans() ([]models.CMSScan, error) {
 		scans = append(scans, scan)
 	}
 
-	return scans, nil
+	return scans, total, nil
 }
 
 func (d *Database) UpdateScanStatus(id uuid.UUID, status string, progress int, errorMsg *string) error {
@@ -306,6 +321,60 @@ func (d *Database) GetWPScanResults(scanID uuid.UUID) ([]models.WPScanResult, er
 	return results, nil
 }
 
+// ListFindings returns the vulnerabilities WPScan found in every scan, or
+// in one scan, as normalized findings. WPScan rarely has a CVSS score for
+// them; those without one are medium.
+func (d *Database) ListFindings(scanID *uuid.UUID, projectScope string) ([]models.Finding, error) {
+	query := `SELECT id, scan_id, url, vulnerabilities, created_at FROM cms_wpscan_results
+		WHERE ($1::uuid IS NULL OR scan_id = $1)
+		  AND ($2 = '' OR scan_id IN (SELECT id FROM cms_scans WHERE project_id = $2))`
+	rows, err := d.db.Query(query, scanID, projectScope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []models.Finding{}
+	for rows.Next() {
+		var resultID, resultScanID uuid.UUID
+		var url string
+		var vulnsJSON []byte
+		var createdAt time.Time
+		if err := rows.Scan(&resultID, &resultScanID, &url, &vulnsJSON, &createdAt); err != nil {
+			return nil, err
+		}
+		var vulns []models.WPVuln
+		if len(vulnsJSON) > 0 {
+			json.Unmarshal(vulnsJSON, &vulns)
+		}
+		for i, vuln := range vulns {
+			f := models.Finding{
+				// Stable across requests, since the vulnerabilities have no ID of their own
+				ID:        uuid.NewSHA1(resultID, []byte(strconv.Itoa(i))),
+				Source:    "cmsscans",
+				Tool:      "wpscan",
+				ScanID:    resultScanID,
+				Title:     vuln.Title,
+				Severity:  shared.SeverityMedium,
+				Target:    url,
+				CreatedAt: createdAt,
+			}
+			if vuln.Component != "" {
+				f.Target = url + " (" + vuln.Component + ")"
+			}
+			if vuln.CVE != nil {
+				f.Identifier = *vuln.CVE
+			}
+			if vuln.CVSS != nil {
+				f.Severity = shared.SeverityFromCVSS(*vuln.CVSS)
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	return findings, nil
+}
+
 // Log operations
 func (d *Database) AddLog(scanID uuid.UUID, level, message string) error {
 	query := `INSERT INTO cms_scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`
@@ -337,3 +406,25 @@ func (d *Database) GetLogs(scanID uuid.UUID) ([]models.ScanLog, error) {
 func (d *Database) Close() error {
 	return d.db.Close()
 }
+
+// Scope creates the scope rule table, managed through the gateway, and
+// returns the checker scans' targets must pass
+func (d *Database) Scope() (*scope.Checker, error) {
+	if _, err := d.db.Exec(scope.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}), nil
+}
+
+// ChatNotifier creates the chat channel table, managed through the gateway,
+// and returns a notifier posting to its channels and to the env ones
+func (d *Database) ChatNotifier(env []chat.Channel, reportBaseURL string) (*chat.Notifier, error) {
+	if _, err := d.db.Exec(chat.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return chat.NewNotifier(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}, env, reportBaseURL), nil
+}
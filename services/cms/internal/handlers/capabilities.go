@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+var (
+	whatwebOptions = []capabilities.Field{
+		{Name: "whatweb_aggression", Type: capabilities.Integer, Default: 1, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(4)},
+		{Name: "whatweb_plugins", Type: capabilities.String, Description: "Comma-separated WhatWeb plugins"},
+	}
+	cmseekOptions = []capabilities.Field{
+		{Name: "cmseek_follow_redirect", Type: capabilities.Boolean, Default: false},
+		{Name: "cmseek_random_agent", Type: capabilities.Boolean, Default: false},
+	}
+	wpscanOptions = []capabilities.Field{
+		{Name: "wpscan_api_token", Type: capabilities.String, Description: "WPScan API token, for vulnerability data"},
+		{Name: "wpscan_enumerate", Type: capabilities.Array, Default: []string{"vp", "vt", "u"}, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"vp", "ap", "p", "vt", "at", "t", "tt", "cb", "dbe", "u", "m"}}},
+		{Name: "wpscan_detection_mode", Type: capabilities.String, Default: "mixed", Enum: []string{"mixed", "passive", "aggressive"}},
+	}
+	joomscanOptions = []capabilities.Field{
+		{Name: "joomscan_enum_components", Type: capabilities.Boolean, Default: false},
+	}
+	droopescanOptions = []capabilities.Field{
+		{Name: "droopescan_cms", Type: capabilities.String, Default: "auto", Enum: []string{"drupal", "joomla", "moodle", "silverstripe", "auto"}},
+	}
+)
+
+// cmsCapabilities describes CreateCMSScanRequest for each scan type
+var cmsCapabilities = capabilities.New("cms", []capabilities.ScanType{
+	cmsScanType("whatweb", "whatweb", "Technology Detection", "Web technologies and CMS with WhatWeb", whatwebOptions),
+	cmsScanType("cmseek", "cmseek", "CMS Detection", "CMS, version and users with CMSeeK", cmseekOptions),
+	cmsScanType("wpscan", "wpscan", "WordPress Scan", "WordPress plugins, themes, users and their vulnerabilities", wpscanOptions),
+	cmsScanType("joomscan", "joomscan", "Joomla Scan", "Joomla version, components and vulnerabilities with JoomScan", joomscanOptions),
+	cmsScanType("droopescan", "droopescan", "Droopescan", "Drupal, Joomla, Moodle and SilverStripe with droopescan", droopescanOptions),
+	cmsScanType("drupal", "droopescan", "Drupal Scan", "droopescan of a Drupal site", nil),
+	cmsScanType("joomla", "joomscan", "Joomla Scan", "JoomScan of a Joomla site", joomscanOptions),
+	cmsScanType("full", "full", "Full CMS Scan", "WhatWeb, CMSeeK and droopescan, then WPScan or JoomScan when WordPress or Joomla is detected",
+		concat(whatwebOptions, cmseekOptions, wpscanOptions, joomscanOptions, droopescanOptions)),
+})
+
+// cmsScanType describes a scan type whose config holds options and the
+// general ones
+func cmsScanType(name, tool, title, description string, options []capabilities.Field) capabilities.ScanType {
+	return capabilities.ScanType{
+		Name:        name,
+		Title:       title,
+		Description: description,
+		Tool:        tool,
+		Endpoint:    "POST /api/cmsscans",
+		Fields: []capabilities.Field{
+			{Name: "name", Type: capabilities.String, Required: true},
+			{Name: "target", Type: capabilities.String, Required: true, Format: "uri"},
+			{Name: "scan_type", Type: capabilities.String, Required: true, Default: name, Enum: []string{name}},
+			{Name: "config", Type: capabilities.Object, Fields: concat(options, []capabilities.Field{
+				{Name: "timeout", Type: capabilities.Integer, Minimum: capabilities.Bound(1), Description: "Seconds the tool may run"},
+				{Name: "headers", Type: capabilities.Object, Description: "Headers sent with every request, by name"},
+			})},
+		},
+	}
+}
+
+func concat(lists ...[]capabilities.Field) []capabilities.Field {
+	fields := []capabilities.Field{}
+	for _, list := range lists {
+		fields = append(fields, list...)
+	}
+	return fields
+}
+
+// GetCapabilities describes the scan types and their config, for UIs and
+// the CLI to render forms and validate configurations
+func (h *Handler) GetCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, cmsCapabilities)
+}
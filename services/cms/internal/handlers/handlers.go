@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,11 +10,17 @@ import (
 	"github.com/security-scanner/cms-service/internal/database"
 	"github.com/security-scanner/cms-service/internal/models"
 	"github.com/security-scanner/cms-service/internal/scanner"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
 )
 
 type Handler struct {
 	db      *database.Database
 	manager *scanner.ScanManager
+	scope   *scope.Checker
 }
 
 func NewHandler(db *database.Database, manager *scanner.ScanManager) *Handler {
@@ -23,17 +30,47 @@ func NewHandler(db *database.Database, manager *scanner.ScanManager) *Handler {
 	}
 }
 
-// GetScans returns all CMS scans
+// SetScope rejects scans of targets outside the scope rules
+func (h *Handler) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
+// cmsScanSortFields are the fields GetScans sorts by, and their columns
+var cmsScanSortFields = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"name":       "name",
+	"target":     "target",
+	"scan_type":  "scan_type",
+	"status":     "status",
+	"progress":   "progress",
+}
+
+// GetScans returns a page of CMS scans (?page=, ?limit=, ?sort=)
 func (h *Handler) GetScans(c *gin.Context) {
-	scans, err := h.db.GetAllScans()
+	originFilter := c.Query("origin")
+	if originFilter != "" {
+		if err := origin.ValidFilter(originFilter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), cmsScanSortFields, "-created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scans, total, err := h.db.GetAllScans(originFilter, project.Scope(c.Query("project"), c.GetHeader(project.Header)), page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scans"})
 		return
 	}
-	if scans == nil {
-		scans = []models.CMSScan{}
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.Request.URL.RequestURI()) {
+		c.Header(key, value)
 	}
-	c.JSON(http.StatusOK, scans)
+	c.JSON(http.StatusOK, result)
 }
 
 // GetScan returns a single CMS scan
@@ -76,6 +113,10 @@ func (h *Handler) CreateScan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan type. Must be: whatweb, cmseek, wpscan, joomscan, droopescan, drupal, joomla, or full"})
 		return
 	}
+	if status, body := scopeError(h.scope, project.FromRequest(c.GetHeader(project.Header)), req.Target); status != 0 {
+		c.JSON(status, body)
+		return
+	}
 
 	scan := &models.CMSScan{
 		ID:        uuid.New(),
@@ -85,6 +126,8 @@ func (h *Handler) CreateScan(c *gin.Context) {
 		Status:    "pending",
 		Progress:  0,
 		Config:    req.Config,
+		Origin:    origin.FromRequest(c.GetHeader(origin.Header), c.GetHeader(origin.UserIDHeader)),
+		ProjectID: project.FromRequest(c.GetHeader(project.Header)),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -192,6 +235,26 @@ func (h *Handler) GetScanTechnologies(c *gin.Context) {
 	c.JSON(http.StatusOK, techs)
 }
 
+// ListFindings returns the vulnerabilities of every scan as normalized
+// findings, which the gateway lists with the other services' findings
+// (?severity=, ?target=, ?scan_id=, ?limit=)
+func (h *Handler) ListFindings(c *gin.Context) {
+	filter, err := shared.ParseFindingFilter(c.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	findings, err := h.db.ListFindings(filter.ScanID, project.Scope(c.Query("project"), c.GetHeader(project.Header)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch findings"})
+		return
+	}
+	list := filter.Apply(findings)
+	c.Header(pagination.HeaderTotalCount, strconv.Itoa(list.Total))
+	c.JSON(http.StatusOK, list)
+}
+
 // GetScanLogs returns scan logs
 func (h *Handler) GetScanLogs(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/security-scanner/shared/pkg/apierror"
+)
+
+// envelopeWriter holds back error bodies so Errors can rewrite them
+type envelopeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *envelopeWriter) Write(data []byte) (int, error) {
+	if w.Status() >= 400 {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	if w.Status() >= 400 {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {
+	if w.Status() < 400 {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Errors gives every error response the shared envelope (error, code,
+// message, details, request_id) and tags every request with an
+// X-Request-ID, reusing the gateway's. It recovers panics itself so those
+// get an envelope too.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(apierror.RequestIDHeader)
+		if requestID == "" {
+			requestID = apierror.NewRequestID()
+			c.Request.Header.Set(apierror.RequestIDHeader, requestID)
+		}
+		c.Header(apierror.RequestIDHeader, requestID)
+
+		w := &envelopeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic recovered: %v", r)
+				w.body.Reset()
+				w.WriteHeader(http.StatusInternalServerError)
+				c.Abort()
+			}
+			c.Writer = w.ResponseWriter
+			if w.Status() < 400 || w.ResponseWriter.Written() {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.ResponseWriter.Write(apierror.Normalize(w.Status(), w.body.Bytes(), requestID))
+		}()
+
+		c.Next()
+	}
+}
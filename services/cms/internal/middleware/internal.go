@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/security-scanner/shared/pkg/internalauth"
+)
+
+// InternalAuth rejects requests that don't carry a valid gateway token for
+// this method and path, so the service can't be called directly by anything
+// else that reaches it. Disabled when secret is empty.
+func InternalAuth(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			return
+		}
+		if !internalauth.Valid(c.GetHeader(internalauth.Header), secret, c.Request.Method, c.Request.URL.Path) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Requests must come through the gateway"})
+			return
+		}
+		c.Set(internalauth.VerifiedLocal, true)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
 // CMSScan represents a CMS detection scan
@@ -15,6 +16,8 @@ type CMSScan struct {
 	Status    string     `json:"status"`    // pending, running, completed, failed, cancelled
 	Progress  int        `json:"progress"`
 	Config    *CMSScanConfig `json:"config,omitempty"`
+	Origin    string     `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID string     `json:"project_id"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 }
@@ -112,14 +115,12 @@ type WPVuln struct {
 	Reference string   `json:"reference,omitempty"`
 }
 
-// ScanLog represents a log entry for a scan
-type ScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"` // info, warning, error
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
-}
+// ScanLog represents a log entry for a scan (shared by all services)
+type ScanLog = shared.ScanLog
+
+// Finding is a finding in the model every service lists them with (shared
+// by all services)
+type Finding = shared.Finding
 
 // CreateCMSScanRequest represents a request to create a new CMS scan
 type CreateCMSScanRequest struct {
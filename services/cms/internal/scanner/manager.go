@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/security-scanner/cms-service/internal/database"
 	"github.com/security-scanner/cms-service/internal/models"
+	"github.com/security-scanner/shared/pkg/chat"
 )
 
 // ScanManager coordinates CMS scanning operations
@@ -18,6 +19,7 @@ type ScanManager struct {
 	wpscan         *WPScanScanner
 	joomscan       *JoomScanScanner
 	droopescan     *DroopescanScanner
+	chat           *chat.Notifier
 	activeScans    map[uuid.UUID]context.CancelFunc
 	activeScansMux sync.Mutex
 }
@@ -35,6 +37,12 @@ func NewScanManager(db *database.Database, whatwebPath, cmseekPath, wpscanPath,
 	}
 }
 
+// SetChat posts scans whose WPScan vulnerabilities reach a channel's
+// severities to Slack and Discord
+func (m *ScanManager) SetChat(n *chat.Notifier) {
+	m.chat = n
+}
+
 // StartScan initiates a new CMS scan
 func (m *ScanManager) StartScan(scan *models.CMSScan) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -101,6 +109,31 @@ func (m *ScanManager) runScan(ctx context.Context, scan *models.CMSScan) {
 
 	m.db.AddLog(scan.ID, "info", "Scan completed successfully")
 	m.db.UpdateScanStatus(scan.ID, "completed", 100, nil)
+	m.notifyChat(scan)
+}
+
+// notifyChat posts the WPScan vulnerabilities of a completed scan by severity
+func (m *ScanManager) notifyChat(scan *models.CMSScan) {
+	if m.chat == nil {
+		return
+	}
+	findings, err := m.db.ListFindings(&scan.ID, "")
+	if err != nil || len(findings) == 0 {
+		return
+	}
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	m.chat.Notify(chat.Summary{
+		Service:    "cms-service",
+		Tool:       "wpscan",
+		ScanID:     scan.ID.String(),
+		ScanName:   scan.Name,
+		Target:     scan.Target,
+		Counts:     counts,
+		ReportPath: "/cms-scans/" + scan.ID.String(),
+	})
 }
 
 func (m *ScanManager) runFullScan(ctx context.Context, scan *models.CMSScan) error {
@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/security-scanner/gateway/internal/auth"
+	"github.com/security-scanner/gateway/internal/comments"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/gateway/internal/handlers"
+	"github.com/security-scanner/gateway/internal/integrations"
+	"github.com/security-scanner/gateway/internal/maintenance"
 	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/ownership"
+	"github.com/security-scanner/gateway/internal/projects"
 	"github.com/security-scanner/gateway/internal/proxy"
+	"github.com/security-scanner/gateway/internal/scoperules"
+	"github.com/security-scanner/gateway/internal/searches"
+	"github.com/security-scanner/gateway/internal/tlspolicies"
+	"github.com/security-scanner/gateway/internal/usage"
 	"github.com/security-scanner/gateway/pkg/config"
+	sharedclient "github.com/security-scanner/shared/pkg/client"
+	shareddb "github.com/security-scanner/shared/pkg/database"
 )
 
 func main() {
@@ -27,19 +44,274 @@ func main() {
 	app := fiber.New(fiber.Config{
 		AppName:      "Security Scanner API Gateway",
 		ServerHeader: "SecurityScanner",
+		// Source archives for Trivy are up to 100 MB, plus the multipart framing
+		BodyLimit: 101 << 20,
+		// Path checks (ownership, maintenance, roles) compare exact paths, so
+		// /api/Scans must not reach the /api/scans route
+		CaseSensitive: true,
 	})
 
 	// Global middleware
+	app.Use(middleware.Errors()) // first, so it sees every error
 	app.Use(recover.New())
 	app.Use(middleware.Logger())
 	app.Use(middleware.CORS())
 
 	// Create proxy
-	serviceProxy := proxy.NewServiceProxy()
+	serviceProxy := proxy.NewServiceProxy(cfg.InternalAuthSecret)
+	if cfg.InternalAuthSecret != "" {
+		log.Println("🔐 Signing requests to backend services")
+	} else {
+		log.Println("⚠️ INTERNAL_AUTH_SECRET is not set: backend services can't tell requests from the gateway apart from direct calls that forge X-User-ID/X-User-Role")
+	}
+
+	// Authentication is set up first so that proxied requests carry the caller's identity
+	db := openDatabase(cfg)
+	leases := openLeases(db)
+	authHandler, scimHandler := setupAuth(cfg, db, leases)
+	apiKeyHandler, resolvers := setupCredentials(cfg, db, authHandler)
+	app.Use(middleware.Auth(middleware.AuthOptions{
+		Resolvers: resolvers,
+		Required:  cfg.AuthRequired,
+		Public:    cfg.AuthPublicPaths,
+	}))
+	if cfg.AuthRequired {
+		log.Printf("🔒 Authentication required except for %s", strings.Join(cfg.AuthPublicPaths, ", "))
+	}
+	app.Use(middleware.Roles(middleware.RoleOptions{
+		AdminToken:       cfg.AdminToken,
+		ViewerWritePaths: cfg.ViewerWritePaths,
+		AdminPaths:       cfg.AdminPaths,
+	}))
+
+	// Read-only mode for demo and training deployments: existing data is
+	// served, nothing can be started, deleted or changed
+	if cfg.ReadOnly {
+		app.Use(middleware.ReadOnly(cfg.ReadOnlyAllowedPaths))
+		log.Printf("👀 Read-only mode: only GET requests and %s are allowed", strings.Join(cfg.ReadOnlyAllowedPaths, ", "))
+	}
 
 	// API routes
 	api := app.Group("/api")
 
+	// Usage analytics per tenant and API key
+	if db != nil && cfg.UsageTracking {
+		tracker, err := usage.NewTracker(db, cfg.UsageRetentionDays)
+		if err != nil {
+			log.Fatalf("Failed to initialize usage tracking: %v", err)
+		}
+		tracker.SetLeases(leases)
+		go tracker.Start(context.Background())
+		api.Use(tracker.Middleware())
+
+		usageHandler := handlers.NewUsageHandler(tracker, cfg.AdminToken)
+		api.Get("/usage", usageHandler.GetUsage)
+		api.Get("/usage/anomalies", usageHandler.GetAnomalies)
+		log.Println("📊 API usage tracking enabled")
+	}
+
+	// Per-caller rate limit, reported in X-RateLimit-* headers; after usage
+	// tracking so rejected requests are counted. Callers are keyed on the
+	// user Auth resolved, never on the credentials they sent, so made-up
+	// keys or tokens (and failed logins) all count against the caller's IP.
+	if cfg.RateLimitPerMinute > 0 {
+		api.Use(middleware.RateLimit(cfg.RateLimitPerMinute, func(c *fiber.Ctx) string {
+			if userID := c.Get(middleware.UserIDHeader); userID != "" {
+				return "user:" + userID
+			}
+			return "ip:" + c.IP()
+		}))
+		log.Printf("🚦 Rate limit: %d requests a minute per caller", cfg.RateLimitPerMinute)
+	}
+
+	// Projects grouping the scans, templates, findings and assets of each
+	// engagement, selected with X-Tenant-ID
+	if db != nil {
+		projectStore, err := projects.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize projects: %v", err)
+		}
+		api.Use(projectStore.Middleware())
+
+		projectHandler := handlers.NewProjectHandler(projectStore, cfg.AdminToken)
+		api.Get("/projects", projectHandler.ListProjects)
+		api.Post("/projects", projectHandler.CreateProject)
+		api.Get("/projects/:id", projectHandler.GetProject)
+		api.Put("/projects/:id", projectHandler.UpdateProject)
+		api.Delete("/projects/:id", projectHandler.DeleteProject)
+	}
+
+	// Slack and Discord channels the services post high-severity scans to
+	if db != nil {
+		chatStore, err := integrations.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize chat channels: %v", err)
+		}
+		chatHandler := handlers.NewChatHandler(chatStore, cfg.ReportBaseURL, cfg.AdminToken)
+		api.Get("/integrations/chat", chatHandler.ListChannels)
+		api.Post("/integrations/chat", chatHandler.CreateChannel)
+		api.Get("/integrations/chat/:id", chatHandler.GetChannel)
+		api.Put("/integrations/chat/:id", chatHandler.UpdateChannel)
+		api.Delete("/integrations/chat/:id", chatHandler.DeleteChannel)
+		api.Post("/integrations/chat/:id/test", chatHandler.TestChannel)
+	}
+
+	// Allowed and denied CIDRs and domains; the services reject scans of
+	// targets outside them
+	if db != nil {
+		scopeStore, err := scoperules.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize scope rules: %v", err)
+		}
+		scopeHandler := handlers.NewScopeHandler(scopeStore, cfg.AdminToken)
+		api.Get("/scope/rules", scopeHandler.ListRules)
+		api.Post("/scope/rules", scopeHandler.CreateRule)
+		api.Delete("/scope/rules/:id", scopeHandler.DeleteRule)
+		api.Post("/scope/check", scopeHandler.CheckTargets)
+	}
+
+	// Protocols, ciphers, key sizes and certificate lifetimes testssl.sh
+	// results must meet; the web service reports every breach as a finding
+	if db != nil {
+		tlsPolicyStore, err := tlspolicies.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize TLS policies: %v", err)
+		}
+		tlsPolicyHandler := handlers.NewTLSPolicyHandler(tlsPolicyStore, cfg.AdminToken)
+		api.Get("/tls-policies", tlsPolicyHandler.ListPolicies)
+		api.Put("/tls-policies", tlsPolicyHandler.PutPolicy)
+		api.Delete("/tls-policies", tlsPolicyHandler.DeletePolicy)
+	}
+
+	// Domain ownership verification, required before scans by OWNERSHIP_POLICY
+	if db != nil {
+		if !ownership.ValidPolicy(cfg.OwnershipPolicy) {
+			log.Fatalf("Invalid OWNERSHIP_POLICY %q, expected off, aggressive or all", cfg.OwnershipPolicy)
+		}
+		ownershipStore, err := ownership.NewStore(db, time.Duration(cfg.OwnershipValidityDays)*24*time.Hour)
+		if err != nil {
+			log.Fatalf("Failed to initialize ownership verification: %v", err)
+		}
+		api.Use(ownershipStore.Middleware(cfg.OwnershipPolicy))
+
+		ownershipHandler := handlers.NewOwnershipHandler(ownershipStore, cfg.OwnershipPolicy, cfg.AdminToken)
+		api.Get("/ownership/domains", ownershipHandler.ListDomains)
+		api.Post("/ownership/domains", ownershipHandler.RequestDomain)
+		api.Get("/ownership/domains/:domain", ownershipHandler.GetDomain)
+		api.Post("/ownership/domains/:domain/verify", ownershipHandler.VerifyDomain)
+		api.Delete("/ownership/domains/:domain", ownershipHandler.DeleteDomain)
+		if cfg.OwnershipPolicy != ownership.PolicyOff {
+			log.Printf("🔏 Domain ownership verification required for %s scans", cfg.OwnershipPolicy)
+		}
+	} else if cfg.OwnershipPolicy != "off" {
+		log.Fatal("DATABASE_URL is required for OWNERSHIP_POLICY")
+	}
+
+	// Latest scans and findings of every service, through the shared service clients
+	clientOpts := sharedclient.Options{}
+	if cfg.InternalAuthSecret != "" {
+		clientOpts.Sign = sharedclient.InternalSigner(cfg.InternalAuthSecret)
+	}
+	networkClient := sharedclient.NewNetwork(cfg.NetworkServiceURL, clientOpts)
+	webClient := sharedclient.NewWeb(cfg.WebServiceURL, clientOpts)
+	cmsClient := sharedclient.NewCMS(cfg.CMSServiceURL, clientOpts)
+	cloudClient := sharedclient.NewCloud(cfg.CloudServiceURL, clientOpts)
+	reconClient := sharedclient.NewRecon(cfg.ReconServiceURL, clientOpts)
+	apiClient := sharedclient.NewAPI(cfg.APIServiceURL, clientOpts)
+	overviewHandler := handlers.NewOverviewHandler(map[string]*sharedclient.Scans{
+		"network":         networkClient.Scans,
+		"vulnerabilities": webClient.Vulnerabilities,
+		"webscans":        &webClient.WebScans.Scans,
+		"recon":           reconClient.Scans,
+		"apiscans":        apiClient.Scans,
+		"cmsscans":        cmsClient.Scans,
+		"cloudscans":      cloudClient.Scans,
+	})
+	api.Get("/overview", overviewHandler.GetOverview)
+
+	// Maintenance mode: scans can't be started on the services being
+	// upgraded, everything else is still served
+	maintenanceMode, err := maintenance.NewMode(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize maintenance mode: %v", err)
+	}
+	maintenanceMode.SetSources(map[string][]*sharedclient.Scans{
+		"network": {networkClient.Scans},
+		"web":     {webClient.Vulnerabilities, &webClient.WebScans.Scans},
+		"recon":   {reconClient.Scans},
+		"api":     {apiClient.Scans},
+		"cms":     {cmsClient.Scans},
+		"cloud":   {cloudClient.Scans},
+	})
+	go maintenanceMode.Start(context.Background())
+	api.Use(maintenanceMode.Middleware())
+
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceMode, cfg.AdminToken)
+	api.Get("/maintenance", maintenanceHandler.GetMaintenance)
+	api.Put("/maintenance", maintenanceHandler.PutMaintenance)
+	api.Get("/maintenance/drain", maintenanceHandler.GetDrain)
+
+	// Findings of every service in one model (nmap NSE scripts, nuclei, wpscan,
+	// prowler/scoutsuite/trivy); /api/findings/* still goes to the web service
+	findingSources := map[string]*sharedclient.Findings{
+		"network":         networkClient.Findings,
+		"vulnerabilities": webClient.Findings,
+		"cmsscans":        cmsClient.Findings,
+		"cloudscans":      cloudClient.Findings,
+	}
+	collector := searches.NewCollector(findingSources, db)
+	findingsHandler := handlers.NewFindingsHandler(findingSources, collector)
+	api.Get("/findings", findingsHandler.ListFindings)
+
+	// Saved searches over those findings, re-runnable and notifying about
+	// new matches
+	if db != nil {
+		searchStore, err := searches.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize saved searches: %v", err)
+		}
+		watcher := searches.NewWatcher(searchStore, collector, cfg.NotifyWebhookURL, cfg.NotifySecret)
+		watcher.SetChat(db.ChatNotifier(cfg.ReportBaseURL))
+		watcher.SetLeases(leases)
+		go watcher.Start(context.Background())
+
+		searchHandler := handlers.NewSearchHandler(searchStore, collector)
+		api.Get("/searches", searchHandler.ListSearches)
+		api.Post("/searches", searchHandler.CreateSearch)
+		api.Get("/searches/:id", searchHandler.GetSearch)
+		api.Put("/searches/:id", searchHandler.UpdateSearch)
+		api.Delete("/searches/:id", searchHandler.DeleteSearch)
+		api.Get("/searches/:id/run", searchHandler.RunSearch)
+	}
+
+	// Comment threads on scans and findings, included in the network
+	// service's reports with ?comments=true
+	if db != nil {
+		commentStore, err := comments.NewStore(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize comments: %v", err)
+		}
+		commentHandler := handlers.NewCommentHandler(commentStore)
+		api.Get("/comments", commentHandler.ListComments)
+		api.Put("/comments/:id", commentHandler.UpdateComment)
+		api.Delete("/comments/:id", commentHandler.DeleteComment)
+		api.Get("/comments/:subject/:source/:id", commentHandler.GetThread)
+		api.Post("/comments/:subject/:source/:id", commentHandler.CreateComment)
+	}
+
+	// Scan types of each service and the fields of their requests (types,
+	// defaults, constraints), for forms and config validation
+	for name, url := range map[string]string{
+		"network": cfg.NetworkServiceURL,
+		"web":     cfg.WebServiceURL,
+		"recon":   cfg.ReconServiceURL,
+		"api":     cfg.APIServiceURL,
+		"cms":     cfg.CMSServiceURL,
+		"cloud":   cfg.CloudServiceURL,
+	} {
+		api.Get("/capabilities/"+name, serviceProxy.ProxyTo(url+"/api/capabilities", "/api/capabilities/"+name))
+	}
+
 	// ============================================
 	// Network Service Routes (Port 8001)
 	// Handles: Nmap scans, port scanning, network discovery
@@ -50,6 +322,24 @@ func main() {
 	network.All("/templates", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
 	network.All("/templates/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
 	network.All("/reports/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/admin/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/features", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/queue", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/reputation", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/reputation/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/knowledge", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/knowledge/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/tag-rules", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/tag-rules/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/asset-owners", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/asset-owners/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/assets", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/assets/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/hosts/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	network.All("/agents/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL+"/api", "/api/network"))
+	// HTML reports of completed scans hosted per project
+	api.Get("/projects/:id/reports", serviceProxy.ProxyTo(cfg.NetworkServiceURL, ""))
+	api.Get("/projects/:id/reports/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL, ""))
 
 	// ============================================
 	// Web Service Routes (Port 8002)
@@ -65,6 +355,7 @@ func main() {
 	web.All("/ssl", serviceProxy.ProxyTo(cfg.WebServiceURL+"/api", "/api/web"))
 	web.All("/ssl/*", serviceProxy.ProxyTo(cfg.WebServiceURL+"/api", "/api/web"))
 	web.All("/templates/*", serviceProxy.ProxyTo(cfg.WebServiceURL+"/api", "/api/web"))
+	web.All("/findings/*", serviceProxy.ProxyTo(cfg.WebServiceURL+"/api", "/api/web"))
 
 	// ============================================
 	// Legacy routes (backward compatibility)
@@ -86,7 +377,10 @@ func main() {
 	api.All("/vulnerabilities", serviceProxy.ProxyTo(cfg.WebServiceURL, ""))
 	api.All("/vulnerabilities/*", serviceProxy.ProxyTo(cfg.WebServiceURL, ""))
 
-	// /api/webscans -> Web Service /api/webscans (ffuf, gowitness, testssl)
+	// /api/findings -> Web Service /api/findings (history of deduplicated findings)
+	api.All("/findings/*", serviceProxy.ProxyTo(cfg.WebServiceURL, ""))
+
+	// /api/webscans -> Web Service /api/webscans (ffuf, gowitness, testssl, credcheck, sqlmap)
 	api.All("/webscans", serviceProxy.ProxyTo(cfg.WebServiceURL, ""))
 	api.All("/webscans/*", serviceProxy.ProxyTo(cfg.WebServiceURL, ""))
 
@@ -102,7 +396,7 @@ func main() {
 	api.All("/cmsscans", serviceProxy.ProxyTo(cfg.CMSServiceURL, ""))
 	api.All("/cmsscans/*", serviceProxy.ProxyTo(cfg.CMSServiceURL, ""))
 
-	// /api/cloudscans -> Cloud Service /api/cloudscans (trivy, prowler, scoutsuite)
+	// /api/cloudscans -> Cloud Service /api/cloudscans (trivy, prowler, scoutsuite, source archives)
 	api.All("/cloudscans", serviceProxy.ProxyTo(cfg.CloudServiceURL, ""))
 	api.All("/cloudscans/*", serviceProxy.ProxyTo(cfg.CloudServiceURL, ""))
 
@@ -114,6 +408,52 @@ func main() {
 	api.All("/vulnerability-templates", serviceProxy.ProxyTo(cfg.NetworkServiceURL, ""))
 	api.All("/vulnerability-templates/*", serviceProxy.ProxyTo(cfg.NetworkServiceURL, ""))
 
+	// ============================================
+	// Authentication (SSO via OIDC and/or LDAP)
+	// ============================================
+	authRoutes := api.Group("/auth")
+	if apiKeyHandler != nil {
+		authRoutes.Get("/api-keys", apiKeyHandler.ListKeys)
+		authRoutes.Post("/api-keys", apiKeyHandler.CreateKey)
+		authRoutes.Delete("/api-keys/:id", apiKeyHandler.RevokeKey)
+	}
+	if authHandler != nil {
+		authRoutes.Get("/providers", authHandler.Providers)
+		authRoutes.Get("/oidc/login", authHandler.OIDCLogin)
+		authRoutes.Get("/oidc/callback", authHandler.OIDCCallback)
+		authRoutes.Post("/ldap/login", authHandler.LDAPLogin)
+		authRoutes.Post("/refresh", authHandler.Refresh)
+		authRoutes.Get("/me", authHandler.Me)
+		authRoutes.Post("/logout", authHandler.Logout)
+		authRoutes.Get("/sessions", authHandler.ListSessions)
+		authRoutes.Post("/sessions/revoke-all", authHandler.RevokeAllSessions)
+		authRoutes.Delete("/sessions/:id", authHandler.RevokeSession)
+	} else {
+		authRoutes.Get("/providers", func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{"oidc": false, "ldap": false})
+		})
+	}
+
+	// ============================================
+	// SCIM 2.0 provisioning (users and teams, driven by the identity provider)
+	// ============================================
+	if scimHandler != nil {
+		scim := api.Group("/scim/v2", middleware.SCIMAuth(cfg.SCIMToken))
+		scim.Get("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scim.Get("/Users", scimHandler.ListUsers)
+		scim.Post("/Users", scimHandler.CreateUser)
+		scim.Get("/Users/:id", scimHandler.GetUser)
+		scim.Put("/Users/:id", scimHandler.ReplaceUser)
+		scim.Patch("/Users/:id", scimHandler.PatchUser)
+		scim.Delete("/Users/:id", scimHandler.DeleteUser)
+		scim.Get("/Groups", scimHandler.ListGroups)
+		scim.Post("/Groups", scimHandler.CreateGroup)
+		scim.Get("/Groups/:id", scimHandler.GetGroup)
+		scim.Put("/Groups/:id", scimHandler.ReplaceGroup)
+		scim.Patch("/Groups/:id", scimHandler.PatchGroup)
+		scim.Delete("/Groups/:id", scimHandler.DeleteGroup)
+	}
+
 	// ============================================
 	// Health & Status
 	// ============================================
@@ -128,7 +468,9 @@ func main() {
 	// Service status endpoint
 	app.Get("/api/status", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
-			"gateway": "ok",
+			"gateway":     "ok",
+			"maintenance": maintenanceMode.Get().Enabled,
+			"read_only":   cfg.ReadOnly,
 			"services": fiber.Map{
 				"network": cfg.NetworkServiceURL,
 				"web":     cfg.WebServiceURL,
@@ -146,3 +488,139 @@ func main() {
 		log.Fatalf("Failed to start gateway: %v", err)
 	}
 }
+
+// openDatabase connects to DATABASE_URL when SSO, SCIM, usage tracking,
+// ownership verification or required authentication (for stored API keys)
+// need it; it returns nil otherwise
+func openDatabase(cfg *config.Config) *database.Database {
+	needed := cfg.OIDCIssuer != "" || cfg.LDAPURL != "" || cfg.SCIMToken != "" || cfg.UsageTracking ||
+		cfg.OwnershipPolicy != "off" || cfg.AuthRequired
+	if !needed || cfg.DatabaseURL == "" {
+		return nil
+	}
+	db, err := database.NewDatabase(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	return db
+}
+
+// openLeases returns the leases electing the replica that runs each
+// background job (job_leases); nil without a database
+func openLeases(db *database.Database) *shareddb.Leases {
+	if db == nil {
+		return nil
+	}
+	leases, err := db.Leases(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize job leases: %v", err)
+	}
+	return leases
+}
+
+// setupAuth connects the user store, identity providers and SCIM
+// provisioning; each handler is nil when its feature is not configured
+func setupAuth(cfg *config.Config, db *database.Database, leases *shareddb.Leases) (*handlers.AuthHandler, *handlers.SCIMHandler) {
+	sso := cfg.OIDCIssuer != "" || cfg.LDAPURL != ""
+	if !sso && cfg.SCIMToken == "" {
+		return nil, nil
+	}
+	if db == nil {
+		log.Fatal("DATABASE_URL is required for SSO and SCIM provisioning")
+	}
+
+	users, err := auth.NewUserStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize users: %v", err)
+	}
+	roles, err := auth.ParseRoleMapping(cfg.SSOGroupRoles, cfg.SSODefaultRole)
+	if err != nil {
+		log.Fatalf("Invalid SSO_GROUP_ROLES: %v", err)
+	}
+
+	var scimHandler *handlers.SCIMHandler
+	if cfg.SCIMToken != "" {
+		teams, err := auth.NewTeamStore(users)
+		if err != nil {
+			log.Fatalf("Failed to initialize teams: %v", err)
+		}
+		scimHandler = handlers.NewSCIMHandler(users, teams, cfg.SSODefaultRole, "/api/scim/v2")
+		log.Println("👥 SCIM provisioning enabled at /api/scim/v2")
+	}
+	if !sso {
+		return nil, scimHandler
+	}
+
+	secret := cfg.AuthSecret
+	if secret == "" {
+		secret = auth.RandomString()
+		log.Println("⚠️ AUTH_SECRET not set; sessions will not survive a gateway restart")
+	}
+
+	var oidc *auth.OIDCProvider
+	if cfg.OIDCIssuer != "" {
+		oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+		})
+		log.Printf("🔐 OIDC login enabled (%s)", cfg.OIDCIssuer)
+	}
+
+	var ldap *auth.LDAPProvider
+	if cfg.LDAPURL != "" {
+		ldap = auth.NewLDAPProvider(auth.LDAPConfig{
+			URL:                cfg.LDAPURL,
+			BindDN:             cfg.LDAPBindDN,
+			BindPassword:       cfg.LDAPBindPassword,
+			BaseDN:             cfg.LDAPBaseDN,
+			UserAttribute:      cfg.LDAPUserAttribute,
+			InsecureSkipVerify: cfg.LDAPSkipVerify,
+		})
+		log.Printf("🔐 LDAP login enabled (%s)", cfg.LDAPURL)
+	}
+
+	sessions, err := auth.NewSessionStore(users)
+	if err != nil {
+		log.Fatalf("Failed to initialize sessions: %v", err)
+	}
+	sessions.SetLeases(leases)
+	go sessions.Start(context.Background())
+
+	authHandler := handlers.NewAuthHandler(users, sessions, auth.NewTokenIssuer(secret), oidc, ldap, roles,
+		time.Duration(cfg.AccessTTLMinutes)*time.Minute, time.Duration(cfg.SessionTTLHours)*time.Hour, cfg.FrontendURL)
+	return authHandler, scimHandler
+}
+
+// setupCredentials returns the resolvers of the caller's identity, in the
+// order they are tried: SSO sessions, bearer JWTs signed with JWT_SECRET and
+// API keys. The API key handler is nil when no key can be accepted.
+func setupCredentials(cfg *config.Config, db *database.Database, authHandler *handlers.AuthHandler) (*handlers.APIKeyHandler, []middleware.IdentityResolver) {
+	var resolvers []middleware.IdentityResolver
+	if authHandler != nil {
+		resolvers = append(resolvers, authHandler.Identify)
+	}
+	if cfg.JWTSecret != "" {
+		resolvers = append(resolvers, handlers.BearerIdentifier(auth.NewTokenIssuer(cfg.JWTSecret), cfg.SSODefaultRole))
+		log.Println("🔐 Bearer JWTs signed with JWT_SECRET accepted")
+	}
+
+	keys, err := auth.NewAPIKeyStore(db, cfg.APIKeys)
+	if err != nil {
+		log.Fatalf("Invalid API_KEYS: %v", err)
+	}
+	var apiKeyHandler *handlers.APIKeyHandler
+	if keys.Enabled() {
+		apiKeyHandler = handlers.NewAPIKeyHandler(keys, cfg.AdminToken)
+		resolvers = append(resolvers, apiKeyHandler.Identify)
+		log.Println("🔑 API keys accepted in X-API-Key")
+	}
+
+	if cfg.AuthRequired && len(resolvers) == 0 {
+		log.Fatal("AUTH_REQUIRED needs SSO, JWT_SECRET, API_KEYS or DATABASE_URL to authenticate requests")
+	}
+	return apiKeyHandler, resolvers
+}
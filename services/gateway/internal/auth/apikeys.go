@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for unknown or revoked API keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyNotFound is returned when revoking a key that does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey is a static credential for scripts and integrations. Keys come
+// from API_KEYS or are created through the API and stored hashed.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the key, to recognize it
+	Role       string     `json:"role"`
+	Static     bool       `json:"static"` // configured in API_KEYS, can't be revoked through the API
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+const apiKeysSchemaSQL = `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    prefix VARCHAR(16) NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+)`
+
+// APIKeyStore checks API keys against the configured ones and, when there is
+// a database, the ones created through the API
+type APIKeyStore struct {
+	db     *database.Database
+	static map[string]APIKey // by key hash
+}
+
+// NewAPIKeyStore parses the configured keys and creates the api_keys table;
+// db may be nil, and then only configured keys are accepted
+func NewAPIKeyStore(db *database.Database, configured string) (*APIKeyStore, error) {
+	static, err := ParseAPIKeys(configured)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		if _, err := db.Pool.Exec(context.Background(), apiKeysSchemaSQL); err != nil {
+			return nil, fmt.Errorf("failed to create api_keys table: %w", err)
+		}
+	}
+	return &APIKeyStore{db: db, static: static}, nil
+}
+
+// ParseAPIKeys parses "name:key:role" entries separated by commas; the role
+// is optional and defaults to viewer
+func ParseAPIKeys(raw string) (map[string]APIKey, error) {
+	keys := map[string]APIKey{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key %q, expected name:key:role", entry)
+		}
+		role := RoleViewer
+		if len(parts) == 3 {
+			role = parts[2]
+		}
+		if !ValidRole(role) {
+			return nil, fmt.Errorf("invalid role %q for API key %q", role, parts[0])
+		}
+		keys[hashAPIKey(parts[1])] = APIKey{
+			ID:     uuid.NewSHA1(uuid.NameSpaceOID, []byte("api-key:"+parts[0])),
+			Name:   parts[0],
+			Prefix: keyPrefix(parts[1]),
+			Role:   role,
+			Static: true,
+		}
+	}
+	return keys, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyPrefix(key string) string {
+	if len(key) > 8 {
+		return key[:8]
+	}
+	return ""
+}
+
+// Enabled reports whether any key can be accepted
+func (s *APIKeyStore) Enabled() bool {
+	return s.db != nil || len(s.static) > 0
+}
+
+// Lookup returns the API key matching key
+func (s *APIKeyStore) Lookup(ctx context.Context, key string) (*APIKey, error) {
+	hash := hashAPIKey(key)
+	if k, ok := s.static[hash]; ok {
+		return &k, nil
+	}
+	if s.db == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	k, err := scanAPIKey(s.db.Pool.QueryRow(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns, hash))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	return k, err
+}
+
+// Create stores a new key and returns it with its secret, which is not
+// kept and can't be shown again
+func (s *APIKeyStore) Create(ctx context.Context, name, role string) (*APIKey, string, error) {
+	if s.db == nil {
+		return nil, "", errors.New("API keys can only be created with a database")
+	}
+	secret := "ssk_" + RandomString()
+	k, err := scanAPIKey(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, name, key_hash, prefix, role)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+apiKeyColumns, uuid.New(), name, hashAPIKey(secret), keyPrefix(secret), role))
+	if err != nil {
+		return nil, "", err
+	}
+	return k, secret, nil
+}
+
+// List returns the configured keys and the stored ones, newest first
+func (s *APIKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	keys := []APIKey{}
+	for _, k := range s.static {
+		keys = append(keys, k)
+	}
+	if s.db == nil {
+		return keys, nil
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke stops a stored key from being accepted
+func (s *APIKeyStore) Revoke(ctx context.Context, id uuid.UUID) error {
+	if s.db == nil {
+		return ErrAPIKeyNotFound
+	}
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+const apiKeyColumns = `id, name, prefix, role, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Role, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
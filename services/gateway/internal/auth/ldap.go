@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned when the LDAP bind is rejected or the user does not exist
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// LDAPConfig configures login against LDAP / Active Directory
+type LDAPConfig struct {
+	URL                string // ldap://dc.corp.local:389 or ldaps://dc.corp.local:636
+	BindDN             string // service account used to look users up
+	BindPassword       string
+	BaseDN             string
+	UserAttribute      string // sAMAccountName (AD) or uid (OpenLDAP)
+	InsecureSkipVerify bool
+}
+
+// LDAPProvider authenticates users with a search-then-bind against the directory
+// and returns their memberOf groups
+type LDAPProvider struct {
+	cfg LDAPConfig
+}
+
+func NewLDAPProvider(cfg LDAPConfig) *LDAPProvider {
+	if cfg.UserAttribute == "" {
+		cfg.UserAttribute = "sAMAccountName"
+	}
+	return &LDAPProvider{cfg: cfg}
+}
+
+// Authenticate verifies username and password and returns the user's identity
+func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	// An empty password would be an unauthenticated bind, which servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	if err := conn.bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+		return nil, fmt.Errorf("LDAP service account bind failed: %w", err)
+	}
+
+	entries, err := conn.search(p.cfg.BaseDN, p.cfg.UserAttribute, username,
+		[]string{"mail", "displayName", "cn", "memberOf"})
+	if err != nil {
+		return nil, fmt.Errorf("LDAP search failed: %w", err)
+	}
+	if len(entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.bind(entry.dn, password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	id := &Identity{
+		Source:     "ldap",
+		ExternalID: entry.dn,
+		Email:      entry.first("mail"),
+		Name:       entry.first("displayName"),
+		Groups:     entry.attrs["memberof"],
+	}
+	if id.Name == "" {
+		id.Name = entry.first("cn")
+	}
+	return id, nil
+}
+
+// BER tags used by the LDAP messages below (RFC 4511)
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+
+	ldapBindRequest      = 0x60
+	ldapBindResponse     = 0x61
+	ldapUnbindRequest    = 0x42
+	ldapSearchRequest    = 0x63
+	ldapSearchResultItem = 0x64
+	ldapSearchResultDone = 0x65
+	ldapSimpleAuth       = 0x80
+	ldapFilterEquality   = 0xa3
+)
+
+type berElement struct {
+	tag      byte
+	value    []byte
+	children []berElement
+}
+
+func berEncode(tag byte, value []byte) []byte {
+	n := len(value)
+	var length []byte
+	switch {
+	case n < 0x80:
+		length = []byte{byte(n)}
+	case n < 0x100:
+		length = []byte{0x81, byte(n)}
+	case n < 0x10000:
+		length = []byte{0x82, byte(n >> 8), byte(n)}
+	default:
+		length = []byte{0x83, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	out := append([]byte{tag}, length...)
+	return append(out, value...)
+}
+
+func berConstructed(tag byte, children ...[]byte) []byte {
+	var value []byte
+	for _, c := range children {
+		value = append(value, c...)
+	}
+	return berEncode(tag, value)
+}
+
+func berInt(tag byte, v int) []byte {
+	if v < 0x80 {
+		return berEncode(tag, []byte{byte(v)})
+	}
+	return berEncode(tag, []byte{byte(v >> 8), byte(v)})
+}
+
+func berString(s string) []byte {
+	return berEncode(berOctetString, []byte(s))
+}
+
+// berRead reads one element; constructed elements are parsed recursively
+func berRead(r *bufio.Reader) (berElement, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return berElement{}, errors.New("unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return berElement{}, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > 16<<20 {
+		return berElement{}, errors.New("LDAP message too large")
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return berElement{}, err
+	}
+	return berParse(tag, value)
+}
+
+func berParse(tag byte, value []byte) (berElement, error) {
+	el := berElement{tag: tag, value: value}
+	if tag&0x20 == 0 {
+		return el, nil
+	}
+	r := bufio.NewReader(bytes.NewReader(value))
+	for {
+		child, err := berRead(r)
+		if err == io.EOF {
+			return el, nil
+		}
+		if err != nil {
+			return el, err
+		}
+		el.children = append(el.children, child)
+	}
+}
+
+func berUint(value []byte) int {
+	n := 0
+	for _, b := range value {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+type ldapConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	messageID int
+}
+
+type ldapEntry struct {
+	dn    string
+	attrs map[string][]string // lower-cased attribute names
+}
+
+func (e ldapEntry) first(attr string) string {
+	if values := e.attrs[strings.ToLower(attr)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (p *LDAPProvider) dial(ctx context.Context) (*ldapConn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "ldaps" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "ldaps":
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: p.cfg.InsecureSkipVerify,
+		}}).DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported LDAP scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	return &ldapConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *ldapConn) send(op []byte) (int, error) {
+	c.messageID++
+	msg := berConstructed(berSequence, berInt(berInteger, c.messageID), op)
+	_, err := c.conn.Write(msg)
+	return c.messageID, err
+}
+
+// receive reads the next message for id and returns its protocol operation
+func (c *ldapConn) receive(id int) (berElement, error) {
+	for {
+		msg, err := berRead(c.reader)
+		if err != nil {
+			return berElement{}, err
+		}
+		if len(msg.children) < 2 {
+			return berElement{}, errors.New("malformed LDAP message")
+		}
+		if berUint(msg.children[0].value) == id {
+			return msg.children[1], nil
+		}
+	}
+}
+
+// ldapResult returns an error for a non-success LDAPResult
+func ldapResult(op berElement) error {
+	if len(op.children) < 3 {
+		return errors.New("malformed LDAP result")
+	}
+	if code := berUint(op.children[0].value); code != 0 {
+		return fmt.Errorf("LDAP result code %d: %s", code, op.children[2].value)
+	}
+	return nil
+}
+
+func (c *ldapConn) bind(dn, password string) error {
+	id, err := c.send(berConstructed(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(dn),
+		berEncode(ldapSimpleAuth, []byte(password)),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != ldapBindResponse {
+		return errors.New("unexpected LDAP response to bind")
+	}
+	return ldapResult(op)
+}
+
+// search runs a subtree search for attr=value. The filter is encoded
+// structurally, so value needs no escaping.
+func (c *ldapConn) search(baseDN, attr, value string, attributes []string) ([]ldapEntry, error) {
+	var attrList [][]byte
+	for _, a := range attributes {
+		attrList = append(attrList, berString(a))
+	}
+
+	id, err := c.send(berConstructed(ldapSearchRequest,
+		berString(baseDN),
+		berInt(berEnumerated, 2), // wholeSubtree
+		berInt(berEnumerated, 0), // neverDerefAliases
+		berInt(berInteger, 2),    // size limit: two entries means the login is ambiguous
+		berInt(berInteger, 10),   // time limit in seconds
+		berEncode(berBoolean, []byte{0}),
+		berConstructed(ldapFilterEquality, berString(attr), berString(value)),
+		berConstructed(berSequence, attrList...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ldapEntry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case ldapSearchResultItem:
+			if len(op.children) < 2 {
+				return nil, errors.New("malformed LDAP search entry")
+			}
+			entry := ldapEntry{dn: string(op.children[0].value), attrs: map[string][]string{}}
+			for _, attribute := range op.children[1].children {
+				if len(attribute.children) < 2 {
+					continue
+				}
+				name := strings.ToLower(string(attribute.children[0].value))
+				for _, v := range attribute.children[1].children {
+					entry.attrs[name] = append(entry.attrs[name], string(v.value))
+				}
+			}
+			entries = append(entries, entry)
+		case ldapSearchResultDone:
+			// sizeLimitExceeded (4) still returns the entries read so far
+			if err := ldapResult(op); err != nil && berUint(op.children[0].value) != 4 {
+				return nil, err
+			}
+			return entries, nil
+		}
+	}
+}
+
+func (c *ldapConn) close() {
+	c.send(berEncode(ldapUnbindRequest, nil))
+	c.conn.Close()
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrConflict is returned when a username or team name is already taken
+var ErrConflict = errors.New("already exists")
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (s *UserStore) userNameTaken(ctx context.Context, userName string, except uuid.UUID) (bool, error) {
+	var taken bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER($1) AND id <> $2)
+	`, userName, except).Scan(&taken)
+	return taken, err
+}
+
+// Provision creates a user ahead of their first SSO login. The user is
+// linked to their SSO identity by email when they first log in.
+func (s *UserStore) Provision(ctx context.Context, u User) (*User, error) {
+	if !ValidRole(u.Role) {
+		u.Role = RoleViewer
+	}
+	if taken, err := s.userNameTaken(ctx, u.UserName, uuid.Nil); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrConflict
+	}
+
+	user, err := scanUser(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO users (id, username, email, name, role, auth_source, external_id, active, scim_managed, scim_external_id)
+		VALUES ($1, $2, $3, $4, $5, 'scim', $2, $6, true, NULLIF($7, ''))
+		RETURNING `+userColumns,
+		uuid.New(), u.UserName, u.Email, u.Name, u.Role, u.Active, u.SCIMExternalID))
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	return user, err
+}
+
+// Update replaces the provisioned attributes of a user (username, email,
+// name, role, active) and marks it as SCIM-managed
+func (s *UserStore) Update(ctx context.Context, u User) (*User, error) {
+	if !ValidRole(u.Role) {
+		u.Role = RoleViewer
+	}
+	if taken, err := s.userNameTaken(ctx, u.UserName, u.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrConflict
+	}
+
+	return scanUser(s.db.Pool.QueryRow(ctx, `
+		UPDATE users
+		SET username = $1, email = $2, name = $3, role = $4, active = $5,
+			scim_managed = true, scim_external_id = NULLIF($6, ''), updated_at = NOW()
+		WHERE id = $7
+		RETURNING `+userColumns,
+		u.UserName, u.Email, u.Name, u.Role, u.Active, u.SCIMExternalID, u.ID))
+}
+
+// Search returns users matching an exact userName and/or externalId (both
+// optional) with the total count, for paginated listings
+func (s *UserStore) Search(ctx context.Context, userName, externalID string, offset, limit int) ([]User, int, error) {
+	where := []string{"TRUE"}
+	args := []interface{}{}
+	if userName != "" {
+		args = append(args, userName)
+		where = append(where, fmt.Sprintf("LOWER(username) = LOWER($%d)", len(args)))
+	}
+	if externalID != "" {
+		args = append(args, externalID)
+		where = append(where, fmt.Sprintf("scim_external_id = $%d", len(args)))
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.db.Pool.Query(ctx, fmt.Sprintf(`SELECT %s FROM users WHERE %s ORDER BY created_at LIMIT $%d OFFSET $%d`,
+		userColumns, cond, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *u)
+	}
+	return users, total, rows.Err()
+}
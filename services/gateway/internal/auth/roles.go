@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Roles from least to most privileged
+const (
+	RoleViewer  = "viewer"
+	RoleAnalyst = "analyst"
+	RoleAdmin   = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleAnalyst: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleMapping maps identity-provider groups to platform roles
+type RoleMapping struct {
+	groups      map[string]string
+	defaultRole string
+}
+
+// ParseRoleMapping parses "group=role" pairs separated by commas or
+// semicolons (LDAP DNs contain commas, so use ';' for them), e.g.
+// "scanner-admins=admin;CN=SecOps,OU=Groups,DC=corp,DC=local=analyst".
+// Groups match case-insensitively, by full DN or by CN.
+func ParseRoleMapping(raw, defaultRole string) (*RoleMapping, error) {
+	if defaultRole == "" {
+		defaultRole = RoleViewer
+	}
+	if !ValidRole(defaultRole) {
+		return nil, fmt.Errorf("invalid default role %q", defaultRole)
+	}
+
+	m := &RoleMapping{groups: map[string]string{}, defaultRole: defaultRole}
+	sep := ","
+	if strings.Contains(raw, ";") {
+		sep = ";"
+	}
+	for _, pair := range strings.Split(raw, sep) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid group mapping %q, expected group=role", pair)
+		}
+		group, role := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if !ValidRole(role) {
+			return nil, fmt.Errorf("invalid role %q for group %q", role, group)
+		}
+		m.groups[strings.ToLower(group)] = role
+	}
+	return m, nil
+}
+
+// Resolve returns the most privileged role granted by groups, or the default role
+func (m *RoleMapping) Resolve(groups []string) string {
+	role := m.defaultRole
+	for _, group := range groups {
+		candidates := []string{strings.ToLower(group)}
+		if cn := groupCN(group); cn != "" {
+			candidates = append(candidates, strings.ToLower(cn))
+		}
+		for _, candidate := range candidates {
+			if mapped, ok := m.groups[candidate]; ok && roleRank[mapped] > roleRank[role] {
+				role = mapped
+			}
+		}
+	}
+	return role
+}
+
+// groupCN returns the CN of an LDAP DN ("CN=SecOps,OU=Groups,..." -> "SecOps")
+func groupCN(dn string) string {
+	first, _, _ := strings.Cut(dn, ",")
+	key, value, ok := strings.Cut(first, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(key), "cn") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
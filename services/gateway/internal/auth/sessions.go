@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionRevoked is returned for revoked or expired sessions
+	ErrSessionRevoked = errors.New("session has been revoked or has expired")
+)
+
+// Session is a login of a user on one browser or client. Access tokens
+// carry the session ID, so revoking the session invalidates them.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Source     string     `json:"source"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+const sessionsSchemaSQL = `
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL DEFAULT '',
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    previous_token_hash VARCHAR(64),
+    user_agent TEXT DEFAULT '',
+    ip_address VARCHAR(64) DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_previous ON user_sessions(previous_token_hash)`
+
+// SessionStore persists sessions and their rotating refresh tokens. Only
+// SHA-256 hashes of refresh tokens are stored.
+type SessionStore struct {
+	users  *UserStore
+	leases *shareddb.Leases
+}
+
+// NewSessionStore creates the sessions table; users must already be initialized
+func NewSessionStore(users *UserStore) (*SessionStore, error) {
+	if _, err := users.db.Pool.Exec(context.Background(), sessionsSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create sessions table: %w", err)
+	}
+	return &SessionStore{users: users}, nil
+}
+
+func newRefreshToken() (token, hash string) {
+	token = "srt_" + RandomString()
+	return token, hashRefreshToken(token)
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const sessionColumns = `id, user_id, source, COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+	created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row pgx.Row) (*Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.UserID, &s.Source, &s.UserAgent, &s.IPAddress,
+		&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create starts a session for user valid for ttl and returns its refresh token
+func (s *SessionStore) Create(ctx context.Context, user *User, userAgent, ip string, ttl time.Duration) (*Session, string, error) {
+	token, hash := newRefreshToken()
+	session, err := scanSession(s.users.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_sessions (id, user_id, source, refresh_token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+sessionColumns,
+		uuid.New(), user.ID, user.Source, hash, userAgent, ip, time.Now().Add(ttl)))
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Refresh exchanges a refresh token for a new one and extends the session
+// by ttl. Presenting an already rotated token means it was stolen, so the
+// whole session is revoked.
+func (s *SessionStore) Refresh(ctx context.Context, refreshToken, ip string, ttl time.Duration) (*Session, string, error) {
+	hash := hashRefreshToken(refreshToken)
+
+	result, err := s.users.db.Pool.Exec(ctx, `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL
+	`, hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if result.RowsAffected() > 0 {
+		return nil, "", ErrSessionRevoked
+	}
+
+	token, newHash := newRefreshToken()
+	session, err := scanSession(s.users.db.Pool.QueryRow(ctx, `
+		UPDATE user_sessions
+		SET refresh_token_hash = $1, previous_token_hash = refresh_token_hash,
+			last_used_at = NOW(), ip_address = $2, expires_at = $3
+		WHERE refresh_token_hash = $4 AND revoked_at IS NULL AND expires_at > NOW()
+			AND user_id IN (SELECT id FROM users WHERE COALESCE(active, true))
+		RETURNING `+sessionColumns,
+		newHash, ip, time.Now().Add(ttl), hash))
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, "", ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// Validate returns an error unless the session is active and its user is
+// not deactivated
+func (s *SessionStore) Validate(ctx context.Context, id uuid.UUID) error {
+	var valid bool
+	err := s.users.db.Pool.QueryRow(ctx, `
+		SELECT s.revoked_at IS NULL AND s.expires_at > NOW() AND COALESCE(u.active, true)
+		FROM user_sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1
+	`, id).Scan(&valid)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !valid) {
+		return ErrSessionRevoked
+	}
+	return err
+}
+
+// List returns the active sessions of a user, most recently used first
+func (s *SessionStore) List(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	rows, err := s.users.db.Pool.Query(ctx, `SELECT `+sessionColumns+` FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends one session of a user
+func (s *SessionStore) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := s.users.db.Pool.Exec(ctx, `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll ends every session of a user except keep (uuid.Nil keeps none)
+// and returns how many were revoked
+func (s *SessionStore) RevokeAll(ctx context.Context, userID, keep uuid.UUID) (int64, error) {
+	result, err := s.users.db.Pool.Exec(ctx, `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// SetLeases makes Start clean up only on the replica holding the job's lease
+func (s *SessionStore) SetLeases(leases *shareddb.Leases) {
+	s.leases = leases
+}
+
+// Start periodically deletes sessions that expired or were revoked more
+// than a week ago, until ctx is cancelled
+func (s *SessionStore) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if s.leases.Acquire(ctx, "gateway:sessions", 3*time.Hour) {
+			s.cleanup(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SessionStore) cleanup(ctx context.Context) {
+	result, err := s.users.db.Pool.Exec(ctx, `
+		DELETE FROM user_sessions
+		WHERE expires_at < NOW() - INTERVAL '7 days' OR revoked_at < NOW() - INTERVAL '7 days'
+	`)
+	if err != nil {
+		log.Printf("⚠️ Failed to clean up sessions: %v", err)
+		return
+	}
+	if n := result.RowsAffected(); n > 0 {
+		log.Printf("🧹 Removed %d old sessions", n)
+	}
+}
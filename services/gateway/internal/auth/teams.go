@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrTeamNotFound is returned when a team does not exist
+var ErrTeamNotFound = errors.New("team not found")
+
+// Team is a group of users provisioned by the identity provider
+type Team struct {
+	ID         uuid.UUID    `json:"id"`
+	Name       string       `json:"name"`
+	ExternalID string       `json:"external_id,omitempty"`
+	Members    []TeamMember `json:"members"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// TeamMember is a user in a team
+type TeamMember struct {
+	UserID   uuid.UUID `json:"user_id"`
+	UserName string    `json:"username"`
+}
+
+const teamsSchemaSQL = `
+CREATE TABLE IF NOT EXISTS teams (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    external_id VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (team_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_team_members_user ON team_members(user_id)`
+
+// TeamStore persists teams and their membership
+type TeamStore struct {
+	users *UserStore
+}
+
+// NewTeamStore creates the team tables; users must already be initialized
+func NewTeamStore(users *UserStore) (*TeamStore, error) {
+	if _, err := users.db.Pool.Exec(context.Background(), teamsSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create teams tables: %w", err)
+	}
+	return &TeamStore{users: users}, nil
+}
+
+func (s *TeamStore) members(ctx context.Context, teamID uuid.UUID) ([]TeamMember, error) {
+	rows, err := s.users.db.Pool.Query(ctx, `
+		SELECT u.id, COALESCE(u.username, '')
+		FROM team_members m JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1
+		ORDER BY u.username
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []TeamMember{}
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.UserID, &m.UserName); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *TeamStore) scanTeam(ctx context.Context, row pgx.Row) (*Team, error) {
+	var t Team
+	err := row.Scan(&t.ID, &t.Name, &t.ExternalID, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTeamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Members, err = s.members(ctx, t.ID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const teamColumns = `id, name, COALESCE(external_id, ''), created_at, COALESCE(updated_at, created_at)`
+
+// Create adds a team with the given members
+func (s *TeamStore) Create(ctx context.Context, name, externalID string, members []uuid.UUID) (*Team, error) {
+	tx, err := s.users.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	id := uuid.New()
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO teams (id, name, external_id) VALUES ($1, $2, NULLIF($3, ''))
+	`, id, name, externalID); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	if err := addMembers(ctx, tx, id, members); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Get returns a team with its members
+func (s *TeamStore) Get(ctx context.Context, id uuid.UUID) (*Team, error) {
+	return s.scanTeam(ctx, s.users.db.Pool.QueryRow(ctx, `SELECT `+teamColumns+` FROM teams WHERE id = $1`, id))
+}
+
+// Search returns teams, optionally filtered by exact name, with the total count
+func (s *TeamStore) Search(ctx context.Context, name string, offset, limit int) ([]Team, int, error) {
+	var total int
+	if err := s.users.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM teams WHERE $1 = '' OR LOWER(name) = LOWER($1)
+	`, name).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.users.db.Pool.Query(ctx, `
+		SELECT id FROM teams WHERE $1 = '' OR LOWER(name) = LOWER($1)
+		ORDER BY name LIMIT $2 OFFSET $3
+	`, name, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	teams := []Team{}
+	for _, id := range ids {
+		t, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		teams = append(teams, *t)
+	}
+	return teams, total, nil
+}
+
+// Update renames a team and replaces its members
+func (s *TeamStore) Update(ctx context.Context, id uuid.UUID, name, externalID string, members []uuid.UUID) (*Team, error) {
+	tx, err := s.users.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		UPDATE teams SET name = $1, external_id = NULLIF($2, ''), updated_at = NOW() WHERE id = $3
+	`, name, externalID, id)
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrTeamNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM team_members WHERE team_id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := addMembers(ctx, tx, id, members); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// AddMembers adds users to a team; users already in the team are ignored
+func (s *TeamStore) AddMembers(ctx context.Context, id uuid.UUID, members []uuid.UUID) error {
+	if err := addMembers(ctx, s.users.db.Pool, id, members); err != nil {
+		return err
+	}
+	_, err := s.users.db.Pool.Exec(ctx, `UPDATE teams SET updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// RemoveMembers removes users from a team
+func (s *TeamStore) RemoveMembers(ctx context.Context, id uuid.UUID, members []uuid.UUID) error {
+	if _, err := s.users.db.Pool.Exec(ctx, `
+		DELETE FROM team_members WHERE team_id = $1 AND user_id = ANY($2)
+	`, id, members); err != nil {
+		return err
+	}
+	_, err := s.users.db.Pool.Exec(ctx, `UPDATE teams SET updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// Delete removes a team; its members are not affected
+func (s *TeamStore) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := s.users.db.Pool.Exec(ctx, `DELETE FROM teams WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTeamNotFound
+	}
+	return nil
+}
+
+// UserTeams returns the teams a user belongs to, without their members
+func (s *TeamStore) UserTeams(ctx context.Context, userID uuid.UUID) ([]Team, error) {
+	rows, err := s.users.db.Pool.Query(ctx, `SELECT `+teamColumns+` FROM teams
+		WHERE id IN (SELECT team_id FROM team_members WHERE user_id = $1)
+		ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.Name, &t.ExternalID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// addMembers inserts memberships, skipping IDs that are not users
+func addMembers(ctx context.Context, db execer, teamID uuid.UUID, members []uuid.UUID) error {
+	if len(members) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO team_members (team_id, user_id)
+		SELECT $1, id FROM users WHERE id = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, teamID, members)
+	return err
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for malformed, tampered or expired tokens
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are carried in gateway-issued tokens (HS256 JWT)
+type Claims struct {
+	Subject   string `json:"sub"`
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Source    string `json:"src,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Purpose   string `json:"purpose,omitempty"` // empty for sessions
+	Data      string `json:"data,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenIssuer signs and verifies HS256 JWTs with a shared secret
+type TokenIssuer struct {
+	secret []byte
+}
+
+func NewTokenIssuer(secret string) *TokenIssuer {
+	return &TokenIssuer{secret: []byte(secret)}
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign returns a token for claims valid for ttl
+func (t *TokenIssuer) Sign(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + t.signature(unsigned), nil
+}
+
+// Verify checks the signature and expiry of a token issued for purpose
+func (t *TokenIssuer) Verify(token, purpose string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(t.signature(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Purpose != purpose || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func (t *TokenIssuer) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyBearer checks an HS256 JWT issued outside the gateway (CI systems,
+// other internal services) with the same secret: its signature, its exp
+// claim, which is required so a leaked token can't be used forever, and nbf
+// when present. Session tokens are refused, since they are only valid while
+// their session is.
+func (t *TokenIssuer) VerifyBearer(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(t.signature(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims struct {
+		Claims
+		NotBefore int64 `json:"nbf"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now().Unix()
+	if claims.Subject == "" || claims.SessionID != "" || claims.Purpose != "" ||
+		claims.ExpiresAt == 0 || now >= claims.ExpiresAt || now < claims.NotBefore {
+		return nil, ErrInvalidToken
+	}
+	return &claims.Claims, nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+)
+
+var (
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserInactive is returned when a deactivated user tries to log in
+	ErrUserInactive = errors.New("user is deactivated")
+)
+
+// User is a platform user. Users are created on first SSO login or
+// provisioned ahead of time through the SCIM API.
+type User struct {
+	ID             uuid.UUID  `json:"id"`
+	UserName       string     `json:"username"`
+	Email          string     `json:"email"`
+	Name           string     `json:"name"`
+	Role           string     `json:"role"`
+	Source         string     `json:"source"` // oidc, ldap, or scim until the first login
+	ExternalID     string     `json:"external_id"`
+	Groups         []string   `json:"groups"`
+	Active         bool       `json:"active"`
+	SCIMManaged    bool       `json:"scim_managed"`
+	SCIMExternalID string     `json:"scim_external_id,omitempty"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Identity is what an identity provider returns after a successful login
+type Identity struct {
+	Source     string
+	ExternalID string
+	Email      string
+	Name       string
+	Groups     []string
+}
+
+const usersSchemaSQL = `
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    auth_source VARCHAR(20) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    groups TEXT[] DEFAULT '{}',
+    active BOOLEAN DEFAULT true,
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (auth_source, external_id)
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_managed BOOLEAN DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_external_id VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP`
+
+// UserStore persists users in the shared database
+type UserStore struct {
+	db *database.Database
+}
+
+func NewUserStore(db *database.Database) (*UserStore, error) {
+	if _, err := db.Pool.Exec(context.Background(), usersSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create users table: %w", err)
+	}
+	return &UserStore{db: db}, nil
+}
+
+const userColumns = `id, COALESCE(username, ''), email, name, role, auth_source, external_id, COALESCE(groups, '{}'),
+	COALESCE(active, true), COALESCE(scim_managed, false), COALESCE(scim_external_id, ''),
+	last_login_at, created_at, COALESCE(updated_at, created_at)`
+
+func scanUser(row pgx.Row) (*User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.UserName, &u.Email, &u.Name, &u.Role, &u.Source, &u.ExternalID, &u.Groups,
+		&u.Active, &u.SCIMManaged, &u.SCIMExternalID, &u.LastLoginAt, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Login creates or updates the user behind an SSO identity. A user
+// provisioned through SCIM is linked on first login by email. The role is
+// recomputed from the identity's groups on every login, except for
+// SCIM-managed users whose role is assigned by the identity provider.
+func (s *UserStore) Login(ctx context.Context, id Identity, role string) (*User, error) {
+	if id.Groups == nil {
+		id.Groups = []string{}
+	}
+
+	const update = `
+		SET email = CASE WHEN users.scim_managed AND users.email <> '' THEN users.email ELSE $1 END,
+			name = CASE WHEN users.scim_managed AND users.name <> '' THEN users.name ELSE $2 END,
+			role = CASE WHEN users.scim_managed THEN users.role ELSE $3 END,
+			groups = $4,
+			auth_source = $5,
+			external_id = $6,
+			last_login_at = CASE WHEN users.active THEN NOW() ELSE users.last_login_at END,
+			updated_at = NOW()`
+
+	user, err := scanUser(s.db.Pool.QueryRow(ctx, `UPDATE users `+update+`
+		WHERE auth_source = $5 AND external_id = $6
+		RETURNING `+userColumns,
+		id.Email, id.Name, role, id.Groups, id.Source, id.ExternalID))
+	if errors.Is(err, ErrUserNotFound) && id.Email != "" {
+		user, err = scanUser(s.db.Pool.QueryRow(ctx, `UPDATE users `+update+`
+			WHERE id = (
+				SELECT id FROM users
+				WHERE auth_source = 'scim' AND (LOWER(email) = LOWER($1) OR LOWER(username) = LOWER($1))
+				LIMIT 1
+			)
+			RETURNING `+userColumns,
+			id.Email, id.Name, role, id.Groups, id.Source, id.ExternalID))
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		user, err = scanUser(s.db.Pool.QueryRow(ctx, `
+			INSERT INTO users (id, username, email, name, role, auth_source, external_id, groups, last_login_at)
+			VALUES ($1, $2, $2, $3, $4, $5, $6, $7, NOW())
+			RETURNING `+userColumns,
+			uuid.New(), id.Email, id.Name, role, id.Source, id.ExternalID, id.Groups))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, ErrUserInactive
+	}
+	return user, nil
+}
+
+// Get returns a user by ID
+func (s *UserStore) Get(ctx context.Context, id uuid.UUID) (*User, error) {
+	return scanUser(s.db.Pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+// List returns every user
+func (s *UserStore) List(ctx context.Context) ([]User, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY email`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
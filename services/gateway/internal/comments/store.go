@@ -0,0 +1,256 @@
+// Package comments keeps the discussion threads of scans and findings, so
+// a team working an engagement can talk about results next to them.
+package comments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+)
+
+// MaxBodyLength is the longest comment accepted, in characters
+const MaxBodyLength = 10000
+
+// Subjects comments are attached to
+const (
+	SubjectScan    = "scan"
+	SubjectFinding = "finding"
+)
+
+// Sources are the services whose scans and findings can be commented, named
+// as in /api/overview and /api/findings
+var Sources = []string{"network", "vulnerabilities", "webscans", "recon", "apiscans", "cmsscans", "cloudscans"}
+
+var (
+	// ErrCommentNotFound is returned for unknown comments
+	ErrCommentNotFound = errors.New("comment not found")
+)
+
+// mentionPattern matches @username mentions; an address like a@b.com is
+// not a mention since the @ must start a word
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9][A-Za-z0-9._-]{0,62})`)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS comments (
+    id UUID PRIMARY KEY,
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    subject VARCHAR(20) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    subject_id UUID NOT NULL,
+    scan_id UUID,
+    author_id VARCHAR(255) NOT NULL DEFAULT '',
+    author_name VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    mentions TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_comments_subject ON comments(subject, source, subject_id);
+CREATE INDEX IF NOT EXISTS idx_comments_scan ON comments(source, scan_id);
+CREATE INDEX IF NOT EXISTS idx_comments_mentions ON comments USING GIN(mentions)`
+
+// Comment is a markdown note on a scan or a finding. ScanID is the scan the
+// subject belongs to, so exports of a scan can include the comments on its
+// findings too.
+type Comment struct {
+	ID         uuid.UUID  `json:"id"`
+	ProjectID  string     `json:"project_id"`
+	Subject    string     `json:"subject"` // scan or finding
+	Source     string     `json:"source"`  // one of Sources
+	SubjectID  uuid.UUID  `json:"subject_id"`
+	ScanID     *uuid.UUID `json:"scan_id,omitempty"`
+	AuthorID   string     `json:"author_id"`
+	AuthorName string     `json:"author_name"`
+	Body       string     `json:"body"` // markdown
+	Mentions   []string   `json:"mentions"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Edited     bool       `json:"edited"`
+}
+
+const commentColumns = `id, project_id, subject, source, subject_id, scan_id, author_id, author_name, body, mentions, created_at, updated_at`
+
+// ValidSource reports whether source is one of Sources
+func ValidSource(source string) bool {
+	for _, s := range Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateBody trims a comment body and checks its length
+func ValidateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("body is required")
+	}
+	if len([]rune(body)) > MaxBodyLength {
+		return "", fmt.Errorf("body must be at most %d characters", MaxBodyLength)
+	}
+	return body, nil
+}
+
+// Mentions returns the users a body mentions (@alice, @bob.smith), in
+// lowercase and without duplicates. Mentions in code spans and blocks are
+// ignored.
+func Mentions(body string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	inBlock := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inBlock = !inBlock
+			continue
+		}
+		if inBlock {
+			continue
+		}
+		// Drop inline code: every other part between backticks
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			for _, match := range mentionPattern.FindAllStringSubmatch(parts[i], -1) {
+				name := strings.ToLower(strings.TrimRight(match[1], "._-"))
+				if name != "" && !seen[name] {
+					seen[name] = true
+					mentions = append(mentions, name)
+				}
+			}
+		}
+	}
+	return mentions
+}
+
+// Store keeps the comments
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates the comments table
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create comments table: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func scanComment(row pgx.Row) (*Comment, error) {
+	var c Comment
+	err := row.Scan(&c.ID, &c.ProjectID, &c.Subject, &c.Source, &c.SubjectID, &c.ScanID, &c.AuthorID, &c.AuthorName,
+		&c.Body, &c.Mentions, &c.CreatedAt, &c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Edited = c.UpdatedAt.After(c.CreatedAt)
+	return &c, nil
+}
+
+func (s *Store) list(ctx context.Context, where string, args ...interface{}) ([]*Comment, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+commentColumns+` FROM comments `+where+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// Thread returns the comments on a scan or finding, oldest first
+func (s *Store) Thread(ctx context.Context, subject, source string, subjectID uuid.UUID) ([]*Comment, error) {
+	return s.list(ctx, "WHERE subject = $1 AND source = $2 AND subject_id = $3", subject, source, subjectID)
+}
+
+// Filter selects comments across threads; empty fields match everything
+type Filter struct {
+	Project string
+	Mention string
+	Author  string
+	Source  string
+	ScanID  *uuid.UUID
+}
+
+// Search returns the comments matching a filter, oldest first
+func (s *Store) Search(ctx context.Context, f Filter) ([]*Comment, error) {
+	return s.list(ctx, `
+		WHERE ($1 = '' OR project_id = $1)
+		  AND ($2 = '' OR $2 = ANY(mentions))
+		  AND ($3 = '' OR author_id = $3)
+		  AND ($4 = '' OR source = $4)
+		  AND ($5::uuid IS NULL OR scan_id = $5)`,
+		f.Project, strings.ToLower(f.Mention), f.Author, f.Source, f.ScanID)
+}
+
+// Get returns a comment
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Comment, error) {
+	return scanComment(s.db.Pool.QueryRow(ctx, `SELECT `+commentColumns+` FROM comments WHERE id = $1`, id))
+}
+
+// AuthorName returns the name comments by a user are signed with: the
+// username, name or email of the user, or the ID itself for callers that
+// aren't users (API keys) or when SSO is not configured
+func (s *Store) AuthorName(ctx context.Context, userID string) string {
+	if userID == "" {
+		return "anonymous"
+	}
+	var name string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(NULLIF(username, ''), NULLIF(name, ''), email) FROM users WHERE id::text = $1
+	`, userID).Scan(&name)
+	if err != nil || name == "" {
+		return userID
+	}
+	return name
+}
+
+// Create adds a comment; its body must pass ValidateBody. Mentions are
+// parsed from the body.
+func (s *Store) Create(ctx context.Context, comment *Comment) (*Comment, error) {
+	comment.ID = uuid.New()
+	comment.Mentions = Mentions(comment.Body)
+	return scanComment(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO comments (id, project_id, subject, source, subject_id, scan_id, author_id, author_name, body, mentions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+commentColumns,
+		comment.ID, comment.ProjectID, comment.Subject, comment.Source, comment.SubjectID, comment.ScanID,
+		comment.AuthorID, comment.AuthorName, comment.Body, comment.Mentions))
+}
+
+// Update replaces the body of a comment and its mentions
+func (s *Store) Update(ctx context.Context, id uuid.UUID, body string) (*Comment, error) {
+	return scanComment(s.db.Pool.QueryRow(ctx, `
+		UPDATE comments SET body = $2, mentions = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+commentColumns,
+		id, body, Mentions(body)))
+}
+
+// Delete removes a comment
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM comments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
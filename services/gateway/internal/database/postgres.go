@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/security-scanner/shared/pkg/chat"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+type Database struct {
+	Pool *pgxpool.Pool
+}
+
+func NewDatabase(databaseURL string) (*Database, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse database URL: %w", err)
+	}
+
+	// Retries while the database starts, like every service
+	var pool *pgxpool.Pool
+	err = shareddb.Retry(func() error {
+		p, err := pgxpool.NewWithConfig(context.Background(), config)
+		if err != nil {
+			return err
+		}
+		if err := p.Ping(context.Background()); err != nil {
+			p.Close()
+			return err
+		}
+		pool = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Connected to PostgreSQL database")
+
+	return &Database{Pool: pool}, nil
+}
+
+func (db *Database) Close() {
+	db.Pool.Close()
+}
+
+// Leases creates the job lease table and returns the leases electing which
+// replica runs each background job
+func (db *Database) Leases(ctx context.Context) (*shareddb.Leases, error) {
+	if _, err := db.Pool.Exec(ctx, shareddb.LeaseSchemaSQL); err != nil {
+		return nil, err
+	}
+	return shareddb.NewLeases(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
+
+// ChatNotifier returns a notifier posting to the channels of chat_channels
+func (db *Database) ChatNotifier(reportBaseURL string) *chat.Notifier {
+	return chat.NewNotifier(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}, nil, reportBaseURL)
+}
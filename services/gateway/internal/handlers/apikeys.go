@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/auth"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// APIKeyHeader carries API keys
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyHandler authenticates API keys and lets admins manage them
+type APIKeyHandler struct {
+	keys       *auth.APIKeyStore
+	adminToken string
+}
+
+func NewAPIKeyHandler(keys *auth.APIKeyStore, adminToken string) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys, adminToken: adminToken}
+}
+
+// Identify resolves the caller of a request with an X-API-Key for
+// middleware.Auth; the user ID is "apikey:<name>"
+func (h *APIKeyHandler) Identify(c *fiber.Ctx) (string, string, bool) {
+	key := c.Get(APIKeyHeader)
+	if key == "" {
+		return "", "", false
+	}
+	k, err := h.keys.Lookup(context.Background(), key)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidAPIKey) {
+			log.Printf("❌ Failed to verify API key: %v", err)
+		}
+		return "", "", false
+	}
+	return "apikey:" + k.Name, k.Role, true
+}
+
+// BearerIdentifier resolves callers with an HS256 JWT signed by tokens, for
+// middleware.Auth. Tokens without a known role get defaultRole.
+func BearerIdentifier(tokens *auth.TokenIssuer, defaultRole string) middleware.IdentityResolver {
+	return func(c *fiber.Ctx) (string, string, bool) {
+		header := c.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return "", "", false
+		}
+		claims, err := tokens.VerifyBearer(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			return "", "", false
+		}
+		role := claims.Role
+		if !auth.ValidRole(role) {
+			role = defaultRole
+		}
+		return claims.Subject, role, true
+	}
+}
+
+// ListKeys returns the configured and stored API keys, without their secrets
+func (h *APIKeyHandler) ListKeys(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role required"})
+	}
+	keys, err := h.keys.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch API keys"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(keys)))
+	return c.JSON(fiber.Map{"api_keys": keys, "total": len(keys)})
+}
+
+// CreateKey creates an API key; its secret is only in this response
+func (h *APIKeyHandler) CreateKey(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role required"})
+	}
+	var req struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "name is required (up to 100 characters)"})
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleViewer
+	}
+	if !auth.ValidRole(req.Role) {
+		return c.Status(400).JSON(fiber.Map{"error": "role must be viewer, analyst or admin"})
+	}
+
+	key, secret, err := h.keys.Create(context.Background(), req.Name, req.Role)
+	if err != nil {
+		log.Printf("❌ Failed to create API key: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create API key"})
+	}
+	log.Printf("🔑 API key %s created with role %s", key.Name, key.Role)
+	return c.Status(201).JSON(fiber.Map{"api_key": key, "key": secret})
+}
+
+// RevokeKey stops a stored API key from being accepted
+func (h *APIKeyHandler) RevokeKey(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid API key ID"})
+	}
+	err = h.keys.Revoke(context.Background(), id)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "API key not found (keys from API_KEYS are removed from the configuration)"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke API key"})
+	}
+	return c.JSON(fiber.Map{"message": "API key revoked"})
+}
@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/auth"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+const (
+	// SessionCookie holds the access token of browser logins
+	SessionCookie = "scanner_session"
+	refreshCookie = "scanner_refresh"
+	stateCookie   = "scanner_sso_state"
+)
+
+// AuthHandler implements SSO login (OIDC and LDAP) and session management.
+// Logins get a short-lived access token and a rotating refresh token tied
+// to a revocable session.
+type AuthHandler struct {
+	users         *auth.UserStore
+	sessions      *auth.SessionStore
+	tokens        *auth.TokenIssuer
+	oidc          *auth.OIDCProvider
+	ldap          *auth.LDAPProvider
+	roles         *auth.RoleMapping
+	accessTTL     time.Duration
+	sessionTTL    time.Duration
+	frontendURL   string
+	secureCookies bool
+}
+
+// NewAuthHandler creates the auth handler; oidc and ldap are nil when not configured
+func NewAuthHandler(users *auth.UserStore, sessions *auth.SessionStore, tokens *auth.TokenIssuer,
+	oidc *auth.OIDCProvider, ldap *auth.LDAPProvider, roles *auth.RoleMapping,
+	accessTTL, sessionTTL time.Duration, frontendURL string) *AuthHandler {
+	return &AuthHandler{
+		users:         users,
+		sessions:      sessions,
+		tokens:        tokens,
+		oidc:          oidc,
+		ldap:          ldap,
+		roles:         roles,
+		accessTTL:     accessTTL,
+		sessionTTL:    sessionTTL,
+		frontendURL:   frontendURL,
+		secureCookies: strings.HasPrefix(frontendURL, "https://"),
+	}
+}
+
+// Providers lists the enabled login methods for the login page
+func (h *AuthHandler) Providers(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"oidc": h.oidc != nil,
+		"ldap": h.ldap != nil,
+	})
+}
+
+// OIDCLogin redirects the browser to the identity provider
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	if h.oidc == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "OIDC login is not configured"})
+	}
+
+	state, nonce, verifier := auth.RandomString(), auth.RandomString(), auth.RandomString()
+	redirect, err := h.oidc.AuthURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("❌ OIDC login failed: %v", err)
+		return c.Status(502).JSON(fiber.Map{"error": "Identity provider is unavailable"})
+	}
+
+	// The login attempt is kept client-side in a signed, short-lived cookie
+	attempt, err := h.tokens.Sign(auth.Claims{
+		Purpose: "oidc_state",
+		Subject: state,
+		Data:    nonce + " " + verifier,
+	}, 10*time.Minute)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start login"})
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     stateCookie,
+		Value:    attempt,
+		Path:     "/api/auth",
+		MaxAge:   600,
+		HTTPOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: "Lax",
+	})
+
+	return c.Redirect(redirect)
+}
+
+// OIDCCallback completes the authorization code flow and starts a session
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	if h.oidc == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "OIDC login is not configured"})
+	}
+	if msg := c.Query("error"); msg != "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Login was rejected: " + msg, "details": c.Query("error_description")})
+	}
+
+	attempt, err := h.tokens.Verify(c.Cookies(stateCookie), "oidc_state")
+	if err != nil || attempt.Subject != c.Query("state") {
+		return c.Status(400).JSON(fiber.Map{"error": "Login session expired or state mismatch, please retry"})
+	}
+	c.ClearCookie(stateCookie)
+	nonce, verifier, _ := strings.Cut(attempt.Data, " ")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	identity, err := h.oidc.Exchange(ctx, c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("❌ OIDC callback failed: %v", err)
+		return c.Status(401).JSON(fiber.Map{"error": "Login failed"})
+	}
+
+	user, _, err := h.startSession(ctx, c, identity)
+	if err != nil {
+		return h.loginError(c, err)
+	}
+	log.Printf("🔐 %s logged in via OIDC as %s", user.Email, user.Role)
+
+	return c.Redirect(h.frontendURL)
+}
+
+// LDAPLogin authenticates username/password against the directory
+func (h *AuthHandler) LDAPLogin(c *fiber.Ctx) error {
+	if h.ldap == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "LDAP login is not configured"})
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	identity, err := h.ldap.Authenticate(ctx, strings.TrimSpace(req.Username), req.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid username or password"})
+	}
+	if err != nil {
+		log.Printf("❌ LDAP login failed: %v", err)
+		return c.Status(502).JSON(fiber.Map{"error": "Directory is unavailable"})
+	}
+
+	user, tokens, err := h.startSession(ctx, c, identity)
+	if err != nil {
+		return h.loginError(c, err)
+	}
+	log.Printf("🔐 %s logged in via LDAP as %s", user.Email, user.Role)
+
+	tokens["user"] = user
+	return c.JSON(tokens)
+}
+
+// Refresh exchanges a refresh token (cookie or JSON body) for a new access
+// token and refresh token
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	refreshToken := c.Cookies(refreshCookie)
+	if refreshToken == "" {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		c.BodyParser(&req)
+		refreshToken = req.RefreshToken
+	}
+	if refreshToken == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Refresh token is required"})
+	}
+
+	ctx := context.Background()
+	session, newRefresh, err := h.sessions.Refresh(ctx, refreshToken, c.IP(), h.sessionTTL)
+	if errors.Is(err, auth.ErrSessionRevoked) {
+		h.clearCookies(c)
+		return c.Status(401).JSON(fiber.Map{"error": "Session has been revoked or has expired, please log in again"})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to refresh session: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+
+	user, err := h.users.Get(ctx, session.UserID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+	tokens, err := h.issueTokens(c, user, session, newRefresh)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+	return c.JSON(tokens)
+}
+
+// Me returns the user of the current session
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	_, user, err := h.authenticate(c)
+	if err != nil {
+		return h.authError(c, err)
+	}
+	return c.JSON(user)
+}
+
+// Logout revokes the current session and clears the cookies
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	if claims, user, err := h.authenticate(c); err == nil {
+		if id, err := uuid.Parse(claims.SessionID); err == nil {
+			h.sessions.Revoke(context.Background(), user.ID, id)
+		}
+	}
+	h.clearCookies(c)
+	return c.JSON(fiber.Map{"message": "Logged out"})
+}
+
+// ListSessions returns the active sessions of the current user
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	claims, user, err := h.authenticate(c)
+	if err != nil {
+		return h.authError(c, err)
+	}
+
+	sessions, err := h.sessions.List(context.Background(), user.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list sessions"})
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == claims.SessionID
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(sessions)))
+	return c.JSON(sessions)
+}
+
+// RevokeSession ends one session of the current user
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	claims, user, err := h.authenticate(c)
+	if err != nil {
+		return h.authError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+
+	err = h.sessions.Revoke(context.Background(), user.ID, id)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke session"})
+	}
+	if id.String() == claims.SessionID {
+		h.clearCookies(c)
+	}
+	return c.JSON(fiber.Map{"message": "Session revoked"})
+}
+
+// RevokeAllSessions ends every session of the current user, e.g. after a
+// device was lost. With ?keep_current=true the calling session survives.
+func (h *AuthHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	claims, user, err := h.authenticate(c)
+	if err != nil {
+		return h.authError(c, err)
+	}
+
+	keep := uuid.Nil
+	if c.QueryBool("keep_current") {
+		keep, _ = uuid.Parse(claims.SessionID)
+	}
+	revoked, err := h.sessions.RevokeAll(context.Background(), user.ID, keep)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+	if keep == uuid.Nil {
+		h.clearCookies(c)
+	}
+	log.Printf("🔐 %s revoked %d sessions", user.Email, revoked)
+
+	return c.JSON(fiber.Map{"message": "Sessions revoked", "revoked": revoked})
+}
+
+// authenticate verifies the access token and that its session is still active
+func (h *AuthHandler) authenticate(c *fiber.Ctx) (*auth.Claims, *auth.User, error) {
+	claims, err := h.tokens.Verify(SessionToken(c), "")
+	if err != nil {
+		return nil, nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, nil, auth.ErrInvalidToken
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, nil, auth.ErrInvalidToken
+	}
+
+	ctx := context.Background()
+	if err := h.sessions.Validate(ctx, sessionID); err != nil {
+		return nil, nil, err
+	}
+	user, err := h.users.Get(ctx, userID)
+	if err == nil && !user.Active {
+		err = auth.ErrUserInactive
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, user, nil
+}
+
+// Identify resolves the caller's user ID and role for middleware.Identity
+func (h *AuthHandler) Identify(c *fiber.Ctx) (string, string, bool) {
+	if SessionToken(c) == "" {
+		return "", "", false
+	}
+	_, user, err := h.authenticate(c)
+	if err != nil {
+		return "", "", false
+	}
+	return user.ID.String(), user.Role, true
+}
+
+func (h *AuthHandler) authError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrSessionRevoked),
+		errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrUserInactive):
+		return c.Status(401).JSON(fiber.Map{"error": "Not authenticated"})
+	}
+	log.Printf("❌ Failed to verify session: %v", err)
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to verify session"})
+}
+
+// startSession provisions the user, opens a session and sets the token cookies
+func (h *AuthHandler) startSession(ctx context.Context, c *fiber.Ctx, identity *auth.Identity) (*auth.User, fiber.Map, error) {
+	user, err := h.users.Login(ctx, *identity, h.roles.Resolve(identity.Groups))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, refreshToken, err := h.sessions.Create(ctx, user, c.Get(fiber.HeaderUserAgent), c.IP(), h.sessionTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := h.issueTokens(c, user, session, refreshToken)
+	return user, tokens, err
+}
+
+// issueTokens signs an access token for session and sets both cookies
+func (h *AuthHandler) issueTokens(c *fiber.Ctx, user *auth.User, session *auth.Session, refreshToken string) (fiber.Map, error) {
+	token, err := h.tokens.Sign(auth.Claims{
+		Subject:   user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		Source:    user.Source,
+		SessionID: session.ID.String(),
+	}, h.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	h.setCookie(c, SessionCookie, token, "/", h.accessTTL)
+	h.setCookie(c, refreshCookie, refreshToken, "/api/auth", time.Until(session.ExpiresAt))
+
+	return fiber.Map{
+		"token":         token,
+		"expires_in":    int(h.accessTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"session_id":    session.ID,
+	}, nil
+}
+
+func (h *AuthHandler) loginError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, auth.ErrUserInactive) {
+		return c.Status(403).JSON(fiber.Map{"error": "User is deactivated"})
+	}
+	log.Printf("❌ Failed to start session: %v", err)
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to start session"})
+}
+
+func (h *AuthHandler) setCookie(c *fiber.Ctx, name, value, path string, ttl time.Duration) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		HTTPOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: "Lax",
+	})
+}
+
+// clearCookies expires both token cookies on their own paths
+func (h *AuthHandler) clearCookies(c *fiber.Ctx) {
+	for name, path := range map[string]string{SessionCookie: "/", refreshCookie: "/api/auth"} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Path:     path,
+			Expires:  time.Unix(0, 0),
+			HTTPOnly: true,
+			Secure:   h.secureCookies,
+			SameSite: "Lax",
+		})
+	}
+}
+
+// SessionToken returns the bearer token or, for browsers, the session cookie
+func SessionToken(c *fiber.Ctx) string {
+	if header := c.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return c.Cookies(SessionCookie)
+}
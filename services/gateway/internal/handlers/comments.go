@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/comments"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// CommentHandler manages the comment threads of scans and findings
+type CommentHandler struct {
+	store *comments.Store
+}
+
+func NewCommentHandler(store *comments.Store) *CommentHandler {
+	return &CommentHandler{store: store}
+}
+
+// commentRequest is the editable part of a comment. ScanID is the scan a
+// commented finding belongs to.
+type commentRequest struct {
+	Body   string `json:"body"`
+	ScanID string `json:"scan_id"`
+}
+
+// commentSubject reads the :subject (scans or findings), :source and :id
+// parameters; when they are invalid, it writes the error response and ok
+// is false
+func commentSubject(c *fiber.Ctx) (subject, source string, id uuid.UUID, ok bool) {
+	switch c.Params("subject") {
+	case "scans":
+		subject = comments.SubjectScan
+	case "findings":
+		subject = comments.SubjectFinding
+	default:
+		c.Status(404).JSON(fiber.Map{"error": "Comments are on scans or findings"})
+		return "", "", uuid.Nil, false
+	}
+	source = c.Params("source")
+	if !comments.ValidSource(source) {
+		c.Status(400).JSON(fiber.Map{"error": "Unknown source", "sources": comments.Sources})
+		return "", "", uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(400).JSON(fiber.Map{"error": "Invalid " + subject + " ID"})
+		return "", "", uuid.Nil, false
+	}
+	return subject, source, id, true
+}
+
+// getOwn returns the comment of the :id parameter when the caller may edit
+// it: its author, or an admin. When it can't, it writes the error response
+// and ok is false.
+func (h *CommentHandler) getOwn(c *fiber.Ctx) (comment *comments.Comment, ok bool) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(400).JSON(fiber.Map{"error": "Invalid comment ID"})
+		return nil, false
+	}
+	comment, err = h.store.Get(context.Background(), id)
+	if err == nil && !project.Match(comment.ProjectID, project.Scope(c.Query("project"), c.Get(project.Header))) {
+		err = comments.ErrCommentNotFound
+	}
+	if errors.Is(err, comments.ErrCommentNotFound) {
+		c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.Status(500).JSON(fiber.Map{"error": "Failed to fetch comment"})
+		return nil, false
+	}
+	userID := c.Get(middleware.UserIDHeader)
+	if c.Get(middleware.UserRoleHeader) != "admin" && (userID == "" || userID != comment.AuthorID) {
+		c.Status(403).JSON(fiber.Map{"error": "Only the author of a comment can change it"})
+		return nil, false
+	}
+	return comment, true
+}
+
+// ListComments returns the comments of the project (?project= or
+// X-Tenant-ID) across threads: ?mention= those mentioning a user, ?author=
+// those by a user ID, ?source= and ?scan_id= those on a scan and its findings
+func (h *CommentHandler) ListComments(c *fiber.Ctx) error {
+	filter := comments.Filter{
+		Project: project.Scope(c.Query("project"), c.Get(project.Header)),
+		Mention: strings.TrimPrefix(strings.TrimSpace(c.Query("mention")), "@"),
+		Author:  c.Query("author"),
+		Source:  c.Query("source"),
+	}
+	if filter.Source != "" && !comments.ValidSource(filter.Source) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown source", "sources": comments.Sources})
+	}
+	if raw := c.Query("scan_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid scan_id"})
+		}
+		filter.ScanID = &id
+	}
+
+	list, err := h.store.Search(context.Background(), filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch comments"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{"comments": list, "total": len(list)})
+}
+
+// GetThread returns the comments on a scan or finding, oldest first
+func (h *CommentHandler) GetThread(c *fiber.Ctx) error {
+	subject, source, id, ok := commentSubject(c)
+	if !ok {
+		return nil
+	}
+	thread, err := h.store.Thread(context.Background(), subject, source, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch comments"})
+	}
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	list := []*comments.Comment{}
+	for _, comment := range thread {
+		if project.Match(comment.ProjectID, scope) {
+			list = append(list, comment)
+		}
+	}
+	return c.JSON(fiber.Map{"comments": list, "total": len(list)})
+}
+
+// CreateComment adds a comment to a scan or finding, signed by the caller.
+// Comments on findings take the scan_id of the finding, so that exports of
+// the scan include them.
+func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
+	subject, source, id, ok := commentSubject(c)
+	if !ok {
+		return nil
+	}
+	var req commentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	body, err := comments.ValidateBody(req.Body)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	comment := &comments.Comment{
+		ProjectID: project.FromRequest(c.Get(project.Header)),
+		Subject:   subject,
+		Source:    source,
+		SubjectID: id,
+		AuthorID:  c.Get(middleware.UserIDHeader),
+		Body:      body,
+	}
+	if subject == comments.SubjectScan {
+		comment.ScanID = &id
+	} else if req.ScanID != "" {
+		scanID, err := uuid.Parse(req.ScanID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid scan_id"})
+		}
+		comment.ScanID = &scanID
+	}
+	comment.AuthorName = h.store.AuthorName(context.Background(), comment.AuthorID)
+
+	created, err := h.store.Create(context.Background(), comment)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create comment"})
+	}
+	return c.Status(201).JSON(created)
+}
+
+// UpdateComment replaces the body of a comment
+func (h *CommentHandler) UpdateComment(c *fiber.Ctx) error {
+	current, ok := h.getOwn(c)
+	if !ok {
+		return nil
+	}
+	var req commentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	body, err := comments.ValidateBody(req.Body)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	updated, err := h.store.Update(context.Background(), current.ID, body)
+	if errors.Is(err, comments.ErrCommentNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update comment"})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteComment removes a comment
+func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
+	current, ok := h.getOwn(c)
+	if !ok {
+		return nil
+	}
+	err := h.store.Delete(context.Background(), current.ID)
+	if errors.Is(err, comments.ErrCommentNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete comment"})
+	}
+	return c.JSON(fiber.Map{"message": "Comment deleted"})
+}
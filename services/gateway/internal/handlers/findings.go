@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/searches"
+	"github.com/security-scanner/shared/pkg/client"
+	"github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// maxFindingsPage is the largest page of GET /api/findings
+const maxFindingsPage = 500
+
+// FindingsHandler lists the findings of every service in one model
+type FindingsHandler struct {
+	sources   map[string]*client.Findings // by the Source of their findings
+	collector *searches.Collector
+}
+
+func NewFindingsHandler(sources map[string]*client.Findings, collector *searches.Collector) *FindingsHandler {
+	return &FindingsHandler{sources: sources, collector: collector}
+}
+
+// ListFindings merges the findings of the services, most severe first and
+// newest first within a severity. ?severity= (comma-separated), ?target=
+// and ?scan_id= are passed to the services, as is the project (?project=
+// or X-Tenant-ID); ?source= picks the services;
+// ?limit= (default 50) and ?offset= page through the merged list. A service
+// that doesn't answer is listed in errors instead of failing the whole list.
+// ?q= takes a search query (severity>=high AND tag=prod) instead of the
+// other filters.
+func (h *FindingsHandler) ListFindings(c *fiber.Ctx) error {
+	limit, offset, err := findingsPage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if raw := c.Query("q"); raw != "" {
+		q, err := searches.Parse(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return runSearch(c, h.collector, q, project.Scope(c.Query("project"), c.Get(project.Header)), limit, offset)
+	}
+	// Checked here so a bad filter fails once rather than in every service
+	if _, err := models.ParseFindingFilter(func(key string) string { return c.Query(key) }); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sources := h.sources
+	if raw := c.Query("source"); raw != "" {
+		sources = map[string]*client.Findings{}
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			source, ok := h.sources[name]
+			if !ok {
+				return c.Status(400).JSON(fiber.Map{"error": "Unknown source", "sources": h.sourceNames()})
+			}
+			sources[name] = source
+		}
+	}
+
+	// Each service returns its first offset+limit findings, enough for the
+	// page of the merged list
+	query := url.Values{"limit": {strconv.Itoa(offset + limit)}}
+	for _, key := range []string{"severity", "target", "scan_id"} {
+		if value := c.Query(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		query.Set("project", scope)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	findings, total, errs := searches.Fetch(ctx, sources, query)
+
+	if offset < len(findings) {
+		findings = findings[offset:]
+	} else {
+		findings = []models.Finding{}
+	}
+	if len(findings) > limit {
+		findings = findings[:limit]
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(total))
+	return c.JSON(fiber.Map{
+		"findings": findings,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"errors":   errs,
+	})
+}
+
+// findingsPage reads ?limit= (default 50) and ?offset=
+func findingsPage(c *fiber.Ctx) (int, int, error) {
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit < 1 || limit > maxFindingsPage {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxFindingsPage)
+	}
+	if offset < 0 || offset+limit > models.MaxFindingLimit {
+		return 0, 0, fmt.Errorf("offset + limit can't exceed %d; narrow the list with filters", models.MaxFindingLimit)
+	}
+	return limit, offset, nil
+}
+
+// runSearch returns a page of the findings of scope matching q, with their
+// tags and status
+func runSearch(c *fiber.Ctx, collector *searches.Collector, q *searches.Query, scope string, limit, offset int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results, errs, err := collector.Run(ctx, q, scope)
+	if errors.Is(err, searches.ErrNoDatabase) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to run search"})
+	}
+
+	total := len(results)
+	if offset < len(results) {
+		results = results[offset:]
+	} else {
+		results = []searches.Result{}
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return c.JSON(fiber.Map{
+		"findings": results,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"query":    q.String(),
+		"errors":   errs,
+	})
+}
+
+func (h *FindingsHandler) sourceNames() []string {
+	names := make([]string, 0, len(h.sources))
+	for name := range h.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
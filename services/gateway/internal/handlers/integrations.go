@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/integrations"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// ChatHandler manages the Slack and Discord channels scans are posted to.
+// Webhook URLs are secrets, so every endpoint is for admins only.
+type ChatHandler struct {
+	store      *integrations.Store
+	notifier   *chat.Notifier
+	adminToken string
+}
+
+func NewChatHandler(store *integrations.Store, reportBaseURL, adminToken string) *ChatHandler {
+	return &ChatHandler{store: store, notifier: chat.NewNotifier(nil, nil, reportBaseURL), adminToken: adminToken}
+}
+
+// channelRequest is the editable part of a channel; enabled defaults to true
+type channelRequest struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	WebhookURL string   `json:"webhook_url"`
+	Severities []string `json:"severities"`
+	Tools      []string `json:"tools"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+func parseChannel(c *fiber.Ctx) (*chat.Channel, error) {
+	var req channelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	channel := &chat.Channel{
+		Name:       req.Name,
+		Type:       req.Type,
+		WebhookURL: req.WebhookURL,
+		Severities: req.Severities,
+		Tools:      req.Tools,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := chat.Validate(channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// ListChannels returns every channel
+func (h *ChatHandler) ListChannels(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	channels, err := h.store.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch chat channels"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(channels)))
+	return c.JSON(fiber.Map{"channels": channels, "total": len(channels)})
+}
+
+// GetChannel returns a channel
+func (h *ChatHandler) GetChannel(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid channel ID"})
+	}
+	channel, err := h.store.Get(context.Background(), id)
+	if errors.Is(err, integrations.ErrChannelNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch chat channel"})
+	}
+	return c.JSON(channel)
+}
+
+// CreateChannel adds a channel
+func (h *ChatHandler) CreateChannel(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	channel, err := parseChannel(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	created, err := h.store.Create(context.Background(), channel)
+	if errors.Is(err, integrations.ErrChannelExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create chat channel"})
+	}
+	return c.Status(201).JSON(created)
+}
+
+// UpdateChannel replaces a channel
+func (h *ChatHandler) UpdateChannel(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid channel ID"})
+	}
+	channel, err := parseChannel(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	updated, err := h.store.Update(context.Background(), id, channel)
+	if errors.Is(err, integrations.ErrChannelNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, integrations.ErrChannelExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update chat channel"})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteChannel removes a channel
+func (h *ChatHandler) DeleteChannel(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid channel ID"})
+	}
+	err = h.store.Delete(context.Background(), id)
+	if errors.Is(err, integrations.ErrChannelNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete chat channel"})
+	}
+	return c.JSON(fiber.Map{"message": "Chat channel deleted"})
+}
+
+// TestChannel posts a sample scan to a channel, whatever its severities,
+// tools and state, to check the webhook
+func (h *ChatHandler) TestChannel(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid channel ID"})
+	}
+	channel, err := h.store.Get(context.Background(), id)
+	if errors.Is(err, integrations.ErrChannelNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch chat channel"})
+	}
+
+	err = h.notifier.Send(context.Background(), *channel, chat.Summary{
+		Service:  "gateway",
+		Tool:     "test",
+		ScanID:   "00000000-0000-0000-0000-000000000000",
+		ScanName: "test message",
+		Target:   "example.com",
+		Counts:   map[string]int{"critical": 1, "high": 2},
+	})
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Webhook rejected the test message: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Test message sent"})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/maintenance"
+	"github.com/security-scanner/gateway/internal/middleware"
+)
+
+// MaintenanceHandler turns the gateway's maintenance mode on and off and
+// reports how far the services have drained
+type MaintenanceHandler struct {
+	mode       *maintenance.Mode
+	adminToken string
+}
+
+func NewMaintenanceHandler(mode *maintenance.Mode, adminToken string) *MaintenanceHandler {
+	return &MaintenanceHandler{mode: mode, adminToken: adminToken}
+}
+
+// GetMaintenance returns the maintenance mode, for banners in the frontend
+func (h *MaintenanceHandler) GetMaintenance(c *fiber.Ctx) error {
+	return c.JSON(h.mode.Get())
+}
+
+// PutMaintenance enables (with a message, retry_after seconds and the
+// services it's limited to) or disables the maintenance mode (admins only)
+func (h *MaintenanceHandler) PutMaintenance(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	var req maintenance.State
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := maintenance.Validate(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	state, err := h.mode.Set(context.Background(), req, c.Get(middleware.UserIDHeader))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save maintenance mode"})
+	}
+	if state.Enabled {
+		services := "every service"
+		if len(state.Services) > 0 {
+			services = strings.Join(state.Services, ", ")
+		}
+		log.Printf("🚧 Maintenance mode enabled for %s", services)
+	} else {
+		log.Println("Maintenance mode disabled")
+	}
+	return c.JSON(state)
+}
+
+// GetDrain reports the requests in flight and the pending and running scans
+// of the services under maintenance (admins only); upgrade them once drained
+func (h *MaintenanceHandler) GetDrain(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return c.JSON(fiber.Map{
+		"maintenance": h.mode.Get(),
+		"drain":       h.mode.Drain(ctx),
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/client"
+	"github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// OverviewHandler lists the scans of every service in one call
+type OverviewHandler struct {
+	sources map[string]*client.Scans // by the collection name shown to callers
+}
+
+func NewOverviewHandler(sources map[string]*client.Scans) *OverviewHandler {
+	return &OverviewHandler{sources: sources}
+}
+
+// OverviewScan is a scan and the collection it belongs to
+type OverviewScan struct {
+	Source string `json:"source"` // network, vulnerabilities, webscans, recon, apiscans, cmsscans, cloudscans
+	models.Scan
+}
+
+// GetOverview merges the latest scans of every service, newest first
+// (?limit=, default 20; ?status=; ?origin=, e.g. manual or schedule; ?project=, by default the
+// X-Tenant-ID project). A service that doesn't answer is listed in errors instead of failing the whole
+// overview.
+func (h *OverviewHandler) GetOverview(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	status := c.Query("status")
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if status != "" {
+		query.Set("status", status)
+	}
+	originFilter := c.Query("origin")
+	if originFilter != "" {
+		if err := origin.ValidFilter(originFilter); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		query.Set("origin", originFilter)
+	}
+	// The service clients don't forward headers, so the project goes in the query
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	if scope != "" {
+		query.Set("project", scope)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	scans := []OverviewScan{}
+	errors := map[string]string{}
+	for name, source := range h.sources {
+		wg.Add(1)
+		go func(name string, source *client.Scans) {
+			defer wg.Done()
+			list, err := source.List(ctx, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors[name] = err.Error()
+				return
+			}
+			for _, scan := range list.Items {
+				// Not every service filters by status
+				if status != "" && scan.Status != status {
+					continue
+				}
+				// nor by origin, when it runs an older version
+				if originFilter != "" && !origin.Match(scan.Origin, originFilter) {
+					continue
+				}
+				if !project.Match(scan.ProjectID, scope) {
+					continue
+				}
+				scans = append(scans, OverviewScan{Source: name, Scan: scan})
+			}
+		}(name, source)
+	}
+	wg.Wait()
+
+	sort.Slice(scans, func(i, j int) bool { return scans[i].CreatedAt.After(scans[j].CreatedAt) })
+	if len(scans) > limit {
+		scans = scans[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"scans":  scans,
+		"errors": errors,
+	})
+}
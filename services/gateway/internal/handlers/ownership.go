@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/ownership"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// OwnershipHandler serves the domain ownership verification workflow
+type OwnershipHandler struct {
+	store      *ownership.Store
+	policy     string
+	adminToken string
+}
+
+func NewOwnershipHandler(store *ownership.Store, policy, adminToken string) *OwnershipHandler {
+	return &OwnershipHandler{store: store, policy: policy, adminToken: adminToken}
+}
+
+// ListDomains returns the domains with a challenge and the active policy
+func (h *OwnershipHandler) ListDomains(c *fiber.Ctx) error {
+	domains, err := h.store.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch domains"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(domains)))
+	return c.JSON(fiber.Map{
+		"policy":  h.policy,
+		"domains": domains,
+		"total":   len(domains),
+	})
+}
+
+// RequestDomain creates the challenge of a domain (or returns the existing
+// one) with instructions for the DNS and file methods
+func (h *OwnershipHandler) RequestDomain(c *fiber.Ctx) error {
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	domain, err := h.store.Request(context.Background(), req.Domain, c.Get(middleware.UserIDHeader))
+	if errors.Is(err, ownership.ErrInvalidDomain) {
+		return c.Status(400).JSON(fiber.Map{"error": "domain must be a domain name such as example.com"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create verification challenge"})
+	}
+	return c.Status(201).JSON(domain)
+}
+
+// GetDomain returns a domain's challenge and verification state
+func (h *OwnershipHandler) GetDomain(c *fiber.Ctx) error {
+	domain, err := h.store.Get(context.Background(), strings.ToLower(c.Params("domain")))
+	if errors.Is(err, ownership.ErrDomainNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch domain"})
+	}
+	return c.JSON(domain)
+}
+
+// VerifyDomain checks the DNS TXT record and the well-known file now
+func (h *OwnershipHandler) VerifyDomain(c *fiber.Ctx) error {
+	domain, err := h.store.Verify(context.Background(), strings.ToLower(c.Params("domain")))
+	if errors.Is(err, ownership.ErrDomainNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to verify domain"})
+	}
+	if domain.Status != "verified" {
+		return c.Status(422).JSON(domain)
+	}
+	return c.JSON(domain)
+}
+
+// DeleteDomain removes a domain's verification (admins only)
+func (h *OwnershipHandler) DeleteDomain(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	err := h.store.Delete(context.Background(), strings.ToLower(c.Params("domain")))
+	if errors.Is(err, ownership.ErrDomainNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete domain"})
+	}
+	return c.JSON(fiber.Map{"message": "Domain verification deleted"})
+}
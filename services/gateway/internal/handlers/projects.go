@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/projects"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// ProjectHandler manages the projects scans are grouped in
+type ProjectHandler struct {
+	store      *projects.Store
+	adminToken string
+}
+
+func NewProjectHandler(store *projects.Store, adminToken string) *ProjectHandler {
+	return &ProjectHandler{store: store, adminToken: adminToken}
+}
+
+// ListProjects returns every project
+func (h *ProjectHandler) ListProjects(c *fiber.Ctx) error {
+	list, err := h.store.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch projects"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{"projects": list, "total": len(list)})
+}
+
+// GetProject returns a project
+func (h *ProjectHandler) GetProject(c *fiber.Ctx) error {
+	p, err := h.store.Get(context.Background(), c.Params("id"))
+	if errors.Is(err, projects.ErrProjectNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch project"})
+	}
+	return c.JSON(p)
+}
+
+// CreateProject adds a project
+func (h *ProjectHandler) CreateProject(c *fiber.Ctx) error {
+	var req projects.Project
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.ID = strings.ToLower(strings.TrimSpace(req.ID))
+	if err := project.Valid(req.ID); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.CreatedBy = c.Get(middleware.UserIDHeader)
+
+	p, err := h.store.Create(context.Background(), &req)
+	if errors.Is(err, projects.ErrProjectExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create project"})
+	}
+	return c.Status(201).JSON(p)
+}
+
+// UpdateProject changes the name and description of a project
+func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	p, err := h.store.Update(context.Background(), c.Params("id"), req.Name, req.Description)
+	if errors.Is(err, projects.ErrProjectNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update project"})
+	}
+	return c.JSON(p)
+}
+
+// DeleteProject removes a project (admins only); its scans are kept
+func (h *ProjectHandler) DeleteProject(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	err := h.store.Delete(context.Background(), c.Params("id"))
+	if errors.Is(err, projects.ErrProjectNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, projects.ErrDefaultProject) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete project"})
+	}
+	return c.JSON(fiber.Map{"message": "Project deleted"})
+}
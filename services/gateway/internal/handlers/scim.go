@@ -0,0 +1,808 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/auth"
+)
+
+// SCIM 2.0 schema URNs (RFC 7643 / RFC 7644)
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimMaxCount = 200
+)
+
+// SCIMHandler implements the SCIM 2.0 Users and Groups endpoints used by
+// identity providers (Okta, Azure AD, OneLogin) to provision, update and
+// deprovision users and teams
+type SCIMHandler struct {
+	users       *auth.UserStore
+	teams       *auth.TeamStore
+	defaultRole string
+	baseURL     string
+}
+
+// NewSCIMHandler creates the SCIM handler; baseURL prefixes resource locations
+func NewSCIMHandler(users *auth.UserStore, teams *auth.TeamStore, defaultRole, baseURL string) *SCIMHandler {
+	return &SCIMHandler{
+		users:       users,
+		teams:       teams,
+		defaultRole: defaultRole,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+type scimMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type scimUser struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *scimName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []scimMultiValue `json:"emails,omitempty"`
+	Active      *scimBool        `json:"active,omitempty"`
+	Roles       []scimMultiValue `json:"roles,omitempty"`
+	Groups      []scimMultiValue `json:"groups,omitempty"`
+	Meta        *scimMeta        `json:"meta,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	DisplayName string           `json:"displayName"`
+	Members     []scimMultiValue `json:"members"`
+	Meta        *scimMeta        `json:"meta,omitempty"`
+}
+
+type scimPatch struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// scimBool accepts true/false as well as "True"/"False", which Azure AD sends
+type scimBool bool
+
+func (b *scimBool) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseBool(strings.Trim(string(data), `"`))
+	if err != nil {
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	*b = scimBool(v)
+	return nil
+}
+
+func scimError(c *fiber.Ctx, status int, scimType, detail string) error {
+	body := fiber.Map{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	return c.Status(status).JSON(body)
+}
+
+func scimJSON(c *fiber.Ctx, status int, body interface{}) error {
+	c.Set(fiber.HeaderContentType, "application/scim+json")
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.Status(status).Send(data)
+}
+
+func (h *SCIMHandler) storeError(c *fiber.Ctx, err error, action string) error {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		return scimError(c, 404, "", "User not found")
+	case errors.Is(err, auth.ErrTeamNotFound):
+		return scimError(c, 404, "", "Group not found")
+	case errors.Is(err, auth.ErrConflict):
+		return scimError(c, 409, "uniqueness", "A resource with that name already exists")
+	}
+	log.Printf("❌ SCIM: failed to %s: %v", action, err)
+	return scimError(c, 500, "", "Failed to "+action)
+}
+
+var scimFilterRe = regexp.MustCompile(`^\s*(\w+)\s+eq\s+"([^"]*)"\s*$`)
+
+// parseFilter supports the equality filters identity providers use to look
+// up existing resources before creating them, e.g. userName eq "jdoe"
+func parseFilter(filter string) (attr, value string, err error) {
+	if filter == "" {
+		return "", "", nil
+	}
+	m := scimFilterRe.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", fmt.Errorf("unsupported filter %q", filter)
+	}
+	return strings.ToLower(m[1]), m[2], nil
+}
+
+// scimPage reads the 1-based startIndex and count query parameters
+func scimPage(c *fiber.Ctx) (offset, count int) {
+	start := c.QueryInt("startIndex", 1)
+	if start < 1 {
+		start = 1
+	}
+	count = c.QueryInt("count", 100)
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return start - 1, count
+}
+
+func listResponse(total, offset int, resources interface{}, n int) fiber.Map {
+	return fiber.Map{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   offset + 1,
+		"itemsPerPage": n,
+		"Resources":    resources,
+	}
+}
+
+// ServiceProviderConfig advertises the supported SCIM features
+func (h *SCIMHandler) ServiceProviderConfig(c *fiber.Ctx) error {
+	return scimJSON(c, 200, fiber.Map{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          fiber.Map{"supported": true},
+		"bulk":           fiber.Map{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         fiber.Map{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": fiber.Map{"supported": false},
+		"sort":           fiber.Map{"supported": false},
+		"etag":           fiber.Map{"supported": false},
+		"authenticationSchemes": []fiber.Map{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Static token configured with SCIM_TOKEN",
+		}},
+	})
+}
+
+// ============================================
+// Users
+// ============================================
+
+func (h *SCIMHandler) toSCIMUser(ctx context.Context, u *auth.User) (scimUser, error) {
+	active := scimBool(u.Active)
+	out := scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          u.ID.String(),
+		ExternalID:  u.SCIMExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.Name,
+		Active:      &active,
+		Roles:       []scimMultiValue{{Value: u.Role, Primary: true}},
+		Groups:      []scimMultiValue{},
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     h.baseURL + "/Users/" + u.ID.String(),
+		},
+	}
+	if u.Name != "" {
+		out.Name = &scimName{Formatted: u.Name}
+	}
+	if u.Email != "" {
+		out.Emails = []scimMultiValue{{Value: u.Email, Type: "work", Primary: true}}
+	}
+
+	teams, err := h.teams.UserTeams(ctx, u.ID)
+	if err != nil {
+		return out, err
+	}
+	for _, t := range teams {
+		out.Groups = append(out.Groups, scimMultiValue{Value: t.ID.String(), Display: t.Name})
+	}
+	return out, nil
+}
+
+// fromSCIMUser copies the provisioned attributes onto u
+func (h *SCIMHandler) fromSCIMUser(in scimUser, u *auth.User) error {
+	in.UserName = strings.TrimSpace(in.UserName)
+	if in.UserName == "" {
+		return errors.New("userName is required")
+	}
+	u.UserName = in.UserName
+	u.SCIMExternalID = in.ExternalID
+
+	u.Name = in.DisplayName
+	if in.Name != nil {
+		if u.Name == "" {
+			u.Name = in.Name.Formatted
+		}
+		if u.Name == "" {
+			u.Name = strings.TrimSpace(in.Name.GivenName + " " + in.Name.FamilyName)
+		}
+	}
+
+	u.Email = ""
+	for _, email := range in.Emails {
+		if u.Email == "" || email.Primary {
+			u.Email = email.Value
+		}
+	}
+	if u.Email == "" && strings.Contains(u.UserName, "@") {
+		u.Email = u.UserName
+	}
+
+	if in.Active != nil {
+		u.Active = bool(*in.Active)
+	}
+
+	if len(in.Roles) > 0 {
+		role := ""
+		for _, r := range in.Roles {
+			value := strings.ToLower(r.Value)
+			if !auth.ValidRole(value) {
+				return fmt.Errorf("invalid role %q, expected viewer, analyst or admin", r.Value)
+			}
+			if role == "" || r.Primary {
+				role = value
+			}
+		}
+		u.Role = role
+	}
+	return nil
+}
+
+// ListUsers handles GET /Users with optional filter, startIndex and count
+func (h *SCIMHandler) ListUsers(c *fiber.Ctx) error {
+	attr, value, err := parseFilter(c.Query("filter"))
+	if err != nil {
+		return scimError(c, 400, "invalidFilter", err.Error())
+	}
+	var userName, externalID string
+	switch attr {
+	case "":
+	case "username":
+		userName = value
+	case "externalid":
+		externalID = value
+	default:
+		return scimError(c, 400, "invalidFilter", "Only userName and externalId filters are supported")
+	}
+
+	ctx := context.Background()
+	offset, count := scimPage(c)
+	users, total, err := h.users.Search(ctx, userName, externalID, offset, count)
+	if err != nil {
+		return h.storeError(c, err, "list users")
+	}
+
+	resources := []scimUser{}
+	for i := range users {
+		u, err := h.toSCIMUser(ctx, &users[i])
+		if err != nil {
+			return h.storeError(c, err, "list users")
+		}
+		resources = append(resources, u)
+	}
+	return scimJSON(c, 200, listResponse(total, offset, resources, len(resources)))
+}
+
+// GetUser handles GET /Users/:id
+func (h *SCIMHandler) GetUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "User not found")
+	}
+	ctx := context.Background()
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		return h.storeError(c, err, "fetch user")
+	}
+	out, err := h.toSCIMUser(ctx, user)
+	if err != nil {
+		return h.storeError(c, err, "fetch user")
+	}
+	return scimJSON(c, 200, out)
+}
+
+// CreateUser handles POST /Users
+func (h *SCIMHandler) CreateUser(c *fiber.Ctx) error {
+	var in scimUser
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return scimError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+
+	user := auth.User{Role: h.defaultRole, Active: true}
+	if err := h.fromSCIMUser(in, &user); err != nil {
+		return scimError(c, 400, "invalidValue", err.Error())
+	}
+
+	ctx := context.Background()
+	created, err := h.users.Provision(ctx, user)
+	if err != nil {
+		return h.storeError(c, err, "create user")
+	}
+	log.Printf("👤 SCIM provisioned user %s as %s", created.UserName, created.Role)
+
+	out, err := h.toSCIMUser(ctx, created)
+	if err != nil {
+		return h.storeError(c, err, "create user")
+	}
+	c.Set(fiber.HeaderLocation, out.Meta.Location)
+	return scimJSON(c, 201, out)
+}
+
+// ReplaceUser handles PUT /Users/:id
+func (h *SCIMHandler) ReplaceUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "User not found")
+	}
+	var in scimUser
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return scimError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+
+	ctx := context.Background()
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		return h.storeError(c, err, "update user")
+	}
+	if err := h.fromSCIMUser(in, user); err != nil {
+		return scimError(c, 400, "invalidValue", err.Error())
+	}
+	return h.saveUser(ctx, c, user)
+}
+
+// PatchUser handles PATCH /Users/:id. Operations are applied to the SCIM
+// representation of the user, which is then saved like a PUT.
+func (h *SCIMHandler) PatchUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "User not found")
+	}
+	var patch scimPatch
+	if err := json.Unmarshal(c.Body(), &patch); err != nil || len(patch.Operations) == 0 {
+		return scimError(c, 400, "invalidSyntax", "Expected a "+scimPatchSchema+" request with Operations")
+	}
+
+	ctx := context.Background()
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		return h.storeError(c, err, "update user")
+	}
+	current, err := h.toSCIMUser(ctx, user)
+	if err != nil {
+		return h.storeError(c, err, "update user")
+	}
+
+	// Work on a generic document so any attribute path can be patched
+	raw, _ := json.Marshal(current)
+	var doc map[string]interface{}
+	json.Unmarshal(raw, &doc)
+
+	for _, op := range patch.Operations {
+		var value interface{}
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return scimError(c, 400, "invalidSyntax", "Invalid operation value")
+			}
+		}
+		if err := patchUserDocument(doc, strings.ToLower(op.Op), op.Path, value); err != nil {
+			return scimError(c, 400, "invalidPath", err.Error())
+		}
+	}
+
+	raw, _ = json.Marshal(doc)
+	var patched scimUser
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return scimError(c, 400, "invalidValue", err.Error())
+	}
+	if err := h.fromSCIMUser(patched, user); err != nil {
+		return scimError(c, 400, "invalidValue", err.Error())
+	}
+	return h.saveUser(ctx, c, user)
+}
+
+// DeleteUser handles DELETE /Users/:id. Users are deactivated rather than
+// deleted, so their scans and audit history keep a valid owner.
+func (h *SCIMHandler) DeleteUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "User not found")
+	}
+	ctx := context.Background()
+	user, err := h.users.Get(ctx, id)
+	if err != nil {
+		return h.storeError(c, err, "deprovision user")
+	}
+	user.Active = false
+	if _, err := h.users.Update(ctx, *user); err != nil {
+		return h.storeError(c, err, "deprovision user")
+	}
+	log.Printf("👤 SCIM deprovisioned user %s", user.UserName)
+	return c.SendStatus(204)
+}
+
+func (h *SCIMHandler) saveUser(ctx context.Context, c *fiber.Ctx, user *auth.User) error {
+	updated, err := h.users.Update(ctx, *user)
+	if err != nil {
+		return h.storeError(c, err, "update user")
+	}
+	log.Printf("👤 SCIM updated user %s (role %s, active %t)", updated.UserName, updated.Role, updated.Active)
+
+	out, err := h.toSCIMUser(ctx, updated)
+	if err != nil {
+		return h.storeError(c, err, "update user")
+	}
+	return scimJSON(c, 200, out)
+}
+
+// scimUserAttributes maps lower-cased attribute names to their canonical
+// form; SCIM attribute names are case-insensitive
+var scimUserAttributes = map[string]string{
+	"username":    "userName",
+	"externalid":  "externalId",
+	"displayname": "displayName",
+	"name":        "name",
+	"emails":      "emails",
+	"active":      "active",
+	"roles":       "roles",
+}
+
+// patchUserDocument applies one add/replace/remove operation. Paths may be
+// plain ("active"), a sub-attribute ("name.givenName") or a value filter
+// ('emails[type eq "work"].value'); filtered multi-valued attributes are
+// replaced by a single primary value.
+func patchUserDocument(doc map[string]interface{}, op, path string, value interface{}) error {
+	if op != "add" && op != "replace" && op != "remove" {
+		return fmt.Errorf("unsupported operation %q", op)
+	}
+
+	if path == "" {
+		if op == "remove" {
+			return errors.New("remove requires a path")
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("operation without path requires an object value")
+		}
+		for key, v := range values {
+			if strings.HasPrefix(key, "urn:") {
+				continue
+			}
+			if err := patchUserDocument(doc, op, key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr, sub := path, ""
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		attr = path[:i]
+		if j := strings.LastIndex(path, "."); j > i || (j == i && path[i] == '.') {
+			sub = path[j+1:]
+		}
+	}
+	canonical, ok := scimUserAttributes[strings.ToLower(attr)]
+	if !ok {
+		return fmt.Errorf("unsupported attribute %q", attr)
+	}
+
+	if op == "remove" {
+		delete(doc, canonical)
+		return nil
+	}
+
+	switch {
+	case canonical == "name" && sub != "":
+		name, _ := doc["name"].(map[string]interface{})
+		if name == nil {
+			name = map[string]interface{}{}
+		}
+		name[sub] = value
+		// A changed part invalidates the formatted name
+		if !strings.EqualFold(sub, "formatted") {
+			delete(name, "formatted")
+		}
+		doc["name"] = name
+		if _, ok := doc["displayName"]; ok && !strings.EqualFold(sub, "formatted") {
+			delete(doc, "displayName")
+		}
+	case (canonical == "emails" || canonical == "roles") && sub != "":
+		doc[canonical] = []interface{}{map[string]interface{}{"value": value, "primary": true}}
+	case canonical == "roles":
+		// Some providers send roles as plain strings
+		if list, ok := value.([]interface{}); ok {
+			for i, r := range list {
+				if s, ok := r.(string); ok {
+					list[i] = map[string]interface{}{"value": s}
+				}
+			}
+		}
+		doc[canonical] = value
+	default:
+		doc[canonical] = value
+	}
+	return nil
+}
+
+// ============================================
+// Groups (teams)
+// ============================================
+
+func (h *SCIMHandler) toSCIMGroup(t *auth.Team) scimGroup {
+	out := scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          t.ID.String(),
+		ExternalID:  t.ExternalID,
+		DisplayName: t.Name,
+		Members:     []scimMultiValue{},
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Created:      t.CreatedAt,
+			LastModified: t.UpdatedAt,
+			Location:     h.baseURL + "/Groups/" + t.ID.String(),
+		},
+	}
+	for _, m := range t.Members {
+		out.Members = append(out.Members, scimMultiValue{Value: m.UserID.String(), Display: m.UserName})
+	}
+	return out
+}
+
+// memberIDs parses member values; unknown IDs are ignored by the store
+func memberIDs(members []scimMultiValue) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, m := range members {
+		if id, err := uuid.Parse(m.Value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ListGroups handles GET /Groups with an optional displayName filter
+func (h *SCIMHandler) ListGroups(c *fiber.Ctx) error {
+	attr, value, err := parseFilter(c.Query("filter"))
+	if err != nil {
+		return scimError(c, 400, "invalidFilter", err.Error())
+	}
+	if attr != "" && attr != "displayname" {
+		return scimError(c, 400, "invalidFilter", "Only the displayName filter is supported")
+	}
+
+	offset, count := scimPage(c)
+	teams, total, err := h.teams.Search(context.Background(), value, offset, count)
+	if err != nil {
+		return h.storeError(c, err, "list groups")
+	}
+
+	resources := []scimGroup{}
+	for i := range teams {
+		resources = append(resources, h.toSCIMGroup(&teams[i]))
+	}
+	return scimJSON(c, 200, listResponse(total, offset, resources, len(resources)))
+}
+
+// GetGroup handles GET /Groups/:id
+func (h *SCIMHandler) GetGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "Group not found")
+	}
+	team, err := h.teams.Get(context.Background(), id)
+	if err != nil {
+		return h.storeError(c, err, "fetch group")
+	}
+	return scimJSON(c, 200, h.toSCIMGroup(team))
+}
+
+// CreateGroup handles POST /Groups
+func (h *SCIMHandler) CreateGroup(c *fiber.Ctx) error {
+	var in scimGroup
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return scimError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	if strings.TrimSpace(in.DisplayName) == "" {
+		return scimError(c, 400, "invalidValue", "displayName is required")
+	}
+
+	team, err := h.teams.Create(context.Background(), strings.TrimSpace(in.DisplayName), in.ExternalID, memberIDs(in.Members))
+	if err != nil {
+		return h.storeError(c, err, "create group")
+	}
+	log.Printf("👥 SCIM provisioned team %s with %d members", team.Name, len(team.Members))
+
+	out := h.toSCIMGroup(team)
+	c.Set(fiber.HeaderLocation, out.Meta.Location)
+	return scimJSON(c, 201, out)
+}
+
+// ReplaceGroup handles PUT /Groups/:id
+func (h *SCIMHandler) ReplaceGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "Group not found")
+	}
+	var in scimGroup
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return scimError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	if strings.TrimSpace(in.DisplayName) == "" {
+		return scimError(c, 400, "invalidValue", "displayName is required")
+	}
+
+	team, err := h.teams.Update(context.Background(), id, strings.TrimSpace(in.DisplayName), in.ExternalID, memberIDs(in.Members))
+	if err != nil {
+		return h.storeError(c, err, "update group")
+	}
+	return scimJSON(c, 200, h.toSCIMGroup(team))
+}
+
+var memberFilterRe = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]+)"\s*\]$`)
+
+// PatchGroup handles PATCH /Groups/:id: renames and member add/remove/replace
+func (h *SCIMHandler) PatchGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "Group not found")
+	}
+	var patch scimPatch
+	if err := json.Unmarshal(c.Body(), &patch); err != nil || len(patch.Operations) == 0 {
+		return scimError(c, 400, "invalidSyntax", "Expected a "+scimPatchSchema+" request with Operations")
+	}
+
+	ctx := context.Background()
+	team, err := h.teams.Get(ctx, id)
+	if err != nil {
+		return h.storeError(c, err, "update group")
+	}
+
+	for _, op := range patch.Operations {
+		kind := strings.ToLower(op.Op)
+		path := strings.TrimSpace(op.Path)
+
+		switch {
+		case memberFilterRe.MatchString(path) && kind == "remove":
+			memberID, err := uuid.Parse(memberFilterRe.FindStringSubmatch(path)[1])
+			if err == nil {
+				err = h.teams.RemoveMembers(ctx, id, []uuid.UUID{memberID})
+			}
+			if err != nil {
+				return h.storeError(c, err, "update group")
+			}
+
+		case strings.EqualFold(path, "members"):
+			var members []scimMultiValue
+			if len(op.Value) > 0 {
+				if err := json.Unmarshal(op.Value, &members); err != nil {
+					return scimError(c, 400, "invalidValue", "members must be a list of {\"value\": id}")
+				}
+			}
+			switch kind {
+			case "add":
+				err = h.teams.AddMembers(ctx, id, memberIDs(members))
+			case "remove":
+				if len(members) == 0 {
+					// Removing the attribute without a value removes every member
+					_, err = h.teams.Update(ctx, id, team.Name, team.ExternalID, nil)
+				} else {
+					err = h.teams.RemoveMembers(ctx, id, memberIDs(members))
+				}
+			case "replace":
+				_, err = h.teams.Update(ctx, id, team.Name, team.ExternalID, memberIDs(members))
+			default:
+				return scimError(c, 400, "invalidSyntax", fmt.Sprintf("unsupported operation %q", op.Op))
+			}
+			if err != nil {
+				return h.storeError(c, err, "update group")
+			}
+
+		case (kind == "replace" || kind == "add") && (path == "" || strings.EqualFold(path, "displayName") || strings.EqualFold(path, "externalId")):
+			name, externalID := team.Name, team.ExternalID
+			if path == "" {
+				var values struct {
+					DisplayName *string `json:"displayName"`
+					ExternalID  *string `json:"externalId"`
+				}
+				if err := json.Unmarshal(op.Value, &values); err != nil {
+					return scimError(c, 400, "invalidValue", "operation without path requires an object value")
+				}
+				if values.DisplayName != nil {
+					name = *values.DisplayName
+				}
+				if values.ExternalID != nil {
+					externalID = *values.ExternalID
+				}
+			} else {
+				var value string
+				if err := json.Unmarshal(op.Value, &value); err != nil {
+					return scimError(c, 400, "invalidValue", path+" must be a string")
+				}
+				if strings.EqualFold(path, "displayName") {
+					name = value
+				} else {
+					externalID = value
+				}
+			}
+			if strings.TrimSpace(name) == "" {
+				return scimError(c, 400, "invalidValue", "displayName is required")
+			}
+
+			// Keep the current members when only renaming
+			current, err := h.teams.Get(ctx, id)
+			if err != nil {
+				return h.storeError(c, err, "update group")
+			}
+			var ids []uuid.UUID
+			for _, m := range current.Members {
+				ids = append(ids, m.UserID)
+			}
+			if _, err := h.teams.Update(ctx, id, strings.TrimSpace(name), externalID, ids); err != nil {
+				return h.storeError(c, err, "update group")
+			}
+
+		default:
+			return scimError(c, 400, "invalidPath", fmt.Sprintf("unsupported operation %s %q", op.Op, op.Path))
+		}
+
+		if team, err = h.teams.Get(ctx, id); err != nil {
+			return h.storeError(c, err, "update group")
+		}
+	}
+
+	return scimJSON(c, 200, h.toSCIMGroup(team))
+}
+
+// DeleteGroup handles DELETE /Groups/:id; the members themselves are kept
+func (h *SCIMHandler) DeleteGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scimError(c, 404, "", "Group not found")
+	}
+	if err := h.teams.Delete(context.Background(), id); err != nil {
+		return h.storeError(c, err, "delete group")
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/scoperules"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
+)
+
+// ScopeHandler manages the rules scan targets must pass
+type ScopeHandler struct {
+	store      *scoperules.Store
+	adminToken string
+}
+
+func NewScopeHandler(store *scoperules.Store, adminToken string) *ScopeHandler {
+	return &ScopeHandler{store: store, adminToken: adminToken}
+}
+
+// ListRules returns the rules of ?project= and those of every project, or
+// every rule without it
+func (h *ScopeHandler) ListRules(c *fiber.Ctx) error {
+	rules, err := h.store.List(context.Background(), c.Query("project"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scope rules"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(rules)))
+	return c.JSON(fiber.Map{"rules": rules, "total": len(rules)})
+}
+
+// CreateRule adds an allow or deny rule (admins only); without project_id
+// it applies to every project
+func (h *ScopeHandler) CreateRule(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	var req scope.Rule
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.ProjectID = strings.ToLower(strings.TrimSpace(req.ProjectID))
+	if req.ProjectID != "" {
+		if err := project.Valid(req.ProjectID); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if err := scope.Validate(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.CreatedBy = c.Get(middleware.UserIDHeader)
+
+	rule, err := h.store.Create(context.Background(), &req)
+	if errors.Is(err, scoperules.ErrRuleExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create scope rule"})
+	}
+	return c.Status(201).JSON(rule)
+}
+
+// DeleteRule removes a rule (admins only)
+func (h *ScopeHandler) DeleteRule(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+	err = h.store.Delete(context.Background(), id)
+	if errors.Is(err, scoperules.ErrRuleNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete scope rule"})
+	}
+	return c.JSON(fiber.Map{"message": "Scope rule deleted"})
+}
+
+// CheckTargets tells whether a scan of targets would be allowed in the
+// project of the request, without creating it
+func (h *ScopeHandler) CheckTargets(c *fiber.Ctx) error {
+	var req struct {
+		Targets []string `json:"targets"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(scope.Split(req.Targets...)) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "targets are required"})
+	}
+
+	err := h.store.Check(context.Background(), project.FromRequest(c.Get(project.Header)), req.Targets...)
+	var outOfScope *scope.Error
+	switch {
+	case err == nil:
+		return c.JSON(fiber.Map{"in_scope": true, "out_of_scope": []scope.Violation{}})
+	case errors.As(err, &outOfScope):
+		return c.JSON(fiber.Map{"in_scope": false, "out_of_scope": outOfScope.Violations})
+	default:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check scope rules"})
+	}
+}
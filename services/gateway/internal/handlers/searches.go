@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/searches"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// SearchHandler manages saved searches over the findings of every service
+type SearchHandler struct {
+	store     *searches.Store
+	collector *searches.Collector
+}
+
+func NewSearchHandler(store *searches.Store, collector *searches.Collector) *SearchHandler {
+	return &SearchHandler{store: store, collector: collector}
+}
+
+// searchRequest is the editable part of a saved search
+type searchRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Query       string `json:"query"`
+	ProjectID   string `json:"project_id"`
+	Notify      bool   `json:"notify"`
+}
+
+// visible reports whether a search is listed in the caller's project scope;
+// searches of every project are only listed without one
+func visible(s *searches.Search, scope string) bool {
+	return scope == "" || s.ProjectID == scope
+}
+
+// getVisible returns the search of the :id parameter; when it can't, it
+// writes the error response and ok is false
+func (h *SearchHandler) getVisible(c *fiber.Ctx) (s *searches.Search, ok bool) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		c.Status(400).JSON(fiber.Map{"error": "Invalid search ID"})
+		return nil, false
+	}
+	s, err = h.store.Get(context.Background(), id)
+	if err == nil && !visible(s, project.Scope(c.Query("project"), c.Get(project.Header))) {
+		err = searches.ErrSearchNotFound
+	}
+	if errors.Is(err, searches.ErrSearchNotFound) {
+		c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.Status(500).JSON(fiber.Map{"error": "Failed to fetch saved search"})
+		return nil, false
+	}
+	return s, true
+}
+
+// ListSearches returns the searches of the project (?project= or
+// X-Tenant-ID), or every search without one
+func (h *SearchHandler) ListSearches(c *fiber.Ctx) error {
+	list, err := h.store.List(context.Background(), project.Scope(c.Query("project"), c.Get(project.Header)))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch saved searches"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{"searches": list, "total": len(list)})
+}
+
+// GetSearch returns a search
+func (h *SearchHandler) GetSearch(c *fiber.Ctx) error {
+	s, ok := h.getVisible(c)
+	if !ok {
+		return nil
+	}
+	return c.JSON(s)
+}
+
+// CreateSearch saves a search in project_id, by default the request's
+// project (none searches every project)
+func (h *SearchHandler) CreateSearch(c *fiber.Ctx) error {
+	var req searchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	scope := project.Scope(req.ProjectID, c.Get(project.Header))
+	if scope != "" {
+		if err := project.Valid(scope); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	search := &searches.Search{
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Query:       req.Query,
+		ProjectID:   scope,
+		Notify:      req.Notify,
+		CreatedBy:   c.Get(middleware.UserIDHeader),
+	}
+	if err := searches.Validate(search); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	s, err := h.store.Create(context.Background(), search)
+	if errors.Is(err, searches.ErrSearchExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create saved search"})
+	}
+	return c.Status(201).JSON(s)
+}
+
+// UpdateSearch replaces the name, description, query and notify flag of a
+// search; its project can't change
+func (h *SearchHandler) UpdateSearch(c *fiber.Ctx) error {
+	current, ok := h.getVisible(c)
+	if !ok {
+		return nil
+	}
+	var req searchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	search := &searches.Search{
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Query:       req.Query,
+		Notify:      req.Notify,
+	}
+	if err := searches.Validate(search); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	s, err := h.store.Update(context.Background(), current.ID, search)
+	if errors.Is(err, searches.ErrSearchNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, searches.ErrSearchExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update saved search"})
+	}
+	return c.JSON(s)
+}
+
+// DeleteSearch removes a search
+func (h *SearchHandler) DeleteSearch(c *fiber.Ctx) error {
+	s, ok := h.getVisible(c)
+	if !ok {
+		return nil
+	}
+	err := h.store.Delete(context.Background(), s.ID)
+	if errors.Is(err, searches.ErrSearchNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete saved search"})
+	}
+	return c.JSON(fiber.Map{"message": "Saved search deleted"})
+}
+
+// RunSearch returns a page (?limit=, ?offset=) of the findings of the
+// search's project that match it now
+func (h *SearchHandler) RunSearch(c *fiber.Ctx) error {
+	s, ok := h.getVisible(c)
+	if !ok {
+		return nil
+	}
+	limit, offset, err := findingsPage(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	q, err := searches.Parse(s.Query)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Stored query is invalid: " + err.Error()})
+	}
+	return runSearch(c, h.collector, q, s.ProjectID, limit, offset)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/tlspolicies"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/tlspolicy"
+)
+
+// TLSPolicyHandler manages the TLS policies testssl.sh results are checked
+// against
+type TLSPolicyHandler struct {
+	store      *tlspolicies.Store
+	adminToken string
+}
+
+func NewTLSPolicyHandler(store *tlspolicies.Store, adminToken string) *TLSPolicyHandler {
+	return &TLSPolicyHandler{store: store, adminToken: adminToken}
+}
+
+// policyProject reads a project ID, empty for every project
+func policyProject(raw string) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(raw))
+	if id == "" {
+		return "", nil
+	}
+	return id, project.Valid(id)
+}
+
+// ListPolicies returns every policy, or with ?project= the one applied to
+// the project's scans: its own, or the one of every project
+func (h *TLSPolicyHandler) ListPolicies(c *fiber.Ctx) error {
+	ctx := context.Background()
+	if c.Query("project") == "" {
+		policies, err := h.store.List(ctx)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch TLS policies"})
+		}
+		c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(policies)))
+		return c.JSON(fiber.Map{"policies": policies, "total": len(policies)})
+	}
+
+	projectID, err := policyProject(c.Query("project"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	policy, err := h.store.Get(ctx, projectID)
+	if errors.Is(err, tlspolicies.ErrPolicyNotFound) {
+		policy, err = h.store.Get(ctx, "")
+	}
+	if errors.Is(err, tlspolicies.ErrPolicyNotFound) {
+		c.Set(pagination.HeaderTotalCount, "0")
+		return c.JSON(fiber.Map{"policies": []*tlspolicy.Policy{}, "total": 0})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch TLS policies"})
+	}
+	c.Set(pagination.HeaderTotalCount, "1")
+	return c.JSON(fiber.Map{"policies": []*tlspolicy.Policy{policy}, "total": 1})
+}
+
+// PutPolicy creates or replaces the policy of project_id (admins only);
+// without project_id it applies to every project without a policy of its own
+func (h *TLSPolicyHandler) PutPolicy(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	var req tlspolicy.Policy
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	projectID, err := policyProject(req.ProjectID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.ProjectID = projectID
+	if err := tlspolicy.Validate(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.UpdatedBy = c.Get(middleware.UserIDHeader)
+
+	policy, err := h.store.Put(context.Background(), &req)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save TLS policy"})
+	}
+	return c.JSON(policy)
+}
+
+// DeletePolicy removes the policy of ?project=, or the one of every project
+// without it (admins only)
+func (h *TLSPolicyHandler) DeletePolicy(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	projectID, err := policyProject(c.Query("project"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.store.Delete(context.Background(), projectID)
+	if errors.Is(err, tlspolicies.ErrPolicyNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete TLS policy"})
+	}
+	return c.JSON(fiber.Map{"message": "TLS policy deleted"})
+}
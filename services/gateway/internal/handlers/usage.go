@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/middleware"
+	"github.com/security-scanner/gateway/internal/usage"
+)
+
+// UsageHandler serves API usage for chargeback and abuse detection
+type UsageHandler struct {
+	tracker    *usage.Tracker
+	adminToken string
+}
+
+func NewUsageHandler(tracker *usage.Tracker, adminToken string) *UsageHandler {
+	return &UsageHandler{tracker: tracker, adminToken: adminToken}
+}
+
+// GetUsage returns usage rows and totals (?period=daily|monthly, from, to as
+// YYYY-MM-DD, tenant, api_key). Admins see every key; other callers only
+// their own.
+func (h *UsageHandler) GetUsage(c *fiber.Ctx) error {
+	apiKey := c.Query("api_key")
+	tenant := c.Query("tenant")
+	if !middleware.IsAdmin(c, h.adminToken) {
+		apiKey = usage.KeyFor(c)
+		if apiKey == "anonymous" {
+			return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+		}
+	}
+
+	monthly := false
+	switch c.Query("period", "daily") {
+	case "daily":
+	case "monthly":
+		monthly = true
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "period must be daily or monthly"})
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	if monthly {
+		from = to.AddDate(0, -12, 0)
+	}
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "from must be YYYY-MM-DD"})
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "to must be YYYY-MM-DD"})
+		}
+	}
+
+	rows, total, err := h.tracker.Report(context.Background(), monthly, from, to, tenant, apiKey)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch usage"})
+	}
+	return c.JSON(fiber.Map{
+		"period": c.Query("period", "daily"),
+		"from":   from.Format("2006-01-02"),
+		"to":     to.Format("2006-01-02"),
+		"usage":  rows,
+		"total":  total,
+	})
+}
+
+// GetAnomalies lists keys whose usage today is far above their 7-day
+// average (?factor=, default 5; ?min_requests=, default 500). Admin only.
+func (h *UsageHandler) GetAnomalies(c *fiber.Ctx) error {
+	if !middleware.IsAdmin(c, h.adminToken) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+	}
+	factor := c.QueryFloat("factor", 5)
+	if factor < 1 {
+		factor = 5
+	}
+	minRequests := c.QueryInt("min_requests", 500)
+
+	anomalies, err := h.tracker.Anomalies(context.Background(), factor, int64(minRequests))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to compute usage anomalies"})
+	}
+	return c.JSON(fiber.Map{"factor": factor, "min_requests": minRequests, "anomalies": anomalies})
+}
@@ -0,0 +1,122 @@
+// Package integrations keeps the Slack and Discord channels the services
+// post scans with high-severity findings to (chat_channels); the services
+// read the table directly, so changes apply to the next scan.
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/shared/pkg/chat"
+)
+
+var (
+	// ErrChannelNotFound is returned for unknown channels
+	ErrChannelNotFound = errors.New("chat channel not found")
+	// ErrChannelExists is returned for a name already taken
+	ErrChannelExists = errors.New("a chat channel with this name already exists")
+)
+
+// Store keeps the chat channels
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates the chat channel table
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), chat.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create chat channel table: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func scanChannel(row pgx.Row) (*chat.Channel, error) {
+	c, err := chat.Scan(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrChannelNotFound
+	}
+	return c, err
+}
+
+// List returns every channel
+func (s *Store) List(ctx context.Context) ([]*chat.Channel, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+chat.Columns+` FROM chat_channels ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []*chat.Channel{}
+	for rows.Next() {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// Get returns a channel
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*chat.Channel, error) {
+	return scanChannel(s.db.Pool.QueryRow(ctx, `SELECT `+chat.Columns+` FROM chat_channels WHERE id = $1`, id))
+}
+
+// nameTaken reports whether another channel than id has name
+func (s *Store) nameTaken(ctx context.Context, name string, id uuid.UUID) (bool, error) {
+	var taken bool
+	err := s.db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM chat_channels WHERE name = $1 AND id <> $2)`, name, id).Scan(&taken)
+	return taken, err
+}
+
+// Create adds a channel; it must pass chat.Validate
+func (s *Store) Create(ctx context.Context, c *chat.Channel) (*chat.Channel, error) {
+	if err := chat.Validate(c); err != nil {
+		return nil, err
+	}
+	c.ID = uuid.New()
+	if taken, err := s.nameTaken(ctx, c.Name, c.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrChannelExists
+	}
+	return scanChannel(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO chat_channels (id, name, type, webhook_url, severities, tools, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+chat.Columns,
+		c.ID, c.Name, c.Type, c.WebhookURL, c.Severities, c.Tools, c.Enabled))
+}
+
+// Update replaces a channel; it must pass chat.Validate
+func (s *Store) Update(ctx context.Context, id uuid.UUID, c *chat.Channel) (*chat.Channel, error) {
+	if err := chat.Validate(c); err != nil {
+		return nil, err
+	}
+	if taken, err := s.nameTaken(ctx, c.Name, id); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrChannelExists
+	}
+	return scanChannel(s.db.Pool.QueryRow(ctx, `
+		UPDATE chat_channels SET name = $2, type = $3, webhook_url = $4, severities = $5, tools = $6,
+			enabled = $7, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+chat.Columns,
+		id, c.Name, c.Type, c.WebhookURL, c.Severities, c.Tools, c.Enabled))
+}
+
+// Delete removes a channel
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM chat_channels WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// Package maintenance puts the gateway in maintenance mode while backend
+// services are upgraded: requests that would start scans get a 503 with
+// Retry-After, every other request is still served, and the drain status
+// tells when the work already started has finished.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/gateway/internal/usage"
+	"github.com/security-scanner/shared/pkg/client"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS gateway_maintenance (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL DEFAULT '',
+    retry_after INTEGER NOT NULL DEFAULT 0,
+    services TEXT[] NOT NULL DEFAULT '{}',
+    started_at TIMESTAMP,
+    started_by VARCHAR(255) NOT NULL DEFAULT ''
+)`
+
+// DefaultRetryAfter is the Retry-After, in seconds, of modes that don't set one
+const DefaultRetryAfter = 300
+
+// DefaultMessage is shown to callers of modes without a message of their own
+const DefaultMessage = "The scanner is under maintenance; new scans can be started again shortly"
+
+// Services are the backend services a maintenance can be limited to
+var Services = []string{"network", "web", "recon", "api", "cms", "cloud"}
+
+// collections are the proxied scan collections (those counted as scan
+// creations by usage.CreatesScan) and the service behind each one
+var collections = []struct{ path, service string }{
+	{"/api/scans", "network"},
+	{"/api/network/scans", "network"},
+	{"/api/vulnerabilities", "web"},
+	{"/api/web/vulnerabilities", "web"},
+	{"/api/webscans", "web"},
+	{"/api/web/fuzzing", "web"},
+	{"/api/web/screenshots", "web"},
+	{"/api/web/ssl", "web"},
+	{"/api/recon", "recon"},
+	{"/api/apiscans", "api"},
+	{"/api/cmsscans", "cms"},
+	{"/api/cloudscans", "cloud"},
+}
+
+// drainingStatuses are the statuses of scans a service is still working on
+var drainingStatuses = []string{"pending", "queued", "running"}
+
+// State is the maintenance mode set by an admin
+type State struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message"`
+	RetryAfter int        `json:"retry_after"` // seconds
+	Services   []string   `json:"services"`    // empty: every service
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	StartedBy  string     `json:"started_by,omitempty"`
+}
+
+// Covers reports whether new scans of service are refused
+func (s State) Covers(service string) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Services) == 0 {
+		return true
+	}
+	for _, name := range s.Services {
+		if name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate normalizes s and checks its services and Retry-After
+func Validate(s *State) error {
+	if s.RetryAfter < 0 || s.RetryAfter > 86400 {
+		return errors.New("retry_after must be between 0 and 86400 seconds")
+	}
+	if s.RetryAfter == 0 {
+		s.RetryAfter = DefaultRetryAfter
+	}
+	s.Message = strings.TrimSpace(s.Message)
+	if len(s.Message) > 500 {
+		return errors.New("message must be at most 500 characters")
+	}
+	services := []string{}
+	for _, name := range s.Services {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, service := range Services {
+			known = known || service == name
+		}
+		if !known {
+			return fmt.Errorf("unknown service %q, expected one of %s", name, strings.Join(Services, ", "))
+		}
+		services = append(services, name)
+	}
+	s.Services = services
+	return nil
+}
+
+// ServiceDrain is what a service is still doing
+type ServiceDrain struct {
+	InFlight int    `json:"in_flight"` // writes proxied by this gateway replica, not answered yet
+	Pending  int    `json:"pending"`   // scans created but not started, including queued ones
+	Running  int    `json:"running"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Drain is the progress of draining the services under maintenance
+type Drain struct {
+	Drained  bool                     `json:"drained"` // nothing in flight, pending or running
+	Services map[string]*ServiceDrain `json:"services"`
+}
+
+// Mode is the maintenance mode of the gateway. With a database it is shared
+// by every replica (gateway_maintenance); otherwise it is kept in memory and
+// lost on restart.
+type Mode struct {
+	db      *database.Database
+	sources map[string][]*client.Scans
+
+	mu       sync.RWMutex
+	state    State
+	inFlight map[string]int
+}
+
+// NewMode creates the maintenance table when db is not nil and loads the
+// mode left by an earlier run
+func NewMode(db *database.Database) (*Mode, error) {
+	m := &Mode{db: db, state: State{Services: []string{}}, inFlight: map[string]int{}}
+	if db == nil {
+		return m, nil
+	}
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create maintenance table: %w", err)
+	}
+	if err := m.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetSources sets the scan collections of each service whose pending and
+// running scans are counted by Drain
+func (m *Mode) SetSources(sources map[string][]*client.Scans) {
+	m.sources = sources
+}
+
+// Start reloads the mode every 10 seconds, so that a change made on another
+// replica applies here too, until ctx is cancelled
+func (m *Mode) Start(ctx context.Context) {
+	if m.db == nil {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.refresh(ctx); err != nil {
+				log.Printf("Failed to reload maintenance mode: %v", err)
+			}
+		}
+	}
+}
+
+func (m *Mode) refresh(ctx context.Context) error {
+	var s State
+	err := m.db.Pool.QueryRow(ctx, `
+		SELECT enabled, message, retry_after, services, started_at, started_by
+		FROM gateway_maintenance WHERE id = 1
+	`).Scan(&s.Enabled, &s.Message, &s.RetryAfter, &s.Services, &s.StartedAt, &s.StartedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s = State{Services: []string{}}
+	} else if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.state = s
+	m.mu.Unlock()
+	return nil
+}
+
+// Get returns the current mode
+func (m *Mode) Get() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Set enables the mode s describes (it must pass Validate), or disables it;
+// the start is kept when an enabled mode is only edited
+func (m *Mode) Set(ctx context.Context, s State, by string) (State, error) {
+	if !s.Enabled {
+		s = State{Services: []string{}}
+	} else {
+		current := m.Get()
+		if current.Enabled {
+			s.StartedAt, s.StartedBy = current.StartedAt, current.StartedBy
+		} else {
+			now := time.Now()
+			s.StartedAt, s.StartedBy = &now, by
+		}
+	}
+
+	if m.db != nil {
+		_, err := m.db.Pool.Exec(ctx, `
+			INSERT INTO gateway_maintenance (id, enabled, message, retry_after, services, started_at, started_by)
+			VALUES (1, $1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO UPDATE SET
+				enabled = EXCLUDED.enabled, message = EXCLUDED.message, retry_after = EXCLUDED.retry_after,
+				services = EXCLUDED.services, started_at = EXCLUDED.started_at, started_by = EXCLUDED.started_by
+		`, s.Enabled, s.Message, s.RetryAfter, s.Services, s.StartedAt, s.StartedBy)
+		if err != nil {
+			return State{}, err
+		}
+	}
+	m.mu.Lock()
+	m.state = s
+	m.mu.Unlock()
+	return s, nil
+}
+
+// serviceOf returns the service behind a scan collection path, "" for
+// other paths
+func serviceOf(path string) string {
+	for _, c := range collections {
+		if path == c.path || strings.HasPrefix(path, c.path+"/") {
+			return c.service
+		}
+	}
+	return ""
+}
+
+// startsScan reports whether a POST to path starts work on a service: it
+// creates a scan, or retries or runs an existing one (e.g. a schedule)
+func startsScan(path string) bool {
+	return usage.CreatesScan(path) || strings.HasSuffix(path, "/retry") || strings.HasSuffix(path, "/run")
+}
+
+// Middleware refuses requests that would start scans on a service under
+// maintenance, and counts the writes in flight to each service (reads,
+// including streamed logs, don't hold up an upgrade)
+func (m *Mode) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// The services route case-insensitively, so /api/network/SCANS is a scan
+		path := strings.ToLower(strings.TrimSuffix(c.Path(), "/"))
+		service := serviceOf(path)
+		if service == "" {
+			return c.Next()
+		}
+
+		if state := m.Get(); c.Method() == fiber.MethodPost && state.Covers(service) && startsScan(path) {
+			message := state.Message
+			if message == "" {
+				message = DefaultMessage
+			}
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(state.RetryAfter))
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error":       message,
+				"maintenance": true,
+				"retry_after": state.RetryAfter,
+			})
+		}
+
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+		m.mu.Lock()
+		m.inFlight[service]++
+		m.mu.Unlock()
+		defer func() {
+			m.mu.Lock()
+			m.inFlight[service]--
+			m.mu.Unlock()
+		}()
+		return c.Next()
+	}
+}
+
+// Drain counts what the services under maintenance (every service when
+// maintenance is off) are still doing. A service that doesn't answer is not
+// drained.
+func (m *Mode) Drain(ctx context.Context) *Drain {
+	state := m.Get()
+	drain := &Drain{Drained: true, Services: map[string]*ServiceDrain{}}
+
+	m.mu.RLock()
+	for _, service := range Services {
+		if !state.Enabled || state.Covers(service) {
+			drain.Services[service] = &ServiceDrain{InFlight: m.inFlight[service]}
+		}
+	}
+	m.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for service, d := range drain.Services {
+		for _, source := range m.sources[service] {
+			for _, status := range drainingStatuses {
+				wg.Add(1)
+				go func(d *ServiceDrain, source *client.Scans, status string) {
+					defer wg.Done()
+					list, err := source.List(ctx, url.Values{"status": {status}, "limit": {"100"}})
+
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						d.Error = err.Error()
+						return
+					}
+					// Not every service filters by status
+					for _, scan := range list.Items {
+						switch {
+						case scan.Status != status:
+						case status == "running":
+							d.Running++
+						default:
+							d.Pending++
+						}
+					}
+				}(d, source, status)
+			}
+		}
+	}
+	wg.Wait()
+
+	for _, d := range drain.Services {
+		if d.InFlight > 0 || d.Pending > 0 || d.Running > 0 || d.Error != "" {
+			drain.Drained = false
+		}
+	}
+	return drain
+}
@@ -0,0 +1,30 @@
+package maintenance
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddlewareBlocksScansWhateverTheCase(t *testing.T) {
+	m, err := NewMode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.state = State{Enabled: true}
+
+	app := fiber.New()
+	app.Use(m.Middleware())
+	app.All("/*", func(c *fiber.Ctx) error { return c.SendStatus(201) })
+
+	for _, path := range []string{"/api/scans", "/api/SCANS", "/api/Network/Scans", "/api/webscans/SQLMAP"} {
+		resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusServiceUnavailable {
+			t.Errorf("POST %s = %d during maintenance", path, resp.StatusCode)
+		}
+	}
+}
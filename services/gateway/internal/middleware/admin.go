@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminTokenHeader carries ADMIN_TOKEN for callers without a user account
+const AdminTokenHeader = "X-Admin-Token"
+
+// IsAdmin reports whether the caller has admin rights: the admin token, or a
+// user Auth identified with the admin role. Auth removes client-supplied
+// identity headers, so the role can be trusted behind it.
+func IsAdmin(c *fiber.Ctx, adminToken string) bool {
+	if c.Get(UserRoleHeader) == "admin" {
+		return true
+	}
+	provided := c.Get(AdminTokenHeader)
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) == 1
+}
@@ -7,9 +7,11 @@ import (
 
 func CORS() fiber.Handler {
 	return cors.New(cors.Config{
-		AllowOrigins:     "*",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Request-ID",
+		AllowOrigins: "*",
+		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS,PATCH",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization,X-Request-ID,X-Admin-Token,X-Tenant-ID",
+		// Read by clients that paginate and throttle by headers
+		ExposeHeaders:    "X-Request-ID,X-Total-Count,Link,Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset",
 		AllowCredentials: false,
 		MaxAge:           86400,
 	})
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Identity headers forwarded to backend services
+const (
+	UserIDHeader   = "X-User-ID"
+	UserRoleHeader = "X-User-Role"
+)
+
+// IdentityResolver returns the caller's user ID and role; ok is false for anonymous requests
+type IdentityResolver func(c *fiber.Ctx) (userID, role string, ok bool)
+
+// AuthOptions configures Auth
+type AuthOptions struct {
+	// Resolvers are tried in order until one recognizes the caller
+	Resolvers []IdentityResolver
+	// Required rejects anonymous requests, except to Public paths
+	Required bool
+	// Public paths need no credentials; "/api/auth/*" matches /api/auth and
+	// everything under it, and a "*" segment matches any one segment
+	Public []string
+}
+
+// Auth replaces client-supplied identity headers with the caller's
+// authenticated identity (session or bearer token, or API key), so backend
+// services can trust them. When required, requests nobody recognizes are
+// rejected with 401 unless their path is public.
+func Auth(opts AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Request().Header.Del(UserIDHeader)
+		c.Request().Header.Del(UserRoleHeader)
+		for _, resolve := range opts.Resolvers {
+			if userID, role, ok := resolve(c); ok {
+				c.Request().Header.Set(UserIDHeader, userID)
+				c.Request().Header.Set(UserRoleHeader, role)
+				return c.Next()
+			}
+		}
+
+		if !opts.Required || c.Method() == fiber.MethodOptions || publicPath(c.Path(), opts.Public) {
+			return c.Next()
+		}
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="security-scanner"`)
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+}
+
+func publicPath(path string, public []string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, p := range public {
+		if matchPath(path, strings.TrimSuffix(p, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches path against pattern: a "*" segment matches any one
+// segment, and a trailing "/*" matches the path before it and everything
+// under it. Case is ignored, since the services behind the gateway route
+// case-insensitively.
+func matchPath(path, pattern string) bool {
+	prefix, subtree := strings.CutSuffix(pattern, "/*")
+	want := strings.Split(prefix, "/")
+	got := strings.Split(path, "/")
+	if len(got) < len(want) || (!subtree && len(got) != len(want)) {
+		return false
+	}
+	for i, segment := range want {
+		if segment != "*" && !strings.EqualFold(segment, got[i]) {
+			return false
+		}
+	}
+	return true
+}
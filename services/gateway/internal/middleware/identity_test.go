@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/pkg/config"
+)
+
+func TestAgentTokenPassesRequiredAuth(t *testing.T) {
+	cfg := config.Load()
+	notJWT := func(c *fiber.Ctx) (string, string, bool) { return "", "", false }
+
+	app := fiber.New()
+	app.Use(Auth(AuthOptions{Resolvers: []IdentityResolver{notJWT}, Required: true, Public: cfg.AuthPublicPaths}))
+	app.Use(Roles(RoleOptions{ViewerWritePaths: cfg.ViewerWritePaths, AdminPaths: cfg.AdminPaths}))
+	app.All("/*", func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+	for path, want := range map[string]int{
+		"/api/network/agents/heartbeat":     204,
+		"/api/network/agents/jobs/next":     204,
+		"/api/network/agents/jobs/1/result": 204,
+		"/api/network/scans":                401,
+		"/api/network/admin/agents":         401,
+	} {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer agent-token")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("POST %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
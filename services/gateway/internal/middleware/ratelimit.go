@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// Headers telling API clients how much of their rate limit is left; Reset
+// is in seconds until the window starts over
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimit allows each caller, as identified by key, perMinute requests a
+// minute and answers 429 with Retry-After beyond that. Every response carries
+// the X-RateLimit headers. Counts are kept by each gateway replica.
+func RateLimit(perMinute int, key func(*fiber.Ctx) string) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:          perMinute,
+		Expiration:   time.Minute,
+		KeyGenerator: key,
+		LimitReached: func(c *fiber.Ctx) error {
+			// The limiter only sets Retry-After on rejected requests
+			c.Set(RateLimitLimitHeader, strconv.Itoa(perMinute))
+			c.Set(RateLimitRemainingHeader, "0")
+			c.Set(RateLimitResetHeader, string(c.Response().Header.Peek(fiber.HeaderRetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Rate limit exceeded, retry after the time in Retry-After",
+			})
+		},
+	})
+}
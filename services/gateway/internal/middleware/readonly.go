@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReadOnly refuses every request that could change state (any method other
+// than GET, HEAD and OPTIONS) with 403, so demo and training deployments
+// serve their existing scans and findings but nobody can start, delete or
+// reconfigure anything. Allowed paths, matched like AuthOptions.Public, are
+// POSTs that only read or sign in, such as logins and estimates.
+func ReadOnly(allowed []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if publicPath(c.Path(), allowed) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":     "This deployment is read-only: scans, deletions and configuration changes are disabled",
+			"read_only": true,
+		})
+	}
+}
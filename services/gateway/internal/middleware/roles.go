@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoleOptions configures Roles
+type RoleOptions struct {
+	// AdminToken is accepted in X-Admin-Token in place of the admin role
+	AdminToken string
+	// ViewerWritePaths are the paths viewers may POST, PUT or DELETE to:
+	// logging in and out, managing their own sessions, dry runs
+	ViewerWritePaths []string
+	// AdminPaths are only served to admins
+	AdminPaths []string
+}
+
+// Roles enforces the role Auth identified the caller with: viewers can only
+// read (GET and HEAD) except on ViewerWritePaths, analysts can do anything
+// outside AdminPaths, and admins (or the admin token) everything. Requests
+// without an identity are left to Auth and AUTH_REQUIRED, except that
+// AdminPaths still need the admin token.
+func Roles(opts RoleOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions || IsAdmin(c, opts.AdminToken) {
+			return c.Next()
+		}
+		role := c.Get(UserRoleHeader)
+		if publicPath(c.Path(), opts.AdminPaths) {
+			return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required", "role": role})
+		}
+		if role == "viewer" && c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead &&
+			!publicPath(c.Path(), opts.ViewerWritePaths) {
+			return c.Status(403).JSON(fiber.Map{"error": "The viewer role can only read; analyst or admin is required to make changes", "role": role})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SCIMAuth requires "Authorization: Bearer <token>" with the SCIM token
+// configured in the identity provider. Errors use the SCIM error schema.
+func SCIMAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return c.Status(401).JSON(fiber.Map{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+				"status":  "401",
+				"detail":  "Invalid SCIM token",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,307 @@
+package ownership
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+)
+
+const (
+	// DNSRecordPrefix is the label the TXT challenge is published under
+	DNSRecordPrefix = "_scanner-verification."
+	// TokenPrefix precedes the token in the TXT record
+	TokenPrefix = "scanner-verification="
+	// WellKnownPath is where the file challenge is served
+	WellKnownPath = "/.well-known/scanner-verification.txt"
+)
+
+var (
+	// ErrDomainNotFound is returned for domains without a challenge
+	ErrDomainNotFound = errors.New("domain has no verification challenge")
+	// ErrInvalidDomain is returned for IPs, URLs and malformed names
+	ErrInvalidDomain = errors.New("invalid domain name")
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS domain_verifications (
+    domain VARCHAR(255) PRIMARY KEY,
+    token VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    method VARCHAR(10),
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    verified_at TIMESTAMP,
+    last_checked_at TIMESTAMP,
+    last_error TEXT,
+    CONSTRAINT valid_domain_verification_status CHECK (status IN ('pending', 'verified', 'failed'))
+)`
+
+// Domain is a domain and the state of its ownership challenge. A verified
+// domain covers its subdomains.
+type Domain struct {
+	Domain        string     `json:"domain"`
+	Token         string     `json:"token"`
+	Status        string     `json:"status"` // pending, verified, failed or expired
+	Method        string     `json:"method,omitempty"`
+	RequestedBy   string     `json:"requested_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Instructions  struct {
+		DNSRecord string `json:"dns_record"`
+		DNSValue  string `json:"dns_value"`
+		FileURL   string `json:"file_url"`
+		FileBody  string `json:"file_body"`
+	} `json:"instructions"`
+}
+
+const domainColumns = `domain, token, status, COALESCE(method, ''), requested_by, created_at, verified_at, last_checked_at, COALESCE(last_error, '')`
+
+// Store keeps ownership challenges and checks them over DNS and HTTP
+type Store struct {
+	db       *database.Database
+	validity time.Duration
+	resolver *net.Resolver
+	client   *http.Client
+}
+
+// NewStore creates the domain_verifications table. Verifications older than
+// validity must be renewed; zero keeps them forever.
+func NewStore(db *database.Database, validity time.Duration) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create domain_verifications table: %w", err)
+	}
+	return &Store{
+		db:       db,
+		validity: validity,
+		resolver: net.DefaultResolver,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+	}, nil
+}
+
+// NormalizeDomain lowercases a domain and rejects IPs, URLs and wildcards
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" || len(domain) > 253 || net.ParseIP(domain) != nil || !strings.Contains(domain, ".") {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", ErrInvalidDomain
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", ErrInvalidDomain
+			}
+		}
+	}
+	return domain, nil
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Store) scanDomain(row pgx.Row) (*Domain, error) {
+	var d Domain
+	err := row.Scan(&d.Domain, &d.Token, &d.Status, &d.Method, &d.RequestedBy, &d.CreatedAt,
+		&d.VerifiedAt, &d.LastCheckedAt, &d.LastError)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.VerifiedAt != nil && s.validity > 0 {
+		expires := d.VerifiedAt.Add(s.validity)
+		d.ExpiresAt = &expires
+		if d.Status == "verified" && time.Now().After(expires) {
+			d.Status = "expired"
+		}
+	}
+	d.Instructions.DNSRecord = DNSRecordPrefix + d.Domain
+	d.Instructions.DNSValue = TokenPrefix + d.Token
+	d.Instructions.FileURL = "https://" + d.Domain + WellKnownPath
+	d.Instructions.FileBody = d.Token
+	return &d, nil
+}
+
+// List returns every domain with a challenge
+func (s *Store) List(ctx context.Context) ([]*Domain, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+domainColumns+` FROM domain_verifications ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []*Domain{}
+	for rows.Next() {
+		d, err := s.scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// Get returns a domain's challenge
+func (s *Store) Get(ctx context.Context, domain string) (*Domain, error) {
+	return s.scanDomain(s.db.Pool.QueryRow(ctx, `SELECT `+domainColumns+` FROM domain_verifications WHERE domain = $1`, domain))
+}
+
+// Request returns the challenge of a domain, creating it on first request.
+// The token never changes, so published records stay valid.
+func (s *Store) Request(ctx context.Context, domain, requestedBy string) (*Domain, error) {
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO domain_verifications (domain, token, requested_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (domain) DO NOTHING
+	`, domain, newToken(), requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, domain)
+}
+
+// Delete removes a domain's challenge and verification
+func (s *Store) Delete(ctx context.Context, domain string) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM domain_verifications WHERE domain = $1`, domain)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// Verify looks for the token in the domain's TXT record, then in the
+// well-known file, and records the outcome
+func (s *Store) Verify(ctx context.Context, domain string) (*Domain, error) {
+	d, err := s.Get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	method, checkErr := "", s.checkDNS(ctx, d)
+	if checkErr == nil {
+		method = "dns"
+	} else if fileErr := s.checkFile(ctx, d); fileErr == nil {
+		method, checkErr = "file", nil
+	} else {
+		checkErr = fmt.Errorf("%v; %v", checkErr, fileErr)
+	}
+
+	if checkErr != nil {
+		_, err = s.db.Pool.Exec(ctx, `
+			UPDATE domain_verifications
+			SET status = CASE WHEN status = 'verified' THEN status ELSE 'failed' END,
+			    last_checked_at = NOW(), last_error = $2
+			WHERE domain = $1
+		`, d.Domain, checkErr.Error())
+	} else {
+		_, err = s.db.Pool.Exec(ctx, `
+			UPDATE domain_verifications
+			SET status = 'verified', method = $2, verified_at = NOW(), last_checked_at = NOW(), last_error = NULL
+			WHERE domain = $1
+		`, d.Domain, method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, d.Domain)
+}
+
+func (s *Store) checkDNS(ctx context.Context, d *Domain) error {
+	records, err := s.resolver.LookupTXT(ctx, DNSRecordPrefix+d.Domain)
+	if err != nil {
+		return fmt.Errorf("no TXT record at %s%s", DNSRecordPrefix, d.Domain)
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == TokenPrefix+d.Token {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT record at %s%s does not contain the token", DNSRecordPrefix, d.Domain)
+}
+
+func (s *Store) checkFile(ctx context.Context, d *Domain) error {
+	var lastErr error
+	for _, scheme := range []string{"https", "http"} {
+		url := scheme + "://" + d.Domain + WellKnownPath
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to fetch %s", url)
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("%s returned HTTP %d", url, resp.StatusCode)
+			continue
+		}
+		if strings.TrimSpace(string(body)) == d.Token {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s does not contain the token", url)
+	}
+	return lastErr
+}
+
+// IsVerified reports whether host or one of its parent domains has a
+// current verification
+func (s *Store) IsVerified(ctx context.Context, host string) (bool, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	var candidates []string
+	for name := host; strings.Contains(name, "."); {
+		candidates = append(candidates, name)
+		_, name, _ = strings.Cut(name, ".")
+	}
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	var since time.Time
+	if s.validity > 0 {
+		since = time.Now().Add(-s.validity)
+	}
+	var verified bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM domain_verifications
+			WHERE domain = ANY($1) AND status = 'verified' AND verified_at > $2
+		)
+	`, candidates, since).Scan(&verified)
+	return verified, err
+}
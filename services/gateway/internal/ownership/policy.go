@@ -0,0 +1,337 @@
+package ownership
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Policies for scans of unverified domains
+const (
+	PolicyOff        = "off"        // never require verification
+	PolicyAggressive = "aggressive" // require it for masscan full, nuclei critical, brute force and sqlmap scans
+	PolicyAll        = "all"        // require it for every scan of a domain
+)
+
+// ValidPolicy reports whether policy is a known policy
+func ValidPolicy(policy string) bool {
+	return policy == PolicyOff || policy == PolicyAggressive || policy == PolicyAll
+}
+
+// scanEndpoint is a scan create endpoint, the body fields holding its
+// targets, and what makes one of its scans aggressive
+type scanEndpoint struct {
+	paths      []string
+	fields     []string
+	aggressive func(body map[string]interface{}) bool
+}
+
+var scanEndpoints = []scanEndpoint{
+	{[]string{"/api/scans", "/api/network/scans"}, []string{"target"}, masscanFull},
+	{[]string{"/api/vulnerabilities", "/api/web/vulnerabilities"}, []string{"target"}, nucleiCritical},
+	{[]string{"/api/webscans/ffuf"}, []string{"url"}, always},
+	{[]string{"/api/webscans/credcheck"}, []string{"targets"}, always},
+	{[]string{"/api/webscans/sqlmap"}, []string{"url"}, always},
+	{[]string{"/api/webscans/gowitness"}, []string{"urls"}, never},
+	{[]string{"/api/webscans/testssl"}, []string{"target"}, never},
+	{[]string{"/api/recon"}, []string{"target"}, never},
+	{[]string{"/api/apiscans"}, []string{"target"}, never},
+	{[]string{"/api/cmsscans"}, []string{"target"}, never},
+	{[]string{"/api/cloudscans"}, []string{"target"}, never},
+}
+
+// bodyFields are the request fields the policy reads. The services decode
+// bodies into structs, which match JSON keys case-insensitively and keep the
+// last of duplicate keys, so decodeBody does the same.
+var bodyFields = []string{
+	"target", "targets", "url", "urls", "scan_type", "scanner", "ports", "top_ports",
+	"configuration", "severity", "simulate", "template_id",
+}
+
+// decodeBody returns the policy's fields of a JSON object under their
+// canonical names, matching keys the way encoding/json matches struct fields
+func decodeBody(raw []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("body is not a JSON object")
+	}
+	body := map[string]interface{}{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		for _, field := range bodyFields {
+			if strings.EqualFold(key, field) {
+				body[field] = value
+			}
+		}
+	}
+	return body, nil
+}
+
+// scanTemplate holds what a network scan template fills in a scan request
+type scanTemplate struct {
+	scanType      string
+	scanner       string
+	ports         *string
+	configuration map[string]interface{}
+}
+
+// loadTemplate reads a network scan template (template_id) from the shared
+// database; nil when there is no such template, which the service rejects
+func (s *Store) loadTemplate(ctx context.Context, id string) (*scanTemplate, error) {
+	var exists bool
+	if err := s.db.Pool.QueryRow(ctx, `SELECT to_regclass('scan_templates') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	var t scanTemplate
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT scan_type, scanner, ports, configuration FROM scan_templates WHERE id::text = $1
+	`, id).Scan(&t.scanType, &t.scanner, &t.ports, &t.configuration)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// withTemplate fills what the request leaves out from its template, like
+// the network service does before running the scan
+func withTemplate(body map[string]interface{}, t *scanTemplate) {
+	if stringField(body, "scan_type") == "" {
+		body["scan_type"] = t.scanType
+	}
+	if stringField(body, "scanner") == "" {
+		body["scanner"] = t.scanner
+	}
+	if body["configuration"] == nil && t.configuration != nil {
+		body["configuration"] = t.configuration
+	}
+	if t.scanner == "masscan" || t.scanner == "pipeline" {
+		configuration, ok := body["configuration"].(map[string]interface{})
+		if !ok {
+			configuration = map[string]interface{}{}
+		}
+		if _, ok := configuration["ports"]; !ok && t.ports != nil {
+			configuration["ports"] = *t.ports
+		}
+		body["configuration"] = configuration
+	}
+}
+
+func always(map[string]interface{}) bool { return true }
+func never(map[string]interface{}) bool  { return false }
+
+// masscanFull matches masscan scans of all ports, or of more than 1000
+func masscanFull(body map[string]interface{}) bool {
+	scanType := strings.ToLower(stringField(body, "scan_type"))
+	scanner := strings.ToLower(stringField(body, "scanner"))
+	if scanner != "masscan" && !(scanner == "" && strings.HasPrefix(scanType, "masscan")) {
+		return false
+	}
+	if scanType == "masscan_full" {
+		return true
+	}
+	// masscan takes its ports from the configuration, and scans all of them
+	// when the configuration doesn't list any
+	if configuration, ok := body["configuration"].(map[string]interface{}); ok {
+		ports, _ := configuration["ports"].(string)
+		if ports == "" {
+			return true
+		}
+		if countPorts(ports) > 1000 {
+			return true
+		}
+	}
+	if topPorts, ok := body["top_ports"].(float64); ok && topPorts > 1000 {
+		return true
+	}
+	return countPorts(stringField(body, "ports")) > 1000
+}
+
+// nucleiCritical matches nuclei scans that run critical templates, which
+// includes scans without a severity filter
+func nucleiCritical(body map[string]interface{}) bool {
+	severities, _ := body["severity"].([]interface{})
+	if len(severities) == 0 {
+		return true
+	}
+	for _, severity := range severities {
+		if s, ok := severity.(string); ok && strings.EqualFold(s, "critical") {
+			return true
+		}
+	}
+	return false
+}
+
+// countPorts counts the ports of a "22,80,8000-8100" list
+func countPorts(ports string) int {
+	total := 0
+	for _, part := range strings.Split(ports, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		l, err := strconv.Atoi(low)
+		if err != nil {
+			continue
+		}
+		h := l
+		if isRange {
+			if h, err = strconv.Atoi(high); err != nil {
+				continue
+			}
+		}
+		if h >= l {
+			total += h - l + 1
+		}
+	}
+	return total
+}
+
+func stringField(body map[string]interface{}, field string) string {
+	s, _ := body[field].(string)
+	return s
+}
+
+// Domains returns the domain names among the targets of a request body;
+// IPs, CIDRs and ranges are left out since they can't be verified
+func Domains(body map[string]interface{}, fields []string) []string {
+	var targets []string
+	for _, field := range fields {
+		switch value := body[field].(type) {
+		case string:
+			targets = append(targets, strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+		case []interface{}:
+			for _, item := range value {
+				if s, ok := item.(string); ok {
+					targets = append(targets, s)
+				}
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var domains []string
+	for _, target := range targets {
+		host := target
+		if strings.Contains(target, "://") {
+			u, err := url.Parse(target)
+			if err != nil {
+				continue
+			}
+			host = u.Hostname()
+		} else if h, _, err := net.SplitHostPort(target); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+		if host == "" || strings.ContainsAny(host, "/*") || net.ParseIP(host) != nil || isIPRange(host) || seen[host] {
+			continue
+		}
+		if _, err := NormalizeDomain(host); err != nil {
+			continue
+		}
+		seen[host] = true
+		domains = append(domains, host)
+	}
+	return domains
+}
+
+// isIPRange matches last-octet ranges such as 192.168.1.10-50
+func isIPRange(host string) bool {
+	dash := strings.LastIndex(host, "-")
+	return dash > 0 && net.ParseIP(host[:dash]) != nil
+}
+
+// Middleware rejects scans of unverified domains that the policy requires
+// to be verified. Simulated scans send no traffic and are always allowed.
+func (s *Store) Middleware(policy string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if policy == PolicyOff || c.Method() != fiber.MethodPost {
+			return c.Next()
+		}
+		endpoint := matchEndpoint(strings.TrimSuffix(c.Path(), "/"))
+		if endpoint == nil {
+			return c.Next()
+		}
+
+		body, err := decodeBody(c.Body())
+		if err != nil {
+			// Let the service report the malformed body
+			return c.Next()
+		}
+		if simulate, _ := body["simulate"].(bool); simulate {
+			return c.Next()
+		}
+		// A template can make a scan aggressive, e.g. one with scan_type masscan_full
+		if id := stringField(body, "template_id"); id != "" && policy == PolicyAggressive {
+			template, err := s.loadTemplate(c.Context(), id)
+			if err != nil {
+				log.Printf("Failed to load scan template %s: %v", id, err)
+				return c.Status(503).JSON(fiber.Map{"error": "Failed to check domain ownership"})
+			}
+			if template != nil {
+				withTemplate(body, template)
+			}
+		}
+		if policy == PolicyAggressive && !endpoint.aggressive(body) {
+			return c.Next()
+		}
+
+		var unverified []string
+		for _, domain := range Domains(body, endpoint.fields) {
+			verified, err := s.IsVerified(c.Context(), domain)
+			if err != nil {
+				log.Printf("Failed to check ownership of %s: %v", domain, err)
+				return c.Status(503).JSON(fiber.Map{"error": "Failed to check domain ownership"})
+			}
+			if !verified {
+				unverified = append(unverified, domain)
+			}
+		}
+		if len(unverified) > 0 {
+			reason := "Scans"
+			if policy == PolicyAggressive {
+				reason = "Aggressive scans"
+			}
+			return c.Status(403).JSON(fiber.Map{
+				"error":              fmt.Sprintf("%s require verified ownership of the target domain", reason),
+				"unverified_domains": unverified,
+				"verify_with":        "/api/ownership/domains",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// matchEndpoint ignores case, since the services' routers do
+func matchEndpoint(path string) *scanEndpoint {
+	for i := range scanEndpoints {
+		for _, p := range scanEndpoints[i].paths {
+			if strings.EqualFold(p, path) {
+				return &scanEndpoints[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package ownership
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeBodyMatchesKeysLikeStructs(t *testing.T) {
+	body, err := decodeBody([]byte(`{"Target":"victim.com","SCAN_TYPE":"masscan_full","target":"other.com","tarGet":"last.com","ſimulate":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Domains(body, []string{"target"}); !reflect.DeepEqual(got, []string{"last.com"}) {
+		t.Errorf("domains = %v, want the last target key", got)
+	}
+	if !masscanFull(body) {
+		t.Error("SCAN_TYPE masscan_full is not aggressive")
+	}
+	// encoding/json folds ſ to s when matching struct fields
+	if simulate, _ := body["simulate"].(bool); !simulate {
+		t.Error("ſimulate was not read as simulate")
+	}
+
+	body, err = decodeBody([]byte(`{"URLS":["https://victim.com/a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Domains(body, []string{"urls"}); !reflect.DeepEqual(got, []string{"victim.com"}) {
+		t.Errorf("domains = %v", got)
+	}
+}
+
+func TestMatchEndpointIgnoresCase(t *testing.T) {
+	for _, path := range []string{"/api/scans", "/api/Scans", "/API/NETWORK/SCANS", "/api/WebScans/SQLMap"} {
+		if matchEndpoint(path) == nil {
+			t.Errorf("%s is not a scan endpoint", path)
+		}
+	}
+}
+
+func TestTemplateMakesScanAggressive(t *testing.T) {
+	body := map[string]interface{}{"target": "victim.com", "template_id": "8d0f1c9e-0000-0000-0000-000000000000"}
+	if masscanFull(body) {
+		t.Fatal("plain request is aggressive")
+	}
+
+	withTemplate(body, &scanTemplate{scanType: "masscan_full", scanner: "masscan"})
+	if !masscanFull(body) {
+		t.Error("masscan_full template is not aggressive")
+	}
+
+	ports := "1-5000"
+	body = map[string]interface{}{"target": "victim.com"}
+	withTemplate(body, &scanTemplate{scanType: "masscan_custom", scanner: "masscan", ports: &ports})
+	if !masscanFull(body) {
+		t.Error("template scanning 5000 ports is not aggressive")
+	}
+
+	ports = "22,80,443"
+	body = map[string]interface{}{"target": "victim.com"}
+	withTemplate(body, &scanTemplate{scanType: "masscan_custom", scanner: "masscan", ports: &ports})
+	if masscanFull(body) {
+		t.Error("template scanning 3 ports is aggressive")
+	}
+}
@@ -0,0 +1,178 @@
+package projects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+var (
+	// ErrProjectNotFound is returned for unknown projects
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrProjectExists is returned when creating a project twice
+	ErrProjectExists = errors.New("project already exists")
+	// ErrDefaultProject is returned when deleting the default project
+	ErrDefaultProject = errors.New("the default project cannot be deleted")
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS projects (
+    id VARCHAR(63) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO projects (id, name, description) VALUES ('default', 'Default', 'Scans created without a project')
+ON CONFLICT (id) DO NOTHING`
+
+// Project is a workspace of scans, templates, findings and assets, selected
+// with the X-Tenant-ID header
+type Project struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const projectColumns = `id, name, description, created_by, created_at`
+
+// Store keeps the projects
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates the projects table with the default project
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create projects table: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func scanProject(row pgx.Row) (*Project, error) {
+	var p Project
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedBy, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List returns every project
+func (s *Store) List(ctx context.Context) ([]*Project, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+projectColumns+` FROM projects ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// Get returns a project
+func (s *Store) Get(ctx context.Context, id string) (*Project, error) {
+	return scanProject(s.db.Pool.QueryRow(ctx, `SELECT `+projectColumns+` FROM projects WHERE id = $1`, strings.ToLower(id)))
+}
+
+// Exists reports whether a project exists
+func (s *Store) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := s.db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1)`, strings.ToLower(id)).Scan(&exists)
+	return exists, err
+}
+
+// Create adds a project; its ID must pass project.Valid
+func (s *Store) Create(ctx context.Context, p *Project) (*Project, error) {
+	p.ID = strings.ToLower(strings.TrimSpace(p.ID))
+	if err := project.Valid(p.ID); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		p.Name = p.ID
+	}
+	tag, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO projects (id, name, description, created_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+	`, p.ID, p.Name, p.Description, p.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrProjectExists
+	}
+	return s.Get(ctx, p.ID)
+}
+
+// Update changes the name and description of a project
+func (s *Store) Update(ctx context.Context, id, name, description string) (*Project, error) {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE projects SET name = COALESCE(NULLIF($2, ''), name), description = $3 WHERE id = $1
+	`, strings.ToLower(id), name, description)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrProjectNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a project. Its scans are kept and can still be listed with
+// ?project=<id>, but no new scan can be created in it.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	id = strings.ToLower(id)
+	if id == project.Default {
+		return ErrDefaultProject
+	}
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM projects WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// Middleware rejects requests for a project that doesn't exist, so that a
+// typo in X-Tenant-ID doesn't silently start a new workspace. Listing a
+// deleted project with ?project= stays possible.
+func (s *Store) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := strings.ToLower(strings.TrimSpace(c.Get(project.Header)))
+		if id == "" || id == project.Default {
+			return c.Next()
+		}
+		exists, err := s.Exists(c.Context(), id)
+		if err != nil {
+			log.Printf("Failed to check project %s: %v", id, err)
+			return c.Status(503).JSON(fiber.Map{"error": "Failed to check project"})
+		}
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{"error": "Unknown project", "project": id})
+		}
+		return c.Next()
+	}
+}
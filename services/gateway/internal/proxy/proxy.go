@@ -1,31 +1,56 @@
 package proxy
 
 import (
+	"bufio"
+	"errors"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/auth"
+	"github.com/security-scanner/shared/pkg/apierror"
+	"github.com/security-scanner/shared/pkg/pagination"
 )
 
+// InternalTokenHeader carries the token backend services require on their
+// API: an HS256 JWT with purpose "internal" whose data is "<METHOD> <path>"
+// of the proxied request, valid for a minute
+const InternalTokenHeader = "X-Internal-Token"
+
+const internalTokenTTL = time.Minute
+
 // ServiceProxy handles proxying requests to backend services
 type ServiceProxy struct {
 	client *http.Client
+	// stream serves event streams, which stay open as long as the scan
+	// they follow runs, so it has no overall timeout
+	stream *http.Client
+	signer *auth.TokenIssuer // nil when service-to-service auth is off
 }
 
-// NewServiceProxy creates a new proxy instance
-func NewServiceProxy() *ServiceProxy {
+// NewServiceProxy creates a new proxy instance. Requests are signed with
+// internalSecret when it is set.
+func NewServiceProxy(internalSecret string) *ServiceProxy {
+	var signer *auth.TokenIssuer
+	if internalSecret != "" {
+		signer = auth.NewTokenIssuer(internalSecret)
+	}
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
 	return &ServiceProxy{
+		signer: signer,
 		client: &http.Client{
-			Timeout: 5 * time.Minute, // Long timeout for scans
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Timeout:   5 * time.Minute, // Long timeout for scans
+			Transport: transport,
 		},
+		stream: &http.Client{Transport: transport},
 	}
 }
 
@@ -66,11 +91,34 @@ func (p *ServiceProxy) ProxyTo(targetBaseURL string, stripPrefix string) fiber.H
 		req.Header.Set("X-Forwarded-Host", c.Hostname())
 		req.Header.Set("X-Real-IP", c.IP())
 
+		// Only the gateway can vouch for a request
+		req.Header.Del(InternalTokenHeader)
+		if p.signer != nil {
+			token, err := p.signer.Sign(auth.Claims{
+				Subject: "gateway",
+				Purpose: "internal",
+				Data:    req.Method + " " + req.URL.Path,
+			}, internalTokenTTL)
+			if err != nil {
+				log.Printf("❌ Error signing proxy request: %v", err)
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to create proxy request"})
+			}
+			req.Header.Set(InternalTokenHeader, token)
+		}
+
 		// Execute request
-		resp, err := p.client.Do(req)
+		client := p.client
+		if strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream") {
+			client = p.stream
+		}
+		resp, err := client.Do(req)
 		if err != nil {
 			log.Printf("❌ Error proxying request: %v", err)
-			return c.Status(502).JSON(fiber.Map{"error": "Service unavailable", "details": err.Error()})
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return c.Status(504).JSON(apierror.New(apierror.CodeTimeout, "Service timed out", err.Error()))
+			}
+			return c.Status(502).JSON(apierror.New(apierror.CodeUnavailable, "Service unavailable", err.Error()))
 		}
 		defer resp.Body.Close()
 
@@ -80,6 +128,22 @@ func (p *ServiceProxy) ProxyTo(targetBaseURL string, stripPrefix string) fiber.H
 				c.Set(key, value)
 			}
 		}
+		// Page links point to the service's path, not the gateway's
+		if link := resp.Header.Get(pagination.HeaderLink); link != "" && resp.Header.Get(pagination.HeaderTotalCount) != "" {
+			c.Set(pagination.HeaderLink, pagination.RebaseLink(link, c.Path()))
+		}
+
+		// Event streams are relayed as they arrive
+		if strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/event-stream") {
+			c.Status(resp.StatusCode)
+			body := resp.Body
+			resp.Body = http.NoBody // closed by the stream writer instead
+			c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+				defer body.Close()
+				relayStream(w, body)
+			})
+			return nil
+		}
 
 		// Read response body
 		body, err := io.ReadAll(resp.Body)
@@ -91,3 +155,23 @@ func (p *ServiceProxy) ProxyTo(targetBaseURL string, stripPrefix string) fiber.H
 		return c.Status(resp.StatusCode).Send(body)
 	}
 }
+
+// relayStream copies an event stream to the client, flushing after every
+// read, until either side closes it
+func relayStream(w *bufio.Writer, body io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if werr := w.Flush(); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package scoperules keeps the allow and deny rules scan targets are checked
+// against (scope_rules); the services read the table directly, so changes
+// apply to the next scan.
+package scoperules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/scope"
+)
+
+var (
+	// ErrRuleNotFound is returned for unknown rules
+	ErrRuleNotFound = errors.New("scope rule not found")
+	// ErrRuleExists is returned for a rule already defined for the project
+	ErrRuleExists = errors.New("this scope rule already exists")
+)
+
+// Store keeps the scope rules
+type Store struct {
+	db      *database.Database
+	checker *scope.Checker
+}
+
+// NewStore creates the scope rule table
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), scope.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create scope rule table: %w", err)
+	}
+	checker := scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	})
+	return &Store{db: db, checker: checker}, nil
+}
+
+func scanRule(row pgx.Row) (*scope.Rule, error) {
+	r, err := scope.Scan(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRuleNotFound
+	}
+	return r, err
+}
+
+// List returns the rules of a project and those of every project, or every
+// rule when project is empty
+func (s *Store) List(ctx context.Context, project string) ([]*scope.Rule, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+scope.Columns+` FROM scope_rules
+		WHERE $1 = '' OR project_id = '' OR project_id = $1
+		ORDER BY project_id, action DESC, value
+	`, project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*scope.Rule{}
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// Create adds a rule; it must pass scope.Validate
+func (s *Store) Create(ctx context.Context, r *scope.Rule) (*scope.Rule, error) {
+	if err := scope.Validate(r); err != nil {
+		return nil, err
+	}
+	r.ID = uuid.New()
+	created, err := scanRule(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO scope_rules (id, action, value, project_id, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (project_id, action, value) DO NOTHING
+		RETURNING `+scope.Columns,
+		r.ID, r.Action, r.Value, r.ProjectID, r.Description, r.CreatedBy))
+	if errors.Is(err, ErrRuleNotFound) {
+		return nil, ErrRuleExists
+	}
+	return created, err
+}
+
+// Delete removes a rule
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM scope_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// Check checks targets against the rules of a project as the services do
+// when creating a scan
+func (s *Store) Check(ctx context.Context, project string, targets ...string) error {
+	return s.checker.Check(ctx, project, targets...)
+}
@@ -0,0 +1,205 @@
+package searches
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/shared/pkg/client"
+	"github.com/security-scanner/shared/pkg/models"
+)
+
+// ErrNoDatabase is returned for queries on tags or status without a database
+var ErrNoDatabase = errors.New("tag and status conditions require DATABASE_URL")
+
+// Fetch lists the findings of sources matching query, merged most severe
+// first and newest first within a severity, with the total the services
+// matched. A service that doesn't answer is listed in errors.
+func Fetch(ctx context.Context, sources map[string]*client.Findings, query url.Values) ([]models.Finding, int, map[string]string) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	findings := []models.Finding{}
+	total := 0
+	errors := map[string]string{}
+	for name, source := range sources {
+		wg.Add(1)
+		go func(name string, source *client.Findings) {
+			defer wg.Done()
+			list, err := source.List(ctx, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errors[name] = err.Error()
+				return
+			}
+			for _, finding := range list.Findings {
+				finding.Source = name
+				findings = append(findings, finding)
+			}
+			total += list.Total
+		}(name, source)
+	}
+	wg.Wait()
+
+	models.SortFindings(findings)
+	return findings, total, errors
+}
+
+// Collector runs queries against the findings of the services, reading the
+// tags of assets (asset_tags, network service) and the status of nuclei
+// findings (vulnerabilities, web service) from the shared database
+type Collector struct {
+	sources map[string]*client.Findings // by the Source of their findings
+	db      *database.Database          // nil without a database
+}
+
+func NewCollector(sources map[string]*client.Findings, db *database.Database) *Collector {
+	return &Collector{sources: sources, db: db}
+}
+
+// SourceNames returns the sources findings come from
+func (c *Collector) SourceNames() []string {
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run returns the findings of the project scope (empty for every project)
+// matching q. Each service contributes at most models.MaxFindingLimit
+// findings before the gateway's conditions apply.
+func (c *Collector) Run(ctx context.Context, q *Query, scope string) ([]Result, map[string]string, error) {
+	if (q.Needs("tag") || q.Needs("status")) && c.db == nil {
+		return nil, nil, ErrNoDatabase
+	}
+	results := []Result{}
+	if q.Needs("severity") && len(q.Severities()) == 0 {
+		return results, map[string]string{}, nil
+	}
+
+	sources := map[string]*client.Findings{}
+	for _, name := range q.Sources(c.SourceNames()) {
+		sources[name] = c.sources[name]
+	}
+	query := q.Pushdown()
+	query.Set("limit", strconv.Itoa(models.MaxFindingLimit))
+	if scope != "" {
+		query.Set("project", scope)
+	}
+	findings, _, errs := Fetch(ctx, sources, query)
+
+	// Without a database no condition needs them, and every finding is open
+	var tags map[string][]string
+	var statuses map[uuid.UUID]string
+	if c.db != nil {
+		var err error
+		if tags, err = c.assetTags(ctx, findings); err != nil {
+			return nil, nil, err
+		}
+		if statuses, err = c.statuses(ctx, findings); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range findings {
+		r := Result{Finding: f, Tags: tags[hostOf(f.Target)], Status: "open"}
+		if r.Tags == nil {
+			r.Tags = []string{}
+		}
+		if status, ok := statuses[f.ID]; ok {
+			r.Status = status
+		}
+		if q.Match(r) {
+			results = append(results, r)
+		}
+	}
+	return results, errs, nil
+}
+
+// hostOf returns the asset of a finding's target: the host of a URL or of a
+// host:port/protocol target
+func hostOf(target string) string {
+	target = strings.ToLower(strings.TrimSpace(target))
+	if _, rest, ok := strings.Cut(target, "://"); ok {
+		target = rest
+	}
+	target, _, _ = strings.Cut(target, "/")
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		target = h
+	}
+	return strings.TrimSuffix(strings.Trim(target, "[]"), ".")
+}
+
+// assetTags returns the tags of the assets of findings
+func (c *Collector) assetTags(ctx context.Context, findings []models.Finding) (map[string][]string, error) {
+	seen := map[string]bool{}
+	var hosts []string
+	for _, f := range findings {
+		if host := hostOf(f.Target); host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	tags := map[string][]string{}
+	if len(hosts) == 0 {
+		return tags, nil
+	}
+
+	rows, err := c.db.Pool.Query(ctx, `
+		SELECT asset, array_agg(DISTINCT LOWER(tag)) FROM asset_tags
+		WHERE asset = ANY($1) GROUP BY asset
+	`, hosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var asset string
+		var list []string
+		if err := rows.Scan(&asset, &list); err != nil {
+			return nil, err
+		}
+		tags[asset] = list
+	}
+	return tags, rows.Err()
+}
+
+// statuses returns the remediation status of the nuclei findings; the other
+// tools don't track one, so their findings are always open
+func (c *Collector) statuses(ctx context.Context, findings []models.Finding) (map[uuid.UUID]string, error) {
+	var ids []uuid.UUID
+	for _, f := range findings {
+		if f.Source == "vulnerabilities" {
+			ids = append(ids, f.ID)
+		}
+	}
+	statuses := map[uuid.UUID]string{}
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+
+	rows, err := c.db.Pool.Query(ctx, `SELECT id, status FROM vulnerabilities WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, err
+		}
+		statuses[id] = status
+	}
+	return statuses, rows.Err()
+}
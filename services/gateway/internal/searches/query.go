@@ -0,0 +1,321 @@
+// Package searches filters the findings of every service with queries such
+// as "severity>=high AND tag=prod AND status=open", and keeps them as named
+// saved searches that can be re-run and notify when new findings match.
+package searches
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/models"
+)
+
+// Operators of a condition
+const (
+	OpEqual    = "="
+	OpNotEqual = "!="
+	OpContains = "~"
+	OpAtLeast  = ">="
+	OpAtMost   = "<="
+	OpAbove    = ">"
+	OpBelow    = "<"
+)
+
+// maxQueryLen bounds the length of a query
+const maxQueryLen = 2000
+
+// operators are tried longest first, so that ">=" isn't read as ">"
+var operators = []string{OpAtLeast, OpAtMost, OpNotEqual, OpEqual, OpContains, OpAbove, OpBelow}
+
+// fieldOps are the fields of a query and the operators each accepts
+var fieldOps = map[string][]string{
+	"severity":   {OpEqual, OpNotEqual, OpAtLeast, OpAtMost, OpAbove, OpBelow},
+	"source":     {OpEqual, OpNotEqual},
+	"tool":       {OpEqual, OpNotEqual},
+	"scan_id":    {OpEqual, OpNotEqual},
+	"status":     {OpEqual, OpNotEqual},
+	"tag":        {OpEqual, OpNotEqual},
+	"target":     {OpEqual, OpNotEqual, OpContains},
+	"title":      {OpEqual, OpNotEqual, OpContains},
+	"identifier": {OpEqual, OpNotEqual, OpContains},
+}
+
+// Condition is one clause of a query, e.g. severity>=high. = and != accept
+// comma-separated values (severity=critical,high), matching any of them.
+type Condition struct {
+	Field  string   `json:"field"`
+	Op     string   `json:"op"`
+	Values []string `json:"values"`
+}
+
+// Query is a list of conditions that must all match
+type Query struct {
+	Conditions []Condition `json:"conditions"`
+}
+
+// Parse reads a query: conditions joined by AND (case-insensitive), values
+// optionally double-quoted. An empty query matches every finding.
+func Parse(raw string) (*Query, error) {
+	if len(raw) > maxQueryLen {
+		return nil, fmt.Errorf("query is longer than %d characters", maxQueryLen)
+	}
+	q := &Query{Conditions: []Condition{}}
+	for _, clause := range splitAnd(raw) {
+		if clause = strings.TrimSpace(clause); clause == "" {
+			return nil, fmt.Errorf("empty condition in query")
+		}
+		c, err := parseCondition(clause)
+		if err != nil {
+			return nil, err
+		}
+		q.Conditions = append(q.Conditions, c)
+	}
+	return q, nil
+}
+
+// splitAnd splits raw on the AND keyword outside double quotes
+func splitAnd(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var clauses []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '"':
+			quoted = !quoted
+		case !quoted && i+5 <= len(raw) && isSpace(raw[i]) && strings.EqualFold(raw[i+1:i+4], "AND") && isSpace(raw[i+4]):
+			clauses = append(clauses, raw[start:i])
+			start = i + 5
+			i += 4
+		}
+	}
+	return append(clauses, raw[start:])
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func parseCondition(clause string) (Condition, error) {
+	end := 0
+	for end < len(clause) && (clause[end] == '_' || (clause[end] >= 'a' && clause[end] <= 'z') || (clause[end] >= 'A' && clause[end] <= 'Z')) {
+		end++
+	}
+	c := Condition{Field: strings.ToLower(clause[:end])}
+	allowed, ok := fieldOps[c.Field]
+	if !ok {
+		return c, fmt.Errorf("unknown field in %q: expected one of severity, source, tool, scan_id, status, tag, target, title, identifier", clause)
+	}
+
+	rest := strings.TrimSpace(clause[end:])
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			c.Op = op
+			break
+		}
+	}
+	if c.Op == "" || !contains(allowed, c.Op) {
+		return c, fmt.Errorf("invalid operator in %q: %s accepts %s", clause, c.Field, strings.Join(allowed, " "))
+	}
+
+	value := strings.TrimSpace(rest[len(c.Op):])
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		c.Values = []string{value[1 : len(value)-1]}
+	} else if strings.Contains(value, `"`) {
+		return c, fmt.Errorf("unbalanced quotes in %q", clause)
+	} else if c.Op == OpEqual || c.Op == OpNotEqual {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, v)
+			}
+		}
+	} else if value != "" {
+		c.Values = []string{value}
+	}
+	if len(c.Values) == 0 {
+		return c, fmt.Errorf("missing value in %q", clause)
+	}
+
+	for i, v := range c.Values {
+		switch c.Field {
+		case "severity":
+			v = strings.ToLower(v)
+			if models.SeverityRank(v) == len(models.Severities) {
+				return c, fmt.Errorf("invalid severity %q: expected one of %s", v, strings.Join(models.Severities, ", "))
+			}
+		case "scan_id":
+			if _, err := uuid.Parse(v); err != nil {
+				return c, fmt.Errorf("invalid scan_id %q", v)
+			}
+			v = strings.ToLower(v)
+		case "target", "title", "identifier":
+		default:
+			v = strings.ToLower(v)
+		}
+		c.Values[i] = v
+	}
+	return c, nil
+}
+
+// String returns the canonical form of the query
+func (q *Query) String() string {
+	clauses := make([]string, 0, len(q.Conditions))
+	for _, c := range q.Conditions {
+		value := strings.Join(c.Values, ",")
+		if len(c.Values) == 1 && strings.ContainsAny(value, " \t,") {
+			value = `"` + value + `"`
+		}
+		clauses = append(clauses, c.Field+c.Op+value)
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// Needs reports whether the query uses field
+func (q *Query) Needs(field string) bool {
+	for _, c := range q.Conditions {
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Severities returns the severities the query can match, most severe
+// first, for the services to filter on
+func (q *Query) Severities() []string {
+	var severities []string
+	for _, s := range models.Severities {
+		if q.matchAll("severity", s) {
+			severities = append(severities, s)
+		}
+	}
+	return severities
+}
+
+// Sources returns the sources the query can match among names
+func (q *Query) Sources(names []string) []string {
+	var sources []string
+	for _, name := range names {
+		if q.matchAll("source", name) {
+			sources = append(sources, name)
+		}
+	}
+	return sources
+}
+
+// Pushdown returns the filters of the query the services apply themselves
+// (?severity=, ?scan_id=); the rest is matched by the gateway
+func (q *Query) Pushdown() url.Values {
+	query := url.Values{}
+	if q.Needs("severity") {
+		query.Set("severity", strings.Join(q.Severities(), ","))
+	}
+	for _, c := range q.Conditions {
+		if c.Field == "scan_id" && c.Op == OpEqual && len(c.Values) == 1 {
+			query.Set("scan_id", c.Values[0])
+		}
+	}
+	return query
+}
+
+// Result is a finding with the tags of its asset and its remediation status
+type Result struct {
+	models.Finding
+	Tags   []string `json:"tags"`
+	Status string   `json:"status"`
+}
+
+// Match reports whether every condition matches r
+func (q *Query) Match(r Result) bool {
+	for _, c := range q.Conditions {
+		if !c.match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAll reports whether every condition on field accepts value
+func (q *Query) matchAll(field, value string) bool {
+	for _, c := range q.Conditions {
+		if c.Field == field && !c.matchValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) match(r Result) bool {
+	switch c.Field {
+	case "severity":
+		return c.matchValue(r.Severity)
+	case "source":
+		return c.matchValue(r.Source)
+	case "tool":
+		return c.matchValue(strings.ToLower(r.Tool))
+	case "scan_id":
+		return c.matchValue(r.ScanID.String())
+	case "status":
+		return c.matchValue(r.Status)
+	case "target":
+		return c.matchValue(r.Target)
+	case "title":
+		return c.matchValue(r.Title)
+	case "identifier":
+		return c.matchValue(r.Identifier)
+	case "tag":
+		// tag=prod: any tag is prod; tag!=prod: no tag is prod
+		for _, tag := range r.Tags {
+			if contains(c.Values, tag) {
+				return c.Op == OpEqual
+			}
+		}
+		return c.Op == OpNotEqual
+	}
+	return false
+}
+
+// matchValue applies the condition to one value; text is compared
+// case-insensitively
+func (c Condition) matchValue(value string) bool {
+	switch c.Op {
+	case OpEqual, OpNotEqual:
+		found := false
+		for _, v := range c.Values {
+			if strings.EqualFold(v, value) {
+				found = true
+				break
+			}
+		}
+		return found == (c.Op == OpEqual)
+	case OpContains:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(c.Values[0]))
+	}
+
+	// Severity comparisons: a lower rank is more severe
+	rank, bound := models.SeverityRank(value), models.SeverityRank(c.Values[0])
+	switch c.Op {
+	case OpAtLeast:
+		return rank <= bound
+	case OpAtMost:
+		return rank >= bound
+	case OpAbove:
+		return rank < bound
+	case OpBelow:
+		return rank > bound
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package searches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+)
+
+var (
+	// ErrSearchNotFound is returned for unknown saved searches
+	ErrSearchNotFound = errors.New("saved search not found")
+	// ErrSearchExists is returned for a name already taken in the project
+	ErrSearchExists = errors.New("a saved search with this name already exists")
+)
+
+// schemaSQL creates the saved searches and the findings each matched, so
+// that only new matches are notified
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL,
+    project_id VARCHAR(63) NOT NULL DEFAULT '',
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    last_checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, name)
+);
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    finding_id UUID NOT NULL,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (search_id, finding_id)
+)`
+
+// Search is a named query over the findings of a project
+type Search struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Query         string     `json:"query"`
+	ProjectID     string     `json:"project_id"` // empty for every project
+	Notify        bool       `json:"notify"`     // alert when new findings match
+	CreatedBy     string     `json:"created_by,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+const searchColumns = `id, name, description, query, project_id, notify, created_by, last_checked_at, created_at, updated_at`
+
+// Validate normalizes a search and checks its name and query, which is
+// stored in its canonical form
+func Validate(s *Search) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	q, err := Parse(s.Query)
+	if err != nil {
+		return err
+	}
+	s.Query = q.String()
+	return nil
+}
+
+// Store keeps the saved searches
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates the saved search tables
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create saved search tables: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func scanSearch(row pgx.Row) (*Search, error) {
+	var s Search
+	err := row.Scan(&s.ID, &s.Name, &s.Description, &s.Query, &s.ProjectID, &s.Notify, &s.CreatedBy, &s.LastCheckedAt, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSearchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Store) list(ctx context.Context, where string, args ...interface{}) ([]*Search, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+searchColumns+` FROM saved_searches `+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []*Search{}
+	for rows.Next() {
+		search, err := scanSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+// List returns the searches of a project, or every search when project is
+// empty
+func (s *Store) List(ctx context.Context, project string) ([]*Search, error) {
+	if project == "" {
+		return s.list(ctx, "")
+	}
+	return s.list(ctx, "WHERE project_id = $1", project)
+}
+
+// Notifying returns the searches that notify about new matches
+func (s *Store) Notifying(ctx context.Context) ([]*Search, error) {
+	return s.list(ctx, "WHERE notify")
+}
+
+// Get returns a search
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Search, error) {
+	return scanSearch(s.db.Pool.QueryRow(ctx, `SELECT `+searchColumns+` FROM saved_searches WHERE id = $1`, id))
+}
+
+// nameTaken reports whether another search than id of the project has name
+func (s *Store) nameTaken(ctx context.Context, project, name string, id uuid.UUID) (bool, error) {
+	var taken bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM saved_searches WHERE project_id = $1 AND name = $2 AND id <> $3)
+	`, project, name, id).Scan(&taken)
+	return taken, err
+}
+
+// Create adds a search; it must pass Validate
+func (s *Store) Create(ctx context.Context, search *Search) (*Search, error) {
+	if err := Validate(search); err != nil {
+		return nil, err
+	}
+	search.ID = uuid.New()
+	if taken, err := s.nameTaken(ctx, search.ProjectID, search.Name, search.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrSearchExists
+	}
+	return scanSearch(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO saved_searches (id, name, description, query, project_id, notify, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+searchColumns,
+		search.ID, search.Name, search.Description, search.Query, search.ProjectID, search.Notify, search.CreatedBy))
+}
+
+// Update replaces the name, description, query and notify flag of a search;
+// it must pass Validate. A new query, or turning notifications on, forgets
+// the findings matched so far, so that the next check doesn't notify about
+// every existing finding.
+func (s *Store) Update(ctx context.Context, id uuid.UUID, search *Search) (*Search, error) {
+	if err := Validate(search); err != nil {
+		return nil, err
+	}
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if taken, err := s.nameTaken(ctx, current.ProjectID, search.Name, id); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrSearchExists
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	if search.Query != current.Query || (search.Notify && !current.Notify) {
+		if _, err := tx.Exec(ctx, `DELETE FROM saved_search_matches WHERE search_id = $1`, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE saved_searches SET last_checked_at = NULL WHERE id = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+	updated, err := scanSearch(tx.QueryRow(ctx, `
+		UPDATE saved_searches SET name = $2, description = $3, query = $4, notify = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+searchColumns,
+		id, search.Name, search.Description, search.Query, search.Notify))
+	if err != nil {
+		return nil, err
+	}
+	return updated, tx.Commit(ctx)
+}
+
+// Delete removes a search and its matches
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM saved_searches WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSearchNotFound
+	}
+	return nil
+}
+
+// Record stores the findings a check of a search matched and returns those
+// it never matched before
+func (s *Store) Record(ctx context.Context, id uuid.UUID, findings []uuid.UUID) ([]uuid.UUID, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO saved_search_matches (search_id, finding_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT DO NOTHING
+		RETURNING finding_id
+	`, id, findings)
+	if err != nil {
+		return nil, err
+	}
+	added := []uuid.UUID{}
+	for rows.Next() {
+		var finding uuid.UUID
+		if err := rows.Scan(&finding); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		added = append(added, finding)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE saved_searches SET last_checked_at = NOW() WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return added, tx.Commit(ctx)
+}
@@ -0,0 +1,182 @@
+package searches
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/chat"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+// notifyListLimit caps the findings listed in one notification; the counts
+// still cover all of them
+const notifyListLimit = 50
+
+// Watcher re-runs the saved searches that notify, and posts the findings
+// each matched for the first time to the webhook and the chat channels. The
+// first check of a search only records what it matches.
+type Watcher struct {
+	store      *Store
+	collector  *Collector
+	webhookURL string
+	secret     string // signs the body as X-Scanner-Signature: sha256=<hmac>
+	client     *http.Client
+	chat       *chat.Notifier
+	leases     *shareddb.Leases
+}
+
+// NewWatcher returns a watcher posting to webhookURL, if any
+func NewWatcher(store *Store, collector *Collector, webhookURL, secret string) *Watcher {
+	return &Watcher{
+		store:      store,
+		collector:  collector,
+		webhookURL: webhookURL,
+		secret:     secret,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SetChat posts new matches to Slack and Discord channels as well
+func (w *Watcher) SetChat(n *chat.Notifier) {
+	w.chat = n
+}
+
+// SetLeases makes Start check searches only on the replica holding the
+// job's lease
+func (w *Watcher) SetLeases(leases *shareddb.Leases) {
+	w.leases = leases
+}
+
+// Start checks the searches every 5 minutes until ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if w.leases.Acquire(ctx, "gateway:saved-searches", 10*time.Minute) {
+			w.checkAll(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) checkAll(ctx context.Context) {
+	list, err := w.store.Notifying(ctx)
+	if err != nil {
+		log.Printf("⚠️ Failed to list saved searches: %v", err)
+		return
+	}
+	for _, search := range list {
+		if err := w.check(ctx, search); err != nil {
+			log.Printf("⚠️ Failed to check saved search %s: %v", search.ID, err)
+		}
+	}
+}
+
+// check runs a search and notifies about the findings it never matched
+func (w *Watcher) check(ctx context.Context, search *Search) error {
+	q, err := Parse(search.Query)
+	if err != nil {
+		return err
+	}
+	runCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	results, errs, err := w.collector.Run(runCtx, q, search.ProjectID)
+	cancel()
+	if err != nil {
+		return err
+	}
+	for source, msg := range errs {
+		log.Printf("⚠️ Saved search %s: %s didn't answer: %s", search.ID, source, msg)
+	}
+
+	ids := make([]uuid.UUID, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	added, err := w.store.Record(ctx, search.ID, ids)
+	if err != nil {
+		return err
+	}
+	if search.LastCheckedAt == nil || len(added) == 0 {
+		return nil
+	}
+
+	isNew := map[uuid.UUID]bool{}
+	for _, id := range added {
+		isNew[id] = true
+	}
+	matches := []Result{}
+	counts := map[string]int{}
+	for _, r := range results {
+		if isNew[r.ID] {
+			counts[r.Severity]++
+			if len(matches) < notifyListLimit {
+				matches = append(matches, r)
+			}
+		}
+	}
+	log.Printf("🔎 Saved search %q matched %d new findings", search.Name, len(added))
+
+	w.chat.Notify(chat.Summary{
+		Service:  "gateway",
+		Tool:     "saved-search",
+		ScanID:   search.ID.String(),
+		ScanName: search.Name,
+		Target:   search.Query,
+		Counts:   counts,
+	})
+	if w.webhookURL == "" {
+		return nil
+	}
+	return w.post(ctx, map[string]interface{}{
+		"type":        "saved_search",
+		"time":        time.Now().UTC(),
+		"search":      search,
+		"new_matches": len(added),
+		"by_severity": counts,
+		"findings":    matches,
+	})
+}
+
+func (w *Watcher) post(ctx context.Context, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(payload)
+		req.Header.Set("X-Scanner-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
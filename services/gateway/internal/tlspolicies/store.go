@@ -0,0 +1,96 @@
+// Package tlspolicies keeps the TLS policies testssl.sh results are checked
+// against (tls_policies); the web service reads the table directly, so
+// changes apply to the next scan.
+package tlspolicies
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/gateway/internal/database"
+	"github.com/security-scanner/shared/pkg/tlspolicy"
+)
+
+// ErrPolicyNotFound is returned for projects without a policy
+var ErrPolicyNotFound = errors.New("TLS policy not found")
+
+// Store keeps the TLS policies
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates the TLS policy table
+func NewStore(db *database.Database) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), tlspolicy.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create TLS policy table: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func scanPolicy(row pgx.Row) (*tlspolicy.Policy, error) {
+	p, err := tlspolicy.Scan(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPolicyNotFound
+	}
+	return p, err
+}
+
+// List returns every policy, the one of every project first
+func (s *Store) List(ctx context.Context) ([]*tlspolicy.Policy, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT `+tlspolicy.Columns+` FROM tls_policies ORDER BY project_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*tlspolicy.Policy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// Get returns the policy of a project, "" for the one of every project
+func (s *Store) Get(ctx context.Context, project string) (*tlspolicy.Policy, error) {
+	return scanPolicy(s.db.Pool.QueryRow(ctx,
+		`SELECT `+tlspolicy.Columns+` FROM tls_policies WHERE project_id = $1`, project))
+}
+
+// Put creates or replaces the policy of p.ProjectID; it must pass
+// tlspolicy.Validate
+func (s *Store) Put(ctx context.Context, p *tlspolicy.Policy) (*tlspolicy.Policy, error) {
+	if err := tlspolicy.Validate(p); err != nil {
+		return nil, err
+	}
+	ciphers, _ := json.Marshal(p.BannedCiphers)
+	return scanPolicy(s.db.Pool.QueryRow(ctx, `
+		INSERT INTO tls_policies (project_id, min_protocol, banned_ciphers, min_rsa_bits, min_ecc_bits,
+			max_cert_days, description, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (project_id) DO UPDATE SET
+			min_protocol = EXCLUDED.min_protocol, banned_ciphers = EXCLUDED.banned_ciphers,
+			min_rsa_bits = EXCLUDED.min_rsa_bits, min_ecc_bits = EXCLUDED.min_ecc_bits,
+			max_cert_days = EXCLUDED.max_cert_days, description = EXCLUDED.description,
+			updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING `+tlspolicy.Columns,
+		p.ProjectID, p.MinProtocol, ciphers, p.MinRSABits, p.MinECCBits, p.MaxCertDays, p.Description, p.UpdatedBy))
+}
+
+// Delete removes the policy of a project, "" for the one of every project
+func (s *Store) Delete(ctx context.Context, project string) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM tls_policies WHERE project_id = $1`, project)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
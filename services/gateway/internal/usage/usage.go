@@ -0,0 +1,378 @@
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/gateway/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS api_usage_daily (
+    day DATE NOT NULL,
+    tenant VARCHAR(100) NOT NULL,
+    api_key VARCHAR(100) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    scans_created BIGINT NOT NULL DEFAULT 0,
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, tenant, api_key)
+);
+CREATE TABLE IF NOT EXISTS api_usage_monthly (
+    month DATE NOT NULL,
+    tenant VARCHAR(100) NOT NULL,
+    api_key VARCHAR(100) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    scans_created BIGINT NOT NULL DEFAULT 0,
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (month, tenant, api_key)
+)`
+
+// scanCollections are the proxied collections where a successful POST creates a scan
+var scanCollections = []string{
+	"/api/scans", "/api/network/scans", "/api/vulnerabilities", "/api/web/vulnerabilities",
+	"/api/webscans", "/api/web/fuzzing", "/api/web/screenshots", "/api/web/ssl",
+	"/api/recon", "/api/apiscans", "/api/cmsscans", "/api/cloudscans",
+}
+
+type counterKey struct {
+	day    string
+	tenant string
+	apiKey string
+}
+
+// Counters are the usage figures of one key
+type Counters struct {
+	Requests     int64 `json:"requests"`
+	Errors       int64 `json:"errors"`
+	ScansCreated int64 `json:"scans_created"`
+	BytesIn      int64 `json:"bytes_in"`
+	BytesOut     int64 `json:"bytes_out"`
+}
+
+// Tracker counts requests per tenant and API key in memory and flushes the
+// counts to daily rows; monthly rollups are rebuilt from the daily rows
+type Tracker struct {
+	db            *database.Database
+	retentionDays int
+	leases        *shareddb.Leases
+
+	mu      sync.Mutex
+	pending map[counterKey]*Counters
+}
+
+// NewTracker creates the usage tables. Daily rows older than retentionDays
+// are deleted once rolled up (at least 62 days are kept so the previous
+// month can still be rebuilt).
+func NewTracker(db *database.Database, retentionDays int) (*Tracker, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create usage tables: %w", err)
+	}
+	if retentionDays < 62 {
+		retentionDays = 62
+	}
+	return &Tracker{db: db, retentionDays: retentionDays, pending: map[counterKey]*Counters{}}, nil
+}
+
+// KeyFor identifies the caller: the authenticated user, or a hash of the
+// API key or bearer token, or "anonymous"
+func KeyFor(c *fiber.Ctx) string {
+	if userID := c.Get("X-User-ID"); userID != "" {
+		return "user:" + userID
+	}
+	if key := c.Get("X-API-Key"); key != "" {
+		return "key:" + shortHash(key)
+	}
+	if header := c.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return "token:" + shortHash(strings.TrimPrefix(header, "Bearer "))
+	}
+	return "anonymous"
+}
+
+// shortHash identifies a secret without storing it
+func shortHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Middleware counts every request once the response is ready
+func (t *Tracker) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		key := counterKey{
+			day:    time.Now().UTC().Format("2006-01-02"),
+			tenant: c.Get("X-Tenant-ID", "default"),
+			apiKey: KeyFor(c),
+		}
+		t.mu.Lock()
+		counts, ok := t.pending[key]
+		if !ok {
+			counts = &Counters{}
+			t.pending[key] = counts
+		}
+		counts.Requests++
+		if status >= 400 {
+			counts.Errors++
+		}
+		if c.Method() == fiber.MethodPost && status >= 200 && status < 300 && CreatesScan(c.Path()) {
+			counts.ScansCreated++
+		}
+		counts.BytesIn += int64(len(c.Request().Body()))
+		// Reading a streamed body would wait for the stream to end
+		if !c.Response().IsBodyStream() {
+			counts.BytesOut += int64(len(c.Response().Body()))
+		}
+		t.mu.Unlock()
+		return err
+	}
+}
+
+// CreatesScan reports whether path is a scan collection or one of its
+// tool-specific create endpoints (e.g. /api/webscans/testssl). Estimates
+// run nothing and don't count. Case is ignored, like the services' routers do.
+func CreatesScan(path string) bool {
+	path = strings.ToLower(strings.TrimSuffix(path, "/"))
+	for _, collection := range scanCollections {
+		if path == collection {
+			return true
+		}
+		if rest, ok := strings.CutPrefix(path, collection+"/"); ok && !strings.Contains(rest, "/") && !looksLikeID(rest) && rest != "estimate" {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeID matches UUIDs and numeric IDs, i.e. POSTs to an existing scan
+func looksLikeID(segment string) bool {
+	if len(segment) == 36 && strings.Count(segment, "-") == 4 {
+		return true
+	}
+	return strings.Trim(segment, "0123456789") == ""
+}
+
+// SetLeases makes the monthly rollup and retention run only on the replica
+// holding the job's lease; every replica still flushes its own counts
+func (t *Tracker) SetLeases(leases *shareddb.Leases) {
+	t.leases = leases
+}
+
+// Start flushes counts every 30 seconds and rebuilds the monthly rollups
+// every hour until ctx is cancelled
+func (t *Tracker) Start(ctx context.Context) {
+	flush := time.NewTicker(30 * time.Second)
+	defer flush.Stop()
+	rollup := time.NewTicker(time.Hour)
+	defer rollup.Stop()
+
+	t.rollupLeased(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			t.Flush(context.Background())
+			return
+		case <-flush.C:
+			t.Flush(ctx)
+		case <-rollup.C:
+			t.rollupLeased(ctx)
+		}
+	}
+}
+
+// Flush writes the pending counts; counts that fail to write are kept for the next flush
+func (t *Tracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = map[counterKey]*Counters{}
+	t.mu.Unlock()
+
+	for key, counts := range pending {
+		_, err := t.db.Pool.Exec(ctx, `
+			INSERT INTO api_usage_daily (day, tenant, api_key, requests, errors, scans_created, bytes_in, bytes_out)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (day, tenant, api_key) DO UPDATE SET
+				requests = api_usage_daily.requests + EXCLUDED.requests,
+				errors = api_usage_daily.errors + EXCLUDED.errors,
+				scans_created = api_usage_daily.scans_created + EXCLUDED.scans_created,
+				bytes_in = api_usage_daily.bytes_in + EXCLUDED.bytes_in,
+				bytes_out = api_usage_daily.bytes_out + EXCLUDED.bytes_out
+		`, key.day, key.tenant, key.apiKey, counts.Requests, counts.Errors, counts.ScansCreated, counts.BytesIn, counts.BytesOut)
+		if err != nil {
+			log.Printf("⚠️ Failed to flush API usage: %v", err)
+			t.mu.Lock()
+			if current, ok := t.pending[key]; ok {
+				current.Requests += counts.Requests
+				current.Errors += counts.Errors
+				current.ScansCreated += counts.ScansCreated
+				current.BytesIn += counts.BytesIn
+				current.BytesOut += counts.BytesOut
+			} else {
+				t.pending[key] = counts
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// rollupLeased rolls up on the replica holding the job's lease
+func (t *Tracker) rollupLeased(ctx context.Context) {
+	if t.leases.Acquire(ctx, "gateway:usage-rollup", 3*time.Hour) {
+		t.rollup(ctx)
+	}
+}
+
+// rollup rebuilds the current and previous month from the daily rows and
+// drops daily rows past retention
+func (t *Tracker) rollup(ctx context.Context) {
+	_, err := t.db.Pool.Exec(ctx, `
+		INSERT INTO api_usage_monthly (month, tenant, api_key, requests, errors, scans_created, bytes_in, bytes_out, updated_at)
+		SELECT date_trunc('month', day)::date, tenant, api_key,
+		       SUM(requests), SUM(errors), SUM(scans_created), SUM(bytes_in), SUM(bytes_out), NOW()
+		FROM api_usage_daily
+		WHERE day >= (date_trunc('month', CURRENT_DATE) - INTERVAL '1 month')::date
+		GROUP BY 1, 2, 3
+		ON CONFLICT (month, tenant, api_key) DO UPDATE SET
+			requests = EXCLUDED.requests,
+			errors = EXCLUDED.errors,
+			scans_created = EXCLUDED.scans_created,
+			bytes_in = EXCLUDED.bytes_in,
+			bytes_out = EXCLUDED.bytes_out,
+			updated_at = NOW()
+	`)
+	if err != nil {
+		log.Printf("⚠️ Failed to roll up API usage: %v", err)
+		return
+	}
+	t.db.Pool.Exec(ctx, `DELETE FROM api_usage_daily WHERE day < CURRENT_DATE - $1::int`, t.retentionDays)
+}
+
+// Row is the usage of one key in one day or month
+type Row struct {
+	Period string `json:"period"` // YYYY-MM-DD or YYYY-MM
+	Tenant string `json:"tenant"`
+	APIKey string `json:"api_key"`
+	Counters
+}
+
+// Report returns daily or monthly rows between from and to (inclusive),
+// optionally for one tenant and/or key, and their totals
+func (t *Tracker) Report(ctx context.Context, monthly bool, from, to time.Time, tenant, apiKey string) ([]Row, Counters, error) {
+	table, column, layout := "api_usage_daily", "day", "2006-01-02"
+	if monthly {
+		table, column, layout = "api_usage_monthly", "month", "2006-01"
+		from = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	rows, err := t.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT %[2]s, tenant, api_key, requests, errors, scans_created, bytes_in, bytes_out
+		FROM %[1]s
+		WHERE %[2]s BETWEEN $1 AND $2
+		  AND ($3 = '' OR tenant = $3)
+		  AND ($4 = '' OR api_key = $4)
+		ORDER BY %[2]s, requests DESC
+	`, table, column), from, to, tenant, apiKey)
+	if err != nil {
+		return nil, Counters{}, err
+	}
+	defer rows.Close()
+
+	result := []Row{}
+	var total Counters
+	for rows.Next() {
+		var r Row
+		var period time.Time
+		if err := rows.Scan(&period, &r.Tenant, &r.APIKey, &r.Requests, &r.Errors,
+			&r.ScansCreated, &r.BytesIn, &r.BytesOut); err != nil {
+			return nil, Counters{}, err
+		}
+		r.Period = period.Format(layout)
+		total.Requests += r.Requests
+		total.Errors += r.Errors
+		total.ScansCreated += r.ScansCreated
+		total.BytesIn += r.BytesIn
+		total.BytesOut += r.BytesOut
+		result = append(result, r)
+	}
+	return result, total, rows.Err()
+}
+
+// Anomaly is a key whose usage today is well above its recent average
+type Anomaly struct {
+	Tenant          string  `json:"tenant"`
+	APIKey          string  `json:"api_key"`
+	RequestsToday   int64   `json:"requests_today"`
+	ScansToday      int64   `json:"scans_created_today"`
+	BytesOutToday   int64   `json:"bytes_out_today"`
+	AverageRequests float64 `json:"average_daily_requests"`
+	Factor          float64 `json:"factor"`
+}
+
+// Anomalies returns the keys whose requests, scans or egress today exceed
+// factor times their daily average over the previous 7 days. Keys with
+// fewer than minRequests today are ignored, and new keys are compared
+// against an average of zero.
+func (t *Tracker) Anomalies(ctx context.Context, factor float64, minRequests int64) ([]Anomaly, error) {
+	rows, err := t.db.Pool.Query(ctx, `
+		WITH today AS (
+			SELECT tenant, api_key, requests, scans_created, bytes_out
+			FROM api_usage_daily WHERE day = CURRENT_DATE AND requests >= $2
+		), history AS (
+			SELECT tenant, api_key,
+			       SUM(requests) / 7.0 AS requests,
+			       SUM(scans_created) / 7.0 AS scans,
+			       SUM(bytes_out) / 7.0 AS bytes_out
+			FROM api_usage_daily
+			WHERE day >= CURRENT_DATE - 7 AND day < CURRENT_DATE
+			GROUP BY tenant, api_key
+		)
+		SELECT t.tenant, t.api_key, t.requests, t.scans_created, t.bytes_out,
+		       COALESCE(h.requests, 0)::float8,
+		       GREATEST(
+		           t.requests / GREATEST(COALESCE(h.requests, 0), 1),
+		           t.scans_created / GREATEST(COALESCE(h.scans, 0), 1),
+		           t.bytes_out / GREATEST(COALESCE(h.bytes_out, 0), 1)
+		       )::float8 AS factor
+		FROM today t LEFT JOIN history h ON h.tenant = t.tenant AND h.api_key = t.api_key
+		WHERE t.requests > $1 * COALESCE(h.requests, 0)
+		   OR t.scans_created > $1 * COALESCE(h.scans, 0) AND t.scans_created > 0
+		   OR t.bytes_out > $1 * COALESCE(h.bytes_out, 0)
+		ORDER BY factor DESC
+	`, factor, minRequests)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anomalies := []Anomaly{}
+	for rows.Next() {
+		var a Anomaly
+		if err := rows.Scan(&a.Tenant, &a.APIKey, &a.RequestsToday, &a.ScansToday, &a.BytesOutToday,
+			&a.AverageRequests, &a.Factor); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
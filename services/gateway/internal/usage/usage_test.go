@@ -0,0 +1,16 @@
+package usage
+
+import "testing"
+
+func TestCreatesScanIgnoresCase(t *testing.T) {
+	for _, path := range []string{"/api/scans", "/api/SCANS", "/api/Network/Scans/", "/api/WebScans/TestSSL"} {
+		if !CreatesScan(path) {
+			t.Errorf("%s does not create a scan", path)
+		}
+	}
+	for _, path := range []string{"/api/scans/estimate", "/api/SCANS/Estimate", "/api/scans/123"} {
+		if CreatesScan(path) {
+			t.Errorf("%s creates a scan", path)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -13,6 +15,70 @@ type Config struct {
 	APIServiceURL     string
 	CMSServiceURL     string
 	CloudServiceURL   string
+
+	// Shared with the backend services, which reject API requests not signed
+	// with it (service-to-service auth is off when empty)
+	InternalAuthSecret string
+
+	// Authentication of API requests: sessions (SSO), bearer JWTs signed with
+	// JWTSecret and API keys (APIKeys, plus the ones stored in DatabaseURL).
+	// With AuthRequired, requests without them are rejected except to
+	// AuthPublicPaths.
+	AuthRequired    bool
+	JWTSecret       string
+	APIKeys         string // name:key:role entries
+	AuthPublicPaths []string
+	// Role enforcement: viewers only read except on ViewerWritePaths, and
+	// AdminPaths are for admins
+	ViewerWritePaths []string
+	AdminPaths       []string
+
+	// SSO (enabled when OIDCIssuer or LDAPURL is set) and SCIM provisioning
+	// (enabled when SCIMToken is set); users are stored in DatabaseURL
+	DatabaseURL       string
+	AuthSecret        string // HMAC key for session tokens
+	AccessTTLMinutes  int    // lifetime of access tokens
+	SessionTTLHours   int    // sessions expire after this long without a refresh
+	FrontendURL       string
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        []string
+	OIDCGroupsClaim   string
+	LDAPURL           string
+	LDAPBindDN        string
+	LDAPBindPassword  string
+	LDAPBaseDN        string
+	LDAPUserAttribute string
+	LDAPSkipVerify    bool
+	SSOGroupRoles     string // group=role pairs
+	SSODefaultRole    string
+	SCIMToken         string // bearer token of the SCIM provisioning API (disabled when empty)
+
+	// API usage analytics (stored in DatabaseURL)
+	AdminToken         string // X-Admin-Token accepted by admin-only gateway endpoints
+	UsageTracking      bool
+	UsageRetentionDays int // daily usage rows are kept this long; monthly rollups are kept
+
+	// Requests a minute allowed per user, API key or token (0 = unlimited)
+	RateLimitPerMinute int
+
+	// Domain ownership verification (stored in DatabaseURL)
+	OwnershipPolicy       string // off, aggressive or all: which scans of unverified domains are rejected
+	OwnershipValidityDays int    // verifications must be renewed after this many days (0 = never)
+
+	// Slack/Discord channels (stored in DatabaseURL); test messages link here
+	ReportBaseURL string
+
+	// Saved searches (stored in DatabaseURL) post their new matches here
+	NotifyWebhookURL string
+	NotifySecret     string // signs the body as X-Scanner-Signature
+
+	// Read-only deployments (demos, training) refuse every state-changing
+	// request except to ReadOnlyAllowedPaths
+	ReadOnly             bool
+	ReadOnlyAllowedPaths []string
 }
 
 func Load() *Config {
@@ -25,6 +91,62 @@ func Load() *Config {
 		APIServiceURL:     getEnv("API_SERVICE_URL", "http://api-service:8004"),
 		CMSServiceURL:     getEnv("CMS_SERVICE_URL", "http://cms-service:8005"),
 		CloudServiceURL:   getEnv("CLOUD_SERVICE_URL", "http://cloud-service:8006"),
+
+		// Service-to-service auth
+		InternalAuthSecret: getEnv("INTERNAL_AUTH_SECRET", ""),
+
+		// API authentication
+		AuthRequired:     getEnvBool("AUTH_REQUIRED", false),
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		APIKeys:          getEnv("API_KEYS", ""),
+		AuthPublicPaths:  strings.Split(getEnv("AUTH_PUBLIC_PATHS", "/health,/api/status,/api/auth/*,/api/scim/*,/api/projects/*/reports/*,/api/network/agents/*"), ","),
+		ViewerWritePaths: strings.Split(getEnv("VIEWER_WRITE_PATHS", "/api/auth/ldap/login,/api/auth/refresh,/api/auth/logout,/api/auth/sessions/*,/api/scope/check,/api/network/scans/estimate"), ","),
+		AdminPaths:       strings.Split(getEnv("ADMIN_PATHS", "/api/network/admin/*"), ","),
+
+		// SSO
+		DatabaseURL:       getEnv("DATABASE_URL", ""),
+		AuthSecret:        getEnv("AUTH_SECRET", ""),
+		AccessTTLMinutes:  getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15),
+		SessionTTLHours:   getEnvInt("SESSION_TTL_HOURS", 12),
+		FrontendURL:       getEnv("FRONTEND_URL", "http://localhost:3000"),
+		OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", "http://localhost:8000/api/auth/oidc/callback"),
+		OIDCScopes:        strings.Fields(getEnv("OIDC_SCOPES", "openid profile email")),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		LDAPURL:           getEnv("LDAP_URL", ""),
+		LDAPBindDN:        getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:  getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:        getEnv("LDAP_BASE_DN", ""),
+		LDAPUserAttribute: getEnv("LDAP_USER_ATTRIBUTE", "sAMAccountName"),
+		LDAPSkipVerify:    getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		SSOGroupRoles:     getEnv("SSO_GROUP_ROLES", ""),
+		SSODefaultRole:    getEnv("SSO_DEFAULT_ROLE", "viewer"),
+		SCIMToken:         getEnv("SCIM_TOKEN", ""),
+
+		// Usage analytics
+		AdminToken:         getEnv("ADMIN_TOKEN", ""),
+		UsageTracking:      getEnvBool("USAGE_TRACKING", true),
+		UsageRetentionDays: getEnvInt("USAGE_RETENTION_DAYS", 90),
+
+		// Rate limiting
+		RateLimitPerMinute: getEnvInt("RATE_LIMIT_PER_MINUTE", 600),
+
+		// Ownership verification
+		OwnershipPolicy:       strings.ToLower(getEnv("OWNERSHIP_POLICY", "off")),
+		OwnershipValidityDays: getEnvInt("OWNERSHIP_VALIDITY_DAYS", 90),
+
+		// Chat channels
+		ReportBaseURL: getEnv("REPORT_BASE_URL", "http://localhost:3000"),
+
+		// Saved search notifications
+		NotifyWebhookURL: getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifySecret:     getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+
+		// Read-only mode
+		ReadOnly:             getEnvBool("READ_ONLY", false),
+		ReadOnlyAllowedPaths: strings.Split(getEnv("READ_ONLY_ALLOWED_PATHS", "/api/auth/ldap/login,/api/auth/refresh,/api/auth/logout,/api/scope/check,/api/network/scans/estimate"), ","),
 	}
 }
 
@@ -34,3 +156,25 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return intVal
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return defaultValue
+		}
+		return boolVal
+	}
+	return defaultValue
+}
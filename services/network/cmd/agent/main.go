@@ -0,0 +1,94 @@
+// Command agent runs authorized nmap scans from a Windows or macOS host
+// inside an internal network and reports the results to the network service.
+//
+//	agent install   [-config path]   register as a Windows service / launchd daemon
+//	agent uninstall
+//	agent run       [-config path] [-log path]
+//	agent version
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/security-scanner/network-service/internal/agent"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <install|uninstall|run|version> [-config path] [-log path]\n", filepath.Base(os.Args[0]))
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configPath := flags.String("config", agent.DefaultConfigPath(), "agent configuration file")
+	logPath := flags.String("log", "", "log file (default: stderr)")
+	flags.Parse(os.Args[2:])
+
+	switch command {
+	case "version":
+		fmt.Println(agent.Version)
+
+	case "install":
+		// Validate everything the service will need before registering it
+		cfg, err := agent.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		nmapPath, err := agent.DetectNmap(cfg.NmapPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		exePath, err := os.Executable()
+		if err != nil {
+			log.Fatalf("❌ Failed to resolve agent path: %v", err)
+		}
+		configAbs, _ := filepath.Abs(*configPath)
+		if *logPath == "" {
+			*logPath = filepath.Join(filepath.Dir(configAbs), "agent.log")
+		}
+		if err := agent.Install(exePath, configAbs, *logPath); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ Installed %s using %s, logging to %s", agent.ServiceName, nmapPath, *logPath)
+
+	case "uninstall":
+		if err := agent.Uninstall(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ Removed %s", agent.ServiceName)
+
+	case "run":
+		if *logPath != "" {
+			f, err := os.OpenFile(*logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				log.Fatalf("Failed to open log file: %v", err)
+			}
+			defer f.Close()
+			log.SetOutput(f)
+		}
+
+		cfg, err := agent.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		a, err := agent.New(cfg)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if err := agent.RunService(a.Run); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+
+	default:
+		usage()
+	}
+}
@@ -1,22 +1,48 @@
 package main
 
 import (
+	"context"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/nmap-scanner/backend-go/internal/api/handlers"
-	"github.com/nmap-scanner/backend-go/internal/api/middleware"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/scanner"
-	"github.com/nmap-scanner/backend-go/pkg/config"
+	"github.com/security-scanner/network-service/internal/agents"
+	"github.com/security-scanner/network-service/internal/api/handlers"
+	"github.com/security-scanner/network-service/internal/api/middleware"
+	"github.com/security-scanner/network-service/internal/backup"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/exporter"
+	"github.com/security-scanner/network-service/internal/features"
+	"github.com/security-scanner/network-service/internal/hooks"
+	"github.com/security-scanner/network-service/internal/hosting"
+	"github.com/security-scanner/network-service/internal/jobs"
+	"github.com/security-scanner/network-service/internal/knowledge"
+	"github.com/security-scanner/network-service/internal/kubejobs"
+	"github.com/security-scanner/network-service/internal/porthistory"
+	"github.com/security-scanner/network-service/internal/rawoutput"
+	"github.com/security-scanner/network-service/internal/reputation"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/network-service/internal/templatepacks"
+	"github.com/security-scanner/network-service/pkg/config"
+	"github.com/security-scanner/shared/pkg/events"
+	"github.com/security-scanner/shared/pkg/internalauth"
+	"github.com/security-scanner/shared/pkg/redact"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/securedns"
+	"github.com/security-scanner/shared/pkg/supervise"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	log.Println("Starting Network Service (Nmap, Masscan, DNS)")
+	log.Println("Starting Network Service (Nmap, Masscan, Naabu, DNS)")
 	log.Printf("Database: %s", cfg.DatabaseURL)
 	log.Printf("Redis: %s", cfg.RedisURL)
 	log.Printf("Use System Nmap: %v", cfg.UseSystemNmap)
@@ -29,17 +55,345 @@ func main() {
 	}
 	defer db.Close()
 
+	// Background jobs run on the replica holding their lease (job_leases)
+	leases, err := db.Leases(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize job leases: %v", err)
+	}
+
+	// Central configuration overrides (platform_config), polled for hot reload
+	runtimeConfig, err := runtimeconfig.NewStore(db.Pool, "network", time.Duration(cfg.ConfigReloadInterval)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to load runtime configuration: %v", err)
+	}
+	go runtimeConfig.Start(context.Background())
+
+	// Feature flags (feature_flags), cached and refreshed on the same interval
+	featureFlags, err := features.NewStore(db, time.Duration(cfg.ConfigReloadInterval)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to load feature flags: %v", err)
+	}
+	go featureFlags.Start(context.Background())
+
+	// Settings that need a restart are read once at startup
+	cfg.EventBroker = runtimeConfig.Get("events.broker", cfg.EventBroker)
+	cfg.Neo4jSyncInterval = int(runtimeConfig.Duration("neo4j.sync_interval", time.Duration(cfg.Neo4jSyncInterval)*time.Second).Seconds())
+	cfg.ElasticsearchInterval = int(runtimeConfig.Duration("elasticsearch.sync_interval", time.Duration(cfg.ElasticsearchInterval)*time.Second).Seconds())
+
+	// Initialize event bus (nil when no broker, syslog, SIEM connector or notification webhook is configured)
+	var publishers []events.Publisher
+	brokerPublisher, err := events.NewBrokerPublisher(cfg.EventBroker, cfg.EventBrokerURL, cfg.EventTopic, "network-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize event broker: %v", err)
+	}
+	if brokerPublisher != nil {
+		publishers = append(publishers, brokerPublisher)
+	}
+	if cfg.SyslogAddress != "" {
+		syslogPublisher, err := events.NewSyslogPublisher(cfg.SyslogNetwork, cfg.SyslogAddress, cfg.SyslogFormat, cfg.SyslogFieldMapping, "network-service")
+		if err != nil {
+			log.Fatalf("Failed to initialize SIEM syslog output: %v", err)
+		}
+		log.Printf("🛰️ Sending findings to SIEM as %s over %s (%s)", cfg.SyslogFormat, cfg.SyslogNetwork, cfg.SyslogAddress)
+		publishers = append(publishers, syslogPublisher)
+	}
+	connectors, err := events.LoadConnectors(cfg.SIEMConnectors)
+	if err != nil {
+		log.Fatalf("Failed to initialize SIEM connectors: %v", err)
+	}
+	publishers = append(publishers, connectors...)
+	if cfg.NotifyWebhookURL != "" {
+		notifier, err := events.NewNotifier(db.Pool, events.NotifierConfig{
+			WebhookURL: cfg.NotifyWebhookURL,
+			Secret:     cfg.NotifySecret,
+			Mode:       cfg.NotifyMode,
+			DigestHour: cfg.NotifyDigestHour,
+			DedupDays:  cfg.NotifyDedupDays,
+			Tags:       strings.FieldsFunc(strings.ToLower(cfg.NotifyTags), func(r rune) bool { return r == ',' || r == ' ' }),
+		}, "network-service")
+		if err != nil {
+			log.Fatalf("Failed to initialize notifications: %v", err)
+		}
+		log.Printf("📬 Finding notifications enabled (%s, dedup %d days)", cfg.NotifyMode, cfg.NotifyDedupDays)
+		publishers = append(publishers, notifier)
+	}
+	eventBus := events.NewBus("network-service", cfg.EventSubjectPrefix, publishers...)
+	defer eventBus.Close()
+
+	// Tool sandboxing (nil runs tools directly)
+	toolSandbox, err := sandbox.Load(cfg.SandboxProfiles, cfg.SetprivPath, cfg.BwrapPath)
+	if err != nil {
+		log.Fatalf("Failed to load sandbox profiles: %v", err)
+	}
+	if toolSandbox != nil && !cfg.UseSystemNmap {
+		log.Println("⚠️ Sandbox profiles only apply to nmap with USE_SYSTEM_NMAP=true")
+	}
+
+	// Kubernetes Jobs for the tools with a job profile (nil runs every tool
+	// on this pod)
+	var kubeBackend *kubejobs.Backend
+	switch cfg.ExecutionBackend {
+	case "local":
+	case "kubernetes":
+		kubeBackend, err = kubejobs.Load(cfg.K8sJobProfiles, cfg.K8sZones, cfg.K8sAPIURL, cfg.K8sNamespace)
+		if err != nil {
+			log.Fatalf("Failed to initialize Kubernetes backend: %v", err)
+		}
+		log.Printf("☸️ Tools with a job profile run as Kubernetes Jobs (zones: %s)", strings.Join(kubeBackend.Zones(), ", "))
+	default:
+		log.Fatalf("EXECUTION_BACKEND must be local or kubernetes, got %q", cfg.ExecutionBackend)
+	}
+
+	// Honeypot/tarpit scores stored with scan results
+	if err := deception.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize honeypot detection: %v", err)
+	}
+
+	// Knowledge base that documents findings in reports
+	if err := knowledge.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize finding knowledge base: %v", err)
+	}
+
+	// Asset tagging rules, applied to scans as they finish and to recon subdomains as they arrive
+	tagEngine, err := tagging.NewEngine(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize asset tagging: %v", err)
+	}
+	tagEngine.SetLeases(leases)
+	go tagEngine.Start(context.Background(), time.Minute)
+
+	// Webhooks and allow-listed scripts run before and after scans
+	hookRunner, err := hooks.NewRunner(db, toolSandbox, cfg.HooksDir, cfg.HookWebhookSecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize scan hooks: %v", err)
+	}
+
+	// Community template packs imported into the scan, vulnerability and web scan templates
+	if err := templatepacks.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize template packs: %v", err)
+	}
+	packVerifier, err := templatepacks.NewVerifier(cfg.TemplatePackKeys, cfg.UnsignedPacks)
+	if err != nil {
+		log.Fatalf("Invalid TEMPLATE_PACK_KEYS: %v", err)
+	}
+
+	// Open/closed transitions of each host:port, recorded as scans finish
+	if err := porthistory.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize port state history: %v", err)
+	}
+	go porthistory.Backfill(context.Background(), db)
+
+	// Unparsed nmap/masscan output, kept for evidence bundles
+	if err := rawoutput.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize raw scanner output: %v", err)
+	}
+
+	// IP reputation (Spamhaus, AbuseIPDB) of scanned hosts and resolved subdomains
+	if err := reputation.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize IP reputation: %v", err)
+	}
+	var reputationEnricher *reputation.Enricher
+	if cfg.ReputationEnabled {
+		reputationEnricher = reputation.NewEnricher(db, reputation.Config{
+			SpamhausZone:   cfg.SpamhausZone,
+			AbuseIPDBKey:   cfg.AbuseIPDBAPIKey,
+			Refresh:        time.Duration(cfg.ReputationTTLHours) * time.Hour,
+			AbuseThreshold: cfg.AbuseScoreThreshold,
+		})
+		reputationEnricher.SetLeases(leases)
+		go reputationEnricher.Start(context.Background())
+	}
+
 	// Initialize scanners
-	nmapScanner := scanner.NewScanner(db, cfg.UseSystemNmap, cfg.NmapPath)
-	masscanScanner := scanner.NewMasscanScanner(db, cfg.MasscanPath)
+	nmapScanner := scanner.NewScanner(db, cfg.UseSystemNmap, cfg.NmapPath, toolSandbox)
+	if cfg.UDPProbeEnabled {
+		nmapScanner.SetUDPProber(scanner.NewUDPProber(time.Duration(cfg.UDPProbeTimeout) * time.Millisecond))
+	}
+	nmapScanner.SetKubernetes(kubeBackend)
+	masscanScanner := scanner.NewMasscanScanner(db, cfg.MasscanPath, toolSandbox)
+	masscanScanner.SetKubernetes(kubeBackend)
+	naabuScanner := scanner.NewNaabuScanner(db, cfg.NaabuPath, toolSandbox)
+	naabuScanner.SetKubernetes(kubeBackend)
+	toolRetry := supervise.Policy{MaxAttempts: cfg.ToolMaxAttempts, BaseDelay: time.Duration(cfg.ToolRetryDelay) * time.Second}
+	nmapScanner.SetRetryPolicy(toolRetry)
+	masscanScanner.SetRetryPolicy(toolRetry)
+	naabuScanner.SetRetryPolicy(toolRetry)
 	dnsScanner := scanner.NewDNSScanner(db)
+	dnsResolvers, err := securedns.ParseEndpoints(cfg.DNSResolvers)
+	if err != nil {
+		log.Fatalf("Invalid DNS_RESOLVERS: %v", err)
+	}
+	dnsScanner.SetResolvers(dnsResolvers)
+	dnsScanner.SetWordlistsPath(cfg.WordlistsPath)
+	if err := scanner.EnsureNativeSchema(db); err != nil {
+		log.Fatalf("Failed to initialize native scanner: %v", err)
+	}
+	nativeScanner := scanner.NewNativeScanner(db)
+	simulator := scanner.NewSimulator(db)
 
-	log.Printf("Initialized scanners: Nmap (%s), Masscan (%s), DNS", cfg.NmapPath, cfg.MasscanPath)
+	log.Printf("Initialized scanners: Nmap (%s), Masscan (%s), Naabu (%s), DNS, Native, Pipeline", cfg.NmapPath, cfg.MasscanPath, cfg.NaabuPath)
+
+	// Hot-reloadable settings; an empty value means the override was removed
+	scanLimiter := runtimeconfig.NewLimiter(cfg.MaxConcurrentScans)
+	scanJobs := jobs.NewTracker(scanLimiter)
+	scannerLimits, err := jobs.ParseLimits(cfg.ScannerMaxConcurrent)
+	if err != nil {
+		log.Fatalf("Invalid SCANNER_MAX_CONCURRENT: %v", err)
+	}
+	if err := jobs.EnsureSchema(db); err != nil {
+		log.Fatalf("Failed to initialize scan queue: %v", err)
+	}
+	// Scans left unfinished by replicas that stopped are marked interrupted
+	scanReconciler := jobs.NewReconciler(db, leases)
+	scanReconciler.Start(context.Background(), time.Minute)
+	runtimeConfig.Watch("nmap.path", func(value string) {
+		if value == "" {
+			value = cfg.NmapPath
+		}
+		nmapScanner.SetNmapPath(value)
+	})
+	runtimeConfig.Watch("masscan.path", func(value string) {
+		if value == "" {
+			value = cfg.MasscanPath
+		}
+		masscanScanner.SetMasscanPath(value)
+	})
+	runtimeConfig.Watch("naabu.path", func(value string) {
+		if value == "" {
+			value = cfg.NaabuPath
+		}
+		naabuScanner.SetNaabuPath(value)
+	})
+	runtimeConfig.Watch("scans.max_concurrent", func(value string) {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			limit = cfg.MaxConcurrentScans
+		}
+		scanLimiter.SetLimit(limit)
+	})
+	for _, name := range []string{"nmap", "masscan", "dns", "native", "pipeline", "naabu"} {
+		name := name
+		scanJobs.SetScannerLimit(name, scannerLimits[name])
+		runtimeConfig.Watch("scans.max_concurrent."+name, func(value string) {
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				limit = scannerLimits[name]
+			}
+			scanJobs.SetScannerLimit(name, limit)
+		})
+	}
+
+	// Optional Neo4j graph sync
+	if cfg.Neo4jURL != "" {
+		neo4jExporter, err := exporter.NewNeo4jExporter(db, cfg.Neo4jURL, cfg.Neo4jUser, cfg.Neo4jPassword,
+			cfg.Neo4jDatabase, time.Duration(cfg.Neo4jSyncInterval)*time.Second)
+		if err != nil {
+			log.Fatalf("Failed to initialize Neo4j sync: %v", err)
+		}
+		neo4jExporter.SetLeases(leases)
+		go neo4jExporter.Start(context.Background())
+	}
+
+	// Optional Elasticsearch/OpenSearch indexing
+	var esIndexer *exporter.ElasticsearchIndexer
+	if cfg.ElasticsearchURL != "" {
+		esIndexer = exporter.NewElasticsearchIndexer(db, cfg.ElasticsearchURL, cfg.ElasticsearchUser,
+			cfg.ElasticsearchPassword, cfg.ElasticsearchAPIKey, cfg.ElasticsearchPrefix, cfg.ElasticsearchTenant,
+			time.Duration(cfg.ElasticsearchInterval)*time.Second)
+		esIndexer.SetLeases(leases)
+		go esIndexer.Start(context.Background())
+	}
+
+	// Optional database backups
+	var backupManager *backup.Manager
+	if cfg.BackupStorage != "" {
+		var storage backup.Storage
+		switch cfg.BackupStorage {
+		case "local":
+			storage, err = backup.NewLocalStorage(cfg.BackupDir)
+			if err != nil {
+				log.Fatalf("Failed to initialize backup directory: %v", err)
+			}
+		case "s3":
+			if cfg.BackupS3Bucket == "" {
+				log.Fatalf("BACKUP_S3_BUCKET is required for s3 backup storage")
+			}
+			storage = backup.NewS3Storage(cfg.BackupS3Endpoint, cfg.BackupS3Region, cfg.BackupS3Bucket,
+				cfg.BackupS3Prefix, cfg.BackupS3AccessKey, cfg.BackupS3SecretKey)
+		default:
+			log.Fatalf("Unsupported BACKUP_STORAGE: %s", cfg.BackupStorage)
+		}
+		backupManager, err = backup.NewManager(db, cfg.DatabaseURL, storage, leases)
+		if err != nil {
+			log.Fatalf("Failed to initialize backups: %v", err)
+		}
+	}
+
+	// Remote scanning agents (Windows/macOS hosts inside customer networks)
+	agentRegistry, err := agents.NewRegistry(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize agent registry: %v", err)
+	}
 
 	// Initialize handlers
-	scanHandler := handlers.NewScanHandler(db, nmapScanner, masscanScanner, dnsScanner)
-	templateHandler := handlers.NewTemplateHandler(db)
+	scanHandler := handlers.NewScanHandler(db, nmapScanner, masscanScanner, dnsScanner, nativeScanner, naabuScanner, simulator, eventBus, scanJobs, agentRegistry, featureFlags)
+	scanHandler.SetTagger(tagEngine)
+	scanHandler.SetHooks(hookRunner)
+	scanHandler.SetKubernetes(kubeBackend)
+	scanHandler.SetRunner(scanReconciler.Runner())
+	scopeChecker, err := db.Scope(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize scope rules: %v", err)
+	}
+	scanHandler.SetScope(scopeChecker)
+	queueHandler := handlers.NewQueueHandler(db, scanJobs)
+	reputationHandler := handlers.NewReputationHandler(db, reputationEnricher)
+	templateHandler := handlers.NewTemplateHandler(db, nmapScanner)
+	templatePackHandler := handlers.NewTemplatePackHandler(db, packVerifier)
 	reportHandler := handlers.NewReportHandler(db)
+	knowledgeHandler := handlers.NewKnowledgeHandler(db)
+	tagHandler := handlers.NewTagHandler(db, tagEngine)
+	exportHandler := handlers.NewExportHandler(esIndexer)
+	adminHandler := handlers.NewAdminHandler(backupManager, runtimeConfig)
+	featureHandler := handlers.NewFeatureHandler(featureFlags)
+	agentHandler := handlers.NewAgentHandler(agentRegistry, nmapScanner, scanHandler)
+	hookHandler := handlers.NewHookHandler(db, hookRunner)
+	hostHandler := handlers.NewHostHandler(db)
+
+	// Optional hosting of the HTML reports of completed scans, per project
+	var reportPublisher *hosting.Publisher
+	if cfg.ReportStorage != "" {
+		var storage hosting.Storage
+		switch cfg.ReportStorage {
+		case "local":
+			storage, err = hosting.NewLocalStorage(cfg.ReportDir)
+			if err != nil {
+				log.Fatalf("Failed to initialize report directory: %v", err)
+			}
+		case "s3":
+			if cfg.ReportS3Bucket == "" {
+				log.Fatalf("REPORT_S3_BUCKET is required for s3 report storage")
+			}
+			storage = hosting.NewS3Storage(cfg.ReportS3Endpoint, cfg.ReportS3Region, cfg.ReportS3Bucket,
+				cfg.ReportS3Prefix, cfg.ReportS3AccessKey, cfg.ReportS3SecretKey)
+		default:
+			log.Fatalf("Unsupported REPORT_STORAGE: %s", cfg.ReportStorage)
+		}
+		profile, err := redact.Lookup(cfg.ReportRedaction)
+		if err != nil {
+			log.Fatalf("Invalid REPORT_REDACTION: %v", err)
+		}
+		render := func(kind, scanID string) ([]byte, error) {
+			return reportHandler.RenderHTML(kind, scanID, redact.New(profile))
+		}
+		reportPublisher, err = hosting.NewPublisher(db, storage, render, time.Duration(cfg.ReportPublishInterval)*time.Second)
+		if err != nil {
+			log.Fatalf("Failed to initialize report hosting: %v", err)
+		}
+		reportPublisher.SetLeases(leases)
+		go reportPublisher.Start(context.Background())
+	}
+	hostedReportHandler := handlers.NewHostedReportHandler(reportPublisher)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -48,6 +402,7 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(middleware.Errors()) // first, so it sees every error
 	app.Use(recover.New())
 	app.Use(middleware.Logger())
 	app.Use(middleware.CORS())
@@ -58,41 +413,147 @@ func main() {
 			"status":   "ok",
 			"service":  "network-service",
 			"version":  "1.1.0",
-			"scanners": []string{"nmap", "masscan", "dns"},
+			"scanners": []string{"nmap", "masscan", "naabu", "dns"},
 		})
 	})
 
-	// Routes
-	api := app.Group("/api")
+	// Routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalauth.LogStatus(cfg.InternalAuthSecret)
+	api := app.Group("/api", middleware.InternalAuth(cfg.InternalAuthSecret), middleware.DetectAdmin(cfg.AdminToken))
 
 	// Scan routes (Nmap, Masscan, DNS scans)
 	scans := api.Group("/scans")
 	scans.Get("/", scanHandler.ListScans)
 	scans.Post("/", scanHandler.CreateScan)
+	scans.Post("/estimate", scanHandler.EstimateScan)
 	scans.Get("/templates/all", scanHandler.GetAllTemplates) // All scanner templates
+	scans.Get("/advanced-options", scanHandler.GetAdvancedOptions)
+	scans.Get("/zones", scanHandler.GetZones)
+	scans.Get("/wordlists", scanHandler.GetWordlists) // subdomain brute force wordlists of DNS scans
+	scans.Get("/findings", scanHandler.ListFindings)  // normalized, for the gateway's /api/findings
+	scans.Get("/compare", scanHandler.CompareTools)   // nmap vs masscan ports of a target
 	scans.Get("/:id", scanHandler.GetScan)
 	scans.Get("/:id/results", scanHandler.GetScanResults)
 	scans.Get("/:id/logs", scanHandler.GetScanLogs)
+	scans.Get("/:id/stream", scanHandler.StreamScan) // server-sent events while the scan runs
+	scans.Get("/:id/recommendations", scanHandler.GetScanRecommendations)
+	scans.Get("/:id/diff", scanHandler.GetScanDiff)
+	scans.Get("/:id/consistency", scanHandler.GetScanConsistency) // scanned IPs vs recon DNS resolutions
+	scans.Get("/:id/bundle", reportHandler.GetScanBundle)         // zip of reports, raw output, logs and screenshots
 	scans.Delete("/:id", scanHandler.DeleteScan)
 	scans.Post("/:id/cancel", scanHandler.CancelScan)
+	scans.Post("/:id/retry", scanHandler.RetryScan) // failed or interrupted scans
+
+	// Queue introspection (running and waiting scans, agent queues)
+	api.Get("/queue", queueHandler.GetQueue)
+
+	// IP reputation of scanned hosts and resolved subdomains
+	api.Get("/reputation", reputationHandler.ListReputation)
+	api.Get("/reputation/:ip", reputationHandler.GetReputation)
+	api.Post("/reputation/:ip/refresh", reputationHandler.RefreshReputation)
 
 	// Template routes
 	templates := api.Group("/templates")
 	templates.Get("/", templateHandler.ListTemplates)
 	templates.Get("/builtin", templateHandler.ListBuiltinTemplates)
+	templates.Get("/analytics", templateHandler.GetTemplateAnalytics)
+	// Template packs download and import templates for every scanner: admin only
+	packs := templates.Group("/packs", middleware.RequireAdmin())
+	packs.Get("/", templatePackHandler.ListPacks)
+	packs.Post("/", templatePackHandler.ImportPack)
+	packs.Get("/:id", templatePackHandler.GetPack)
+	packs.Post("/:id/update", templatePackHandler.UpdatePack)
+	packs.Delete("/:id", templatePackHandler.DeletePack)
 	templates.Post("/", templateHandler.CreateTemplate)
 	templates.Get("/:id", templateHandler.GetTemplate)
 	templates.Put("/:id", templateHandler.UpdateTemplate)
 	templates.Delete("/:id", templateHandler.DeleteTemplate)
 
+	// Scan types and their request fields, for forms and config validation
+	api.Get("/capabilities", templateHandler.GetCapabilities)
+
 	// Vulnerability templates route (for Nmap scan type selection)
 	api.Get("/vulnerability-templates", templateHandler.ListVulnerabilityTemplates)
 
 	// Report routes
 	reports := api.Group("/reports")
+	reports.Get("/redaction-profiles", reportHandler.GetRedactionProfiles)
 	reports.Get("/:id/json", reportHandler.GetJSONReport)
 	reports.Get("/:id/html", reportHandler.GetHTMLReport)
 	reports.Get("/:id/csv", reportHandler.GetCSVReport)
+	reports.Get("/:id/xml", reportHandler.GetXMLReport)
+	reports.Get("/vulnerabilities/:id/json", reportHandler.GetVulnJSONReport)
+	reports.Get("/vulnerabilities/:id/html", reportHandler.GetVulnHTMLReport)
+
+	// Reports hosted per project: index, latest and history
+	hosted := api.Group("/projects/:id/reports")
+	hosted.Get("/", hostedReportHandler.ListReports)
+	hosted.Get("/latest", hostedReportHandler.GetLatestReport)
+	hosted.Get("/:reportId", hostedReportHandler.GetReport)
+
+	// Finding knowledge base merged into reports
+	api.Get("/knowledge", knowledgeHandler.ListKnowledge)
+	api.Get("/knowledge/:finding_id", knowledgeHandler.GetKnowledge)
+	api.Put("/knowledge/:finding_id/:lang", knowledgeHandler.PutKnowledge)
+	api.Delete("/knowledge/:finding_id/:lang", knowledgeHandler.DeleteKnowledge)
+
+	// Asset tagging rules and the assets they tagged
+	tagRules := api.Group("/tag-rules")
+	tagRules.Get("/", tagHandler.ListRules)
+	tagRules.Post("/", tagHandler.CreateRule)
+	tagRules.Post("/apply", tagHandler.ApplyRules)
+	tagRules.Get("/:id", tagHandler.GetRule)
+	tagRules.Put("/:id", tagHandler.UpdateRule)
+	tagRules.Delete("/:id", tagHandler.DeleteRule)
+	ownerHandler := handlers.NewOwnerHandler(db)
+	assetOwners := api.Group("/asset-owners")
+	assetOwners.Get("/", ownerHandler.ListOwners)
+	assetOwners.Post("/", ownerHandler.CreateOwner)
+	assetOwners.Get("/resolve", ownerHandler.ResolveOwner)
+	assetOwners.Get("/:id", ownerHandler.GetOwner)
+	assetOwners.Put("/:id", ownerHandler.UpdateOwner)
+	assetOwners.Delete("/:id", ownerHandler.DeleteOwner)
+	api.Get("/assets", tagHandler.ListAssets)
+	api.Get("/assets/:asset", tagHandler.GetAsset)
+	api.Get("/assets/:asset/timeline", tagHandler.GetAssetTimeline)
+	api.Get("/hosts/:host/ports/:port/history", hostHandler.GetPortHistory)
+
+	// Admin routes (require X-Admin-Token)
+	admin := api.Group("/admin", middleware.AdminAuth(cfg.AdminToken))
+	admin.Get("/backups", adminHandler.ListBackups)
+	admin.Post("/backups", adminHandler.CreateBackup)
+	admin.Get("/backups/objects", adminHandler.ListBackupObjects)
+	admin.Get("/backups/:id", adminHandler.GetBackup)
+	admin.Post("/backups/:id/restore", adminHandler.RestoreBackup)
+	admin.Post("/exports/elasticsearch/reindex", exportHandler.ReindexElasticsearch)
+	admin.Get("/config", adminHandler.GetConfig)
+	admin.Get("/config/:service", adminHandler.GetServiceConfig)
+	admin.Put("/config/:service/:key", adminHandler.SetConfig)
+	admin.Delete("/config/:service/:key", adminHandler.DeleteConfig)
+	admin.Get("/flags", featureHandler.ListFlags)
+	admin.Get("/flags/:key", featureHandler.GetFlag)
+	admin.Put("/flags/:key", featureHandler.SetFlag)
+	admin.Delete("/flags/:key", featureHandler.DeleteFlag)
+	admin.Get("/hooks", hookHandler.ListHooks)
+	admin.Post("/hooks", hookHandler.CreateHook)
+	admin.Get("/hooks/:id", hookHandler.GetHook)
+	admin.Put("/hooks/:id", hookHandler.UpdateHook)
+	admin.Delete("/hooks/:id", hookHandler.DeleteHook)
+	admin.Get("/agents", agentHandler.ListAgents)
+	admin.Post("/agents", agentHandler.CreateAgent)
+	admin.Delete("/agents/:id", agentHandler.DeleteAgent)
+	admin.Post("/projects/:id/report-token", hostedReportHandler.CreateReportToken)
+	admin.Delete("/projects/:id/report-token", hostedReportHandler.RevokeReportToken)
+
+	// Agent routes (require an agent token)
+	agentAPI := api.Group("/agents", middleware.AgentAuth(agentRegistry))
+	agentAPI.Post("/heartbeat", agentHandler.Heartbeat)
+	agentAPI.Get("/jobs/next", agentHandler.NextJob)
+	agentAPI.Post("/jobs/:id/result", agentHandler.SubmitResult)
+
+	// Feature flags evaluated for the caller's tenant (X-Tenant-ID)
+	api.Get("/features", featureHandler.EvaluateFeatures)
 
 	// Start server
 	log.Printf("Server listening on port %s", cfg.Port)
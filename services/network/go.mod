@@ -1,13 +1,16 @@
-module github.com/nmap-scanner/backend-go
+module github.com/security-scanner/network-service
 
 go 1.21
 
 require (
+	github.com/Ullaakut/nmap/v3 v3.0.3
 	github.com/gofiber/fiber/v2 v2.52.0
+	github.com/google/uuid v1.5.0
 	github.com/jackc/pgx/v5 v5.5.1
 	github.com/redis/go-redis/v9 v9.4.0
-	github.com/google/uuid v1.5.0
-	github.com/Ullaakut/nmap/v3 v3.0.3
+	github.com/security-scanner/shared v0.0.0
+	golang.org/x/sys v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -27,6 +30,8 @@ require (
 	github.com/valyala/tcplisten v1.0.0 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
+
+// Shared models, clients and database helpers (services/shared)
+replace github.com/security-scanner/shared => ../shared
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Version is set at build time with -ldflags "-X .../internal/agent.Version=..."
+var Version = "dev"
+
+// Agent polls the network service for nmap jobs, runs them locally and
+// uploads the XML reports. Jobs run one at a time.
+type Agent struct {
+	cfg      *Config
+	client   *Client
+	nmapPath string
+	hb       Heartbeat
+}
+
+func New(cfg *Config) (*Agent, error) {
+	nmapPath, err := DetectNmap(cfg.NmapPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	return &Agent{
+		cfg:      cfg,
+		client:   NewClient(cfg),
+		nmapPath: nmapPath,
+		hb: Heartbeat{
+			Hostname:    hostname,
+			OS:          runtime.GOOS,
+			Arch:        runtime.GOARCH,
+			Version:     Version,
+			NmapVersion: NmapVersion(nmapPath),
+		},
+	}, nil
+}
+
+// Run polls for jobs until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	log.Printf("🛰️ Scanner agent %s (%s/%s) using %s (%s)", Version, a.hb.OS, a.hb.Arch, a.nmapPath, a.hb.NmapVersion)
+	log.Printf("Reporting to %s every %s", a.cfg.ServerURL, a.cfg.pollInterval())
+
+	ticker := time.NewTicker(a.cfg.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		a.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Agent stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll sends a heartbeat and runs every job that is waiting
+func (a *Agent) poll(ctx context.Context) {
+	if err := a.client.Heartbeat(ctx, a.hb); err != nil {
+		log.Printf("⚠️ Heartbeat failed: %v", err)
+		return
+	}
+
+	for ctx.Err() == nil {
+		job, err := a.client.NextJob(ctx)
+		if err != nil {
+			log.Printf("⚠️ Failed to fetch job: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		a.runJob(ctx, job)
+	}
+}
+
+func (a *Agent) runJob(ctx context.Context, job *Job) {
+	log.Printf("🔍 Scan %s: nmap %s %s", job.ScanID, job.Arguments, job.Target)
+
+	scanCtx, cancel := context.WithTimeout(ctx, a.cfg.scanTimeout())
+	defer cancel()
+
+	args := strings.Fields(job.Arguments)
+	args = append(args, "-oX", "-", job.Target)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(scanCtx, a.nmapPath, args...)
+	cmd.Stderr = &stderr
+	output, scanErr := cmd.Output()
+	if scanErr != nil {
+		scanErr = fmt.Errorf("nmap failed: %w: %s", scanErr, strings.TrimSpace(stderr.String()))
+		output = nil
+		log.Printf("❌ Scan %s: %v", job.ScanID, scanErr)
+	}
+
+	// Report even when the agent is stopping, so the scan does not stay running
+	submitCtx, cancelSubmit := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelSubmit()
+	if err := a.client.SubmitResult(submitCtx, job.ScanID, output, scanErr); err != nil {
+		log.Printf("⚠️ Failed to upload result of scan %s: %v", job.ScanID, err)
+		return
+	}
+	if scanErr == nil {
+		log.Printf("✅ Scan %s uploaded", job.ScanID)
+	}
+}
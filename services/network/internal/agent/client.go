@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Heartbeat mirrors agents.Heartbeat on the network service
+type Heartbeat struct {
+	Hostname    string `json:"hostname"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	Version     string `json:"version"`
+	NmapVersion string `json:"nmap_version"`
+}
+
+// Job mirrors agents.Job on the network service
+type Job struct {
+	ScanID    string `json:"scan_id"`
+	Target    string `json:"target"`
+	Arguments string `json:"arguments"`
+}
+
+// Client talks to the network service agent API
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(cfg *Config) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Client{
+		baseURL: cfg.ServerURL,
+		token:   cfg.Token,
+		http:    &http.Client{Timeout: 60 * time.Second, Transport: transport},
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode, nil
+}
+
+// Heartbeat reports the agent as online
+func (c *Client) Heartbeat(ctx context.Context, hb Heartbeat) error {
+	_, err := c.do(ctx, http.MethodPost, "/agents/heartbeat", hb, nil)
+	return err
+}
+
+// NextJob claims the next scan assigned to this agent; nil means none
+func (c *Client) NextJob(ctx context.Context) (*Job, error) {
+	var job Job
+	status, err := c.do(ctx, http.MethodGet, "/agents/jobs/next", nil, &job)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SubmitResult uploads the nmap XML report, or the error that prevented it
+func (c *Client) SubmitResult(ctx context.Context, scanID string, output []byte, scanErr error) error {
+	body := map[string]string{"output": string(output)}
+	if scanErr != nil {
+		body["error"] = scanErr.Error()
+	}
+	_, err := c.do(ctx, http.MethodPost, "/agents/jobs/"+scanID+"/result", body, nil)
+	return err
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config is read from agent.json (see DefaultConfigPath)
+type Config struct {
+	// Network service base URL, e.g. https://scanner.example.com/api/network
+	ServerURL string `json:"server_url"`
+	// Token returned by POST /api/admin/agents
+	Token string `json:"token"`
+	// nmap binary; detected automatically when empty
+	NmapPath string `json:"nmap_path"`
+	// Seconds between job polls (default 15)
+	PollInterval int `json:"poll_interval"`
+	// Maximum runtime of a single scan in minutes (default 240)
+	ScanTimeout int `json:"scan_timeout"`
+	// Skip TLS verification of ServerURL (self-signed lab setups only)
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// LoadConfig reads and validates the agent configuration
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid agent config %s: %w", path, err)
+	}
+
+	cfg.ServerURL = strings.TrimRight(strings.TrimSpace(cfg.ServerURL), "/")
+	if cfg.ServerURL == "" {
+		return nil, errors.New("server_url is required")
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("token is required")
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 15
+	}
+	if cfg.ScanTimeout <= 0 {
+		cfg.ScanTimeout = 240
+	}
+	return &cfg, nil
+}
+
+func (c *Config) pollInterval() time.Duration {
+	return time.Duration(c.PollInterval) * time.Second
+}
+
+func (c *Config) scanTimeout() time.Duration {
+	return time.Duration(c.ScanTimeout) * time.Minute
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DetectNmap returns the nmap binary to use: the configured path, nmap on
+// PATH, or the default install location of the platform
+func DetectNmap(configured string) (string, error) {
+	if configured != "" {
+		if _, err := os.Stat(configured); err != nil {
+			return "", errors.New("configured nmap_path does not exist: " + configured)
+		}
+		return configured, nil
+	}
+
+	if path, err := exec.LookPath("nmap"); err == nil {
+		return path, nil
+	}
+	for _, candidate := range nmapCandidates() {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("nmap not found; install it from https://nmap.org/download or set nmap_path")
+}
+
+// NmapVersion returns the first line of `nmap --version`
+func NmapVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
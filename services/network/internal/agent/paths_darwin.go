@@ -0,0 +1,11 @@
+package agent
+
+func nmapCandidates() []string {
+	// Homebrew (Apple Silicon and Intel), MacPorts, nmap.org installer
+	return []string{"/opt/homebrew/bin/nmap", "/usr/local/bin/nmap", "/opt/local/bin/nmap"}
+}
+
+// DefaultConfigPath is the system-wide Application Support directory
+func DefaultConfigPath() string {
+	return "/Library/Application Support/ScannerAgent/agent.json"
+}
@@ -0,0 +1,12 @@
+//go:build !windows && !darwin
+
+package agent
+
+func nmapCandidates() []string {
+	return []string{"/usr/bin/nmap", "/usr/local/bin/nmap"}
+}
+
+// DefaultConfigPath is /etc/scanner-agent/agent.json
+func DefaultConfigPath() string {
+	return "/etc/scanner-agent/agent.json"
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func nmapCandidates() []string {
+	var candidates []string
+	for _, env := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+		if dir := os.Getenv(env); dir != "" {
+			candidates = append(candidates, filepath.Join(dir, "Nmap", "nmap.exe"))
+		}
+	}
+	return append(candidates, `C:\Program Files (x86)\Nmap\nmap.exe`, `C:\Program Files\Nmap\nmap.exe`)
+}
+
+// DefaultConfigPath is %ProgramData%\ScannerAgent\agent.json
+func DefaultConfigPath() string {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		dir = `C:\ProgramData`
+	}
+	return filepath.Join(dir, "ScannerAgent", "agent.json")
+}
@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ServiceName is the Windows service name and the base of the launchd label
+const ServiceName = "ScannerAgent"
+
+// runForeground runs fn until Ctrl+C or SIGTERM
+func runForeground(run func(ctx context.Context)) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	run(ctx)
+}
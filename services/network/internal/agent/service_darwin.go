@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+const (
+	launchdLabel = "com.securityscanner.agent"
+	plistPath    = "/Library/LaunchDaemons/" + launchdLabel + ".plist"
+)
+
+// Install writes a launchd daemon running "<exe> run -config <configPath>"
+// at boot, logging to logPath, and loads it
+func Install(exePath, configPath, logPath string) error {
+	if _, err := os.Stat(plistPath); err == nil {
+		return fmt.Errorf("%s already exists", plistPath)
+	}
+
+	var plist bytes.Buffer
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+`)
+	for _, arg := range []string{exePath, "run", "-config", configPath} {
+		plist.WriteString("\t\t<string>")
+		xml.EscapeText(&plist, []byte(arg))
+		plist.WriteString("</string>\n")
+	}
+	plist.WriteString(`	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>`)
+	xml.EscapeText(&plist, []byte(logPath))
+	plist.WriteString(`</string>
+	<key>StandardErrorPath</key>
+	<string>`)
+	xml.EscapeText(&plist, []byte(logPath))
+	plist.WriteString(`</string>
+</dict>
+</plist>
+`)
+
+	if err := os.WriteFile(plistPath, plist.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s (run with sudo): %w", plistPath, err)
+	}
+	if out, err := exec.Command("launchctl", "load", "-w", plistPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl load failed: %v: %s", err, out)
+	}
+	return nil
+}
+
+// Uninstall unloads and removes the launchd daemon
+func Uninstall() error {
+	if _, err := os.Stat(plistPath); err != nil {
+		return fmt.Errorf("%s is not installed", launchdLabel)
+	}
+	exec.Command("launchctl", "unload", "-w", plistPath).Run()
+	return os.Remove(plistPath)
+}
+
+// RunService runs fn in the foreground; launchd stops it with SIGTERM
+func RunService(run func(ctx context.Context)) error {
+	runForeground(run)
+	return nil
+}
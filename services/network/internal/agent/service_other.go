@@ -0,0 +1,26 @@
+//go:build !windows && !darwin
+
+package agent
+
+import (
+	"context"
+	"errors"
+)
+
+var errUnsupported = errors.New("service installation is only supported on Windows and macOS; use systemd or the network-service container on Linux")
+
+// Install is not supported on this platform
+func Install(exePath, configPath, logPath string) error {
+	return errUnsupported
+}
+
+// Uninstall is not supported on this platform
+func Uninstall() error {
+	return errUnsupported
+}
+
+// RunService runs fn in the foreground until SIGINT/SIGTERM
+func RunService(run func(ctx context.Context)) error {
+	runForeground(run)
+	return nil
+}
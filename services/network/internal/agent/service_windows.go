@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install registers the agent as an automatic Windows service running
+// "<exe> run -config <configPath> -log <logPath>" and starts it
+func Install(exePath, configPath, logPath string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(ServiceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", ServiceName)
+	}
+
+	s, err := m.CreateService(ServiceName, exePath, mgr.Config{
+		DisplayName: "Security Scanner Agent",
+		Description: "Runs authorized nmap scans for the Security Scanner platform",
+		StartType:   mgr.StartAutomatic,
+	}, "run", "-config", configPath, "-log", logPath)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	// Restart after a crash
+	s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, 86400)
+
+	return s.Start()
+}
+
+// Uninstall stops and removes the Windows service
+func Uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", ServiceName)
+	}
+	defer s.Close()
+
+	s.Control(svc.Stop)
+	return s.Delete()
+}
+
+// RunService runs fn under the service control manager when started as a
+// service, and in the foreground otherwise
+func RunService(run func(ctx context.Context)) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		runForeground(run)
+		return nil
+	}
+	return svc.Run(ServiceName, &serviceHandler{run: run})
+}
+
+type serviceHandler struct {
+	run func(ctx context.Context)
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		h.run(ctx)
+		close(done)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			}
+		case <-done:
+			return false, 1
+		}
+	}
+}
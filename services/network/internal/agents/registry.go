@@ -0,0 +1,230 @@
+package agents
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+)
+
+var (
+	// ErrNotFound is returned when an agent or job does not exist
+	ErrNotFound = errors.New("agent not found")
+	// ErrUnauthorized is returned for unknown or revoked agent tokens
+	ErrUnauthorized = errors.New("invalid agent token")
+)
+
+// OfflineAfter is how long an agent may go without a heartbeat before it is reported offline
+const OfflineAfter = 2 * time.Minute
+
+// Agent is a scanner running on a customer host (Windows/macOS) that pulls
+// nmap jobs from the network service and reports results back
+type Agent struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Hostname    string     `json:"hostname"`
+	OS          string     `json:"os"`
+	Arch        string     `json:"arch"`
+	Version     string     `json:"version"`
+	NmapVersion string     `json:"nmap_version"`
+	Online      bool       `json:"online"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Heartbeat is the host information an agent reports on every poll
+type Heartbeat struct {
+	Hostname    string `json:"hostname"`
+	OS          string `json:"os"`
+	Arch        string `json:"arch"`
+	Version     string `json:"version"`
+	NmapVersion string `json:"nmap_version"`
+}
+
+// Job is a pending nmap scan assigned to an agent
+type Job struct {
+	ScanID    uuid.UUID `json:"scan_id"`
+	Target    string    `json:"target"`
+	Arguments string    `json:"arguments"`
+}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS scan_agents (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    hostname VARCHAR(255) DEFAULT '',
+    os VARCHAR(50) DEFAULT '',
+    arch VARCHAR(50) DEFAULT '',
+    version VARCHAR(50) DEFAULT '',
+    nmap_version VARCHAR(100) DEFAULT '',
+    last_seen_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS agent_id UUID REFERENCES scan_agents(id) ON DELETE SET NULL`
+
+// Registry stores agents and hands out the scans assigned to them
+type Registry struct {
+	db *database.Database
+}
+
+func NewRegistry(db *database.Database) (*Registry, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create scan_agents table: %w", err)
+	}
+	return &Registry{db: db}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create registers an agent and returns its token. Only the token hash is
+// stored, so the token cannot be shown again.
+func (r *Registry) Create(ctx context.Context, name string) (*Agent, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := "sca_" + hex.EncodeToString(raw)
+
+	agent := Agent{ID: uuid.New(), Name: name}
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO scan_agents (id, name, token_hash) VALUES ($1, $2, $3) RETURNING created_at
+	`, agent.ID, name, hashToken(token)).Scan(&agent.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &agent, token, nil
+}
+
+const agentColumns = `id, name, COALESCE(hostname, ''), COALESCE(os, ''), COALESCE(arch, ''),
+	COALESCE(version, ''), COALESCE(nmap_version, ''), last_seen_at, created_at`
+
+func scanAgent(row pgx.Row) (*Agent, error) {
+	var a Agent
+	if err := row.Scan(&a.ID, &a.Name, &a.Hostname, &a.OS, &a.Arch,
+		&a.Version, &a.NmapVersion, &a.LastSeenAt, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	a.Online = a.LastSeenAt != nil && time.Since(*a.LastSeenAt) < OfflineAfter
+	return &a, nil
+}
+
+// List returns every registered agent
+func (r *Registry) List(ctx context.Context) ([]Agent, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+agentColumns+` FROM scan_agents ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []Agent{}
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *a)
+	}
+	return agents, rows.Err()
+}
+
+// Get returns a single agent
+func (r *Registry) Get(ctx context.Context, id uuid.UUID) (*Agent, error) {
+	a, err := scanAgent(r.db.Pool.QueryRow(ctx, `SELECT `+agentColumns+` FROM scan_agents WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// Delete revokes an agent; its pending scans are failed
+func (r *Registry) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.Pool.Exec(ctx, `
+		UPDATE scans SET status = 'failed', error_message = 'Agent was removed', completed_at = NOW()
+		WHERE agent_id = $1 AND status IN ('pending', 'running')
+	`, id); err != nil {
+		return err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM scan_agents WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the agent owning token
+func (r *Registry) Authenticate(ctx context.Context, token string) (*Agent, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	a, err := scanAgent(r.db.Pool.QueryRow(ctx, `SELECT `+agentColumns+` FROM scan_agents WHERE token_hash = $1`, hashToken(token)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUnauthorized
+	}
+	return a, err
+}
+
+// Heartbeat records that an agent is alive along with its host details
+func (r *Registry) Heartbeat(ctx context.Context, id uuid.UUID, hb Heartbeat) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE scan_agents
+		SET hostname = $1, os = $2, arch = $3, version = $4, nmap_version = $5, last_seen_at = NOW()
+		WHERE id = $6
+	`, hb.Hostname, hb.OS, hb.Arch, hb.Version, hb.NmapVersion, id)
+	return err
+}
+
+// NextJob claims the oldest pending scan assigned to the agent and marks it
+// running. It returns nil when there is nothing to do.
+func (r *Registry) NextJob(ctx context.Context, agentID uuid.UUID) (*Job, error) {
+	var job Job
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE scans SET status = 'running', progress = 0, started_at = NOW()
+		WHERE id = (
+			SELECT id FROM scans
+			WHERE agent_id = $1 AND status = 'pending'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, target, COALESCE(nmap_arguments, '')
+	`, agentID).Scan(&job.ScanID, &job.Target, &job.Arguments)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimResult checks that scanID is a running scan of the agent, so results
+// of cancelled or foreign scans are rejected
+func (r *Registry) ClaimResult(ctx context.Context, agentID, scanID uuid.UUID) error {
+	var status string
+	err := r.db.Pool.QueryRow(ctx, `SELECT status FROM scans WHERE id = $1 AND agent_id = $2`, scanID, agentID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != "running" {
+		return fmt.Errorf("scan is %s", status)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/backup"
+	"github.com/security-scanner/shared/pkg/apierror"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+)
+
+type AdminHandler struct {
+	backups *backup.Manager
+	config  *runtimeconfig.Store
+}
+
+// NewAdminHandler creates the admin handler; backups is nil when backups are not configured
+func NewAdminHandler(backups *backup.Manager, config *runtimeconfig.Store) *AdminHandler {
+	return &AdminHandler{backups: backups, config: config}
+}
+
+func (h *AdminHandler) backupsDisabled(c *fiber.Ctx) error {
+	return c.Status(503).JSON(fiber.Map{"error": "Backups are not configured"})
+}
+
+// ListBackups lists backup and restore jobs (?type=backup|restore)
+func (h *AdminHandler) ListBackups(c *fiber.Ctx) error {
+	if h.backups == nil {
+		return h.backupsDisabled(c)
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	jobs, err := h.backups.ListJobs(context.Background(), c.Query("type", ""), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch backups"})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(jobs)))
+	return c.JSON(fiber.Map{"jobs": jobs, "total": len(jobs)})
+}
+
+// CreateBackup starts a new logical backup
+func (h *AdminHandler) CreateBackup(c *fiber.Ctx) error {
+	if h.backups == nil {
+		return h.backupsDisabled(c)
+	}
+
+	job, err := h.backups.StartBackup(context.Background())
+	if errors.Is(err, backup.ErrJobRunning) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(apierror.New(apierror.CodeToolFailure, "Failed to start backup", err.Error()))
+	}
+
+	return c.Status(202).JSON(job)
+}
+
+// GetBackup returns the status of a backup or restore job
+func (h *AdminHandler) GetBackup(c *fiber.Ctx) error {
+	if h.backups == nil {
+		return h.backupsDisabled(c)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid job ID"})
+	}
+
+	job, err := h.backups.GetJob(context.Background(), id)
+	if errors.Is(err, backup.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Backup job not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch backup job"})
+	}
+
+	return c.JSON(job)
+}
+
+// RestoreBackup restores the database from a completed backup
+func (h *AdminHandler) RestoreBackup(c *fiber.Ctx) error {
+	if h.backups == nil {
+		return h.backupsDisabled(c)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid backup ID"})
+	}
+
+	// Restores replace live data, so require an explicit confirmation
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	c.BodyParser(&req)
+	if !req.Confirm {
+		return c.Status(400).JSON(fiber.Map{"error": "Restore overwrites the current database; send {\"confirm\": true} to proceed"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	job, err := h.backups.StartRestore(ctx, id)
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Backup not found"})
+	case errors.Is(err, backup.ErrJobRunning):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(202).JSON(job)
+}
+
+// ListBackupObjects lists the dumps present in object storage
+func (h *AdminHandler) ListBackupObjects(c *fiber.Ctx) error {
+	if h.backups == nil {
+		return h.backupsDisabled(c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objects, err := h.backups.ListObjects(ctx)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to list backup storage: " + err.Error()})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(objects)))
+	return c.JSON(fiber.Map{"objects": objects, "total": len(objects)})
+}
+
+// configService maps the URL service name to the stored one ("global" is "*")
+func configService(name string) string {
+	if name == "global" {
+		return runtimeconfig.Global
+	}
+	return name
+}
+
+// GetConfig returns the settings catalog and every stored override
+func (h *AdminHandler) GetConfig(c *fiber.Ctx) error {
+	entries, err := h.config.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch configuration"})
+	}
+
+	return c.JSON(fiber.Map{
+		"settings": runtimeconfig.Catalog,
+		"entries":  entries,
+	})
+}
+
+// GetServiceConfig returns the effective overrides for one service (global values merged in)
+func (h *AdminHandler) GetServiceConfig(c *fiber.Ctx) error {
+	service := configService(c.Params("service"))
+
+	entries, err := h.config.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch configuration"})
+	}
+
+	effective := map[string]runtimeconfig.Entry{}
+	for _, e := range entries {
+		if e.Service == runtimeconfig.Global {
+			if _, ok := effective[e.Key]; !ok {
+				effective[e.Key] = e
+			}
+		} else if e.Service == service {
+			effective[e.Key] = e
+		}
+	}
+
+	return c.JSON(fiber.Map{"service": c.Params("service"), "config": effective})
+}
+
+// SetConfig stores a value for a service; hot-reload settings are picked up
+// by running services on their next poll, the rest after a restart
+func (h *AdminHandler) SetConfig(c *fiber.Ctx) error {
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	entry, err := h.config.Set(context.Background(), configService(c.Params("service")), c.Params("key"),
+		req.Value, c.Get("X-Admin-User", c.IP()))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	message := "Configuration updated"
+	if !entry.HotReload {
+		message = "Configuration updated; restart the service to apply it"
+	}
+	return c.JSON(fiber.Map{"message": message, "entry": entry})
+}
+
+// DeleteConfig removes an override so the service falls back to its environment value
+func (h *AdminHandler) DeleteConfig(c *fiber.Ctx) error {
+	deleted, err := h.config.Delete(context.Background(), configService(c.Params("service")), c.Params("key"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete configuration"})
+	}
+	if !deleted {
+		return c.Status(404).JSON(fiber.Map{"error": "Configuration entry not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Configuration override removed"})
+}
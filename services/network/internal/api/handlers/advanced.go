@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/sandbox"
+)
+
+// advancedOptionsError checks the advanced options of a scan request. It
+// returns the status and body to respond with, or 0 when they are allowed.
+func advancedOptionsError(c *fiber.Ctx, req models.CreateScanRequest, scannerName string) (int, fiber.Map) {
+	if req.Advanced == nil || (len(req.Advanced.Flags) == 0 && len(req.Advanced.Env) == 0) {
+		return 0, nil
+	}
+	if !requestIsAdmin(c) {
+		return 403, fiber.Map{"error": "Advanced options require the admin role"}
+	}
+	if req.AgentID != nil {
+		return 400, fiber.Map{"error": "Advanced options can't be used on agents"}
+	}
+	if _, _, err := scanner.ValidateAdvanced(scannerName, req.Advanced); err != nil {
+		flags, env := scanner.AdvancedAllowList(scannerName)
+		return 400, fiber.Map{"error": err.Error(), "allowed_flags": flags, "allowed_env": env}
+	}
+	return 0, nil
+}
+
+// masscanAdapterError checks the masscan adapter options of a scan or
+// template configuration. Choosing the interface and source address the
+// scanner sends from is admin only, including when they come from the
+// scan's template.
+func masscanAdapterError(c *fiber.Ctx, configuration map[string]interface{}, scannerName string) (int, fiber.Map) {
+	if !scanner.HasMasscanAdapter(configuration) {
+		return 0, nil
+	}
+	if scannerName != "" && scannerName != "masscan" && scannerName != "pipeline" {
+		return 400, fiber.Map{"error": "adapter, adapter_ip, adapter_port and router_mac only apply to masscan and pipeline scans"}
+	}
+	if !requestIsAdmin(c) {
+		return 403, fiber.Map{"error": "Masscan adapter options require the admin role"}
+	}
+	if _, err := scanner.ParseMasscanAdapter(configuration); err != nil {
+		return 400, fiber.Map{"error": err.Error()}
+	}
+	return 0, nil
+}
+
+// withAdvanced applies the advanced options of a scan for tool: their
+// environment goes into ctx for the tool process, and their flags are
+// returned to add to its arguments. They were validated when the scan was
+// created; if they no longer pass (the allow-list changed while the scan was
+// queued) the error is returned so the scan fails instead of running
+// without them.
+func withAdvanced(ctx context.Context, req models.CreateScanRequest, tool string) (context.Context, []string, error) {
+	args, env, err := scanner.ValidateAdvanced(tool, req.Advanced)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("invalid advanced options: %w", err)
+	}
+	return sandbox.WithEnv(ctx, env), args, nil
+}
+
+// GetAdvancedOptions lists the flags and environment variables each scanner
+// accepts in a scan's advanced options
+func (h *ScanHandler) GetAdvancedOptions(c *fiber.Ctx) error {
+	result := fiber.Map{}
+	for _, name := range []string{"nmap", "masscan", "naabu"} {
+		flags, env := scanner.AdvancedAllowList(name)
+		result[name] = fiber.Map{"flags": flags, "env": env}
+	}
+	return c.JSON(result)
+}
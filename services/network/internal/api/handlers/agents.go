@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/agents"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+type AgentHandler struct {
+	agents      *agents.Registry
+	nmapScanner *scanner.Scanner
+	scans       *ScanHandler
+}
+
+func NewAgentHandler(registry *agents.Registry, nmapScanner *scanner.Scanner, scans *ScanHandler) *AgentHandler {
+	return &AgentHandler{agents: registry, nmapScanner: nmapScanner, scans: scans}
+}
+
+// ListAgents returns every registered agent with its online state
+func (h *AgentHandler) ListAgents(c *fiber.Ctx) error {
+	list, err := h.agents.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch agents"})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{"agents": list, "total": len(list)})
+}
+
+// CreateAgent registers an agent; the token is only returned here
+func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	agent, token, err := h.agents.Create(context.Background(), req.Name)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to register agent"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"agent": agent, "token": token})
+}
+
+// DeleteAgent revokes an agent's token
+func (h *AgentHandler) DeleteAgent(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid agent ID"})
+	}
+
+	err = h.agents.Delete(context.Background(), id)
+	if errors.Is(err, agents.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Agent not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete agent"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Agent deleted successfully"})
+}
+
+// Heartbeat records the calling agent's host details
+func (h *AgentHandler) Heartbeat(c *fiber.Ctx) error {
+	agent := c.Locals("agent").(*agents.Agent)
+
+	var hb agents.Heartbeat
+	if err := c.BodyParser(&hb); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := h.agents.Heartbeat(context.Background(), agent.ID, hb); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to record heartbeat"})
+	}
+
+	return c.JSON(fiber.Map{"id": agent.ID, "name": agent.Name})
+}
+
+// NextJob hands the calling agent its oldest pending scan (204 when idle)
+func (h *AgentHandler) NextJob(c *fiber.Ctx) error {
+	agent := c.Locals("agent").(*agents.Agent)
+
+	job, err := h.agents.NextJob(context.Background(), agent.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch job"})
+	}
+	if job == nil {
+		return c.SendStatus(204)
+	}
+
+	log.Printf("📡 Agent %s picked up scan %s (%s)", agent.Name, job.ScanID, job.Target)
+	return c.JSON(job)
+}
+
+// SubmitResult stores the nmap XML report (or error) of a job
+func (h *AgentHandler) SubmitResult(c *fiber.Ctx) error {
+	agent := c.Locals("agent").(*agents.Agent)
+
+	scanID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+
+	var req struct {
+		Output string `json:"output"` // nmap -oX report
+		Error  string `json:"error"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := context.Background()
+	err = h.agents.ClaimResult(ctx, agent.ID, scanID)
+	if errors.Is(err, agents.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if err != nil {
+		// Cancelled while the agent was scanning
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if req.Error != "" {
+		h.nmapScanner.FailScan(ctx, scanID, req.Error)
+	} else if err := h.nmapScanner.ImportResults(ctx, scanID, []byte(req.Output), "agent "+agent.Name); err != nil {
+		h.scans.publishScanOutcome(ctx, scanID)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.scans.publishScanOutcome(ctx, scanID)
+
+	return c.JSON(fiber.Map{"message": "Result stored"})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/rawoutput"
+	"github.com/security-scanner/shared/pkg/redact"
+)
+
+// bundleFile is a file of an evidence bundle
+type bundleFile struct {
+	Name        string `json:"name"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	Description string `json:"description"`
+	content     []byte
+}
+
+// bundleManifest lists the files of a bundle with their checksums, so an
+// archived bundle can be checked for tampering
+type bundleManifest struct {
+	ScanID      string       `json:"scan_id"`
+	ScanName    string       `json:"scan_name"`
+	Target      string       `json:"target"`
+	Status      string       `json:"status"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Redaction   string       `json:"redaction,omitempty"`
+	Files       []bundleFile `json:"files"`
+}
+
+// GetScanBundle streams a zip with everything about a scan: the JSON and
+// HTML reports, the raw output of nmap/masscan, the logs and the
+// screenshots of the web ports it found, with a manifest of SHA-256
+// checksums. It takes the report's ?lang, ?tag, ?comments and ?redact.
+func (h *ReportHandler) GetScanBundle(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	id, err := uuid.Parse(scanID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+
+	report, err := h.getScanReport(scanID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if err := h.addTags(report, c.Query("tag")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load asset tags"})
+	}
+	if err := h.addFindings(report, reportLanguage(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load finding descriptions"})
+	}
+	if reportWantsComments(c) {
+		if report.Comments, err = h.scanComments("network", scanID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load comments"})
+		}
+	}
+	redactScanReport(report, redactor)
+	logs := make([]string, len(report.Logs))
+	for i, entry := range report.Logs {
+		logs[i] = fmt.Sprintf("%s [%s] %s", entry.CreatedAt.Format(time.RFC3339), strings.ToUpper(entry.Level), entry.Message)
+	}
+
+	ctx := context.Background()
+	outputs, err := rawoutput.List(ctx, h.db, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load raw scanner output"})
+	}
+	var shots []bundleFile
+	if !redactor.Screenshots() {
+		if shots, err = h.bundleScreenshots(ctx, id, redactor); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load screenshots"})
+		}
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate JSON report"})
+	}
+	files := []bundleFile{
+		{Name: "report.json", Description: "JSON report", content: reportJSON},
+		{Name: "report.html", Description: "HTML report", content: []byte(h.generateHTMLReport(report))},
+		{Name: "logs.txt", Description: "Scan logs", content: []byte(strings.Join(logs, "\n"))},
+	}
+	runs := map[string]int{}
+	for _, output := range outputs {
+		runs[output.Tool]++
+		description := fmt.Sprintf("Raw %s output, run %d", output.Tool, runs[output.Tool])
+		if output.Truncated {
+			description += fmt.Sprintf(" (truncated to %d bytes)", rawoutput.MaxBytes)
+		}
+		content := output.Content
+		if redactor.Active() {
+			content = []byte(redactor.Text(string(content)))
+		}
+		files = append(files, bundleFile{
+			Name:        fmt.Sprintf("raw/%s-%d.%s", output.Tool, runs[output.Tool], output.Format),
+			Description: description,
+			content:     content,
+		})
+	}
+	files = append(files, shots...)
+
+	manifest := bundleManifest{
+		ScanID:      scanID,
+		ScanName:    report.Scan.Name,
+		Target:      report.Scan.Target,
+		Status:      report.Scan.Status,
+		GeneratedAt: time.Now().UTC(),
+		Redaction:   report.Redaction,
+	}
+	for i := range files {
+		sum := sha256.Sum256(files[i].content)
+		files[i].Size = len(files[i].content)
+		files[i].SHA256 = hex.EncodeToString(sum[:])
+	}
+	manifest.Files = files
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=scan_%s_bundle.zip", scanID))
+	c.Set("Content-Type", "application/zip")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := writeBundle(w, manifest); err != nil {
+			log.Printf("Failed to write evidence bundle of scan %s: %v", scanID, err)
+		}
+	})
+	return nil
+}
+
+// writeBundle writes the manifest and files of a bundle as a zip
+func writeBundle(w *bufio.Writer, manifest bundleManifest) error {
+	zw := zip.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := append([]bundleFile{{Name: "manifest.json", content: data}}, manifest.Files...)
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.content); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// bundleScreenshots returns the gowitness screenshots of the web ports the
+// scan found, when the web service shares the database
+func (h *ReportHandler) bundleScreenshots(ctx context.Context, scanID uuid.UUID, r *redact.Redactor) ([]bundleFile, error) {
+	var shared bool
+	err := h.db.Pool.QueryRow(ctx, `SELECT to_regclass('asset_screenshots') IS NOT NULL AND to_regclass('web_scan_results') IS NOT NULL`).Scan(&shared)
+	if err != nil || !shared {
+		return nil, err
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT s.asset, COALESCE(w.url, ''), w.screenshot_b64
+		FROM asset_screenshots s JOIN web_scan_results w ON w.scan_id = s.web_scan_id
+		WHERE s.network_scan_id = $1 AND w.screenshot_b64 IS NOT NULL
+		ORDER BY s.asset, w.url
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []bundleFile
+	for rows.Next() {
+		var asset, url, b64 string
+		if err := rows.Scan(&asset, &url, &b64); err != nil {
+			return nil, err
+		}
+		image, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			continue
+		}
+		ext := "png"
+		if http.DetectContentType(image) == "image/jpeg" {
+			ext = "jpeg"
+		}
+		files = append(files, bundleFile{
+			Name:        fmt.Sprintf("screenshots/%03d-%s.%s", len(files)+1, safeFileName(r.Hostname(asset)), ext),
+			Description: "Screenshot of " + r.URL(url),
+			content:     image,
+		})
+	}
+	return files, rows.Err()
+}
+
+// safeFileName keeps letters, digits, dots and dashes of name
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
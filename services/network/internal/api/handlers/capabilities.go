@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+// portsPattern matches the port lists validatePortList accepts
+const portsPattern = `^\s*\d+(\s*-\s*\d+)?(\s*,\s*\d+(\s*-\s*\d+)?)*\s*$`
+
+// networkCapabilities describes every builtin scan type
+var networkCapabilities = capabilities.New("network", networkScanTypes())
+
+// networkScanTypes derives a scan type from each builtin template, with the
+// request fields its scanner accepts
+func networkScanTypes() []capabilities.ScanType {
+	scanTypes := make([]capabilities.ScanType, 0, len(builtinTemplates))
+	for _, t := range builtinTemplates {
+		scanTypes = append(scanTypes, capabilities.ScanType{
+			Name:        t.ScanType,
+			Title:       t.Name,
+			Description: t.Description,
+			Tool:        t.Scanner,
+			Endpoint:    "POST /api/scans",
+			Fields:      scanFields(t),
+		})
+	}
+	return scanTypes
+}
+
+// scanFields returns the CreateScanRequest fields of a builtin template
+func scanFields(t BuiltinTemplate) []capabilities.Field {
+	fields := []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true, Description: "Name of the scan"},
+		{Name: "target", Type: capabilities.String, Required: true, Description: "Hosts, IPs or CIDR blocks, comma-separated"},
+		{Name: "scan_type", Type: capabilities.String, Required: true, Default: t.ScanType, Enum: []string{t.ScanType}},
+		{Name: "scanner", Type: capabilities.String, Default: t.Scanner, Enum: []string{t.Scanner}, Description: "Derived from scan_type when empty"},
+		{Name: "template_id", Type: capabilities.String, Format: "uuid", Description: "Stored template filling whatever the request leaves out"},
+		{Name: "agent_id", Type: capabilities.String, Format: "uuid", Description: "Remote agent to run the scan on"},
+		{Name: "zone", Type: capabilities.String, Description: "Network zone of the scan's Kubernetes Job"},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Return synthetic results without scanning"},
+	}
+
+	switch t.Scanner {
+	case "nmap":
+		fields = append(fields,
+			capabilities.Field{Name: "nmap_arguments", Type: capabilities.String, Default: t.Arguments, Description: "nmap arguments, checked against the allow-list"},
+			capabilities.Field{Name: "ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges, e.g. 22,80,8000-8100; exclusive with top_ports"},
+			capabilities.Field{Name: "top_ports", Type: capabilities.Integer, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(maxTopPorts), Description: "Scan the N most common ports"},
+			capabilities.Field{Name: "protocol", Type: capabilities.String, Default: "tcp", Enum: []string{"tcp", "udp", "both"}},
+		)
+	case "native":
+		fields = append(fields,
+			capabilities.Field{Name: "ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges, e.g. 22,80,8000-8100; exclusive with top_ports"},
+			capabilities.Field{Name: "top_ports", Type: capabilities.Integer, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(maxTopPorts), Description: "Scan the N most common ports"},
+			capabilities.Field{Name: "protocol", Type: capabilities.String, Default: "tcp", Enum: []string{"tcp"}},
+		)
+	case "masscan":
+		fields = append(fields,
+			capabilities.Field{Name: "ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges, e.g. 22,80,8000-8100"},
+		)
+	case "naabu":
+		fields = append(fields,
+			capabilities.Field{Name: "ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges, e.g. 22,80,8000-8100; exclusive with top_ports"},
+			capabilities.Field{Name: "top_ports", Type: capabilities.Integer, Minimum: capabilities.Bound(100), Maximum: capabilities.Bound(1000), Description: "Scan the 100 or 1000 most common ports; naabu has no other lists"},
+			capabilities.Field{Name: "protocol", Type: capabilities.String, Default: "tcp", Enum: []string{"tcp"}},
+		)
+	case "pipeline":
+		fields = append(fields,
+			capabilities.Field{Name: "nmap_arguments", Type: capabilities.String, Default: t.Arguments, Description: "Arguments of the nmap scan of the open ports"},
+			capabilities.Field{Name: "ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges, e.g. 22,80,8000-8100"},
+		)
+	}
+	if t.Scanner != "dns" && t.Scanner != "masscan" && t.Scanner != "naabu" {
+		fields = append(fields, capabilities.Field{
+			Name: "host_timeout", Type: capabilities.Integer, Minimum: capabilities.Bound(0),
+			Description: "Seconds each host may use; hosts that use them up are skipped",
+		})
+	}
+
+	fields = append(fields,
+		capabilities.Field{Name: "configuration", Type: capabilities.Object, Fields: configurationFields(t)},
+		capabilities.Field{
+			Name: "advanced", Type: capabilities.Object, AdminOnly: true,
+			Description: "Extra tool flags and environment variables, checked against the scanner's allow-list",
+			Fields: []capabilities.Field{
+				{Name: "flags", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}},
+				{Name: "env", Type: capabilities.Object, Description: "Variable names and values"},
+			},
+		},
+	)
+	return fields
+}
+
+// configurationFields returns the configuration keys a scanner reads
+func configurationFields(t BuiltinTemplate) []capabilities.Field {
+	var ports interface{}
+	if t.Ports != "" {
+		ports = t.Ports
+	}
+	var rate interface{}
+	if t.Rate != 0 {
+		rate = t.Rate
+	}
+	adapter := []capabilities.Field{
+		{Name: "adapter", Type: capabilities.String, Description: "Network interface masscan sends from"},
+		{Name: "adapter_ip", Type: capabilities.String, Description: "Source IP address, range or CIDR"},
+		{Name: "adapter_port", Type: capabilities.String, Description: "Source port or port range"},
+		{Name: "router_mac", Type: capabilities.String, Pattern: `^([0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}$`, Description: "MAC address of the gateway"},
+	}
+
+	switch t.Scanner {
+	case "masscan", "pipeline":
+		return append([]capabilities.Field{
+			{Name: "ports", Type: capabilities.String, Default: ports, Pattern: portsPattern},
+			{Name: "rate", Type: capabilities.Integer, Default: rate, Minimum: capabilities.Bound(1), Description: "Packets per second"},
+		}, adapter...)
+	case "native":
+		return []capabilities.Field{
+			{Name: "ports", Type: capabilities.String, Default: ports, Pattern: portsPattern},
+			{Name: "concurrency", Type: capabilities.Integer, Default: scanner.NativeDefaultConcurrency, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(scanner.NativeMaxConcurrency), Description: "Connections in flight"},
+			{Name: "timeout", Type: capabilities.Integer, Default: scanner.NativeDefaultTimeout.Milliseconds(), Minimum: capabilities.Bound(1), Description: "Connect and banner read timeout in milliseconds"},
+			{Name: "banners", Type: capabilities.Boolean, Default: true, Description: "Read the banner of open ports"},
+		}
+	case "naabu":
+		return []capabilities.Field{
+			{Name: "ports", Type: capabilities.String, Default: ports, Pattern: portsPattern},
+			{Name: "rate", Type: capabilities.Integer, Default: rate, Minimum: capabilities.Bound(1), Description: "Packets per second"},
+			{Name: "exclude_ports", Type: capabilities.String, Pattern: portsPattern, Description: "Ports and ranges never probed"},
+			{Name: "verify", Type: capabilities.Boolean, Default: true, Description: "Confirm open ports with a TCP connect"},
+		}
+	case "dns":
+		return []capabilities.Field{{
+			Name: "resolvers", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Format: "uri"},
+			Description: "DNS-over-HTTPS (https://) or DNS-over-TLS (tls://) resolvers; the system resolver when empty",
+		}}
+	}
+	return nil
+}
+
+// GetCapabilities describes the scan types and their request fields, for
+// UIs and the CLI to render forms and validate configurations
+func (h *TemplateHandler) GetCapabilities(c *fiber.Ctx) error {
+	return c.JSON(networkCapabilities)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// Statuses of a port in a tool comparison
+const (
+	compareAgree        = "agree"
+	compareOnlyNmap     = "only_nmap"
+	compareOnlyMasscan  = "only_masscan"
+	compareNmapFiltered = "nmap_filtered"
+	compareNmapClosed   = "nmap_closed"
+)
+
+// CompareTools reconciles the ports nmap and masscan reported for the same
+// target: the latest completed scan of each for ?target=, or the scans given
+// as ?nmap=<id>&masscan=<id>. Ports only one tool found open, or that nmap
+// saw filtered or closed, point at a blind spot or at filtering; ?all=true
+// also lists the ports both agree on.
+func (h *ScanHandler) CompareTools(c *fiber.Ctx) error {
+	ctx := context.Background()
+	target := cleanTarget(c.Query("target"))
+
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	nmapID, status, err := h.compareScanID(ctx, c.Query("nmap"), "nmap", target, scope)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	masscanID, status, err := h.compareScanID(ctx, c.Query("masscan"), "masscan", target, scope)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	nmap, nmapStatus, err := h.diffScan(ctx, nmapID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "nmap scan not found"})
+	}
+	masscan, masscanStatus, err := h.diffScan(ctx, masscanID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "masscan scan not found"})
+	}
+	if nmap.Scanner != "nmap" || masscan.Scanner != "masscan" {
+		return c.Status(400).JSON(fiber.Map{"error": "nmap and masscan must be the IDs of an nmap and a masscan scan"})
+	}
+	if nmapStatus != "completed" || masscanStatus != "completed" {
+		return c.Status(409).JSON(fiber.Map{"error": "Both scans must have completed"})
+	}
+	if !strings.EqualFold(cleanTarget(nmap.Target), cleanTarget(masscan.Target)) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": fmt.Sprintf("The scans have different targets (%s and %s)", nmap.Target, masscan.Target),
+		})
+	}
+
+	nmapResults, err := h.diffResults(ctx, nmapID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+	masscanResults, err := h.diffResults(ctx, masscanID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+
+	comparison := compareToolResults(nmapResults, masscanResults, c.QueryBool("all"))
+	comparison.Target = nmap.Target
+	comparison.Nmap, comparison.Masscan = nmap, masscan
+	return c.JSON(comparison)
+}
+
+// compareScanID returns the scan of a tool to compare: the one given by ID,
+// or the latest completed scan of target by scanner in the project scope.
+// Errors come with the HTTP status to answer with.
+func (h *ScanHandler) compareScanID(ctx context.Context, id, scanner, target, scope string) (uuid.UUID, int, error) {
+	if id != "" {
+		scanID, err := uuid.Parse(id)
+		if err != nil {
+			return uuid.Nil, 400, fmt.Errorf("invalid %s scan ID", scanner)
+		}
+		return scanID, 0, nil
+	}
+	if target == "" {
+		return uuid.Nil, 400, errors.New("target, or the nmap and masscan scan IDs, is required")
+	}
+
+	query := `SELECT id FROM scans WHERE LOWER(target) = LOWER($1) AND scanner = $2 AND status = 'completed'`
+	args := []interface{}{target, scanner}
+	if scope != "" {
+		query += " AND " + project.Filter("project_id", 3)
+		args = append(args, scope)
+	}
+	query += " ORDER BY completed_at DESC LIMIT 1"
+
+	var scanID uuid.UUID
+	err := h.db.Pool.QueryRow(ctx, query, args...).Scan(&scanID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, 404, fmt.Errorf("no completed %s scan of %s", scanner, target)
+	}
+	if err != nil {
+		return uuid.Nil, 500, errors.New("failed to fetch scans")
+	}
+	return scanID, 0, nil
+}
+
+// compareToolResults reconciles the ports of each host nmap and masscan
+// found up. Masscan only reports open ports; nmap also reports those it
+// found filtered or closed, which a masscan hit on the same port usually
+// means a firewall treating the tools differently.
+func compareToolResults(nmap, masscan map[string]models.ScanResult, all bool) models.ToolComparison {
+	comparison := models.ToolComparison{
+		Ports: []models.ToolPort{},
+		Summary: map[string]int{
+			compareAgree: 0, compareOnlyNmap: 0, compareOnlyMasscan: 0, compareNmapFiltered: 0, compareNmapClosed: 0,
+		},
+	}
+
+	hosts := map[string]models.ScanResult{}
+	for host, result := range nmap {
+		hosts[host] = result
+	}
+	for host, result := range masscan {
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = result
+		}
+	}
+
+	for _, host := range sortedHosts(hosts) {
+		hostname := hostnameOf(nmap[host])
+		if hostname == "" {
+			hostname = hostnameOf(masscan[host])
+		}
+		nmapPorts := allPortsByKey(nmap[host])
+		masscanOpen := portsByKey(masscan[host])
+
+		var ports []models.ToolPort
+		for key, port := range masscanOpen {
+			entry := models.ToolPort{
+				Host: host, Hostname: hostname, Port: port.Port, Protocol: strings.ToLower(port.Protocol),
+				MasscanState: "open", ReportedBy: []string{"masscan"},
+			}
+			nmapPort, seen := nmapPorts[key]
+			switch {
+			case !seen:
+				entry.Status = compareOnlyMasscan
+			case isOpen(nmapPort):
+				entry.Status = compareAgree
+				entry.ReportedBy = []string{"nmap", "masscan"}
+			case strings.HasPrefix(nmapPort.State, "closed"):
+				entry.Status = compareNmapClosed
+				entry.ReportedBy = []string{"nmap", "masscan"}
+			default: // filtered, open|filtered
+				entry.Status = compareNmapFiltered
+				entry.ReportedBy = []string{"nmap", "masscan"}
+			}
+			if seen {
+				entry.NmapState = stateOf(nmapPort)
+				entry.Service = nmapPort.Service
+			}
+			ports = append(ports, entry)
+		}
+		for key, port := range nmapPorts {
+			if _, ok := masscanOpen[key]; ok || !isOpen(port) {
+				continue
+			}
+			ports = append(ports, models.ToolPort{
+				Host: host, Hostname: hostname, Port: port.Port, Protocol: strings.ToLower(port.Protocol),
+				Status: compareOnlyNmap, ReportedBy: []string{"nmap"},
+				NmapState: stateOf(port), Service: port.Service,
+			})
+		}
+
+		sortToolPorts(ports)
+		for _, port := range ports {
+			comparison.Summary[port.Status]++
+			if all || port.Status != compareAgree {
+				comparison.Ports = append(comparison.Ports, port)
+			}
+		}
+	}
+	return comparison
+}
+
+// allPortsByKey returns every port of a host whatever its state
+func allPortsByKey(result models.ScanResult) map[string]models.Port {
+	ports := map[string]models.Port{}
+	for _, port := range result.Ports {
+		ports[portKey(port)] = port
+	}
+	return ports
+}
+
+func isOpen(port models.Port) bool {
+	return port.State == "" || port.State == "open"
+}
+
+func stateOf(port models.Port) string {
+	if port.State == "" {
+		return "open"
+	}
+	return port.State
+}
+
+func sortToolPorts(ports []models.ToolPort) {
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+		return ports[i].Port < ports[j].Port
+	})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// GetScanDiff compares a scan with an earlier scan of the same target
+// (?against=<scan id>): hosts that appeared or disappeared, ports that
+// opened or closed and services whose version changed
+func (h *ScanHandler) GetScanDiff(c *fiber.Ctx) error {
+	scanID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+	againstID, err := uuid.Parse(c.Query("against"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "against must be the ID of the scan to compare with"})
+	}
+	if againstID == scanID {
+		return c.Status(400).JSON(fiber.Map{"error": "against must be another scan"})
+	}
+
+	ctx := context.Background()
+	scan, status, err := h.diffScan(ctx, scanID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	against, againstStatus, err := h.diffScan(ctx, againstID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan to compare with not found"})
+	}
+
+	if status != "completed" || againstStatus != "completed" {
+		return c.Status(409).JSON(fiber.Map{"error": "Both scans must have completed"})
+	}
+	if !strings.EqualFold(cleanTarget(scan.Target), cleanTarget(against.Target)) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": fmt.Sprintf("The scans have different targets (%s and %s)", scan.Target, against.Target),
+		})
+	}
+	if scan.Scanner == "dns" || against.Scanner == "dns" {
+		return c.Status(400).JSON(fiber.Map{"error": "DNS scans have no ports to compare"})
+	}
+
+	before, err := h.diffResults(ctx, againstID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+	after, err := h.diffResults(ctx, scanID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+
+	diff := diffScanResults(before, after)
+	diff.Scan, diff.Against = scan, against
+	return c.JSON(diff)
+}
+
+// diffScan returns the reference and status of a scan to diff
+func (h *ScanHandler) diffScan(ctx context.Context, id uuid.UUID) (models.ScanDiffRef, string, error) {
+	ref := models.ScanDiffRef{ID: id}
+	var status string
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT name, target, scanner, status, completed_at FROM scans WHERE id = $1
+	`, id).Scan(&ref.Name, &ref.Target, &ref.Scanner, &status, &ref.CompletedAt)
+	return ref, status, err
+}
+
+// diffResults returns the hosts a scan found up, by address
+func (h *ScanHandler) diffResults(ctx context.Context, scanID uuid.UUID) (map[string]models.ScanResult, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT host, hostname, state, ports FROM scan_results WHERE scan_id = $1
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := map[string]models.ScanResult{}
+	for rows.Next() {
+		var result models.ScanResult
+		if err := rows.Scan(&result.Host, &result.Hostname, &result.State, &result.Ports); err != nil {
+			continue
+		}
+		if result.State == "down" {
+			continue
+		}
+		results[result.Host] = result
+	}
+	return results, rows.Err()
+}
+
+// diffScanResults compares the hosts of an earlier scan (before) with those
+// of a later one (after)
+func diffScanResults(before, after map[string]models.ScanResult) models.ScanDiff {
+	diff := models.ScanDiff{
+		NewHosts:        []models.DiffHost{},
+		RemovedHosts:    []models.DiffHost{},
+		OpenedPorts:     []models.PortChange{},
+		ClosedPorts:     []models.PortChange{},
+		ChangedServices: []models.ServiceChange{},
+	}
+
+	for _, host := range sortedHosts(after) {
+		current := after[host]
+		previous, seen := before[host]
+		if !seen {
+			diff.NewHosts = append(diff.NewHosts, models.DiffHost{Host: host, Hostname: hostnameOf(current), Ports: openPorts(current)})
+			continue
+		}
+		hostname := hostnameOf(current)
+		if hostname == "" {
+			hostname = hostnameOf(previous)
+		}
+
+		was := portsByKey(previous)
+		is := portsByKey(current)
+		for _, port := range openPorts(current) {
+			old, ok := was[portKey(port)]
+			if !ok {
+				diff.OpenedPorts = append(diff.OpenedPorts, models.PortChange{Host: host, Hostname: hostname, Port: port})
+				continue
+			}
+			if serviceChanged(old, port) {
+				diff.ChangedServices = append(diff.ChangedServices, models.ServiceChange{
+					Host: host, Hostname: hostname, Port: port.Port, Protocol: port.Protocol, Before: old, After: port,
+				})
+			}
+		}
+		for _, port := range openPorts(previous) {
+			if _, ok := is[portKey(port)]; !ok {
+				diff.ClosedPorts = append(diff.ClosedPorts, models.PortChange{Host: host, Hostname: hostname, Port: port})
+			}
+		}
+	}
+
+	for _, host := range sortedHosts(before) {
+		if _, ok := after[host]; !ok {
+			diff.RemovedHosts = append(diff.RemovedHosts, models.DiffHost{Host: host, Hostname: hostnameOf(before[host]), Ports: openPorts(before[host])})
+		}
+	}
+
+	diff.Summary = map[string]int{
+		"new_hosts":        len(diff.NewHosts),
+		"removed_hosts":    len(diff.RemovedHosts),
+		"opened_ports":     len(diff.OpenedPorts),
+		"closed_ports":     len(diff.ClosedPorts),
+		"changed_services": len(diff.ChangedServices),
+	}
+	return diff
+}
+
+// openPorts returns the open ports of a host, by protocol and number
+func openPorts(result models.ScanResult) []models.Port {
+	ports := []models.Port{}
+	for _, port := range result.Ports {
+		if port.State == "" || port.State == "open" {
+			ports = append(ports, port)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+		return ports[i].Port < ports[j].Port
+	})
+	return ports
+}
+
+func portsByKey(result models.ScanResult) map[string]models.Port {
+	ports := map[string]models.Port{}
+	for _, port := range openPorts(result) {
+		ports[portKey(port)] = port
+	}
+	return ports
+}
+
+func portKey(port models.Port) string {
+	return fmt.Sprintf("%d/%s", port.Port, strings.ToLower(port.Protocol))
+}
+
+// serviceChanged reports whether the service on a port changed. Ports a
+// scan didn't identify (masscan's, or nmap without -sV) can't be compared.
+func serviceChanged(before, after models.Port) bool {
+	if !identified(before) || !identified(after) {
+		return false
+	}
+	return !strings.EqualFold(before.Service, after.Service) ||
+		before.Product != after.Product ||
+		before.Version != after.Version
+}
+
+func identified(port models.Port) bool {
+	return port.Product != "" || port.Version != "" || (port.Service != "" && port.Service != "unknown")
+}
+
+func hostnameOf(result models.ScanResult) string {
+	if result.Hostname == nil {
+		return ""
+	}
+	return *result.Hostname
+}
+
+// sortedHosts returns the hosts of results with addresses in numeric order
+// and names after them
+func sortedHosts(results map[string]models.ScanResult) []string {
+	hosts := make([]string, 0, len(results))
+	for host := range results {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		a, errA := netip.ParseAddr(hosts[i])
+		b, errB := netip.ParseAddr(hosts[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a.Less(b)
+		case errA == nil:
+			return true
+		case errB == nil:
+			return false
+		default:
+			return hosts[i] < hosts[j]
+		}
+	})
+	return hosts
+}
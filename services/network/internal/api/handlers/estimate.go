@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/scanner"
+)
+
+// EstimateScan returns the expected hosts, probes, duration and
+// intrusiveness of a scan without running it
+func (h *ScanHandler) EstimateScan(c *fiber.Ctx) error {
+	var req models.CreateScanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if status, body := h.applyTemplate(&req); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+
+	if req.Target == "" || req.ScanType == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "target and scan_type (or template_id) are required"})
+	}
+	target, collapsed := scanner.DedupTargets(req.Target)
+	req.Target = cleanTarget(target)
+
+	scannerType := scannerFor(req)
+	switch scannerType {
+	case "nmap", "masscan", "dns", "native", "pipeline", "naabu":
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "scanner must be nmap, masscan, dns, native, pipeline or naabu"})
+	}
+	if err := validatePortOptions(req, scannerType); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.NmapArguments != nil {
+		// Privileged arguments are fine to estimate; CreateScan enforces the role
+		if _, err := scanner.ValidateNmapArguments(*req.NmapArguments); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	hosts, hostnames, err := scanner.EstimateHosts(req.Target)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var estimate *models.ScanEstimate
+	switch {
+	case req.Simulate:
+		estimate = scanner.EstimateSimulation(scannerType, req.ScanType, hosts, hostnames)
+	case scannerType == "masscan":
+		ports, rate := h.masscanOptions(req)
+		estimate = scanner.EstimateMasscan(req.ScanType, ports, rate, hosts, hostnames)
+	case scannerType == "pipeline":
+		ports, rate := h.masscanOptions(req)
+		estimate = scanner.EstimatePipeline(req.ScanType, ports, rate, hosts, hostnames)
+	case scannerType == "native":
+		config, err := h.nativeConfig(req)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		estimate = scanner.EstimateNative(req.ScanType, config, hosts, hostnames)
+	case scannerType == "naabu":
+		config, err := h.naabuConfig(req)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		estimate = scanner.EstimateNaabu(req.ScanType, config, hosts, hostnames)
+	case scannerType == "dns":
+		estimate = scanner.EstimateDNS(req.ScanType, hosts, hostnames)
+	default:
+		estimate = scanner.EstimateNmap(req.ScanType, h.nmapArguments(req), hosts, hostnames)
+	}
+	estimate.CollapsedTargets = collapsed
+	return c.JSON(estimate)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/exporter"
+)
+
+type ExportHandler struct {
+	indexer *exporter.ElasticsearchIndexer
+}
+
+// NewExportHandler creates the export handler; indexer is nil when Elasticsearch is disabled
+func NewExportHandler(indexer *exporter.ElasticsearchIndexer) *ExportHandler {
+	return &ExportHandler{indexer: indexer}
+}
+
+// ReindexElasticsearch pushes all findings and scans to Elasticsearch again.
+// Admin only: it reads the findings of every project.
+func (h *ExportHandler) ReindexElasticsearch(c *fiber.Ctx) error {
+	if h.indexer == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Elasticsearch indexing is not enabled"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	stats, err := h.indexer.Reindex(ctx)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Reindex failed: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Reindex completed",
+		"findings_index": h.indexer.IndexName(stats.Tenant, "findings"),
+		"scans_index":    h.indexer.IndexName(stats.Tenant, "scans"),
+		"stats":          stats,
+	})
+}
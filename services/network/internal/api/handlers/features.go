@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/api/middleware"
+	"github.com/security-scanner/network-service/internal/features"
+	"github.com/security-scanner/shared/pkg/internalauth"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+type FeatureHandler struct {
+	flags *features.Store
+}
+
+func NewFeatureHandler(flags *features.Store) *FeatureHandler {
+	return &FeatureHandler{flags: flags}
+}
+
+// requestTenant returns the tenant (project) from X-Tenant-ID ("default" when absent)
+func requestTenant(c *fiber.Ctx) string {
+	return project.FromRequest(c.Get(project.Header))
+}
+
+// requestIsAdmin reports whether middleware.DetectAdmin found admin rights
+func requestIsAdmin(c *fiber.Ctx) bool {
+	isAdmin, _ := c.Locals(middleware.IsAdminLocal).(bool)
+	return isAdmin
+}
+
+// requestUser returns the user the gateway authenticated, or "" for
+// anonymous requests and requests that didn't come signed by the gateway
+func requestUser(c *fiber.Ctx) string {
+	if fromGateway, _ := c.Locals(internalauth.VerifiedLocal).(bool); !fromGateway {
+		return ""
+	}
+	return c.Get("X-User-ID")
+}
+
+// EvaluateFeatures returns which flags are on for the caller's tenant (?subject= for sticky rollouts)
+func (h *FeatureHandler) EvaluateFeatures(c *fiber.Ctx) error {
+	tenant := requestTenant(c)
+	return c.JSON(fiber.Map{
+		"tenant":   tenant,
+		"features": h.flags.EvaluateAll(tenant, c.Query("subject", "")),
+	})
+}
+
+// ListFlags returns every flag with its rollout rules
+func (h *FeatureHandler) ListFlags(c *fiber.Ctx) error {
+	flags, err := h.flags.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch feature flags"})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(flags)))
+	return c.JSON(fiber.Map{"flags": flags, "total": len(flags)})
+}
+
+// GetFlag returns a single flag
+func (h *FeatureHandler) GetFlag(c *fiber.Ctx) error {
+	flag, err := h.flags.Get(context.Background(), c.Params("key"))
+	if errors.Is(err, features.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Feature flag not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch feature flag"})
+	}
+
+	return c.JSON(flag)
+}
+
+// SetFlag creates a flag or updates the fields present in the body
+func (h *FeatureHandler) SetFlag(c *fiber.Ctx) error {
+	var req struct {
+		Description       *string   `json:"description"`
+		Enabled           *bool     `json:"enabled"`
+		RolloutPercentage *int      `json:"rollout_percentage"`
+		EnabledTenants    *[]string `json:"enabled_tenants"`
+		DisabledTenants   *[]string `json:"disabled_tenants"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	ctx := context.Background()
+	flag, err := h.flags.Get(ctx, c.Params("key"))
+	if errors.Is(err, features.ErrNotFound) {
+		flag = &features.Flag{Key: c.Params("key"), RolloutPercentage: 100}
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch feature flag"})
+	}
+
+	if req.Description != nil {
+		flag.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		flag.Enabled = *req.Enabled
+	}
+	if req.RolloutPercentage != nil {
+		flag.RolloutPercentage = *req.RolloutPercentage
+	}
+	if req.EnabledTenants != nil {
+		flag.EnabledTenants = *req.EnabledTenants
+	}
+	if req.DisabledTenants != nil {
+		flag.DisabledTenants = *req.DisabledTenants
+	}
+
+	saved, err := h.flags.Save(ctx, *flag)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(saved)
+}
+
+// DeleteFlag removes a flag
+func (h *FeatureHandler) DeleteFlag(c *fiber.Ctx) error {
+	err := h.flags.Delete(context.Background(), c.Params("key"))
+	if errors.Is(err, features.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Feature flag not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete feature flag"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Feature flag deleted"})
+}
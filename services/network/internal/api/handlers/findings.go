@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/models"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// ListFindings returns the vulnerabilities NSE scripts found in every scan
+// as normalized findings, which the gateway lists with the other services'
+// findings (?severity=, ?target=, ?scan_id=, ?project=, ?limit=)
+func (h *ScanHandler) ListFindings(c *fiber.Ctx) error {
+	filter, err := shared.ParseFindingFilter(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT id, scan_id, host, vulnerabilities, created_at
+		FROM scan_results
+		WHERE jsonb_typeof(vulnerabilities) = 'array' AND jsonb_array_length(vulnerabilities) > 0
+		  AND ($1::uuid IS NULL OR scan_id = $1)
+		  AND ($2 = '' OR scan_id IN (SELECT id FROM scans WHERE project_id = $2))
+	`, filter.ScanID, project.Scope(c.Query("project"), c.Get(project.Header)))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch findings"})
+	}
+	defer rows.Close()
+
+	findings := []shared.Finding{}
+	for rows.Next() {
+		var result models.ScanResult
+		if err := rows.Scan(&result.ID, &result.ScanID, &result.Host, &result.Vulnerabilities, &result.CreatedAt); err != nil {
+			continue
+		}
+		for i, vuln := range result.Vulnerabilities {
+			target := result.Host
+			if vuln.Port != 0 {
+				target = fmt.Sprintf("%s:%d/%s", result.Host, vuln.Port, vuln.Protocol)
+			}
+			findings = append(findings, shared.Finding{
+				// Stable across requests, since the vulnerabilities have no ID of their own
+				ID:         uuid.NewSHA1(result.ID, []byte(strconv.Itoa(i))),
+				Source:     "network",
+				Tool:       "nmap",
+				ScanID:     result.ScanID,
+				Title:      vuln.Title,
+				Severity:   vuln.Severity,
+				Target:     target,
+				Identifier: vuln.ID,
+				CreatedAt:  result.CreatedAt,
+			})
+		}
+	}
+	list := filter.Apply(findings)
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(list.Total))
+	return c.JSON(list)
+}
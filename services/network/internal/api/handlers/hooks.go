@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/hooks"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// HookHandler manages the pre and post scan hooks of templates and projects
+type HookHandler struct {
+	db     *database.Database
+	runner *hooks.Runner
+}
+
+func NewHookHandler(db *database.Database, runner *hooks.Runner) *HookHandler {
+	return &HookHandler{db: db, runner: runner}
+}
+
+// hookRequest is the editable part of a hook
+type hookRequest struct {
+	Name           string     `json:"name"`
+	Stage          string     `json:"stage"`
+	Kind           string     `json:"kind"`
+	URL            string     `json:"url"`
+	Script         string     `json:"script"`
+	TemplateID     *uuid.UUID `json:"template_id"`
+	Project        string     `json:"project"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	Required       bool       `json:"required"`
+	Enabled        *bool      `json:"enabled,omitempty"`
+}
+
+// parseHook reads and validates a hook from the request body
+func (h *HookHandler) parseHook(c *fiber.Ctx) (*models.ScanHook, error) {
+	var req hookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	hook := &models.ScanHook{
+		Name:           req.Name,
+		Stage:          req.Stage,
+		Kind:           req.Kind,
+		URL:            req.URL,
+		Script:         req.Script,
+		TemplateID:     req.TemplateID,
+		Project:        req.Project,
+		TimeoutSeconds: req.TimeoutSeconds,
+		Required:       req.Required,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	}
+	if err := h.runner.Validate(hook); err != nil {
+		return nil, err
+	}
+	if hook.TemplateID != nil {
+		var exists bool
+		h.db.Pool.QueryRow(context.Background(),
+			`SELECT EXISTS(SELECT 1 FROM scan_templates WHERE id = $1)`, *hook.TemplateID).Scan(&exists)
+		if !exists {
+			return nil, errors.New("template_id is not a saved template")
+		}
+	}
+	return hook, nil
+}
+
+// nameTaken reports whether another hook than id uses name
+func (h *HookHandler) nameTaken(name, id string) bool {
+	var exists bool
+	h.db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM scan_hooks WHERE name = $1 AND id::text <> $2)`, name, id).Scan(&exists)
+	return exists
+}
+
+// ListHooks returns every hook (?template_id= or ?project= to filter) and
+// the scripts hooks may run
+func (h *HookHandler) ListHooks(c *fiber.Ctx) error {
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT `+hooks.Columns+` FROM scan_hooks
+		WHERE ($1 = '' OR template_id::text = $1) AND ($2 = '' OR project = $2)
+		ORDER BY stage DESC, name
+	`, c.Query("template_id"), c.Query("project"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch hooks"})
+	}
+	defer rows.Close()
+
+	list := []*models.ScanHook{}
+	for rows.Next() {
+		hook, err := hooks.ScanHook(rows)
+		if err != nil {
+			continue
+		}
+		list = append(list, hook)
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{
+		"hooks":   list,
+		"total":   len(list),
+		"scripts": h.runner.Scripts(),
+	})
+}
+
+// GetHook returns a hook
+func (h *HookHandler) GetHook(c *fiber.Ctx) error {
+	hook, err := hooks.ScanHook(h.db.Pool.QueryRow(context.Background(),
+		`SELECT `+hooks.Columns+` FROM scan_hooks WHERE id = $1`, c.Params("id")))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Hook not found"})
+	}
+	return c.JSON(hook)
+}
+
+// CreateHook adds a hook; it runs for scans started from now on
+func (h *HookHandler) CreateHook(c *fiber.Ctx) error {
+	hook, err := h.parseHook(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.nameTaken(hook.Name, uuid.Nil.String()) {
+		return c.Status(409).JSON(fiber.Map{"error": "Hook with this name already exists"})
+	}
+
+	row := h.db.Pool.QueryRow(context.Background(), `
+		INSERT INTO scan_hooks (id, name, stage, kind, url, script, template_id, project, timeout_seconds, required, enabled)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), $9, $10, $11)
+		RETURNING `+hooks.Columns,
+		uuid.New(), hook.Name, hook.Stage, hook.Kind, hook.URL, hook.Script, hook.TemplateID, hook.Project,
+		hook.TimeoutSeconds, hook.Required, hook.Enabled)
+	created, err := hooks.ScanHook(row)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create hook"})
+	}
+	return c.Status(201).JSON(created)
+}
+
+// UpdateHook replaces a hook
+func (h *HookHandler) UpdateHook(c *fiber.Ctx) error {
+	hook, err := h.parseHook(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.nameTaken(hook.Name, c.Params("id")) {
+		return c.Status(409).JSON(fiber.Map{"error": "Hook with this name already exists"})
+	}
+
+	row := h.db.Pool.QueryRow(context.Background(), `
+		UPDATE scan_hooks
+		SET name = $2, stage = $3, kind = $4, url = NULLIF($5, ''), script = NULLIF($6, ''), template_id = $7,
+		    project = NULLIF($8, ''), timeout_seconds = $9, required = $10, enabled = $11, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+hooks.Columns,
+		c.Params("id"), hook.Name, hook.Stage, hook.Kind, hook.URL, hook.Script, hook.TemplateID, hook.Project,
+		hook.TimeoutSeconds, hook.Required, hook.Enabled)
+	updated, err := hooks.ScanHook(row)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Hook not found"})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteHook removes a hook; results it recorded on scans are kept
+func (h *HookHandler) DeleteHook(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(), `DELETE FROM scan_hooks WHERE id = $1`, c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete hook"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Hook not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Hook deleted"})
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/hosting"
+	"github.com/security-scanner/network-service/internal/knowledge"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/redact"
+)
+
+// RenderHTML renders the HTML report of a network scan or, for
+// hosting.KindVulnerabilities, of a nuclei scan, as GET /reports/:id/html
+// and /reports/vulnerabilities/:id/html do without comments, for the reports
+// hosted per project
+func (h *ReportHandler) RenderHTML(kind, scanID string, redactor *redact.Redactor) ([]byte, error) {
+	if kind == hosting.KindVulnerabilities {
+		report, err := h.getVulnerabilityReport(scanID, knowledge.DefaultLanguage)
+		if err != nil {
+			return nil, err
+		}
+		redactVulnerabilityReport(report, redactor)
+		return []byte(h.generateVulnHTMLReport(report)), nil
+	}
+
+	report, err := h.getScanReport(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.addTags(report, ""); err != nil {
+		return nil, err
+	}
+	if err := h.addFindings(report, knowledge.DefaultLanguage); err != nil {
+		return nil, err
+	}
+	redactScanReport(report, redactor)
+	return []byte(h.generateHTMLReport(report)), nil
+}
+
+// HostedReportHandler serves the reports published to object storage for
+// each project
+type HostedReportHandler struct {
+	publisher *hosting.Publisher
+}
+
+func NewHostedReportHandler(publisher *hosting.Publisher) *HostedReportHandler {
+	return &HostedReportHandler{publisher: publisher}
+}
+
+// ReportTokenHeader carries a project's read token; ?token= works too, so
+// the links shared with stakeholders open in a browser
+const ReportTokenHeader = "X-Report-Token"
+
+// hostedProject returns the project of the request once the caller may read
+// its reports: admins, holders of the project's read token, and users the
+// gateway authenticated working in that project (X-Tenant-ID, required).
+// Anonymous requests are refused.
+func (h *HostedReportHandler) hostedProject(c *fiber.Ctx) (string, error) {
+	if h.publisher == nil {
+		return "", fiber.NewError(503, "Report hosting is disabled (REPORT_STORAGE not set)")
+	}
+	id := strings.ToLower(strings.TrimSpace(c.Params("id")))
+	if err := project.Valid(id); err != nil {
+		return "", fiber.NewError(400, err.Error())
+	}
+	if requestIsAdmin(c) {
+		return id, nil
+	}
+
+	if token := reportToken(c); token != "" {
+		ok, err := h.publisher.ValidToken(context.Background(), id, token)
+		if err != nil {
+			return "", fiber.NewError(500, "Failed to check the report token")
+		}
+		if !ok {
+			return "", fiber.NewError(401, "Invalid report token")
+		}
+		return id, nil
+	}
+
+	if requestUser(c) == "" {
+		return "", fiber.NewError(401, "Reading hosted reports requires an authenticated user or the project's report token")
+	}
+	scope := strings.ToLower(strings.TrimSpace(c.Get(project.Header)))
+	if scope == "" {
+		return "", fiber.NewError(403, fmt.Sprintf("Reports of project %s can only be read from that project (%s: %s)", id, project.Header, id))
+	}
+	if scope != id {
+		return "", fiber.NewError(403, fmt.Sprintf("Reports of project %s can't be read from project %s", id, scope))
+	}
+	return id, nil
+}
+
+// reportToken returns the read token of the request, if any
+func reportToken(c *fiber.Ctx) string {
+	if token := c.Get(ReportTokenHeader); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
+
+// hostedKind reads ?kind=, network or vulnerabilities (every kind when empty)
+func hostedKind(c *fiber.Ctx) (string, error) {
+	kind := strings.ToLower(c.Query("kind"))
+	if kind != "" && !containsString(hosting.Kinds, kind) {
+		return "", fiber.NewError(400, fmt.Sprintf("kind must be one of %s", strings.Join(hosting.Kinds, ", ")))
+	}
+	return kind, nil
+}
+
+func hostedError(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{"error": fe.Message})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
+
+// ListReports is the index of a project's hosted reports, newest first by
+// default (?page=, ?limit=, ?sort=, ?kind=). Browsers get an HTML page with
+// the reports grouped by the day their scan completed; API clients get the
+// usual JSON page.
+func (h *HostedReportHandler) ListReports(c *fiber.Ctx) error {
+	projectID, err := h.hostedProject(c)
+	if err != nil {
+		return hostedError(c, err)
+	}
+	kind, err := hostedKind(c)
+	if err != nil {
+		return hostedError(c, err)
+	}
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), hosting.SortFields, "-completed_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	reports, total, err := h.publisher.List(context.Background(), projectID, kind, page)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports"})
+	}
+	base := hostedBase(projectID)
+	for i := range reports {
+		reports[i].URL = base + "/" + reports[i].ID.String()
+	}
+	// Links followed from a shared index need the token the index was opened with
+	linkQuery := ""
+	if token := c.Query("token"); token != "" {
+		linkQuery = "?token=" + url.QueryEscape(token)
+		for i := range reports {
+			reports[i].URL += linkQuery
+		}
+	}
+
+	result := pagination.New(reports, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
+	}
+	c.Set("Cache-Control", "private, no-cache")
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML || c.Query("format") == "html" {
+		index, err := renderReportIndex(projectID, base, linkQuery, result)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to render the report index"})
+		}
+		c.Set("Content-Type", "text/html; charset=utf-8")
+		return c.Send(index)
+	}
+	return c.JSON(result)
+}
+
+// GetLatestReport serves the report of the project's scan that completed
+// last (?kind= to pick network or vulnerabilities), a stable URL to the
+// latest results
+func (h *HostedReportHandler) GetLatestReport(c *fiber.Ctx) error {
+	projectID, err := h.hostedProject(c)
+	if err != nil {
+		return hostedError(c, err)
+	}
+	kind, err := hostedKind(c)
+	if err != nil {
+		return hostedError(c, err)
+	}
+	report, err := h.publisher.Latest(context.Background(), projectID, kind)
+	if errors.Is(err, hosting.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "The project has no hosted reports yet"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reports"})
+	}
+	return h.serve(c, report)
+}
+
+// GetReport serves a hosted report
+func (h *HostedReportHandler) GetReport(c *fiber.Ctx) error {
+	projectID, err := h.hostedProject(c)
+	if err != nil {
+		return hostedError(c, err)
+	}
+	id, err := uuid.Parse(c.Params("reportId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid report ID"})
+	}
+	report, err := h.publisher.Get(context.Background(), projectID, id)
+	if errors.Is(err, hosting.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch report"})
+	}
+	return h.serve(c, report)
+}
+
+// serve sends a report inline, so the URL opens in a browser
+func (h *HostedReportHandler) serve(c *fiber.Ctx, report *hosting.Report) error {
+	body, err := h.publisher.Open(context.Background(), report)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to read the report from storage", "details": err.Error()})
+	}
+	defer body.Close()
+	html, err := io.ReadAll(body)
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to read the report from storage", "details": err.Error()})
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s_%s.html", report.Kind, report.ScanID))
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "private, no-cache")
+	c.Set("X-Report-ID", report.ID.String())
+	c.Set("X-Robots-Tag", "noindex")
+	return c.Send(html)
+}
+
+// hostedBase is the path a project's hosted reports are served under
+func hostedBase(projectID string) string {
+	return "/api/projects/" + projectID + "/reports"
+}
+
+// reportIndexTemplate lists a project's hosted reports by day
+const reportIndexTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Security Scanner Reports - {{.Project}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <div class="header">
+        <h1>🛡️ {{.Project}}</h1>
+        <div class="meta">
+            <span><strong>Reports:</strong> {{.Page.Total}}</span>
+            <span><a href="{{.Base}}/latest{{.LinkQuery}}">Latest report</a></span>
+            {{if gt .Page.Pages 1}}<span><strong>Page:</strong> {{.Page.Page}} / {{.Page.Pages}}</span>{{end}}
+        </div>
+    </div>
+
+    {{range .Days}}
+    <div class="section">
+        <div class="section-header">📅 {{.Date}}</div>
+        <div class="section-body">
+            <table class="ports-table">
+                <thead><tr><th>Completed</th><th>Scan</th><th>Target</th><th>Report</th><th>Size</th></tr></thead>
+                <tbody>
+                {{range .Reports}}
+                <tr>
+                    <td>{{.CompletedAt.UTC.Format "15:04"}} UTC</td>
+                    <td><a href="{{.URL}}">{{.ScanName}}</a></td>
+                    <td>{{.Target}}</td>
+                    <td>{{.Kind}}</td>
+                    <td>{{.SizeBytes}} B</td>
+                </tr>
+                {{end}}
+                </tbody>
+            </table>
+        </div>
+    </div>
+    {{else}}
+    <div class="section"><div class="section-body"><p>No reports have been published for this project yet.</p></div></div>
+    {{end}}
+
+    <div class="footer">
+        <p>Generated by Security Scanner Platform on {{.GeneratedAt}}</p>
+    </div>
+</body>
+</html>`
+
+type reportIndexDay struct {
+	Date    string
+	Reports []hosting.Report
+}
+
+// renderReportIndex renders a page of hosted reports, grouped by the day
+// (UTC) their scan completed in the page's order
+func renderReportIndex(projectID, base, linkQuery string, page pagination.Page[hosting.Report]) ([]byte, error) {
+	var days []reportIndexDay
+	for _, report := range page.Items {
+		date := report.CompletedAt.UTC().Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, reportIndexDay{Date: date})
+		}
+		days[len(days)-1].Reports = append(days[len(days)-1].Reports, report)
+	}
+
+	tmpl, err := parseReportTemplate(reportIndexTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Project     string
+		Base        string
+		LinkQuery   string
+		Page        pagination.Page[hosting.Report]
+		Days        []reportIndexDay
+		GeneratedAt string
+	}{projectID, base, linkQuery, page, days, time.Now().Format("2006-01-02 15:04:05")})
+	return buf.Bytes(), err
+}
+
+// CreateReportToken issues a new read token for a project's hosted reports,
+// revoking the previous one; the token is only returned here
+func (h *HostedReportHandler) CreateReportToken(c *fiber.Ctx) error {
+	if h.publisher == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Report hosting is disabled (REPORT_STORAGE not set)"})
+	}
+	id := strings.ToLower(strings.TrimSpace(c.Params("id")))
+	if err := project.Valid(id); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	token, err := h.publisher.CreateToken(context.Background(), id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create the report token"})
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"project_id": id,
+		"token":      token,
+		"url":        hostedBase(id) + "?token=" + url.QueryEscape(token),
+	})
+}
+
+// RevokeReportToken deletes a project's read token
+func (h *HostedReportHandler) RevokeReportToken(c *fiber.Ctx) error {
+	if h.publisher == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Report hosting is disabled (REPORT_STORAGE not set)"})
+	}
+	id := strings.ToLower(strings.TrimSpace(c.Params("id")))
+	err := h.publisher.RevokeToken(context.Background(), id)
+	if errors.Is(err, hosting.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "The project has no report token"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke the report token"})
+	}
+	return c.JSON(fiber.Map{"message": "Report token revoked successfully"})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/porthistory"
+)
+
+// HostHandler serves the port state history of scanned hosts
+type HostHandler struct {
+	db *database.Database
+}
+
+func NewHostHandler(db *database.Database) *HostHandler {
+	return &HostHandler{db: db}
+}
+
+// GetPortHistory returns when a port of a host opened and closed across
+// scans, oldest first. ?protocol= is tcp (default) or udp.
+func (h *HostHandler) GetPortHistory(c *fiber.Ctx) error {
+	port, err := strconv.Atoi(c.Params("port"))
+	if err != nil || port < 1 || port > 65535 {
+		return c.Status(400).JSON(fiber.Map{"error": "port must be between 1 and 65535"})
+	}
+	protocol := strings.ToLower(c.Query("protocol", "tcp"))
+	if protocol != "tcp" && protocol != "udp" {
+		return c.Status(400).JSON(fiber.Map{"error": "protocol must be tcp or udp"})
+	}
+
+	history, err := porthistory.Get(context.Background(), h.db, c.Params("host"), port, protocol)
+	if errors.Is(err, porthistory.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Host not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch port history"})
+	}
+	return c.JSON(history)
+}
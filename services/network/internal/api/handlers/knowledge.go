@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/knowledge"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+var (
+	languagePattern     = regexp.MustCompile(`^[a-z]{2,3}$`)
+	knowledgeSeverities = map[string]bool{"": true, "info": true, "low": true, "medium": true, "high": true, "critical": true}
+)
+
+type KnowledgeHandler struct {
+	db *database.Database
+}
+
+func NewKnowledgeHandler(db *database.Database) *KnowledgeHandler {
+	return &KnowledgeHandler{db: db}
+}
+
+// knowledgeRequest is the editable part of a knowledge entry
+type knowledgeRequest struct {
+	Title       string   `json:"title"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	Impact      string   `json:"impact"`
+	Remediation string   `json:"remediation"`
+	References  []string `json:"references"`
+}
+
+// ListKnowledge lists knowledge base entries. ?lang= keeps one language and
+// ?q= searches finding IDs and titles.
+func (h *KnowledgeHandler) ListKnowledge(c *fiber.Ctx) error {
+	lang := knowledge.NormalizeLanguage(c.Query("lang"))
+	search := strings.TrimSpace(c.Query("q"))
+
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT `+knowledge.Columns+` FROM finding_knowledge
+		WHERE ($1 = '' OR language = $1)
+		  AND ($2 = '' OR finding_id ILIKE '%' || $2 || '%' OR title ILIKE '%' || $2 || '%')
+		ORDER BY finding_id, language
+	`, lang, search)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch knowledge base"})
+	}
+	defer rows.Close()
+
+	entries := []*models.KnowledgeEntry{}
+	for rows.Next() {
+		entry, err := knowledge.ScanRow(rows)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(entries)))
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+// GetKnowledge returns a finding's entry in ?lang=, falling back to English
+// and then to any language it was written in
+func (h *KnowledgeHandler) GetKnowledge(c *fiber.Ctx) error {
+	entry, err := knowledge.Get(context.Background(), h.db, c.Params("finding_id"), c.Query("lang", knowledge.DefaultLanguage))
+	if errors.Is(err, knowledge.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Finding has no knowledge base entry"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch knowledge base entry"})
+	}
+	return c.JSON(entry)
+}
+
+// PutKnowledge creates or replaces a finding's entry in one language
+func (h *KnowledgeHandler) PutKnowledge(c *fiber.Ctx) error {
+	findingID := strings.TrimSpace(c.Params("finding_id"))
+	lang := knowledge.NormalizeLanguage(c.Params("lang"))
+	if findingID == "" || len(findingID) > 255 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid finding ID"})
+	}
+	if !languagePattern.MatchString(lang) {
+		return c.Status(400).JSON(fiber.Map{"error": "Language must be an ISO 639 code such as en or es"})
+	}
+
+	var req knowledgeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Severity = strings.ToLower(strings.TrimSpace(req.Severity))
+	if req.Title == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Title is required"})
+	}
+	if !knowledgeSeverities[req.Severity] {
+		return c.Status(400).JSON(fiber.Map{"error": "Severity must be info, low, medium, high or critical"})
+	}
+	if req.References == nil {
+		req.References = []string{}
+	}
+
+	row := h.db.Pool.QueryRow(context.Background(), `
+		INSERT INTO finding_knowledge (finding_id, language, title, severity, description, impact, remediation, reference_urls, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, NOW())
+		ON CONFLICT (finding_id, language) DO UPDATE SET
+			title = EXCLUDED.title, severity = EXCLUDED.severity, description = EXCLUDED.description,
+			impact = EXCLUDED.impact, remediation = EXCLUDED.remediation,
+			reference_urls = EXCLUDED.reference_urls, updated_at = NOW()
+		RETURNING `+knowledge.Columns,
+		findingID, lang, req.Title, req.Severity, req.Description, req.Impact, req.Remediation, req.References)
+	entry, err := knowledge.ScanRow(row)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save knowledge base entry"})
+	}
+	return c.JSON(entry)
+}
+
+// DeleteKnowledge removes a finding's entry in one language
+func (h *KnowledgeHandler) DeleteKnowledge(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(),
+		`DELETE FROM finding_knowledge WHERE finding_id = $1 AND language = $2`,
+		c.Params("finding_id"), knowledge.NormalizeLanguage(c.Params("lang")))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete knowledge base entry"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Knowledge base entry not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Knowledge base entry deleted"})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/scanner"
+)
+
+// nmapArgumentsError validates user-supplied nmap arguments for the caller.
+// It returns the status and body to respond with, or 0 when they are allowed.
+func nmapArgumentsError(c *fiber.Ctx, arguments string) (int, fiber.Map) {
+	privileged, err := scanner.ValidateNmapArguments(arguments)
+	if err != nil {
+		return 400, fiber.Map{"error": err.Error()}
+	}
+	if len(privileged) > 0 && !requestIsAdmin(c) {
+		return 403, fiber.Map{
+			"error":     "These nmap arguments require the admin role",
+			"arguments": privileged,
+		}
+	}
+	return 0, nil
+}
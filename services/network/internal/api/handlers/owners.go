@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// OwnerHandler manages who owns which assets
+type OwnerHandler struct {
+	db *database.Database
+}
+
+func NewOwnerHandler(db *database.Database) *OwnerHandler {
+	return &OwnerHandler{db: db}
+}
+
+// parseAssignment reads and validates an owner assignment from the request body
+func parseAssignment(c *fiber.Ctx) (*owners.Assignment, error) {
+	var a owners.Assignment
+	if err := c.BodyParser(&a); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if err := owners.Validate(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// targetTaken reports whether another assignment than id covers target
+func (h *OwnerHandler) targetTaken(target, id string) bool {
+	var exists bool
+	h.db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM asset_owners WHERE target = $1 AND id::text <> $2)`, target, id).Scan(&exists)
+	return exists
+}
+
+// ListOwners returns every owner assignment
+func (h *OwnerHandler) ListOwners(c *fiber.Ctx) error {
+	assignments, err := tagging.LoadOwners(context.Background(), h.db)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch asset owners"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(assignments)))
+	return c.JSON(fiber.Map{
+		"owners": assignments,
+		"total":  len(assignments),
+	})
+}
+
+// GetOwner returns an owner assignment
+func (h *OwnerHandler) GetOwner(c *fiber.Ctx) error {
+	a, err := owners.Scan(h.db.Pool.QueryRow(context.Background(),
+		`SELECT `+owners.Columns+` FROM asset_owners WHERE id = $1`, c.Params("id")))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Asset owner not found"})
+	}
+	return c.JSON(a)
+}
+
+// CreateOwner assigns the assets matching a target to an owner
+func (h *OwnerHandler) CreateOwner(c *fiber.Ctx) error {
+	a, err := parseAssignment(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.targetTaken(a.Target, uuid.Nil.String()) {
+		return c.Status(409).JSON(fiber.Map{"error": "Target already has an owner"})
+	}
+
+	created, err := owners.Scan(h.db.Pool.QueryRow(context.Background(), `
+		INSERT INTO asset_owners (id, target, owner_team, contact_email, business_unit, notes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+owners.Columns,
+		uuid.New(), a.Target, a.Team, a.ContactEmail, a.BusinessUnit, a.Notes))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create asset owner"})
+	}
+	return c.Status(201).JSON(created)
+}
+
+// UpdateOwner replaces an owner assignment
+func (h *OwnerHandler) UpdateOwner(c *fiber.Ctx) error {
+	a, err := parseAssignment(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.targetTaken(a.Target, c.Params("id")) {
+		return c.Status(409).JSON(fiber.Map{"error": "Target already has an owner"})
+	}
+
+	updated, err := owners.Scan(h.db.Pool.QueryRow(context.Background(), `
+		UPDATE asset_owners
+		SET target = $2, owner_team = $3, contact_email = $4, business_unit = $5, notes = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+owners.Columns,
+		c.Params("id"), a.Target, a.Team, a.ContactEmail, a.BusinessUnit, a.Notes))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Asset owner not found"})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteOwner removes an owner assignment
+func (h *OwnerHandler) DeleteOwner(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(), `DELETE FROM asset_owners WHERE id = $1`, c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete asset owner"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Asset owner not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Asset owner deleted"})
+}
+
+// ResolveOwner returns the owner of ?host= (a host name, IP or URL), from its
+// most specific assignment
+func (h *OwnerHandler) ResolveOwner(c *fiber.Ctx) error {
+	host := strings.TrimSpace(c.Query("host"))
+	if host == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "host is required"})
+	}
+	assignments, err := tagging.LoadOwners(context.Background(), h.db)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch asset owners"})
+	}
+	owner := owners.Match(assignments, host)
+	if owner == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Host has no owner", "host": host})
+	}
+	return c.JSON(fiber.Map{"host": host, "owner": owner})
+}
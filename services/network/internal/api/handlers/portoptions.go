@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// maxTopPorts is the size of nmap's services frequency table
+const maxTopPorts = 65535
+
+// validatePortOptions checks the ports, top_ports and protocol fields of a
+// scan request for the scanner that will run it
+func validatePortOptions(req models.CreateScanRequest, scanner string) error {
+	if req.Ports == "" && req.TopPorts == 0 && req.Protocol == "" {
+		return nil
+	}
+	if scanner == "dns" {
+		return fmt.Errorf("ports, top_ports and protocol are not supported for DNS scans")
+	}
+	if req.Ports != "" && req.TopPorts != 0 {
+		return fmt.Errorf("ports and top_ports cannot be combined")
+	}
+	if req.TopPorts < 0 || req.TopPorts > maxTopPorts {
+		return fmt.Errorf("top_ports must be between 1 and %d", maxTopPorts)
+	}
+	if req.Ports != "" {
+		if err := validatePortList(req.Ports); err != nil {
+			return err
+		}
+	}
+	switch strings.ToLower(req.Protocol) {
+	case "", "tcp", "udp", "both":
+	default:
+		return fmt.Errorf("protocol must be tcp, udp or both")
+	}
+	if (scanner == "masscan" || scanner == "pipeline") && (req.TopPorts != 0 || req.Protocol != "") {
+		return fmt.Errorf("%s scans only support the ports field", scanner)
+	}
+	if (scanner == "native" || scanner == "naabu") && req.Protocol != "" && strings.ToLower(req.Protocol) != "tcp" {
+		return fmt.Errorf("%s scans only support tcp", scanner)
+	}
+	// naabu only ships the 100 and 1000 most common ports
+	if scanner == "naabu" && req.TopPorts != 0 && req.TopPorts != 100 && req.TopPorts != 1000 {
+		return fmt.Errorf("naabu scans support top_ports 100 or 1000")
+	}
+	return nil
+}
+
+// validatePortList accepts comma-separated ports and ranges such as
+// "22,80,443,8000-8100"
+func validatePortList(ports string) error {
+	for _, part := range strings.Split(ports, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.SplitN(part, "-", 2)
+		low, err := parsePort(bounds[0])
+		if err != nil {
+			return fmt.Errorf("invalid port %q", part)
+		}
+		if len(bounds) == 2 {
+			high, err := parsePort(bounds[1])
+			if err != nil || high < low {
+				return fmt.Errorf("invalid port range %q", part)
+			}
+		}
+	}
+	return nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port")
+	}
+	return port, nil
+}
+
+// applyPortOptions replaces the port selection and scan protocol in nmap
+// arguments with the request's ports, top_ports and protocol
+func applyPortOptions(arguments string, req models.CreateScanRequest) string {
+	if req.Ports == "" && req.TopPorts == 0 && req.Protocol == "" {
+		return arguments
+	}
+
+	protocol := strings.ToLower(req.Protocol)
+	fields := strings.Fields(arguments)
+	var args []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case (req.Ports != "" || req.TopPorts != 0) && (f == "-p" || f == "--top-ports"):
+			i++ // drop the flag's value too
+			continue
+		case (req.Ports != "" || req.TopPorts != 0) && (f == "-F" || strings.HasPrefix(f, "-p") || strings.HasPrefix(f, "--top-ports=")):
+			continue
+		case protocol == "udp" && (f == "-sS" || f == "-sT"):
+			continue
+		case protocol != "" && f == "-sU":
+			continue
+		}
+		args = append(args, f)
+	}
+
+	switch protocol {
+	case "udp":
+		args = append(args, "-sU")
+	case "both":
+		if !containsAny(args, "-sS", "-sT") {
+			args = append(args, "-sS")
+		}
+		args = append(args, "-sU")
+	}
+	if req.Ports != "" {
+		args = append(args, "-p", strings.ReplaceAll(req.Ports, " ", ""))
+	}
+	if req.TopPorts != 0 {
+		args = append(args, "--top-ports", strconv.Itoa(req.TopPorts))
+	}
+	return strings.Join(args, " ")
+}
+
+func containsAny(args []string, flags ...string) bool {
+	for _, a := range args {
+		for _, f := range flags {
+			if a == f {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/agents"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/jobs"
+)
+
+// defaultScanDuration is assumed for scanners without completed scans to learn from
+const defaultScanDuration = 5 * time.Minute
+
+type QueueHandler struct {
+	db     *database.Database
+	jobs   *jobs.Tracker
+	worker string
+}
+
+func NewQueueHandler(db *database.Database, scanJobs *jobs.Tracker) *QueueHandler {
+	worker, _ := os.Hostname()
+	if worker == "" {
+		worker = "network-service"
+	}
+	return &QueueHandler{db: db, jobs: scanJobs, worker: worker}
+}
+
+type runningJob struct {
+	jobs.Job
+	Worker              string         `json:"worker"`
+	RuntimeSeconds      int64          `json:"runtime_seconds"`
+	EstimatedCompletion time.Time      `json:"estimated_completion"`
+	Processes           []jobs.Process `json:"processes"`
+}
+
+type queuedJob struct {
+	jobs.Job
+	Position       int       `json:"position"`
+	Worker         string    `json:"worker"`
+	WaitSeconds    int64     `json:"wait_seconds"`
+	EstimatedStart time.Time `json:"estimated_start"`
+}
+
+// scannerQueue is the queue of one scanner
+type scannerQueue struct {
+	jobs.Slots
+	Queued int `json:"queued"`
+}
+
+type agentJob struct {
+	ScanID    uuid.UUID  `json:"scan_id"`
+	Name      string     `json:"name"`
+	Target    string     `json:"target"`
+	Status    string     `json:"status"`
+	Position  int        `json:"position,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+type agentQueue struct {
+	AgentID   uuid.UUID  `json:"agent_id"`
+	AgentName string     `json:"agent_name"`
+	Online    bool       `json:"online"`
+	Jobs      []agentJob `json:"jobs"`
+}
+
+// GetQueue shows the scans running on this service with their processes,
+// the scans waiting for a slot with their estimated start, the slots of
+// each scanner, and the scans assigned to remote agents
+func (h *QueueHandler) GetQueue(c *fiber.Ctx) error {
+	ctx := context.Background()
+	now := time.Now()
+	limit, inUse := h.jobs.Limits()
+	scannerLimits := h.jobs.ScannerLimits()
+	queued, running := h.jobs.Snapshot()
+	durations := h.averageDurations(ctx)
+	processes := h.jobs.Processes()
+
+	duration := func(scanner string) time.Duration {
+		if d, ok := durations[scanner]; ok {
+			return d
+		}
+		return defaultScanDuration
+	}
+
+	// Slots free up when running scans are expected to finish; a scan that
+	// overran its estimate is assumed to finish now. Each scanner has its own
+	// slots besides the shared ones.
+	var slots []time.Time
+	scannerSlots := map[string][]time.Time{}
+	runningJobs := []runningJob{}
+	for _, j := range running {
+		done := j.StartedAt.Add(duration(j.Scanner))
+		if done.Before(now) {
+			done = now
+		}
+		slots = append(slots, done)
+		scannerSlots[j.Scanner] = append(scannerSlots[j.Scanner], done)
+		procs := processes[j.ID]
+		if procs == nil {
+			procs = []jobs.Process{}
+		}
+		runningJobs = append(runningJobs, runningJob{
+			Job:                 j,
+			Worker:              h.worker,
+			RuntimeSeconds:      int64(now.Sub(*j.StartedAt).Seconds()),
+			EstimatedCompletion: done,
+			Processes:           procs,
+		})
+	}
+	if limit > 0 {
+		for len(slots) < limit {
+			slots = append(slots, now)
+		}
+	}
+	for scanner, s := range scannerLimits {
+		if s.MaxConcurrent > 0 {
+			for len(scannerSlots[scanner]) < s.MaxConcurrent {
+				scannerSlots[scanner] = append(scannerSlots[scanner], now)
+			}
+		}
+	}
+	sortSlots := func(times []time.Time) {
+		sort.Slice(times, func(a, b int) bool { return times[a].Before(times[b]) })
+	}
+
+	queuedJobs := []queuedJob{}
+	queuedBy := map[string]int{}
+	for i, j := range queued {
+		queuedBy[j.Scanner]++
+		start := now
+		limited := scannerLimits[j.Scanner].MaxConcurrent > 0
+		if limit > 0 {
+			sortSlots(slots)
+			start = slots[0]
+		}
+		if limited {
+			sortSlots(scannerSlots[j.Scanner])
+			if scannerSlots[j.Scanner][0].After(start) {
+				start = scannerSlots[j.Scanner][0]
+			}
+		}
+		if limit > 0 {
+			slots[0] = start.Add(duration(j.Scanner))
+		}
+		if limited {
+			scannerSlots[j.Scanner][0] = start.Add(duration(j.Scanner))
+		}
+		queuedJobs = append(queuedJobs, queuedJob{
+			Job:            j,
+			Position:       i + 1,
+			Worker:         h.worker,
+			WaitSeconds:    int64(now.Sub(j.QueuedAt).Seconds()),
+			EstimatedStart: start,
+		})
+	}
+
+	scanners := map[string]scannerQueue{}
+	for scanner, s := range scannerLimits {
+		scanners[scanner] = scannerQueue{Slots: s, Queued: queuedBy[scanner]}
+	}
+
+	agentQueues, err := h.agentQueues(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch agent jobs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"worker":         h.worker,
+		"max_concurrent": limit,
+		"slots_in_use":   inUse,
+		"scanners":       scanners,
+		"running":        runningJobs,
+		"queued":         queuedJobs,
+		"agents":         agentQueues,
+	})
+}
+
+// averageDurations returns the average runtime per scanner over recent completed scans
+func (h *QueueHandler) averageDurations(ctx context.Context) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT scanner, EXTRACT(EPOCH FROM AVG(completed_at - started_at))::float8
+		FROM (
+			SELECT COALESCE(scanner, 'nmap') AS scanner, started_at, completed_at FROM scans
+			WHERE status = 'completed' AND started_at IS NOT NULL AND completed_at IS NOT NULL AND agent_id IS NULL
+			ORDER BY completed_at DESC
+			LIMIT 500
+		) recent
+		GROUP BY scanner
+	`)
+	if err != nil {
+		return durations
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scanner string
+		var seconds float64
+		if err := rows.Scan(&scanner, &seconds); err == nil && seconds > 0 {
+			durations[scanner] = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return durations
+}
+
+// agentQueues lists the pending and running scans of each agent; agents run
+// one scan at a time, in creation order
+func (h *QueueHandler) agentQueues(ctx context.Context) ([]agentQueue, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT s.id, s.name, s.target, s.status, s.created_at, s.started_at, a.id, a.name, a.last_seen_at
+		FROM scans s JOIN scan_agents a ON a.id = s.agent_id
+		WHERE s.status IN ('pending', 'running')
+		ORDER BY a.name, a.id, s.status DESC, s.created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queues := []agentQueue{}
+	for rows.Next() {
+		var j agentJob
+		var agentID uuid.UUID
+		var agentName string
+		var lastSeen *time.Time
+		if err := rows.Scan(&j.ScanID, &j.Name, &j.Target, &j.Status, &j.QueuedAt, &j.StartedAt,
+			&agentID, &agentName, &lastSeen); err != nil {
+			return nil, err
+		}
+		if len(queues) == 0 || queues[len(queues)-1].AgentID != agentID {
+			queues = append(queues, agentQueue{
+				AgentID:   agentID,
+				AgentName: agentName,
+				Online:    lastSeen != nil && time.Since(*lastSeen) < agents.OfflineAfter,
+			})
+		}
+		q := &queues[len(queues)-1]
+		if j.Status == "pending" {
+			position := 1
+			for _, other := range q.Jobs {
+				if other.Status == "pending" {
+					position++
+				}
+			}
+			j.Position = position
+		}
+		q.Jobs = append(q.Jobs, j)
+	}
+	return queues, rows.Err()
+}
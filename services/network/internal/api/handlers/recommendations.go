@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// cmsScanTypes maps technologies seen in service banners to CMS scan types
+var cmsScanTypes = []struct {
+	keyword  string
+	scanType string
+	name     string
+}{
+	{"wordpress", "wpscan", "WordPress"},
+	{"joomla", "joomscan", "Joomla"},
+	{"drupal", "droopescan", "Drupal"},
+}
+
+// webEvidence is a URL found by the web service (ffuf, gowitness) on a host
+type webEvidence struct {
+	url   *url.URL
+	title string
+}
+
+// GetScanRecommendations suggests follow-up scans for the services a
+// completed scan detected, each with a ready-to-POST payload
+func (h *ScanHandler) GetScanRecommendations(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	ctx := context.Background()
+
+	var name, status string
+	err := h.db.Pool.QueryRow(ctx, `SELECT name, status FROM scans WHERE id = $1`, scanID).Scan(&name, &status)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if status != "completed" {
+		return c.Status(409).JSON(fiber.Map{"error": "Recommendations are available once the scan has completed"})
+	}
+
+	// Likely honeypots are left out so nobody follows up on fake services
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT host, hostname, ports FROM scan_results WHERE scan_id = $1 AND honeypot_score < $2
+	`, scanID, deception.LikelyScore)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+	var results []models.ScanResult
+	for rows.Next() {
+		var result models.ScanResult
+		if err := rows.Scan(&result.Host, &result.Hostname, &result.Ports); err != nil {
+			continue
+		}
+		results = append(results, result)
+	}
+	rows.Close()
+
+	evidence := h.webEvidence(ctx)
+
+	recommendations := []models.ScanRecommendation{}
+	seen := map[string]bool{}
+	add := func(r models.ScanRecommendation) {
+		key := r.Tool + "|" + fmt.Sprint(r.Payload["target"])
+		if !seen[key] {
+			seen[key] = true
+			recommendations = append(recommendations, r)
+		}
+	}
+
+	for _, result := range results {
+		host := result.Host
+		if result.Hostname != nil && *result.Hostname != "" {
+			host = *result.Hostname
+		}
+
+		for _, port := range result.Ports {
+			if port.State != "open" || !isWebPort(port) {
+				continue
+			}
+			tls := isTLSPort(port)
+			baseURL := webURL(host, port.Port, tls)
+			banner := strings.ToLower(port.Product + " " + port.Version + " " + port.ExtraInfo)
+			pages := evidenceFor(evidence, host, result.Host, port.Port)
+
+			if tls {
+				add(models.ScanRecommendation{
+					Tool:   "testssl",
+					Reason: fmt.Sprintf("TLS service on port %d", port.Port),
+					Host:   host,
+					Port:   port.Port,
+					Method: "POST",
+					Path:   "/api/webscans/testssl",
+					Payload: map[string]interface{}{
+						"name":   fmt.Sprintf("%s - TLS %s:%d", name, host, port.Port),
+						"target": fmt.Sprintf("%s:%d", host, port.Port),
+						"full":   true,
+					},
+				})
+			}
+
+			for _, cms := range cmsScanTypes {
+				reason := ""
+				if strings.Contains(banner, cms.keyword) {
+					reason = fmt.Sprintf("%s detected in the service banner on port %d", cms.name, port.Port)
+				} else if page := matchEvidence(pages, cms.keyword); page != "" {
+					reason = fmt.Sprintf("%s detected at %s", cms.name, page)
+				}
+				if reason == "" {
+					continue
+				}
+				add(models.ScanRecommendation{
+					Tool:   cms.scanType,
+					Reason: reason,
+					Host:   host,
+					Port:   port.Port,
+					Method: "POST",
+					Path:   "/api/cmsscans",
+					Payload: map[string]interface{}{
+						"name":      fmt.Sprintf("%s - %s %s", name, cms.name, host),
+						"target":    baseURL,
+						"scan_type": cms.scanType,
+					},
+				})
+			}
+
+			var graphqlPaths []string
+			for _, page := range pages {
+				if strings.Contains(strings.ToLower(page.url.Path), "graphql") {
+					graphqlPaths = append(graphqlPaths, page.url.Path)
+				}
+			}
+			if len(graphqlPaths) > 0 || strings.Contains(banner, "graphql") {
+				reason := fmt.Sprintf("GraphQL mentioned in the service banner on port %d", port.Port)
+				payload := map[string]interface{}{
+					"name":      fmt.Sprintf("%s - GraphQL %s", name, host),
+					"target":    baseURL,
+					"scan_type": "graphql",
+				}
+				if len(graphqlPaths) > 0 {
+					reason = fmt.Sprintf("GraphQL path %s seen on port %d", graphqlPaths[0], port.Port)
+					payload["config"] = map[string]interface{}{"graphql_endpoints": graphqlPaths}
+				}
+				add(models.ScanRecommendation{
+					Tool:    "graphql",
+					Reason:  reason,
+					Host:    host,
+					Port:    port.Port,
+					Method:  "POST",
+					Path:    "/api/apiscans",
+					Payload: payload,
+				})
+			}
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"scan_id":         scanID,
+		"recommendations": recommendations,
+		"total":           len(recommendations),
+	})
+}
+
+// webEvidence loads recent URLs from web scans that point at a technology
+// the recommendations care about
+func (h *ScanHandler) webEvidence(ctx context.Context) []webEvidence {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT url, COALESCE(title, '') FROM web_scan_results
+		WHERE url IS NOT NULL
+		  AND created_at > NOW() - INTERVAL '30 days'
+		  AND (url ILIKE '%graphql%' OR url ILIKE '%wp-content%' OR url ILIKE '%wp-login%'
+		       OR title ILIKE '%wordpress%' OR title ILIKE '%joomla%' OR title ILIKE '%drupal%')
+		ORDER BY created_at DESC
+		LIMIT 1000
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var evidence []webEvidence
+	for rows.Next() {
+		var raw, title string
+		if err := rows.Scan(&raw, &title); err != nil {
+			continue
+		}
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			evidence = append(evidence, webEvidence{url: u, title: title})
+		}
+	}
+	return evidence
+}
+
+// evidenceFor returns the evidence for hostname or ip on port
+func evidenceFor(evidence []webEvidence, hostname, ip string, port int) []webEvidence {
+	var matches []webEvidence
+	for _, e := range evidence {
+		h := strings.ToLower(e.url.Hostname())
+		if h != strings.ToLower(hostname) && h != ip {
+			continue
+		}
+		p := e.url.Port()
+		if p == "" {
+			p = "80"
+			if e.url.Scheme == "https" {
+				p = "443"
+			}
+		}
+		if p == strconv.Itoa(port) {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+// matchEvidence returns the first URL whose path or title mentions keyword
+func matchEvidence(pages []webEvidence, keyword string) string {
+	for _, page := range pages {
+		text := strings.ToLower(page.url.Path + " " + page.title)
+		if strings.Contains(text, keyword) || (keyword == "wordpress" && strings.Contains(text, "/wp-")) {
+			return page.url.String()
+		}
+	}
+	return ""
+}
+
+func isWebPort(port models.Port) bool {
+	if port.Protocol != "" && port.Protocol != "tcp" {
+		return false
+	}
+	if strings.Contains(port.Service, "http") {
+		return true
+	}
+	switch port.Port {
+	case 80, 443, 8000, 8080, 8443:
+		return true
+	}
+	return false
+}
+
+func isTLSPort(port models.Port) bool {
+	return port.Port == 443 || port.Port == 8443 ||
+		strings.Contains(port.Service, "https") || strings.HasPrefix(port.Service, "ssl")
+}
+
+// webURL builds the base URL of a web service, omitting default ports
+func webURL(host string, port int, tls bool) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if (tls && port == 443) || (!tls && port == 80) {
+		return fmt.Sprintf("%s://%s", scheme, host)
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, port)
+}
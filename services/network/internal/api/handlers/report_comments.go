@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportComment is a comment the team left on the scan or one of its
+// findings through the gateway's /api/comments
+type ReportComment struct {
+	Subject   string    `json:"subject"` // scan or finding
+	SubjectID string    `json:"subject_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"` // markdown
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// reportWantsComments reports whether a report request asked for the
+// comments with ?comments=true
+func reportWantsComments(c *fiber.Ctx) bool {
+	return c.QueryBool("comments")
+}
+
+// scanComments returns the comments on a scan of source and on its
+// findings, oldest first. The comments table belongs to the gateway; until
+// it has created it there are no comments.
+func (h *ReportHandler) scanComments(source, scanID string) ([]ReportComment, error) {
+	ctx := context.Background()
+	var exists bool
+	if err := h.db.Pool.QueryRow(ctx, `SELECT to_regclass('comments') IS NOT NULL`).Scan(&exists); err != nil || !exists {
+		return []ReportComment{}, err
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT subject, subject_id::text, author_name, body, created_at
+		FROM comments WHERE source = $1 AND scan_id::text = $2
+		ORDER BY created_at, id
+	`, source, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ReportComment{}
+	for rows.Next() {
+		var comment ReportComment
+		if err := rows.Scan(&comment.Subject, &comment.SubjectID, &comment.Author, &comment.Body, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// commentsTemplate renders the comments section of a report; the data needs
+// Comments and Labels fields. Bodies are shown as written, markdown
+// included, since html/template escapes them.
+const commentsTemplate = `{{define "comments"}}
+    <div class="section">
+        <div class="section-header">💬 {{index .Labels "comments"}} ({{len .Comments}})</div>
+        <div class="section-body">
+            {{range .Comments}}
+            <div class="comment">
+                <div class="comment-meta"><strong>{{.Author}}</strong> · {{.CreatedAt.Format "2006-01-02 15:04"}}{{if eq .Subject "finding"}} · <code>{{.SubjectID}}</code>{{end}}</div>
+                <div class="comment-body">{{.Body}}</div>
+            </div>
+            {{end}}
+        </div>
+    </div>
+{{end}}`
@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/knowledge"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/redact"
+)
+
+// ReportFinding is a report finding merged with its knowledge base entry, so
+// deliverables carry a written-up description instead of raw tool output
+type ReportFinding struct {
+	FindingID   string   `json:"finding_id"`
+	Title       string   `json:"title"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	Impact      string   `json:"impact,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	References  []string `json:"references,omitempty"`
+	Language    string   `json:"language,omitempty"` // language of the knowledge base entry used
+	Documented  bool     `json:"documented"`         // false when only the tool's own text was available
+	Affected    []string `json:"affected"`
+	// Owners are the owners of the affected hosts
+	Owners []owners.Owner `json:"owners,omitempty"`
+	// Evidence is the raw request and response of each affected location,
+	// when the tool kept them
+	Evidence []FindingEvidence `json:"evidence,omitempty"`
+}
+
+// FindingEvidence is what a tool sent and got back at an affected location
+type FindingEvidence struct {
+	Location    string `json:"location"`
+	Request     string `json:"request,omitempty"`
+	Response    string `json:"response,omitempty"`
+	CurlCommand string `json:"curl_command,omitempty"`
+}
+
+// VulnerabilityReport is a nuclei scan with its findings grouped by template
+type VulnerabilityReport struct {
+	Scan     vulnReportScan  `json:"scan"`
+	Language string          `json:"language"`
+	Findings []ReportFinding `json:"findings"`
+	// Redaction is the profile the report was sanitized with
+	Redaction string `json:"redaction,omitempty"`
+	// Comments are the team's comments on the scan and its findings, with
+	// ?comments=true
+	Comments []ReportComment `json:"comments,omitempty"`
+}
+
+type vulnReportScan struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Target      string     `json:"target"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// reportLabels are the headings of the findings section per language
+var reportLabels = map[string]map[string]string{
+	"en": {
+		"findings":    "Findings",
+		"description": "Description",
+		"impact":      "Business impact",
+		"remediation": "Remediation",
+		"references":  "References",
+		"affected":    "Affected",
+		"owners":      "Owners",
+		"none":        "No findings",
+		"target":      "Target",
+		"status":      "Status",
+		"created":     "Created",
+		"summary":     "Summary",
+		"generated":   "Generated by Security Scanner on",
+		"redacted":    "Redacted",
+		"comments":    "Comments",
+	},
+	"es": {
+		"findings":    "Hallazgos",
+		"description": "Descripción",
+		"impact":      "Impacto en el negocio",
+		"remediation": "Remediación",
+		"references":  "Referencias",
+		"affected":    "Afectados",
+		"owners":      "Responsables",
+		"none":        "Sin hallazgos",
+		"target":      "Objetivo",
+		"status":      "Estado",
+		"created":     "Creado",
+		"summary":     "Resumen",
+		"generated":   "Generado por Security Scanner el",
+		"redacted":    "Anonimizado",
+		"comments":    "Comentarios",
+	},
+}
+
+var reportSeverityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3, "info": 4}
+
+// labelsFor returns the report headings in lang, English when not translated
+func labelsFor(lang string) map[string]string {
+	if labels, ok := reportLabels[knowledge.NormalizeLanguage(lang)]; ok {
+		return labels
+	}
+	return reportLabels[knowledge.DefaultLanguage]
+}
+
+// reportLanguage is the ?lang= of a report request, English by default
+func reportLanguage(c *fiber.Ctx) string {
+	if lang := knowledge.NormalizeLanguage(c.Query("lang")); lang != "" {
+		return lang
+	}
+	return knowledge.DefaultLanguage
+}
+
+// sortFindings orders findings by severity, then title
+func sortFindings(findings []ReportFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		ri, okI := reportSeverityRank[findings[i].Severity]
+		rj, okJ := reportSeverityRank[findings[j].Severity]
+		if !okI {
+			ri = len(reportSeverityRank)
+		}
+		if !okJ {
+			rj = len(reportSeverityRank)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return findings[i].Title < findings[j].Title
+	})
+}
+
+// addOwner adds owner to the owners of a finding once
+func (f *ReportFinding) addOwner(owner *owners.Owner) {
+	if owner == nil {
+		return
+	}
+	for _, o := range f.Owners {
+		if o == *owner {
+			return
+		}
+	}
+	f.Owners = append(f.Owners, *owner)
+}
+
+// applyKnowledge fills a finding from its knowledge base entry
+func applyKnowledge(finding *ReportFinding, entry models.KnowledgeEntry) {
+	finding.Title = entry.Title
+	if entry.Severity != "" {
+		finding.Severity = entry.Severity
+	}
+	if entry.Description != "" {
+		finding.Description = entry.Description
+	}
+	finding.Impact = entry.Impact
+	finding.Remediation = entry.Remediation
+	if len(entry.References) > 0 {
+		finding.References = entry.References
+	}
+	finding.Language = entry.Language
+	finding.Documented = true
+}
+
+// networkFindings turns the open ports of a scan that have a knowledge base
+// entry into findings. Ports without an entry stay in the host listing only.
+func (h *ReportHandler) networkFindings(ctx context.Context, results []models.ScanResult, lang string) ([]ReportFinding, error) {
+	var ids []string
+	seen := map[string]bool{}
+	for _, result := range results {
+		for _, port := range result.Ports {
+			if port.State != "" && port.State != "open" {
+				continue
+			}
+			for _, id := range knowledge.PortFindingIDs(port) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	entries, err := knowledge.Lookup(ctx, h.db, ids, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*ReportFinding{}
+	var order []string
+	for _, result := range results {
+		for _, port := range result.Ports {
+			if port.State != "" && port.State != "open" {
+				continue
+			}
+			for _, id := range knowledge.PortFindingIDs(port) {
+				entry, ok := entries[id]
+				if !ok {
+					continue
+				}
+				finding, ok := byID[id]
+				if !ok {
+					finding = &ReportFinding{FindingID: id, Severity: "info", Affected: []string{}}
+					applyKnowledge(finding, entry)
+					byID[id] = finding
+					order = append(order, id)
+				}
+				finding.Affected = append(finding.Affected, fmt.Sprintf("%s:%d/%s", result.Host, port.Port, port.Protocol))
+				finding.addOwner(result.Owner)
+				break
+			}
+		}
+	}
+
+	findings := []ReportFinding{}
+	for _, id := range order {
+		findings = append(findings, *byID[id])
+	}
+	sortFindings(findings)
+	return findings, nil
+}
+
+// GetVulnJSONReport returns a nuclei scan's findings merged with the
+// knowledge base in ?lang=
+func (h *ReportHandler) GetVulnJSONReport(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+
+	report, err := h.getVulnerabilityReport(scanID, reportLanguage(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Vulnerability scan not found"})
+	}
+	if reportWantsComments(c) {
+		if report.Comments, err = h.scanComments("vulnerabilities", scanID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load comments"})
+		}
+	}
+	redactVulnerabilityReport(report, redactor)
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=vulnerabilities_%s.json", scanID))
+	c.Set("Content-Type", "application/json")
+
+	return c.JSON(report)
+}
+
+// GetVulnHTMLReport returns a nuclei scan's findings as a client-ready HTML
+// report in ?lang=
+func (h *ReportHandler) GetVulnHTMLReport(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+
+	report, err := h.getVulnerabilityReport(scanID, reportLanguage(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Vulnerability scan not found"})
+	}
+	if reportWantsComments(c) {
+		if report.Comments, err = h.scanComments("vulnerabilities", scanID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load comments"})
+		}
+	}
+	redactVulnerabilityReport(report, redactor)
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=vulnerabilities_%s.html", scanID))
+	c.Set("Content-Type", "text/html")
+
+	return c.SendString(h.generateVulnHTMLReport(report))
+}
+
+// getVulnerabilityReport reads a nuclei scan of the web service and groups
+// its findings by template, documented from the knowledge base when possible
+func (h *ReportHandler) getVulnerabilityReport(scanID, lang string) (*VulnerabilityReport, error) {
+	ctx := context.Background()
+
+	report := &VulnerabilityReport{Language: lang, Findings: []ReportFinding{}}
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT id::text, name, target, status, created_at, started_at, completed_at
+		FROM vulnerability_scans WHERE id = $1
+	`, scanID).Scan(&report.Scan.ID, &report.Scan.Name, &report.Scan.Target, &report.Scan.Status,
+		&report.Scan.CreatedAt, &report.Scan.StartedAt, &report.Scan.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := tagging.LoadOwners(ctx, h.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT template_id, template_name, severity, COALESCE(NULLIF(matched_at, ''), host),
+		       COALESCE(metadata->>'description', ''),
+		       COALESCE(ARRAY(SELECT jsonb_array_elements_text(CASE WHEN jsonb_typeof(metadata->'reference') = 'array' THEN metadata->'reference' ELSE '[]' END)), '{}'),
+		       COALESCE(request, ''), COALESCE(response, ''), COALESCE(curl_command, '')
+		FROM vulnerabilities WHERE scan_id = $1
+		ORDER BY created_at
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := map[string]*ReportFinding{}
+	var ids []string
+	for rows.Next() {
+		var templateID, name, severity, location, description string
+		var references []string
+		var evidence FindingEvidence
+		if err := rows.Scan(&templateID, &name, &severity, &location, &description, &references,
+			&evidence.Request, &evidence.Response, &evidence.CurlCommand); err != nil {
+			continue
+		}
+		finding, ok := byID[templateID]
+		if !ok {
+			finding = &ReportFinding{
+				FindingID:   templateID,
+				Title:       name,
+				Severity:    strings.ToLower(severity),
+				Description: description,
+				References:  references,
+				Affected:    []string{},
+			}
+			byID[templateID] = finding
+			ids = append(ids, templateID)
+		}
+		if !containsString(finding.Affected, location) {
+			finding.Affected = append(finding.Affected, location)
+			finding.addOwner(owners.Match(assignments, location))
+			if evidence.Request != "" || evidence.Response != "" || evidence.CurlCommand != "" {
+				evidence.Location = location
+				finding.Evidence = append(finding.Evidence, evidence)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := knowledge.Lookup(ctx, h.db, ids, lang)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		finding := byID[id]
+		if entry, ok := entries[id]; ok {
+			applyKnowledge(finding, entry)
+		}
+		report.Findings = append(report.Findings, *finding)
+	}
+	sortFindings(report.Findings)
+	return report, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// generateVulnHTMLReport creates an HTML report of a nuclei scan
+func (h *ReportHandler) generateVulnHTMLReport(report *VulnerabilityReport) string {
+	const htmlTemplate = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Security Scanner Report - {{.Scan.Name}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <div class="header">
+        <h1>🛡️ {{.Scan.Name}}</h1>
+        <div class="meta">
+            <span><strong>{{index .Labels "target"}}:</strong> {{.Scan.Target}}</span>
+            <span><strong>{{index .Labels "status"}}:</strong> <span class="badge badge-{{.Scan.Status}}">{{.Scan.Status}}</span></span>
+            <span><strong>{{index .Labels "created"}}:</strong> {{.Scan.CreatedAt.Format "2006-01-02 15:04:05"}}</span>
+            {{if .Redaction}}<span><strong>{{index .Labels "redacted"}}:</strong> {{.Redaction}}</span>{{end}}
+        </div>
+    </div>
+
+    <div class="section">
+        <div class="section-header">📊 {{index .Labels "summary"}}</div>
+        <div class="section-body">
+            {{range .Severities}}<span class="badge badge-{{.Name}}">{{.Name}}: {{.Count}}</span> {{end}}
+        </div>
+    </div>
+
+    {{template "findings" .}}
+
+    {{if .Comments}}{{template "comments" .}}{{end}}
+
+    <div class="footer">
+        <p>{{index .Labels "generated"}} {{.GeneratedAt}}</p>
+    </div>
+</body>
+</html>`
+
+	type severityCount struct {
+		Name  string
+		Count int
+	}
+	counts := map[string]int{}
+	for _, finding := range report.Findings {
+		counts[finding.Severity]++
+	}
+	var severities []severityCount
+	for _, name := range []string{"critical", "high", "medium", "low", "info"} {
+		if counts[name] > 0 {
+			severities = append(severities, severityCount{Name: name, Count: counts[name]})
+		}
+	}
+
+	data := struct {
+		Scan        vulnReportScan
+		Language    string
+		Findings    []ReportFinding
+		Severities  []severityCount
+		Labels      map[string]string
+		GeneratedAt string
+		Redaction   string
+		Comments    []ReportComment
+	}{
+		Scan:        report.Scan,
+		Language:    report.Language,
+		Findings:    report.Findings,
+		Severities:  severities,
+		Labels:      labelsFor(report.Language),
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Redaction:   report.Redaction,
+		Comments:    report.Comments,
+	}
+
+	tmpl, err := parseReportTemplate(htmlTemplate)
+	if err != nil {
+		return fmt.Sprintf("<html><body>Error generating report: %v</body></html>", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("<html><body>Error generating report: %v</body></html>", err)
+	}
+
+	return buf.String()
+}
+
+// parseReportTemplate parses a report page along with the shared style and
+// findings templates
+func parseReportTemplate(page string) (*template.Template, error) {
+	tmpl, err := template.New("report").Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(reportStyleTemplate); err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(commentsTemplate); err != nil {
+		return nil, err
+	}
+	return tmpl.Parse(findingsTemplate)
+}
+
+// findingsTemplate renders the findings section of a report; the data needs
+// Findings and Labels fields
+const findingsTemplate = `{{define "findings"}}
+    <div class="section">
+        <div class="section-header">🔎 {{index .Labels "findings"}} ({{len .Findings}})</div>
+        <div class="section-body">
+            {{range .Findings}}
+            <div class="finding">
+                <div class="host-header">
+                    <span><strong>{{.Title}}</strong> <code>{{.FindingID}}</code></span>
+                    <span class="badge badge-{{.Severity}}">{{.Severity}}</span>
+                </div>
+                <div class="finding-body">
+                    {{if .Description}}<h4>{{index $.Labels "description"}}</h4><p>{{.Description}}</p>{{end}}
+                    {{if .Impact}}<h4>{{index $.Labels "impact"}}</h4><p>{{.Impact}}</p>{{end}}
+                    {{if .Remediation}}<h4>{{index $.Labels "remediation"}}</h4><p>{{.Remediation}}</p>{{end}}
+                    {{if .References}}<h4>{{index $.Labels "references"}}</h4>{{range .References}}<div class="service-item">{{.}}</div>{{end}}{{end}}
+                    <h4>{{index $.Labels "affected"}} ({{len .Affected}})</h4>
+                    {{range .Affected}}<div class="service-item">{{.}}</div>{{end}}
+                    {{if .Owners}}<h4>{{index $.Labels "owners"}}</h4>{{range .Owners}}<div class="service-item">{{.Team}}{{if .BusinessUnit}} ({{.BusinessUnit}}){{end}}{{if .ContactEmail}} - {{.ContactEmail}}{{end}}</div>{{end}}{{end}}
+                </div>
+            </div>
+            {{else}}
+            <p>{{index .Labels "none"}}</p>
+            {{end}}
+        </div>
+    </div>
+{{end}}`
+
+// reportStyleTemplate is the stylesheet shared by the HTML reports
+const reportStyleTemplate = `{{define "style"}}
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; max-width: 1200px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); color: white; padding: 30px; border-radius: 10px; margin-bottom: 30px; }
+        .header h1 { font-size: 28px; margin-bottom: 10px; }
+        .header .meta { display: flex; gap: 20px; flex-wrap: wrap; font-size: 14px; opacity: 0.9; }
+        .section { background: white; border: 1px solid #e5e7eb; border-radius: 8px; margin-bottom: 20px; overflow: hidden; }
+        .section-header { background: #f9fafb; padding: 15px 20px; border-bottom: 1px solid #e5e7eb; font-weight: 600; font-size: 18px; }
+        .section-body { padding: 20px; }
+        .badge { display: inline-block; padding: 4px 12px; border-radius: 20px; font-size: 12px; font-weight: 600; text-transform: uppercase; }
+        .badge-completed { background: #dcfce7; color: #166534; }
+        .badge-failed { background: #fecaca; color: #991b1b; }
+        .badge-running { background: #dbeafe; color: #1e40af; }
+        .badge-resolved { background: #dcfce7; color: #166534; }
+        .host-card { border: 1px solid #e5e7eb; border-radius: 8px; margin-bottom: 15px; }
+        .host-header { background: #f3f4f6; padding: 12px 16px; display: flex; justify-content: space-between; align-items: center; }
+        .host-body { padding: 16px; }
+        .ports-table { width: 100%; border-collapse: collapse; margin-top: 10px; }
+        .ports-table th, .ports-table td { padding: 10px; text-align: left; border-bottom: 1px solid #e5e7eb; }
+        .ports-table th { background: #f9fafb; font-weight: 600; }
+        .port-open { color: #166534; }
+        .port-closed { color: #991b1b; }
+        .dns-record { display: flex; padding: 8px 0; border-bottom: 1px solid #f3f4f6; }
+        .dns-record:last-child { border-bottom: none; }
+        .dns-type { font-weight: 600; color: #667eea; min-width: 100px; }
+        .dns-value { color: #374151; word-break: break-all; }
+        .service-item { padding: 6px 0; border-bottom: 1px solid #f3f4f6; font-family: monospace; font-size: 13px; }
+        .service-item:last-child { border-bottom: none; }
+        .badge-critical { background: #7f1d1d; color: white; }
+        .badge-high { background: #fecaca; color: #991b1b; }
+        .badge-medium { background: #fed7aa; color: #9a3412; }
+        .badge-low { background: #fef9c3; color: #854d0e; }
+        .badge-info { background: #e0e7ff; color: #3730a3; }
+        .tag { display: inline-block; padding: 2px 8px; border-radius: 4px; background: #eef2ff; color: #4338ca; font-size: 12px; font-family: monospace; }
+        .finding { border: 1px solid #e5e7eb; border-radius: 8px; margin-bottom: 15px; }
+        .finding-body { padding: 16px; }
+        .finding-body h4 { margin: 12px 0 4px; font-size: 14px; color: #4b5563; }
+        .finding-body h4:first-child { margin-top: 0; }
+        .comment { border-left: 3px solid #667eea; padding: 8px 12px; margin-bottom: 12px; background: #f9fafb; }
+        .comment-meta { font-size: 13px; color: #6b7280; margin-bottom: 4px; }
+        .comment-body { white-space: pre-wrap; font-size: 14px; }
+        .footer { text-align: center; color: #6b7280; font-size: 14px; margin-top: 30px; padding: 20px; border-top: 1px solid #e5e7eb; }
+    </style>
+{{end}}`
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/redact"
+)
+
+// reportRedactor returns a redactor for the ?redact= profile of a report
+// request; without one the report is complete
+func reportRedactor(c *fiber.Ctx) (*redact.Redactor, error) {
+	profile, err := redact.Lookup(c.Query("redact"))
+	if err != nil {
+		return nil, err
+	}
+	return redact.New(profile), nil
+}
+
+// GetRedactionProfiles lists the profiles reports can be sanitized with
+func (h *ReportHandler) GetRedactionProfiles(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"profiles": redact.Profiles,
+		"custom":   []string{"ips", "hostnames", "screenshots", "requests"},
+	})
+}
+
+// redactScanReport masks the addresses and names of a scan report. Targets
+// and hosts go first so the names they introduce are also masked in logs
+// and DNS records.
+func redactScanReport(report *ScanReport, r *redact.Redactor) {
+	if !r.Active() {
+		return
+	}
+	report.Redaction = r.Profile().Name
+
+	report.Scan.Target = r.Target(report.Scan.Target)
+	for i := range report.Results {
+		result := &report.Results[i]
+		result.Host = r.Hostname(result.Host)
+		// Owners are internal contacts, not for whoever receives the report
+		result.Owner = nil
+		if result.Hostname != nil {
+			hostname := r.Hostname(*result.Hostname)
+			result.Hostname = &hostname
+		}
+	}
+
+	report.Scan.Name = r.Text(report.Scan.Name)
+	if report.Scan.ErrorMessage != nil {
+		msg := r.Text(*report.Scan.ErrorMessage)
+		report.Scan.ErrorMessage = &msg
+	}
+	for i := range report.Results {
+		result := &report.Results[i]
+		result.Services = r.Texts(result.Services)
+		if r.Profile().IPs {
+			// A MAC address identifies the device as much as its IP
+			result.MacAddress = nil
+		}
+	}
+	for i := range report.Logs {
+		report.Logs[i].Message = r.Text(report.Logs[i].Message)
+	}
+	redactFindings(report.Findings, r)
+	redactComments(report.Comments, r)
+}
+
+// redactVulnerabilityReport masks the target and affected locations of a
+// vulnerability report and leaves out its raw requests when asked to
+func redactVulnerabilityReport(report *VulnerabilityReport, r *redact.Redactor) {
+	if !r.Active() {
+		return
+	}
+	report.Redaction = r.Profile().Name
+	report.Scan.Target = r.Target(report.Scan.Target)
+	report.Scan.Name = r.Text(report.Scan.Name)
+	redactFindings(report.Findings, r)
+	redactComments(report.Comments, r)
+}
+
+// redactComments masks the addresses and names the team wrote in comments
+func redactComments(comments []ReportComment, r *redact.Redactor) {
+	for i := range comments {
+		comments[i].Body = r.Text(comments[i].Body)
+	}
+}
+
+// redactFindings masks where findings were found, and their evidence
+func redactFindings(findings []ReportFinding, r *redact.Redactor) {
+	for i := range findings {
+		finding := &findings[i]
+		for j, location := range finding.Affected {
+			finding.Affected[j] = r.URL(location)
+		}
+		finding.Owners = nil
+		if r.Requests() {
+			finding.Evidence = nil
+			continue
+		}
+		for j := range finding.Evidence {
+			evidence := &finding.Evidence[j]
+			evidence.Location = r.URL(evidence.Location)
+			evidence.Request = r.Text(evidence.Request)
+			evidence.Response = r.Text(evidence.Response)
+			evidence.CurlCommand = r.Text(evidence.CurlCommand)
+		}
+	}
+}
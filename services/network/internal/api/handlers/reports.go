@@ -5,15 +5,19 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
-	"html/template"
+	"net"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/redact"
 )
 
 type ReportHandler struct {
@@ -29,16 +33,42 @@ type ScanReport struct {
 	Scan    models.Scan       `json:"scan"`
 	Results []models.ScanResult `json:"results"`
 	Logs    []models.ScanLog    `json:"logs"`
+	// Findings are the open ports documented in the knowledge base, in Language
+	Language string          `json:"language,omitempty"`
+	Findings []ReportFinding `json:"findings,omitempty"`
+	// Tag is the asset tag the results were filtered by
+	Tag string `json:"tag,omitempty"`
+	// Redaction is the profile the report was sanitized with
+	Redaction string `json:"redaction,omitempty"`
+	// Comments are the team's comments on the scan and its findings, with
+	// ?comments=true
+	Comments []ReportComment `json:"comments,omitempty"`
 }
 
 // GetJSONReport returns scan results in JSON format
 func (h *ReportHandler) GetJSONReport(c *fiber.Ctx) error {
 	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
 
 	report, err := h.getScanReport(scanID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
+	if err := h.addTags(report, c.Query("tag")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load asset tags"})
+	}
+	if err := h.addFindings(report, reportLanguage(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load finding descriptions"})
+	}
+	if reportWantsComments(c) {
+		if report.Comments, err = h.scanComments("network", scanID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load comments"})
+		}
+	}
+	redactScanReport(report, redactor)
 
 	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=scan_%s.json", scanID))
 	c.Set("Content-Type", "application/json")
@@ -49,11 +79,27 @@ func (h *ReportHandler) GetJSONReport(c *fiber.Ctx) error {
 // GetHTMLReport returns scan results as an HTML report
 func (h *ReportHandler) GetHTMLReport(c *fiber.Ctx) error {
 	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
 
 	report, err := h.getScanReport(scanID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
+	if err := h.addTags(report, c.Query("tag")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load asset tags"})
+	}
+	if err := h.addFindings(report, reportLanguage(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load finding descriptions"})
+	}
+	if reportWantsComments(c) {
+		if report.Comments, err = h.scanComments("network", scanID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load comments"})
+		}
+	}
+	redactScanReport(report, redactor)
 
 	htmlContent := h.generateHTMLReport(report)
 
@@ -66,11 +112,19 @@ func (h *ReportHandler) GetHTMLReport(c *fiber.Ctx) error {
 // GetCSVReport returns scan results as a CSV file
 func (h *ReportHandler) GetCSVReport(c *fiber.Ctx) error {
 	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
 
 	report, err := h.getScanReport(scanID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
+	if err := h.addTags(report, c.Query("tag")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load asset tags"})
+	}
+	redactScanReport(report, redactor)
 
 	csvContent := h.generateCSVReport(report)
 
@@ -80,6 +134,35 @@ func (h *ReportHandler) GetCSVReport(c *fiber.Ctx) error {
 	return c.SendString(csvContent)
 }
 
+// GetXMLReport returns scan results as nmap-compatible XML so masscan and DNS
+// scans can be imported into Metasploit, Faraday and other nmap XML consumers
+func (h *ReportHandler) GetXMLReport(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	redactor, err := reportRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+
+	report, err := h.getScanReport(scanID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if err := h.addTags(report, c.Query("tag")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load asset tags"})
+	}
+	redactScanReport(report, redactor)
+
+	xmlContent, err := h.generateNmapXMLReport(report)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate XML report"})
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=scan_%s.xml", scanID))
+	c.Set("Content-Type", "application/xml")
+
+	return c.Send(xmlContent)
+}
+
 // getScanReport retrieves a complete scan report from database
 func (h *ReportHandler) getScanReport(scanID string) (*ScanReport, error) {
 	ctx := context.Background()
@@ -159,6 +242,59 @@ func (h *ReportHandler) getScanReport(scanID string) (*ScanReport, error) {
 	}, nil
 }
 
+// addTags sets the asset tags and owner of each host and, when tag is set,
+// keeps only the hosts with that tag
+func (h *ReportHandler) addTags(report *ScanReport, tag string) error {
+	hosts := make([]string, len(report.Results))
+	for i, result := range report.Results {
+		hosts[i] = result.Host
+	}
+	assets, err := tagging.Lookup(context.Background(), h.db, hosts)
+	if err != nil {
+		return err
+	}
+	assignments, err := tagging.LoadOwners(context.Background(), h.db)
+	if err != nil {
+		return err
+	}
+
+	tag = strings.ToLower(tag)
+	results := []models.ScanResult{}
+	for _, result := range report.Results {
+		if asset, ok := assets[strings.ToLower(result.Host)]; ok {
+			result.Tags = asset.Tags
+			result.Criticality = asset.Criticality
+		}
+		result.Owner = owners.Match(assignments, hostNames(result)...)
+		if tag != "" && !containsString(result.Tags, tag) {
+			continue
+		}
+		results = append(results, result)
+	}
+	report.Results = results
+	report.Tag = tag
+	return nil
+}
+
+// hostNames returns the IP and, when resolved, the host name of a result
+func hostNames(result models.ScanResult) []string {
+	if result.Hostname != nil && *result.Hostname != "" {
+		return []string{result.Host, *result.Hostname}
+	}
+	return []string{result.Host}
+}
+
+// addFindings documents the report's open ports from the knowledge base in lang
+func (h *ReportHandler) addFindings(report *ScanReport, lang string) error {
+	findings, err := h.networkFindings(context.Background(), report.Results, lang)
+	if err != nil {
+		return err
+	}
+	report.Language = lang
+	report.Findings = findings
+	return nil
+}
+
 // generateHTMLReport creates an HTML report from scan data
 func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
 	const htmlTemplate = `<!DOCTYPE html>
@@ -167,36 +303,7 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Security Scanner Report - {{.Scan.Name}}</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; max-width: 1200px; margin: 0 auto; padding: 20px; }
-        .header { background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); color: white; padding: 30px; border-radius: 10px; margin-bottom: 30px; }
-        .header h1 { font-size: 28px; margin-bottom: 10px; }
-        .header .meta { display: flex; gap: 20px; flex-wrap: wrap; font-size: 14px; opacity: 0.9; }
-        .section { background: white; border: 1px solid #e5e7eb; border-radius: 8px; margin-bottom: 20px; overflow: hidden; }
-        .section-header { background: #f9fafb; padding: 15px 20px; border-bottom: 1px solid #e5e7eb; font-weight: 600; font-size: 18px; }
-        .section-body { padding: 20px; }
-        .badge { display: inline-block; padding: 4px 12px; border-radius: 20px; font-size: 12px; font-weight: 600; text-transform: uppercase; }
-        .badge-completed { background: #dcfce7; color: #166534; }
-        .badge-failed { background: #fecaca; color: #991b1b; }
-        .badge-running { background: #dbeafe; color: #1e40af; }
-        .badge-resolved { background: #dcfce7; color: #166534; }
-        .host-card { border: 1px solid #e5e7eb; border-radius: 8px; margin-bottom: 15px; }
-        .host-header { background: #f3f4f6; padding: 12px 16px; display: flex; justify-content: space-between; align-items: center; }
-        .host-body { padding: 16px; }
-        .ports-table { width: 100%; border-collapse: collapse; margin-top: 10px; }
-        .ports-table th, .ports-table td { padding: 10px; text-align: left; border-bottom: 1px solid #e5e7eb; }
-        .ports-table th { background: #f9fafb; font-weight: 600; }
-        .port-open { color: #166534; }
-        .port-closed { color: #991b1b; }
-        .dns-record { display: flex; padding: 8px 0; border-bottom: 1px solid #f3f4f6; }
-        .dns-record:last-child { border-bottom: none; }
-        .dns-type { font-weight: 600; color: #667eea; min-width: 100px; }
-        .dns-value { color: #374151; word-break: break-all; }
-        .service-item { padding: 6px 0; border-bottom: 1px solid #f3f4f6; font-family: monospace; font-size: 13px; }
-        .service-item:last-child { border-bottom: none; }
-        .footer { text-align: center; color: #6b7280; font-size: 14px; margin-top: 30px; padding: 20px; border-top: 1px solid #e5e7eb; }
-    </style>
+    {{template "style"}}
 </head>
 <body>
     <div class="header">
@@ -206,6 +313,8 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
             <span><strong>Type:</strong> {{.Scan.ScanType}}</span>
             <span><strong>Status:</strong> <span class="badge badge-{{.Scan.Status}}">{{.Scan.Status}}</span></span>
             <span><strong>Created:</strong> {{.Scan.CreatedAt.Format "2006-01-02 15:04:05"}}</span>
+            {{if .Tag}}<span><strong>Tag:</strong> {{.Tag}}</span>{{end}}
+            {{if .Redaction}}<span><strong>Redacted:</strong> {{.Redaction}}</span>{{end}}
         </div>
     </div>
 
@@ -218,6 +327,10 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
         </div>
     </div>
 
+    {{if .Findings}}{{template "findings" .}}{{end}}
+
+    {{if .Comments}}{{template "comments" .}}{{end}}
+
     {{if .IsDNSScan}}
     <div class="section">
         <div class="section-header">🌐 DNS Records</div>
@@ -254,6 +367,8 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
                     <span class="badge badge-{{if eq .State "up"}}completed{{else if eq .State "resolved"}}resolved{{else}}failed{{end}}">{{.State}}</span>
                 </div>
                 <div class="host-body">
+                    {{if .Tags}}<p><strong>Tags:</strong> {{range .Tags}}<span class="tag">{{.}}</span> {{end}}{{if .Criticality}}<span class="badge badge-{{.Criticality}}">{{.Criticality}}</span>{{end}}</p>{{end}}
+                    {{with .Owner}}<p><strong>Owner:</strong> {{.Team}}{{if .BusinessUnit}} ({{.BusinessUnit}}){{end}}{{if .ContactEmail}} - <a href="mailto:{{.ContactEmail}}">{{.ContactEmail}}</a>{{end}}</p>{{end}}
                     {{if .MacAddress}}<p><strong>MAC:</strong> {{.MacAddress}}{{if .MacVendor}} - {{.MacVendor}}{{end}}</p>{{end}}
                     {{if .Ports}}
                     <table class="ports-table">
@@ -328,6 +443,11 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
 		GeneratedAt     string
 		IsDNSScan       bool
 		TotalDNSRecords int
+		Findings        []ReportFinding
+		Labels          map[string]string
+		Tag             string
+		Redaction       string
+		Comments        []ReportComment
 	}{
 		Scan:            report.Scan,
 		Results:         report.Results,
@@ -335,9 +455,14 @@ func (h *ReportHandler) generateHTMLReport(report *ScanReport) string {
 		GeneratedAt:     time.Now().Format("2006-01-02 15:04:05"),
 		IsDNSScan:       isDNSScan,
 		TotalDNSRecords: totalDNSRecords,
+		Findings:        report.Findings,
+		Labels:          labelsFor(report.Language),
+		Tag:             report.Tag,
+		Redaction:       report.Redaction,
+		Comments:        report.Comments,
 	}
 
-	tmpl, err := template.New("report").Parse(htmlTemplate)
+	tmpl, err := parseReportTemplate(htmlTemplate)
 	if err != nil {
 		return fmt.Sprintf("<html><body>Error generating report: %v</body></html>", err)
 	}
@@ -356,7 +481,7 @@ func (h *ReportHandler) generateCSVReport(report *ScanReport) string {
 	writer := csv.NewWriter(&buf)
 
 	// Write header
-	writer.Write([]string{"Host", "Hostname", "State", "MAC Address", "MAC Vendor", "Port", "Protocol", "Port State", "Service", "Product", "Version"})
+	writer.Write([]string{"Host", "Hostname", "State", "MAC Address", "MAC Vendor", "Port", "Protocol", "Port State", "Service", "Product", "Version", "Tags", "Criticality", "Owner Team", "Contact Email", "Business Unit"})
 
 	for _, result := range report.Results {
 		hostname := ""
@@ -371,10 +496,16 @@ func (h *ReportHandler) generateCSVReport(report *ScanReport) string {
 		if result.MacVendor != nil {
 			macVendor = *result.MacVendor
 		}
+		tags := strings.Join(result.Tags, ";")
+		var owner owners.Owner
+		if result.Owner != nil {
+			owner = *result.Owner
+		}
 
 		if len(result.Ports) == 0 {
 			// Host with no ports
-			writer.Write([]string{result.Host, hostname, result.State, macAddress, macVendor, "", "", "", "", "", ""})
+			writer.Write([]string{result.Host, hostname, result.State, macAddress, macVendor, "", "", "", "", "", "", tags, result.Criticality,
+				owner.Team, owner.ContactEmail, owner.BusinessUnit})
 		} else {
 			// Write a row for each port
 			for _, port := range result.Ports {
@@ -390,6 +521,11 @@ func (h *ReportHandler) generateCSVReport(report *ScanReport) string {
 					port.Service,
 					port.Product,
 					port.Version,
+					tags,
+					result.Criticality,
+					owner.Team,
+					owner.ContactEmail,
+					owner.BusinessUnit,
 				})
 			}
 		}
@@ -399,6 +535,282 @@ func (h *ReportHandler) generateCSVReport(report *ScanReport) string {
 	return buf.String()
 }
 
+// nmapRun mirrors the subset of the nmap XML schema understood by importers
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type     string `xml:"type,attr"`
+	Protocol string `xml:"protocol,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64          `xml:"starttime,attr,omitempty"`
+	EndTime   int64          `xml:"endtime,attr,omitempty"`
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapStatus   `xml:"state"`
+	Service  *nmapService `xml:"service,omitempty"`
+}
+
+type nmapService struct {
+	Name      string `xml:"name,attr"`
+	Product   string `xml:"product,attr,omitempty"`
+	Version   string `xml:"version,attr,omitempty"`
+	ExtraInfo string `xml:"extrainfo,attr,omitempty"`
+	Method    string `xml:"method,attr"`
+	Conf      int    `xml:"conf,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// generateNmapXMLReport converts any scan (nmap, masscan or dns) into nmap XML
+func (h *ReportHandler) generateNmapXMLReport(report *ScanReport) ([]byte, error) {
+	scan := report.Scan
+
+	start := scan.CreatedAt
+	if scan.StartedAt != nil {
+		start = *scan.StartedAt
+	}
+	end := time.Now()
+	if scan.CompletedAt != nil {
+		end = *scan.CompletedAt
+	}
+
+	run := nmapRun{
+		Scanner:          scan.Scanner,
+		Args:             fmt.Sprintf("%s %s", scan.Scanner, scan.Target),
+		Start:            start.Unix(),
+		StartStr:         start.Format(time.ANSIC),
+		Version:          "7.94",
+		XMLOutputVersion: "1.05",
+		ScanInfo:         nmapScanInfo{Type: "syn", Protocol: "tcp"},
+	}
+
+	// Importers such as Metasploit only accept the "nmap" scanner name
+	if scan.Scanner != "nmap" {
+		run.Scanner = "nmap"
+		run.Args = fmt.Sprintf("%s (converted from %s)", run.Args, scan.Scanner)
+	}
+
+	var hosts []nmapHost
+	if scan.Scanner == "dns" {
+		hosts = dnsResultsToNmapHosts(report.Results)
+	} else {
+		hosts = portResultsToNmapHosts(report.Results)
+	}
+
+	up := 0
+	for i := range hosts {
+		hosts[i].StartTime = start.Unix()
+		hosts[i].EndTime = end.Unix()
+		if hosts[i].Status.State == "up" {
+			up++
+		}
+	}
+	run.Hosts = hosts
+
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    end.Unix(),
+			TimeStr: end.Format(time.ANSIC),
+			Elapsed: fmt.Sprintf("%.2f", end.Sub(start).Seconds()),
+			Exit:    "success",
+		},
+		Hosts: nmapHostStats{Up: up, Down: len(hosts) - up, Total: len(hosts)},
+	}
+	if scan.Status == "failed" {
+		run.RunStats.Finished.Exit = "error"
+	}
+
+	out, err := xml.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<!DOCTYPE nmaprun>\n")
+	buf.Write(out)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// portResultsToNmapHosts converts nmap/masscan results into nmap XML hosts
+func portResultsToNmapHosts(results []models.ScanResult) []nmapHost {
+	hosts := []nmapHost{}
+	for _, result := range results {
+		host := nmapHost{
+			Status:    nmapStatus{State: nmapHostState(result.State), Reason: "user-set"},
+			Addresses: []nmapAddress{{Addr: result.Host, AddrType: addrType(result.Host)}},
+		}
+		if result.MacAddress != nil && *result.MacAddress != "" {
+			mac := nmapAddress{Addr: *result.MacAddress, AddrType: "mac"}
+			if result.MacVendor != nil {
+				mac.Vendor = *result.MacVendor
+			}
+			host.Addresses = append(host.Addresses, mac)
+		}
+		if result.Hostname != nil && *result.Hostname != "" {
+			host.Hostnames = []nmapHostname{{Name: *result.Hostname, Type: "PTR"}}
+		}
+
+		for _, port := range result.Ports {
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			state := port.State
+			if state == "" {
+				state = "open"
+			}
+			p := nmapPort{
+				Protocol: protocol,
+				PortID:   port.Port,
+				State:    nmapStatus{State: state, Reason: "syn-ack"},
+			}
+			if port.Service != "" {
+				p.Service = &nmapService{
+					Name:      port.Service,
+					Product:   port.Product,
+					Version:   port.Version,
+					ExtraInfo: port.ExtraInfo,
+					Method:    "table",
+					Conf:      3,
+				}
+			}
+			host.Ports = append(host.Ports, p)
+		}
+
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// dnsResultsToNmapHosts turns resolved DNS records into one host per IP address,
+// keeping every name that resolved to it as a hostname
+func dnsResultsToNmapHosts(results []models.ScanResult) []nmapHost {
+	hostnames := map[string][]string{}
+	order := []string{}
+
+	addName := func(ip, name string) {
+		if net.ParseIP(ip) == nil {
+			return
+		}
+		if _, ok := hostnames[ip]; !ok {
+			order = append(order, ip)
+		}
+		for _, existing := range hostnames[ip] {
+			if existing == name {
+				return
+			}
+		}
+		hostnames[ip] = append(hostnames[ip], name)
+	}
+
+	for _, result := range results {
+		for _, record := range result.Services {
+			parts := strings.SplitN(record, ": ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "A", "AAAA":
+				addName(strings.TrimSpace(parts[1]), result.Host)
+			case "SUBDOMAIN":
+				// Stored as "sub.example.com -> 1.2.3.4"
+				mapping := strings.SplitN(parts[1], " -> ", 2)
+				if len(mapping) == 2 {
+					addName(strings.TrimSpace(mapping[1]), strings.TrimSpace(mapping[0]))
+				}
+			}
+		}
+	}
+
+	hosts := []nmapHost{}
+	for _, ip := range order {
+		host := nmapHost{
+			Status:    nmapStatus{State: "up", Reason: "user-set"},
+			Addresses: []nmapAddress{{Addr: ip, AddrType: addrType(ip)}},
+		}
+		for _, name := range hostnames[ip] {
+			host.Hostnames = append(host.Hostnames, nmapHostname{Name: name, Type: "user"})
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// nmapHostState maps stored host states to nmap's up/down
+func nmapHostState(state string) string {
+	switch strings.ToLower(state) {
+	case "up", "open", "resolved":
+		return "up"
+	case "":
+		return "unknown"
+	default:
+		return "down"
+	}
+}
+
+// addrType returns the nmap address type for an IP
+func addrType(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip != nil && ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
 // Ensure uuid is used (for type compatibility)
 var _ = uuid.UUID{}
 // Ensure json is used
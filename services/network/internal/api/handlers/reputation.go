@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/reputation"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+type ReputationHandler struct {
+	db       *database.Database
+	enricher *reputation.Enricher
+}
+
+// NewReputationHandler creates the handler; enricher is nil when enrichment is disabled
+func NewReputationHandler(db *database.Database, enricher *reputation.Enricher) *ReputationHandler {
+	return &ReputationHandler{db: db, enricher: enricher}
+}
+
+// reputationAssets are the assets an IP belongs to
+type reputationAssets struct {
+	Subdomains []string `json:"subdomains"`
+	Hostnames  []string `json:"hostnames"`
+	Scans      int      `json:"scans"`
+}
+
+type assetReputation struct {
+	*models.IPReputation
+	Assets reputationAssets `json:"assets"`
+}
+
+// ListReputation lists checked IPs, worst first. ?malicious=true keeps only
+// IPs flagged by a blocklist or above the abuse score threshold.
+func (h *ReputationHandler) ListReputation(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT `+reputation.Columns+` FROM ip_reputation
+		WHERE NOT $1 OR malicious
+		ORDER BY malicious DESC, COALESCE(abuse_score, 0) DESC, cardinality(spamhaus_lists) DESC, ip
+		LIMIT $2
+	`, c.QueryBool("malicious"), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reputation data"})
+	}
+	var reputations []*models.IPReputation
+	for rows.Next() {
+		rep, err := reputation.ScanRow(rows)
+		if err != nil {
+			continue
+		}
+		reputations = append(reputations, rep)
+	}
+	rows.Close()
+
+	results := []assetReputation{}
+	for _, rep := range reputations {
+		results = append(results, assetReputation{IPReputation: rep, Assets: h.assets(rep.IP)})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(results)))
+	return c.JSON(fiber.Map{
+		"results": results,
+		"total":   len(results),
+	})
+}
+
+// GetReputation returns the stored reputation of an IP and the assets using it
+func (h *ReputationHandler) GetReputation(c *fiber.Ctx) error {
+	ip := c.Params("ip")
+	if net.ParseIP(ip) == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid IP address"})
+	}
+
+	row := h.db.Pool.QueryRow(context.Background(), `SELECT `+reputation.Columns+` FROM ip_reputation WHERE ip = $1`, ip)
+	rep, err := reputation.ScanRow(row)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "IP has not been checked yet"})
+	}
+	return c.JSON(assetReputation{IPReputation: rep, Assets: h.assets(ip)})
+}
+
+// RefreshReputation checks an IP right away instead of waiting for the next run
+func (h *ReputationHandler) RefreshReputation(c *fiber.Ctx) error {
+	if h.enricher == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "IP reputation enrichment is disabled (REPUTATION_ENABLED=false)"})
+	}
+	ip := c.Params("ip")
+	if net.ParseIP(ip) == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid IP address"})
+	}
+
+	rep, err := h.enricher.Refresh(context.Background(), ip)
+	if errors.Is(err, reputation.ErrRateLimited) {
+		return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(assetReputation{IPReputation: rep, Assets: h.assets(ip)})
+}
+
+// assets finds the subdomains that resolved to ip and the scans that found it
+func (h *ReputationHandler) assets(ip string) reputationAssets {
+	ctx := context.Background()
+	assets := reputationAssets{Subdomains: []string{}, Hostnames: []string{}}
+
+	if rows, err := h.db.Pool.Query(ctx, `
+		SELECT DISTINCT subdomain FROM subdomain_results WHERE $1 = ANY(ip_addresses) ORDER BY subdomain LIMIT 100
+	`, ip); err == nil {
+		for rows.Next() {
+			var subdomain string
+			if rows.Scan(&subdomain) == nil {
+				assets.Subdomains = append(assets.Subdomains, subdomain)
+			}
+		}
+		rows.Close()
+	}
+
+	if rows, err := h.db.Pool.Query(ctx, `
+		SELECT DISTINCT hostname FROM scan_results WHERE host = $1 AND hostname IS NOT NULL AND hostname <> '' LIMIT 100
+	`, ip); err == nil {
+		for rows.Next() {
+			var hostname string
+			if rows.Scan(&hostname) == nil {
+				assets.Hostnames = append(assets.Hostnames, hostname)
+			}
+		}
+		rows.Close()
+	}
+
+	h.db.Pool.QueryRow(ctx, `SELECT COUNT(DISTINCT scan_id) FROM scan_results WHERE host = $1`, ip).Scan(&assets.Scans)
+	return assets
+}
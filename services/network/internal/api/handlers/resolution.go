@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/resolution"
+)
+
+// hostConsistency is a scanned host and what recon resolved for it
+type hostConsistency struct {
+	Host     string  `json:"host"`
+	Hostname *string `json:"hostname,omitempty"`
+	*models.ResolutionCheck
+}
+
+// GetScanConsistency compares the addresses a scan reached with recon's DNS
+// resolutions of the same names. Hosts recon knows nothing about are left
+// out; ?status=stale_dns or ?status=cdn keeps only the mismatches of a kind.
+func (h *ScanHandler) GetScanConsistency(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	ctx := context.Background()
+
+	var target string
+	if err := h.db.Pool.QueryRow(ctx, `SELECT target FROM scans WHERE id = $1`, scanID).Scan(&target); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `SELECT host, hostname FROM scan_results WHERE scan_id = $1 ORDER BY host`, scanID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+	}
+	var results []models.ScanResult
+	for rows.Next() {
+		var result models.ScanResult
+		if err := rows.Scan(&result.Host, &result.Hostname); err != nil {
+			continue
+		}
+		results = append(results, result)
+	}
+	rows.Close()
+
+	checks, err := resolution.Check(ctx, h.db, resolution.Hosts(target, results))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to compare with recon resolutions"})
+	}
+
+	status := c.Query("status")
+	summary := map[string]int{resolution.StatusConsistent: 0, resolution.StatusStaleDNS: 0, resolution.StatusCDN: 0, "unknown": 0}
+	hosts := []hostConsistency{}
+	for _, result := range results {
+		check, ok := checks[result.Host]
+		if !ok {
+			summary["unknown"]++
+			continue
+		}
+		summary[check.Status]++
+		if status == "" || status == check.Status {
+			hosts = append(hosts, hostConsistency{Host: result.Host, Hostname: result.Hostname, ResolutionCheck: check})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"scan_id": scanID,
+		"target":  target,
+		"summary": summary,
+		"hosts":   hosts,
+	})
+}
@@ -10,9 +10,26 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
-	"github.com/nmap-scanner/backend-go/internal/scanner"
+	"github.com/security-scanner/network-service/internal/agents"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/features"
+	"github.com/security-scanner/network-service/internal/hooks"
+	"github.com/security-scanner/network-service/internal/jobs"
+	"github.com/security-scanner/network-service/internal/kubejobs"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/porthistory"
+	"github.com/security-scanner/network-service/internal/reputation"
+	"github.com/security-scanner/network-service/internal/resolution"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/shared/pkg/events"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type ScanHandler struct {
@@ -20,17 +37,80 @@ type ScanHandler struct {
 	nmapScanner    *scanner.Scanner
 	masscanScanner *scanner.MasscanScanner
 	dnsScanner     *scanner.DNSScanner
+	nativeScanner  *scanner.NativeScanner
+	naabuScanner   *scanner.NaabuScanner
+	pipeline       *scanner.PipelineScanner
+	simulator      *scanner.Simulator
+	events         *events.Bus
+	jobs           *jobs.Tracker
+	agents         *agents.Registry
+	flags          *features.Store
+	tagger         *tagging.Engine
+	hooks          *hooks.Runner
+	kube           *kubejobs.Backend
+	runner         string
+	scope          *scope.Checker
 }
 
-func NewScanHandler(db *database.Database, nmapScanner *scanner.Scanner, masscanScanner *scanner.MasscanScanner, dnsScanner *scanner.DNSScanner) *ScanHandler {
+func NewScanHandler(db *database.Database, nmapScanner *scanner.Scanner, masscanScanner *scanner.MasscanScanner, dnsScanner *scanner.DNSScanner, nativeScanner *scanner.NativeScanner, naabuScanner *scanner.NaabuScanner, simulator *scanner.Simulator, bus *events.Bus, scanJobs *jobs.Tracker, agentRegistry *agents.Registry, flags *features.Store) *ScanHandler {
 	return &ScanHandler{
 		db:             db,
 		nmapScanner:    nmapScanner,
 		masscanScanner: masscanScanner,
 		dnsScanner:     dnsScanner,
+		nativeScanner:  nativeScanner,
+		naabuScanner:   naabuScanner,
+		pipeline:       scanner.NewPipelineScanner(nmapScanner, masscanScanner),
+		simulator:      simulator,
+		events:         bus,
+		jobs:           scanJobs,
+		agents:         agentRegistry,
+		flags:          flags,
 	}
 }
 
+// SetTagger tags the hosts of every completed scan with the asset tag rules
+func (h *ScanHandler) SetTagger(tagger *tagging.Engine) {
+	h.tagger = tagger
+}
+
+// SetHooks runs the pre and post hooks of scans' templates and projects
+func (h *ScanHandler) SetHooks(runner *hooks.Runner) {
+	h.hooks = runner
+}
+
+// SetKubernetes lets scans pick the network zone their Kubernetes Job runs in
+func (h *ScanHandler) SetKubernetes(b *kubejobs.Backend) {
+	h.kube = b
+}
+
+// SetScope rejects scans of targets outside the scope rules
+func (h *ScanHandler) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
+// SetRunner records runner as the replica running the scans this handler
+// starts, so the scans of a replica that stops can be told apart
+func (h *ScanHandler) SetRunner(runner string) {
+	h.runner = runner
+}
+
+// GetZones lists the network zones scans can run in, and the scanners that
+// run as Kubernetes Jobs
+func (h *ScanHandler) GetZones(c *fiber.Ctx) error {
+	scanners := []string{}
+	for _, name := range []string{"nmap", "masscan", "naabu"} {
+		if h.kube.Handles(name) {
+			scanners = append(scanners, name)
+		}
+	}
+	zones := h.kube.Zones()
+	if zones == nil {
+		zones = []string{}
+	}
+	return c.JSON(fiber.Map{"zones": zones, "scanners": scanners})
+}
+
 // determineScannerType returns the scanner name based on scan_type
 func determineScannerType(scanType string) string {
 	scanTypeLower := strings.ToLower(scanType)
@@ -39,11 +119,68 @@ func determineScannerType(scanType string) string {
 		return "masscan"
 	case strings.HasPrefix(scanTypeLower, "dns"):
 		return "dns"
+	case strings.HasPrefix(scanTypeLower, "native"):
+		return "native"
+	case strings.HasPrefix(scanTypeLower, "pipeline"):
+		return "pipeline"
+	case strings.HasPrefix(scanTypeLower, "naabu"):
+		return "naabu"
 	default:
 		return "nmap"
 	}
 }
 
+// scannerFor returns the scanner requested explicitly, or the one of the scan_type
+func scannerFor(req models.CreateScanRequest) string {
+	if req.Scanner != "" {
+		return strings.ToLower(req.Scanner)
+	}
+	return determineScannerType(req.ScanType)
+}
+
+// applyTemplate fills whatever the request leaves out from its stored
+// template (template_id), returning the response to answer when it fails
+func (h *ScanHandler) applyTemplate(req *models.CreateScanRequest) (int, fiber.Map) {
+	if req.TemplateID == nil {
+		return 0, nil
+	}
+	var scanType, scannerName string
+	var nmapArguments, ports *string
+	var rate *int
+	var configuration map[string]interface{}
+	err := h.db.Pool.QueryRow(context.Background(), `
+		SELECT scan_type, scanner, nmap_arguments, ports, rate, configuration FROM scan_templates WHERE id = $1
+	`, *req.TemplateID).Scan(&scanType, &scannerName, &nmapArguments, &ports, &rate, &configuration)
+	if err != nil {
+		return 404, fiber.Map{"error": "Template not found"}
+	}
+
+	if req.ScanType == "" {
+		req.ScanType = scanType
+	}
+	if req.Scanner == "" {
+		req.Scanner = scannerName
+	}
+	if req.NmapArguments == nil {
+		req.NmapArguments = nmapArguments
+	}
+	if req.Configuration == nil {
+		req.Configuration = configuration
+	}
+	if scannerName == "masscan" || scannerName == "pipeline" {
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		if _, ok := req.Configuration["ports"]; !ok && ports != nil {
+			req.Configuration["ports"] = *ports
+		}
+		if _, ok := req.Configuration["rate"]; !ok && rate != nil {
+			req.Configuration["rate"] = float64(*rate)
+		}
+	}
+	return 0, nil
+}
+
 // cleanTarget extracts hostname from URL if needed
 func cleanTarget(target string) string {
 	target = strings.TrimSpace(target)
@@ -68,84 +205,410 @@ func (h *ScanHandler) CreateScan(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	if status, body := h.applyTemplate(&req); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+	req.Project = requestTenant(c)
+
 	// Validate required fields
 	if req.Name == "" || req.Target == "" || req.ScanType == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "name, target, and scan_type are required"})
+		return c.Status(400).JSON(fiber.Map{"error": "name, target, and scan_type (or template_id) are required"})
+	}
+
+	// Drop repeated and overlapping targets; what was left out is kept in
+	// the scan's configuration
+	target, collapsed := scanner.DedupTargets(req.Target)
+	if len(collapsed) > 0 {
+		req.Target = target
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		req.Configuration["collapsed_targets"] = collapsed
 	}
 
 	// Clean the target (extract hostname from URL if needed)
 	req.Target = cleanTarget(req.Target)
 
-	// Determine scanner type based on scan_type
-	scanner := determineScannerType(req.ScanType)
+	// Determine scanner type based on scanner or scan_type
+	scanner := scannerFor(req)
+	switch scanner {
+	case "nmap", "masscan", "dns", "native", "pipeline", "naabu":
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "scanner must be nmap, masscan, dns, native, pipeline or naabu"})
+	}
+
+	if err := validatePortOptions(req, scanner); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if scanner == "naabu" {
+		if _, err := h.naabuConfig(req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if err := applyHostTimeout(&req, scanner); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.NmapArguments != nil {
+		if status, body := nmapArgumentsError(c, *req.NmapArguments); status != 0 {
+			return c.Status(status).JSON(body)
+		}
+	}
+	if status, body := advancedOptionsError(c, req, scanner); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+	if status, body := masscanAdapterError(c, req.Configuration, scanner); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+	if err := validateResolvers(req.Configuration, scanner); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.validateBruteForce(req.Configuration, scanner); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if status, body := scopeError(h.scope, req.Project, req.Simulate, req.Target); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+	if req.Advanced != nil {
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		req.Configuration["advanced"] = req.Advanced
+	}
+
+	// The zone places the scan's Kubernetes Job on the nodes of a network
+	// zone
+	if req.Zone != "" {
+		switch {
+		case req.AgentID != nil || req.Simulate:
+			return c.Status(400).JSON(fiber.Map{"error": "zone only applies to scans run as Kubernetes jobs"})
+		case !h.kube.Handles(scanner):
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("%s scans don't run as Kubernetes jobs", scanner)})
+		case !h.kube.HasZone(req.Zone):
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown zone", "zones": h.kube.Zones()})
+		}
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		req.Configuration["zone"] = req.Zone
+	}
+
+	// Simulated scans are flagged in their configuration so their results are
+	// never mistaken for real ones
+	if req.Simulate {
+		if req.AgentID != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Simulated scans can't run on agents"})
+		}
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		req.Configuration["simulated"] = true
+	}
+
+	// Scans assigned to an agent stay pending until the agent picks them up
+	var agentArgs *string
+	if req.AgentID != nil {
+		if !h.flags.IsEnabled("agent_mode", requestTenant(c), "") {
+			return c.Status(403).JSON(fiber.Map{"error": "Remote agents are not enabled for this tenant"})
+		}
+		if scanner != "nmap" {
+			return c.Status(400).JSON(fiber.Map{"error": "Only nmap scans can run on agents"})
+		}
+		if _, err := h.agents.Get(context.Background(), *req.AgentID); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Agent not found"})
+		}
+		args := h.nmapArguments(req)
+		agentArgs = &args
+	}
 
-	// Create scan record
+	// Create scan record; the request is kept so the scan can be retried
 	scanID := uuid.New()
 	query := `
-		INSERT INTO scans (id, name, target, scan_type, scanner, status, progress, created_at, configuration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, name, target, scan_type, scanner, status, progress, created_at
+		INSERT INTO scans (id, name, target, scan_type, scanner, status, progress, created_at, configuration, agent_id, nmap_arguments, template_id, origin, project_id, runner, request)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16)
+		RETURNING id, name, target, scan_type, scanner, status, progress, created_at, configuration, agent_id, origin, project_id
 	`
 
 	var scan models.Scan
 	err := h.db.Pool.QueryRow(context.Background(), query,
-		scanID, req.Name, req.Target, req.ScanType, scanner, "pending", 0, time.Now(), req.Configuration,
-	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Scanner, &scan.Status, &scan.Progress, &scan.CreatedAt)
+		scanID, req.Name, req.Target, req.ScanType, scanner, "pending", 0, time.Now(), req.Configuration, req.AgentID, agentArgs, req.TemplateID,
+		origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)), req.Project, h.runner, req,
+	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Scanner, &scan.Status, &scan.Progress, &scan.CreatedAt, &scan.Configuration, &scan.AgentID, &scan.Origin, &scan.ProjectID)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create scan"})
 	}
 
+	h.events.Publish(events.ScanCreated, scan.ID.String(), events.ScanData{
+		ScanID:   scan.ID.String(),
+		Name:     scan.Name,
+		Target:   scan.Target,
+		ScanType: scan.ScanType,
+		Scanner:  scan.Scanner,
+		Status:   scan.Status,
+	})
+
 	// Route to appropriate scanner based on scan type
-	go h.executeScan(scanID, req)
+	if req.AgentID == nil {
+		go h.executeScan(scanID, req)
+	}
 
 	return c.Status(201).JSON(scan)
 }
 
 // executeScan routes the scan to the appropriate scanner
 func (h *ScanHandler) executeScan(scanID uuid.UUID, req models.CreateScanRequest) {
-	ctx := context.Background()
+	// Wait for a free slot (scans.max_concurrent and the scanner's own
+	// limit); the scan is queued meanwhile
+	scanner := scannerFor(req)
+	h.jobs.Run(context.Background(), jobs.Job{
+		ID:      scanID.String(),
+		Name:    req.Name,
+		Target:  req.Target,
+		Scanner: scanner,
+	}, func() {
+		h.db.Pool.Exec(context.Background(),
+			`UPDATE scans SET status = 'queued' WHERE id = $1 AND status = 'pending'`, scanID)
+		h.db.Pool.Exec(context.Background(),
+			`INSERT INTO scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`,
+			uuid.New(), scanID, "info", fmt.Sprintf("Queued: waiting for a free %s slot", scanner), time.Now())
+	}, func(ctx context.Context) {
+		h.runScan(ctx, scanID, req)
+	})
+}
 
-	// Determine scanner type based on scan_type prefix or name
-	scanType := strings.ToLower(req.ScanType)
+// runScan runs the scan once it holds a slot
+func (h *ScanHandler) runScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	scanner := scannerFor(req)
+
+	h.events.Publish(events.ScanStarted, scanID.String(), events.ScanData{
+		ScanID:   scanID.String(),
+		Name:     req.Name,
+		Target:   req.Target,
+		ScanType: req.ScanType,
+		Scanner:  scanner,
+		Status:   "running",
+	})
+	defer h.publishScanOutcome(ctx, scanID)
+
+	if req.Simulate {
+		h.executeSimulatedScan(ctx, scanID, req)
+		return
+	}
+	ctx = kubejobs.WithZone(ctx, req.Zone)
+
+	if h.hooks != nil {
+		hookScan := hooks.Scan{
+			ID:         scanID,
+			Name:       req.Name,
+			Target:     req.Target,
+			ScanType:   req.ScanType,
+			Scanner:    scanner,
+			TemplateID: req.TemplateID,
+			Project:    req.Project,
+		}
+		if err := h.hooks.Run(ctx, hooks.StagePre, hookScan); err != nil {
+			h.failScan(scanID, err.Error())
+			return
+		}
+		defer h.runPostHooks(scanID, hookScan)
+	}
 
-	switch {
-	// Masscan types
-	case strings.HasPrefix(scanType, "masscan"):
+	switch scanner {
+	case "masscan":
 		h.executeMasscanScan(ctx, scanID, req)
 
-	// DNS scan types
-	case strings.HasPrefix(scanType, "dns"):
+	case "dns":
 		h.executeDNSScan(ctx, scanID, req)
 
+	// Pure-Go TCP connect scanner
+	case "native":
+		h.executeNativeScan(ctx, scanID, req)
+
+	// masscan discovery, then nmap on the open ports
+	case "pipeline":
+		h.executePipelineScan(ctx, scanID, req)
+
+	// SYN scan with the open ports confirmed
+	case "naabu":
+		h.executeNaabuScan(ctx, scanID, req)
+
 	// Default to Nmap for all other types
 	default:
 		h.executeNmapScan(ctx, scanID, req)
 	}
 }
 
-// executeNmapScan runs an Nmap scan
-func (h *ScanHandler) executeNmapScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
-	nmapArgs := ""
+// failScan marks a scan that could not start as failed
+func (h *ScanHandler) failScan(scanID uuid.UUID, message string) {
+	h.db.Pool.Exec(context.Background(), `
+		UPDATE scans SET status = 'failed', error_message = $1, completed_at = NOW() WHERE id = $2
+	`, message, scanID)
+}
+
+// runPostHooks runs the post hooks of a scan that completed or failed;
+// cancelled scans skip them
+func (h *ScanHandler) runPostHooks(scanID uuid.UUID, scan hooks.Scan) {
+	var errorMessage *string
+	err := h.db.Pool.QueryRow(context.Background(),
+		`SELECT status, error_message FROM scans WHERE id = $1`, scanID).Scan(&scan.Status, &errorMessage)
+	if err != nil || (scan.Status != "completed" && scan.Status != "failed") {
+		return
+	}
+	if errorMessage != nil {
+		scan.Error = *errorMessage
+	}
+	h.hooks.Run(context.Background(), hooks.StagePost, scan)
+}
+
+// publishScanOutcome emits scan.completed/scan.failed and one finding.created
+// per open port once the scanner has finished. Hosts are tagged first so
+// findings carry their asset's tags, and the ports that opened or closed are
+// added to the port state history.
+func (h *ScanHandler) publishScanOutcome(ctx context.Context, scanID uuid.UUID) {
+	var data events.ScanData
+	var errorMessage *string
+	var simulated bool
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT name, target, scan_type, COALESCE(scanner, 'nmap'), status, error_message,
+		       COALESCE((configuration->>'simulated')::boolean, false)
+		FROM scans WHERE id = $1
+	`, scanID).Scan(&data.Name, &data.Target, &data.ScanType, &data.Scanner, &data.Status, &errorMessage, &simulated)
+	if err != nil {
+		return
+	}
+	data.ScanID = scanID.String()
+	if errorMessage != nil {
+		data.Error = *errorMessage
+	}
+
+	if data.Status == "failed" {
+		h.events.Publish(events.ScanFailed, data.ScanID, data)
+		return
+	}
+	if data.Status != "completed" {
+		return
+	}
+
+	// Synthetic hosts of simulated scans are not assets
+	if h.tagger != nil && !simulated {
+		if _, err := h.tagger.TagScan(ctx, scanID); err != nil {
+			fmt.Printf("Failed to tag hosts of scan %s: %v\n", scanID, err)
+		}
+	}
+
+	if _, err := porthistory.Record(ctx, h.db, scanID); err != nil {
+		fmt.Printf("Failed to record port history of scan %s: %v\n", scanID, err)
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `SELECT host, COALESCE(hostname, ''), ports FROM scan_results WHERE scan_id = $1`, scanID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	findings := []events.FindingData{}
+	hosts := []string{}
+	hostnames := map[string]string{}
+	for rows.Next() {
+		var host, hostname string
+		var ports []models.Port
+		if err := rows.Scan(&host, &hostname, &ports); err != nil {
+			continue
+		}
+		hosts = append(hosts, host)
+		hostnames[host] = hostname
+		for _, port := range ports {
+			if port.State != "" && port.State != "open" {
+				continue
+			}
+			findings = append(findings, events.FindingData{
+				ScanID:   data.ScanID,
+				Scanner:  data.Scanner,
+				Host:     host,
+				Port:     port.Port,
+				Protocol: port.Protocol,
+				Service:  port.Service,
+				Title:    fmt.Sprintf("Open port %d/%s", port.Port, port.Protocol),
+				Severity: "info",
+			})
+		}
+	}
+
+	rows.Close()
+
+	if err := events.TagFindings(ctx, h.db.Pool, findings); err != nil {
+		fmt.Printf("Failed to tag findings of scan %s: %v\n", scanID, err)
+	}
+	if assignments, err := tagging.LoadOwners(ctx, h.db); err == nil {
+		for i := range findings {
+			findings[i].Owner = owners.Match(assignments, findings[i].Host, hostnames[findings[i].Host])
+		}
+	}
+
+	data.Summary = map[string]int{"hosts": len(hosts), "open_ports": len(findings)}
+	h.events.Publish(events.ScanCompleted, data.ScanID, data)
+	for _, finding := range findings {
+		h.events.Publish(events.FindingCreated, data.ScanID, finding)
+	}
+}
+
+// nmapArguments returns the explicit nmap arguments or those of the scan type's template,
+// with the request's ports, top_ports, protocol and host_timeout applied
+func (h *ScanHandler) nmapArguments(req models.CreateScanRequest) string {
+	arguments := "-F -T4" // default to quick scan
 	if req.NmapArguments != nil {
-		nmapArgs = *req.NmapArguments
-	} else {
-		templates := h.nmapScanner.GetScanTemplates()
-		if template, ok := templates[req.ScanType]; ok {
-			nmapArgs = template["arguments"]
-		} else {
-			// Default to quick scan
-			nmapArgs = "-F -T4"
+		arguments = *req.NmapArguments
+	} else if template, ok := h.nmapScanner.GetScanTemplates()[req.ScanType]; ok {
+		arguments = template["arguments"]
+	}
+	return scanner.WithHostTimeout(applyPortOptions(arguments, req), time.Duration(req.HostTimeout)*time.Second)
+}
+
+// applyHostTimeout checks the request's host_timeout, or takes it from the
+// configuration of its template, and records it in the scan's configuration
+func applyHostTimeout(req *models.CreateScanRequest, scanner string) error {
+	if req.HostTimeout == 0 && req.Configuration != nil {
+		if t, ok := req.Configuration["host_timeout"].(float64); ok {
+			req.HostTimeout = int(t)
 		}
 	}
+	if req.HostTimeout == 0 {
+		return nil
+	}
+	if req.HostTimeout < 0 {
+		return fmt.Errorf("host_timeout must be a positive number of seconds")
+	}
+	if scanner != "nmap" && scanner != "native" && scanner != "pipeline" {
+		// masscan keeps no per-host state, and DNS scans have no ports
+		return fmt.Errorf("host_timeout is only supported for nmap, native and pipeline scans")
+	}
+	if req.Configuration == nil {
+		req.Configuration = map[string]interface{}{}
+	}
+	req.Configuration["host_timeout"] = req.HostTimeout
+	return nil
+}
+
+// executeNmapScan runs an Nmap scan
+func (h *ScanHandler) executeNmapScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	nmapArgs := h.nmapArguments(req)
+	ctx, extraArgs, err := withAdvanced(ctx, req, "nmap")
+	if err != nil {
+		h.nmapScanner.FailScan(ctx, scanID, err.Error())
+		return
+	}
+	if len(extraArgs) > 0 {
+		nmapArgs += " " + strings.Join(extraArgs, " ")
+	}
 
 	if err := h.nmapScanner.ExecuteScan(ctx, scanID, req.Target, nmapArgs); err != nil {
 		fmt.Printf("Nmap scan %s failed: %v\n", scanID, err)
 	}
 }
 
-// executeMasscanScan runs a Masscan scan
-func (h *ScanHandler) executeMasscanScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+// masscanOptions returns the ports and rate of a masscan or pipeline scan
+// from the request's configuration or the scan type's template
+func (h *ScanHandler) masscanOptions(req models.CreateScanRequest) (string, int) {
 	ports := "1-65535"
 	rate := 10000
 
@@ -165,6 +628,9 @@ func (h *ScanHandler) executeMasscanScan(ctx context.Context, scanID uuid.UUID,
 	} else {
 		// Use template defaults
 		templates := h.masscanScanner.GetTemplates()
+		if scannerFor(req) == "pipeline" {
+			templates = h.pipeline.GetTemplates()
+		}
 		if template, ok := templates[req.ScanType]; ok {
 			if p, ok := template["ports"].(string); ok {
 				ports = p
@@ -174,26 +640,242 @@ func (h *ScanHandler) executeMasscanScan(ctx context.Context, scanID uuid.UUID,
 			}
 		}
 	}
+	if req.Ports != "" {
+		ports = req.Ports
+	}
+	return ports, rate
+}
+
+// executeMasscanScan runs a Masscan scan
+func (h *ScanHandler) executeMasscanScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	ports, rate := h.masscanOptions(req)
+	ctx, extraArgs, err := withAdvanced(ctx, req, "masscan")
+	if err != nil {
+		h.nmapScanner.FailScan(ctx, scanID, err.Error())
+		return
+	}
+	if adapter, err := scanner.ParseMasscanAdapter(req.Configuration); err == nil {
+		extraArgs = append(adapter.Args(), extraArgs...)
+	}
 
-	if err := h.masscanScanner.ExecuteScan(ctx, scanID, req.Target, ports, rate); err != nil {
+	if err := h.masscanScanner.ExecuteScan(ctx, scanID, req.Target, ports, rate, extraArgs); err != nil {
 		fmt.Printf("Masscan scan %s failed: %v\n", scanID, err)
 	}
 }
 
+// pipelineConfig builds the pipeline scan configuration: masscan's ports,
+// rate and adapter, and nmap's arguments from the request or the scan type's
+// template
+func (h *ScanHandler) pipelineConfig(req models.CreateScanRequest) scanner.PipelineConfig {
+	config := scanner.PipelineConfig{NmapArguments: scanner.PipelineDefaultArguments}
+	config.Ports, config.Rate = h.masscanOptions(req)
+	if req.NmapArguments != nil {
+		config.NmapArguments = *req.NmapArguments
+	} else if template, ok := h.pipeline.GetTemplates()[req.ScanType]; ok {
+		if arguments, ok := template["arguments"].(string); ok {
+			config.NmapArguments = arguments
+		}
+	}
+	config.NmapArguments = scanner.WithHostTimeout(config.NmapArguments, time.Duration(req.HostTimeout)*time.Second)
+	if adapter, err := scanner.ParseMasscanAdapter(req.Configuration); err == nil {
+		config.MasscanArgs = adapter.Args()
+	}
+	return config
+}
+
+// executePipelineScan runs a masscan discovery followed by an nmap scan of
+// the open ports it finds
+func (h *ScanHandler) executePipelineScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	if err := h.pipeline.ExecuteScan(ctx, scanID, req.Target, h.pipelineConfig(req)); err != nil {
+		fmt.Printf("Pipeline scan %s failed: %v\n", scanID, err)
+	}
+}
+
+// nativeConfig builds the native scan configuration from the request and
+// the scan type's template
+func (h *ScanHandler) nativeConfig(req models.CreateScanRequest) (scanner.NativeScanConfig, error) {
+	config := scanner.NativeScanConfig{Banners: true, HostTimeout: time.Duration(req.HostTimeout) * time.Second}
+	ports := req.Ports
+	if template, ok := h.nativeScanner.GetTemplates()[req.ScanType]; ok && ports == "" && req.TopPorts == 0 {
+		ports, _ = template["ports"].(string)
+	}
+	if req.Configuration != nil {
+		if p, ok := req.Configuration["ports"].(string); ok && req.Ports == "" {
+			ports = p
+		}
+		if c, ok := req.Configuration["concurrency"].(float64); ok {
+			config.Concurrency = int(c)
+		}
+		if t, ok := req.Configuration["timeout"].(float64); ok {
+			config.Timeout = time.Duration(t) * time.Millisecond
+		}
+		if b, ok := req.Configuration["banners"].(bool); ok {
+			config.Banners = b
+		}
+	}
+
+	var err error
+	config.Ports, err = scanner.NativePorts(ports, req.TopPorts)
+	return config, err
+}
+
+// executeNativeScan runs a native TCP connect scan
+func (h *ScanHandler) executeNativeScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	config, err := h.nativeConfig(req)
+	if err != nil {
+		h.nmapScanner.FailScan(ctx, scanID, err.Error())
+		return
+	}
+
+	if err := h.nativeScanner.ExecuteScan(ctx, scanID, req.Target, config); err != nil {
+		fmt.Printf("Native scan %s failed: %v\n", scanID, err)
+	}
+}
+
+// naabuConfig builds the naabu scan configuration from the request and the
+// scan type's template: ports (or top_ports, 100 or 1000) and the
+// configuration's rate, exclude_ports and verify
+func (h *ScanHandler) naabuConfig(req models.CreateScanRequest) (scanner.NaabuScanConfig, error) {
+	config := scanner.NaabuScanConfig{Ports: req.Ports, TopPorts: req.TopPorts, Verify: true}
+	if template, ok := h.naabuScanner.GetTemplates()[req.ScanType]; ok {
+		if config.Ports == "" && config.TopPorts == 0 {
+			config.Ports, _ = template["ports"].(string)
+			config.TopPorts, _ = template["top_ports"].(int)
+		}
+		config.Rate, _ = template["rate"].(int)
+	}
+	if req.Configuration != nil {
+		if p, ok := req.Configuration["ports"].(string); ok && req.Ports == "" && req.TopPorts == 0 {
+			config.Ports, config.TopPorts = p, 0
+		}
+		if r, ok := req.Configuration["rate"].(float64); ok {
+			config.Rate = int(r)
+		}
+		if e, ok := req.Configuration["exclude_ports"].(string); ok {
+			config.ExcludePorts = strings.TrimSpace(e)
+		}
+		if v, ok := req.Configuration["verify"].(bool); ok {
+			config.Verify = v
+		}
+	}
+
+	if config.Ports != "" {
+		if err := validatePortList(config.Ports); err != nil {
+			return config, err
+		}
+	}
+	if config.ExcludePorts != "" {
+		if err := validatePortList(config.ExcludePorts); err != nil {
+			return config, fmt.Errorf("exclude_ports: %v", err)
+		}
+	}
+	if config.Rate < 0 {
+		return config, fmt.Errorf("rate must be positive")
+	}
+	return config, nil
+}
+
+// executeNaabuScan runs a naabu SYN scan
+func (h *ScanHandler) executeNaabuScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	config, err := h.naabuConfig(req)
+	if err != nil {
+		h.nmapScanner.FailScan(ctx, scanID, err.Error())
+		return
+	}
+	ctx, extraArgs, err := withAdvanced(ctx, req, "naabu")
+	if err != nil {
+		h.nmapScanner.FailScan(ctx, scanID, err.Error())
+		return
+	}
+
+	if err := h.naabuScanner.ExecuteScan(ctx, scanID, req.Target, config, extraArgs); err != nil {
+		fmt.Printf("Naabu scan %s failed: %v\n", scanID, err)
+	}
+}
+
+// executeSimulatedScan generates synthetic results instead of scanning
+func (h *ScanHandler) executeSimulatedScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
+	var ports []int
+	if req.Ports != "" || req.TopPorts > 0 {
+		ports, _ = scanner.NativePorts(req.Ports, req.TopPorts)
+	}
+	if err := h.simulator.ExecuteScan(ctx, scanID, req.Target, scannerFor(req), ports); err != nil {
+		fmt.Printf("Simulated scan %s failed: %v\n", scanID, err)
+	}
+}
+
+// validateResolvers checks the DoH/DoT resolvers of a scan's configuration,
+// which only DNS scans use
+func validateResolvers(configuration map[string]interface{}, scanner string) error {
+	value, ok := configuration["resolvers"]
+	if !ok {
+		return nil
+	}
+	if scanner != "dns" {
+		return fmt.Errorf("resolvers only apply to dns scans")
+	}
+	_, err := securedns.FromConfiguration(value)
+	return err
+}
+
+// validateBruteForce checks the subdomain brute force options of a scan's
+// configuration (wordlist, resolver pool, concurrency), which only DNS
+// scans use
+func (h *ScanHandler) validateBruteForce(configuration map[string]interface{}, scannerType string) error {
+	if !scanner.HasBruteForceOptions(configuration) {
+		return nil
+	}
+	if scannerType != "dns" {
+		return fmt.Errorf("wordlist, wordlist_entries, resolver_pool and concurrency only apply to dns scans")
+	}
+	opts, err := scanner.DNSBruteForceFromConfiguration(configuration)
+	if err != nil {
+		return err
+	}
+	return h.dnsScanner.CheckWordlist(opts)
+}
+
+// GetWordlists lists the wordlists DNS scans can brute force subdomains
+// with: the built-in list and the files of WORDLISTS_PATH
+func (h *ScanHandler) GetWordlists(c *fiber.Ctx) error {
+	return c.JSON(h.dnsScanner.Wordlists())
+}
+
 // executeDNSScan runs a DNS scan
 func (h *ScanHandler) executeDNSScan(ctx context.Context, scanID uuid.UUID, req models.CreateScanRequest) {
-	if err := h.dnsScanner.ExecuteScan(ctx, scanID, req.Target, req.ScanType); err != nil {
+	// The resolvers and brute force options were validated when the scan was created
+	resolvers, _ := securedns.FromConfiguration(req.Configuration["resolvers"])
+	brute, _ := scanner.DNSBruteForceFromConfiguration(req.Configuration)
+	if err := h.dnsScanner.ExecuteScan(ctx, scanID, req.Target, req.ScanType, resolvers, brute); err != nil {
 		fmt.Printf("DNS scan %s failed: %v\n", scanID, err)
 	}
 }
 
-// ListScans returns all scans
+// scanSortFields are the fields ListScans sorts by, and their columns
+var scanSortFields = map[string]string{
+	"created_at":   "created_at",
+	"started_at":   "started_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"target":       "target",
+	"status":       "status",
+	"progress":     "progress",
+	"scanner":      "scanner",
+	"scan_type":    "scan_type",
+}
+
+// ListScans returns a page of scans (?page=, ?limit=, ?sort=, newest first
+// by default), filtered by ?status=, ?scanner=, ?origin= and ?project=
 func (h *ScanHandler) ListScans(c *fiber.Ctx) error {
 	status := c.Query("status", "")
 	scanner := c.Query("scanner", "")
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), scanSortFields, "-created_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	query := `
-		SELECT id, name, target, scan_type, scanner, status, progress, created_at, started_at, completed_at, error_message
+		SELECT id, name, target, scan_type, scanner, status, progress, created_at, started_at, completed_at, error_message, agent_id, origin, project_id
 		FROM scans
 	`
 	args := []interface{}{}
@@ -212,13 +894,33 @@ func (h *ScanHandler) ListScans(c *fiber.Ctx) error {
 		argIndex++
 	}
 
+	if filter := c.Query("origin"); filter != "" {
+		condition, value, err := origin.Filter("origin", argIndex, filter)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		conditions = append(conditions, condition)
+		args = append(args, value)
+		argIndex++
+	}
+
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		conditions = append(conditions, project.Filter("project_id", argIndex))
+		args = append(args, scope)
+		argIndex++
+	}
+
+	where := ""
 	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY created_at DESC LIMIT 100"
+	var total int
+	if err := h.db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM scans`+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
+	}
 
-	rows, err := h.db.Pool.Query(context.Background(), query, args...)
+	rows, err := h.db.Pool.Query(context.Background(), query+where+page.OrderBy(), args...)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
 	}
@@ -229,7 +931,7 @@ func (h *ScanHandler) ListScans(c *fiber.Ctx) error {
 		var scan models.Scan
 		var scanner *string
 		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scanner, &scan.Status,
-			&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage)
+			&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage, &scan.AgentID, &scan.Origin, &scan.ProjectID)
 		if err != nil {
 			continue
 		}
@@ -242,7 +944,11 @@ func (h *ScanHandler) ListScans(c *fiber.Ctx) error {
 		scans = append(scans, scan)
 	}
 
-	return c.JSON(scans)
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
+	}
+	return c.JSON(result)
 }
 
 // GetScan returns a specific scan by ID
@@ -250,7 +956,8 @@ func (h *ScanHandler) GetScan(c *fiber.Ctx) error {
 	scanID := c.Params("id")
 
 	query := `
-		SELECT id, name, target, scan_type, scanner, status, progress, created_at, started_at, completed_at, error_message
+		SELECT id, name, target, scan_type, scanner, status, progress, created_at, started_at, completed_at, error_message, agent_id, origin, project_id,
+		       possibly_blocked, quality, hook_results, skipped_hosts, resolver_health
 		FROM scans
 		WHERE id = $1
 	`
@@ -259,7 +966,9 @@ func (h *ScanHandler) GetScan(c *fiber.Ctx) error {
 	var scanner *string
 	err := h.db.Pool.QueryRow(context.Background(), query, scanID).Scan(
 		&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scanner, &scan.Status,
-		&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage,
+		&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage, &scan.AgentID, &scan.Origin, &scan.ProjectID,
+		&scan.PossiblyBlocked, &scan.Quality, &scan.HookResults, &scan.SkippedHosts,
+		&scan.ResolverHealth,
 	)
 
 	if err != nil {
@@ -280,13 +989,15 @@ func (h *ScanHandler) GetScan(c *fiber.Ctx) error {
 func (h *ScanHandler) GetScanResults(c *fiber.Ctx) error {
 	scanID := c.Params("id")
 
+	// ?exclude_honeypots=true hides hosts marked as likely honeypots
 	query := `
-		SELECT id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at
+		SELECT id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at,
+		       honeypot_score, COALESCE(honeypot_reasons, '[]'::jsonb), COALESCE(vulnerabilities, '[]'::jsonb)
 		FROM scan_results
-		WHERE scan_id = $1
+		WHERE scan_id = $1 AND (NOT $2 OR honeypot_score < $3)
 	`
 
-	rows, err := h.db.Pool.Query(context.Background(), query, scanID)
+	rows, err := h.db.Pool.Query(context.Background(), query, scanID, c.QueryBool("exclude_honeypots"), deception.LikelyScore)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
 	}
@@ -295,14 +1006,56 @@ func (h *ScanHandler) GetScanResults(c *fiber.Ctx) error {
 	results := []models.ScanResult{}
 	for rows.Next() {
 		var result models.ScanResult
+		var honeypot models.HoneypotAssessment
 		err := rows.Scan(&result.ID, &result.ScanID, &result.Host, &result.Hostname, &result.State,
-			&result.Ports, &result.OSDetection, &result.Services, &result.MacAddress, &result.MacVendor, &result.CreatedAt)
+			&result.Ports, &result.OSDetection, &result.Services, &result.MacAddress, &result.MacVendor, &result.CreatedAt,
+			&honeypot.Score, &honeypot.Reasons, &result.Vulnerabilities)
 		if err != nil {
 			continue
 		}
+		if honeypot.Score > 0 {
+			honeypot.Likely = honeypot.Score >= deception.LikelyScore
+			result.Honeypot = &honeypot
+		}
 		results = append(results, result)
 	}
 
+	// Blocklist and abuse data for each host, when it has been checked
+	hosts := make([]string, len(results))
+	for i, result := range results {
+		hosts[i] = result.Host
+	}
+	if reputations, err := reputation.Lookup(context.Background(), h.db, hosts); err == nil {
+		for i := range results {
+			results[i].Reputation = reputations[results[i].Host]
+		}
+	}
+
+	// What recon resolved each host's names to, flagging stale DNS and CDN edges
+	var target string
+	if err := h.db.Pool.QueryRow(context.Background(), `SELECT target FROM scans WHERE id = $1`, scanID).Scan(&target); err == nil {
+		if checks, err := resolution.Check(context.Background(), h.db, resolution.Hosts(target, results)); err == nil {
+			for i := range results {
+				results[i].Resolution = checks[results[i].Host]
+			}
+		}
+	}
+
+	// Tags the asset tagging rules gave each host, and its owner
+	if assets, err := tagging.Lookup(context.Background(), h.db, hosts); err == nil {
+		for i := range results {
+			if asset, ok := assets[strings.ToLower(results[i].Host)]; ok {
+				results[i].Tags = asset.Tags
+				results[i].Criticality = asset.Criticality
+			}
+		}
+	}
+	if assignments, err := tagging.LoadOwners(context.Background(), h.db); err == nil {
+		for i := range results {
+			results[i].Owner = owners.Match(assignments, hostNames(results[i])...)
+		}
+	}
+
 	return c.JSON(results)
 }
 
@@ -348,8 +1101,8 @@ func (h *ScanHandler) DeleteScan(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
 
-	// If scan is running, cancel it first
-	if status == "running" {
+	// If scan is running or queued, cancel it first
+	if status == "running" || status == "queued" {
 		h.cancelScanByType(scanID, scanType)
 	}
 
@@ -379,7 +1132,7 @@ func (h *ScanHandler) CancelScan(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
 
-	if status != "running" && status != "pending" {
+	if status != "running" && status != "pending" && status != "queued" {
 		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Cannot cancel scan with status: %s", status)})
 	}
 
@@ -396,9 +1149,85 @@ func (h *ScanHandler) CancelScan(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Scan cancelled successfully"})
 }
 
+// RetryScan runs a failed or interrupted scan again with the request that
+// created it. The scan keeps its ID; its previous results are dropped and
+// its logs kept. Scans assigned to an agent go back to the agent's queue.
+func (h *ScanHandler) RetryScan(c *fiber.Ctx) error {
+	scanID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+	ctx := context.Background()
+
+	var status, projectID string
+	var errorMessage *string
+	var agentID *uuid.UUID
+	var stored *models.CreateScanRequest
+	var fallback models.CreateScanRequest
+	err = h.db.Pool.QueryRow(ctx, `
+		SELECT status, error_message, agent_id, request, project_id,
+		       name, target, scan_type, COALESCE(scanner, ''), configuration, nmap_arguments, template_id
+		FROM scans WHERE id = $1
+	`, scanID).Scan(&status, &errorMessage, &agentID, &stored, &projectID,
+		&fallback.Name, &fallback.Target, &fallback.ScanType, &fallback.Scanner, &fallback.Configuration,
+		&fallback.NmapArguments, &fallback.TemplateID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if status != "failed" && status != "interrupted" {
+		return c.Status(409).JSON(fiber.Map{"error": fmt.Sprintf("Only failed or interrupted scans can be retried, this one is %s", status)})
+	}
+
+	// Scans created before requests were stored are rebuilt from their
+	// columns; their ports options live in the configuration or arguments
+	req := fallback
+	if stored != nil {
+		req = *stored
+	} else {
+		applyHostTimeout(&req, scannerFor(req))
+		req.Zone, _ = req.Configuration["zone"].(string)
+		req.Simulate, _ = req.Configuration["simulated"].(bool)
+	}
+	req.Project = projectID
+	// The scope may have changed since the scan was created
+	if status, body := scopeError(h.scope, req.Project, req.Simulate, req.Target); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE scans SET status = 'pending', progress = 0, started_at = NULL, completed_at = NULL,
+		       error_message = NULL, possibly_blocked = NULL, quality = NULL, hook_results = NULL,
+		       skipped_hosts = NULL, resolver_health = NULL, runner = NULLIF($2, '')
+		WHERE id = $1 AND status IN ('failed', 'interrupted')
+	`, scanID, h.runner)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to retry scan"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "The scan was retried by another request"})
+	}
+	h.db.Pool.Exec(ctx, `DELETE FROM scan_results WHERE scan_id = $1`, scanID)
+
+	message := "Retrying " + status + " scan"
+	if errorMessage != nil {
+		message += " (" + *errorMessage + ")"
+	}
+	h.db.Pool.Exec(ctx,
+		`INSERT INTO scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New(), scanID, "info", message, time.Now())
+
+	if agentID == nil {
+		go h.executeScan(scanID, req)
+	}
+	return h.GetScan(c)
+}
+
 // cancelScanByType cancels a scan using the appropriate scanner
 func (h *ScanHandler) cancelScanByType(scanID string, scanType string) {
 	scanTypeLower := strings.ToLower(scanType)
+	// A queued scan is dropped from the queue before it starts
+	h.jobs.Cancel(scanID)
+	h.simulator.CancelScan(scanID)
 
 	switch {
 	case strings.HasPrefix(scanTypeLower, "masscan"):
@@ -406,7 +1235,12 @@ func (h *ScanHandler) cancelScanByType(scanID string, scanType string) {
 	case strings.HasPrefix(scanTypeLower, "dns"):
 		h.dnsScanner.CancelScan(scanID)
 	default:
+		// Native, pipeline and naabu scans may have any scan_type when
+		// scanner is given explicitly
 		h.nmapScanner.CancelScan(scanID)
+		h.nativeScanner.CancelScan(scanID)
+		h.pipeline.CancelScan(scanID)
+		h.naabuScanner.CancelScan(scanID)
 	}
 }
 
@@ -435,6 +1269,40 @@ func (h *ScanHandler) GetAllTemplates(c *fiber.Ctx) error {
 		}
 	}
 
+	// Native templates
+	for key, tmpl := range h.nativeScanner.GetTemplates() {
+		templates[key] = map[string]interface{}{
+			"name":        tmpl["name"],
+			"description": tmpl["description"],
+			"scanner":     "native",
+			"ports":       tmpl["ports"],
+		}
+	}
+
+	// Naabu templates
+	for key, tmpl := range h.naabuScanner.GetTemplates() {
+		templates[key] = map[string]interface{}{
+			"name":        tmpl["name"],
+			"description": tmpl["description"],
+			"scanner":     "naabu",
+			"ports":       tmpl["ports"],
+			"top_ports":   tmpl["top_ports"],
+			"rate":        tmpl["rate"],
+		}
+	}
+
+	// Pipeline templates
+	for key, tmpl := range h.pipeline.GetTemplates() {
+		templates[key] = map[string]interface{}{
+			"name":        tmpl["name"],
+			"description": tmpl["description"],
+			"scanner":     "pipeline",
+			"ports":       tmpl["ports"],
+			"rate":        tmpl["rate"],
+			"arguments":   tmpl["arguments"],
+		}
+	}
+
 	// DNS templates
 	for key, tmpl := range h.dnsScanner.GetTemplates() {
 		templates[key] = map[string]interface{}{
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+const (
+	// streamPollInterval is how often a stream looks for changes of its scan
+	streamPollInterval = time.Second
+	// streamKeepAlive is how long a stream may stay silent before a comment
+	// is sent, so proxies don't close it
+	streamKeepAlive = 15 * time.Second
+)
+
+// scanProgress is the status event of a scan stream
+type scanProgress struct {
+	Status       string     `json:"status"`
+	Progress     int        `json:"progress"`
+	ErrorMessage *string    `json:"error_message,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// logCursor is the position of the last log line a stream sent; it is the
+// event ID clients send back in Last-Event-ID when they reconnect
+type logCursor struct {
+	at time.Time
+	id uuid.UUID
+}
+
+func (c logCursor) String() string {
+	return c.at.Format(time.RFC3339Nano) + "|" + c.id.String()
+}
+
+func parseLogCursor(s string) logCursor {
+	at, id, _ := strings.Cut(s, "|")
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return logCursor{}
+	}
+	parsed, _ := uuid.Parse(id)
+	return logCursor{at: t, id: parsed}
+}
+
+// StreamScan streams a scan as server-sent events: "status" when its
+// status or progress changes, "log" for each log line (the ones before
+// Last-Event-ID are skipped on reconnect), and "done" once it finished,
+// after which the stream ends
+func (h *ScanHandler) StreamScan(c *fiber.Ctx) error {
+	scanID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+	if _, err := h.scanProgress(scanID); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	cursor := parseLogCursor(c.Get("Last-Event-ID"))
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // nginx must not buffer the stream
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		h.streamScan(scanID, cursor, w)
+	})
+	return nil
+}
+
+// streamScan writes the events of a scan until it finished or the client
+// went away
+func (h *ScanHandler) streamScan(scanID uuid.UUID, cursor logCursor, w *bufio.Writer) {
+	var last *scanProgress
+	lastWrite := time.Now()
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		progress, err := h.scanProgress(scanID)
+		if err != nil {
+			writeEvent(w, "done", "", fiber.Map{"status": "deleted"})
+			return
+		}
+
+		logs, _ := h.logsAfter(scanID, cursor)
+		wrote := false
+		for _, log := range logs {
+			cursor = logCursor{at: log.CreatedAt, id: log.ID}
+			writeEvent(w, "log", cursor.String(), log)
+			wrote = true
+		}
+		if last == nil || progress.Status != last.Status || progress.Progress != last.Progress {
+			writeEvent(w, "status", "", progress)
+			last = progress
+			wrote = true
+		}
+
+		finished := progress.Status == "completed" || progress.Status == "failed" || progress.Status == "cancelled" ||
+			progress.Status == "interrupted"
+		if finished {
+			writeEvent(w, "done", "", progress)
+			w.Flush()
+			return
+		}
+		if !wrote && time.Since(lastWrite) >= streamKeepAlive {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			wrote = true
+		}
+		if wrote {
+			// A failed flush means the client disconnected
+			if err := w.Flush(); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		<-ticker.C
+	}
+}
+
+func (h *ScanHandler) scanProgress(scanID uuid.UUID) (*scanProgress, error) {
+	var p scanProgress
+	err := h.db.Pool.QueryRow(context.Background(), `
+		SELECT status, progress, error_message, started_at, completed_at FROM scans WHERE id = $1
+	`, scanID).Scan(&p.Status, &p.Progress, &p.ErrorMessage, &p.StartedAt, &p.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// logsAfter returns the log lines of a scan after cursor, oldest first
+func (h *ScanHandler) logsAfter(scanID uuid.UUID, cursor logCursor) ([]models.ScanLog, error) {
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT id, scan_id, level, message, created_at
+		FROM scan_logs
+		WHERE scan_id = $1 AND (created_at, id) > ($2, $3)
+		ORDER BY created_at, id
+		LIMIT 500
+	`, scanID, cursor.at, cursor.id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.ScanLog{}
+	for rows.Next() {
+		var log models.ScanLog
+		if err := rows.Scan(&log.ID, &log.ScanID, &log.Level, &log.Message, &log.CreatedAt); err != nil {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+// writeEvent writes one server-sent event with data as JSON
+func writeEvent(w *bufio.Writer, event, id string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
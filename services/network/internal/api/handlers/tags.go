@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/tagging"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/screenshots"
+)
+
+// TagHandler manages asset tagging rules and lists tagged assets
+type TagHandler struct {
+	db     *database.Database
+	engine *tagging.Engine
+}
+
+func NewTagHandler(db *database.Database, engine *tagging.Engine) *TagHandler {
+	return &TagHandler{db: db, engine: engine}
+}
+
+// tagRuleRequest is the editable part of a tag rule
+type tagRuleRequest struct {
+	Name        string                `json:"name"`
+	Description *string               `json:"description,omitempty"`
+	Conditions  []models.TagCondition `json:"conditions"`
+	Tags        []string              `json:"tags"`
+	Criticality string                `json:"criticality"`
+	Enabled     *bool                 `json:"enabled,omitempty"`
+}
+
+// parseRule reads and validates a rule from the request body
+func parseRule(c *fiber.Ctx) (*models.TagRule, error) {
+	var req tagRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	rule := &models.TagRule{
+		Name:        req.Name,
+		Description: req.Description,
+		Conditions:  req.Conditions,
+		Tags:        req.Tags,
+		Criticality: req.Criticality,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+	if err := tagging.Validate(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// nameTaken reports whether another rule than id uses name
+func (h *TagHandler) nameTaken(name, id string) bool {
+	var exists bool
+	h.db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM asset_tag_rules WHERE name = $1 AND id::text <> $2)`, name, id).Scan(&exists)
+	return exists
+}
+
+// ListRules returns every tag rule
+func (h *TagHandler) ListRules(c *fiber.Ctx) error {
+	rows, err := h.db.Pool.Query(context.Background(), `SELECT `+tagging.RuleColumns+` FROM asset_tag_rules ORDER BY name`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch tag rules"})
+	}
+	defer rows.Close()
+
+	rules := []*models.TagRule{}
+	for rows.Next() {
+		rule, err := tagging.ScanRule(rows)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(rules)))
+	return c.JSON(fiber.Map{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// GetRule returns a tag rule
+func (h *TagHandler) GetRule(c *fiber.Ctx) error {
+	rule, err := tagging.ScanRule(h.db.Pool.QueryRow(context.Background(),
+		`SELECT `+tagging.RuleColumns+` FROM asset_tag_rules WHERE id = $1`, c.Params("id")))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Tag rule not found"})
+	}
+	return c.JSON(rule)
+}
+
+// CreateRule adds a tag rule. It applies to results that arrive from now on;
+// POST /api/tag-rules/apply re-evaluates existing results.
+func (h *TagHandler) CreateRule(c *fiber.Ctx) error {
+	rule, err := parseRule(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.nameTaken(rule.Name, uuid.Nil.String()) {
+		return c.Status(409).JSON(fiber.Map{"error": "Tag rule with this name already exists"})
+	}
+	conditions, _ := json.Marshal(rule.Conditions)
+
+	row := h.db.Pool.QueryRow(context.Background(), `
+		INSERT INTO asset_tag_rules (id, name, description, conditions, tags, criticality, enabled)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING `+tagging.RuleColumns,
+		uuid.New(), rule.Name, rule.Description, conditions, rule.Tags, rule.Criticality, rule.Enabled)
+	created, err := tagging.ScanRule(row)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create tag rule"})
+	}
+	if err := h.engine.Reload(context.Background()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload tag rules"})
+	}
+	return c.Status(201).JSON(created)
+}
+
+// UpdateRule replaces a tag rule
+func (h *TagHandler) UpdateRule(c *fiber.Ctx) error {
+	rule, err := parseRule(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.nameTaken(rule.Name, c.Params("id")) {
+		return c.Status(409).JSON(fiber.Map{"error": "Tag rule with this name already exists"})
+	}
+	conditions, _ := json.Marshal(rule.Conditions)
+
+	row := h.db.Pool.QueryRow(context.Background(), `
+		UPDATE asset_tag_rules
+		SET name = $2, description = $3, conditions = $4, tags = $5, criticality = NULLIF($6, ''),
+		    enabled = $7, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+tagging.RuleColumns,
+		c.Params("id"), rule.Name, rule.Description, conditions, rule.Tags, rule.Criticality, rule.Enabled)
+	updated, err := tagging.ScanRule(row)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Tag rule not found"})
+	}
+	if err := h.engine.Reload(context.Background()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload tag rules"})
+	}
+	return c.JSON(updated)
+}
+
+// DeleteRule removes a tag rule and the tags it gave
+func (h *TagHandler) DeleteRule(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(), `DELETE FROM asset_tag_rules WHERE id = $1`, c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete tag rule"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Tag rule not found"})
+	}
+	if err := h.engine.Reload(context.Background()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload tag rules"})
+	}
+	return c.JSON(fiber.Map{"message": "Tag rule deleted"})
+}
+
+// ApplyRules recomputes every asset's tags from its latest results
+func (h *TagHandler) ApplyRules(c *fiber.Ctx) error {
+	tagged, err := h.engine.Retag(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to apply tag rules"})
+	}
+	return c.JSON(fiber.Map{"message": "Tag rules applied", "tags": tagged})
+}
+
+// ListAssets returns tagged assets, most critical first. ?tag= keeps assets
+// with that tag, ?criticality= those at least that critical and ?project=
+// (or X-Tenant-ID) those the project's scans found.
+func (h *TagHandler) ListAssets(c *fiber.Ctx) error {
+	criticality := strings.ToLower(c.Query("criticality"))
+	if !tagging.ValidCriticality(criticality) {
+		return c.Status(400).JSON(fiber.Map{"error": "criticality must be low, medium, high or critical"})
+	}
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	assets, err := tagging.List(context.Background(), h.db, c.Query("tag"), criticality, scope)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch assets"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(assets)))
+	return c.JSON(fiber.Map{
+		"assets": assets,
+		"total":  len(assets),
+	})
+}
+
+// GetAsset returns the tags and owner of one host or subdomain
+func (h *TagHandler) GetAsset(c *fiber.Ctx) error {
+	name := strings.ToLower(c.Params("asset"))
+	assets, err := tagging.Lookup(context.Background(), h.db, []string{name})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch asset"})
+	}
+	asset, ok := assets[name]
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Asset has no tags"})
+	}
+	if assignments, err := tagging.LoadOwners(context.Background(), h.db); err == nil {
+		asset.Owner = owners.Match(assignments, asset.Asset)
+	}
+	return c.JSON(asset)
+}
+
+// timelineEvent is a scan that saw an asset, or a screenshot of its newly
+// opened web ports
+type timelineEvent struct {
+	Type       string                  `json:"type"` // scan or screenshot
+	Time       time.Time               `json:"time"`
+	ScanID     *uuid.UUID              `json:"scan_id,omitempty"`
+	ScanName   string                  `json:"scan_name,omitempty"`
+	OpenPorts  []int                   `json:"open_ports,omitempty"`
+	Opened     []int                   `json:"opened,omitempty"` // since the previous scan
+	Closed     []int                   `json:"closed,omitempty"`
+	Screenshot *screenshots.Screenshot `json:"screenshot,omitempty"`
+	Status     string                  `json:"status,omitempty"` // of the gowitness scan
+}
+
+// GetAssetTimeline returns the completed scans that saw a host or subdomain,
+// with the ports each opened and closed, and the gowitness scans queued for
+// its newly opened web ports, oldest first. ?project= (or X-Tenant-ID) keeps
+// the project's scans.
+func (h *TagHandler) GetAssetTimeline(c *fiber.Ctx) error {
+	ctx := context.Background()
+	name := strings.ToLower(c.Params("asset"))
+	args := []interface{}{name}
+	scanFilter := ""
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		args = append(args, scope)
+		scanFilter = " AND " + project.Filter("s.project_id", 2)
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT s.id, s.name, COALESCE(s.completed_at, s.created_at), COALESCE(r.ports, '[]'::jsonb)
+		FROM scan_results r JOIN scans s ON s.id = r.scan_id
+		WHERE (LOWER(r.host) = $1 OR LOWER(r.hostname) = $1) AND s.status = 'completed'`+scanFilter+`
+		ORDER BY 3
+	`, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch asset timeline"})
+	}
+	events := []timelineEvent{}
+	previous := map[int]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		var event timelineEvent
+		var raw []byte
+		if err := rows.Scan(&id, &event.ScanName, &event.Time, &raw); err != nil {
+			continue
+		}
+		var ports []models.Port
+		json.Unmarshal(raw, &ports)
+		open := map[int]bool{}
+		for _, p := range ports {
+			if p.State == "open" && !open[p.Port] {
+				open[p.Port] = true
+				event.OpenPorts = append(event.OpenPorts, p.Port)
+				if len(events) > 0 && !previous[p.Port] {
+					event.Opened = append(event.Opened, p.Port)
+				}
+			}
+		}
+		for port := range previous {
+			if !open[port] {
+				event.Closed = append(event.Closed, port)
+			}
+		}
+		sort.Ints(event.OpenPorts)
+		sort.Ints(event.Opened)
+		sort.Ints(event.Closed)
+		event.Type = "scan"
+		event.ScanID = &id
+		events = append(events, event)
+		previous = open
+	}
+	rows.Close()
+
+	rows, err = h.db.Pool.Query(ctx, `
+		SELECT `+screenshots.Columns+` FROM asset_screenshots
+		WHERE (asset = $1 OR hostname = $1)
+		  AND network_scan_id IN (SELECT s.id FROM scans s WHERE TRUE`+scanFilter+`)
+	`, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch asset timeline"})
+	}
+	var webScanIDs []uuid.UUID
+	var shots []*screenshots.Screenshot
+	for rows.Next() {
+		if shot, err := screenshots.Scan(rows); err == nil {
+			shots = append(shots, shot)
+			webScanIDs = append(webScanIDs, shot.WebScanID)
+		}
+	}
+	rows.Close()
+
+	// Screenshots of deleted web scans stay in the timeline
+	statuses := map[uuid.UUID]string{}
+	if len(webScanIDs) > 0 {
+		rows, err = h.db.Pool.Query(ctx, `SELECT id, status FROM web_scans WHERE id = ANY($1)`, webScanIDs)
+		if err == nil {
+			for rows.Next() {
+				var id uuid.UUID
+				var status string
+				if rows.Scan(&id, &status) == nil {
+					statuses[id] = status
+				}
+			}
+			rows.Close()
+		}
+	}
+	for _, shot := range shots {
+		status, ok := statuses[shot.WebScanID]
+		if !ok {
+			status = "deleted"
+		}
+		events = append(events, timelineEvent{Type: "screenshot", Time: shot.CreatedAt, Screenshot: shot, Status: status})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	return c.JSON(fiber.Map{
+		"asset":  name,
+		"events": events,
+		"total":  len(events),
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/scanner"
+)
+
+// templateUsageQuery counts the scans of each scanner, scan type and
+// template, with the hosts and open ports their completed, non-simulated
+// runs found
+const templateUsageQuery = `
+	WITH per_scan AS (
+		SELECT s.id, s.scanner, s.scan_type, s.template_id, s.status, s.created_at,
+		       COALESCE(s.configuration->>'simulated', '') = 'true' AS simulated,
+		       COUNT(r.id) AS hosts,
+		       COALESCE(SUM(CASE WHEN jsonb_typeof(r.ports) = 'array' THEN (
+		           SELECT COUNT(*) FROM jsonb_array_elements(r.ports) p WHERE p->>'state' = 'open'
+		       ) ELSE 0 END), 0) AS open_ports
+		FROM scans s
+		LEFT JOIN scan_results r ON r.scan_id = s.id
+		GROUP BY s.id
+	)
+	SELECT scanner, scan_type, template_id,
+	       COUNT(*),
+	       COUNT(*) FILTER (WHERE status = 'completed'),
+	       COUNT(*) FILTER (WHERE status = 'failed'),
+	       MAX(created_at),
+	       COUNT(*) FILTER (WHERE status = 'completed' AND NOT simulated),
+	       COALESCE(AVG(hosts) FILTER (WHERE status = 'completed' AND NOT simulated), 0)::float8,
+	       COALESCE(AVG(open_ports) FILTER (WHERE status = 'completed' AND NOT simulated), 0)::float8
+	FROM per_scan
+	GROUP BY scanner, scan_type, template_id
+`
+
+// GetTemplateAnalytics reports how much each builtin and stored template is
+// used and what its scans find, with hints for templates to retire: unused
+// for ?unused_days= (default 90), or with arguments the installed nmap no
+// longer accepts
+func (h *TemplateHandler) GetTemplateAnalytics(c *fiber.Ctx) error {
+	unusedDays := c.QueryInt("unused_days", 90)
+	if unusedDays < 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "unused_days must be at least 1"})
+	}
+	ctx := context.Background()
+
+	// Stored templates first (defaults before custom ones), so scans without
+	// template_id count for the stored copy of a builtin template
+	templates := []models.TemplateUsage{}
+	created := map[int]time.Time{}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, name, scan_type, scanner, COALESCE(nmap_arguments, ''), COALESCE(ports, ''), is_default, created_at
+		FROM scan_templates
+		ORDER BY is_default DESC, created_at, name
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch templates"})
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var t models.TemplateUsage
+		var arguments, ports string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &t.Name, &t.ScanType, &t.Scanner, &arguments, &ports, &t.IsDefault, &createdAt); err != nil {
+			continue
+		}
+		t.ID = &id
+		t.Source = "stored"
+		t.Arguments = arguments
+		if t.Scanner == "masscan" || t.Scanner == "naabu" {
+			t.Arguments = ports
+		}
+		created[len(templates)] = createdAt
+		templates = append(templates, t)
+	}
+	rows.Close()
+
+	byType := map[string]int{} // scanner/scan_type -> first template
+	byID := map[uuid.UUID]int{}
+	for i, t := range templates {
+		byID[*t.ID] = i
+		if _, ok := byType[t.Scanner+"/"+t.ScanType]; !ok {
+			byType[t.Scanner+"/"+t.ScanType] = i
+		}
+	}
+	for _, b := range builtinTemplates {
+		key := b.Scanner + "/" + b.ScanType
+		if _, ok := byType[key]; ok {
+			continue
+		}
+		arguments := b.Arguments
+		if b.Scanner == "masscan" || b.Scanner == "naabu" {
+			arguments = b.Ports
+		}
+		byType[key] = len(templates)
+		templates = append(templates, models.TemplateUsage{
+			Source:    "builtin",
+			Name:      b.Name,
+			Scanner:   b.Scanner,
+			ScanType:  b.ScanType,
+			Arguments: arguments,
+			IsDefault: true,
+		})
+	}
+
+	// Usage, attributed by template_id or else by scanner and scan type
+	usage, err := h.db.Pool.Query(ctx, templateUsageQuery)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch template usage"})
+	}
+	for usage.Next() {
+		var scannerName, scanType string
+		var templateID *uuid.UUID
+		var uses, completed, failed, measured int
+		var lastUsed time.Time
+		var avgHosts, avgOpenPorts float64
+		if err := usage.Scan(&scannerName, &scanType, &templateID, &uses, &completed, &failed,
+			&lastUsed, &measured, &avgHosts, &avgOpenPorts); err != nil {
+			continue
+		}
+
+		i, ok := -1, false
+		if templateID != nil {
+			i, ok = byID[*templateID]
+		}
+		if !ok {
+			if i, ok = byType[scannerName+"/"+scanType]; !ok {
+				continue // ad-hoc scan types without a template
+			}
+		}
+
+		t := &templates[i]
+		if total := t.MeasuredScans + measured; total > 0 {
+			t.AvgHosts = (t.AvgHosts*float64(t.MeasuredScans) + avgHosts*float64(measured)) / float64(total)
+			t.AvgOpenPorts = (t.AvgOpenPorts*float64(t.MeasuredScans) + avgOpenPorts*float64(measured)) / float64(total)
+		}
+		t.MeasuredScans += measured
+		t.Uses += uses
+		t.CompletedUses += completed
+		t.FailedUses += failed
+		if t.LastUsedAt == nil || lastUsed.After(*t.LastUsedAt) {
+			last := lastUsed
+			t.LastUsedAt = &last
+		}
+	}
+	usage.Close()
+
+	// Builtin templates exist since the first scan; without a scan older
+	// than the window, "unused" would flag every template of a new install
+	var firstScan *time.Time
+	h.db.Pool.QueryRow(ctx, `SELECT MIN(created_at) FROM scans`).Scan(&firstScan)
+
+	install := h.nmapScanner.Install()
+	cutoff := time.Now().AddDate(0, 0, -unusedDays)
+	for i := range templates {
+		t := &templates[i]
+		t.AvgHosts = math.Round(t.AvgHosts*100) / 100
+		t.AvgOpenPorts = math.Round(t.AvgOpenPorts*100) / 100
+		t.Hints = []models.TemplateHint{}
+
+		since := firstScan
+		if createdAt, ok := created[i]; ok && (since == nil || createdAt.After(*since)) {
+			since = &createdAt
+		}
+		switch {
+		case t.LastUsedAt != nil && t.LastUsedAt.Before(cutoff):
+			t.Hints = append(t.Hints, models.TemplateHint{
+				Kind:    models.HintUnused,
+				Message: fmt.Sprintf("last used %d days ago", int(time.Since(*t.LastUsedAt).Hours()/24)),
+			})
+		case t.LastUsedAt == nil && since != nil && since.Before(cutoff):
+			t.Hints = append(t.Hints, models.TemplateHint{
+				Kind:    models.HintUnused,
+				Message: fmt.Sprintf("not used in the last %d days", unusedDays),
+			})
+		}
+
+		t.Hints = append(t.Hints, templateArgumentHints(t, install)...)
+	}
+
+	return c.JSON(models.TemplateAnalytics{
+		NmapVersion: install.Version,
+		UnusedDays:  unusedDays,
+		Templates:   templates,
+	})
+}
+
+// templateArgumentHints checks a template's options against its scanner
+func templateArgumentHints(t *models.TemplateUsage, install scanner.NmapInstall) []models.TemplateHint {
+	switch t.Scanner {
+	case "nmap":
+		return scanner.CheckNmapArguments(t.Arguments, install)
+	case "masscan", "naabu":
+		if t.Arguments == "" {
+			return nil
+		}
+		if err := validatePortList(t.Arguments); err != nil {
+			return []models.TemplateHint{{Kind: models.HintInvalidArguments, Message: err.Error()}}
+		}
+	case "dns":
+		for _, b := range builtinTemplates {
+			if b.Scanner == "dns" && b.ScanType == t.ScanType {
+				return nil
+			}
+		}
+		return []models.TemplateHint{{
+			Kind:    models.HintUnknownScanType,
+			Message: fmt.Sprintf("the DNS scanner has no %s scan type", t.ScanType),
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/templatepacks"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+type TemplatePackHandler struct {
+	db       *database.Database
+	verifier *templatepacks.Verifier
+}
+
+func NewTemplatePackHandler(db *database.Database, verifier *templatepacks.Verifier) *TemplatePackHandler {
+	return &TemplatePackHandler{db: db, verifier: verifier}
+}
+
+// readBundle returns the bundle of a request: an uploaded file (multipart
+// "file"), {"url": "..."} to download, or the bundle itself as the body.
+// The URL is nil for uploads.
+func readBundle(c *fiber.Ctx) ([]byte, *string, error) {
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > templatepacks.MaxBundleSize {
+			return nil, nil, errors.New("template pack file is too large")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		return data, nil, err
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if strings.Contains(c.Get(fiber.HeaderContentType), "json") && c.BodyParser(&req) == nil && req.URL != "" {
+		data, err := templatepacks.Fetch(context.Background(), req.URL)
+		return data, &req.URL, err
+	}
+	if len(c.Body()) == 0 {
+		return nil, nil, errors.New(`send a bundle file, {"url": "..."} or the bundle as the body`)
+	}
+	if len(c.Body()) > templatepacks.MaxBundleSize {
+		return nil, nil, errors.New("template pack is too large")
+	}
+	return c.Body(), nil, nil
+}
+
+// verifyBundle verifies a bundle, returning the response to answer when it
+// fails. Its nmap templates are checked as templates created by hand are:
+// arguments outside the safe allow-list and masscan adapter options need
+// the admin role.
+func (h *TemplatePackHandler) verifyBundle(c *fiber.Ctx, data []byte) (*templatepacks.Verified, int, fiber.Map) {
+	verified, err := h.verifier.Verify(data)
+	if err != nil {
+		status := 400
+		if errors.Is(err, templatepacks.ErrUnsigned) || errors.Is(err, templatepacks.ErrUntrustedKey) ||
+			errors.Is(err, templatepacks.ErrBadSignature) {
+			status = 422
+		}
+		return nil, status, fiber.Map{"error": err.Error()}
+	}
+	for _, t := range verified.Pack.Nmap {
+		if status, body := nmapArgumentsError(c, t.NmapArguments); status != 0 {
+			body["template"] = t.Name
+			return nil, status, body
+		}
+		if status, body := masscanAdapterError(c, t.Configuration, ""); status != 0 {
+			body["template"] = t.Name
+			return nil, status, body
+		}
+	}
+	return verified, 0, nil
+}
+
+// packError answers a store error
+func packError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, templatepacks.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, templatepacks.ErrWrongPack):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, templatepacks.ErrExists), errors.Is(err, templatepacks.ErrConflict):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save template pack", "details": err.Error()})
+	}
+}
+
+// ListPacks returns the imported template packs, with ?project= (or
+// X-Tenant-ID) those of the project
+func (h *TemplatePackHandler) ListPacks(c *fiber.Ctx) error {
+	packs, err := templatepacks.List(context.Background(), h.db, project.Scope(c.Query("project"), c.Get(project.Header)))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch template packs"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(packs)))
+	return c.JSON(packs)
+}
+
+// GetPack returns a template pack and the templates it imported
+func (h *TemplatePackHandler) GetPack(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template pack ID"})
+	}
+	pack, err := templatepacks.Get(context.Background(), h.db, id)
+	if err != nil {
+		return packError(c, err)
+	}
+	return c.JSON(pack)
+}
+
+// ImportPack imports a signed template pack from a URL or an upload into
+// the caller's project
+func (h *TemplatePackHandler) ImportPack(c *fiber.Ctx) error {
+	data, sourceURL, err := readBundle(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	verified, status, body := h.verifyBundle(c, data)
+	if status != 0 {
+		return c.Status(status).JSON(body)
+	}
+	pack, err := templatepacks.Import(context.Background(), h.db, verified, templatepacks.Provenance{
+		SourceURL:  sourceURL,
+		ImportedBy: c.Get("X-User-ID"),
+		ProjectID:  project.FromRequest(c.Get(project.Header)),
+	})
+	if err != nil {
+		return packError(c, err)
+	}
+	return c.Status(201).JSON(pack)
+}
+
+// UpdatePack replaces the templates of a pack with the latest bundle: the
+// one uploaded, or without one the bundle at the pack's URL
+func (h *TemplatePackHandler) UpdatePack(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template pack ID"})
+	}
+	current, err := templatepacks.Get(context.Background(), h.db, id)
+	if err != nil {
+		return packError(c, err)
+	}
+
+	var data []byte
+	var sourceURL *string
+	if len(c.Body()) == 0 {
+		if current.SourceURL == nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Uploaded packs are updated by uploading the new bundle"})
+		}
+		if data, err = templatepacks.Fetch(context.Background(), *current.SourceURL); err != nil {
+			return c.Status(502).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else if data, sourceURL, err = readBundle(c); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	verified, status, body := h.verifyBundle(c, data)
+	if status != 0 {
+		return c.Status(status).JSON(body)
+	}
+
+	updated, err := templatepacks.Update(context.Background(), h.db, id, verified, sourceURL)
+	if err != nil {
+		return packError(c, err)
+	}
+	pack, err := templatepacks.Get(context.Background(), h.db, id)
+	if err != nil {
+		return packError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"updated":      updated,
+		"from_version": current.Version,
+		"pack":         pack,
+	})
+}
+
+// DeletePack removes a template pack and its templates
+func (h *TemplatePackHandler) DeletePack(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template pack ID"})
+	}
+	if err := templatepacks.Delete(context.Background(), h.db, id); err != nil {
+		return packError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Template pack deleted successfully"})
+}
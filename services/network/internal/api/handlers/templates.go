@@ -2,31 +2,39 @@ package handlers
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/scanner"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
 )
 
 type TemplateHandler struct {
-	db *database.Database
+	db          *database.Database
+	nmapScanner *scanner.Scanner // checks template arguments against the installed nmap
 }
 
-func NewTemplateHandler(db *database.Database) *TemplateHandler {
-	return &TemplateHandler{db: db}
+func NewTemplateHandler(db *database.Database, nmapScanner *scanner.Scanner) *TemplateHandler {
+	return &TemplateHandler{db: db, nmapScanner: nmapScanner}
 }
 
-// ListTemplates returns all templates
+// ListTemplates returns all templates. With ?project= (or X-Tenant-ID) only
+// that project's templates and the shared ones of the default project.
 func (h *TemplateHandler) ListTemplates(c *fiber.Ctx) error {
 	query := `
-		SELECT id, name, description, scan_type, nmap_arguments, configuration, is_default, created_at
+		SELECT id, name, description, scan_type, nmap_arguments, configuration, is_default, project_id, pack_id, created_at
 		FROM scan_templates
+		WHERE $1 = '' OR project_id IN ($1, 'default')
 		ORDER BY is_default DESC, name ASC
 	`
 
-	rows, err := h.db.Pool.Query(context.Background(), query)
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	rows, err := h.db.Pool.Query(context.Background(), query, scope)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch templates"})
 	}
@@ -36,13 +44,14 @@ func (h *TemplateHandler) ListTemplates(c *fiber.Ctx) error {
 	for rows.Next() {
 		var template models.ScanTemplate
 		err := rows.Scan(&template.ID, &template.Name, &template.Description, &template.ScanType,
-			&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.CreatedAt)
+			&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.ProjectID, &template.PackID, &template.CreatedAt)
 		if err != nil {
 			continue
 		}
 		templates = append(templates, template)
 	}
 
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(templates)))
 	return c.JSON(templates)
 }
 
@@ -51,7 +60,7 @@ func (h *TemplateHandler) GetTemplate(c *fiber.Ctx) error {
 	templateID := c.Params("id")
 
 	query := `
-		SELECT id, name, description, scan_type, nmap_arguments, configuration, is_default, created_at
+		SELECT id, name, description, scan_type, nmap_arguments, configuration, is_default, project_id, pack_id, created_at
 		FROM scan_templates
 		WHERE id = $1
 	`
@@ -59,7 +68,7 @@ func (h *TemplateHandler) GetTemplate(c *fiber.Ctx) error {
 	var template models.ScanTemplate
 	err := h.db.Pool.QueryRow(context.Background(), query, templateID).Scan(
 		&template.ID, &template.Name, &template.Description, &template.ScanType,
-		&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.CreatedAt,
+		&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.ProjectID, &template.PackID, &template.CreatedAt,
 	)
 
 	if err != nil {
@@ -79,6 +88,14 @@ func (h *TemplateHandler) CreateTemplate(c *fiber.Ctx) error {
 	if req.Name == "" || req.ScanType == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "name and scan_type are required"})
 	}
+	if req.NmapArguments != nil {
+		if status, body := nmapArgumentsError(c, *req.NmapArguments); status != 0 {
+			return c.Status(status).JSON(body)
+		}
+	}
+	if status, body := masscanAdapterError(c, req.Configuration, ""); status != 0 {
+		return c.Status(status).JSON(body)
+	}
 
 	// Check if template with same name exists
 	var exists bool
@@ -86,21 +103,22 @@ func (h *TemplateHandler) CreateTemplate(c *fiber.Ctx) error {
 	h.db.Pool.QueryRow(context.Background(), checkQuery, req.Name).Scan(&exists)
 
 	if exists {
-		return c.Status(400).JSON(fiber.Map{"error": "Template with this name already exists"})
+		return c.Status(409).JSON(fiber.Map{"error": "Template with this name already exists"})
 	}
 
 	templateID := uuid.New()
 	query := `
-		INSERT INTO scan_templates (id, name, description, scan_type, nmap_arguments, configuration, is_default, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, description, scan_type, nmap_arguments, configuration, is_default, created_at
+		INSERT INTO scan_templates (id, name, description, scan_type, nmap_arguments, configuration, is_default, project_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, name, description, scan_type, nmap_arguments, configuration, is_default, project_id, created_at
 	`
 
 	var template models.ScanTemplate
 	err := h.db.Pool.QueryRow(context.Background(), query,
-		templateID, req.Name, req.Description, req.ScanType, req.NmapArguments, req.Configuration, req.IsDefault, time.Now(),
+		templateID, req.Name, req.Description, req.ScanType, req.NmapArguments, req.Configuration, req.IsDefault,
+		project.FromRequest(c.Get(project.Header)), time.Now(),
 	).Scan(&template.ID, &template.Name, &template.Description, &template.ScanType,
-		&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.CreatedAt)
+		&template.NmapArguments, &template.Configuration, &template.IsDefault, &template.ProjectID, &template.CreatedAt)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create template"})
@@ -117,6 +135,14 @@ func (h *TemplateHandler) UpdateTemplate(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if req.NmapArguments != nil {
+		if status, body := nmapArgumentsError(c, *req.NmapArguments); status != 0 {
+			return c.Status(status).JSON(body)
+		}
+	}
+	if status, body := masscanAdapterError(c, req.Configuration, ""); status != 0 {
+		return c.Status(status).JSON(body)
+	}
 
 	query := `
 		UPDATE scan_templates
@@ -172,58 +198,77 @@ type BuiltinTemplate struct {
 	Rate        int    `json:"rate,omitempty"`
 }
 
+// builtinTemplates are the predefined scan templates of all scanners
+var builtinTemplates = []BuiltinTemplate{
+	// Nmap templates
+	{ScanType: "quick", Name: "Quick Scan", Description: "Fast scan of the most common 100 ports", Arguments: "-F -T4", Scanner: "nmap"},
+	{ScanType: "full", Name: "Full Port Scan", Description: "Comprehensive scan of all 65535 ports", Arguments: "-p- -T4", Scanner: "nmap"},
+	{ScanType: "udp", Name: "UDP Scan", Description: "Scan common UDP ports", Arguments: "-sU --top-ports 100 -T4", Scanner: "nmap"},
+	{ScanType: "discovery", Name: "Host Discovery", Description: "Discover active hosts in network (ping sweep)", Arguments: "-sn -PE -PP -PM --dns-servers 8.8.8.8,1.1.1.1 -T4", Scanner: "nmap"},
+	{ScanType: "local_network", Name: "Local Network Scan", Description: "Complete local network scan with MAC vendor identification", Arguments: "-sn -PR --dns-servers 8.8.8.8,1.1.1.1 -T4", Scanner: "nmap"},
+	{ScanType: "web_server", Name: "Web Server Scan", Description: "Scan web servers (HTTP/HTTPS) with service detection", Arguments: "-p 80,443,8080,8443,3000,5000,8000 -sV --script http-title,http-methods,http-headers -T4", Scanner: "nmap"},
+	{ScanType: "db_server", Name: "Database Server Scan", Description: "Scan common database ports with version detection", Arguments: "-p 3306,5432,1433,1521,27017,6379,5984,9200,11211 -sV -T4", Scanner: "nmap"},
+	{ScanType: "mail_server", Name: "Mail Server Scan", Description: "Scan mail servers (SMTP, POP3, IMAP)", Arguments: "-p 25,110,143,465,587,993,995 -sV --script smtp-commands,pop3-capabilities,imap-capabilities -T4", Scanner: "nmap"},
+	{ScanType: "ftp_ssh_server", Name: "FTP/SSH Server Scan", Description: "Scan file transfer and remote access services", Arguments: "-p 20,21,22,23,990,2121,2222 -sV --script ftp-anon,ssh-auth-methods -T4", Scanner: "nmap"},
+	{ScanType: "service", Name: "Service Version Detection", Description: "Detect service versions and OS", Arguments: "-sV -O -T4", Scanner: "nmap"},
+	{ScanType: "vulnerability", Name: "Vulnerability Scan", Description: "Scan with NSE vulnerability scripts", Arguments: "-sV --script vuln -T4", Scanner: "nmap"},
+	{ScanType: "security_audit", Name: "Security Audit", Description: "Complete security audit with SSL/TLS checks", Arguments: "-p- -sV --script ssl-cert,ssl-enum-ciphers,ssh-auth-methods -T4", Scanner: "nmap"},
+	{ScanType: "stealth", Name: "Stealth Scan", Description: "SYN stealth scan with minimal footprint", Arguments: "-sS -T2 -f", Scanner: "nmap"},
+	{ScanType: "aggressive", Name: "Aggressive Scan", Description: "Aggressive scan with OS detection, version, scripts and traceroute", Arguments: "-A -T4", Scanner: "nmap"},
+	// Masscan templates
+	{ScanType: "masscan_quick", Name: "Masscan Quick Scan", Description: "Fast scan of common ports at high speed", Ports: "21,22,23,25,53,80,110,111,135,139,143,443,445,993,995,1723,3306,3389,5900,8080", Rate: 10000, Scanner: "masscan"},
+	{ScanType: "masscan_full", Name: "Masscan Full Port Scan", Description: "Scan all 65535 ports at high speed", Ports: "1-65535", Rate: 100000, Scanner: "masscan"},
+	{ScanType: "masscan_web", Name: "Masscan Web Ports", Description: "Scan common web server ports", Ports: "80,443,8080,8443,8000,8888,9000,9090,3000,5000", Rate: 10000, Scanner: "masscan"},
+	{ScanType: "masscan_database", Name: "Masscan Database Ports", Description: "Scan common database ports", Ports: "1433,1521,3306,5432,6379,27017,9200,5984", Rate: 10000, Scanner: "masscan"},
+	// Native (pure-Go TCP connect) templates
+	{ScanType: "native_quick", Name: "Native Quick Scan", Description: "TCP connect scan of the 100 most common ports without nmap", Scanner: "native"},
+	{ScanType: "native_web", Name: "Native Web Ports", Description: "TCP connect scan of common web server ports without nmap", Ports: "80,443,8080,8443,8000,8888,9000,9090,3000,5000", Scanner: "native"},
+	{ScanType: "native_full", Name: "Native Full Port Scan", Description: "TCP connect scan of all 65535 ports without nmap (slow)", Ports: "1-65535", Scanner: "native"},
+	// Naabu (SYN scan, open ports confirmed with a TCP connect) templates
+	{ScanType: "naabu_top100", Name: "Naabu Top 100 Ports", Description: "SYN scan of the 100 most common ports, verified with a TCP connect", Rate: 1000, Scanner: "naabu"},
+	{ScanType: "naabu_top1000", Name: "Naabu Top 1000 Ports", Description: "SYN scan of the 1000 most common ports, verified with a TCP connect", Rate: 1000, Scanner: "naabu"},
+	{ScanType: "naabu_full", Name: "Naabu Full Port Scan", Description: "SYN scan of all 65535 ports, verified with a TCP connect", Ports: "1-65535", Rate: 5000, Scanner: "naabu"},
+	// Pipeline (masscan discovery, then nmap on the open ports) templates
+	{ScanType: "pipeline_quick", Name: "Pipeline Quick Scan", Description: "masscan on the first 1000 ports, then nmap service detection on the open ones", Arguments: "-sV -T4", Ports: "1-1000", Rate: 10000, Scanner: "pipeline"},
+	{ScanType: "pipeline_full", Name: "Pipeline Full Scan", Description: "masscan on all 65535 ports, then nmap service detection on the open ones", Arguments: "-sV -T4", Ports: "1-65535", Rate: 50000, Scanner: "pipeline"},
+	{ScanType: "pipeline_deep", Name: "Pipeline Deep Scan", Description: "masscan on all 65535 ports, then nmap service, OS and script detection on the open ones", Arguments: "-sV -sC -O -T4", Ports: "1-65535", Rate: 50000, Scanner: "pipeline"},
+	// DNS templates
+	{ScanType: "dns_records", Name: "DNS Records Scan", Description: "Query all DNS record types (A, AAAA, MX, NS, TXT)", Scanner: "dns"},
+	{ScanType: "dns_full", Name: "Full DNS Scan", Description: "Complete DNS reconnaissance including subdomain enumeration", Scanner: "dns"},
+	{ScanType: "dns_subdomain", Name: "Subdomain Enumeration", Description: "Discover subdomains using common wordlist", Scanner: "dns"},
+}
+
 // ListBuiltinTemplates returns predefined scan templates for all scanners
 func (h *TemplateHandler) ListBuiltinTemplates(c *fiber.Ctx) error {
-	templates := []BuiltinTemplate{
-		// Nmap templates
-		{ScanType: "quick", Name: "Quick Scan", Description: "Fast scan of the most common 100 ports", Arguments: "-F -T4", Scanner: "nmap"},
-		{ScanType: "full", Name: "Full Port Scan", Description: "Comprehensive scan of all 65535 ports", Arguments: "-p- -T4", Scanner: "nmap"},
-		{ScanType: "udp", Name: "UDP Scan", Description: "Scan common UDP ports", Arguments: "-sU --top-ports 100 -T4", Scanner: "nmap"},
-		{ScanType: "discovery", Name: "Host Discovery", Description: "Discover active hosts in network (ping sweep)", Arguments: "-sn -PE -PP -PM --dns-servers 8.8.8.8,1.1.1.1 -T4", Scanner: "nmap"},
-		{ScanType: "local_network", Name: "Local Network Scan", Description: "Complete local network scan with MAC vendor identification", Arguments: "-sn -PR --dns-servers 8.8.8.8,1.1.1.1 -T4", Scanner: "nmap"},
-		{ScanType: "web_server", Name: "Web Server Scan", Description: "Scan web servers (HTTP/HTTPS) with service detection", Arguments: "-p 80,443,8080,8443,3000,5000,8000 -sV --script http-title,http-methods,http-headers -T4", Scanner: "nmap"},
-		{ScanType: "db_server", Name: "Database Server Scan", Description: "Scan common database ports with version detection", Arguments: "-p 3306,5432,1433,1521,27017,6379,5984,9200,11211 -sV -T4", Scanner: "nmap"},
-		{ScanType: "mail_server", Name: "Mail Server Scan", Description: "Scan mail servers (SMTP, POP3, IMAP)", Arguments: "-p 25,110,143,465,587,993,995 -sV --script smtp-commands,pop3-capabilities,imap-capabilities -T4", Scanner: "nmap"},
-		{ScanType: "ftp_ssh_server", Name: "FTP/SSH Server Scan", Description: "Scan file transfer and remote access services", Arguments: "-p 20,21,22,23,990,2121,2222 -sV --script ftp-anon,ssh-auth-methods -T4", Scanner: "nmap"},
-		{ScanType: "service", Name: "Service Version Detection", Description: "Detect service versions and OS", Arguments: "-sV -O -T4", Scanner: "nmap"},
-		{ScanType: "vulnerability", Name: "Vulnerability Scan", Description: "Scan with NSE vulnerability scripts", Arguments: "-sV --script vuln -T4", Scanner: "nmap"},
-		{ScanType: "security_audit", Name: "Security Audit", Description: "Complete security audit with SSL/TLS checks", Arguments: "-p- -sV --script ssl-cert,ssl-enum-ciphers,ssh-auth-methods -T4", Scanner: "nmap"},
-		{ScanType: "stealth", Name: "Stealth Scan", Description: "SYN stealth scan with minimal footprint", Arguments: "-sS -T2 -f", Scanner: "nmap"},
-		{ScanType: "aggressive", Name: "Aggressive Scan", Description: "Aggressive scan with OS detection, version, scripts and traceroute", Arguments: "-A -T4", Scanner: "nmap"},
-		// Masscan templates
-		{ScanType: "masscan_quick", Name: "Masscan Quick Scan", Description: "Fast scan of common ports at high speed", Ports: "21,22,23,25,53,80,110,111,135,139,143,443,445,993,995,1723,3306,3389,5900,8080", Rate: 10000, Scanner: "masscan"},
-		{ScanType: "masscan_full", Name: "Masscan Full Port Scan", Description: "Scan all 65535 ports at high speed", Ports: "1-65535", Rate: 100000, Scanner: "masscan"},
-		{ScanType: "masscan_web", Name: "Masscan Web Ports", Description: "Scan common web server ports", Ports: "80,443,8080,8443,8000,8888,9000,9090,3000,5000", Rate: 10000, Scanner: "masscan"},
-		{ScanType: "masscan_database", Name: "Masscan Database Ports", Description: "Scan common database ports", Ports: "1433,1521,3306,5432,6379,27017,9200,5984", Rate: 10000, Scanner: "masscan"},
-		// DNS templates
-		{ScanType: "dns_records", Name: "DNS Records Scan", Description: "Query all DNS record types (A, AAAA, MX, NS, TXT)", Scanner: "dns"},
-		{ScanType: "dns_full", Name: "Full DNS Scan", Description: "Complete DNS reconnaissance including subdomain enumeration", Scanner: "dns"},
-		{ScanType: "dns_subdomain", Name: "Subdomain Enumeration", Description: "Discover subdomains using common wordlist", Scanner: "dns"},
-	}
-
-	return c.JSON(templates)
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(builtinTemplates)))
+	return c.JSON(builtinTemplates)
 }
 
 // VulnTemplate represents a vulnerability scan template
 type VulnTemplate struct {
-	ID             string   `json:"id"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Category       string   `json:"category"`
-	NucleiTags     []string `json:"nuclei_tags"`
-	SeverityFilter []string `json:"severity_filter"`
-	IsDefault      bool     `json:"is_default"`
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Category       string     `json:"category"`
+	NucleiTags     []string   `json:"nuclei_tags"`
+	SeverityFilter []string   `json:"severity_filter"`
+	IsDefault      bool       `json:"is_default"`
+	PackID         *uuid.UUID `json:"pack_id,omitempty"` // the template pack that imported it
 }
 
-// ListVulnerabilityTemplates returns predefined Nuclei vulnerability scan templates
+// ListVulnerabilityTemplates returns predefined Nuclei vulnerability scan
+// templates, with ?project= (or X-Tenant-ID) those of the project and the
+// shared ones
 func (h *TemplateHandler) ListVulnerabilityTemplates(c *fiber.Ctx) error {
 	query := `
-		SELECT id, name, description, category, nuclei_tags, severity_filter, is_default
+		SELECT id, name, description, category, nuclei_tags, severity_filter, is_default, pack_id
 		FROM vulnerability_templates
+		WHERE $1 = '' OR project_id IN ($1, 'default')
 		ORDER BY is_default DESC, category, name
 	`
 
-	rows, err := h.db.Pool.Query(context.Background(), query)
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	rows, err := h.db.Pool.Query(context.Background(), query, scope)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch vulnerability templates"})
 	}
@@ -233,12 +278,13 @@ func (h *TemplateHandler) ListVulnerabilityTemplates(c *fiber.Ctx) error {
 	for rows.Next() {
 		var template VulnTemplate
 		err := rows.Scan(&template.ID, &template.Name, &template.Description, &template.Category,
-			&template.NucleiTags, &template.SeverityFilter, &template.IsDefault)
+			&template.NucleiTags, &template.SeverityFilter, &template.IsDefault, &template.PackID)
 		if err != nil {
 			continue
 		}
 		templates = append(templates, template)
 	}
 
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(templates)))
 	return c.JSON(templates)
 }
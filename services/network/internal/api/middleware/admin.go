@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/internalauth"
+)
+
+// AdminAuth requires admin rights: the X-Admin-Token header matching token,
+// or a user the gateway authenticated with the admin role (see DetectAdmin).
+// Without a token only gateway admins get through.
+func AdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fromGateway, _ := c.Locals(internalauth.VerifiedLocal).(bool)
+		if fromGateway && c.Get("X-User-Role") == "admin" {
+			return c.Next()
+		}
+		if token == "" {
+			return c.Status(403).JSON(fiber.Map{"error": "Admin role required (ADMIN_TOKEN not set)"})
+		}
+		provided := c.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			return c.Status(401).JSON(fiber.Map{"error": "Admin role or a valid X-Admin-Token required"})
+		}
+		return c.Next()
+	}
+}
+
+// IsAdminLocal is the c.Locals key set by DetectAdmin
+const IsAdminLocal = "is_admin"
+
+// DetectAdmin marks requests with admin rights, i.e. carrying the admin token
+// or authenticated by the gateway as a user with the admin role, so handlers
+// can allow admin-only options without the route itself being admin-only.
+// X-User-Role is only believed when InternalAuth has verified the gateway's
+// token for the request; it runs after InternalAuth for that reason.
+func DetectAdmin(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided := c.Get("X-Admin-Token")
+		fromGateway, _ := c.Locals(internalauth.VerifiedLocal).(bool)
+		isAdmin := (fromGateway && c.Get("X-User-Role") == "admin") ||
+			(token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1)
+		c.Locals(IsAdminLocal, isAdmin)
+		return c.Next()
+	}
+}
+
+// RequireAdmin only lets through requests DetectAdmin found admin rights in:
+// the admin token, or a gateway-authenticated user with the admin role
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isAdmin, _ := c.Locals(IsAdminLocal).(bool); !isAdmin {
+			return c.Status(403).JSON(fiber.Map{"error": "Admin role or X-Admin-Token required"})
+		}
+		return c.Next()
+	}
+}
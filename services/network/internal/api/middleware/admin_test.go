@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/internalauth"
+)
+
+func TestAdminAuthAcceptsGatewayAdmins(t *testing.T) {
+	cases := []struct {
+		configured, role, verified, provided string
+		want                                 int
+	}{
+		{"secret", "admin", "true", "", 204},
+		{"secret", "", "false", "secret", 204},
+		{"secret", "admin", "false", "", 401},
+		{"secret", "analyst", "true", "", 401},
+		{"", "admin", "true", "", 204},
+		{"", "admin", "false", "", 403},
+		{"", "", "false", "anything", 403},
+	}
+	for _, tc := range cases {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals(internalauth.VerifiedLocal, tc.verified == "true")
+			return c.Next()
+		})
+		app.Use(AdminAuth(tc.configured))
+		app.Get("/backups", func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+		req := httptest.NewRequest("GET", "/backups", nil)
+		req.Header.Set("X-User-Role", tc.role)
+		req.Header.Set("X-Admin-Token", tc.provided)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%+v: got %d", tc, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/network-service/internal/agents"
+)
+
+// AgentAuth requires a registered agent token in "Authorization: Bearer <token>"
+// and stores the agent in c.Locals("agent")
+func AgentAuth(registry *agents.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		agent, err := registry.Authenticate(context.Background(), token)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid agent token"})
+		}
+		c.Locals("agent", agent)
+		return c.Next()
+	}
+}
@@ -8,7 +8,7 @@ import (
 func CORS() fiber.Handler {
 	return cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Admin-Token, X-Tenant-ID",
 		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
 	})
 }
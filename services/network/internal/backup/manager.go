@@ -0,0 +1,372 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+const (
+	// jobTimeout bounds a backup or restore
+	jobTimeout = 2 * time.Hour
+	// leaseJob is the lease a replica holds while it runs a job
+	leaseJob = "network:backups"
+)
+
+// ErrJobRunning is returned when a backup or restore is already in progress
+var ErrJobRunning = errors.New("a backup or restore job is already running")
+
+// ErrNotFound is returned when a job or backup object does not exist
+var ErrNotFound = errors.New("backup not found")
+
+// Job is a backup or restore run tracked in platform_backups
+type Job struct {
+	ID           uuid.UUID  `json:"id"`
+	Type         string     `json:"type"` // backup or restore
+	Status       string     `json:"status"`
+	ObjectKey    string     `json:"object_key"`
+	SizeBytes    int64      `json:"size_bytes"`
+	SourceID     *uuid.UUID `json:"source_id,omitempty"`
+	ErrorMessage *string    `json:"error_message,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Manager takes logical backups of the platform database with pg_dump,
+// stores them in object storage and restores them with pg_restore.
+// Only one job runs at a time, across every replica of the service.
+type Manager struct {
+	db          *database.Database
+	databaseURL string
+	storage     Storage
+	leases      *shareddb.Leases
+
+	mu      sync.Mutex
+	running bool
+}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS platform_backups (
+    id UUID PRIMARY KEY,
+    type VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    object_key VARCHAR(500) NOT NULL,
+    size_bytes BIGINT DEFAULT 0,
+    source_id UUID,
+    error_message TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+func NewManager(db *database.Database, databaseURL string, storage Storage, leases *shareddb.Leases) (*Manager, error) {
+	ctx := context.Background()
+	if _, err := db.Pool.Exec(ctx, schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create platform_backups table: %w", err)
+	}
+
+	m := &Manager{db: db, databaseURL: databaseURL, storage: storage, leases: leases}
+	// While another replica holds the lease its jobs are still running
+	if leases.Acquire(ctx, leaseJob, jobTimeout+time.Minute) {
+		m.failInterrupted(ctx)
+		leases.Release(ctx, leaseJob)
+	}
+
+	log.Printf("💾 Backups stored in %s", storage.Name())
+	return m, nil
+}
+
+// failInterrupted fails the jobs left unfinished by a restart, which will
+// never finish. It must be called holding the lease.
+func (m *Manager) failInterrupted(ctx context.Context) {
+	m.db.Pool.Exec(ctx,
+		`UPDATE platform_backups SET status = 'failed', error_message = 'interrupted by service restart', completed_at = NOW()
+		 WHERE status IN ('pending', 'running')`)
+}
+
+// StartBackup records a backup job and runs pg_dump in the background
+func (m *Manager) StartBackup(ctx context.Context) (*Job, error) {
+	if !m.acquire(ctx) {
+		return nil, ErrJobRunning
+	}
+
+	job := &Job{
+		ID:        uuid.New(),
+		Type:      "backup",
+		Status:    "pending",
+		ObjectKey: fmt.Sprintf("nmap_scanner-%s.dump", time.Now().UTC().Format("20060102T150405Z")),
+		CreatedAt: time.Now(),
+	}
+	if err := m.insertJob(ctx, job); err != nil {
+		m.release()
+		return nil, err
+	}
+
+	go func() {
+		defer m.release()
+		m.run(job.ID, m.backup)
+	}()
+	return job, nil
+}
+
+// StartRestore records a restore job for a completed backup and runs pg_restore in the background
+func (m *Manager) StartRestore(ctx context.Context, backupID uuid.UUID) (*Job, error) {
+	source, err := m.GetJob(ctx, backupID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Type != "backup" || source.Status != "completed" {
+		return nil, fmt.Errorf("backup %s is not a completed backup", backupID)
+	}
+
+	if !m.acquire(ctx) {
+		return nil, ErrJobRunning
+	}
+
+	job := &Job{
+		ID:        uuid.New(),
+		Type:      "restore",
+		Status:    "pending",
+		ObjectKey: source.ObjectKey,
+		SourceID:  &source.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := m.insertJob(ctx, job); err != nil {
+		m.release()
+		return nil, err
+	}
+
+	go func() {
+		defer m.release()
+		m.run(job.ID, m.restore)
+	}()
+	return job, nil
+}
+
+// acquire reserves the job slot of this replica and the lease keeping other
+// replicas from running jobs. Jobs still unfinished when the lease is free
+// belong to a replica that died running them.
+func (m *Manager) acquire(ctx context.Context) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return false
+	}
+	if !m.leases.Acquire(ctx, leaseJob, jobTimeout+time.Minute) {
+		return false
+	}
+	m.failInterrupted(ctx)
+	m.running = true
+	return true
+}
+
+func (m *Manager) release() {
+	m.mu.Lock()
+	m.running = false
+	m.mu.Unlock()
+	m.leases.Release(context.Background(), leaseJob)
+}
+
+func (m *Manager) insertJob(ctx context.Context, job *Job) error {
+	_, err := m.db.Pool.Exec(ctx,
+		`INSERT INTO platform_backups (id, type, status, object_key, source_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		job.ID, job.Type, job.Status, job.ObjectKey, job.SourceID, job.CreatedAt)
+	return err
+}
+
+// run executes a job and records its outcome
+func (m *Manager) run(id uuid.UUID, fn func(ctx context.Context, key string) (int64, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	var key string
+	m.db.Pool.QueryRow(ctx,
+		`UPDATE platform_backups SET status = 'running', started_at = NOW() WHERE id = $1 RETURNING object_key`,
+		id).Scan(&key)
+
+	size, err := fn(ctx, key)
+	if err != nil {
+		log.Printf("❌ Backup job %s failed: %v", id, err)
+		m.db.Pool.Exec(context.Background(),
+			`UPDATE platform_backups SET status = 'failed', error_message = $1, completed_at = NOW() WHERE id = $2`,
+			err.Error(), id)
+		return
+	}
+
+	log.Printf("✅ Backup job %s completed (%s, %d bytes)", id, key, size)
+	m.db.Pool.Exec(context.Background(),
+		`UPDATE platform_backups SET status = 'completed', size_bytes = $1, completed_at = NOW() WHERE id = $2`,
+		size, id)
+}
+
+// backup dumps the database to a temporary file and uploads it
+func (m *Manager) backup(ctx context.Context, key string) (int64, error) {
+	tmp, err := os.CreateTemp("", "backup-*.dump")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	dbname, env := pgConnection(m.databaseURL)
+	// The job table is excluded so a restore does not rewrite backup history
+	cmd := exec.CommandContext(ctx, "pg_dump",
+		"--format=custom",
+		"--no-owner",
+		"--no-privileges",
+		"--exclude-table=platform_backups",
+		"--dbname="+dbname)
+	cmd.Env = env
+	cmd.Stdout = tmp
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("pg_dump failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := m.storage.Put(ctx, key, tmp, info.Size()); err != nil {
+		return 0, fmt.Errorf("upload failed: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// pgConnection splits the password out of a connection string (URL or
+// key=value) for pg_dump and pg_restore: the string without it goes on the
+// command line, which any local user can read in the process list, and the
+// password goes in PGPASSWORD in the environment returned
+func pgConnection(databaseURL string) (string, []string) {
+	env := os.Environ()
+	if u, err := url.Parse(databaseURL); err == nil && strings.Contains(databaseURL, "://") {
+		if u.User == nil {
+			return databaseURL, env
+		}
+		password, ok := u.User.Password()
+		if !ok {
+			return databaseURL, env
+		}
+		u.User = url.User(u.User.Username())
+		return u.String(), append(env, "PGPASSWORD="+password)
+	}
+
+	var kept []string
+	for _, field := range strings.Fields(databaseURL) {
+		if password, ok := strings.CutPrefix(field, "password="); ok {
+			env = append(env, "PGPASSWORD="+strings.Trim(password, "'"))
+			continue
+		}
+		kept = append(kept, field)
+	}
+	return strings.Join(kept, " "), env
+}
+
+// restore downloads a dump and replaces the current schema objects with it
+func (m *Manager) restore(ctx context.Context, key string) (int64, error) {
+	body, err := m.storage.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("download failed: %w", err)
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "restore-*.dump")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		return 0, fmt.Errorf("download failed: %w", err)
+	}
+
+	dbname, env := pgConnection(m.databaseURL)
+	cmd := exec.CommandContext(ctx, "pg_restore",
+		"--clean",
+		"--if-exists",
+		"--no-owner",
+		"--no-privileges",
+		"--single-transaction",
+		"--dbname="+dbname,
+		tmp.Name())
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("pg_restore failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return size, nil
+}
+
+// ListJobs returns the most recent backup and restore jobs
+func (m *Manager) ListJobs(ctx context.Context, jobType string, limit int) ([]Job, error) {
+	query := `SELECT id, type, status, object_key, COALESCE(size_bytes, 0), source_id, error_message, started_at, completed_at, created_at
+		FROM platform_backups`
+	args := []interface{}{}
+	if jobType != "" {
+		query += " WHERE type = $1"
+		args = append(args, jobType)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT %d", limit)
+
+	rows, err := m.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var job Job
+		if err := rows.Scan(&job.ID, &job.Type, &job.Status, &job.ObjectKey, &job.SizeBytes, &job.SourceID,
+			&job.ErrorMessage, &job.StartedAt, &job.CompletedAt, &job.CreatedAt); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// GetJob returns a single job
+func (m *Manager) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var job Job
+	err := m.db.Pool.QueryRow(ctx,
+		`SELECT id, type, status, object_key, COALESCE(size_bytes, 0), source_id, error_message, started_at, completed_at, created_at
+		 FROM platform_backups WHERE id = $1`, id).
+		Scan(&job.ID, &job.Type, &job.Status, &job.ObjectKey, &job.SizeBytes, &job.SourceID,
+			&job.ErrorMessage, &job.StartedAt, &job.CompletedAt, &job.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListObjects returns the dumps present in storage
+func (m *Manager) ListObjects(ctx context.Context) ([]Object, error) {
+	return m.storage.List(ctx)
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/security-scanner/shared/pkg/objectstore"
+)
+
+// Object is a stored backup file
+type Object = objectstore.Object
+
+// Storage is where backup dumps are kept
+type Storage interface {
+	Put(ctx context.Context, key string, file *os.File, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context) ([]Object, error)
+	Name() string
+}
+
+// LocalStorage keeps backups in a directory (e.g. a mounted volume)
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+func (s *LocalStorage) Name() string {
+	return "local:" + s.dir
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid backup key: %s", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, file *os.File, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, file)
+	return err
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalStorage) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []Object{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Key: entry.Name(), Size: info.Size(), LastModified: info.ModTime()})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].LastModified.After(objects[j].LastModified) })
+	return objects, nil
+}
+
+// S3Storage stores backups in an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Storage struct {
+	*objectstore.S3
+}
+
+func NewS3Storage(endpoint, region, bucket, prefix, accessKey, secretKey string) *S3Storage {
+	return &S3Storage{S3: objectstore.NewS3(endpoint, region, bucket, prefix, accessKey, secretKey)}
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, file *os.File, size int64) error {
+	return s.S3.Put(ctx, key, file, size, "application/octet-stream")
+}
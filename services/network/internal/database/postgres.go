@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/scope"
 )
 
 type Database struct {
@@ -19,39 +20,22 @@ func NewDatabase(databaseURL string) (*Database, error) {
 		return nil, fmt.Errorf("unable to parse database URL: %w", err)
 	}
 
-	// Retry logic with exponential backoff
-	maxRetries := 10
+	// Retries while the database starts, like every service
 	var pool *pgxpool.Pool
-
-	for i := 0; i < maxRetries; i++ {
-		pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	err = shareddb.Retry(func() error {
+		p, err := pgxpool.NewWithConfig(context.Background(), config)
 		if err != nil {
-			waitTime := time.Duration(1<<uint(i)) * time.Second
-			if waitTime > 30*time.Second {
-				waitTime = 30 * time.Second
-			}
-			log.Printf("Failed to create connection pool (attempt %d/%d): %v. Retrying in %v...", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
-		}
-
-		// Test connection
-		err = pool.Ping(context.Background())
-		if err == nil {
-			break
+			return err
 		}
-
-		pool.Close()
-		waitTime := time.Duration(1<<uint(i)) * time.Second
-		if waitTime > 30*time.Second {
-			waitTime = 30 * time.Second
+		if err := p.Ping(context.Background()); err != nil {
+			p.Close()
+			return err
 		}
-		log.Printf("Failed to ping database (attempt %d/%d): %v. Retrying in %v...", i+1, maxRetries, err, waitTime)
-		time.Sleep(waitTime)
-	}
-
+		pool = p
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
 
 	log.Println("Connected to PostgreSQL database")
@@ -62,3 +46,25 @@ func NewDatabase(databaseURL string) (*Database, error) {
 func (db *Database) Close() {
 	db.Pool.Close()
 }
+
+// Leases creates the job lease table and returns the leases electing which
+// replica runs each background job
+func (db *Database) Leases(ctx context.Context) (*shareddb.Leases, error) {
+	if _, err := db.Pool.Exec(ctx, shareddb.LeaseSchemaSQL); err != nil {
+		return nil, err
+	}
+	return shareddb.NewLeases(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
+
+// Scope creates the scope rule table, managed through the gateway, and
+// returns the checker scans' targets must pass
+func (db *Database) Scope(ctx context.Context) (*scope.Checker, error) {
+	if _, err := db.Pool.Exec(ctx, scope.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
@@ -0,0 +1,149 @@
+package deception
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// LikelyScore is the score from which a host is marked as a likely honeypot
+const LikelyScore = 50
+
+// honeypotKeywords appear in banners of common honeypot and tarpit software
+var honeypotKeywords = []string{
+	"honeypot", "honeyd", "cowrie", "kippo", "dionaea", "conpot", "glastopf",
+	"amun", "labrea", "t-pot", "opencanary", "heralding", "elastichoney",
+}
+
+const schemaSQL = `
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS honeypot_score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS honeypot_reasons JSONB;
+CREATE INDEX IF NOT EXISTS idx_scan_results_honeypot ON scan_results(scan_id) WHERE honeypot_score >= 50`
+
+// EnsureSchema adds the honeypot columns to scan_results
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to add honeypot columns: %w", err)
+	}
+	return nil
+}
+
+// Signals are scanner measurements beyond the port list; zero values mean unknown
+type Signals struct {
+	ScannedPorts int           // ports probed on the host, listed or not
+	SRTT         time.Duration // smoothed round-trip time
+	RTTVar       time.Duration // round-trip time variance
+}
+
+// Assess scores how likely the host behind ports is a honeypot or tarpit.
+// It returns nil when nothing suspicious was found.
+func Assess(ports []models.Port, sig Signals) *models.HoneypotAssessment {
+	var open []models.Port
+	for _, p := range ports {
+		if p.State == "open" {
+			open = append(open, p)
+		}
+	}
+
+	a := &models.HoneypotAssessment{Reasons: []string{}}
+	add := func(points int, reason string) {
+		a.Score += points
+		a.Reasons = append(a.Reasons, reason)
+	}
+
+	// Every port open: real hosts close or filter most of what is probed
+	switch {
+	case sig.ScannedPorts >= 20 && len(open) >= 20 && float64(len(open)) >= 0.9*float64(sig.ScannedPorts):
+		add(40, fmt.Sprintf("%d of %d scanned ports are open", len(open), sig.ScannedPorts))
+	case sig.ScannedPorts == 0 && len(open) >= 200:
+		add(40, fmt.Sprintf("%d ports are open", len(open)))
+	}
+
+	// Ports that accept connections but never speak (LaBrea-style tarpits)
+	silent := 0
+	for _, p := range open {
+		if p.Service == "tcpwrapped" {
+			silent++
+		}
+	}
+	if len(open) >= 10 && float64(silent) >= 0.8*float64(len(open)) {
+		add(25, fmt.Sprintf("%d of %d open ports accept connections but send nothing", silent, len(open)))
+	}
+
+	// The same banner behind unrelated services
+	banners := map[string][]models.Port{}
+	for _, p := range open {
+		if p.Product == "" {
+			continue
+		}
+		key := strings.ToLower(p.Product + "|" + p.Version + "|" + p.ExtraInfo)
+		banners[key] = append(banners[key], p)
+	}
+	for _, group := range banners {
+		services := map[string]bool{}
+		for _, p := range group {
+			services[p.Service] = true
+		}
+		if len(group) >= 5 && len(services) >= 2 {
+			add(30, fmt.Sprintf("identical banner %q on %d ports (%d different services)", group[0].Product, len(group), len(services)))
+			break
+		}
+	}
+
+	// Banners naming honeypot software
+	for _, p := range open {
+		banner := strings.ToLower(p.Product + " " + p.Version + " " + p.ExtraInfo)
+		if keyword := matchKeyword(banner); keyword != "" {
+			add(60, fmt.Sprintf("port %d/%s banner mentions %s", p.Port, p.Protocol, keyword))
+			break
+		}
+	}
+
+	// Tarpits hold connections open, so round trips are slow and erratic
+	if len(open) >= 20 && sig.SRTT > 0 && (sig.SRTT >= time.Second || sig.RTTVar >= 2*sig.SRTT) {
+		add(20, fmt.Sprintf("tarpit-like latency (srtt %s, rttvar %s)", sig.SRTT, sig.RTTVar))
+	}
+
+	if a.Score == 0 {
+		return nil
+	}
+	if a.Score > 100 {
+		a.Score = 100
+	}
+	a.Likely = a.Score >= LikelyScore
+	return a
+}
+
+// CountPorts returns how many ports a list such as "22,80,8000-8100" covers
+func CountPorts(spec string) int {
+	count := 0
+	for _, part := range strings.Split(spec, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		l, err := strconv.Atoi(low)
+		if err != nil {
+			continue
+		}
+		if !isRange {
+			count++
+			continue
+		}
+		if h, err := strconv.Atoi(high); err == nil && h >= l {
+			count += h - l + 1
+		}
+	}
+	return count
+}
+
+func matchKeyword(banner string) string {
+	for _, keyword := range honeypotKeywords {
+		if strings.Contains(banner, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
@@ -0,0 +1,409 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+// ElasticsearchIndexer ships normalized findings and scan metadata from every
+// service to Elasticsearch/OpenSearch through the _bulk API. Documents use the
+// source row ID as _id, so re-indexing overwrites instead of duplicating.
+type ElasticsearchIndexer struct {
+	db       *database.Database
+	url      string
+	username string
+	password string
+	apiKey   string
+	prefix   string
+	tenant   string
+	interval time.Duration
+	client   *http.Client
+	leases   *shareddb.Leases
+
+	mu       sync.Mutex
+	lastSync time.Time
+}
+
+// Finding is the normalized document stored in the findings index
+type Finding struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant"`
+	Service     string    `json:"service"`
+	Tool        string    `json:"tool"`
+	ScanID      string    `json:"scan_id"`
+	Target      string    `json:"target"`
+	Title       string    `json:"title"`
+	Severity    string    `json:"severity"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Reference   string    `json:"reference,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ScanDocument is the scan metadata stored in the scans index
+type ScanDocument struct {
+	ID          string     `json:"id"`
+	Tenant      string     `json:"tenant"`
+	Service     string     `json:"service"`
+	Tool        string     `json:"tool"`
+	Name        string     `json:"name"`
+	Target      string     `json:"target"`
+	ScanType    string     `json:"scan_type"`
+	Status      string     `json:"status"`
+	Origin      string     `json:"origin"`
+	ProjectID   string     `json:"project_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// IndexStats summarizes a single indexing run
+type IndexStats struct {
+	Tenant    string    `json:"tenant"`
+	Findings  int       `json:"findings"`
+	Scans     int       `json:"scans"`
+	Skipped   []string  `json:"skipped,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+}
+
+// findingSources return: id, service, tool, scan_id, target, title, severity, type, description, reference, created_at
+var findingSources = map[string]string{
+	"nuclei": `
+		SELECT id::text, 'web', 'nuclei', scan_id::text, host, template_name, severity, type,
+			COALESCE(matched_at, ''), template_id, created_at
+		FROM vulnerabilities WHERE created_at > $1`,
+	"testssl": `
+		SELECT id::text, 'web', tool, scan_id::text, COALESCE(url, ''), COALESCE(finding_id, ''), severity, 'tls',
+			COALESCE(finding_text, ''), COALESCE(cve, ''), created_at
+		FROM web_scan_results WHERE severity IS NOT NULL AND created_at > $1`,
+	"cloud": `
+		SELECT id::text, 'cloud', source, scan_id::text, COALESCE(resource_arn, resource_id, ''), title, severity,
+			COALESCE(service, 'misconfiguration'), COALESCE(description, ''), '', created_at
+		FROM cloud_findings WHERE created_at > $1`,
+	"trivy": `
+		SELECT id::text, 'cloud', 'trivy', scan_id::text, target, COALESCE(title, vulnerability_id), severity, 'vulnerability',
+			COALESCE(description, ''), vulnerability_id, created_at
+		FROM vulnerability_results WHERE created_at > $1`,
+	"open_ports": `
+		SELECT r.id::text || ':' || (p->>'port') || '/' || COALESCE(p->>'protocol', 'tcp'), 'network', COALESCE(s.scanner, 'nmap'),
+			r.scan_id::text, r.host, 'Open port ' || (p->>'port') || '/' || COALESCE(p->>'protocol', 'tcp'), 'info', 'open_port',
+			COALESCE(p->>'service', ''), '', r.created_at
+		FROM scan_results r
+		JOIN scans s ON s.id = r.scan_id,
+		jsonb_array_elements(CASE WHEN jsonb_typeof(r.ports) = 'array' THEN r.ports ELSE '[]'::jsonb END) p
+		WHERE p->>'state' = 'open' AND r.created_at > $1`,
+}
+
+// scanSources return: id, service, tool, name, target, scan_type, status, origin, project_id, created_at, completed_at
+var scanSources = map[string]string{
+	"network": `
+		SELECT id::text, 'network', COALESCE(scanner, 'nmap'), name, target, scan_type, COALESCE(status, ''), origin, project_id, created_at, completed_at
+		FROM scans WHERE created_at > $1 OR completed_at > $1`,
+	"nuclei": `
+		SELECT id::text, 'web', 'nuclei', name, target, 'vulnerability', status, origin, project_id, created_at, completed_at
+		FROM vulnerability_scans WHERE created_at > $1 OR completed_at > $1`,
+	"web": `
+		SELECT id::text, 'web', tool, name, target, tool, status, origin, project_id, created_at, completed_at
+		FROM web_scans WHERE created_at > $1 OR completed_at > $1`,
+	"recon": `
+		SELECT id::text, 'recon', scan_type, name, target, scan_type, status, origin, project_id, created_at, completed_at
+		FROM recon_scans WHERE created_at > $1 OR completed_at > $1`,
+	"api": `
+		SELECT id::text, 'api', scan_type, name, target, scan_type, status, origin, project_id, created_at, completed_at
+		FROM api_scans WHERE created_at > $1 OR completed_at > $1`,
+	"cms": `
+		SELECT id::text, 'cms', scan_type, name, target, scan_type, COALESCE(status, ''), origin, project_id, created_at, updated_at
+		FROM cms_scans WHERE created_at > $1 OR updated_at > $1`,
+	"cloud": `
+		SELECT id::text, 'cloud', provider, name, COALESCE(target, provider), scan_type, status, origin, project_id, created_at, completed_at
+		FROM cloud_scans WHERE created_at > $1 OR updated_at > $1`,
+}
+
+// sourceTables are the tables each source reads, owned by other services for
+// most of them, so a source is skipped until its service has created them
+var sourceTables = map[string]string{
+	"findings:nuclei":     "vulnerabilities",
+	"findings:testssl":    "web_scan_results",
+	"findings:cloud":      "cloud_findings",
+	"findings:trivy":      "vulnerability_results",
+	"findings:open_ports": "scan_results",
+	"scans:network":       "scans",
+	"scans:nuclei":        "vulnerability_scans",
+	"scans:web":           "web_scans",
+	"scans:recon":         "recon_scans",
+	"scans:api":           "api_scans",
+	"scans:cms":           "cms_scans",
+	"scans:cloud":         "cloud_scans",
+}
+
+var indexNameSanitizer = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+func NewElasticsearchIndexer(db *database.Database, esURL, username, password, apiKey, prefix, tenant string, interval time.Duration) *ElasticsearchIndexer {
+	if prefix == "" {
+		prefix = "scanner"
+	}
+	if tenant == "" {
+		tenant = "default"
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ElasticsearchIndexer{
+		db:       db,
+		url:      strings.TrimRight(esURL, "/"),
+		username: username,
+		password: password,
+		apiKey:   apiKey,
+		prefix:   prefix,
+		tenant:   tenant,
+		interval: interval,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// IndexName returns the per-tenant index name, e.g. scanner-acme-findings
+func (e *ElasticsearchIndexer) IndexName(tenant, kind string) string {
+	name := strings.ToLower(fmt.Sprintf("%s-%s-%s", e.prefix, tenant, kind))
+	return indexNameSanitizer.ReplaceAllString(name, "_")
+}
+
+// SetLeases makes Start index only on the replica holding the job's lease
+func (e *ElasticsearchIndexer) SetLeases(leases *shareddb.Leases) {
+	e.leases = leases
+}
+
+// Start runs the incremental indexing loop until the context is cancelled
+func (e *ElasticsearchIndexer) Start(ctx context.Context) {
+	log.Printf("🔎 Elasticsearch indexing enabled: %s (index prefix %s-%s, every %v)", e.url, e.prefix, e.tenant, e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		// Documents are keyed by ID, so the full reindex of a replica taking
+		// the job over only overwrites them
+		if e.leases.Acquire(ctx, "network:elasticsearch", 3*e.interval) {
+			e.mu.Lock()
+			stats, err := e.index(ctx, e.tenant, e.lastSync)
+			if err == nil {
+				e.lastSync = stats.StartedAt
+			}
+			e.mu.Unlock()
+
+			if err != nil {
+				log.Printf("❌ Elasticsearch indexing failed: %v", err)
+			} else if stats.Findings+stats.Scans > 0 {
+				log.Printf("🔎 Indexed %d findings and %d scans", stats.Findings, stats.Scans)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reindex pushes every finding and scan again into the indices of the
+// deployment's tenant (ELASTICSEARCH_TENANT)
+func (e *ElasticsearchIndexer) Reindex(ctx context.Context) (*IndexStats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats, err := e.index(ctx, e.tenant, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	e.lastSync = stats.StartedAt
+	return stats, nil
+}
+
+// sourceExists reports whether the table a source reads has been created
+func (e *ElasticsearchIndexer) sourceExists(ctx context.Context, source string) (bool, error) {
+	var exists bool
+	err := e.db.Pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, sourceTables[source]).Scan(&exists)
+	return exists, err
+}
+
+func (e *ElasticsearchIndexer) index(ctx context.Context, tenant string, since time.Time) (*IndexStats, error) {
+	stats := &IndexStats{Tenant: tenant, StartedAt: time.Now()}
+	findingsIndex := e.IndexName(tenant, "findings")
+	scansIndex := e.IndexName(tenant, "scans")
+
+	for name, query := range findingSources {
+		// Tables owned by other services may not exist yet
+		exists, err := e.sourceExists(ctx, "findings:"+name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			stats.Skipped = append(stats.Skipped, "findings:"+name)
+			continue
+		}
+		docs, err := e.loadFindings(ctx, query, tenant, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s findings: %w", name, err)
+		}
+		if err := e.bulk(ctx, findingsIndex, docs); err != nil {
+			return nil, err
+		}
+		stats.Findings += len(docs)
+	}
+
+	for name, query := range scanSources {
+		exists, err := e.sourceExists(ctx, "scans:"+name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			stats.Skipped = append(stats.Skipped, "scans:"+name)
+			continue
+		}
+		docs, err := e.loadScans(ctx, query, tenant, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s scans: %w", name, err)
+		}
+		if err := e.bulk(ctx, scansIndex, docs); err != nil {
+			return nil, err
+		}
+		stats.Scans += len(docs)
+	}
+
+	stats.Duration = time.Since(stats.StartedAt).String()
+	return stats, nil
+}
+
+func (e *ElasticsearchIndexer) loadFindings(ctx context.Context, query, tenant string, since time.Time) ([]bulkDoc, error) {
+	rows, err := e.db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []bulkDoc{}
+	for rows.Next() {
+		f := Finding{Tenant: tenant}
+		if err := rows.Scan(&f.ID, &f.Service, &f.Tool, &f.ScanID, &f.Target, &f.Title, &f.Severity,
+			&f.Type, &f.Description, &f.Reference, &f.CreatedAt); err != nil {
+			continue
+		}
+		f.Severity = strings.ToLower(f.Severity)
+		docs = append(docs, bulkDoc{ID: f.ID, Body: f})
+	}
+	return docs, rows.Err()
+}
+
+func (e *ElasticsearchIndexer) loadScans(ctx context.Context, query, tenant string, since time.Time) ([]bulkDoc, error) {
+	rows, err := e.db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []bulkDoc{}
+	for rows.Next() {
+		s := ScanDocument{Tenant: tenant}
+		if err := rows.Scan(&s.ID, &s.Service, &s.Tool, &s.Name, &s.Target, &s.ScanType, &s.Status,
+			&s.Origin, &s.ProjectID, &s.CreatedAt, &s.CompletedAt); err != nil {
+			continue
+		}
+		docs = append(docs, bulkDoc{ID: s.Service + ":" + s.ID, Body: s})
+	}
+	return docs, rows.Err()
+}
+
+type bulkDoc struct {
+	ID   string
+	Body interface{}
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error,omitempty"`
+	} `json:"items"`
+}
+
+// bulk indexes documents in chunks using the NDJSON _bulk endpoint
+func (e *ElasticsearchIndexer) bulk(ctx context.Context, index string, docs []bulkDoc) error {
+	const batchSize = 500
+	for start := 0; start < len(docs); start += batchSize {
+		end := start + batchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, doc := range docs[start:end] {
+			action := map[string]interface{}{"index": map[string]string{"_index": index, "_id": doc.ID}}
+			if err := enc.Encode(action); err != nil {
+				return err
+			}
+			if err := enc.Encode(doc.Body); err != nil {
+				return err
+			}
+		}
+
+		if err := e.sendBulk(ctx, &buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ElasticsearchIndexer) sendBulk(ctx context.Context, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url+"/_bulk", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	} else if e.username != "" {
+		req.SetBasicAuth(e.username, e.password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("elasticsearch returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, r := range item {
+				if r.Error != nil {
+					return fmt.Errorf("bulk indexing error: %s: %s", r.Error.Type, r.Error.Reason)
+				}
+			}
+		}
+	}
+	return nil
+}
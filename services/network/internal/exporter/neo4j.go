@@ -0,0 +1,557 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+// Neo4jExporter continuously mirrors assets and their relationships
+// (Domain -> Subdomain -> IP -> Port -> Service -> Vulnerability) into Neo4j
+// using the transactional HTTP API. Rows are synced incrementally using their
+// created_at timestamp as a watermark and written with MERGE, so re-running a
+// sync is idempotent. The watermark is kept in Postgres so a restart or a
+// replica taking the job over resumes where the last sync stopped.
+type Neo4jExporter struct {
+	db       *database.Database
+	url      string
+	user     string
+	password string
+	database string
+	interval time.Duration
+	client   *http.Client
+	leases   *shareddb.Leases
+
+	mu sync.Mutex
+}
+
+// watermarkSchemaSQL holds the point up to which each exporter has synced
+const watermarkSchemaSQL = `
+CREATE TABLE IF NOT EXISTS exporter_watermarks (
+    exporter VARCHAR(100) PRIMARY KEY,
+    synced_at TIMESTAMP NOT NULL
+)`
+
+const neo4jWatermark = "neo4j"
+
+// SyncStats summarizes a single sync run
+type SyncStats struct {
+	Subdomains      int       `json:"subdomains"`
+	DNSRecords      int       `json:"dns_records"`
+	Hosts           int       `json:"hosts"`
+	Vulnerabilities int       `json:"vulnerabilities"`
+	ScanErrors      int       `json:"scan_errors"`
+	StartedAt       time.Time `json:"started_at"`
+	Duration        string    `json:"duration"`
+}
+
+func NewNeo4jExporter(db *database.Database, neo4jURL, user, password, dbName string, interval time.Duration) (*Neo4jExporter, error) {
+	if _, err := db.Pool.Exec(context.Background(), watermarkSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create exporter_watermarks table: %w", err)
+	}
+	if dbName == "" {
+		dbName = "neo4j"
+	}
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Neo4jExporter{
+		db:       db,
+		url:      strings.TrimRight(neo4jURL, "/"),
+		user:     user,
+		password: password,
+		database: dbName,
+		interval: interval,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// SetLeases makes Start sync only on the replica holding the job's lease
+func (e *Neo4jExporter) SetLeases(leases *shareddb.Leases) {
+	e.leases = leases
+}
+
+// Start runs the sync loop until the context is cancelled
+func (e *Neo4jExporter) Start(ctx context.Context) {
+	log.Printf("🕸️ Neo4j sync enabled: %s (every %v)", e.url, e.interval)
+
+	if err := e.ensureConstraints(ctx); err != nil {
+		log.Printf("⚠️ Neo4j constraint setup failed: %v", err)
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		// A replica taking the job over resumes from the shared watermark
+		if e.leases.Acquire(ctx, "network:neo4j", 3*e.interval) {
+			if _, err := e.Sync(ctx); err != nil {
+				log.Printf("❌ Neo4j sync failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pushes every row created since the previous successful sync
+func (e *Neo4jExporter) Sync(ctx context.Context) (*SyncStats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := &SyncStats{StartedAt: time.Now()}
+	// Another replica may have synced since this one last held the lease
+	since, err := e.loadWatermark(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("watermark: %w", err)
+	}
+
+	if stats.Subdomains, err = e.syncSubdomains(ctx, since, stats); err != nil {
+		return nil, fmt.Errorf("subdomains: %w", err)
+	}
+	if stats.DNSRecords, err = e.syncDNS(ctx, since, stats); err != nil {
+		return nil, fmt.Errorf("dns: %w", err)
+	}
+	if stats.Hosts, err = e.syncHosts(ctx, since, stats); err != nil {
+		return nil, fmt.Errorf("hosts: %w", err)
+	}
+	if stats.Vulnerabilities, err = e.syncVulnerabilities(ctx, since, stats); err != nil {
+		return nil, fmt.Errorf("vulnerabilities: %w", err)
+	}
+
+	if err := e.saveWatermark(ctx, stats.StartedAt); err != nil {
+		return nil, fmt.Errorf("watermark: %w", err)
+	}
+	stats.Duration = time.Since(stats.StartedAt).String()
+
+	if stats.Subdomains+stats.DNSRecords+stats.Hosts+stats.Vulnerabilities > 0 {
+		log.Printf("🕸️ Neo4j sync: %d subdomains, %d DNS records, %d hosts, %d vulnerabilities",
+			stats.Subdomains, stats.DNSRecords, stats.Hosts, stats.Vulnerabilities)
+	}
+	if stats.ScanErrors > 0 {
+		log.Printf("⚠️ Neo4j sync skipped %d rows that could not be read", stats.ScanErrors)
+	}
+
+	return stats, nil
+}
+
+// loadWatermark returns the start of the last successful sync, from any
+// replica, or the zero time when nothing was synced yet
+func (e *Neo4jExporter) loadWatermark(ctx context.Context) (time.Time, error) {
+	var syncedAt time.Time
+	err := e.db.Pool.QueryRow(ctx,
+		`SELECT synced_at FROM exporter_watermarks WHERE exporter = $1`, neo4jWatermark).Scan(&syncedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return syncedAt, err
+}
+
+func (e *Neo4jExporter) saveWatermark(ctx context.Context, syncedAt time.Time) error {
+	_, err := e.db.Pool.Exec(ctx, `
+		INSERT INTO exporter_watermarks (exporter, synced_at) VALUES ($1, $2)
+		ON CONFLICT (exporter) DO UPDATE SET synced_at = EXCLUDED.synced_at
+	`, neo4jWatermark, syncedAt)
+	return err
+}
+
+// scanFailed logs a row that couldn't be read and counts it in the run's stats
+func (e *Neo4jExporter) scanFailed(stats *SyncStats, table string, err error) {
+	stats.ScanErrors++
+	log.Printf("⚠️ Neo4j sync: skipping unreadable %s row: %v", table, err)
+}
+
+func (e *Neo4jExporter) ensureConstraints(ctx context.Context) error {
+	constraints := []string{
+		"CREATE CONSTRAINT domain_name IF NOT EXISTS FOR (d:Domain) REQUIRE d.name IS UNIQUE",
+		"CREATE CONSTRAINT subdomain_name IF NOT EXISTS FOR (s:Subdomain) REQUIRE s.name IS UNIQUE",
+		"CREATE CONSTRAINT ip_address IF NOT EXISTS FOR (i:IP) REQUIRE i.address IS UNIQUE",
+		"CREATE CONSTRAINT port_id IF NOT EXISTS FOR (p:Port) REQUIRE p.id IS UNIQUE",
+		"CREATE CONSTRAINT service_id IF NOT EXISTS FOR (s:Service) REQUIRE s.id IS UNIQUE",
+		"CREATE CONSTRAINT vulnerability_id IF NOT EXISTS FOR (v:Vulnerability) REQUIRE v.id IS UNIQUE",
+	}
+
+	statements := make([]neo4jStatement, 0, len(constraints))
+	for _, c := range constraints {
+		statements = append(statements, neo4jStatement{Statement: c})
+	}
+	return e.run(ctx, statements)
+}
+
+// syncSubdomains links recon targets to their subdomains and resolved IPs
+func (e *Neo4jExporter) syncSubdomains(ctx context.Context, since time.Time, stats *SyncStats) (int, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT rs.target, sr.subdomain, COALESCE(sr.ip_addresses, '{}'), COALESCE(sr.is_alive, false), COALESCE(sr.source, '')
+		FROM subdomain_results sr
+		JOIN recon_scans rs ON rs.id = sr.scan_id
+		WHERE sr.created_at > $1 AND sr.wildcard IS NULL
+	`, since)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := []map[string]interface{}{}
+	for rows.Next() {
+		var domain, subdomain, source string
+		var ips []string
+		var alive bool
+		if err := rows.Scan(&domain, &subdomain, &ips, &alive, &source); err != nil {
+			e.scanFailed(stats, "subdomain_results", err)
+			continue
+		}
+		batch = append(batch, map[string]interface{}{
+			"domain":    normalizeName(domain),
+			"subdomain": normalizeName(subdomain),
+			"ips":       ips,
+			"alive":     alive,
+			"source":    source,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(batch), e.runBatches(ctx, `
+		UNWIND $rows AS row
+		MERGE (d:Domain {name: row.domain})
+		MERGE (s:Subdomain {name: row.subdomain})
+		SET s.is_alive = row.alive, s.source = row.source, s.last_seen = datetime()
+		MERGE (d)-[:HAS_SUBDOMAIN]->(s)
+		WITH s, row
+		UNWIND row.ips AS ip
+		MERGE (i:IP {address: ip})
+		MERGE (s)-[:RESOLVES_TO]->(i)
+	`, batch)
+}
+
+// syncDNS links domains from recon DNS lookups to their A/AAAA records
+func (e *Neo4jExporter) syncDNS(ctx context.Context, since time.Time, stats *SyncStats) (int, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT domain, COALESCE(a_records, '{}'), COALESCE(aaaa_records, '{}')
+		FROM dns_results
+		WHERE created_at > $1
+	`, since)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := []map[string]interface{}{}
+	for rows.Next() {
+		var domain string
+		var a, aaaa []string
+		if err := rows.Scan(&domain, &a, &aaaa); err != nil {
+			e.scanFailed(stats, "dns_results", err)
+			continue
+		}
+		batch = append(batch, map[string]interface{}{
+			"domain": normalizeName(domain),
+			"ips":    append(a, aaaa...),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(batch), e.runBatches(ctx, `
+		UNWIND $rows AS row
+		MERGE (d:Domain {name: row.domain})
+		WITH d, row
+		UNWIND row.ips AS ip
+		MERGE (i:IP {address: ip})
+		MERGE (d)-[:RESOLVES_TO]->(i)
+	`, batch)
+}
+
+// syncHosts pushes network scan results as IP -> Port -> Service
+func (e *Neo4jExporter) syncHosts(ctx context.Context, since time.Time, stats *SyncStats) (int, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT host, hostname, COALESCE(state, ''), ports
+		FROM scan_results
+		WHERE created_at > $1
+	`, since)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := []map[string]interface{}{}
+	for rows.Next() {
+		var host, state string
+		var hostname *string
+		var ports []models.Port
+		if err := rows.Scan(&host, &hostname, &state, &ports); err != nil {
+			e.scanFailed(stats, "scan_results", err)
+			continue
+		}
+		// DNS scan results store domains, not addresses
+		if net.ParseIP(host) == nil {
+			continue
+		}
+
+		portRows := []map[string]interface{}{}
+		for _, p := range ports {
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			portRows = append(portRows, map[string]interface{}{
+				"id":       fmt.Sprintf("%s:%d/%s", host, p.Port, protocol),
+				"number":   p.Port,
+				"protocol": protocol,
+				"state":    p.State,
+				"service":  p.Service,
+				"product":  p.Product,
+				"version":  p.Version,
+			})
+		}
+
+		row := map[string]interface{}{
+			"ip":       host,
+			"state":    state,
+			"hostname": "",
+			"ports":    portRows,
+		}
+		if hostname != nil {
+			row["hostname"] = normalizeName(*hostname)
+		}
+		batch = append(batch, row)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(batch), e.runBatches(ctx, `
+		UNWIND $rows AS row
+		MERGE (i:IP {address: row.ip})
+		SET i.state = row.state, i.last_seen = datetime()
+		FOREACH (hasName IN CASE WHEN row.hostname <> '' THEN [1] ELSE [] END |
+			MERGE (s:Subdomain {name: row.hostname})
+			MERGE (s)-[:RESOLVES_TO]->(i)
+		)
+		WITH i, row
+		UNWIND row.ports AS port
+		MERGE (p:Port {id: port.id})
+		SET p.number = port.number, p.protocol = port.protocol, p.state = port.state, p.last_seen = datetime()
+		MERGE (i)-[:HAS_PORT]->(p)
+		FOREACH (hasService IN CASE WHEN port.service <> '' THEN [1] ELSE [] END |
+			MERGE (svc:Service {id: port.id})
+			SET svc.name = port.service, svc.product = port.product, svc.version = port.version
+			MERGE (p)-[:RUNS]->(svc)
+		)
+	`, batch)
+}
+
+// syncVulnerabilities attaches web vulnerability findings to the affected
+// service, falling back to the host when no port can be derived
+func (e *Neo4jExporter) syncVulnerabilities(ctx context.Context, since time.Time, stats *SyncStats) (int, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT id::text, template_id, template_name, severity, type, host, COALESCE(matched_at, '')
+		FROM vulnerabilities
+		WHERE created_at > $1
+	`, since)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := []map[string]interface{}{}
+	for rows.Next() {
+		var id, templateID, templateName, severity, vulnType, host, matchedAt string
+		if err := rows.Scan(&id, &templateID, &templateName, &severity, &vulnType, &host, &matchedAt); err != nil {
+			e.scanFailed(stats, "vulnerabilities", err)
+			continue
+		}
+
+		hostname, port := splitTarget(host)
+		if hostname == "" {
+			continue
+		}
+		hostLabel := "Subdomain"
+		if net.ParseIP(hostname) != nil {
+			hostLabel = "IP"
+		}
+
+		portID := ""
+		if port > 0 {
+			portID = fmt.Sprintf("%s:%d/tcp", hostname, port)
+		}
+
+		batch = append(batch, map[string]interface{}{
+			"id":            id,
+			"template_id":   templateID,
+			"template_name": templateName,
+			"severity":      severity,
+			"type":          vulnType,
+			"matched_at":    matchedAt,
+			"host":          hostname,
+			"is_ip":         hostLabel == "IP",
+			"port_id":       portID,
+			"port":          port,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(batch), e.runBatches(ctx, `
+		UNWIND $rows AS row
+		MERGE (v:Vulnerability {id: row.id})
+		SET v.template_id = row.template_id, v.name = row.template_name, v.severity = row.severity,
+			v.type = row.type, v.matched_at = row.matched_at
+		FOREACH (isIP IN CASE WHEN row.is_ip THEN [1] ELSE [] END |
+			MERGE (h:IP {address: row.host})
+			FOREACH (hasPort IN CASE WHEN row.port_id <> '' THEN [1] ELSE [] END |
+				MERGE (p:Port {id: row.port_id})
+				ON CREATE SET p.number = row.port, p.protocol = 'tcp'
+				MERGE (h)-[:HAS_PORT]->(p)
+				MERGE (svc:Service {id: row.port_id})
+				MERGE (p)-[:RUNS]->(svc)
+				MERGE (svc)-[:HAS_VULNERABILITY]->(v)
+			)
+			FOREACH (noPort IN CASE WHEN row.port_id = '' THEN [1] ELSE [] END |
+				MERGE (h)-[:HAS_VULNERABILITY]->(v)
+			)
+		)
+		FOREACH (isName IN CASE WHEN NOT row.is_ip THEN [1] ELSE [] END |
+			MERGE (h:Subdomain {name: row.host})
+			FOREACH (hasPort IN CASE WHEN row.port_id <> '' THEN [1] ELSE [] END |
+				MERGE (p:Port {id: row.port_id})
+				ON CREATE SET p.number = row.port, p.protocol = 'tcp'
+				MERGE (h)-[:HAS_PORT]->(p)
+				MERGE (svc:Service {id: row.port_id})
+				MERGE (p)-[:RUNS]->(svc)
+				MERGE (svc)-[:HAS_VULNERABILITY]->(v)
+			)
+			FOREACH (noPort IN CASE WHEN row.port_id = '' THEN [1] ELSE [] END |
+				MERGE (h)-[:HAS_VULNERABILITY]->(v)
+			)
+		)
+	`, batch)
+}
+
+// neo4j transactional HTTP API payloads
+type neo4jStatement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type neo4jRequest struct {
+	Statements []neo4jStatement `json:"statements"`
+}
+
+type neo4jResponse struct {
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// runBatches sends rows in chunks so large scans don't produce huge payloads
+func (e *Neo4jExporter) runBatches(ctx context.Context, statement string, rows []map[string]interface{}) error {
+	const batchSize = 500
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		err := e.run(ctx, []neo4jStatement{{
+			Statement:  statement,
+			Parameters: map[string]interface{}{"rows": rows[start:end]},
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Neo4jExporter) run(ctx context.Context, statements []neo4jStatement) error {
+	body, err := json.Marshal(neo4jRequest{Statements: statements})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/db/%s/tx/commit", e.url, url.PathEscape(e.database))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if e.user != "" {
+		req.SetBasicAuth(e.user, e.password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("neo4j returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result neo4jResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%s: %s", result.Errors[0].Code, result.Errors[0].Message)
+	}
+	return nil
+}
+
+// splitTarget extracts the hostname and port from a URL or host:port string
+func splitTarget(target string) (string, int) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "://") {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return "", 0
+		}
+		port, _ := strconv.Atoi(parsed.Port())
+		if port == 0 {
+			switch parsed.Scheme {
+			case "https":
+				port = 443
+			case "http":
+				port = 80
+			}
+		}
+		return normalizeName(parsed.Hostname()), port
+	}
+
+	if host, portStr, err := net.SplitHostPort(target); err == nil {
+		port, _ := strconv.Atoi(portStr)
+		return normalizeName(host), port
+	}
+	return normalizeName(target), 0
+}
+
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
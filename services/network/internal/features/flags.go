@@ -0,0 +1,261 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+)
+
+// ErrNotFound is returned when a flag does not exist
+var ErrNotFound = errors.New("feature flag not found")
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+
+// Flag is a feature flag. A flag is on for a tenant when the tenant is in
+// EnabledTenants, off when it is in DisabledTenants, and otherwise on for
+// RolloutPercentage percent of subjects while Enabled is true.
+type Flag struct {
+	Key               string    `json:"key"`
+	Description       string    `json:"description"`
+	Enabled           bool      `json:"enabled"`
+	RolloutPercentage int       `json:"rollout_percentage"`
+	EnabledTenants    []string  `json:"enabled_tenants"`
+	DisabledTenants   []string  `json:"disabled_tenants"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key VARCHAR(100) PRIMARY KEY,
+    description TEXT DEFAULT '',
+    enabled BOOLEAN DEFAULT false,
+    rollout_percentage INTEGER DEFAULT 100,
+    enabled_tenants TEXT[] DEFAULT '{}',
+    disabled_tenants TEXT[] DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Store caches the feature_flags table in memory and refreshes it periodically.
+// Unknown flags are off.
+type Store struct {
+	db       *database.Database
+	interval time.Duration
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+func NewStore(db *database.Database, interval time.Duration) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create feature_flags table: %w", err)
+	}
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	s := &Store{db: db, interval: interval, flags: map[string]Flag{}}
+	if err := s.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start refreshes the cache until ctx is cancelled
+func (s *Store) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("⚠️ Feature flag refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// Refresh reloads every flag from the database
+func (s *Store) Refresh(ctx context.Context) error {
+	flags, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string]Flag, len(flags))
+	for _, f := range flags {
+		cache[f.Key] = f
+	}
+
+	s.mu.Lock()
+	s.flags = cache
+	s.mu.Unlock()
+	return nil
+}
+
+// IsEnabled evaluates a flag for a tenant. subject (a user, target or scan ID)
+// keeps percentage rollouts sticky; an empty subject uses the tenant.
+func (s *Store) IsEnabled(key, tenant, subject string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	flag, ok := s.flags[key]
+	s.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	return flag.Evaluate(tenant, subject)
+}
+
+// Evaluate returns whether the flag is on for tenant and subject
+func (f Flag) Evaluate(tenant, subject string) bool {
+	if contains(f.DisabledTenants, tenant) {
+		return false
+	}
+	if contains(f.EnabledTenants, tenant) {
+		return true
+	}
+	if !f.Enabled {
+		return false
+	}
+	if f.RolloutPercentage >= 100 {
+		return true
+	}
+	if f.RolloutPercentage <= 0 {
+		return false
+	}
+	if subject == "" {
+		subject = tenant
+	}
+	return bucket(f.Key, subject) < f.RolloutPercentage
+}
+
+// EvaluateAll returns the state of every flag for tenant and subject
+func (s *Store) EvaluateAll(tenant, subject string) map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]bool, len(s.flags))
+	for key, flag := range s.flags {
+		result[key] = flag.Evaluate(tenant, subject)
+	}
+	return result
+}
+
+// bucket maps key+subject to a stable value in [0, 100)
+func bucket(key, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + subject))
+	return int(h.Sum32() % 100)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns every flag from the database
+func (s *Store) List(ctx context.Context) ([]Flag, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT key, COALESCE(description, ''), COALESCE(enabled, false), COALESCE(rollout_percentage, 100),
+			COALESCE(enabled_tenants, '{}'), COALESCE(disabled_tenants, '{}'), updated_at
+		FROM feature_flags ORDER BY key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []Flag{}
+	for rows.Next() {
+		var f Flag
+		if err := rows.Scan(&f.Key, &f.Description, &f.Enabled, &f.RolloutPercentage,
+			&f.EnabledTenants, &f.DisabledTenants, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// Get returns a single flag from the database
+func (s *Store) Get(ctx context.Context, key string) (*Flag, error) {
+	var f Flag
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT key, COALESCE(description, ''), COALESCE(enabled, false), COALESCE(rollout_percentage, 100),
+			COALESCE(enabled_tenants, '{}'), COALESCE(disabled_tenants, '{}'), updated_at
+		FROM feature_flags WHERE key = $1
+	`, key).Scan(&f.Key, &f.Description, &f.Enabled, &f.RolloutPercentage,
+		&f.EnabledTenants, &f.DisabledTenants, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Save creates or replaces a flag and refreshes the cache
+func (s *Store) Save(ctx context.Context, f Flag) (*Flag, error) {
+	if !keyPattern.MatchString(f.Key) {
+		return nil, fmt.Errorf("invalid flag key %q: use lowercase letters, digits, '_', '.' or '-'", f.Key)
+	}
+	if f.RolloutPercentage < 0 || f.RolloutPercentage > 100 {
+		return nil, fmt.Errorf("rollout_percentage must be between 0 and 100")
+	}
+	if f.EnabledTenants == nil {
+		f.EnabledTenants = []string{}
+	}
+	if f.DisabledTenants == nil {
+		f.DisabledTenants = []string{}
+	}
+
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO feature_flags (key, description, enabled, rollout_percentage, enabled_tenants, disabled_tenants, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			enabled = EXCLUDED.enabled,
+			rollout_percentage = EXCLUDED.rollout_percentage,
+			enabled_tenants = EXCLUDED.enabled_tenants,
+			disabled_tenants = EXCLUDED.disabled_tenants,
+			updated_at = NOW()
+		RETURNING updated_at
+	`, f.Key, f.Description, f.Enabled, f.RolloutPercentage, f.EnabledTenants, f.DisabledTenants).Scan(&f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Refresh(ctx)
+	return &f, nil
+}
+
+// Delete removes a flag (it evaluates as off afterwards)
+func (s *Store) Delete(ctx context.Context, key string) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM feature_flags WHERE key = $1`, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	s.Refresh(ctx)
+	return nil
+}
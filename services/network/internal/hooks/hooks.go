@@ -0,0 +1,391 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/sandbox"
+)
+
+// Stages a hook runs at
+const (
+	StagePre  = "pre"
+	StagePost = "post"
+)
+
+// Hook kinds
+const (
+	KindWebhook = "webhook"
+	KindScript  = "script"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	maxTimeout     = 5 * time.Minute
+	// maxOutput is how much of a script's output or a webhook's response is
+	// kept with the result
+	maxOutput = 4096
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS scan_hooks (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    stage VARCHAR(10) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    url TEXT,
+    script VARCHAR(255),
+    template_id UUID REFERENCES scan_templates(id) ON DELETE CASCADE,
+    project VARCHAR(100),
+    timeout_seconds INTEGER NOT NULL DEFAULT 30,
+    required BOOLEAN NOT NULL DEFAULT false,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_scan_hooks_template ON scan_hooks(template_id);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS hook_results JSONB`
+
+// Columns are the scan_hooks columns read by ScanHook
+const Columns = `id, name, stage, kind, COALESCE(url, ''), COALESCE(script, ''), template_id, COALESCE(project, ''),
+	timeout_seconds, required, enabled, created_at, updated_at`
+
+// ScanHook reads a hook selected with Columns
+func ScanHook(row interface{ Scan(...interface{}) error }) (*models.ScanHook, error) {
+	var h models.ScanHook
+	err := row.Scan(&h.ID, &h.Name, &h.Stage, &h.Kind, &h.URL, &h.Script, &h.TemplateID, &h.Project,
+		&h.TimeoutSeconds, &h.Required, &h.Enabled, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Scan is what a hook is told about the scan it runs for
+type Scan struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Target     string     `json:"target"`
+	ScanType   string     `json:"scan_type"`
+	Scanner    string     `json:"scanner"`
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	Project    string     `json:"project,omitempty"`
+	Status     string     `json:"status,omitempty"` // post hooks: completed or failed
+	Error      string     `json:"error,omitempty"`
+}
+
+// Runner runs the hooks of scans and records their results on the scan
+type Runner struct {
+	db         *database.Database
+	sandbox    *sandbox.Sandbox
+	scriptsDir string
+	secret     string
+	client     *http.Client
+}
+
+// NewRunner creates the hooks table. Scripts are only run from scriptsDir;
+// webhook bodies are signed with secret when it is set.
+func NewRunner(db *database.Database, box *sandbox.Sandbox, scriptsDir, secret string) (*Runner, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create scan hooks table: %w", err)
+	}
+	return &Runner{
+		db:         db,
+		sandbox:    box,
+		scriptsDir: scriptsDir,
+		secret:     secret,
+		client:     &http.Client{},
+	}, nil
+}
+
+// Scripts lists the scripts hooks may run
+func (r *Runner) Scripts() []string {
+	scripts := []string{}
+	entries, err := os.ReadDir(r.scriptsDir)
+	if err != nil {
+		return scripts
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			scripts = append(scripts, entry.Name())
+		}
+	}
+	return scripts
+}
+
+// Validate normalizes a hook and checks it can run
+func (r *Runner) Validate(h *models.ScanHook) error {
+	h.Name = strings.TrimSpace(h.Name)
+	h.Stage = strings.ToLower(strings.TrimSpace(h.Stage))
+	h.Kind = strings.ToLower(strings.TrimSpace(h.Kind))
+	h.Project = strings.TrimSpace(h.Project)
+	if h.Name == "" {
+		return errors.New("name is required")
+	}
+	if h.Stage != StagePre && h.Stage != StagePost {
+		return errors.New("stage must be pre or post")
+	}
+	if h.TemplateID == nil && h.Project == "" {
+		return errors.New("template_id or project is required")
+	}
+	if h.Required && h.Stage != StagePre {
+		return errors.New("only pre hooks can be required")
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = int(defaultTimeout / time.Second)
+	}
+	if h.TimeoutSeconds < 1 || time.Duration(h.TimeoutSeconds)*time.Second > maxTimeout {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", int(maxTimeout/time.Second))
+	}
+
+	switch h.Kind {
+	case KindWebhook:
+		h.Script = ""
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an http or https URL")
+		}
+	case KindScript:
+		h.URL = ""
+		if _, err := r.scriptPath(h.Script); err != nil {
+			return err
+		}
+	default:
+		return errors.New("kind must be webhook or script")
+	}
+	return nil
+}
+
+// scriptPath returns the path of an allow-listed script: an executable file
+// directly in the scripts directory
+func (r *Runner) scriptPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", errors.New("script must be the file name of a script in HOOKS_DIR")
+	}
+	path := filepath.Join(r.scriptsDir, name)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+		return "", fmt.Errorf("script %s is not an executable file in %s", name, r.scriptsDir)
+	}
+	return path, nil
+}
+
+// hooksFor returns the enabled hooks of a stage for a scan's template and project
+func (r *Runner) hooksFor(ctx context.Context, stage string, scan Scan) ([]*models.ScanHook, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+Columns+` FROM scan_hooks
+		WHERE enabled AND stage = $1 AND (template_id = $2 OR project = $3)
+		ORDER BY name
+	`, stage, scan.TemplateID, scan.Project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*models.ScanHook{}
+	for rows.Next() {
+		hook, err := ScanHook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// Run runs the hooks of a stage for scan one after another and records their
+// results. For pre hooks it returns an error when a required hook failed,
+// and the scan must not start.
+func (r *Runner) Run(ctx context.Context, stage string, scan Scan) error {
+	hooks, err := r.hooksFor(ctx, stage, scan)
+	if err != nil {
+		log.Printf("Failed to fetch %s hooks of scan %s: %v", stage, scan.ID, err)
+		return nil
+	}
+
+	for _, hook := range hooks {
+		result := r.runHook(ctx, hook, scan)
+		r.record(scan.ID, result)
+		if result.Status == "success" {
+			r.addLog(scan.ID, "info", fmt.Sprintf("%s hook %s succeeded in %dms", stage, hook.Name, result.DurationMS))
+			continue
+		}
+		r.addLog(scan.ID, "warning", fmt.Sprintf("%s hook %s failed: %s", stage, hook.Name, result.Error))
+		if hook.Required && stage == StagePre {
+			return fmt.Errorf("required pre-scan hook %s failed: %s", hook.Name, result.Error)
+		}
+	}
+	return nil
+}
+
+// runHook runs one hook within its timeout
+func (r *Runner) runHook(ctx context.Context, hook *models.ScanHook, scan Scan) models.HookResult {
+	result := models.HookResult{
+		HookID:    hook.ID,
+		Name:      hook.Name,
+		Stage:     hook.Stage,
+		Kind:      hook.Kind,
+		StartedAt: time.Now(),
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(hook.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	payload, _ := json.Marshal(hookEvent{Event: "scan." + hook.Stage + "_hook", Hook: hook.Name, Scan: scan})
+	var err error
+	if hook.Kind == KindWebhook {
+		err = r.callWebhook(ctx, hook, payload, &result)
+	} else {
+		err = r.runScript(ctx, hook, scan, payload, &result)
+	}
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %ds", hook.TimeoutSeconds)
+	}
+
+	result.DurationMS = time.Since(result.StartedAt).Milliseconds()
+	result.Status = "success"
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// hookEvent is the JSON body of webhooks and the stdin of scripts
+type hookEvent struct {
+	Event string `json:"event"`
+	Hook  string `json:"hook"`
+	Scan  Scan   `json:"scan"`
+}
+
+// callWebhook POSTs the payload; any 2xx response is a success
+func (r *Runner) callWebhook(ctx context.Context, hook *models.ScanHook, payload []byte, result *models.HookResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "security-scanner-hooks/1.0")
+	if r.secret != "" {
+		mac := hmac.New(sha256.New, []byte(r.secret))
+		mac.Write(payload)
+		req.Header.Set("X-Scanner-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %ds", hook.TimeoutSeconds)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	result.HTTPStatus = resp.StatusCode
+	result.Output = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runScript runs an allow-listed script with the payload on stdin and the
+// scan in SCAN_* environment variables; exit status 0 is a success
+func (r *Runner) runScript(ctx context.Context, hook *models.ScanHook, scan Scan, payload []byte, result *models.HookResult) error {
+	path, err := r.scriptPath(hook.Script)
+	if err != nil {
+		return err
+	}
+
+	env := []string{
+		"HOOK_NAME=" + hook.Name,
+		"HOOK_STAGE=" + hook.Stage,
+		"SCAN_ID=" + scan.ID.String(),
+		"SCAN_NAME=" + scan.Name,
+		"SCAN_TARGET=" + scan.Target,
+		"SCAN_TYPE=" + scan.ScanType,
+		"SCANNER=" + scan.Scanner,
+		"SCAN_PROJECT=" + scan.Project,
+		"SCAN_STATUS=" + scan.Status,
+	}
+	cmd := r.sandbox.Command(sandbox.WithEnv(ctx, env), "hooks", path)
+	cmd.Stdin = bytes.NewReader(payload)
+	var output bytes.Buffer
+	cmd.Stdout = &limitedWriter{buf: &output, max: maxOutput}
+	cmd.Stderr = cmd.Stdout
+
+	err = cmd.Run()
+	result.Output = output.String()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		result.ExitCode = &code
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %ds", hook.TimeoutSeconds)
+		}
+		return fmt.Errorf("script exited with status %d", code)
+	}
+	if err != nil {
+		return err
+	}
+	code := 0
+	result.ExitCode = &code
+	return nil
+}
+
+// limitedWriter keeps the first max bytes written to it and drops the rest
+type limitedWriter struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if room := w.max - w.buf.Len(); room > 0 {
+		if len(p) > room {
+			w.buf.Write(p[:room])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// record appends a result to the scan's hook results
+func (r *Runner) record(scanID uuid.UUID, result models.HookResult) {
+	data, err := json.Marshal([]models.HookResult{result})
+	if err != nil {
+		return
+	}
+	_, err = r.db.Pool.Exec(context.Background(), `
+		UPDATE scans SET hook_results = COALESCE(hook_results, '[]'::jsonb) || $1::jsonb WHERE id = $2
+	`, data, scanID)
+	if err != nil {
+		log.Printf("Failed to record hook result of scan %s: %v", scanID, err)
+	}
+}
+
+func (r *Runner) addLog(scanID uuid.UUID, level, message string) {
+	_, err := r.db.Pool.Exec(context.Background(),
+		`INSERT INTO scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New(), scanID, level, message, time.Now())
+	if err != nil {
+		log.Printf("Failed to add log: %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+// Package hosting publishes the HTML report of every completed network and
+// nuclei scan to object storage, indexed per project, so stakeholders have
+// stable URLs to the history and the latest results of an engagement
+// instead of downloading reports one by one.
+package hosting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// Kinds of hosted reports: the scans they are the report of
+const (
+	KindNetwork         = "network"         // nmap, masscan, naabu and DNS scans
+	KindVulnerabilities = "vulnerabilities" // nuclei scans of the web service
+)
+
+// Kinds lists the kinds of hosted reports, in the order they are published
+var Kinds = []string{KindNetwork, KindVulnerabilities}
+
+const (
+	// leaseJob is the lease a replica holds while it publishes reports
+	leaseJob = "network:report-hosting"
+	// publishBatch bounds the reports of each kind published a round, so
+	// enabling hosting on a busy platform backfills its history gradually
+	publishBatch = 20
+)
+
+// ErrNotFound is returned for reports that weren't published
+var ErrNotFound = errors.New("report not found")
+
+// SortFields are the fields hosted report lists sort by
+var SortFields = map[string]string{
+	"completed_at": "scan_completed_at",
+	"published_at": "published_at",
+	"name":         "scan_name",
+	"target":       "target",
+}
+
+// Report is a published report, tracked in hosted_reports
+type Report struct {
+	ID          uuid.UUID `json:"id"`
+	ProjectID   string    `json:"project_id"`
+	Kind        string    `json:"kind"`
+	ScanID      uuid.UUID `json:"scan_id"`
+	ScanName    string    `json:"scan_name"`
+	Target      string    `json:"target"`
+	ObjectKey   string    `json:"-"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CompletedAt time.Time `json:"completed_at"`
+	PublishedAt time.Time `json:"published_at"`
+	// URL is where the gateway serves the report
+	URL string `json:"url"`
+}
+
+// Renderer returns the HTML report of a scan of kind
+type Renderer func(kind, scanID string) ([]byte, error)
+
+// Publisher renders the reports of newly completed scans and stores them.
+// Scans whose report can't be rendered are recorded with the error and
+// not retried.
+type Publisher struct {
+	db       *database.Database
+	storage  Storage
+	render   Renderer
+	leases   *shareddb.Leases
+	interval time.Duration
+}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS hosted_reports (
+    id UUID PRIMARY KEY,
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    kind VARCHAR(20) NOT NULL,
+    scan_id UUID NOT NULL,
+    scan_name VARCHAR(255) NOT NULL DEFAULT '',
+    target TEXT NOT NULL DEFAULT '',
+    object_key VARCHAR(500) NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    scan_completed_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, scan_id)
+);
+CREATE INDEX IF NOT EXISTS idx_hosted_reports_project ON hosted_reports(project_id, scan_completed_at DESC)`
+
+// candidateSources return the completed scans of each kind without a hosted
+// report, oldest first: id, name, target, project_id, completed_at
+var candidateSources = map[string]string{
+	KindNetwork: `
+		SELECT s.id, s.name, s.target, s.project_id, s.completed_at FROM scans s
+		WHERE s.status = 'completed' AND s.completed_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM hosted_reports r WHERE r.kind = 'network' AND r.scan_id = s.id)
+		ORDER BY s.completed_at LIMIT $1`,
+	KindVulnerabilities: `
+		SELECT s.id, s.name, s.target, s.project_id, s.completed_at FROM vulnerability_scans s
+		WHERE s.status = 'completed' AND s.completed_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM hosted_reports r WHERE r.kind = 'vulnerabilities' AND r.scan_id = s.id)
+		ORDER BY s.completed_at LIMIT $1`,
+}
+
+// kindTables are the tables each kind's scans are read from; the
+// vulnerability scans belong to the web service
+var kindTables = map[string]string{
+	KindNetwork:         "scans",
+	KindVulnerabilities: "vulnerability_scans",
+}
+
+func NewPublisher(db *database.Database, storage Storage, render Renderer, interval time.Duration) (*Publisher, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create hosted_reports table: %w", err)
+	}
+	if _, err := db.Pool.Exec(context.Background(), tokenSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create hosted_report_tokens table: %w", err)
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Publisher{db: db, storage: storage, render: render, interval: interval}, nil
+}
+
+// SetLeases makes Start publish only on the replica holding the job's lease
+func (p *Publisher) SetLeases(leases *shareddb.Leases) {
+	p.leases = leases
+}
+
+// Start publishes the reports of completed scans until the context is
+// cancelled
+func (p *Publisher) Start(ctx context.Context) {
+	log.Printf("📰 Hosting project reports in %s (every %v)", p.storage.Name(), p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if p.leases.Acquire(ctx, leaseJob, 3*p.interval) {
+			published, err := p.publish(ctx)
+			if err != nil {
+				log.Printf("❌ Report hosting failed: %v", err)
+			} else if published > 0 {
+				log.Printf("📰 Published %d reports", published)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type candidate struct {
+	id          uuid.UUID
+	name        string
+	target      string
+	projectID   string
+	completedAt time.Time
+}
+
+// publish publishes a batch of the reports of each kind not yet hosted
+func (p *Publisher) publish(ctx context.Context) (int, error) {
+	published := 0
+	for _, kind := range Kinds {
+		var exists bool
+		if err := p.db.Pool.QueryRow(ctx, `SELECT to_regclass('`+kindTables[kind]+`') IS NOT NULL`).Scan(&exists); err != nil {
+			return published, err
+		}
+		if !exists {
+			continue
+		}
+
+		rows, err := p.db.Pool.Query(ctx, candidateSources[kind], publishBatch)
+		if err != nil {
+			return published, err
+		}
+		var candidates []candidate
+		for rows.Next() {
+			var c candidate
+			if err := rows.Scan(&c.id, &c.name, &c.target, &c.projectID, &c.completedAt); err != nil {
+				rows.Close()
+				return published, err
+			}
+			candidates = append(candidates, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return published, err
+		}
+
+		for _, c := range candidates {
+			if err := p.publishScan(ctx, kind, c); err != nil {
+				return published, err
+			}
+			published++
+		}
+	}
+	return published, nil
+}
+
+// publishScan renders and stores the report of a scan. A report that can't
+// be rendered is recorded as failed; storage errors are returned so the scan
+// is tried again next round.
+func (p *Publisher) publishScan(ctx context.Context, kind string, c candidate) error {
+	report := Report{
+		ID:          uuid.New(),
+		ProjectID:   c.projectID,
+		Kind:        kind,
+		ScanID:      c.id,
+		ScanName:    c.name,
+		Target:      c.target,
+		CompletedAt: c.completedAt,
+	}
+
+	var renderErr *string
+	html, err := p.render(kind, c.id.String())
+	if err != nil {
+		message := err.Error()
+		renderErr = &message
+		log.Printf("⚠️ Failed to render the %s report of scan %s: %v", kind, c.id, err)
+	} else {
+		report.ObjectKey = ObjectKey(report)
+		report.SizeBytes = int64(len(html))
+		if err := p.storage.Put(ctx, report.ObjectKey, html); err != nil {
+			return fmt.Errorf("failed to store %s: %w", report.ObjectKey, err)
+		}
+	}
+
+	_, err = p.db.Pool.Exec(ctx, `
+		INSERT INTO hosted_reports (id, project_id, kind, scan_id, scan_name, target, object_key, size_bytes, error_message, scan_completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (kind, scan_id) DO NOTHING
+	`, report.ID, report.ProjectID, report.Kind, report.ScanID, report.ScanName, report.Target,
+		report.ObjectKey, report.SizeBytes, renderErr, report.CompletedAt)
+	return err
+}
+
+// ObjectKey is where a report is stored: by project and completion date
+func ObjectKey(r Report) string {
+	return fmt.Sprintf("%s/%s/%s-%s.html", r.ProjectID, r.CompletedAt.UTC().Format("2006-01-02"), r.Kind, r.ScanID)
+}
+
+const reportColumns = `id, project_id, kind, scan_id, scan_name, target, object_key, size_bytes, scan_completed_at, published_at`
+
+func scanReport(row pgx.Row) (*Report, error) {
+	var r Report
+	err := row.Scan(&r.ID, &r.ProjectID, &r.Kind, &r.ScanID, &r.ScanName, &r.Target,
+		&r.ObjectKey, &r.SizeBytes, &r.CompletedAt, &r.PublishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return &r, err
+}
+
+// List returns a page of the published reports of a project, optionally of
+// one kind, and how many there are
+func (p *Publisher) List(ctx context.Context, projectID, kind string, page pagination.Params) ([]Report, int, error) {
+	where := ` WHERE project_id = $1 AND error_message IS NULL`
+	args := []interface{}{projectID}
+	if kind != "" {
+		where += ` AND kind = $2`
+		args = append(args, kind)
+	}
+
+	var total int
+	if err := p.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM hosted_reports`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := p.db.Pool.Query(ctx, `SELECT `+reportColumns+` FROM hosted_reports`+where+page.OrderBy(), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, *r)
+	}
+	return reports, total, rows.Err()
+}
+
+// Get returns a published report of a project
+func (p *Publisher) Get(ctx context.Context, projectID string, id uuid.UUID) (*Report, error) {
+	return scanReport(p.db.Pool.QueryRow(ctx,
+		`SELECT `+reportColumns+` FROM hosted_reports WHERE project_id = $1 AND id = $2 AND error_message IS NULL`,
+		projectID, id))
+}
+
+// Latest returns the report of the scan of a project that completed last,
+// optionally of one kind
+func (p *Publisher) Latest(ctx context.Context, projectID, kind string) (*Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM hosted_reports WHERE project_id = $1 AND error_message IS NULL`
+	args := []interface{}{projectID}
+	if kind != "" {
+		query += ` AND kind = $2`
+		args = append(args, kind)
+	}
+	query += ` ORDER BY scan_completed_at DESC, published_at DESC LIMIT 1`
+	return scanReport(p.db.Pool.QueryRow(ctx, query, args...))
+}
+
+// Open returns the HTML of a published report
+func (p *Publisher) Open(ctx context.Context, r *Report) (io.ReadCloser, error) {
+	return p.storage.Get(ctx, r.ObjectKey)
+}
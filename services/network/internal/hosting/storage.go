@@ -0,0 +1,76 @@
+package hosting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/security-scanner/shared/pkg/objectstore"
+)
+
+// Storage is where hosted reports are kept, under keys like
+// acme/2026-10-16/network-<scan id>.html
+type Storage interface {
+	Put(ctx context.Context, key string, body []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Name() string
+}
+
+// LocalStorage keeps reports in a directory (e.g. a mounted volume)
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+func (s *LocalStorage) Name() string {
+	return "local:" + s.dir
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid report key: %s", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, 0o600)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// S3Storage stores reports in an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Storage struct {
+	*objectstore.S3
+}
+
+func NewS3Storage(endpoint, region, bucket, prefix, accessKey, secretKey string) *S3Storage {
+	return &S3Storage{S3: objectstore.NewS3(endpoint, region, bucket, prefix, accessKey, secretKey)}
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body []byte) error {
+	return s.S3.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "text/html; charset=utf-8")
+}
@@ -0,0 +1,75 @@
+package hosting
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tokenSchemaSQL holds the read token of each project: whoever has it can
+// read the project's hosted reports without an account, e.g. from a link
+// shared with stakeholders
+const tokenSchemaSQL = `
+CREATE TABLE IF NOT EXISTS hosted_report_tokens (
+    project_id VARCHAR(63) PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken issues a new read token for a project's reports, replacing the
+// previous one. Only its hash is stored, so it can't be shown again.
+func (p *Publisher) CreateToken(ctx context.Context, projectID string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := "srr_" + hex.EncodeToString(raw)
+
+	_, err := p.db.Pool.Exec(ctx, `
+		INSERT INTO hosted_report_tokens (project_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (project_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+	`, projectID, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeToken deletes a project's read token
+func (p *Publisher) RevokeToken(ctx context.Context, projectID string) error {
+	tag, err := p.db.Pool.Exec(ctx, `DELETE FROM hosted_report_tokens WHERE project_id = $1`, projectID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ValidToken reports whether token is the read token of a project
+func (p *Publisher) ValidToken(ctx context.Context, projectID, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	var stored string
+	err := p.db.Pool.QueryRow(ctx,
+		`SELECT token_hash FROM hosted_report_tokens WHERE project_id = $1`, projectID).Scan(&stored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(hashToken(token))) == 1, nil
+}
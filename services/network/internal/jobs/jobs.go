@@ -0,0 +1,255 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/sandbox"
+)
+
+// EnvVar is set on tool processes started for a job so they can be traced
+// back to it
+const EnvVar = "SCANNER_JOB_ID"
+
+// schemaSQL allows scans waiting for a slot to be 'queued' and those left
+// unfinished by a restart to be 'interrupted', and records the replica
+// running each scan and the request that created it, for retries
+const schemaSQL = `
+ALTER TABLE scans DROP CONSTRAINT IF EXISTS valid_status;
+ALTER TABLE scans ADD CONSTRAINT valid_status CHECK (status IN ('pending', 'queued', 'running', 'completed', 'failed', 'cancelled', 'interrupted'));
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS runner VARCHAR(255);
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS request JSONB`
+
+// EnsureSchema allows the queued and interrupted statuses in scans
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to allow queued scans: %w", err)
+	}
+	return nil
+}
+
+type jobKey struct{}
+
+// WithJob returns a context carrying the job ID, which sandbox.Command
+// passes to the tools it starts through EnvVar
+func WithJob(ctx context.Context, id string) context.Context {
+	ctx = sandbox.WithEnv(ctx, []string{EnvVar + "=" + id})
+	return context.WithValue(ctx, jobKey{}, id)
+}
+
+// FromContext returns the job ID carried by ctx, if any
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobKey{}).(string)
+	return id
+}
+
+// Job is a scan waiting for or holding a concurrency slot
+type Job struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Target    string     `json:"target"`
+	Scanner   string     `json:"scanner"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// Process is an operating system process started for a job
+type Process struct {
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Tracker runs jobs through a Limiter shared by every scanner and one per
+// scanner, and keeps track of which are queued and which are running
+type Tracker struct {
+	limiter *runtimeconfig.Limiter
+
+	mu       sync.Mutex
+	scanners map[string]*runtimeconfig.Limiter
+	queued   map[string]*Job
+	running  map[string]*Job
+	cancels  map[string]context.CancelFunc // of queued jobs
+}
+
+func NewTracker(limiter *runtimeconfig.Limiter) *Tracker {
+	return &Tracker{
+		limiter:  limiter,
+		scanners: map[string]*runtimeconfig.Limiter{},
+		queued:   map[string]*Job{},
+		running:  map[string]*Job{},
+		cancels:  map[string]context.CancelFunc{},
+	}
+}
+
+// SetScannerLimit caps how many jobs of scanner run at once; 0 is unlimited
+func (t *Tracker) SetScannerLimit(scanner string, limit int) {
+	t.scannerLimiter(scanner).SetLimit(limit)
+}
+
+func (t *Tracker) scannerLimiter(scanner string) *runtimeconfig.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limiter, ok := t.scanners[scanner]
+	if !ok {
+		limiter = runtimeconfig.NewLimiter(0)
+		t.scanners[scanner] = limiter
+	}
+	return limiter
+}
+
+// Run waits for a free slot of the job's scanner and a free slot overall,
+// and runs fn with a context carrying the job ID. queued, when set, is
+// called once if the job has to wait. It returns ctx's error, or
+// context.Canceled after Cancel, when the job never got to run.
+func (t *Tracker) Run(ctx context.Context, job Job, queued func(), fn func(ctx context.Context)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	scannerSlots := t.scannerLimiter(job.Scanner)
+
+	job.QueuedAt = time.Now()
+	t.mu.Lock()
+	t.queued[job.ID] = &job
+	t.cancels[job.ID] = cancel
+	t.mu.Unlock()
+
+	var err error
+	if !t.tryAcquire(scannerSlots) {
+		if queued != nil {
+			queued()
+		}
+		err = t.acquire(ctx, scannerSlots)
+	}
+
+	t.mu.Lock()
+	delete(t.queued, job.ID)
+	delete(t.cancels, job.ID)
+	if err == nil {
+		now := time.Now()
+		job.StartedAt = &now
+		t.running[job.ID] = &job
+	}
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		t.limiter.Release()
+		scannerSlots.Release()
+		t.mu.Lock()
+		delete(t.running, job.ID)
+		t.mu.Unlock()
+	}()
+	fn(WithJob(ctx, job.ID))
+	return nil
+}
+
+// tryAcquire takes a scanner slot and a shared slot if both are free
+func (t *Tracker) tryAcquire(scannerSlots *runtimeconfig.Limiter) bool {
+	if !scannerSlots.TryAcquire() {
+		return false
+	}
+	if !t.limiter.TryAcquire() {
+		scannerSlots.Release()
+		return false
+	}
+	return true
+}
+
+// acquire waits for a scanner slot, then for a shared one; holding the
+// scanner slot meanwhile keeps shared slots free for other scanners
+func (t *Tracker) acquire(ctx context.Context, scannerSlots *runtimeconfig.Limiter) error {
+	if err := scannerSlots.Acquire(ctx); err != nil {
+		return err
+	}
+	if err := t.limiter.Acquire(ctx); err != nil {
+		scannerSlots.Release()
+		return err
+	}
+	return nil
+}
+
+// Cancel stops a queued job from waiting; Run returns without running it.
+// It reports false when the job isn't queued.
+func (t *Tracker) Cancel(id string) bool {
+	t.mu.Lock()
+	cancel, ok := t.cancels[id]
+	t.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// Snapshot returns the queued jobs in queue order and the running jobs,
+// oldest first
+func (t *Tracker) Snapshot() (queued, running []Job) {
+	t.mu.Lock()
+	for _, j := range t.queued {
+		queued = append(queued, *j)
+	}
+	for _, j := range t.running {
+		running = append(running, *j)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(queued, func(a, b int) bool { return queued[a].QueuedAt.Before(queued[b].QueuedAt) })
+	sort.Slice(running, func(a, b int) bool { return running[a].StartedAt.Before(*running[b].StartedAt) })
+	return queued, running
+}
+
+// Limits returns the concurrency limit (0 is unlimited) and the slots in use
+func (t *Tracker) Limits() (limit, running int) {
+	return t.limiter.Stats()
+}
+
+// ParseLimits reads per-scanner limits written as "nmap=4,masscan=1"
+func ParseLimits(value string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		scanner, n, ok := strings.Cut(item, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(n))
+		if !ok || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid scanner limit %q, expected scanner=count", item)
+		}
+		limits[strings.ToLower(strings.TrimSpace(scanner))] = limit
+	}
+	return limits, nil
+}
+
+// Slots are the concurrency limit of a scanner (0 is unlimited) and the
+// slots in use
+type Slots struct {
+	MaxConcurrent int `json:"max_concurrent"`
+	InUse         int `json:"in_use"`
+}
+
+// ScannerLimits returns the slots of each scanner that has run a job or has
+// a limit
+func (t *Tracker) ScannerLimits() map[string]Slots {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slots := map[string]Slots{}
+	for scanner, limiter := range t.scanners {
+		limit, running := limiter.Stats()
+		slots[scanner] = Slots{MaxConcurrent: limit, InUse: running}
+	}
+	return slots
+}
+
+// Processes returns the tool processes of each running job
+func (t *Tracker) Processes() map[string][]Process {
+	return jobProcesses()
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux platform Go supports
+const clockTicks = 100
+
+// jobProcesses scans /proc for processes carrying EnvVar
+func jobProcesses() map[string][]Process {
+	bootTime := readBootTime()
+	marker := []byte(EnvVar + "=")
+
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	result := map[string][]Process{}
+	for _, dir := range dirs {
+		environ, err := os.ReadFile(filepath.Join(dir, "environ"))
+		if err != nil {
+			continue
+		}
+		var jobID string
+		for _, kv := range bytes.Split(environ, []byte{0}) {
+			if bytes.HasPrefix(kv, marker) {
+				jobID = string(kv[len(marker):])
+				break
+			}
+		}
+		if jobID == "" {
+			continue
+		}
+
+		pid, _ := strconv.Atoi(filepath.Base(dir))
+		cmdline, _ := os.ReadFile(filepath.Join(dir, "cmdline"))
+		p := Process{
+			PID:     pid,
+			Command: strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '}))),
+		}
+		if stat, err := os.ReadFile(filepath.Join(dir, "stat")); err == nil && !bootTime.IsZero() {
+			// Fields after the parenthesised command name; starttime is field 22
+			if i := bytes.LastIndexByte(stat, ')'); i > 0 {
+				fields := strings.Fields(string(stat[i+1:]))
+				if len(fields) > 19 {
+					ticks, _ := strconv.ParseInt(fields[19], 10, 64)
+					p.StartedAt = bootTime.Add(time.Duration(ticks) * time.Second / clockTicks)
+				}
+			}
+		}
+		result[jobID] = append(result[jobID], p)
+	}
+	return result
+}
+
+func readBootTime() time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			secs, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
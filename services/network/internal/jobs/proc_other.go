@@ -0,0 +1,8 @@
+//go:build !linux
+
+package jobs
+
+// jobProcesses is not supported outside Linux; jobs are listed without processes
+func jobProcesses() map[string][]Process {
+	return nil
+}
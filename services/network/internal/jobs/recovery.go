@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+// interruptedMessage is the error of scans whose replica stopped running them
+const interruptedMessage = "Interrupted: the network service stopped while the scan was running; retry it with POST /api/scans/<id>/retry"
+
+// Reconciler marks the scans of replicas that stopped as interrupted. Each
+// replica renews a lease of its own while it runs, and the scans it runs
+// record it as their runner, so a scan whose runner's lease expired will
+// never finish.
+type Reconciler struct {
+	db     *database.Database
+	leases *shareddb.Leases
+}
+
+func NewReconciler(db *database.Database, leases *shareddb.Leases) *Reconciler {
+	return &Reconciler{db: db, leases: leases}
+}
+
+// Runner is the ID stored in the runner column of the scans this replica runs
+func (r *Reconciler) Runner() string {
+	return r.leases.Holder()
+}
+
+func (r *Reconciler) replicaLease() string {
+	return "network:replica:" + r.Runner()
+}
+
+// Start renews this replica's lease and interrupts orphaned scans right
+// away, then every interval until ctx is cancelled. Call it before serving
+// requests so the scans this replica starts are never taken for orphans.
+func (r *Reconciler) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	r.tick(ctx, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.tick(ctx, interval)
+			}
+		}
+	}()
+}
+
+func (r *Reconciler) tick(ctx context.Context, interval time.Duration) {
+	r.leases.Acquire(ctx, r.replicaLease(), 3*interval)
+	if !r.leases.Acquire(ctx, "network:reconcile", 3*interval) {
+		return
+	}
+	interrupted, err := r.Interrupt(ctx)
+	if err != nil {
+		log.Printf("Failed to reconcile orphaned scans: %v", err)
+	}
+	if interrupted > 0 {
+		log.Printf("⏸️ Marked %d orphaned scan(s) as interrupted", interrupted)
+	}
+}
+
+// Interrupt marks as interrupted the pending, queued and running scans
+// whose runner holds no lease anymore, or that never recorded one. Scans
+// assigned to agents are left alone: the agent runs them, not a replica.
+func (r *Reconciler) Interrupt(ctx context.Context) (int, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE scans s SET status = 'interrupted', error_message = $1, completed_at = NOW()
+		WHERE s.status IN ('pending', 'queued', 'running') AND s.agent_id IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM job_leases l
+			WHERE l.job = 'network:replica:' || s.runner AND l.expires_at > NOW()
+		  )
+		RETURNING s.id
+	`, interruptedMessage)
+	if err != nil {
+		return 0, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		r.db.Pool.Exec(ctx, `
+			INSERT INTO scan_logs (id, scan_id, level, message, created_at)
+			VALUES ($1, $2, 'error', $3, NOW())
+		`, uuid.New(), id, interruptedMessage)
+	}
+	return len(ids), nil
+}
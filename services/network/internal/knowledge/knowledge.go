@@ -0,0 +1,198 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// DefaultLanguage is used when an entry doesn't exist in the requested language
+const DefaultLanguage = "en"
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS finding_knowledge (
+    finding_id VARCHAR(255) NOT NULL,
+    language VARCHAR(10) NOT NULL DEFAULT 'en',
+    title TEXT NOT NULL,
+    severity VARCHAR(20),
+    description TEXT NOT NULL DEFAULT '',
+    impact TEXT NOT NULL DEFAULT '',
+    remediation TEXT NOT NULL DEFAULT '',
+    reference_urls TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (finding_id, language)
+)`
+
+// Columns are the finding_knowledge columns read by ScanRow
+const Columns = `finding_id, language, title, COALESCE(severity, ''), description, impact, remediation, reference_urls, updated_at`
+
+// ErrNotFound is returned when a finding has no entry in any language
+var ErrNotFound = errors.New("knowledge entry not found")
+
+// seedEntries are written once so reports are useful out of the box; edits
+// made through the API are never overwritten
+var seedEntries = []models.KnowledgeEntry{
+	{
+		FindingID: "port-23-tcp", Language: "en", Title: "Telnet service exposed", Severity: "high",
+		Description: "The host accepts Telnet connections. Telnet sends credentials and session data in clear text.",
+		Impact:      "Anyone able to observe network traffic can capture administrator credentials and take over the device.",
+		Remediation: "Disable Telnet and manage the device over SSH. If Telnet cannot be removed, restrict it to a management network.",
+	},
+	{
+		FindingID: "port-23-tcp", Language: "es", Title: "Servicio Telnet expuesto", Severity: "high",
+		Description: "El host acepta conexiones Telnet. Telnet envía credenciales y datos de sesión en texto claro.",
+		Impact:      "Cualquiera que pueda observar el tráfico de red puede capturar credenciales de administrador y tomar el control del equipo.",
+		Remediation: "Deshabilite Telnet y administre el equipo por SSH. Si Telnet no puede eliminarse, restrínjalo a una red de gestión.",
+	},
+	{
+		FindingID: "port-21-tcp", Language: "en", Title: "FTP service exposed", Severity: "medium",
+		Description: "The host runs an FTP server. FTP transfers credentials and files without encryption.",
+		Impact:      "Credentials and transferred files can be intercepted, and anonymous access may expose internal documents.",
+		Remediation: "Replace FTP with SFTP or FTPS, disable anonymous login and restrict access to the users that need it.",
+	},
+	{
+		FindingID: "port-21-tcp", Language: "es", Title: "Servicio FTP expuesto", Severity: "medium",
+		Description: "El host ejecuta un servidor FTP. FTP transfiere credenciales y archivos sin cifrar.",
+		Impact:      "Las credenciales y los archivos transferidos pueden ser interceptados, y el acceso anónimo puede exponer documentos internos.",
+		Remediation: "Reemplace FTP por SFTP o FTPS, deshabilite el acceso anónimo y limite el acceso a los usuarios que lo necesiten.",
+	},
+	{
+		FindingID: "port-3389-tcp", Language: "en", Title: "Remote Desktop exposed", Severity: "high",
+		Description: "Microsoft Remote Desktop (RDP) is reachable on the host.",
+		Impact:      "Exposed RDP is a common ransomware entry point through password guessing and RDP vulnerabilities.",
+		Remediation: "Put RDP behind a VPN or gateway, require Network Level Authentication and MFA, and keep the host patched.",
+	},
+	{
+		FindingID: "port-3389-tcp", Language: "es", Title: "Escritorio Remoto expuesto", Severity: "high",
+		Description: "El Escritorio Remoto de Microsoft (RDP) es accesible en el host.",
+		Impact:      "RDP expuesto es una vía de entrada habitual de ransomware mediante adivinación de contraseñas y vulnerabilidades de RDP.",
+		Remediation: "Ubique RDP detrás de una VPN o gateway, exija Network Level Authentication y MFA, y mantenga el host actualizado.",
+	},
+	{
+		FindingID: "port-445-tcp", Language: "en", Title: "SMB service exposed", Severity: "high",
+		Description: "The host shares files over SMB on port 445.",
+		Impact:      "SMB has a history of wormable vulnerabilities and can leak shares, users and domain information.",
+		Remediation: "Block port 445 at the perimeter, disable SMBv1 and require SMB signing.",
+	},
+	{
+		FindingID: "port-445-tcp", Language: "es", Title: "Servicio SMB expuesto", Severity: "high",
+		Description: "El host comparte archivos por SMB en el puerto 445.",
+		Impact:      "SMB tiene un historial de vulnerabilidades propagables como gusanos y puede filtrar recursos compartidos, usuarios e información del dominio.",
+		Remediation: "Bloquee el puerto 445 en el perímetro, deshabilite SMBv1 y exija la firma SMB.",
+	},
+	{
+		FindingID: "port-6379-tcp", Language: "en", Title: "Redis exposed", Severity: "critical",
+		Description: "A Redis server is reachable. Redis has no authentication by default.",
+		Impact:      "Attackers can read or wipe cached data and often gain command execution on the server.",
+		Remediation: "Bind Redis to localhost or a private network, enable requirepass or ACLs, and rename dangerous commands.",
+	},
+	{
+		FindingID: "port-6379-tcp", Language: "es", Title: "Redis expuesto", Severity: "critical",
+		Description: "Un servidor Redis es accesible. Redis no tiene autenticación por defecto.",
+		Impact:      "Un atacante puede leer o borrar los datos en caché y con frecuencia ejecutar comandos en el servidor.",
+		Remediation: "Enlace Redis a localhost o a una red privada, habilite requirepass o ACLs y renombre los comandos peligrosos.",
+	},
+}
+
+// EnsureSchema creates the finding_knowledge table and its seed entries
+func EnsureSchema(db *database.Database) error {
+	ctx := context.Background()
+	if _, err := db.Pool.Exec(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("failed to create finding_knowledge table: %w", err)
+	}
+	for _, entry := range seedEntries {
+		_, err := db.Pool.Exec(ctx, `
+			INSERT INTO finding_knowledge (finding_id, language, title, severity, description, impact, remediation)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (finding_id, language) DO NOTHING
+		`, entry.FindingID, entry.Language, entry.Title, entry.Severity, entry.Description, entry.Impact, entry.Remediation)
+		if err != nil {
+			return fmt.Errorf("failed to seed finding knowledge: %w", err)
+		}
+	}
+	return nil
+}
+
+// ScanRow reads an entry selected with Columns
+func ScanRow(row interface{ Scan(...interface{}) error }) (*models.KnowledgeEntry, error) {
+	var entry models.KnowledgeEntry
+	err := row.Scan(&entry.FindingID, &entry.Language, &entry.Title, &entry.Severity,
+		&entry.Description, &entry.Impact, &entry.Remediation, &entry.References, &entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if entry.References == nil {
+		entry.References = []string{}
+	}
+	return &entry, nil
+}
+
+// NormalizeLanguage lowercases a language tag and keeps the primary subtag,
+// so "es-AR" finds entries written in "es"
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if primary, _, ok := strings.Cut(strings.ReplaceAll(lang, "_", "-"), "-"); ok {
+		lang = primary
+	}
+	return lang
+}
+
+// PortFindingIDs are the IDs a network port finding is looked up by, the
+// most specific first
+func PortFindingIDs(port models.Port) []string {
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	ids := []string{fmt.Sprintf("port-%d-%s", port.Port, protocol)}
+	if port.Service != "" && port.Service != "unknown" {
+		ids = append(ids, "service-"+strings.ToLower(port.Service))
+	}
+	return ids
+}
+
+// Lookup returns the entries of the given finding IDs keyed by ID. Each
+// entry is in lang when written in it, otherwise in DefaultLanguage, otherwise
+// in any language. IDs without an entry are left out.
+func Lookup(ctx context.Context, db *database.Database, ids []string, lang string) (map[string]models.KnowledgeEntry, error) {
+	entries := map[string]models.KnowledgeEntry{}
+	if len(ids) == 0 {
+		return entries, nil
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (finding_id) `+Columns+` FROM finding_knowledge
+		WHERE finding_id = ANY($1)
+		ORDER BY finding_id, language = $2 DESC, language = $3 DESC, language
+	`, ids, NormalizeLanguage(lang), DefaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := ScanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries[entry.FindingID] = *entry
+	}
+	return entries, rows.Err()
+}
+
+// Get returns one finding's entry with the same language fallback as Lookup
+func Get(ctx context.Context, db *database.Database, findingID, lang string) (*models.KnowledgeEntry, error) {
+	entries, err := Lookup(ctx, db, []string{findingID}, lang)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[findingID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
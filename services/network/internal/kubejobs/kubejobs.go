@@ -0,0 +1,613 @@
+// Package kubejobs runs scan tools as Kubernetes Jobs instead of processes
+// on the service pod. Each tool has a profile with its image, resources and
+// node selector; a scan may also pick a network zone, whose node selector
+// places the job on nodes inside that zone. The tool's stdout is read from
+// the pod log and its stderr from the container's termination message.
+package kubejobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/jobs"
+	"github.com/security-scanner/shared/pkg/sandbox"
+)
+
+const (
+	// serviceAccountDir holds the token, CA and namespace of the pod
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// pollInterval is how often a job's status is checked
+	pollInterval = 2 * time.Second
+	// finishedTTL is how long Kubernetes keeps a job the service failed to delete
+	finishedTTL = 600
+
+	labelScan = "security-scanner/scan-id"
+	labelTool = "security-scanner/tool"
+	container = "tool"
+)
+
+// Profile configures the job of one tool
+type Profile struct {
+	// Image runs the tool, e.g. "instrumentisto/nmap:7.94"; it needs sh
+	Image string `json:"image"`
+	// Command is the tool's binary in the image, the tool name by default
+	Command string `json:"command,omitempty"`
+	// Resources are the container's requests and limits, e.g.
+	// {"requests": {"cpu": "250m", "memory": "128Mi"}, "limits": {"memory": "512Mi"}}
+	Resources map[string]map[string]string `json:"resources,omitempty"`
+	// NodeSelector places every job of the tool, on top of the scan's zone
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// Capabilities are added to the container, e.g. NET_RAW for raw packets
+	Capabilities []string `json:"capabilities,omitempty"`
+	// HostNetwork runs the tool in the node's network namespace
+	HostNetwork    bool   `json:"host_network,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	// DeadlineSeconds stops jobs running longer (0 = no limit)
+	DeadlineSeconds int `json:"deadline_seconds,omitempty"`
+}
+
+// Backend creates tool jobs through the Kubernetes API
+type Backend struct {
+	apiURL    string
+	namespace string
+	tokenFile string
+	client    *http.Client
+	profiles  map[string]Profile
+	zones     map[string]map[string]string
+}
+
+// Load parses the JSON profile map (K8S_JOB_PROFILES), keyed by tool name,
+// and the zones (K8S_ZONES), a map of zone name to node selector. It uses
+// the pod's service account; apiURL and namespace override the in-cluster
+// defaults.
+func Load(rawProfiles, rawZones, apiURL, namespace string) (*Backend, error) {
+	b := &Backend{
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		namespace: namespace,
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+		profiles:  map[string]Profile{},
+		zones:     map[string]map[string]string{},
+	}
+	if err := json.Unmarshal([]byte(rawProfiles), &b.profiles); err != nil {
+		return nil, fmt.Errorf("invalid job profiles: %w", err)
+	}
+	for tool, p := range b.profiles {
+		if p.Image == "" {
+			return nil, fmt.Errorf("job profile %s has no image", tool)
+		}
+	}
+	if strings.TrimSpace(rawZones) != "" {
+		if err := json.Unmarshal([]byte(rawZones), &b.zones); err != nil {
+			return nil, fmt.Errorf("invalid zones: %w", err)
+		}
+	}
+
+	if b.apiURL == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, errors.New("not running in a cluster; set K8S_API_URL")
+		}
+		b.apiURL = "https://" + host + ":" + port
+	}
+	if b.namespace == "" {
+		ns, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read namespace: %w", err)
+		}
+		b.namespace = strings.TrimSpace(string(ns))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	b.client = &http.Client{Transport: transport}
+	return b, nil
+}
+
+// Handles reports whether tool runs as a job; it is false on a nil backend
+func (b *Backend) Handles(tool string) bool {
+	if b == nil {
+		return false
+	}
+	_, ok := b.profiles[tool]
+	return ok
+}
+
+// Zones returns the names of the network zones, sorted
+func (b *Backend) Zones() []string {
+	if b == nil {
+		return nil
+	}
+	names := make([]string, 0, len(b.zones))
+	for name := range b.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasZone reports whether zone is configured
+func (b *Backend) HasZone(zone string) bool {
+	if b == nil {
+		return false
+	}
+	_, ok := b.zones[zone]
+	return ok
+}
+
+type zoneKey struct{}
+
+// WithZone returns a context whose jobs run on the nodes of zone
+func WithZone(ctx context.Context, zone string) context.Context {
+	if zone == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, zoneKey{}, zone)
+}
+
+// ExitError is a job whose tool failed or never ran
+type ExitError struct {
+	Code   int
+	Reason string // e.g. Error, OOMKilled, DeadlineExceeded, ErrImagePull
+	Stderr string
+}
+
+// ExitCode returns the tool's exit status, -1 when it never ran or was
+// stopped (e.g. ErrImagePull, DeadlineExceeded)
+func (e *ExitError) ExitCode() int {
+	if e.Code == 0 {
+		return -1
+	}
+	return e.Code
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("job failed: %s", e.Reason)
+	if e.Code != 0 {
+		msg = fmt.Sprintf("job failed: exit status %d (%s)", e.Code, e.Reason)
+	}
+	if e.Stderr != "" {
+		msg += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return msg
+}
+
+// Job is a tool job started by Start
+type Job struct {
+	b      *Backend
+	ctx    context.Context
+	name   string
+	stdout *io.PipeReader
+	logs   chan struct{} // closed when the log stream ended
+	stderr string
+}
+
+// Start creates the job of tool with args. Like exec.Cmd's StdoutPipe,
+// Stdout must be read to the end before calling Wait.
+func (b *Backend) Start(ctx context.Context, tool string, args []string) (*Job, error) {
+	profile, ok := b.profiles[tool]
+	if !ok {
+		return nil, fmt.Errorf("no job profile for %s", tool)
+	}
+	name, err := jobName(tool, jobs.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	manifest := b.manifest(ctx, name, tool, profile, args)
+	if err := b.do(ctx, http.MethodPost, b.jobsPath(""), manifest, nil); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	job := &Job{b: b, ctx: ctx, name: name, stdout: reader, logs: make(chan struct{})}
+	go job.streamLogs(writer)
+	return job, nil
+}
+
+// Name returns the job's name
+func (j *Job) Name() string {
+	return j.name
+}
+
+// Stdout returns the tool's output as it runs
+func (j *Job) Stdout() io.Reader {
+	return j.stdout
+}
+
+// Stderr returns the tool's error output once Wait returned; Kubernetes
+// keeps its last 4 KB
+func (j *Job) Stderr() string {
+	return j.stderr
+}
+
+// Output reads the tool's output and waits for the job
+func (j *Job) Output() ([]byte, error) {
+	output, readErr := io.ReadAll(j.stdout)
+	if err := j.Wait(); err != nil {
+		return output, err
+	}
+	return output, readErr
+}
+
+// Wait waits for the job to finish and deletes it. A cancelled context
+// deletes the job, stopping the tool.
+func (j *Job) Wait() error {
+	defer j.delete()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := j.finished()
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+		select {
+		case <-j.ctx.Done():
+			return j.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	state, err := j.terminated()
+	if err != nil {
+		return err
+	}
+	j.stderr = state.Message
+	if state.ExitCode != 0 || state.Reason != "Completed" {
+		return &ExitError{Code: state.ExitCode, Reason: state.Reason, Stderr: state.Message}
+	}
+	return nil
+}
+
+// delete removes the job and its pod
+func (j *Job) delete() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	j.b.do(ctx, http.MethodDelete, j.b.jobsPath(j.name)+"?propagationPolicy=Background", nil, nil)
+}
+
+// streamLogs copies the pod log into w once the tool started
+func (j *Job) streamLogs(w *io.PipeWriter) {
+	defer close(j.logs)
+	pod, err := j.waitForPod()
+	if err != nil {
+		w.CloseWithError(err)
+		return
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log?container=%s&follow=true", j.b.namespace, pod, container)
+	req, err := j.b.request(j.ctx, http.MethodGet, path, nil)
+	if err != nil {
+		w.CloseWithError(err)
+		return
+	}
+	resp, err := j.b.client.Do(req)
+	if err != nil {
+		w.CloseWithError(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		w.CloseWithError(apiError(resp))
+		return
+	}
+	_, err = io.Copy(w, resp.Body)
+	w.CloseWithError(err)
+}
+
+// waitForPod returns the job's pod once its tool started or finished
+func (j *Job) waitForPod() (string, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := j.pod()
+		if err != nil {
+			return "", err
+		}
+		if pod != nil {
+			if pod.Status.Phase != "Pending" {
+				return pod.Metadata.Name, nil
+			}
+			if state := pod.containerState(); state.Waiting != nil && fatalWaiting[state.Waiting.Reason] {
+				return "", &ExitError{Reason: state.Waiting.Reason, Stderr: state.Waiting.Message}
+			}
+		}
+		select {
+		case <-j.ctx.Done():
+			return "", j.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fatalWaiting are the reasons a container waits for that won't resolve
+// by themselves
+var fatalWaiting = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// finished reports whether the job succeeded or failed. A pod stuck on an
+// image that can't be pulled fails it.
+func (j *Job) finished() (bool, error) {
+	var job struct {
+		Status struct {
+			Succeeded  int `json:"succeeded"`
+			Failed     int `json:"failed"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := j.b.do(j.ctx, http.MethodGet, j.b.jobsPath(j.name), nil, &job); err != nil {
+		if j.ctx.Err() != nil {
+			return false, j.ctx.Err()
+		}
+		return false, fmt.Errorf("failed to read job: %w", err)
+	}
+	if job.Status.Succeeded > 0 || job.Status.Failed > 0 {
+		return true, nil
+	}
+	for _, c := range job.Status.Conditions {
+		if (c.Type == "Failed" || c.Type == "Complete") && c.Status == "True" {
+			return true, nil
+		}
+	}
+
+	pod, err := j.pod()
+	if err == nil && pod != nil {
+		if state := pod.containerState(); state.Waiting != nil && fatalWaiting[state.Waiting.Reason] {
+			return false, &ExitError{Reason: state.Waiting.Reason, Stderr: state.Waiting.Message}
+		}
+	}
+	return false, nil
+}
+
+// terminated returns how the tool's container ended
+func (j *Job) terminated() (*terminatedState, error) {
+	pod, err := j.pod()
+	if err != nil {
+		return nil, err
+	}
+	if pod == nil {
+		// The pod is gone when the job hit its deadline
+		return &terminatedState{Reason: "DeadlineExceeded"}, nil
+	}
+	if state := pod.containerState(); state.Terminated != nil {
+		return state.Terminated, nil
+	}
+	return &terminatedState{Reason: pod.Status.Phase}, nil
+}
+
+type terminatedState struct {
+	ExitCode int    `json:"exitCode"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+}
+
+type containerState struct {
+	Waiting *struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"waiting"`
+	Terminated *terminatedState `json:"terminated"`
+}
+
+type pod struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Phase             string `json:"phase"`
+		ContainerStatuses []struct {
+			Name  string         `json:"name"`
+			State containerState `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+func (p *pod) containerState() containerState {
+	for _, s := range p.Status.ContainerStatuses {
+		if s.Name == container {
+			return s.State
+		}
+	}
+	return containerState{}
+}
+
+// pod returns the job's pod, nil while it has none
+func (j *Job) pod() (*pod, error) {
+	var list struct {
+		Items []pod `json:"items"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s", j.b.namespace, url.QueryEscape("job-name="+j.name))
+	if err := j.b.do(j.ctx, http.MethodGet, path, nil, &list); err != nil {
+		if j.ctx.Err() != nil {
+			return nil, j.ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read job pod: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return &list.Items[len(list.Items)-1], nil
+}
+
+// manifest builds the Job object. The tool's stderr goes to the termination
+// log so the pod log is its stdout alone.
+func (b *Backend) manifest(ctx context.Context, name, tool string, p Profile, args []string) map[string]interface{} {
+	command := p.Command
+	if command == "" {
+		command = tool
+	}
+	labels := map[string]string{labelTool: tool}
+	env := []map[string]string{}
+	if id := jobs.FromContext(ctx); id != "" {
+		labels[labelScan] = id
+	}
+	// Includes jobs.EnvVar, set by jobs.WithJob
+	for _, kv := range sandbox.Env(ctx) {
+		key, value, _ := strings.Cut(kv, "=")
+		env = append(env, map[string]string{"name": key, "value": value})
+	}
+
+	nodeSelector := map[string]string{}
+	for k, v := range p.NodeSelector {
+		nodeSelector[k] = v
+	}
+	if zone, _ := ctx.Value(zoneKey{}).(string); zone != "" {
+		for k, v := range b.zones[zone] {
+			nodeSelector[k] = v
+		}
+	}
+
+	c := map[string]interface{}{
+		"name":    container,
+		"image":   p.Image,
+		"command": append([]string{"sh", "-c", `exec "$0" "$@" 2>/dev/termination-log`, command}, args...),
+		"env":     env,
+	}
+	if len(p.Resources) > 0 {
+		c["resources"] = p.Resources
+	}
+	if len(p.Capabilities) > 0 {
+		c["securityContext"] = map[string]interface{}{
+			"capabilities": map[string]interface{}{"add": p.Capabilities},
+		}
+	}
+
+	podSpec := map[string]interface{}{
+		"restartPolicy": "Never",
+		"containers":    []interface{}{c},
+		"hostNetwork":   p.HostNetwork,
+	}
+	if len(nodeSelector) > 0 {
+		podSpec["nodeSelector"] = nodeSelector
+	}
+	if p.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = p.ServiceAccount
+	}
+
+	spec := map[string]interface{}{
+		"backoffLimit":            0,
+		"ttlSecondsAfterFinished": finishedTTL,
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": labels},
+			"spec":     podSpec,
+		},
+	}
+	if p.DeadlineSeconds > 0 {
+		spec["activeDeadlineSeconds"] = p.DeadlineSeconds
+	}
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		"spec":       spec,
+	}
+}
+
+// jobName is "scan-<tool>-<start of the scan ID>-<random>", short enough
+// for the pod name Kubernetes derives from it
+func jobName(tool, scanID string) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	if len(scanID) > 8 {
+		scanID = scanID[:8]
+	}
+	name := "scan-" + tool
+	if scanID != "" {
+		name += "-" + scanID
+	}
+	return strings.ToLower(name + "-" + hex.EncodeToString(suffix)), nil
+}
+
+func (b *Backend) jobsPath(name string) string {
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", b.namespace)
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+// request builds an API request authenticated with the service account
+// token, read each time since Kubernetes rotates it
+func (b *Backend) request(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.apiURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token, err := os.ReadFile(b.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return req, nil
+}
+
+// do sends an API request and decodes the response into out
+func (b *Backend) do(ctx context.Context, method, path string, body, out interface{}) error {
+	req, err := b.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiError reads the Status object of a failed API request
+func apiError(resp *http.Response) error {
+	var status struct {
+		Message string `json:"message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(body, &status) == nil && status.Message != "" {
+		return fmt.Errorf("kubernetes API %d: %s", resp.StatusCode, status.Message)
+	}
+	return fmt.Errorf("kubernetes API %d", resp.StatusCode)
+}
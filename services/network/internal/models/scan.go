@@ -4,6 +4,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type Scan struct {
@@ -19,6 +22,59 @@ type Scan struct {
 	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
 	ErrorMessage  *string                `json:"error_message,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	AgentID       *uuid.UUID             `json:"agent_id,omitempty"`
+	// Origin is what started the scan: manual, schedule:<id>,
+	// workflow:<id> or ci:<API key name>
+	Origin string `json:"origin"`
+	// ProjectID is the project (X-Tenant-ID) the scan belongs to
+	ProjectID string `json:"project_id"`
+	// PossiblyBlocked is set when the target stopped answering mid-scan
+	PossiblyBlocked *BlockedStatus `json:"possibly_blocked,omitempty"`
+	// Quality tells a clean scan from a degraded one
+	Quality *ScanQuality `json:"quality,omitempty"`
+	// HookResults are the outcomes of the scan's pre and post hooks
+	HookResults []HookResult `json:"hook_results,omitempty"`
+	// SkippedHosts ran out of their host_timeout budget: their ports were
+	// not fully scanned
+	SkippedHosts []SkippedHost `json:"skipped_hosts,omitempty"`
+	// ResolverHealth is how each DoH/DoT resolver of a DNS scan answered
+	ResolverHealth []securedns.Health `json:"resolver_health,omitempty"`
+}
+
+// SkippedHost is a host a scan gave up on before scanning all its ports
+type SkippedHost struct {
+	Host           string  `json:"host"`
+	Hostname       string  `json:"hostname,omitempty"`
+	Reason         string  `json:"reason"` // host_timeout
+	ElapsedSeconds float64 `json:"elapsed_seconds,omitempty"`
+	ProbedPorts    int     `json:"probed_ports,omitempty"` // native scans: ports probed before the host was skipped
+	TotalPorts     int     `json:"total_ports,omitempty"`
+}
+
+// BlockedStatus means hosts stopped answering mid-scan the way an IPS or
+// firewall blocking the scanner does: results after Since are unreliable,
+// not clean
+type BlockedStatus struct {
+	Since      time.Time `json:"since"` // first unanswered probe
+	DetectedAt time.Time `json:"detected_at"`
+	Hosts      []string  `json:"hosts"`
+	Reason     string    `json:"reason"`
+}
+
+// ScanQuality scores how complete and trustworthy a scan's results are,
+// from what happened to its probes. Counts cover the hosts found up.
+type ScanQuality struct {
+	Score           int      `json:"score"` // 0-100
+	Grade           string   `json:"grade"` // clean, degraded or unreliable
+	Hosts           int      `json:"hosts"`
+	ProbesAttempted int      `json:"probes_attempted"` // host/port pairs
+	ProbesAnswered  int      `json:"probes_answered"`  // open, closed, or refused by a firewall
+	Timeouts        int      `json:"timeouts"`
+	Errors          int      `json:"errors"`          // probes the scanner failed to send
+	Retransmissions int      `json:"retransmissions"` // hosts nmap gave up retransmitting to
+	HostsTimedOut   int      `json:"hosts_timed_out"`
+	Coverage        float64  `json:"coverage"` // answered / attempted
+	Reasons         []string `json:"reasons"`
 }
 
 type ScanResult struct {
@@ -32,7 +88,149 @@ type ScanResult struct {
 	Services    []string               `json:"services"`
 	MacAddress  *string                `json:"mac_address,omitempty"`
 	MacVendor   *string                `json:"mac_vendor,omitempty"`
+	Honeypot    *HoneypotAssessment    `json:"honeypot,omitempty"`
+	Reputation  *IPReputation          `json:"reputation,omitempty"`
+	Resolution  *ResolutionCheck       `json:"resolution,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Criticality string                 `json:"criticality,omitempty"`
+	Owner       *owners.Owner          `json:"owner,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
+	// Vulnerabilities are what NSE scripts (vuln category, vulners) found
+	Vulnerabilities []ScriptVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// ScriptVulnerability is a vulnerability an NSE script reported on a host or
+// one of its ports
+type ScriptVulnerability struct {
+	Script   string   `json:"script"`
+	Port     int      `json:"port,omitempty"` // 0 for host scripts
+	Protocol string   `json:"protocol,omitempty"`
+	ID       string   `json:"id"` // CVE when known, else the script's key for it
+	Title    string   `json:"title"`
+	State    string   `json:"state,omitempty"` // VULNERABLE, LIKELY VULNERABLE, ...
+	Severity string   `json:"severity"`
+	CVSS     float64  `json:"cvss,omitempty"`
+	IDs      []string `json:"ids,omitempty"` // CVE, BID, OSVDB, ...
+}
+
+// HoneypotAssessment flags hosts whose results look like a honeypot or tarpit
+type HoneypotAssessment struct {
+	Score   int      `json:"score"`  // 0-100
+	Likely  bool     `json:"likely"` // score reached the threshold
+	Reasons []string `json:"reasons"`
+}
+
+// IPReputation is what blocklists and abuse databases know about an IP
+type IPReputation struct {
+	IP                  string     `json:"ip"`
+	Malicious           bool       `json:"malicious"`
+	SpamhausLists       []string   `json:"spamhaus_lists"`
+	AbuseScore          *int       `json:"abuse_score,omitempty"` // AbuseIPDB confidence, 0-100
+	AbuseReports        *int       `json:"abuse_reports,omitempty"`
+	AbuseLastReportedAt *time.Time `json:"abuse_last_reported_at,omitempty"`
+	Country             *string    `json:"country,omitempty"`
+	ISP                 *string    `json:"isp,omitempty"`
+	UsageType           *string    `json:"usage_type,omitempty"`
+	Error               *string    `json:"error,omitempty"`
+	CheckedAt           time.Time  `json:"checked_at"`
+}
+
+// ResolutionCheck compares the address a host was scanned at with what
+// recon resolved its names to, to tell what was really scanned
+type ResolutionCheck struct {
+	// consistent: recon resolved the names to this address; stale_dns: recon
+	// resolved the scanned name elsewhere; cdn: the address is a CDN edge, so
+	// the CDN was scanned, not the origin server
+	Status     string     `json:"status"`
+	Hostnames  []string   `json:"hostnames"`             // names recon resolved to this address, and the scanned one
+	ReconIPs   []string   `json:"recon_ips,omitempty"`   // what recon resolved the scanned name to
+	CNAMEs     []string   `json:"cnames,omitempty"`      // CNAMEs recon found for the scanned name
+	CDN        string     `json:"cdn,omitempty"`         // e.g. Cloudflare, Akamai, CloudFront
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"` // when recon resolved the scanned name
+	Note       string     `json:"note"`
+}
+
+// KnowledgeEntry is the client-ready write-up of a finding in one language.
+// FindingID is a nuclei template ID, a testssl/prowler check ID, or
+// port-<port>-<proto> / service-<name> for network findings.
+type KnowledgeEntry struct {
+	FindingID   string    `json:"finding_id"`
+	Language    string    `json:"language"`
+	Title       string    `json:"title"`
+	Severity    string    `json:"severity,omitempty"`
+	Description string    `json:"description"`
+	Impact      string    `json:"impact"`
+	Remediation string    `json:"remediation"`
+	References  []string  `json:"references"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TagRule tags the assets whose results match all of its conditions, and
+// optionally raises their criticality
+type TagRule struct {
+	ID          uuid.UUID      `json:"id"`
+	Name        string         `json:"name"`
+	Description *string        `json:"description,omitempty"`
+	Conditions  []TagCondition `json:"conditions"`
+	Tags        []string       `json:"tags"`
+	Criticality string         `json:"criticality,omitempty"` // low, medium, high or critical
+	Enabled     bool           `json:"enabled"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// TagCondition matches one attribute of an asset: port, service, product,
+// ip, hostname or os. Value may list alternatives separated by commas.
+type TagCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator,omitempty"` // equals (default), matches (regex) or in_cidr
+	Value    string `json:"value"`
+}
+
+// ScanHook is a webhook or allow-listed script run before a scan starts or
+// after it finishes, for the scans of a template or of a project
+// (X-Tenant-ID). A required pre hook that fails stops its scan.
+type ScanHook struct {
+	ID             uuid.UUID  `json:"id"`
+	Name           string     `json:"name"`
+	Stage          string     `json:"stage"` // pre or post
+	Kind           string     `json:"kind"`  // webhook or script
+	URL            string     `json:"url,omitempty"`
+	Script         string     `json:"script,omitempty"` // file name in HOOKS_DIR
+	TemplateID     *uuid.UUID `json:"template_id,omitempty"`
+	Project        string     `json:"project,omitempty"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	Required       bool       `json:"required"`
+	Enabled        bool       `json:"enabled"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// HookResult is how one run of a hook went
+type HookResult struct {
+	HookID     uuid.UUID `json:"hook_id"`
+	Name       string    `json:"name"`
+	Stage      string    `json:"stage"`
+	Kind       string    `json:"kind"`
+	Status     string    `json:"status"` // success or failed
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Output     string    `json:"output,omitempty"` // start of the script output or response body
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Asset is a host or subdomain with the tags the rules gave it and its owner
+type Asset struct {
+	Asset       string        `json:"asset"`
+	Tags        []string      `json:"tags"`
+	Criticality string        `json:"criticality,omitempty"`
+	Owner       *owners.Owner `json:"owner,omitempty"`
+	Sources     []string      `json:"sources"` // scan and/or recon
+	LastScanID  *uuid.UUID    `json:"last_scan_id,omitempty"`
+	FirstSeen   time.Time     `json:"first_seen"`
+	LastSeen    time.Time     `json:"last_seen"`
 }
 
 type Port struct {
@@ -43,15 +241,11 @@ type Port struct {
 	Version   string `json:"version,omitempty"`
 	Product   string `json:"product,omitempty"`
 	ExtraInfo string `json:"extrainfo,omitempty"`
+	Confirmed bool   `json:"confirmed,omitempty"` // a UDP probe got an answer
 }
 
-type ScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
-}
+// ScanLog represents a log entry for a scan (shared by all services)
+type ScanLog = shared.ScanLog
 
 type ScanTemplate struct {
 	ID            uuid.UUID              `json:"id"`
@@ -61,15 +255,144 @@ type ScanTemplate struct {
 	NmapArguments *string                `json:"nmap_arguments,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
 	IsDefault     bool                   `json:"is_default"`
+	ProjectID     string                 `json:"project_id"`
+	PackID        *uuid.UUID             `json:"pack_id,omitempty"` // the template pack that imported it
 	CreatedAt     time.Time              `json:"created_at"`
 }
 
+// TemplatePack is an imported community template pack and where it came from
+type TemplatePack struct {
+	ID              uuid.UUID           `json:"id"`
+	Name            string              `json:"name"`
+	Version         string              `json:"version"`
+	Publisher       string              `json:"publisher"`
+	Description     string              `json:"description"`
+	SourceURL       *string             `json:"source_url"` // null for uploaded packs
+	Signed          bool                `json:"signed"`
+	KeyID           *string             `json:"key_id"` // the trusted key that signed it
+	Digest          string              `json:"digest"` // sha256 of the pack document
+	ImportedBy      string              `json:"imported_by"`
+	ProjectID       string              `json:"project_id"`
+	ImportedAt      time.Time           `json:"imported_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	NmapTemplates   int                 `json:"nmap_templates"`
+	NucleiTemplates int                 `json:"nuclei_templates"`
+	FfufTemplates   int                 `json:"ffuf_templates"`
+	Templates       []TemplatePackEntry `json:"templates,omitempty"`
+}
+
+// TemplatePackEntry is a template imported by a pack
+type TemplatePackEntry struct {
+	Kind string    `json:"kind"` // nmap, nuclei or ffuf
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// Template hint kinds
+const (
+	HintUnused             = "unused"              // not used within the analytics window
+	HintInvalidArguments   = "invalid_arguments"   // the service rejects the arguments
+	HintDeprecatedArgument = "deprecated_argument" // an option current nmap replaced
+	HintMissingScript      = "missing_script"      // an NSE script the installed nmap lacks
+	HintUnknownScanType    = "unknown_scan_type"   // no scanner runs this scan type
+)
+
+// TemplateHint is a reason to review or retire a template
+type TemplateHint struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// TemplateUsage is how much a template is used and what its scans find.
+// Builtin templates are the scan types scanners define in code; stored ones
+// are rows of scan_templates. Scans created without template_id count for
+// the template of their scanner and scan type.
+type TemplateUsage struct {
+	ID        *uuid.UUID `json:"id,omitempty"` // stored templates only
+	Source    string     `json:"source"`       // builtin or stored
+	Name      string     `json:"name"`
+	Scanner   string     `json:"scanner"`
+	ScanType  string     `json:"scan_type"`
+	Arguments string     `json:"arguments,omitempty"` // nmap arguments, or masscan ports
+	IsDefault bool       `json:"is_default"`
+
+	Uses          int            `json:"uses"`
+	CompletedUses int            `json:"completed_uses"`
+	FailedUses    int            `json:"failed_uses"`
+	LastUsedAt    *time.Time     `json:"last_used_at,omitempty"`
+	AvgHosts      float64        `json:"avg_hosts"`      // hosts found per completed scan
+	AvgOpenPorts  float64        `json:"avg_open_ports"` // open ports found per completed scan
+	MeasuredScans int            `json:"measured_scans"` // completed, non-simulated scans behind the averages
+	Hints         []TemplateHint `json:"hints"`
+}
+
+// TemplateAnalytics is the usage of every template
+type TemplateAnalytics struct {
+	NmapVersion string          `json:"nmap_version,omitempty"`
+	UnusedDays  int             `json:"unused_days"`
+	Templates   []TemplateUsage `json:"templates"`
+}
+
 type CreateScanRequest struct {
 	Name          string                 `json:"name"`
 	Target        string                 `json:"target"`
 	ScanType      string                 `json:"scan_type"`
 	NmapArguments *string                `json:"nmap_arguments,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	AgentID       *uuid.UUID             `json:"agent_id,omitempty"`  // run on a remote agent instead of this service
+	Scanner       string                 `json:"scanner,omitempty"`   // nmap, masscan, dns or native; derived from scan_type when empty
+	Ports         string                 `json:"ports,omitempty"`     // e.g. "22,80,443,8000-8100"
+	TopPorts      int                    `json:"top_ports,omitempty"` // scan the N most common ports
+	Protocol      string                 `json:"protocol,omitempty"`  // tcp, udp or both
+	Simulate      bool                   `json:"simulate,omitempty"`  // return synthetic results without scanning
+	Zone          string                 `json:"zone,omitempty"`      // network zone of the scan's Kubernetes Job
+	// HostTimeout is the time budget of each host in seconds; hosts that
+	// use it up are skipped and listed in the scan's skipped_hosts
+	HostTimeout int `json:"host_timeout,omitempty"`
+	// TemplateID fills scan_type, nmap_arguments and configuration from a
+	// stored template and records which template the scan used
+	TemplateID *uuid.UUID `json:"template_id,omitempty"`
+	// Advanced passes extra flags and environment variables to the tool
+	// (admin only)
+	Advanced *AdvancedOptions `json:"advanced,omitempty"`
+	// Project is the X-Tenant-ID the scan was created with, which selects
+	// the project's hooks
+	Project string `json:"-"`
+}
+
+// AdvancedOptions are tool flags and environment variables the API has no
+// field for, each checked against the scanner's allow-list
+type AdvancedOptions struct {
+	Flags []string          `json:"flags,omitempty"` // e.g. ["--data-length", "24", "--ttl=64"]
+	Env   map[string]string `json:"env,omitempty"`
+}
+
+// ScanEstimate is the expected footprint of a scan before it runs. Figures
+// are approximations from the scanner's options, not measurements.
+type ScanEstimate struct {
+	Scanner         string   `json:"scanner"`
+	ScanType        string   `json:"scan_type"`
+	Arguments       string   `json:"arguments,omitempty"` // nmap arguments the scan would run with
+	Hosts           int64    `json:"hosts"`
+	Hostnames       int      `json:"hostnames"` // unresolved names, counted as one host each
+	PortsPerHost    int      `json:"ports_per_host"`
+	Packets         int64    `json:"packets"` // probes sent: packets, connections for native, queries for dns
+	DurationSeconds int64    `json:"duration_seconds"`
+	Duration        string   `json:"duration"`
+	Intrusiveness   string   `json:"intrusiveness"` // low, medium, high or critical
+	Factors         []string `json:"factors"`       // what drives the rating
+	Assumptions     []string `json:"assumptions"`
+	Warnings        []string `json:"warnings,omitempty"`
+
+	CollapsedTargets []CollapsedTarget `json:"collapsed_targets,omitempty"`
+}
+
+// CollapsedTarget is a part of a target list left out of a scan because
+// another part already covers it
+type CollapsedTarget struct {
+	Target string `json:"target"`
+	Into   string `json:"into"`   // the part that covers it
+	Reason string `json:"reason"` // duplicate or covered
 }
 
 type CreateTemplateRequest struct {
@@ -80,3 +403,104 @@ type CreateTemplateRequest struct {
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
 	IsDefault     bool                   `json:"is_default"`
 }
+
+// ScanRecommendation is a follow-up scan suggested from a scan's findings.
+// Payload can be POSTed as-is to Path on the gateway.
+type ScanRecommendation struct {
+	Tool    string                 `json:"tool"`
+	Reason  string                 `json:"reason"`
+	Host    string                 `json:"host"`
+	Port    int                    `json:"port,omitempty"`
+	Method  string                 `json:"method"`
+	Path    string                 `json:"path"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// ScanDiff is what changed on a target between two scans of it: Against is
+// the earlier scan and Scan the later one. Opened, closed and changed ports
+// are those of hosts in both scans; new and removed hosts list their ports.
+type ScanDiff struct {
+	Scan            ScanDiffRef     `json:"scan"`
+	Against         ScanDiffRef     `json:"against"`
+	NewHosts        []DiffHost      `json:"new_hosts"`
+	RemovedHosts    []DiffHost      `json:"removed_hosts"`
+	OpenedPorts     []PortChange    `json:"opened_ports"`
+	ClosedPorts     []PortChange    `json:"closed_ports"`
+	ChangedServices []ServiceChange `json:"changed_services"`
+	Summary         map[string]int  `json:"summary"`
+}
+
+// ScanDiffRef identifies a scan of a diff
+type ScanDiffRef struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Target      string     `json:"target"`
+	Scanner     string     `json:"scanner"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// DiffHost is a host only one scan of a diff found
+type DiffHost struct {
+	Host     string `json:"host"`
+	Hostname string `json:"hostname,omitempty"`
+	Ports    []Port `json:"ports"`
+}
+
+// PortChange is a port that opened or closed between the scans of a diff
+type PortChange struct {
+	Host     string `json:"host"`
+	Hostname string `json:"hostname,omitempty"`
+	Port     Port   `json:"port"`
+}
+
+// ServiceChange is an open port whose service or version changed
+type ServiceChange struct {
+	Host     string `json:"host"`
+	Hostname string `json:"hostname,omitempty"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Before   Port   `json:"before"`
+	After    Port   `json:"after"`
+}
+
+// PortTransition is a change of state of a host:port seen by a scan
+type PortTransition struct {
+	State     string    `json:"state"` // open or closed
+	ScanID    uuid.UUID `json:"scan_id"`
+	ScanName  string    `json:"scan_name,omitempty"` // empty once the scan is deleted
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// PortHistory is the state transitions of a host:port, oldest first
+type PortHistory struct {
+	Host        string           `json:"host"`
+	Port        int              `json:"port"`
+	Protocol    string           `json:"protocol"`
+	State       string           `json:"state"` // current; unknown when never seen open
+	FirstOpened *time.Time       `json:"first_opened,omitempty"`
+	Transitions []PortTransition `json:"transitions"`
+}
+
+// ToolComparison reconciles what nmap and masscan reported for the same
+// target. Ports lists the disagreements, or every port with ?all=true.
+type ToolComparison struct {
+	Target  string         `json:"target"`
+	Nmap    ScanDiffRef    `json:"nmap"`
+	Masscan ScanDiffRef    `json:"masscan"`
+	Ports   []ToolPort     `json:"ports"`
+	Summary map[string]int `json:"summary"`
+}
+
+// ToolPort is a host:port as each tool of a comparison saw it. A state is
+// empty when the tool didn't report the port at all.
+type ToolPort struct {
+	Host         string   `json:"host"`
+	Hostname     string   `json:"hostname,omitempty"`
+	Port         int      `json:"port"`
+	Protocol     string   `json:"protocol"`
+	Status       string   `json:"status"` // agree, only_nmap, only_masscan, nmap_filtered or nmap_closed
+	ReportedBy   []string `json:"reported_by"`
+	NmapState    string   `json:"nmap_state,omitempty"`
+	MasscanState string   `json:"masscan_state,omitempty"`
+	Service      string   `json:"service,omitempty"`
+}
@@ -0,0 +1,242 @@
+package porthistory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// Port states stored in the history
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// ErrNotFound is returned for a host no scan has seen
+var ErrNotFound = errors.New("host not found")
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS port_state_history (
+    host VARCHAR(255) NOT NULL,
+    port INTEGER NOT NULL,
+    protocol VARCHAR(10) NOT NULL,
+    state VARCHAR(10) NOT NULL,
+    scan_id UUID NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, port, protocol, scan_id)
+);
+CREATE INDEX IF NOT EXISTS idx_port_state_history_changed ON port_state_history(host, port, protocol, changed_at)`
+
+// EnsureSchema creates the port state history table
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to create port state history table: %w", err)
+	}
+	return nil
+}
+
+type portKey struct {
+	port     int
+	protocol string
+}
+
+// Record stores the ports of a completed scan that changed state since the
+// previous scan of each host: ports it found open that weren't, and ports
+// open before that it no longer lists. Simulated and DNS scans are skipped.
+// Recording a scan twice changes nothing.
+func Record(ctx context.Context, db *database.Database, scanID uuid.UUID) (int, error) {
+	var scanner, status string
+	var simulated bool
+	var completedAt time.Time
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(scanner, 'nmap'), status, COALESCE(completed_at, created_at),
+		       COALESCE((configuration->>'simulated')::boolean, false)
+		FROM scans WHERE id = $1
+	`, scanID).Scan(&scanner, &status, &completedAt, &simulated)
+	if err != nil {
+		return 0, err
+	}
+	if status != "completed" || simulated || scanner == "dns" {
+		return 0, nil
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT host, ports FROM scan_results WHERE scan_id = $1 AND COALESCE(state, 'up') <> 'down'
+	`, scanID)
+	if err != nil {
+		return 0, err
+	}
+	hosts := map[string]map[portKey]bool{}
+	for rows.Next() {
+		var host string
+		var ports []models.Port
+		if err := rows.Scan(&host, &ports); err != nil {
+			continue
+		}
+		host = strings.ToLower(host)
+		if hosts[host] == nil {
+			hosts[host] = map[portKey]bool{}
+		}
+		for _, port := range ports {
+			if port.State == "" || port.State == StateOpen {
+				hosts[host][portKey{port.Port, strings.ToLower(port.Protocol)}] = true
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for host, open := range hosts {
+		previous, err := currentStates(ctx, db, host, completedAt)
+		if err != nil {
+			return recorded, err
+		}
+		var changes []portKey
+		var states []string
+		for key := range open {
+			if previous[key] != StateOpen {
+				changes = append(changes, key)
+				states = append(states, StateOpen)
+			}
+		}
+		for key, state := range previous {
+			if state == StateOpen && !open[key] {
+				changes = append(changes, key)
+				states = append(states, StateClosed)
+			}
+		}
+		for i, key := range changes {
+			tag, err := db.Pool.Exec(ctx, `
+				INSERT INTO port_state_history (host, port, protocol, state, scan_id, changed_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT DO NOTHING
+			`, host, key.port, key.protocol, states[i], scanID, completedAt)
+			if err != nil {
+				return recorded, err
+			}
+			recorded += int(tag.RowsAffected())
+		}
+	}
+	return recorded, nil
+}
+
+// currentStates returns the last state recorded for each port of a host
+// up to a time
+func currentStates(ctx context.Context, db *database.Database, host string, until time.Time) (map[portKey]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (port, protocol) port, protocol, state
+		FROM port_state_history WHERE host = $1 AND changed_at <= $2
+		ORDER BY port, protocol, changed_at DESC
+	`, host, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := map[portKey]string{}
+	for rows.Next() {
+		var key portKey
+		var state string
+		if err := rows.Scan(&key.port, &key.protocol, &state); err != nil {
+			return nil, err
+		}
+		states[key] = state
+	}
+	return states, rows.Err()
+}
+
+// Backfill records the completed scans stored before the history existed,
+// oldest first. It does nothing once the history has rows.
+func Backfill(ctx context.Context, db *database.Database) {
+	var empty bool
+	if err := db.Pool.QueryRow(ctx, `SELECT NOT EXISTS (SELECT 1 FROM port_state_history)`).Scan(&empty); err != nil || !empty {
+		return
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id FROM scans WHERE status = 'completed' ORDER BY COALESCE(completed_at, created_at)
+	`)
+	if err != nil {
+		log.Printf("Failed to backfill port state history: %v", err)
+		return
+	}
+	var scanIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if rows.Scan(&id) == nil {
+			scanIDs = append(scanIDs, id)
+		}
+	}
+	rows.Close()
+
+	recorded := 0
+	for _, id := range scanIDs {
+		n, err := Record(ctx, db, id)
+		if err != nil {
+			log.Printf("Failed to backfill port state history from scan %s: %v", id, err)
+			return
+		}
+		recorded += n
+	}
+	if recorded > 0 {
+		log.Printf("🕰️ Backfilled %d port state change(s) from %d scan(s)", recorded, len(scanIDs))
+	}
+}
+
+// Get returns the transitions of a host:port, oldest first. A hostname is
+// resolved to the address it had in its latest scan.
+func Get(ctx context.Context, db *database.Database, host string, port int, protocol string) (*models.PortHistory, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	var known bool
+	err := db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM port_state_history WHERE host = $1)`, host).Scan(&known)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		err := db.Pool.QueryRow(ctx, `
+			SELECT LOWER(host) FROM scan_results WHERE LOWER(hostname) = $1 ORDER BY created_at DESC LIMIT 1
+		`, host).Scan(&host)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT h.state, h.scan_id, COALESCE(s.name, ''), h.changed_at
+		FROM port_state_history h LEFT JOIN scans s ON s.id = h.scan_id
+		WHERE h.host = $1 AND h.port = $2 AND h.protocol = $3
+		ORDER BY h.changed_at
+	`, host, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.PortHistory{Host: host, Port: port, Protocol: protocol, State: "unknown", Transitions: []models.PortTransition{}}
+	for rows.Next() {
+		var t models.PortTransition
+		if err := rows.Scan(&t.State, &t.ScanID, &t.ScanName, &t.ChangedAt); err != nil {
+			return nil, err
+		}
+		if t.State == StateOpen && history.FirstOpened == nil {
+			opened := t.ChangedAt
+			history.FirstOpened = &opened
+		}
+		history.State = t.State
+		history.Transitions = append(history.Transitions, t)
+	}
+	return history, rows.Err()
+}
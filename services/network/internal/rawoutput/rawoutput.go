@@ -0,0 +1,82 @@
+// Package rawoutput keeps the unparsed output of the scanners (nmap XML,
+// masscan JSON) so it can be handed over as evidence with the scan's
+// evidence bundle, as the tool wrote it.
+package rawoutput
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS scan_raw_output (
+    id BIGSERIAL PRIMARY KEY,
+    scan_id UUID NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+    tool VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    content BYTEA NOT NULL,
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_scan_raw_output_scan ON scan_raw_output(scan_id)`
+
+// MaxBytes is the most of a run's output kept; the rest is dropped
+const MaxBytes = 32 << 20
+
+// Output is the output of one run of a tool; scans split in batches have one
+// per batch
+type Output struct {
+	Tool      string
+	Format    string // file extension: xml, json
+	Content   []byte
+	Truncated bool
+	CreatedAt time.Time
+}
+
+// EnsureSchema creates the raw output table
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to create raw output table: %w", err)
+	}
+	return nil
+}
+
+// Save stores the output of a run of tool. Empty output is not stored.
+func Save(ctx context.Context, db *database.Database, scanID uuid.UUID, tool, format string, content []byte) error {
+	if len(content) == 0 {
+		return nil
+	}
+	truncated := len(content) > MaxBytes
+	if truncated {
+		content = content[:MaxBytes]
+	}
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO scan_raw_output (scan_id, tool, format, content, truncated) VALUES ($1, $2, $3, $4, $5)
+	`, scanID, tool, format, content, truncated)
+	return err
+}
+
+// List returns the outputs of a scan's runs, in the order they ran
+func List(ctx context.Context, db *database.Database, scanID uuid.UUID) ([]Output, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT tool, format, content, truncated, created_at FROM scan_raw_output WHERE scan_id = $1 ORDER BY id
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outputs []Output
+	for rows.Next() {
+		var o Output
+		if err := rows.Scan(&o.Tool, &o.Format, &o.Content, &o.Truncated, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs, rows.Err()
+}
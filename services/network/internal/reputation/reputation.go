@@ -0,0 +1,437 @@
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS ip_reputation (
+    ip VARCHAR(45) PRIMARY KEY,
+    malicious BOOLEAN NOT NULL DEFAULT false,
+    spamhaus_lists TEXT[] NOT NULL DEFAULT '{}',
+    abuse_score INTEGER,
+    abuse_reports INTEGER,
+    abuse_last_reported_at TIMESTAMP,
+    country VARCHAR(8),
+    isp TEXT,
+    usage_type TEXT,
+    error TEXT,
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_ip_reputation_malicious ON ip_reputation(malicious) WHERE malicious`
+
+// spamhausLists maps Spamhaus ZEN return codes to list names. PBL entries are
+// policy listings of dynamic ranges, not evidence of abuse.
+var spamhausLists = map[string]string{
+	"127.0.0.2":  "SBL",
+	"127.0.0.3":  "SBL-CSS",
+	"127.0.0.4":  "XBL",
+	"127.0.0.5":  "XBL",
+	"127.0.0.6":  "XBL",
+	"127.0.0.7":  "XBL",
+	"127.0.0.9":  "DROP",
+	"127.0.0.10": "PBL",
+	"127.0.0.11": "PBL",
+}
+
+const abuseIPDBURL = "https://api.abuseipdb.com/api/v2/check"
+
+// ErrRateLimited is returned while AbuseIPDB's daily quota is used up
+var ErrRateLimited = errors.New("AbuseIPDB rate limit reached")
+
+// EnsureSchema creates the ip_reputation table
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to create ip_reputation table: %w", err)
+	}
+	return nil
+}
+
+// Config selects the reputation sources
+type Config struct {
+	SpamhausZone    string // DNSBL zone, e.g. zen.spamhaus.org or <key>.zen.dq.spamhaus.net
+	AbuseIPDBKey    string // AbuseIPDB is skipped when empty
+	Refresh         time.Duration
+	AbuseThreshold  int // AbuseIPDB confidence score from which an IP is malicious
+	Interval        time.Duration
+	BatchSize       int
+	CandidateWindow time.Duration // only IPs seen in results this recent are checked
+}
+
+// Enricher looks up the reputation of IPs found by network scans and of the
+// IPs subdomains resolved to in recon scans, and stores it in ip_reputation
+type Enricher struct {
+	db       *database.Database
+	cfg      Config
+	client   *http.Client
+	resolver *net.Resolver
+	leases   *shareddb.Leases
+
+	mu            sync.Mutex
+	abusePausedTo time.Time
+}
+
+func NewEnricher(db *database.Database, cfg Config) *Enricher {
+	if cfg.SpamhausZone == "" {
+		cfg.SpamhausZone = "zen.spamhaus.org"
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = 24 * time.Hour
+	}
+	if cfg.AbuseThreshold <= 0 {
+		cfg.AbuseThreshold = 50
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.CandidateWindow <= 0 {
+		cfg.CandidateWindow = 30 * 24 * time.Hour
+	}
+	return &Enricher{
+		db:       db,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		resolver: net.DefaultResolver,
+	}
+}
+
+// SetLeases makes Start enrich only on the replica holding the job's lease
+func (e *Enricher) SetLeases(leases *shareddb.Leases) {
+	e.leases = leases
+}
+
+// Start enriches stale or unchecked IPs every interval until ctx is cancelled
+func (e *Enricher) Start(ctx context.Context) {
+	sources := "Spamhaus (" + e.cfg.SpamhausZone + ")"
+	if e.cfg.AbuseIPDBKey != "" {
+		sources += ", AbuseIPDB"
+	}
+	log.Printf("🛡️ IP reputation enrichment enabled: %s (refresh every %v)", sources, e.cfg.Refresh)
+
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if e.leases.Acquire(ctx, "network:reputation", 3*e.cfg.Interval) {
+			if checked, err := e.Enrich(ctx); err != nil {
+				log.Printf("❌ IP reputation enrichment failed: %v", err)
+			} else if checked > 0 {
+				log.Printf("🛡️ Checked reputation of %d IPs", checked)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enrich checks one batch of IPs that were never checked or whose data is
+// older than the refresh interval, and returns how many were stored
+func (e *Enricher) Enrich(ctx context.Context) (int, error) {
+	ips, err := e.candidates(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	checked := 0
+	for _, ip := range ips {
+		rep, err := e.Check(ctx, ip)
+		if errors.Is(err, ErrRateLimited) {
+			// The rest of the batch is retried on a later run
+			log.Printf("⚠️ %v, resuming after %s", err, e.abusePausedUntil().Format(time.RFC3339))
+			break
+		}
+		if err != nil {
+			return checked, err
+		}
+		if err := e.store(ctx, rep); err != nil {
+			return checked, err
+		}
+		if rep.Malicious {
+			log.Printf("🚩 %s has a bad reputation (spamhaus %v, abuse score %s)", ip, rep.SpamhausLists, scoreString(rep.AbuseScore))
+		}
+		checked++
+	}
+	return checked, nil
+}
+
+// Refresh checks ip right away and stores the result
+func (e *Enricher) Refresh(ctx context.Context, ip string) (*models.IPReputation, error) {
+	rep, err := e.Check(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.store(ctx, rep); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// Check queries every configured source for ip without storing the result.
+// Lookup failures of a single source are recorded in the Error field.
+func (e *Enricher) Check(ctx context.Context, ip string) (*models.IPReputation, error) {
+	rep := &models.IPReputation{IP: ip, SpamhausLists: []string{}, CheckedAt: time.Now()}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+	if !isPublic(addr) {
+		rep.Error = stringPtr("not a public address")
+		return rep, nil
+	}
+
+	var problems []string
+	if addr.To4() != nil {
+		lists, err := e.spamhaus(ctx, addr)
+		if err != nil {
+			problems = append(problems, "spamhaus: "+err.Error())
+		}
+		rep.SpamhausLists = lists
+	}
+
+	if e.cfg.AbuseIPDBKey != "" {
+		if until := e.abusePausedUntil(); time.Now().Before(until) {
+			return nil, ErrRateLimited
+		}
+		if err := e.abuseIPDB(ctx, ip, rep); err != nil {
+			if errors.Is(err, ErrRateLimited) {
+				return nil, err
+			}
+			problems = append(problems, "abuseipdb: "+err.Error())
+		}
+	}
+
+	for _, list := range rep.SpamhausLists {
+		if list != "PBL" {
+			rep.Malicious = true
+		}
+	}
+	if rep.AbuseScore != nil && *rep.AbuseScore >= e.cfg.AbuseThreshold {
+		rep.Malicious = true
+	}
+	if len(problems) > 0 {
+		rep.Error = stringPtr(strings.Join(problems, "; "))
+	}
+	return rep, nil
+}
+
+// candidates returns IPs from recent scan results and resolved subdomains
+// that are due for a check
+func (e *Enricher) candidates(ctx context.Context) ([]string, error) {
+	since := time.Now().Add(-e.cfg.CandidateWindow)
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT c.ip FROM (
+			SELECT host AS ip FROM scan_results WHERE created_at > $1
+			UNION
+			SELECT unnest(ip_addresses) FROM subdomain_results WHERE created_at > $1
+		) c
+		LEFT JOIN ip_reputation r ON r.ip = c.ip
+		WHERE c.ip ~ '^[0-9a-fA-F:.]+$' AND (r.ip IS NULL OR r.checked_at < $2)
+		ORDER BY r.checked_at NULLS FIRST
+		LIMIT $3
+	`, since, time.Now().Add(-e.cfg.Refresh), e.cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IPs: %w", err)
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		if net.ParseIP(ip) != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, rows.Err()
+}
+
+func (e *Enricher) store(ctx context.Context, rep *models.IPReputation) error {
+	_, err := e.db.Pool.Exec(ctx, `
+		INSERT INTO ip_reputation (ip, malicious, spamhaus_lists, abuse_score, abuse_reports,
+			abuse_last_reported_at, country, isp, usage_type, error, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (ip) DO UPDATE SET
+			malicious = EXCLUDED.malicious, spamhaus_lists = EXCLUDED.spamhaus_lists,
+			abuse_score = EXCLUDED.abuse_score, abuse_reports = EXCLUDED.abuse_reports,
+			abuse_last_reported_at = EXCLUDED.abuse_last_reported_at, country = EXCLUDED.country,
+			isp = EXCLUDED.isp, usage_type = EXCLUDED.usage_type, error = EXCLUDED.error,
+			checked_at = EXCLUDED.checked_at
+	`, rep.IP, rep.Malicious, rep.SpamhausLists, rep.AbuseScore, rep.AbuseReports,
+		rep.AbuseLastReportedAt, rep.Country, rep.ISP, rep.UsageType, rep.Error, rep.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store reputation of %s: %w", rep.IP, err)
+	}
+	return nil
+}
+
+// spamhaus looks ip up in the DNSBL zone; NXDOMAIN means it is not listed
+func (e *Enricher) spamhaus(ctx context.Context, ip net.IP) ([]string, error) {
+	v4 := ip.To4()
+	name := fmt.Sprintf("%d.%d.%d.%d.%s", v4[3], v4[2], v4[1], v4[0], e.cfg.SpamhausZone)
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := e.resolver.LookupHost(lookupCtx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{}, nil
+		}
+		return []string{}, err
+	}
+
+	lists := []string{}
+	seen := map[string]bool{}
+	for _, addr := range addrs {
+		if strings.HasPrefix(addr, "127.255.255.") {
+			// Spamhaus refuses queries from public resolvers and over-quota users
+			return []string{}, fmt.Errorf("query refused (%s), use your own resolver or a DQS key", addr)
+		}
+		list, ok := spamhausLists[addr]
+		if !ok {
+			list = addr
+		}
+		if !seen[list] {
+			seen[list] = true
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
+
+type abuseIPDBResponse struct {
+	Data struct {
+		AbuseConfidenceScore int        `json:"abuseConfidenceScore"`
+		TotalReports         int        `json:"totalReports"`
+		LastReportedAt       *time.Time `json:"lastReportedAt"`
+		CountryCode          *string    `json:"countryCode"`
+		ISP                  *string    `json:"isp"`
+		UsageType            *string    `json:"usageType"`
+	} `json:"data"`
+}
+
+// abuseIPDB fills the AbuseIPDB fields of rep
+func (e *Enricher) abuseIPDB(ctx context.Context, ip string, rep *models.IPReputation) error {
+	query := url.Values{"ipAddress": {ip}, "maxAgeInDays": {"90"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, abuseIPDBURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Key", e.cfg.AbuseIPDBKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := time.Hour
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		e.mu.Lock()
+		e.abusePausedTo = time.Now().Add(wait)
+		e.mu.Unlock()
+		return ErrRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body abuseIPDBResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	rep.AbuseScore = &body.Data.AbuseConfidenceScore
+	rep.AbuseReports = &body.Data.TotalReports
+	rep.AbuseLastReportedAt = body.Data.LastReportedAt
+	rep.Country = body.Data.CountryCode
+	rep.ISP = body.Data.ISP
+	rep.UsageType = body.Data.UsageType
+	return nil
+}
+
+func (e *Enricher) abusePausedUntil() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.abusePausedTo
+}
+
+// Lookup returns the stored reputation of the given IPs, keyed by IP
+func Lookup(ctx context.Context, db *database.Database, ips []string) (map[string]*models.IPReputation, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+Columns+` FROM ip_reputation WHERE ip = ANY($1)`, ips)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reputations := map[string]*models.IPReputation{}
+	for rows.Next() {
+		rep, err := ScanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		reputations[rep.IP] = rep
+	}
+	return reputations, rows.Err()
+}
+
+// Columns lists the ip_reputation columns in the order ScanRow reads them
+const Columns = `ip, malicious, spamhaus_lists, abuse_score, abuse_reports, abuse_last_reported_at,
+	country, isp, usage_type, error, checked_at`
+
+// ScanRow reads a row selected with Columns
+func ScanRow(row interface{ Scan(...interface{}) error }) (*models.IPReputation, error) {
+	var rep models.IPReputation
+	err := row.Scan(&rep.IP, &rep.Malicious, &rep.SpamhausLists, &rep.AbuseScore, &rep.AbuseReports,
+		&rep.AbuseLastReportedAt, &rep.Country, &rep.ISP, &rep.UsageType, &rep.Error, &rep.CheckedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// isPublic reports whether ip is routable on the internet; reputation
+// services know nothing about private ranges
+func isPublic(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || ip.Equal(net.IPv4bcast))
+}
+
+func scoreString(score *int) string {
+	if score == nil {
+		return "n/a"
+	}
+	return strconv.Itoa(*score)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
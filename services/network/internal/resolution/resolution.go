@@ -0,0 +1,296 @@
+// Package resolution checks the addresses nmap and masscan scanned against
+// what recon resolved the same names to. A name scanned at an address recon
+// never saw for it points to stale DNS (or split-horizon DNS), and an address
+// of a CDN means the CDN's edge was scanned, not the server behind it.
+package resolution
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// Statuses of a check
+const (
+	StatusConsistent = "consistent"
+	StatusStaleDNS   = "stale_dns"
+	StatusCDN        = "cdn"
+)
+
+// maxHostnames is how many of the names resolving to an address a check lists
+const maxHostnames = 20
+
+// cdnRanges are the published IPv4 ranges of CDNs whose edges answer for
+// many sites
+var cdnRanges = map[string][]string{
+	"Cloudflare": {
+		"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22", "141.101.64.0/18",
+		"108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20", "197.234.240.0/22", "198.41.128.0/17",
+		"162.158.0.0/15", "104.16.0.0/13", "104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	},
+	"Fastly": {
+		"23.235.32.0/20", "43.249.72.0/22", "103.244.50.0/24", "103.245.222.0/23", "103.245.224.0/24",
+		"104.156.80.0/20", "140.248.64.0/18", "140.248.128.0/17", "146.75.0.0/17", "151.101.0.0/16",
+		"157.52.64.0/18", "167.82.0.0/17", "172.111.64.0/18", "185.31.16.0/22", "199.27.72.0/21",
+		"199.232.0.0/16",
+	},
+}
+
+// cdnSuffixes are the domains of CDN edges, found in CNAMEs and reverse DNS
+var cdnSuffixes = map[string]string{
+	"cloudfront.net":         "CloudFront",
+	"akamaiedge.net":         "Akamai",
+	"akamai.net":             "Akamai",
+	"akamaitechnologies.com": "Akamai",
+	"edgekey.net":            "Akamai",
+	"edgesuite.net":          "Akamai",
+	"fastly.net":             "Fastly",
+	"fastlylb.net":           "Fastly",
+	"cdn.cloudflare.net":     "Cloudflare",
+	"azureedge.net":          "Azure CDN",
+	"azurefd.net":            "Azure Front Door",
+	"edgecastcdn.net":        "Edgecast",
+	"b-cdn.net":              "Bunny CDN",
+	"cdn77.org":              "CDN77",
+	"incapdns.net":           "Imperva",
+	"stackpathdns.com":       "StackPath",
+}
+
+var cdnNets = parseRanges(cdnRanges)
+
+type cdnNet struct {
+	name string
+	net  *net.IPNet
+}
+
+func parseRanges(ranges map[string][]string) []cdnNet {
+	var nets []cdnNet
+	for name, cidrs := range ranges {
+		for _, cidr := range cidrs {
+			if _, n, err := net.ParseCIDR(cidr); err == nil {
+				nets = append(nets, cdnNet{name: name, net: n})
+			}
+		}
+	}
+	return nets
+}
+
+// CDN returns the CDN an address or name belongs to, "" when none
+func CDN(ip string, names ...string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, n := range cdnNets {
+			if n.net.Contains(parsed) {
+				return n.name
+			}
+		}
+	}
+	for _, name := range names {
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		for suffix, cdn := range cdnSuffixes {
+			if name == suffix || strings.HasSuffix(name, "."+suffix) {
+				return cdn
+			}
+		}
+	}
+	return ""
+}
+
+// Host is a scanned address and its hostname. Targeted tells a name given as
+// a scan target, which the scanner resolved, from a reverse DNS name.
+type Host struct {
+	IP       string
+	Hostname string
+	Targeted bool
+}
+
+// Hosts returns the hosts of a scan's results; target is the scan's target
+// list, which tells the names the scanner resolved
+func Hosts(target string, results []models.ScanResult) []Host {
+	targeted := map[string]bool{}
+	for _, part := range strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if u, err := url.Parse(part); err == nil && u.Host != "" {
+			part = u.Hostname()
+		} else if host, _, err := net.SplitHostPort(part); err == nil {
+			part = host
+		}
+		targeted[strings.ToLower(strings.TrimSuffix(part, "."))] = true
+	}
+
+	hosts := make([]Host, 0, len(results))
+	for _, result := range results {
+		h := Host{IP: result.Host}
+		if result.Hostname != nil {
+			h.Hostname = strings.TrimSuffix(*result.Hostname, ".")
+			h.Targeted = targeted[strings.ToLower(h.Hostname)]
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// resolved is recon's latest resolution of a name
+type resolved struct {
+	ips    []string
+	cnames []string
+	at     time.Time
+}
+
+// Check compares each host with recon's resolutions, by address. Hosts recon
+// knows nothing about, by address or name, are left out.
+func Check(ctx context.Context, db *database.Database, hosts []Host) (map[string]*models.ResolutionCheck, error) {
+	checks := map[string]*models.ResolutionCheck{}
+	// Recon is a separate service; without its tables there is nothing to compare
+	var hasRecon bool
+	if err := db.Pool.QueryRow(ctx, `SELECT to_regclass('subdomain_results') IS NOT NULL AND to_regclass('dns_results') IS NOT NULL`).Scan(&hasRecon); err != nil || !hasRecon {
+		return checks, err
+	}
+
+	ips := make([]string, 0, len(hosts))
+	var names []string
+	for _, h := range hosts {
+		ips = append(ips, h.IP)
+		if h.Targeted && h.Hostname != "" && net.ParseIP(h.Hostname) == nil {
+			names = append(names, strings.ToLower(h.Hostname))
+		}
+	}
+
+	byIP, err := namesByIP(ctx, db, ips)
+	if err != nil {
+		return nil, err
+	}
+	byName, err := resolutions(ctx, db, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range hosts {
+		var r *resolved
+		if h.Targeted {
+			r = byName[strings.ToLower(h.Hostname)]
+		}
+		if check := compare(h, byIP[h.IP], r); check != nil {
+			checks[h.IP] = check
+		}
+	}
+	return checks, nil
+}
+
+// compare checks a host against the names recon resolved to its address and
+// recon's resolution of the name it was scanned by (nil for reverse DNS names)
+func compare(h Host, names []string, r *resolved) *models.ResolutionCheck {
+	hostname := strings.ToLower(h.Hostname)
+	if len(names) == 0 && r == nil {
+		return nil
+	}
+
+	check := &models.ResolutionCheck{Hostnames: names}
+	if r != nil && !contains(names, hostname) {
+		check.Hostnames = append([]string{hostname}, names...)
+	}
+	if r != nil {
+		check.ReconIPs, check.CNAMEs = r.ips, r.cnames
+		at := r.at
+		check.ResolvedAt = &at
+	}
+
+	var cnames []string
+	if r != nil {
+		cnames = r.cnames
+	}
+	check.CDN = CDN(h.IP, append([]string{hostname}, cnames...)...)
+
+	switch {
+	case check.CDN != "":
+		check.Status = StatusCDN
+		check.Note = fmt.Sprintf("%s is a %s edge: the ports and services found are the CDN's, not those of the origin server", h.IP, check.CDN)
+	case r != nil && !contains(r.ips, h.IP):
+		check.Status = StatusStaleDNS
+		check.Note = fmt.Sprintf("%s was scanned at %s, but recon resolved it to %s on %s: DNS changed since, or resolves differently from the scanner",
+			hostname, h.IP, strings.Join(r.ips, ", "), r.at.Format("2006-01-02"))
+		if len(r.ips) == 0 {
+			check.Note = fmt.Sprintf("%s was scanned at %s, but recon could not resolve it on %s", hostname, h.IP, r.at.Format("2006-01-02"))
+		}
+	default:
+		check.Status = StatusConsistent
+		check.Note = fmt.Sprintf("Recon resolved %s to %s", strings.Join(check.Hostnames, ", "), h.IP)
+	}
+	return check
+}
+
+// namesByIP returns the names recon's latest resolutions point to each of ips
+func namesByIP(ctx context.Context, db *database.Database, ips []string) (map[string][]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (lower(subdomain)) lower(subdomain) AS name, COALESCE(ip_addresses, '{}') AS ips
+			FROM subdomain_results WHERE wildcard IS NULL AND ip_addresses && $1
+			ORDER BY lower(subdomain), created_at DESC
+		)
+		SELECT ip, name FROM latest, unnest(ips) AS ip WHERE ip = ANY($1) ORDER BY ip, name
+	`, ips)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string][]string{}
+	for rows.Next() {
+		var ip, name string
+		if err := rows.Scan(&ip, &name); err != nil {
+			return nil, err
+		}
+		if len(names[ip]) < maxHostnames {
+			names[ip] = append(names[ip], name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// resolutions returns recon's latest resolution of each of names, from
+// subdomain enumeration or DNS lookups, whichever is newer
+func resolutions(ctx context.Context, db *database.Database, names []string) (map[string]*resolved, error) {
+	byName := map[string]*resolved{}
+	if len(names) == 0 {
+		return byName, nil
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (name) name, ips, cnames, created_at FROM (
+			SELECT lower(subdomain) AS name, COALESCE(ip_addresses, '{}') AS ips, '{}'::text[] AS cnames, created_at
+			FROM subdomain_results WHERE wildcard IS NULL AND lower(subdomain) = ANY($1)
+			UNION ALL
+			SELECT lower(domain), COALESCE(a_records, '{}') || COALESCE(aaaa_records, '{}'), COALESCE(cname_records, '{}'), created_at
+			FROM dns_results WHERE lower(domain) = ANY($1)
+		) r ORDER BY name, created_at DESC
+	`, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		r := &resolved{}
+		if err := rows.Scan(&name, &r.ips, &r.cnames, &r.at); err != nil {
+			return nil, err
+		}
+		sort.Strings(r.ips)
+		byName[name] = r
+	}
+	return byName, rows.Err()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// advancedFlags are the flags each tool accepts in a scan's advanced
+// options: packet crafting, interfaces and retries the API has no field
+// for. Nothing that reads or writes files on the scanner host. true means
+// the flag takes a value.
+var advancedFlags = map[string]map[string]bool{
+	"nmap": {
+		"--data-length": true, "--data-string": true, "--ttl": true, "-g": true, "--source-port": true,
+		"-f": false, "--mtu": true, "--badsum": false, "--ip-options": true, "-S": true, "-e": true,
+		"--spoof-mac": true, "-D": true, "--proxies": true, "--send-eth": false, "--send-ip": false,
+		"--privileged": false, "--unprivileged": false, "--dns-servers": true, "--system-dns": false,
+		"--defeat-rst-ratelimit": false, "--defeat-icmp-ratelimit": false, "--nsock-engine": true,
+		"--max-os-tries": true, "--script-timeout": true,
+	},
+	"masscan": {
+		"--banners": false, "--retries": true, "--ttl": true, "--wait": true, "--source-ip": true,
+		"--source-port": true, "--adapter": true, "-e": true, "--adapter-ip": true, "--adapter-mac": true,
+		"--router-mac": true, "--randomize-hosts": false, "--seed": true, "--ping": false,
+		"--exclude": true, "--connection-timeout": true, "--http-user-agent": true,
+	},
+	"naabu": {
+		"-retries": true, "-timeout": true, "-warm-up-time": true, "-source-ip": true, "-interface": true,
+		"-exclude-hosts": true, "-exclude-cdn": false, "-ping": false, "-Pn": false, "-stream": false,
+	},
+}
+
+// advancedEnv are the environment variables each tool accepts
+var advancedEnv = map[string]map[string]bool{
+	"nmap":    {"NMAP_PRIVILEGED": true, "NMAP_UNPRIVILEGED": true},
+	"masscan": {},
+	"naabu":   {},
+}
+
+// AdvancedAllowList returns the flags and environment variables scanner
+// accepts in advanced options, sorted
+func AdvancedAllowList(scanner string) (flags []string, env []string) {
+	for flag := range advancedFlags[scanner] {
+		flags = append(flags, flag)
+	}
+	for name := range advancedEnv[scanner] {
+		env = append(env, name)
+	}
+	sort.Strings(flags)
+	sort.Strings(env)
+	return flags, env
+}
+
+// ValidateAdvanced checks a scan's advanced options against the scanner's
+// allow-lists. It returns the flags as tool arguments and the environment
+// as "KEY=value" pairs.
+func ValidateAdvanced(scanner string, opts *models.AdvancedOptions) (args []string, env []string, err error) {
+	if opts == nil || (len(opts.Flags) == 0 && len(opts.Env) == 0) {
+		return nil, nil, nil
+	}
+	flags, ok := advancedFlags[scanner]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s scans have no advanced options", scanner)
+	}
+
+	for i := 0; i < len(opts.Flags); i++ {
+		arg := opts.Flags[i]
+		if arg == "" || strings.IndexFunc(arg, unicode.IsSpace) >= 0 || strings.IndexFunc(arg, unicode.IsControl) >= 0 {
+			return nil, nil, fmt.Errorf("flag %q must be a single word; pass values as separate items", arg)
+		}
+		flag, _, hasValue := strings.Cut(arg, "=")
+		takesValue, allowed := flags[flag]
+		if !allowed {
+			return nil, nil, fmt.Errorf("flag %s is not allowed for %s", flag, scanner)
+		}
+		args = append(args, arg)
+		if takesValue && !hasValue {
+			if i+1 >= len(opts.Flags) {
+				return nil, nil, fmt.Errorf("flag %s requires a value", flag)
+			}
+			i++
+			value := opts.Flags[i]
+			if value == "" || strings.IndexFunc(value, unicode.IsSpace) >= 0 || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+				return nil, nil, fmt.Errorf("value %q of %s must be a single word", value, flag)
+			}
+			args = append(args, value)
+		}
+	}
+
+	names := make([]string, 0, len(opts.Env))
+	for name := range opts.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !advancedEnv[scanner][name] {
+			return nil, nil, fmt.Errorf("environment variable %s is not allowed for %s", name, scanner)
+		}
+		value := opts.Env[name]
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return nil, nil, fmt.Errorf("environment variable %s has control characters", name)
+		}
+		env = append(env, name+"="+value)
+	}
+	return args, env, nil
+}
@@ -0,0 +1,12 @@
+package scanner
+
+import "github.com/security-scanner/shared/pkg/supervise"
+
+// attemptLog returns the scan log entry of a supervised run of tool: info
+// when it succeeded, warning when it failed
+func attemptLog(tool string, a supervise.Attempt) (string, string) {
+	if a.Err == nil {
+		return "info", tool + " " + a.String()
+	}
+	return "warning", tool + " " + a.String()
+}
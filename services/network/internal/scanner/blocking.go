@@ -0,0 +1,214 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Ullaakut/nmap/v3"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+const (
+	// blockSilence is how many probes in a row a host that answered before
+	// may leave unanswered before its canary port is checked again
+	blockSilence = 30
+	// blockCanaryTries is how many times the canary port is probed before
+	// the host counts as blocking, so one lost packet doesn't flag it
+	blockCanaryTries = 2
+	// nmapCanaryTimeout is the canary timeout after nmap runs
+	nmapCanaryTimeout = 3 * time.Second
+)
+
+// BlockDetector notices hosts that stop answering mid-scan. Each host
+// remembers a port that answered; after blockSilence unanswered probes that
+// port is probed again. A host that no longer answers there is blocking the
+// scanner rather than filtering the ports probed since.
+type BlockDetector struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	hosts   map[string]*hostSilence
+	status  *models.BlockedStatus
+}
+
+type hostSilence struct {
+	canary   int       // a port that answered, 0 before any did
+	silent   int       // unanswered probes since the last answer
+	since    time.Time // when the first of them was sent
+	checking bool
+	blocked  bool
+}
+
+// NewBlockDetector returns a detector probing canary ports with timeout
+func NewBlockDetector(timeout time.Duration) *BlockDetector {
+	return &BlockDetector{timeout: timeout, hosts: map[string]*hostSilence{}}
+}
+
+// Observe records the outcome of a probe to ip:port sent at sent. It returns
+// the scan's blocked status when this probe confirmed a newly blocked host,
+// and nil otherwise.
+func (d *BlockDetector) Observe(ctx context.Context, ip string, port int, answered bool, sent time.Time) *models.BlockedStatus {
+	d.mu.Lock()
+	host, ok := d.hosts[ip]
+	if !ok {
+		host = &hostSilence{}
+		d.hosts[ip] = host
+	}
+	if host.blocked {
+		d.mu.Unlock()
+		return nil
+	}
+	if answered {
+		host.canary = port
+		host.silent = 0
+		d.mu.Unlock()
+		return nil
+	}
+	if host.canary == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if host.silent == 0 || sent.Before(host.since) {
+		host.since = sent
+	}
+	host.silent++
+	if host.silent < blockSilence || host.checking {
+		d.mu.Unlock()
+		return nil
+	}
+	host.checking = true
+	canary, since := host.canary, host.since
+	d.mu.Unlock()
+
+	stillAnswers := canaryAnswers(ctx, ip, canary, d.timeout)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	host.checking = false
+	if stillAnswers || ctx.Err() != nil {
+		host.silent = 0
+		return nil
+	}
+	host.blocked = true
+
+	if d.status == nil {
+		d.status = &models.BlockedStatus{Since: since, Reason: fmt.Sprintf(
+			"%s stopped answering, even on port %d that answered earlier in the scan", ip, canary)}
+	}
+	if since.Before(d.status.Since) {
+		d.status.Since = since
+	}
+	d.status.DetectedAt = time.Now()
+	d.status.Hosts = append(d.status.Hosts, ip)
+	status := *d.status
+	status.Hosts = append([]string{}, d.status.Hosts...)
+	return &status
+}
+
+// canaryAnswers reports whether a TCP port answers (accepts or refuses the
+// connection) within timeout on any of blockCanaryTries attempts
+func canaryAnswers(ctx context.Context, ip string, port int, timeout time.Duration) bool {
+	dialer := net.Dialer{Timeout: timeout}
+	for i := 0; i < blockCanaryTries && ctx.Err() == nil; i++ {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+	}
+	return false
+}
+
+// NmapBlocked looks for the same pattern in an nmap run, whose report has no
+// per-probe times: the hosts scanned after the last one that answered all
+// went silent (down, or every port filtered without a response), and an
+// open TCP port found earlier no longer answers now.
+func NmapBlocked(ctx context.Context, result *nmap.Run) *models.BlockedStatus {
+	var hosts []nmap.Host
+	for _, host := range result.Hosts {
+		if len(host.Addresses) > 0 && !time.Time(host.StartTime).IsZero() {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return time.Time(hosts[i].StartTime).Before(time.Time(hosts[j].StartTime))
+	})
+
+	lastAnswered, canaryHost, canaryPort := -1, -1, 0
+	for i, host := range hosts {
+		if !nmapHostAnswered(host) {
+			continue
+		}
+		lastAnswered = i
+		for _, port := range host.Ports {
+			if port.Protocol == "tcp" && port.State.State == "open" {
+				canaryHost, canaryPort = i, int(port.ID)
+				break
+			}
+		}
+	}
+	if canaryHost < 0 || lastAnswered == len(hosts)-1 {
+		return nil
+	}
+
+	ip := hosts[canaryHost].Addresses[0].Addr
+	if canaryAnswers(ctx, ip, canaryPort, nmapCanaryTimeout) || ctx.Err() != nil {
+		return nil
+	}
+
+	status := &models.BlockedStatus{
+		Since:      time.Time(hosts[lastAnswered+1].StartTime),
+		DetectedAt: time.Now(),
+		Hosts:      []string{},
+		Reason: fmt.Sprintf("hosts scanned after %s stopped answering, and port %d of %s that was open no longer answers",
+			hosts[lastAnswered].Addresses[0].Addr, canaryPort, ip),
+	}
+	for _, host := range hosts[lastAnswered+1:] {
+		status.Hosts = append(status.Hosts, host.Addresses[0].Addr)
+	}
+	return status
+}
+
+// nmapHostAnswered reports whether any TCP probe to host got a response
+func nmapHostAnswered(host nmap.Host) bool {
+	if host.Status.State != "up" {
+		return false
+	}
+	for _, port := range host.Ports {
+		if port.Protocol != "tcp" {
+			continue
+		}
+		switch port.State.State {
+		case "open", "closed", "unfiltered":
+			return true
+		}
+	}
+	for _, extra := range host.ExtraPorts {
+		if extra.State == "closed" || extra.State == "unfiltered" {
+			return true
+		}
+	}
+	return false
+}
+
+// recordBlocked stores the blocked status of a scan
+func recordBlocked(ctx context.Context, db *database.Database, scanID uuid.UUID, status *models.BlockedStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(ctx, `UPDATE scans SET possibly_blocked = $1 WHERE id = $2`, data, scanID)
+	return err
+}
@@ -5,20 +5,31 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/dnswildcard"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type DNSScanner struct {
 	db          *database.Database
 	cancelFuncs map[string]context.CancelFunc
 	resolver    *net.Resolver
+	// endpoints are the DoH/DoT resolvers of scans that don't choose
+	// their own; none means the system resolver
+	endpoints []securedns.Endpoint
+	// wordlistsPath is the directory of the wordlists scans can choose
+	wordlistsPath string
 }
 
+// scanResolverKey carries the resolver of a scan that uses DoH/DoT
+type scanResolverKey struct{}
+
 // DNSRecord represents a DNS record
 type DNSRecord struct {
 	Type  string `json:"type"`
@@ -36,6 +47,15 @@ type DNSScanResult struct {
 	MXRecords    []string    `json:"mx_records,omitempty"`
 	TXTRecords   []string    `json:"txt_records,omitempty"`
 	ZoneTransfer bool        `json:"zone_transfer_possible"`
+
+	// Wildcard DNS: the addresses any name under the domain resolves to,
+	// and how many brute-forced subdomains were dropped for only getting them
+	WildcardAddresses []string `json:"wildcard_addresses,omitempty"`
+	WildcardFiltered  int      `json:"wildcard_filtered,omitempty"`
+
+	// Brute force: the wordlist and how many of its names were looked up
+	Wordlist     string `json:"wordlist,omitempty"`
+	WordsChecked int    `json:"words_checked,omitempty"`
 }
 
 func NewDNSScanner(db *database.Database) *DNSScanner {
@@ -52,8 +72,24 @@ func NewDNSScanner(db *database.Database) *DNSScanner {
 	}
 }
 
-// ExecuteScan runs a DNS scan on the target domain
-func (s *DNSScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, domain string, scanType string) error {
+// SetResolvers sends the queries of scans that don't choose their own
+// resolvers to these DoH/DoT endpoints
+func (s *DNSScanner) SetResolvers(endpoints []securedns.Endpoint) {
+	s.endpoints = endpoints
+}
+
+// resolverFor returns the resolver of the scan running in ctx
+func (s *DNSScanner) resolverFor(ctx context.Context) *net.Resolver {
+	if r, ok := ctx.Value(scanResolverKey{}).(*net.Resolver); ok {
+		return r
+	}
+	return s.resolver
+}
+
+// ExecuteScan runs a DNS scan on the target domain. Its queries go to
+// resolvers over DoH/DoT, or to the service's when nil; brute sets how
+// subdomains are brute forced (nil: the built-in list).
+func (s *DNSScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, domain string, scanType string, resolvers []securedns.Endpoint, brute *DNSBruteForce) error {
 	log.Printf("🔍 Starting DNS scan %s on domain: %s type: %s", scanID, domain, scanType)
 
 	// Create cancellable context
@@ -71,17 +107,35 @@ func (s *DNSScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, domain s
 
 	s.addLog(ctx, scanID, "info", fmt.Sprintf("Starting DNS scan on domain: %s", domain))
 
+	if resolvers == nil {
+		resolvers = s.endpoints
+	}
+	var secure *securedns.Resolver
+	if len(resolvers) > 0 {
+		secure = securedns.New(resolvers, 0)
+		ctx = context.WithValue(ctx, scanResolverKey{}, secure.NetResolver())
+		names := make([]string, len(resolvers))
+		for i, r := range resolvers {
+			names[i] = r.String()
+		}
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Resolving over DoH/DoT through %s", strings.Join(names, ", ")))
+	}
+
+	if brute == nil {
+		brute = &DNSBruteForce{Concurrency: dnsDefaultConcurrency}
+	}
+
 	var dnsResult DNSScanResult
 	dnsResult.Domain = domain
 
 	// Perform different DNS queries based on scan type
 	switch scanType {
 	case "dns_full", "dns_comprehensive":
-		s.performFullDNSScan(ctx, scanID, domain, &dnsResult)
+		s.performFullDNSScan(ctx, scanID, domain, brute, &dnsResult)
 	case "dns_records":
 		s.performRecordsScan(ctx, scanID, domain, &dnsResult)
 	case "dns_subdomain":
-		s.performSubdomainEnum(ctx, scanID, domain, &dnsResult)
+		s.performSubdomainEnum(ctx, scanID, domain, brute, &dnsResult)
 	default:
 		s.performRecordsScan(ctx, scanID, domain, &dnsResult)
 	}
@@ -92,6 +146,10 @@ func (s *DNSScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, domain s
 		return nil
 	}
 
+	if secure != nil {
+		s.reportResolvers(ctx, scanID, secure)
+	}
+
 	// Store results as ScanResult
 	result := s.convertToScanResult(scanID, domain, &dnsResult)
 	if err := s.storeResult(ctx, result); err != nil {
@@ -109,7 +167,7 @@ func (s *DNSScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, domain s
 	return nil
 }
 
-func (s *DNSScanner) performFullDNSScan(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
+func (s *DNSScanner) performFullDNSScan(ctx context.Context, scanID uuid.UUID, domain string, brute *DNSBruteForce, result *DNSScanResult) {
 	s.addLog(ctx, scanID, "info", "Performing full DNS scan")
 
 	// A records
@@ -140,8 +198,8 @@ func (s *DNSScanner) performFullDNSScan(ctx context.Context, scanID uuid.UUID, d
 	s.querySOARecord(ctx, scanID, domain, result)
 	s.updateScanStatus(ctx, scanID, "running", 90, nil)
 
-	// Common subdomains check
-	s.checkCommonSubdomains(ctx, scanID, domain, result)
+	// Subdomain brute force
+	s.bruteForceSubdomains(ctx, scanID, domain, brute, 90, result)
 }
 
 func (s *DNSScanner) performRecordsScan(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
@@ -163,13 +221,13 @@ func (s *DNSScanner) performRecordsScan(ctx context.Context, scanID uuid.UUID, d
 	s.updateScanStatus(ctx, scanID, "running", 90, nil)
 }
 
-func (s *DNSScanner) performSubdomainEnum(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
+func (s *DNSScanner) performSubdomainEnum(ctx context.Context, scanID uuid.UUID, domain string, brute *DNSBruteForce, result *DNSScanResult) {
 	s.addLog(ctx, scanID, "info", "Performing subdomain enumeration")
-	s.checkCommonSubdomains(ctx, scanID, domain, result)
+	s.bruteForceSubdomains(ctx, scanID, domain, brute, 0, result)
 }
 
 func (s *DNSScanner) queryARecords(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	ips, err := s.resolver.LookupIP(ctx, "ip4", domain)
+	ips, err := s.resolverFor(ctx).LookupIP(ctx, "ip4", domain)
 	if err != nil {
 		s.addLog(ctx, scanID, "warning", fmt.Sprintf("A record lookup failed: %v", err))
 		return
@@ -185,7 +243,7 @@ func (s *DNSScanner) queryARecords(ctx context.Context, scanID uuid.UUID, domain
 }
 
 func (s *DNSScanner) queryAAAARecords(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	ips, err := s.resolver.LookupIP(ctx, "ip6", domain)
+	ips, err := s.resolverFor(ctx).LookupIP(ctx, "ip6", domain)
 	if err != nil {
 		s.addLog(ctx, scanID, "warning", fmt.Sprintf("AAAA record lookup failed: %v", err))
 		return
@@ -201,7 +259,7 @@ func (s *DNSScanner) queryAAAARecords(ctx context.Context, scanID uuid.UUID, dom
 }
 
 func (s *DNSScanner) queryMXRecords(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	mxRecords, err := s.resolver.LookupMX(ctx, domain)
+	mxRecords, err := s.resolverFor(ctx).LookupMX(ctx, domain)
 	if err != nil {
 		s.addLog(ctx, scanID, "warning", fmt.Sprintf("MX record lookup failed: %v", err))
 		return
@@ -218,7 +276,7 @@ func (s *DNSScanner) queryMXRecords(ctx context.Context, scanID uuid.UUID, domai
 }
 
 func (s *DNSScanner) queryNSRecords(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	nsRecords, err := s.resolver.LookupNS(ctx, domain)
+	nsRecords, err := s.resolverFor(ctx).LookupNS(ctx, domain)
 	if err != nil {
 		s.addLog(ctx, scanID, "warning", fmt.Sprintf("NS record lookup failed: %v", err))
 		return
@@ -235,7 +293,7 @@ func (s *DNSScanner) queryNSRecords(ctx context.Context, scanID uuid.UUID, domai
 }
 
 func (s *DNSScanner) queryTXTRecords(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	txtRecords, err := s.resolver.LookupTXT(ctx, domain)
+	txtRecords, err := s.resolverFor(ctx).LookupTXT(ctx, domain)
 	if err != nil {
 		s.addLog(ctx, scanID, "warning", fmt.Sprintf("TXT record lookup failed: %v", err))
 		return
@@ -257,7 +315,7 @@ func (s *DNSScanner) queryTXTRecords(ctx context.Context, scanID uuid.UUID, doma
 }
 
 func (s *DNSScanner) queryCNAMERecord(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	cname, err := s.resolver.LookupCNAME(ctx, domain)
+	cname, err := s.resolverFor(ctx).LookupCNAME(ctx, domain)
 	if err != nil {
 		return // CNAME errors are common, don't log
 	}
@@ -273,7 +331,7 @@ func (s *DNSScanner) queryCNAMERecord(ctx context.Context, scanID uuid.UUID, dom
 
 func (s *DNSScanner) querySOARecord(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
 	// SOA lookup using net package
-	_, err := net.LookupNS(domain)
+	_, err := s.resolverFor(ctx).LookupNS(ctx, domain)
 	if err == nil {
 		result.Records = append(result.Records, DNSRecord{
 			Type:  "SOA",
@@ -283,31 +341,62 @@ func (s *DNSScanner) querySOARecord(ctx context.Context, scanID uuid.UUID, domai
 	}
 }
 
-func (s *DNSScanner) checkCommonSubdomains(ctx context.Context, scanID uuid.UUID, domain string, result *DNSScanResult) {
-	commonSubdomains := []string{
-		"www", "mail", "ftp", "localhost", "webmail", "smtp", "pop", "ns1", "ns2",
-		"dns", "dns1", "dns2", "mx", "mx1", "mx2", "api", "dev", "staging", "test",
-		"admin", "portal", "blog", "shop", "store", "app", "mobile", "m", "static",
-		"cdn", "media", "images", "img", "assets", "js", "css", "vpn", "remote",
-		"gateway", "proxy", "firewall", "router", "server", "web", "www2", "secure",
-		"login", "auth", "sso", "id", "account", "accounts", "my", "dashboard",
-		"cp", "cpanel", "panel", "control", "manage", "manager", "support", "help",
-		"docs", "doc", "documentation", "wiki", "kb", "knowledge", "forum", "forums",
-		"community", "chat", "irc", "slack", "teams", "meet", "zoom", "video",
-		"git", "gitlab", "github", "bitbucket", "svn", "repo", "repository",
-		"jenkins", "ci", "cd", "build", "deploy", "release", "stage", "prod",
-		"production", "development", "qa", "uat", "sandbox", "demo", "preview",
+// dnsCommonSubdomains are the names checked by subdomain enumeration
+var dnsCommonSubdomains = []string{
+	"www", "mail", "ftp", "localhost", "webmail", "smtp", "pop", "ns1", "ns2",
+	"dns", "dns1", "dns2", "mx", "mx1", "mx2", "api", "dev", "staging", "test",
+	"admin", "portal", "blog", "shop", "store", "app", "mobile", "m", "static",
+	"cdn", "media", "images", "img", "assets", "js", "css", "vpn", "remote",
+	"gateway", "proxy", "firewall", "router", "server", "web", "www2", "secure",
+	"login", "auth", "sso", "id", "account", "accounts", "my", "dashboard",
+	"cp", "cpanel", "panel", "control", "manage", "manager", "support", "help",
+	"docs", "doc", "documentation", "wiki", "kb", "knowledge", "forum", "forums",
+	"community", "chat", "irc", "slack", "teams", "meet", "zoom", "video",
+	"git", "gitlab", "github", "bitbucket", "svn", "repo", "repository",
+	"jenkins", "ci", "cd", "build", "deploy", "release", "stage", "prod",
+	"production", "development", "qa", "uat", "sandbox", "demo", "preview",
+}
+
+// bruteForceSubdomains looks up the words of the scan's wordlist under
+// domain, moving the scan's progress from `from` to 100 and logging it every
+// 10%
+func (s *DNSScanner) bruteForceSubdomains(ctx context.Context, scanID uuid.UUID, domain string, brute *DNSBruteForce, from int, result *DNSScanResult) {
+	words, source, err := s.words(brute)
+	if err != nil {
+		s.addLog(ctx, scanID, "error", fmt.Sprintf("Subdomain brute force skipped: %v", err))
+		return
 	}
+	result.Wordlist = source
 
-	s.addLog(ctx, scanID, "info", fmt.Sprintf("Checking %d common subdomains", len(commonSubdomains)))
+	lookup := s.resolverFor(ctx)
+	var pool *resolverPool
+	if len(brute.ResolverPool) > 0 {
+		pool = newResolverPool(brute.ResolverPool)
+		lookup = pool.resolvers[0]
+	}
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Checking %d subdomains from %s with %d concurrent lookups%s",
+		len(words), source, brute.Concurrency, poolDescription(brute.ResolverPool)))
+
+	// With wildcard DNS every label resolves; names that only get the
+	// wildcard's addresses are left out
+	wildcards := dnswildcard.New(lookup)
+	if addrs := wildcards.Addresses(ctx, domain); len(addrs) > 0 {
+		result.WildcardAddresses = addrs
+		s.addLog(ctx, scanID, "warning", fmt.Sprintf("Wildcard DNS on *.%s (%s): subdomains resolving only to it are ignored", domain, strings.Join(addrs, ", ")))
+	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	sem := make(chan struct{}, 10) // Limit concurrent lookups
+	sem := make(chan struct{}, brute.Concurrency) // Limit concurrent lookups
+	step := len(words) / 10
+	if step == 0 {
+		step = 1
+	}
 
-	for i, sub := range commonSubdomains {
+	for i, sub := range words {
 		select {
 		case <-ctx.Done():
+			wg.Wait()
 			return
 		default:
 		}
@@ -315,13 +404,31 @@ func (s *DNSScanner) checkCommonSubdomains(ctx context.Context, scanID uuid.UUID
 		wg.Add(1)
 		sem <- struct{}{}
 
-		go func(subdomain string, idx int) {
+		resolver := lookup
+		if pool != nil {
+			resolver = pool.pick()
+		}
+		go func(subdomain string, resolver *net.Resolver) {
 			defer wg.Done()
 			defer func() { <-sem }()
 
 			fullDomain := subdomain + "." + domain
-			ips, err := s.resolver.LookupIP(ctx, "ip4", fullDomain)
+			ips, err := resolver.LookupIP(ctx, "ip4", fullDomain)
+			mu.Lock()
+			result.WordsChecked++
+			mu.Unlock()
 			if err == nil && len(ips) > 0 {
+				addrs := make([]string, len(ips))
+				for i, ip := range ips {
+					addrs[i] = ip.String()
+				}
+				if wildcards.Match(ctx, fullDomain, addrs) != "" {
+					mu.Lock()
+					result.WildcardFiltered++
+					mu.Unlock()
+					return
+				}
+
 				mu.Lock()
 				result.Subdomains = append(result.Subdomains, fullDomain)
 				result.Records = append(result.Records, DNSRecord{
@@ -332,16 +439,50 @@ func (s *DNSScanner) checkCommonSubdomains(ctx context.Context, scanID uuid.UUID
 				mu.Unlock()
 				s.addLog(ctx, scanID, "info", fmt.Sprintf("Found subdomain: %s -> %s", fullDomain, ips[0].String()))
 			}
-		}(sub, i)
+		}(sub, resolver)
 
 		// Update progress
-		if i%10 == 0 {
-			progress := 50 + (i * 50 / len(commonSubdomains))
-			s.updateScanStatus(ctx, scanID, "running", progress, nil)
+		if done := i + 1; done%step == 0 && done < len(words) {
+			s.updateScanStatus(ctx, scanID, "running", from+(done*(100-from)/len(words)), nil)
+			mu.Lock()
+			found := len(result.Subdomains)
+			mu.Unlock()
+			s.addLog(ctx, scanID, "info", fmt.Sprintf("Brute force progress: %d/%d names sent, %d subdomains found", done, len(words), found))
 		}
 	}
 
 	wg.Wait()
+
+	if result.WildcardFiltered > 0 {
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Ignored %d subdomains that only resolve through wildcard DNS", result.WildcardFiltered))
+	}
+}
+
+// poolDescription describes the resolver pool of a brute force for its log
+func poolDescription(pool []string) string {
+	if len(pool) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" across %d resolvers (%s)", len(pool), strings.Join(pool, ", "))
+}
+
+// reportResolvers logs how each DoH/DoT resolver of a scan did and stores
+// it in the scan's resolver_health
+func (s *DNSScanner) reportResolvers(ctx context.Context, scanID uuid.UUID, secure *securedns.Resolver) {
+	health := secure.Health()
+	for _, h := range health {
+		level := "info"
+		if !h.Healthy {
+			level = "warning"
+		}
+		s.addLog(ctx, scanID, level, "Resolver "+h.String())
+	}
+	if !secure.Healthy() {
+		s.addLog(ctx, scanID, "warning", "No DoH/DoT resolver answered: the results are likely incomplete")
+	}
+	if _, err := s.db.Pool.Exec(ctx, `UPDATE scans SET resolver_health = $1 WHERE id = $2`, health, scanID); err != nil {
+		log.Printf("Failed to store resolver health of scan %s: %v", scanID, err)
+	}
 }
 
 func (s *DNSScanner) convertToScanResult(scanID uuid.UUID, domain string, dnsResult *DNSScanResult) *models.ScanResult {
@@ -360,6 +501,14 @@ func (s *DNSScanner) convertToScanResult(scanID uuid.UUID, domain string, dnsRes
 		"txt_records":   dnsResult.TXTRecords,
 		"zone_transfer": dnsResult.ZoneTransfer,
 	}
+	if len(dnsResult.WildcardAddresses) > 0 {
+		extraData["wildcard_addresses"] = dnsResult.WildcardAddresses
+		extraData["wildcard_filtered"] = dnsResult.WildcardFiltered
+	}
+	if dnsResult.Wordlist != "" {
+		extraData["wordlist"] = dnsResult.Wordlist
+		extraData["words_checked"] = dnsResult.WordsChecked
+	}
 
 	return &models.ScanResult{
 		ID:          uuid.New(),
@@ -437,7 +586,7 @@ func (s *DNSScanner) GetTemplates() map[string]map[string]interface{} {
 		},
 		"dns_subdomain": {
 			"name":        "Subdomain Enumeration",
-			"description": "Discover subdomains using common wordlist",
+			"description": "Discover subdomains by brute force with the built-in or a custom wordlist",
 			"scan_type":   "dns_subdomain",
 		},
 	}
@@ -0,0 +1,285 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limits of subdomain brute forcing
+const (
+	dnsDefaultConcurrency = 10
+	dnsMaxConcurrency     = 200
+	dnsMaxWordlistEntries = 500000 // files under WORDLISTS_PATH
+	dnsMaxUploadedEntries = 100000 // lists sent with the scan, kept in its configuration
+	dnsMaxResolverPool    = 50
+)
+
+// DNSBuiltinWordlist is the name of the built-in list of common subdomains
+const DNSBuiltinWordlist = "common"
+
+// DNSBruteForce is how a DNS scan brute forces subdomains, from the
+// "wordlist", "wordlist_entries", "resolver_pool" and "concurrency" keys of
+// its configuration
+type DNSBruteForce struct {
+	// Wordlist is a file under WORDLISTS_PATH; "" or "common" is the
+	// built-in list
+	Wordlist string
+	// Entries is a list uploaded with the scan, used instead of Wordlist
+	Entries []string
+	// ResolverPool are plain DNS servers (host or host:port) the lookups
+	// are spread across, round-robin; none uses the scan's resolver
+	ResolverPool []string
+	// Concurrency is the number of lookups in flight
+	Concurrency int
+}
+
+// DNSWordlist is a wordlist available to DNS scans
+type DNSWordlist struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries,omitempty"`
+	Size    int64  `json:"size,omitempty"` // bytes, for files
+	BuiltIn bool   `json:"built_in,omitempty"`
+}
+
+// DNSBruteForceFromConfiguration reads and checks the brute force options
+// of a DNS scan's configuration; a scan without them checks the built-in
+// list through its resolver
+func DNSBruteForceFromConfiguration(configuration map[string]interface{}) (*DNSBruteForce, error) {
+	opts := &DNSBruteForce{Concurrency: dnsDefaultConcurrency}
+
+	if value, ok := configuration["wordlist"]; ok && value != nil {
+		name, ok := value.(string)
+		if !ok {
+			return nil, errors.New("wordlist must be the name of a wordlist")
+		}
+		if name != "" && (name != filepath.Base(name) || strings.HasPrefix(name, ".")) {
+			return nil, fmt.Errorf("invalid wordlist %q: use a name listed by /api/scans/wordlists", name)
+		}
+		opts.Wordlist = name
+	}
+
+	if value, ok := configuration["wordlist_entries"]; ok && value != nil {
+		entries, err := stringList(value, "wordlist_entries")
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > dnsMaxUploadedEntries {
+			return nil, fmt.Errorf("wordlist_entries has %d entries, at most %d are allowed; larger lists go in WORDLISTS_PATH", len(entries), dnsMaxUploadedEntries)
+		}
+		opts.Entries = normalizeWords(entries)
+		if len(opts.Entries) == 0 {
+			return nil, errors.New("wordlist_entries has no valid subdomain labels")
+		}
+	}
+
+	if value, ok := configuration["resolver_pool"]; ok && value != nil {
+		pool, err := stringList(value, "resolver_pool")
+		if err != nil {
+			return nil, err
+		}
+		if len(pool) > dnsMaxResolverPool {
+			return nil, fmt.Errorf("resolver_pool has %d servers, at most %d are allowed", len(pool), dnsMaxResolverPool)
+		}
+		for _, server := range pool {
+			address, err := dnsServerAddress(server)
+			if err != nil {
+				return nil, err
+			}
+			opts.ResolverPool = append(opts.ResolverPool, address)
+		}
+		if _, ok := configuration["resolvers"]; ok && len(opts.ResolverPool) > 0 {
+			return nil, errors.New("resolver_pool can't be combined with DoH/DoT resolvers")
+		}
+	}
+
+	if value, ok := configuration["concurrency"]; ok && value != nil {
+		n, ok := value.(float64)
+		if !ok || n != float64(int(n)) || n < 1 || n > dnsMaxConcurrency {
+			return nil, fmt.Errorf("concurrency must be between 1 and %d", dnsMaxConcurrency)
+		}
+		opts.Concurrency = int(n)
+	}
+	return opts, nil
+}
+
+// HasBruteForceOptions reports whether a configuration sets any of the
+// subdomain brute force options
+func HasBruteForceOptions(configuration map[string]interface{}) bool {
+	for _, key := range []string{"wordlist", "wordlist_entries", "resolver_pool", "concurrency"} {
+		if _, ok := configuration[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func stringList(value interface{}, key string) ([]string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", key)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// dnsServerAddress returns the host:port of a DNS server given as an IP,
+// with or without port
+func dnsServerAddress(server string) (string, error) {
+	server = strings.TrimSpace(server)
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = strings.Trim(server, "[]"), "53"
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid resolver_pool server %q: expected an IP address, optionally with a port", server)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port in resolver_pool server %q", server)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// normalizeWords lowercases the words of a wordlist and drops comments,
+// duplicates and words that aren't subdomain labels
+func normalizeWords(words []string) []string {
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Trim(strings.ToLower(strings.TrimSpace(word)), ".")
+		if word == "" || strings.HasPrefix(word, "#") || seen[word] || !validSubdomainWord(word) {
+			continue
+		}
+		seen[word] = true
+		normalized = append(normalized, word)
+	}
+	return normalized
+}
+
+// validSubdomainWord accepts one or more dot-separated DNS labels
+func validSubdomainWord(word string) bool {
+	if len(word) > 200 {
+		return false
+	}
+	for _, label := range strings.Split(word, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SetWordlistsPath sets the directory of the wordlists scans can choose
+func (s *DNSScanner) SetWordlistsPath(path string) {
+	s.wordlistsPath = path
+}
+
+// Wordlists lists the built-in wordlist and the .txt files of the
+// wordlists directory
+func (s *DNSScanner) Wordlists() []DNSWordlist {
+	lists := []DNSWordlist{{Name: DNSBuiltinWordlist, Entries: len(dnsCommonSubdomains), BuiltIn: true}}
+	if s.wordlistsPath == "" {
+		return lists
+	}
+	paths, _ := filepath.Glob(filepath.Join(s.wordlistsPath, "*.txt"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		lists = append(lists, DNSWordlist{Name: strings.TrimSuffix(filepath.Base(path), ".txt"), Size: info.Size()})
+	}
+	return lists
+}
+
+// words returns the words to brute force and where they come from
+func (s *DNSScanner) words(opts *DNSBruteForce) ([]string, string, error) {
+	if len(opts.Entries) > 0 {
+		return opts.Entries, "uploaded list", nil
+	}
+	if opts.Wordlist == "" || opts.Wordlist == DNSBuiltinWordlist {
+		return dnsCommonSubdomains, DNSBuiltinWordlist, nil
+	}
+	if s.wordlistsPath == "" {
+		return nil, "", errors.New("no wordlists directory is configured (WORDLISTS_PATH)")
+	}
+
+	path := filepath.Join(s.wordlistsPath, opts.Wordlist+".txt")
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(s.wordlistsPath, opts.Wordlist)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("wordlist not found: %s", opts.Wordlist)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(lines) < dnsMaxWordlistEntries {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read wordlist %s: %w", opts.Wordlist, err)
+	}
+	words := normalizeWords(lines)
+	if len(words) == 0 {
+		return nil, "", fmt.Errorf("wordlist %s has no valid subdomain labels", opts.Wordlist)
+	}
+	return words, opts.Wordlist, nil
+}
+
+// resolverPool spreads lookups across DNS servers, round-robin
+type resolverPool struct {
+	resolvers []*net.Resolver
+	next      uint64
+}
+
+func newResolverPool(servers []string) *resolverPool {
+	pool := &resolverPool{}
+	for _, server := range servers {
+		server := server
+		pool.resolvers = append(pool.resolvers, &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, server)
+			},
+		})
+	}
+	return pool
+}
+
+// pick returns the next resolver of the pool
+func (p *resolverPool) pick() *net.Resolver {
+	n := atomic.AddUint64(&p.next, 1)
+	return p.resolvers[int(n-1)%len(p.resolvers)]
+}
+
+// CheckWordlist checks that the wordlist of a brute force can be read
+func (s *DNSScanner) CheckWordlist(opts *DNSBruteForce) error {
+	_, _, err := s.words(opts)
+	return err
+}
@@ -0,0 +1,500 @@
+package scanner
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// Intrusiveness ratings, least to most intrusive
+var intrusivenessLevels = []string{"low", "medium", "high", "critical"}
+
+// nmapTimingRates are rough probes per second of each -T template on a
+// healthy network
+var nmapTimingRates = map[string]float64{
+	"0": 1.0 / 300, "1": 1.0 / 15, "2": 2.5, "3": 300, "4": 1000, "5": 3000,
+}
+
+// nmapIntrusiveScripts are NSE categories that attack or stress the target
+var nmapIntrusiveScripts = []string{"vuln", "brute", "exploit", "intrusive", "dos", "fuzzer", "malware"}
+
+const (
+	estimateUpRatio        = 0.25 // share of a range's hosts expected to be up
+	estimateOpenRatio      = 0.02 // share of scanned ports expected to be open
+	estimateRetries        = 1.5  // probes per port, counting retransmissions to filtered ports
+	estimateDiscoveryProbe = 4    // nmap host discovery probes per host
+	estimateDNSQueryTime   = 50 * time.Millisecond
+)
+
+// estimator accumulates an estimate and its intrusiveness rating
+type estimator struct {
+	estimate *models.ScanEstimate
+	level    int
+	seconds  float64
+}
+
+func newEstimator(scanner, scanType string, hosts int64, hostnames int) *estimator {
+	e := &estimator{estimate: &models.ScanEstimate{
+		Scanner:     scanner,
+		ScanType:    scanType,
+		Hosts:       hosts,
+		Hostnames:   hostnames,
+		Factors:     []string{},
+		Assumptions: []string{},
+	}}
+	if hostnames > 0 {
+		e.assume(fmt.Sprintf("%d hostname(s) counted as one host each without resolving them", hostnames))
+	}
+	return e
+}
+
+// raise sets the rating to at least level, recording why
+func (e *estimator) raise(level int, reason string) {
+	if level > e.level {
+		e.level = level
+	}
+	e.estimate.Factors = append(e.estimate.Factors, reason)
+}
+
+func (e *estimator) assume(assumption string) {
+	e.estimate.Assumptions = append(e.estimate.Assumptions, assumption)
+}
+
+func (e *estimator) warn(warning string) {
+	e.estimate.Warnings = append(e.estimate.Warnings, warning)
+}
+
+func (e *estimator) finish() *models.ScanEstimate {
+	e.estimate.Intrusiveness = intrusivenessLevels[e.level]
+	e.estimate.DurationSeconds = int64(math.Ceil(e.seconds))
+	e.estimate.Duration = (time.Duration(e.estimate.DurationSeconds) * time.Second).String()
+	return e.estimate
+}
+
+// EstimateHosts counts the hosts of a target the way the scanners expand it,
+// without resolving hostnames. Ranges larger than an int64 are capped.
+func EstimateHosts(target string) (hosts int64, hostnames int, err error) {
+	for _, part := range strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		switch {
+		case strings.Contains(part, "/"):
+			_, network, err := net.ParseCIDR(part)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid CIDR %q", part)
+			}
+			ones, bits := network.Mask.Size()
+			size := int64(math.MaxInt64)
+			if bits-ones < 63 {
+				size = int64(1) << uint(bits-ones)
+			}
+			// Network and broadcast addresses of IPv4 subnets are skipped
+			if bits == 32 && ones < 31 {
+				size -= 2
+			}
+			if hosts > math.MaxInt64-size {
+				return math.MaxInt64, hostnames, nil
+			}
+			hosts += size
+		case isLastOctetRange(part):
+			dash := strings.LastIndex(part, "-")
+			start := net.ParseIP(part[:dash]).To4()
+			end, err := strconv.Atoi(part[dash+1:])
+			if err != nil || end < int(start[3]) || end > 255 {
+				return 0, 0, fmt.Errorf("invalid range %q", part)
+			}
+			hosts += int64(end - int(start[3]) + 1)
+		case net.ParseIP(part) != nil:
+			hosts++
+		default:
+			hosts++
+			hostnames++
+		}
+	}
+	if hosts == 0 {
+		return 0, 0, fmt.Errorf("no hosts to scan")
+	}
+	return hosts, hostnames, nil
+}
+
+// expectedUp is how many hosts are expected to answer
+func expectedUp(hosts int64) float64 {
+	if hosts <= 4 {
+		return float64(hosts)
+	}
+	return math.Max(1, float64(hosts)*estimateUpRatio)
+}
+
+// countPortList counts the ports of an nmap/masscan port list such as
+// "22,80,8000-8100" or "T:80,U:53"
+func countPortList(ports string) int {
+	total := 0
+	for _, part := range strings.Split(ports, ",") {
+		part = strings.TrimSpace(part)
+		if i := strings.Index(part, ":"); i >= 0 {
+			part = part[i+1:]
+		}
+		low, high, isRange := strings.Cut(part, "-")
+		l, err := strconv.Atoi(low)
+		if err != nil {
+			if isRange && low == "" {
+				l = 1
+			} else {
+				continue
+			}
+		}
+		h := l
+		if isRange {
+			if high == "" {
+				h = 65535
+			} else if h, err = strconv.Atoi(high); err != nil {
+				continue
+			}
+		}
+		if h >= l {
+			total += h - l + 1
+		}
+	}
+	return total
+}
+
+// EstimateNmap estimates an nmap run with the given arguments
+func EstimateNmap(scanType, arguments string, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("nmap", scanType, hosts, hostnames)
+	e.estimate.Arguments = arguments
+	args := strings.Fields(arguments)
+
+	ports, rate := 1000, nmapTimingRates["3"]
+	tcp, udp, discovery, pingOnly := true, false, true, false
+	versions, osDetection, scripts := false, false, ""
+	var minRate, maxRate float64
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		switch {
+		case arg == "-p-":
+			ports = 65535
+		case arg == "-p" && next != "":
+			ports = countPortList(next)
+			i++
+		case strings.HasPrefix(arg, "-p") && len(arg) > 2:
+			ports = countPortList(arg[2:])
+		case arg == "--top-ports" && next != "":
+			ports, _ = strconv.Atoi(next)
+			i++
+		case strings.HasPrefix(arg, "--top-ports="):
+			ports, _ = strconv.Atoi(strings.TrimPrefix(arg, "--top-ports="))
+		case arg == "-F":
+			ports = 100
+		case arg == "-sU":
+			udp = true
+		case arg == "-sn":
+			pingOnly = true
+		case arg == "-Pn":
+			discovery = false
+		case arg == "-sV":
+			versions = true
+		case arg == "-O":
+			osDetection = true
+		case arg == "-A":
+			versions, osDetection = true, true
+			if scripts == "" {
+				scripts = "default"
+			}
+		case arg == "-sC":
+			if scripts == "" {
+				scripts = "default"
+			}
+		case arg == "--script" && next != "":
+			scripts = next
+			i++
+		case strings.HasPrefix(arg, "--script="):
+			scripts = strings.TrimPrefix(arg, "--script=")
+		case len(arg) == 3 && strings.HasPrefix(arg, "-T"):
+			if r, ok := nmapTimingRates[arg[2:]]; ok {
+				rate = r
+				switch arg[2:] {
+				case "0", "1":
+					e.raise(0, fmt.Sprintf("%s is paced to evade detection", arg))
+				case "5":
+					e.raise(2, "-T5 sends at the highest rate and may drop probes or trip rate limits")
+				}
+			}
+		case arg == "--min-rate" && next != "":
+			minRate, _ = strconv.ParseFloat(next, 64)
+			i++
+		case arg == "--max-rate" && next != "":
+			maxRate, _ = strconv.ParseFloat(next, 64)
+			i++
+		}
+	}
+	if udp && !containsAny(args, "-sS", "-sT", "-sA", "-sF", "-sN", "-sX") {
+		tcp = false
+	}
+	if minRate > rate {
+		rate = minRate
+	}
+	if maxRate > 0 && maxRate < rate {
+		rate = maxRate
+	}
+	if ports <= 0 {
+		ports = 1000
+	}
+
+	up := expectedUp(hosts)
+	if !discovery {
+		up = float64(hosts)
+		e.assume("-Pn treats every host as up, so every host is port scanned")
+	} else if hosts > 4 {
+		e.assume(fmt.Sprintf("%.0f%% of the range's hosts are up", estimateUpRatio*100))
+	}
+
+	var packets float64
+	if discovery {
+		packets += float64(hosts) * estimateDiscoveryProbe
+	}
+	if pingOnly {
+		e.estimate.PortsPerHost = 0
+		e.estimate.Packets = int64(packets)
+		e.seconds = packets / rate
+		e.raise(0, "host discovery only (-sn)")
+		return e.finish()
+	}
+
+	protocols := 0
+	if tcp {
+		protocols++
+	}
+	if udp {
+		protocols++
+	}
+	e.estimate.PortsPerHost = ports * protocols
+	portProbes := up * float64(ports) * estimateRetries
+	packets += portProbes * float64(protocols)
+	e.seconds = packets / rate
+	e.assume(fmt.Sprintf("%.1f probes per port including retransmissions", estimateRetries))
+
+	level := 1
+	switch {
+	case ports >= 10000:
+		level = 2
+		e.raise(level, fmt.Sprintf("%d ports per host", ports))
+	case ports > 1000:
+		e.raise(level, fmt.Sprintf("%d ports per host", ports))
+	case ports <= 100 && !versions && !osDetection && scripts == "":
+		level = 0
+		e.raise(level, fmt.Sprintf("port scan of %d common ports", ports))
+	default:
+		e.raise(level, fmt.Sprintf("port scan of %d ports", ports))
+	}
+	if udp {
+		// Targets rate limit ICMP port unreachable replies to about one per second
+		e.seconds += up * float64(ports) / math.Min(up, 64)
+		e.raise(2, "UDP scanning is slow and sends payloads to UDP services")
+		e.assume("closed UDP ports answer about once per second (ICMP rate limiting)")
+	}
+
+	open := math.Max(1, math.Min(50, float64(ports*protocols)*estimateOpenRatio))
+	parallelHosts := math.Max(1, math.Min(up, 16))
+	if versions || osDetection || scripts != "" {
+		e.assume(fmt.Sprintf("about %.0f open port(s) per host", open))
+	}
+	if versions {
+		packets += up * open * 8
+		e.seconds += up * 5 / parallelHosts
+		e.raise(1, "service version detection (-sV) connects to every open port")
+	}
+	if osDetection {
+		packets += up * 30
+		e.seconds += up * 3 / parallelHosts
+		e.raise(2, "OS detection (-O) sends malformed packets")
+	}
+	if scripts != "" {
+		packets += up * open * 20
+		e.seconds += up * 15 / parallelHosts
+		intrusive := ""
+		for _, category := range nmapIntrusiveScripts {
+			if strings.Contains(scripts, category) {
+				intrusive = category
+				break
+			}
+		}
+		if intrusive != "" {
+			e.raise(3, fmt.Sprintf("NSE scripts %q include the %s category, which attacks services", scripts, intrusive))
+		} else {
+			e.raise(2, fmt.Sprintf("NSE scripts (%s) interact with discovered services", scripts))
+		}
+	}
+
+	e.estimate.Packets = int64(packets)
+	e.assume(fmt.Sprintf("about %.0f probes per second", rate))
+	return e.finish()
+}
+
+// EstimateMasscan estimates a masscan run of ports at rate packets per second
+func EstimateMasscan(scanType, ports string, rate int, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("masscan", scanType, hosts, hostnames)
+	e.masscan(ports, rate, hosts, hostnames)
+	return e.finish()
+}
+
+// EstimatePipeline estimates a pipeline scan. Its nmap stage only probes the
+// ports masscan finds open, which can't be known beforehand, so the estimate
+// covers the discovery.
+func EstimatePipeline(scanType, ports string, rate int, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("pipeline", scanType, hosts, hostnames)
+	e.masscan(ports, rate, hosts, hostnames)
+	e.raise(1, "nmap service detection connects to every open port found")
+	e.warn("the nmap stage adds time for each open port masscan finds, which is not included")
+	return e.finish()
+}
+
+// masscan adds the packets and time of a masscan discovery
+func (e *estimator) masscan(ports string, rate int, hosts int64, hostnames int) {
+	if rate <= 0 {
+		rate = 10000
+	}
+	count := countPortList(ports)
+	e.estimate.PortsPerHost = count
+	e.estimate.Packets = hosts * int64(count)
+	// masscan waits 10 seconds for late replies once every packet is sent
+	e.seconds = float64(e.estimate.Packets)/float64(rate) + 10
+	e.assume(fmt.Sprintf("one SYN per port at %d packets per second, plus masscan's 10 second wait", rate))
+
+	switch {
+	case rate >= 100000:
+		e.raise(3, fmt.Sprintf("%d packets per second can saturate links and trip IDS", rate))
+	case rate >= 50000:
+		e.raise(2, fmt.Sprintf("%d packets per second", rate))
+	default:
+		e.raise(1, fmt.Sprintf("stateless SYN scan at %d packets per second", rate))
+	}
+	if count >= 10000 {
+		e.raise(2, fmt.Sprintf("%d ports per host", count))
+	}
+	if hostnames > 0 {
+		e.warn("masscan only scans IP addresses; hostnames are resolved before the scan")
+	}
+}
+
+// EstimateNaabu estimates a naabu SYN scan of config's ports. Ports found
+// open are confirmed with a TCP connect when config.Verify is set, which
+// can't be known beforehand.
+func EstimateNaabu(scanType string, config NaabuScanConfig, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("naabu", scanType, hosts, hostnames)
+	rate := config.Rate
+	if rate <= 0 {
+		rate = NaabuDefaultRate
+	}
+	count := config.TopPorts
+	if config.Ports != "" {
+		count = countPortList(config.Ports)
+	} else if count <= 0 {
+		count = NaabuDefaultTopPorts
+	}
+	if config.ExcludePorts != "" {
+		count -= countPortList(config.ExcludePorts)
+		if count < 0 {
+			count = 0
+		}
+	}
+	e.estimate.PortsPerHost = count
+	e.estimate.Packets = hosts * int64(count)
+	e.seconds = float64(e.estimate.Packets) / float64(rate)
+	e.assume(fmt.Sprintf("one SYN per port at %d packets per second", rate))
+
+	switch {
+	case rate >= 10000:
+		e.raise(2, fmt.Sprintf("%d packets per second", rate))
+	default:
+		e.raise(1, fmt.Sprintf("SYN scan at %d packets per second", rate))
+	}
+	if count >= 10000 {
+		e.raise(2, fmt.Sprintf("%d ports per host", count))
+	}
+	if config.Verify {
+		e.warn("open ports are confirmed with a TCP connect, which adds time for each one found")
+	}
+	return e.finish()
+}
+
+// EstimateNative estimates a native TCP connect scan
+func EstimateNative(scanType string, config NativeScanConfig, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("native", scanType, hosts, hostnames)
+	if config.Concurrency <= 0 {
+		config.Concurrency = NativeDefaultConcurrency
+	}
+	if config.Concurrency > NativeMaxConcurrency {
+		config.Concurrency = NativeMaxConcurrency
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = NativeDefaultTimeout
+	}
+
+	e.estimate.PortsPerHost = len(config.Ports)
+	e.estimate.Packets = hosts * int64(len(config.Ports))
+	rounds := math.Ceil(float64(e.estimate.Packets) / float64(config.Concurrency))
+	e.seconds = rounds * config.Timeout.Seconds()
+	e.assume(fmt.Sprintf("worst case: every connection waits the %s timeout, %d at a time", config.Timeout, config.Concurrency))
+
+	e.raise(1, "full TCP connections are logged by the target services")
+	if config.Banners {
+		e.raise(1, "banner grabbing sends an HTTP request to silent services")
+	}
+	if len(config.Ports) >= 10000 {
+		e.raise(2, fmt.Sprintf("%d ports per host", len(config.Ports)))
+	}
+	if hosts > nativeMaxHosts {
+		e.warn(fmt.Sprintf("native scans refuse targets of more than %d hosts", nativeMaxHosts))
+	}
+	return e.finish()
+}
+
+// EstimateDNS estimates a DNS scan of the given domains
+func EstimateDNS(scanType string, domains int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator("dns", scanType, domains, 0)
+	queries := int64(5) // A, AAAA, MX, NS and TXT
+	switch scanType {
+	case "dns_full", "dns_comprehensive":
+		queries = 7 + int64(len(dnsCommonSubdomains))
+		e.raise(0, fmt.Sprintf("record lookups and %d subdomain guesses", len(dnsCommonSubdomains)))
+	case "dns_subdomain":
+		queries = int64(len(dnsCommonSubdomains))
+		e.raise(0, fmt.Sprintf("%d subdomain guesses", len(dnsCommonSubdomains)))
+	default:
+		e.raise(0, "record lookups through the configured resolver")
+	}
+	e.estimate.Packets = domains * queries
+	e.seconds = float64(e.estimate.Packets) * estimateDNSQueryTime.Seconds()
+	e.assume(fmt.Sprintf("%s per query", estimateDNSQueryTime))
+	if int64(hostnames) < domains {
+		e.warn("DNS scans expect domain names; IP addresses only get reverse lookups at best")
+	}
+	return e.finish()
+}
+
+// EstimateSimulation estimates a simulated scan, which sends nothing
+func EstimateSimulation(scanner, scanType string, hosts int64, hostnames int) *models.ScanEstimate {
+	e := newEstimator(scanner, scanType, hosts, hostnames)
+	e.seconds = (simulatedSteps * simulatedStepTime).Seconds()
+	e.raise(0, "simulated scans generate synthetic results without sending traffic")
+	return e.finish()
+}
+
+func containsAny(values []string, candidates ...string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if v == c {
+				return true
+			}
+		}
+	}
+	return false
+}
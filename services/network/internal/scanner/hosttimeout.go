@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ullaakut/nmap/v3"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// maxSkippedInLog is how many skipped hosts a scan log line names
+const maxSkippedInLog = 10
+
+// SkipHostTimeout is the reason of hosts skipped for using up their time
+// budget
+const SkipHostTimeout = "host_timeout"
+
+// WithHostTimeout sets nmap's --host-timeout in arguments, replacing the
+// one they have; a zero timeout leaves them unchanged
+func WithHostTimeout(arguments string, timeout time.Duration) string {
+	if timeout <= 0 {
+		return arguments
+	}
+	fields := strings.Fields(arguments)
+	args := make([]string, 0, len(fields)+2)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--host-timeout":
+			i++ // drop the flag's value too
+			continue
+		case strings.HasPrefix(fields[i], "--host-timeout="):
+			continue
+		}
+		args = append(args, fields[i])
+	}
+	args = append(args, "--host-timeout", strconv.Itoa(int(timeout.Seconds()))+"s")
+	return strings.Join(args, " ")
+}
+
+// nmapSkippedHosts returns the hosts nmap gave up on at --host-timeout. nmap
+// reports no ports for them, so they are coverage gaps rather than results.
+func nmapSkippedHosts(result *nmap.Run) []models.SkippedHost {
+	skipped := []models.SkippedHost{}
+	for _, host := range result.Hosts {
+		if !host.TimedOut || len(host.Addresses) == 0 {
+			continue
+		}
+		s := models.SkippedHost{Host: host.Addresses[0].Addr, Reason: SkipHostTimeout}
+		if len(host.Hostnames) > 0 {
+			s.Hostname = host.Hostnames[0].Name
+		}
+		start, end := time.Time(host.StartTime), time.Time(host.EndTime)
+		if !start.IsZero() && end.After(start) {
+			s.ElapsedSeconds = end.Sub(start).Seconds()
+		}
+		skipped = append(skipped, s)
+	}
+	return skipped
+}
+
+// recordSkipped stores the hosts a scan gave up on and logs them
+func recordSkipped(ctx context.Context, db *database.Database, scanID uuid.UUID, skipped []models.SkippedHost,
+	addLog func(ctx context.Context, scanID uuid.UUID, level, message string)) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+	names := make([]string, 0, maxSkippedInLog)
+	for _, s := range skipped {
+		if len(names) == maxSkippedInLog {
+			names = append(names, fmt.Sprintf("and %d more", len(skipped)-maxSkippedInLog))
+			break
+		}
+		names = append(names, s.Host)
+	}
+	addLog(ctx, scanID, "warning", fmt.Sprintf("Skipped %d hosts that hit the host timeout, their ports were not fully scanned: %s",
+		len(skipped), strings.Join(names, ", ")))
+
+	data, err := json.Marshal(skipped)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(ctx, `UPDATE scans SET skipped_hosts = $1 WHERE id = $2`, data, scanID)
+	return err
+}
@@ -2,23 +2,42 @@ package scanner
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/kubejobs"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/rawoutput"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+)
+
+// Ports and rate (packets/sec) of masscan scans that don't set them
+const (
+	masscanDefaultPorts = "1-65535"
+	masscanDefaultRate  = 10000
 )
 
 type MasscanScanner struct {
 	db          *database.Database
 	masscanPath string
+	pathMu      sync.RWMutex
+	sandbox     *sandbox.Sandbox
+	kube        *kubejobs.Backend
+	retry       supervise.Policy
 	cancelFuncs map[string]context.CancelFunc
 }
 
@@ -35,19 +54,45 @@ type MasscanResult struct {
 	} `json:"ports"`
 }
 
-func NewMasscanScanner(db *database.Database, masscanPath string) *MasscanScanner {
+func NewMasscanScanner(db *database.Database, masscanPath string, sb *sandbox.Sandbox) *MasscanScanner {
 	if masscanPath == "" {
 		masscanPath = "masscan"
 	}
 	return &MasscanScanner{
 		db:          db,
 		masscanPath: masscanPath,
+		sandbox:     sb,
 		cancelFuncs: make(map[string]context.CancelFunc),
 	}
 }
 
-// ExecuteScan runs a masscan scan and stores results
-func (s *MasscanScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, ports string, rate int) error {
+// SetMasscanPath changes the masscan binary used by scans started afterwards
+func (s *MasscanScanner) SetMasscanPath(path string) {
+	s.pathMu.Lock()
+	s.masscanPath = path
+	s.pathMu.Unlock()
+}
+
+// SetKubernetes runs masscan as a Kubernetes Job when the backend has a
+// profile for it
+func (s *MasscanScanner) SetKubernetes(b *kubejobs.Backend) {
+	s.kube = b
+}
+
+// SetRetryPolicy retries masscan runs that failed transiently
+func (s *MasscanScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+func (s *MasscanScanner) currentMasscanPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.masscanPath
+}
+
+// ExecuteScan runs a masscan scan and stores results. extraArgs are added
+// to masscan's arguments (a scan's advanced options).
+func (s *MasscanScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, ports string, rate int, extraArgs []string) error {
 	log.Printf("🚀 Starting Masscan scan %s on target: %s ports: %s rate: %d", scanID, target, ports, rate)
 
 	// Create cancellable context
@@ -66,12 +111,57 @@ func (s *MasscanScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, targ
 	// Add log entry
 	s.addLog(ctx, scanID, "info", fmt.Sprintf("Starting Masscan on target: %s", target))
 
+	if ports == "" {
+		ports = masscanDefaultPorts
+	}
+	results, err := s.Discover(ctx, scanID, target, ports, rate, extraArgs)
+
+	// Check if context was cancelled
+	if ctx.Err() == context.Canceled {
+		s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
+		return nil
+	}
+
+	if err != nil {
+		errMsg := err.Error()
+		s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
+		s.addLog(ctx, scanID, "error", fmt.Sprintf("Masscan failed: %s", errMsg))
+		return fmt.Errorf("masscan failed: %w", err)
+	}
+
+	// Store results
+	scannedPorts := deception.CountPorts(ports)
+	for _, result := range results {
+		result.Honeypot = deception.Assess(result.Ports, deception.Signals{ScannedPorts: scannedPorts})
+		if result.Honeypot != nil && result.Honeypot.Likely {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("Host %s looks like a honeypot/tarpit (score %d): %s",
+				result.Host, result.Honeypot.Score, strings.Join(result.Honeypot.Reasons, "; ")))
+		}
+		if err := s.storeResult(ctx, result); err != nil {
+			log.Printf("Failed to store result: %v", err)
+		}
+	}
+
+	// Update scan status to completed
+	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	s.addLog(ctx, scanID, "success", fmt.Sprintf("Masscan completed. Found %d hosts with open ports", len(results)))
+	log.Printf("✅ Masscan %s completed. Found %d hosts", scanID, len(results))
+
+	return nil
+}
+
+// Discover runs masscan with the scan's retry policy and returns the open
+// ports it found, grouped by host, without storing them
+func (s *MasscanScanner) Discover(ctx context.Context, scanID uuid.UUID, target string, ports string, rate int, extraArgs []string) (map[string]*models.ScanResult, error) {
 	// Default values
 	if ports == "" {
-		ports = "1-65535"
+		ports = masscanDefaultPorts
 	}
 	if rate == 0 {
-		rate = 10000 // Default rate: 10k packets/sec
+		rate = masscanDefaultRate
 	}
 
 	// Build command arguments
@@ -82,43 +172,78 @@ func (s *MasscanScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, targ
 		"-oJ", "-", // JSON output to stdout
 		"--open",   // Only show open ports
 	}
+	args = append(args, extraArgs...)
 
-	log.Printf("Running: %s %s", s.masscanPath, strings.Join(args, " "))
+	masscanPath := s.currentMasscanPath()
+	log.Printf("Running: %s %s", masscanPath, strings.Join(args, " "))
 	s.addLog(ctx, scanID, "info", fmt.Sprintf("Command: masscan %s", strings.Join(args, " ")))
 
-	cmd := exec.CommandContext(ctx, s.masscanPath, args...)
+	var results map[string]*models.ScanResult
+	err := s.retry.Run(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = s.runMasscan(ctx, scanID, masscanPath, args)
+		return err
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("masscan", a)
+		s.addLog(ctx, scanID, level, message)
+	})
+	return results, err
+}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
-	}
+// runMasscan runs masscan once, locally or as a Kubernetes Job, and groups
+// the open ports it reports by IP
+func (s *MasscanScanner) runMasscan(ctx context.Context, scanID uuid.UUID, masscanPath string, args []string) (map[string]*models.ScanResult, error) {
+	var stdout io.Reader
+	var wait func() error
+	var stderrLines []string
+	stderrDone := make(chan struct{})
+	if s.kube.Handles("masscan") {
+		job, err := s.kube.Start(ctx, "masscan", args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start masscan job: %w", err)
+		}
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Running as Kubernetes job %s", job.Name()))
+		stdout, wait = job.Stdout(), job.Wait
+		close(stderrDone)
+	} else {
+		cmd := s.sandbox.Command(ctx, "masscan", masscanPath, args...)
+
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+		}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
-	}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
+		}
 
-	if err := cmd.Start(); err != nil {
-		errMsg := err.Error()
-		s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
-		s.addLog(ctx, scanID, "error", fmt.Sprintf("Failed to start masscan: %s", errMsg))
-		return fmt.Errorf("failed to start masscan: %w", err)
-	}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start masscan: %w", err)
+		}
 
-	// Read stderr for progress/errors
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.Contains(line, "rate:") || strings.Contains(line, "Scanning") {
-				s.addLog(ctx, scanID, "info", line)
+		// Read stderr for progress/errors
+		go func() {
+			defer close(stderrDone)
+			scanner := bufio.NewScanner(stderr)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if strings.Contains(line, "rate:") || strings.Contains(line, "Scanning") {
+					s.addLog(ctx, scanID, "info", line)
+				} else if sandbox.ViolationLine(line) {
+					s.addLog(ctx, scanID, "error", "Sandbox violation: "+line)
+				} else {
+					stderrLines = append(stderrLines, line)
+				}
 			}
-		}
-	}()
+		}()
+		stdout, wait = pipe, cmd.Wait
+	}
 
-	// Parse JSON output
+	// Parse JSON output, keeping it for the scan's evidence bundle
+	var raw bytes.Buffer
 	results := make(map[string]*models.ScanResult)
-	scanner := bufio.NewScanner(stdout)
+	scanner := bufio.NewScanner(io.TeeReader(stdout, &raw))
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" || line == "[" || line == "]" {
@@ -162,40 +287,22 @@ func (s *MasscanScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, targ
 		}
 	}
 
-	// Check if context was cancelled
-	if ctx.Err() == context.Canceled {
-		s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
-		return nil
-	}
-
-	if err := cmd.Wait(); err != nil {
-		// Check if it was cancelled
-		if ctx.Err() == context.Canceled {
-			s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
-			return nil
+	// The pipes are closed by Wait, once stderr was read to the end
+	<-stderrDone
+	if err := wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = []byte(strings.Join(stderrLines, "\n"))
 		}
-		errMsg := err.Error()
-		s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
-		s.addLog(ctx, scanID, "error", fmt.Sprintf("Masscan failed: %s", errMsg))
-		return fmt.Errorf("masscan failed: %w", err)
-	}
-
-	// Store results
-	for _, result := range results {
-		if err := s.storeResult(ctx, result); err != nil {
-			log.Printf("Failed to store result: %v", err)
+		if msg, ok := sandbox.Violation(err); ok {
+			s.addLog(ctx, scanID, "error", "Sandbox violation: "+msg)
 		}
+		return nil, err
 	}
-
-	// Update scan status to completed
-	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
-		return fmt.Errorf("failed to update scan status: %w", err)
+	if err := rawoutput.Save(ctx, s.db, scanID, "masscan", "json", raw.Bytes()); err != nil {
+		log.Printf("Failed to save masscan output of scan %s: %v", scanID, err)
 	}
-
-	s.addLog(ctx, scanID, "success", fmt.Sprintf("Masscan completed. Found %d hosts with open ports", len(results)))
-	log.Printf("✅ Masscan %s completed. Found %d hosts", scanID, len(results))
-
-	return nil
+	return results, nil
 }
 
 // CancelScan cancels a running scan
@@ -228,9 +335,15 @@ func (s *MasscanScanner) addLog(ctx context.Context, scanID uuid.UUID, level, me
 
 func (s *MasscanScanner) storeResult(ctx context.Context, result *models.ScanResult) error {
 	query := `
-		INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at, honeypot_score, honeypot_reasons)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
+	honeypotScore := 0
+	var honeypotReasons []string
+	if result.Honeypot != nil {
+		honeypotScore = result.Honeypot.Score
+		honeypotReasons = result.Honeypot.Reasons
+	}
 	_, err := s.db.Pool.Exec(ctx, query,
 		result.ID,
 		result.ScanID,
@@ -243,6 +356,8 @@ func (s *MasscanScanner) storeResult(ctx context.Context, result *models.ScanRes
 		result.MacAddress,
 		result.MacVendor,
 		result.CreatedAt,
+		honeypotScore,
+		honeypotReasons,
 	)
 	return err
 }
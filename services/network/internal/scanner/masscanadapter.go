@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MasscanAdapterKeys are the configuration keys that choose where masscan
+// sends from
+var MasscanAdapterKeys = []string{"adapter", "adapter_ip", "adapter_port", "router_mac"}
+
+// interfacePattern matches network interface names (at most 15 characters
+// on Linux)
+var interfacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:@-]{0,14}$`)
+
+// MasscanAdapter is the interface, source address and gateway masscan
+// sends its packets through, for scanners with several networks. masscan
+// picks them from the default route otherwise.
+type MasscanAdapter struct {
+	Adapter     string // --adapter, e.g. eth1
+	AdapterIP   string // --adapter-ip: an address, range or CIDR to send from
+	AdapterPort string // --adapter-port: a source port, or a range of a power of two ports
+	RouterMAC   string // --router-mac: the gateway's MAC address
+}
+
+// HasMasscanAdapter reports whether a scan configuration sets any of the
+// masscan adapter options
+func HasMasscanAdapter(configuration map[string]interface{}) bool {
+	for _, key := range MasscanAdapterKeys {
+		if _, ok := configuration[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMasscanAdapter reads and validates the adapter options of a scan
+// configuration
+func ParseMasscanAdapter(configuration map[string]interface{}) (MasscanAdapter, error) {
+	var a MasscanAdapter
+	values := map[string]string{}
+	for _, key := range MasscanAdapterKeys {
+		switch v := configuration[key].(type) {
+		case nil:
+		case string:
+			values[key] = strings.TrimSpace(v)
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return a, fmt.Errorf("%s must be a string", key)
+		}
+	}
+
+	if a.Adapter = values["adapter"]; a.Adapter != "" && !interfacePattern.MatchString(a.Adapter) {
+		return a, fmt.Errorf("adapter %q is not a network interface name", a.Adapter)
+	}
+	if a.AdapterIP = values["adapter_ip"]; a.AdapterIP != "" && !validAdapterIP(a.AdapterIP) {
+		return a, fmt.Errorf("adapter_ip %q must be an IP address, range (10.0.0.10-10.0.0.20) or CIDR", a.AdapterIP)
+	}
+	if a.AdapterPort = values["adapter_port"]; a.AdapterPort != "" {
+		if err := validateAdapterPort(a.AdapterPort); err != nil {
+			return a, err
+		}
+	}
+	if mac := values["router_mac"]; mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil || len(hw) != 6 {
+			return a, fmt.Errorf("router_mac %q is not a MAC address", mac)
+		}
+		a.RouterMAC = hw.String()
+	}
+	return a, nil
+}
+
+// validAdapterIP accepts an address, a range of addresses of the same
+// family or a CIDR
+func validAdapterIP(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	low, high, ok := strings.Cut(value, "-")
+	if !ok {
+		return false
+	}
+	first, last := net.ParseIP(strings.TrimSpace(low)), net.ParseIP(strings.TrimSpace(high))
+	if first == nil || last == nil || (first.To4() == nil) != (last.To4() == nil) {
+		return false
+	}
+	if first.To4() != nil {
+		first, last = first.To4(), last.To4()
+	}
+	return bytes.Compare(first, last) <= 0
+}
+
+// validateAdapterPort accepts a port or a range of ports; masscan needs
+// ranges of a power of two ports
+func validateAdapterPort(value string) error {
+	low, high, isRange := strings.Cut(value, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(low))
+	if err != nil || first < 1 || first > 65535 {
+		return fmt.Errorf("adapter_port %q must be a port or a range of ports", value)
+	}
+	if !isRange {
+		return nil
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(high))
+	if err != nil || last < first || last > 65535 {
+		return fmt.Errorf("adapter_port %q must be a port or a range of ports", value)
+	}
+	if count := uint(last - first + 1); bits.OnesCount(count) != 1 {
+		return fmt.Errorf("adapter_port range %q must span a power of two ports (e.g. 40000-40255), not %d", value, count)
+	}
+	return nil
+}
+
+// Args returns the masscan flags of the adapter options that are set
+func (a MasscanAdapter) Args() []string {
+	var args []string
+	if a.Adapter != "" {
+		args = append(args, "--adapter", a.Adapter)
+	}
+	if a.AdapterIP != "" {
+		args = append(args, "--adapter-ip", a.AdapterIP)
+	}
+	if a.AdapterPort != "" {
+		args = append(args, "--adapter-port", a.AdapterPort)
+	}
+	if a.RouterMAC != "" {
+		args = append(args, "--router-mac", a.RouterMAC)
+	}
+	return args
+}
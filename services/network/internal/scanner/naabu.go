@@ -0,0 +1,406 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/kubejobs"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+)
+
+// Packets per second and top ports of naabu scans that don't set them
+const (
+	NaabuDefaultRate     = 1000
+	NaabuDefaultTopPorts = 100
+)
+
+// NaabuScanner runs ProjectDiscovery's naabu, a SYN scanner that checks the
+// ports it finds open with a TCP connect, so it reports fewer false
+// positives than masscan at a lower rate
+type NaabuScanner struct {
+	db          *database.Database
+	naabuPath   string
+	pathMu      sync.RWMutex
+	sandbox     *sandbox.Sandbox
+	kube        *kubejobs.Backend
+	retry       supervise.Policy
+	mu          sync.Mutex
+	cancelFuncs map[string]context.CancelFunc
+}
+
+// NaabuScanConfig holds the options of a naabu scan. Ports wins over
+// TopPorts (100 or 1000).
+type NaabuScanConfig struct {
+	Ports        string
+	TopPorts     int
+	Rate         int    // packets per second
+	ExcludePorts string // ports and ranges never probed
+	Verify       bool   // confirm open ports with a TCP connect
+}
+
+// naabuResult is a line of naabu's JSON output: one open port
+type naabuResult struct {
+	Host     string `json:"host"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	TLS      bool   `json:"tls"`
+}
+
+func NewNaabuScanner(db *database.Database, naabuPath string, sb *sandbox.Sandbox) *NaabuScanner {
+	if naabuPath == "" {
+		naabuPath = "naabu"
+	}
+	return &NaabuScanner{
+		db:          db,
+		naabuPath:   naabuPath,
+		sandbox:     sb,
+		cancelFuncs: make(map[string]context.CancelFunc),
+	}
+}
+
+// SetNaabuPath changes the naabu binary used by scans started afterwards
+func (s *NaabuScanner) SetNaabuPath(path string) {
+	s.pathMu.Lock()
+	s.naabuPath = path
+	s.pathMu.Unlock()
+}
+
+// SetKubernetes runs naabu as a Kubernetes Job when the backend has a
+// profile for it
+func (s *NaabuScanner) SetKubernetes(b *kubejobs.Backend) {
+	s.kube = b
+}
+
+// SetRetryPolicy retries naabu runs that failed transiently
+func (s *NaabuScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+func (s *NaabuScanner) currentNaabuPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.naabuPath
+}
+
+// NaabuArgs returns naabu's arguments for a scan of target. Targets
+// separated by spaces are passed comma-separated, as naabu expects.
+func NaabuArgs(target string, config NaabuScanConfig) []string {
+	hosts := strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	args := []string{"-host", strings.Join(hosts, ",")}
+	switch {
+	case config.Ports != "":
+		args = append(args, "-p", config.Ports)
+	case config.TopPorts > 0:
+		args = append(args, "-top-ports", strconv.Itoa(config.TopPorts))
+	default:
+		args = append(args, "-top-ports", strconv.Itoa(NaabuDefaultTopPorts))
+	}
+	if config.ExcludePorts != "" {
+		args = append(args, "-exclude-ports", config.ExcludePorts)
+	}
+	rate := config.Rate
+	if rate <= 0 {
+		rate = NaabuDefaultRate
+	}
+	args = append(args, "-rate", strconv.Itoa(rate), "-scan-type", "s")
+	if config.Verify {
+		args = append(args, "-verify")
+	}
+	return append(args, "-json", "-silent", "-no-color")
+}
+
+// ExecuteScan runs a naabu scan and stores its results. extraArgs are added
+// to naabu's arguments (a scan's advanced options).
+func (s *NaabuScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, config NaabuScanConfig, extraArgs []string) error {
+	log.Printf("🚀 Starting naabu scan %s on target: %s", scanID, target)
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancelFuncs[scanID.String()] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancelFuncs, scanID.String())
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	if err := s.updateScanStatus(ctx, scanID, "running", 0, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Starting naabu on target: %s", target))
+
+	args := append(NaabuArgs(target, config), extraArgs...)
+	naabuPath := s.currentNaabuPath()
+	log.Printf("Running: %s %s", naabuPath, strings.Join(args, " "))
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Command: naabu %s", strings.Join(args, " ")))
+
+	var results map[string]*models.ScanResult
+	err := s.retry.Run(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = s.runNaabu(ctx, scanID, naabuPath, args)
+		return err
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("naabu", a)
+		s.addLog(ctx, scanID, level, message)
+	})
+
+	if ctx.Err() == context.Canceled {
+		s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
+		return nil
+	}
+	if err != nil {
+		errMsg := err.Error()
+		s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
+		s.addLog(ctx, scanID, "error", fmt.Sprintf("naabu failed: %s", errMsg))
+		return fmt.Errorf("naabu failed: %w", err)
+	}
+
+	scannedPorts := 0
+	if config.Ports != "" {
+		scannedPorts = deception.CountPorts(config.Ports)
+	} else if config.TopPorts > 0 {
+		scannedPorts = config.TopPorts
+	} else {
+		scannedPorts = NaabuDefaultTopPorts
+	}
+	for _, result := range results {
+		sort.Slice(result.Ports, func(i, j int) bool { return result.Ports[i].Port < result.Ports[j].Port })
+		result.Honeypot = deception.Assess(result.Ports, deception.Signals{ScannedPorts: scannedPorts})
+		if result.Honeypot != nil && result.Honeypot.Likely {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("Host %s looks like a honeypot/tarpit (score %d): %s",
+				result.Host, result.Honeypot.Score, strings.Join(result.Honeypot.Reasons, "; ")))
+		}
+		if err := s.storeResult(ctx, result); err != nil {
+			log.Printf("Failed to store result: %v", err)
+		}
+	}
+
+	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	s.addLog(ctx, scanID, "success", fmt.Sprintf("naabu completed. Found %d hosts with open ports", len(results)))
+	log.Printf("✅ naabu %s completed. Found %d hosts", scanID, len(results))
+	return nil
+}
+
+// runNaabu runs naabu once, locally or as a Kubernetes Job, and groups the
+// open ports it reports by IP
+func (s *NaabuScanner) runNaabu(ctx context.Context, scanID uuid.UUID, naabuPath string, args []string) (map[string]*models.ScanResult, error) {
+	var stdout io.Reader
+	var wait func() error
+	var stderrLines []string
+	stderrDone := make(chan struct{})
+	if s.kube.Handles("naabu") {
+		job, err := s.kube.Start(ctx, "naabu", args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start naabu job: %w", err)
+		}
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Running as Kubernetes job %s", job.Name()))
+		stdout, wait = job.Stdout(), job.Wait
+		close(stderrDone)
+	} else {
+		cmd := s.sandbox.Command(ctx, "naabu", naabuPath, args...)
+
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start naabu: %w", err)
+		}
+
+		go func() {
+			defer close(stderrDone)
+			scanner := bufio.NewScanner(stderr)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if sandbox.ViolationLine(line) {
+					s.addLog(ctx, scanID, "error", "Sandbox violation: "+line)
+				} else {
+					stderrLines = append(stderrLines, line)
+				}
+			}
+		}()
+		stdout, wait = pipe, cmd.Wait
+	}
+
+	results, err := parseNaabuOutput(scanID, stdout)
+
+	// The pipes are closed by Wait, once stderr was read to the end
+	<-stderrDone
+	if waitErr := wait(); waitErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			exitErr.Stderr = []byte(strings.Join(stderrLines, "\n"))
+		}
+		if msg, ok := sandbox.Violation(waitErr); ok {
+			s.addLog(ctx, scanID, "error", "Sandbox violation: "+msg)
+		}
+		return nil, waitErr
+	}
+	return results, err
+}
+
+// parseNaabuOutput groups the open ports of naabu's JSON lines by IP. The
+// hostname is kept when the target was a name.
+func parseNaabuOutput(scanID uuid.UUID, output io.Reader) (map[string]*models.ScanResult, error) {
+	results := make(map[string]*models.ScanResult)
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var found naabuResult
+		if err := json.Unmarshal([]byte(line), &found); err != nil || found.Port == 0 {
+			log.Printf("Failed to parse naabu output: %v - line: %s", err, line)
+			continue
+		}
+		ip := found.IP
+		if ip == "" {
+			ip = found.Host
+		}
+		protocol := found.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		key := fmt.Sprintf("%s/%d/%s", ip, found.Port, protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		result, exists := results[ip]
+		if !exists {
+			result = &models.ScanResult{
+				ID:        uuid.New(),
+				ScanID:    scanID,
+				Host:      ip,
+				State:     "up",
+				Ports:     []models.Port{},
+				Services:  []string{},
+				CreatedAt: time.Now(),
+			}
+			if found.Host != "" && found.Host != ip {
+				hostname := found.Host
+				result.Hostname = &hostname
+			}
+			results[ip] = result
+		}
+		port := models.Port{Port: found.Port, Protocol: protocol, State: "open", Service: "unknown"} // naabu doesn't do service detection
+		if found.TLS {
+			port.ExtraInfo = "tls"
+		}
+		result.Ports = append(result.Ports, port)
+		result.Services = append(result.Services, fmt.Sprintf("%d/%s", found.Port, protocol))
+	}
+	return results, scanner.Err()
+}
+
+// CancelScan cancels a running scan
+func (s *NaabuScanner) CancelScan(scanID string) {
+	s.mu.Lock()
+	cancel, ok := s.cancelFuncs[scanID]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+		log.Printf("🛑 Cancelled naabu scan %s", scanID)
+	}
+}
+
+func (s *NaabuScanner) updateScanStatus(ctx context.Context, scanID uuid.UUID, status string, progress int, errorMsg *string) error {
+	query := `
+		UPDATE scans
+		SET status = $1, progress = $2, error_message = $3,
+		    started_at = CASE WHEN $4 = 'running' AND started_at IS NULL THEN NOW() ELSE started_at END,
+		    completed_at = CASE WHEN $5 IN ('completed', 'failed') THEN NOW() ELSE completed_at END
+		WHERE id = $6
+	`
+	_, err := s.db.Pool.Exec(ctx, query, status, progress, errorMsg, status, status, scanID)
+	return err
+}
+
+func (s *NaabuScanner) addLog(ctx context.Context, scanID uuid.UUID, level, message string) {
+	query := `INSERT INTO scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.Pool.Exec(ctx, query, uuid.New(), scanID, level, message, time.Now())
+	if err != nil {
+		log.Printf("Failed to add log: %v", err)
+	}
+}
+
+func (s *NaabuScanner) storeResult(ctx context.Context, result *models.ScanResult) error {
+	query := `
+		INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at, honeypot_score, honeypot_reasons)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	honeypotScore := 0
+	var honeypotReasons []string
+	if result.Honeypot != nil {
+		honeypotScore = result.Honeypot.Score
+		honeypotReasons = result.Honeypot.Reasons
+	}
+	_, err := s.db.Pool.Exec(ctx, query,
+		result.ID,
+		result.ScanID,
+		result.Host,
+		result.Hostname,
+		result.State,
+		result.Ports,
+		result.OSDetection,
+		result.Services,
+		result.MacAddress,
+		result.MacVendor,
+		result.CreatedAt,
+		honeypotScore,
+		honeypotReasons,
+	)
+	return err
+}
+
+// GetTemplates returns predefined naabu templates
+func (s *NaabuScanner) GetTemplates() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"naabu_top100": {
+			"name":        "Naabu Top 100 Ports",
+			"description": "SYN scan of the 100 most common ports, verified with a TCP connect",
+			"top_ports":   100,
+			"rate":        NaabuDefaultRate,
+		},
+		"naabu_top1000": {
+			"name":        "Naabu Top 1000 Ports",
+			"description": "SYN scan of the 1000 most common ports, verified with a TCP connect",
+			"top_ports":   1000,
+			"rate":        NaabuDefaultRate,
+		},
+		"naabu_full": {
+			"name":        "Naabu Full Port Scan",
+			"description": "SYN scan of all 65535 ports, verified with a TCP connect",
+			"ports":       "1-65535",
+			"rate":        5000,
+		},
+	}
+}
@@ -0,0 +1,628 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+const (
+	NativeDefaultConcurrency = 200
+	NativeMaxConcurrency     = 2000
+	NativeDefaultTimeout     = 1500 * time.Millisecond
+	nativeMaxHosts           = 65536
+)
+
+// nativeSchemaSQL allows native, pipeline and naabu scans in scans
+const nativeSchemaSQL = `
+ALTER TABLE scans DROP CONSTRAINT IF EXISTS valid_scan_scanner;
+ALTER TABLE scans ADD CONSTRAINT valid_scan_scanner CHECK (scanner IN ('nmap', 'masscan', 'dns', 'native', 'pipeline', 'naabu'))`
+
+// nativeTopPorts are nmap's 100 most frequent TCP ports, most common first
+var nativeTopPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// nativeServiceNames are guesses for open ports whose banner says nothing
+var nativeServiceNames = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 80: "http", 110: "pop3", 111: "rpcbind",
+	135: "msrpc", 139: "netbios-ssn", 143: "imap", 389: "ldap", 443: "https", 445: "microsoft-ds", 465: "smtps",
+	587: "submission", 631: "ipp", 993: "imaps", 995: "pop3s", 1433: "ms-sql-s", 1521: "oracle", 1723: "pptp",
+	2049: "nfs", 3000: "ppp", 3306: "mysql", 3389: "ms-wbt-server", 5432: "postgresql", 5900: "vnc",
+	6379: "redis", 8000: "http-alt", 8080: "http-proxy", 8443: "https-alt", 9200: "elasticsearch", 27017: "mongodb",
+}
+
+// NativeScanner is a pure-Go TCP connect scanner for environments where nmap
+// and masscan can't be installed. It has no service or OS detection beyond
+// banners.
+type NativeScanner struct {
+	db          *database.Database
+	mu          sync.Mutex
+	cancelFuncs map[string]context.CancelFunc
+}
+
+// NativeScanConfig holds the options of a native scan
+type NativeScanConfig struct {
+	Ports       []int
+	Concurrency int           // connections in flight
+	Timeout     time.Duration // connect and banner read timeout
+	Banners     bool          // read (or ask for) a banner on open ports
+	// HostTimeout is the probing time each host may use up, summed over its
+	// probes; the rest of its ports are skipped after that. 0 means no limit.
+	HostTimeout time.Duration
+}
+
+func NewNativeScanner(db *database.Database) *NativeScanner {
+	return &NativeScanner{
+		db:          db,
+		cancelFuncs: make(map[string]context.CancelFunc),
+	}
+}
+
+// EnsureNativeSchema allows the native scanner in the scans table
+func EnsureNativeSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), nativeSchemaSQL); err != nil {
+		return fmt.Errorf("failed to allow native scans: %w", err)
+	}
+	return nil
+}
+
+// NativePorts returns the ports of a "22,80,8000-8100" list, or the topPorts
+// most common ports (100 when both are empty)
+func NativePorts(ports string, topPorts int) ([]int, error) {
+	if ports == "" {
+		if topPorts <= 0 {
+			topPorts = len(nativeTopPorts)
+		}
+		result := append([]int{}, nativeTopPorts...)
+		if topPorts <= len(result) {
+			return result[:topPorts], nil
+		}
+		seen := map[int]bool{}
+		for _, p := range result {
+			seen[p] = true
+		}
+		for p := 1; p <= 65535 && len(result) < topPorts; p++ {
+			if !seen[p] {
+				result = append(result, p)
+			}
+		}
+		return result, nil
+	}
+
+	seen := map[int]bool{}
+	var result []int
+	for _, part := range strings.Split(ports, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		high := low
+		if err == nil && len(bounds) == 2 {
+			high, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		}
+		if err != nil || low < 1 || high > 65535 || high < low {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		for p := low; p <= high; p++ {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// nativeHost is a target address and the name it was given by
+type nativeHost struct {
+	ip       string
+	hostname string
+}
+
+// expandTargets turns IPs, hostnames, CIDRs and last-octet ranges
+// (192.168.1.10-50), separated by commas or spaces, into hosts
+func expandTargets(ctx context.Context, target string) ([]nativeHost, error) {
+	var hosts []nativeHost
+	seen := map[string]bool{}
+	add := func(h nativeHost) error {
+		if seen[h.ip] {
+			return nil
+		}
+		if len(hosts) >= nativeMaxHosts {
+			return fmt.Errorf("target expands to more than %d hosts", nativeMaxHosts)
+		}
+		seen[h.ip] = true
+		hosts = append(hosts, h)
+		return nil
+	}
+
+	for _, part := range strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		switch {
+		case strings.Contains(part, "/"):
+			ip, network, err := net.ParseCIDR(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", part)
+			}
+			ones, bits := network.Mask.Size()
+			if bits-ones > 16 {
+				return nil, fmt.Errorf("CIDR %q is larger than /%d", part, bits-16)
+			}
+			ip = ip.Mask(network.Mask)
+			for cur := ip; network.Contains(cur); cur = nextIP(cur) {
+				// Skip network and broadcast addresses of IPv4 subnets
+				if bits == 32 && ones < 31 && (cur.Equal(ip) || !network.Contains(nextIP(cur))) {
+					continue
+				}
+				if err := add(nativeHost{ip: cur.String()}); err != nil {
+					return nil, err
+				}
+			}
+		case isLastOctetRange(part):
+			dash := strings.LastIndex(part, "-")
+			start := net.ParseIP(part[:dash]).To4()
+			end, err := strconv.Atoi(part[dash+1:])
+			if err != nil || end < int(start[3]) || end > 255 {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			for last := int(start[3]); last <= end; last++ {
+				ip := net.IPv4(start[0], start[1], start[2], byte(last))
+				if err := add(nativeHost{ip: ip.String()}); err != nil {
+					return nil, err
+				}
+			}
+		case net.ParseIP(part) != nil:
+			if err := add(nativeHost{ip: part}); err != nil {
+				return nil, err
+			}
+		default:
+			addrs, err := net.DefaultResolver.LookupHost(ctx, part)
+			if err != nil || len(addrs) == 0 {
+				return nil, fmt.Errorf("failed to resolve %s", part)
+			}
+			if err := add(nativeHost{ip: addrs[0], hostname: part}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no hosts to scan")
+	}
+	return hosts, nil
+}
+
+func isLastOctetRange(part string) bool {
+	dash := strings.LastIndex(part, "-")
+	return dash > 0 && net.ParseIP(part[:dash]).To4() != nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// ExecuteScan connects to every port of every target host and stores the
+// hosts that answered
+func (s *NativeScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, config NativeScanConfig) error {
+	log.Printf("🔌 Starting native scan %s on target: %s (%d ports)", scanID, target, len(config.Ports))
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancelFuncs[scanID.String()] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancelFuncs, scanID.String())
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	if err := s.updateScanStatus(ctx, scanID, "running", 0, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = NativeDefaultConcurrency
+	}
+	if config.Concurrency > NativeMaxConcurrency {
+		config.Concurrency = NativeMaxConcurrency
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = NativeDefaultTimeout
+	}
+
+	hosts, err := expandTargets(ctx, target)
+	if err != nil {
+		errMsg := err.Error()
+		s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
+		s.addLog(ctx, scanID, "error", fmt.Sprintf("Native scan failed: %s", errMsg))
+		return err
+	}
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Starting native TCP connect scan of %d hosts x %d ports (concurrency %d, timeout %s)",
+		len(hosts), len(config.Ports), config.Concurrency, config.Timeout))
+	if config.HostTimeout > 0 {
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Hosts are skipped after %s of probing", config.HostTimeout))
+	}
+
+	type probe struct {
+		host int
+		port int
+	}
+	probes := make(chan probe)
+	go func() {
+		defer close(probes)
+		for _, port := range config.Ports {
+			for i := range hosts {
+				select {
+				case probes <- probe{host: i, port: port}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	up := make([]bool, len(hosts))
+	open := make([][]models.Port, len(hosts))
+	total := len(hosts) * len(config.Ports)
+	done, lastProgress := 0, 0
+	blocks := NewBlockDetector(config.Timeout)
+	tallies := make([]probeTally, len(hosts))
+	spent := make([]time.Duration, len(hosts))
+	probed := make([]int, len(hosts))
+	skipped := make([]bool, len(hosts))
+
+	// advance counts a finished (or skipped) probe, mu held
+	advance := func() {
+		done++
+		progress := done * 100 / total
+		if progress >= lastProgress+5 && progress < 100 {
+			lastProgress = progress
+			s.updateScanStatus(ctx, scanID, "running", progress, nil)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < config.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probes {
+				mu.Lock()
+				if skipped[p.host] {
+					advance()
+					mu.Unlock()
+					continue
+				}
+				mu.Unlock()
+
+				sent := time.Now()
+				port, alive, err := s.probePort(ctx, hosts[p.host].ip, p.port, config)
+				if blocked := blocks.Observe(ctx, hosts[p.host].ip, p.port, alive, sent); blocked != nil {
+					s.reportBlocked(ctx, scanID, hosts[p.host].ip, blocked)
+				}
+
+				mu.Lock()
+				if alive {
+					up[p.host] = true
+				}
+				tallies[p.host].count(alive, err)
+				if port != nil {
+					open[p.host] = append(open[p.host], *port)
+				}
+				spent[p.host] += time.Since(sent)
+				probed[p.host]++
+				if config.HostTimeout > 0 && spent[p.host] >= config.HostTimeout && probed[p.host] < len(config.Ports) {
+					skipped[p.host] = true
+				}
+				advance()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() == context.Canceled {
+		s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
+		return nil
+	}
+
+	// Skipped hosts keep the open ports found before they were skipped
+	skippedHosts := []models.SkippedHost{}
+	for i, host := range hosts {
+		if skipped[i] {
+			skippedHosts = append(skippedHosts, models.SkippedHost{
+				Host:           host.ip,
+				Hostname:       host.hostname,
+				Reason:         SkipHostTimeout,
+				ElapsedSeconds: spent[i].Seconds(),
+				ProbedPorts:    probed[i],
+				TotalPorts:     len(config.Ports),
+			})
+		}
+	}
+	if err := recordSkipped(ctx, s.db, scanID, skippedHosts, s.addLog); err != nil {
+		log.Printf("Failed to record skipped hosts: %v", err)
+	}
+
+	found := 0
+	var tally probeTally
+	for i, host := range hosts {
+		if !up[i] {
+			continue
+		}
+		found++
+		tally.add(tallies[i])
+		if skipped[i] {
+			tally.hostsTimedOut++
+		}
+		ports := open[i]
+		sort.Slice(ports, func(a, b int) bool { return ports[a].Port < ports[b].Port })
+		result := &models.ScanResult{
+			ID:        uuid.New(),
+			ScanID:    scanID,
+			Host:      host.ip,
+			State:     "up",
+			Ports:     ports,
+			Services:  []string{},
+			CreatedAt: time.Now(),
+		}
+		if result.Ports == nil {
+			result.Ports = []models.Port{}
+		}
+		if host.hostname != "" {
+			hostname := host.hostname
+			result.Hostname = &hostname
+		}
+		for _, port := range ports {
+			result.Services = append(result.Services, fmt.Sprintf("%d/%s - %s", port.Port, port.Protocol, port.Service))
+		}
+		result.Honeypot = deception.Assess(result.Ports, deception.Signals{ScannedPorts: len(config.Ports)})
+		if result.Honeypot != nil && result.Honeypot.Likely {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("Host %s looks like a honeypot/tarpit (score %d): %s",
+				result.Host, result.Honeypot.Score, strings.Join(result.Honeypot.Reasons, "; ")))
+		}
+		if err := s.storeResult(ctx, result); err != nil {
+			log.Printf("Failed to store result: %v", err)
+		}
+	}
+
+	tally.blocked = blocks.status != nil
+	if quality := tally.quality(); quality != nil {
+		if err := recordQuality(ctx, s.db, scanID, quality); err != nil {
+			log.Printf("Failed to record scan quality: %v", err)
+		}
+		if quality.Grade != QualityClean {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("Scan quality %s (score %d): %s",
+				quality.Grade, quality.Score, strings.Join(quality.Reasons, "; ")))
+		}
+	}
+
+	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	s.addLog(ctx, scanID, "success", fmt.Sprintf("Native scan completed. Found %d hosts up", found))
+	log.Printf("✅ Native scan %s completed. Found %d hosts", scanID, found)
+	return nil
+}
+
+// reportBlocked records that ip stopped answering mid-scan
+func (s *NativeScanner) reportBlocked(ctx context.Context, scanID uuid.UUID, ip string, status *models.BlockedStatus) {
+	if err := recordBlocked(ctx, s.db, scanID, status); err != nil {
+		log.Printf("Failed to record blocked scan: %v", err)
+	}
+	s.addLog(ctx, scanID, "warning", fmt.Sprintf("Host %s possibly blocked the scan since %s: results after that point are unreliable",
+		ip, status.Since.Format(time.RFC3339)))
+}
+
+// probePort connects to a port. It returns the port when open, whether the
+// host answered at all (a refused connection means the host is up), and the
+// connect error when it didn't.
+func (s *NativeScanner) probePort(ctx context.Context, ip string, port int, config NativeScanConfig) (*models.Port, bool, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	result := &models.Port{
+		Port:     port,
+		Protocol: "tcp",
+		State:    "open",
+		Service:  nativeServiceNames[port],
+	}
+	if config.Banners {
+		identifyBanner(result, grabBanner(conn, config.Timeout))
+	}
+	if result.Service == "" {
+		result.Service = "unknown"
+	}
+	return result, true, nil
+}
+
+// grabBanner reads what the service sends first; silent services get an
+// HTTP request, which most of them answer one way or another
+func grabBanner(conn net.Conn, timeout time.Duration) string {
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	n, _ := conn.Read(buf)
+	if n == 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err != nil {
+			return ""
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		n, _ = conn.Read(buf)
+	}
+	return string(buf[:n])
+}
+
+// identifyBanner sets service, product and version from well-known banners
+// and keeps the first banner line as extra info
+func identifyBanner(port *models.Port, banner string) {
+	if banner == "" {
+		return
+	}
+	lines := strings.Split(banner, "\n")
+	first := strings.TrimSpace(lines[0])
+
+	switch {
+	case strings.HasPrefix(first, "SSH-"):
+		port.Service = "ssh"
+		// SSH-2.0-OpenSSH_8.9p1 Ubuntu-3
+		software := strings.SplitN(first, "-", 3)
+		if len(software) == 3 {
+			if fields := strings.Fields(software[2]); len(fields) > 0 {
+				port.Product, port.Version, _ = strings.Cut(fields[0], "_")
+			}
+		}
+	case strings.HasPrefix(first, "HTTP/"):
+		if port.Service == "" || !strings.HasPrefix(port.Service, "http") {
+			port.Service = "http"
+		}
+		for _, line := range lines[1:] {
+			if name, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && strings.EqualFold(name, "server") {
+				product, version, _ := strings.Cut(strings.TrimSpace(value), "/")
+				if fields := strings.Fields(version); len(fields) > 0 {
+					version = fields[0]
+				}
+				port.Product, port.Version = product, version
+				break
+			}
+		}
+	case strings.HasPrefix(first, "220") && strings.Contains(strings.ToUpper(first), "FTP"):
+		port.Service = "ftp"
+	case strings.HasPrefix(first, "220") && strings.Contains(strings.ToUpper(first), "SMTP"):
+		port.Service = "smtp"
+	case strings.HasPrefix(first, "+OK"):
+		port.Service = "pop3"
+	case strings.HasPrefix(first, "* OK"):
+		port.Service = "imap"
+	case strings.HasPrefix(first, "RFB "):
+		port.Service = "vnc"
+	case strings.Contains(banner, "mysql_native_password") || strings.Contains(banner, "caching_sha2_password"):
+		port.Service = "mysql"
+	}
+
+	// Keep printable characters only, binary protocols produce noise
+	info := strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, first)
+	if len(info) > 120 {
+		info = info[:120]
+	}
+	port.ExtraInfo = info
+}
+
+// CancelScan cancels a running native scan
+func (s *NativeScanner) CancelScan(scanID string) {
+	s.mu.Lock()
+	cancel, ok := s.cancelFuncs[scanID]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+		log.Printf("🛑 Cancelled native scan %s", scanID)
+	}
+}
+
+func (s *NativeScanner) updateScanStatus(ctx context.Context, scanID uuid.UUID, status string, progress int, errorMsg *string) error {
+	query := `
+		UPDATE scans
+		SET status = $1, progress = $2, error_message = $3,
+		    started_at = CASE WHEN $4 = 'running' AND started_at IS NULL THEN NOW() ELSE started_at END,
+		    completed_at = CASE WHEN $5 IN ('completed', 'failed') THEN NOW() ELSE completed_at END
+		WHERE id = $6
+	`
+	_, err := s.db.Pool.Exec(ctx, query, status, progress, errorMsg, status, status, scanID)
+	return err
+}
+
+func (s *NativeScanner) addLog(ctx context.Context, scanID uuid.UUID, level, message string) {
+	query := `INSERT INTO scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.Pool.Exec(ctx, query, uuid.New(), scanID, level, message, time.Now())
+	if err != nil {
+		log.Printf("Failed to add log: %v", err)
+	}
+}
+
+func (s *NativeScanner) storeResult(ctx context.Context, result *models.ScanResult) error {
+	query := `
+		INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at, honeypot_score, honeypot_reasons)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	honeypotScore := 0
+	var honeypotReasons []string
+	if result.Honeypot != nil {
+		honeypotScore = result.Honeypot.Score
+		honeypotReasons = result.Honeypot.Reasons
+	}
+	_, err := s.db.Pool.Exec(ctx, query,
+		result.ID,
+		result.ScanID,
+		result.Host,
+		result.Hostname,
+		result.State,
+		result.Ports,
+		result.OSDetection,
+		result.Services,
+		result.MacAddress,
+		result.MacVendor,
+		result.CreatedAt,
+		honeypotScore,
+		honeypotReasons,
+	)
+	return err
+}
+
+// GetTemplates returns predefined native scan templates
+func (s *NativeScanner) GetTemplates() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"native_quick": {
+			"name":        "Native Quick Scan",
+			"description": "TCP connect scan of the 100 most common ports without nmap",
+			"ports":       "",
+		},
+		"native_web": {
+			"name":        "Native Web Ports",
+			"description": "TCP connect scan of common web server ports without nmap",
+			"ports":       "80,443,8080,8443,8000,8888,9000,9090,3000,5000",
+		},
+		"native_full": {
+			"name":        "Native Full Port Scan",
+			"description": "TCP connect scan of all 65535 ports without nmap (slow)",
+			"ports":       "1-65535",
+		},
+	}
+}
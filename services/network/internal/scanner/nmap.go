@@ -1,35 +1,80 @@
 package scanner
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Ullaakut/nmap/v3"
 	"github.com/google/uuid"
-	"github.com/nmap-scanner/backend-go/internal/database"
-	"github.com/nmap-scanner/backend-go/internal/models"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/kubejobs"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/network-service/internal/rawoutput"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
 )
 
 type Scanner struct {
 	db            *database.Database
 	useSystemNmap bool
 	nmapPath      string
+	pathMu        sync.RWMutex
+	sandbox       *sandbox.Sandbox
+	udpProber     *UDPProber
+	kube          *kubejobs.Backend
+	retry         supervise.Policy
 	cancelFuncs   map[string]context.CancelFunc
 }
 
-func NewScanner(db *database.Database, useSystemNmap bool, nmapPath string) *Scanner {
+func NewScanner(db *database.Database, useSystemNmap bool, nmapPath string, sb *sandbox.Sandbox) *Scanner {
 	return &Scanner{
 		db:            db,
 		useSystemNmap: useSystemNmap,
 		nmapPath:      nmapPath,
+		sandbox:       sb,
 		cancelFuncs:   make(map[string]context.CancelFunc),
 	}
 }
 
+// SetNmapPath changes the nmap binary used by scans started afterwards
+func (s *Scanner) SetNmapPath(path string) {
+	s.pathMu.Lock()
+	s.nmapPath = path
+	s.pathMu.Unlock()
+}
+
+// SetUDPProber enables confirming open|filtered UDP ports after UDP scans
+func (s *Scanner) SetUDPProber(p *UDPProber) {
+	s.udpProber = p
+}
+
+// SetKubernetes runs nmap as a Kubernetes Job when the backend has a
+// profile for it
+func (s *Scanner) SetKubernetes(b *kubejobs.Backend) {
+	s.kube = b
+}
+
+// SetRetryPolicy retries nmap runs that failed transiently
+func (s *Scanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+func (s *Scanner) currentNmapPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.nmapPath
+}
+
 // ExecuteScan runs an nmap scan and stores results
 func (s *Scanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, arguments string) error {
 	log.Printf("🔍 Starting scan %s on target: %s with args: %s", scanID, target, arguments)
@@ -50,14 +95,16 @@ func (s *Scanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target stri
 	// Add log entry
 	s.addLog(ctx, scanID, "info", fmt.Sprintf("Starting scan on target: %s", target))
 
-	var results []models.ScanResult
-	var scanErr error
-
-	if s.useSystemNmap {
-		results, scanErr = s.runSystemNmap(ctx, scanID, target, arguments)
-	} else {
-		results, scanErr = s.runGonmap(ctx, scanID, target, arguments)
-	}
+	var run *nmap.Run
+	var stderr []string
+	scanErr := s.retry.Run(ctx, func(ctx context.Context) error {
+		var err error
+		run, stderr, err = s.runNmap(ctx, scanID, []string{target}, arguments)
+		return err
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("nmap", a)
+		s.addLog(ctx, scanID, level, message)
+	})
 
 	// Check if context was cancelled
 	if ctx.Err() == context.Canceled {
@@ -72,6 +119,16 @@ func (s *Scanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target stri
 		return scanErr
 	}
 
+	s.assessRun(ctx, scanID, run, stderr, arguments)
+	results := s.parseGonmapResults(run)
+
+	// Confirm UDP services nmap could not tell apart from filtered ports
+	if s.udpProber != nil && IsUDPScan(arguments) {
+		if confirmed := s.udpProber.Probe(ctx, results); confirmed > 0 {
+			s.addLog(ctx, scanID, "info", fmt.Sprintf("UDP probes confirmed %d services", confirmed))
+		}
+	}
+
 	// Store results in database
 	if err := s.storeResults(ctx, scanID, results); err != nil {
 		log.Printf("Failed to store results: %v", err)
@@ -96,69 +153,211 @@ func (s *Scanner) CancelScan(scanID string) {
 	}
 }
 
+// runNmap runs nmap once against targets, as a Kubernetes Job, through the
+// system binary or the library, and returns its report and stderr lines
+func (s *Scanner) runNmap(ctx context.Context, scanID uuid.UUID, targets []string, arguments string) (*nmap.Run, []string, error) {
+	var run *nmap.Run
+	var stderr []string
+	var err error
+	switch {
+	case s.kube.Handles("nmap"):
+		run, stderr, err = s.runKubeNmap(ctx, scanID, targets, arguments)
+	case s.useSystemNmap:
+		run, stderr, err = s.runSystemNmap(ctx, scanID, targets, arguments)
+	default:
+		run, stderr, err = s.runGonmap(ctx, targets, arguments)
+	}
+	if err == nil {
+		s.saveRawOutput(ctx, scanID, run)
+	}
+	return run, stderr, err
+}
+
+// saveRawOutput keeps the XML report of an nmap run for the scan's evidence
+// bundle
+func (s *Scanner) saveRawOutput(ctx context.Context, scanID uuid.UUID, run *nmap.Run) {
+	output, err := io.ReadAll(run.ToReader())
+	if err == nil {
+		err = rawoutput.Save(ctx, s.db, scanID, "nmap", "xml", output)
+	}
+	if err != nil {
+		log.Printf("Failed to save nmap output of scan %s: %v", scanID, err)
+	}
+}
+
 // runGonmap executes scan using gonmap library
-func (s *Scanner) runGonmap(ctx context.Context, scanID uuid.UUID, target string, arguments string) ([]models.ScanResult, error) {
+func (s *Scanner) runGonmap(ctx context.Context, targets []string, arguments string) (*nmap.Run, []string, error) {
 	log.Println("Using gonmap library for scan")
 
 	// Parse arguments
 	args := strings.Fields(arguments)
-	args = append(args, target)
+	args = append(args, targets...)
 
 	// Create scanner
 	scanner, err := nmap.NewScanner(
 		ctx,
-		nmap.WithTargets(target),
+		nmap.WithTargets(targets...),
 		nmap.WithCustomArguments(args...),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create nmap scanner: %w", err)
+		return nil, nil, fmt.Errorf("failed to create nmap scanner: %w", err)
 	}
 
 	// Run scan
 	result, warnings, err := scanner.Run()
 	if err != nil {
-		return nil, fmt.Errorf("nmap scan failed: %w", err)
+		return nil, nil, fmt.Errorf("nmap scan failed: %w", err)
 	}
 
+	var stderr []string
 	if warnings != nil {
 		log.Printf("⚠️  Nmap warnings: %v", warnings)
+		stderr = *warnings
 	}
-
-	// Parse results
-	return s.parseGonmapResults(result), nil
+	return result, stderr, nil
 }
 
 // runSystemNmap executes system nmap command
-func (s *Scanner) runSystemNmap(ctx context.Context, scanID uuid.UUID, target string, arguments string) ([]models.ScanResult, error) {
-	log.Printf("Using system nmap at: %s", s.nmapPath)
+func (s *Scanner) runSystemNmap(ctx context.Context, scanID uuid.UUID, targets []string, arguments string) (*nmap.Run, []string, error) {
+	nmapPath := s.currentNmapPath()
+	log.Printf("Using system nmap at: %s", nmapPath)
 
 	// Build command
 	args := strings.Fields(arguments)
 	args = append(args, "-oX", "-") // Output XML to stdout
-	args = append(args, target)
+	args = append(args, targets...)
 
-	cmd := exec.CommandContext(ctx, s.nmapPath, args...)
+	cmd := s.sandbox.Command(ctx, "nmap", nmapPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("system nmap failed: %w", err)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = stderr.Bytes()
+		}
+		s.logSandboxViolations(ctx, scanID, err)
+		return nil, nil, fmt.Errorf("system nmap failed: %w", err)
+	}
+
+	return parseXML(output, stderr.String())
+}
+
+// runKubeNmap runs nmap as a Kubernetes Job with the same arguments as the
+// system binary
+func (s *Scanner) runKubeNmap(ctx context.Context, scanID uuid.UUID, targets []string, arguments string) (*nmap.Run, []string, error) {
+	args := strings.Fields(arguments)
+	args = append(args, "-oX", "-")
+	args = append(args, targets...)
+
+	job, err := s.kube.Start(ctx, "nmap", args)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Running as Kubernetes job %s", job.Name()))
+
+	output, err := job.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("nmap job failed: %w", err)
+	}
+	return parseXML(output, job.Stderr())
+}
+
+// parseXML parses the XML report of an nmap run
+func parseXML(output []byte, stderr string) (*nmap.Run, []string, error) {
+	var result nmap.Run
+	if err := nmap.Parse(output, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse nmap output: %w", err)
+	}
+	return &result, strings.Split(stderr, "\n"), nil
+}
+
+// assessRun flags the scan when its hosts stopped answering mid-run,
+// records the hosts skipped at --host-timeout and scores the quality of its
+// results
+func (s *Scanner) assessRun(ctx context.Context, scanID uuid.UUID, result *nmap.Run, stderr []string, arguments string) {
+	if err := recordSkipped(ctx, s.db, scanID, nmapSkippedHosts(result), s.addLog); err != nil {
+		log.Printf("Failed to record skipped hosts: %v", err)
+	}
+
+	blocked := NmapBlocked(ctx, result)
+	if blocked != nil {
+		if err := recordBlocked(ctx, s.db, scanID, blocked); err != nil {
+			log.Printf("Failed to record blocked scan: %v", err)
+		}
+		s.addLog(ctx, scanID, "warning", fmt.Sprintf("Scan possibly blocked since %s (%s): results after that point are unreliable",
+			blocked.Since.Format(time.RFC3339), blocked.Reason))
 	}
 
-	// Parse XML output using gonmap
+	tally := nmapTally(result, stderr, IsUDPScan(arguments))
+	tally.blocked = blocked != nil
+	if quality := tally.quality(); quality != nil {
+		if err := recordQuality(ctx, s.db, scanID, quality); err != nil {
+			log.Printf("Failed to record scan quality: %v", err)
+		}
+		if quality.Grade != QualityClean {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("Scan quality %s (score %d): %s",
+				quality.Grade, quality.Score, strings.Join(quality.Reasons, "; ")))
+		}
+	}
+}
+
+// ImportResults stores the nmap XML report of a scan that ran elsewhere (a
+// remote agent) and completes the scan
+func (s *Scanner) ImportResults(ctx context.Context, scanID uuid.UUID, output []byte, source string) error {
 	var result nmap.Run
 	if err := nmap.Parse(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse nmap output: %w", err)
+		errMsg := fmt.Sprintf("failed to parse nmap output: %v", err)
+		s.FailScan(ctx, scanID, errMsg)
+		return errors.New(errMsg)
 	}
+	s.saveRawOutput(ctx, scanID, &result)
 
-	return s.parseGonmapResults(&result), nil
+	if err := recordSkipped(ctx, s.db, scanID, nmapSkippedHosts(&result), s.addLog); err != nil {
+		log.Printf("Failed to record skipped hosts: %v", err)
+	}
+	results := s.parseGonmapResults(&result)
+	if err := s.storeResults(ctx, scanID, results); err != nil {
+		s.FailScan(ctx, scanID, err.Error())
+		return err
+	}
+	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	s.addLog(ctx, scanID, "success", fmt.Sprintf("Scan completed by %s. Found %d hosts", source, len(results)))
+	return nil
+}
+
+// FailScan marks a scan as failed
+func (s *Scanner) FailScan(ctx context.Context, scanID uuid.UUID, errMsg string) {
+	s.updateScanStatus(ctx, scanID, "failed", 0, &errMsg)
+	s.addLog(ctx, scanID, "error", fmt.Sprintf("Scan failed: %s", errMsg))
 }
 
-// parseGonmapResults converts gonmap results to our models
+// logSandboxViolations writes sandbox denials from a failed nmap run to the scan logs
+func (s *Scanner) logSandboxViolations(ctx context.Context, scanID uuid.UUID, err error) {
+	if msg, ok := sandbox.Violation(err); ok {
+		s.addLog(ctx, scanID, "error", "Sandbox violation: "+msg)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		for _, line := range strings.Split(string(exitErr.Stderr), "\n") {
+			if sandbox.ViolationLine(line) {
+				s.addLog(ctx, scanID, "error", "Sandbox violation: "+line)
+			}
+		}
+	}
+}
+
+// parseGonmapResults converts gonmap results to our models. Hosts that hit
+// --host-timeout are left out: nmap reports none of their ports.
 func (s *Scanner) parseGonmapResults(result *nmap.Run) []models.ScanResult {
 	var results []models.ScanResult
 
 	for _, host := range result.Hosts {
-		if len(host.Addresses) == 0 {
+		if len(host.Addresses) == 0 || host.TimedOut {
 			continue
 		}
 
@@ -206,10 +405,27 @@ func (s *Scanner) parseGonmapResults(result *nmap.Run) []models.ScanResult {
 			}
 
 			scanResult.Ports = append(scanResult.Ports, portInfo)
+			scanResult.Vulnerabilities = append(scanResult.Vulnerabilities,
+				scriptVulnerabilities(port.Scripts, int(port.ID), port.Protocol)...)
 			scanResult.Services = append(scanResult.Services,
 				fmt.Sprintf("%d/%s - %s", port.ID, port.Protocol, port.Service.Name))
 		}
 
+		scanResult.Vulnerabilities = append(scanResult.Vulnerabilities, scriptVulnerabilities(host.HostScripts, 0, "")...)
+
+		// Honeypot/tarpit heuristics; nmap reports times in microseconds
+		signals := deception.Signals{ScannedPorts: len(host.Ports)}
+		for _, extra := range host.ExtraPorts {
+			signals.ScannedPorts += extra.Count
+		}
+		if srtt, err := strconv.Atoi(host.Times.SRTT); err == nil {
+			signals.SRTT = time.Duration(srtt) * time.Microsecond
+		}
+		if rttvar, err := strconv.Atoi(host.Times.RTT); err == nil {
+			signals.RTTVar = time.Duration(rttvar) * time.Microsecond
+		}
+		scanResult.Honeypot = deception.Assess(scanResult.Ports, signals)
+
 		results = append(results, scanResult)
 	}
 
@@ -246,10 +462,21 @@ func (s *Scanner) storeResults(ctx context.Context, scanID uuid.UUID, results []
 		result.CreatedAt = time.Now()
 
 		query := `
-			INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			INSERT INTO scan_results (id, scan_id, host, hostname, state, ports, os_detection, services, mac_address, mac_vendor, created_at, honeypot_score, honeypot_reasons, vulnerabilities)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		`
 
+		honeypotScore := 0
+		var honeypotReasons []string
+		if result.Honeypot != nil {
+			honeypotScore = result.Honeypot.Score
+			honeypotReasons = result.Honeypot.Reasons
+			if result.Honeypot.Likely {
+				s.addLog(ctx, scanID, "warning", fmt.Sprintf("Host %s looks like a honeypot/tarpit (score %d): %s",
+					result.Host, result.Honeypot.Score, strings.Join(result.Honeypot.Reasons, "; ")))
+			}
+		}
+
 		_, err := s.db.Pool.Exec(ctx, query,
 			result.ID,
 			result.ScanID,
@@ -262,6 +489,9 @@ func (s *Scanner) storeResults(ctx context.Context, scanID uuid.UUID, results []
 			result.MacAddress,
 			result.MacVendor,
 			result.CreatedAt,
+			honeypotScore,
+			honeypotReasons,
+			result.Vulnerabilities,
 		)
 
 		if err != nil {
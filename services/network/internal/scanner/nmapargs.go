@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// nmapFlagsBlocked are the long options, without dashes, that read or write
+// files on the scanner host or make nmap interactive; they are rejected for
+// everyone. nmap parses arguments with getopt_long_only, so each of them can
+// also be written with a single dash or abbreviated.
+var nmapFlagsBlocked = map[string]bool{
+	"oN": true, "oX": true, "oS": true, "oG": true, "oA": true, "oM": true,
+	"resume": true, "stylesheet": true, "webxml": true, "append-output": true,
+	"iL": true, "excludefile": true, "datadir": true, "servicedb": true,
+	"versiondb": true, "script-args-file": true, "script-updatedb": true,
+	"interactive": true, "log-errors": true,
+}
+
+// nmapShortWithValue are nmap's short options that take a value, which
+// consumes the rest of a bundle like -p22 or -noN/tmp/x
+const nmapShortWithValue = "bDdegiMmOoPpSsTv"
+
+// nmapFlagsAllowed are the flags anyone may use. true means the flag takes a value.
+var nmapFlagsAllowed = map[string]bool{
+	// scan types and detection
+	"-sS": false, "-sT": false, "-sU": false, "-sA": false, "-sW": false, "-sN": false,
+	"-sF": false, "-sX": false, "-sn": false, "-sV": false, "-sC": false, "-O": false,
+	"-A": false, "--osscan-guess": false, "--osscan-limit": false, "--traceroute": false,
+	"--version-light": false, "--version-all": false, "--version-intensity": true,
+	// host discovery
+	"-Pn": false, "-PE": false, "-PP": false, "-PM": false, "-n": false, "-R": false,
+	"--disable-arp-ping": false,
+	// ports
+	"-p": true, "-F": false, "-r": false, "--top-ports": true, "--port-ratio": true,
+	"--exclude-ports": true, "--exclude": true, "-6": false,
+	// timing
+	"-T0": false, "-T1": false, "-T2": false, "-T3": false, "-T4": false, "-T5": false,
+	"--min-rate": true, "--max-rate": true, "--max-retries": true, "--host-timeout": true,
+	"--scan-delay": true, "--max-scan-delay": true, "--min-parallelism": true,
+	"--max-parallelism": true, "--min-hostgroup": true, "--max-hostgroup": true,
+	"--min-rtt-timeout": true, "--max-rtt-timeout": true, "--initial-rtt-timeout": true,
+	// output verbosity (results always go to XML on stdout)
+	"-v": false, "-vv": false, "--open": false, "--reason": false, "--script": true,
+}
+
+// nmapScriptCategories may be used with --script without admin rights
+var nmapScriptCategories = map[string]bool{
+	"default": true, "safe": true, "discovery": true, "version": true, "vuln": true,
+}
+
+// nmapPingProbe matches -PS, -PA, -PU and -PY with an optional port list
+var nmapPingProbe = regexp.MustCompile(`^-P[SAUY][0-9,\-]*$`)
+
+// nmapScriptName matches a script or category name, without paths or wildcards
+var nmapScriptName = regexp.MustCompile(`^[a-z0-9][a-z0-9\-]*$`)
+
+// ValidateNmapArguments checks user-supplied nmap arguments. It returns an
+// error for flags that are never allowed, and the flags outside the safe
+// allow-list, which only admins may use.
+func ValidateNmapArguments(arguments string) (privileged []string, err error) {
+	args := strings.Fields(arguments)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, hasValue := strings.Cut(arg, "=")
+
+		if !strings.HasPrefix(arg, "-") {
+			// Targets are set by the scan request, not the arguments
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		if nmapBlocked(arg, next) {
+			return nil, fmt.Errorf("argument %s is not allowed", flag)
+		}
+
+		takesValue, allowed := nmapFlagsAllowed[flag]
+		switch {
+		case allowed && takesValue && !hasValue:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("argument %s requires a value", flag)
+			}
+			i++
+			value = args[i]
+		case !allowed && strings.HasPrefix(flag, "-p") && !strings.HasPrefix(flag, "--"):
+			// -p22,80 with the value attached
+			allowed = true
+		case !allowed && nmapPingProbe.MatchString(flag):
+			allowed = true
+		}
+
+		if flag == "--script" {
+			scriptPrivileged, err := validateNmapScripts(value)
+			if err != nil {
+				return nil, err
+			}
+			if scriptPrivileged {
+				privileged = append(privileged, "--script "+value)
+			}
+			continue
+		}
+		if !allowed {
+			// Unknown flags may take a value; keep it with the flag
+			if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				arg += " " + args[i]
+			}
+			privileged = append(privileged, arg)
+		}
+	}
+	return privileged, nil
+}
+
+// nmapBlocked reports whether arg, followed by next, would make nmap read or
+// write files: a blocked long option, written with one or two dashes or
+// abbreviated, or a short option bundle containing -o or -iL
+func nmapBlocked(arg, next string) bool {
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		return nmapLongBlocked(name)
+	}
+	name := arg[1:]
+	if len(name) > 1 && nmapLongBlocked(name) {
+		return true
+	}
+	for j := 0; j < len(name); j++ {
+		switch c := name[j]; {
+		case c == 'o':
+			return true
+		case c == 'i':
+			value := name[j+1:]
+			if value == "" {
+				value = next
+			}
+			return strings.HasPrefix(value, "L")
+		case strings.IndexByte(nmapShortWithValue, c) >= 0:
+			return false
+		}
+	}
+	return false
+}
+
+// nmapLongBlocked reports whether getopt could read name as a blocked long
+// option. An exact match with an allowed option wins over abbreviations, so
+// --exclude and --script stay usable.
+func nmapLongBlocked(name string) bool {
+	name, _, _ = strings.Cut(name, "=")
+	if name == "" {
+		return false
+	}
+	if _, allowed := nmapFlagsAllowed["--"+name]; allowed {
+		return false
+	}
+	for blocked := range nmapFlagsBlocked {
+		if strings.HasPrefix(blocked, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNmapScripts rejects script paths and reports whether the selection
+// goes beyond the safe categories
+func validateNmapScripts(value string) (bool, error) {
+	if value == "" {
+		return false, fmt.Errorf("argument --script requires a value")
+	}
+	privileged := false
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") || strings.HasSuffix(name, ".nse") {
+			return false, fmt.Errorf("script %q is not allowed: only installed scripts can be used", name)
+		}
+		if !nmapScriptName.MatchString(name) {
+			// Boolean expressions and wildcards are admin-only
+			privileged = true
+			continue
+		}
+		if !nmapScriptCategories[name] {
+			privileged = true
+		}
+	}
+	return privileged, nil
+}
@@ -0,0 +1,38 @@
+package scanner
+
+import "testing"
+
+func TestBlocksFileArgumentsInAnyForm(t *testing.T) {
+	for _, arguments := range []string{
+		"-oN /tmp/x",
+		"-noN/tmp/x",
+		"-RoX /tmp/x",
+		"-n -iL /etc/passwd",
+		"-niL/etc/passwd",
+		"-ni L/etc/passwd",
+		"-resume /tmp/x",
+		"--resu /tmp/x",
+		"--oN=/tmp/x",
+		"-datadir /tmp",
+		"--excludef /etc/passwd",
+		"--script-args-f=/etc/passwd",
+		"-n=oN",
+	} {
+		if _, err := ValidateNmapArguments(arguments); err == nil {
+			t.Errorf("%q was accepted", arguments)
+		}
+	}
+}
+
+func TestAllowsSafeArguments(t *testing.T) {
+	for _, arguments := range []string{
+		"-sV -Pn -T4 -p22,80",
+		"-sS -n -vv --open --reason",
+		"-PS22,80 -iR 0 -O",
+		"--exclude 10.0.0.1 --script default,safe",
+	} {
+		if _, err := ValidateNmapArguments(arguments); err != nil {
+			t.Errorf("%q: %v", arguments, err)
+		}
+	}
+}
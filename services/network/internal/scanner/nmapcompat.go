@@ -0,0 +1,143 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// nmapDeprecated are options of older nmap releases that current ones
+// renamed or dropped, and their replacement
+var nmapDeprecated = map[string]string{
+	"-sP": "-sn",
+	"-P0": "-Pn",
+	"-PN": "-Pn",
+	"-sR": "-sV",
+	"-PT": "-PA",
+	"-PI": "-PE",
+	"-PB": "-PE -PA",
+}
+
+// nmapAllCategories are the script categories nmap ships, valid in --script
+// on any installation
+var nmapAllCategories = map[string]bool{
+	"all": true, "auth": true, "broadcast": true, "brute": true, "default": true,
+	"discovery": true, "dos": true, "exploit": true, "external": true, "fuzzer": true,
+	"intrusive": true, "malware": true, "safe": true, "version": true, "vuln": true,
+}
+
+// scriptDBEntry matches the script names of script.db:
+// Entry { filename = "http-title.nse", categories = { "default", "discovery", "safe", } }
+var scriptDBEntry = regexp.MustCompile(`filename\s*=\s*"([^"]+)\.nse"`)
+
+// NmapInstall is what the installed nmap supports
+type NmapInstall struct {
+	Version string          // first line of nmap --version, empty when nmap didn't run
+	Scripts map[string]bool // installed NSE scripts; nil when script.db wasn't found
+}
+
+// Install inspects the nmap binary scans run with
+func (s *Scanner) Install() NmapInstall {
+	path := s.currentNmapPath()
+	if path == "" {
+		path = "nmap"
+	}
+	install := NmapInstall{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, path, "--version").Output(); err == nil {
+		line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+		install.Version = strings.TrimSpace(line)
+	}
+
+	for _, dir := range nmapDataDirs(path) {
+		if scripts, err := readScriptDB(filepath.Join(dir, "scripts", "script.db")); err == nil {
+			install.Scripts = scripts
+			break
+		}
+	}
+	return install
+}
+
+// nmapDataDirs are where nmap looks for its data files: $NMAPDIR, next to
+// the binary's prefix, then the usual install locations
+func nmapDataDirs(binary string) []string {
+	var dirs []string
+	if dir := os.Getenv("NMAPDIR"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if resolved, err := exec.LookPath(binary); err == nil {
+		if real, err := filepath.EvalSymlinks(resolved); err == nil {
+			resolved = real
+		}
+		dirs = append(dirs, filepath.Join(filepath.Dir(resolved), "..", "share", "nmap"))
+	}
+	return append(dirs, "/usr/share/nmap", "/usr/local/share/nmap")
+}
+
+func readScriptDB(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scripts := map[string]bool{}
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		if m := scriptDBEntry.FindStringSubmatch(lines.Text()); m != nil {
+			scripts[m[1]] = true
+		}
+	}
+	return scripts, lines.Err()
+}
+
+// CheckNmapArguments reports why arguments would fail, or behave
+// differently than intended, with the installed nmap: rejected arguments,
+// options replaced in current releases and scripts that aren't installed
+func CheckNmapArguments(arguments string, install NmapInstall) []models.TemplateHint {
+	var hints []models.TemplateHint
+	if _, err := ValidateNmapArguments(arguments); err != nil {
+		hints = append(hints, models.TemplateHint{Kind: models.HintInvalidArguments, Message: err.Error()})
+	}
+
+	args := strings.Fields(arguments)
+	for i, arg := range args {
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if replacement, ok := nmapDeprecated[flag]; ok {
+			hints = append(hints, models.TemplateHint{
+				Kind:    models.HintDeprecatedArgument,
+				Message: fmt.Sprintf("%s is deprecated in current nmap releases, use %s", flag, replacement),
+			})
+		}
+		if flag != "--script" || install.Scripts == nil {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			// Expressions and wildcards can't be checked by name
+			if !nmapScriptName.MatchString(name) || nmapAllCategories[name] {
+				continue
+			}
+			if !install.Scripts[name] {
+				hints = append(hints, models.TemplateHint{
+					Kind:    models.HintMissingScript,
+					Message: fmt.Sprintf("script %s is not installed in this nmap", name),
+				})
+			}
+		}
+	}
+	return hints
+}
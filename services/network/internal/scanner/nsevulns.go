@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ullaakut/nmap/v3"
+	"github.com/security-scanner/network-service/internal/models"
+	shared "github.com/security-scanner/shared/pkg/models"
+)
+
+// riskFactorPattern reads the risk factor from the text output of scripts
+// built on the vulns library, whose XML doesn't always carry it
+var riskFactorPattern = regexp.MustCompile(`(?i)Risk factor:\s*(\w+)`)
+
+// scriptVulnerabilities returns the vulnerabilities reported by the NSE
+// scripts of a host (port 0) or one of its ports: scripts of the vuln
+// category, which use the vulns library, and vulners' CVE lists
+func scriptVulnerabilities(scripts []nmap.Script, port int, protocol string) []models.ScriptVulnerability {
+	var vulns []models.ScriptVulnerability
+	for _, script := range scripts {
+		if script.ID == "vulners" {
+			vulns = append(vulns, vulnersEntries(script, port, protocol)...)
+			continue
+		}
+		for _, table := range script.Tables {
+			vuln, ok := vulnsLibEntry(script, table)
+			if !ok {
+				continue
+			}
+			vuln.Port, vuln.Protocol = port, protocol
+			vulns = append(vulns, vuln)
+		}
+	}
+	return vulns
+}
+
+// vulnsLibEntry reads a vulnerability table of the vulns library; only
+// vulnerable states ("VULNERABLE", "LIKELY VULNERABLE", "VULNERABLE
+// (Exploitable)") are kept
+func vulnsLibEntry(script nmap.Script, table nmap.Table) (models.ScriptVulnerability, bool) {
+	vuln := models.ScriptVulnerability{Script: script.ID, ID: table.Key}
+	risk := ""
+	for _, elem := range table.Elements {
+		switch elem.Key {
+		case "title":
+			vuln.Title = elem.Value
+		case "state":
+			vuln.State = elem.Value
+		case "risk_factor":
+			risk = elem.Value
+		}
+	}
+	for _, sub := range table.Tables {
+		if sub.Key != "ids" {
+			continue
+		}
+		for _, elem := range sub.Elements {
+			// "CVE:CVE-2014-0160", "BID:70574"
+			_, id, _ := strings.Cut(elem.Value, ":")
+			vuln.IDs = append(vuln.IDs, id)
+		}
+	}
+	state := strings.ToUpper(vuln.State)
+	if vuln.Title == "" || !strings.Contains(state, "VULNERABLE") || strings.Contains(state, "NOT VULNERABLE") {
+		return vuln, false
+	}
+
+	if risk == "" {
+		if m := riskFactorPattern.FindStringSubmatch(script.Output); m != nil {
+			risk = m[1]
+		}
+	}
+	switch {
+	case risk != "":
+		vuln.Severity = shared.NormalizeSeverity(risk)
+	case strings.Contains(state, "EXPLOITABLE"):
+		vuln.Severity = shared.SeverityHigh
+	default:
+		vuln.Severity = shared.SeverityMedium
+	}
+	for _, id := range vuln.IDs {
+		if strings.HasPrefix(id, "CVE-") {
+			vuln.ID = id
+			break
+		}
+	}
+	return vuln, true
+}
+
+// vulnersEntries reads the CVEs vulners lists per CPE of the service
+func vulnersEntries(script nmap.Script, port int, protocol string) []models.ScriptVulnerability {
+	var vulns []models.ScriptVulnerability
+	for _, cpe := range script.Tables {
+		for _, entry := range cpe.Tables {
+			vuln := models.ScriptVulnerability{Script: script.ID, Port: port, Protocol: protocol}
+			for _, elem := range entry.Elements {
+				switch elem.Key {
+				case "id":
+					vuln.ID = elem.Value
+				case "cvss":
+					vuln.CVSS, _ = strconv.ParseFloat(elem.Value, 64)
+				}
+			}
+			if vuln.ID == "" {
+				continue
+			}
+			vuln.Title = vuln.ID + " in " + cpe.Key
+			vuln.Severity = shared.SeverityFromCVSS(vuln.CVSS)
+			vulns = append(vulns, vuln)
+		}
+	}
+	return vulns
+}
@@ -0,0 +1,321 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Ullaakut/nmap/v3"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/deception"
+	"github.com/security-scanner/network-service/internal/models"
+	"github.com/security-scanner/shared/pkg/supervise"
+)
+
+const (
+	// pipelineDiscoveryProgress is the progress of a pipeline scan once
+	// masscan finished; nmap's runs take it to 100
+	pipelineDiscoveryProgress = 30
+	// pipelineMaxHosts is how many hosts one nmap run of a pipeline scans
+	pipelineMaxHosts = 64
+	// PipelineDefaultArguments are the nmap arguments of pipeline scans that
+	// don't set them
+	PipelineDefaultArguments = "-sV -T4"
+)
+
+// PipelineScanner chains masscan and nmap in one scan: masscan finds the
+// open ports fast, then nmap -sV identifies the services on just those ports
+type PipelineScanner struct {
+	nmap    *Scanner
+	masscan *MasscanScanner
+
+	mu          sync.Mutex
+	cancelFuncs map[string]context.CancelFunc
+}
+
+// PipelineConfig holds the options of a pipeline scan
+type PipelineConfig struct {
+	Ports         string   // masscan's ports, all of them by default
+	Rate          int      // masscan's packets per second
+	MasscanArgs   []string // adapter and advanced options of masscan
+	NmapArguments string   // nmap's options; ports are set from masscan's findings
+}
+
+func NewPipelineScanner(nmapScanner *Scanner, masscanScanner *MasscanScanner) *PipelineScanner {
+	return &PipelineScanner{
+		nmap:        nmapScanner,
+		masscan:     masscanScanner,
+		cancelFuncs: make(map[string]context.CancelFunc),
+	}
+}
+
+// pipelineGroup is one nmap run of a pipeline scan: hosts masscan found the
+// same open ports on
+type pipelineGroup struct {
+	hosts []string
+	ports []int
+}
+
+// ExecuteScan runs masscan on target, then nmap on the open ports it found,
+// and stores the merged results: nmap's view of each port, and masscan's for
+// the ports nmap didn't report
+func (p *PipelineScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, target string, config PipelineConfig) error {
+	log.Printf("🔗 Starting pipeline scan %s on target: %s", scanID, target)
+	s := p.nmap
+
+	ctx, cancel := context.WithCancel(ctx)
+	p.mu.Lock()
+	p.cancelFuncs[scanID.String()] = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.cancelFuncs, scanID.String())
+		p.mu.Unlock()
+		cancel()
+	}()
+
+	if err := s.updateScanStatus(ctx, scanID, "running", 0, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	if config.Ports == "" {
+		config.Ports = masscanDefaultPorts
+	}
+	if config.NmapArguments == "" {
+		config.NmapArguments = PipelineDefaultArguments
+	}
+
+	// Stage 1: discovery
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("Stage 1/2: masscan discovery of ports %s on %s", config.Ports, target))
+	discovered, err := p.masscan.Discover(ctx, scanID, target, config.Ports, config.Rate, config.MasscanArgs)
+	if ctx.Err() == context.Canceled {
+		s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
+		return nil
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("masscan discovery failed: %v", err)
+		s.FailScan(ctx, scanID, errMsg)
+		return err
+	}
+
+	groups := pipelineGroups(discovered)
+	openPorts := 0
+	for _, result := range discovered {
+		openPorts += len(result.Ports)
+	}
+	s.addLog(ctx, scanID, "info", fmt.Sprintf("masscan found %d open ports on %d hosts", openPorts, len(discovered)))
+	s.updateScanStatus(ctx, scanID, "running", pipelineDiscoveryProgress, nil)
+
+	// Stage 2: service detection, one nmap run per group of hosts
+	merged := &nmap.Run{}
+	var stderr []string
+	if len(groups) > 0 {
+		s.addLog(ctx, scanID, "info", fmt.Sprintf("Stage 2/2: nmap service detection in %d runs", len(groups)))
+	}
+	for i, group := range groups {
+		arguments := pipelineNmapArguments(config.NmapArguments, group.ports)
+		var run *nmap.Run
+		var lines []string
+		err := s.retry.Run(ctx, func(ctx context.Context) error {
+			var err error
+			run, lines, err = s.runNmap(ctx, scanID, group.hosts, arguments)
+			return err
+		}, func(a supervise.Attempt) {
+			level, message := attemptLog("nmap", a)
+			s.addLog(ctx, scanID, level, message)
+		})
+		if ctx.Err() == context.Canceled {
+			s.addLog(context.Background(), scanID, "info", "Scan was cancelled by user")
+			return nil
+		}
+		if err != nil {
+			s.addLog(ctx, scanID, "warning", fmt.Sprintf("nmap failed on %s, keeping masscan's results for them: %v",
+				strings.Join(group.hosts, ", "), err))
+		} else {
+			merged.Hosts = append(merged.Hosts, run.Hosts...)
+			stderr = append(stderr, lines...)
+		}
+
+		if progress := pipelineDiscoveryProgress + (100-pipelineDiscoveryProgress)*(i+1)/len(groups); progress < 100 {
+			s.updateScanStatus(ctx, scanID, "running", progress, nil)
+		}
+	}
+
+	if len(merged.Hosts) > 0 {
+		s.assessRun(ctx, scanID, merged, stderr, config.NmapArguments)
+	}
+	results := mergePipelineResults(discovered, s.parseGonmapResults(merged), deception.CountPorts(config.Ports))
+	if err := s.storeResults(ctx, scanID, results); err != nil {
+		log.Printf("Failed to store results: %v", err)
+	}
+
+	if err := s.updateScanStatus(ctx, scanID, "completed", 100, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	s.addLog(ctx, scanID, "success", fmt.Sprintf("Pipeline scan completed. Found %d hosts with %d open ports", len(results), openPorts))
+	log.Printf("✅ Pipeline scan %s completed. Found %d hosts", scanID, len(results))
+	return nil
+}
+
+// CancelScan cancels a running pipeline scan
+func (p *PipelineScanner) CancelScan(scanID string) {
+	p.mu.Lock()
+	cancel, ok := p.cancelFuncs[scanID]
+	p.mu.Unlock()
+	if ok {
+		cancel()
+		log.Printf("🛑 Cancelled pipeline scan %s", scanID)
+	}
+}
+
+// pipelineGroups groups the hosts masscan found by their open TCP ports, so
+// hosts running the same services share an nmap run, in runs of at most
+// pipelineMaxHosts hosts
+func pipelineGroups(discovered map[string]*models.ScanResult) []pipelineGroup {
+	byPorts := map[string]*pipelineGroup{}
+	var keys []string
+	for host, result := range discovered {
+		var ports []int
+		for _, port := range result.Ports {
+			if port.Protocol == "tcp" {
+				ports = append(ports, port.Port)
+			}
+		}
+		if len(ports) == 0 {
+			continue
+		}
+		sort.Ints(ports)
+		key := joinPorts(ports)
+		if byPorts[key] == nil {
+			byPorts[key] = &pipelineGroup{ports: ports}
+			keys = append(keys, key)
+		}
+		byPorts[key].hosts = append(byPorts[key].hosts, host)
+	}
+	sort.Strings(keys)
+
+	var groups []pipelineGroup
+	for _, key := range keys {
+		group := byPorts[key]
+		sort.Strings(group.hosts)
+		for start := 0; start < len(group.hosts); start += pipelineMaxHosts {
+			end := start + pipelineMaxHosts
+			if end > len(group.hosts) {
+				end = len(group.hosts)
+			}
+			groups = append(groups, pipelineGroup{hosts: group.hosts[start:end], ports: group.ports})
+		}
+	}
+	return groups
+}
+
+// pipelineNmapArguments returns nmap's arguments for a group: the scan's
+// own without their port selection, with -sV, -Pn (masscan saw the hosts
+// answer) and the group's ports
+func pipelineNmapArguments(arguments string, ports []int) string {
+	fields := strings.Fields(arguments)
+	args := make([]string, 0, len(fields)+4)
+	versions, noPing := false, false
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "-p" || f == "--top-ports":
+			i++ // drop the flag's value too
+			continue
+		case f == "-F" || strings.HasPrefix(f, "-p") || strings.HasPrefix(f, "--top-ports="):
+			continue
+		case f == "-sV" || f == "-A":
+			versions = true
+		case f == "-Pn":
+			noPing = true
+		}
+		args = append(args, f)
+	}
+	if !versions {
+		args = append(args, "-sV")
+	}
+	if !noPing {
+		args = append(args, "-Pn")
+	}
+	args = append(args, "-p", joinPorts(ports))
+	return strings.Join(args, " ")
+}
+
+// mergePipelineResults combines masscan's and nmap's results per host: nmap's
+// ports (with their services), plus the ports only masscan reported. Hosts
+// nmap couldn't scan keep masscan's results.
+func mergePipelineResults(discovered map[string]*models.ScanResult, identified []models.ScanResult, scannedPorts int) []models.ScanResult {
+	byHost := map[string]models.ScanResult{}
+	for _, result := range identified {
+		byHost[result.Host] = result
+	}
+
+	hosts := make([]string, 0, len(discovered))
+	for host := range discovered {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	results := make([]models.ScanResult, 0, len(hosts))
+	for _, host := range hosts {
+		found := discovered[host]
+		result, ok := byHost[host]
+		if !ok {
+			result = *found
+		} else {
+			seen := map[string]bool{}
+			for _, port := range result.Ports {
+				seen[fmt.Sprintf("%d/%s", port.Port, port.Protocol)] = true
+			}
+			for _, port := range found.Ports {
+				if key := fmt.Sprintf("%d/%s", port.Port, port.Protocol); !seen[key] {
+					result.Ports = append(result.Ports, port)
+					result.Services = append(result.Services, key)
+				}
+			}
+		}
+		// Only the open ports were given to nmap: rate them against what
+		// masscan probed
+		result.Honeypot = deception.Assess(result.Ports, deception.Signals{ScannedPorts: scannedPorts})
+		results = append(results, result)
+	}
+	return results
+}
+
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ",")
+}
+
+// GetTemplates returns predefined pipeline scan templates
+func (p *PipelineScanner) GetTemplates() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"pipeline_quick": {
+			"name":        "Pipeline Quick Scan",
+			"description": "masscan on the first 1000 ports, then nmap service detection on the open ones",
+			"ports":       "1-1000",
+			"rate":        10000,
+			"arguments":   PipelineDefaultArguments,
+		},
+		"pipeline_full": {
+			"name":        "Pipeline Full Scan",
+			"description": "masscan on all 65535 ports, then nmap service detection on the open ones",
+			"ports":       "1-65535",
+			"rate":        50000,
+			"arguments":   PipelineDefaultArguments,
+		},
+		"pipeline_deep": {
+			"name":        "Pipeline Deep Scan",
+			"description": "masscan on all 65535 ports, then nmap service, OS and script detection on the open ones",
+			"ports":       "1-65535",
+			"rate":        50000,
+			"arguments":   "-sV -sC -O -T4",
+		},
+	}
+}
@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/Ullaakut/nmap/v3"
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// Scan quality grades
+const (
+	QualityClean      = "clean"
+	QualityDegraded   = "degraded"
+	QualityUnreliable = "unreliable"
+)
+
+// Score thresholds of the grades
+const (
+	qualityCleanScore    = 75
+	qualityDegradedScore = 45
+)
+
+// probeTally counts what happened to the probes sent to a scan's live hosts
+type probeTally struct {
+	hosts           int
+	attempted       int
+	answered        int
+	timeouts        int
+	errors          int
+	retransmissions int
+	hostsTimedOut   int
+	blocked         bool
+}
+
+// count adds a native probe: answered, or failed with err. Dials cut short
+// by cancellation don't count.
+func (t *probeTally) count(answered bool, err error) {
+	var netErr net.Error
+	switch {
+	case answered:
+		t.attempted++
+		t.answered++
+	case errors.Is(err, context.Canceled):
+	case errors.As(err, &netErr) && netErr.Timeout(),
+		errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		t.attempted++
+		t.timeouts++
+	default:
+		t.attempted++
+		t.errors++
+	}
+}
+
+// add sums the probes of one host into t
+func (t *probeTally) add(host probeTally) {
+	t.hosts++
+	t.attempted += host.attempted
+	t.answered += host.answered
+	t.timeouts += host.timeouts
+	t.errors += host.errors
+}
+
+// quality scores the tally, or returns nil when no port was probed (ping
+// scans). Each problem takes points off 100:
+//   - unanswered probes: up to 25, in proportion to the coverage lost
+//   - send errors: one per percent of probes, up to 40
+//   - hosts nmap gave up retransmitting to: up to 15
+//   - hosts that hit --host-timeout: up to 25
+//   - the target possibly blocked the scan: 40
+func (t probeTally) quality() *models.ScanQuality {
+	if t.attempted == 0 {
+		return nil
+	}
+	q := &models.ScanQuality{
+		Hosts:           t.hosts,
+		ProbesAttempted: t.attempted,
+		ProbesAnswered:  t.answered,
+		Timeouts:        t.timeouts,
+		Errors:          t.errors,
+		Retransmissions: t.retransmissions,
+		HostsTimedOut:   t.hostsTimedOut,
+		Coverage:        math.Round(float64(t.answered)/float64(t.attempted)*100) / 100,
+		Reasons:         []string{},
+	}
+
+	penalty := 0.0
+	if lost := 25 * (1 - float64(t.answered)/float64(t.attempted)); lost >= 1 {
+		penalty += lost
+		q.Reasons = append(q.Reasons, fmt.Sprintf("%d of %d probes got no answer (%d timed out)",
+			t.attempted-t.answered, t.attempted, t.timeouts))
+	}
+	if t.errors > 0 {
+		penalty += math.Min(40, float64(t.errors)*100/float64(t.attempted))
+		q.Reasons = append(q.Reasons, fmt.Sprintf("%d probes could not be sent", t.errors))
+	}
+	if t.retransmissions > 0 && t.hosts > 0 {
+		penalty += 15 * math.Min(1, float64(t.retransmissions)/float64(t.hosts))
+		q.Reasons = append(q.Reasons, fmt.Sprintf("nmap hit the retransmission cap on %d hosts", t.retransmissions))
+	}
+	if t.hostsTimedOut > 0 && t.hosts > 0 {
+		penalty += 25 * math.Min(1, float64(t.hostsTimedOut)/float64(t.hosts))
+		q.Reasons = append(q.Reasons, fmt.Sprintf("%d hosts hit the host timeout before finishing", t.hostsTimedOut))
+	}
+	if t.blocked {
+		penalty += 40
+		q.Reasons = append(q.Reasons, "the target possibly blocked the scan")
+	}
+
+	q.Score = int(math.Max(0, math.Round(100-penalty)))
+	switch {
+	case q.Score >= qualityCleanScore:
+		q.Grade = QualityClean
+	case q.Score >= qualityDegradedScore:
+		q.Grade = QualityDegraded
+	default:
+		q.Grade = QualityUnreliable
+	}
+	return q
+}
+
+// nmapTally counts the TCP probes of an nmap run from its report, and send
+// errors and retransmission give-ups from its stderr lines. UDP ports are
+// left out: silence is their normal answer.
+func nmapTally(result *nmap.Run, stderr []string, udp bool) probeTally {
+	var t probeTally
+	for _, host := range result.Hosts {
+		if host.Status.State != "up" {
+			continue
+		}
+		t.hosts++
+		if host.TimedOut {
+			t.hostsTimedOut++
+		}
+		for _, port := range host.Ports {
+			if port.Protocol != "tcp" {
+				continue
+			}
+			t.attempted++
+			if strings.HasPrefix(port.State.Reason, "no-response") {
+				t.timeouts++
+			} else {
+				t.answered++
+			}
+		}
+		// Extra ports don't say their protocol
+		if udp {
+			continue
+		}
+		for _, extra := range host.ExtraPorts {
+			t.attempted += extra.Count
+			silent := 0
+			if len(extra.Reasons) == 0 && extra.State == "filtered" {
+				silent = extra.Count
+			}
+			for _, reason := range extra.Reasons {
+				if strings.HasPrefix(reason.Reason, "no-response") {
+					silent += reason.Count
+				}
+			}
+			t.timeouts += silent
+			t.answered += extra.Count - silent
+		}
+	}
+
+	for _, line := range stderr {
+		switch {
+		case strings.Contains(line, "retransmission cap hit"):
+			t.retransmissions++
+		case strings.HasPrefix(line, "sendto in send_"), strings.HasPrefix(line, "Failed to send"):
+			t.errors++
+		}
+	}
+	return t
+}
+
+// recordQuality stores the quality of a scan
+func recordQuality(ctx context.Context, db *database.Database, scanID uuid.UUID, quality *models.ScanQuality) error {
+	data, err := json.Marshal(quality)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(ctx, `UPDATE scans SET quality = $1 WHERE id = $2`, data, scanID)
+	return err
+}
@@ -0,0 +1,191 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+// Reasons a target was collapsed
+const (
+	CollapsedDuplicate = "duplicate" // the same host, CIDR or range after normalization
+	CollapsedCovered   = "covered"   // inside a CIDR or range of the same list
+)
+
+// targetSpec is one part of a target list
+type targetSpec struct {
+	original string
+	norm     string
+	ip       net.IP     // IPs
+	network  *net.IPNet // CIDRs
+	first    uint32     // IPv4 ranges and CIDRs: first and last address
+	last     uint32
+	isRange  bool
+}
+
+// DedupTargets normalizes a target list (IPs, hostnames, URLs, CIDRs and
+// last-octet ranges separated by commas or spaces) and drops the parts
+// another one already covers: repeated hosts, hostnames written with and
+// without scheme, and IPs, ranges or CIDRs inside a CIDR or range of the
+// list. Hostnames aren't resolved, so a name is never collapsed into an IP.
+// The target is returned unchanged when nothing was collapsed.
+func DedupTargets(target string) (string, []models.CollapsedTarget) {
+	parts := strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+
+	var specs []*targetSpec
+	var collapsed []models.CollapsedTarget
+	seen := map[string]*targetSpec{}
+	for _, part := range parts {
+		spec := parseTargetSpec(part)
+		if prev, ok := seen[spec.norm]; ok {
+			collapsed = append(collapsed, models.CollapsedTarget{Target: part, Into: prev.original, Reason: CollapsedDuplicate})
+			continue
+		}
+		seen[spec.norm] = spec
+		specs = append(specs, spec)
+	}
+
+	kept := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		if cover := coveringSpec(spec, specs); cover != nil {
+			collapsed = append(collapsed, models.CollapsedTarget{Target: spec.original, Into: cover.original, Reason: CollapsedCovered})
+			continue
+		}
+		kept = append(kept, spec.norm)
+	}
+
+	if len(collapsed) == 0 {
+		return target, nil
+	}
+
+	// Point at the part that is scanned: a CIDR covered by a wider one
+	// hands over what it covered
+	into := map[string]string{}
+	for _, c := range collapsed {
+		if c.Reason == CollapsedCovered {
+			into[c.Target] = c.Into
+		}
+	}
+	for i := range collapsed {
+		for next, ok := into[collapsed[i].Into]; ok; next, ok = into[next] {
+			collapsed[i].Into = next
+		}
+	}
+
+	separator := " "
+	if strings.Contains(target, ",") {
+		separator = ","
+	}
+	return strings.Join(kept, separator), collapsed
+}
+
+func parseTargetSpec(part string) *targetSpec {
+	spec := &targetSpec{original: part, norm: part}
+
+	// https://Example.com/path and example.com are the same host
+	if strings.Contains(part, "://") {
+		if parsed, err := url.Parse(part); err == nil && parsed.Host != "" {
+			spec.norm = parsed.Host
+		}
+	}
+
+	switch {
+	case strings.Contains(spec.norm, "/") && !strings.HasSuffix(spec.norm, "/"):
+		ip, network, err := net.ParseCIDR(spec.norm)
+		if err != nil {
+			return spec
+		}
+		ones, bits := network.Mask.Size()
+		if ones == bits {
+			spec.ip = ip
+			spec.norm = ip.String()
+			return spec
+		}
+		spec.network = network
+		spec.norm = network.String()
+		if v4 := network.IP.To4(); v4 != nil {
+			spec.first = binary.BigEndian.Uint32(v4)
+			spec.last = spec.first | (1<<uint(bits-ones) - 1)
+		}
+	case isLastOctetRange(spec.norm):
+		dash := strings.LastIndex(spec.norm, "-")
+		start := net.ParseIP(spec.norm[:dash]).To4()
+		end, err := strconv.Atoi(spec.norm[dash+1:])
+		if err != nil || end < int(start[3]) || end > 255 {
+			return spec
+		}
+		if end == int(start[3]) {
+			spec.ip = start
+			spec.norm = start.String()
+			return spec
+		}
+		spec.isRange = true
+		spec.first = binary.BigEndian.Uint32(start)
+		spec.last = spec.first - uint32(start[3]) + uint32(end)
+		spec.norm = start.String() + "-" + strconv.Itoa(end)
+	case net.ParseIP(spec.norm) != nil:
+		spec.ip = net.ParseIP(spec.norm)
+		spec.norm = spec.ip.String()
+	default:
+		spec.norm = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(spec.norm), "/"), ".")
+	}
+	return spec
+}
+
+// coveringSpec returns the CIDR or range of specs that strictly contains
+// spec, if any. Network and broadcast addresses don't count as covered: the
+// native scanner skips them when expanding a CIDR.
+func coveringSpec(spec *targetSpec, specs []*targetSpec) *targetSpec {
+	for _, other := range specs {
+		if other == spec || (other.network == nil && !other.isRange) {
+			continue
+		}
+		switch {
+		case spec.ip != nil:
+			if other.network != nil && other.network.Contains(spec.ip) && !isNetworkEdge(spec.ip, other.network) {
+				return other
+			}
+			if v4 := spec.ip.To4(); other.isRange && v4 != nil {
+				if addr := binary.BigEndian.Uint32(v4); addr >= other.first && addr <= other.last {
+					return other
+				}
+			}
+		case spec.network != nil && other.network != nil:
+			specOnes, _ := spec.network.Mask.Size()
+			otherOnes, _ := other.network.Mask.Size()
+			if otherOnes < specOnes && other.network.Contains(spec.network.IP) {
+				return other
+			}
+		case spec.isRange:
+			if spec.first < other.first || spec.last > other.last {
+				continue
+			}
+			if other.isRange {
+				return other
+			}
+			if other.network.IP.To4() != nil && spec.first != other.first && spec.last != other.last {
+				return other
+			}
+		}
+	}
+	return nil
+}
+
+// isNetworkEdge reports whether ip is the network or broadcast address of
+// an IPv4 subnet larger than /31
+func isNetworkEdge(ip net.IP, network *net.IPNet) bool {
+	v4 := ip.To4()
+	ones, bits := network.Mask.Size()
+	if v4 == nil || bits != 32 || ones >= 31 {
+		return false
+	}
+	first := binary.BigEndian.Uint32(network.IP.To4())
+	last := first | (1<<uint(bits-ones) - 1)
+	addr := binary.BigEndian.Uint32(v4)
+	return addr == first || addr == last
+}
@@ -0,0 +1,283 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+const udpProbeWorkers = 50
+
+// udpProbe is a protocol-specific payload for a well-known UDP port. check
+// returns whether the response comes from that protocol and any detail worth
+// keeping (version, banner...)
+type udpProbe struct {
+	port    int
+	service string
+	payload func() []byte
+	check   func(req, resp []byte) (bool, string)
+}
+
+var udpProbes = []udpProbe{
+	{53, "domain", dnsPayload, checkDNS},
+	{69, "tftp", tftpPayload, checkTFTP},
+	{123, "ntp", ntpPayload, checkNTP},
+	{161, "snmp", snmpPayload, checkSNMP},
+	{500, "isakmp", ikePayload, checkIKE},
+}
+
+// UDPProber confirms UDP ports nmap could only report as open|filtered by
+// sending payloads real services answer to
+type UDPProber struct {
+	timeout time.Duration
+}
+
+func NewUDPProber(timeout time.Duration) *UDPProber {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &UDPProber{timeout: timeout}
+}
+
+// IsUDPScan reports whether nmap arguments include a UDP scan (-sU, -sSU...)
+func IsUDPScan(arguments string) bool {
+	for _, f := range strings.Fields(arguments) {
+		if strings.HasPrefix(f, "-s") && strings.Contains(f[2:], "U") {
+			return true
+		}
+	}
+	return false
+}
+
+// Probe sends the payload of each known UDP port reported for hosts that are
+// up and marks the ports that answered as open and confirmed. It returns the
+// number of confirmed ports.
+func (p *UDPProber) Probe(ctx context.Context, results []models.ScanResult) int {
+	type job struct {
+		result int
+		port   int
+		probe  udpProbe
+	}
+	var jobs []job
+	for i, result := range results {
+		if result.State != "up" {
+			continue
+		}
+		for j, port := range result.Ports {
+			if port.Protocol != "udp" || (port.State != "open" && port.State != "open|filtered") {
+				continue
+			}
+			for _, probe := range udpProbes {
+				if probe.port == port.Port {
+					jobs = append(jobs, job{result: i, port: j, probe: probe})
+				}
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, udpProbeWorkers)
+	confirmed := 0
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ok, detail := p.send(ctx, results[j.result].Host, j.probe)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			port := &results[j.result].Ports[j.port]
+			port.State = "open"
+			port.Confirmed = true
+			if port.Service == "" || strings.HasSuffix(port.Service, "?") {
+				port.Service = j.probe.service
+			}
+			if detail != "" && port.ExtraInfo == "" {
+				port.ExtraInfo = detail
+			}
+			confirmed++
+		}(j)
+	}
+	wg.Wait()
+	return confirmed
+}
+
+// send writes the probe payload and waits for a matching answer, resending
+// once since UDP datagrams get lost. The socket is unconnected because some
+// services (TFTP) answer from another port.
+func (p *UDPProber) send(ctx context.Context, host string, probe udpProbe) (bool, string) {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, fmt.Sprint(probe.port)))
+	if err != nil {
+		return false, ""
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return false, ""
+	}
+	defer conn.Close()
+
+	payload := probe.payload()
+	buf := make([]byte, 4096)
+	for attempt := 0; attempt < 2 && ctx.Err() == nil; attempt++ {
+		if _, err := conn.WriteToUDP(payload, addr); err != nil {
+			return false, ""
+		}
+		conn.SetReadDeadline(time.Now().Add(p.timeout / 2))
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				break // deadline, try again
+			}
+			if !from.IP.Equal(addr.IP) {
+				continue
+			}
+			if ok, detail := probe.check(payload, buf[:n]); ok {
+				return true, detail
+			}
+		}
+	}
+	return false, ""
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// dnsPayload asks for the NS records of the root zone
+func dnsPayload() []byte {
+	msg := append(randomBytes(2), 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	return append(msg, 0x00, 0x00, 0x02, 0x00, 0x01)
+}
+
+func checkDNS(req, resp []byte) (bool, string) {
+	if len(resp) < 12 || !bytes.Equal(resp[:2], req[:2]) || resp[2]&0x80 == 0 {
+		return false, ""
+	}
+	if resp[3]&0x80 != 0 {
+		return true, "recursion available"
+	}
+	return true, ""
+}
+
+// tftpPayload requests a file that should not exist; servers answer with an error
+func tftpPayload() []byte {
+	name := fmt.Sprintf("probe-%x", randomBytes(4))
+	msg := []byte{0x00, 0x01}
+	msg = append(msg, name...)
+	msg = append(msg, 0x00)
+	msg = append(msg, "octet"...)
+	return append(msg, 0x00)
+}
+
+func checkTFTP(req, resp []byte) (bool, string) {
+	if len(resp) < 4 || resp[0] != 0x00 {
+		return false, ""
+	}
+	switch resp[1] {
+	case 0x03:
+		return true, "file read allowed"
+	case 0x05:
+		return true, ""
+	}
+	return false, ""
+}
+
+// ntpPayload is an NTPv3 client request
+func ntpPayload() []byte {
+	msg := make([]byte, 48)
+	msg[0] = 0x1b
+	return msg
+}
+
+func checkNTP(req, resp []byte) (bool, string) {
+	if len(resp) < 48 || resp[0]&0x07 != 4 {
+		return false, ""
+	}
+	return true, fmt.Sprintf("NTPv%d, stratum %d", resp[0]>>3&0x07, resp[1])
+}
+
+// sysDescrOID is 1.3.6.1.2.1.1.1.0 BER encoded
+var sysDescrOID = []byte{0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// snmpPayload is an SNMPv1 get-request of sysDescr with the public community
+func snmpPayload() []byte {
+	varbind := append(append([]byte{}, sysDescrOID...), 0x05, 0x00)
+	varbind = berWrap(0x30, varbind)
+	pdu := append([]byte{0x02, 0x04}, randomBytes(4)...)  // request-id
+	pdu = append(pdu, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00) // error-status, error-index
+	pdu = append(pdu, berWrap(0x30, varbind)...)
+	msg := []byte{0x02, 0x01, 0x00} // version 1
+	msg = append(msg, berWrap(0x04, []byte("public"))...)
+	msg = append(msg, berWrap(0xa0, pdu)...)
+	return berWrap(0x30, msg)
+}
+
+func berWrap(tag byte, content []byte) []byte {
+	return append([]byte{tag, byte(len(content))}, content...)
+}
+
+func checkSNMP(req, resp []byte) (bool, string) {
+	if len(resp) < 2 || resp[0] != 0x30 || !bytes.Contains(resp, []byte{0xa2}) {
+		return false, ""
+	}
+	detail := "community public"
+	if i := bytes.Index(resp, sysDescrOID); i >= 0 {
+		rest := resp[i+len(sysDescrOID):]
+		if len(rest) > 2 && rest[0] == 0x04 && rest[1] < 0x80 && int(rest[1]) <= len(rest)-2 {
+			detail += ": " + strings.TrimSpace(string(rest[2:2+rest[1]]))
+		}
+	}
+	return true, detail
+}
+
+// ikePayload is an IKEv1 main mode proposal (3DES, SHA1, PSK, group 2)
+func ikePayload() []byte {
+	transform := []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00,
+		0x80, 0x01, 0x00, 0x05, // encryption 3DES
+		0x80, 0x02, 0x00, 0x02, // hash SHA1
+		0x80, 0x03, 0x00, 0x01, // auth pre-shared key
+		0x80, 0x04, 0x00, 0x02, // group MODP1024
+		0x80, 0x0b, 0x00, 0x01, // life type seconds
+		0x80, 0x0c, 0x70, 0x80, // life duration 28800
+	}
+	binary.BigEndian.PutUint16(transform[2:], uint16(len(transform)))
+	proposal := append([]byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x01}, transform...)
+	binary.BigEndian.PutUint16(proposal[2:], uint16(len(proposal)))
+	sa := append([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01}, proposal...)
+	binary.BigEndian.PutUint16(sa[2:], uint16(len(sa)))
+
+	header := append(randomBytes(8), make([]byte, 8)...) // initiator and responder cookies
+	header = append(header, 0x01, 0x10, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	msg := append(header, sa...)
+	binary.BigEndian.PutUint32(msg[24:], uint32(len(msg)))
+	return msg
+}
+
+func checkIKE(req, resp []byte) (bool, string) {
+	if len(resp) < 28 || !bytes.Equal(resp[:8], req[:8]) {
+		return false, ""
+	}
+	if resp[18] == 0x05 {
+		return true, "IKEv1 informational"
+	}
+	return true, "IKEv1"
+}
@@ -0,0 +1,27 @@
+package tagging
+
+import (
+	"context"
+
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/shared/pkg/owners"
+)
+
+// LoadOwners returns every asset owner assignment, for owners.Match
+func LoadOwners(ctx context.Context, db *database.Database) ([]owners.Assignment, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+owners.Columns+` FROM asset_owners ORDER BY target`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []owners.Assignment{}
+	for rows.Next() {
+		a, err := owners.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, *a)
+	}
+	return assignments, rows.Err()
+}
@@ -0,0 +1,797 @@
+package tagging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/screenshots"
+)
+
+// Sources of the results an asset was tagged from
+const (
+	SourceScan  = "scan"
+	SourceRecon = "recon"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS asset_tag_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    conditions JSONB NOT NULL DEFAULT '[]',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    criticality VARCHAR(20),
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS asset_tags (
+    asset VARCHAR(500) NOT NULL,
+    tag VARCHAR(100) NOT NULL,
+    rule_id UUID NOT NULL REFERENCES asset_tag_rules(id) ON DELETE CASCADE,
+    criticality VARCHAR(20),
+    source VARCHAR(20) NOT NULL,
+    scan_id UUID,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (asset, tag, rule_id)
+);
+CREATE INDEX IF NOT EXISTS idx_asset_tags_tag ON asset_tags(tag)`
+
+// RuleColumns are the asset_tag_rules columns read by ScanRule
+const RuleColumns = `id, name, description, conditions, tags, COALESCE(criticality, ''), enabled, created_at, updated_at`
+
+// Criticalities from least to most critical
+var criticalities = map[string]int{"": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// Fields a condition can match and the operators each supports
+var fieldOperators = map[string][]string{
+	"port":     {"equals"},
+	"service":  {"equals", "matches"},
+	"product":  {"equals", "matches"},
+	"ip":       {"equals", "matches", "in_cidr"},
+	"hostname": {"equals", "matches"},
+	"os":       {"equals", "matches"},
+}
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._:-]{0,99}$`)
+
+// seedRules are created when the rules table is empty
+var seedRules = []models.TagRule{
+	{
+		Name:        "RDP exposed",
+		Conditions:  []models.TagCondition{{Field: "port", Value: "3389/tcp"}},
+		Tags:        []string{"rdp-exposed"},
+		Criticality: "high",
+	},
+	{
+		Name:        "Database exposed",
+		Conditions:  []models.TagCondition{{Field: "port", Value: "1433,1521,3306,5432,6379,9200,27017"}},
+		Tags:        []string{"database-exposed"},
+		Criticality: "high",
+	},
+	{
+		Name:       "Non-production hostname",
+		Conditions: []models.TagCondition{{Field: "hostname", Operator: "matches", Value: `(^|[.-])(dev|develop|staging|stage|test|qa|uat|preprod)([.-]|[0-9]|$)`}},
+		Tags:       []string{"non-prod"},
+	},
+}
+
+// Subject is a host or subdomain the rules are evaluated against
+type Subject struct {
+	Asset     string // name the tags are stored under
+	IPs       []string
+	Hostnames []string
+	Ports     []models.Port // only open ports are matched
+	OS        string
+}
+
+type condition struct {
+	field    string
+	operator string
+	values   []string
+	patterns []*regexp.Regexp
+	networks []*net.IPNet
+}
+
+type rule struct {
+	*models.TagRule
+	conditions []condition
+}
+
+// Engine evaluates the enabled rules against results and stores the tags
+type Engine struct {
+	db     *database.Database
+	leases *shareddb.Leases
+	mu     sync.RWMutex
+	rules  []*rule
+}
+
+// NewEngine creates the rule, tag, owner and screenshot tables, seeds the
+// default rules into an empty rules table and loads the enabled rules
+func NewEngine(db *database.Database) (*Engine, error) {
+	ctx := context.Background()
+	if _, err := db.Pool.Exec(ctx, schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create asset tag tables: %w", err)
+	}
+	if _, err := db.Pool.Exec(ctx, owners.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create asset owner table: %w", err)
+	}
+	if _, err := db.Pool.Exec(ctx, screenshots.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create asset screenshot table: %w", err)
+	}
+
+	var empty bool
+	if err := db.Pool.QueryRow(ctx, `SELECT NOT EXISTS (SELECT 1 FROM asset_tag_rules)`).Scan(&empty); err != nil {
+		return nil, fmt.Errorf("failed to check asset tag rules: %w", err)
+	}
+	if empty {
+		for _, seed := range seedRules {
+			conditions, _ := json.Marshal(seed.Conditions)
+			_, err := db.Pool.Exec(ctx, `
+				INSERT INTO asset_tag_rules (id, name, conditions, tags, criticality)
+				VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+				ON CONFLICT (name) DO NOTHING
+			`, uuid.New(), seed.Name, conditions, seed.Tags, seed.Criticality)
+			if err != nil {
+				return nil, fmt.Errorf("failed to seed asset tag rules: %w", err)
+			}
+		}
+	}
+
+	e := &Engine{db: db}
+	if err := e.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ScanRule reads a rule selected with RuleColumns
+func ScanRule(row interface{ Scan(...interface{}) error }) (*models.TagRule, error) {
+	var r models.TagRule
+	var conditions []byte
+	err := row.Scan(&r.ID, &r.Name, &r.Description, &conditions, &r.Tags, &r.Criticality, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(conditions, &r.Conditions); err != nil {
+		return nil, fmt.Errorf("invalid conditions in rule %s: %w", r.Name, err)
+	}
+	return &r, nil
+}
+
+// Validate normalizes a rule and reports the first problem with it
+func Validate(r *models.TagRule) error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Criticality = strings.ToLower(strings.TrimSpace(r.Criticality))
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("at least one condition is required")
+	}
+	if len(r.Tags) == 0 && r.Criticality == "" {
+		return fmt.Errorf("tags or criticality is required")
+	}
+	for i, tag := range r.Tags {
+		r.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(r.Tags[i]) {
+			return fmt.Errorf("invalid tag %q: use lowercase letters, digits, '.', '_', ':' and '-'", tag)
+		}
+	}
+	if _, ok := criticalities[r.Criticality]; !ok {
+		return fmt.Errorf("criticality must be low, medium, high or critical")
+	}
+	_, err := compile(r)
+	return err
+}
+
+func compile(r *models.TagRule) (*rule, error) {
+	compiled := &rule{TagRule: r}
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		c.Field = strings.ToLower(strings.TrimSpace(c.Field))
+		c.Operator = strings.ToLower(strings.TrimSpace(c.Operator))
+		if c.Operator == "" {
+			c.Operator = "equals"
+		}
+		operators, ok := fieldOperators[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("condition %d: field must be port, service, product, ip, hostname or os", i+1)
+		}
+		if !contains(operators, c.Operator) {
+			return nil, fmt.Errorf("condition %d: %s supports %s", i+1, c.Field, strings.Join(operators, ", "))
+		}
+
+		cond := condition{field: c.Field, operator: c.Operator}
+		if c.Operator == "matches" {
+			pattern, err := regexp.Compile("(?i)" + c.Value)
+			if err != nil {
+				return nil, fmt.Errorf("condition %d: invalid pattern: %v", i+1, err)
+			}
+			cond.patterns = append(cond.patterns, pattern)
+			compiled.conditions = append(compiled.conditions, cond)
+			continue
+		}
+		for _, value := range strings.Split(c.Value, ",") {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value == "" {
+				continue
+			}
+			switch {
+			case c.Operator == "in_cidr":
+				if !strings.Contains(value, "/") {
+					value += "/" + strconv.Itoa(8*len(ipBytes(value)))
+				}
+				_, network, err := net.ParseCIDR(value)
+				if err != nil {
+					return nil, fmt.Errorf("condition %d: invalid CIDR %q", i+1, value)
+				}
+				cond.networks = append(cond.networks, network)
+			case c.Field == "port":
+				number, proto, _ := strings.Cut(value, "/")
+				if port, err := strconv.Atoi(number); err != nil || port < 1 || port > 65535 || (proto != "" && proto != "tcp" && proto != "udp") {
+					return nil, fmt.Errorf("condition %d: ports look like 3389 or 3389/tcp", i+1)
+				}
+				cond.values = append(cond.values, value)
+			default:
+				cond.values = append(cond.values, value)
+			}
+		}
+		if len(cond.values) == 0 && len(cond.networks) == 0 {
+			return nil, fmt.Errorf("condition %d: value is required", i+1)
+		}
+		compiled.conditions = append(compiled.conditions, cond)
+	}
+	return compiled, nil
+}
+
+func ipBytes(value string) net.IP {
+	ip := net.ParseIP(value)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// Reload recompiles the enabled rules; call it after editing rules
+func (e *Engine) Reload(ctx context.Context) error {
+	rows, err := e.db.Pool.Query(ctx, `SELECT `+RuleColumns+` FROM asset_tag_rules WHERE enabled ORDER BY name`)
+	if err != nil {
+		return fmt.Errorf("failed to load asset tag rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*rule
+	for rows.Next() {
+		r, err := ScanRule(rows)
+		if err != nil {
+			return err
+		}
+		compiled, err := compile(r)
+		if err != nil {
+			log.Printf("Skipping tag rule %s: %v", r.Name, err)
+			continue
+		}
+		rules = append(rules, compiled)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	return nil
+}
+
+// matches reports whether the subject satisfies the condition
+func (c condition) matches(s Subject) bool {
+	switch c.field {
+	case "port":
+		for _, port := range openPorts(s.Ports) {
+			number := strconv.Itoa(port.Port)
+			for _, value := range c.values {
+				if value == number || value == number+"/"+strings.ToLower(port.Protocol) {
+					return true
+				}
+			}
+		}
+		return false
+	case "service":
+		var services []string
+		for _, port := range openPorts(s.Ports) {
+			services = append(services, port.Service)
+		}
+		return c.matchAny(services)
+	case "product":
+		var products []string
+		for _, port := range openPorts(s.Ports) {
+			if port.Product != "" {
+				products = append(products, strings.TrimSpace(port.Product+" "+port.Version))
+			}
+		}
+		return c.matchAny(products)
+	case "ip":
+		if len(c.networks) > 0 {
+			for _, value := range s.IPs {
+				ip := net.ParseIP(value)
+				for _, network := range c.networks {
+					if ip != nil && network.Contains(ip) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		return c.matchAny(s.IPs)
+	case "hostname":
+		return c.matchAny(s.Hostnames)
+	case "os":
+		return s.OS != "" && c.matchAny([]string{s.OS})
+	}
+	return false
+}
+
+func (c condition) matchAny(candidates []string) bool {
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(strings.TrimSuffix(candidate, "."))
+		if candidate == "" {
+			continue
+		}
+		for _, pattern := range c.patterns {
+			if pattern.MatchString(candidate) {
+				return true
+			}
+		}
+		if contains(c.values, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func openPorts(ports []models.Port) []models.Port {
+	var open []models.Port
+	for _, port := range ports {
+		if port.State == "" || port.State == "open" {
+			open = append(open, port)
+		}
+	}
+	return open
+}
+
+// Evaluate returns the enabled rules whose conditions all match the subject
+func (e *Engine) Evaluate(s Subject) []*models.TagRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var matched []*models.TagRule
+	for _, r := range e.rules {
+		ok := true
+		for _, c := range r.conditions {
+			if !c.matches(s) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, r.TagRule)
+		}
+	}
+	return matched
+}
+
+// Apply evaluates the rules against a subject and stores the resulting tags.
+// Tags are kept until their rule is deleted or the tags are recomputed.
+func (e *Engine) Apply(ctx context.Context, s Subject, source string, scanID *uuid.UUID) (int, error) {
+	tagged := 0
+	for _, r := range e.Evaluate(s) {
+		tags := r.Tags
+		if len(tags) == 0 {
+			// Criticality-only rules still need a row to carry it
+			tags = []string{"criticality:" + r.Criticality}
+		}
+		for _, tag := range tags {
+			_, err := e.db.Pool.Exec(ctx, `
+				INSERT INTO asset_tags (asset, tag, rule_id, criticality, source, scan_id)
+				VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+				ON CONFLICT (asset, tag, rule_id) DO UPDATE SET
+					criticality = EXCLUDED.criticality, source = EXCLUDED.source,
+					scan_id = EXCLUDED.scan_id, last_seen = NOW()
+			`, strings.ToLower(s.Asset), tag, r.ID, r.Criticality, source, scanID)
+			if err != nil {
+				return tagged, err
+			}
+			tagged++
+		}
+	}
+	return tagged, nil
+}
+
+// TagScan tags the hosts of a network scan
+func (e *Engine) TagScan(ctx context.Context, scanID uuid.UUID) (int, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT host, hostname, ports, os_detection FROM scan_results WHERE scan_id = $1
+	`, scanID)
+	if err != nil {
+		return 0, err
+	}
+	subjects := []Subject{}
+	for rows.Next() {
+		var host string
+		var hostname *string
+		var ports []models.Port
+		var osDetection map[string]interface{}
+		if err := rows.Scan(&host, &hostname, &ports, &osDetection); err != nil {
+			continue
+		}
+		subjects = append(subjects, hostSubject(host, hostname, ports, osDetection))
+	}
+	rows.Close()
+
+	tagged := 0
+	for _, subject := range subjects {
+		n, err := e.Apply(ctx, subject, SourceScan, &scanID)
+		tagged += n
+		if err != nil {
+			return tagged, err
+		}
+	}
+	return tagged, nil
+}
+
+// hostSubject builds the subject of a scan result
+func hostSubject(host string, hostname *string, ports []models.Port, osDetection map[string]interface{}) Subject {
+	s := Subject{Asset: host, Ports: ports}
+	if net.ParseIP(host) != nil {
+		s.IPs = []string{host}
+	} else {
+		// DNS scans store the domain as the host
+		s.Hostnames = []string{host}
+	}
+	if hostname != nil && *hostname != "" {
+		s.Hostnames = append(s.Hostnames, *hostname)
+	}
+	if name, ok := osDetection["name"].(string); ok {
+		s.OS = name
+	}
+	return s
+}
+
+// tagSubdomains tags the recon subdomains stored after since and returns the
+// newest created_at it saw
+func (e *Engine) tagSubdomains(ctx context.Context, since time.Time) (int, time.Time, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT scan_id, subdomain, COALESCE(ip_addresses, '{}'), created_at
+		FROM subdomain_results WHERE created_at > $1 AND wildcard IS NULL ORDER BY created_at
+	`, since)
+	if err != nil {
+		return 0, since, err
+	}
+	type subdomain struct {
+		scanID  *uuid.UUID
+		subject Subject
+	}
+	var subdomains []subdomain
+	for rows.Next() {
+		var sd subdomain
+		var name string
+		var ips []string
+		var createdAt time.Time
+		if err := rows.Scan(&sd.scanID, &name, &ips, &createdAt); err != nil {
+			continue
+		}
+		sd.subject = Subject{Asset: name, IPs: ips, Hostnames: []string{name}}
+		subdomains = append(subdomains, sd)
+		since = createdAt
+	}
+	rows.Close()
+
+	tagged := 0
+	for _, sd := range subdomains {
+		n, err := e.Apply(ctx, sd.subject, SourceRecon, sd.scanID)
+		tagged += n
+		if err != nil {
+			return tagged, since, err
+		}
+	}
+	return tagged, since, nil
+}
+
+// SetLeases makes Start tag recon subdomains only on the replica holding
+// the job's lease
+func (e *Engine) SetLeases(leases *shareddb.Leases) {
+	e.leases = leases
+}
+
+// Start tags recon subdomains as the recon service stores them, checking
+// every interval until ctx is cancelled. Network scans are tagged by TagScan
+// when they finish.
+func (e *Engine) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	var since time.Time
+	if err := e.db.Pool.QueryRow(ctx, `SELECT (NOW() - $1 * INTERVAL '1 second')::timestamp`, interval.Seconds()).Scan(&since); err != nil {
+		log.Printf("Asset tagging disabled for recon results: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Other replicas keep their cursor, so the one taking the job
+			// over tags what was stored since it last ran
+			if !e.leases.Acquire(ctx, "network:tagging", 3*interval) {
+				continue
+			}
+			tagged, newest, err := e.tagSubdomains(ctx, since)
+			if err != nil {
+				log.Printf("Failed to tag recon subdomains: %v", err)
+			}
+			if tagged > 0 {
+				log.Printf("🏷️ Tagged %d recon subdomain(s)", tagged)
+			}
+			since = newest
+		}
+	}
+}
+
+// Retag recomputes every tag from the latest results of each host and
+// subdomain, so tags of rules that no longer match are dropped
+func (e *Engine) Retag(ctx context.Context) (int, error) {
+	if _, err := e.db.Pool.Exec(ctx, `DELETE FROM asset_tags`); err != nil {
+		return 0, err
+	}
+
+	type latest struct {
+		scanID  uuid.UUID
+		subject Subject
+	}
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (host) scan_id, host, hostname, ports, os_detection
+		FROM scan_results ORDER BY host, created_at DESC
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var hosts []latest
+	for rows.Next() {
+		var l latest
+		var host string
+		var hostname *string
+		var ports []models.Port
+		var osDetection map[string]interface{}
+		if err := rows.Scan(&l.scanID, &host, &hostname, &ports, &osDetection); err != nil {
+			continue
+		}
+		l.subject = hostSubject(host, hostname, ports, osDetection)
+		hosts = append(hosts, l)
+	}
+	rows.Close()
+
+	tagged := 0
+	for _, h := range hosts {
+		n, err := e.Apply(ctx, h.subject, SourceScan, &h.scanID)
+		tagged += n
+		if err != nil {
+			return tagged, err
+		}
+	}
+
+	// Recon is a separate service; without its tables only hosts are tagged
+	var hasRecon bool
+	if err := e.db.Pool.QueryRow(ctx, `SELECT to_regclass('subdomain_results') IS NOT NULL`).Scan(&hasRecon); err != nil || !hasRecon {
+		return tagged, err
+	}
+	rows, err = e.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (subdomain) scan_id, subdomain, COALESCE(ip_addresses, '{}')
+		FROM subdomain_results WHERE wildcard IS NULL ORDER BY subdomain, created_at DESC
+	`)
+	if err != nil {
+		return tagged, err
+	}
+	var subdomains []latest
+	for rows.Next() {
+		var l latest
+		var name string
+		var ips []string
+		if err := rows.Scan(&l.scanID, &name, &ips); err != nil {
+			continue
+		}
+		l.subject = Subject{Asset: name, IPs: ips, Hostnames: []string{name}}
+		subdomains = append(subdomains, l)
+	}
+	rows.Close()
+
+	for _, sd := range subdomains {
+		n, err := e.Apply(ctx, sd.subject, SourceRecon, &sd.scanID)
+		tagged += n
+		if err != nil {
+			return tagged, err
+		}
+	}
+	return tagged, nil
+}
+
+// Lookup returns the tags and highest criticality of each asset
+func Lookup(ctx context.Context, db *database.Database, assets []string) (map[string]*models.Asset, error) {
+	lower := make([]string, len(assets))
+	for i, asset := range assets {
+		lower[i] = strings.ToLower(asset)
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT asset, tag, COALESCE(criticality, ''), source, scan_id, first_seen, last_seen
+		FROM asset_tags WHERE asset = ANY($1)
+		ORDER BY asset, last_seen
+	`, lower)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return collect(rows)
+}
+
+// collect groups asset_tags rows ordered by asset and last_seen into assets
+func collect(rows interface {
+	Next() bool
+	Scan(...interface{}) error
+	Err() error
+}) (map[string]*models.Asset, error) {
+	assets := map[string]*models.Asset{}
+	for rows.Next() {
+		var name, tag, criticality, source string
+		var scanID *uuid.UUID
+		var firstSeen, lastSeen time.Time
+		if err := rows.Scan(&name, &tag, &criticality, &source, &scanID, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		asset, ok := assets[name]
+		if !ok {
+			asset = &models.Asset{Asset: name, Tags: []string{}, Sources: []string{}, FirstSeen: firstSeen}
+			assets[name] = asset
+		}
+		if !strings.HasPrefix(tag, "criticality:") && !contains(asset.Tags, tag) {
+			asset.Tags = append(asset.Tags, tag)
+		}
+		if !contains(asset.Sources, source) {
+			asset.Sources = append(asset.Sources, source)
+		}
+		if criticalities[criticality] > criticalities[asset.Criticality] {
+			asset.Criticality = criticality
+		}
+		if firstSeen.Before(asset.FirstSeen) {
+			asset.FirstSeen = firstSeen
+		}
+		if !lastSeen.Before(asset.LastSeen) {
+			asset.LastSeen = lastSeen
+			if scanID != nil {
+				asset.LastScanID = scanID
+			}
+		}
+	}
+	for _, asset := range assets {
+		sort.Strings(asset.Tags)
+	}
+	return assets, rows.Err()
+}
+
+// List returns the tagged assets with their owner, optionally only those
+// with tag, at least minCriticality or seen by the scans of project
+func List(ctx context.Context, db *database.Database, tag, minCriticality, project string) ([]*models.Asset, error) {
+	var inProject map[string]bool
+	if project != "" {
+		var err error
+		if inProject, err = projectAssets(ctx, db, project); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT asset, tag, COALESCE(criticality, ''), source, scan_id, first_seen, last_seen
+		FROM asset_tags
+		WHERE $1 = '' OR asset IN (SELECT asset FROM asset_tags WHERE tag = $1)
+		ORDER BY asset, last_seen
+	`, strings.ToLower(tag))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byName, err := collect(rows)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := LoadOwners(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	assets := []*models.Asset{}
+	for _, asset := range byName {
+		if inProject != nil && !inProject[asset.Asset] {
+			continue
+		}
+		if criticalities[asset.Criticality] >= criticalities[minCriticality] {
+			asset.Owner = owners.Match(assignments, asset.Asset)
+			assets = append(assets, asset)
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		ci, cj := criticalities[assets[i].Criticality], criticalities[assets[j].Criticality]
+		if ci != cj {
+			return ci > cj
+		}
+		return assets[i].Asset < assets[j].Asset
+	})
+	return assets, nil
+}
+
+// projectAssets returns the hosts and subdomains found by the network and
+// recon scans of a project
+func projectAssets(ctx context.Context, db *database.Database, project string) (map[string]bool, error) {
+	assets := map[string]bool{}
+	add := func(query string) error {
+		rows, err := db.Pool.Query(ctx, query, project)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var asset string
+			if err := rows.Scan(&asset); err == nil {
+				assets[asset] = true
+			}
+		}
+		return rows.Err()
+	}
+
+	if err := add(`
+		SELECT DISTINCT lower(r.host) FROM scan_results r
+		JOIN scans s ON s.id = r.scan_id WHERE s.project_id = $1
+	`); err != nil {
+		return nil, err
+	}
+	var hasRecon bool
+	if err := db.Pool.QueryRow(ctx, `SELECT to_regclass('subdomain_results') IS NOT NULL`).Scan(&hasRecon); err != nil || !hasRecon {
+		return assets, err
+	}
+	err := add(`
+		SELECT DISTINCT lower(d.subdomain) FROM subdomain_results d
+		JOIN recon_scans s ON s.id = d.scan_id WHERE s.project_id = $1
+	`)
+	return assets, err
+}
+
+// ValidCriticality reports whether c is empty or a known criticality
+func ValidCriticality(c string) bool {
+	_, ok := criticalities[c]
+	return ok
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package templatepacks
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/security-scanner/network-service/internal/scanner"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxBundleSize is the largest bundle read from a URL or an upload
+const MaxBundleSize = 2 << 20
+
+var (
+	// ErrUnsigned is returned for unsigned packs unless they are allowed
+	ErrUnsigned = errors.New("template pack is not signed; set TEMPLATE_PACKS_ALLOW_UNSIGNED=true to import unsigned packs")
+	// ErrUntrustedKey is returned for packs signed with a key not in TEMPLATE_PACK_KEYS
+	ErrUntrustedKey = errors.New("template pack is signed with an untrusted key")
+	// ErrBadSignature is returned when the signature doesn't match the payload
+	ErrBadSignature = errors.New("template pack signature is invalid")
+)
+
+var packNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// Pack is a community template pack: nmap argument sets, nuclei tag
+// combinations and ffuf configurations, published together under a version
+type Pack struct {
+	Name        string           `json:"name" yaml:"name"`
+	Version     string           `json:"version" yaml:"version"`
+	Publisher   string           `json:"publisher" yaml:"publisher"`
+	Description string           `json:"description" yaml:"description"`
+	Nmap        []NmapTemplate   `json:"nmap" yaml:"nmap"`
+	Nuclei      []NucleiTemplate `json:"nuclei" yaml:"nuclei"`
+	Ffuf        []FfufTemplate   `json:"ffuf" yaml:"ffuf"`
+}
+
+// NmapTemplate becomes a scan template (scan_templates)
+type NmapTemplate struct {
+	Name          string                 `json:"name" yaml:"name"`
+	Description   string                 `json:"description" yaml:"description"`
+	ScanType      string                 `json:"scan_type" yaml:"scan_type"` // custom when empty
+	NmapArguments string                 `json:"nmap_arguments" yaml:"nmap_arguments"`
+	Configuration map[string]interface{} `json:"configuration,omitempty" yaml:"configuration"`
+}
+
+// NucleiTemplate becomes a vulnerability template (vulnerability_templates)
+type NucleiTemplate struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Category    string   `json:"category" yaml:"category"` // community when empty
+	Tags        []string `json:"tags" yaml:"tags"`
+	Severity    []string `json:"severity,omitempty" yaml:"severity"`
+}
+
+// FfufTemplate becomes a web scan template (web_scan_templates) listed by
+// the web service next to its built-in ones
+type FfufTemplate struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description" yaml:"description"`
+	Category    string                 `json:"category" yaml:"category"` // discovery when empty
+	Config      map[string]interface{} `json:"config" yaml:"config"`
+}
+
+// bundle is a signed pack: payload is the base64 pack document (JSON or
+// YAML) and signature its base64 ed25519 signature by key_id
+type bundle struct {
+	Payload   string `yaml:"payload"`
+	KeyID     string `yaml:"key_id"`
+	Signature string `yaml:"signature"`
+}
+
+// Verified is a parsed pack and what its bundle proved about it
+type Verified struct {
+	Pack   *Pack
+	KeyID  string // empty for unsigned packs
+	Digest string // sha256 of the pack document
+}
+
+// Verifier checks bundles against the trusted publisher keys
+type Verifier struct {
+	keys          map[string]ed25519.PublicKey
+	allowUnsigned bool
+}
+
+// NewVerifier parses TEMPLATE_PACK_KEYS: comma-separated key_id=base64
+// ed25519 public keys
+func NewVerifier(keys string, allowUnsigned bool) (*Verifier, error) {
+	v := &Verifier{keys: map[string]ed25519.PublicKey{}, allowUnsigned: allowUnsigned}
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("key %q must be key_id=base64 public key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q is not a base64 ed25519 public key", id)
+		}
+		v.keys[strings.TrimSpace(id)] = ed25519.PublicKey(key)
+	}
+	return v, nil
+}
+
+// Verify parses a bundle, or a bare pack document when unsigned packs are
+// allowed, checks its signature and validates the pack
+func (v *Verifier) Verify(data []byte) (*Verified, error) {
+	var envelope bundle
+	if err := yaml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("template pack is neither JSON nor YAML: %w", err)
+	}
+
+	document, keyID := data, ""
+	if envelope.Payload != "" {
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("template pack payload is not base64: %w", err)
+		}
+		document = payload
+		if envelope.Signature != "" {
+			key, ok := v.keys[envelope.KeyID]
+			if !ok {
+				return nil, fmt.Errorf("%w (%q)", ErrUntrustedKey, envelope.KeyID)
+			}
+			signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+			if err != nil || !ed25519.Verify(key, payload, signature) {
+				return nil, ErrBadSignature
+			}
+			keyID = envelope.KeyID
+		}
+	}
+	if keyID == "" && !v.allowUnsigned {
+		return nil, ErrUnsigned
+	}
+
+	var pack Pack
+	if err := yaml.Unmarshal(document, &pack); err != nil {
+		return nil, fmt.Errorf("template pack is neither JSON nor YAML: %w", err)
+	}
+	if err := pack.validate(); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(document)
+	return &Verified{Pack: &pack, KeyID: keyID, Digest: hex.EncodeToString(digest[:])}, nil
+}
+
+// validate checks the fields every template needs and fills the defaults
+func (p *Pack) validate() error {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if !packNamePattern.MatchString(p.Name) {
+		return errors.New("pack name must be 1 to 63 lowercase letters, digits, dots, dashes or underscores")
+	}
+	if strings.TrimSpace(p.Version) == "" {
+		return errors.New("pack version is required")
+	}
+	if len(p.Nmap)+len(p.Nuclei)+len(p.Ffuf) == 0 {
+		return errors.New("pack has no nmap, nuclei or ffuf templates")
+	}
+
+	seen := map[string]bool{}
+	unique := func(kind, name string) error {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("every %s template needs a name", kind)
+		}
+		if seen[kind+"/"+name] {
+			return fmt.Errorf("%s template %q appears twice", kind, name)
+		}
+		seen[kind+"/"+name] = true
+		return nil
+	}
+	for i := range p.Nmap {
+		t := &p.Nmap[i]
+		if err := unique("nmap", t.Name); err != nil {
+			return err
+		}
+		if strings.TrimSpace(t.NmapArguments) == "" {
+			return fmt.Errorf("nmap template %q has no nmap_arguments", t.Name)
+		}
+		if _, err := scanner.ValidateNmapArguments(t.NmapArguments); err != nil {
+			return fmt.Errorf("nmap template %q: %w", t.Name, err)
+		}
+		if t.ScanType == "" {
+			t.ScanType = "custom"
+		}
+	}
+	for i := range p.Nuclei {
+		t := &p.Nuclei[i]
+		if err := unique("nuclei", t.Name); err != nil {
+			return err
+		}
+		if len(t.Tags) == 0 {
+			return fmt.Errorf("nuclei template %q has no tags", t.Name)
+		}
+		if t.Category == "" {
+			t.Category = "community"
+		}
+	}
+	for i := range p.Ffuf {
+		t := &p.Ffuf[i]
+		if err := unique("ffuf", t.Name); err != nil {
+			return err
+		}
+		if t.Config == nil {
+			return fmt.Errorf("ffuf template %q has no config", t.Name)
+		}
+		if t.Category == "" {
+			t.Category = "discovery"
+		}
+	}
+	return nil
+}
+
+// fetchClient downloads bundles. It only connects to public addresses, so a
+// pack URL (or a redirect, or a name resolving somewhere else later) can't
+// reach the scanner's own network, cloud metadata endpoints or localhost.
+var fetchClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+					return fmt.Errorf("template packs can't be downloaded from %s, which is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return errors.New("redirect to a non-http(s) URL")
+		}
+		return nil
+	},
+}
+
+// publicAddress reports whether ip is routable on the internet
+func publicAddress(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || ip.Equal(net.IPv4bcast))
+}
+
+// Fetch downloads a bundle from an http(s) URL on a public address
+func Fetch(ctx context.Context, source string) ([]byte, error) {
+	parsed, err := url.Parse(source)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.New("url must be an http(s) URL")
+	}
+	if parsed.User != nil {
+		return nil, errors.New("url must not contain credentials")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download template pack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download template pack: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download template pack: %w", err)
+	}
+	if len(data) > MaxBundleSize {
+		return nil, fmt.Errorf("template pack is larger than %d bytes", MaxBundleSize)
+	}
+	return bytes.TrimSpace(data), nil
+}
@@ -0,0 +1,292 @@
+// Package templatepacks imports community template packs into the scan
+// (nmap), vulnerability (nuclei) and web scan (ffuf) templates, keeping
+// where each pack came from so it can be updated in place.
+package templatepacks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/network-service/internal/database"
+	"github.com/security-scanner/network-service/internal/models"
+)
+
+var (
+	// ErrNotFound is returned for an unknown pack
+	ErrNotFound = errors.New("template pack not found")
+	// ErrExists is returned when importing a pack the project already has
+	ErrExists = errors.New("template pack already imported; update it instead")
+	// ErrConflict is returned when a template name is taken outside the pack
+	ErrConflict = errors.New("template name already used")
+	// ErrWrongPack is returned when updating a pack with another pack's bundle
+	ErrWrongPack = errors.New("bundle is a different template pack")
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS template_packs (
+    id UUID PRIMARY KEY,
+    name VARCHAR(63) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    publisher VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    source_url TEXT,
+    key_id VARCHAR(255),
+    digest VARCHAR(64) NOT NULL,
+    imported_by VARCHAR(255) NOT NULL DEFAULT '',
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, name)
+);
+ALTER TABLE scan_templates ADD COLUMN IF NOT EXISTS pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE;
+ALTER TABLE vulnerability_templates ADD COLUMN IF NOT EXISTS pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE;
+CREATE TABLE IF NOT EXISTS web_scan_templates (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    tool VARCHAR(50) NOT NULL,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    configuration JSONB NOT NULL DEFAULT '{}',
+    project_id VARCHAR(63) NOT NULL DEFAULT 'default',
+    pack_id UUID REFERENCES template_packs(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_web_scan_templates_project_id ON web_scan_templates(project_id)`
+
+// EnsureSchema creates the template pack tables
+func EnsureSchema(db *database.Database) error {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return fmt.Errorf("failed to create template pack tables: %w", err)
+	}
+	return nil
+}
+
+// Provenance is where an import came from: the URL (nil for uploads) and
+// who imported it
+type Provenance struct {
+	SourceURL  *string
+	ImportedBy string
+	ProjectID  string
+}
+
+const packColumns = `id, name, version, publisher, description, source_url, key_id, digest, imported_by, project_id, imported_at, updated_at,
+	(SELECT COUNT(*) FROM scan_templates WHERE pack_id = template_packs.id),
+	(SELECT COUNT(*) FROM vulnerability_templates WHERE pack_id = template_packs.id),
+	(SELECT COUNT(*) FROM web_scan_templates WHERE pack_id = template_packs.id)`
+
+func scanPack(row pgx.Row) (*models.TemplatePack, error) {
+	var p models.TemplatePack
+	err := row.Scan(&p.ID, &p.Name, &p.Version, &p.Publisher, &p.Description, &p.SourceURL, &p.KeyID, &p.Digest,
+		&p.ImportedBy, &p.ProjectID, &p.ImportedAt, &p.UpdatedAt, &p.NmapTemplates, &p.NucleiTemplates, &p.FfufTemplates)
+	if err != nil {
+		return nil, err
+	}
+	p.Signed = p.KeyID != nil
+	return &p, nil
+}
+
+// List returns the packs of a project scope (empty for every project),
+// sorted by name
+func List(ctx context.Context, db *database.Database, scope string) ([]*models.TemplatePack, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+packColumns+` FROM template_packs
+		WHERE $1 = '' OR project_id = $1 ORDER BY name`, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	packs := []*models.TemplatePack{}
+	for rows.Next() {
+		pack, err := scanPack(rows)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, rows.Err()
+}
+
+// Get returns a pack and the templates it imported
+func Get(ctx context.Context, db *database.Database, id uuid.UUID) (*models.TemplatePack, error) {
+	pack, err := scanPack(db.Pool.QueryRow(ctx, `SELECT `+packColumns+` FROM template_packs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT 'nmap', id, name FROM scan_templates WHERE pack_id = $1
+		UNION ALL SELECT 'nuclei', id, name FROM vulnerability_templates WHERE pack_id = $1
+		UNION ALL SELECT 'ffuf', id, name FROM web_scan_templates WHERE pack_id = $1
+		ORDER BY 1, 3
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pack.Templates = []models.TemplatePackEntry{}
+	for rows.Next() {
+		var entry models.TemplatePackEntry
+		if err := rows.Scan(&entry.Kind, &entry.ID, &entry.Name); err != nil {
+			return nil, err
+		}
+		pack.Templates = append(pack.Templates, entry)
+	}
+	return pack, rows.Err()
+}
+
+// Import stores a verified pack and its templates. Template names are
+// prefixed with the pack name ("acme/Fast web").
+func Import(ctx context.Context, db *database.Database, verified *Verified, provenance Provenance) (*models.TemplatePack, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	pack := verified.Pack
+	id := uuid.New()
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO template_packs (id, name, version, publisher, description, source_url, key_id, digest, imported_by, project_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10)
+		ON CONFLICT (project_id, name) DO NOTHING
+	`, id, pack.Name, pack.Version, pack.Publisher, pack.Description, provenance.SourceURL, verified.KeyID,
+		verified.Digest, provenance.ImportedBy, provenance.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrExists
+	}
+	if err := insertTemplates(ctx, tx, id, pack, provenance.ProjectID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return Get(ctx, db, id)
+}
+
+// Update replaces the templates of a pack with those of a newer bundle of
+// the same pack. It returns false when the bundle has the content already
+// imported. Edits made to the pack's templates are overwritten.
+func Update(ctx context.Context, db *database.Database, id uuid.UUID, verified *Verified, sourceURL *string) (bool, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var name, digest, projectID string
+	err = tx.QueryRow(ctx, `SELECT name, digest, project_id FROM template_packs WHERE id = $1 FOR UPDATE`, id).
+		Scan(&name, &digest, &projectID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	pack := verified.Pack
+	if pack.Name != name {
+		return false, fmt.Errorf("%w: %q, not %q", ErrWrongPack, pack.Name, name)
+	}
+	if verified.Digest == digest {
+		return false, nil
+	}
+
+	for _, table := range []string{"scan_templates", "vulnerability_templates", "web_scan_templates"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE pack_id = $1`, id); err != nil {
+			return false, err
+		}
+	}
+	if err := insertTemplates(ctx, tx, id, pack, projectID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE template_packs
+		SET version = $2, publisher = $3, description = $4, source_url = COALESCE($5, source_url),
+		    key_id = NULLIF($6, ''), digest = $7, updated_at = $8
+		WHERE id = $1
+	`, id, pack.Version, pack.Publisher, pack.Description, sourceURL, verified.KeyID, verified.Digest, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// Delete removes a pack and the templates it imported
+func Delete(ctx context.Context, db *database.Database, id uuid.UUID) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM template_packs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// insertTemplates stores the templates of a pack, failing on names used
+// by templates outside it
+func insertTemplates(ctx context.Context, tx pgx.Tx, packID uuid.UUID, pack *Pack, projectID string) error {
+	now := time.Now()
+	for _, t := range pack.Nmap {
+		name := pack.Name + "/" + t.Name
+		if err := checkName(ctx, tx, "scan_templates", name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO scan_templates (id, name, description, scan_type, nmap_arguments, configuration, is_default, project_id, pack_id, created_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, false, $7, $8, $9)
+		`, uuid.New(), name, t.Description, t.ScanType, t.NmapArguments, t.Configuration, projectID, packID, now)
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range pack.Nuclei {
+		name := pack.Name + "/" + t.Name
+		if err := checkName(ctx, tx, "vulnerability_templates", name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO vulnerability_templates (id, name, description, category, nuclei_tags, severity_filter, is_default, project_id, pack_id, created_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, false, $7, $8, $9)
+		`, uuid.New(), name, t.Description, t.Category, t.Tags, t.Severity, projectID, packID, now)
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range pack.Ffuf {
+		name := pack.Name + "/" + t.Name
+		if err := checkName(ctx, tx, "web_scan_templates", name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO web_scan_templates (id, name, description, tool, category, configuration, project_id, pack_id, created_at)
+			VALUES ($1, $2, $3, 'ffuf', $4, $5, $6, $7, $8)
+		`, uuid.New(), name, t.Description, t.Category, t.Config, projectID, packID, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkName fails when another template of table already has the name
+func checkName(ctx context.Context, tx pgx.Tx, table, name string) error {
+	var taken bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE name = $1)`, name).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: %q", ErrConflict, name)
+	}
+	return nil
+}
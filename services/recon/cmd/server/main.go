@@ -10,6 +10,9 @@ import (
 	"github.com/security-scanner/recon-service/internal/database"
 	"github.com/security-scanner/recon-service/internal/recon"
 	"github.com/security-scanner/recon-service/pkg/config"
+	"github.com/security-scanner/shared/pkg/client"
+	"github.com/security-scanner/shared/pkg/internalauth"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 func main() {
@@ -28,16 +31,50 @@ func main() {
 	defer db.Close()
 
 	// Initialize scanners
-	subdomainScanner := recon.NewSubdomainScanner(db, cfg.SubfinderPath, cfg.AmassPath)
+	subdomainScanner := recon.NewSubdomainScanner(db, cfg.SubfinderPath, cfg.AmassPath, cfg.HttpxPath)
 	whoisScanner := recon.NewWhoisScanner(db)
 	dnsScanner := recon.NewDNSScanner(db)
 	techScanner := recon.NewTechScanner(db, cfg.HttpxPath)
+	codeLeakScanner := recon.NewCodeLeakScanner(db, cfg.GitHubToken, cfg.GitLabURL, cfg.GitLabToken)
+	emailScanner := recon.NewEmailScanner(db, cfg.HunterAPIKey, cfg.HIBPAPIKey, cfg.HIBPRate)
+	simulator := recon.NewSimulator(db)
+	dnsResolvers, err := securedns.ParseEndpoints(cfg.DNSResolvers)
+	if err != nil {
+		log.Fatalf("Invalid DNS_RESOLVERS: %v", err)
+	}
+	dnsScanner.SetResolvers(dnsResolvers)
+	subdomainScanner.SetResolvers(dnsResolvers)
+	subdomainScanner.SetCertSpotterToken(cfg.CertSpotterToken)
 
 	log.Printf("Initialized scanners: Subfinder (%s), Amass (%s), Httpx (%s)",
 		cfg.SubfinderPath, cfg.AmassPath, cfg.HttpxPath)
+	if cfg.GitHubToken == "" && cfg.GitLabToken == "" {
+		log.Println("⚠️ GITHUB_TOKEN and GITLAB_TOKEN are not set, code leak scans are disabled")
+	}
 
 	// Initialize handlers
-	reconHandler := handlers.NewReconHandler(db, subdomainScanner, whoisScanner, dnsScanner, techScanner)
+	reconHandler := handlers.NewReconHandler(db, subdomainScanner, whoisScanner, dnsScanner, techScanner, codeLeakScanner, emailScanner, simulator)
+
+	// Scans of targets outside the scope rules are rejected
+	scopeChecker, err := db.Scope()
+	if err != nil {
+		log.Fatalf("Failed to initialize scope rules: %v", err)
+	}
+	reconHandler.SetScope(scopeChecker)
+
+	// recon_full scans run the other scanners as steps, then screenshot
+	// the alive hosts through the web service
+	pipeline := recon.NewPipeline(db, subdomainScanner, dnsScanner, techScanner, simulator)
+	if cfg.WebServiceURL != "" {
+		var sign client.Signer
+		if cfg.InternalAuthSecret != "" {
+			sign = client.InternalSigner(cfg.InternalAuthSecret)
+		}
+		pipeline.SetWebService(cfg.WebServiceURL, sign)
+	} else {
+		log.Println("⚠️ WEB_SERVICE_URL is not set, recon_full scans take no screenshots")
+	}
+	reconHandler.SetPipeline(pipeline)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -46,6 +83,7 @@ func main() {
 	})
 
 	// Middleware
+	app.Use(middleware.Errors()) // first, so it sees every error
 	app.Use(recover.New())
 	app.Use(middleware.Logger())
 	app.Use(middleware.CORS())
@@ -56,12 +94,14 @@ func main() {
 			"status":  "ok",
 			"service": "recon-service",
 			"version": "1.0.0",
-			"tools":   []string{"subfinder", "amass", "whois", "dns", "httpx"},
+			"tools":   []string{"subfinder", "amass", "whois", "dns", "httpx", "github", "gitlab", "hunter", "hibp"},
 		})
 	})
 
-	// Routes
-	api := app.Group("/api")
+	// Routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalauth.LogStatus(cfg.InternalAuthSecret)
+	api := app.Group("/api", middleware.InternalAuth(cfg.InternalAuthSecret))
 
 	// Recon routes
 	recons := api.Group("/recon")
@@ -69,10 +109,14 @@ func main() {
 	recons.Post("/", reconHandler.CreateScan)
 	recons.Get("/:id", reconHandler.GetScan)
 	recons.Get("/:id/results", reconHandler.GetScanResults)
+	recons.Get("/:id/graph", reconHandler.GetScanGraph)
 	recons.Get("/:id/logs", reconHandler.GetScanLogs)
 	recons.Delete("/:id", reconHandler.DeleteScan)
 	recons.Post("/:id/cancel", reconHandler.CancelScan)
 
+	// Scan types and their options, for forms and config validation
+	api.Get("/capabilities", reconHandler.GetCapabilities)
+
 	// Start server
 	log.Printf("Server listening on port %s", cfg.Port)
 	if err := app.Listen(":" + cfg.Port); err != nil {
@@ -8,6 +8,7 @@ require (
 	github.com/lib/pq v1.10.9
 	github.com/likexian/whois v1.15.1
 	github.com/likexian/whois-parser v1.24.9
+	github.com/security-scanner/shared v0.0.0
 )
 
 require (
@@ -25,3 +26,6 @@ require (
 	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
+
+// Shared models, clients and database helpers (services/shared)
+replace github.com/security-scanner/shared => ../shared
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/recon-service/internal/recon"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+// reconCapabilities describes CreateReconRequest for each scan type
+var reconCapabilities = capabilities.New("recon", []capabilities.ScanType{
+	reconScanType("subdomain", "Subdomain Enumeration", "subfinder, amass and certificate transparency enumeration with wildcard detection and httpx probing", resolversOption,
+		capabilities.Field{Name: "http_probe", Type: capabilities.Boolean, Default: true, Description: "Probe the live subdomains over HTTP and HTTPS with httpx"},
+	),
+	reconScanType("whois", "WHOIS Lookup", "Registrar, dates and contacts of a domain"),
+	reconScanType("dns", "DNS Records", "A, AAAA, MX, NS, TXT and other records of a domain", resolversOption),
+	reconScanType("tech", "Technology Detection", "Web technologies of the target with httpx"),
+	reconScanType("code_leaks", "Code Leak Search", "Mentions and possible credential leaks of the domain on GitHub and GitLab",
+		capabilities.Field{Name: "platforms", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"github", "gitlab"}}, Description: "Platforms with a configured token; all when empty"},
+		capabilities.Field{Name: "org", Type: capabilities.String, Description: "Restrict the search to an organization"},
+		capabilities.Field{Name: "keywords", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Terms searched next to the domain"},
+		capabilities.Field{Name: "max_results", Type: capabilities.Integer, Default: 100, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(100), Description: "Results per search"},
+	),
+	reconScanType("emails", "Email Harvesting", "Addresses of the target domains and their known breaches",
+		capabilities.Field{Name: "sources", Type: capabilities.Array, Default: []string{"hunter", "whois", "scrape"}, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"hunter", "whois", "scrape"}}},
+		capabilities.Field{Name: "consent", Type: capabilities.Boolean, Default: false, Description: "Confirms you are authorized to crawl the target's website; scrape is skipped without it"},
+		capabilities.Field{Name: "check_breaches", Type: capabilities.Boolean, Default: true, Description: "Look up each address on Have I Been Pwned"},
+	),
+	reconScanType("recon_full", "Full Recon Pipeline", "Subdomain enumeration, DNS records and technology detection of the alive hosts as linked scans, then gowitness screenshots on the web service", resolversOption,
+		capabilities.Field{Name: "screenshots", Type: capabilities.Boolean, Default: true, Description: "Screenshot the alive hosts with a gowitness scan of the web service"},
+		capabilities.Field{Name: "max_hosts", Type: capabilities.Integer, Default: recon.PipelineDefaultMaxHosts, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(1000), Description: "Alive hosts fingerprinted and screenshotted"},
+	),
+})
+
+// resolversOption replaces the system resolver with DoH/DoT resolvers
+var resolversOption = capabilities.Field{
+	Name: "resolvers", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Format: "uri"},
+	Description: "DNS-over-HTTPS (https://) or DNS-over-TLS (tls://) resolvers; the system resolver when empty",
+}
+
+// reconScanType describes a scan type whose options are the given fields
+func reconScanType(name, title, description string, options ...capabilities.Field) capabilities.ScanType {
+	return capabilities.ScanType{
+		Name:        name,
+		Title:       title,
+		Description: description,
+		Tool:        name,
+		Endpoint:    "POST /api/recon",
+		Fields: []capabilities.Field{
+			{Name: "name", Type: capabilities.String, Description: "Defaults to \"<scan_type> - <target>\""},
+			{Name: "target", Type: capabilities.String, Required: true},
+			{Name: "scan_type", Type: capabilities.String, Required: true, Default: name, Enum: []string{name}},
+			{Name: "options", Type: capabilities.Object, Fields: options},
+			{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+		},
+	}
+}
+
+// GetCapabilities describes the scan types and their options, for UIs and
+// the CLI to render forms and validate configurations
+func (h *ReconHandler) GetCapabilities(c *fiber.Ctx) error {
+	return c.JSON(reconCapabilities)
+}
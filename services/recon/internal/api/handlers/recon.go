@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,6 +10,11 @@ import (
 	"github.com/security-scanner/recon-service/internal/database"
 	"github.com/security-scanner/recon-service/internal/models"
 	"github.com/security-scanner/recon-service/internal/recon"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type ReconHandler struct {
@@ -17,33 +23,76 @@ type ReconHandler struct {
 	whoisScanner     *recon.WhoisScanner
 	dnsScanner       *recon.DNSScanner
 	techScanner      *recon.TechScanner
+	codeLeakScanner  *recon.CodeLeakScanner
+	emailScanner     *recon.EmailScanner
+	simulator        *recon.Simulator
+	graphBuilder     *recon.GraphBuilder
+	pipeline         *recon.Pipeline
+	scope            *scope.Checker
 }
 
-func NewReconHandler(db *database.Database, subdomain *recon.SubdomainScanner, whois *recon.WhoisScanner, dns *recon.DNSScanner, tech *recon.TechScanner) *ReconHandler {
+func NewReconHandler(db *database.Database, subdomain *recon.SubdomainScanner, whois *recon.WhoisScanner, dns *recon.DNSScanner, tech *recon.TechScanner, codeLeaks *recon.CodeLeakScanner, emails *recon.EmailScanner, simulator *recon.Simulator) *ReconHandler {
 	return &ReconHandler{
 		db:               db,
 		subdomainScanner: subdomain,
 		whoisScanner:     whois,
 		dnsScanner:       dns,
 		techScanner:      tech,
+		codeLeakScanner:  codeLeaks,
+		emailScanner:     emails,
+		simulator:        simulator,
+		graphBuilder:     recon.NewGraphBuilder(db),
 	}
 }
 
-// ListScans returns all recon scans
+// SetScope rejects scans of targets outside the scope rules
+func (h *ReconHandler) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
+// SetPipeline runs recon_full scans
+func (h *ReconHandler) SetPipeline(pipeline *recon.Pipeline) {
+	h.pipeline = pipeline
+}
+
+// reconSortFields are the fields ListScans sorts by, and their columns
+var reconSortFields = map[string]string{
+	"created_at":   "created_at",
+	"started_at":   "started_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"target":       "target",
+	"scan_type":    "scan_type",
+	"status":       "status",
+	"progress":     "progress",
+}
+
+// ListScans returns a page of recon scans (?page=, ?limit=, ?sort=, newest
+// first by default), filtered by ?type=, ?status=, ?origin= and ?project=
 func (h *ReconHandler) ListScans(c *fiber.Ctx) error {
 	scanType := c.Query("type", "")
 	status := c.Query("status", "")
+	originFilter := c.Query("origin", "")
+	if originFilter != "" {
+		if err := origin.ValidFilter(originFilter); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), reconSortFields, "-created_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	scans, err := h.db.ListScans(scanType, status)
+	scans, total, err := h.db.ListScans(scanType, status, originFilter, project.Scope(c.Query("project"), c.Get(project.Header)), page)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if scans == nil {
-		scans = []models.ReconScan{}
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
 	}
-
-	return c.JSON(scans)
+	return c.JSON(result)
 }
 
 // CreateScan creates a new recon scan
@@ -62,9 +111,50 @@ func (h *ReconHandler) CreateScan(c *fiber.Ctx) error {
 	}
 
 	// Validate scan type
-	validTypes := map[string]bool{"subdomain": true, "whois": true, "dns": true, "tech": true}
+	validTypes := map[string]bool{"subdomain": true, "whois": true, "dns": true, "tech": true, "code_leaks": true, "emails": true, "recon_full": true}
 	if !validTypes[req.ScanType] {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan type. Valid types: subdomain, whois, dns, tech"})
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan type. Valid types: subdomain, whois, dns, tech, code_leaks, emails, recon_full"})
+	}
+
+	// DoH/DoT resolvers replace the system one for the scan's own lookups
+	if value, ok := req.Options["resolvers"]; ok {
+		if req.ScanType != "dns" && req.ScanType != "subdomain" && req.ScanType != "recon_full" {
+			return c.Status(400).JSON(fiber.Map{"error": "resolvers only apply to dns, subdomain and recon_full scans"})
+		}
+		if _, err := securedns.FromConfiguration(value); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	if value, ok := req.Options["http_probe"]; ok {
+		if req.ScanType != "subdomain" {
+			return c.Status(400).JSON(fiber.Map{"error": "http_probe only applies to subdomain scans"})
+		}
+		if _, ok := value.(bool); !ok {
+			return c.Status(400).JSON(fiber.Map{"error": "http_probe must be true or false"})
+		}
+	}
+
+	_, hasScreenshots := req.Options["screenshots"]
+	_, hasMaxHosts := req.Options["max_hosts"]
+	if req.ScanType == "recon_full" {
+		if _, _, err := recon.PipelineOptions(req.Options); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else if hasScreenshots || hasMaxHosts {
+		return c.Status(400).JSON(fiber.Map{"error": "screenshots and max_hosts only apply to recon_full scans"})
+	}
+
+	if status, body := scopeError(h.scope, project.FromRequest(c.Get(project.Header)), req.Simulate, req.Target); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+
+	// Flag simulated scans so their results are never mistaken for real ones
+	if req.Simulate {
+		if req.Options == nil {
+			req.Options = map[string]interface{}{}
+		}
+		req.Options["simulated"] = true
 	}
 
 	scan := &models.ReconScan{
@@ -76,6 +166,8 @@ func (h *ReconHandler) CreateScan(c *fiber.Ctx) error {
 		Progress:  0,
 		CreatedAt: time.Now(),
 		Options:   req.Options,
+		Origin:    origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)),
+		ProjectID: project.FromRequest(c.Get(project.Header)),
 	}
 
 	if scan.Name == "" {
@@ -93,19 +185,31 @@ func (h *ReconHandler) CreateScan(c *fiber.Ctx) error {
 }
 
 func (h *ReconHandler) runScan(scan *models.ReconScan) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	timeout := 30 * time.Minute
+	if scan.ScanType == "recon_full" {
+		timeout = recon.PipelineTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	var err error
-	switch scan.ScanType {
-	case "subdomain":
+	switch {
+	case scan.ScanType == "recon_full": // simulated or not, each step is
+		err = h.pipeline.Scan(ctx, scan)
+	case scan.Options["simulated"] == true:
+		err = h.simulator.Scan(ctx, scan)
+	case scan.ScanType == "subdomain":
 		err = h.subdomainScanner.Scan(ctx, scan)
-	case "whois":
+	case scan.ScanType == "whois":
 		err = h.whoisScanner.Scan(ctx, scan)
-	case "dns":
+	case scan.ScanType == "dns":
 		err = h.dnsScanner.Scan(ctx, scan)
-	case "tech":
+	case scan.ScanType == "tech":
 		err = h.techScanner.Scan(ctx, scan)
+	case scan.ScanType == "code_leaks":
+		err = h.codeLeakScanner.Scan(ctx, scan)
+	case scan.ScanType == "emails":
+		err = h.emailScanner.Scan(ctx, scan)
 	}
 
 	if err != nil {
@@ -147,12 +251,20 @@ func (h *ReconHandler) GetScanResults(c *fiber.Ctx) error {
 
 	switch scan.ScanType {
 	case "subdomain":
-		subdomains, _ := h.db.GetSubdomainResults(id)
+		// Wildcard DNS matches come last; ?wildcards=exclude leaves them out
+		subdomains, _ := h.db.GetSubdomainResults(id, c.Query("wildcards") == "exclude")
 		if subdomains == nil {
 			subdomains = []models.SubdomainResult{}
 		}
+		wildcards := 0
+		for _, sub := range subdomains {
+			if sub.Wildcard != "" {
+				wildcards++
+			}
+		}
 		result["subdomains"] = subdomains
 		result["total"] = len(subdomains)
+		result["wildcards"] = wildcards
 
 	case "whois":
 		whois, _ := h.db.GetWhoisResult(id)
@@ -168,11 +280,95 @@ func (h *ReconHandler) GetScanResults(c *fiber.Ctx) error {
 			tech = []models.TechResult{}
 		}
 		result["technologies"] = tech
+
+	case "code_leaks":
+		leaks, _ := h.db.GetCodeLeakResults(id)
+		if leaks == nil {
+			leaks = []models.CodeLeakResult{}
+		}
+		findings := 0
+		for _, leak := range leaks {
+			if leak.Severity != "info" {
+				findings++
+			}
+		}
+		result["leaks"] = leaks
+		result["total"] = len(leaks)
+		result["findings"] = findings
+
+	case "emails":
+		emails, _ := h.db.GetEmailResults(id)
+		if emails == nil {
+			emails = []models.EmailResult{}
+		}
+		result["emails"] = emails
+		result["total"] = len(emails)
+		result["domains"] = recon.SummarizeEmails(emails)
+
+	case "recon_full":
+		// The results of each step are those of its own scan
+		steps := recon.PipelineSteps(h.db, scan)
+		for _, step := range steps {
+			switch step.ScanType {
+			case "subdomain":
+				subdomains, _ := h.db.GetSubdomainResults(step.ID, c.Query("wildcards") == "exclude")
+				if subdomains == nil {
+					subdomains = []models.SubdomainResult{}
+				}
+				result["subdomains"] = subdomains
+				result["total"] = len(subdomains)
+			case "dns":
+				dns, _ := h.db.GetDNSResult(step.ID)
+				result["dns"] = dns
+			case "tech":
+				tech, _ := h.db.GetTechResults(step.ID)
+				if tech == nil {
+					tech = []models.TechResult{}
+				}
+				result["technologies"] = tech
+			}
+		}
+		result["steps"] = steps
 	}
 
 	return c.JSON(result)
 }
 
+// GetScanGraph returns the scan results as a nodes/edges graph (JSON or GraphML)
+func (h *ReconHandler) GetScanGraph(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+
+	scan, err := h.db.GetScan(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "graphml" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid format. Valid formats: json, graphml"})
+	}
+
+	graph, err := h.graphBuilder.Build(c.Context(), scan, c.QueryBool("asn", false))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if format == "graphml" {
+		data, err := graph.ToGraphML()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=recon_%s.graphml", id))
+		c.Set("Content-Type", "application/xml")
+		return c.Send(data)
+	}
+
+	return c.JSON(graph)
+}
+
 // GetScanLogs returns logs for a scan
 func (h *ReconHandler) GetScanLogs(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
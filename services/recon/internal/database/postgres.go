@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,12 @@ import (
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/security-scanner/recon-service/internal/models"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type Database struct {
@@ -26,39 +33,10 @@ func NewDatabase(connectionString string) (*Database, error) {
 		}
 	}
 
-	// Retry logic with exponential backoff
-	maxRetries := 10
-	var db *sql.DB
-	var err error
-
-	for i := 0; i < maxRetries; i++ {
-		db, err = sql.Open("postgres", connectionString)
-		if err != nil {
-			waitTime := time.Duration(1<<uint(i)) * time.Second
-			if waitTime > 30*time.Second {
-				waitTime = 30 * time.Second
-			}
-			fmt.Printf("Failed to open database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-			time.Sleep(waitTime)
-			continue
-		}
-
-		err = db.Ping()
-		if err == nil {
-			break
-		}
-
-		db.Close()
-		waitTime := time.Duration(1<<uint(i)) * time.Second
-		if waitTime > 30*time.Second {
-			waitTime = 30 * time.Second
-		}
-		fmt.Printf("Failed to ping database (attempt %d/%d): %v. Retrying in %v...\n", i+1, maxRetries, err, waitTime)
-		time.Sleep(waitTime)
-	}
-
+	// Retries while the database starts, like every service
+	db, err := shareddb.OpenSQL("postgres", connectionString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
 
 	database := &Database{db: db}
@@ -73,6 +51,17 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// Scope creates the scope rule table, managed through the gateway, and
+// returns the checker scans' targets must pass
+func (d *Database) Scope() (*scope.Checker, error) {
+	if _, err := d.db.Exec(scope.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return d.db.QueryRowContext(ctx, query, args...)
+	}), nil
+}
+
 func (d *Database) runMigrations() error {
 	migrations := []string{
 		`CREATE TABLE IF NOT EXISTS recon_scans (
@@ -88,6 +77,9 @@ func (d *Database) runMigrations() error {
 			error_message TEXT,
 			configuration JSONB DEFAULT '{}'
 		)`,
+		`ALTER TABLE recon_scans DROP CONSTRAINT IF EXISTS valid_recon_scan_type`,
+		`ALTER TABLE recon_scans ADD CONSTRAINT valid_recon_scan_type
+			CHECK (scan_type IN ('subdomain', 'whois', 'dns', 'tech', 'code_leaks', 'emails', 'recon_full'))`,
 		`CREATE TABLE IF NOT EXISTS subdomain_results (
 			id UUID PRIMARY KEY,
 			scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
@@ -138,6 +130,35 @@ func (d *Database) runMigrations() error {
 			content_type VARCHAR(255),
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS code_leak_results (
+			id UUID PRIMARY KEY,
+			scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
+			platform VARCHAR(20) NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			repository VARCHAR(512),
+			path TEXT,
+			url TEXT NOT NULL,
+			query TEXT,
+			severity VARCHAR(20) NOT NULL,
+			secrets JSONB,
+			snippet TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(scan_id, url)
+		)`,
+		`CREATE TABLE IF NOT EXISTS email_results (
+			id UUID PRIMARY KEY,
+			scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
+			email VARCHAR(320) NOT NULL,
+			domain VARCHAR(255) NOT NULL,
+			sources TEXT[],
+			confidence INTEGER,
+			name VARCHAR(255),
+			position VARCHAR(255),
+			breach_checked BOOLEAN DEFAULT false,
+			breaches JSONB,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(scan_id, email)
+		)`,
 		`CREATE TABLE IF NOT EXISTS recon_logs (
 			id UUID PRIMARY KEY,
 			scan_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE,
@@ -145,12 +166,26 @@ func (d *Database) runMigrations() error {
 			message TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS resolver_health JSONB`,
+		`ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS origin VARCHAR(150) NOT NULL DEFAULT 'manual'`,
+		`ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS project_id VARCHAR(63) NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS sources TEXT[]`,
+		`ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS title TEXT`,
+		`ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS content_length INTEGER`,
+		`ALTER TABLE subdomain_results ADD COLUMN IF NOT EXISTS web_server VARCHAR(255)`,
+		`ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES recon_scans(id) ON DELETE CASCADE`,
+		`ALTER TABLE recon_scans ADD COLUMN IF NOT EXISTS screenshot_scan_id UUID`,
 		`CREATE INDEX IF NOT EXISTS idx_recon_scans_status ON recon_scans(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_recon_scans_scan_type ON recon_scans(scan_type)`,
+		`CREATE INDEX IF NOT EXISTS idx_recon_scans_origin ON recon_scans(origin)`,
+		`CREATE INDEX IF NOT EXISTS idx_recon_scans_project_id ON recon_scans(project_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_recon_scans_parent_id ON recon_scans(parent_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_subdomain_results_scan_id ON subdomain_results(scan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_whois_results_scan_id ON whois_results(scan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_dns_results_scan_id ON dns_results(scan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_tech_results_scan_id ON tech_results(scan_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_code_leak_results_scan_id ON code_leak_results(scan_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_results_scan_id ON email_results(scan_id)`,
 	}
 
 	for _, migration := range migrations {
@@ -166,23 +201,25 @@ func (d *Database) runMigrations() error {
 func (d *Database) CreateScan(scan *models.ReconScan) error {
 	optionsJSON, _ := json.Marshal(scan.Options)
 	_, err := d.db.Exec(`
-		INSERT INTO recon_scans (id, name, target, scan_type, status, progress, created_at, configuration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, scan.ID, scan.Name, scan.Target, scan.ScanType, scan.Status, scan.Progress, scan.CreatedAt, optionsJSON)
+		INSERT INTO recon_scans (id, name, target, scan_type, status, progress, created_at, configuration, origin, project_id, parent_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, scan.ID, scan.Name, scan.Target, scan.ScanType, scan.Status, scan.Progress, scan.CreatedAt, optionsJSON, scan.Origin, scan.ProjectID, scan.ParentID)
 	return err
 }
 
 func (d *Database) GetScan(id uuid.UUID) (*models.ReconScan, error) {
 	var scan models.ReconScan
-	var optionsJSON []byte
+	var optionsJSON, healthJSON []byte
 	var startedAt, completedAt sql.NullTime
 	var errorMessage sql.NullString
 
 	err := d.db.QueryRow(`
-		SELECT id, name, target, scan_type, status, progress, created_at, started_at, completed_at, error_message, configuration
+		SELECT id, name, target, scan_type, status, progress, created_at, started_at, completed_at, error_message, configuration,
+		       resolver_health, origin, project_id, parent_id, screenshot_scan_id
 		FROM recon_scans WHERE id = $1
 	`, id).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress,
-		&scan.CreatedAt, &startedAt, &completedAt, &errorMessage, &optionsJSON)
+		&scan.CreatedAt, &startedAt, &completedAt, &errorMessage, &optionsJSON, &healthJSON, &scan.Origin, &scan.ProjectID,
+		&scan.ParentID, &scan.ScreenshotScanID)
 
 	if err != nil {
 		return nil, err
@@ -198,31 +235,57 @@ func (d *Database) GetScan(id uuid.UUID) (*models.ReconScan, error) {
 		scan.ErrorMessage = &errorMessage.String
 	}
 	json.Unmarshal(optionsJSON, &scan.Options)
+	if healthJSON != nil {
+		json.Unmarshal(healthJSON, &scan.ResolverHealth)
+	}
 
 	return &scan, nil
 }
 
-func (d *Database) ListScans(scanType, status string) ([]models.ReconScan, error) {
-	query := `SELECT id, name, target, scan_type, status, progress, created_at, started_at, completed_at, error_message, configuration FROM recon_scans WHERE 1=1`
+// ListScans returns the page of the scans matching the non-empty filters
+// and how many match; originFilter must have been checked with
+// origin.ValidFilter
+func (d *Database) ListScans(scanType, status, originFilter, projectScope string, page pagination.Params) ([]models.ReconScan, int, error) {
+	where := ` WHERE 1=1`
 	args := []interface{}{}
 	argIndex := 1
 
 	if scanType != "" {
-		query += fmt.Sprintf(" AND scan_type = $%d", argIndex)
+		where += fmt.Sprintf(" AND scan_type = $%d", argIndex)
 		args = append(args, scanType)
 		argIndex++
 	}
 	if status != "" {
-		query += fmt.Sprintf(" AND status = $%d", argIndex)
+		where += fmt.Sprintf(" AND status = $%d", argIndex)
 		args = append(args, status)
 		argIndex++
 	}
+	if originFilter != "" {
+		condition, value, err := origin.Filter("origin", argIndex, originFilter)
+		if err != nil {
+			return nil, 0, err
+		}
+		where += " AND " + condition
+		args = append(args, value)
+		argIndex++
+	}
+	if projectScope != "" {
+		where += " AND " + project.Filter("project_id", argIndex)
+		args = append(args, projectScope)
+		argIndex++
+	}
 
-	query += " ORDER BY created_at DESC"
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM recon_scans`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
 
+	query := `SELECT id, name, target, scan_type, status, progress, created_at, started_at, completed_at, error_message, configuration, origin, project_id,
+		parent_id, screenshot_scan_id FROM recon_scans` +
+		where + page.OrderBy()
 	rows, err := d.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -234,7 +297,8 @@ func (d *Database) ListScans(scanType, status string) ([]models.ReconScan, error
 		var errorMessage sql.NullString
 
 		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.ScanType, &scan.Status, &scan.Progress,
-			&scan.CreatedAt, &startedAt, &completedAt, &errorMessage, &optionsJSON)
+			&scan.CreatedAt, &startedAt, &completedAt, &errorMessage, &optionsJSON, &scan.Origin, &scan.ProjectID,
+			&scan.ParentID, &scan.ScreenshotScanID)
 		if err != nil {
 			continue
 		}
@@ -252,7 +316,7 @@ func (d *Database) ListScans(scanType, status string) ([]models.ReconScan, error
 		scans = append(scans, scan)
 	}
 
-	return scans, nil
+	return scans, total, nil
 }
 
 func (d *Database) UpdateScanStatus(id uuid.UUID, status string, progress int, errorMsg *string) error {
@@ -283,6 +347,72 @@ func (d *Database) UpdateScanStatus(id uuid.UUID, status string, progress int, e
 	return err
 }
 
+// SaveResolverHealth stores how the DoH/DoT resolvers of a scan answered
+func (d *Database) SaveResolverHealth(id uuid.UUID, health []securedns.Health) error {
+	data, err := json.Marshal(health)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`UPDATE recon_scans SET resolver_health = $1 WHERE id = $2`, data, id)
+	return err
+}
+
+// GetChildScans returns the scans a recon_full scan started, oldest first
+func (d *Database) GetChildScans(parentID uuid.UUID) ([]models.ReconScan, error) {
+	rows, err := d.db.Query(`
+		SELECT id, scan_type, status, progress, error_message FROM recon_scans WHERE parent_id = $1 ORDER BY created_at
+	`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []models.ReconScan
+	for rows.Next() {
+		var scan models.ReconScan
+		var errorMessage sql.NullString
+		if err := rows.Scan(&scan.ID, &scan.ScanType, &scan.Status, &scan.Progress, &errorMessage); err != nil {
+			return nil, err
+		}
+		if errorMessage.Valid {
+			scan.ErrorMessage = &errorMessage.String
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+// SetScreenshotScan links a recon_full scan to the web service's gowitness
+// scan of its alive hosts
+func (d *Database) SetScreenshotScan(id, webScanID uuid.UUID) error {
+	_, err := d.db.Exec(`UPDATE recon_scans SET screenshot_scan_id = $1 WHERE id = $2`, webScanID, id)
+	return err
+}
+
+// GetWebScan returns the status, progress and error of a web service scan,
+// read from its table when the web service shares the database
+func (d *Database) GetWebScan(id uuid.UUID) (string, int, *string, error) {
+	var shared bool
+	if err := d.db.QueryRow(`SELECT to_regclass('web_scans') IS NOT NULL`).Scan(&shared); err != nil {
+		return "", 0, nil, err
+	}
+	if !shared {
+		return "", 0, nil, sql.ErrNoRows
+	}
+	var status string
+	var progress sql.NullInt64
+	var errorMessage sql.NullString
+	err := d.db.QueryRow(`SELECT status, progress, error_message FROM web_scans WHERE id = $1`, id).Scan(&status, &progress, &errorMessage)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	var message *string
+	if errorMessage.Valid {
+		message = &errorMessage.String
+	}
+	return status, int(progress.Int64), message, nil
+}
+
 func (d *Database) DeleteScan(id uuid.UUID) error {
 	_, err := d.db.Exec(`DELETE FROM recon_scans WHERE id = $1`, id)
 	return err
@@ -291,18 +421,33 @@ func (d *Database) DeleteScan(id uuid.UUID) error {
 // Subdomain operations
 func (d *Database) SaveSubdomainResult(result *models.SubdomainResult) error {
 	_, err := d.db.Exec(`
-		INSERT INTO subdomain_results (id, scan_id, subdomain, ip_addresses, source, is_alive, http_status, https_status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO subdomain_results (id, scan_id, subdomain, ip_addresses, source, sources, is_alive, http_status, https_status,
+		                               title, content_length, web_server, wildcard, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14)
 		ON CONFLICT (scan_id, subdomain) DO NOTHING
-	`, result.ID, result.ScanID, result.Subdomain, pq.Array(result.IPAddresses), result.Source, result.IsAlive, result.HTTPStatus, result.HTTPSStatus, result.CreatedAt)
+	`, result.ID, result.ScanID, result.Subdomain, pq.Array(result.IPAddresses), result.Source, pq.Array(result.Sources), result.IsAlive, result.HTTPStatus, result.HTTPSStatus,
+		result.Title, result.ContentLength, result.WebServer, result.Wildcard, result.CreatedAt)
+	return err
+}
+
+// SaveSubdomainProbe stores what a subdomain of a scan answered over HTTP
+func (d *Database) SaveSubdomainProbe(scanID uuid.UUID, subdomain string, probe *models.HTTPProbe) error {
+	_, err := d.db.Exec(`
+		UPDATE subdomain_results SET http_status = $3, https_status = $4, title = $5, content_length = $6, web_server = $7
+		WHERE scan_id = $1 AND subdomain = $2
+	`, scanID, subdomain, probe.HTTPStatus, probe.HTTPSStatus, probe.Title, probe.ContentLength, probe.WebServer)
 	return err
 }
 
-func (d *Database) GetSubdomainResults(scanID uuid.UUID) ([]models.SubdomainResult, error) {
+// GetSubdomainResults lists the subdomains of a scan, wildcard matches last
+// or, with excludeWildcards, left out
+func (d *Database) GetSubdomainResults(scanID uuid.UUID, excludeWildcards bool) ([]models.SubdomainResult, error) {
 	rows, err := d.db.Query(`
-		SELECT id, scan_id, subdomain, ip_addresses, source, is_alive, http_status, https_status, created_at
-		FROM subdomain_results WHERE scan_id = $1 ORDER BY subdomain
-	`, scanID)
+		SELECT id, scan_id, subdomain, ip_addresses, source, COALESCE(sources, ARRAY[source]), is_alive, http_status, https_status,
+		       title, content_length, web_server, COALESCE(wildcard, ''), created_at
+		FROM subdomain_results WHERE scan_id = $1 AND (NOT $2 OR wildcard IS NULL)
+		ORDER BY wildcard IS NOT NULL, subdomain
+	`, scanID, excludeWildcards)
 	if err != nil {
 		return nil, err
 	}
@@ -312,7 +457,8 @@ func (d *Database) GetSubdomainResults(scanID uuid.UUID) ([]models.SubdomainResu
 	for rows.Next() {
 		var r models.SubdomainResult
 		var httpStatus, httpsStatus sql.NullInt32
-		err := rows.Scan(&r.ID, &r.ScanID, &r.Subdomain, pq.Array(&r.IPAddresses), &r.Source, &r.IsAlive, &httpStatus, &httpsStatus, &r.CreatedAt)
+		err := rows.Scan(&r.ID, &r.ScanID, &r.Subdomain, pq.Array(&r.IPAddresses), &r.Source, pq.Array(&r.Sources), &r.IsAlive, &httpStatus, &httpsStatus,
+			&r.Title, &r.ContentLength, &r.WebServer, &r.Wildcard, &r.CreatedAt)
 		if err != nil {
 			continue
 		}
@@ -465,6 +611,132 @@ func (d *Database) GetTechResults(scanID uuid.UUID) ([]models.TechResult, error)
 	return results, nil
 }
 
+// Code leak operations
+func (d *Database) SaveCodeLeakResult(result *models.CodeLeakResult) error {
+	secretsJSON, _ := json.Marshal(result.Secrets)
+
+	_, err := d.db.Exec(`
+		INSERT INTO code_leak_results (id, scan_id, platform, kind, repository, path, url, query, severity, secrets, snippet, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (scan_id, url) DO NOTHING
+	`, result.ID, result.ScanID, result.Platform, result.Kind, result.Repository, result.Path, result.URL,
+		result.Query, result.Severity, secretsJSON, result.Snippet, result.CreatedAt)
+	return err
+}
+
+func (d *Database) GetCodeLeakResults(scanID uuid.UUID) ([]models.CodeLeakResult, error) {
+	rows, err := d.db.Query(`
+		SELECT id, scan_id, platform, kind, COALESCE(repository, ''), COALESCE(path, ''), url, COALESCE(query, ''),
+			severity, secrets, COALESCE(snippet, ''), created_at
+		FROM code_leak_results WHERE scan_id = $1
+		ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 ELSE 3 END, repository, path
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.CodeLeakResult
+	for rows.Next() {
+		var r models.CodeLeakResult
+		var secretsJSON []byte
+
+		err := rows.Scan(&r.ID, &r.ScanID, &r.Platform, &r.Kind, &r.Repository, &r.Path, &r.URL, &r.Query,
+			&r.Severity, &secretsJSON, &r.Snippet, &r.CreatedAt)
+		if err != nil {
+			continue
+		}
+		json.Unmarshal(secretsJSON, &r.Secrets)
+		if r.Secrets == nil {
+			r.Secrets = []models.LeakedSecret{}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Email operations
+func (d *Database) SaveEmailResult(result *models.EmailResult) error {
+	breachesJSON, _ := json.Marshal(result.Breaches)
+
+	_, err := d.db.Exec(`
+		INSERT INTO email_results (id, scan_id, email, domain, sources, confidence, name, position, breach_checked, breaches, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (scan_id, email) DO NOTHING
+	`, result.ID, result.ScanID, result.Email, result.Domain, pq.Array(result.Sources), result.Confidence,
+		result.Name, result.Position, result.BreachCheck, breachesJSON, result.CreatedAt)
+	return err
+}
+
+func (d *Database) GetEmailResults(scanID uuid.UUID) ([]models.EmailResult, error) {
+	rows, err := d.db.Query(`
+		SELECT id, scan_id, email, domain, sources, confidence, name, position, breach_checked, breaches, created_at
+		FROM email_results WHERE scan_id = $1
+		ORDER BY jsonb_array_length(COALESCE(breaches, '[]'::jsonb)) DESC, email
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.EmailResult
+	for rows.Next() {
+		var r models.EmailResult
+		var confidence sql.NullInt32
+		var name, position sql.NullString
+		var breachesJSON []byte
+
+		err := rows.Scan(&r.ID, &r.ScanID, &r.Email, &r.Domain, pq.Array(&r.Sources), &confidence, &name, &position,
+			&r.BreachCheck, &breachesJSON, &r.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if confidence.Valid {
+			value := int(confidence.Int32)
+			r.Confidence = &value
+		}
+		if name.Valid {
+			r.Name = &name.String
+		}
+		if position.Valid {
+			r.Position = &position.String
+		}
+		json.Unmarshal(breachesJSON, &r.Breaches)
+		if r.Breaches == nil {
+			r.Breaches = []models.EmailBreach{}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// GetWhoisEmails returns the contact emails of earlier WHOIS lookups of a domain
+func (d *Database) GetWhoisEmails(domain string) ([]string, error) {
+	// Contact columns are admin/tech here and admin_contact/tech_contact in init.sql
+	rows, err := d.db.Query(`
+		SELECT DISTINCT email FROM (
+			SELECT jsonb_array_elements_text(jsonb_build_array(
+				w->'registrant'->>'email',
+				COALESCE(w->'admin', w->'admin_contact')->>'email',
+				COALESCE(w->'tech', w->'tech_contact')->>'email')) AS email
+			FROM (SELECT to_jsonb(whois_results.*) AS w FROM whois_results WHERE domain = $1) r
+		) e WHERE email IS NOT NULL AND email <> ''
+	`, domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err == nil {
+			emails = append(emails, email)
+		}
+	}
+	return emails, nil
+}
+
 // Log operations
 func (d *Database) AddLog(scanID uuid.UUID, level, message string) error {
 	_, err := d.db.Exec(`
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 // ReconScan represents a reconnaissance scan
@@ -11,7 +13,7 @@ type ReconScan struct {
 	ID           uuid.UUID              `json:"id"`
 	Name         string                 `json:"name"`
 	Target       string                 `json:"target"`
-	ScanType     string                 `json:"scan_type"` // subdomain, whois, dns, tech
+	ScanType     string                 `json:"scan_type"` // subdomain, whois, dns, tech, code_leaks, emails, recon_full
 	Status       string                 `json:"status"`    // pending, running, completed, failed, cancelled
 	Progress     int                    `json:"progress"`
 	CreatedAt    time.Time              `json:"created_at"`
@@ -19,6 +21,29 @@ type ReconScan struct {
 	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
 	ErrorMessage *string                `json:"error_message,omitempty"`
 	Options      map[string]interface{} `json:"options,omitempty"`
+	Origin       string                 `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID    string                 `json:"project_id"`
+
+	// ResolverHealth is how each DoH/DoT resolver of the scan answered
+	ResolverHealth []securedns.Health `json:"resolver_health,omitempty"`
+
+	// ParentID is the recon_full scan that started this one as a step
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+	// ScreenshotScanID is the web service's gowitness scan of the alive
+	// hosts of a recon_full scan
+	ScreenshotScanID *uuid.UUID `json:"screenshot_scan_id,omitempty"`
+}
+
+// PipelineStep is a scan a recon_full scan started: a recon scan, or the
+// web service's screenshot scan
+type PipelineStep struct {
+	Step         string    `json:"step"`    // subdomains, dns, tech, screenshots
+	Service      string    `json:"service"` // recon or web
+	ScanType     string    `json:"scan_type"`
+	ID           uuid.UUID `json:"id"`
+	Status       string    `json:"status"`
+	Progress     int       `json:"progress"`
+	ErrorMessage *string   `json:"error_message,omitempty"`
 }
 
 // SubdomainResult represents a discovered subdomain
@@ -27,13 +52,24 @@ type SubdomainResult struct {
 	ScanID      uuid.UUID  `json:"scan_id"`
 	Subdomain   string     `json:"subdomain"`
 	IPAddresses []string   `json:"ip_addresses,omitempty"`
-	Source      string     `json:"source"` // subfinder, amass, etc.
+	Source      string     `json:"source"`            // first source that found it: subfinder, crtsh, certspotter, amass
+	Sources     []string   `json:"sources,omitempty"` // every source that found it
 	IsAlive     bool       `json:"is_alive"`
-	HTTPStatus  *int       `json:"http_status,omitempty"`
-	HTTPSStatus *int       `json:"https_status,omitempty"`
+	HTTPProbe                // what answered over HTTP/HTTPS, when probed
+	Wildcard    string     `json:"wildcard,omitempty"` // e.g. *.example.com: only resolves through that wildcard
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
+// HTTPProbe is what a subdomain answered when probed with httpx. Title,
+// length and server come from HTTPS when both schemes answer.
+type HTTPProbe struct {
+	HTTPStatus    *int    `json:"http_status,omitempty"`
+	HTTPSStatus   *int    `json:"https_status,omitempty"`
+	Title         *string `json:"title,omitempty"`
+	ContentLength *int    `json:"content_length,omitempty"`
+	WebServer     *string `json:"web_server,omitempty"`
+}
+
 // WhoisResult represents WHOIS lookup results
 type WhoisResult struct {
 	ID              uuid.UUID  `json:"id"`
@@ -117,19 +153,63 @@ type Technology struct {
 	Confidence int      `json:"confidence"`
 }
 
-// ReconLog represents a log entry for a recon scan
-type ReconLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
+// CodeLeakResult is a mention of the target in public code, a commit, a gist
+// or a snippet, with any credentials found next to it (masked)
+type CodeLeakResult struct {
+	ID         uuid.UUID      `json:"id"`
+	ScanID     uuid.UUID      `json:"scan_id"`
+	Platform   string         `json:"platform"` // github, gitlab
+	Kind       string         `json:"kind"`     // code, commit, gist, snippet
+	Repository string         `json:"repository,omitempty"`
+	Path       string         `json:"path,omitempty"`
+	URL        string         `json:"url"`
+	Query      string         `json:"query"`
+	Severity   string         `json:"severity"` // critical, high, medium, info
+	Secrets    []LeakedSecret `json:"secrets"`
+	Snippet    string         `json:"snippet,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
 }
 
+// LeakedSecret is a credential pattern matched in a code leak
+type LeakedSecret struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Match    string `json:"match"` // masked
+}
+
+// EmailResult is an email address found for a domain and its exposure in
+// known data breaches
+type EmailResult struct {
+	ID          uuid.UUID     `json:"id"`
+	ScanID      uuid.UUID     `json:"scan_id"`
+	Email       string        `json:"email"`
+	Domain      string        `json:"domain"`
+	Sources     []string      `json:"sources"` // hunter, scrape, whois
+	Confidence  *int          `json:"confidence,omitempty"`
+	Name        *string       `json:"name,omitempty"`
+	Position    *string       `json:"position,omitempty"`
+	BreachCheck bool          `json:"breach_checked"`
+	Breaches    []EmailBreach `json:"breaches"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// EmailBreach is a HaveIBeenPwned breach an address appears in
+type EmailBreach struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Domain      string   `json:"domain,omitempty"`
+	BreachDate  string   `json:"breach_date"`
+	DataClasses []string `json:"data_classes"`
+}
+
+// ReconLog represents a log entry for a recon scan (shared by all services)
+type ReconLog = shared.ScanLog
+
 // Request structs
 type CreateReconRequest struct {
 	Name     string                 `json:"name"`
 	Target   string                 `json:"target"`
 	ScanType string                 `json:"scan_type"`
 	Options  map[string]interface{} `json:"options,omitempty"`
+	Simulate bool                   `json:"simulate,omitempty"` // synthetic results, no traffic
 }
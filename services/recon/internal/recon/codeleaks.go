@@ -0,0 +1,524 @@
+package recon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/recon-service/internal/database"
+	"github.com/security-scanner/recon-service/internal/models"
+)
+
+// dorkTerms are combined with each target domain; the empty term finds any mention
+var dorkTerms = []string{"", "password", "secret", "api_key", "token", `"PRIVATE KEY"`}
+
+// githubCodeSearchDelay keeps code search under GitHub's 10 requests per minute
+const githubCodeSearchDelay = 7 * time.Second
+
+// maxGistFiles caps the gist files downloaded per scan
+const maxGistFiles = 200
+
+// CodeLeakScanner searches GitHub and GitLab for an organization's domains in
+// code, commits, gists and snippets, and flags credentials found next to them
+type CodeLeakScanner struct {
+	db          *database.Database
+	githubToken string
+	gitlabURL   string
+	gitlabToken string
+	client      *http.Client
+}
+
+func NewCodeLeakScanner(db *database.Database, githubToken, gitlabURL, gitlabToken string) *CodeLeakScanner {
+	return &CodeLeakScanner{
+		db:          db,
+		githubToken: githubToken,
+		gitlabURL:   strings.TrimRight(gitlabURL, "/"),
+		gitlabToken: gitlabToken,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// codeLeakOptions are read from the scan options
+type codeLeakOptions struct {
+	platforms  map[string]bool
+	org        string // GitHub organization / GitLab group to restrict the search to
+	keywords   []string
+	maxResults int
+}
+
+func (s *CodeLeakScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
+	s.db.UpdateScanStatus(scan.ID, "running", 0, nil)
+	s.db.AddLog(scan.ID, "info", "Starting code leak search for "+scan.Target)
+
+	domains := strings.FieldsFunc(scan.Target, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	})
+	if len(domains) == 0 {
+		errMsg := "No target domains provided"
+		s.db.UpdateScanStatus(scan.ID, "failed", 0, &errMsg)
+		return nil
+	}
+
+	opts := s.parseOptions(scan.Options)
+	if len(opts.platforms) == 0 {
+		errMsg := "GITHUB_TOKEN or GITLAB_TOKEN is required for code leak searches"
+		s.db.UpdateScanStatus(scan.ID, "failed", 0, &errMsg)
+		s.db.AddLog(scan.ID, "error", errMsg)
+		return nil
+	}
+
+	terms := append(append([]string{}, dorkTerms...), opts.keywords...)
+	found := map[string]bool{}
+	leaks := 0
+	save := func(result *models.CodeLeakResult) {
+		if found[result.URL] {
+			return
+		}
+		found[result.URL] = true
+		result.ID = uuid.New()
+		result.ScanID = scan.ID
+		result.CreatedAt = time.Now()
+		if err := s.db.SaveCodeLeakResult(result); err != nil {
+			s.db.AddLog(scan.ID, "warning", "Failed to save result: "+err.Error())
+			return
+		}
+		if result.Severity != "info" {
+			leaks++
+			s.db.AddLog(scan.ID, "warning", fmt.Sprintf("Possible %s leak: %s", result.Secrets[0].Type, result.URL))
+		}
+	}
+
+	if opts.platforms["github"] {
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("Searching GitHub code and commits (%d queries per domain)", len(terms)))
+		for i, domain := range domains {
+			for j, term := range terms {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				query := dorkQuery(domain, term, opts.org)
+				results, err := s.githubCode(ctx, query, opts.maxResults)
+				if err != nil {
+					s.db.AddLog(scan.ID, "warning", fmt.Sprintf("GitHub code search %q failed: %v", query, err))
+				}
+				for _, r := range results {
+					save(r)
+				}
+				s.db.UpdateScanStatus(scan.ID, "running", 5+50*(i*len(terms)+j+1)/(len(domains)*len(terms)), nil)
+				sleepContext(ctx, githubCodeSearchDelay)
+			}
+
+			query := dorkQuery(domain, "", opts.org)
+			results, err := s.githubCommits(ctx, query, opts.maxResults)
+			if err != nil {
+				s.db.AddLog(scan.ID, "warning", fmt.Sprintf("GitHub commit search %q failed: %v", query, err))
+			}
+			for _, r := range results {
+				save(r)
+			}
+		}
+
+		if opts.org != "" {
+			s.db.AddLog(scan.ID, "info", "Searching public gists of "+opts.org+" members")
+			results, err := s.githubGists(ctx, opts.org, domains)
+			if err != nil {
+				s.db.AddLog(scan.ID, "warning", "GitHub gist search failed: "+err.Error())
+			}
+			for _, r := range results {
+				save(r)
+			}
+		}
+	}
+	s.db.UpdateScanStatus(scan.ID, "running", 65, nil)
+
+	if opts.platforms["gitlab"] {
+		s.db.AddLog(scan.ID, "info", "Searching GitLab code, commits and snippets ("+s.gitlabURL+")")
+		for _, domain := range domains {
+			for _, term := range terms {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				query := strings.TrimSpace(domain + " " + strings.Trim(term, `"`))
+				results, err := s.gitlabSearch(ctx, query, opts.org, domain, opts.maxResults)
+				if err != nil {
+					s.db.AddLog(scan.ID, "warning", fmt.Sprintf("GitLab search %q failed: %v", query, err))
+				}
+				for _, r := range results {
+					save(r)
+				}
+			}
+		}
+	}
+
+	s.db.UpdateScanStatus(scan.ID, "completed", 100, nil)
+	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Code leak search completed: %d mentions, %d possible credential leaks", len(found), leaks))
+	return nil
+}
+
+func (s *CodeLeakScanner) parseOptions(options map[string]interface{}) codeLeakOptions {
+	opts := codeLeakOptions{platforms: map[string]bool{}, maxResults: 100}
+
+	requested := map[string]bool{}
+	if list, ok := options["platforms"].([]interface{}); ok {
+		for _, p := range list {
+			if name, ok := p.(string); ok {
+				requested[strings.ToLower(name)] = true
+			}
+		}
+	}
+	if s.githubToken != "" && (len(requested) == 0 || requested["github"]) {
+		opts.platforms["github"] = true
+	}
+	if s.gitlabToken != "" && (len(requested) == 0 || requested["gitlab"]) {
+		opts.platforms["gitlab"] = true
+	}
+
+	if org, ok := options["org"].(string); ok {
+		opts.org = strings.TrimSpace(org)
+	}
+	if list, ok := options["keywords"].([]interface{}); ok {
+		for _, k := range list {
+			if keyword, ok := k.(string); ok && strings.TrimSpace(keyword) != "" {
+				opts.keywords = append(opts.keywords, strings.TrimSpace(keyword))
+			}
+		}
+	}
+	// Search APIs return at most 100 results per page
+	if limit, ok := options["max_results"].(float64); ok && limit > 0 && limit <= 100 {
+		opts.maxResults = int(limit)
+	}
+	return opts
+}
+
+// dorkQuery builds a GitHub search query for a domain and a term, optionally
+// restricted to an organization
+func dorkQuery(domain, term, org string) string {
+	query := `"` + domain + `"`
+	if term != "" {
+		query += " " + term
+	}
+	if org != "" {
+		query += " org:" + org
+	}
+	return query
+}
+
+// newCodeLeak builds a result from the text around a match
+func newCodeLeak(platform, kind, repository, path, link, query, text string) *models.CodeLeakResult {
+	secrets, severity, masked := findSecrets(text)
+	if len(masked) > 1000 {
+		masked = masked[:1000]
+	}
+	return &models.CodeLeakResult{
+		Platform:   platform,
+		Kind:       kind,
+		Repository: repository,
+		Path:       path,
+		URL:        link,
+		Query:      query,
+		Severity:   severity,
+		Secrets:    secrets,
+		Snippet:    masked,
+	}
+}
+
+// GitHub
+
+type githubTextMatch struct {
+	Fragment string `json:"fragment"`
+}
+
+type githubRepository struct {
+	FullName string `json:"full_name"`
+}
+
+func (s *CodeLeakScanner) githubCode(ctx context.Context, query string, perPage int) ([]*models.CodeLeakResult, error) {
+	var resp struct {
+		Items []struct {
+			Path        string            `json:"path"`
+			HTMLURL     string            `json:"html_url"`
+			Repository  githubRepository  `json:"repository"`
+			TextMatches []githubTextMatch `json:"text_matches"`
+		} `json:"items"`
+	}
+	endpoint := "https://api.github.com/search/code?" + url.Values{"q": {query}, "per_page": {strconv.Itoa(perPage)}}.Encode()
+	if err := s.githubGet(ctx, endpoint, &resp); err != nil {
+		return nil, err
+	}
+
+	var results []*models.CodeLeakResult
+	for _, item := range resp.Items {
+		var fragments []string
+		for _, m := range item.TextMatches {
+			fragments = append(fragments, m.Fragment)
+		}
+		results = append(results, newCodeLeak("github", "code", item.Repository.FullName, item.Path,
+			item.HTMLURL, query, strings.Join(fragments, "\n...\n")))
+	}
+	return results, nil
+}
+
+func (s *CodeLeakScanner) githubCommits(ctx context.Context, query string, perPage int) ([]*models.CodeLeakResult, error) {
+	var resp struct {
+		Items []struct {
+			SHA     string `json:"sha"`
+			HTMLURL string `json:"html_url"`
+			Commit  struct {
+				Message string `json:"message"`
+			} `json:"commit"`
+			Repository githubRepository `json:"repository"`
+		} `json:"items"`
+	}
+	endpoint := "https://api.github.com/search/commits?" + url.Values{"q": {query}, "per_page": {strconv.Itoa(perPage)}}.Encode()
+	if err := s.githubGet(ctx, endpoint, &resp); err != nil {
+		return nil, err
+	}
+
+	var results []*models.CodeLeakResult
+	for _, item := range resp.Items {
+		results = append(results, newCodeLeak("github", "commit", item.Repository.FullName, item.SHA,
+			item.HTMLURL, query, item.Commit.Message))
+	}
+	return results, nil
+}
+
+// githubGists reads the public gists of the organization's public members,
+// since GitHub has no gist search API
+func (s *CodeLeakScanner) githubGists(ctx context.Context, org string, domains []string) ([]*models.CodeLeakResult, error) {
+	var members []struct {
+		Login string `json:"login"`
+	}
+	if err := s.githubGet(ctx, "https://api.github.com/orgs/"+url.PathEscape(org)+"/public_members?per_page=100", &members); err != nil {
+		return nil, err
+	}
+
+	var results []*models.CodeLeakResult
+	files := 0
+	for _, member := range members {
+		var gists []struct {
+			HTMLURL string `json:"html_url"`
+			Files   map[string]struct {
+				RawURL string `json:"raw_url"`
+				Size   int    `json:"size"`
+			} `json:"files"`
+		}
+		if err := s.githubGet(ctx, "https://api.github.com/users/"+url.PathEscape(member.Login)+"/gists?per_page=30", &gists); err != nil {
+			continue
+		}
+		for _, gist := range gists {
+			for name, file := range gist.Files {
+				if files >= maxGistFiles || ctx.Err() != nil {
+					return results, nil
+				}
+				if file.Size > 512*1024 {
+					continue
+				}
+				files++
+				content, err := s.fetch(ctx, file.RawURL, map[string]string{"Authorization": "Bearer " + s.githubToken})
+				if err != nil {
+					continue
+				}
+				for _, domain := range domains {
+					if text := excerpt(content, domain); text != "" {
+						results = append(results, newCodeLeak("github", "gist", member.Login, name,
+							gist.HTMLURL+"#file-"+strings.ReplaceAll(strings.ToLower(name), ".", "-"), "gist:"+member.Login, text))
+						break
+					}
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// githubGet calls the GitHub API, waiting once for the rate limit to reset
+func (s *CodeLeakScanner) githubGet(ctx context.Context, endpoint string, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.githubToken)
+		req.Header.Set("Accept", "application/vnd.github.text-match+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+		resp.Body.Close()
+
+		if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && attempt == 0 {
+			wait := time.Minute
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				if d := time.Until(time.Unix(reset, 0)) + time.Second; d > 0 && d < wait {
+					wait = d
+				}
+			}
+			sleepContext(ctx, wait)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GitHub API returned %d: %s", resp.StatusCode, truncate(string(body), 200))
+		}
+		return json.Unmarshal(body, out)
+	}
+}
+
+// GitLab
+
+func (s *CodeLeakScanner) gitlabSearch(ctx context.Context, query, group, domain string, perPage int) ([]*models.CodeLeakResult, error) {
+	base := s.gitlabURL + "/api/v4/search"
+	if group != "" {
+		base = s.gitlabURL + "/api/v4/groups/" + url.PathEscape(group) + "/search"
+	}
+	params := func(scope string) string {
+		return base + "?" + url.Values{"scope": {scope}, "search": {query}, "per_page": {strconv.Itoa(perPage)}}.Encode()
+	}
+
+	var results []*models.CodeLeakResult
+	projects := map[int]string{}
+
+	var blobs []struct {
+		Path      string `json:"path"`
+		Data      string `json:"data"`
+		Ref       string `json:"ref"`
+		Startline int    `json:"startline"`
+		ProjectID int    `json:"project_id"`
+	}
+	if err := s.gitlabGet(ctx, params("blobs"), &blobs); err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		project := s.gitlabProject(ctx, blob.ProjectID, projects)
+		link := fmt.Sprintf("%s/-/blob/%s/%s#L%d", project, blob.Ref, blob.Path, blob.Startline)
+		results = append(results, newCodeLeak("gitlab", "code", strings.TrimPrefix(project, s.gitlabURL+"/"),
+			blob.Path, link, query, blob.Data))
+	}
+
+	var commits []struct {
+		ID        string `json:"id"`
+		Message   string `json:"message"`
+		WebURL    string `json:"web_url"`
+		ProjectID int    `json:"project_id"`
+	}
+	if err := s.gitlabGet(ctx, params("commits"), &commits); err == nil {
+		for _, commit := range commits {
+			project := s.gitlabProject(ctx, commit.ProjectID, projects)
+			link := commit.WebURL
+			if link == "" {
+				link = project + "/-/commit/" + commit.ID
+			}
+			results = append(results, newCodeLeak("gitlab", "commit", strings.TrimPrefix(project, s.gitlabURL+"/"),
+				commit.ID, link, query, commit.Message))
+		}
+	}
+
+	// Snippet search is only available instance-wide
+	if group == "" {
+		var snippets []struct {
+			ID       int    `json:"id"`
+			FileName string `json:"file_name"`
+			WebURL   string `json:"web_url"`
+		}
+		if err := s.gitlabGet(ctx, params("snippet_titles"), &snippets); err == nil {
+			for _, snippet := range snippets {
+				content, err := s.fetch(ctx, fmt.Sprintf("%s/api/v4/snippets/%d/raw", s.gitlabURL, snippet.ID),
+					map[string]string{"PRIVATE-TOKEN": s.gitlabToken})
+				if err != nil {
+					continue
+				}
+				if text := excerpt(content, domain); text != "" {
+					results = append(results, newCodeLeak("gitlab", "snippet", "", snippet.FileName, snippet.WebURL, query, text))
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// gitlabProject returns the web URL of a project, caching lookups
+func (s *CodeLeakScanner) gitlabProject(ctx context.Context, id int, cache map[int]string) string {
+	if webURL, ok := cache[id]; ok {
+		return webURL
+	}
+	var project struct {
+		WebURL string `json:"web_url"`
+	}
+	webURL := fmt.Sprintf("%s/projects/%d", s.gitlabURL, id)
+	if err := s.gitlabGet(ctx, fmt.Sprintf("%s/api/v4/projects/%d", s.gitlabURL, id), &project); err == nil && project.WebURL != "" {
+		webURL = project.WebURL
+	}
+	cache[id] = webURL
+	return webURL
+}
+
+func (s *CodeLeakScanner) gitlabGet(ctx context.Context, endpoint string, out interface{}) error {
+	body, err := s.fetch(ctx, endpoint, map[string]string{"PRIVATE-TOKEN": s.gitlabToken})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(body), out)
+}
+
+// fetch downloads a URL as text
+func (s *CodeLeakScanner) fetch(ctx context.Context, endpoint string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("returned %d: %s", resp.StatusCode, truncate(string(body), 200))
+	}
+	return string(body), nil
+}
+
+// excerpt returns the lines around the first mention of domain, or "" when
+// the content does not mention it
+func excerpt(content, domain string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.Contains(strings.ToLower(line), strings.ToLower(domain)) {
+			start, end := i-5, i+6
+			if start < 0 {
+				start = 0
+			}
+			if end > len(lines) {
+				end = len(lines)
+			}
+			return strings.Join(lines[start:end], "\n")
+		}
+	}
+	return ""
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
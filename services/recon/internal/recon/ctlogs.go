@@ -0,0 +1,132 @@
+package recon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Certificate transparency sources, as recorded in subdomain_results
+const (
+	SourceCrtSh       = "crtsh"
+	SourceCertSpotter = "certspotter"
+)
+
+// ctMaxResponseBytes caps a CT log API response; crt.sh answers for big
+// domains run to tens of megabytes
+const ctMaxResponseBytes = 64 << 20
+
+// certSpotterMaxPages caps the pages of issuances fetched from Cert Spotter
+// (100 certificates each)
+const certSpotterMaxPages = 20
+
+// queryCrtSh returns the names of the certificates crt.sh logged for
+// domain and its subdomains
+func (s *SubdomainScanner) queryCrtSh(ctx context.Context, domain string) ([]string, error) {
+	endpoint := "https://crt.sh/?output=json&q=" + url.QueryEscape("%."+domain)
+	body, err := s.ctGet(ctx, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []struct {
+		CommonName string `json:"common_name"`
+		NameValue  string `json:"name_value"` // names of the certificate, one per line
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("invalid crt.sh response: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.CommonName)
+		names = append(names, strings.Split(entry.NameValue, "\n")...)
+	}
+	return ctSubdomains(domain, names), nil
+}
+
+// queryCertSpotter returns the DNS names of the certificates Cert Spotter
+// found in the CT logs for domain and its subdomains. Without a token the
+// API allows a few queries an hour.
+func (s *SubdomainScanner) queryCertSpotter(ctx context.Context, domain string) ([]string, error) {
+	var headers map[string]string
+	if s.certSpotterToken != "" {
+		headers = map[string]string{"Authorization": "Bearer " + s.certSpotterToken}
+	}
+
+	var names []string
+	after := ""
+	for page := 0; page < certSpotterMaxPages; page++ {
+		query := url.Values{"domain": {domain}, "include_subdomains": {"true"}, "expand": {"dns_names"}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		body, err := s.ctGet(ctx, "https://api.certspotter.com/v1/issuances?"+query.Encode(), headers)
+		if err != nil {
+			if len(names) > 0 {
+				break // keep the pages already fetched
+			}
+			return nil, err
+		}
+
+		var issuances []struct {
+			ID       string   `json:"id"`
+			DNSNames []string `json:"dns_names"`
+		}
+		if err := json.Unmarshal(body, &issuances); err != nil {
+			return nil, fmt.Errorf("invalid Cert Spotter response: %w", err)
+		}
+		for _, issuance := range issuances {
+			names = append(names, issuance.DNSNames...)
+		}
+		if len(issuances) == 0 {
+			break
+		}
+		after = issuances[len(issuances)-1].ID
+	}
+	return ctSubdomains(domain, names), nil
+}
+
+func (s *SubdomainScanner) ctGet(ctx context.Context, endpoint string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "security-scanner-recon")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP %d", req.URL.Host, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, ctMaxResponseBytes))
+}
+
+// ctSubdomains keeps the names under domain, without wildcard labels,
+// duplicates or the domain itself. Certificates also list e-mail addresses
+// and names of other domains.
+func ctSubdomains(domain string, names []string) []string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	seen := map[string]bool{}
+	var subdomains []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		name = strings.TrimPrefix(name, "*.")
+		if name == domain || !strings.HasSuffix(name, "."+domain) || strings.ContainsAny(name, "@* /") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		subdomains = append(subdomains, name)
+	}
+	return subdomains
+}
@@ -8,19 +8,28 @@ import (
 	"github.com/google/uuid"
 	"github.com/security-scanner/recon-service/internal/database"
 	"github.com/security-scanner/recon-service/internal/models"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type DNSScanner struct {
-	db *database.Database
+	db        *database.Database
+	resolvers []securedns.Endpoint
 }
 
 func NewDNSScanner(db *database.Database) *DNSScanner {
 	return &DNSScanner{db: db}
 }
 
+// SetResolvers sends the lookups of scans without resolvers of their own to
+// these DoH/DoT endpoints
+func (d *DNSScanner) SetResolvers(endpoints []securedns.Endpoint) {
+	d.resolvers = endpoints
+}
+
 func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	d.db.UpdateScanStatus(scan.ID, "running", 0, nil)
 	d.db.AddLog(scan.ID, "info", "Starting DNS records lookup for "+scan.Target)
+	resolver, secure := scanResolver(d.db, scan, d.resolvers)
 
 	result := &models.DNSResult{
 		ID:        uuid.New(),
@@ -32,7 +41,7 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// A Records
 	d.db.AddLog(scan.ID, "info", "Looking up A records...")
 	d.db.UpdateScanStatus(scan.ID, "running", 15, nil)
-	aRecords, err := net.LookupHost(scan.Target)
+	aRecords, err := resolver.LookupHost(ctx, scan.Target)
 	if err == nil {
 		for _, ip := range aRecords {
 			if net.ParseIP(ip).To4() != nil {
@@ -46,7 +55,7 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// CNAME Records
 	d.db.AddLog(scan.ID, "info", "Looking up CNAME records...")
 	d.db.UpdateScanStatus(scan.ID, "running", 30, nil)
-	cname, err := net.LookupCNAME(scan.Target)
+	cname, err := resolver.LookupCNAME(ctx, scan.Target)
 	if err == nil && cname != scan.Target+"." {
 		result.CNAME = append(result.CNAME, cname)
 	}
@@ -54,7 +63,7 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// MX Records
 	d.db.AddLog(scan.ID, "info", "Looking up MX records...")
 	d.db.UpdateScanStatus(scan.ID, "running", 45, nil)
-	mxRecords, err := net.LookupMX(scan.Target)
+	mxRecords, err := resolver.LookupMX(ctx, scan.Target)
 	if err == nil {
 		for _, mx := range mxRecords {
 			result.MX = append(result.MX, models.MXRecord{
@@ -67,7 +76,7 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// NS Records
 	d.db.AddLog(scan.ID, "info", "Looking up NS records...")
 	d.db.UpdateScanStatus(scan.ID, "running", 60, nil)
-	nsRecords, err := net.LookupNS(scan.Target)
+	nsRecords, err := resolver.LookupNS(ctx, scan.Target)
 	if err == nil {
 		for _, ns := range nsRecords {
 			result.NS = append(result.NS, ns.Host)
@@ -77,7 +86,7 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// TXT Records
 	d.db.AddLog(scan.ID, "info", "Looking up TXT records...")
 	d.db.UpdateScanStatus(scan.ID, "running", 75, nil)
-	txtRecords, err := net.LookupTXT(scan.Target)
+	txtRecords, err := resolver.LookupTXT(ctx, scan.Target)
 	if err == nil {
 		result.TXT = txtRecords
 	}
@@ -89,6 +98,8 @@ func (d *DNSScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	// We would need a DNS library like miekg/dns for full SOA support
 	// For now, we'll skip SOA or use external tool
 
+	reportResolvers(d.db, scan, secure)
+
 	// Save result
 	d.db.UpdateScanStatus(scan.ID, "running", 95, nil)
 	if err := d.db.SaveDNSResult(result); err != nil {
@@ -0,0 +1,383 @@
+package recon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/recon-service/internal/database"
+	"github.com/security-scanner/recon-service/internal/models"
+)
+
+// scrapePaths are the pages checked for addresses when scraping is consented
+var scrapePaths = []string{"/", "/contact", "/contact-us", "/about", "/about-us", "/team", "/imprint", "/impressum", "/legal"}
+
+var emailPattern = regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`)
+
+// EmailScanner harvests email addresses for a domain and checks them against
+// HaveIBeenPwned
+type EmailScanner struct {
+	db           *database.Database
+	hunterKey    string
+	hibpKey      string
+	hibpInterval time.Duration
+	client       *http.Client
+
+	// HIBP limits requests per API key, so concurrent scans share the pacing
+	mu       sync.Mutex
+	nextHIBP time.Time
+}
+
+func NewEmailScanner(db *database.Database, hunterKey, hibpKey string, hibpPerMinute int) *EmailScanner {
+	if hibpPerMinute <= 0 {
+		hibpPerMinute = 10
+	}
+	return &EmailScanner{
+		db:           db,
+		hunterKey:    hunterKey,
+		hibpKey:      hibpKey,
+		hibpInterval: time.Minute / time.Duration(hibpPerMinute),
+		client:       &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// harvestedEmail collects what the sources know about an address
+type harvestedEmail struct {
+	domain     string
+	sources    []string
+	confidence *int
+	name       *string
+	position   *string
+}
+
+func (e *EmailScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
+	e.db.UpdateScanStatus(scan.ID, "running", 0, nil)
+	e.db.AddLog(scan.ID, "info", "Starting email harvesting for "+scan.Target)
+
+	domains := strings.FieldsFunc(strings.ToLower(scan.Target), func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	})
+	if len(domains) == 0 {
+		errMsg := "No target domains provided"
+		e.db.UpdateScanStatus(scan.ID, "failed", 0, &errMsg)
+		return nil
+	}
+
+	sources := optionStrings(scan.Options, "sources")
+	if len(sources) == 0 {
+		sources = []string{"hunter", "whois", "scrape"}
+	}
+	consent, _ := scan.Options["consent"].(bool)
+	checkBreaches := true
+	if v, ok := scan.Options["check_breaches"].(bool); ok {
+		checkBreaches = v
+	}
+
+	emails := map[string]*harvestedEmail{}
+	add := func(email, domain, source string) *harvestedEmail {
+		email = strings.ToLower(strings.Trim(email, ".,;:"))
+		h, ok := emails[email]
+		if !ok {
+			h = &harvestedEmail{domain: domain}
+			emails[email] = h
+		}
+		for _, s := range h.sources {
+			if s == source {
+				return h
+			}
+		}
+		h.sources = append(h.sources, source)
+		return h
+	}
+
+	for i, domain := range domains {
+		for _, source := range sources {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			switch source {
+			case "hunter":
+				if e.hunterKey == "" {
+					e.db.AddLog(scan.ID, "warning", "HUNTER_API_KEY is not set, skipping Hunter.io")
+					continue
+				}
+				found, err := e.hunter(ctx, domain, add)
+				if err != nil {
+					e.db.AddLog(scan.ID, "warning", fmt.Sprintf("Hunter.io lookup for %s failed: %v", domain, err))
+					continue
+				}
+				e.db.AddLog(scan.ID, "info", fmt.Sprintf("Hunter.io found %d addresses for %s", found, domain))
+			case "whois":
+				found, _ := e.db.GetWhoisEmails(domain)
+				for _, email := range found {
+					if strings.HasSuffix(strings.ToLower(email), "@"+domain) {
+						add(email, domain, "whois")
+					}
+				}
+			case "scrape":
+				// Scraping the target's own website needs the operator's explicit consent
+				if !consent {
+					e.db.AddLog(scan.ID, "warning", "Website scraping skipped: set options.consent=true to confirm you are authorized to crawl "+domain)
+					continue
+				}
+				found := e.scrape(ctx, domain, add)
+				e.db.AddLog(scan.ID, "info", fmt.Sprintf("Scraping found %d addresses on %s", found, domain))
+			default:
+				e.db.AddLog(scan.ID, "warning", "Unknown email source: "+source)
+			}
+		}
+		e.db.UpdateScanStatus(scan.ID, "running", 5+35*(i+1)/len(domains), nil)
+	}
+
+	addresses := make([]string, 0, len(emails))
+	for email := range emails {
+		addresses = append(addresses, email)
+	}
+	sort.Strings(addresses)
+	e.db.AddLog(scan.ID, "info", fmt.Sprintf("Harvested %d unique addresses", len(addresses)))
+
+	if checkBreaches && e.hibpKey == "" && len(addresses) > 0 {
+		e.db.AddLog(scan.ID, "warning", "HIBP_API_KEY is not set, skipping breach checks")
+	}
+
+	exposed := 0
+	for i, email := range addresses {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		h := emails[email]
+		result := &models.EmailResult{
+			ID:         uuid.New(),
+			ScanID:     scan.ID,
+			Email:      email,
+			Domain:     h.domain,
+			Sources:    h.sources,
+			Confidence: h.confidence,
+			Name:       h.name,
+			Position:   h.position,
+			Breaches:   []models.EmailBreach{},
+			CreatedAt:  time.Now(),
+		}
+
+		if checkBreaches && e.hibpKey != "" {
+			breaches, err := e.breaches(ctx, email)
+			if err != nil {
+				e.db.AddLog(scan.ID, "warning", fmt.Sprintf("HaveIBeenPwned check for %s failed: %v", email, err))
+			} else {
+				result.BreachCheck = true
+				result.Breaches = breaches
+				if len(breaches) > 0 {
+					exposed++
+					e.db.AddLog(scan.ID, "info", fmt.Sprintf("%s appears in %d breaches", email, len(breaches)))
+				}
+			}
+		}
+
+		if err := e.db.SaveEmailResult(result); err != nil {
+			e.db.AddLog(scan.ID, "warning", "Failed to save result: "+err.Error())
+		}
+		e.db.UpdateScanStatus(scan.ID, "running", 40+55*(i+1)/len(addresses), nil)
+	}
+
+	e.db.UpdateScanStatus(scan.ID, "completed", 100, nil)
+	e.db.AddLog(scan.ID, "info", fmt.Sprintf("Email harvesting completed: %d addresses, %d exposed in breaches", len(addresses), exposed))
+	return nil
+}
+
+// hunter queries the Hunter.io domain search API
+func (e *EmailScanner) hunter(ctx context.Context, domain string, add func(email, domain, source string) *harvestedEmail) (int, error) {
+	var resp struct {
+		Data struct {
+			Emails []struct {
+				Value      string `json:"value"`
+				Confidence int    `json:"confidence"`
+				FirstName  string `json:"first_name"`
+				LastName   string `json:"last_name"`
+				Position   string `json:"position"`
+			} `json:"emails"`
+		} `json:"data"`
+	}
+	endpoint := "https://api.hunter.io/v2/domain-search?" + url.Values{
+		"domain": {domain}, "api_key": {e.hunterKey}, "limit": {"100"},
+	}.Encode()
+	body, status, err := e.get(ctx, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("Hunter.io returned %d", status)
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, err
+	}
+
+	for _, found := range resp.Data.Emails {
+		h := add(found.Value, domain, "hunter")
+		confidence := found.Confidence
+		h.confidence = &confidence
+		h.name = strPtr(strings.TrimSpace(found.FirstName + " " + found.LastName))
+		h.position = strPtr(found.Position)
+	}
+	return len(resp.Data.Emails), nil
+}
+
+// scrape extracts addresses on the domain from a few well-known pages
+func (e *EmailScanner) scrape(ctx context.Context, domain string, add func(email, domain, source string) *harvestedEmail) int {
+	found := map[string]bool{}
+	for _, path := range scrapePaths {
+		body, status, err := e.get(ctx, "https://"+domain+path, map[string]string{"User-Agent": "Mozilla/5.0 (compatible; SecurityScanner)"})
+		if err != nil || status != http.StatusOK {
+			continue
+		}
+		for _, email := range emailPattern.FindAllString(string(body), -1) {
+			lower := strings.ToLower(email)
+			if strings.HasSuffix(lower, "@"+domain) || strings.HasSuffix(lower, "."+domain) {
+				add(lower, domain, "scrape")
+				found[lower] = true
+			}
+		}
+	}
+	return len(found)
+}
+
+// breaches returns the HaveIBeenPwned breaches an address appears in,
+// pacing requests to the API key's rate limit
+func (e *EmailScanner) breaches(ctx context.Context, email string) ([]models.EmailBreach, error) {
+	headers := map[string]string{"hibp-api-key": e.hibpKey, "User-Agent": "security-scanner-recon"}
+	endpoint := "https://haveibeenpwned.com/api/v3/breachedaccount/" + url.PathEscape(email) + "?truncateResponse=false"
+
+	for attempt := 0; attempt < 3; attempt++ {
+		sleepContext(ctx, e.reserveHIBPSlot())
+
+		body, status, err := e.get(ctx, endpoint, headers)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case http.StatusNotFound:
+			return []models.EmailBreach{}, nil
+		case http.StatusTooManyRequests:
+			sleepContext(ctx, 2*time.Second)
+			continue
+		case http.StatusOK:
+		default:
+			return nil, fmt.Errorf("HaveIBeenPwned returned %d", status)
+		}
+
+		var raw []struct {
+			Name        string   `json:"Name"`
+			Title       string   `json:"Title"`
+			Domain      string   `json:"Domain"`
+			BreachDate  string   `json:"BreachDate"`
+			DataClasses []string `json:"DataClasses"`
+		}
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, err
+		}
+		breaches := make([]models.EmailBreach, 0, len(raw))
+		for _, b := range raw {
+			breaches = append(breaches, models.EmailBreach{
+				Name: b.Name, Title: b.Title, Domain: b.Domain, BreachDate: b.BreachDate, DataClasses: b.DataClasses,
+			})
+		}
+		return breaches, nil
+	}
+	return nil, fmt.Errorf("rate limited by HaveIBeenPwned")
+}
+
+// reserveHIBPSlot returns how long to wait before the next HIBP request
+func (e *EmailScanner) reserveHIBPSlot() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if e.nextHIBP.Before(now) {
+		e.nextHIBP = now
+	}
+	wait := e.nextHIBP.Sub(now)
+	e.nextHIBP = e.nextHIBP.Add(e.hibpInterval)
+	return wait
+}
+
+func (e *EmailScanner) get(ctx context.Context, endpoint string, headers map[string]string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// HIBP sends the wait in seconds
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			sleepContext(ctx, time.Duration(seconds)*time.Second)
+		}
+	}
+	return body, resp.StatusCode, err
+}
+
+// EmailDomainSummary reports the breach exposure of one domain
+type EmailDomainSummary struct {
+	Domain   string         `json:"domain"`
+	Emails   int            `json:"emails"`
+	Exposed  int            `json:"exposed"`
+	Severity string         `json:"severity"`
+	Breaches map[string]int `json:"breaches"` // breach name -> exposed addresses
+}
+
+// SummarizeEmails groups email results per domain as informational findings
+func SummarizeEmails(results []models.EmailResult) []EmailDomainSummary {
+	byDomain := map[string]*EmailDomainSummary{}
+	var order []string
+	for _, r := range results {
+		s, ok := byDomain[r.Domain]
+		if !ok {
+			s = &EmailDomainSummary{Domain: r.Domain, Severity: "info", Breaches: map[string]int{}}
+			byDomain[r.Domain] = s
+			order = append(order, r.Domain)
+		}
+		s.Emails++
+		if len(r.Breaches) > 0 {
+			s.Exposed++
+		}
+		for _, b := range r.Breaches {
+			s.Breaches[b.Name]++
+		}
+	}
+
+	sort.Strings(order)
+	summaries := make([]EmailDomainSummary, 0, len(order))
+	for _, domain := range order {
+		summaries = append(summaries, *byDomain[domain])
+	}
+	return summaries
+}
+
+// optionStrings reads a list of strings from the scan options
+func optionStrings(options map[string]interface{}, key string) []string {
+	var values []string
+	if list, ok := options[key].([]interface{}); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				values = append(values, strings.ToLower(strings.TrimSpace(s)))
+			}
+		}
+	}
+	return values
+}
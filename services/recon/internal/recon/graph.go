@@ -0,0 +1,379 @@
+package recon
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/recon-service/internal/database"
+	"github.com/security-scanner/recon-service/internal/models"
+)
+
+// GraphNode represents an asset in the recon graph
+type GraphNode struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"` // domain, subdomain, ip, asn, technology, url, nameserver, mailserver, registrar
+	Label      string                 `json:"label"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// GraphEdge represents a relationship between two assets
+type GraphEdge struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"` // has_subdomain, resolves_to, belongs_to, runs, serves, uses_nameserver, uses_mailserver, alias_of, registered_with
+}
+
+// Graph is a nodes/edges representation of recon results
+type Graph struct {
+	ScanID string      `json:"scan_id"`
+	Target string      `json:"target"`
+	Nodes  []GraphNode `json:"nodes"`
+	Edges  []GraphEdge `json:"edges"`
+}
+
+type GraphBuilder struct {
+	db       *database.Database
+	resolver *net.Resolver
+}
+
+func NewGraphBuilder(db *database.Database) *GraphBuilder {
+	return &GraphBuilder{
+		db:       db,
+		resolver: net.DefaultResolver,
+	}
+}
+
+// graphState collects nodes and edges without duplicates
+type graphState struct {
+	nodes map[string]*GraphNode
+	edges map[string]GraphEdge
+}
+
+func (g *graphState) addNode(nodeType, label string, props map[string]interface{}) string {
+	label = strings.TrimSpace(label)
+	if nodeType != "url" {
+		label = strings.TrimSuffix(strings.ToLower(label), ".")
+	}
+	id := nodeType + ":" + label
+	if node, ok := g.nodes[id]; ok {
+		for k, v := range props {
+			node.Properties[k] = v
+		}
+		return id
+	}
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	g.nodes[id] = &GraphNode{ID: id, Type: nodeType, Label: label, Properties: props}
+	return id
+}
+
+func (g *graphState) addEdge(source, target, edgeType string) {
+	if source == target {
+		return
+	}
+	id := fmt.Sprintf("%s|%s|%s", source, edgeType, target)
+	g.edges[id] = GraphEdge{ID: id, Source: source, Target: target, Type: edgeType}
+}
+
+// Build creates the graph for a recon scan. When resolveASN is set, IP nodes are
+// enriched with their origin ASN using the Team Cymru DNS service.
+func (b *GraphBuilder) Build(ctx context.Context, scan *models.ReconScan, resolveASN bool) (*Graph, error) {
+	g := &graphState{
+		nodes: map[string]*GraphNode{},
+		edges: map[string]GraphEdge{},
+	}
+
+	if err := b.addScan(g, scan); err != nil {
+		return nil, err
+	}
+
+	if resolveASN {
+		b.addASNNodes(ctx, g)
+	}
+
+	graph := &Graph{
+		ScanID: scan.ID.String(),
+		Target: scan.Target,
+		Nodes:  []GraphNode{},
+		Edges:  []GraphEdge{},
+	}
+	for _, node := range g.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	for _, edge := range g.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool { return graph.Edges[i].ID < graph.Edges[j].ID })
+
+	return graph, nil
+}
+
+// addScan adds the results of a scan to the graph
+func (b *GraphBuilder) addScan(g *graphState, scan *models.ReconScan) error {
+	switch scan.ScanType {
+	case "recon_full":
+		// The union of the graphs of its steps
+		children, err := b.db.GetChildScans(scan.ID)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			step, err := b.db.GetScan(child.ID)
+			if err != nil {
+				return err
+			}
+			if err := b.addScan(g, step); err != nil {
+				return err
+			}
+		}
+
+	case "subdomain":
+		// Wildcard matches would all hang off the wildcard's addresses
+		subdomains, err := b.db.GetSubdomainResults(scan.ID, true)
+		if err != nil {
+			return err
+		}
+		root := g.addNode("domain", scan.Target, nil)
+		for _, sub := range subdomains {
+			subID := g.addNode("subdomain", sub.Subdomain, map[string]interface{}{
+				"source":   sub.Source,
+				"sources":  sub.Sources,
+				"is_alive": sub.IsAlive,
+			})
+			g.addEdge(root, subID, "has_subdomain")
+			for _, ip := range sub.IPAddresses {
+				g.addEdge(subID, g.addNode("ip", ip, nil), "resolves_to")
+			}
+		}
+
+	case "dns":
+		dns, err := b.db.GetDNSResult(scan.ID)
+		if err == sql.ErrNoRows {
+			g.addNode("domain", scan.Target, nil)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		root := g.addNode("domain", dns.Domain, nil)
+		for _, ip := range append(append([]string{}, dns.A...), dns.AAAA...) {
+			g.addEdge(root, g.addNode("ip", ip, nil), "resolves_to")
+		}
+		for _, cname := range dns.CNAME {
+			g.addEdge(root, g.addNode("domain", cname, nil), "alias_of")
+		}
+		for _, ns := range dns.NS {
+			g.addEdge(root, g.addNode("nameserver", ns, nil), "uses_nameserver")
+		}
+		for _, mx := range dns.MX {
+			g.addEdge(root, g.addNode("mailserver", mx.Host, map[string]interface{}{"priority": mx.Priority}), "uses_mailserver")
+		}
+
+	case "whois":
+		whois, err := b.db.GetWhoisResult(scan.ID)
+		if err == sql.ErrNoRows {
+			g.addNode("domain", scan.Target, nil)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		root := g.addNode("domain", whois.Domain, nil)
+		if whois.Registrar != nil && *whois.Registrar != "" {
+			g.addEdge(root, g.addNode("registrar", *whois.Registrar, nil), "registered_with")
+		}
+		for _, ns := range whois.NameServers {
+			g.addEdge(root, g.addNode("nameserver", ns, nil), "uses_nameserver")
+		}
+
+	case "tech":
+		techResults, err := b.db.GetTechResults(scan.ID)
+		if err != nil {
+			return err
+		}
+		for _, tech := range techResults {
+			urlID := g.addNode("url", tech.URL, map[string]interface{}{"status_code": tech.StatusCode})
+			if parsed, err := url.Parse(tech.URL); err == nil && parsed.Hostname() != "" {
+				hostType := "subdomain"
+				if net.ParseIP(parsed.Hostname()) != nil {
+					hostType = "ip"
+				}
+				g.addEdge(g.addNode(hostType, parsed.Hostname(), nil), urlID, "serves")
+			}
+			for _, t := range tech.Technologies {
+				props := map[string]interface{}{"category": t.Category}
+				if t.Version != nil {
+					props["version"] = *t.Version
+				}
+				g.addEdge(urlID, g.addNode("technology", t.Name, props), "runs")
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported scan type: %s", scan.ScanType)
+	}
+	return nil
+}
+
+// addASNNodes links every IP node to its origin ASN
+func (b *GraphBuilder) addASNNodes(ctx context.Context, g *graphState) {
+	var ips []string
+	for _, node := range g.nodes {
+		if node.Type == "ip" {
+			ips = append(ips, node.Label)
+		}
+	}
+
+	for _, ip := range ips {
+		lookupCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		asn, prefix, country, err := b.lookupASN(lookupCtx, ip)
+		cancel()
+		if err != nil || asn == "" {
+			continue
+		}
+		asnID := g.addNode("asn", "AS"+asn, map[string]interface{}{"country": country})
+		ipID := g.addNode("ip", ip, map[string]interface{}{"prefix": prefix})
+		g.addEdge(ipID, asnID, "belongs_to")
+	}
+}
+
+// lookupASN queries origin.asn.cymru.com, which answers TXT records like
+// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28"
+func (b *GraphBuilder) lookupASN(ctx context.Context, ip string) (asn, prefix, country string, err error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", "", "", fmt.Errorf("invalid IP: %s", ip)
+	}
+
+	var query string
+	if v4 := parsed.To4(); v4 != nil {
+		query = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", v4[3], v4[2], v4[1], v4[0])
+	} else {
+		hex := fmt.Sprintf("%x", []byte(parsed.To16()))
+		nibbles := make([]string, 0, len(hex))
+		for i := len(hex) - 1; i >= 0; i-- {
+			nibbles = append(nibbles, string(hex[i]))
+		}
+		query = strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+	}
+
+	records, err := b.resolver.LookupTXT(ctx, query)
+	if err != nil || len(records) == 0 {
+		return "", "", "", err
+	}
+
+	fields := strings.Split(records[0], "|")
+	if len(fields) < 3 {
+		return "", "", "", fmt.Errorf("unexpected ASN response: %s", records[0])
+	}
+	// Multiple origin ASNs are space separated, keep the first
+	origins := strings.Fields(fields[0])
+	if len(origins) == 0 {
+		return "", "", "", fmt.Errorf("unexpected ASN response: %s", records[0])
+	}
+	return origins[0], strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2]), nil
+}
+
+// GraphML structures
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ToGraphML renders the graph as GraphML for Gephi, yEd, Maltego imports, etc.
+func (g *Graph) ToGraphML() ([]byte, error) {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "properties", For: "node", AttrName: "properties", AttrType: "string"},
+			{ID: "relation", For: "edge", AttrName: "relation", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: g.ScanID, EdgeDefault: "directed"},
+	}
+
+	for _, node := range g.Nodes {
+		data := []graphMLData{
+			{Key: "label", Value: node.Label},
+			{Key: "type", Value: node.Type},
+		}
+		if len(node.Properties) > 0 {
+			keys := make([]string, 0, len(node.Properties))
+			for k := range node.Properties {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			parts := make([]string, 0, len(keys))
+			for _, k := range keys {
+				parts = append(parts, fmt.Sprintf("%s=%v", k, node.Properties[k]))
+			}
+			data = append(data, graphMLData{Key: "properties", Value: strings.Join(parts, "; ")})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: node.ID, Data: data})
+	}
+
+	for _, edge := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     edge.ID,
+			Source: edge.Source,
+			Target: edge.Target,
+			Data:   []graphMLData{{Key: "relation", Value: edge.Type}},
+		})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.Write(out)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,332 @@
+package recon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/recon-service/internal/database"
+	"github.com/security-scanner/recon-service/internal/models"
+	"github.com/security-scanner/shared/pkg/client"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/project"
+)
+
+// PipelineTimeout bounds a recon_full scan, all of its steps included
+const PipelineTimeout = 3 * time.Hour
+
+// PipelineDefaultMaxHosts is how many alive hosts a recon_full scan detects
+// technologies on and screenshots, unless its max_hosts option says otherwise
+const PipelineDefaultMaxHosts = 100
+
+// pipelinePollInterval is how often a recon_full scan follows the progress
+// of the step it is running
+const pipelinePollInterval = 3 * time.Second
+
+// errPipelineCancelled stops a recon_full scan that was cancelled
+var errPipelineCancelled = errors.New("scan cancelled")
+
+// Pipeline runs recon_full scans: subdomain enumeration, DNS records and
+// technology detection of the alive hosts as recon scans of their own, then
+// screenshots of those hosts with a gowitness scan of the web service
+type Pipeline struct {
+	db        *database.Database
+	subdomain *SubdomainScanner
+	dns       *DNSScanner
+	tech      *TechScanner
+	simulator *Simulator
+	webURL    string
+	sign      client.Signer
+}
+
+func NewPipeline(db *database.Database, subdomain *SubdomainScanner, dns *DNSScanner, tech *TechScanner, simulator *Simulator) *Pipeline {
+	return &Pipeline{
+		db:        db,
+		subdomain: subdomain,
+		dns:       dns,
+		tech:      tech,
+		simulator: simulator,
+	}
+}
+
+// SetWebService sends the screenshots of recon_full scans to the web
+// service at baseURL, signing the requests when sign is set. Without it
+// the screenshot step is skipped.
+func (p *Pipeline) SetWebService(baseURL string, sign client.Signer) {
+	p.webURL = baseURL
+	p.sign = sign
+}
+
+// PipelineOptions are the recon_full options of a scan: "screenshots"
+// (default true) and "max_hosts"
+func PipelineOptions(options map[string]interface{}) (screenshots bool, maxHosts int, err error) {
+	screenshots, maxHosts = true, PipelineDefaultMaxHosts
+	if value, ok := options["screenshots"]; ok {
+		if screenshots, ok = value.(bool); !ok {
+			return false, 0, errors.New("screenshots must be true or false")
+		}
+	}
+	if value, ok := options["max_hosts"]; ok {
+		n, ok := value.(float64)
+		if !ok || n != float64(int(n)) || n < 1 || n > 1000 {
+			return false, 0, errors.New("max_hosts must be between 1 and 1000")
+		}
+		maxHosts = int(n)
+	}
+	return screenshots, maxHosts, nil
+}
+
+// Scan runs the steps of a recon_full scan, each taking its share of the
+// scan's progress. Enumeration failing fails the scan; the later steps
+// failing only leaves their results out.
+func (p *Pipeline) Scan(ctx context.Context, scan *models.ReconScan) error {
+	p.db.UpdateScanStatus(scan.ID, "running", 0, nil)
+	p.db.AddLog(scan.ID, "info", "Starting full recon pipeline for "+scan.Target)
+	screenshots, maxHosts, _ := PipelineOptions(scan.Options)
+
+	subdomains, err := p.runStep(ctx, scan, "subdomain", scan.Target, 0, 45)
+	if errors.Is(err, errPipelineCancelled) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("subdomain enumeration failed: %w", err)
+	}
+
+	if _, err := p.runStep(ctx, scan, "dns", scan.Target, 45, 55); errors.Is(err, errPipelineCancelled) {
+		return nil
+	} else if err != nil {
+		p.db.AddLog(scan.ID, "warning", "DNS records step failed: "+err.Error())
+	}
+
+	urls, total := p.aliveURLs(subdomains.ID, maxHosts)
+	if len(urls) < total {
+		p.db.AddLog(scan.ID, "warning", fmt.Sprintf("%d hosts answer over HTTP/HTTPS, only the first %d (max_hosts) are fingerprinted and screenshotted", total, len(urls)))
+	}
+	if len(urls) == 0 {
+		p.db.AddLog(scan.ID, "info", "No subdomain answers over HTTP/HTTPS: skipping technology detection and screenshots")
+		p.complete(scan)
+		return nil
+	}
+
+	// Simulated tech scans make up their hosts from the domain
+	techTarget := strings.Join(urls, "\n")
+	if scan.Options["simulated"] == true {
+		techTarget = scan.Target
+	}
+	p.db.AddLog(scan.ID, "info", fmt.Sprintf("%d alive hosts found", len(urls)))
+	if _, err := p.runStep(ctx, scan, "tech", techTarget, 55, 80); errors.Is(err, errPipelineCancelled) {
+		return nil
+	} else if err != nil {
+		p.db.AddLog(scan.ID, "warning", "Technology detection step failed: "+err.Error())
+	}
+
+	switch {
+	case !screenshots:
+		p.db.AddLog(scan.ID, "info", "Screenshots disabled (screenshots: false)")
+	case p.webURL == "":
+		p.db.AddLog(scan.ID, "warning", "WEB_SERVICE_URL is not set: skipping screenshots")
+	default:
+		if err := p.screenshots(ctx, scan, urls, 80, 100); errors.Is(err, errPipelineCancelled) {
+			return nil
+		} else if err != nil {
+			p.db.AddLog(scan.ID, "warning", "Screenshot step failed: "+err.Error())
+		}
+	}
+
+	p.complete(scan)
+	return nil
+}
+
+func (p *Pipeline) complete(scan *models.ReconScan) {
+	p.db.UpdateScanStatus(scan.ID, "completed", 100, nil)
+	p.db.AddLog(scan.ID, "info", "Full recon pipeline completed")
+}
+
+// runStep runs a recon scan of scanType as a child of the pipeline scan,
+// scanning target, and maps its progress to from-to of the parent's. It
+// returns the child once it ended, or the reason it didn't complete.
+func (p *Pipeline) runStep(ctx context.Context, parent *models.ReconScan, scanType, target string, from, to int) (*models.ReconScan, error) {
+	parentID := parent.ID
+	child := &models.ReconScan{
+		ID:        uuid.New(),
+		Name:      parent.Name + " / " + scanType,
+		Target:    parent.Target,
+		ScanType:  scanType,
+		Status:    "pending",
+		CreatedAt: time.Now(),
+		Options:   map[string]interface{}{},
+		Origin:    parent.Origin,
+		ProjectID: parent.ProjectID,
+		ParentID:  &parentID,
+	}
+	if resolvers, ok := parent.Options["resolvers"]; ok && scanType != "tech" {
+		child.Options["resolvers"] = resolvers
+	}
+	if scanType == "subdomain" {
+		child.Options["http_probe"] = true // the alive hosts of the later steps
+	}
+	if parent.Options["simulated"] == true {
+		child.Options["simulated"] = true
+	}
+	if err := p.db.CreateScan(child); err != nil {
+		return nil, err
+	}
+	p.db.AddLog(parent.ID, "info", fmt.Sprintf("Started %s scan %s", scanType, child.ID))
+
+	// The child scans the hosts, while its record names the domain
+	run := *child
+	run.Target = target
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- p.scanner(&run)(childCtx, &run) }()
+
+	ticker := time.NewTicker(pipelinePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			current, getErr := p.db.GetScan(child.ID)
+			if err == nil && getErr != nil {
+				err = getErr
+			}
+			if err == nil && current.Status != "completed" {
+				err = fmt.Errorf("%s scan %s", scanType, current.Status)
+			}
+			if err != nil {
+				msg := err.Error()
+				p.db.UpdateScanStatus(child.ID, "failed", 0, &msg)
+				return current, err
+			}
+			p.db.UpdateScanStatus(parent.ID, "running", to, nil)
+			return current, nil
+
+		case <-ticker.C:
+			if p.cancelled(parent.ID) {
+				cancel()
+				<-done
+				p.db.UpdateScanStatus(child.ID, "cancelled", 0, nil)
+				return nil, errPipelineCancelled
+			}
+			if current, err := p.db.GetScan(child.ID); err == nil {
+				p.db.UpdateScanStatus(parent.ID, "running", from+current.Progress*(to-from)/100, nil)
+			}
+		}
+	}
+}
+
+// scanner returns what runs a child scan
+func (p *Pipeline) scanner(scan *models.ReconScan) func(context.Context, *models.ReconScan) error {
+	switch {
+	case scan.Options["simulated"] == true:
+		return p.simulator.Scan
+	case scan.ScanType == "subdomain":
+		return p.subdomain.Scan
+	case scan.ScanType == "dns":
+		return p.dns.Scan
+	default:
+		return p.tech.Scan
+	}
+}
+
+func (p *Pipeline) cancelled(id uuid.UUID) bool {
+	scan, err := p.db.GetScan(id)
+	return err == nil && scan.Status == "cancelled"
+}
+
+// aliveURLs returns the URLs of the subdomains that answered the HTTP probe,
+// HTTPS when it answered, at most max of them, and how many answered
+func (p *Pipeline) aliveURLs(subdomainScanID uuid.UUID, max int) ([]string, int) {
+	results, err := p.db.GetSubdomainResults(subdomainScanID, true)
+	if err != nil {
+		return nil, 0
+	}
+	var urls []string
+	for _, result := range results {
+		switch {
+		case result.HTTPSStatus != nil:
+			urls = append(urls, "https://"+result.Subdomain)
+		case result.HTTPStatus != nil:
+			urls = append(urls, "http://"+result.Subdomain)
+		}
+	}
+	sort.Strings(urls)
+	total := len(urls)
+	if total > max {
+		urls = urls[:max]
+	}
+	return urls, total
+}
+
+// screenshots starts a gowitness scan of urls on the web service, in the
+// pipeline scan's project and with its origin, and follows it to the end
+func (p *Pipeline) screenshots(ctx context.Context, scan *models.ReconScan, urls []string, from, to int) error {
+	header := http.Header{}
+	header.Set(project.Header, scan.ProjectID)
+	header.Set(origin.Header, scan.Origin) // schedules and workflows keep theirs
+	web := client.NewWeb(p.webURL, client.Options{Sign: p.sign, Header: header})
+
+	webScan, err := web.WebScans.Create(ctx, "gowitness", map[string]interface{}{
+		"name":     scan.Name + " / screenshots",
+		"urls":     urls,
+		"simulate": scan.Options["simulated"] == true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start the gowitness scan: %w", err)
+	}
+	p.db.SetScreenshotScan(scan.ID, webScan.ID)
+	p.db.AddLog(scan.ID, "info", fmt.Sprintf("Started gowitness scan %s of %d hosts on the web service", webScan.ID, len(urls)))
+
+	ticker := time.NewTicker(pipelinePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			web.WebScans.Cancel(context.Background(), webScan.ID)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if p.cancelled(scan.ID) {
+			web.WebScans.Cancel(ctx, webScan.ID)
+			return errPipelineCancelled
+		}
+		current, err := web.WebScans.Get(ctx, webScan.ID)
+		if err != nil {
+			continue // the web service restarting; the timeout still applies
+		}
+		p.db.UpdateScanStatus(scan.ID, "running", from+current.Progress*(to-from)/100, nil)
+		if current.Finished() {
+			if current.Status != "completed" {
+				return fmt.Errorf("gowitness scan %s", current.Status)
+			}
+			return nil
+		}
+	}
+}
+
+// PipelineSteps returns the scans a recon_full scan started, in the order
+// it ran them
+func PipelineSteps(db *database.Database, scan *models.ReconScan) []models.PipelineStep {
+	names := map[string]string{"subdomain": "subdomains", "dns": "dns", "tech": "tech"}
+	steps := []models.PipelineStep{}
+	children, _ := db.GetChildScans(scan.ID)
+	for _, child := range children {
+		steps = append(steps, models.PipelineStep{
+			Step: names[child.ScanType], Service: "recon", ScanType: child.ScanType, ID: child.ID,
+			Status: child.Status, Progress: child.Progress, ErrorMessage: child.ErrorMessage,
+		})
+	}
+	if scan.ScreenshotScanID != nil {
+		step := models.PipelineStep{Step: "screenshots", Service: "web", ScanType: "gowitness", ID: *scan.ScreenshotScanID, Status: "unknown"}
+		if status, progress, message, err := db.GetWebScan(*scan.ScreenshotScanID); err == nil {
+			step.Status, step.Progress, step.ErrorMessage = status, progress, message
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
@@ -0,0 +1,121 @@
+package recon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/recon-service/internal/models"
+)
+
+// httpProbeTimeout bounds the httpx run of a subdomain scan
+const httpProbeTimeout = 15 * time.Minute
+
+// httpProbeThreads is the number of hosts httpx probes at once
+const httpProbeThreads = "50"
+
+// httpProbeEnabled reports whether a subdomain scan probes what it finds
+// over HTTP; "http_probe": false in its options turns it off
+func httpProbeEnabled(scan *models.ReconScan) bool {
+	enabled, ok := scan.Options["http_probe"].(bool)
+	return enabled || !ok
+}
+
+// probeHTTP runs httpx once over hosts, on both HTTP and HTTPS, and returns
+// what answered by host. Hosts that answer neither are left out.
+func (s *SubdomainScanner) probeHTTP(ctx context.Context, hosts []string) (map[string]*models.HTTPProbe, error) {
+	ctx, cancel := context.WithTimeout(ctx, httpProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.httpxPath,
+		"-silent",
+		"-json",
+		"-no-fallback", // probe https and http, not http only when https fails
+		"-status-code",
+		"-title",
+		"-server",
+		"-content-length",
+		"-no-color",
+		"-threads", httpProbeThreads,
+		"-timeout", "10",
+	)
+	cmd.Stdin = strings.NewReader(strings.Join(hosts, "\n"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start httpx: %w", err)
+	}
+
+	probes := make(map[string]*models.HTTPProbe)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var result HttpxResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		host := probeHost(result.Input)
+		if host == "" {
+			continue
+		}
+		probe := probes[host]
+		if probe == nil {
+			probe = &models.HTTPProbe{}
+			probes[host] = probe
+		}
+		addProbeResult(probe, &result)
+	}
+
+	if err := cmd.Wait(); err != nil && len(probes) == 0 {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("httpx timed out after %s", httpProbeTimeout)
+		}
+		return nil, err
+	}
+	return probes, nil
+}
+
+// addProbeResult adds one scheme's answer to a host's probe. Title, length
+// and server are the HTTPS ones when both schemes answer.
+func addProbeResult(probe *models.HTTPProbe, result *HttpxResult) {
+	status := result.StatusCode
+	https := result.Scheme == "https" || strings.HasPrefix(result.URL, "https://")
+	if https {
+		probe.HTTPSStatus = &status
+	} else {
+		probe.HTTPStatus = &status
+	}
+	if !https && probe.HTTPSStatus != nil {
+		return
+	}
+	probe.Title, probe.ContentLength, probe.WebServer = nil, nil, nil
+	if title := strings.TrimSpace(result.Title); title != "" {
+		probe.Title = &title
+	}
+	if result.ContentLength > 0 {
+		length := result.ContentLength
+		probe.ContentLength = &length
+	}
+	if result.Webserver != "" {
+		server := result.Webserver
+		probe.WebServer = &server
+	}
+}
+
+// probeHost returns the subdomain httpx was given for an input, which it
+// can echo with a scheme or port
+func probeHost(input string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(input)), "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
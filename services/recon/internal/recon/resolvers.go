@@ -0,0 +1,54 @@
+package recon
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/security-scanner/recon-service/internal/database"
+	"github.com/security-scanner/recon-service/internal/models"
+	"github.com/security-scanner/shared/pkg/securedns"
+)
+
+// scanResolver returns the resolver of a scan's lookups: DoH/DoT through the
+// resolvers of its "resolvers" option, or through defaults when it has none.
+// secure is nil when the scan uses the system resolver.
+func scanResolver(db *database.Database, scan *models.ReconScan, defaults []securedns.Endpoint) (resolver *net.Resolver, secure *securedns.Resolver) {
+	endpoints, err := securedns.FromConfiguration(scan.Options["resolvers"])
+	if err != nil || endpoints == nil {
+		endpoints = defaults
+	}
+	if len(endpoints) == 0 {
+		return net.DefaultResolver, nil
+	}
+	names := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		names[i] = e.String()
+	}
+	db.AddLog(scan.ID, "info", "Resolving over DoH/DoT through "+strings.Join(names, ", "))
+	secure = securedns.New(endpoints, 0)
+	return secure.NetResolver(), secure
+}
+
+// reportResolvers logs how each DoH/DoT resolver of a scan did and stores it
+// in the scan's resolver_health
+func reportResolvers(db *database.Database, scan *models.ReconScan, secure *securedns.Resolver) {
+	if secure == nil {
+		return
+	}
+	health := secure.Health()
+	for _, h := range health {
+		level := "info"
+		if !h.Healthy {
+			level = "warning"
+		}
+		db.AddLog(scan.ID, level, "Resolver "+h.String())
+	}
+	if !secure.Healthy() {
+		db.AddLog(scan.ID, "warning", fmt.Sprintf("No DoH/DoT resolver answered: the results of %s are likely incomplete", scan.Target))
+	}
+	if err := db.SaveResolverHealth(scan.ID, health); err != nil {
+		log.Printf("Error saving resolver health of scan %s: %v", scan.ID, err)
+	}
+}
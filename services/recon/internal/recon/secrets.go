@@ -0,0 +1,98 @@
+package recon
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/security-scanner/recon-service/internal/models"
+)
+
+// secretPatterns are credential formats worth flagging when found next to
+// the target's domain
+var secretPatterns = []struct {
+	name     string
+	severity string
+	re       *regexp.Regexp
+}{
+	{"AWS access key", "critical", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"Private key", "critical", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY-----(?:\s*([A-Za-z0-9+/=]{16,}))?`)},
+	{"GitHub token", "critical", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})`)},
+	{"GitLab token", "critical", regexp.MustCompile(`\bglpat-[A-Za-z0-9_\-]{20,}`)},
+	{"Stripe secret key", "critical", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}`)},
+	{"Slack token", "high", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}`)},
+	{"Google API key", "high", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{"SendGrid API key", "high", regexp.MustCompile(`\bSG\.[A-Za-z0-9_\-]{22}\.[A-Za-z0-9_\-]{43}`)},
+	{"Credentials in URL", "high", regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^/\s:@'"]+:([^/\s:@'"]+)@[^\s'"]+`)},
+	{"JSON Web Token", "medium", regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	{"Hardcoded password or secret", "medium", regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|api[_\-]?key|access[_\-]?token|auth[_\-]?token)["']?\s*[:=]\s*["']([^"'\s]{6,})["']`)},
+}
+
+// severityRank orders leak severities; info means the domain was mentioned
+// without any credential next to it
+var severityRank = map[string]int{"info": 0, "medium": 1, "high": 2, "critical": 3}
+
+// placeholderValues are example values that are not real credentials
+var placeholderValues = []string{
+	"password", "changeme", "example", "xxxx", "****", "your_", "your-", "<", "${", "{{", "%s", "secret", "dummy", "test",
+}
+
+// findSecrets matches text against the credential patterns and returns the
+// secrets found (masked), the highest severity and text with the secrets masked
+func findSecrets(text string) ([]models.LeakedSecret, string, string) {
+	secrets := []models.LeakedSecret{}
+	severity := "info"
+	masked := text
+	seen := map[string]bool{}
+
+	for _, pattern := range secretPatterns {
+		for _, match := range pattern.re.FindAllStringSubmatch(text, -1) {
+			// Patterns with a group capture only the secret part of the match
+			value, hidden := match[0], ""
+			switch {
+			case len(match) > 1 && match[1] == "":
+				// Only the header of a private key is in the text
+				hidden = value
+			case len(match) > 1:
+				value = match[1]
+			}
+			if hidden == "" {
+				if isPlaceholder(value) {
+					continue
+				}
+				hidden = maskSecret(value)
+			}
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			masked = strings.ReplaceAll(masked, value, hidden)
+			secrets = append(secrets, models.LeakedSecret{
+				Type:     pattern.name,
+				Severity: pattern.severity,
+				Match:    strings.Replace(match[0], value, hidden, 1),
+			})
+			if severityRank[pattern.severity] > severityRank[severity] {
+				severity = pattern.severity
+			}
+		}
+	}
+	return secrets, severity, masked
+}
+
+func isPlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, p := range placeholderValues {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret keeps enough of a secret to recognise it, never the whole value
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return value[:2] + "******"
+	}
+	return value[:4] + "********" + value[len(value)-4:]
+}
@@ -5,7 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net"
+	"net/http"
 	"os/exec"
 	"strings"
 	"time"
@@ -13,43 +13,94 @@ import (
 	"github.com/google/uuid"
 	"github.com/security-scanner/recon-service/internal/database"
 	"github.com/security-scanner/recon-service/internal/models"
+	"github.com/security-scanner/shared/pkg/dnswildcard"
+	"github.com/security-scanner/shared/pkg/securedns"
 )
 
 type SubdomainScanner struct {
-	db            *database.Database
-	subfinderPath string
-	amassPath     string
+	db               *database.Database
+	subfinderPath    string
+	amassPath        string
+	httpxPath        string
+	resolvers        []securedns.Endpoint
+	client           *http.Client // certificate transparency APIs
+	certSpotterToken string
 }
 
-func NewSubdomainScanner(db *database.Database, subfinderPath, amassPath string) *SubdomainScanner {
+func NewSubdomainScanner(db *database.Database, subfinderPath, amassPath, httpxPath string) *SubdomainScanner {
 	return &SubdomainScanner{
 		db:            db,
 		subfinderPath: subfinderPath,
 		amassPath:     amassPath,
+		httpxPath:     httpxPath,
+		client:        &http.Client{Timeout: 90 * time.Second},
 	}
 }
 
+// SetCertSpotterToken authenticates Cert Spotter queries, which are limited
+// to a few an hour without a token
+func (s *SubdomainScanner) SetCertSpotterToken(token string) {
+	s.certSpotterToken = token
+}
+
+// SetResolvers sends the lookups of scans without resolvers of their own to
+// these DoH/DoT endpoints
+func (s *SubdomainScanner) SetResolvers(endpoints []securedns.Endpoint) {
+	s.resolvers = endpoints
+}
+
 func (s *SubdomainScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
 	s.db.UpdateScanStatus(scan.ID, "running", 0, nil)
 	s.db.AddLog(scan.ID, "info", "Starting subdomain enumeration for "+scan.Target)
 
-	subdomains := make(map[string]string) // subdomain -> source
+	// Every source that found a subdomain is kept, in the order they ran
+	subdomains := make(map[string][]string)
+	merge := func(source string, found []string) int {
+		added := 0
+		for _, sub := range found {
+			sub = strings.ToLower(sub)
+			sources := subdomains[sub]
+			if len(sources) == 0 {
+				added++
+			}
+			if len(sources) == 0 || sources[len(sources)-1] != source {
+				subdomains[sub] = append(sources, source)
+			}
+		}
+		return added
+	}
 
 	// Run Subfinder
 	s.db.AddLog(scan.ID, "info", "Running Subfinder...")
-	s.db.UpdateScanStatus(scan.ID, "running", 20, nil)
+	s.db.UpdateScanStatus(scan.ID, "running", 10, nil)
 	subfinderResults, err := s.runSubfinder(ctx, scan.Target)
 	if err != nil {
 		s.db.AddLog(scan.ID, "warning", "Subfinder error: "+err.Error())
 	} else {
-		for _, sub := range subfinderResults {
-			if _, exists := subdomains[sub]; !exists {
-				subdomains[sub] = "subfinder"
-			}
-		}
+		merge("subfinder", subfinderResults)
 		s.db.AddLog(scan.ID, "info", fmt.Sprintf("Subfinder found %d subdomains", len(subfinderResults)))
 	}
 
+	// Query the certificate transparency logs directly, so certificates
+	// are found even when subfinder's sources are rate limited
+	s.db.AddLog(scan.ID, "info", "Querying certificate transparency logs (crt.sh, Cert Spotter)...")
+	s.db.UpdateScanStatus(scan.ID, "running", 30, nil)
+	for _, ct := range []struct {
+		source, name string
+		query        func(context.Context, string) ([]string, error)
+	}{
+		{SourceCrtSh, "crt.sh", s.queryCrtSh},
+		{SourceCertSpotter, "Cert Spotter", s.queryCertSpotter},
+	} {
+		results, err := ct.query(ctx, scan.Target)
+		if err != nil {
+			s.db.AddLog(scan.ID, "warning", ct.name+" error: "+err.Error())
+			continue
+		}
+		added := merge(ct.source, results)
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("%s found %d subdomains (%d new)", ct.name, len(results), added))
+	}
+
 	// Run Amass (passive mode for speed) with timeout
 	s.db.AddLog(scan.ID, "info", "Running Amass (passive mode, 2min timeout)...")
 	s.db.UpdateScanStatus(scan.ID, "running", 50, nil)
@@ -59,24 +110,30 @@ func (s *SubdomainScanner) Scan(ctx context.Context, scan *models.ReconScan) err
 	if err != nil {
 		s.db.AddLog(scan.ID, "warning", "Amass error: "+err.Error())
 	} else {
-		for _, sub := range amassResults {
-			if _, exists := subdomains[sub]; !exists {
-				subdomains[sub] = "amass"
-			}
-		}
-		s.db.AddLog(scan.ID, "info", fmt.Sprintf("Amass found %d additional subdomains", len(amassResults)))
+		added := merge("amass", amassResults)
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("Amass found %d additional subdomains", added))
 	}
 
 	// Resolve IPs and save results
 	s.db.AddLog(scan.ID, "info", "Resolving IP addresses...")
 	s.db.UpdateScanStatus(scan.ID, "running", 70, nil)
+	resolver, secure := scanResolver(s.db, scan, s.resolvers)
+
+	// With wildcard DNS every name resolves; results that only get the
+	// wildcard's addresses are marked and listed last
+	wildcards := dnswildcard.New(resolver)
+	if addrs := wildcards.Addresses(ctx, scan.Target); len(addrs) > 0 {
+		s.db.AddLog(scan.ID, "warning", fmt.Sprintf("Wildcard DNS on *.%s (%s): matching subdomains are marked as wildcard", scan.Target, strings.Join(addrs, ", ")))
+	}
 
 	count := 0
+	wildcardCount := 0
 	total := len(subdomains)
-	for subdomain, source := range subdomains {
+	var live []string // resolved to addresses of their own
+	for subdomain, sources := range subdomains {
 		// Resolve IP addresses
 		var ipAddresses []string
-		ips, err := net.LookupIP(subdomain)
+		ips, err := resolver.LookupIP(ctx, "ip", subdomain)
 		if err == nil && len(ips) > 0 {
 			for _, ip := range ips {
 				ipAddresses = append(ipAddresses, ip.String())
@@ -88,21 +145,50 @@ func (s *SubdomainScanner) Scan(ctx context.Context, scan *models.ReconScan) err
 			ScanID:      scan.ID,
 			Subdomain:   subdomain,
 			IPAddresses: ipAddresses,
-			Source:      source,
+			Source:      sources[0],
+			Sources:     sources,
 			IsAlive:     len(ipAddresses) > 0,
+			Wildcard:    wildcards.Match(ctx, subdomain, ipAddresses),
 			CreatedAt:   time.Now(),
 		}
+		if result.Wildcard != "" {
+			wildcardCount++
+		} else if result.IsAlive {
+			live = append(live, subdomain)
+		}
 		if err := s.db.SaveSubdomainResult(result); err != nil {
 			log.Printf("Error saving subdomain %s: %v", subdomain, err)
 		}
 		count++
 
 		// Update progress
-		progress := 70 + (count * 30 / total)
+		progress := 70 + (count * 15 / total)
 		s.db.UpdateScanStatus(scan.ID, "running", progress, nil)
 	}
 
+	reportResolvers(s.db, scan, secure)
+
+	// Probe the live subdomains over HTTP and HTTPS; wildcard matches would
+	// all answer with the wildcard's site
+	if httpProbeEnabled(scan) && len(live) > 0 {
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("Probing %d live subdomains over HTTP/HTTPS with httpx...", len(live)))
+		s.db.UpdateScanStatus(scan.ID, "running", 85, nil)
+		probes, err := s.probeHTTP(ctx, live)
+		if err != nil {
+			s.db.AddLog(scan.ID, "warning", "httpx error: "+err.Error())
+		}
+		for subdomain, probe := range probes {
+			if err := s.db.SaveSubdomainProbe(scan.ID, subdomain, probe); err != nil {
+				log.Printf("Error saving HTTP probe of %s: %v", subdomain, err)
+			}
+		}
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("%d of %d live subdomains answer over HTTP/HTTPS", len(probes), len(live)))
+	}
+
 	s.db.AddLog(scan.ID, "info", fmt.Sprintf("Found %d unique subdomains", count))
+	if wildcardCount > 0 {
+		s.db.AddLog(scan.ID, "info", fmt.Sprintf("%d of them only resolve through wildcard DNS", wildcardCount))
+	}
 	s.db.UpdateScanStatus(scan.ID, "completed", 100, nil)
 	s.db.AddLog(scan.ID, "info", "Subdomain enumeration completed")
 
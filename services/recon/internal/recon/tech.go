@@ -29,17 +29,19 @@ func NewTechScanner(db *database.Database, httpxPath string) *TechScanner {
 
 // HttpxResult represents the JSON output from httpx
 type HttpxResult struct {
-	URL         string            `json:"url"`
-	StatusCode  int               `json:"status_code"`
-	Title       string            `json:"title"`
-	Tech        []string          `json:"tech"`
-	Webserver   string            `json:"webserver"`
-	ContentType string            `json:"content_type"`
-	Host        string            `json:"host"`
-	Port        string            `json:"port"`
-	Scheme      string            `json:"scheme"`
-	Method      string            `json:"method"`
-	Headers     map[string]string `json:"header,omitempty"`
+	URL           string            `json:"url"`
+	StatusCode    int               `json:"status_code"`
+	Title         string            `json:"title"`
+	Tech          []string          `json:"tech"`
+	Webserver     string            `json:"webserver"`
+	ContentType   string            `json:"content_type"`
+	Host          string            `json:"host"`
+	Port          string            `json:"port"`
+	Input         string            `json:"input"` // the target as given to httpx
+	ContentLength int               `json:"content_length"`
+	Scheme        string            `json:"scheme"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"header,omitempty"`
 }
 
 func (t *TechScanner) Scan(ctx context.Context, scan *models.ReconScan) error {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
@@ -12,6 +13,27 @@ type Config struct {
 	SubfinderPath string
 	AmassPath     string
 	HttpxPath     string
+	GitHubToken   string
+	GitLabURL     string
+	GitLabToken   string
+	HunterAPIKey  string
+	HIBPAPIKey    string
+	HIBPRate      int // HaveIBeenPwned requests per minute allowed by the API key
+
+	// Cert Spotter API token for subdomain scans; unauthenticated queries
+	// are limited to a few an hour
+	CertSpotterToken string
+
+	// DNS and subdomain scans resolve over these comma-separated DoH
+	// (https://) or DoT (tls://) resolvers unless a scan sets its own
+	DNSResolvers string
+
+	// API requests must be signed by the gateway with this secret (disabled when empty)
+	InternalAuthSecret string
+
+	// Web service recon_full scans screenshot alive hosts with; its
+	// requests are signed with InternalAuthSecret
+	WebServiceURL string
 }
 
 func Load() *Config {
@@ -23,6 +45,18 @@ func Load() *Config {
 		SubfinderPath: getEnv("SUBFINDER_PATH", "/usr/local/bin/subfinder"),
 		AmassPath:     getEnv("AMASS_PATH", "/usr/local/bin/amass"),
 		HttpxPath:     getEnv("HTTPX_PATH", "/usr/local/bin/httpx"),
+		GitHubToken:   getEnv("GITHUB_TOKEN", ""),
+		GitLabURL:     getEnv("GITLAB_URL", "https://gitlab.com"),
+		GitLabToken:   getEnv("GITLAB_TOKEN", ""),
+		HunterAPIKey:  getEnv("HUNTER_API_KEY", ""),
+		HIBPAPIKey:    getEnv("HIBP_API_KEY", ""),
+		HIBPRate:      getEnvInt("HIBP_REQUESTS_PER_MINUTE", 10),
+		DNSResolvers:  getEnv("DNS_RESOLVERS", ""),
+
+		CertSpotterToken: getEnv("CERTSPOTTER_API_KEY", ""),
+
+		InternalAuthSecret: getEnv("INTERNAL_AUTH_SECRET", ""),
+		WebServiceURL:      getEnv("WEB_SERVICE_URL", ""),
 	}
 }
 
@@ -32,3 +66,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return intVal
+	}
+	return defaultValue
+}
@@ -0,0 +1,118 @@
+// Package apierror is the error response every service returns:
+//
+//	{"error": "Scan not found", "code": "not_found", "message": "Scan not found",
+//	 "details": ..., "request_id": "..."}
+//
+// "error" repeats the message for clients of the older {"error": "..."}
+// format. Handlers keep answering {"error": "..."} with a status code; each
+// service's error middleware turns that into an envelope, deriving the code
+// from the status unless the handler returned an Envelope with its own.
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader carries the request ID, set by the gateway and forwarded
+// to the services so both log and return the same ID
+const RequestIDHeader = "X-Request-ID"
+
+// Failure classes
+const (
+	CodeValidation   = "validation"   // 400, 413, 422: the request is wrong
+	CodeUnauthorized = "unauthorized" // 401
+	CodeForbidden    = "forbidden"    // 403
+	CodeNotFound     = "not_found"    // 404, 405
+	CodeConflict     = "conflict"     // 409: e.g. deleting a running scan
+	CodeRateLimited  = "rate_limited" // 429
+	CodeToolFailure  = "tool_failure" // a scanner or external tool failed
+	CodeTimeout      = "timeout"      // 504 or a tool that didn't finish in time
+	CodeUnavailable  = "unavailable"  // 502, 503: a dependency or feature is down
+	CodeInternal     = "internal"     // 500
+)
+
+// Envelope is an error response
+type Envelope struct {
+	Error     string      `json:"error"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// New returns an envelope with an explicit code; details may be nil
+func New(code, message string, details interface{}) Envelope {
+	return Envelope{Error: message, Code: code, Message: message, Details: details}
+}
+
+// CodeForStatus is the failure class of an HTTP status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return CodeValidation
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeValidation
+}
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Normalize turns the body of an error response into an envelope. JSON
+// objects keep their other fields, so endpoints that return more than the
+// message don't break; anything else becomes the message.
+func Normalize(status int, body []byte, requestID string) []byte {
+	fields := map[string]interface{}{}
+	if json.Unmarshal(body, &fields) != nil {
+		fields = map[string]interface{}{}
+		if message := strings.TrimSpace(string(body)); message != "" {
+			fields["error"] = message
+		}
+	}
+
+	message, _ := fields["message"].(string)
+	if message == "" {
+		message, _ = fields["error"].(string)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	if code, _ := fields["code"].(string); code == "" {
+		fields["code"] = CodeForStatus(status)
+	}
+	fields["error"] = message
+	fields["message"] = message
+	if _, ok := fields["request_id"]; !ok && requestID != "" {
+		fields["request_id"] = requestID
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return data
+}
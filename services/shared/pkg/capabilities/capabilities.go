@@ -0,0 +1,120 @@
+// Package capabilities describes the scan types a service supports and the
+// fields of their requests, so that UIs and the CLI can render forms and
+// validate configurations without knowing each tool. Every service serves
+// its description at GET /api/capabilities; each scan type comes with a
+// JSON Schema of its request body generated from its fields.
+package capabilities
+
+// Field types, as in JSON Schema
+const (
+	String  = "string"
+	Integer = "integer"
+	Number  = "number"
+	Boolean = "boolean"
+	Array   = "array"
+	Object  = "object"
+)
+
+// Field is a field of a scan request
+type Field struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Minimum     *float64    `json:"minimum,omitempty"`
+	Maximum     *float64    `json:"maximum,omitempty"`
+	Pattern     string      `json:"pattern,omitempty"`
+	Format      string      `json:"format,omitempty"` // uuid, uri, email, ...
+	Items       *Field      `json:"items,omitempty"`  // the elements of an array
+	Fields      []Field     `json:"fields,omitempty"` // the properties of an object
+	AdminOnly   bool        `json:"admin_only,omitempty"`
+}
+
+// ScanType is a kind of scan and the request that creates it
+type ScanType struct {
+	Name        string  `json:"name"` // the scan_type, or the tool when each tool has its own endpoint
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Tool        string  `json:"tool"`
+	Endpoint    string  `json:"endpoint"` // e.g. POST /api/scans
+	Fields      []Field `json:"fields"`
+	// Schema is the JSON Schema of the request body
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// Capabilities are the scan types of a service
+type Capabilities struct {
+	Service   string     `json:"service"`
+	ScanTypes []ScanType `json:"scan_types"`
+}
+
+// New returns the capabilities of service, generating the schema of each
+// scan type from its fields
+func New(service string, scanTypes []ScanType) Capabilities {
+	for i := range scanTypes {
+		s := &scanTypes[i]
+		s.Schema = objectSchema(s.Fields)
+		s.Schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		s.Schema["title"] = s.Title
+		if s.Description != "" {
+			s.Schema["description"] = s.Description
+		}
+	}
+	return Capabilities{Service: service, ScanTypes: scanTypes}
+}
+
+// Bound returns a pointer to v, for Field.Minimum and Field.Maximum
+func Bound(v float64) *float64 {
+	return &v
+}
+
+func objectSchema(fields []Field) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, f := range fields {
+		properties[f.Name] = f.schema()
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	schema := map[string]interface{}{"type": Object, "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schema returns the JSON Schema of a field
+func (f Field) schema() map[string]interface{} {
+	schema := map[string]interface{}{"type": f.Type}
+	if f.Type == Object && len(f.Fields) > 0 {
+		schema = objectSchema(f.Fields)
+	}
+	if f.Description != "" {
+		schema["description"] = f.Description
+	}
+	if f.Default != nil {
+		schema["default"] = f.Default
+	}
+	if len(f.Enum) > 0 {
+		schema["enum"] = f.Enum
+	}
+	if f.Minimum != nil {
+		schema["minimum"] = *f.Minimum
+	}
+	if f.Maximum != nil {
+		schema["maximum"] = *f.Maximum
+	}
+	if f.Pattern != "" {
+		schema["pattern"] = f.Pattern
+	}
+	if f.Format != "" {
+		schema["format"] = f.Format
+	}
+	if f.Items != nil {
+		schema["items"] = f.Items.schema()
+	}
+	return schema
+}
@@ -0,0 +1,366 @@
+// Package chat posts scans with high-severity findings to Slack and Discord
+// channels through their incoming webhooks: the scan, its target, its
+// findings by severity and a link to its report. Channels come from the
+// environment of each service (SLACK_WEBHOOK_URL, DISCORD_WEBHOOK_URL) and
+// from the chat_channels table, managed through the gateway.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/models"
+)
+
+// Channel types
+const (
+	Slack   = "slack"
+	Discord = "discord"
+)
+
+// DefaultSeverities are the severities a channel is notified about unless
+// it says otherwise
+var DefaultSeverities = []string{models.SeverityCritical, models.SeverityHigh}
+
+// SchemaSQL creates the table of channels, managed through the gateway and
+// read by every service that notifies
+const SchemaSQL = `
+CREATE TABLE IF NOT EXISTS chat_channels (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    severities TEXT[] NOT NULL DEFAULT '{critical,high}',
+    tools TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Columns are the chat_channels columns read by Scan
+const Columns = `id, name, type, webhook_url, severities, tools, enabled, created_at, updated_at`
+
+// Channel is a Slack or Discord incoming webhook and the scans posted to it
+type Channel struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"` // slack or discord
+	WebhookURL string    `json:"webhook_url"`
+	Severities []string  `json:"severities"` // posted when the scan found any of these
+	Tools      []string  `json:"tools"`      // nuclei, wpscan, prowler, saved-search, drift; empty for all
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Scan reads a channel selected with Columns; severities and tools are
+// TEXT[], so row must come from pgx
+func Scan(row database.Row) (*Channel, error) {
+	var c Channel
+	err := row.Scan(&c.ID, &c.Name, &c.Type, &c.WebhookURL, &c.Severities, &c.Tools, &c.Enabled, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate normalizes a channel and checks its type, webhook and severities
+func Validate(c *Channel) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Type = strings.ToLower(strings.TrimSpace(c.Type))
+	c.WebhookURL = strings.TrimSpace(c.WebhookURL)
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.Type != Slack && c.Type != Discord {
+		return fmt.Errorf("type must be slack or discord")
+	}
+	u, err := url.Parse(c.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an https URL")
+	}
+
+	if len(c.Severities) == 0 {
+		c.Severities = DefaultSeverities
+	}
+	severities := []string{}
+	for _, s := range c.Severities {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if models.NormalizeSeverity(s) != s {
+			return fmt.Errorf("invalid severity %q: expected one of %s", s, strings.Join(models.Severities, ", "))
+		}
+		severities = append(severities, s)
+	}
+	c.Severities = severities
+
+	tools := []string{}
+	for _, t := range c.Tools {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tools = append(tools, t)
+		}
+	}
+	c.Tools = tools
+	return nil
+}
+
+// FromEnv returns the channels configured in a service's environment: a
+// Slack and a Discord webhook, notified about the comma-separated severities
+func FromEnv(slackURL, discordURL, severities string) ([]Channel, error) {
+	var list []string
+	for _, s := range strings.Split(severities, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+
+	var channels []Channel
+	for _, c := range []Channel{
+		{Name: "SLACK_WEBHOOK_URL", Type: Slack, WebhookURL: slackURL},
+		{Name: "DISCORD_WEBHOOK_URL", Type: Discord, WebhookURL: discordURL},
+	} {
+		if c.WebhookURL == "" {
+			continue
+		}
+		c.Severities = list
+		c.Enabled = true
+		if err := Validate(&c); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		channels = append(channels, c)
+	}
+	return channels, nil
+}
+
+// Summary is a finished scan to post
+type Summary struct {
+	Service    string // e.g. web-service
+	Tool       string // nuclei, wpscan, prowler, saved-search for new matches of a search, drift for new cloud failures
+	ScanID     string
+	ScanName   string
+	Target     string
+	Counts     map[string]int // findings by severity
+	ReportPath string         // e.g. /vuln-scan/<id>, appended to the report base URL
+}
+
+// Matches reports whether a channel is notified about a scan
+func (c Channel) Matches(s Summary) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Tools) > 0 && !contains(c.Tools, s.Tool) {
+		return false
+	}
+	for _, severity := range c.Severities {
+		if s.Counts[severity] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier posts summaries to the channels of the environment and of the
+// chat_channels table. A nil Notifier posts nothing.
+type Notifier struct {
+	queryRow      database.QueryRowFunc
+	env           []Channel
+	reportBaseURL string
+	client        *http.Client
+}
+
+// NewNotifier returns a notifier for the env channels and, when queryRow
+// isn't nil, those stored in chat_channels; links to reports start with
+// reportBaseURL (the frontend)
+func NewNotifier(queryRow database.QueryRowFunc, env []Channel, reportBaseURL string) *Notifier {
+	return &Notifier{
+		queryRow:      queryRow,
+		env:           env,
+		reportBaseURL: strings.TrimRight(reportBaseURL, "/"),
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// channels returns the env channels and the enabled stored ones
+func (n *Notifier) channels(ctx context.Context) ([]Channel, error) {
+	channels := append([]Channel{}, n.env...)
+	if n.queryRow == nil {
+		return channels, nil
+	}
+
+	// Aggregated as JSON, so that database/sql drivers can read the arrays
+	var raw []byte
+	err := n.queryRow(ctx, `
+		SELECT COALESCE(json_agg(json_build_object(
+			'id', id, 'name', name, 'type', type, 'webhook_url', webhook_url,
+			'severities', severities, 'tools', tools, 'enabled', enabled
+		)), '[]') FROM chat_channels WHERE enabled
+	`).Scan(&raw)
+	if err != nil {
+		return channels, err
+	}
+	var stored []Channel
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return channels, err
+	}
+	return append(channels, stored...), nil
+}
+
+// Notify posts a summary to every matching channel in the background;
+// failures are logged, never returned, so chat outages can't break scanning
+func (n *Notifier) Notify(s Summary) {
+	if n == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		channels, err := n.channels(ctx)
+		if err != nil {
+			log.Printf("⚠️ Failed to load chat channels: %v", err)
+		}
+		for _, c := range channels {
+			if !c.Matches(s) {
+				continue
+			}
+			if err := n.Send(ctx, c, s); err != nil {
+				log.Printf("⚠️ Failed to post scan %s to %s channel %s: %v", s.ScanID, c.Type, c.Name, err)
+			}
+		}
+	}()
+}
+
+// severityColors are the colors of the most severe finding of a summary
+var severityColors = map[string]int{
+	models.SeverityCritical: 0x8B0000,
+	models.SeverityHigh:     0xE53E3E,
+	models.SeverityMedium:   0xDD6B20,
+	models.SeverityLow:      0xD69E2E,
+	models.SeverityInfo:     0x3182CE,
+}
+
+// Send posts a summary to a channel, whatever its severities and tools
+func (n *Notifier) Send(ctx context.Context, c Channel, s Summary) error {
+	title := fmt.Sprintf("%s found %s in %s", s.Tool, findingsText(s.Counts), s.ScanName)
+	link := ""
+	if n.reportBaseURL != "" && s.ReportPath != "" {
+		link = n.reportBaseURL + s.ReportPath
+	}
+	color := severityColors[models.SeverityInfo]
+	for i := len(models.Severities) - 1; i >= 0; i-- {
+		if s.Counts[models.Severities[i]] > 0 {
+			color = severityColors[models.Severities[i]]
+		}
+	}
+
+	var body interface{}
+	switch c.Type {
+	case Slack:
+		fields := []map[string]interface{}{{"title": "Target", "value": s.Target, "short": false}}
+		for _, severity := range models.Severities {
+			if count := s.Counts[severity]; count > 0 {
+				fields = append(fields, map[string]interface{}{"title": capitalize(severity), "value": strconv.Itoa(count), "short": true})
+			}
+		}
+		attachment := map[string]interface{}{
+			"fallback": title,
+			"color":    fmt.Sprintf("#%06X", color),
+			"title":    title,
+			"fields":   fields,
+			"footer":   s.Service + " · scan " + s.ScanID,
+		}
+		if link != "" {
+			attachment["title_link"] = link
+		}
+		body = map[string]interface{}{"text": title, "attachments": []interface{}{attachment}}
+	case Discord:
+		fields := []map[string]interface{}{{"name": "Target", "value": s.Target, "inline": false}}
+		for _, severity := range models.Severities {
+			if count := s.Counts[severity]; count > 0 {
+				fields = append(fields, map[string]interface{}{"name": capitalize(severity), "value": strconv.Itoa(count), "inline": true})
+			}
+		}
+		embed := map[string]interface{}{
+			"title":  title,
+			"color":  color,
+			"fields": fields,
+			"footer": map[string]string{"text": s.Service + " · scan " + s.ScanID},
+		}
+		if link != "" {
+			embed["url"] = link
+		}
+		body = map[string]interface{}{"username": "Security Scanner", "embeds": []interface{}{embed}}
+	default:
+		return fmt.Errorf("unsupported channel type: %s", c.Type)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// findingsText returns e.g. "2 critical and 5 high findings"
+func findingsText(counts map[string]int) string {
+	var parts []string
+	total := 0
+	for _, severity := range []string{models.SeverityCritical, models.SeverityHigh} {
+		if count := counts[severity]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, severity))
+			total += count
+		}
+	}
+	if len(parts) == 0 {
+		for _, severity := range models.Severities {
+			total += counts[severity]
+		}
+		return fmt.Sprintf("%d findings", total)
+	}
+	noun := " findings"
+	if total == 1 {
+		noun = " finding"
+	}
+	return strings.Join(parts, " and ") + noun
+}
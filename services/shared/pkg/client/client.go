@@ -0,0 +1,152 @@
+// Package client has typed clients for the APIs of the scanner services.
+// They can call the services directly (signing requests when the services
+// require INTERNAL_AUTH_SECRET) or the gateway, with the gateway's paths.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// InternalTokenHeader carries the gateway token services check when
+// INTERNAL_AUTH_SECRET is set
+const InternalTokenHeader = "X-Internal-Token"
+
+// Signer returns the internal token for a request
+type Signer func(method, path string) (string, error)
+
+// Options configure a client
+type Options struct {
+	HTTPClient *http.Client // defaults to a client with a 30s timeout
+	Sign       Signer       // signs requests to services requiring INTERNAL_AUTH_SECRET
+	Header     http.Header  // sent with every request, e.g. Authorization for the gateway
+}
+
+// Error is a non-2xx response; Code and RequestID come from the error
+// envelope (see pkg/apierror)
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls one service
+type Client struct {
+	baseURL string
+	http    *http.Client
+	sign    Signer
+	header  http.Header
+}
+
+// New creates a client for the service at baseURL (e.g. http://web-service:8002)
+func New(baseURL string, opts Options) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    opts.HTTPClient,
+		sign:    opts.Sign,
+		header:  opts.Header,
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: 30 * time.Second}
+	}
+	return c
+}
+
+// Do sends a request with body encoded as JSON and decodes the response into
+// out. body and out may be nil.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range c.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.sign != nil {
+		token, err := c.sign(method, req.URL.Path)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(InternalTokenHeader, token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var e struct {
+			Error     string `json:"error"`
+			Code      string `json:"code"`
+			RequestID string `json:"request_id"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		return &Error{StatusCode: resp.StatusCode, Code: e.Code, Message: e.Error, RequestID: e.RequestID}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// InternalSigner signs requests like the gateway does: an HS256 JWT with
+// purpose "internal" for "<METHOD> <path>", valid for a minute
+func InternalSigner(secret string) Signer {
+	return func(method, path string) (string, error) {
+		now := time.Now()
+		payload, err := json.Marshal(map[string]interface{}{
+			"sub":     "client",
+			"purpose": "internal",
+			"data":    method + " " + path,
+			"iat":     now.Unix(),
+			"exp":     now.Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			return "", err
+		}
+		unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(unsigned))
+		return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+	}
+}
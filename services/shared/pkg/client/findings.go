@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/security-scanner/shared/pkg/models"
+)
+
+// Findings are the normalized findings of a service's scans. Every service
+// filters them by ?severity= (comma-separated), ?target= (substring) and
+// ?scan_id=, orders them most severe first and newest first within a
+// severity, and returns at most ?limit= of them.
+type Findings struct {
+	c    *Client
+	path string // e.g. /api/cloudscans/findings
+}
+
+// List returns the findings matching query and the total that matched
+func (f *Findings) List(ctx context.Context, query url.Values) (*models.FindingList, error) {
+	list := &models.FindingList{Findings: []models.Finding{}}
+	if err := f.c.Do(ctx, http.MethodGet, f.path, query, nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
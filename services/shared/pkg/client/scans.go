@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+)
+
+// Scans is a scan collection: every service serves list, get, create,
+// cancel, delete, results and logs under the same paths
+type Scans struct {
+	c    *Client
+	path string // e.g. /api/recon
+}
+
+// List returns a page of scans, filtered by query (e.g. status=running,
+// page=2, sort=-created_at)
+func (s *Scans) List(ctx context.Context, query url.Values) (*pagination.Page[models.Scan], error) {
+	var raw json.RawMessage
+	if err := s.c.Do(ctx, http.MethodGet, s.path, query, nil, &raw); err != nil {
+		return nil, err
+	}
+	// Services older than the paginated lists answer with a bare array
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		scans := []models.Scan{}
+		if err := json.Unmarshal(trimmed, &scans); err != nil {
+			return nil, err
+		}
+		return &pagination.Page[models.Scan]{Items: scans, Total: len(scans), Page: 1, Pages: 1, Limit: len(scans)}, nil
+	}
+	page := &pagination.Page[models.Scan]{}
+	if err := json.Unmarshal(raw, page); err != nil {
+		return nil, err
+	}
+	if page.Items == nil {
+		page.Items = []models.Scan{}
+	}
+	return page, nil
+}
+
+func (s *Scans) Get(ctx context.Context, id uuid.UUID) (*models.Scan, error) {
+	var scan models.Scan
+	if err := s.c.Do(ctx, http.MethodGet, s.path+"/"+id.String(), nil, nil, &scan); err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// Create creates a scan from the service's create request
+func (s *Scans) Create(ctx context.Context, req interface{}) (*models.Scan, error) {
+	return s.create(ctx, s.path, req)
+}
+
+func (s *Scans) create(ctx context.Context, path string, req interface{}) (*models.Scan, error) {
+	var scan models.Scan
+	if err := s.c.Do(ctx, http.MethodPost, path, nil, req, &scan); err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (s *Scans) Cancel(ctx context.Context, id uuid.UUID) error {
+	return s.c.Do(ctx, http.MethodPost, s.path+"/"+id.String()+"/cancel", nil, nil, nil)
+}
+
+func (s *Scans) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.c.Do(ctx, http.MethodDelete, s.path+"/"+id.String(), nil, nil, nil)
+}
+
+func (s *Scans) Logs(ctx context.Context, id uuid.UUID) ([]models.ScanLog, error) {
+	logs := []models.ScanLog{}
+	err := s.c.Do(ctx, http.MethodGet, s.path+"/"+id.String()+"/logs", nil, nil, &logs)
+	return logs, err
+}
+
+// Results decodes the results of a scan into out, whose type depends on the
+// service
+func (s *Scans) Results(ctx context.Context, id uuid.UUID, out interface{}) error {
+	return s.c.Do(ctx, http.MethodGet, s.path+"/"+id.String()+"/results", nil, nil, out)
+}
+
+// WebScans are created per tool (ffuf, gowitness, testssl, credcheck, sqlmap)
+type WebScans struct {
+	Scans
+}
+
+// Create creates a scan with tool
+func (s *WebScans) Create(ctx context.Context, tool string, req interface{}) (*models.Scan, error) {
+	return s.create(ctx, s.path+"/"+url.PathEscape(tool), req)
+}
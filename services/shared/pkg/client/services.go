@@ -0,0 +1,89 @@
+package client
+
+// Network is the network service (nmap, masscan, native, dns)
+type Network struct {
+	*Client
+	Scans    *Scans
+	Findings *Findings // NSE vulnerability scripts
+}
+
+func NewNetwork(baseURL string, opts Options) *Network {
+	c := New(baseURL, opts)
+	return &Network{
+		Client:   c,
+		Scans:    &Scans{c: c, path: "/api/scans"},
+		Findings: &Findings{c: c, path: "/api/scans/findings"},
+	}
+}
+
+// Web is the web service (nuclei and the web scanning tools)
+type Web struct {
+	*Client
+	Vulnerabilities *Scans
+	WebScans        *WebScans
+	Findings        *Findings // nuclei
+}
+
+func NewWeb(baseURL string, opts Options) *Web {
+	c := New(baseURL, opts)
+	return &Web{
+		Client:          c,
+		Vulnerabilities: &Scans{c: c, path: "/api/vulnerabilities"},
+		WebScans:        &WebScans{Scans{c: c, path: "/api/webscans"}},
+		Findings:        &Findings{c: c, path: "/api/vulnerabilities/findings"},
+	}
+}
+
+// Recon is the recon service
+type Recon struct {
+	*Client
+	Scans *Scans
+}
+
+func NewRecon(baseURL string, opts Options) *Recon {
+	c := New(baseURL, opts)
+	return &Recon{Client: c, Scans: &Scans{c: c, path: "/api/recon"}}
+}
+
+// API is the API discovery service
+type API struct {
+	*Client
+	Scans *Scans
+}
+
+func NewAPI(baseURL string, opts Options) *API {
+	c := New(baseURL, opts)
+	return &API{Client: c, Scans: &Scans{c: c, path: "/api/apiscans"}}
+}
+
+// CMS is the CMS detection service
+type CMS struct {
+	*Client
+	Scans    *Scans
+	Findings *Findings // wpscan
+}
+
+func NewCMS(baseURL string, opts Options) *CMS {
+	c := New(baseURL, opts)
+	return &CMS{
+		Client:   c,
+		Scans:    &Scans{c: c, path: "/api/cmsscans"},
+		Findings: &Findings{c: c, path: "/api/cmsscans/findings"},
+	}
+}
+
+// Cloud is the cloud security service
+type Cloud struct {
+	*Client
+	Scans    *Scans
+	Findings *Findings // prowler, scoutsuite, buckets and trivy
+}
+
+func NewCloud(baseURL string, opts Options) *Cloud {
+	c := New(baseURL, opts)
+	return &Cloud{
+		Client:   c,
+		Scans:    &Scans{c: c, path: "/api/cloudscans"},
+		Findings: &Findings{c: c, path: "/api/cloudscans/findings"},
+	}
+}
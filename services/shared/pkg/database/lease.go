@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LeaseSchemaSQL creates the table of job leases, shared by every service
+const LeaseSchemaSQL = `
+CREATE TABLE IF NOT EXISTS job_leases (
+    job VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+`
+
+// Row is a single-row query result; pgx.Row and *sql.Row both fit
+type Row interface {
+	Scan(dest ...interface{}) error
+}
+
+// QueryRowFunc runs a query returning one row on the service's database
+type QueryRowFunc func(ctx context.Context, query string, args ...interface{}) Row
+
+// Leases elect the replica that runs each background job (schedulers,
+// retention and reconcilers). A job's lease belongs to one replica until it
+// expires and is renewed each time its holder runs the job, so with several
+// replicas every job runs on exactly one of them, and moves to another
+// replica once its holder stops renewing it.
+type Leases struct {
+	queryRow QueryRowFunc
+	holder   string
+
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+// NewLeases returns the leases of this process, identified by the host name
+// (the pod name on Kubernetes), the PID and a random suffix
+func NewLeases(queryRow QueryRowFunc) *Leases {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Leases{
+		queryRow: queryRow,
+		holder:   fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		held:     map[string]bool{},
+	}
+}
+
+// Holder returns the ID this process holds leases as
+func (l *Leases) Holder() string {
+	if l == nil {
+		return ""
+	}
+	return l.holder
+}
+
+// Acquire takes or renews the lease on job for ttl and reports whether this
+// process holds it. ttl must outlast the job's interval for the holder to
+// keep it between runs. A nil Leases holds every job, and a database error
+// holds none, so that no job runs twice.
+func (l *Leases) Acquire(ctx context.Context, job string, ttl time.Duration) bool {
+	if l == nil {
+		return true
+	}
+	var taken int
+	err := l.queryRow(ctx, `
+		WITH taken AS (
+			INSERT INTO job_leases (job, holder, expires_at)
+			VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+			ON CONFLICT (job) DO UPDATE SET
+				holder = EXCLUDED.holder,
+				expires_at = EXCLUDED.expires_at,
+				acquired_at = CASE WHEN job_leases.holder = EXCLUDED.holder THEN job_leases.acquired_at ELSE NOW() END
+			WHERE job_leases.holder = EXCLUDED.holder OR job_leases.expires_at < NOW()
+			RETURNING job
+		)
+		SELECT COUNT(*) FROM taken`, job, l.holder, ttl.Seconds()).Scan(&taken)
+	if err != nil {
+		log.Printf("Failed to acquire lease on %s: %v", job, err)
+		taken = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case taken > 0 && !l.held[job]:
+		log.Printf("👑 This replica now runs %s", job)
+	case taken == 0 && l.held[job]:
+		log.Printf("👑 %s moved to another replica", job)
+	}
+	l.held[job] = taken > 0
+	return taken > 0
+}
+
+// Release gives up the lease on job, if held, so another replica may take
+// it right away
+func (l *Leases) Release(ctx context.Context, job string) {
+	if l == nil {
+		return
+	}
+	var released int
+	err := l.queryRow(ctx, `
+		WITH released AS (
+			DELETE FROM job_leases WHERE job = $1 AND holder = $2 RETURNING job
+		)
+		SELECT COUNT(*) FROM released`, job, l.holder).Scan(&released)
+	if err != nil {
+		log.Printf("Failed to release lease on %s: %v", job, err)
+		return
+	}
+	l.mu.Lock()
+	delete(l.held, job)
+	l.mu.Unlock()
+}
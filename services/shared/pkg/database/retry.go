@@ -0,0 +1,58 @@
+// Package database holds the connection retry every service runs at startup,
+// when PostgreSQL may still be starting.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	maxAttempts = 10
+	maxWait     = 30 * time.Second
+)
+
+// Retry calls connect until it succeeds, waiting 1s, 2s, 4s... (at most
+// 30s) between attempts, and gives up after 10 attempts
+func Retry(connect func() error) error {
+	var err error
+	for i := 0; i < maxAttempts; i++ {
+		if err = connect(); err == nil {
+			return nil
+		}
+		if i == maxAttempts-1 {
+			break
+		}
+		wait := time.Duration(1<<uint(i)) * time.Second
+		if wait > maxWait {
+			wait = maxWait
+		}
+		log.Printf("Failed to connect to database (attempt %d/%d): %v. Retrying in %v...", i+1, maxAttempts, err, wait)
+		time.Sleep(wait)
+	}
+	return fmt.Errorf("failed to connect to database after %d attempts: %w", maxAttempts, err)
+}
+
+// OpenSQL opens and pings a database/sql connection, retrying while the
+// database is unavailable. The driver must be registered by the caller.
+func OpenSQL(driver, dsn string) (*sql.DB, error) {
+	var db *sql.DB
+	err := Retry(func() error {
+		conn, err := sql.Open(driver, dsn)
+		if err != nil {
+			return err
+		}
+		if err := conn.Ping(); err != nil {
+			conn.Close()
+			return err
+		}
+		db = conn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,112 @@
+// Package dnswildcard detects wildcard DNS records, which make every name
+// under a domain resolve and so turn subdomain brute forcing and passive
+// enumeration results into noise. A parent domain has a wildcard when
+// random labels under it resolve; a name under it is a wildcard match when
+// it resolves only to the addresses those random labels get.
+package dnswildcard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// probes is how many random labels are resolved per parent; wildcards
+// served round-robin answer each with a different subset of addresses
+const probes = 3
+
+// Resolver resolves names; *net.Resolver implements it
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// Detector probes each parent domain once and remembers the answer
+type Detector struct {
+	resolver Resolver
+	mu       sync.Mutex
+	parents  map[string]map[string]bool // parent -> wildcard addresses, nil when it has none
+}
+
+// New returns a detector using resolver, or net.DefaultResolver when nil
+func New(resolver Resolver) *Detector {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &Detector{resolver: resolver, parents: map[string]map[string]bool{}}
+}
+
+// Addresses returns the addresses random names under parent resolve to,
+// sorted, or nil when parent has no wildcard
+func (d *Detector) Addresses(ctx context.Context, parent string) []string {
+	var addrs []string
+	for addr := range d.probe(ctx, parent) {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Match reports the wildcard ("*.parent") that answered for name when all
+// of ips are addresses of the wildcard of name's parent, and "" when name
+// has records of its own or its parent has no wildcard
+func (d *Detector) Match(ctx context.Context, name string, ips []string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	_, parent, ok := strings.Cut(name, ".")
+	if !ok || len(ips) == 0 || !strings.Contains(parent, ".") {
+		return ""
+	}
+	wildcard := d.probe(ctx, parent)
+	if wildcard == nil {
+		return ""
+	}
+	for _, ip := range ips {
+		if !wildcard[ip] {
+			return ""
+		}
+	}
+	return "*." + parent
+}
+
+func (d *Detector) probe(ctx context.Context, parent string) map[string]bool {
+	parent = strings.TrimSuffix(strings.ToLower(parent), ".")
+
+	d.mu.Lock()
+	addrs, done := d.parents[parent]
+	d.mu.Unlock()
+	if done {
+		return addrs
+	}
+
+	for i := 0; i < probes; i++ {
+		ips, err := d.resolver.LookupIP(ctx, "ip", randomLabel()+"."+parent)
+		if err != nil || len(ips) == 0 {
+			continue
+		}
+		if addrs == nil {
+			addrs = map[string]bool{}
+		}
+		for _, ip := range ips {
+			addrs[ip.String()] = true
+		}
+	}
+	if ctx.Err() != nil {
+		// Don't remember an answer cut short by cancellation
+		return addrs
+	}
+
+	d.mu.Lock()
+	d.parents[parent] = addrs
+	d.mu.Unlock()
+	return addrs
+}
+
+// randomLabel is a label no real zone has a record for
+func randomLabel() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return "wc-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,331 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConnectorConfig configures one SIEM connector. Connectors are declared per
+// tenant; the tenant is stamped on every record so a shared SIEM can separate them.
+type ConnectorConfig struct {
+	Tenant string `json:"tenant"`
+	Type   string `json:"type"` // splunk or sentinel
+
+	// Splunk HTTP Event Collector
+	URL        string `json:"url,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Index      string `json:"index,omitempty"`
+	SourceType string `json:"sourcetype,omitempty"`
+
+	// Microsoft Sentinel (Log Analytics HTTP Data Collector API)
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	SharedKey   string `json:"shared_key,omitempty"`
+	LogType     string `json:"log_type,omitempty"`
+
+	// Delivery
+	BatchSize     int `json:"batch_size,omitempty"`
+	FlushInterval int `json:"flush_interval,omitempty"` // seconds
+	MaxRetries    int `json:"max_retries,omitempty"`
+}
+
+// connectorRecord is the flattened record shipped to a SIEM
+type connectorRecord struct {
+	Tenant        string          `json:"tenant"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	SchemaVersion string          `json:"schema_version"`
+	Source        string          `json:"source"`
+	Time          time.Time       `json:"time"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// LoadConnectors parses the JSON connector list (SIEM_CONNECTORS) into publishers
+func LoadConnectors(raw string) ([]Publisher, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var configs []ConnectorConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("invalid SIEM connector configuration: %w", err)
+	}
+
+	publishers := []Publisher{}
+	for _, cfg := range configs {
+		if cfg.Tenant == "" {
+			cfg.Tenant = "default"
+		}
+
+		var send func(ctx context.Context, records []connectorRecord) error
+		switch strings.ToLower(cfg.Type) {
+		case "splunk":
+			if cfg.URL == "" || cfg.Token == "" {
+				return nil, fmt.Errorf("splunk connector for tenant %s requires url and token", cfg.Tenant)
+			}
+			send = newSplunkSender(cfg)
+		case "sentinel":
+			if cfg.WorkspaceID == "" || cfg.SharedKey == "" {
+				return nil, fmt.Errorf("sentinel connector for tenant %s requires workspace_id and shared_key", cfg.Tenant)
+			}
+			sender, err := newSentinelSender(cfg)
+			if err != nil {
+				return nil, err
+			}
+			send = sender
+		default:
+			return nil, fmt.Errorf("unsupported SIEM connector type: %s", cfg.Type)
+		}
+
+		log.Printf("🛰️ SIEM connector enabled: %s for tenant %s", cfg.Type, cfg.Tenant)
+		publishers = append(publishers, newBatchPublisher(cfg, send))
+	}
+	return publishers, nil
+}
+
+// batchPublisher buffers scan summaries and findings and flushes them when the
+// batch is full or the flush interval elapses, retrying with backoff
+type batchPublisher struct {
+	name       string
+	tenant     string
+	batchSize  int
+	maxRetries int
+	send       func(ctx context.Context, records []connectorRecord) error
+
+	mu     sync.Mutex
+	buffer []connectorRecord
+	flush  chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newBatchPublisher(cfg ConnectorConfig, send func(ctx context.Context, records []connectorRecord) error) *batchPublisher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+
+	p := &batchPublisher{
+		name:       cfg.Type + "/" + cfg.Tenant,
+		tenant:     cfg.Tenant,
+		batchSize:  cfg.BatchSize,
+		maxRetries: cfg.MaxRetries,
+		send:       send,
+		flush:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go p.run(time.Duration(cfg.FlushInterval) * time.Second)
+	return p
+}
+
+// Publish buffers completed scans, failed scans and findings
+func (p *batchPublisher) Publish(ctx context.Context, subject, key string, payload []byte) error {
+	var event struct {
+		SchemaVersion string          `json:"schema_version"`
+		ID            string          `json:"id"`
+		Type          string          `json:"type"`
+		Source        string          `json:"source"`
+		Time          time.Time       `json:"time"`
+		Data          json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	if event.Type != ScanCompleted && event.Type != ScanFailed && event.Type != FindingCreated {
+		return nil
+	}
+
+	p.mu.Lock()
+	p.buffer = append(p.buffer, connectorRecord{
+		Tenant:        p.tenant,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		SchemaVersion: event.SchemaVersion,
+		Source:        event.Source,
+		Time:          event.Time,
+		Data:          event.Data,
+	})
+	full := len(p.buffer) >= p.batchSize
+	p.mu.Unlock()
+
+	if full {
+		select {
+		case p.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (p *batchPublisher) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.flushAll()
+		case <-p.flush:
+			p.flushAll()
+		case <-p.stop:
+			p.flushAll()
+			return
+		}
+	}
+}
+
+func (p *batchPublisher) flushAll() {
+	for {
+		p.mu.Lock()
+		if len(p.buffer) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		n := len(p.buffer)
+		if n > p.batchSize {
+			n = p.batchSize
+		}
+		batch := p.buffer[:n]
+		p.buffer = p.buffer[n:]
+		p.mu.Unlock()
+
+		if err := p.sendWithRetry(batch); err != nil {
+			log.Printf("❌ SIEM connector %s dropped %d records: %v", p.name, len(batch), err)
+		}
+	}
+}
+
+func (p *batchPublisher) sendWithRetry(batch []connectorRecord) error {
+	var err error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = p.send(ctx, batch)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("⚠️ SIEM connector %s attempt %d/%d failed: %v", p.name, attempt+1, p.maxRetries+1, err)
+	}
+	return err
+}
+
+// Close flushes buffered records
+func (p *batchPublisher) Close() error {
+	close(p.stop)
+	<-p.done
+	return nil
+}
+
+// newSplunkSender posts records to the Splunk HTTP Event Collector
+func newSplunkSender(cfg ConnectorConfig) func(ctx context.Context, records []connectorRecord) error {
+	endpoint := strings.TrimRight(cfg.URL, "/")
+	if !strings.Contains(endpoint, "/services/collector") {
+		endpoint += "/services/collector/event"
+	}
+	sourceType := cfg.SourceType
+	if sourceType == "" {
+		sourceType = "security_scanner"
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	return func(ctx context.Context, records []connectorRecord) error {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, record := range records {
+			hecEvent := map[string]interface{}{
+				"time":       float64(record.Time.UnixNano()) / 1e9,
+				"source":     record.Source,
+				"sourcetype": sourceType + ":" + strings.ReplaceAll(record.EventType, ".", "_"),
+				"event":      record,
+			}
+			if cfg.Index != "" {
+				hecEvent["index"] = cfg.Index
+			}
+			if err := enc.Encode(hecEvent); err != nil {
+				return err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &buf)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Splunk "+cfg.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		return doConnectorRequest(client, req)
+	}
+}
+
+// newSentinelSender posts records to the Log Analytics HTTP Data Collector API
+func newSentinelSender(cfg ConnectorConfig) (func(ctx context.Context, records []connectorRecord) error, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.SharedKey)
+	if err != nil {
+		return nil, fmt.Errorf("sentinel shared_key for tenant %s is not valid base64: %w", cfg.Tenant, err)
+	}
+	logType := cfg.LogType
+	if logType == "" {
+		logType = "SecurityScanner"
+	}
+	endpoint := fmt.Sprintf("https://%s.ods.opinsights.azure.com/api/logs?api-version=2016-04-01", cfg.WorkspaceID)
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	return func(ctx context.Context, records []connectorRecord) error {
+		body, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+
+		date := time.Now().UTC().Format(http.TimeFormat)
+		stringToSign := fmt.Sprintf("POST\n%d\napplication/json\nx-ms-date:%s\n/api/logs", len(body), date)
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(stringToSign))
+		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Log-Type", logType)
+		req.Header.Set("x-ms-date", date)
+		req.Header.Set("time-generated-field", "time")
+		req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", cfg.WorkspaceID, signature))
+
+		return doConnectorRequest(client, req)
+	}, nil
+}
+
+func doConnectorRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
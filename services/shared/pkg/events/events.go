@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/owners"
+)
+
+// SchemaVersion is bumped whenever the envelope or a payload changes incompatibly
+const SchemaVersion = "1.0"
+
+// Event types
+const (
+	ScanCreated    = "scan.created"
+	ScanStarted    = "scan.started"
+	ScanCompleted  = "scan.completed"
+	ScanFailed     = "scan.failed"
+	FindingCreated = "finding.created"
+)
+
+// Event is the versioned envelope published for every lifecycle event
+type Event struct {
+	SchemaVersion string      `json:"schema_version"`
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	Source        string      `json:"source"`
+	Time          time.Time   `json:"time"`
+	Data          interface{} `json:"data"`
+}
+
+// ScanData is the payload of scan.* events
+type ScanData struct {
+	ScanID   string         `json:"scan_id"`
+	Name     string         `json:"name"`
+	Target   string         `json:"target"`
+	ScanType string         `json:"scan_type"`
+	Scanner  string         `json:"scanner"`
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Summary  map[string]int `json:"summary,omitempty"` // set on scan.completed
+}
+
+// FindingData is the payload of finding.created events
+type FindingData struct {
+	ScanID     string `json:"scan_id"`
+	Scanner    string `json:"scanner"`
+	Host       string `json:"host"`
+	Port       int    `json:"port,omitempty"`
+	Protocol   string `json:"protocol,omitempty"`
+	Service    string `json:"service,omitempty"`
+	Title      string `json:"title"`
+	Severity   string `json:"severity"`
+	TemplateID string `json:"template_id,omitempty"`
+	MatchedAt  string `json:"matched_at,omitempty"`
+	// Tags and Criticality of the host, from the asset tag rules
+	Tags        []string `json:"tags,omitempty"`
+	Criticality string   `json:"criticality,omitempty"`
+	// Owner of the host, so the finding can be routed to the team that fixes it
+	Owner *owners.Owner `json:"owner,omitempty"`
+}
+
+// Publisher delivers a serialized event to a broker or SIEM
+type Publisher interface {
+	Publish(ctx context.Context, subject, key string, payload []byte) error
+	Close() error
+}
+
+// Bus publishes lifecycle events. A nil Bus is valid and drops every event,
+// so callers don't need to check whether any publisher is configured.
+type Bus struct {
+	publishers []Publisher
+	source     string
+	prefix     string
+	queue      chan message
+	done       chan struct{}
+
+	// closed is set under mu before the queue is closed, so Publish never
+	// sends on a closed channel
+	mu     sync.RWMutex
+	closed bool
+}
+
+type message struct {
+	eventType string
+	subject   string
+	key       string
+	payload   []byte
+}
+
+// NewBrokerPublisher creates the publisher for a message broker ("nats" or "kafka").
+// An empty broker returns a nil Publisher.
+func NewBrokerPublisher(broker, brokerURL, topic, source string) (Publisher, error) {
+	switch strings.ToLower(broker) {
+	case "":
+		return nil, nil
+	case "nats":
+		publisher, err := NewNATSPublisher(brokerURL, source)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("📡 Publishing scan events to NATS (%s)", brokerURL)
+		return publisher, nil
+	case "kafka":
+		log.Printf("📡 Publishing scan events to Kafka REST proxy (%s)", brokerURL)
+		return NewKafkaRESTPublisher(brokerURL, topic), nil
+	default:
+		return nil, fmt.Errorf("unsupported event broker: %s", broker)
+	}
+}
+
+// NewBus creates an event bus that delivers every event to all publishers.
+// Without publishers it returns a nil Bus.
+func NewBus(source, prefix string, publishers ...Publisher) *Bus {
+	if len(publishers) == 0 {
+		return nil
+	}
+	if prefix == "" {
+		prefix = "scanner"
+	}
+
+	bus := &Bus{
+		publishers: publishers,
+		source:     source,
+		prefix:     prefix,
+		queue:      make(chan message, 1000),
+		done:       make(chan struct{}),
+	}
+	go bus.run()
+
+	return bus
+}
+
+// run delivers queued events one at a time so consumers see them in order
+func (b *Bus) run() {
+	defer close(b.done)
+	for msg := range b.queue {
+		for _, publisher := range b.publishers {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := publisher.Publish(ctx, msg.subject, msg.key, msg.payload); err != nil {
+				log.Printf("Failed to publish %s event: %v", msg.eventType, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Publish queues an event for delivery; failures are logged, never returned,
+// so a broker outage can't break scanning
+func (b *Bus) Publish(eventType string, key string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		SchemaVersion: SchemaVersion,
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		Source:        b.source,
+		Time:          time.Now().UTC(),
+		Data:          data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		log.Printf("Event bus closed, dropping %s event", eventType)
+		return
+	}
+	select {
+	case b.queue <- message{eventType: eventType, subject: b.prefix + "." + eventType, key: key, payload: payload}:
+	default:
+		log.Printf("Event queue full, dropping %s event", eventType)
+	}
+}
+
+// Close flushes queued events and releases the broker connection. Events
+// published afterwards, e.g. by scans still running at shutdown, are dropped.
+func (b *Bus) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+	<-b.done
+
+	var firstErr error
+	for _, publisher := range b.publishers {
+		if err := publisher.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaRESTPublisher produces records through a Kafka REST Proxy (v2 API).
+// All events go to a single topic keyed by scan ID so consumers keep per-scan
+// ordering; the event type is carried in the envelope.
+type KafkaRESTPublisher struct {
+	url    string
+	topic  string
+	client *http.Client
+}
+
+func NewKafkaRESTPublisher(proxyURL, topic string) *KafkaRESTPublisher {
+	if topic == "" {
+		topic = "scanner.events"
+	}
+	return &KafkaRESTPublisher{
+		url:    strings.TrimRight(proxyURL, "/"),
+		topic:  topic,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *KafkaRESTPublisher) Publish(ctx context.Context, subject, key string, payload []byte) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{
+			{"key": key, "value": json.RawMessage(payload)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/topics/%s", p.url, url.PathEscape(p.topic))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("kafka rest proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func (p *KafkaRESTPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSPublisher speaks the NATS client text protocol (CONNECT/PUB/PING/PONG)
+// directly over TCP and reconnects lazily after a failure
+type NATSPublisher struct {
+	addr  string
+	user  string
+	pass  string
+	token string
+	name  string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	writer *bufio.Writer
+}
+
+func NewNATSPublisher(brokerURL, name string) (*NATSPublisher, error) {
+	if !strings.Contains(brokerURL, "://") {
+		brokerURL = "nats://" + brokerURL
+	}
+	parsed, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+
+	addr := parsed.Host
+	if parsed.Port() == "" {
+		addr = net.JoinHostPort(parsed.Hostname(), "4222")
+	}
+
+	p := &NATSPublisher{addr: addr, name: name}
+	if parsed.User != nil {
+		if pass, ok := parsed.User.Password(); ok {
+			p.user = parsed.User.Username()
+			p.pass = pass
+		} else {
+			p.token = parsed.User.Username()
+		}
+	}
+	return p, nil
+}
+
+func (p *NATSPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting: %q", strings.TrimSpace(info))
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     p.name,
+		"lang":     "go",
+		"version":  "1.0.0",
+	}
+	if p.user != "" {
+		options["user"] = p.user
+		options["pass"] = p.pass
+	}
+	if p.token != "" {
+		options["auth_token"] = p.token
+	}
+	connectJSON, _ := json.Marshal(options)
+
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "CONNECT %s\r\nPING\r\n", connectJSON)
+	if err := writer.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// The server answers PONG once CONNECT is accepted, or -ERR otherwise
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(reply, "PONG") {
+		conn.Close()
+		return fmt.Errorf("NATS connect rejected: %q", strings.TrimSpace(reply))
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	p.conn = conn
+	p.writer = writer
+
+	// Keep answering server PINGs so the connection isn't dropped as stale
+	go p.readLoop(conn, reader)
+	return nil
+}
+
+func (p *NATSPublisher) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			p.mu.Lock()
+			if p.conn == conn {
+				p.conn.Close()
+				p.conn = nil
+			}
+			p.mu.Unlock()
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			p.mu.Lock()
+			// A failed write stays in the bufio.Writer and would fail every
+			// later Publish, so the connection is dropped and redialed instead
+			if p.conn == conn {
+				conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+				p.writer.WriteString("PONG\r\n")
+				if err := p.writer.Flush(); err != nil {
+					p.conn.Close()
+					p.conn = nil
+				} else {
+					conn.SetWriteDeadline(time.Time{})
+				}
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Publish sends a message to the subject; the key is unused by NATS
+func (p *NATSPublisher) Publish(ctx context.Context, subject, key string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		p.conn.SetWriteDeadline(deadline)
+	}
+	fmt.Fprintf(p.writer, "PUB %s %d\r\n", subject, len(payload))
+	p.writer.Write(payload)
+	p.writer.WriteString("\r\n")
+	if err := p.writer.Flush(); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	// The deadline belongs to this ctx; later writes set their own
+	p.conn.SetWriteDeadline(time.Time{})
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
@@ -0,0 +1,355 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Notification modes
+const (
+	NotifyImmediate = "immediate"
+	NotifyHourly    = "hourly"
+	NotifyDaily     = "daily"
+)
+
+// digestListLimit caps the findings listed in one digest; the counts still cover all of them
+const digestListLimit = 200
+
+// NotifierConfig configures webhook notifications about findings
+type NotifierConfig struct {
+	WebhookURL string
+	Secret     string   // signs the body as X-Scanner-Signature: sha256=<hmac>
+	Mode       string   // immediate, hourly or daily
+	DigestHour int      // UTC hour of daily digests
+	DedupDays  int      // don't re-notify a finding fingerprint within this many days (0 disables)
+	Tags       []string // only notify findings on assets with one of these tags (empty notifies all)
+}
+
+// Notifier sends finding.created events to a webhook, either one request per
+// finding or as hourly/daily digests. Findings whose fingerprint was already
+// notified within the dedup window are skipped, across scans and restarts.
+type Notifier struct {
+	pool   *pgxpool.Pool
+	cfg    NotifierConfig
+	source string
+	client *http.Client
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+const notifierSchemaSQL = `
+CREATE TABLE IF NOT EXISTS notification_fingerprints (
+    fingerprint VARCHAR(64) PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    first_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_notified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    occurrences INTEGER DEFAULT 1
+);
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    finding JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_notification_digest_pending ON notification_digest_items(source, created_at) WHERE sent_at IS NULL`
+
+// NewNotifier creates the notification tables in the service's database and,
+// in digest mode, starts the digest scheduler
+func NewNotifier(pool *pgxpool.Pool, cfg NotifierConfig, source string) (*Notifier, error) {
+	cfg.Mode = strings.ToLower(cfg.Mode)
+	if cfg.Mode == "" {
+		cfg.Mode = NotifyImmediate
+	}
+	if cfg.Mode != NotifyImmediate && cfg.Mode != NotifyHourly && cfg.Mode != NotifyDaily {
+		return nil, fmt.Errorf("unsupported notification mode: %s", cfg.Mode)
+	}
+	if cfg.DigestHour < 0 || cfg.DigestHour > 23 {
+		return nil, fmt.Errorf("invalid digest hour: %d", cfg.DigestHour)
+	}
+
+	if _, err := pool.Exec(context.Background(), notifierSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create notification tables: %w", err)
+	}
+
+	n := &Notifier{
+		pool:   pool,
+		cfg:    cfg,
+		source: source,
+		client: &http.Client{Timeout: 15 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if cfg.Mode == NotifyImmediate {
+		close(n.done)
+	} else {
+		go n.runDigests()
+	}
+	return n, nil
+}
+
+// Fingerprint identifies a finding independently of the scan that found it
+func Fingerprint(f FindingData) string {
+	identity := f.TemplateID
+	if identity == "" {
+		identity = f.Title
+	}
+	parts := []string{f.Scanner, strings.ToLower(f.Host), strconv.Itoa(f.Port), strings.ToLower(f.Protocol), identity}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Publish notifies or queues finding events; other event types are ignored
+func (n *Notifier) Publish(ctx context.Context, subject, key string, payload []byte) error {
+	var event struct {
+		Type string      `json:"type"`
+		Time time.Time   `json:"time"`
+		Data FindingData `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	if event.Type != FindingCreated {
+		return nil
+	}
+	if len(n.cfg.Tags) > 0 && !sharesTag(event.Data.Tags, n.cfg.Tags) {
+		return nil
+	}
+
+	fingerprint := Fingerprint(event.Data)
+	notify, err := n.claim(ctx, fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to check notification dedup: %w", err)
+	}
+	if !notify {
+		return nil
+	}
+
+	if n.cfg.Mode != NotifyImmediate {
+		finding, _ := json.Marshal(event.Data)
+		_, err := n.pool.Exec(ctx, `
+			INSERT INTO notification_digest_items (source, fingerprint, finding) VALUES ($1, $2, $3)
+		`, n.source, fingerprint, finding)
+		return err
+	}
+
+	err = n.post(ctx, map[string]interface{}{
+		"type":        "finding",
+		"source":      n.source,
+		"time":        event.Time,
+		"fingerprint": fingerprint,
+		"finding":     event.Data,
+	})
+	if err != nil && n.cfg.DedupDays > 0 {
+		// Not delivered, so the next sighting must notify again
+		n.pool.Exec(ctx, `UPDATE notification_fingerprints SET last_notified_at = 'epoch' WHERE fingerprint = $1`, fingerprint)
+	}
+	return err
+}
+
+func sharesTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claim records a sighting of fingerprint and reports whether it should be
+// notified, i.e. it is new or was last notified before the dedup window
+func (n *Notifier) claim(ctx context.Context, fingerprint string) (bool, error) {
+	if n.cfg.DedupDays <= 0 {
+		return true, nil
+	}
+
+	// NOW() is fixed for the statement, so last_notified_at = NOW() means
+	// this statement set it
+	var notify bool
+	err := n.pool.QueryRow(ctx, `
+		INSERT INTO notification_fingerprints (fingerprint, source, last_notified_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (fingerprint) DO UPDATE SET
+			occurrences = notification_fingerprints.occurrences + 1,
+			last_notified_at = CASE
+				WHEN notification_fingerprints.last_notified_at < NOW() - make_interval(days => $3) THEN NOW()
+				ELSE notification_fingerprints.last_notified_at
+			END
+		RETURNING last_notified_at = NOW()
+	`, fingerprint, n.source, n.cfg.DedupDays).Scan(&notify)
+	return notify, err
+}
+
+// nextDigest returns when the digest after now is due
+func (n *Notifier) nextDigest(now time.Time) time.Time {
+	now = now.UTC()
+	if n.cfg.Mode == NotifyHourly {
+		return now.Truncate(time.Hour).Add(time.Hour)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), n.cfg.DigestHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (n *Notifier) runDigests() {
+	defer close(n.done)
+	for {
+		next := n.nextDigest(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-n.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := n.sendDigest(ctx, next); err != nil {
+			log.Printf("⚠️ Failed to send %s notification digest (will retry next period): %v", n.cfg.Mode, err)
+		}
+		cancel()
+	}
+}
+
+// sendDigest posts the pending findings as one summary. Rows are locked so
+// that only one replica sends them, and stay pending if the webhook fails.
+func (n *Notifier) sendDigest(ctx context.Context, periodEnd time.Time) error {
+	tx, err := n.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, finding, created_at FROM notification_digest_items
+		WHERE source = $1 AND sent_at IS NULL
+		ORDER BY created_at
+		FOR UPDATE SKIP LOCKED
+	`, n.source)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	var periodStart time.Time
+	findings := []FindingData{}
+	bySeverity := map[string]int{}
+	byOwner := map[string]int{}
+	for rows.Next() {
+		var id int64
+		var raw []byte
+		var createdAt time.Time
+		if err := rows.Scan(&id, &raw, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		var finding FindingData
+		if err := json.Unmarshal(raw, &finding); err != nil {
+			continue
+		}
+		if len(ids) == 0 {
+			periodStart = createdAt
+		}
+		ids = append(ids, id)
+		bySeverity[strings.ToLower(finding.Severity)]++
+		if finding.Owner != nil {
+			byOwner[finding.Owner.Team]++
+		}
+		if len(findings) < digestListLimit {
+			findings = append(findings, finding)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := n.post(ctx, map[string]interface{}{
+		"type":        "digest",
+		"source":      n.source,
+		"period":      n.cfg.Mode,
+		"from":        periodStart.UTC(),
+		"to":          periodEnd,
+		"total":       len(ids),
+		"by_severity": bySeverity,
+		"by_owner":    byOwner,
+		"findings":    findings,
+		"truncated":   len(ids) > len(findings),
+		"dedup_days":  n.cfg.DedupDays,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE notification_digest_items SET sent_at = NOW() WHERE id = ANY($1)`, ids); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Printf("📬 Sent %s notification digest with %d findings", n.cfg.Mode, len(ids))
+
+	// Sent items are only kept for a week
+	n.pool.Exec(ctx, `DELETE FROM notification_digest_items WHERE sent_at < NOW() - INTERVAL '7 days'`)
+	return nil
+}
+
+func (n *Notifier) post(ctx context.Context, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.cfg.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Scanner-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops the digest scheduler; pending digest items stay queued in the database
+func (n *Notifier) Close() error {
+	select {
+	case <-n.stop:
+	default:
+		close(n.stop)
+	}
+	<-n.done
+	return nil
+}
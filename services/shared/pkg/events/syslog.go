@@ -0,0 +1,333 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCEFFields maps finding fields to CEF extension keys
+var defaultCEFFields = map[string]string{
+	"host":        "dhost",
+	"port":        "dpt",
+	"protocol":    "proto",
+	"service":     "app",
+	"matched_at":  "request",
+	"scanner":     "cs1",
+	"scan_id":     "cs2",
+	"template_id": "cs3",
+}
+
+// defaultLEEFFields maps finding fields to LEEF attribute keys
+var defaultLEEFFields = map[string]string{
+	"host":        "dst",
+	"port":        "dstPort",
+	"protocol":    "proto",
+	"service":     "app",
+	"matched_at":  "url",
+	"scanner":     "scanner",
+	"scan_id":     "scanId",
+	"template_id": "templateId",
+	"severity":    "sev",
+}
+
+// SyslogPublisher forwards finding.created events to a SIEM as CEF or LEEF
+// messages over syslog (RFC 5424) on UDP, TCP or TLS. Other event types are ignored.
+type SyslogPublisher struct {
+	network  string
+	address  string
+	format   string
+	fields   map[string]string
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogPublisher creates a syslog publisher. network is udp, tcp or tls,
+// format is cef or leef, and fieldMapping ("host=dst,port=dpt") overrides the
+// default field mapping for the chosen format.
+func NewSyslogPublisher(network, address, format, fieldMapping, appName string) (*SyslogPublisher, error) {
+	network = strings.ToLower(network)
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" && network != "tls" {
+		return nil, fmt.Errorf("unsupported syslog network: %s", network)
+	}
+
+	format = strings.ToLower(format)
+	if format == "" {
+		format = "cef"
+	}
+
+	var fields map[string]string
+	switch format {
+	case "cef":
+		fields = copyFields(defaultCEFFields)
+	case "leef":
+		fields = copyFields(defaultLEEFFields)
+	default:
+		return nil, fmt.Errorf("unsupported SIEM format: %s", format)
+	}
+
+	for _, pair := range strings.Split(fieldMapping, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+		if kv[1] == "" {
+			delete(fields, kv[0])
+		} else {
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	// The app name is also the RFC 5424 APP-NAME, which can't hold spaces
+	appName = strings.Join(strings.Fields(appName), "-")
+	if appName == "" {
+		appName = "-"
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogPublisher{
+		network:  network,
+		address:  address,
+		format:   format,
+		fields:   fields,
+		hostname: hostname,
+		appName:  appName,
+	}, nil
+}
+
+func copyFields(src map[string]string) map[string]string {
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func (p *SyslogPublisher) connect() error {
+	var conn net.Conn
+	var err error
+	switch p.network {
+	case "tls":
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.address, &tls.Config{MinVersion: tls.VersionTLS12})
+	default:
+		conn, err = net.DialTimeout(p.network, p.address, 5*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	return nil
+}
+
+// Publish formats finding events and writes them to the syslog receiver
+func (p *SyslogPublisher) Publish(ctx context.Context, subject, key string, payload []byte) error {
+	var event struct {
+		Type string      `json:"type"`
+		Time time.Time   `json:"time"`
+		Data FindingData `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	if event.Type != FindingCreated {
+		return nil
+	}
+
+	var body string
+	if p.format == "leef" {
+		body = p.formatLEEF(event.Data)
+	} else {
+		body = p.formatCEF(event.Data)
+	}
+
+	// RFC 5424 header, facility local4 (20)
+	pri := 20*8 + syslogSeverity(event.Data.Severity)
+	line := fmt.Sprintf("<%d>1 %s %s %s - - - %s", pri, event.Time.UTC().Format(time.RFC3339), p.hostname, p.appName, body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		p.conn.SetWriteDeadline(deadline)
+	}
+
+	// Stream transports need a frame delimiter, datagrams don't
+	if p.network != "udp" {
+		line += "\n"
+	}
+	if _, err := p.conn.Write([]byte(line)); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (p *SyslogPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// findingValues flattens a finding into field name -> value
+func findingValues(f FindingData) map[string]string {
+	values := map[string]string{
+		"host":        f.Host,
+		"protocol":    f.Protocol,
+		"service":     f.Service,
+		"matched_at":  f.MatchedAt,
+		"scanner":     f.Scanner,
+		"scan_id":     f.ScanID,
+		"template_id": f.TemplateID,
+		"severity":    f.Severity,
+		"title":       f.Title,
+	}
+	if f.Port > 0 {
+		values["port"] = strconv.Itoa(f.Port)
+	}
+	return values
+}
+
+// mappedPairs returns the configured (key, value, field) triples sorted by key
+func (p *SyslogPublisher) mappedPairs(f FindingData) [][3]string {
+	values := findingValues(f)
+	pairs := [][3]string{}
+	for field, key := range p.fields {
+		if v := values[field]; v != "" {
+			pairs = append(pairs, [3]string{key, v, field})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+func (p *SyslogPublisher) formatCEF(f FindingData) string {
+	signature := f.TemplateID
+	if signature == "" {
+		signature = "finding"
+	}
+
+	var ext []string
+	for _, pair := range p.mappedPairs(f) {
+		ext = append(ext, pair[0]+"="+cefExtensionEscape(pair[1]))
+		// Custom fields (cs1..cs6, cn1..cn3) carry their meaning in a label
+		if strings.HasPrefix(pair[0], "cs") || strings.HasPrefix(pair[0], "cn") {
+			ext = append(ext, pair[0]+"Label="+pair[2])
+		}
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeaderEscape("Security Scanner"),
+		cefHeaderEscape(p.appName),
+		"1.0",
+		cefHeaderEscape(signature),
+		cefHeaderEscape(f.Title),
+		cefSeverity(f.Severity),
+		strings.Join(ext, " "))
+}
+
+func (p *SyslogPublisher) formatLEEF(f FindingData) string {
+	eventID := f.TemplateID
+	if eventID == "" {
+		eventID = "finding"
+	}
+
+	attrs := []string{"cat=" + leefEscape(f.Title)}
+	for _, pair := range p.mappedPairs(f) {
+		value := pair[1]
+		if pair[0] == "sev" {
+			value = strconv.Itoa(cefSeverity(f.Severity))
+		}
+		attrs = append(attrs, pair[0]+"="+leefEscape(value))
+	}
+
+	return fmt.Sprintf("LEEF:1.0|Security Scanner|%s|1.0|%s|%s",
+		leefEscape(p.appName), leefEscape(eventID), strings.Join(attrs, "\t"))
+}
+
+// cefHeaderEscape, cefExtensionEscape and leefEscape all take line breaks out
+// of values: on stream transports a line break ends the syslog message, and
+// the rest of the value would be read as a forged message of its own
+func cefHeaderEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\r", " ")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func cefExtensionEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "=", `\=`)
+	s = strings.ReplaceAll(s, "\r", `\r`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func leefEscape(s string) string {
+	s = strings.ReplaceAll(s, "\t", " ")
+	s = strings.ReplaceAll(s, "\r", " ")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", "/")
+}
+
+// cefSeverity maps scanner severities to the CEF 0-10 scale
+func cefSeverity(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 10
+	case "high":
+		return 8
+	case "medium":
+		return 5
+	case "low":
+		return 3
+	case "info":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// syslogSeverity maps scanner severities to syslog severity levels
+func syslogSeverity(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 2 // crit
+	case "high":
+		return 3 // err
+	case "medium":
+		return 4 // warning
+	case "low":
+		return 5 // notice
+	default:
+		return 6 // info
+	}
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyslogFormatsKeepFindingsOnOneLine(t *testing.T) {
+	finding := FindingData{
+		Title:      "XSS\r\n<13>1 2024-01-01T00:00:00Z host app - - - CEF:0|forged",
+		TemplateID: "xss\n",
+		Host:       "example.com\r\n",
+		Severity:   "high",
+	}
+	for _, format := range []string{"cef", "leef"} {
+		p, err := NewSyslogPublisher("tcp", "127.0.0.1:514", format, "", "network service\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{p.formatCEF(finding), p.formatLEEF(finding), p.appName} {
+			if strings.ContainsAny(line, "\r\n") {
+				t.Errorf("%s: line break in %q", format, line)
+			}
+		}
+	}
+}
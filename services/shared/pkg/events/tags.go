@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/security-scanner/shared/pkg/owners"
+)
+
+// criticalities ranks the criticality of the asset tag rules
+var criticalities = map[string]int{"": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// TagFindings sets the Tags and Criticality of each finding from the asset
+// tags the network service's rules assigned to its host. Findings are left
+// untouched while no rule has tagged anything yet.
+func TagFindings(ctx context.Context, pool *pgxpool.Pool, findings []FindingData) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('asset_tags') IS NOT NULL`).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	hosts := make([]string, 0, len(findings))
+	for _, f := range findings {
+		hosts = append(hosts, owners.HostOf(f.Host))
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT asset, tag, COALESCE(criticality, '') FROM asset_tags WHERE asset = ANY($1)
+	`, hosts)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tags := map[string][]string{}
+	criticality := map[string]string{}
+	for rows.Next() {
+		var asset, tag, crit string
+		if err := rows.Scan(&asset, &tag, &crit); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(tag, "criticality:") && !contains(tags[asset], tag) {
+			tags[asset] = append(tags[asset], tag)
+		}
+		if criticalities[crit] > criticalities[criticality[asset]] {
+			criticality[asset] = crit
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for asset := range tags {
+		sort.Strings(tags[asset])
+	}
+	for i := range findings {
+		host := owners.HostOf(findings[i].Host)
+		findings[i].Tags = tags[host]
+		findings[i].Criticality = criticality[host]
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Finding severities, from most to least severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// Severities are the finding severities, most severe first
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Finding is a vulnerability or misconfiguration reported by any service's
+// tools, normalized so the gateway can list them together
+type Finding struct {
+	ID         uuid.UUID `json:"id"`
+	Source     string    `json:"source"` // network, vulnerabilities, cmsscans, cloudscans
+	Tool       string    `json:"tool"`   // nmap, nuclei, wpscan, prowler, trivy, scoutsuite, buckets
+	ScanID     uuid.UUID `json:"scan_id"`
+	Title      string    `json:"title"`
+	Severity   string    `json:"severity"`             // one of Severities
+	Target     string    `json:"target"`               // host, URL, port or cloud resource where it was found
+	Identifier string    `json:"identifier,omitempty"` // CVE, template or check ID
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FindingList is a page of findings and how many matched in total
+type FindingList struct {
+	Findings []Finding `json:"findings"`
+	Total    int       `json:"total"`
+}
+
+// NormalizeSeverity maps the severity names of the tools ("CRITICAL",
+// "Informational", "moderate") to Severities; unknown names are info
+func NormalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "critical":
+		return SeverityCritical
+	case "high":
+		return SeverityHigh
+	case "medium", "moderate":
+		return SeverityMedium
+	case "low":
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
+
+// SeverityFromCVSS returns the CVSS v3 rating of a score
+func SeverityFromCVSS(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityInfo
+	}
+}
+
+// SeverityRank orders severities, 0 being the most severe
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// Page sizes of findings lists
+const (
+	DefaultFindingLimit = 100
+	MaxFindingLimit     = 10000
+)
+
+// FindingFilter selects findings
+type FindingFilter struct {
+	Severities []string   // any severity when empty
+	Target     string     // substring of the target, case-insensitive
+	ScanID     *uuid.UUID // findings of one scan
+	Limit      int
+}
+
+// ParseFindingFilter reads the ?severity=, ?target=, ?scan_id= and ?limit=
+// parameters of a findings request through query
+func ParseFindingFilter(query func(key string) string) (FindingFilter, error) {
+	filter := FindingFilter{Target: strings.TrimSpace(query("target")), Limit: DefaultFindingLimit}
+	for _, severity := range strings.Split(query("severity"), ",") {
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if severity == "" {
+			continue
+		}
+		if SeverityRank(severity) == len(Severities) {
+			return filter, fmt.Errorf("severity must be one of %s", strings.Join(Severities, ", "))
+		}
+		filter.Severities = append(filter.Severities, severity)
+	}
+	if raw := query("scan_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return filter, errors.New("invalid scan_id")
+		}
+		filter.ScanID = &id
+	}
+	if raw := query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxFindingLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", MaxFindingLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// Match reports whether the filter selects finding
+func (f FindingFilter) Match(finding Finding) bool {
+	if f.ScanID != nil && finding.ScanID != *f.ScanID {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(finding.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	if len(f.Severities) == 0 {
+		return true
+	}
+	for _, severity := range f.Severities {
+		if finding.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the findings the filter selects, sorted and limited
+func (f FindingFilter) Apply(findings []Finding) FindingList {
+	matched := []Finding{}
+	for _, finding := range findings {
+		if f.Match(finding) {
+			matched = append(matched, finding)
+		}
+	}
+	SortFindings(matched)
+	list := FindingList{Findings: matched, Total: len(matched)}
+	if f.Limit > 0 && len(matched) > f.Limit {
+		list.Findings = matched[:f.Limit]
+	}
+	return list
+}
+
+// SortFindings orders findings most severe first, and newest first within a
+// severity
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		ri, rj := SeverityRank(findings[i].Severity), SeverityRank(findings[j].Severity)
+		if ri != rj {
+			return ri < rj
+		}
+		return findings[i].CreatedAt.After(findings[j].CreatedAt)
+	})
+}
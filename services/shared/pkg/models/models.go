@@ -0,0 +1,55 @@
+// Package models holds the structs every service returns the same way, so
+// they are defined once for the services, the gateway and API clients.
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scan statuses, shared by every kind of scan
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	// StatusInterrupted is a scan whose service restarted while it ran
+	StatusInterrupted = "interrupted"
+)
+
+// Scan is the part of a scan every service returns. Which of Type, Tool and
+// Scanner are set depends on the service.
+type Scan struct {
+	ID            uuid.UUID              `json:"id"`
+	Name          string                 `json:"name"`
+	Target        string                 `json:"target"`
+	Type          string                 `json:"scan_type,omitempty"` // network, recon, apiscans, cmsscans, cloudscans
+	Tool          string                 `json:"tool,omitempty"`      // webscans
+	Scanner       string                 `json:"scanner,omitempty"`   // network
+	Origin        string                 `json:"origin,omitempty"`    // manual, schedule:<id>, workflow:<id>, ci:<key>
+	ProjectID     string                 `json:"project_id,omitempty"`
+	Status        string                 `json:"status"`
+	Progress      int                    `json:"progress"`
+	CreatedAt     time.Time              `json:"created_at"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"`
+	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
+	ErrorMessage  *string                `json:"error_message,omitempty"`
+	Configuration map[string]interface{} `json:"configuration,omitempty"`
+}
+
+// Finished reports whether the scan will make no more progress
+func (s *Scan) Finished() bool {
+	return s.Status == StatusCompleted || s.Status == StatusFailed || s.Status == StatusCancelled ||
+		s.Status == StatusInterrupted
+}
+
+// ScanLog is a log line of a scan
+type ScanLog struct {
+	ID        uuid.UUID `json:"id"`
+	ScanID    uuid.UUID `json:"scan_id"`
+	Level     string    `json:"level"` // debug, info, warning, error
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,109 @@
+// Package origin records what started a scan, so dashboards can tell
+// analysts' ad-hoc scans apart from automated background scanning. An origin
+// is a kind, followed by ":" and the ID of what started the scan when there
+// is one: "manual", "schedule:<schedule id>", "workflow:<workflow id>" or
+// "ci:<API key name>".
+package origin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of origin
+const (
+	Manual   = "manual"   // the UI or an analyst calling the API
+	Schedule = "schedule" // a scheduler, with the schedule's ID
+	Workflow = "workflow" // a workflow, with the workflow's ID
+	CI       = "ci"       // a CI pipeline, with the name of its API key
+)
+
+// Kinds are the kinds of origin, for validation and documentation
+var Kinds = []string{Manual, Schedule, Workflow, CI}
+
+const (
+	// Header is set by schedulers and workflows on the requests creating
+	// scans: "schedule:<id>" or "workflow:<id>"
+	Header = "X-Scan-Origin"
+	// UserIDHeader is the caller's identity, set by the gateway; API keys
+	// are "apikey:<name>"
+	UserIDHeader = "X-User-ID"
+	// maxIDLength bounds the ID part; origins are stored in a VARCHAR(150)
+	maxIDLength = 100
+)
+
+// FromRequest returns the origin of a scan created by a request with the
+// given Header and UserIDHeader. Schedules and workflows name themselves in
+// Header, requests with an API key are from CI and anything else is manual.
+func FromRequest(header, userID string) string {
+	if kind, id, err := split(header); err == nil && id != "" && (kind == Schedule || kind == Workflow) {
+		return kind + ":" + id
+	}
+	if name, ok := strings.CutPrefix(userID, "apikey:"); ok && name != "" {
+		if len(name) > maxIDLength {
+			name = name[:maxIDLength]
+		}
+		return CI + ":" + name
+	}
+	return Manual
+}
+
+// Of returns the origin of kind with id, for scans the services start
+// themselves
+func Of(kind, id string) string {
+	if id == "" {
+		return kind
+	}
+	return kind + ":" + id
+}
+
+// Filter returns the SQL condition of an ?origin= filter on column, whose
+// value is placeholder $n, and that value. A kind ("schedule") matches every
+// origin of that kind, a kind with an ID ("schedule:nightly") only that one.
+func Filter(column string, n int, filter string) (string, string, error) {
+	kind, id, err := split(filter)
+	if err != nil {
+		return "", "", err
+	}
+	if id == "" {
+		return fmt.Sprintf("split_part(%s, ':', 1) = $%d", column, n), kind, nil
+	}
+	return fmt.Sprintf("%s = $%d", column, n), kind + ":" + id, nil
+}
+
+// Match reports whether origin matches a filter checked with ValidFilter,
+// for services filtering scans in memory
+func Match(origin, filter string) bool {
+	kind, id, err := split(filter)
+	if err != nil {
+		return false
+	}
+	if id == "" {
+		scanKind, _, _ := strings.Cut(origin, ":")
+		return scanKind == kind
+	}
+	return origin == kind+":"+id
+}
+
+// ValidFilter checks an ?origin= filter before it is passed to Filter
+func ValidFilter(filter string) error {
+	_, _, err := split(filter)
+	return err
+}
+
+// split parses "kind" or "kind:id"
+func split(value string) (kind, id string, err error) {
+	kind, id, _ = strings.Cut(strings.TrimSpace(value), ":")
+	kind = strings.ToLower(kind)
+	known := false
+	for _, k := range Kinds {
+		known = known || k == kind
+	}
+	if !known {
+		return "", "", fmt.Errorf("origin must be one of %s, optionally followed by :<id>", strings.Join(Kinds, ", "))
+	}
+	if len(id) > maxIDLength || strings.ContainsAny(id, " \t\r\n") {
+		return "", "", fmt.Errorf("the ID of an origin must have at most %d characters and no spaces", maxIDLength)
+	}
+	return kind, id, nil
+}
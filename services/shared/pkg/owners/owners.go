@@ -0,0 +1,148 @@
+// Package owners records who owns each asset: the team, its contact email
+// and business unit. An assignment covers a single host, a CIDR block or
+// every subdomain of a domain ("*.example.com"), and the most specific
+// assignment of a host is its owner. Findings carry their host's owner in
+// notifications and reports, so they reach the team that can fix them.
+package owners
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/database"
+)
+
+// SchemaSQL creates the table of assignments, shared by every service
+const SchemaSQL = `
+CREATE TABLE IF NOT EXISTS asset_owners (
+    id UUID PRIMARY KEY,
+    target VARCHAR(500) NOT NULL UNIQUE,
+    owner_team VARCHAR(255) NOT NULL,
+    contact_email VARCHAR(255) NOT NULL DEFAULT '',
+    business_unit VARCHAR(255) NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Columns are the asset_owners columns read by Scan
+const Columns = `id, target, owner_team, contact_email, business_unit, notes, created_at, updated_at`
+
+// Owner is who to contact about an asset
+type Owner struct {
+	Team         string `json:"owner_team"`
+	ContactEmail string `json:"contact_email,omitempty"`
+	BusinessUnit string `json:"business_unit,omitempty"`
+}
+
+// Assignment gives the assets matching Target to an owner. Target is a
+// host name or IP, a CIDR block, or "*.domain" for the domain and all of its
+// subdomains.
+type Assignment struct {
+	ID     uuid.UUID `json:"id"`
+	Target string    `json:"target"`
+	Owner
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Scan reads an assignment selected with Columns
+func Scan(row database.Row) (*Assignment, error) {
+	var a Assignment
+	err := row.Scan(&a.ID, &a.Target, &a.Team, &a.ContactEmail, &a.BusinessUnit, &a.Notes, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Validate normalizes an assignment's target and checks it and the owner
+func Validate(a *Assignment) error {
+	a.Target = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(a.Target)), ".")
+	a.Team = strings.TrimSpace(a.Team)
+	a.ContactEmail = strings.TrimSpace(a.ContactEmail)
+	a.BusinessUnit = strings.TrimSpace(a.BusinessUnit)
+	if a.Target == "" {
+		return fmt.Errorf("target is required: a host, a CIDR block or *.domain")
+	}
+	if strings.Contains(a.Target, "/") {
+		if _, _, err := net.ParseCIDR(a.Target); err != nil {
+			return fmt.Errorf("invalid CIDR block %q", a.Target)
+		}
+	} else if strings.ContainsAny(strings.TrimPrefix(a.Target, "*."), "*:/ ") && net.ParseIP(a.Target) == nil {
+		return fmt.Errorf("invalid target %q: expected a host, a CIDR block or *.domain", a.Target)
+	}
+	if a.Team == "" {
+		return fmt.Errorf("owner_team is required")
+	}
+	if a.ContactEmail != "" {
+		if _, err := mail.ParseAddress(a.ContactEmail); err != nil {
+			return fmt.Errorf("invalid contact_email %q", a.ContactEmail)
+		}
+	}
+	return nil
+}
+
+// Match returns the owner of a host, known by one or more names (host
+// names, IPs or URLs), from its most specific assignment: an exact host, then
+// the longest domain, then the smallest CIDR block. It returns nil for hosts
+// nobody owns.
+func Match(assignments []Assignment, names ...string) *Owner {
+	var best *Assignment
+	bestScore := -1
+	for _, name := range names {
+		host := HostOf(name)
+		if host == "" {
+			continue
+		}
+		for i := range assignments {
+			if score := specificity(assignments[i].Target, host); score > bestScore {
+				best, bestScore = &assignments[i], score
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	owner := best.Owner
+	return &owner
+}
+
+// specificity scores how closely target covers host, -1 when it doesn't
+func specificity(target, host string) int {
+	switch {
+	case target == host:
+		return 1000
+	case strings.HasPrefix(target, "*."):
+		domain := target[2:]
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return 500 + len(domain)
+		}
+	case strings.Contains(target, "/"):
+		ip := net.ParseIP(host)
+		if _, network, err := net.ParseCIDR(target); err == nil && ip != nil && network.Contains(ip) {
+			ones, _ := network.Mask.Size()
+			return ones
+		}
+	}
+	return -1
+}
+
+// HostOf returns the lowercase host of a host name, IP, host:port or URL
+func HostOf(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		value = rest
+	}
+	value, _, _ = strings.Cut(value, "/")
+	if h, _, err := net.SplitHostPort(value); err == nil {
+		value = h
+	}
+	return strings.TrimSuffix(strings.Trim(value, "[]"), ".")
+}
@@ -0,0 +1,194 @@
+// Package pagination reads the page, limit and sort parameters every scan
+// list accepts and builds the envelope they answer with:
+// {"items": [...], "total": 42, "page": 1, "pages": 3, "limit": 20}, also
+// sent as the X-Total-Count and Link headers.
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultLimit is the page size without ?limit=
+	DefaultLimit = 20
+	// MaxLimit is the largest ?limit= accepted
+	MaxLimit = 100
+)
+
+// Params are the page to return and its order. Sort is a column of the
+// endpoint's sortable fields, never user input.
+type Params struct {
+	Page  int
+	Limit int
+	Sort  string
+	Desc  bool
+	Field string // the field Sort was chosen by, as the client wrote it
+}
+
+// Parse reads ?page= (from 1), ?limit= (1 to MaxLimit) and ?sort=, a field
+// of sortable, descending with a leading "-" ("-created_at"). sortable maps
+// the fields clients sort by to their columns; an empty sort is defaultSort.
+func Parse(page, limit, sortBy string, sortable map[string]string, defaultSort string) (Params, error) {
+	p := Params{Page: 1, Limit: DefaultLimit}
+	if page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("page must be a number from 1")
+		}
+		p.Page = n
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			return p, fmt.Errorf("limit must be a number from 1 to %d", MaxLimit)
+		}
+		p.Limit = n
+	}
+
+	sortBy = strings.TrimSpace(sortBy)
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	field := strings.TrimPrefix(sortBy, "-")
+	column, ok := sortable[strings.ToLower(field)]
+	if !ok {
+		return p, fmt.Errorf("sort must be one of %s, optionally prefixed with - for descending order",
+			strings.Join(Fields(sortable), ", "))
+	}
+	p.Sort, p.Desc, p.Field = column, strings.HasPrefix(sortBy, "-"), strings.ToLower(field)
+	return p, nil
+}
+
+// Fields returns the sortable fields in alphabetical order
+func Fields(sortable map[string]string) []string {
+	fields := make([]string, 0, len(sortable))
+	for field := range sortable {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Offset is the number of items before the page
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// OrderBy returns the ORDER BY, LIMIT and OFFSET clauses of the page. Rows
+// with the same value keep a stable order by id, and NULLs (scans not yet
+// started or completed) come last either way.
+func (p Params) OrderBy() string {
+	direction := "ASC"
+	if p.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id %s LIMIT %d OFFSET %d",
+		p.Sort, direction, direction, p.Limit, p.Offset())
+}
+
+// Page is a page of a list and how many items the whole list has
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+	Limit int `json:"limit"`
+}
+
+// New returns the page of items, which the database already limited to p,
+// out of total
+func New[T any](items []T, total int, p Params) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	pages := (total + p.Limit - 1) / p.Limit
+	return Page[T]{Items: items, Total: total, Page: p.Page, Pages: pages, Limit: p.Limit}
+}
+
+// Slice returns the page p of items, for services that filter and sort in
+// memory; less orders items by p.Sort ascending
+func Slice[T any](items []T, p Params, less func(a, b T) bool) Page[T] {
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if p.Desc {
+			return less(sorted[j], sorted[i])
+		}
+		return less(sorted[i], sorted[j])
+	})
+
+	start := p.Offset()
+	if start > len(sorted) {
+		start = len(sorted)
+	}
+	end := start + p.Limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+	return New(sorted[start:end], len(items), p)
+}
+
+// Headers of a page, for clients that paginate by headers instead of the
+// envelope
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderLink       = "Link"
+)
+
+// Headers returns the X-Total-Count and Link headers of the page. The links
+// (first, prev, next and last, RFC 8288) are requestURI, the path and query
+// of the request, with its page and limit replaced.
+func (p Page[T]) Headers(requestURI string) map[string]string {
+	headers := map[string]string{HeaderTotalCount: strconv.Itoa(p.Total)}
+	if link := Link(requestURI, p.Page, p.Pages, p.Limit); link != "" {
+		headers[HeaderLink] = link
+	}
+	return headers
+}
+
+// Link returns the Link header of page out of pages of requestURI; the
+// previous page of a page past the end is the last one
+func Link(requestURI string, page, pages, limit int) string {
+	u, err := url.Parse(requestURI)
+	if err != nil || pages < 1 {
+		return ""
+	}
+	ref := func(n int, rel string) string {
+		query := u.Query()
+		query.Set("page", strconv.Itoa(n))
+		query.Set("limit", strconv.Itoa(limit))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, query.Encode(), rel)
+	}
+
+	links := []string{ref(1, "first")}
+	if page > 1 {
+		links = append(links, ref(min(page-1, pages), "prev"))
+	}
+	if page < pages {
+		links = append(links, ref(page+1, "next"))
+	}
+	links = append(links, ref(pages, "last"))
+	return strings.Join(links, ", ")
+}
+
+// RebaseLink moves the links of a Link header to path, keeping their
+// queries: the gateway serves the lists of the services under other paths
+func RebaseLink(link, path string) string {
+	parts := strings.Split(link, ", ")
+	for i, part := range parts {
+		target, rest, ok := strings.Cut(part, ">")
+		if !ok || !strings.HasPrefix(target, "<") {
+			continue
+		}
+		query := ""
+		if _, q, found := strings.Cut(target, "?"); found {
+			query = "?" + q
+		}
+		parts[i] = "<" + path + query + ">" + rest
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,67 @@
+// Package project groups engagements into projects. Every scan, template
+// and, through its scan, every finding and asset belongs to a project, named
+// by the X-Tenant-ID header of the request that created it; anything created
+// without one belongs to the default project. The gateway keeps the list of
+// projects and rejects requests for unknown ones.
+//
+// Projects label and filter listings; they are not an access boundary. The
+// client picks X-Tenant-ID and it isn't checked against the caller, and
+// anything fetched by ID is served whatever its project.
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Header names the project of a request
+	Header = "X-Tenant-ID"
+	// Default is the project of everything created without Header
+	Default = "default"
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid checks a project ID: lowercase letters, digits, "-" and "_", at
+// most 63 characters
+func Valid(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("project must be lowercase letters, digits, - and _ (at most 63 characters)")
+	}
+	return nil
+}
+
+// FromRequest returns the project of what a request with the given Header
+// creates
+func FromRequest(header string) string {
+	if id := strings.ToLower(strings.TrimSpace(header)); id != "" {
+		return id
+	}
+	return Default
+}
+
+// Scope returns the project a listing is limited to: ?project= when given,
+// otherwise the request's Header. Empty lists every project.
+func Scope(query, header string) string {
+	if query != "" {
+		return strings.ToLower(strings.TrimSpace(query))
+	}
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
+// Filter returns the SQL condition limiting column to a project whose ID is
+// placeholder $n
+func Filter(column string, n int) string {
+	return fmt.Sprintf("%s = $%d", column, n)
+}
+
+// Match reports whether something of project is listed in scope, for
+// services filtering in memory
+func Match(project, scope string) bool {
+	if project == "" {
+		project = Default
+	}
+	return scope == "" || project == scope
+}
@@ -0,0 +1,241 @@
+// Package redact sanitizes exported reports so they can be shared with third
+// parties while the full evidence stays internal. A profile says what to
+// hide: IP addresses and hostnames are replaced by pseudonyms ("ip-1",
+// "host-1") that stay the same across one report, so it still reads as the
+// same network; screenshots and raw requests/responses are left out.
+package redact
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Profile is what a report hides
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IPs         bool   `json:"ips"`
+	Hostnames   bool   `json:"hostnames"`
+	Screenshots bool   `json:"screenshots"`
+	Requests    bool   `json:"requests"` // raw requests, responses and curl commands
+}
+
+// Active reports whether the profile hides anything
+func (p Profile) Active() bool {
+	return p.IPs || p.Hostnames || p.Screenshots || p.Requests
+}
+
+// None is the profile of reports for internal use
+const None = "none"
+
+// Profiles are the built-in profiles
+var Profiles = []Profile{
+	{Name: None, Description: "Full evidence, for internal use"},
+	{Name: "client", Description: "Raw requests and responses left out; addresses kept for the asset owner",
+		Requests: true},
+	{Name: "third-party", Description: "Addresses and hostnames masked, screenshots and raw requests left out",
+		IPs: true, Hostnames: true, Screenshots: true, Requests: true},
+}
+
+// Lookup returns the profile named name, None when empty. A comma-separated
+// list of "ips", "hostnames", "screenshots" and "requests" is a custom
+// profile hiding those.
+func Lookup(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = None
+	}
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	custom := Profile{Name: name, Description: "Custom"}
+	for _, part := range strings.Split(name, ",") {
+		switch strings.TrimSpace(part) {
+		case "ips":
+			custom.IPs = true
+		case "hostnames":
+			custom.Hostnames = true
+		case "screenshots":
+			custom.Screenshots = true
+		case "requests":
+			custom.Requests = true
+		default:
+			return Profile{}, fmt.Errorf("unknown redaction profile %q", name)
+		}
+	}
+	return custom, nil
+}
+
+// Names returns the names of the built-in profiles
+func Names() []string {
+	names := make([]string, len(Profiles))
+	for i, p := range Profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Pattern matches candidates only; net.ParseIP decides
+	ipv6Pattern = regexp.MustCompile(`(?i)[0-9a-f]*:[0-9a-f:]*:[0-9a-f.]*`)
+	// splitPattern matches the items of a list separated by commas or spaces
+	splitPattern = regexp.MustCompile(`[^,\s]+`)
+	// hostnamePattern matches names with at least two labels
+	hostnamePattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z](?:[a-z0-9-]{0,61}[a-z0-9])?\b`)
+)
+
+// Redactor applies a profile to the values of one report, handing out the
+// same pseudonym for the same address or name
+type Redactor struct {
+	profile Profile
+	ips     map[string]string
+	hosts   map[string]string
+}
+
+// New returns a redactor for profile
+func New(profile Profile) *Redactor {
+	return &Redactor{profile: profile, ips: map[string]string{}, hosts: map[string]string{}}
+}
+
+// Profile returns the profile the redactor applies
+func (r *Redactor) Profile() Profile {
+	return r.profile
+}
+
+// Active reports whether the redactor changes anything
+func (r *Redactor) Active() bool {
+	return r.profile.Active()
+}
+
+// Screenshots reports whether screenshots must be left out
+func (r *Redactor) Screenshots() bool {
+	return r.profile.Screenshots
+}
+
+// Requests reports whether raw requests and responses must be left out
+func (r *Redactor) Requests() bool {
+	return r.profile.Requests
+}
+
+// IP returns the pseudonym of an address; other values are returned as is
+func (r *Redactor) IP(value string) string {
+	if !r.profile.IPs || net.ParseIP(value) == nil {
+		return value
+	}
+	key := net.ParseIP(value).String()
+	if alias, ok := r.ips[key]; ok {
+		return alias
+	}
+	alias := fmt.Sprintf("ip-%d", len(r.ips)+1)
+	r.ips[key] = alias
+	return alias
+}
+
+// Hostname returns the pseudonym of a hostname, which is also masked when
+// it shows up in text later. Addresses are handed to IP.
+func (r *Redactor) Hostname(value string) string {
+	if net.ParseIP(value) != nil {
+		return r.IP(value)
+	}
+	if !r.profile.Hostnames || value == "" {
+		return value
+	}
+	key := strings.TrimSuffix(strings.ToLower(value), ".")
+	if alias, ok := r.hosts[key]; ok {
+		return alias
+	}
+	alias := fmt.Sprintf("host-%d", len(r.hosts)+1)
+	r.hosts[key] = alias
+	return alias
+}
+
+// Target masks a scan target: a list of addresses, ranges, hostnames or URLs
+func (r *Redactor) Target(value string) string {
+	if !r.profile.IPs && !r.profile.Hostnames {
+		return value
+	}
+	return splitPattern.ReplaceAllStringFunc(value, func(part string) string {
+		if strings.Contains(part, "://") {
+			return r.URL(part)
+		}
+		if addr, bits, ok := strings.Cut(part, "/"); ok && net.ParseIP(addr) != nil {
+			return r.IP(addr) + "/" + bits
+		}
+		return r.Hostname(part)
+	})
+}
+
+// URL masks the host of a URL and any address or known name in the rest
+func (r *Redactor) URL(value string) string {
+	if !r.profile.IPs && !r.profile.Hostnames {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Hostname() == "" {
+		return r.Text(value)
+	}
+	host := r.Hostname(u.Hostname())
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	return r.Text(u.String())
+}
+
+// Text masks the addresses in free text, and the hostnames the redactor
+// already knows along with their subdomains
+func (r *Redactor) Text(value string) string {
+	if r.profile.IPs {
+		value = ipv4Pattern.ReplaceAllStringFunc(value, r.IP)
+		value = ipv6Pattern.ReplaceAllStringFunc(value, func(candidate string) string {
+			if !strings.ContainsAny(candidate, "0123456789abcdefABCDEF") {
+				return candidate
+			}
+			return r.IP(candidate)
+		})
+	}
+	if r.profile.Hostnames && len(r.hosts) > 0 {
+		value = hostnamePattern.ReplaceAllStringFunc(value, func(name string) string {
+			if r.known(name) {
+				return r.Hostname(name)
+			}
+			return name
+		})
+	}
+	return value
+}
+
+// known reports whether name is a hostname the redactor masked, or one of
+// its subdomains
+func (r *Redactor) known(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := r.hosts[name]; ok {
+		return true
+	}
+	for host := range r.hosts {
+		if strings.HasSuffix(name, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Texts masks each of values
+func (r *Redactor) Texts(values []string) []string {
+	if !r.profile.IPs && !r.profile.Hostnames {
+		return values
+	}
+	masked := make([]string, len(values))
+	for i, value := range values {
+		masked[i] = r.Text(value)
+	}
+	return masked
+}
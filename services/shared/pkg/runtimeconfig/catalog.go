@@ -0,0 +1,75 @@
+package runtimeconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Global is the service name for settings that apply to every service
+const Global = "*"
+
+// Setting describes a key that can be managed through /api/admin/config.
+// HotReload settings are applied by the running service; the others are
+// stored centrally but only take effect after a restart.
+type Setting struct {
+	Service     string `json:"service"`
+	Key         string `json:"key"`
+	Type        string `json:"type"` // string, int, bool or duration
+	Description string `json:"description"`
+	HotReload   bool   `json:"hot_reload"`
+}
+
+// Catalog lists every known setting. Feature flags live in the features package.
+var Catalog = []Setting{
+	{Service: "network", Key: "nmap.path", Type: "string", Description: "Path to the nmap binary", HotReload: true},
+	{Service: "network", Key: "masscan.path", Type: "string", Description: "Path to the masscan binary", HotReload: true},
+	{Service: "network", Key: "naabu.path", Type: "string", Description: "Path to the naabu binary", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent", Type: "int", Description: "Maximum scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent.nmap", Type: "int", Description: "Maximum nmap scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent.masscan", Type: "int", Description: "Maximum masscan scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent.dns", Type: "int", Description: "Maximum DNS scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent.native", Type: "int", Description: "Maximum native scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "scans.max_concurrent.naabu", Type: "int", Description: "Maximum naabu scans running at once (0 = unlimited)", HotReload: true},
+	{Service: "network", Key: "neo4j.sync_interval", Type: "duration", Description: "Neo4j graph sync interval", HotReload: false},
+	{Service: "network", Key: "elasticsearch.sync_interval", Type: "duration", Description: "Elasticsearch indexing interval", HotReload: false},
+	{Service: "web", Key: "nuclei.path", Type: "string", Description: "Path to the nuclei binary", HotReload: true},
+	{Service: "web", Key: "ffuf.path", Type: "string", Description: "Path to the ffuf binary", HotReload: true},
+	{Service: "web", Key: "gowitness.path", Type: "string", Description: "Path to the gowitness binary", HotReload: true},
+	{Service: "web", Key: "testssl.path", Type: "string", Description: "Path to testssl.sh", HotReload: true},
+	{Service: "web", Key: "sqlmap.path", Type: "string", Description: "Path to sqlmap", HotReload: true},
+	{Service: "web", Key: "scans.max_concurrent", Type: "int", Description: "Maximum scans running at once (0 = unlimited)", HotReload: true},
+	{Service: Global, Key: "events.broker", Type: "string", Description: "Event broker (nats or kafka)", HotReload: false},
+}
+
+// Lookup returns the catalog entry for a key. Global settings match any service.
+func Lookup(service, key string) (Setting, bool) {
+	for _, s := range Catalog {
+		if s.Key == key && (s.Service == service || s.Service == Global) {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Validate checks that value matches the setting type
+func (s Setting) Validate(value string) error {
+	var err error
+	switch s.Type {
+	case "int":
+		_, err = strconv.Atoi(value)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "string":
+		if strings.TrimSpace(value) == "" {
+			err = fmt.Errorf("must not be empty")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value for %s: %v", s.Type, s.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package runtimeconfig
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter caps how many jobs run at once. The limit can be changed while
+// jobs are waiting; a limit of 0 means unlimited.
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	changed chan struct{}
+}
+
+func NewLimiter(limit int) *Limiter {
+	return &Limiter{limit: limit, changed: make(chan struct{})}
+}
+
+// SetLimit changes the limit and wakes up waiting jobs
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.notify()
+	l.mu.Unlock()
+}
+
+// Acquire blocks until a slot is free or ctx is cancelled
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.limit <= 0 || l.running < l.limit {
+			l.running++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TryAcquire takes a slot if one is free, without waiting
+func (l *Limiter) TryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 || l.running < l.limit {
+		l.running++
+		return true
+	}
+	return false
+}
+
+// Release frees a slot taken by Acquire or TryAcquire
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	l.running--
+	l.notify()
+	l.mu.Unlock()
+}
+
+// Stats returns the current limit and number of running jobs
+func (l *Limiter) Stats() (limit, running int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.running
+}
+
+// notify must be called with mu held
+func (l *Limiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,263 @@
+package runtimeconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Entry is a stored configuration value
+type Entry struct {
+	Service   string    `json:"service"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	HotReload bool      `json:"hot_reload"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS platform_config (
+    service VARCHAR(50) NOT NULL,
+    key VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    updated_by VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (service, key)
+)`
+
+// Store holds the configuration overrides for one service, loaded from the
+// platform_config table. Service-specific values win over global ("*") ones.
+// Start polls the table and calls the registered watchers when a value changes.
+type Store struct {
+	pool     *pgxpool.Pool
+	service  string
+	interval time.Duration
+
+	mu       sync.RWMutex
+	values   map[string]string
+	version  string
+	watchers map[string][]func(value string)
+}
+
+func NewStore(pool *pgxpool.Pool, service string, interval time.Duration) (*Store, error) {
+	if _, err := pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create platform_config table: %w", err)
+	}
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	s := &Store{
+		pool:     pool,
+		service:  service,
+		interval: interval,
+		values:   map[string]string{},
+		watchers: map[string][]func(value string){},
+	}
+	if err := s.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start polls for changes until ctx is cancelled
+func (s *Store) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("⚠️ Config reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// Reload re-reads the overrides if the table changed and notifies watchers
+func (s *Store) Reload(ctx context.Context) error {
+	// max(updated_at) + count detects inserts, updates and deletes
+	var version string
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(updated_at)::text, '') || '/' || COUNT(*)::text
+		FROM platform_config WHERE service IN ($1, $2)
+	`, s.service, Global).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	unchanged := version == s.version
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	// Global rows first so service rows overwrite them
+	rows, err := s.pool.Query(ctx, `
+		SELECT key, value FROM platform_config WHERE service IN ($1, $2)
+		ORDER BY CASE WHEN service = $2 THEN 0 ELSE 1 END
+	`, s.service, Global)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.values
+	s.values = values
+	s.version = version
+	type change struct {
+		fn    func(string)
+		value string
+	}
+	changes := []change{}
+	for key, fns := range s.watchers {
+		if old[key] != values[key] {
+			for _, fn := range fns {
+				changes = append(changes, change{fn: fn, value: values[key]})
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range changes {
+		c.fn(c.value)
+	}
+	if len(changes) > 0 {
+		log.Printf("🔄 Applied %d configuration change(s)", len(changes))
+	}
+	return nil
+}
+
+// Watch registers fn to be called with the new value whenever key changes.
+// An empty value means the override was removed and the env default applies.
+func (s *Store) Watch(key string, fn func(value string)) {
+	s.mu.Lock()
+	s.watchers[key] = append(s.watchers[key], fn)
+	value, ok := s.values[key]
+	s.mu.Unlock()
+
+	if ok {
+		fn(value)
+	}
+}
+
+// Get returns the override for key, or def when unset
+func (s *Store) Get(key, def string) string {
+	if s == nil {
+		return def
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if value, ok := s.values[key]; ok {
+		return value
+	}
+	return def
+}
+
+// Int returns the override for key as an int, or def when unset or invalid
+func (s *Store) Int(key string, def int) int {
+	if v, err := strconv.Atoi(s.Get(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Bool returns the override for key as a bool, or def when unset or invalid
+func (s *Store) Bool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(s.Get(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Duration returns the override for key as a duration, or def when unset or invalid
+func (s *Store) Duration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(s.Get(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// List returns every stored entry for all services
+func (s *Store) List(ctx context.Context) ([]Entry, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT service, key, value, COALESCE(updated_by, ''), updated_at
+		FROM platform_config ORDER BY service, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Service, &e.Key, &e.Value, &e.UpdatedBy, &e.UpdatedAt); err != nil {
+			continue
+		}
+		if setting, ok := Lookup(e.Service, e.Key); ok {
+			e.HotReload = setting.HotReload
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Set validates and stores a value for a service (or Global)
+func (s *Store) Set(ctx context.Context, service, key, value, updatedBy string) (*Entry, error) {
+	setting, ok := Lookup(service, key)
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %s for service %s", key, service)
+	}
+	if err := setting.Validate(value); err != nil {
+		return nil, err
+	}
+
+	entry := Entry{Service: service, Key: key, Value: value, HotReload: setting.HotReload, UpdatedBy: updatedBy}
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO platform_config (service, key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NOW())
+		ON CONFLICT (service, key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_at
+	`, service, key, value, updatedBy).Scan(&entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Reload(ctx)
+	return &entry, nil
+}
+
+// Delete removes an override so the service falls back to its env default
+func (s *Store) Delete(ctx context.Context, service, key string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM platform_config WHERE service = $1 AND key = $2`, service, key)
+	if err != nil {
+		return false, err
+	}
+
+	s.Reload(ctx)
+	return tag.RowsAffected() > 0, nil
+}
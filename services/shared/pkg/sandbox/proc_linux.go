@@ -0,0 +1,36 @@
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// applyProcAttr puts the tool in its own process group (so cancellation kills
+// helpers it spawned), kills it if the service dies and optionally unshares
+// the network namespace
+func applyProcAttr(cmd *exec.Cmd, p Profile) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if p.IsolateNetwork {
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNET
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// Violation reports whether the tool was killed for a forbidden system call
+func Violation(err error) (string, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return "", false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() && status.Signal() == syscall.SIGSYS {
+		return "process killed by seccomp filter (SIGSYS)", true
+	}
+	return "", false
+}
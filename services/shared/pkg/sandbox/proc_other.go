@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import "os/exec"
+
+// applyProcAttr is a no-op outside Linux; profiles still apply through the wrappers
+func applyProcAttr(cmd *exec.Cmd, p Profile) {}
+
+// Violation always reports false outside Linux
+func Violation(err error) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,208 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+
+	"github.com/security-scanner/shared/pkg/supervise"
+)
+
+// Profile configures how one tool is sandboxed
+type Profile struct {
+	// User runs the tool as another account ("nobody", "65534" or "65534:65534")
+	User string `json:"user,omitempty"`
+	// NoNewPrivs stops the tool and its children from gaining privileges (setuid binaries, file caps)
+	NoNewPrivs bool `json:"no_new_privs,omitempty"`
+	// Capabilities kept as ambient capabilities after switching user, e.g. net_raw for nmap
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Seccomp is the path to a compiled seccomp BPF filter, applied through bubblewrap
+	Seccomp string `json:"seccomp,omitempty"`
+	// IsolateNetwork runs the tool in an empty network namespace (for offline tools only)
+	IsolateNetwork bool `json:"isolate_network,omitempty"`
+	// Disabled runs the tool directly, overriding a "*" profile
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Sandbox wraps tool executions according to per-tool profiles.
+// Tools without a profile (and no "*" default) run directly.
+type Sandbox struct {
+	profiles    map[string]Profile
+	setprivPath string
+	bwrapPath   string
+}
+
+// Load parses the JSON profile map (SANDBOX_PROFILES), keyed by tool name.
+// It returns nil, meaning no sandboxing, when raw is empty.
+func Load(raw, setprivPath, bwrapPath string) (*Sandbox, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	profiles := map[string]Profile{}
+	if err := json.Unmarshal([]byte(raw), &profiles); err != nil {
+		return nil, fmt.Errorf("invalid sandbox profiles: %w", err)
+	}
+
+	for tool, p := range profiles {
+		if p.User != "" {
+			if _, _, err := lookupUser(p.User); err != nil {
+				return nil, fmt.Errorf("sandbox profile %s: %w", tool, err)
+			}
+		}
+		if p.Seccomp != "" {
+			if _, err := os.Stat(p.Seccomp); err != nil {
+				return nil, fmt.Errorf("sandbox profile %s: seccomp filter: %w", tool, err)
+			}
+		}
+		if !p.Disabled {
+			log.Printf("🔒 Sandbox profile for %s: user=%q no_new_privs=%v caps=%v seccomp=%q isolate_network=%v",
+				tool, p.User, p.NoNewPrivs, p.Capabilities, p.Seccomp, p.IsolateNetwork)
+		}
+	}
+
+	return &Sandbox{profiles: profiles, setprivPath: setprivPath, bwrapPath: bwrapPath}, nil
+}
+
+func (s *Sandbox) profile(tool string) (Profile, bool) {
+	if s == nil {
+		return Profile{}, false
+	}
+	p, ok := s.profiles[tool]
+	if !ok {
+		p, ok = s.profiles["*"]
+	}
+	if !ok || p.Disabled {
+		return Profile{}, false
+	}
+	return p, true
+}
+
+// Command builds the command that runs path with args for tool, wrapped by
+// bubblewrap (seccomp) and setpriv (user, capabilities, no_new_privs) as the
+// profile requires. A nil Sandbox returns a plain exec.CommandContext. The
+// command is tracked by the supervisor running ctx, if any.
+func (s *Sandbox) Command(ctx context.Context, tool, path string, args ...string) *exec.Cmd {
+	p, ok := s.profile(tool)
+	if !ok {
+		cmd := exec.CommandContext(ctx, path, args...)
+		addEnv(ctx, cmd)
+		supervise.Track(ctx, cmd)
+		return cmd
+	}
+
+	argv := append([]string{path}, args...)
+
+	if p.User != "" || p.NoNewPrivs || len(p.Capabilities) > 0 {
+		wrapper := []string{s.setprivPath}
+		if p.User != "" {
+			uid, gid, _ := lookupUser(p.User)
+			wrapper = append(wrapper, "--reuid="+uid, "--regid="+gid, "--clear-groups")
+		}
+		if len(p.Capabilities) > 0 {
+			caps := "+" + strings.Join(p.Capabilities, ",+")
+			wrapper = append(wrapper, "--inh-caps="+caps, "--ambient-caps="+caps)
+		}
+		if p.NoNewPrivs {
+			wrapper = append(wrapper, "--no-new-privs")
+		}
+		argv = append(append(wrapper, "--"), argv...)
+	}
+
+	var seccomp *os.File
+	if p.Seccomp != "" {
+		f, err := os.Open(p.Seccomp)
+		if err != nil {
+			// Fail closed: bwrap reports the missing filter and the scan logs a violation
+			log.Printf("⚠️ Sandbox: cannot open seccomp filter for %s: %v", tool, err)
+		} else {
+			seccomp = f
+		}
+		argv = append([]string{s.bwrapPath,
+			"--bind", "/", "/",
+			"--dev-bind", "/dev", "/dev",
+			"--proc", "/proc",
+			"--die-with-parent",
+			"--seccomp", "3",
+			"--"}, argv...)
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if seccomp != nil {
+		// Passed to the child as fd 3; the parent copy is closed when the file is collected
+		cmd.ExtraFiles = []*os.File{seccomp}
+	}
+	applyProcAttr(cmd, p)
+	addEnv(ctx, cmd)
+	supervise.Track(ctx, cmd)
+	return cmd
+}
+
+type envKey struct{}
+
+// WithEnv returns a context whose tools get vars ("KEY=value") on top of
+// the service's environment and of the variables ctx already carries, e.g.
+// a scan's advanced options or the ID of the job running it
+func WithEnv(ctx context.Context, vars []string) context.Context {
+	if len(vars) == 0 {
+		return ctx
+	}
+	inherited := Env(ctx)
+	return context.WithValue(ctx, envKey{}, append(inherited[:len(inherited):len(inherited)], vars...))
+}
+
+// Env returns the variables of WithEnv carried by ctx
+func Env(ctx context.Context) []string {
+	vars, _ := ctx.Value(envKey{}).([]string)
+	return vars
+}
+
+// addEnv sets the variables of WithEnv on the command
+func addEnv(ctx context.Context, cmd *exec.Cmd) {
+	vars := Env(ctx)
+	if len(vars) == 0 {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, vars...)
+}
+
+// lookupUser resolves "name", "uid" or "uid:gid" to numeric IDs
+func lookupUser(spec string) (string, string, error) {
+	if uid, gid, ok := strings.Cut(spec, ":"); ok {
+		return uid, gid, nil
+	}
+	u, err := user.Lookup(spec)
+	if err != nil {
+		u, err = user.LookupId(spec)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unknown user %q", spec)
+	}
+	return u.Uid, u.Gid, nil
+}
+
+// violationMarkers are stderr fragments emitted when the sandbox blocks a tool
+var violationMarkers = []string{
+	"setpriv:",
+	"bwrap:",
+	"Operation not permitted",
+	"Bad system call",
+}
+
+// ViolationLine reports whether a stderr line shows the sandbox blocking the tool
+func ViolationLine(line string) bool {
+	for _, marker := range violationMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
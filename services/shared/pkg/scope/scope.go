@@ -0,0 +1,502 @@
+// Package scope keeps scans inside the engagement. Admins list the CIDRs
+// and domains that may be scanned (allow) and those that never may (deny,
+// e.g. RFC1918 ranges or production addresses), for every project or for
+// one, and each service checks the targets of a scan against them before
+// creating it. Rules are managed through the gateway and stored in the
+// scope_rules table, which the services read on every check.
+package scope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/database"
+)
+
+// Rule actions
+const (
+	Allow = "allow" // once a project has allow rules, only their targets may be scanned
+	Deny  = "deny"  // never scanned, even when an allow rule matches
+)
+
+// resolveTimeout bounds the lookup of the hostnames of a scan
+const resolveTimeout = 3 * time.Second
+
+// SchemaSQL creates the table of scope rules, managed through the gateway
+// and read by every service that creates scans
+const SchemaSQL = `
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id UUID PRIMARY KEY,
+    action VARCHAR(10) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    project_id VARCHAR(63) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (project_id, action, value)
+);
+`
+
+// Columns are the scope_rules columns read by Scan
+const Columns = `id, action, value, project_id, description, created_by, created_at`
+
+// Rule allows or denies a CIDR (an IP is a /32 or /128) or a domain and its
+// subdomains
+type Rule struct {
+	ID          uuid.UUID `json:"id"`
+	Action      string    `json:"action"`     // allow or deny
+	Value       string    `json:"value"`      // CIDR or domain
+	ProjectID   string    `json:"project_id"` // empty for every project
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	prefix netip.Prefix // set for CIDRs by Validate
+}
+
+// Scan reads a rule selected with Columns
+func Scan(row database.Row) (*Rule, error) {
+	var r Rule
+	err := row.Scan(&r.ID, &r.Action, &r.Value, &r.ProjectID, &r.Description, &r.CreatedBy, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.parse()
+	return &r, nil
+}
+
+// parse sets the prefix of a CIDR rule
+func (r *Rule) parse() {
+	if prefix, err := netip.ParsePrefix(r.Value); err == nil {
+		r.prefix = prefix.Masked()
+	}
+}
+
+// Validate normalizes a rule: IPs become /32 or /128 CIDRs, CIDRs their
+// network address, and domains lowercase without a leading "*." or
+// trailing dot. It checks the action and the value.
+func Validate(r *Rule) error {
+	r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+	if r.Action != Allow && r.Action != Deny {
+		return fmt.Errorf("action must be %s or %s", Allow, Deny)
+	}
+	r.Description = strings.TrimSpace(r.Description)
+
+	value := strings.ToLower(strings.TrimSpace(r.Value))
+	switch {
+	case value == "":
+		return fmt.Errorf("value is required")
+	case strings.Contains(value, "/"):
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return fmt.Errorf("value %q is not a valid CIDR", r.Value)
+		}
+		r.Value = prefix.Masked().String()
+	default:
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap()
+			r.Value = netip.PrefixFrom(addr, addr.BitLen()).String()
+		} else {
+			domain := strings.TrimSuffix(strings.TrimPrefix(value, "*."), ".")
+			if !validDomain(domain) {
+				return fmt.Errorf("value %q is not an IP, CIDR or domain", r.Value)
+			}
+			r.Value = domain
+		}
+	}
+	r.parse()
+	return nil
+}
+
+func validDomain(domain string) bool {
+	if len(domain) > 253 || !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Violation is a target outside the scope and why
+type Violation struct {
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+	Rule   string `json:"rule,omitempty"` // the deny rule it matched
+}
+
+// Error is returned for scans with targets outside the scope
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	targets := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		targets[i] = v.Target
+	}
+	return fmt.Sprintf("target out of scope: %s", strings.Join(targets, ", "))
+}
+
+// Response is the body services answer an out-of-scope scan with, 403
+func (e *Error) Response() map[string]interface{} {
+	return map[string]interface{}{
+		"error":        e.Error(),
+		"out_of_scope": e.Violations,
+		"scope_rules":  "/api/scope/rules",
+	}
+}
+
+// Resolver looks up the addresses of a hostname
+type Resolver func(ctx context.Context, host string) ([]netip.Addr, error)
+
+// Checker checks scan targets against the stored rules
+type Checker struct {
+	queryRow database.QueryRowFunc
+	resolve  Resolver
+}
+
+// NewChecker returns a checker reading the rules with queryRow and
+// resolving hostnames with the system resolver
+func NewChecker(queryRow database.QueryRowFunc) *Checker {
+	return &Checker{queryRow: queryRow, resolve: func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	}}
+}
+
+// Rules returns the rules that apply to a project: its own and those of
+// every project
+func (c *Checker) Rules(ctx context.Context, project string) ([]Rule, error) {
+	// Aggregated as JSON, so that database/sql drivers can read it too
+	var raw []byte
+	err := c.queryRow(ctx, `
+		SELECT COALESCE(json_agg(json_build_object(
+			'id', id, 'action', action, 'value', value, 'project_id', project_id,
+			'description', description
+		)), '[]') FROM scope_rules WHERE project_id = '' OR project_id = $1
+	`, project).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		rules[i].parse()
+	}
+	return rules, nil
+}
+
+// Check returns an *Error when any of the targets is outside the scope of
+// the project. Each target may hold several, separated by commas or spaces.
+// A nil checker allows everything.
+func (c *Checker) Check(ctx context.Context, project string, targets ...string) error {
+	if c == nil {
+		return nil
+	}
+	rules, err := c.Rules(ctx, project)
+	if err != nil {
+		return fmt.Errorf("failed to load scope rules: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	if violations := Evaluate(ctx, rules, Split(targets...), c.resolve); len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// Split splits targets separated by commas or whitespace
+func Split(targets ...string) []string {
+	var parts []string
+	for _, target := range targets {
+		parts = append(parts, strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+	}
+	return parts
+}
+
+// target is a scan target reduced to an address range or a hostname
+type target struct {
+	first, last netip.Addr // set for IPs, CIDRs and ranges
+	host        string     // set for hostnames
+}
+
+// parseTarget reads an IP, CIDR, range (10.0.0.1-50 or 10.0.0.1-10.0.0.50),
+// nmap octet range (10.0.*.1-254), hostname, wildcard (*.example.com),
+// host:port or URL. Anything else fails, including the numeric forms
+// inet_aton and nmap accept for addresses (167772161, 10.1, 0x0a000001),
+// since they can't be told apart from the addresses they stand for.
+func parseTarget(raw string) (target, bool) {
+	value := strings.TrimSpace(raw)
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil || u.Hostname() == "" {
+			return target{}, false
+		}
+		value = u.Hostname()
+	} else if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.Trim(strings.ToLower(value), "[]")
+
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		prefix = prefix.Masked()
+		return target{first: prefix.Addr(), last: lastAddr(prefix)}, true
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return target{first: addr.Unmap(), last: addr.Unmap()}, true
+	}
+	if start, end, ok := strings.Cut(value, "-"); ok {
+		if first, err := netip.ParseAddr(start); err == nil {
+			first = first.Unmap()
+			last, err := netip.ParseAddr(end)
+			if err != nil && first.Is4() {
+				// Last-octet range: 10.0.0.1-50
+				octets := first.As4()
+				last, err = netip.ParseAddr(fmt.Sprintf("%d.%d.%d.%s", octets[0], octets[1], octets[2], end))
+			}
+			if err == nil && !last.Unmap().Less(first) {
+				return target{first: first, last: last.Unmap()}, true
+			}
+			return target{}, false
+		}
+	}
+	if first, last, ok := parseOctetRange(value); ok {
+		return target{first: first, last: last}, true
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(value, "*."), ".")
+	if !validHostname(host) {
+		return target{}, false
+	}
+	return target{host: host}, true
+}
+
+// parseOctetRange reads nmap's IPv4 octet ranges, where each octet is a
+// number, "*" or a range ("1-254", "-100", "100-"), and returns the lowest
+// and highest address they expand to
+func parseOctetRange(value string) (netip.Addr, netip.Addr, bool) {
+	octets := strings.Split(value, ".")
+	if len(octets) != 4 {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+	var low, high [4]byte
+	for i, octet := range octets {
+		l, h := 0, 255
+		if octet != "*" {
+			start, end, isRange := strings.Cut(octet, "-")
+			var ok bool
+			if l, ok = parseOctet(start, 0); !ok {
+				return netip.Addr{}, netip.Addr{}, false
+			}
+			h = l
+			if isRange {
+				if h, ok = parseOctet(end, 255); !ok || h < l {
+					return netip.Addr{}, netip.Addr{}, false
+				}
+			} else if start == "" {
+				return netip.Addr{}, netip.Addr{}, false
+			}
+		}
+		low[i], high[i] = byte(l), byte(h)
+	}
+	return netip.AddrFrom4(low), netip.AddrFrom4(high), true
+}
+
+// parseOctet reads a decimal octet without leading zeros, which inet_aton
+// would read as octal; empty is def, the open end of a range
+func parseOctet(s string, def int) (int, bool) {
+	if s == "" {
+		return def, true
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 255 || strings.Trim(s, "0123456789") != "" {
+		return 0, false
+	}
+	return n, true
+}
+
+// validHostname checks an RFC 1123 hostname whose last label isn't a number,
+// which resolvers and nmap would take as (part of) an IPv4 address
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	last := labels[len(labels)-1]
+	if strings.Trim(last, "0123456789") == "" {
+		return false
+	}
+	if hex, ok := strings.CutPrefix(last, "0x"); ok && strings.Trim(hex, "0123456789abcdef") == "" {
+		return false
+	}
+	return true
+}
+
+// lastAddr is the last address of a prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// contains reports whether rule holds the whole range
+func (r Rule) contains(first, last netip.Addr) bool {
+	return r.prefix.IsValid() && r.prefix.Contains(first) && r.prefix.Contains(last)
+}
+
+// overlaps reports whether rule holds any address of the range
+func (r Rule) overlaps(first, last netip.Addr) bool {
+	if !r.prefix.IsValid() || r.prefix.Addr().BitLen() != first.BitLen() {
+		return false
+	}
+	return !last.Less(r.prefix.Addr()) && !lastAddr(r.prefix).Less(first)
+}
+
+// matchesHost reports whether a domain rule covers a hostname
+func (r Rule) matchesHost(host string) bool {
+	return !r.prefix.IsValid() && (host == r.Value || strings.HasSuffix(host, "."+r.Value))
+}
+
+// Evaluate returns the targets the rules leave out of scope. A target is
+// out of scope when it matches a deny rule (any address of a CIDR or range,
+// or a hostname under a denied domain or resolving to a denied address), or
+// when there are allow rules and none covers it: a CIDR or range must fall
+// within one allowed CIDR, and a hostname must be under an allowed domain or
+// resolve only to allowed addresses. Hostnames that don't resolve are only
+// checked against domain rules. Targets that can't be parsed are out of
+// scope.
+func Evaluate(ctx context.Context, rules []Rule, targets []string, resolve Resolver) []Violation {
+	var allows, denies []Rule
+	for _, rule := range rules {
+		if rule.Action == Deny {
+			denies = append(denies, rule)
+		} else {
+			allows = append(allows, rule)
+		}
+	}
+
+	violations := []Violation{}
+	seen := map[string]bool{}
+	for _, raw := range targets {
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		t, ok := parseTarget(raw)
+		if !ok {
+			// A target that can't be checked may still expand into denied addresses
+			violations = append(violations, Violation{Target: raw, Reason: "not an IP, CIDR, range or hostname"})
+			continue
+		}
+		if v, out := evaluate(ctx, t, allows, denies, resolve); out {
+			v.Target = raw
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+func evaluate(ctx context.Context, t target, allows, denies []Rule, resolve Resolver) (Violation, bool) {
+	if t.host == "" {
+		for _, rule := range denies {
+			if rule.overlaps(t.first, t.last) {
+				return Violation{Reason: "denied", Rule: describe(rule)}, true
+			}
+		}
+		if len(allows) == 0 {
+			return Violation{}, false
+		}
+		for _, rule := range allows {
+			if rule.contains(t.first, t.last) {
+				return Violation{}, false
+			}
+		}
+		return Violation{Reason: "not within an allowed CIDR"}, true
+	}
+
+	for _, rule := range denies {
+		if rule.matchesHost(t.host) {
+			return Violation{Reason: "denied", Rule: describe(rule)}, true
+		}
+	}
+	var addrs []netip.Addr
+	if resolve != nil {
+		addrs, _ = resolve(ctx, t.host)
+	}
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		for _, rule := range denies {
+			if rule.overlaps(addr, addr) {
+				return Violation{Reason: fmt.Sprintf("resolves to denied address %s", addr), Rule: describe(rule)}, true
+			}
+		}
+	}
+	if len(allows) == 0 {
+		return Violation{}, false
+	}
+	for _, rule := range allows {
+		if rule.matchesHost(t.host) {
+			return Violation{}, false
+		}
+	}
+	if len(addrs) == 0 {
+		return Violation{Reason: "not under an allowed domain"}, true
+	}
+	for _, addr := range addrs {
+		allowed := false
+		for _, rule := range allows {
+			if rule.contains(addr.Unmap(), addr.Unmap()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return Violation{Reason: fmt.Sprintf("not under an allowed domain and resolves to %s, outside the allowed CIDRs", addr.Unmap())}, true
+		}
+	}
+	return Violation{}, false
+}
+
+// describe names a rule in a violation: its value and description
+func describe(rule Rule) string {
+	if rule.Description != "" {
+		return fmt.Sprintf("%s (%s)", rule.Value, rule.Description)
+	}
+	return rule.Value
+}
@@ -0,0 +1,58 @@
+package scope
+
+import (
+	"context"
+	"testing"
+)
+
+func denyRule(t *testing.T, value string) Rule {
+	t.Helper()
+	r := Rule{Action: Deny, Value: value}
+	if err := Validate(&r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestDenyCoversNmapTargetForms(t *testing.T) {
+	rules := []Rule{denyRule(t, "10.0.0.0/8")}
+	for _, target := range []string{
+		"10.0.0.*",
+		"10.0.0-5.1-254",
+		"10.*.*.*",
+		"10.0.0.-100",
+		"10.0.0.100-",
+		"167772161",
+		"10.1",
+		"0x0a000001",
+		"010.0.0.1",
+		"http://167772161/",
+		"10.0.0.1,5",
+		"example.com/24",
+	} {
+		if violations := Evaluate(context.Background(), rules, Split(target), nil); len(violations) == 0 {
+			t.Errorf("%s is in scope despite the 10.0.0.0/8 deny rule", target)
+		}
+	}
+}
+
+func TestOctetRangeBounds(t *testing.T) {
+	first, last, ok := parseOctetRange("10.0.0-5.1-254")
+	if !ok || first.String() != "10.0.0.1" || last.String() != "10.0.5.254" {
+		t.Errorf("got %v-%v (%v)", first, last, ok)
+	}
+	for _, bad := range []string{"10.0.0", "10.0.0.256", "10.0.0.5-1", "10.0.0.01", "10.0.0.a"} {
+		if _, _, ok := parseOctetRange(bad); ok {
+			t.Errorf("%s parsed as an octet range", bad)
+		}
+	}
+}
+
+func TestOutsideDenyStaysInScope(t *testing.T) {
+	rules := []Rule{denyRule(t, "10.0.0.0/8")}
+	for _, target := range []string{"192.168.1.*", "192.168.1.1-254", "scanme.nmap.org", "router", "https://example.com:8443/x", "10e.example"} {
+		if violations := Evaluate(context.Background(), rules, Split(target), nil); len(violations) > 0 {
+			t.Errorf("%s is out of scope: %+v", target, violations)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package screenshots links assets to the gowitness scans queued for them
+// when a network scan finds web ports that weren't open in the asset's
+// previous scan. The web service writes the links, and the network service
+// shows them in the asset's timeline.
+package screenshots
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/database"
+)
+
+// SchemaSQL creates the table of links, shared by the web and network services
+const SchemaSQL = `
+CREATE TABLE IF NOT EXISTS asset_screenshots (
+    id UUID PRIMARY KEY,
+    asset VARCHAR(255) NOT NULL,
+    hostname VARCHAR(255) NOT NULL DEFAULT '',
+    urls TEXT[] NOT NULL,
+    network_scan_id UUID NOT NULL,
+    web_scan_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_asset_screenshots_asset ON asset_screenshots(asset);
+CREATE INDEX IF NOT EXISTS idx_asset_screenshots_hostname ON asset_screenshots(hostname);
+`
+
+// Columns are the asset_screenshots columns read by Scan
+const Columns = `id, asset, hostname, urls, network_scan_id, web_scan_id, created_at`
+
+// Screenshot is a gowitness scan of the newly opened web ports of an asset
+type Screenshot struct {
+	ID            uuid.UUID `json:"id"`
+	Asset         string    `json:"asset"` // the host's address
+	Hostname      string    `json:"hostname,omitempty"`
+	URLs          []string  `json:"urls"`
+	NetworkScanID uuid.UUID `json:"network_scan_id"` // the scan that found the ports
+	WebScanID     uuid.UUID `json:"web_scan_id"`     // the gowitness scan
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Scan reads a screenshot selected with Columns
+func Scan(row database.Row) (*Screenshot, error) {
+	var s Screenshot
+	err := row.Scan(&s.ID, &s.Asset, &s.Hostname, &s.URLs, &s.NetworkScanID, &s.WebScanID, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
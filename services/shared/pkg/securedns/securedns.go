@@ -0,0 +1,435 @@
+// Package securedns resolves names over DNS over HTTPS (RFC 8484) and DNS
+// over TLS (RFC 7858), for scanners on networks that block plain DNS to
+// anything but their own resolvers. It plugs into Go's resolver, so a
+// *net.Resolver from it works wherever the system one does, and it keeps
+// per-endpoint health so a scan can report which resolvers answered.
+package securedns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProtocolDoH = "doh"
+	ProtocolDoT = "dot"
+
+	dotPort        = "853"
+	defaultTimeout = 5 * time.Second
+	// maxMessage is the largest DNS message a DoH answer may carry
+	maxMessage = 65535
+	// unhealthyAfter is how many failures in a row make an endpoint
+	// unhealthy, so queries start with the next one
+	unhealthyAfter = 3
+)
+
+// Endpoint is a DoH URL (https://dns.example/dns-query) or a DoT server
+// (tls://1.1.1.1 or tls://dns.example:853)
+type Endpoint struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"` // the URL for DoH, host:port for DoT
+}
+
+func (e Endpoint) String() string {
+	if e.Protocol == ProtocolDoT {
+		return "tls://" + e.Address
+	}
+	return e.Address
+}
+
+// ParseEndpoint reads a resolver: an https:// URL for DoH, or tls://host[:port]
+// for DoT
+func ParseEndpoint(value string) (Endpoint, error) {
+	value = strings.TrimSpace(value)
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return Endpoint{}, fmt.Errorf("resolver %q must be an https:// (DoH) or tls:// (DoT) URL", value)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return Endpoint{Protocol: ProtocolDoH, Address: u.String()}, nil
+	case "tls":
+		if u.Path != "" && u.Path != "/" {
+			return Endpoint{}, fmt.Errorf("resolver %q: DoT servers have no path", value)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), dotPort)
+		}
+		return Endpoint{Protocol: ProtocolDoT, Address: host}, nil
+	default:
+		return Endpoint{}, fmt.Errorf("resolver %q must be an https:// (DoH) or tls:// (DoT) URL", value)
+	}
+}
+
+// ParseEndpoints reads comma-separated resolvers, e.g. DNS_RESOLVERS
+func ParseEndpoints(value string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		endpoint, err := ParseEndpoint(item)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// FromConfiguration reads the resolvers of a scan's configuration: a list of
+// URLs or one comma-separated string. It returns nil when there are none.
+func FromConfiguration(value interface{}) ([]Endpoint, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return ParseEndpoints(v)
+	case []string:
+		return ParseEndpoints(strings.Join(v, ","))
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("resolvers must be a list of URLs")
+			}
+			items = append(items, s)
+		}
+		return ParseEndpoints(strings.Join(items, ","))
+	default:
+		return nil, fmt.Errorf("resolvers must be a list of URLs")
+	}
+}
+
+// Health is how an endpoint did during a scan
+type Health struct {
+	Endpoint     string  `json:"endpoint"`
+	Protocol     string  `json:"protocol"`
+	Queries      int     `json:"queries"`
+	Failures     int     `json:"failures"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	LastError    string  `json:"last_error,omitempty"`
+	Healthy      bool    `json:"healthy"`
+}
+
+// String summarizes h for a scan log, e.g. "tls://1.1.1.1:853 answered
+// 48/50 queries (avg 31.2 ms)"
+func (h Health) String() string {
+	summary := fmt.Sprintf("%s answered %d/%d queries", h.Endpoint, h.Queries-h.Failures, h.Queries)
+	if h.Queries > h.Failures {
+		summary += fmt.Sprintf(" (avg %.1f ms)", h.AvgLatencyMs)
+	}
+	if h.LastError != "" {
+		summary += ", last error: " + h.LastError
+	}
+	return summary
+}
+
+type endpointState struct {
+	Endpoint
+	queries     int
+	failures    int
+	consecutive int
+	latency     time.Duration
+	lastError   string
+}
+
+// Resolver sends DNS queries to its endpoints in order, moving on to the
+// next one when an endpoint fails. Use one per scan to get that scan's
+// health.
+type Resolver struct {
+	endpoints []*endpointState
+	timeout   time.Duration
+	client    *http.Client
+
+	mu sync.Mutex
+}
+
+// New returns a resolver over endpoints; timeout bounds each query to an
+// endpoint (5 seconds when zero)
+func New(endpoints []Endpoint, timeout time.Duration) *Resolver {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	r := &Resolver{
+		timeout: timeout,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				ForceAttemptHTTP2: true,
+				MaxIdleConns:      10,
+				IdleConnTimeout:   90 * time.Second,
+			},
+		},
+	}
+	for _, e := range endpoints {
+		r.endpoints = append(r.endpoints, &endpointState{Endpoint: e})
+	}
+	return r
+}
+
+// NetResolver returns a *net.Resolver that sends every query through r
+func (r *Resolver) NetResolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		// Go's resolver frames queries for streams (a two byte length, as
+		// on TCP) when the connection isn't a PacketConn, which is what
+		// DoT expects and what exchangeConn unwraps for DoH
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &exchangeConn{ctx: ctx, resolver: r}, nil
+		},
+	}
+}
+
+// Health returns how each endpoint did so far
+func (r *Resolver) Health() []Health {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := make([]Health, 0, len(r.endpoints))
+	for _, e := range r.endpoints {
+		h := Health{
+			Endpoint:  e.String(),
+			Protocol:  e.Protocol,
+			Queries:   e.queries,
+			Failures:  e.failures,
+			LastError: e.lastError,
+			Healthy:   e.queries == 0 || e.consecutive < unhealthyAfter,
+		}
+		if answered := e.queries - e.failures; answered > 0 {
+			h.AvgLatencyMs = float64(e.latency.Microseconds()) / float64(answered) / 1000
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// Healthy reports whether any endpoint answers
+func (r *Resolver) Healthy() bool {
+	for _, h := range r.Health() {
+		if h.Healthy && h.Queries > h.Failures {
+			return true
+		}
+	}
+	return false
+}
+
+// order returns the endpoints to try, healthy ones first and otherwise in
+// their configured order
+func (r *Resolver) order() []*endpointState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := make([]*endpointState, 0, len(r.endpoints))
+	for _, e := range r.endpoints {
+		if e.consecutive < unhealthyAfter {
+			ordered = append(ordered, e)
+		}
+	}
+	for _, e := range r.endpoints {
+		if e.consecutive >= unhealthyAfter {
+			ordered = append(ordered, e)
+		}
+	}
+	return ordered
+}
+
+func (r *Resolver) record(e *endpointState, took time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.queries++
+	if err != nil {
+		e.failures++
+		e.consecutive++
+		e.lastError = err.Error()
+		return
+	}
+	e.consecutive = 0
+	e.latency += took
+}
+
+// exchange sends a DNS message and returns the answer of the first
+// endpoint that gives one
+func (r *Resolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if len(r.endpoints) == 0 {
+		return nil, errors.New("no DoH or DoT resolvers configured")
+	}
+	var lastErr error
+	for _, e := range r.order() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		qctx, cancel := context.WithTimeout(ctx, r.timeout)
+		start := time.Now()
+		var answer []byte
+		var err error
+		if e.Protocol == ProtocolDoT {
+			answer, err = r.exchangeDoT(qctx, e.Address, query)
+		} else {
+			answer, err = r.exchangeDoH(qctx, e.Address, query)
+		}
+		cancel()
+		if ctx.Err() != nil {
+			// The scan was cancelled, which says nothing about the endpoint
+			return nil, ctx.Err()
+		}
+		r.record(e, time.Since(start), err)
+		if err == nil {
+			return answer, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", e, err)
+	}
+	return nil, lastErr
+}
+
+func (r *Resolver) exchangeDoH(ctx context.Context, endpoint string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(answer) < 12 || len(answer) > maxMessage {
+		return nil, fmt.Errorf("invalid DNS answer of %d bytes", len(answer))
+	}
+	return answer, nil
+}
+
+func (r *Resolver) exchangeDoT(ctx context.Context, address string, query []byte) ([]byte, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := tls.Dialer{Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// exchangeConn is the connection Go's resolver writes its length-prefixed
+// queries to; each query is answered through the resolver's endpoints and
+// the answer is read back with the same framing
+type exchangeConn struct {
+	ctx      context.Context
+	resolver *Resolver
+
+	mu       sync.Mutex
+	deadline time.Time
+	pending  []byte
+	answers  bytes.Buffer
+}
+
+func (c *exchangeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.pending = append(c.pending, b...)
+	ctx, cancel := c.context()
+	c.mu.Unlock()
+	defer cancel()
+
+	for {
+		c.mu.Lock()
+		if len(c.pending) < 2 || len(c.pending) < 2+int(binary.BigEndian.Uint16(c.pending)) {
+			c.mu.Unlock()
+			return len(b), nil
+		}
+		size := int(binary.BigEndian.Uint16(c.pending))
+		query := append([]byte(nil), c.pending[2:2+size]...)
+		c.pending = c.pending[2+size:]
+		c.mu.Unlock()
+
+		answer, err := c.resolver.exchange(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		c.mu.Lock()
+		var length [2]byte
+		binary.BigEndian.PutUint16(length[:], uint16(len(answer)))
+		c.answers.Write(length[:])
+		c.answers.Write(answer)
+		c.mu.Unlock()
+	}
+}
+
+func (c *exchangeConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.answers.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.answers.Read(b)
+}
+
+// context bounds a query by the resolver's context and the deadline Go's
+// resolver set on the connection
+func (c *exchangeConn) context() (context.Context, context.CancelFunc) {
+	if c.deadline.IsZero() {
+		return context.WithCancel(c.ctx)
+	}
+	return context.WithDeadline(c.ctx, c.deadline)
+}
+
+func (c *exchangeConn) Close() error                       { return nil }
+func (c *exchangeConn) LocalAddr() net.Addr                { return exchangeAddr{} }
+func (c *exchangeConn) RemoteAddr() net.Addr               { return exchangeAddr{} }
+func (c *exchangeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *exchangeConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+func (c *exchangeConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+type exchangeAddr struct{}
+
+func (exchangeAddr) Network() string { return "securedns" }
+func (exchangeAddr) String() string  { return "securedns" }
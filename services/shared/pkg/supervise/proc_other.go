@@ -0,0 +1,15 @@
+//go:build !unix
+
+package supervise
+
+import "os"
+
+// maxRSS is unknown outside Unix
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
+
+// killed always reports false outside Unix
+func killed(err error) bool {
+	return false
+}
@@ -0,0 +1,226 @@
+// Package supervise runs scan tools under a retry policy: transient
+// failures (the tool killed for running out of memory, a DNS or network
+// hiccup) are retried with a jittered, doubling delay, while permanent ones
+// (bad arguments, unreachable targets, a cancelled scan) fail at once. Every
+// attempt is reported with its exit code and resource usage.
+package supervise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy bounds the retries of transient failures
+type Policy struct {
+	// MaxAttempts counts the first run; 1 or less disables retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each one
+	// and jittered by ±50%
+	BaseDelay time.Duration
+}
+
+// Attempt is one run of a tool
+type Attempt struct {
+	Number      int
+	MaxAttempts int
+	Err         error
+	ExitCode    int // -1 when unknown: the tool never started or was killed by a signal
+	Duration    time.Duration
+	UserTime    time.Duration
+	SystemTime  time.Duration
+	MaxRSS      int64 // bytes, 0 when unknown
+	Transient   bool
+	Reason      string        // why the failure is transient
+	RetryIn     time.Duration // 0 when not retried
+}
+
+// String describes the attempt for scan logs, e.g. "attempt 1/3 failed
+// after 42s (exit 137, cpu 30.1s user / 2.3s sys, max RSS 1.9 GB): killed,
+// likely out of memory; retrying in 6s"
+func (a Attempt) String() string {
+	usage := []string{"exit status unknown"}
+	if a.ExitCode >= 0 {
+		usage[0] = fmt.Sprintf("exit %d", a.ExitCode)
+	}
+	if a.UserTime > 0 || a.SystemTime > 0 {
+		usage = append(usage, fmt.Sprintf("cpu %s user / %s sys", round(a.UserTime), round(a.SystemTime)))
+	}
+	if a.MaxRSS > 0 {
+		usage = append(usage, "max RSS "+formatBytes(a.MaxRSS))
+	}
+
+	if a.Err == nil {
+		return fmt.Sprintf("attempt %d/%d succeeded after %s (%s)", a.Number, a.MaxAttempts, round(a.Duration), strings.Join(usage, ", "))
+	}
+	msg := fmt.Sprintf("attempt %d/%d failed after %s (%s): ", a.Number, a.MaxAttempts, round(a.Duration), strings.Join(usage, ", "))
+	if a.Transient {
+		msg += a.Reason
+	} else {
+		msg += a.Err.Error()
+	}
+	if a.RetryIn > 0 {
+		msg += fmt.Sprintf("; retrying in %s", round(a.RetryIn))
+	} else if a.Transient {
+		msg += "; no attempts left"
+	}
+	return msg
+}
+
+// Run calls attempt until it succeeds, fails permanently or uses up the
+// policy's attempts, and returns the last error. report, when set, is called
+// after each attempt. Commands created for the attempt's context are
+// tracked (see Track) for their exit code and resource usage.
+func (p Policy) Run(ctx context.Context, attempt func(ctx context.Context) error, report func(Attempt)) error {
+	max := p.MaxAttempts
+	if max < 1 {
+		max = 1
+	}
+	for n := 1; ; n++ {
+		t := &tracker{}
+		started := time.Now()
+		err := attempt(context.WithValue(ctx, trackerKey{}, t))
+
+		a := Attempt{Number: n, MaxAttempts: max, Err: err, ExitCode: -1, Duration: time.Since(started)}
+		t.usage(&a)
+		if a.ExitCode < 0 {
+			var exit interface{ ExitCode() int }
+			if errors.As(err, &exit) && exit.ExitCode() >= 0 {
+				a.ExitCode = exit.ExitCode()
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			a.Transient, a.Reason = Classify(err, a.ExitCode)
+		}
+		if a.Transient && n < max {
+			a.RetryIn = p.delay(n)
+		}
+		if report != nil {
+			report(a)
+		}
+		if a.RetryIn == 0 {
+			return err
+		}
+
+		timer := time.NewTimer(a.RetryIn)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns the jittered wait before retry n
+func (p Policy) delay(n int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = time.Second
+	}
+	d := base << uint(n-1)
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// transientPatterns are error or stderr fragments of failures that may not
+// happen again, lower case
+var transientPatterns = []struct{ pattern, reason string }{
+	{"oomkilled", "killed, out of memory"},
+	{"temporary failure in name resolution", "DNS resolution failure"},
+	{"server misbehaving", "DNS resolution failure"},
+	{"no servers could be reached", "DNS resolution failure"},
+	{"i/o timeout", "network timeout"},
+	{"tls handshake timeout", "network timeout"},
+	{"connection reset by peer", "connection reset"},
+	{"resource temporarily unavailable", "resources exhausted"},
+	{"cannot allocate memory", "resources exhausted"},
+}
+
+// Classify reports whether a failure is transient and why. Exit code 137
+// (SIGKILL, which the OOM killer sends) and the patterns above are; any
+// other failure is permanent. The error's text should include the tool's
+// stderr (exec.ExitError's Stderr is read too).
+func Classify(err error, exitCode int) (bool, string) {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, ""
+	}
+	if exitCode == 137 || killed(err) {
+		return true, "killed, likely out of memory"
+	}
+
+	text := err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		text += "\n" + string(exitErr.Stderr)
+	}
+	text = strings.ToLower(text)
+	for _, p := range transientPatterns {
+		if strings.Contains(text, p.pattern) {
+			return true, p.reason
+		}
+	}
+	return false, ""
+}
+
+type trackerKey struct{}
+
+// tracker collects the commands of an attempt
+type tracker struct {
+	mu   sync.Mutex
+	cmds []*exec.Cmd
+}
+
+// Track records cmd as part of the attempt running with ctx, so its exit
+// code and resource usage are reported once it finished. It does nothing
+// outside of Run.
+func Track(ctx context.Context, cmd *exec.Cmd) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.mu.Lock()
+		t.cmds = append(t.cmds, cmd)
+		t.mu.Unlock()
+	}
+}
+
+// usage adds up the resources of the finished commands; the exit code is
+// the last one's
+func (t *tracker) usage(a *Attempt) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, cmd := range t.cmds {
+		state := cmd.ProcessState
+		if state == nil {
+			continue
+		}
+		a.ExitCode = state.ExitCode()
+		a.UserTime += state.UserTime()
+		a.SystemTime += state.SystemTime()
+		if rss := maxRSS(state); rss > a.MaxRSS {
+			a.MaxRSS = rss
+		}
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,372 @@
+// Package tlspolicy turns testssl.sh results into policy decisions. Admins
+// define the TLS configuration targets must meet (minimum protocol, banned
+// ciphers, minimum key sizes, maximum certificate lifetime), for every
+// project or for one, and the web service evaluates the findings of each
+// testssl.sh scan against it, reporting every breach as a finding of its
+// own. Policies are managed through the gateway and stored in the
+// tls_policies table.
+package tlspolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/security-scanner/shared/pkg/database"
+)
+
+// SchemaSQL creates the table of TLS policies, managed through the gateway
+// and read by the web service after each testssl.sh scan
+const SchemaSQL = `
+CREATE TABLE IF NOT EXISTS tls_policies (
+    project_id VARCHAR(63) PRIMARY KEY,
+    min_protocol VARCHAR(10) NOT NULL DEFAULT '',
+    banned_ciphers JSONB NOT NULL DEFAULT '[]',
+    min_rsa_bits INTEGER NOT NULL DEFAULT 0,
+    min_ecc_bits INTEGER NOT NULL DEFAULT 0,
+    max_cert_days INTEGER NOT NULL DEFAULT 0,
+    description TEXT NOT NULL DEFAULT '',
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Columns are the tls_policies columns read by Scan
+const Columns = `project_id, min_protocol, banned_ciphers, min_rsa_bits, min_ecc_bits, max_cert_days, description, updated_by, updated_at`
+
+// Rules of a policy, used in the finding IDs of its violations
+const (
+	RuleMinProtocol  = "min_protocol"
+	RuleBannedCipher = "banned_cipher"
+	RuleRSAKeySize   = "min_rsa_bits"
+	RuleECCKeySize   = "min_ecc_bits"
+	RuleCertLifetime = "max_cert_days"
+)
+
+// Protocols testssl.sh reports, oldest first, with the IDs of its findings
+var Protocols = []struct {
+	Name string
+	ID   string
+}{
+	{"SSLv2", "SSLv2"},
+	{"SSLv3", "SSLv3"},
+	{"TLS1.0", "TLS1"},
+	{"TLS1.1", "TLS1_1"},
+	{"TLS1.2", "TLS1_2"},
+	{"TLS1.3", "TLS1_3"},
+}
+
+// Policy is the TLS configuration the targets of a project must meet; a
+// zero field is not checked
+type Policy struct {
+	ProjectID     string    `json:"project_id"`     // empty for every project
+	MinProtocol   string    `json:"min_protocol"`   // e.g. TLS1.2: older protocols must not be offered
+	BannedCiphers []string  `json:"banned_ciphers"` // e.g. RC4, 3DES, NULL: matched against cipher names
+	MinRSABits    int       `json:"min_rsa_bits"`
+	MinECCBits    int       `json:"min_ecc_bits"`
+	MaxCertDays   int       `json:"max_cert_days"` // from notBefore to notAfter
+	Description   string    `json:"description"`
+	UpdatedBy     string    `json:"updated_by,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Scan reads a policy selected with Columns
+func Scan(row database.Row) (*Policy, error) {
+	var p Policy
+	var ciphers []byte
+	err := row.Scan(&p.ProjectID, &p.MinProtocol, &ciphers, &p.MinRSABits, &p.MinECCBits,
+		&p.MaxCertDays, &p.Description, &p.UpdatedBy, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ciphers, &p.BannedCiphers); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// protocolIndex returns the position of a protocol in Protocols, accepting
+// testssl.sh's spellings (TLSv1.2, TLS1_2, tls1.2)
+func protocolIndex(name string) int {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	name = strings.NewReplacer("TLSV", "TLS", "_", ".").Replace(name)
+	if name == "TLS1" {
+		name = "TLS1.0"
+	}
+	for i, p := range Protocols {
+		if strings.ToUpper(p.Name) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate normalizes a policy: the protocol to its name in Protocols and
+// banned ciphers uppercase and deduplicated. It checks that the policy
+// checks something.
+func Validate(p *Policy) error {
+	p.Description = strings.TrimSpace(p.Description)
+	if p.MinProtocol = strings.TrimSpace(p.MinProtocol); p.MinProtocol != "" {
+		i := protocolIndex(p.MinProtocol)
+		if i < 0 {
+			names := make([]string, len(Protocols))
+			for j, proto := range Protocols {
+				names[j] = proto.Name
+			}
+			return fmt.Errorf("min_protocol must be one of %s", strings.Join(names, ", "))
+		}
+		p.MinProtocol = Protocols[i].Name
+	}
+
+	seen := map[string]bool{}
+	ciphers := []string{}
+	for _, c := range p.BannedCiphers {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" || seen[c] {
+			continue
+		}
+		if strings.ContainsAny(c, " ,") {
+			return fmt.Errorf("banned cipher %q must be a single name or fragment", c)
+		}
+		seen[c] = true
+		ciphers = append(ciphers, c)
+	}
+	sort.Strings(ciphers)
+	p.BannedCiphers = ciphers
+
+	switch {
+	case p.MinRSABits < 0 || p.MinECCBits < 0 || p.MaxCertDays < 0:
+		return errors.New("key sizes and certificate lifetime cannot be negative")
+	case p.MinRSABits > 16384 || p.MinECCBits > 1024:
+		return errors.New("minimum key sizes are in bits, at most 16384 for RSA and 1024 for ECC")
+	case p.MinProtocol == "" && len(p.BannedCiphers) == 0 && p.MinRSABits == 0 && p.MinECCBits == 0 && p.MaxCertDays == 0:
+		return errors.New("the policy must set at least one rule")
+	}
+	return nil
+}
+
+// Finding is a testssl.sh finding, as in its --jsonfile output
+type Finding struct {
+	ID      string
+	Finding string
+}
+
+// Violation is a breach of a policy found in a scan
+type Violation struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Detail   string `json:"detail"`
+	Finding  string `json:"finding"` // ID of the testssl.sh finding showing it
+	Evidence string `json:"evidence"`
+}
+
+var (
+	keySizeRe  = regexp.MustCompile(`(?i)\b(RSA|EC|ECDSA|DSA|EdDSA)\S*\s+(\d+)\s*bits?`)
+	certDateRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}`)
+)
+
+// offered tells whether a protocol or cipher list finding says it is
+// offered ("offered", "offered (deprecated)", ...; not "not offered")
+func offered(finding string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(finding)), "offered")
+}
+
+// baseID strips the certificate suffix testssl.sh adds to the IDs of
+// servers with several certificates ("cert_keySize <hostCert#1>")
+func baseID(id string) (string, string) {
+	base, cert, _ := strings.Cut(id, " ")
+	return base, strings.TrimSpace(cert)
+}
+
+// Evaluate returns the violations of a policy in the findings of a scan
+func Evaluate(p *Policy, findings []Finding) []Violation {
+	if p == nil {
+		return nil
+	}
+	violations := []Violation{}
+	seenCiphers := map[string]bool{}
+	notBefore := map[string]time.Time{}
+	notAfter := map[string]time.Time{}
+	minProtocol := protocolIndex(p.MinProtocol)
+
+	for _, f := range findings {
+		id, cert := baseID(f.ID)
+		switch {
+		case minProtocol > 0 && protocolIndexByID(id) >= 0 && protocolIndexByID(id) < minProtocol:
+			if offered(f.Finding) {
+				violations = append(violations, Violation{
+					Rule:     RuleMinProtocol,
+					Severity: "high",
+					Detail:   fmt.Sprintf("%s is offered; the policy requires %s or later", Protocols[protocolIndexByID(id)].Name, p.MinProtocol),
+					Finding:  f.ID,
+					Evidence: f.Finding,
+				})
+			}
+
+		case len(p.BannedCiphers) > 0 && strings.HasPrefix(id, "cipherlist_"):
+			// Cipher categories (cipherlist_NULL, cipherlist_3DES_IDEA, ...)
+			category := strings.ToUpper(strings.TrimPrefix(id, "cipherlist_"))
+			for _, banned := range p.BannedCiphers {
+				if offered(f.Finding) && strings.Contains(category, banned) && !seenCiphers[category] {
+					seenCiphers[category] = true
+					violations = append(violations, Violation{
+						Rule:     RuleBannedCipher,
+						Severity: "high",
+						Detail:   fmt.Sprintf("%s ciphers are offered; the policy bans %s", category, banned),
+						Finding:  f.ID,
+						Evidence: f.Finding,
+					})
+				}
+			}
+
+		case len(p.BannedCiphers) > 0 && strings.HasPrefix(id, "cipher"):
+			// Individual ciphers (cipher-tls1_2_xc013, cipherorder_TLSv1_2,
+			// cipher_negotiated): their names hold a dash or an underscore.
+			// Findings of a single cipher name it twice, OpenSSL and IANA.
+			single := strings.HasPrefix(id, "cipher-") || strings.HasPrefix(id, "cipher_x")
+		names:
+			for _, name := range strings.Fields(f.Finding) {
+				name = strings.ToUpper(strings.Trim(name, ",()"))
+				if !strings.ContainsAny(name, "-_") || seenCiphers[name] {
+					continue
+				}
+				for _, banned := range p.BannedCiphers {
+					if strings.Contains(name, banned) {
+						seenCiphers[name] = true
+						violations = append(violations, Violation{
+							Rule:     RuleBannedCipher,
+							Severity: "high",
+							Detail:   fmt.Sprintf("Cipher %s is offered; the policy bans %s", name, banned),
+							Finding:  f.ID,
+							Evidence: f.Finding,
+						})
+						if single {
+							break names
+						}
+						break
+					}
+				}
+			}
+
+		case id == "cert_keySize":
+			if v, ok := keySizeViolation(p, f, cert); ok {
+				violations = append(violations, v)
+			}
+
+		case id == "cert_notBefore" || id == "cert_notAfter":
+			at, err := time.Parse("2006-01-02 15:04", certDateRe.FindString(f.Finding))
+			if err != nil {
+				continue
+			}
+			if id == "cert_notBefore" {
+				notBefore[cert] = at
+			} else {
+				notAfter[cert] = at
+			}
+		}
+	}
+
+	if p.MaxCertDays > 0 {
+		certs := make([]string, 0, len(notAfter))
+		for cert := range notAfter {
+			certs = append(certs, cert)
+		}
+		sort.Strings(certs)
+		for _, cert := range certs {
+			from, ok := notBefore[cert]
+			if !ok {
+				continue
+			}
+			days := int(notAfter[cert].Sub(from).Hours() / 24)
+			if days > p.MaxCertDays {
+				id := strings.TrimSpace("cert_notAfter " + cert)
+				violations = append(violations, Violation{
+					Rule:     RuleCertLifetime,
+					Severity: "medium",
+					Detail:   fmt.Sprintf("Certificate is valid for %d days; the policy allows at most %d", days, p.MaxCertDays),
+					Finding:  id,
+					Evidence: fmt.Sprintf("%s to %s", from.Format("2006-01-02"), notAfter[cert].Format("2006-01-02")),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// protocolIndexByID returns the position in Protocols of the protocol of a
+// finding ID, or -1
+func protocolIndexByID(id string) int {
+	for i, p := range Protocols {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// keySizeViolation checks a cert_keySize finding ("RSA 2048 bits (exponent
+// is 65537)", "EC 256 bits (curve P-256)") against the minimum key sizes
+func keySizeViolation(p *Policy, f Finding, cert string) (Violation, bool) {
+	m := keySizeRe.FindStringSubmatch(f.Finding)
+	if m == nil {
+		return Violation{}, false
+	}
+	bits, _ := strconv.Atoi(m[2])
+	rule, minimum, kind := RuleRSAKeySize, p.MinRSABits, "RSA"
+	if strings.HasPrefix(strings.ToUpper(m[1]), "EC") || strings.EqualFold(m[1], "EdDSA") {
+		rule, minimum, kind = RuleECCKeySize, p.MinECCBits, "ECC"
+	}
+	if minimum == 0 || bits >= minimum {
+		return Violation{}, false
+	}
+	detail := fmt.Sprintf("%s key of %d bits; the policy requires at least %d", kind, bits, minimum)
+	if cert != "" {
+		detail += " (" + cert + ")"
+	}
+	return Violation{Rule: rule, Severity: "high", Detail: detail, Finding: f.ID, Evidence: f.Finding}, true
+}
+
+// Loader reads the policy that applies to a project
+type Loader struct {
+	queryRow database.QueryRowFunc
+}
+
+// NewLoader returns a loader reading the policies with queryRow
+func NewLoader(queryRow database.QueryRowFunc) *Loader {
+	return &Loader{queryRow: queryRow}
+}
+
+// For returns the policy of a project, or the one of every project when it
+// has none; nil when neither is defined. A nil loader has no policies.
+func (l *Loader) For(ctx context.Context, project string) (*Policy, error) {
+	if l == nil {
+		return nil, nil
+	}
+	// Aggregated as JSON, so that database/sql drivers can read it too
+	var raw []byte
+	err := l.queryRow(ctx, `
+		SELECT COALESCE((
+			SELECT json_build_object(
+				'project_id', project_id, 'min_protocol', min_protocol,
+				'banned_ciphers', banned_ciphers, 'min_rsa_bits', min_rsa_bits,
+				'min_ecc_bits', min_ecc_bits, 'max_cert_days', max_cert_days,
+				'description', description
+			) FROM tls_policies WHERE project_id = '' OR project_id = $1
+			ORDER BY project_id DESC LIMIT 1
+		), 'null')
+	`, project).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	var p *Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/events"
+	"github.com/security-scanner/shared/pkg/internalauth"
+	"github.com/security-scanner/shared/pkg/objectstore"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
 	"github.com/security-scanner/web-service/internal/api/handlers"
 	"github.com/security-scanner/web-service/internal/api/middleware"
+	"github.com/security-scanner/web-service/internal/artifacts"
+	"github.com/security-scanner/web-service/internal/autoscreenshot"
 	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
+	"github.com/security-scanner/web-service/internal/noise"
 	"github.com/security-scanner/web-service/internal/scanner"
+	"github.com/security-scanner/web-service/internal/tools"
+	"github.com/security-scanner/web-service/internal/verification"
 	"github.com/security-scanner/web-service/pkg/config"
 )
 
@@ -16,7 +33,7 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	log.Printf("Starting Web Service (Nuclei, ffuf, Gowitness, testssl.sh) on port %s...", cfg.Port)
+	log.Printf("Starting Web Service (Nuclei, ffuf, Gowitness, testssl.sh, sqlmap) on port %s...", cfg.Port)
 	log.Printf("Environment: %s", cfg.Environment)
 
 	// Connect to database
@@ -27,21 +44,227 @@ func main() {
 	defer db.Close()
 	log.Println("Connected to database")
 
+	// Background jobs run on the replica holding their lease (job_leases)
+	leases, err := db.Leases(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize job leases: %v", err)
+	}
+
+	// Central configuration overrides (platform_config), polled for hot reload
+	runtimeConfig, err := runtimeconfig.NewStore(db.Pool, "web", time.Duration(cfg.ConfigReloadInterval)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to load runtime configuration: %v", err)
+	}
+	go runtimeConfig.Start(context.Background())
+
+	// Settings that need a restart are read once at startup
+	cfg.EventBroker = runtimeConfig.Get("events.broker", cfg.EventBroker)
+
+	// Initialize event bus (nil when no broker, syslog, SIEM connector or notification webhook is configured)
+	var publishers []events.Publisher
+	brokerPublisher, err := events.NewBrokerPublisher(cfg.EventBroker, cfg.EventBrokerURL, cfg.EventTopic, "web-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize event broker: %v", err)
+	}
+	if brokerPublisher != nil {
+		publishers = append(publishers, brokerPublisher)
+	}
+	if cfg.SyslogAddress != "" {
+		syslogPublisher, err := events.NewSyslogPublisher(cfg.SyslogNetwork, cfg.SyslogAddress, cfg.SyslogFormat, cfg.SyslogFieldMapping, "web-service")
+		if err != nil {
+			log.Fatalf("Failed to initialize SIEM syslog output: %v", err)
+		}
+		log.Printf("🛰️ Sending findings to SIEM as %s over %s (%s)", cfg.SyslogFormat, cfg.SyslogNetwork, cfg.SyslogAddress)
+		publishers = append(publishers, syslogPublisher)
+	}
+	connectors, err := events.LoadConnectors(cfg.SIEMConnectors)
+	if err != nil {
+		log.Fatalf("Failed to initialize SIEM connectors: %v", err)
+	}
+	publishers = append(publishers, connectors...)
+	if cfg.NotifyWebhookURL != "" {
+		notifier, err := events.NewNotifier(db.Pool, events.NotifierConfig{
+			WebhookURL: cfg.NotifyWebhookURL,
+			Secret:     cfg.NotifySecret,
+			Mode:       cfg.NotifyMode,
+			DigestHour: cfg.NotifyDigestHour,
+			DedupDays:  cfg.NotifyDedupDays,
+			Tags:       strings.FieldsFunc(strings.ToLower(cfg.NotifyTags), func(r rune) bool { return r == ',' || r == ' ' }),
+		}, "web-service")
+		if err != nil {
+			log.Fatalf("Failed to initialize notifications: %v", err)
+		}
+		log.Printf("📬 Finding notifications enabled (%s, dedup %d days)", cfg.NotifyMode, cfg.NotifyDedupDays)
+		publishers = append(publishers, notifier)
+	}
+	eventBus := events.NewBus("web-service", cfg.EventSubjectPrefix, publishers...)
+	defer eventBus.Close()
+
+	// Tool sandboxing (nil runs tools directly)
+	toolSandbox, err := sandbox.Load(cfg.SandboxProfiles, cfg.SetprivPath, cfg.BwrapPath)
+	if err != nil {
+		log.Fatalf("Failed to load sandbox profiles: %v", err)
+	}
+
+	// Per-scan workspaces; leftovers of scans interrupted by a crash are removed
+	// before any scan starts
+	artifactManager, err := artifacts.NewManager(cfg.ArtifactsPath, time.Duration(cfg.ArtifactRetentionHours)*time.Hour)
+	if err != nil {
+		log.Fatalf("Failed to initialize artifact storage: %v", err)
+	}
+	artifactManager.RecoverOrphans()
+	go artifactManager.Start(context.Background())
+
+	// Result fields above their size limit are cut and kept whole elsewhere
+	fieldLimits, err := limits.Parse(cfg.ResultFieldLimits)
+	if err != nil {
+		log.Fatalf("Invalid RESULT_FIELD_LIMITS: %v", err)
+	}
+	var resultStore *objectstore.S3
+	switch cfg.ResultStorage {
+	case "":
+	case "s3":
+		if cfg.ResultS3Bucket == "" {
+			log.Fatalf("RESULT_S3_BUCKET is required for s3 result storage")
+		}
+		resultStore = objectstore.NewS3(cfg.ResultS3Endpoint, cfg.ResultS3Region, cfg.ResultS3Bucket,
+			cfg.ResultS3Prefix, cfg.ResultS3AccessKey, cfg.ResultS3SecretKey)
+	default:
+		log.Fatalf("Unsupported RESULT_STORAGE: %s", cfg.ResultStorage)
+	}
+	resultLimits := limits.New(fieldLimits, artifactManager, resultStore)
+
 	// Initialize scanners
-	nucleiScanner := scanner.NewNucleiScanner(db, cfg.NucleiPath, cfg.TemplatesPath)
-	ffufScanner := scanner.NewFfufScanner(db, cfg.FfufPath, cfg.WordlistsPath)
-	gowitnessScanner := scanner.NewGowitnessScanner(db, cfg.GowitnessPath, cfg.ScreenshotsPath, cfg.ChromePath)
-	testsslScanner := scanner.NewTestsslScanner(db, cfg.TestsslPath)
+	nucleiScanner := scanner.NewNucleiScanner(db, cfg.NucleiPath, cfg.TemplatesPath, toolSandbox, artifactManager)
+	ffufScanner := scanner.NewFfufScanner(db, cfg.FfufPath, cfg.WordlistsPath, toolSandbox, artifactManager)
+	gowitnessScanner := scanner.NewGowitnessScanner(db, cfg.GowitnessPath, cfg.ScreenshotsPath, cfg.ChromePath, toolSandbox, artifactManager)
+	testsslScanner := scanner.NewTestsslScanner(db, cfg.TestsslPath, toolSandbox, artifactManager)
+	sqlmapScanner, err := scanner.NewSqlmapScanner(db, cfg.SqlmapPath, toolSandbox, artifactManager)
+	if err != nil {
+		log.Fatalf("Failed to initialize sqlmap: %v", err)
+	}
+	// nuclei is not retried: its findings are stored as it finds them
+	toolRetry := supervise.Policy{MaxAttempts: cfg.ToolMaxAttempts, BaseDelay: time.Duration(cfg.ToolRetryDelay) * time.Second}
+	ffufScanner.SetRetryPolicy(toolRetry)
+	gowitnessScanner.SetRetryPolicy(toolRetry)
+	testsslScanner.SetRetryPolicy(toolRetry)
+	sqlmapScanner.SetRetryPolicy(toolRetry)
+	nucleiScanner.SetLimits(resultLimits)
+	testsslScanner.SetLimits(resultLimits)
+	sqlmapScanner.SetLimits(resultLimits)
+	simulator := scanner.NewSimulator(db)
+
+	// Informational nuclei findings are filtered by noise profiles at ingest
+	noiseStore, err := noise.NewStore(db, cfg.NoiseProfile)
+	if err != nil {
+		log.Fatalf("Failed to initialize noise profiles: %v", err)
+	}
+	nucleiScanner.SetNoise(noiseStore)
+	simulator.SetNoise(noiseStore)
+	var credCheckScanner *scanner.CredCheckScanner
+	if cfg.CredCheckEnabled {
+		credCheckScanner, err = scanner.NewCredCheckScanner(db)
+		if err != nil {
+			log.Fatalf("Failed to initialize default credential checks: %v", err)
+		}
+	}
 
 	log.Printf("Initialized scanners:")
-	log.Printf("  - Nuclei: %s", cfg.NucleiPath)
+	log.Printf("  - Nuclei: %s (noise profile: %s)", cfg.NucleiPath, cfg.NoiseProfile)
 	log.Printf("  - ffuf: %s (wordlists: %s)", cfg.FfufPath, cfg.WordlistsPath)
 	log.Printf("  - Gowitness: %s (screenshots: %s)", cfg.GowitnessPath, cfg.ScreenshotsPath)
 	log.Printf("  - testssl.sh: %s", cfg.TestsslPath)
+	log.Printf("  - sqlmap: %s", cfg.SqlmapPath)
+	if credCheckScanner != nil {
+		log.Printf("  - Default credential checks: enabled")
+	}
+	log.Printf("  - Artifacts: %s (kept %dh)", cfg.ArtifactsPath, cfg.ArtifactRetentionHours)
+	if resultStore != nil {
+		log.Printf("  - Full results of truncated fields: %s", resultStore.Name())
+	}
+
+	// Hot-reloadable settings; an empty value means the override was removed
+	scanLimiter := runtimeconfig.NewLimiter(0)
+	watchPath := func(key, def string, set func(string)) {
+		runtimeConfig.Watch(key, func(value string) {
+			if value == "" {
+				value = def
+			}
+			set(value)
+		})
+	}
+	watchPath("nuclei.path", cfg.NucleiPath, nucleiScanner.SetNucleiPath)
+	watchPath("ffuf.path", cfg.FfufPath, ffufScanner.SetFfufPath)
+	watchPath("gowitness.path", cfg.GowitnessPath, gowitnessScanner.SetGowitnessPath)
+	watchPath("testssl.path", cfg.TestsslPath, testsslScanner.SetTestsslPath)
+	watchPath("sqlmap.path", cfg.SqlmapPath, sqlmapScanner.SetSqlmapPath)
+	runtimeConfig.Watch("scans.max_concurrent", func(value string) {
+		limit, _ := strconv.Atoi(value)
+		scanLimiter.SetLimit(limit)
+	})
 
 	// Initialize handlers
-	vulnHandler := handlers.NewVulnerabilityHandler(db, nucleiScanner)
-	webScanHandler := handlers.NewWebScanHandler(db, ffufScanner, gowitnessScanner, testsslScanner)
+	vulnHandler := handlers.NewVulnerabilityHandler(db, nucleiScanner, simulator, eventBus, scanLimiter, artifactManager)
+
+	// Nuclei scans with high-severity findings are posted to Slack and Discord
+	chatChannels, err := chat.FromEnv(cfg.SlackWebhookURL, cfg.DiscordWebhookURL, cfg.ChatSeverities)
+	if err != nil {
+		log.Fatalf("Invalid chat notification configuration: %v", err)
+	}
+	chatNotifier, err := db.ChatNotifier(context.Background(), chatChannels, cfg.ReportBaseURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize chat notifications: %v", err)
+	}
+	vulnHandler.SetChat(chatNotifier)
+	vulnHandler.SetNoise(noiseStore)
+	noiseHandler := handlers.NewNoiseHandler(noiseStore)
+
+	// Scans of targets outside the scope rules are rejected
+	scopeChecker, err := db.Scope(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize scope rules: %v", err)
+	}
+	vulnHandler.SetScope(scopeChecker)
+
+	// testssl.sh findings are checked against the TLS policy of the project
+	tlsPolicies, err := db.TLSPolicies(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize TLS policies: %v", err)
+	}
+	testsslScanner.SetPolicies(tlsPolicies)
+
+	// Findings marked fixed are rescanned after a delay to verify the fix
+	verifier, err := verification.NewVerifier(db, nucleiScanner, scanLimiter,
+		strings.Split(cfg.VerifySeverities, ","), time.Duration(cfg.VerifyDelayMinutes)*time.Minute)
+	if err != nil {
+		log.Fatalf("Failed to initialize fix verification: %v", err)
+	}
+	verifier.SetLeases(leases)
+	go verifier.Start(context.Background())
+	findingHandler := handlers.NewFindingHandler(db, verifier)
+	// Web scanning tools; a new tool only needs registering here
+	webTools := tools.NewRegistry()
+	webTools.Register(tools.NewFfuf(ffufScanner))
+	webTools.Register(tools.NewGowitness(gowitnessScanner))
+	webTools.Register(tools.NewTestssl(testsslScanner))
+	webTools.Register(tools.NewCredCheck(credCheckScanner))
+	webTools.Register(tools.NewSqlmap(sqlmapScanner))
+	webScanHandler := handlers.NewWebScanHandler(db, webTools, ffufScanner, simulator, scanLimiter, artifactManager)
+	webScanHandler.SetLimits(resultLimits)
+	webScanHandler.SetScope(scopeChecker)
+
+	// Web ports a network scan finds newly opened on known assets are
+	// screenshotted, and linked to the asset's timeline
+	if cfg.AutoScreenshotPorts != "" {
+		gowitness, _ := webTools.Get("gowitness")
+		watcher, err := autoscreenshot.NewWatcher(db, gowitness, scanLimiter, cfg.AutoScreenshotPorts)
+		if err != nil {
+			log.Fatalf("Failed to initialize automatic screenshots: %v", err)
+		}
+		watcher.SetLeases(leases)
+		go watcher.Start(context.Background())
+	}
+	artifactHandler := handlers.NewArtifactHandler(artifactManager)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -50,6 +273,7 @@ func main() {
 	})
 
 	// Global middleware
+	app.Use(middleware.Errors()) // first, so it sees every error
 	app.Use(middleware.CORS())
 	app.Use(middleware.Logger())
 
@@ -59,40 +283,65 @@ func main() {
 			"status":  "ok",
 			"service": "web-service",
 			"version": "2.0.0",
-			"tools":   []string{"nuclei", "ffuf", "gowitness", "testssl"},
+			"tools":   []string{"nuclei", "ffuf", "gowitness", "testssl", "sqlmap"},
 		})
 	})
 
-	// API routes
-	api := app.Group("/api")
+	// API routes. Only requests proxied by the gateway are served when
+	// INTERNAL_AUTH_SECRET is set; /health stays open for probes
+	internalauth.LogStatus(cfg.InternalAuthSecret)
+	api := app.Group("/api", middleware.InternalAuth(cfg.InternalAuthSecret))
 
 	// Vulnerability scan routes (Nuclei)
 	vulns := api.Group("/vulnerabilities")
+	vulns.Get("/findings", findingHandler.ListFindings) // normalized, for the gateway's /api/findings
+	vulns.Get("/findings/:id/status", findingHandler.GetFindingStatus)
+	vulns.Put("/findings/:id/status", findingHandler.UpdateFindingStatus)
 	vulns.Get("/", vulnHandler.ListVulnScans)
 	vulns.Post("/", vulnHandler.CreateVulnScan)
+	vulns.Post("/cve-rescan", vulnHandler.RescanCVE)
+	vulns.Get("/noise-profiles", noiseHandler.ListProfiles)
+	vulns.Get("/noise-profiles/:name", noiseHandler.GetProfile)
+	vulns.Put("/noise-profiles/:name", noiseHandler.SaveProfile)
+	vulns.Delete("/noise-profiles/:name", noiseHandler.DeleteProfile)
+	vulns.Get("/technologies", noiseHandler.ListTechnologies)
 	vulns.Get("/:id", vulnHandler.GetVulnScan)
 	vulns.Delete("/:id", vulnHandler.DeleteVulnScan)
 	vulns.Post("/:id/cancel", vulnHandler.CancelVulnScan)
 	vulns.Get("/:id/results", vulnHandler.GetVulnScanResults)
+	vulns.Get("/:id/sarif", vulnHandler.ExportVulnScanSARIF)
 	vulns.Get("/:id/logs", vulnHandler.GetVulnScanLogs)
 	vulns.Get("/:id/stats", vulnHandler.GetVulnScanStats)
+	vulns.Get("/:id/targets", vulnHandler.GetVulnScanTargets)
+	vulns.Post("/:id/retry", vulnHandler.RetryFailedTargets)
+	vulns.Get("/:id/artifacts", artifactHandler.ListArtifacts)
+	vulns.Get("/:id/artifacts/:name", artifactHandler.DownloadArtifact)
+
+	// Deduplicated findings across scans
+	findings := api.Group("/findings")
+	findings.Get("/:id/history", findingHandler.GetFindingHistory)
 
-	// Web scanning routes (ffuf, gowitness, testssl)
+	// Web scanning routes (ffuf, gowitness, testssl, credcheck, sqlmap)
 	webscans := api.Group("/webscans")
 	webscans.Get("/", webScanHandler.ListWebScans)
 	webscans.Get("/templates", webScanHandler.GetWebScanTemplates)
 	webscans.Get("/wordlists", webScanHandler.GetWordlists)
+	webscans.Get("/compare", webScanHandler.CompareEndpoints) // ffuf vs kiterunner vs swagger paths of a host
 	webscans.Get("/:id", webScanHandler.GetWebScan)
 	webscans.Delete("/:id", webScanHandler.DeleteWebScan)
 	webscans.Post("/:id/cancel", webScanHandler.CancelWebScan)
 	webscans.Get("/:id/results", webScanHandler.GetWebScanResults)
 	webscans.Get("/:id/logs", webScanHandler.GetWebScanLogs)
 	webscans.Get("/:id/stats", webScanHandler.GetWebScanStats)
+	webscans.Get("/:id/artifacts", artifactHandler.ListArtifacts)
+	webscans.Get("/:id/artifacts/:name", artifactHandler.DownloadArtifact)
+
+	// Scan creation and import, per registered tool
+	webscans.Post("/:tool", webScanHandler.CreateWebScan)
+	webscans.Post("/:tool/import", webScanHandler.ImportWebScan)
 
-	// Tool-specific scan creation endpoints
-	webscans.Post("/ffuf", webScanHandler.CreateFfufScan)
-	webscans.Post("/gowitness", webScanHandler.CreateGowintessScan)
-	webscans.Post("/testssl", webScanHandler.CreateTestsslScan)
+	// Scan types and their request fields, for forms and config validation
+	api.Get("/capabilities", webScanHandler.GetCapabilities)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Port)
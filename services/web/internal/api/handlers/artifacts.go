@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/web-service/internal/artifacts"
+	"strconv"
+)
+
+// ArtifactHandler exposes the raw tool outputs kept in scan workspaces
+type ArtifactHandler struct {
+	artifacts *artifacts.Manager
+}
+
+// NewArtifactHandler creates a new artifact handler
+func NewArtifactHandler(manager *artifacts.Manager) *ArtifactHandler {
+	return &ArtifactHandler{artifacts: manager}
+}
+
+// ListArtifacts returns the raw output files of a scan
+func (h *ArtifactHandler) ListArtifacts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+
+	list, err := h.artifacts.List(id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list artifacts"})
+	}
+
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(list)))
+	return c.JSON(fiber.Map{
+		"scan_id":   id,
+		"artifacts": list,
+		"total":     len(list),
+	})
+}
+
+// DownloadArtifact sends a single raw output file
+func (h *ArtifactHandler) DownloadArtifact(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+
+	name := c.Params("name")
+	path, err := h.artifacts.Path(id, name)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Artifact not found"})
+	}
+
+	return c.Download(path, name)
+}
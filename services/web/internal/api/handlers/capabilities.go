@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/capabilities"
+)
+
+// nucleiScanType describes CreateVulnScanRequest
+var nucleiScanType = capabilities.ScanType{
+	Name:        "nuclei",
+	Title:       "Vulnerability Scan",
+	Description: "Nuclei templates run against URLs or IPs",
+	Tool:        "nuclei",
+	Endpoint:    "POST /api/vulnerabilities",
+	Fields: []capabilities.Field{
+		{Name: "name", Type: capabilities.String},
+		{Name: "target", Type: capabilities.String, Required: true, Description: "URLs or IPs, separated by commas, spaces or newlines"},
+		{Name: "templates", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Nuclei templates or template directories; all when empty"},
+		{Name: "severity", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"info", "low", "medium", "high", "critical"}}},
+		{Name: "tags", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Nuclei template tags, e.g. cve"},
+		{Name: "configuration", Type: capabilities.Object},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+	},
+}
+
+// GetCapabilities describes the nuclei scan and every registered web tool,
+// for UIs and the CLI to render forms and validate configurations
+func (h *WebScanHandler) GetCapabilities(c *fiber.Ctx) error {
+	scanTypes := append([]capabilities.ScanType{nucleiScanType}, h.tools.ScanTypes()...)
+	return c.JSON(capabilities.New("web", scanTypes))
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+// compareTools are the tools an endpoint comparison reconciles, in the
+// order they are listed
+var compareTools = []string{"ffuf", "kiterunner", "swagger"}
+
+// compareCandidates bounds the recent scans searched for one of a host
+const compareCandidates = 100
+
+// endpointHit is a URL a tool reported
+type endpointHit struct {
+	url    string
+	method string
+	status int
+}
+
+// CompareEndpoints reconciles the paths ffuf brute forced, kiterunner found
+// and swagger specifications document for the same host: the latest
+// completed scan of each for ?target=, or the scans given as ?ffuf=<web scan
+// id>, ?kiterunner= and ?swagger=<API scan id>. Paths some tools missed, or
+// that answered them with different status codes, point at wordlist blind
+// spots or at filtering; ?all=true also lists the paths all tools agree on.
+func (h *WebScanHandler) CompareEndpoints(c *fiber.Ctx) error {
+	ctx := context.Background()
+	scope := project.Scope(c.Query("project"), c.Get(project.Header))
+	host := compareHost(c.Query("target"))
+
+	var hasAPI bool
+	if err := h.db.Pool.QueryRow(ctx, `SELECT to_regclass('api_endpoints') IS NOT NULL`).Scan(&hasAPI); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
+	}
+
+	comparison := models.EndpointComparison{
+		Tools:     []string{},
+		Scans:     map[string]models.ComparedScan{},
+		Endpoints: []models.ComparedEndpoint{},
+	}
+	hits := map[string][]endpointHit{}
+	for _, tool := range compareTools {
+		id := c.Query(tool)
+		if id == "" && host == "" {
+			continue
+		}
+		if tool != "ffuf" && !hasAPI {
+			if id != "" {
+				return c.Status(404).JSON(fiber.Map{"error": "The API service's tables don't exist"})
+			}
+			continue
+		}
+
+		ref, found, err := h.compareScan(ctx, tool, id, host, scope)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
+		}
+		if !found {
+			if id != "" {
+				return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("No completed %s scan %s", tool, id)})
+			}
+			continue
+		}
+		if host == "" {
+			host = compareHost(ref.Target)
+		}
+		if scanHost := compareHost(ref.Target); scanHost != host {
+			return c.Status(409).JSON(fiber.Map{
+				"error": fmt.Sprintf("The %s scan targets %s, not %s", tool, scanHost, host),
+			})
+		}
+
+		toolHits, err := h.compareHits(ctx, tool, ref.ID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch results"})
+		}
+		comparison.Tools = append(comparison.Tools, tool)
+		comparison.Scans[tool] = ref
+		hits[tool] = toolHits
+	}
+
+	if host == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "target, or the IDs of the scans to compare (ffuf, kiterunner, swagger), is required"})
+	}
+	if len(comparison.Tools) < 2 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": fmt.Sprintf("Completed scans of %s by at least two of ffuf, kiterunner and swagger are needed, found %d", host, len(comparison.Tools)),
+		})
+	}
+
+	comparison.Host = host
+	compareEndpointHits(&comparison, hits, c.QueryBool("all"))
+	return c.JSON(comparison)
+}
+
+// compareScan returns the scan of a tool to compare: the one given by id, or
+// the latest completed one of host in the project scope
+func (h *WebScanHandler) compareScan(ctx context.Context, tool, id, host, scope string) (models.ComparedScan, bool, error) {
+	var query string
+	args := []interface{}{}
+	if tool == "ffuf" {
+		query = `SELECT s.id, s.name, s.target, s.completed_at FROM web_scans s WHERE s.tool = 'ffuf' AND s.status = 'completed'`
+	} else {
+		query = `SELECT s.id, s.name, s.target, s.completed_at FROM api_scans s
+			WHERE s.status = 'completed' AND EXISTS (SELECT 1 FROM api_endpoints e WHERE e.scan_id = s.id AND e.source = $1)`
+		args = append(args, tool)
+	}
+
+	if id != "" {
+		scanID, err := uuid.Parse(id)
+		if err != nil {
+			return models.ComparedScan{}, false, nil
+		}
+		args = append(args, scanID)
+		query += fmt.Sprintf(" AND s.id = $%d", len(args))
+	} else if scope != "" {
+		args = append(args, scope)
+		query += " AND " + project.Filter("s.project_id", len(args))
+	}
+	query += fmt.Sprintf(" ORDER BY s.completed_at DESC LIMIT %d", compareCandidates)
+
+	rows, err := h.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return models.ComparedScan{}, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ref models.ComparedScan
+		if err := rows.Scan(&ref.ID, &ref.Name, &ref.Target, &ref.CompletedAt); err != nil {
+			return models.ComparedScan{}, false, err
+		}
+		if id != "" || compareHost(ref.Target) == host {
+			return ref, true, nil
+		}
+	}
+	return models.ComparedScan{}, false, rows.Err()
+}
+
+// compareHits returns the URLs a tool's scan reported
+func (h *WebScanHandler) compareHits(ctx context.Context, tool string, scanID uuid.UUID) ([]endpointHit, error) {
+	var rows pgx.Rows
+	var err error
+	if tool == "ffuf" {
+		rows, err = h.db.Pool.Query(ctx, `
+			SELECT url, 'GET', COALESCE(status_code, 0) FROM web_scan_results WHERE scan_id = $1 AND url IS NOT NULL
+		`, scanID)
+	} else {
+		rows, err = h.db.Pool.Query(ctx, `
+			SELECT url, method, COALESCE(status_code, 0) FROM api_endpoints WHERE scan_id = $1 AND source = $2
+		`, scanID, tool)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []endpointHit
+	for rows.Next() {
+		var hit endpointHit
+		if err := rows.Scan(&hit.url, &hit.method, &hit.status); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// compareEndpointHits reconciles the hits of each tool by path. Concrete
+// paths that match a templated swagger path (/users/{id}) count as hits on
+// it.
+func compareEndpointHits(comparison *models.EndpointComparison, hits map[string][]endpointHit, all bool) {
+	byPath := map[string]*models.ComparedEndpoint{}
+	for _, tool := range comparison.Tools {
+		for _, hit := range hits[tool] {
+			path, ok := comparePath(hit.url, comparison.Host)
+			if !ok {
+				continue
+			}
+			endpoint := byPath[path]
+			if endpoint == nil {
+				endpoint = &models.ComparedEndpoint{Path: path, Statuses: map[string]int{}}
+				byPath[path] = endpoint
+			}
+			addEndpointHit(endpoint, tool, hit.method, hit.status)
+		}
+	}
+
+	for template, endpoint := range byPath {
+		if !strings.Contains(template, "{") {
+			continue
+		}
+		for path, concrete := range byPath {
+			if strings.Contains(path, "{") || !matchesTemplate(path, template) {
+				continue
+			}
+			for _, tool := range concrete.FoundBy {
+				status := concrete.Statuses[tool]
+				for _, method := range concrete.Methods {
+					addEndpointHit(endpoint, tool, method, status)
+				}
+			}
+			delete(byPath, path)
+		}
+	}
+
+	comparison.Summary = map[string]int{"total": len(byPath), "agree": 0, "disagree": 0, "status_mismatch": 0}
+	for _, tool := range comparison.Tools {
+		comparison.Summary[tool] = 0
+		comparison.Summary["only_"+tool] = 0
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		endpoint := byPath[path]
+		sort.Strings(endpoint.Methods)
+		found := map[string]bool{}
+		for _, tool := range endpoint.FoundBy {
+			found[tool] = true
+			comparison.Summary[tool]++
+		}
+		endpoint.FoundBy = endpoint.FoundBy[:0]
+		for _, tool := range comparison.Tools {
+			if found[tool] {
+				endpoint.FoundBy = append(endpoint.FoundBy, tool)
+			} else {
+				endpoint.MissedBy = append(endpoint.MissedBy, tool)
+			}
+		}
+		if len(endpoint.FoundBy) == 1 {
+			comparison.Summary["only_"+endpoint.FoundBy[0]]++
+		}
+
+		codes := map[int]bool{}
+		for _, status := range endpoint.Statuses {
+			codes[status] = true
+		}
+		endpoint.StatusMismatch = len(codes) > 1
+		if len(endpoint.Statuses) == 0 {
+			endpoint.Statuses = nil
+		}
+
+		switch {
+		case len(endpoint.MissedBy) > 0:
+			comparison.Summary["disagree"]++
+		case endpoint.StatusMismatch:
+			comparison.Summary["status_mismatch"]++
+		default:
+			comparison.Summary["agree"]++
+		}
+		if all || len(endpoint.MissedBy) > 0 || endpoint.StatusMismatch {
+			comparison.Endpoints = append(comparison.Endpoints, *endpoint)
+		}
+	}
+}
+
+func addEndpointHit(endpoint *models.ComparedEndpoint, tool, method string, status int) {
+	if !containsString(endpoint.FoundBy, tool) {
+		endpoint.FoundBy = append(endpoint.FoundBy, tool)
+	}
+	if method = strings.ToUpper(method); method != "" && !containsString(endpoint.Methods, method) {
+		endpoint.Methods = append(endpoint.Methods, method)
+	}
+	if _, ok := endpoint.Statuses[tool]; !ok && status > 0 {
+		endpoint.Statuses[tool] = status
+	}
+}
+
+// compareHost returns the host (and port, when not the default) of a scan
+// target, which may be a URL with ffuf's FUZZ keyword or a bare host
+func compareHost(target string) string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return ""
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	return host
+}
+
+// comparePath returns the path of a URL on host, without query or trailing
+// slash. URLs of other hosts (ffuf virtual host fuzzing) don't compare.
+func comparePath(rawURL, host string) (string, bool) {
+	if compareHost(rawURL) != host {
+		return "", false
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	path := strings.TrimRight(parsed.Path, "/")
+	if path == "" {
+		path = "/"
+	}
+	return path, true
+}
+
+// matchesTemplate reports whether a path is an instance of a templated one,
+// each {parameter} matching one segment
+func matchesTemplate(path, template string) bool {
+	segments := strings.Split(path, "/")
+	templateSegments := strings.Split(template, "/")
+	if len(segments) != len(templateSegments) {
+		return false
+	}
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/events"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// Open ports of the latest non-simulated network scan results, as
+// "<port>/<proto> <service> <product> <version>"
+const networkAssetsSQL = `
+	SELECT DISTINCT ON (r.host, p->>'port', p->>'protocol')
+	       r.host, COALESCE(p->>'port', ''),
+	       CONCAT_WS(' ', CONCAT(p->>'port', '/', p->>'protocol'), p->>'service', p->>'product', p->>'version', p->>'extrainfo'),
+	       r.created_at
+	FROM scan_results r
+	JOIN scans s ON s.id = r.scan_id
+	CROSS JOIN LATERAL jsonb_array_elements(CASE WHEN jsonb_typeof(r.ports) = 'array' THEN r.ports ELSE '[]'::jsonb END) p
+	WHERE NOT COALESCE((s.configuration->>'simulated')::boolean, false)
+	  AND p->>'state' = 'open'
+	  AND CONCAT_WS(' ', p->>'service', p->>'product', p->>'version', p->>'extrainfo') ILIKE ANY($1)
+	ORDER BY r.host, p->>'port', p->>'protocol', r.created_at DESC`
+
+// URLs whose server header or detected technologies match, from the latest
+// non-simulated recon tech scans
+const reconAssetsSQL = `
+	SELECT url, detail, created_at FROM (
+		SELECT DISTINCT ON (t.url) t.url, t.created_at,
+		       CONCAT_WS(', ', NULLIF(t.server, ''),
+		                 (SELECT string_agg(CONCAT_WS(' ', x->>'name', x->>'version'), ', ')
+		                  FROM jsonb_array_elements(CASE WHEN jsonb_typeof(t.technologies) = 'array' THEN t.technologies ELSE '[]'::jsonb END) x)) AS detail
+		FROM tech_results t
+		JOIN recon_scans s ON s.id = t.scan_id
+		WHERE NOT COALESCE((s.configuration->>'simulated')::boolean, false)
+		ORDER BY t.url, t.created_at DESC
+	) latest
+	WHERE detail ILIKE ANY($1)`
+
+// Hosts where nuclei already detected the software, or already found the
+// template itself
+const webAssetsSQL = `
+	SELECT DISTINCT ON (v.host) v.host, v.template_name, v.created_at
+	FROM vulnerabilities v
+	JOIN vulnerability_scans s ON s.id = v.scan_id
+	WHERE NOT COALESCE((s.configuration->>'simulated')::boolean, false)
+	  AND (LOWER(v.template_id) = LOWER($2)
+	       OR CONCAT_WS(' ', v.template_id, v.template_name, array_to_string(v.extracted_results, ' ')) ILIKE ANY($1))
+	ORDER BY v.host, v.created_at DESC`
+
+// RescanCVE finds every asset whose recorded services or technologies
+// match the software a CVE targets and queues a scan of the CVE's template
+// against each: the whole "new critical CVE" workflow in one call.
+func (h *VulnerabilityHandler) RescanCVE(c *fiber.Ctx) error {
+	var req models.CVERescanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	templateID := strings.TrimSpace(req.TemplateID)
+	if templateID == "" {
+		templateID = strings.ToUpper(strings.TrimSpace(req.CVE))
+	}
+	if templateID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "cve or template_id is required"})
+	}
+
+	rescan := models.CVERescan{TemplateID: templateID, Assets: []models.AffectedAsset{}}
+	var keywords []string
+	template, err := h.nucleiScanner.FindTemplate(templateID)
+	switch {
+	case err == nil:
+		rescan.TemplateID = template.ID
+		rescan.TemplateName = template.Name
+		rescan.CVE = template.CVE
+		keywords = append(keywords, template.Product...)
+	case errors.Is(err, scanner.ErrTemplateNotFound):
+		// Nuclei may still know it (e.g. templates updated since); without
+		// the template's metadata the caller has to say what to look for
+		if len(req.Keywords) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Template not installed; keywords are required to find affected assets"})
+		}
+	default:
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to read templates: %v", err)})
+	}
+	rescan.Keywords = cleanKeywords(append(keywords, req.Keywords...))
+	if len(rescan.Keywords) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Template names no product; keywords are required to find affected assets"})
+	}
+
+	assets, err := h.affectedAssets(context.Background(), rescan.TemplateID, rescan.Keywords)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to find affected assets: %v", err)})
+	}
+	rescan.Assets = assets
+	rescan.Total = len(assets)
+	if req.DryRun {
+		return c.JSON(rescan)
+	}
+
+	rescanID := uuid.New()
+	projectID := project.FromRequest(c.Get(project.Header))
+	for i := range rescan.Assets {
+		// Assets scanned before a scope rule denied them are left alone
+		if status, body := scopeError(h.scope, projectID, false, rescan.Assets[i].Target); status != 0 {
+			rescan.Assets[i].OutOfScope, _ = body["error"].(string)
+			rescan.OutOfScope++
+			continue
+		}
+		scanID, err := h.queueTemplateScan(rescanID, rescan.TemplateID, &rescan.Assets[i], projectID)
+		if err != nil {
+			log.Printf("⚠️ Failed to queue %s rescan of %s: %v", rescan.TemplateID, rescan.Assets[i].Target, err)
+			continue
+		}
+		rescan.Assets[i].ScanID = &scanID
+		rescan.Queued++
+	}
+	return c.Status(202).JSON(rescan)
+}
+
+// cleanKeywords lowercases and dedups keywords, dropping ones too short to
+// match anything specific. ILIKE treats "_" as a wildcard, so template
+// products like http_server also match "http server".
+func cleanKeywords(keywords []string) []string {
+	seen := map[string]bool{}
+	cleaned := []string{}
+	for _, k := range keywords {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if len(k) < 3 || seen[k] {
+			continue
+		}
+		seen[k] = true
+		cleaned = append(cleaned, k)
+	}
+	return cleaned
+}
+
+// affectedAssets collects network, recon and nuclei assets matching keywords,
+// one per target
+func (h *VulnerabilityHandler) affectedAssets(ctx context.Context, templateID string, keywords []string) ([]models.AffectedAsset, error) {
+	patterns := make([]string, len(keywords))
+	for i, k := range keywords {
+		patterns[i] = "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`).Replace(k) + "%"
+	}
+
+	byTarget := map[string]*models.AffectedAsset{}
+	add := func(target, source, detail string, seen time.Time) {
+		key := strings.ToLower(strings.TrimSuffix(target, "/"))
+		if key == "" {
+			return
+		}
+		if existing, ok := byTarget[key]; ok && !existing.LastSeen.Before(seen) {
+			return
+		}
+		byTarget[key] = &models.AffectedAsset{
+			Target:   target,
+			Source:   source,
+			Matched:  matchedKeywords(detail, keywords),
+			Detail:   detail,
+			LastSeen: seen,
+		}
+	}
+
+	rows, err := h.db.Pool.Query(ctx, networkAssetsSQL, patterns)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var host, port, detail string
+		var seen time.Time
+		if err := rows.Scan(&host, &port, &detail, &seen); err != nil {
+			continue
+		}
+		target := host
+		if port != "" {
+			target = host + ":" + port
+		}
+		add(target, "network", detail, seen)
+	}
+	rows.Close()
+
+	rows, err = h.db.Pool.Query(ctx, reconAssetsSQL, patterns)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var url, detail string
+		var seen time.Time
+		if err := rows.Scan(&url, &detail, &seen); err != nil {
+			continue
+		}
+		add(url, "recon", detail, seen)
+	}
+	rows.Close()
+
+	rows, err = h.db.Pool.Query(ctx, webAssetsSQL, patterns, templateID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var host, detail string
+		var seen time.Time
+		if err := rows.Scan(&host, &detail, &seen); err != nil {
+			continue
+		}
+		add(host, "web", detail, seen)
+	}
+	rows.Close()
+
+	assets := make([]models.AffectedAsset, 0, len(byTarget))
+	for _, asset := range byTarget {
+		assets = append(assets, *asset)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Target < assets[j].Target })
+	return assets, nil
+}
+
+// matchedKeywords returns the keywords found in detail
+func matchedKeywords(detail string, keywords []string) []string {
+	detail = strings.ToLower(detail)
+	matched := []string{}
+	for _, k := range keywords {
+		if strings.Contains(detail, k) || strings.Contains(detail, strings.ReplaceAll(k, "_", " ")) {
+			matched = append(matched, k)
+		}
+	}
+	return matched
+}
+
+// queueTemplateScan creates a vulnerability scan of templateID against the
+// asset in projectID and runs it once a scan slot is free
+func (h *VulnerabilityHandler) queueTemplateScan(rescanID uuid.UUID, templateID string, asset *models.AffectedAsset, projectID string) (uuid.UUID, error) {
+	scanID := uuid.New()
+	target := asset.Target
+	name := fmt.Sprintf("Rescan %s on %s", templateID, target)
+	_, err := h.db.Pool.Exec(context.Background(), `
+		INSERT INTO vulnerability_scans (id, name, target, status, progress, created_at, templates, configuration, origin, project_id)
+		VALUES ($1, $2, $3, 'pending', 0, NOW(), $4, $5, $6, $7)
+	`, scanID, name, target, []string{templateID}, map[string]interface{}{
+		"cve_rescan": rescanID.String(),
+		"source":     asset.Source,
+		"matched_on": asset.Detail,
+	}, origin.Of(origin.Workflow, "cve-rescan-"+rescanID.String()), projectID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	scanData := events.ScanData{
+		ScanID:   scanID.String(),
+		Name:     name,
+		Target:   target,
+		ScanType: "vulnerability",
+		Scanner:  "nuclei",
+		Status:   "pending",
+	}
+	h.events.Publish(events.ScanCreated, scanData.ScanID, scanData)
+
+	go func() {
+		ctx := context.Background()
+
+		h.limiter.Acquire(ctx)
+		defer h.limiter.Release()
+
+		scanData.Status = "running"
+		h.events.Publish(events.ScanStarted, scanData.ScanID, scanData)
+		if err := h.nucleiScanner.ExecuteTemplateCheck(ctx, scanID, target, templateID); err != nil {
+			fmt.Printf("Rescan %s failed: %v\n", scanID, err)
+		}
+		h.publishScanOutcome(ctx, scanID, scanData)
+	}()
+	return scanID, nil
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/verification"
+)
+
+// findingFingerprintSQL computes events.Fingerprint of a vulnerabilities row,
+// so a finding is deduplicated the same way as its notifications
+const findingFingerprintSQL = `encode(sha256(convert_to('nuclei|' || LOWER(v.host) || '|0||' || COALESCE(NULLIF(v.template_id, ''), v.template_name), 'UTF8')), 'hex')`
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// FindingHandler manages the remediation status of individual findings
+type FindingHandler struct {
+	db       *database.Database
+	verifier *verification.Verifier
+}
+
+// NewFindingHandler creates a new finding handler
+func NewFindingHandler(db *database.Database, verifier *verification.Verifier) *FindingHandler {
+	return &FindingHandler{db: db, verifier: verifier}
+}
+
+// GetFindingStatus returns the status of a finding and its verification
+func (h *FindingHandler) GetFindingStatus(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid finding ID"})
+	}
+
+	status, err := h.verifier.Get(context.Background(), id)
+	if errors.Is(err, verification.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Finding not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch finding status"})
+	}
+	return c.JSON(status)
+}
+
+// UpdateFindingStatus marks a finding as open or fixed. Fixed findings of
+// the verified severities get an automatic verification rescan.
+func (h *FindingHandler) UpdateFindingStatus(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid finding ID"})
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	status, err := h.verifier.SetStatus(context.Background(), id, req.Status)
+	switch {
+	case errors.Is(err, verification.ErrInvalidStatus):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, verification.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Finding not found"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update finding status"})
+	}
+	return c.JSON(status)
+}
+
+// GetFindingHistory returns every observation of a deduplicated finding with
+// the evidence each scan recorded. The ID is a finding ID from any of those
+// scans or the finding's fingerprint. ?evidence=false leaves out requests,
+// responses and cURL commands.
+func (h *FindingHandler) GetFindingHistory(c *fiber.Ctx) error {
+	ctx := context.Background()
+	id := strings.ToLower(c.Params("id"))
+
+	fingerprint := id
+	if findingID, err := uuid.Parse(id); err == nil {
+		err := h.db.Pool.QueryRow(ctx,
+			`SELECT `+findingFingerprintSQL+` FROM vulnerabilities v WHERE v.id = $1`, findingID).Scan(&fingerprint)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(404).JSON(fiber.Map{"error": "Finding not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch finding"})
+		}
+	} else if !fingerprintPattern.MatchString(id) {
+		return c.Status(400).JSON(fiber.Map{"error": "ID must be a finding ID or a finding fingerprint"})
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT v.id, v.scan_id, COALESCE(s.name, ''), COALESCE((s.configuration->>'simulated')::boolean, false),
+		       v.created_at, v.severity, v.status, COALESCE(v.matched_at, ''), COALESCE(v.extracted_results, '{}'),
+		       COALESCE(v.curl_command, ''), COALESCE(v.request, ''), COALESCE(v.response, ''),
+		       v.template_id, v.template_name, v.host
+		FROM vulnerabilities v
+		LEFT JOIN vulnerability_scans s ON s.id = v.scan_id
+		WHERE `+findingFingerprintSQL+` = $1
+		ORDER BY v.created_at, v.id
+	`, fingerprint)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch finding history"})
+	}
+	defer rows.Close()
+
+	evidence := c.QueryBool("evidence", true)
+	history := models.FindingHistory{Fingerprint: fingerprint, Observations: []models.FindingObservation{}}
+	scans := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var o models.FindingObservation
+		err := rows.Scan(&o.FindingID, &o.ScanID, &o.ScanName, &o.Simulated, &o.ObservedAt, &o.Severity, &o.Status,
+			&o.MatchedAt, &o.ExtractedResults, &o.CURLCommand, &o.Request, &o.Response,
+			&history.TemplateID, &history.TemplateName, &history.Host)
+		if err != nil {
+			continue
+		}
+		if !evidence {
+			o.CURLCommand, o.Request, o.Response = "", "", ""
+		}
+		history.Observations = append(history.Observations, o)
+		scans[o.ScanID] = true
+	}
+	if len(history.Observations) == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Finding not found"})
+	}
+
+	first, last := history.Observations[0], history.Observations[len(history.Observations)-1]
+	history.FirstSeen = first.ObservedAt
+	history.LastSeen = last.ObservedAt
+	history.Severity = last.Severity
+	history.Status = last.Status
+	history.Occurrences = len(history.Observations)
+	history.Scans = len(scans)
+	return c.JSON(history)
+}
+
+// ListFindings returns the nuclei findings of every scan as normalized
+// findings, which the gateway lists with the other services' findings
+// (?severity=, ?target=, ?scan_id=, ?project=, ?limit=)
+func (h *FindingHandler) ListFindings(c *fiber.Ctx) error {
+	filter, err := shared.ParseFindingFilter(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := `
+		SELECT v.id, v.scan_id, v.template_id, v.template_name, v.severity,
+		       COALESCE(NULLIF(v.matched_at, ''), v.host), v.created_at
+		FROM vulnerabilities v`
+	args := []interface{}{}
+	conditions := []string{"v.suppressed IS NULL"} // noise stays out
+	if filter.ScanID != nil {
+		args = append(args, *filter.ScanID)
+		conditions = append(conditions, fmt.Sprintf("v.scan_id = $%d", len(args)))
+	}
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		args = append(args, scope)
+		conditions = append(conditions, fmt.Sprintf("v.scan_id IN (SELECT id FROM vulnerability_scans WHERE %s)", project.Filter("project_id", len(args))))
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	rows, err := h.db.Pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch findings"})
+	}
+	defer rows.Close()
+
+	findings := []shared.Finding{}
+	for rows.Next() {
+		f := shared.Finding{Source: "vulnerabilities", Tool: "nuclei"}
+		var severity string
+		if err := rows.Scan(&f.ID, &f.ScanID, &f.Identifier, &f.Title, &severity, &f.Target, &f.CreatedAt); err != nil {
+			continue
+		}
+		f.Severity = shared.NormalizeSeverity(severity)
+		findings = append(findings, f)
+	}
+	list := filter.Apply(findings)
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(list.Total))
+	return c.JSON(list)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/web-service/internal/noise"
+)
+
+// NoiseHandler manages the nuclei noise profiles and the technologies
+// collapsed fingerprint findings recorded
+type NoiseHandler struct {
+	store *noise.Store
+}
+
+// NewNoiseHandler creates a new noise profile handler
+func NewNoiseHandler(store *noise.Store) *NoiseHandler {
+	return &NoiseHandler{store: store}
+}
+
+// ListProfiles returns the built-in and custom noise profiles, and the one
+// scans get when they don't choose one
+func (h *NoiseHandler) ListProfiles(c *fiber.Ctx) error {
+	profiles, err := h.store.List(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list noise profiles"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(profiles)))
+	return c.JSON(fiber.Map{
+		"profiles": profiles,
+		"default":  h.store.Fallback(),
+		"total":    len(profiles),
+	})
+}
+
+// GetProfile returns a noise profile
+func (h *NoiseHandler) GetProfile(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if name == noise.NoProfile {
+		return c.Status(404).JSON(fiber.Map{"error": "\"none\" disables filtering, it has no rules"})
+	}
+	profile, err := h.store.Get(context.Background(), name)
+	if errors.Is(err, noise.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Noise profile not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch noise profile"})
+	}
+	return c.JSON(profile)
+}
+
+// SaveProfile creates or replaces a custom noise profile. It applies to
+// scans started afterwards; stored findings keep how they were filtered.
+func (h *NoiseHandler) SaveProfile(c *fiber.Ctx) error {
+	var profile noise.Profile
+	if err := c.BodyParser(&profile); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	profile.Name = c.Params("name")
+	if err := noise.Validate(&profile); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.store.Save(context.Background(), &profile, c.Get(origin.UserIDHeader)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save noise profile"})
+	}
+	return c.JSON(profile)
+}
+
+// DeleteProfile removes a custom noise profile
+func (h *NoiseHandler) DeleteProfile(c *fiber.Ctx) error {
+	err := h.store.Delete(context.Background(), c.Params("name"))
+	switch {
+	case errors.Is(err, noise.ErrBuiltIn):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, noise.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Noise profile not found"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete noise profile"})
+	}
+	return c.JSON(fiber.Map{"message": "Noise profile deleted"})
+}
+
+// ListTechnologies returns the technologies detected on each asset by the
+// fingerprint findings noise profiles collapsed (?host=, comma separated)
+func (h *NoiseHandler) ListTechnologies(c *fiber.Ctx) error {
+	var hosts []string
+	for _, host := range strings.Split(c.Query("host"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	techs, err := h.store.Technologies(context.Background(), hosts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch technologies"})
+	}
+	c.Set(pagination.HeaderTotalCount, strconv.Itoa(len(techs)))
+	return c.JSON(fiber.Map{
+		"technologies": techs,
+		"by_host":      noise.ByHost(techs),
+		"total":        len(techs),
+	})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/redact"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+// queryRedactor returns a redactor for the ?redact= profile of a results
+// request, which exports of scan results use to share a sanitized copy
+func queryRedactor(c *fiber.Ctx) (*redact.Redactor, error) {
+	profile, err := redact.Lookup(c.Query("redact"))
+	if err != nil {
+		return nil, err
+	}
+	return redact.New(profile), nil
+}
+
+// redactWebScanResult masks the URLs of a web scan result and leaves out its
+// screenshot when asked to
+func redactWebScanResult(result *models.WebScanResult, r *redact.Redactor) {
+	if r.Screenshots() {
+		result.ScreenshotPath = ""
+		result.ScreenshotB64 = ""
+	}
+	result.URL = r.URL(result.URL)
+	if result.RedirectURL != "" {
+		result.RedirectURL = r.URL(result.RedirectURL)
+	}
+	result.Title = r.Text(result.Title)
+	result.FindingText = r.Text(result.FindingText)
+	if result.Metadata != nil && (r.Profile().IPs || r.Profile().Hostnames) {
+		var metadata map[string]interface{}
+		raw, _ := json.Marshal(result.Metadata)
+		if json.Unmarshal([]byte(r.Text(string(raw))), &metadata) == nil {
+			result.Metadata = metadata
+		}
+	}
+}
+
+// redactVulnerability masks where a vulnerability was found and leaves out
+// its raw request and response when asked to
+func redactVulnerability(vuln *models.Vulnerability, r *redact.Redactor) {
+	vuln.Host = r.URL(vuln.Host)
+	vuln.MatchedAt = r.URL(vuln.MatchedAt)
+	vuln.ExtractedResults = r.Texts(vuln.ExtractedResults)
+	if r.Requests() {
+		vuln.CURLCommand = ""
+		vuln.Request = ""
+		vuln.Response = ""
+		vuln.Truncated = nil
+		return
+	}
+	vuln.CURLCommand = r.Text(vuln.CURLCommand)
+	vuln.Request = r.Text(vuln.Request)
+	vuln.Response = r.Text(vuln.Response)
+}
@@ -3,12 +3,26 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/chat"
+	"github.com/security-scanner/shared/pkg/events"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/redact"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
 	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/noise"
+	"github.com/security-scanner/web-service/internal/sarif"
 	"github.com/security-scanner/web-service/internal/scanner"
 )
 
@@ -16,16 +30,43 @@ import (
 type VulnerabilityHandler struct {
 	db            *database.Database
 	nucleiScanner *scanner.NucleiScanner
+	simulator     *scanner.Simulator
+	events        *events.Bus
+	limiter       *runtimeconfig.Limiter
+	artifacts     *artifacts.Manager
+	chat          *chat.Notifier
+	scope         *scope.Checker
+	noise         *noise.Store
 }
 
 // NewVulnerabilityHandler creates a new vulnerability handler
-func NewVulnerabilityHandler(db *database.Database, nucleiScanner *scanner.NucleiScanner) *VulnerabilityHandler {
+func NewVulnerabilityHandler(db *database.Database, nucleiScanner *scanner.NucleiScanner, simulator *scanner.Simulator, bus *events.Bus, limiter *runtimeconfig.Limiter, artifactManager *artifacts.Manager) *VulnerabilityHandler {
 	return &VulnerabilityHandler{
 		db:            db,
 		nucleiScanner: nucleiScanner,
+		simulator:     simulator,
+		events:        bus,
+		limiter:       limiter,
+		artifacts:     artifactManager,
 	}
 }
 
+// SetChat posts scans whose findings reach a channel's severities to Slack
+// and Discord
+func (h *VulnerabilityHandler) SetChat(n *chat.Notifier) {
+	h.chat = n
+}
+
+// SetNoise checks the noise profile scans choose
+func (h *VulnerabilityHandler) SetNoise(store *noise.Store) {
+	h.noise = store
+}
+
+// SetScope rejects scans of targets outside the scope rules
+func (h *VulnerabilityHandler) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
 // CreateVulnScan creates a new vulnerability scan
 func (h *VulnerabilityHandler) CreateVulnScan(c *fiber.Ctx) error {
 	var req models.CreateVulnScanRequest
@@ -37,6 +78,24 @@ func (h *VulnerabilityHandler) CreateVulnScan(c *fiber.Ctx) error {
 	if req.Target == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Target is required"})
 	}
+	if status, body := scopeError(h.scope, project.FromRequest(c.Get(project.Header)), req.Simulate, req.Target); status != 0 {
+		return c.Status(status).JSON(body)
+	}
+
+	if value, ok := req.Configuration["noise_profile"]; ok && h.noise != nil {
+		name, _ := value.(string)
+		if _, err := h.noise.Get(context.Background(), name); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Unknown noise_profile %v: use one listed by /api/vulnerabilities/noise-profiles or \"none\"", value)})
+		}
+	}
+
+	// Flag simulated scans so their findings are never mistaken for real ones
+	if req.Simulate {
+		if req.Configuration == nil {
+			req.Configuration = map[string]interface{}{}
+		}
+		req.Configuration["simulated"] = true
+	}
 
 	// Create scan record
 	scanID := uuid.New()
@@ -51,49 +110,177 @@ func (h *VulnerabilityHandler) CreateVulnScan(c *fiber.Ctx) error {
 		Severity:      req.Severity,
 		Tags:          req.Tags,
 		Configuration: req.Configuration,
+		Origin:        origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)),
+		ProjectID:     project.FromRequest(c.Get(project.Header)),
 	}
 
 	// Insert into database
 	query := `INSERT INTO vulnerability_scans
-	          (id, name, target, status, progress, created_at, templates, severity, tags, configuration)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	          (id, name, target, status, progress, created_at, templates, severity, tags, configuration, origin, project_id)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
 	_, err := h.db.Pool.Exec(context.Background(), query,
 		scan.ID, scan.Name, scan.Target, scan.Status, scan.Progress, scan.CreatedAt,
-		scan.Templates, scan.Severity, scan.Tags, scan.Configuration)
+		scan.Templates, scan.Severity, scan.Tags, scan.Configuration, scan.Origin, scan.ProjectID)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create scan: %v", err)})
 	}
 
+	scanData := events.ScanData{
+		ScanID:   scanID.String(),
+		Name:     scan.Name,
+		Target:   scan.Target,
+		ScanType: "vulnerability",
+		Scanner:  "nuclei",
+		Status:   scan.Status,
+	}
+	h.events.Publish(events.ScanCreated, scanData.ScanID, scanData)
+
 	// Start scan in background
 	go func() {
 		ctx := context.Background()
-		if err := h.nucleiScanner.ExecuteVulnScan(ctx, scanID, req.Target, req.Templates, req.Severity, req.Tags); err != nil {
+
+		// Wait for a free slot (scans.max_concurrent); the scan stays pending meanwhile
+		h.limiter.Acquire(ctx)
+		defer h.limiter.Release()
+
+		scanData.Status = "running"
+		h.events.Publish(events.ScanStarted, scanData.ScanID, scanData)
+		var err error
+		if req.Simulate {
+			err = h.simulator.ExecuteVulnScan(ctx, scanID, req.Target, req.Severity)
+		} else {
+			err = h.nucleiScanner.ExecuteVulnScan(ctx, scanID, req.Target, req.Templates, req.Severity, req.Tags)
+		}
+		if err != nil {
 			fmt.Printf("Vulnerability scan %s failed: %v\n", scanID, err)
 		}
+		h.publishScanOutcome(ctx, scanID, scanData)
 	}()
 
 	return c.Status(201).JSON(scan)
 }
 
-// ListVulnScans returns all vulnerability scans
+// publishScanOutcome emits scan.completed/scan.failed and one finding.created
+// per vulnerability once nuclei has finished
+func (h *VulnerabilityHandler) publishScanOutcome(ctx context.Context, scanID uuid.UUID, data events.ScanData) {
+	var errorMessage *string
+	err := h.db.Pool.QueryRow(ctx, `SELECT status, error_message FROM vulnerability_scans WHERE id = $1`, scanID).
+		Scan(&data.Status, &errorMessage)
+	if err != nil {
+		return
+	}
+	if errorMessage != nil {
+		data.Error = *errorMessage
+	}
+
+	if data.Status == "failed" {
+		h.events.Publish(events.ScanFailed, data.ScanID, data)
+		return
+	}
+	if data.Status != "completed" {
+		return
+	}
+
+	// Owners route each finding to the team of its host
+	assignments, _ := h.db.Owners(ctx)
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT template_id, template_name, severity, host, COALESCE(matched_at, '') FROM vulnerabilities WHERE scan_id = $1 AND suppressed IS NULL
+	`, scanID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	findings := []events.FindingData{}
+	data.Summary = map[string]int{}
+	for rows.Next() {
+		finding := events.FindingData{ScanID: data.ScanID, Scanner: "nuclei"}
+		if err := rows.Scan(&finding.TemplateID, &finding.Title, &finding.Severity, &finding.Host, &finding.MatchedAt); err != nil {
+			continue
+		}
+		finding.Owner = owners.Match(assignments, finding.Host, finding.MatchedAt)
+		findings = append(findings, finding)
+		data.Summary[finding.Severity]++
+	}
+	data.Summary["findings"] = len(findings)
+	if err := events.TagFindings(ctx, h.db.Pool, findings); err != nil {
+		fmt.Printf("Failed to tag findings of scan %s: %v\n", scanID, err)
+	}
+
+	h.events.Publish(events.ScanCompleted, data.ScanID, data)
+	if len(findings) > 0 {
+		h.chat.Notify(chat.Summary{
+			Service:    "web-service",
+			Tool:       "nuclei",
+			ScanID:     data.ScanID,
+			ScanName:   data.Name,
+			Target:     data.Target,
+			Counts:     data.Summary,
+			ReportPath: "/vuln-scan/" + data.ScanID,
+		})
+	}
+	for _, finding := range findings {
+		h.events.Publish(events.FindingCreated, data.ScanID, finding)
+	}
+}
+
+// vulnScanSortFields are the fields ListVulnScans sorts by, and their columns
+var vulnScanSortFields = map[string]string{
+	"created_at":   "created_at",
+	"started_at":   "started_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"target":       "target",
+	"status":       "status",
+	"progress":     "progress",
+}
+
+// ListVulnScans returns a page of vulnerability scans (?page=, ?limit=,
+// ?sort=, newest first by default), filtered by ?status=, ?origin= and
+// ?project=
 func (h *VulnerabilityHandler) ListVulnScans(c *fiber.Ctx) error {
 	status := c.Query("status", "")
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), vulnScanSortFields, "-created_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	query := `SELECT id, name, target, status, progress, created_at, started_at, completed_at,
-	          error_message, templates, severity, tags, configuration
+	          error_message, templates, severity, tags, configuration, origin, project_id
 	          FROM vulnerability_scans`
 
 	args := []interface{}{}
+	conditions := []string{}
 	if status != "" {
-		query += " WHERE status = $1"
 		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter := c.Query("origin"); filter != "" {
+		condition, value, err := origin.Filter("origin", len(args)+1, filter)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		args = append(args, value)
+		conditions = append(conditions, condition)
+	}
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		args = append(args, scope)
+		conditions = append(conditions, project.Filter("project_id", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY created_at DESC"
+	var total int
+	if err := h.db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM vulnerability_scans`+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
+	}
 
-	rows, err := h.db.Pool.Query(context.Background(), query, args...)
+	rows, err := h.db.Pool.Query(context.Background(), query+where+page.OrderBy(), args...)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
 	}
@@ -104,14 +291,18 @@ func (h *VulnerabilityHandler) ListVulnScans(c *fiber.Ctx) error {
 		var scan models.VulnerabilityScan
 		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Status, &scan.Progress,
 			&scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage,
-			&scan.Templates, &scan.Severity, &scan.Tags, &scan.Configuration)
+			&scan.Templates, &scan.Severity, &scan.Tags, &scan.Configuration, &scan.Origin, &scan.ProjectID)
 		if err != nil {
 			continue
 		}
 		scans = append(scans, scan)
 	}
 
-	return c.JSON(scans)
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
+	}
+	return c.JSON(result)
 }
 
 // GetVulnScan returns a specific vulnerability scan
@@ -123,14 +314,14 @@ func (h *VulnerabilityHandler) GetVulnScan(c *fiber.Ctx) error {
 	}
 
 	query := `SELECT id, name, target, status, progress, created_at, started_at, completed_at,
-	          error_message, templates, severity, tags, configuration
+	          error_message, templates, severity, tags, configuration, origin, project_id
 	          FROM vulnerability_scans WHERE id = $1`
 
 	var scan models.VulnerabilityScan
 	err = h.db.Pool.QueryRow(context.Background(), query, id).Scan(
 		&scan.ID, &scan.Name, &scan.Target, &scan.Status, &scan.Progress,
 		&scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage,
-		&scan.Templates, &scan.Severity, &scan.Tags, &scan.Configuration)
+		&scan.Templates, &scan.Severity, &scan.Tags, &scan.Configuration, &scan.Origin, &scan.ProjectID)
 
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
@@ -139,22 +330,93 @@ func (h *VulnerabilityHandler) GetVulnScan(c *fiber.Ctx) error {
 	return c.JSON(scan)
 }
 
-// GetVulnScanResults returns vulnerabilities found in a scan
+// GetVulnScanResults returns vulnerabilities found in a scan. Findings the
+// noise profile filtered are left out; ?suppressed=include adds them and
+// ?suppressed=only lists just them.
 func (h *VulnerabilityHandler) GetVulnScanResults(c *fiber.Ctx) error {
 	scanID := c.Params("id")
 	id, err := uuid.Parse(scanID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
 	}
+	redactor, err := queryRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+	suppressed := c.Query("suppressed", "exclude")
+	if suppressed != "exclude" && suppressed != "include" && suppressed != "only" {
+		return c.Status(400).JSON(fiber.Map{"error": "suppressed must be exclude, include or only"})
+	}
 
-	query := `SELECT id, scan_id, template_id, template_name, severity, type, host, matched_at,
-	          extracted_results, curl_command, request, response, metadata, created_at
-	          FROM vulnerabilities WHERE scan_id = $1 ORDER BY created_at DESC`
+	vulnerabilities, err := h.scanVulnerabilities(context.Background(), id, suppressed)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch vulnerabilities"})
+	}
+	for i := range vulnerabilities {
+		redactVulnerability(&vulnerabilities[i], redactor)
+	}
 
-	rows, err := h.db.Pool.Query(context.Background(), query, id)
+	return c.JSON(vulnerabilities)
+}
+
+// ExportVulnScanSARIF returns the findings of a scan as a SARIF 2.1.0 log,
+// for GitHub code scanning and other SARIF consumers. ?redact= sanitizes it
+// like the results.
+func (h *VulnerabilityHandler) ExportVulnScanSARIF(c *fiber.Ctx) error {
+	scanID := c.Params("id")
+	id, err := uuid.Parse(scanID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+	redactor, err := queryRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
+
+	var scan models.VulnerabilityScan
+	err = h.db.Pool.QueryRow(context.Background(), `
+		SELECT id, name, target, status, started_at, completed_at, configuration
+		FROM vulnerability_scans WHERE id = $1`, id).Scan(
+		&scan.ID, &scan.Name, &scan.Target, &scan.Status, &scan.StartedAt, &scan.CompletedAt, &scan.Configuration)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+
+	vulnerabilities, err := h.scanVulnerabilities(context.Background(), id, "exclude")
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch vulnerabilities"})
 	}
+	// Fingerprinted before redaction, so they match across exports
+	fingerprints := make(map[uuid.UUID]string, len(vulnerabilities))
+	for i, vuln := range vulnerabilities {
+		fingerprints[vuln.ID] = events.Fingerprint(events.FindingData{
+			Scanner: "nuclei", Host: vuln.Host, Title: vuln.TemplateName, TemplateID: vuln.TemplateID,
+		})
+		redactVulnerability(&vulnerabilities[i], redactor)
+	}
+	scan.Name, scan.Target = redactor.Text(scan.Name), redactor.Text(scan.Target)
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=nuclei_%s.sarif", scanID))
+	c.Set("Content-Type", "application/sarif+json")
+	return c.JSON(sarif.FromNuclei(scan, vulnerabilities, fingerprints))
+}
+
+// scanVulnerabilities returns the findings of a scan, newest first;
+// suppressed is exclude, include or only for the findings filtered as noise
+func (h *VulnerabilityHandler) scanVulnerabilities(ctx context.Context, scanID uuid.UUID, suppressed string) ([]models.Vulnerability, error) {
+	filter := map[string]string{
+		"exclude": " AND suppressed IS NULL",
+		"only":    " AND suppressed IS NOT NULL",
+	}[suppressed]
+	query := `SELECT id, scan_id, template_id, template_name, severity, type, host, matched_at,
+	          extracted_results, curl_command, request, response, metadata, status, truncated,
+	          COALESCE(suppressed, ''), COALESCE(suppressed_by, ''), created_at
+	          FROM vulnerabilities WHERE scan_id = $1` + filter + ` ORDER BY created_at DESC`
+
+	rows, err := h.db.Pool.Query(ctx, query, scanID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	vulnerabilities := []models.Vulnerability{}
@@ -163,14 +425,13 @@ func (h *VulnerabilityHandler) GetVulnScanResults(c *fiber.Ctx) error {
 		err := rows.Scan(&vuln.ID, &vuln.ScanID, &vuln.TemplateID, &vuln.TemplateName,
 			&vuln.Severity, &vuln.Type, &vuln.Host, &vuln.MatchedAt,
 			&vuln.ExtractedResults, &vuln.CURLCommand, &vuln.Request, &vuln.Response,
-			&vuln.Metadata, &vuln.CreatedAt)
+			&vuln.Metadata, &vuln.Status, &vuln.Truncated, &vuln.Suppressed, &vuln.SuppressedBy, &vuln.CreatedAt)
 		if err != nil {
 			continue
 		}
 		vulnerabilities = append(vulnerabilities, vuln)
 	}
-
-	return c.JSON(vulnerabilities)
+	return vulnerabilities, nil
 }
 
 // GetVulnScanLogs returns logs for a vulnerability scan
@@ -203,63 +464,272 @@ func (h *VulnerabilityHandler) GetVulnScanLogs(c *fiber.Ctx) error {
 	return c.JSON(logs)
 }
 
-// GetVulnScanStats returns statistics for a vulnerability scan
+// GetVulnScanStats returns statistics for a vulnerability scan: counts by
+// severity, type, host and template, findings per severity over the scan's
+// run time (?bucket= seconds, default a twentieth of the run) and the
+// request rate achieved. Findings filtered as noise are only counted in
+// filtered.
 func (h *VulnerabilityHandler) GetVulnScanStats(c *fiber.Ctx) error {
 	scanID := c.Params("id")
 	id, err := uuid.Parse(scanID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
 	}
+	ctx := context.Background()
+
+	var startedAt *time.Time
+	var duration *float64
+	var runStats *models.NucleiRunStats
+	err = h.db.Pool.QueryRow(ctx, `
+		SELECT started_at,
+		       EXTRACT(EPOCH FROM COALESCE(completed_at, LOCALTIMESTAMP) - started_at)::float8,
+		       run_stats
+		FROM vulnerability_scans WHERE id = $1`, id).Scan(&startedAt, &duration, &runStats)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
 
-	// Get total count and group by severity
-	query := `SELECT
-	            COUNT(*) as total,
-	            severity,
-	            COUNT(*) as count
-	          FROM vulnerabilities
-	          WHERE scan_id = $1
-	          GROUP BY severity`
+	bucket := c.QueryInt("bucket", 0)
+	if bucket < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "bucket must be a number of seconds"})
+	}
+	if bucket == 0 {
+		bucket = 1
+		if duration != nil && *duration > 20 {
+			bucket = int(math.Ceil(*duration / 20))
+		}
+	}
 
-	rows, err := h.db.Pool.Query(context.Background(), query, id)
+	stats := models.VulnScanStats{
+		BySeverity:    make(map[string]int),
+		ByType:        make(map[string]int),
+		ByHost:        make(map[string]int),
+		ByTemplate:    []models.TemplateHits{},
+		Filtered:      make(map[string]int),
+		BucketSeconds: bucket,
+		Timeline:      []models.StatsBucket{},
+	}
+
+	// Counts by severity, type and host
+	for column, counts := range map[string]map[string]int{
+		"severity": stats.BySeverity,
+		"type":     stats.ByType,
+		"host":     stats.ByHost,
+	} {
+		rows, err := h.db.Pool.Query(ctx,
+			`SELECT `+column+`, COUNT(*) FROM vulnerabilities WHERE scan_id = $1 AND suppressed IS NULL GROUP BY `+column, id)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch stats"})
+		}
+		for rows.Next() {
+			var key string
+			var count int
+			if err := rows.Scan(&key, &count); err == nil {
+				counts[key] = count
+			}
+		}
+		rows.Close()
+	}
+	for _, count := range stats.BySeverity {
+		stats.Total += count
+	}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT suppressed, COUNT(*) FROM vulnerabilities WHERE scan_id = $1 AND suppressed IS NOT NULL GROUP BY suppressed`, id)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch stats"})
 	}
-	defer rows.Close()
+	for rows.Next() {
+		var disposition string
+		var count int
+		if err := rows.Scan(&disposition, &count); err == nil {
+			stats.Filtered[disposition] = count
+		}
+	}
+	rows.Close()
+
+	// Hits per template
+	rows, err = h.db.Pool.Query(ctx, `
+		SELECT template_id, MAX(template_name), MAX(severity), COUNT(*), COUNT(DISTINCT host)
+		FROM vulnerabilities WHERE scan_id = $1 AND suppressed IS NULL
+		GROUP BY template_id
+		ORDER BY COUNT(*) DESC, template_id`, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch stats"})
+	}
+	for rows.Next() {
+		var hits models.TemplateHits
+		if err := rows.Scan(&hits.TemplateID, &hits.TemplateName, &hits.Severity, &hits.Count, &hits.Hosts); err == nil {
+			stats.ByTemplate = append(stats.ByTemplate, hits)
+		}
+	}
+	rows.Close()
+
+	// Findings per severity over time; findings are timestamped when nuclei
+	// reports them, so each bucket is what the scan found in that slice
+	if startedAt != nil {
+		rows, err := h.db.Pool.Query(ctx, `
+			SELECT GREATEST(0, FLOOR(EXTRACT(EPOCH FROM v.created_at - s.started_at) / $2))::int AS slot,
+			       v.severity, COUNT(*)
+			FROM vulnerabilities v
+			JOIN vulnerability_scans s ON s.id = v.scan_id
+			WHERE v.scan_id = $1 AND v.suppressed IS NULL
+			GROUP BY slot, v.severity
+			ORDER BY slot`, id, bucket)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch stats"})
+		}
+		for rows.Next() {
+			var slot, count int
+			var severity string
+			if err := rows.Scan(&slot, &severity, &count); err != nil {
+				continue
+			}
+			n := len(stats.Timeline)
+			if n == 0 || stats.Timeline[n-1].Offset != slot*bucket {
+				stats.Timeline = append(stats.Timeline, models.StatsBucket{
+					Offset:     slot * bucket,
+					Start:      startedAt.Add(time.Duration(slot*bucket) * time.Second),
+					BySeverity: make(map[string]int),
+				})
+				n++
+			}
+			stats.Timeline[n-1].BySeverity[severity] += count
+			stats.Timeline[n-1].Total += count
+		}
+		rows.Close()
+	}
 
-	stats := models.VulnScanStats{
-		BySeverity: make(map[string]int),
-		ByType:     make(map[string]int),
+	if duration != nil {
+		stats.Performance.DurationSeconds = *duration
+	}
+	if runStats != nil {
+		stats.Performance.Requests = runStats.Requests
+		stats.Performance.Errors = runStats.Errors
+		stats.Performance.Templates = runStats.Templates
+		stats.Performance.Hosts = runStats.Hosts
+		stats.Performance.PeakRPS = runStats.PeakRPS
+		if stats.Performance.DurationSeconds > 0 {
+			stats.Performance.RequestsPerSecond = math.Round(float64(runStats.Requests)/stats.Performance.DurationSeconds*100) / 100
+		}
+	}
+
+	return c.JSON(stats)
+}
+
+// GetVulnScanTargets returns the status of each target of a vulnerability
+// scan. Scans from before per-target tracking report their targets with the
+// status of the scan.
+func (h *VulnerabilityHandler) GetVulnScanTargets(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
 	}
 
+	var target, status string
+	err = h.db.Pool.QueryRow(context.Background(),
+		`SELECT target, status FROM vulnerability_scans WHERE id = $1`, id).Scan(&target, &status)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT id, target, status, attempts, findings, error, started_at, completed_at
+		FROM vulnerability_scan_targets WHERE scan_id = $1 ORDER BY position
+	`, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch targets"})
+	}
+	defer rows.Close()
+
+	targets := []models.VulnScanTarget{}
 	for rows.Next() {
-		var total, count int
-		var severity string
-		if err := rows.Scan(&total, &severity, &count); err != nil {
+		var t models.VulnScanTarget
+		if err := rows.Scan(&t.ID, &t.Target, &t.Status, &t.Attempts, &t.Findings, &t.Error,
+			&t.StartedAt, &t.CompletedAt); err != nil {
 			continue
 		}
-		stats.Total = total
-		stats.BySeverity[severity] = count
+		targets = append(targets, t)
 	}
 
-	// Get count by type
-	typeQuery := `SELECT type, COUNT(*) as count
-	              FROM vulnerabilities
-	              WHERE scan_id = $1
-	              GROUP BY type`
-
-	typeRows, err := h.db.Pool.Query(context.Background(), typeQuery, id)
-	if err == nil {
-		defer typeRows.Close()
-		for typeRows.Next() {
-			var vulnType string
-			var count int
-			if err := typeRows.Scan(&vulnType, &count); err == nil {
-				stats.ByType[vulnType] = count
-			}
+	if len(targets) == 0 {
+		targetStatus := map[string]string{"completed": "done", "cancelled": "pending"}[status]
+		if targetStatus == "" {
+			targetStatus = status
+		}
+		for _, t := range scanner.SplitTargets(target) {
+			targets = append(targets, models.VulnScanTarget{Target: t, Status: targetStatus})
 		}
 	}
 
-	return c.JSON(stats)
+	counts := map[string]int{"pending": 0, "running": 0, "done": 0, "failed": 0}
+	for _, t := range targets {
+		counts[t.Status]++
+	}
+	return c.JSON(fiber.Map{
+		"scan_id": id,
+		"status":  status,
+		"counts":  counts,
+		"targets": targets,
+	})
+}
+
+// RetryFailedTargets runs a finished vulnerability scan again against only
+// the targets that failed
+func (h *VulnerabilityHandler) RetryFailedTargets(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
+
+	var scan models.VulnerabilityScan
+	err = h.db.Pool.QueryRow(context.Background(), `
+		SELECT id, name, target, status, templates, severity, tags FROM vulnerability_scans WHERE id = $1
+	`, id).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Status, &scan.Templates, &scan.Severity, &scan.Tags)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
+	}
+	if scan.Status == "running" || scan.Status == "pending" {
+		return c.Status(409).JSON(fiber.Map{"error": "Scan is still running"})
+	}
+
+	var failed int
+	h.db.Pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM vulnerability_scan_targets WHERE scan_id = $1 AND status = 'failed'`, id).Scan(&failed)
+	if failed == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Scan has no failed targets"})
+	}
+
+	// Pending until a slot is free, like a new scan
+	if _, err := h.db.Pool.Exec(context.Background(),
+		`UPDATE vulnerability_scans SET status = 'pending' WHERE id = $1`, id); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update scan"})
+	}
+
+	scanData := events.ScanData{
+		ScanID:   id.String(),
+		Name:     scan.Name,
+		Target:   scan.Target,
+		ScanType: "vulnerability",
+		Scanner:  "nuclei",
+		Status:   "running",
+	}
+	go func() {
+		ctx := context.Background()
+		h.limiter.Acquire(ctx)
+		defer h.limiter.Release()
+
+		h.events.Publish(events.ScanStarted, scanData.ScanID, scanData)
+		if err := h.nucleiScanner.RetryFailedTargets(ctx, id, scan.Templates, scan.Severity, scan.Tags); err != nil {
+			fmt.Printf("Vulnerability scan %s retry failed: %v\n", id, err)
+		}
+		h.publishScanOutcome(ctx, id, scanData)
+	}()
+
+	return c.Status(202).JSON(fiber.Map{
+		"message": fmt.Sprintf("Retrying %d failed targets", failed),
+		"scan_id": id,
+		"retried": failed,
+	})
 }
 
 // CancelVulnScan cancels a running vulnerability scan
@@ -291,6 +761,8 @@ func (h *VulnerabilityHandler) CancelVulnScan(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel scan"})
 	}
 
+	h.artifacts.Remove(id)
+
 	return c.JSON(fiber.Map{"message": "Scan cancelled successfully"})
 }
 
@@ -331,5 +803,7 @@ func (h *VulnerabilityHandler) DeleteVulnScan(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to commit transaction"})
 	}
 
+	h.artifacts.Remove(id)
+
 	return c.JSON(fiber.Map{"message": "Scan deleted successfully"})
 }
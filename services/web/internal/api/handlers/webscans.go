@@ -3,57 +3,103 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/pagination"
+	"github.com/security-scanner/shared/pkg/project"
+	"github.com/security-scanner/shared/pkg/redact"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
 	"github.com/security-scanner/web-service/internal/models"
 	"github.com/security-scanner/web-service/internal/scanner"
+	"github.com/security-scanner/web-service/internal/tools"
 )
 
-// WebScanHandler handles web scanning requests (ffuf, gowitness, testssl)
+// WebScanHandler handles web scanning requests for the registered tools
+// (ffuf, gowitness, testssl, credcheck)
 type WebScanHandler struct {
-	db               *database.Database
-	ffufScanner      *scanner.FfufScanner
-	gowitnessScanner *scanner.GowitnessScanner
-	testsslScanner   *scanner.TestsslScanner
+	db          *database.Database
+	tools       *tools.Registry
+	ffufScanner *scanner.FfufScanner // wordlists
+	simulator   *scanner.Simulator
+	limiter     *runtimeconfig.Limiter
+	artifacts   *artifacts.Manager
+	limits      *limits.Limits
+	scope       *scope.Checker
 }
 
 // NewWebScanHandler creates a new web scan handler
 func NewWebScanHandler(
 	db *database.Database,
+	registry *tools.Registry,
 	ffufScanner *scanner.FfufScanner,
-	gowitnessScanner *scanner.GowitnessScanner,
-	testsslScanner *scanner.TestsslScanner,
+	simulator *scanner.Simulator,
+	limiter *runtimeconfig.Limiter,
+	artifactManager *artifacts.Manager,
 ) *WebScanHandler {
 	return &WebScanHandler{
-		db:               db,
-		ffufScanner:      ffufScanner,
-		gowitnessScanner: gowitnessScanner,
-		testsslScanner:   testsslScanner,
+		db:          db,
+		tools:       registry,
+		ffufScanner: ffufScanner,
+		simulator:   simulator,
+		limiter:     limiter,
+		artifacts:   artifactManager,
 	}
 }
 
-// ListWebScans returns all web scans
+// SetLimits caps the finding text of imported results
+func (h *WebScanHandler) SetLimits(l *limits.Limits) {
+	h.limits = l
+}
+
+// SetScope rejects scans of targets outside the scope rules
+func (h *WebScanHandler) SetScope(checker *scope.Checker) {
+	h.scope = checker
+}
+
+// runLimited starts a scan in the background once a slot is free (scans.max_concurrent)
+func (h *WebScanHandler) runLimited(run func(ctx context.Context)) {
+	go func() {
+		ctx := context.Background()
+		h.limiter.Acquire(ctx)
+		defer h.limiter.Release()
+		run(ctx)
+	}()
+}
+
+// webScanSortFields are the fields ListWebScans sorts by, and their columns
+var webScanSortFields = map[string]string{
+	"created_at":   "created_at",
+	"started_at":   "started_at",
+	"completed_at": "completed_at",
+	"name":         "name",
+	"target":       "target",
+	"tool":         "tool",
+	"status":       "status",
+	"progress":     "progress",
+}
+
+// ListWebScans returns a page of web scans (?page=, ?limit=, ?sort=, newest
+// first by default), filtered by ?tool=, ?status=, ?origin= and ?project=
 func (h *WebScanHandler) ListWebScans(c *fiber.Ctx) error {
-	// Pagination
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	tool := c.Query("tool", "")
 	status := c.Query("status", "")
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
+	page, err := pagination.Parse(c.Query("page"), c.Query("limit"), c.Query("sort"), webScanSortFields, "-created_at")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	offset := (page - 1) * limit
 
 	query := `
-		SELECT id, name, target, tool, status, progress, created_at, started_at, completed_at, error_message
+		SELECT id, name, target, tool, status, progress, created_at, started_at, completed_at, error_message, origin, project_id
 		FROM web_scans
 	`
 	args := []interface{}{}
@@ -72,17 +118,33 @@ func (h *WebScanHandler) ListWebScans(c *fiber.Ctx) error {
 		argIndex++
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + conditions[0]
-		for i := 1; i < len(conditions); i++ {
-			query += " AND " + conditions[i]
+	if filter := c.Query("origin"); filter != "" {
+		condition, value, err := origin.Filter("origin", argIndex, filter)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
+		conditions = append(conditions, condition)
+		args = append(args, value)
+		argIndex++
+	}
+
+	if scope := project.Scope(c.Query("project"), c.Get(project.Header)); scope != "" {
+		conditions = append(conditions, project.Filter("project_id", argIndex))
+		args = append(args, scope)
+		argIndex++
 	}
 
-	query += " ORDER BY created_at DESC LIMIT $" + strconv.Itoa(argIndex) + " OFFSET $" + strconv.Itoa(argIndex+1)
-	args = append(args, limit, offset)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
 
-	rows, err := h.db.Pool.Query(context.Background(), query, args...)
+	var total int
+	if err := h.db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM web_scans`+where, args...).Scan(&total); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
+	}
+
+	rows, err := h.db.Pool.Query(context.Background(), query+where+page.OrderBy(), args...)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch scans"})
 	}
@@ -92,14 +154,18 @@ func (h *WebScanHandler) ListWebScans(c *fiber.Ctx) error {
 	for rows.Next() {
 		var scan models.WebScan
 		err := rows.Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status,
-			&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage)
+			&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.ErrorMessage, &scan.Origin, &scan.ProjectID)
 		if err != nil {
 			continue
 		}
 		scans = append(scans, scan)
 	}
 
-	return c.JSON(scans)
+	result := pagination.New(scans, total, page)
+	for key, value := range result.Headers(c.OriginalURL()) {
+		c.Set(key, value)
+	}
+	return c.JSON(result)
 }
 
 // GetWebScan returns a specific web scan
@@ -107,7 +173,7 @@ func (h *WebScanHandler) GetWebScan(c *fiber.Ctx) error {
 	scanID := c.Params("id")
 
 	query := `
-		SELECT id, name, target, tool, status, progress, created_at, started_at, completed_at, error_message, configuration
+		SELECT id, name, target, tool, status, progress, created_at, started_at, completed_at, error_message, configuration, origin, project_id
 		FROM web_scans WHERE id = $1
 	`
 
@@ -116,7 +182,7 @@ func (h *WebScanHandler) GetWebScan(c *fiber.Ctx) error {
 	err := h.db.Pool.QueryRow(context.Background(), query, scanID).Scan(
 		&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status,
 		&scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt,
-		&scan.ErrorMessage, &configJSON)
+		&scan.ErrorMessage, &configJSON, &scan.Origin, &scan.ProjectID)
 
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
@@ -129,201 +195,131 @@ func (h *WebScanHandler) GetWebScan(c *fiber.Ctx) error {
 	return c.JSON(scan)
 }
 
-// CreateFfufScan creates a new ffuf scan
-func (h *WebScanHandler) CreateFfufScan(c *fiber.Ctx) error {
-	var req models.CreateFfufScanRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	if req.Name == "" || req.URL == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "name and url are required"})
+// CreateWebScan creates a scan with the tool named in the path
+func (h *WebScanHandler) CreateWebScan(c *fiber.Ctx) error {
+	tool, ok := h.tools.Get(c.Params("tool"))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Unknown tool"})
 	}
 
-	// Default wordlist
-	if req.Wordlist == "" {
-		req.Wordlist = "common"
+	job, err := tool.Validate(c.Body())
+	if errors.Is(err, tools.ErrDisabled) {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	scanID := uuid.New()
-	config := map[string]interface{}{
-		"url":             req.URL,
-		"wordlist":        req.Wordlist,
-		"method":          req.Method,
-		"threads":         req.Threads,
-		"timeout":         req.Timeout,
-		"match_codes":     req.MatchCodes,
-		"filter_codes":    req.FilterCodes,
-		"filter_size":     req.FilterSize,
-		"extensions":      req.Extensions,
-		"headers":         req.Headers,
-		"recursion":       req.Recursion,
-		"recursion_depth": req.RecursionDepth,
-	}
-	configJSON, _ := json.Marshal(config)
-
-	query := `
-		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, configuration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, target, tool, status, progress, created_at
-	`
-
-	var scan models.WebScan
-	err := h.db.Pool.QueryRow(context.Background(), query,
-		scanID, req.Name, req.URL, "ffuf", "pending", 0, time.Now(), configJSON,
-	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status, &scan.Progress, &scan.CreatedAt)
-
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create scan"})
-	}
-
-	// Start scan in background
-	go h.ffufScanner.ExecuteScan(context.Background(), scanID, scanner.FfufScanConfig{
-		URL:            req.URL,
-		Wordlist:       req.Wordlist,
-		Method:         req.Method,
-		Threads:        req.Threads,
-		Timeout:        req.Timeout,
-		MatchCodes:     req.MatchCodes,
-		FilterCodes:    req.FilterCodes,
-		FilterSize:     req.FilterSize,
-		Extensions:     req.Extensions,
-		Headers:        req.Headers,
-		Recursion:      req.Recursion,
-		RecursionDepth: req.RecursionDepth,
-	})
-
-	return c.Status(201).JSON(scan)
-}
-
-// CreateGowintessScan creates a new gowitness scan
-func (h *WebScanHandler) CreateGowintessScan(c *fiber.Ctx) error {
-	var req models.CreateGowintessScanRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	if req.Name == "" || len(req.URLs) == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "name and urls are required"})
+	if status, body := scopeError(h.scope, project.FromRequest(c.Get(project.Header)), job.Simulate, job.Targets...); status != 0 {
+		return c.Status(status).JSON(body)
 	}
 
 	scanID := uuid.New()
-	config := map[string]interface{}{
-		"urls":       req.URLs,
-		"timeout":    req.Timeout,
-		"resolution": req.Resolution,
-		"delay":      req.Delay,
-		"user_agent": req.UserAgent,
-		"full_page":  req.FullPage,
-	}
-	configJSON, _ := json.Marshal(config)
-
-	// Use first URL as target for display
-	target := req.URLs[0]
-	if len(req.URLs) > 1 {
-		target += " (+" + strconv.Itoa(len(req.URLs)-1) + " more)"
+	if job.Simulate {
+		job.Config["simulated"] = true
 	}
+	configJSON, _ := json.Marshal(job.Config)
 
 	query := `
-		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, configuration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, target, tool, status, progress, created_at
+		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, configuration, origin, project_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, name, target, tool, status, progress, created_at, origin, project_id
 	`
 
 	var scan models.WebScan
-	err := h.db.Pool.QueryRow(context.Background(), query,
-		scanID, req.Name, target, "gowitness", "pending", 0, time.Now(), configJSON,
-	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status, &scan.Progress, &scan.CreatedAt)
+	err = h.db.Pool.QueryRow(context.Background(), query,
+		scanID, job.Name, job.Target, tool.Name(), "pending", 0, time.Now(), configJSON, origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)),
+		project.FromRequest(c.Get(project.Header)),
+	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status, &scan.Progress, &scan.CreatedAt, &scan.Origin, &scan.ProjectID)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create scan"})
 	}
 
 	// Start scan in background
-	go h.gowitnessScanner.ExecuteScan(context.Background(), scanID, scanner.GowitnessConfig{
-		URLs:       req.URLs,
-		Timeout:    req.Timeout,
-		Resolution: req.Resolution,
-		Delay:      req.Delay,
-		UserAgent:  req.UserAgent,
-		FullPage:   req.FullPage,
+	h.runLimited(func(ctx context.Context) {
+		if job.Simulate {
+			h.simulator.ExecuteWebScan(ctx, scanID, tool.Name(), job.Targets)
+			return
+		}
+		tool.Execute(ctx, scanID, job)
 	})
 
 	return c.Status(201).JSON(scan)
 }
 
-// CreateTestsslScan creates a new testssl scan
-func (h *WebScanHandler) CreateTestsslScan(c *fiber.Ctx) error {
-	var req models.CreateTestsslScanRequest
+// ImportWebScan records the raw output of a tool run elsewhere as a
+// completed scan
+func (h *WebScanHandler) ImportWebScan(c *fiber.Ctx) error {
+	tool, ok := h.tools.Get(c.Params("tool"))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Unknown tool"})
+	}
+
+	var req models.ImportWebScanRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if req.Name == "" || req.Target == "" || req.Output == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name, target and output are required"})
+	}
 
-	if req.Name == "" || req.Target == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "name and target are required"})
+	results, err := tool.ParseResults(req.Target, []byte(req.Output))
+	if errors.Is(err, tools.ErrNoRawOutput) {
+		return c.Status(400).JSON(fiber.Map{"error": tool.Name() + " output can't be imported"})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	scanID := uuid.New()
-	config := map[string]interface{}{
-		"target":          req.Target,
-		"protocols":       req.Protocols,
-		"ciphers":         req.Ciphers,
-		"vulnerabilities": req.Vulnerabilities,
-		"headers":         req.Headers,
-		"certificate":     req.Certificate,
-		"full":            req.Full,
-		"fast":            req.Fast,
-		"sni":             req.SNI,
-		"starttls":        req.StartTLS,
-	}
-	configJSON, _ := json.Marshal(config)
+	now := time.Now()
+	configJSON, _ := json.Marshal(map[string]interface{}{"imported": true})
 
 	query := `
-		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, configuration)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, name, target, tool, status, progress, created_at
+		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, started_at, completed_at, configuration, origin, project_id)
+		VALUES ($1, $2, $3, $4, 'completed', 100, $5, $5, $5, $6, $7, $8)
+		RETURNING id, name, target, tool, status, progress, created_at, started_at, completed_at, origin, project_id
 	`
 
 	var scan models.WebScan
-	err := h.db.Pool.QueryRow(context.Background(), query,
-		scanID, req.Name, req.Target, "testssl", "pending", 0, time.Now(), configJSON,
-	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status, &scan.Progress, &scan.CreatedAt)
+	err = h.db.Pool.QueryRow(context.Background(), query,
+		scanID, req.Name, req.Target, tool.Name(), now, configJSON, origin.FromRequest(c.Get(origin.Header), c.Get(origin.UserIDHeader)),
+		project.FromRequest(c.Get(project.Header)),
+	).Scan(&scan.ID, &scan.Name, &scan.Target, &scan.Tool, &scan.Status, &scan.Progress, &scan.CreatedAt, &scan.StartedAt, &scan.CompletedAt, &scan.Origin, &scan.ProjectID)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create scan"})
 	}
 
-	// Start scan in background
-	go h.testsslScanner.ExecuteScan(context.Background(), scanID, scanner.TestsslConfig{
-		Target:          req.Target,
-		Protocols:       req.Protocols,
-		Ciphers:         req.Ciphers,
-		Vulnerabilities: req.Vulnerabilities,
-		Headers:         req.Headers,
-		Certificate:     req.Certificate,
-		Full:            req.Full,
-		Fast:            req.Fast,
-		SNI:             req.SNI,
-		StartTLS:        req.StartTLS,
-	})
+	for _, result := range results {
+		h.limits.ApplyWebResult(c.Context(), scanID, &result)
+		scanner.SaveWebScanResult(h.db, scanID, result)
+	}
 
-	return c.Status(201).JSON(scan)
+	return c.Status(201).JSON(fiber.Map{
+		"scan":    scan,
+		"results": len(results),
+	})
 }
 
 // DeleteWebScan deletes a web scan
 func (h *WebScanHandler) DeleteWebScan(c *fiber.Ctx) error {
 	scanID := c.Params("id")
+	id, err := uuid.Parse(scanID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid scan ID"})
+	}
 
 	// Check if scan exists and is not running
 	var status string
 	checkQuery := `SELECT status FROM web_scans WHERE id = $1`
-	err := h.db.Pool.QueryRow(context.Background(), checkQuery, scanID).Scan(&status)
+	err = h.db.Pool.QueryRow(context.Background(), checkQuery, id).Scan(&status)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
 
 	if status == "running" {
-		return c.Status(400).JSON(fiber.Map{"error": "Cannot delete running scan. Cancel it first."})
+		return c.Status(409).JSON(fiber.Map{"error": "Cannot delete running scan. Cancel it first."})
 	}
 
 	// Delete results first (cascade should handle this but being explicit)
@@ -340,6 +336,8 @@ func (h *WebScanHandler) DeleteWebScan(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found"})
 	}
 
+	h.artifacts.Remove(id)
+
 	return c.JSON(fiber.Map{"message": "Scan deleted successfully"})
 }
 
@@ -360,17 +358,30 @@ func (h *WebScanHandler) CancelWebScan(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Scan not found or already completed"})
 	}
 
+	// Stop the tool if it is running here
+	var tool string
+	h.db.Pool.QueryRow(context.Background(), `SELECT tool FROM web_scans WHERE id = $1`, id).Scan(&tool)
+	if t, ok := h.tools.Get(tool); ok {
+		t.Cancel(id)
+	}
+
+	h.artifacts.Remove(id)
+
 	return c.JSON(fiber.Map{"message": "Scan cancelled successfully"})
 }
 
 // GetWebScanResults returns results for a web scan
 func (h *WebScanHandler) GetWebScanResults(c *fiber.Ctx) error {
 	scanID := c.Params("id")
+	redactor, err := queryRedactor(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "profiles": redact.Names()})
+	}
 
 	query := `
 		SELECT id, scan_id, tool, url, status_code, content_length, words, lines,
 			content_type, redirect_url, title, screenshot_path, screenshot_b64,
-			finding_id, severity, finding_text, cve, cwe, metadata, created_at
+			finding_id, severity, finding_text, cve, cwe, metadata, truncated, created_at
 		FROM web_scan_results
 		WHERE scan_id = $1
 		ORDER BY created_at DESC
@@ -392,7 +403,7 @@ func (h *WebScanHandler) GetWebScanResults(c *fiber.Ctx) error {
 		err := rows.Scan(&result.ID, &result.ScanID, &result.Tool, &result.URL,
 			&statusCode, &contentLength, &words, &lines,
 			&contentType, &redirectURL, &title, &screenshotPath, &screenshotB64,
-			&findingID, &severity, &findingText, &cve, &cwe, &metadataJSON, &result.CreatedAt)
+			&findingID, &severity, &findingText, &cve, &cwe, &metadataJSON, &result.Truncated, &result.CreatedAt)
 		if err != nil {
 			continue
 		}
@@ -444,6 +455,7 @@ func (h *WebScanHandler) GetWebScanResults(c *fiber.Ctx) error {
 			json.Unmarshal(metadataJSON, &result.Metadata)
 		}
 
+		redactWebScanResult(&result, redactor)
 		results = append(results, result)
 	}
 
@@ -507,7 +519,7 @@ func (h *WebScanHandler) GetWebScanStats(c *fiber.Ctx) error {
 			stats.ByStatusCode[code] = count
 		}
 
-	case "testssl":
+	case "testssl", "credcheck", "sqlmap":
 		// Count by severity
 		stats.BySeverity = make(map[string]int)
 		rows, _ := h.db.Pool.Query(context.Background(),
@@ -529,25 +541,11 @@ func (h *WebScanHandler) GetWebScanStats(c *fiber.Ctx) error {
 	return c.JSON(stats)
 }
 
-// GetWebScanTemplates returns available templates for web scans
+// GetWebScanTemplates returns available templates for web scans: those of
+// the tools and the ones imported from template packs (web_scan_templates,
+// with ?project= or X-Tenant-ID those of the project)
 func (h *WebScanHandler) GetWebScanTemplates(c *fiber.Ctx) error {
-	templates := []models.WebScanTemplate{
-		// ffuf templates
-		{ID: "ffuf_common", Name: "Common Paths", Description: "Scan for common web paths and directories", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "common", "threads": 40}, IsDefault: true},
-		{ID: "ffuf_directories", Name: "Directory Bruteforce", Description: "Comprehensive directory discovery", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "directory-list-small", "threads": 50}, IsDefault: true},
-		{ID: "ffuf_files", Name: "File Discovery", Description: "Find common files and backups", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "raft-medium-files", "threads": 40, "extensions": []string{".bak", ".old", ".txt", ".log"}}, IsDefault: true},
-		{ID: "ffuf_api", Name: "API Endpoints", Description: "Discover API endpoints", Tool: "ffuf", Category: "api", Config: map[string]interface{}{"wordlist": "common", "threads": 30}, IsDefault: true},
-
-		// gowitness templates
-		{ID: "gowitness_single", Name: "Single Screenshot", Description: "Capture screenshot of a single URL", Tool: "gowitness", Category: "recon", Config: map[string]interface{}{"timeout": 30}, IsDefault: true},
-		{ID: "gowitness_full", Name: "Full Page Screenshot", Description: "Capture full page screenshot", Tool: "gowitness", Category: "recon", Config: map[string]interface{}{"timeout": 60, "full_page": true}, IsDefault: true},
-
-		// testssl templates
-		{ID: "testssl_quick", Name: "Quick SSL Check", Description: "Fast SSL/TLS configuration check", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"protocols": true, "fast": true}, IsDefault: true},
-		{ID: "testssl_full", Name: "Full SSL Audit", Description: "Comprehensive SSL/TLS security audit", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"full": true}, IsDefault: true},
-		{ID: "testssl_vulns", Name: "SSL Vulnerabilities", Description: "Check for SSL/TLS vulnerabilities", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"vulnerabilities": true}, IsDefault: true},
-		{ID: "testssl_ciphers", Name: "Cipher Analysis", Description: "Analyze supported ciphers", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"ciphers": true}, IsDefault: true},
-	}
+	templates := append(h.tools.Templates(), h.importedTemplates(project.Scope(c.Query("project"), c.Get(project.Header)))...)
 
 	// Filter by tool if specified
 	tool := c.Query("tool", "")
@@ -564,6 +562,31 @@ func (h *WebScanHandler) GetWebScanTemplates(c *fiber.Ctx) error {
 	return c.JSON(templates)
 }
 
+// importedTemplates returns the templates imported by the network service
+// from template packs; none when the table doesn't exist yet
+func (h *WebScanHandler) importedTemplates(scope string) []models.WebScanTemplate {
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT id::text, name, description, tool, category, configuration
+		FROM web_scan_templates
+		WHERE $1 = '' OR project_id IN ($1, 'default')
+		ORDER BY name
+	`, scope)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var templates []models.WebScanTemplate
+	for rows.Next() {
+		var t models.WebScanTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.Tool, &t.Category, &t.Config); err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	return templates
+}
+
 // GetWordlists returns available wordlists for ffuf
 func (h *WebScanHandler) GetWordlists(c *fiber.Ctx) error {
 	return c.JSON(h.ffufScanner.GetAvailableWordlists())
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/apierror"
+)
+
+// Errors gives every error response the shared envelope (error, code,
+// message, details, request_id) and tags every request with an
+// X-Request-ID, reusing the gateway's. It goes first so it also sees
+// recovered panics and unknown routes.
+func Errors() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(apierror.RequestIDHeader)
+		if requestID == "" {
+			requestID = apierror.NewRequestID()
+			c.Request().Header.Set(apierror.RequestIDHeader, requestID)
+		}
+		c.Set(apierror.RequestIDHeader, requestID)
+
+		if err := c.Next(); err != nil {
+			status := fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+			envelope := apierror.New(apierror.CodeForStatus(status), err.Error(), nil)
+			envelope.RequestID = requestID
+			return c.Status(status).JSON(envelope)
+		}
+
+		resp := c.Response()
+		if resp.StatusCode() < 400 {
+			return nil
+		}
+		contentType := string(resp.Header.ContentType())
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) || strings.HasPrefix(contentType, fiber.MIMETextPlain) || len(resp.Body()) == 0 {
+			resp.SetBody(apierror.Normalize(resp.StatusCode(), resp.Body(), requestID))
+			resp.Header.SetContentType(fiber.MIMEApplicationJSON)
+		}
+		return nil
+	}
+}
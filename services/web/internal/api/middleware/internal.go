@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/security-scanner/shared/pkg/internalauth"
+)
+
+// InternalAuth rejects requests that don't carry a valid gateway token for
+// this method and path, so the service can't be called directly by anything
+// else that reaches it. Disabled when secret is empty.
+func InternalAuth(secret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if secret == "" {
+			return c.Next()
+		}
+		if !internalauth.Valid(c.Get(internalauth.Header), secret, c.Method(), c.Path()) {
+			return c.Status(401).JSON(fiber.Map{"error": "Requests must come through the gateway"})
+		}
+		c.Locals(internalauth.VerifiedLocal, true)
+		return c.Next()
+	}
+}
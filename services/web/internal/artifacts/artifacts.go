@@ -0,0 +1,197 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a scan has no workspace or the artifact does not exist
+var ErrNotFound = errors.New("artifact not found")
+
+// scratchDir holds intermediate files inside a workspace. Its presence after a
+// restart means the scan never finished, so the whole workspace is discarded.
+const scratchDir = ".scratch"
+
+// Artifact is a file produced by a scan
+type Artifact struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// Manager gives every scan its own workspace under root. Raw tool outputs are
+// kept for the retention period (or until the scan is deleted); scratch files
+// are removed as soon as the scan finishes.
+type Manager struct {
+	root      string
+	retention time.Duration
+}
+
+func NewManager(root string, retention time.Duration) (*Manager, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	return &Manager{root: root, retention: retention}, nil
+}
+
+// Workspace is the working directory of one running scan
+type Workspace struct {
+	dir string
+}
+
+// Create makes a fresh workspace for a scan
+func (m *Manager) Create(scanID uuid.UUID) (*Workspace, error) {
+	dir := filepath.Join(m.root, scanID.String())
+	if err := os.MkdirAll(filepath.Join(dir, scratchDir), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create scan workspace: %w", err)
+	}
+	return &Workspace{dir: dir}, nil
+}
+
+// Output returns the path of a raw output file that is kept after the scan
+func (w *Workspace) Output(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+// Scratch returns the path of an intermediate file removed when the scan finishes
+func (w *Workspace) Scratch(name string) string {
+	return filepath.Join(w.dir, scratchDir, name)
+}
+
+// Close removes scratch files, and the workspace itself when nothing was produced
+func (w *Workspace) Close() {
+	os.RemoveAll(filepath.Join(w.dir, scratchDir))
+	if entries, err := os.ReadDir(w.dir); err == nil && len(entries) == 0 {
+		os.Remove(w.dir)
+	}
+}
+
+// List returns the artifacts kept for a scan
+func (m *Manager) List(scanID uuid.UUID) ([]Artifact, error) {
+	entries, err := os.ReadDir(filepath.Join(m.root, scanID.String()))
+	if os.IsNotExist(err) {
+		return []Artifact{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []Artifact{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		artifacts = append(artifacts, Artifact{Name: entry.Name(), Size: info.Size(), ModifiedAt: info.ModTime()})
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
+
+// Path returns the file path of a kept artifact
+func (m *Manager) Path(scanID uuid.UUID, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(m.root, scanID.String(), name)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Save writes a kept artifact of a scan, e.g. the full content of a result
+// field cut to its size limit; it expires with the scan's other artifacts
+func (m *Manager) Save(scanID uuid.UUID, name string, data []byte) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid artifact name: %s", name)
+	}
+	dir := filepath.Join(m.root, scanID.String())
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+// Remove deletes a scan's workspace (on cancel or delete)
+func (m *Manager) Remove(scanID uuid.UUID) error {
+	return os.RemoveAll(filepath.Join(m.root, scanID.String()))
+}
+
+// RecoverOrphans removes workspaces left behind by scans interrupted by a
+// crash or restart; it must run before any scan starts
+func (m *Manager) RecoverOrphans() {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.root, entry.Name(), scratchDir)); err == nil {
+			os.RemoveAll(filepath.Join(m.root, entry.Name()))
+			removed++
+		}
+	}
+	if removed > 0 {
+		log.Printf("🧹 Removed %d workspace(s) of interrupted scans", removed)
+	}
+}
+
+// Start removes expired workspaces every hour until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	if m.retention <= 0 {
+		return
+	}
+	m.removeExpired()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.removeExpired()
+		}
+	}
+}
+
+func (m *Manager) removeExpired() {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-m.retention)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(m.root, entry.Name())
+		// Running scans keep their scratch dir and are never expired
+		if _, err := os.Stat(filepath.Join(dir, scratchDir)); err == nil {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			os.RemoveAll(dir)
+		}
+	}
+}
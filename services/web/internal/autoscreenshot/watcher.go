@@ -0,0 +1,321 @@
+package autoscreenshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/shared/pkg/screenshots"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/tools"
+)
+
+// schemaSQL records which network scans were checked, and the gowitness
+// scan queued for each
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS screenshot_checks (
+    network_scan_id UUID PRIMARY KEY,
+    web_scan_id UUID,
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// lookback is how long after completing a network scan is still checked, so
+// that enabling the watcher doesn't screenshot the whole scan history
+const lookback = time.Hour
+
+// httpsPorts are web ports served over TLS when nmap didn't tell
+var httpsPorts = map[int]bool{443: true, 4443: true, 8443: true, 9443: true}
+
+// Watcher queues a gowitness scan whenever a network scan finds web ports
+// that weren't open in the previous scan of a known asset, and links it to
+// the asset's timeline (asset_screenshots)
+type Watcher struct {
+	db        *database.Database
+	gowitness tools.Tool
+	limiter   *runtimeconfig.Limiter
+	ports     map[int]bool
+	leases    *shareddb.Leases
+}
+
+// NewWatcher creates the tables of the watcher; ports is a comma-separated
+// list of the web ports to screenshot
+func NewWatcher(db *database.Database, gowitness tools.Tool, limiter *runtimeconfig.Limiter, ports string) (*Watcher, error) {
+	w := &Watcher{db: db, gowitness: gowitness, limiter: limiter, ports: map[int]bool{}}
+	for _, p := range strings.Split(ports, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid web port %q", p)
+		}
+		w.ports[port] = true
+	}
+
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL+";"+screenshots.SchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create screenshot tables: %w", err)
+	}
+	return w, nil
+}
+
+// SetLeases makes Start check scans only on the replica holding the job's
+// lease
+func (w *Watcher) SetLeases(leases *shareddb.Leases) {
+	w.leases = leases
+}
+
+// Start checks the network scans completed since the last run every minute
+// until ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if w.leases.Acquire(ctx, "web:auto-screenshot", 3*time.Minute) {
+			w.checkCompleted(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// completedScan is a network scan to check for new web ports
+type completedScan struct {
+	id          uuid.UUID
+	name        string
+	projectID   string
+	completedAt time.Time
+}
+
+// checkCompleted claims the recently completed network scans nobody checked
+// yet and checks each
+func (w *Watcher) checkCompleted(ctx context.Context) {
+	rows, err := w.db.Pool.Query(ctx, `
+		WITH claimed AS (
+			INSERT INTO screenshot_checks (network_scan_id)
+			SELECT id FROM scans
+			WHERE status = 'completed' AND scanner <> 'dns' AND completed_at > $1
+			  AND COALESCE(configuration->>'simulated', '') <> 'true'
+			  AND id NOT IN (SELECT network_scan_id FROM screenshot_checks)
+			ORDER BY completed_at
+			LIMIT 20
+			ON CONFLICT DO NOTHING
+			RETURNING network_scan_id
+		)
+		SELECT s.id, s.name, s.project_id, s.completed_at
+		FROM scans s JOIN claimed c ON c.network_scan_id = s.id
+		ORDER BY s.completed_at
+	`, time.Now().Add(-lookback))
+	if err != nil {
+		log.Printf("⚠️ Failed to claim network scans for screenshots: %v", err)
+		return
+	}
+	var claimed []completedScan
+	for rows.Next() {
+		var s completedScan
+		if err := rows.Scan(&s.id, &s.name, &s.projectID, &s.completedAt); err == nil {
+			claimed = append(claimed, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range claimed {
+		if err := w.check(ctx, s); err != nil {
+			log.Printf("⚠️ Failed to check network scan %s for new web ports: %v", s.id, err)
+		}
+	}
+}
+
+// port is a port of a scan_results row
+type port struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	State    string `json:"state"`
+	Service  string `json:"service"`
+}
+
+// newWebPorts is what a network scan found on a known asset
+type newWebPorts struct {
+	host     string
+	hostname string
+	urls     []string
+}
+
+// check queues a gowitness scan of the web ports the scan found open that
+// were closed in the previous scan of their host
+func (w *Watcher) check(ctx context.Context, scan completedScan) error {
+	rows, err := w.db.Pool.Query(ctx, `
+		SELECT host, COALESCE(hostname, ''), COALESCE(ports, '[]'::jsonb)
+		FROM scan_results WHERE scan_id = $1 AND COALESCE(state, '') <> 'down'
+		ORDER BY host
+	`, scan.id)
+	if err != nil {
+		return err
+	}
+	type hostPorts struct {
+		host, hostname string
+		ports          []port
+	}
+	var hosts []hostPorts
+	for rows.Next() {
+		var h hostPorts
+		var raw []byte
+		if err := rows.Scan(&h.host, &h.hostname, &raw); err != nil {
+			continue
+		}
+		json.Unmarshal(raw, &h.ports)
+		hosts = append(hosts, h)
+	}
+	rows.Close()
+
+	var found []newWebPorts
+	for _, h := range hosts {
+		current := w.openWebPorts(h.ports)
+		if len(current) == 0 {
+			continue
+		}
+		previous, known, err := w.previousPorts(ctx, h.host, scan)
+		if err != nil {
+			return err
+		}
+		// New assets have no timeline to compare with
+		if !known {
+			continue
+		}
+		entry := newWebPorts{host: h.host, hostname: h.hostname}
+		for _, p := range current {
+			if !previous[p.Port] {
+				entry.urls = append(entry.urls, webURL(h.host, h.hostname, p))
+			}
+		}
+		if len(entry.urls) > 0 {
+			found = append(found, entry)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return w.queue(ctx, scan, found)
+}
+
+// openWebPorts returns the open TCP ports of ports that are web ports
+func (w *Watcher) openWebPorts(ports []port) []port {
+	var web []port
+	for _, p := range ports {
+		if p.State == "open" && (p.Protocol == "" || p.Protocol == "tcp") && w.ports[p.Port] {
+			web = append(web, p)
+		}
+	}
+	sort.Slice(web, func(i, j int) bool { return web[i].Port < web[j].Port })
+	return web
+}
+
+// previousPorts returns the open TCP ports of host in its latest scan
+// completed before scan, and whether there is one
+func (w *Watcher) previousPorts(ctx context.Context, host string, scan completedScan) (map[int]bool, bool, error) {
+	var raw []byte
+	err := w.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(r.ports, '[]'::jsonb)
+		FROM scan_results r JOIN scans s ON s.id = r.scan_id
+		WHERE r.host = $1 AND s.id <> $2 AND s.status = 'completed' AND s.completed_at < $3
+		  AND COALESCE(r.state, '') <> 'down' AND s.project_id = $4
+		ORDER BY s.completed_at DESC
+		LIMIT 1
+	`, host, scan.id, scan.completedAt, scan.projectID).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var ports []port
+	json.Unmarshal(raw, &ports)
+	open := map[int]bool{}
+	for _, p := range ports {
+		if p.State == "open" && (p.Protocol == "" || p.Protocol == "tcp") {
+			open[p.Port] = true
+		}
+	}
+	return open, true, nil
+}
+
+// webURL returns the URL of a web port, by name when the host has one
+func webURL(host, hostname string, p port) string {
+	scheme := "http"
+	service := strings.ToLower(p.Service)
+	if httpsPorts[p.Port] || strings.Contains(service, "https") || strings.Contains(service, "ssl") {
+		scheme = "https"
+	}
+	name := host
+	if hostname != "" {
+		name = hostname
+	}
+	if (scheme == "http" && p.Port == 80) || (scheme == "https" && p.Port == 443) {
+		if strings.Contains(name, ":") {
+			name = "[" + name + "]"
+		}
+		return scheme + "://" + name
+	}
+	return scheme + "://" + net.JoinHostPort(name, strconv.Itoa(p.Port))
+}
+
+// queue creates one gowitness scan of every new web port of the network
+// scan, in its project, and links it to each asset
+func (w *Watcher) queue(ctx context.Context, scan completedScan, found []newWebPorts) error {
+	var urls []string
+	for _, f := range found {
+		urls = append(urls, f.urls...)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"name": "New web ports of " + scan.name,
+		"urls": urls,
+	})
+	job, err := w.gowitness.Validate(body)
+	if err != nil {
+		return err
+	}
+	job.Config["network_scan_id"] = scan.id.String()
+	configJSON, _ := json.Marshal(job.Config)
+
+	webScanID := uuid.New()
+	if _, err := w.db.Pool.Exec(ctx, `
+		INSERT INTO web_scans (id, name, target, tool, status, progress, created_at, configuration, origin, project_id)
+		VALUES ($1, $2, $3, $4, 'pending', 0, NOW(), $5, $6, $7)
+	`, webScanID, job.Name, job.Target, w.gowitness.Name(), configJSON,
+		origin.Of(origin.Workflow, "auto-screenshot"), scan.projectID); err != nil {
+		return err
+	}
+	for _, f := range found {
+		if _, err := w.db.Pool.Exec(ctx, `
+			INSERT INTO asset_screenshots (id, asset, hostname, urls, network_scan_id, web_scan_id)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, uuid.New(), strings.ToLower(f.host), strings.ToLower(f.hostname), f.urls, scan.id, webScanID); err != nil {
+			log.Printf("⚠️ Failed to link screenshots of %s to scan %s: %v", f.host, webScanID, err)
+		}
+	}
+	w.db.Pool.Exec(ctx, `UPDATE screenshot_checks SET web_scan_id = $1 WHERE network_scan_id = $2`, webScanID, scan.id)
+	log.Printf("📸 Network scan %s opened %d web ports on known assets, screenshotting them with scan %s", scan.id, len(urls), webScanID)
+
+	go func() {
+		ctx := context.Background()
+		w.limiter.Acquire(ctx)
+		defer w.limiter.Release()
+		w.gowitness.Execute(ctx, webScanID, job)
+	}()
+	return nil
+}
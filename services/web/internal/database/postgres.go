@@ -5,6 +5,11 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/security-scanner/shared/pkg/chat"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/owners"
+	"github.com/security-scanner/shared/pkg/scope"
+	"github.com/security-scanner/shared/pkg/tlspolicy"
 )
 
 // Database wraps the PostgreSQL connection pool
@@ -19,14 +24,22 @@ func New(databaseURL string) (*Database, error) {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	// Retries while the database starts, like every service
+	var pool *pgxpool.Pool
+	err = shareddb.Retry(func() error {
+		p, err := pgxpool.NewWithConfig(context.Background(), config)
+		if err != nil {
+			return err
+		}
+		if err := p.Ping(context.Background()); err != nil {
+			p.Close()
+			return err
+		}
+		pool = p
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
-	}
-
-	// Test connection
-	if err := pool.Ping(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
 	}
 
 	return &Database{Pool: pool}, nil
@@ -38,3 +51,70 @@ func (db *Database) Close() {
 		db.Pool.Close()
 	}
 }
+
+// Leases creates the job lease table and returns the leases electing which
+// replica runs each background job
+func (db *Database) Leases(ctx context.Context) (*shareddb.Leases, error) {
+	if _, err := db.Pool.Exec(ctx, shareddb.LeaseSchemaSQL); err != nil {
+		return nil, err
+	}
+	return shareddb.NewLeases(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
+
+// ChatNotifier creates the chat channel table, managed through the gateway,
+// and returns a notifier posting to its channels and to the env ones
+func (db *Database) ChatNotifier(ctx context.Context, env []chat.Channel, reportBaseURL string) (*chat.Notifier, error) {
+	if _, err := db.Pool.Exec(ctx, chat.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return chat.NewNotifier(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}, env, reportBaseURL), nil
+}
+
+// Scope creates the scope rule table, managed through the gateway, and
+// returns the checker scans' targets must pass
+func (db *Database) Scope(ctx context.Context) (*scope.Checker, error) {
+	if _, err := db.Pool.Exec(ctx, scope.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return scope.NewChecker(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
+
+// TLSPolicies creates the TLS policy table, managed through the gateway,
+// and returns the loader of the policies testssl.sh scans are checked against
+func (db *Database) TLSPolicies(ctx context.Context) (*tlspolicy.Loader, error) {
+	if _, err := db.Pool.Exec(ctx, tlspolicy.SchemaSQL); err != nil {
+		return nil, err
+	}
+	return tlspolicy.NewLoader(func(ctx context.Context, query string, args ...interface{}) shareddb.Row {
+		return db.Pool.QueryRow(ctx, query, args...)
+	}), nil
+}
+
+// Owners creates the asset owner table, managed through the network
+// service, and returns its assignments for owners.Match
+func (db *Database) Owners(ctx context.Context) ([]owners.Assignment, error) {
+	if _, err := db.Pool.Exec(ctx, owners.SchemaSQL); err != nil {
+		return nil, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+owners.Columns+` FROM asset_owners ORDER BY target`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []owners.Assignment{}
+	for rows.Next() {
+		a, err := owners.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, *a)
+	}
+	return assignments, rows.Err()
+}
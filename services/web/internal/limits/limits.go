@@ -0,0 +1,141 @@
+// Package limits caps the size of the text fields of scan results. Nuclei
+// requests and responses or testssl.sh findings can be megabytes long; above
+// its limit a field is cut, marked as truncated, and its full content is kept
+// as an artifact of the scan or in object storage.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/objectstore"
+	"github.com/security-scanner/web-service/internal/artifacts"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+// Defaults are the limits of the capped fields, in bytes
+var Defaults = map[string]int{
+	"request":      64 << 10,
+	"response":     256 << 10,
+	"curl_command": 16 << 10,
+	"finding_text": 16 << 10,
+}
+
+// MaxLineBytes bounds a line of tool output read at once, so that a huge
+// finding is cut to its limits instead of stopping the output's processing
+const MaxLineBytes = 64 << 20
+
+// Parse reads "field=bytes" pairs separated by commas over the Defaults; 0
+// removes the limit of a field
+func Parse(spec string) (map[string]int, error) {
+	fields := map[string]int{}
+	for field, limit := range Defaults {
+		fields[field] = limit
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if !ok {
+			return nil, fmt.Errorf("invalid field limit %q: expected field=bytes", pair)
+		}
+		if _, known := Defaults[field]; !known {
+			return nil, fmt.Errorf("unknown field %q, limits apply to %s", field, strings.Join(Fields(), ", "))
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit of %s: %q", field, value)
+		}
+		fields[field] = limit
+	}
+	return fields, nil
+}
+
+// Fields returns the names of the capped fields
+func Fields() []string {
+	fields := make([]string, 0, len(Defaults))
+	for field := range Defaults {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Limits cuts fields to their limit. The full content goes to store when
+// set, to the scan's artifacts otherwise. A nil Limits cuts nothing.
+type Limits struct {
+	fields    map[string]int
+	artifacts *artifacts.Manager
+	store     *objectstore.S3
+}
+
+// New returns limits of fields (see Parse); store may be nil
+func New(fields map[string]int, am *artifacts.Manager, store *objectstore.S3) *Limits {
+	return &Limits{fields: fields, artifacts: am, store: store}
+}
+
+// Apply returns value cut to the limit of field, and records the cut in
+// truncated. owner is the ID of the result the field belongs to.
+func (l *Limits) Apply(ctx context.Context, scanID, owner uuid.UUID, field, value string, truncated *map[string]models.Truncation) string {
+	if l == nil {
+		return value
+	}
+	limit := l.fields[field]
+	if limit <= 0 || len(value) <= limit {
+		return value
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	t := models.Truncation{OriginalBytes: len(value), StoredBytes: cut}
+	l.keep(ctx, scanID, owner, field, value, &t)
+
+	if *truncated == nil {
+		*truncated = map[string]models.Truncation{}
+	}
+	(*truncated)[field] = t
+	return value[:cut] + fmt.Sprintf("\n…[truncated: stored %d of %d bytes]", cut, len(value))
+}
+
+// keep stores the full content of a cut field and points t at it
+func (l *Limits) keep(ctx context.Context, scanID, owner uuid.UUID, field, value string, t *models.Truncation) {
+	if l.store != nil {
+		key := fmt.Sprintf("%s/%s-%s.txt", scanID, owner, field)
+		err := l.store.Put(ctx, key, strings.NewReader(value), int64(len(value)), "text/plain; charset=utf-8")
+		if err == nil {
+			t.Object = l.store.URI(key)
+			return
+		}
+		log.Printf("Failed to store full %s of result %s in %s: %v", field, owner, l.store.Name(), err)
+	}
+	if l.artifacts != nil {
+		name := fmt.Sprintf("%s-%s.txt", field, owner)
+		if err := l.artifacts.Save(scanID, name, []byte(value)); err != nil {
+			log.Printf("Failed to keep full %s of result %s: %v", field, owner, err)
+			return
+		}
+		t.Artifact = name
+	}
+}
+
+// ApplyWebResult cuts the finding text of a web scan result, giving the
+// result its ID first so that the full text can be traced back to it
+func (l *Limits) ApplyWebResult(ctx context.Context, scanID uuid.UUID, result *models.WebScanResult) {
+	if l == nil {
+		return
+	}
+	if result.ID == uuid.Nil {
+		result.ID = uuid.New()
+	}
+	result.FindingText = l.Apply(ctx, scanID, result.ID, "finding_text", result.FindingText, &result.Truncated)
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
 // VulnerabilityScan represents a Nuclei vulnerability scan
@@ -18,6 +19,8 @@ type VulnerabilityScan struct {
 	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
 	ErrorMessage  *string                `json:"error_message,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	Origin        string                 `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID     string                 `json:"project_id"`
 	// Nuclei-specific fields
 	Templates     []string               `json:"templates,omitempty"`      // Template IDs to use
 	Severity      []string               `json:"severity,omitempty"`       // Filter by severity: info, low, medium, high, critical
@@ -39,9 +42,23 @@ type Vulnerability struct {
 	Request          string     `json:"request,omitempty"`           // Raw request
 	Response         string     `json:"response,omitempty"`          // Raw response
 	Metadata         VulnMeta   `json:"metadata"`                    // Additional metadata
+	Status           string     `json:"status"`                      // open, fixed, verifying, verified_fixed, reopened
+	Truncated        map[string]Truncation `json:"truncated,omitempty"` // fields cut to their size limit
+	Suppressed       string     `json:"suppressed,omitempty"`        // suppressed or collapsed by a noise profile
+	SuppressedBy     string     `json:"suppressed_by,omitempty"`     // profile/rule that filtered it
 	CreatedAt        time.Time  `json:"created_at"`
 }
 
+// Truncation is a result field cut to its size limit. The stored value ends
+// with a truncation marker; the full content is kept in the scan's artifacts
+// or in object storage when it could be saved.
+type Truncation struct {
+	OriginalBytes int    `json:"original_bytes"`
+	StoredBytes   int    `json:"stored_bytes"`
+	Artifact      string `json:"artifact,omitempty"` // name among the scan's artifacts
+	Object        string `json:"object,omitempty"`   // s3:// address
+}
+
 // VulnMeta contains metadata about a vulnerability
 type VulnMeta struct {
 	Description    string            `json:"description,omitempty"`
@@ -54,23 +71,31 @@ type VulnMeta struct {
 	Author         []string          `json:"author,omitempty"`
 }
 
-// VulnScanLog represents a log entry for a vulnerability scan
-type VulnScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"`     // info, warning, error
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
+// VulnScanLog represents a log entry for a vulnerability scan (shared by all services)
+type VulnScanLog = shared.ScanLog
+
+// VulnScanTarget is one target of a vulnerability scan and how far nuclei
+// got with it
+type VulnScanTarget struct {
+	ID          uuid.UUID  `json:"id"`
+	Target      string     `json:"target"`
+	Status      string     `json:"status"` // pending, running, done, failed
+	Attempts    int        `json:"attempts"`
+	Findings    int        `json:"findings"`
+	Error       *string    `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 // CreateVulnScanRequest represents the request to create a vulnerability scan
 type CreateVulnScanRequest struct {
 	Name          string                 `json:"name"`
-	Target        string                 `json:"target"` // URL, IP, or file with targets
+	Target        string                 `json:"target"` // URLs or IPs, separated by commas, spaces or newlines
 	Templates     []string               `json:"templates,omitempty"`
 	Severity      []string               `json:"severity,omitempty"`
 	Tags          []string               `json:"tags,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	Simulate      bool                   `json:"simulate,omitempty"` // synthetic results, no traffic
 }
 
 // VulnScanStats represents statistics for a vulnerability scan
@@ -78,4 +103,124 @@ type VulnScanStats struct {
 	Total      int            `json:"total"`
 	BySeverity map[string]int `json:"by_severity"` // count by severity level
 	ByType     map[string]int `json:"by_type"`     // count by vuln type
+	ByHost     map[string]int `json:"by_host"`     // count by host
+
+	ByTemplate []TemplateHits `json:"by_template"` // most hits first
+
+	// Findings filtered by the scan's noise profile, by disposition
+	// (suppressed, collapsed); the counts above leave them out
+	Filtered map[string]int `json:"filtered"`
+
+	// Findings per severity over the scan's run time, in buckets of
+	// BucketSeconds counted from started_at
+	BucketSeconds int            `json:"bucket_seconds"`
+	Timeline      []StatsBucket  `json:"timeline"`
+	Performance   ScanThroughput `json:"performance"`
+}
+
+// TemplateHits is the number of findings of one template in a scan
+type TemplateHits struct {
+	TemplateID   string `json:"template_id"`
+	TemplateName string `json:"template_name"`
+	Severity     string `json:"severity"`
+	Count        int    `json:"count"`
+	Hosts        int    `json:"hosts"` // distinct hosts matched
+}
+
+// StatsBucket is the findings reported during one slice of a scan
+type StatsBucket struct {
+	Offset     int            `json:"offset"` // seconds since the scan started
+	Start      time.Time      `json:"start"`
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+// ScanThroughput is how fast a scan ran. Requests and errors are the last
+// counters nuclei reported (-stats); scans that didn't report them, such as
+// simulated ones, only have the duration.
+type ScanThroughput struct {
+	DurationSeconds   float64 `json:"duration_seconds"`
+	Requests          int64   `json:"requests"`
+	Errors            int64   `json:"errors"`
+	RequestsPerSecond float64 `json:"requests_per_second"` // requests / duration
+	PeakRPS           float64 `json:"peak_rps"`            // highest rate nuclei reported
+	Templates         int64   `json:"templates"`
+	Hosts             int64   `json:"hosts"`
+}
+
+// NucleiRunStats are the counters of a nuclei run, stored with the scan
+type NucleiRunStats struct {
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	Templates int64   `json:"templates"`
+	Hosts     int64   `json:"hosts"`
+	PeakRPS   float64 `json:"peak_rps"`
+}
+
+// FindingHistory is every observation of a deduplicated finding: the same
+// template on the same host, across all scans
+type FindingHistory struct {
+	Fingerprint  string               `json:"fingerprint"` // same fingerprint as notifications
+	TemplateID   string               `json:"template_id"`
+	TemplateName string               `json:"template_name"`
+	Host         string               `json:"host"`
+	Severity     string               `json:"severity"` // of the latest observation
+	Status       string               `json:"status"`   // of the latest observation
+	FirstSeen    time.Time            `json:"first_seen"`
+	LastSeen     time.Time            `json:"last_seen"`
+	Occurrences  int                  `json:"occurrences"`
+	Scans        int                  `json:"scans"`
+	Observations []FindingObservation `json:"observations"` // oldest first
+}
+
+// FindingObservation is one sighting of a finding with its evidence as
+// recorded by that scan
+type FindingObservation struct {
+	FindingID        uuid.UUID `json:"finding_id"`
+	ScanID           uuid.UUID `json:"scan_id"`
+	ScanName         string    `json:"scan_name"`
+	Simulated        bool      `json:"simulated,omitempty"`
+	ObservedAt       time.Time `json:"observed_at"`
+	Severity         string    `json:"severity"`
+	Status           string    `json:"status"`
+	MatchedAt        string    `json:"matched_at"`
+	ExtractedResults []string  `json:"extracted_results,omitempty"`
+	CURLCommand      string    `json:"curl_command,omitempty"`
+	Request          string    `json:"request,omitempty"`
+	Response         string    `json:"response,omitempty"`
+}
+
+// CVERescanRequest asks to re-test every known asset that may run the
+// software a CVE's template targets. Keywords are matched against recorded
+// services and technologies, in addition to the template's products.
+type CVERescanRequest struct {
+	CVE        string   `json:"cve,omitempty"`         // e.g. CVE-2021-44228
+	TemplateID string   `json:"template_id,omitempty"` // nuclei template ID, when it isn't the CVE
+	Keywords   []string `json:"keywords,omitempty"`    // e.g. log4j, solr
+	DryRun     bool     `json:"dry_run,omitempty"`     // list affected assets without scanning
+}
+
+// CVERescan is the outcome of a CVE rescan: the matched assets and the
+// verification scan queued against each
+type CVERescan struct {
+	TemplateID   string          `json:"template_id"`
+	TemplateName string          `json:"template_name,omitempty"`
+	CVE          []string        `json:"cve,omitempty"`
+	Keywords     []string        `json:"keywords"`
+	Assets       []AffectedAsset `json:"assets"`
+	Total        int             `json:"total"`
+	Queued       int             `json:"queued"`
+	OutOfScope   int             `json:"out_of_scope"` // assets the scope rules kept from being rescanned
+}
+
+// AffectedAsset is a target whose recorded services or technologies match
+// a CVE rescan's keywords
+type AffectedAsset struct {
+	Target     string     `json:"target"`
+	Source     string     `json:"source"` // network, recon or web
+	Matched    []string   `json:"matched"`
+	Detail     string     `json:"detail"` // the service, technology or finding that matched
+	LastSeen   time.Time  `json:"last_seen"`
+	ScanID     *uuid.UUID `json:"scan_id,omitempty"`
+	OutOfScope string     `json:"out_of_scope,omitempty"` // why the scope rules kept the asset from being rescanned
 }
@@ -4,14 +4,15 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
 )
 
-// WebScan represents a web scanning task (ffuf, gowitness, testssl)
+// WebScan represents a web scanning task (ffuf, gowitness, testssl, credcheck, sqlmap)
 type WebScan struct {
 	ID            uuid.UUID              `json:"id"`
 	Name          string                 `json:"name"`
 	Target        string                 `json:"target"`
-	Tool          string                 `json:"tool"`   // ffuf, gowitness, testssl
+	Tool          string                 `json:"tool"`   // ffuf, gowitness, testssl, credcheck, sqlmap
 	Status        string                 `json:"status"` // pending, running, completed, failed, cancelled
 	Progress      int                    `json:"progress"`
 	CreatedAt     time.Time              `json:"created_at"`
@@ -19,6 +20,8 @@ type WebScan struct {
 	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
 	ErrorMessage  *string                `json:"error_message,omitempty"`
 	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	Origin        string                 `json:"origin"` // manual, schedule:<id>, workflow:<id>, ci:<API key name>
+	ProjectID     string                 `json:"project_id"`
 }
 
 // WebScanResult represents a single result from a web scan
@@ -42,17 +45,12 @@ type WebScanResult struct {
 	CVE            string                 `json:"cve,omitempty"`
 	CWE            string                 `json:"cwe,omitempty"`
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Truncated      map[string]Truncation  `json:"truncated,omitempty"` // fields cut to their size limit
 	CreatedAt      time.Time              `json:"created_at"`
 }
 
-// WebScanLog represents a log entry for a web scan
-type WebScanLog struct {
-	ID        uuid.UUID `json:"id"`
-	ScanID    uuid.UUID `json:"scan_id"`
-	Level     string    `json:"level"` // info, warning, error, debug
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
-}
+// WebScanLog represents a log entry for a web scan (shared by all services)
+type WebScanLog = shared.ScanLog
 
 // CreateFfufScanRequest represents the request to create a ffuf scan
 type CreateFfufScanRequest struct {
@@ -69,6 +67,7 @@ type CreateFfufScanRequest struct {
 	Headers        []string `json:"headers"`        // Custom headers
 	Recursion      bool     `json:"recursion"`      // Enable recursion
 	RecursionDepth int      `json:"recursion_depth"`
+	Simulate       bool     `json:"simulate"`       // Synthetic results, no traffic
 }
 
 // CreateGowintessScanRequest represents the request to create a gowitness scan
@@ -80,6 +79,7 @@ type CreateGowintessScanRequest struct {
 	Delay      int      `json:"delay"`      // Delay before screenshot
 	UserAgent  string   `json:"user_agent"` // Custom user agent
 	FullPage   bool     `json:"full_page"`  // Capture full page
+	Simulate   bool     `json:"simulate"`   // Synthetic results, no traffic
 }
 
 // CreateTestsslScanRequest represents the request to create a testssl scan
@@ -95,13 +95,53 @@ type CreateTestsslScanRequest struct {
 	Fast            bool   `json:"fast"`            // Fast mode
 	SNI             string `json:"sni"`             // Server Name Indication
 	StartTLS        string `json:"starttls"`        // starttls protocol
+	Simulate        bool   `json:"simulate"`        // Synthetic results, no traffic
+}
+
+// CreateCredCheckScanRequest represents the request to create a default credential check
+type CreateCredCheckScanRequest struct {
+	Name          string   `json:"name"`
+	Targets       []string `json:"targets"`         // http(s)://, ssh://, snmp://, postgres://, mysql:// or redis:// URLs
+	NetworkScanID string   `json:"network_scan_id"` // check the open ports of a network scan
+	Services      []string `json:"services"`        // http, ssh, snmp, postgres, mysql, redis
+	MaxAttempts   int      `json:"max_attempts"`    // Attempts per target
+	Delay         int      `json:"delay"`           // Seconds between attempts on a target
+	Timeout       int      `json:"timeout"`         // Seconds per attempt
+	Concurrency   int      `json:"concurrency"`     // Targets checked at once
+	Simulate      bool     `json:"simulate"`        // Synthetic results, no logins
+}
+
+// CreateSqlmapScanRequest represents the request to create a sqlmap scan
+type CreateSqlmapScanRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`        // URL with the parameters to test
+	Method     string   `json:"method"`     // HTTP method, GET or POST by default
+	Data       string   `json:"data"`       // Request body
+	Cookie     string   `json:"cookie"`     // Cookie header
+	Headers    []string `json:"headers"`    // Extra "Name: value" headers
+	Parameters []string `json:"parameters"` // Test only these parameters
+	Level      int      `json:"level"`      // 1-5
+	Risk       int      `json:"risk"`       // 1-3
+	Technique  string   `json:"technique"`  // Subset of BEUSTQ
+	DBMS       string   `json:"dbms"`       // Known back-end DBMS
+	Threads    int      `json:"threads"`    // Concurrent requests, 1-10
+	Timeout    int      `json:"timeout"`    // Seconds per request
+	Simulate   bool     `json:"simulate"`   // Synthetic results, no traffic
+}
+
+// ImportWebScanRequest represents the request to import the raw output of
+// a tool run elsewhere
+type ImportWebScanRequest struct {
+	Name   string `json:"name"`
+	Target string `json:"target"` // what the tool scanned
+	Output string `json:"output"` // ffuf -of json, testssl.sh --jsonfile or sqlmap console output
 }
 
 // WebScanStats represents statistics for a web scan
 type WebScanStats struct {
 	Total          int            `json:"total"`
 	ByStatusCode   map[int]int    `json:"by_status_code,omitempty"`  // ffuf
-	BySeverity     map[string]int `json:"by_severity,omitempty"`     // testssl
+	BySeverity     map[string]int `json:"by_severity,omitempty"`     // testssl, credcheck, sqlmap
 	UniqueURLs     int            `json:"unique_urls,omitempty"`
 	Screenshots    int            `json:"screenshots,omitempty"`     // gowitness
 }
@@ -111,8 +151,39 @@ type WebScanTemplate struct {
 	ID          string                 `json:"id"`
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
-	Tool        string                 `json:"tool"` // ffuf, gowitness, testssl
+	Tool        string                 `json:"tool"` // ffuf, gowitness, testssl, sqlmap
 	Category    string                 `json:"category"`
 	Config      map[string]interface{} `json:"config"`
 	IsDefault   bool                   `json:"is_default"`
 }
+
+// EndpointComparison reconciles the paths ffuf, kiterunner and swagger
+// reported for the same host. Scans holds the scan each tool's results come
+// from; tools without one are left out of the comparison.
+type EndpointComparison struct {
+	Host      string                  `json:"host"`
+	Tools     []string                `json:"tools"`
+	Scans     map[string]ComparedScan `json:"scans"`
+	Endpoints []ComparedEndpoint      `json:"endpoints"`
+	Summary   map[string]int          `json:"summary"`
+}
+
+// ComparedScan identifies a scan of an endpoint comparison
+type ComparedScan struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Target      string     `json:"target"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ComparedEndpoint is a path as each tool of a comparison saw it. Statuses
+// are the HTTP status codes the tools got; swagger documents paths without
+// requesting them, so it has none.
+type ComparedEndpoint struct {
+	Path           string         `json:"path"`
+	Methods        []string       `json:"methods,omitempty"`
+	FoundBy        []string       `json:"found_by"`
+	MissedBy       []string       `json:"missed_by,omitempty"`
+	Statuses       map[string]int `json:"statuses,omitempty"`
+	StatusMismatch bool           `json:"status_mismatch,omitempty"` // the tools got different status codes
+}
@@ -0,0 +1,416 @@
+// Package noise filters the informational nuclei findings that drown real
+// issues. A profile suppresses templates by ID or tag, and collapses
+// fingerprinting templates (technology, WAF and service detection) into
+// the technology list of each asset. Filtered findings are still stored,
+// marked, so they can be shown on demand.
+package noise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+// Dispositions of a filtered finding
+const (
+	Suppressed = "suppressed"
+	Collapsed  = "collapsed"
+)
+
+// Profile names with a fixed meaning
+const (
+	DefaultProfile = "default"
+	NoProfile      = "none"
+)
+
+// ErrNotFound is returned for unknown profiles
+var ErrNotFound = errors.New("noise profile not found")
+
+// ErrBuiltIn is returned when changing a built-in profile
+var ErrBuiltIn = errors.New("built-in noise profiles can't be changed")
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+var severities = map[string]bool{"info": true, "low": true, "medium": true, "high": true, "critical": true, "unknown": true}
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS nuclei_noise_profiles (
+    name VARCHAR(63) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    severities TEXT[] NOT NULL DEFAULT '{info}',
+    suppress_templates TEXT[] NOT NULL DEFAULT '{}',
+    suppress_tags TEXT[] NOT NULL DEFAULT '{}',
+    collapse_templates TEXT[] NOT NULL DEFAULT '{}',
+    collapse_tags TEXT[] NOT NULL DEFAULT '{}',
+    updated_by VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS suppressed VARCHAR(20);
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS suppressed_by VARCHAR(255);
+CREATE TABLE IF NOT EXISTS asset_technologies (
+    host TEXT NOT NULL,
+    technology VARCHAR(255) NOT NULL,
+    template_id VARCHAR(255) NOT NULL,
+    scan_id UUID NOT NULL,
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (host, technology)
+)`
+
+// Profile is a set of noise filters. Rules only apply to findings of the
+// profile's severities; templates are IDs or globs (*-detect).
+type Profile struct {
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Severities        []string  `json:"severities"`
+	SuppressTemplates []string  `json:"suppress_templates"`
+	SuppressTags      []string  `json:"suppress_tags"`
+	CollapseTemplates []string  `json:"collapse_templates"`
+	CollapseTags      []string  `json:"collapse_tags"`
+	BuiltIn           bool      `json:"built_in,omitempty"`
+	UpdatedBy         string    `json:"updated_by,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at,omitempty"`
+}
+
+// builtIn is the profile scans get unless they choose another
+var builtIn = Profile{
+	Name:              DefaultProfile,
+	Description:       "Collapses technology, WAF and service fingerprints and suppresses per-header findings",
+	Severities:        []string{"info"},
+	SuppressTemplates: []string{"http-missing-security-headers"},
+	SuppressTags:      []string{},
+	CollapseTemplates: []string{"tech-detect", "waf-detect", "*-detect", "*-fingerprint"},
+	CollapseTags:      []string{"tech", "detect", "fingerprint"},
+	BuiltIn:           true,
+}
+
+// Validate normalizes p and checks its name, severities and rules
+func Validate(p *Profile) error {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if !namePattern.MatchString(p.Name) {
+		return errors.New("name must be lowercase letters, digits, '-' or '_', up to 63 characters")
+	}
+	if p.Name == DefaultProfile || p.Name == NoProfile {
+		return ErrBuiltIn
+	}
+	if len(p.Severities) == 0 {
+		p.Severities = []string{"info"}
+	}
+	for i, severity := range p.Severities {
+		p.Severities[i] = strings.ToLower(strings.TrimSpace(severity))
+		if !severities[p.Severities[i]] {
+			return fmt.Errorf("unknown severity %q", severity)
+		}
+	}
+	lists := []*[]string{&p.SuppressTemplates, &p.SuppressTags, &p.CollapseTemplates, &p.CollapseTags}
+	for _, list := range lists {
+		cleaned := []string{}
+		for _, rule := range *list {
+			rule = strings.ToLower(strings.TrimSpace(rule))
+			if rule == "" {
+				continue
+			}
+			if _, err := path.Match(rule, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q", rule)
+			}
+			cleaned = append(cleaned, rule)
+		}
+		*list = cleaned
+	}
+	if len(p.SuppressTemplates)+len(p.SuppressTags)+len(p.CollapseTemplates)+len(p.CollapseTags) == 0 {
+		return errors.New("a profile needs at least one rule")
+	}
+	return nil
+}
+
+// Classify returns what p does with a finding: "" keeps it, Suppressed or
+// Collapsed filter it, and rule is the rule that matched (template:<id> or
+// tag:<tag>). A nil profile keeps everything.
+func (p *Profile) Classify(templateID, severity string, tags []string) (disposition, rule string) {
+	if p == nil || !contains(p.Severities, strings.ToLower(severity)) {
+		return "", ""
+	}
+	templateID = strings.ToLower(templateID)
+	if rule := matchRule(p.SuppressTemplates, p.SuppressTags, templateID, tags); rule != "" {
+		return Suppressed, rule
+	}
+	if rule := matchRule(p.CollapseTemplates, p.CollapseTags, templateID, tags); rule != "" {
+		return Collapsed, rule
+	}
+	return "", ""
+}
+
+func matchRule(templates, tagRules []string, templateID string, tags []string) string {
+	for _, pattern := range templates {
+		if ok, _ := path.Match(pattern, templateID); ok {
+			return "template:" + pattern
+		}
+	}
+	for _, tag := range tags {
+		if contains(tagRules, strings.ToLower(tag)) {
+			return "tag:" + strings.ToLower(tag)
+		}
+	}
+	return ""
+}
+
+// Technology names what a fingerprint finding detected: the matcher that
+// fired (nuclei reports "nginx" for tech-detect), else what it extracted,
+// else the template's name
+func Technology(templateName, matcherName string, extracted []string) string {
+	name := strings.TrimSpace(matcherName)
+	if name == "" && len(extracted) > 0 {
+		name = strings.TrimSpace(strings.Join(extracted, ", "))
+	}
+	if name == "" {
+		name = strings.TrimSpace(templateName)
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AssetTechnology is a technology a collapsed fingerprint finding detected
+// on a host
+type AssetTechnology struct {
+	Host       string    `json:"host"`
+	Technology string    `json:"technology"`
+	TemplateID string    `json:"template_id"`
+	ScanID     uuid.UUID `json:"scan_id"` // the latest scan that detected it
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Store keeps the custom profiles and the technologies of each asset
+type Store struct {
+	db *database.Database
+	// fallback is the profile of scans that don't choose one
+	fallback string
+}
+
+// NewStore creates the profile and technology tables and the columns that
+// mark filtered findings. fallback is the profile of scans that don't
+// choose one ("none" disables filtering).
+func NewStore(db *database.Database, fallback string) (*Store, error) {
+	if _, err := db.Pool.Exec(context.Background(), schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to create noise profile tables: %w", err)
+	}
+	s := &Store{db: db, fallback: strings.ToLower(strings.TrimSpace(fallback))}
+	if s.fallback == "" {
+		s.fallback = NoProfile
+	}
+	return s, nil
+}
+
+// Fallback returns the profile of scans that don't choose one
+func (s *Store) Fallback() string {
+	return s.fallback
+}
+
+// List returns the built-in profile and the custom ones, by name
+func (s *Store) List(ctx context.Context) ([]Profile, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT name, description, severities, suppress_templates, suppress_tags, collapse_templates, collapse_tags, updated_by, updated_at
+		FROM nuclei_noise_profiles ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []Profile{builtIn}
+	for rows.Next() {
+		p, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+func scanProfile(row pgx.Row) (*Profile, error) {
+	var p Profile
+	err := row.Scan(&p.Name, &p.Description, &p.Severities, &p.SuppressTemplates, &p.SuppressTags,
+		&p.CollapseTemplates, &p.CollapseTags, &p.UpdatedBy, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Get returns a profile by name, the fallback for "". "none" returns nil:
+// nothing is filtered.
+func (s *Store) Get(ctx context.Context, name string) (*Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = s.fallback
+	}
+	switch name {
+	case NoProfile:
+		return nil, nil
+	case DefaultProfile:
+		p := builtIn
+		return &p, nil
+	}
+	p, err := scanProfile(s.db.Pool.QueryRow(ctx, `
+		SELECT name, description, severities, suppress_templates, suppress_tags, collapse_templates, collapse_tags, updated_by, updated_at
+		FROM nuclei_noise_profiles WHERE name = $1
+	`, name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+// Save creates or replaces a custom profile, which must pass Validate
+func (s *Store) Save(ctx context.Context, p *Profile, by string) error {
+	p.UpdatedBy, p.UpdatedAt = by, time.Now()
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO nuclei_noise_profiles (name, description, severities, suppress_templates, suppress_tags, collapse_templates, collapse_tags, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description, severities = EXCLUDED.severities,
+			suppress_templates = EXCLUDED.suppress_templates, suppress_tags = EXCLUDED.suppress_tags,
+			collapse_templates = EXCLUDED.collapse_templates, collapse_tags = EXCLUDED.collapse_tags,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, p.Name, p.Description, p.Severities, p.SuppressTemplates, p.SuppressTags, p.CollapseTemplates, p.CollapseTags, p.UpdatedBy, p.UpdatedAt)
+	return err
+}
+
+// Delete removes a custom profile
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if name == DefaultProfile || name == NoProfile {
+		return ErrBuiltIn
+	}
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM nuclei_noise_profiles WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ForScan returns the profile of a vulnerability scan: the "noise_profile"
+// key of its configuration, else the fallback. nil means nothing is
+// filtered, also when the store isn't set.
+func (s *Store) ForScan(ctx context.Context, scanID uuid.UUID) (*Profile, error) {
+	if s == nil {
+		return nil, nil
+	}
+	var name string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(configuration->>'noise_profile', '') FROM vulnerability_scans WHERE id = $1
+	`, scanID).Scan(&name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, name)
+}
+
+// Apply marks vuln as suppressed or collapsed by p before it's saved, and
+// adds what a collapsed fingerprint detected to the technologies of its
+// host. It returns the disposition, "" for findings kept as they are.
+func (s *Store) Apply(ctx context.Context, p *Profile, vuln *models.Vulnerability, matcherName string) (string, error) {
+	disposition, rule := p.Classify(vuln.TemplateID, vuln.Severity, vuln.Metadata.Tags)
+	if disposition == "" {
+		return "", nil
+	}
+	vuln.Suppressed, vuln.SuppressedBy = disposition, p.Name+"/"+rule
+	if disposition != Collapsed {
+		return disposition, nil
+	}
+	technology := Technology(vuln.TemplateName, matcherName, vuln.ExtractedResults)
+	return disposition, s.RecordTechnology(ctx, AssetHost(vuln.Host), technology, vuln.TemplateID, vuln.ScanID)
+}
+
+// AssetHost returns the hostname of a nuclei host, which can be a URL or
+// host:port
+func AssetHost(host string) string {
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// RecordTechnology adds a technology to a host's list, or marks it seen
+// again
+func (s *Store) RecordTechnology(ctx context.Context, host, technology, templateID string, scanID uuid.UUID) error {
+	if host == "" || technology == "" {
+		return nil
+	}
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO asset_technologies (host, technology, template_id, scan_id) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (host, technology) DO UPDATE SET
+			template_id = EXCLUDED.template_id, scan_id = EXCLUDED.scan_id, last_seen = CURRENT_TIMESTAMP
+	`, host, technology, templateID, scanID)
+	return err
+}
+
+// Technologies returns the technologies detected on hosts (every host when
+// none are given), by host and name
+func (s *Store) Technologies(ctx context.Context, hosts []string) ([]AssetTechnology, error) {
+	for i := range hosts {
+		hosts[i] = AssetHost(hosts[i])
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT host, technology, template_id, scan_id, first_seen, last_seen FROM asset_technologies
+		WHERE cardinality($1::text[]) = 0 OR host = ANY($1)
+		ORDER BY host, technology
+	`, hosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	techs := []AssetTechnology{}
+	for rows.Next() {
+		var t AssetTechnology
+		if err := rows.Scan(&t.Host, &t.Technology, &t.TemplateID, &t.ScanID, &t.FirstSeen, &t.LastSeen); err != nil {
+			return nil, err
+		}
+		techs = append(techs, t)
+	}
+	return techs, rows.Err()
+}
+
+// ByHost groups technologies by host, with sorted names
+func ByHost(techs []AssetTechnology) map[string][]string {
+	byHost := map[string][]string{}
+	for _, t := range techs {
+		byHost[t.Host] = append(byHost[t.Host], t.Technology)
+	}
+	for host := range byHost {
+		sort.Strings(byHost[host])
+	}
+	return byHost
+}
@@ -0,0 +1,314 @@
+// Package sarif converts nuclei findings into SARIF 2.1.0 logs, the format
+// GitHub code scanning and other static analysis dashboards import
+package sarif
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	shared "github.com/security-scanner/shared/pkg/models"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	nucleiURI = "https://github.com/projectdiscovery/nuclei"
+)
+
+var categoryUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Log is a SARIF log, with one run per scan
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one tool invocation
+type Run struct {
+	Tool              Tool                   `json:"tool"`
+	AutomationDetails *AutomationDetails     `json:"automationDetails,omitempty"`
+	Invocations       []Invocation           `json:"invocations,omitempty"`
+	Results           []Result               `json:"results"`
+	Properties        map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// AutomationDetails identifies the run; code scanning uses the part before
+// the last slash as the analysis category
+type AutomationDetails struct {
+	ID string `json:"id"`
+}
+
+type Invocation struct {
+	ExecutionSuccessful bool       `json:"executionSuccessful"`
+	StartTimeUTC        *time.Time `json:"startTimeUtc,omitempty"`
+	EndTimeUTC          *time.Time `json:"endTimeUtc,omitempty"`
+}
+
+// Rule describes a nuclei template
+type Rule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     *Message               `json:"shortDescription,omitempty"`
+	FullDescription      *Message               `json:"fullDescription,omitempty"`
+	Help                 *Message               `json:"help,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	DefaultConfiguration Configuration          `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+type Message struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// Result is one finding
+type Result struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Level maps a finding severity to a SARIF level: critical and high are
+// errors, medium warnings and the rest notes
+func Level(severity string) string {
+	switch shared.NormalizeSeverity(severity) {
+	case shared.SeverityCritical, shared.SeverityHigh:
+		return "error"
+	case shared.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the score code scanning ranks security alerts by: the
+// template's CVSS score when it has one, otherwise a score within the
+// severity's CVSS range. Informational findings have none.
+func securitySeverity(severity, cvss string) string {
+	if score, err := strconv.ParseFloat(strings.TrimSpace(cvss), 64); err == nil && score > 0 && score <= 10 {
+		return strconv.FormatFloat(score, 'f', 1, 64)
+	}
+	switch shared.NormalizeSeverity(severity) {
+	case shared.SeverityCritical:
+		return "9.5"
+	case shared.SeverityHigh:
+		return "8.0"
+	case shared.SeverityMedium:
+		return "5.5"
+	case shared.SeverityLow:
+		return "2.0"
+	default:
+		return ""
+	}
+}
+
+// FromNuclei converts the findings of a nuclei scan into a SARIF log. Each
+// template becomes a rule and each finding a result located at the URL it
+// matched; fingerprints, keyed by finding ID, let consumers track a finding
+// across scans.
+func FromNuclei(scan models.VulnerabilityScan, vulns []models.Vulnerability, fingerprints map[uuid.UUID]string) Log {
+	run := Run{
+		Tool: Tool{Driver: Driver{Name: "nuclei", InformationURI: nucleiURI, Rules: []Rule{}}},
+		// Scans of the same name share a category, so uploading a new one
+		// replaces the alerts of the previous one
+		AutomationDetails: &AutomationDetails{ID: fmt.Sprintf("nuclei/%s/%s", category(scan.Name), scan.ID)},
+		Invocations: []Invocation{{
+			ExecutionSuccessful: scan.Status == "completed",
+			StartTimeUTC:        utc(scan.StartedAt),
+			EndTimeUTC:          utc(scan.CompletedAt),
+		}},
+		Results: []Result{},
+		Properties: map[string]interface{}{
+			"scan_id":   scan.ID,
+			"scan_name": scan.Name,
+			"target":    scan.Target,
+		},
+	}
+	if simulated, _ := scan.Configuration["simulated"].(bool); simulated {
+		run.Properties["simulated"] = true
+	}
+
+	// Oldest findings first, so results read in the order nuclei found them
+	ordered := make([]models.Vulnerability, len(vulns))
+	copy(ordered, vulns)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].CreatedAt.Before(ordered[j].CreatedAt) })
+
+	ruleIndex := map[string]int{}
+	for _, vuln := range ordered {
+		ruleID := vuln.TemplateID
+		if ruleID == "" {
+			ruleID = vuln.TemplateName
+		}
+		index, ok := ruleIndex[ruleID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[ruleID] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule(ruleID, vuln))
+		}
+
+		result := Result{
+			RuleID:    ruleID,
+			RuleIndex: index,
+			Level:     Level(vuln.Severity),
+			Message:   Message{Text: message(vuln)},
+			Locations: []Location{{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: location(vuln)}}}},
+			Properties: map[string]interface{}{
+				"finding_id": vuln.ID,
+				"severity":   shared.NormalizeSeverity(vuln.Severity),
+				"type":       vuln.Type,
+				"host":       vuln.Host,
+				"status":     vuln.Status,
+			},
+		}
+		if fingerprint := fingerprints[vuln.ID]; fingerprint != "" {
+			result.PartialFingerprints = map[string]string{"findingFingerprint/v1": fingerprint}
+		}
+		if len(vuln.ExtractedResults) > 0 {
+			result.Properties["extracted_results"] = vuln.ExtractedResults
+		}
+		if vuln.CURLCommand != "" {
+			result.Properties["curl_command"] = vuln.CURLCommand
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return Log{Schema: Schema, Version: Version, Runs: []Run{run}}
+}
+
+// rule describes the template of vuln
+func rule(id string, vuln models.Vulnerability) Rule {
+	meta := vuln.Metadata
+	name := vuln.TemplateName
+	if name == "" {
+		name = id
+	}
+	r := Rule{
+		ID:                   id,
+		Name:                 name,
+		ShortDescription:     &Message{Text: name},
+		DefaultConfiguration: Configuration{Level: Level(vuln.Severity)},
+		Properties:           map[string]interface{}{},
+	}
+	if meta.Description != "" {
+		r.FullDescription = &Message{Text: meta.Description}
+	}
+	if len(meta.Reference) > 0 {
+		r.HelpURI = meta.Reference[0]
+	}
+
+	help := []string{}
+	markdown := []string{"**" + name + "**"}
+	if meta.Description != "" {
+		help = append(help, meta.Description)
+		markdown = append(markdown, meta.Description)
+	}
+	if len(meta.CVE) > 0 {
+		help = append(help, "CVE: "+strings.Join(meta.CVE, ", "))
+		markdown = append(markdown, "CVE: "+strings.Join(meta.CVE, ", "))
+	}
+	if len(meta.CWE) > 0 {
+		help = append(help, "CWE: "+strings.Join(meta.CWE, ", "))
+		markdown = append(markdown, "CWE: "+strings.Join(meta.CWE, ", "))
+	}
+	if len(meta.Reference) > 0 {
+		help = append(help, "References:\n"+strings.Join(meta.Reference, "\n"))
+		links := make([]string, len(meta.Reference))
+		for i, ref := range meta.Reference {
+			links[i] = "- " + ref
+		}
+		markdown = append(markdown, "References:\n"+strings.Join(links, "\n"))
+	}
+	if len(help) == 0 {
+		help = append(help, name)
+	}
+	r.Help = &Message{Text: strings.Join(help, "\n\n"), Markdown: strings.Join(markdown, "\n\n")}
+
+	// Code scanning lists alerts with tags as security alerts, ranked by
+	// security-severity
+	tags := append([]string{"security"}, meta.Tags...)
+	tags = append(tags, meta.CVE...)
+	tags = append(tags, meta.CWE...)
+	r.Properties["tags"] = tags
+	if score := securitySeverity(vuln.Severity, meta.Classification); score != "" {
+		r.Properties["security-severity"] = score
+	}
+	if len(meta.Author) > 0 {
+		r.Properties["authors"] = meta.Author
+	}
+	return r
+}
+
+// message describes a finding in one line, e.g. "Apache Solr RCE found at
+// https://example.com/solr/admin"
+func message(vuln models.Vulnerability) string {
+	name := vuln.TemplateName
+	if name == "" {
+		name = vuln.TemplateID
+	}
+	return fmt.Sprintf("%s found at %s", name, location(vuln))
+}
+
+// location is where a finding was matched, its host when nuclei didn't
+// report the URL
+func location(vuln models.Vulnerability) string {
+	if vuln.MatchedAt != "" {
+		return vuln.MatchedAt
+	}
+	return vuln.Host
+}
+
+// category turns a scan name into a code scanning category, e.g. "Weekly
+// scan: shop" into "weekly-scan-shop"
+func category(name string) string {
+	c := strings.Trim(categoryUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if c == "" {
+		return "scan"
+	}
+	return c
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+
+	"github.com/security-scanner/shared/pkg/supervise"
+)
+
+// stderrTailLines is how much of a tool's stderr is kept to classify its
+// failures
+const stderrTailLines = 20
+
+// attemptLog returns the scan log entry of a supervised run of tool: info
+// when it succeeded, warning when it failed
+func attemptLog(tool string, a supervise.Attempt) (string, string) {
+	if a.Err == nil {
+		return "info", tool + " " + a.String()
+	}
+	return "warning", tool + " " + a.String()
+}
+
+// startError is a tool that couldn't be started, which fails the scan;
+// tools that ran and exited with an error still have their output parsed
+type startError struct {
+	err error
+}
+
+func (e *startError) Error() string { return e.err.Error() }
+func (e *startError) Unwrap() error { return e.err }
+
+// stderrTail keeps the last lines of a tool's stderr
+type stderrTail struct {
+	lines []string
+}
+
+func (t *stderrTail) add(line string) {
+	t.lines = append(t.lines, line)
+	if len(t.lines) > stderrTailLines {
+		t.lines = t.lines[1:]
+	}
+}
+
+// attach sets the tail as the stderr of an exit status error, which the
+// supervisor reads to tell transient failures apart
+func (t *stderrTail) attach(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = []byte(strings.Join(t.lines, "\n"))
+	}
+	return err
+}
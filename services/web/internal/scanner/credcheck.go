@@ -0,0 +1,593 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/web-service/internal/database"
+)
+
+// webScanToolSchemaSQL allows the scans of the tools added after the initial
+// schema (credcheck, sqlmap) in web_scans
+const webScanToolSchemaSQL = `
+ALTER TABLE web_scans DROP CONSTRAINT IF EXISTS valid_web_scan_tool;
+ALTER TABLE web_scans ADD CONSTRAINT valid_web_scan_tool CHECK (tool IN ('ffuf', 'gowitness', 'testssl', 'credcheck', 'sqlmap'))`
+
+// errLockout stops the checks of a target: the service started refusing or
+// throttling logins and more attempts could lock real accounts
+var errLockout = errors.New("lockout or throttling detected")
+
+// lockoutRe matches login pages that mention lockouts, throttling or captchas
+var lockoutRe = regexp.MustCompile(`(?i)(account\s+(is\s+)?(locked|disabled)|too\s+many\s+(failed\s+)?(login\s+)?(attempts|requests)|temporarily\s+(locked|blocked)|captcha)`)
+
+// defaultCredential is a vendor default login. For SNMP the password is the community.
+type defaultCredential struct {
+	Username string
+	Password string
+}
+
+// defaultCredentials is the curated list tried per service, most common first.
+// It is kept short on purpose: MaxAttempts cuts it further to stay below
+// typical lockout thresholds.
+var defaultCredentials = map[string][]defaultCredential{
+	"http": {
+		{"admin", "admin"}, {"admin", "password"}, {"admin", ""}, {"root", "root"}, {"tomcat", "tomcat"},
+		{"admin", "1234"}, {"admin", "changeme"}, {"cisco", "cisco"}, {"ubnt", "ubnt"}, {"tomcat", "s3cret"},
+	},
+	"ssh": {
+		{"root", "root"}, {"root", "toor"}, {"admin", "admin"}, {"pi", "raspberry"}, {"ubnt", "ubnt"},
+		{"vagrant", "vagrant"}, {"cisco", "cisco"}, {"root", "changeme"},
+	},
+	"snmp": {
+		{"", "public"}, {"", "private"}, {"", "community"}, {"", "cisco"}, {"", "manager"},
+	},
+	"postgres": {
+		{"postgres", "postgres"}, {"postgres", ""}, {"postgres", "password"}, {"admin", "admin"},
+	},
+	"mysql": {
+		{"root", ""}, {"root", "root"}, {"root", "mysql"}, {"root", "password"}, {"admin", "admin"},
+	},
+	"redis": {
+		{"", ""}, {"", "foobared"}, {"", "redis"}, {"", "password"},
+	},
+}
+
+// credDefaultPorts is used when a target URL has no port
+var credDefaultPorts = map[string]string{
+	"http": "80", "https": "443", "ssh": "22", "snmp": "161", "postgres": "5432", "mysql": "3306", "redis": "6379",
+}
+
+// CredCheckScanner tries vendor default credentials against admin interfaces
+// (web logins, SSH, SNMP and databases)
+type CredCheckScanner struct {
+	db *database.Database
+}
+
+// CredCheckConfig holds configuration for a default credential check
+type CredCheckConfig struct {
+	Targets       []string `json:"targets"`         // http(s)://, ssh://, snmp://, postgres://, mysql:// or redis:// URLs
+	NetworkScanID string   `json:"network_scan_id"` // take targets from the open ports of a network scan
+	Services      []string `json:"services"`        // limit the services checked
+	MaxAttempts   int      `json:"max_attempts"`    // per target
+	Delay         int      `json:"delay"`           // seconds between attempts on a target
+	Timeout       int      `json:"timeout"`         // seconds per attempt
+	Concurrency   int      `json:"concurrency"`     // targets checked at once
+}
+
+// credTarget is a service to check; url never carries credentials
+type credTarget struct {
+	service string
+	url     *url.URL
+}
+
+// NewCredCheckScanner creates a default credential scanner and allows its
+// scans in web_scans
+func NewCredCheckScanner(db *database.Database) (*CredCheckScanner, error) {
+	if _, err := db.Pool.Exec(context.Background(), webScanToolSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to allow credcheck scans: %w", err)
+	}
+	return &CredCheckScanner{db: db}, nil
+}
+
+// ExecuteScan runs a default credential check
+func (s *CredCheckScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config CredCheckConfig) error {
+	s.updateScanStatus(scanID, "running", 0)
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.Delay <= 0 {
+		config.Delay = 2
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 5
+	}
+
+	targets, err := s.buildTargets(ctx, config)
+	if err != nil {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", err.Error())
+		return err
+	}
+	if len(targets) == 0 {
+		s.addLog(scanID, "warning", "No admin interfaces to check")
+		s.updateScanStatus(scanID, "completed", 100)
+		return nil
+	}
+	s.addLog(scanID, "info", fmt.Sprintf("Checking default credentials on %d targets (max %d attempts each, %ds apart)",
+		len(targets), config.MaxAttempts, config.Delay))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, config.Concurrency)
+	done, found := 0, 0
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target credTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			n := s.checkTarget(ctx, scanID, target, config)
+
+			mu.Lock()
+			done++
+			found += n
+			s.updateScanStatus(scanID, "running", done*100/len(targets))
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	s.addLog(scanID, "info", fmt.Sprintf("Scan completed. Default credentials accepted on %d of %d targets", found, len(targets)))
+	s.updateScanStatus(scanID, "completed", 100)
+	return nil
+}
+
+// buildTargets parses the configured target URLs and adds the open ports of
+// the referenced network scan
+func (s *CredCheckScanner) buildTargets(ctx context.Context, config CredCheckConfig) ([]credTarget, error) {
+	wanted := map[string]bool{}
+	for _, service := range config.Services {
+		wanted[strings.ToLower(service)] = true
+	}
+	seen := map[string]bool{}
+	var targets []credTarget
+	add := func(service string, u *url.URL) {
+		if len(wanted) > 0 && !wanted[service] {
+			return
+		}
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), credDefaultPorts[u.Scheme])
+		}
+		u.User = nil
+		if seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		targets = append(targets, credTarget{service: service, url: u})
+	}
+
+	for _, raw := range config.Targets {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid target %q", raw)
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		service := u.Scheme
+		switch u.Scheme {
+		case "https":
+			service = "http"
+		case "postgresql":
+			u.Scheme, service = "postgres", "postgres"
+		}
+		if _, ok := defaultCredentials[service]; !ok {
+			return nil, fmt.Errorf("unsupported target %q: scheme must be http, https, ssh, snmp, postgres, mysql or redis", raw)
+		}
+		add(service, u)
+	}
+
+	if config.NetworkScanID != "" {
+		rows, err := s.db.Pool.Query(ctx, `SELECT host, ports FROM scan_results WHERE scan_id = $1`, config.NetworkScanID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read network scan results: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var host string
+			var portsJSON []byte
+			if err := rows.Scan(&host, &portsJSON); err != nil {
+				continue
+			}
+			var ports []struct {
+				Port     int    `json:"port"`
+				Protocol string `json:"protocol"`
+				State    string `json:"state"`
+				Service  string `json:"service"`
+			}
+			json.Unmarshal(portsJSON, &ports)
+			for _, p := range ports {
+				if p.State != "open" {
+					continue
+				}
+				if scheme, service := credServiceFor(p.Port, p.Protocol, p.Service); scheme != "" {
+					add(service, &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, strconv.Itoa(p.Port)), Path: "/"})
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// credServiceFor maps an nmap port to the scheme and service to check
+func credServiceFor(port int, protocol, service string) (string, string) {
+	service = strings.ToLower(service)
+	switch {
+	case protocol == "udp":
+		if service == "snmp" || port == 161 {
+			return "snmp", "snmp"
+		}
+	case service == "ssh" || port == 22:
+		return "ssh", "ssh"
+	case service == "postgresql" || port == 5432:
+		return "postgres", "postgres"
+	case service == "mysql" || port == 3306:
+		return "mysql", "mysql"
+	case service == "redis" || port == 6379:
+		return "redis", "redis"
+	case service == "https" || strings.HasPrefix(service, "ssl/http") || port == 443 || port == 8443:
+		return "https", "http"
+	case strings.HasPrefix(service, "http") || port == 80 || port == 8080 || port == 8000 || port == 8888:
+		return "http", "http"
+	}
+	return "", ""
+}
+
+// checkTarget tries the default credentials of a target one at a time and
+// returns 1 if a login worked. It stops at the first success, on lockout
+// signals and after two connection errors in a row (the host may have banned us).
+func (s *CredCheckScanner) checkTarget(ctx context.Context, scanID uuid.UUID, target credTarget, config CredCheckConfig) int {
+	timeout := time.Duration(config.Timeout) * time.Second
+	attempt := s.attemptFunc(ctx, scanID, target, timeout)
+	if attempt == nil {
+		return 0
+	}
+
+	creds := defaultCredentials[target.service]
+	if len(creds) > config.MaxAttempts {
+		creds = creds[:config.MaxAttempts]
+	}
+	failures := 0
+	for i, cred := range creds {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return 0
+			case <-time.After(time.Duration(config.Delay) * time.Second):
+			}
+		}
+
+		ok, err := attempt(ctx, cred)
+		switch {
+		case errors.Is(err, errLockout):
+			s.addLog(scanID, "warning", fmt.Sprintf("%s: stopping, %v", target.url, err))
+			return 0
+		case err != nil:
+			failures++
+			if failures >= 2 {
+				s.addLog(scanID, "warning", fmt.Sprintf("%s: stopping after repeated errors: %v", target.url, err))
+				return 0
+			}
+			continue
+		case ok:
+			s.saveFinding(scanID, target, cred)
+			return 1
+		}
+		failures = 0
+	}
+	return 0
+}
+
+// attemptFunc returns the login function of a target's service, or nil when
+// the target can't be checked
+func (s *CredCheckScanner) attemptFunc(ctx context.Context, scanID uuid.UUID, target credTarget, timeout time.Duration) func(context.Context, defaultCredential) (bool, error) {
+	address := target.url.Host
+	switch target.service {
+	case "ssh":
+		return func(ctx context.Context, cred defaultCredential) (bool, error) {
+			return trySSH(ctx, address, cred, timeout)
+		}
+	case "snmp":
+		return func(ctx context.Context, cred defaultCredential) (bool, error) {
+			return trySNMP(ctx, address, cred, timeout)
+		}
+	case "postgres":
+		return func(ctx context.Context, cred defaultCredential) (bool, error) {
+			return tryPostgres(ctx, target.url, cred, timeout)
+		}
+	case "mysql":
+		return func(ctx context.Context, cred defaultCredential) (bool, error) {
+			return tryMySQL(ctx, address, cred, timeout)
+		}
+	case "redis":
+		return func(ctx context.Context, cred defaultCredential) (bool, error) {
+			return tryRedis(ctx, address, cred, timeout)
+		}
+	case "http":
+		login, err := detectHTTPLogin(ctx, target.url, timeout)
+		if err != nil {
+			s.addLog(scanID, "info", fmt.Sprintf("%s: skipped, %v", target.url, err))
+			return nil
+		}
+		return login.try
+	}
+	return nil
+}
+
+// httpLogin is a login found on a web page: HTTP basic auth or a form
+type httpLogin struct {
+	url     *url.URL
+	basic   bool
+	timeout time.Duration
+}
+
+var (
+	formRe  = regexp.MustCompile(`(?is)<form\b([^>]*)>(.*?)</form>`)
+	inputRe = regexp.MustCompile(`(?is)<input\b([^>]*)>`)
+	attrRe  = regexp.MustCompile(`(?is)\b([a-z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+func newCredHTTPClient(timeout time.Duration) *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{
+		Timeout: timeout,
+		Jar:     jar,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+func httpGet(ctx context.Context, client *http.Client, u string) (*http.Response, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp, string(body), nil
+}
+
+// detectHTTPLogin looks for basic auth or a password form on the page. A form
+// is only used when a bogus login is recognised as failed, so that pages
+// without a clear failure response don't produce false positives.
+func detectHTTPLogin(ctx context.Context, u *url.URL, timeout time.Duration) (*httpLogin, error) {
+	resp, body, err := httpGet(ctx, newCredHTTPClient(timeout), u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && strings.HasPrefix(strings.ToLower(resp.Header.Get("WWW-Authenticate")), "basic") {
+		return &httpLogin{url: u, basic: true, timeout: timeout}, nil
+	}
+	if lockoutRe.MatchString(body) {
+		return nil, errLockout
+	}
+	if findLoginForm(body) == nil {
+		return nil, errors.New("no login form or basic auth found")
+	}
+
+	login := &httpLogin{url: resp.Request.URL, timeout: timeout}
+	ok, err := login.try(ctx, defaultCredential{Username: "scanner-" + uuid.NewString()[:8], Password: uuid.NewString()})
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, errors.New("login form gives no recognisable failure response")
+	}
+	return login, nil
+}
+
+// loginForm is a form with a password field
+type loginForm struct {
+	action   string
+	method   string
+	fields   url.Values
+	userName string
+	passName string
+}
+
+func htmlAttrs(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrRe.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3]
+	}
+	return attrs
+}
+
+func findLoginForm(body string) *loginForm {
+	for _, form := range formRe.FindAllStringSubmatch(body, -1) {
+		attrs := htmlAttrs(form[1])
+		f := &loginForm{action: attrs["action"], method: strings.ToUpper(attrs["method"]), fields: url.Values{}}
+		for _, input := range inputRe.FindAllStringSubmatch(form[2], -1) {
+			in := htmlAttrs(input[1])
+			name := in["name"]
+			if name == "" {
+				continue
+			}
+			switch strings.ToLower(in["type"]) {
+			case "password":
+				if f.passName == "" {
+					f.passName = name
+				}
+			case "", "text", "email":
+				if f.userName == "" {
+					f.userName = name
+				} else {
+					f.fields.Set(name, in["value"])
+				}
+			case "checkbox", "radio", "button", "image", "file", "reset":
+			default:
+				f.fields.Set(name, in["value"])
+			}
+		}
+		if f.passName != "" {
+			return f
+		}
+	}
+	return nil
+}
+
+// try logs in with basic auth, or submits the form with a fresh session
+func (l *httpLogin) try(ctx context.Context, cred defaultCredential) (bool, error) {
+	client := newCredHTTPClient(l.timeout)
+	if l.basic {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url.String(), nil)
+		if err != nil {
+			return false, err
+		}
+		req.SetBasicAuth(cred.Username, cred.Password)
+		resp, err := client.Do(req)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusLocked {
+			return false, errLockout
+		}
+		return resp.StatusCode < 400, nil
+	}
+
+	// Fetch the page again for fresh CSRF tokens and session cookies
+	resp, body, err := httpGet(ctx, client, l.url.String())
+	if err != nil {
+		return false, err
+	}
+	form := findLoginForm(body)
+	if form == nil {
+		return false, errors.New("login form disappeared")
+	}
+	action, err := resp.Request.URL.Parse(form.action)
+	if err != nil {
+		return false, err
+	}
+	values := form.fields
+	if form.userName != "" {
+		values.Set(form.userName, cred.Username)
+	}
+	values.Set(form.passName, cred.Password)
+
+	var req *http.Request
+	if form.method == http.MethodGet {
+		action.RawQuery = values.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, action.String(), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, action.String(), strings.NewReader(values.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	result, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusLocked || lockoutRe.Match(result) {
+		return false, errLockout
+	}
+	// Logged in when the page after submitting has no login form left
+	return resp.StatusCode < 400 && findLoginForm(string(result)) == nil, nil
+}
+
+func (s *CredCheckScanner) saveFinding(scanID uuid.UUID, target credTarget, cred defaultCredential) {
+	account := cred.Username
+	if target.service == "snmp" {
+		account = "community " + cred.Password
+	} else if account == "" {
+		account = "password " + strconv.Quote(cred.Password)
+	} else {
+		account += " / " + strconv.Quote(cred.Password)
+	}
+	if target.service == "redis" && cred.Password == "" {
+		account = "no authentication"
+	}
+	text := fmt.Sprintf("Default credentials accepted by %s: %s", target.service, account)
+
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"service":  target.service,
+		"username": cred.Username,
+		"password": cred.Password,
+	})
+
+	query := `
+		INSERT INTO web_scan_results (id, scan_id, tool, url, finding_id, severity,
+			finding_text, cwe, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := s.db.Pool.Exec(context.Background(), query,
+		uuid.New(), scanID, "credcheck", target.url.String(), "default-credentials-"+target.service, "critical",
+		text, "CWE-1392", metadata, time.Now())
+	if err != nil {
+		log.Printf("Failed to save credcheck result: %v", err)
+	}
+	s.addLog(scanID, "warning", fmt.Sprintf("%s: %s", target.url, text))
+}
+
+func (s *CredCheckScanner) updateScanStatus(scanID uuid.UUID, status string, progress int) {
+	query := `UPDATE web_scans SET status = $1, progress = $2`
+	args := []interface{}{status, progress}
+	argIndex := 3
+
+	if status == "running" && progress == 0 {
+		query += fmt.Sprintf(", started_at = $%d", argIndex)
+		args = append(args, time.Now())
+		argIndex++
+	}
+
+	if status == "completed" || status == "failed" {
+		query += fmt.Sprintf(", completed_at = $%d", argIndex)
+		args = append(args, time.Now())
+		argIndex++
+	}
+
+	// Cancelled scans stay cancelled
+	query += fmt.Sprintf(" WHERE id = $%d AND status <> 'cancelled'", argIndex)
+	args = append(args, scanID)
+
+	s.db.Pool.Exec(context.Background(), query, args...)
+}
+
+func (s *CredCheckScanner) addLog(scanID uuid.UUID, level, message string) {
+	query := `INSERT INTO web_scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`
+	s.db.Pool.Exec(context.Background(), query, uuid.New(), scanID, level, message, time.Now())
+	log.Printf("[%s] %s: %s", scanID.String()[:8], level, message)
+}
@@ -0,0 +1,294 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/ssh"
+)
+
+// Login attempts of the credential checker. They return (true, nil) when the
+// credentials are accepted, (false, nil) when they are rejected, errLockout
+// when the service throttles or blocks us and any other error when the
+// service could not be reached.
+
+func dialTimeout(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	return conn, nil
+}
+
+func trySSH(ctx context.Context, address string, cred defaultCredential, timeout time.Duration) (bool, error) {
+	conn, err := dialTimeout(ctx, "tcp", address, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	config := &ssh.ClientConfig{
+		User: cred.Username,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(cred.Password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = cred.Password
+				}
+				return answers, nil
+			}),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         timeout,
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		msg := strings.ToLower(err.Error())
+		switch {
+		case strings.Contains(msg, "too many authentication failures"), strings.Contains(msg, "maxstartups"):
+			return false, errLockout
+		case strings.Contains(msg, "unable to authenticate"):
+			return false, nil
+		}
+		return false, err
+	}
+	ssh.NewClient(sshConn, chans, reqs).Close()
+	return true, nil
+}
+
+// snmpSysDescr is a GetRequest of sysDescr.0
+var snmpSysDescr = []byte{0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00}
+
+// trySNMP sends an SNMPv2c get of sysDescr with the community as password.
+// Agents silently drop requests with a wrong community.
+func trySNMP(ctx context.Context, address string, cred defaultCredential, timeout time.Duration) (bool, error) {
+	conn, err := dialTimeout(ctx, "udp", address, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	requestID := make([]byte, 4)
+	rand.Read(requestID)
+	pdu := append([]byte{0x02, 0x04}, requestID...)
+	pdu = append(pdu, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00)
+	pdu = append(pdu, asn1Wrap(0x30, snmpSysDescr)...)
+	msg := []byte{0x02, 0x01, 0x01} // version 2c
+	msg = append(msg, asn1Wrap(0x04, []byte(cred.Password))...)
+	msg = append(msg, asn1Wrap(0xa0, pdu)...)
+	if _, err := conn.Write(asn1Wrap(0x30, msg)); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return false, nil
+		}
+		return false, err
+	}
+	// The response echoes the request id inside a GetResponse PDU
+	return bytes.Contains(buf[:n], []byte{0xa2}) && bytes.Contains(buf[:n], requestID), nil
+}
+
+func asn1Wrap(tag byte, content []byte) []byte {
+	return append([]byte{tag, byte(len(content))}, content...)
+}
+
+func tryPostgres(ctx context.Context, u *url.URL, cred defaultCredential, timeout time.Duration) (bool, error) {
+	config, err := pgx.ParseConfig("")
+	if err != nil {
+		return false, err
+	}
+	port, _ := strconv.Atoi(u.Port())
+	config.Host = u.Hostname()
+	config.Port = uint16(port)
+	config.User = cred.Username
+	config.Password = cred.Password
+	config.Database = "postgres"
+	config.ConnectTimeout = timeout
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "28P01", "28000": // invalid_password, invalid_authorization_specification
+				return false, nil
+			case "53300": // too_many_connections
+				return false, errLockout
+			}
+		}
+		return false, err
+	}
+	conn.Close(ctx)
+	return true, nil
+}
+
+// tryMySQL performs the MySQL handshake with mysql_native_password
+func tryMySQL(ctx context.Context, address string, cred defaultCredential, timeout time.Duration) (bool, error) {
+	conn, err := dialTimeout(ctx, "tcp", address, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	greeting, err := readMySQLPacket(conn)
+	if err != nil {
+		return false, err
+	}
+	if len(greeting) > 0 && greeting[0] == 0xff {
+		return false, mysqlError(greeting)
+	}
+	salt, err := mysqlSalt(greeting)
+	if err != nil {
+		return false, err
+	}
+
+	const capabilities = 0x00000200 | 0x00008000 | 0x00080000 // protocol 41, secure connection, plugin auth
+	resp := make([]byte, 32)
+	binary.LittleEndian.PutUint32(resp, capabilities)
+	binary.LittleEndian.PutUint32(resp[4:], 1<<24)
+	resp[8] = 0x21 // utf8
+	resp = append(resp, cred.Username...)
+	resp = append(resp, 0x00)
+	auth := mysqlNativePassword(cred.Password, salt)
+	resp = append(resp, byte(len(auth)))
+	resp = append(resp, auth...)
+	resp = append(resp, "mysql_native_password"...)
+	resp = append(resp, 0x00)
+
+	header := []byte{byte(len(resp)), byte(len(resp) >> 8), byte(len(resp) >> 16), 0x01}
+	if _, err := conn.Write(append(header, resp...)); err != nil {
+		return false, err
+	}
+
+	reply, err := readMySQLPacket(conn)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case len(reply) == 0:
+		return false, errors.New("empty mysql reply")
+	case reply[0] == 0x00:
+		return true, nil
+	case reply[0] == 0xff:
+		err := mysqlError(reply)
+		if code := binary.LittleEndian.Uint16(reply[1:]); code == 1045 {
+			return false, nil // access denied
+		}
+		return false, err
+	}
+	// Auth switch to another plugin (caching_sha2_password...) means the
+	// password was not accepted as sent
+	return false, nil
+}
+
+func readMySQLPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, size)
+	_, err := io.ReadFull(r, payload)
+	return payload, err
+}
+
+// mysqlSalt extracts the 20 byte scramble of a v10 handshake
+func mysqlSalt(greeting []byte) ([]byte, error) {
+	if len(greeting) < 1 || greeting[0] != 10 {
+		return nil, errors.New("unsupported mysql handshake")
+	}
+	end := bytes.IndexByte(greeting[1:], 0x00)
+	if end < 0 || len(greeting) < 1+end+1+4+8+1+2+1+2+2+1+10+12 {
+		return nil, errors.New("short mysql handshake")
+	}
+	pos := 1 + end + 1 + 4 // server version, connection id
+	salt := append([]byte{}, greeting[pos:pos+8]...)
+	pos += 8 + 1 + 2 + 1 + 2 + 2 + 1 + 10
+	return append(salt, greeting[pos:pos+12]...), nil
+}
+
+// mysqlNativePassword is SHA1(password) XOR SHA1(salt + SHA1(SHA1(password)))
+func mysqlNativePassword(password string, salt []byte) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(salt)
+	h.Write(stage2[:])
+	scramble := h.Sum(nil)
+	for i := range scramble {
+		scramble[i] ^= stage1[i]
+	}
+	return scramble
+}
+
+// mysqlError turns an ERR packet into an error; host blocked and too many
+// connections are lockouts
+func mysqlError(packet []byte) error {
+	if len(packet) < 3 {
+		return errors.New("mysql error")
+	}
+	code := binary.LittleEndian.Uint16(packet[1:])
+	if code == 1129 || code == 1040 {
+		return errLockout
+	}
+	msg := string(packet[3:])
+	if strings.HasPrefix(msg, "#") && len(msg) > 6 {
+		msg = msg[6:] // sql state
+	}
+	return fmt.Errorf("mysql error %d: %s", code, msg)
+}
+
+// tryRedis checks PING without credentials for an empty password, AUTH otherwise
+func tryRedis(ctx context.Context, address string, cred defaultCredential, timeout time.Duration) (bool, error) {
+	conn, err := dialTimeout(ctx, "tcp", address, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	command := "PING\r\n"
+	if cred.Password != "" {
+		command = fmt.Sprintf("AUTH %s\r\n", cred.Password)
+	}
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return false, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case strings.HasPrefix(reply, "+PONG"), strings.HasPrefix(reply, "+OK"):
+		return true, nil
+	case strings.Contains(strings.ToLower(reply), "max number of clients"):
+		return false, errLockout
+	case strings.HasPrefix(reply, "-"):
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected redis reply %q", strings.TrimSpace(reply))
+}
@@ -3,15 +3,18 @@ package scanner
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
 )
 
@@ -19,7 +22,11 @@ import (
 type FfufScanner struct {
 	db            *database.Database
 	ffufPath      string
+	pathMu        sync.RWMutex
 	wordlistsPath string
+	sandbox       *sandbox.Sandbox
+	artifacts     *artifacts.Manager
+	retry         supervise.Policy
 }
 
 // FfufResult represents a single ffuf finding
@@ -70,14 +77,34 @@ type FfufScanConfig struct {
 }
 
 // NewFfufScanner creates a new ffuf scanner
-func NewFfufScanner(db *database.Database, ffufPath, wordlistsPath string) *FfufScanner {
+func NewFfufScanner(db *database.Database, ffufPath, wordlistsPath string, sb *sandbox.Sandbox, am *artifacts.Manager) *FfufScanner {
 	return &FfufScanner{
 		db:            db,
 		ffufPath:      ffufPath,
 		wordlistsPath: wordlistsPath,
+		sandbox:       sb,
+		artifacts:     am,
 	}
 }
 
+// SetFfufPath changes the ffuf binary used by scans started afterwards
+func (s *FfufScanner) SetFfufPath(path string) {
+	s.pathMu.Lock()
+	s.ffufPath = path
+	s.pathMu.Unlock()
+}
+
+// SetRetryPolicy retries ffuf runs that failed transiently
+func (s *FfufScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+func (s *FfufScanner) currentFfufPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.ffufPath
+}
+
 // GetAvailableWordlists returns list of available wordlists
 func (s *FfufScanner) GetAvailableWordlists() []map[string]string {
 	return []map[string]string{
@@ -106,9 +133,15 @@ func (s *FfufScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config
 		}
 	}
 
-	// Create temp file for JSON output
-	outputFile := fmt.Sprintf("/tmp/ffuf_%s.json", scanID.String())
-	defer os.Remove(outputFile)
+	// Raw JSON output is kept in the scan workspace
+	ws, err := s.artifacts.Create(scanID)
+	if err != nil {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", err.Error())
+		return err
+	}
+	defer ws.Close()
+	outputFile := ws.Output("ffuf.json")
 
 	// Build ffuf command
 	args := []string{
@@ -199,31 +232,23 @@ func (s *FfufScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config
 		}
 	}
 
-	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", s.ffufPath, args))
-
-	// Execute ffuf
-	cmd := exec.CommandContext(ctx, s.ffufPath, args...)
-
-	// Capture stderr for progress
-	stderr, _ := cmd.StderrPipe()
+	ffufPath := s.currentFfufPath()
+	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", ffufPath, args))
 
-	if err := cmd.Start(); err != nil {
+	// Execute ffuf, again after transient failures
+	err = s.retry.Run(ctx, func(ctx context.Context) error {
+		return s.run(ctx, scanID, ffufPath, args)
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("ffuf", a)
+		s.addLog(scanID, level, message)
+	})
+	var startErr *startError
+	if errors.As(err, &startErr) {
 		s.updateScanStatus(scanID, "failed", 0)
 		s.addLog(scanID, "error", fmt.Sprintf("Failed to start ffuf: %v", err))
 		return err
 	}
-
-	// Read progress from stderr
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			s.addLog(scanID, "debug", line)
-		}
-	}()
-
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
+	if err != nil {
 		// ffuf returns non-zero on no results, which is OK
 		log.Printf("ffuf exited with: %v", err)
 	}
@@ -238,46 +263,59 @@ func (s *FfufScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config
 		return nil
 	}
 
-	var output FfufOutput
-	if err := json.Unmarshal(outputData, &output); err != nil {
+	results, err := ParseFfufOutput(outputData)
+	if err != nil {
 		s.addLog(scanID, "error", fmt.Sprintf("Failed to parse ffuf output: %v", err))
 		s.updateScanStatus(scanID, "failed", 100)
 		return err
 	}
 
 	// Save results
-	for _, result := range output.Results {
-		s.saveFfufResult(scanID, result)
+	for _, result := range results {
+		SaveWebScanResult(s.db, scanID, result)
 	}
 
-	s.addLog(scanID, "info", fmt.Sprintf("Scan completed. Found %d results", len(output.Results)))
+	s.addLog(scanID, "info", fmt.Sprintf("Scan completed. Found %d results", len(results)))
 	s.updateScanStatus(scanID, "completed", 100)
 
 	return nil
 }
 
-func (s *FfufScanner) saveFfufResult(scanID uuid.UUID, result FfufResult) {
-	query := `
-		INSERT INTO web_scan_results (id, scan_id, tool, url, status_code, content_length,
-			words, lines, content_type, redirect_url, metadata, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
-
-	metadata, _ := json.Marshal(map[string]interface{}{
-		"position": result.Position,
-		"duration": result.Duration,
-		"input":    result.Input,
-		"host":     result.Host,
-	})
+// run runs ffuf once, logging its progress
+func (s *FfufScanner) run(ctx context.Context, scanID uuid.UUID, ffufPath string, args []string) error {
+	cmd := s.sandbox.Command(ctx, "ffuf", ffufPath, args...)
 
-	_, err := s.db.Pool.Exec(context.Background(), query,
-		uuid.New(), scanID, "ffuf", result.URL, result.Status, result.Length,
-		result.Words, result.Lines, result.ContentType, result.Redirecturl,
-		metadata, time.Now())
+	// Capture stderr for progress
+	stderr, _ := cmd.StderrPipe()
 
-	if err != nil {
-		log.Printf("Failed to save ffuf result: %v", err)
+	if err := cmd.Start(); err != nil {
+		return &startError{err}
+	}
+
+	// Read progress from stderr
+	var tail stderrTail
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if sandbox.ViolationLine(line) {
+				s.addLog(scanID, "error", "Sandbox violation: "+line)
+				continue
+			}
+			tail.add(line)
+			s.addLog(scanID, "debug", line)
+		}
+	}()
+
+	// Wait for completion
+	<-done
+	err := cmd.Wait()
+	if msg, ok := sandbox.Violation(err); ok {
+		s.addLog(scanID, "error", "Sandbox violation: "+msg)
 	}
+	return tail.attach(err)
 }
 
 func (s *FfufScanner) updateScanStatus(scanID uuid.UUID, status string, progress int) {
@@ -297,7 +335,8 @@ func (s *FfufScanner) updateScanStatus(scanID uuid.UUID, status string, progress
 		argIndex++
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+	// Cancelled scans stay cancelled
+	query += fmt.Sprintf(" WHERE id = $%d AND status <> 'cancelled'", argIndex)
 	args = append(args, scanID)
 
 	s.db.Pool.Exec(context.Background(), query, args...)
@@ -5,15 +5,19 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
 )
 
@@ -21,8 +25,12 @@ import (
 type GowitnessScanner struct {
 	db              *database.Database
 	gowitnessPath   string
+	pathMu          sync.RWMutex
 	screenshotsPath string
 	chromePath      string
+	sandbox         *sandbox.Sandbox
+	artifacts       *artifacts.Manager
+	retry           supervise.Policy
 }
 
 // GowitnessResult represents a gowitness screenshot result
@@ -60,15 +68,35 @@ type GowitnessConfig struct {
 }
 
 // NewGowitnessScanner creates a new gowitness scanner
-func NewGowitnessScanner(db *database.Database, gowitnessPath, screenshotsPath, chromePath string) *GowitnessScanner {
+func NewGowitnessScanner(db *database.Database, gowitnessPath, screenshotsPath, chromePath string, sb *sandbox.Sandbox, am *artifacts.Manager) *GowitnessScanner {
 	return &GowitnessScanner{
 		db:              db,
 		gowitnessPath:   gowitnessPath,
 		screenshotsPath: screenshotsPath,
 		chromePath:      chromePath,
+		sandbox:         sb,
+		artifacts:       am,
 	}
 }
 
+// SetGowitnessPath changes the gowitness binary used by scans started afterwards
+func (s *GowitnessScanner) SetGowitnessPath(path string) {
+	s.pathMu.Lock()
+	s.gowitnessPath = path
+	s.pathMu.Unlock()
+}
+
+// SetRetryPolicy retries gowitness runs that failed transiently
+func (s *GowitnessScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+func (s *GowitnessScanner) currentGowitnessPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.gowitnessPath
+}
+
 // ExecuteScan runs a gowitness scan
 func (s *GowitnessScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config GowitnessConfig) error {
 	// Update scan status to running
@@ -83,8 +111,15 @@ func (s *GowitnessScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, co
 		return err
 	}
 
-	// Create temp file with URLs
-	urlsFile := filepath.Join("/tmp", fmt.Sprintf("urls_%s.txt", scanID.String()))
+	// URL list goes to the workspace scratch dir
+	ws, err := s.artifacts.Create(scanID)
+	if err != nil {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", err.Error())
+		return err
+	}
+	defer ws.Close()
+	urlsFile := ws.Scratch("urls.txt")
 	f, err := os.Create(urlsFile)
 	if err != nil {
 		s.updateScanStatus(scanID, "failed", 0)
@@ -94,7 +129,6 @@ func (s *GowitnessScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, co
 		f.WriteString(url + "\n")
 	}
 	f.Close()
-	defer os.Remove(urlsFile)
 
 	// Build gowitness command (v3.x syntax)
 	args := []string{
@@ -141,38 +175,23 @@ func (s *GowitnessScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, co
 	// Set threads for parallel processing
 	args = append(args, "-t", "4")
 
-	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", s.gowitnessPath, args))
-
-	// Execute gowitness
-	cmd := exec.CommandContext(ctx, s.gowitnessPath, args...)
-	cmd.Env = append(os.Environ(), "DISPLAY=:99")
-
-	// Capture output
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
+	gowitnessPath := s.currentGowitnessPath()
+	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", gowitnessPath, args))
 
-	if err := cmd.Start(); err != nil {
+	// Execute gowitness, again after transient failures
+	err = s.retry.Run(ctx, func(ctx context.Context) error {
+		return s.run(ctx, scanID, gowitnessPath, args)
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("gowitness", a)
+		s.addLog(scanID, level, message)
+	})
+	var startErr *startError
+	if errors.As(err, &startErr) {
 		s.updateScanStatus(scanID, "failed", 0)
 		s.addLog(scanID, "error", fmt.Sprintf("Failed to start gowitness: %v", err))
 		return err
 	}
-
-	// Read output
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			s.addLog(scanID, "debug", scanner.Text())
-		}
-	}()
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			s.addLog(scanID, "debug", scanner.Text())
-		}
-	}()
-
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
+	if err != nil {
 		log.Printf("gowitness exited with: %v", err)
 	}
 
@@ -196,6 +215,53 @@ func (s *GowitnessScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, co
 	return nil
 }
 
+// run runs gowitness once, logging its output
+func (s *GowitnessScanner) run(ctx context.Context, scanID uuid.UUID, gowitnessPath string, args []string) error {
+	cmd := s.sandbox.Command(ctx, "gowitness", gowitnessPath, args...)
+	cmd.Env = append(os.Environ(), "DISPLAY=:99")
+
+	// Capture output
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+
+	if err := cmd.Start(); err != nil {
+		return &startError{err}
+	}
+
+	// Read output
+	var tail stderrTail
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			s.addLog(scanID, "debug", scanner.Text())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if sandbox.ViolationLine(line) {
+				s.addLog(scanID, "error", "Sandbox violation: "+line)
+				continue
+			}
+			tail.add(line)
+			s.addLog(scanID, "debug", line)
+		}
+	}()
+
+	// Wait for completion
+	wg.Wait()
+	err := cmd.Wait()
+	if msg, ok := sandbox.Violation(err); ok {
+		s.addLog(scanID, "error", "Sandbox violation: "+msg)
+	}
+	return tail.attach(err)
+}
+
 func (s *GowitnessScanner) processScreenshots(scanID uuid.UUID, scanDir string, urls []string) ([]GowitnessResult, error) {
 	var results []GowitnessResult
 
@@ -336,7 +402,8 @@ func (s *GowitnessScanner) updateScanStatus(scanID uuid.UUID, status string, pro
 		argIndex++
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+	// Cancelled scans stay cancelled
+	query += fmt.Sprintf(" WHERE id = $%d AND status <> 'cancelled'", argIndex)
 	args = append(args, scanID)
 
 	s.db.Pool.Exec(context.Background(), query, args...)
@@ -5,20 +5,31 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
 	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/noise"
 )
 
 // NucleiScanner handles vulnerability scanning using Nuclei CLI
 type NucleiScanner struct {
 	db            *database.Database
 	nucleiPath    string
+	pathMu        sync.RWMutex
 	templatesPath string
+	sandbox       *sandbox.Sandbox
+	artifacts     *artifacts.Manager
+	limits        *limits.Limits
+	noise         *noise.Store
 }
 
 // NucleiOutput represents the JSON output from Nuclei
@@ -29,6 +40,7 @@ type NucleiOutput struct {
 	Type             string       `json:"type"`
 	Host             string       `json:"host"`
 	MatchedAt        string       `json:"matched-at"`
+	MatcherName      string       `json:"matcher-name"`
 	ExtractedResults []string     `json:"extracted-results"`
 	Request          string       `json:"request"`
 	Response         string       `json:"response"`
@@ -56,14 +68,39 @@ type NucleiClassification struct {
 }
 
 // NewNucleiScanner creates a new Nuclei scanner instance
-func NewNucleiScanner(db *database.Database, nucleiPath, templatesPath string) *NucleiScanner {
+func NewNucleiScanner(db *database.Database, nucleiPath, templatesPath string, sb *sandbox.Sandbox, am *artifacts.Manager) *NucleiScanner {
 	return &NucleiScanner{
 		db:            db,
 		nucleiPath:    nucleiPath,
 		templatesPath: templatesPath,
+		sandbox:       sb,
+		artifacts:     am,
 	}
 }
 
+// SetNucleiPath changes the nuclei binary used by scans started afterwards
+func (ns *NucleiScanner) SetNucleiPath(path string) {
+	ns.pathMu.Lock()
+	ns.nucleiPath = path
+	ns.pathMu.Unlock()
+}
+
+// SetLimits caps the requests, responses and curl commands of findings
+func (ns *NucleiScanner) SetLimits(l *limits.Limits) {
+	ns.limits = l
+}
+
+// SetNoise filters the findings of scans with their noise profile
+func (ns *NucleiScanner) SetNoise(store *noise.Store) {
+	ns.noise = store
+}
+
+func (ns *NucleiScanner) currentNucleiPath() string {
+	ns.pathMu.RLock()
+	defer ns.pathMu.RUnlock()
+	return ns.nucleiPath
+}
+
 // ExecuteVulnScan runs a Nuclei vulnerability scan using CLI
 func (ns *NucleiScanner) ExecuteVulnScan(ctx context.Context, scanID uuid.UUID, target string, templates []string, severity []string, tags []string) error {
 	// Update scan status to running
@@ -74,68 +111,165 @@ func (ns *NucleiScanner) ExecuteVulnScan(ctx context.Context, scanID uuid.UUID,
 	// Log scan start
 	ns.addLog(scanID, "info", fmt.Sprintf("Starting vulnerability scan on target: %s", target))
 
-	// Build Nuclei command
+	// Each target runs in its own nuclei process, so its status is known
+	if err := ns.createTargets(scanID, SplitTargets(target)); err != nil {
+		errMsg := err.Error()
+		ns.addLog(scanID, "error", errMsg)
+		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
+		return err
+	}
+
+	return ns.runTargets(ctx, scanID, vulnScanArgs(templates, severity, tags))
+}
+
+// ExecuteTemplateCheck re-runs a single template (by template ID) against
+// target, e.g. to verify that a finding marked as fixed is really gone
+func (ns *NucleiScanner) ExecuteTemplateCheck(ctx context.Context, scanID uuid.UUID, target, templateID string) error {
+	if err := ns.updateScanStatus(scanID, "running", 0, nil); err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	ns.addLog(scanID, "info", fmt.Sprintf("Verifying template %s on target: %s", templateID, target))
+
 	args := []string{
 		"-target", target,
-		"-jsonl",  // JSONL output for parsing (Nuclei v3)
-		"-silent", // Suppress banner
-		"-nc",     // No color codes
+		"-id", templateID,
+		"-jsonl",
+		"-silent",
+		"-nc",
 	}
+	return ns.run(ctx, scanID, args)
+}
+
+// nucleiStatsInterval is how often nuclei reports its request counters
+const nucleiStatsInterval = "5"
+
+// run executes nuclei with args and stores the findings of scanID
+func (ns *NucleiScanner) run(ctx context.Context, scanID uuid.UUID, args []string) error {
+	// Progress estimate; nuclei doesn't say how far it got
+	ns.updateScanStatus(scanID, "running", 50, nil)
 
-	// Add template filters if specified
-	if len(templates) > 0 {
-		args = append(args, "-t", strings.Join(templates, ","))
+	result, err := ns.process(ctx, scanID, args)
+	if err != nil {
+		errMsg := err.Error()
+		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
+		return err
 	}
+	ns.saveRunStats(scanID, &result.stats)
 
-	// Add severity filters if specified
-	if len(severity) > 0 {
-		args = append(args, "-severity", strings.Join(severity, ","))
+	if result.exitErr != nil && ctx.Err() == context.Canceled {
+		ns.addLog(scanID, "info", "Scan was cancelled")
+		ns.updateScanStatus(scanID, "cancelled", 100, nil)
+		return nil
 	}
 
-	// Add tag filters if specified
-	if len(tags) > 0 {
-		args = append(args, "-tags", strings.Join(tags, ","))
+	// Complete scan
+	ns.addLog(scanID, "info", fmt.Sprintf("Scan completed. Found %d vulnerabilities", result.found))
+	ns.updateScanStatus(scanID, "completed", 100, nil)
+
+	return nil
+}
+
+// nucleiRun is what one nuclei process did
+type nucleiRun struct {
+	found    int
+	filtered map[string]int // noise, by disposition: stored but not in found
+	stats    runStats
+	exitErr  error // nuclei can exit non-zero even if it found vulns
+}
+
+// failed reports whether the run got nothing done: nuclei exited with an
+// error before reporting anything, or every request it sent failed
+func (r *nucleiRun) failed() (string, bool) {
+	if r.exitErr != nil && r.found == 0 && len(r.filtered) == 0 && !r.stats.reported {
+		return fmt.Sprintf("nuclei exited: %v", r.exitErr), true
 	}
+	if r.stats.reported && r.stats.stats.Requests > 0 && r.stats.stats.Errors >= r.stats.stats.Requests {
+		return fmt.Sprintf("all %d requests failed", r.stats.stats.Requests), true
+	}
+	return "", false
+}
 
+// process runs one nuclei process with args and stores its findings. The
+// error is only set when nuclei couldn't be started; its exit status is in
+// the run.
+func (ns *NucleiScanner) process(ctx context.Context, scanID uuid.UUID, args []string) (*nucleiRun, error) {
+	// JSON request counters, kept with the scan for its stats
+	args = append(args, "-stats", "-sj", "-si", nucleiStatsInterval)
 	ns.addLog(scanID, "info", fmt.Sprintf("Running: nuclei %s", strings.Join(args, " ")))
 
 	// Create command with context
-	cmd := exec.CommandContext(ctx, ns.nucleiPath, args...)
+	nucleiPath := ns.currentNucleiPath()
+	cmd := ns.sandbox.Command(ctx, "nuclei", nucleiPath, args...)
 
 	// Get stdout pipe for streaming results
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to create stdout pipe: %v", err)
-		ns.addLog(scanID, "error", errMsg)
-		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		ns.addLog(scanID, "error", fmt.Sprintf("Failed to create stdout pipe: %v", err))
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	// Get stderr pipe for error messages
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to create stderr pipe: %v", err)
-		ns.addLog(scanID, "error", errMsg)
-		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		ns.addLog(scanID, "error", fmt.Sprintf("Failed to create stderr pipe: %v", err))
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		errMsg := fmt.Sprintf("Failed to start Nuclei: %v", err)
-		ns.addLog(scanID, "error", errMsg)
-		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
-		return fmt.Errorf("failed to start nuclei: %w", err)
+		ns.addLog(scanID, "error", fmt.Sprintf("Failed to start Nuclei: %v", err))
+		return nil, fmt.Errorf("failed to start nuclei: %w", err)
 	}
 
+	// Raw JSONL output is kept in the scan workspace
+	var raw *os.File
+	if ws, err := ns.artifacts.Create(scanID); err != nil {
+		ns.addLog(scanID, "warning", err.Error())
+	} else {
+		defer ws.Close()
+		if raw, err = os.OpenFile(ws.Output("nuclei.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			ns.addLog(scanID, "warning", fmt.Sprintf("Failed to create raw output file: %v", err))
+		} else {
+			defer raw.Close()
+		}
+	}
+
+	// Read stderr while stdout is processed: with -stats nuclei writes to it
+	// for the whole run and would block once the pipe buffer is full
+	// Informational noise is filtered as it's stored
+	profile, err := ns.noise.ForScan(ctx, scanID)
+	if err != nil {
+		ns.addLog(scanID, "warning", fmt.Sprintf("Noise profile not applied: %v", err))
+	}
+
+	result := &nucleiRun{filtered: map[string]int{}}
+	var stderrLines []string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		stderrScanner := bufio.NewScanner(stderr)
+		for stderrScanner.Scan() {
+			line := stderrScanner.Text()
+			if !result.stats.add(line) {
+				stderrLines = append(stderrLines, line)
+			}
+		}
+	}()
+
 	// Process stdout (JSON results)
-	vulnCount := 0
 	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), limits.MaxLineBytes)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
+		if result.stats.add(line) {
+			continue
+		}
+		if raw != nil {
+			fmt.Fprintln(raw, line)
+		}
 
 		var output NucleiOutput
 		if err := json.Unmarshal([]byte(line), &output); err != nil {
@@ -145,32 +279,47 @@ func (ns *NucleiScanner) ExecuteVulnScan(ctx context.Context, scanID uuid.UUID,
 
 		// Convert to our vulnerability model and save
 		vuln := ns.parseNucleiOutput(scanID, &output)
+		vuln.Request = ns.limits.Apply(ctx, scanID, vuln.ID, "request", vuln.Request, &vuln.Truncated)
+		vuln.Response = ns.limits.Apply(ctx, scanID, vuln.ID, "response", vuln.Response, &vuln.Truncated)
+		vuln.CURLCommand = ns.limits.Apply(ctx, scanID, vuln.ID, "curl_command", vuln.CURLCommand, &vuln.Truncated)
+		if _, err := ns.noise.Apply(ctx, profile, vuln, output.MatcherName); err != nil {
+			ns.addLog(scanID, "warning", fmt.Sprintf("Failed to record technology of %s: %v", vuln.Host, err))
+		}
 		if err := ns.saveVulnerability(vuln); err != nil {
 			ns.addLog(scanID, "error", fmt.Sprintf("Failed to save vulnerability: %v", err))
+		} else if vuln.Suppressed != "" {
+			result.filtered[vuln.Suppressed]++
 		} else {
-			vulnCount++
+			result.found++
 			ns.addLog(scanID, "info", fmt.Sprintf("Found: [%s] %s - %s",
 				output.Info.Severity, output.TemplateID, output.Host))
 		}
-
-		// Update progress (estimate)
-		ns.updateScanStatus(scanID, "running", 50, nil)
+	}
+	if len(result.filtered) > 0 {
+		ns.addLog(scanID, "info", fmt.Sprintf("Noise profile %s: %d findings collapsed into technologies, %d suppressed",
+			profile.Name, result.filtered[noise.Collapsed], result.filtered[noise.Suppressed]))
 	}
 
-	// Read stderr for any error messages
-	stderrScanner := bufio.NewScanner(stderr)
-	var stderrLines []string
-	for stderrScanner.Scan() {
-		stderrLines = append(stderrLines, stderrScanner.Text())
+	if err := scanner.Err(); err != nil {
+		ns.addLog(scanID, "warning", fmt.Sprintf("Stopped reading nuclei output: %v", err))
 	}
 
+	<-stderrDone
+
 	// Wait for command to complete
 	if err := cmd.Wait(); err != nil {
-		// Check if context was cancelled
+		result.exitErr = err
 		if ctx.Err() == context.Canceled {
-			ns.addLog(scanID, "info", "Scan was cancelled")
-			ns.updateScanStatus(scanID, "cancelled", 100, nil)
-			return nil
+			return result, nil
+		}
+
+		if msg, ok := sandbox.Violation(err); ok {
+			ns.addLog(scanID, "error", "Sandbox violation: "+msg)
+		}
+		for _, line := range stderrLines {
+			if sandbox.ViolationLine(line) {
+				ns.addLog(scanID, "error", "Sandbox violation: "+line)
+			}
 		}
 
 		// Log stderr if there was an error
@@ -178,15 +327,73 @@ func (ns *NucleiScanner) ExecuteVulnScan(ctx context.Context, scanID uuid.UUID,
 			ns.addLog(scanID, "warning", fmt.Sprintf("Nuclei stderr: %s", strings.Join(stderrLines, "\n")))
 		}
 
-		// Nuclei can return non-zero even if it found vulns, so just log
 		ns.addLog(scanID, "info", fmt.Sprintf("Nuclei process exited: %v", err))
 	}
 
-	// Complete scan
-	ns.addLog(scanID, "info", fmt.Sprintf("Scan completed. Found %d vulnerabilities", vulnCount))
-	ns.updateScanStatus(scanID, "completed", 100, nil)
+	return result, nil
+}
 
-	return nil
+// runStats collects the -stats-json lines nuclei reports during a run
+type runStats struct {
+	mu       sync.Mutex
+	reported bool
+	stats    models.NucleiRunStats
+}
+
+// add records line if it is a stats line, such as
+// {"duration":"0:00:05","errors":"0","hosts":"1","requests":"152","rps":"30",...}
+func (r *runStats) add(line string) bool {
+	if !strings.Contains(line, `"rps"`) {
+		return false
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(line), &fields) != nil {
+		return false
+	}
+	if _, ok := fields["template-id"]; ok {
+		return false
+	}
+	if _, ok := fields["requests"]; !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reported = true
+	r.stats.Requests = int64(statsNumber(fields["requests"]))
+	r.stats.Errors = int64(statsNumber(fields["errors"]))
+	r.stats.Templates = int64(statsNumber(fields["templates"]))
+	r.stats.Hosts = int64(statsNumber(fields["hosts"]))
+	if rps := statsNumber(fields["rps"]); rps > r.stats.PeakRPS {
+		r.stats.PeakRPS = rps
+	}
+	return true
+}
+
+// statsNumber reads a counter; nuclei v3 reports them as strings
+func statsNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
+// saveRunStats stores the last counters nuclei reported with the scan
+func (ns *NucleiScanner) saveRunStats(scanID uuid.UUID, r *runStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.reported {
+		return
+	}
+	_, err := ns.db.Pool.Exec(context.Background(),
+		`UPDATE vulnerability_scans SET run_stats = $1 WHERE id = $2`, r.stats, scanID)
+	if err != nil {
+		ns.addLog(scanID, "warning", fmt.Sprintf("Failed to save request stats: %v", err))
+	}
 }
 
 // parseNucleiOutput converts Nuclei JSON output to our Vulnerability model
@@ -264,13 +471,13 @@ func (ns *NucleiScanner) addLog(scanID uuid.UUID, level, message string) error {
 func (ns *NucleiScanner) saveVulnerability(vuln *models.Vulnerability) error {
 	query := `INSERT INTO vulnerabilities
 	          (id, scan_id, template_id, template_name, severity, type, host, matched_at,
-	           extracted_results, curl_command, request, response, metadata, created_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	           extracted_results, curl_command, request, response, metadata, truncated, suppressed, suppressed_by, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), NULLIF($16, ''), $17)`
 
 	_, err := ns.db.Pool.Exec(context.Background(), query,
 		vuln.ID, vuln.ScanID, vuln.TemplateID, vuln.TemplateName, vuln.Severity,
 		vuln.Type, vuln.Host, vuln.MatchedAt, vuln.ExtractedResults, vuln.CURLCommand,
-		vuln.Request, vuln.Response, vuln.Metadata, vuln.CreatedAt)
+		vuln.Request, vuln.Response, vuln.Metadata, vuln.Truncated, vuln.Suppressed, vuln.SuppressedBy, vuln.CreatedAt)
 
 	return err
 }
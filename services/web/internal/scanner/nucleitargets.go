@@ -0,0 +1,278 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+const (
+	// nucleiTargetConcurrency is how many targets of a scan nuclei runs
+	// against at once, unless the scan sets configuration.target_concurrency
+	nucleiTargetConcurrency    = 4
+	nucleiMaxTargetConcurrency = 16
+)
+
+// SplitTargets returns the targets of a list separated by commas, spaces or
+// newlines, without repeats
+func SplitTargets(target string) []string {
+	seen := map[string]bool{}
+	targets := []string{}
+	for _, t := range strings.FieldsFunc(target, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// vulnScanArgs are the nuclei options of a vulnerability scan, without target
+func vulnScanArgs(templates []string, severity []string, tags []string) []string {
+	args := []string{
+		"-jsonl",  // JSONL output for parsing (Nuclei v3)
+		"-silent", // Suppress banner
+		"-nc",     // No color codes
+	}
+
+	// Add template filters if specified
+	if len(templates) > 0 {
+		args = append(args, "-t", strings.Join(templates, ","))
+	}
+
+	// Add severity filters if specified
+	if len(severity) > 0 {
+		args = append(args, "-severity", strings.Join(severity, ","))
+	}
+
+	// Add tag filters if specified
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, ","))
+	}
+	return args
+}
+
+// RetryFailedTargets runs nuclei again against the targets of a finished
+// scan that failed, and completes the scan again
+func (ns *NucleiScanner) RetryFailedTargets(ctx context.Context, scanID uuid.UUID, templates []string, severity []string, tags []string) error {
+	tag, err := ns.db.Pool.Exec(context.Background(), `
+		UPDATE vulnerability_scan_targets SET status = 'pending', error = NULL
+		WHERE scan_id = $1 AND status = 'failed'
+	`, scanID)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed targets: %w", err)
+	}
+	_, err = ns.db.Pool.Exec(context.Background(), `
+		UPDATE vulnerability_scans SET status = 'running', completed_at = NULL, error_message = NULL WHERE id = $1
+	`, scanID)
+	if err != nil {
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	ns.addLog(scanID, "info", fmt.Sprintf("Retrying %d failed targets", tag.RowsAffected()))
+	return ns.runTargets(ctx, scanID, vulnScanArgs(templates, severity, tags))
+}
+
+// createTargets stores the targets of a scan as pending
+func (ns *NucleiScanner) createTargets(scanID uuid.UUID, targets []string) error {
+	for i, target := range targets {
+		_, err := ns.db.Pool.Exec(context.Background(), `
+			INSERT INTO vulnerability_scan_targets (id, scan_id, position, target, status)
+			VALUES ($1, $2, $3, $4, 'pending')
+			ON CONFLICT (scan_id, target) DO NOTHING
+		`, uuid.New(), scanID, i, target)
+		if err != nil {
+			return fmt.Errorf("failed to store targets: %w", err)
+		}
+	}
+	return nil
+}
+
+// pendingTarget is a target nuclei hasn't run against yet
+type pendingTarget struct {
+	id     uuid.UUID
+	target string
+}
+
+// runTargets runs nuclei against the pending targets of a scan, a few at a
+// time, and completes the scan. The scan fails only when every target did.
+func (ns *NucleiScanner) runTargets(ctx context.Context, scanID uuid.UUID, args []string) error {
+	var configuration map[string]interface{}
+	ns.db.Pool.QueryRow(context.Background(),
+		`SELECT configuration FROM vulnerability_scans WHERE id = $1`, scanID).Scan(&configuration)
+	concurrency := nucleiTargetConcurrency
+	if n, ok := configuration["target_concurrency"].(float64); ok && n >= 1 {
+		concurrency = int(n)
+	}
+	if concurrency > nucleiMaxTargetConcurrency {
+		concurrency = nucleiMaxTargetConcurrency
+	}
+
+	rows, err := ns.db.Pool.Query(context.Background(), `
+		SELECT id, target FROM vulnerability_scan_targets
+		WHERE scan_id = $1 AND status = 'pending'
+		ORDER BY position
+	`, scanID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to fetch targets: %v", err)
+		ns.updateScanStatus(scanID, "failed", 0, &errMsg)
+		return errors.New(errMsg)
+	}
+	var pending []pendingTarget
+	for rows.Next() {
+		var t pendingTarget
+		if err := rows.Scan(&t.id, &t.target); err == nil {
+			pending = append(pending, t)
+		}
+	}
+	rows.Close()
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, t := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(t pendingTarget) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			ns.runTarget(ctx, scanID, t, args)
+		}(t)
+	}
+	wg.Wait()
+	ns.saveTargetRunStats(scanID)
+
+	if ctx.Err() == context.Canceled {
+		ns.addLog(scanID, "info", "Scan was cancelled")
+		ns.updateScanStatus(scanID, "cancelled", 100, nil)
+		return nil
+	}
+
+	var done, failed, found int
+	ns.db.Pool.QueryRow(context.Background(), `
+		SELECT COUNT(*) FILTER (WHERE status = 'done'), COUNT(*) FILTER (WHERE status = 'failed'),
+		       COALESCE(SUM(findings), 0)
+		FROM vulnerability_scan_targets WHERE scan_id = $1
+	`, scanID).Scan(&done, &failed, &found)
+
+	if done == 0 && failed > 0 {
+		errMsg := fmt.Sprintf("all %d targets failed", failed)
+		ns.addLog(scanID, "error", "Scan failed: "+errMsg)
+		ns.updateScanStatus(scanID, "failed", 100, &errMsg)
+		return errors.New(errMsg)
+	}
+	if failed > 0 {
+		ns.addLog(scanID, "warning", fmt.Sprintf("%d of %d targets failed; they can be retried with POST /api/vulnerabilities/%s/retry",
+			failed, done+failed, scanID))
+	}
+
+	// Complete scan
+	ns.addLog(scanID, "info", fmt.Sprintf("Scan completed. Found %d vulnerabilities", found))
+	ns.updateScanStatus(scanID, "completed", 100, nil)
+	return nil
+}
+
+// runTarget runs nuclei against one target and records how it went
+func (ns *NucleiScanner) runTarget(ctx context.Context, scanID uuid.UUID, t pendingTarget, args []string) {
+	ns.db.Pool.Exec(context.Background(), `
+		UPDATE vulnerability_scan_targets
+		SET status = 'running', attempts = attempts + 1, started_at = NOW(), completed_at = NULL
+		WHERE id = $1
+	`, t.id)
+	ns.updateTargetProgress(scanID)
+
+	result, err := ns.process(ctx, scanID, append([]string{"-target", t.target}, args...))
+
+	status, found := "done", 0
+	var errMsg *string
+	var stats *models.NucleiRunStats
+	switch {
+	case ctx.Err() == context.Canceled:
+		status = "pending"
+	case err != nil:
+		status = "failed"
+		msg := err.Error()
+		errMsg = &msg
+	default:
+		found = result.found
+		if msg, failed := result.failed(); failed {
+			status = "failed"
+			errMsg = &msg
+		}
+	}
+	if result != nil && result.stats.reported {
+		stats = &result.stats.stats
+	}
+
+	_, err = ns.db.Pool.Exec(context.Background(), `
+		UPDATE vulnerability_scan_targets
+		SET status = $1, findings = findings + $2, error = $3, run_stats = COALESCE($4, run_stats), completed_at = NOW()
+		WHERE id = $5
+	`, status, found, errMsg, stats, t.id)
+	if err != nil {
+		ns.addLog(scanID, "warning", fmt.Sprintf("Failed to update target %s: %v", t.target, err))
+	}
+
+	switch status {
+	case "done":
+		ns.addLog(scanID, "info", fmt.Sprintf("Target %s done: %d vulnerabilities", t.target, found))
+	case "failed":
+		ns.addLog(scanID, "warning", fmt.Sprintf("Target %s failed: %s", t.target, *errMsg))
+	}
+	ns.updateTargetProgress(scanID)
+}
+
+// updateTargetProgress sets the scan's progress from its targets: finished
+// ones count whole, running ones half
+func (ns *NucleiScanner) updateTargetProgress(scanID uuid.UUID) {
+	ns.db.Pool.Exec(context.Background(), `
+		UPDATE vulnerability_scans SET progress = LEAST(99, (
+			SELECT (COUNT(*) FILTER (WHERE status IN ('done', 'failed')) * 100 +
+			        COUNT(*) FILTER (WHERE status = 'running') * 50) / GREATEST(COUNT(*), 1)
+			FROM vulnerability_scan_targets WHERE scan_id = $1
+		))
+		WHERE id = $1 AND status = 'running'
+	`, scanID)
+}
+
+// saveTargetRunStats stores the counters of every target's last run with
+// the scan: the request and error totals, and the busiest target's peak rate
+func (ns *NucleiScanner) saveTargetRunStats(scanID uuid.UUID) {
+	rows, err := ns.db.Pool.Query(context.Background(), `
+		SELECT run_stats FROM vulnerability_scan_targets WHERE scan_id = $1 AND run_stats IS NOT NULL
+	`, scanID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	total := runStats{}
+	for rows.Next() {
+		var stats models.NucleiRunStats
+		if err := rows.Scan(&stats); err != nil {
+			continue
+		}
+		total.reported = true
+		total.stats.Requests += stats.Requests
+		total.stats.Errors += stats.Errors
+		total.stats.Hosts += stats.Hosts
+		if stats.Templates > total.stats.Templates {
+			total.stats.Templates = stats.Templates
+		}
+		if stats.PeakRPS > total.stats.PeakRPS {
+			total.stats.PeakRPS = stats.PeakRPS
+		}
+	}
+	rows.Close()
+	ns.saveRunStats(scanID, &total)
+}
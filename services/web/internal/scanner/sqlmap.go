@@ -0,0 +1,299 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+	"github.com/security-scanner/web-service/internal/artifacts"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
+)
+
+// SqlmapScanner tests request parameters for SQL injection with sqlmap
+type SqlmapScanner struct {
+	db         *database.Database
+	sqlmapPath string
+	pathMu     sync.RWMutex
+	sandbox    *sandbox.Sandbox
+	artifacts  *artifacts.Manager
+	retry      supervise.Policy
+	limits     *limits.Limits
+}
+
+// SqlmapConfig holds configuration for a sqlmap scan
+type SqlmapConfig struct {
+	URL        string   `json:"url"`
+	Method     string   `json:"method"`     // GET, POST, PUT...; sqlmap picks GET, or POST with data
+	Data       string   `json:"data"`       // request body, e.g. id=1&name=a or JSON
+	Cookie     string   `json:"cookie"`     // Cookie header, whose values are tested at level 2 and above
+	Headers    []string `json:"headers"`    // extra "Name: value" headers
+	Parameters []string `json:"parameters"` // test only these parameters (-p)
+	Level      int      `json:"level"`      // 1-5: how many places and payloads are tested
+	Risk       int      `json:"risk"`       // 1-3: 3 adds OR-based payloads that may modify data
+	Technique  string   `json:"technique"`  // subset of BEUSTQ
+	DBMS       string   `json:"dbms"`       // skip the fingerprint and test this DBMS only
+	Threads    int      `json:"threads"`    // 1-10
+	Timeout    int      `json:"timeout"`    // seconds per request
+}
+
+// sqlmapLogLineRe matches sqlmap's console messages and their level
+var sqlmapLogLineRe = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\] \[(\w+)\] (.*)$`)
+
+// NewSqlmapScanner creates a new sqlmap scanner and allows its scans in
+// web_scans
+func NewSqlmapScanner(db *database.Database, sqlmapPath string, sb *sandbox.Sandbox, am *artifacts.Manager) (*SqlmapScanner, error) {
+	if _, err := db.Pool.Exec(context.Background(), webScanToolSchemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to allow sqlmap scans: %w", err)
+	}
+	return &SqlmapScanner{
+		db:         db,
+		sqlmapPath: sqlmapPath,
+		sandbox:    sb,
+		artifacts:  am,
+	}, nil
+}
+
+// SetSqlmapPath changes the sqlmap binary used by scans started afterwards
+func (s *SqlmapScanner) SetSqlmapPath(path string) {
+	s.pathMu.Lock()
+	s.sqlmapPath = path
+	s.pathMu.Unlock()
+}
+
+// SetRetryPolicy retries sqlmap runs that failed transiently
+func (s *SqlmapScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+// SetLimits caps the text of findings
+func (s *SqlmapScanner) SetLimits(l *limits.Limits) {
+	s.limits = l
+}
+
+func (s *SqlmapScanner) currentSqlmapPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.sqlmapPath
+}
+
+// SqlmapArgs builds the sqlmap command line of a scan writing its session
+// to outputDir; it never asks questions (--batch)
+func SqlmapArgs(config SqlmapConfig, outputDir string) []string {
+	args := []string{"-u", config.URL, "--batch", "--output-dir", outputDir, "--disable-coloring"}
+	if config.Method != "" {
+		args = append(args, "--method", strings.ToUpper(config.Method))
+	}
+	if config.Data != "" {
+		args = append(args, "--data", config.Data)
+	}
+	if config.Cookie != "" {
+		args = append(args, "--cookie", config.Cookie)
+	}
+	for _, h := range config.Headers {
+		args = append(args, "-H", h)
+	}
+	if len(config.Parameters) > 0 {
+		args = append(args, "-p", strings.Join(config.Parameters, ","))
+	}
+
+	level := config.Level
+	if level <= 0 {
+		level = 1
+	}
+	risk := config.Risk
+	if risk <= 0 {
+		risk = 1
+	}
+	args = append(args, "--level", strconv.Itoa(level), "--risk", strconv.Itoa(risk))
+
+	if config.Technique != "" {
+		args = append(args, "--technique", strings.ToUpper(config.Technique))
+	}
+	if config.DBMS != "" {
+		args = append(args, "--dbms", config.DBMS)
+	}
+	if config.Threads > 0 {
+		args = append(args, "--threads", strconv.Itoa(config.Threads))
+	}
+	if config.Timeout > 0 {
+		args = append(args, "--timeout", strconv.Itoa(config.Timeout))
+	}
+	return args
+}
+
+// ExecuteScan runs a sqlmap scan
+func (s *SqlmapScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config SqlmapConfig) error {
+	s.updateScanStatus(scanID, "running", 0)
+	s.addLog(scanID, "info", fmt.Sprintf("Starting sqlmap scan on target: %s", config.URL))
+
+	// The console output is kept in the scan workspace; sqlmap's session
+	// files are not
+	ws, err := s.artifacts.Create(scanID)
+	if err != nil {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", err.Error())
+		return err
+	}
+	defer ws.Close()
+	outputFile := ws.Output("sqlmap.log")
+	args := SqlmapArgs(config, ws.Scratch("sqlmap"))
+
+	sqlmapPath := s.currentSqlmapPath()
+	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", sqlmapPath, args))
+
+	// Execute sqlmap, again after transient failures
+	err = s.retry.Run(ctx, func(ctx context.Context) error {
+		// Each attempt's output replaces the previous one's
+		return s.run(ctx, scanID, sqlmapPath, args, outputFile)
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("sqlmap", a)
+		s.addLog(scanID, level, message)
+	})
+	var startErr *startError
+	if errors.As(err, &startErr) {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", fmt.Sprintf("Failed to start sqlmap: %v", err))
+		return err
+	}
+	if err != nil {
+		// Continue to parse results even if exit code is non-zero
+		log.Printf("sqlmap exited with: %v", err)
+	}
+
+	s.updateScanStatus(scanID, "running", 90)
+
+	outputData, err := os.ReadFile(outputFile)
+	if err != nil {
+		s.addLog(scanID, "warning", "No sqlmap output generated")
+		s.updateScanStatus(scanID, "completed", 100)
+		return nil
+	}
+
+	results, _ := ParseSqlmapOutput(config.URL, outputData)
+	injectable := 0
+	for _, result := range results {
+		if result.FindingID != "sqlmap-fingerprint" {
+			injectable++
+		}
+		s.limits.ApplyWebResult(ctx, scanID, &result)
+		SaveWebScanResult(s.db, scanID, result)
+	}
+
+	if injectable == 0 {
+		s.addLog(scanID, "info", "Scan completed. No injectable parameters found")
+	} else {
+		s.addLog(scanID, "warning", fmt.Sprintf("Scan completed. Found %d injectable parameters", injectable))
+	}
+	s.updateScanStatus(scanID, "completed", 100)
+	return nil
+}
+
+// run runs sqlmap once, streaming its output to the scan logs and to
+// outputFile
+func (s *SqlmapScanner) run(ctx context.Context, scanID uuid.UUID, sqlmapPath string, args []string, outputFile string) error {
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return &startError{err}
+	}
+	defer output.Close()
+
+	cmd := s.sandbox.Command(ctx, "sqlmap", sqlmapPath, args...)
+	stderr, _ := cmd.StderrPipe()
+	stdout, _ := cmd.StdoutPipe()
+
+	if err := cmd.Start(); err != nil {
+		return &startError{err}
+	}
+
+	var tail stderrTail
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), limits.MaxLineBytes)
+		for scanner.Scan() {
+			line := scanner.Text()
+			fmt.Fprintln(output, line)
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			// sqlmap's own levels: [INFO], [WARNING], [CRITICAL], [PAYLOAD]...
+			level := "debug"
+			if m := sqlmapLogLineRe.FindStringSubmatch(line); m != nil {
+				switch m[1] {
+				case "INFO":
+					level = "info"
+				case "WARNING":
+					level = "warning"
+				case "ERROR", "CRITICAL":
+					level = "error"
+				}
+			}
+			s.addLog(scanID, level, line)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if sandbox.ViolationLine(line) {
+				s.addLog(scanID, "error", "Sandbox violation: "+line)
+				continue
+			}
+			tail.add(line)
+			s.addLog(scanID, "debug", line)
+		}
+	}()
+
+	wg.Wait()
+	err = cmd.Wait()
+	if msg, ok := sandbox.Violation(err); ok {
+		s.addLog(scanID, "error", "Sandbox violation: "+msg)
+	}
+	return tail.attach(err)
+}
+
+func (s *SqlmapScanner) updateScanStatus(scanID uuid.UUID, status string, progress int) {
+	query := `UPDATE web_scans SET status = $1, progress = $2`
+	args := []interface{}{status, progress}
+	argIndex := 3
+
+	if status == "running" && progress == 0 {
+		query += fmt.Sprintf(", started_at = $%d", argIndex)
+		args = append(args, time.Now())
+		argIndex++
+	}
+
+	if status == "completed" || status == "failed" {
+		query += fmt.Sprintf(", completed_at = $%d", argIndex)
+		args = append(args, time.Now())
+		argIndex++
+	}
+
+	// Cancelled scans stay cancelled
+	query += fmt.Sprintf(" WHERE id = $%d AND status <> 'cancelled'", argIndex)
+	args = append(args, scanID)
+
+	s.db.Pool.Exec(context.Background(), query, args...)
+}
+
+func (s *SqlmapScanner) addLog(scanID uuid.UUID, level, message string) {
+	query := `INSERT INTO web_scan_logs (id, scan_id, level, message, created_at) VALUES ($1, $2, $3, $4, $5)`
+	s.db.Pool.Exec(context.Background(), query, uuid.New(), scanID, level, message, time.Now())
+	log.Printf("[%s] %s: %s", scanID.String()[:8], level, message)
+}
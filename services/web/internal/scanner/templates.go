@@ -0,0 +1,114 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTemplateNotFound is returned when no installed template has the ID
+var ErrTemplateNotFound = errors.New("nuclei template not found")
+
+// TemplateInfo is what a nuclei template says about the software it targets
+type TemplateInfo struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Severity string   `json:"severity,omitempty"`
+	CVE      []string `json:"cve,omitempty"`
+	Vendor   string   `json:"vendor,omitempty"`
+	Product  []string `json:"product,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Path     string   `json:"path"`
+}
+
+// FindTemplate looks up an installed template by ID or CVE. Official
+// templates are named after their ID (CVE-2021-44228.yaml), so only the
+// file names are compared.
+func (ns *NucleiScanner) FindTemplate(id string) (*TemplateInfo, error) {
+	want := strings.ToLower(strings.TrimSpace(id)) + ".yaml"
+
+	var found string
+	err := filepath.WalkDir(ns.templatesPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && strings.ToLower(d.Name()) == want {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == "" {
+		return nil, ErrTemplateNotFound
+	}
+	return readTemplateInfo(found)
+}
+
+// readTemplateInfo pulls the id, info and metadata fields out of a template.
+// Templates keep these as scalar lines, so a line scan is enough.
+func readTemplateInfo(path string) (*TemplateInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &TemplateInfo{Path: path}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if value == "" {
+			continue
+		}
+		// Requests follow the info block and hold nothing we need
+		if !strings.HasPrefix(line, " ") && (key == "http" || key == "requests" || key == "network" || key == "tcp" || key == "dns" || key == "ssl" || key == "code" || key == "javascript") {
+			break
+		}
+		switch key {
+		case "id":
+			if info.ID == "" {
+				info.ID = value
+			}
+		case "name":
+			if info.Name == "" {
+				info.Name = value
+			}
+		case "severity":
+			info.Severity = value
+		case "cve-id":
+			info.CVE = splitList(value)
+		case "vendor":
+			info.Vendor = value
+		case "product":
+			info.Product = splitList(value)
+		case "tags":
+			info.Tags = splitList(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// splitList reads "a,b" and "[a, b]" as a list
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
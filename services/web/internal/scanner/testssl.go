@@ -3,22 +3,33 @@ package scanner
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/sandbox"
+	"github.com/security-scanner/shared/pkg/supervise"
+	"github.com/security-scanner/shared/pkg/tlspolicy"
+	"github.com/security-scanner/web-service/internal/artifacts"
 	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
 )
 
 // TestsslScanner handles SSL/TLS analysis with testssl.sh
 type TestsslScanner struct {
 	db          *database.Database
 	testsslPath string
+	pathMu      sync.RWMutex
+	sandbox     *sandbox.Sandbox
+	artifacts   *artifacts.Manager
+	retry       supervise.Policy
+	limits      *limits.Limits
+	policies    *tlspolicy.Loader
 }
 
 // TestsslFinding represents a single testssl.sh finding
@@ -67,22 +78,59 @@ type TestsslConfig struct {
 }
 
 // NewTestsslScanner creates a new testssl.sh scanner
-func NewTestsslScanner(db *database.Database, testsslPath string) *TestsslScanner {
+func NewTestsslScanner(db *database.Database, testsslPath string, sb *sandbox.Sandbox, am *artifacts.Manager) *TestsslScanner {
 	return &TestsslScanner{
 		db:          db,
 		testsslPath: testsslPath,
+		sandbox:     sb,
+		artifacts:   am,
 	}
 }
 
+// SetTestsslPath changes the testssl.sh binary used by scans started afterwards
+func (s *TestsslScanner) SetTestsslPath(path string) {
+	s.pathMu.Lock()
+	s.testsslPath = path
+	s.pathMu.Unlock()
+}
+
+// SetRetryPolicy retries testssl.sh runs that failed transiently
+func (s *TestsslScanner) SetRetryPolicy(p supervise.Policy) {
+	s.retry = p
+}
+
+// SetLimits caps the text of findings
+func (s *TestsslScanner) SetLimits(l *limits.Limits) {
+	s.limits = l
+}
+
+// SetPolicies evaluates the findings of scans against the TLS policy of
+// their project
+func (s *TestsslScanner) SetPolicies(l *tlspolicy.Loader) {
+	s.policies = l
+}
+
+func (s *TestsslScanner) currentTestsslPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.testsslPath
+}
+
 // ExecuteScan runs a testssl.sh scan
 func (s *TestsslScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, config TestsslConfig) error {
 	// Update scan status to running
 	s.updateScanStatus(scanID, "running", 0)
 	s.addLog(scanID, "info", fmt.Sprintf("Starting testssl.sh scan on target: %s", config.Target))
 
-	// Create temp file for JSON output
-	outputFile := fmt.Sprintf("/tmp/testssl_%s.json", scanID.String())
-	defer os.Remove(outputFile)
+	// JSON output is kept in the scan workspace
+	ws, err := s.artifacts.Create(scanID)
+	if err != nil {
+		s.updateScanStatus(scanID, "failed", 0)
+		s.addLog(scanID, "error", err.Error())
+		return err
+	}
+	defer ws.Close()
+	outputFile := ws.Output("testssl.json")
 
 	// Build testssl.sh command
 	args := []string{
@@ -135,53 +183,27 @@ func (s *TestsslScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, conf
 	// Add target
 	args = append(args, config.Target)
 
-	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", s.testsslPath, args))
-
-	// Execute testssl.sh
-	cmd := exec.CommandContext(ctx, s.testsslPath, args...)
-
-	// Capture stderr for progress
-	stderr, _ := cmd.StderrPipe()
-	stdout, _ := cmd.StdoutPipe()
-
-	if err := cmd.Start(); err != nil {
+	testsslPath := s.currentTestsslPath()
+	s.addLog(scanID, "info", fmt.Sprintf("Executing: %s %v", testsslPath, args))
+
+	// Execute testssl.sh, again after transient failures
+	err = s.retry.Run(ctx, func(ctx context.Context) error {
+		// testssl.sh won't overwrite the JSON file of a failed attempt
+		os.Remove(outputFile)
+		return s.run(ctx, scanID, testsslPath, args)
+	}, func(a supervise.Attempt) {
+		level, message := attemptLog("testssl.sh", a)
+		s.addLog(scanID, level, message)
+	})
+	var startErr *startError
+	if errors.As(err, &startErr) {
 		s.updateScanStatus(scanID, "failed", 0)
 		s.addLog(scanID, "error", fmt.Sprintf("Failed to start testssl.sh: %v", err))
 		return err
 	}
-
-	// Read progress from output
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		lineCount := 0
-		for scanner.Scan() {
-			line := scanner.Text()
-			lineCount++
-			// Update progress based on output
-			if strings.Contains(line, "Testing protocols") {
-				s.updateScanStatus(scanID, "running", 10)
-			} else if strings.Contains(line, "Testing cipher") {
-				s.updateScanStatus(scanID, "running", 30)
-			} else if strings.Contains(line, "Testing vulnerabilities") {
-				s.updateScanStatus(scanID, "running", 50)
-			} else if strings.Contains(line, "Testing HTTP") {
-				s.updateScanStatus(scanID, "running", 70)
-			}
-			s.addLog(scanID, "debug", line)
-		}
-	}()
-
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			s.addLog(scanID, "debug", scanner.Text())
-		}
-	}()
-
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
-		log.Printf("testssl.sh exited with: %v", err)
+	if err != nil {
 		// Continue to parse results even if exit code is non-zero
+		log.Printf("testssl.sh exited with: %v", err)
 	}
 
 	s.updateScanStatus(scanID, "running", 90)
@@ -195,28 +217,14 @@ func (s *TestsslScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, conf
 	}
 
 	// testssl.sh outputs multiple JSON objects, one per line
-	var findings []TestsslFinding
-	scanner := bufio.NewScanner(strings.NewReader(string(outputData)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		var finding TestsslFinding
-		if err := json.Unmarshal([]byte(line), &finding); err != nil {
-			// Try parsing as array
-			var findingArray []TestsslFinding
-			if err2 := json.Unmarshal([]byte(line), &findingArray); err2 == nil {
-				findings = append(findings, findingArray...)
-			}
-			continue
-		}
-		findings = append(findings, finding)
-	}
+	findings := parseTestsslFindings(outputData)
 
 	// Save results
-	s.saveTestsslResults(scanID, config.Target, findings)
+	for _, result := range testsslResults(config.Target, findings) {
+		s.limits.ApplyWebResult(ctx, scanID, &result)
+		SaveWebScanResult(s.db, scanID, result)
+	}
+	s.checkPolicy(ctx, scanID, config.Target, findings)
 
 	// Count findings by severity
 	severityCounts := make(map[string]int)
@@ -237,33 +245,97 @@ func (s *TestsslScanner) ExecuteScan(ctx context.Context, scanID uuid.UUID, conf
 	return nil
 }
 
-func (s *TestsslScanner) saveTestsslResults(scanID uuid.UUID, target string, findings []TestsslFinding) {
-	query := `
-		INSERT INTO web_scan_results (id, scan_id, tool, url, finding_id, severity,
-			finding_text, cve, cwe, metadata, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`
+// checkPolicy saves the violations of the TLS policy of the scan's project
+// as findings of their own
+func (s *TestsslScanner) checkPolicy(ctx context.Context, scanID uuid.UUID, target string, findings []TestsslFinding) {
+	if s.policies == nil || len(findings) == 0 {
+		return
+	}
+	var projectID string
+	if err := s.db.Pool.QueryRow(ctx, `SELECT project_id FROM web_scans WHERE id = $1`, scanID).Scan(&projectID); err != nil {
+		s.addLog(scanID, "warning", fmt.Sprintf("TLS policy not checked: %v", err))
+		return
+	}
+	policy, err := s.policies.For(ctx, projectID)
+	if err != nil {
+		s.addLog(scanID, "warning", fmt.Sprintf("TLS policy not checked: %v", err))
+		return
+	}
+	if policy == nil {
+		return
+	}
 
-	for _, finding := range findings {
-		// Map testssl severity to standard
-		severity := s.mapSeverity(finding.Severity)
+	results := testsslPolicyResults(target, policy, findings)
+	for _, result := range results {
+		SaveWebScanResult(s.db, scanID, result)
+	}
+	if len(results) == 0 {
+		s.addLog(scanID, "info", "Target meets the TLS policy")
+		return
+	}
+	s.addLog(scanID, "warning", fmt.Sprintf("Target violates the TLS policy: %d violations", len(results)))
+}
+
+// run runs testssl.sh once, following its progress
+func (s *TestsslScanner) run(ctx context.Context, scanID uuid.UUID, testsslPath string, args []string) error {
+	cmd := s.sandbox.Command(ctx, "testssl", testsslPath, args...)
 
-		metadata, _ := json.Marshal(map[string]interface{}{
-			"original_severity": finding.Severity,
-			"id":                finding.ID,
-		})
+	// Capture stderr for progress
+	stderr, _ := cmd.StderrPipe()
+	stdout, _ := cmd.StdoutPipe()
 
-		_, err := s.db.Pool.Exec(context.Background(), query,
-			uuid.New(), scanID, "testssl", target, finding.ID, severity,
-			finding.Finding, finding.CVE, finding.CWE, metadata, time.Now())
+	if err := cmd.Start(); err != nil {
+		return &startError{err}
+	}
 
-		if err != nil {
-			log.Printf("Failed to save testssl result: %v", err)
+	// Read progress from output
+	var tail stderrTail
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// Update progress based on output
+			if strings.Contains(line, "Testing protocols") {
+				s.updateScanStatus(scanID, "running", 10)
+			} else if strings.Contains(line, "Testing cipher") {
+				s.updateScanStatus(scanID, "running", 30)
+			} else if strings.Contains(line, "Testing vulnerabilities") {
+				s.updateScanStatus(scanID, "running", 50)
+			} else if strings.Contains(line, "Testing HTTP") {
+				s.updateScanStatus(scanID, "running", 70)
+			}
+			s.addLog(scanID, "debug", line)
 		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if sandbox.ViolationLine(line) {
+				s.addLog(scanID, "error", "Sandbox violation: "+line)
+				continue
+			}
+			tail.add(line)
+			s.addLog(scanID, "debug", line)
+		}
+	}()
+
+	// Wait for completion
+	wg.Wait()
+	err := cmd.Wait()
+	if msg, ok := sandbox.Violation(err); ok {
+		s.addLog(scanID, "error", "Sandbox violation: "+msg)
 	}
+	return tail.attach(err)
 }
 
-func (s *TestsslScanner) mapSeverity(testsslSeverity string) string {
+// testsslSeverity maps testssl.sh severities to standard ones
+func testsslSeverity(testsslSeverity string) string {
 	switch strings.ToUpper(testsslSeverity) {
 	case "CRITICAL":
 		return "critical"
@@ -299,7 +371,8 @@ func (s *TestsslScanner) updateScanStatus(scanID uuid.UUID, status string, progr
 		argIndex++
 	}
 
-	query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+	// Cancelled scans stay cancelled
+	query += fmt.Sprintf(" WHERE id = $%d AND status <> 'cancelled'", argIndex)
 	args = append(args, scanID)
 
 	s.db.Pool.Exec(context.Background(), query, args...)
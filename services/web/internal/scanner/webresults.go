@@ -0,0 +1,279 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/tlspolicy"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/limits"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+// ParseFfufOutput converts ffuf's JSON output (-of json) into results
+func ParseFfufOutput(data []byte) ([]models.WebScanResult, error) {
+	var output FfufOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("invalid ffuf output: %w", err)
+	}
+
+	results := make([]models.WebScanResult, 0, len(output.Results))
+	for _, r := range output.Results {
+		results = append(results, models.WebScanResult{
+			Tool:          "ffuf",
+			URL:           r.URL,
+			StatusCode:    r.Status,
+			ContentLength: r.Length,
+			Words:         r.Words,
+			Lines:         r.Lines,
+			ContentType:   r.ContentType,
+			RedirectURL:   r.Redirecturl,
+			Metadata: map[string]interface{}{
+				"position": r.Position,
+				"duration": r.Duration,
+				"input":    r.Input,
+				"host":     r.Host,
+			},
+		})
+	}
+	return results, nil
+}
+
+// parseTestsslFindings reads testssl.sh's --jsonfile output: one JSON
+// object or array of findings per line
+func parseTestsslFindings(data []byte) []TestsslFinding {
+	var findings []TestsslFinding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), limits.MaxLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var finding TestsslFinding
+		if err := json.Unmarshal(line, &finding); err != nil {
+			var findingArray []TestsslFinding
+			if err2 := json.Unmarshal(line, &findingArray); err2 == nil {
+				findings = append(findings, findingArray...)
+			}
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// testsslResults converts testssl.sh findings for target into results
+func testsslResults(target string, findings []TestsslFinding) []models.WebScanResult {
+	results := make([]models.WebScanResult, 0, len(findings))
+	for _, f := range findings {
+		results = append(results, models.WebScanResult{
+			Tool:        "testssl",
+			URL:         target,
+			FindingID:   f.ID,
+			Severity:    testsslSeverity(f.Severity),
+			FindingText: f.Finding,
+			CVE:         f.CVE,
+			CWE:         f.CWE,
+			Metadata: map[string]interface{}{
+				"original_severity": f.Severity,
+				"id":                f.ID,
+			},
+		})
+	}
+	return results
+}
+
+// testsslPolicyResults converts the violations of policy in testssl.sh
+// findings for target into results, one per breach
+func testsslPolicyResults(target string, policy *tlspolicy.Policy, findings []TestsslFinding) []models.WebScanResult {
+	input := make([]tlspolicy.Finding, len(findings))
+	for i, f := range findings {
+		input[i] = tlspolicy.Finding{ID: f.ID, Finding: f.Finding}
+	}
+	violations := tlspolicy.Evaluate(policy, input)
+	results := make([]models.WebScanResult, 0, len(violations))
+	for _, v := range violations {
+		results = append(results, models.WebScanResult{
+			Tool:        "testssl",
+			URL:         target,
+			FindingID:   "policy_" + v.Rule,
+			Severity:    v.Severity,
+			FindingText: v.Detail,
+			Metadata: map[string]interface{}{
+				"policy_violation": true,
+				"policy_project":   policy.ProjectID,
+				"rule":             v.Rule,
+				"finding":          v.Finding,
+				"evidence":         v.Evidence,
+			},
+		})
+	}
+	return results
+}
+
+// ParseTestsslOutput converts testssl.sh's JSON output for target into results
+func ParseTestsslOutput(target string, data []byte) ([]models.WebScanResult, error) {
+	findings := parseTestsslFindings(data)
+	if len(findings) == 0 && len(bytes.TrimSpace(data)) > 0 {
+		return nil, fmt.Errorf("invalid testssl.sh output: no findings found")
+	}
+	return testsslResults(target, findings), nil
+}
+
+// Delimiters and fields of sqlmap's report of injection points and
+// fingerprint, the same in its console output and its per-target log file
+var (
+	sqlmapParameterRe = regexp.MustCompile(`^Parameter: (.+?) \((.+)\)$`)
+	sqlmapFieldRe     = regexp.MustCompile(`^\s+(Type|Title|Payload|Vector): (.*)$`)
+	sqlmapFingerprint = map[string]string{
+		"back-end DBMS":                  "dbms",
+		"back-end DBMS operating system": "dbms_os",
+		"web server operating system":    "os",
+		"web application technology":     "technology",
+		"banner":                         "banner",
+	}
+)
+
+// sqlmapTechnique is a way sqlmap found to exploit a parameter
+type sqlmapTechnique struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Payload string `json:"payload"`
+}
+
+// ParseSqlmapOutput converts sqlmap's console output (or its per-target log
+// file) for target into results: one per injectable parameter, and one
+// with the DBMS fingerprint when sqlmap identified it
+func ParseSqlmapOutput(target string, data []byte) ([]models.WebScanResult, error) {
+	type injection struct {
+		parameter, place string
+		techniques       []sqlmapTechnique
+	}
+	var injections []*injection
+	seen := map[string]bool{}
+	fingerprint := map[string]interface{}{}
+
+	var current *injection
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 64*1024), limits.MaxLineBytes)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if m := sqlmapParameterRe.FindStringSubmatch(line); m != nil {
+			// sqlmap repeats the report when resuming a session
+			key := m[2] + " " + m[1]
+			current = nil
+			if !seen[key] {
+				seen[key] = true
+				current = &injection{parameter: m[1], place: m[2]}
+				injections = append(injections, current)
+			}
+			continue
+		}
+		if line == "---" {
+			current = nil
+			continue
+		}
+		if m := sqlmapFieldRe.FindStringSubmatch(line); m != nil && current != nil {
+			switch m[1] {
+			case "Type":
+				current.techniques = append(current.techniques, sqlmapTechnique{Type: m[2]})
+			case "Title", "Payload":
+				if len(current.techniques) == 0 {
+					continue
+				}
+				t := &current.techniques[len(current.techniques)-1]
+				if m[1] == "Title" {
+					t.Title = m[2]
+				} else {
+					t.Payload = m[2]
+				}
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ": "); ok {
+			if key, known := sqlmapFingerprint[strings.TrimSpace(name)]; known && value != "" {
+				fingerprint[key] = strings.Trim(strings.TrimSpace(value), "'")
+			}
+		}
+	}
+
+	dbms, _ := fingerprint["dbms"].(string)
+	results := make([]models.WebScanResult, 0, len(injections)+1)
+	for _, inj := range injections {
+		types := make([]string, len(inj.techniques))
+		for i, t := range inj.techniques {
+			types[i] = t.Type
+		}
+		text := fmt.Sprintf("%s parameter '%s' is injectable", inj.place, inj.parameter)
+		if len(types) > 0 {
+			text += " (" + strings.Join(types, ", ") + ")"
+		}
+		if dbms != "" {
+			text += "; back-end DBMS: " + dbms
+		}
+		results = append(results, models.WebScanResult{
+			Tool:        "sqlmap",
+			URL:         target,
+			FindingID:   "sqli-" + strings.ToLower(inj.place) + "-" + inj.parameter,
+			Severity:    "critical",
+			FindingText: text,
+			CWE:         "CWE-89",
+			Metadata: map[string]interface{}{
+				"parameter":  inj.parameter,
+				"place":      inj.place,
+				"techniques": inj.techniques,
+				"dbms":       dbms,
+			},
+		})
+	}
+	if dbms != "" {
+		var parts []string
+		for _, label := range []string{"back-end DBMS", "back-end DBMS operating system", "web server operating system", "web application technology", "banner"} {
+			if v, ok := fingerprint[sqlmapFingerprint[label]].(string); ok {
+				parts = append(parts, label+": "+v)
+			}
+		}
+		results = append(results, models.WebScanResult{
+			Tool:        "sqlmap",
+			URL:         target,
+			FindingID:   "sqlmap-fingerprint",
+			Severity:    "info",
+			FindingText: strings.Join(parts, "; "),
+			Metadata:    fingerprint,
+		})
+	}
+	return results, nil
+}
+
+// SaveWebScanResult stores a result of scanID, under result.ID when set
+func SaveWebScanResult(db *database.Database, scanID uuid.UUID, result models.WebScanResult) error {
+	query := `
+		INSERT INTO web_scan_results (id, scan_id, tool, url, status_code, content_length, words, lines,
+			content_type, redirect_url, title, screenshot_path, screenshot_b64, finding_id, severity,
+			finding_text, cve, cwe, metadata, truncated, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14, $15, $16, $17, $18, $19, $20, $21)
+	`
+	metadata, _ := json.Marshal(result.Metadata)
+	if result.ID == uuid.Nil {
+		result.ID = uuid.New()
+	}
+
+	_, err := db.Pool.Exec(context.Background(), query,
+		result.ID, scanID, result.Tool, result.URL, result.StatusCode, result.ContentLength, result.Words, result.Lines,
+		result.ContentType, result.RedirectURL, result.Title, result.ScreenshotPath, result.ScreenshotB64,
+		result.FindingID, result.Severity, result.FindingText, result.CVE, result.CWE, metadata, result.Truncated, time.Now())
+	if err != nil {
+		log.Printf("Failed to save %s result: %v", result.Tool, err)
+	}
+	return err
+}
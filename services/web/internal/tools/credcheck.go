@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// CredCheck tries default credentials on login forms and services. It is
+// opt-in (CREDCHECK_ENABLED) since it performs real logins; scanner is nil
+// when disabled.
+type CredCheck struct {
+	runs
+	scanner *scanner.CredCheckScanner
+}
+
+func NewCredCheck(s *scanner.CredCheckScanner) *CredCheck {
+	return &CredCheck{scanner: s}
+}
+
+func (t *CredCheck) Name() string { return "credcheck" }
+
+func (t *CredCheck) Validate(body []byte) (*Job, error) {
+	if t.scanner == nil {
+		return nil, disabledError("Default credential checks are disabled (set CREDCHECK_ENABLED=true)")
+	}
+
+	var req models.CreateCredCheckScanRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if req.Name == "" || (len(req.Targets) == 0 && req.NetworkScanID == "") {
+		return nil, errors.New("name and targets or network_scan_id are required")
+	}
+	if req.Simulate && len(req.Targets) == 0 {
+		return nil, errors.New("Simulated credential checks need targets")
+	}
+	if req.NetworkScanID != "" {
+		if _, err := uuid.Parse(req.NetworkScanID); err != nil {
+			return nil, errors.New("Invalid network_scan_id")
+		}
+	}
+
+	// Show the first target, or the network scan
+	target := "network scan " + req.NetworkScanID
+	if len(req.Targets) > 0 {
+		target = displayTarget(req.Targets)
+	}
+
+	return &Job{
+		Name:     req.Name,
+		Target:   target,
+		Targets:  req.Targets,
+		Simulate: req.Simulate,
+		Config: map[string]interface{}{
+			"targets":         req.Targets,
+			"network_scan_id": req.NetworkScanID,
+			"services":        req.Services,
+			"max_attempts":    req.MaxAttempts,
+			"delay":           req.Delay,
+			"timeout":         req.Timeout,
+			"concurrency":     req.Concurrency,
+		},
+		options: scanner.CredCheckConfig{
+			Targets:       req.Targets,
+			NetworkScanID: req.NetworkScanID,
+			Services:      req.Services,
+			MaxAttempts:   req.MaxAttempts,
+			Delay:         req.Delay,
+			Timeout:       req.Timeout,
+			Concurrency:   req.Concurrency,
+		},
+	}, nil
+}
+
+func (t *CredCheck) Execute(ctx context.Context, scanID uuid.UUID, job *Job) error {
+	ctx, done := t.track(ctx, scanID)
+	defer done()
+	return t.scanner.ExecuteScan(ctx, scanID, job.options.(scanner.CredCheckConfig))
+}
+
+func (t *CredCheck) Templates() []models.WebScanTemplate {
+	return nil
+}
+
+// Fields describe CreateCredCheckScanRequest
+func (t *CredCheck) Fields() []capabilities.Field {
+	return []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true},
+		{Name: "targets", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Format: "uri"}, Description: "http(s)://, ssh://, snmp://, postgres://, mysql:// or redis:// URLs; required without network_scan_id"},
+		{Name: "network_scan_id", Type: capabilities.String, Format: "uuid", Description: "Check the open ports of a network scan"},
+		{Name: "services", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String, Enum: []string{"http", "ssh", "snmp", "postgres", "mysql", "redis"}}},
+		{Name: "max_attempts", Type: capabilities.Integer, Default: 5, Minimum: capabilities.Bound(1), Description: "Attempts per target"},
+		{Name: "delay", Type: capabilities.Integer, Default: 2, Minimum: capabilities.Bound(1), Description: "Seconds between attempts on a target"},
+		{Name: "timeout", Type: capabilities.Integer, Default: 10, Minimum: capabilities.Bound(1), Description: "Seconds per attempt"},
+		{Name: "concurrency", Type: capabilities.Integer, Default: 5, Minimum: capabilities.Bound(1), Description: "Targets checked at once"},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no logins"},
+	}
+}
+
+// ParseResults is unsupported: findings come from login attempts, not output
+func (t *CredCheck) ParseResults(target string, output []byte) ([]models.WebScanResult, error) {
+	return nil, ErrNoRawOutput
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// Ffuf fuzzes paths with ffuf
+type Ffuf struct {
+	runs
+	scanner *scanner.FfufScanner
+}
+
+func NewFfuf(s *scanner.FfufScanner) *Ffuf {
+	return &Ffuf{scanner: s}
+}
+
+func (t *Ffuf) Name() string { return "ffuf" }
+
+func (t *Ffuf) Validate(body []byte) (*Job, error) {
+	var req models.CreateFfufScanRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if req.Name == "" || req.URL == "" {
+		return nil, errors.New("name and url are required")
+	}
+
+	// Default wordlist
+	if req.Wordlist == "" {
+		req.Wordlist = "common"
+	}
+
+	return &Job{
+		Name:     req.Name,
+		Target:   req.URL,
+		Targets:  []string{req.URL},
+		Simulate: req.Simulate,
+		Config: map[string]interface{}{
+			"url":             req.URL,
+			"wordlist":        req.Wordlist,
+			"method":          req.Method,
+			"threads":         req.Threads,
+			"timeout":         req.Timeout,
+			"match_codes":     req.MatchCodes,
+			"filter_codes":    req.FilterCodes,
+			"filter_size":     req.FilterSize,
+			"extensions":      req.Extensions,
+			"headers":         req.Headers,
+			"recursion":       req.Recursion,
+			"recursion_depth": req.RecursionDepth,
+		},
+		options: scanner.FfufScanConfig{
+			URL:            req.URL,
+			Wordlist:       req.Wordlist,
+			Method:         req.Method,
+			Threads:        req.Threads,
+			Timeout:        req.Timeout,
+			MatchCodes:     req.MatchCodes,
+			FilterCodes:    req.FilterCodes,
+			FilterSize:     req.FilterSize,
+			Extensions:     req.Extensions,
+			Headers:        req.Headers,
+			Recursion:      req.Recursion,
+			RecursionDepth: req.RecursionDepth,
+		},
+	}, nil
+}
+
+func (t *Ffuf) Execute(ctx context.Context, scanID uuid.UUID, job *Job) error {
+	ctx, done := t.track(ctx, scanID)
+	defer done()
+	return t.scanner.ExecuteScan(ctx, scanID, job.options.(scanner.FfufScanConfig))
+}
+
+func (t *Ffuf) Templates() []models.WebScanTemplate {
+	return []models.WebScanTemplate{
+		{ID: "ffuf_common", Name: "Common Paths", Description: "Scan for common web paths and directories", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "common", "threads": 40}, IsDefault: true},
+		{ID: "ffuf_directories", Name: "Directory Bruteforce", Description: "Comprehensive directory discovery", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "directory-list-small", "threads": 50}, IsDefault: true},
+		{ID: "ffuf_files", Name: "File Discovery", Description: "Find common files and backups", Tool: "ffuf", Category: "discovery", Config: map[string]interface{}{"wordlist": "raft-medium-files", "threads": 40, "extensions": []string{".bak", ".old", ".txt", ".log"}}, IsDefault: true},
+		{ID: "ffuf_api", Name: "API Endpoints", Description: "Discover API endpoints", Tool: "ffuf", Category: "api", Config: map[string]interface{}{"wordlist": "common", "threads": 30}, IsDefault: true},
+	}
+}
+
+// Fields describe CreateFfufScanRequest
+func (t *Ffuf) Fields() []capabilities.Field {
+	wordlists := []string{}
+	for _, w := range t.scanner.GetAvailableWordlists() {
+		wordlists = append(wordlists, w["name"])
+	}
+	codes := &capabilities.Field{Type: capabilities.Integer, Minimum: capabilities.Bound(100), Maximum: capabilities.Bound(599)}
+	return []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true},
+		{Name: "url", Type: capabilities.String, Required: true, Format: "uri", Pattern: "FUZZ", Description: "URL with the FUZZ keyword"},
+		{Name: "wordlist", Type: capabilities.String, Default: "common", Enum: wordlists},
+		{Name: "method", Type: capabilities.String, Default: "GET", Enum: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}},
+		{Name: "threads", Type: capabilities.Integer, Default: 40, Minimum: capabilities.Bound(1)},
+		{Name: "timeout", Type: capabilities.Integer, Default: 10, Minimum: capabilities.Bound(1), Description: "Request timeout in seconds"},
+		{Name: "match_codes", Type: capabilities.Array, Items: codes, Description: "HTTP status codes to match"},
+		{Name: "filter_codes", Type: capabilities.Array, Items: codes, Description: "HTTP status codes to filter out"},
+		{Name: "filter_size", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.Integer, Minimum: capabilities.Bound(0)}, Description: "Response sizes to filter out"},
+		{Name: "extensions", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "File extensions, e.g. .bak"},
+		{Name: "headers", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Headers as \"Name: value\""},
+		{Name: "recursion", Type: capabilities.Boolean, Default: false},
+		{Name: "recursion_depth", Type: capabilities.Integer, Minimum: capabilities.Bound(0)},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+	}
+}
+
+// ParseResults reads ffuf's JSON output (-of json)
+func (t *Ffuf) ParseResults(target string, output []byte) ([]models.WebScanResult, error) {
+	return scanner.ParseFfufOutput(output)
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// Gowitness screenshots pages with gowitness
+type Gowitness struct {
+	runs
+	scanner *scanner.GowitnessScanner
+}
+
+func NewGowitness(s *scanner.GowitnessScanner) *Gowitness {
+	return &Gowitness{scanner: s}
+}
+
+func (t *Gowitness) Name() string { return "gowitness" }
+
+func (t *Gowitness) Validate(body []byte) (*Job, error) {
+	var req models.CreateGowintessScanRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if req.Name == "" || len(req.URLs) == 0 {
+		return nil, errors.New("name and urls are required")
+	}
+
+	return &Job{
+		Name:     req.Name,
+		Target:   displayTarget(req.URLs),
+		Targets:  req.URLs,
+		Simulate: req.Simulate,
+		Config: map[string]interface{}{
+			"urls":       req.URLs,
+			"timeout":    req.Timeout,
+			"resolution": req.Resolution,
+			"delay":      req.Delay,
+			"user_agent": req.UserAgent,
+			"full_page":  req.FullPage,
+		},
+		options: scanner.GowitnessConfig{
+			URLs:       req.URLs,
+			Timeout:    req.Timeout,
+			Resolution: req.Resolution,
+			Delay:      req.Delay,
+			UserAgent:  req.UserAgent,
+			FullPage:   req.FullPage,
+		},
+	}, nil
+}
+
+func (t *Gowitness) Execute(ctx context.Context, scanID uuid.UUID, job *Job) error {
+	ctx, done := t.track(ctx, scanID)
+	defer done()
+	return t.scanner.ExecuteScan(ctx, scanID, job.options.(scanner.GowitnessConfig))
+}
+
+func (t *Gowitness) Templates() []models.WebScanTemplate {
+	return []models.WebScanTemplate{
+		{ID: "gowitness_single", Name: "Single Screenshot", Description: "Capture screenshot of a single URL", Tool: "gowitness", Category: "recon", Config: map[string]interface{}{"timeout": 30}, IsDefault: true},
+		{ID: "gowitness_full", Name: "Full Page Screenshot", Description: "Capture full page screenshot", Tool: "gowitness", Category: "recon", Config: map[string]interface{}{"timeout": 60, "full_page": true}, IsDefault: true},
+	}
+}
+
+// Fields describe CreateGowintessScanRequest
+func (t *Gowitness) Fields() []capabilities.Field {
+	return []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true},
+		{Name: "urls", Type: capabilities.Array, Required: true, Items: &capabilities.Field{Type: capabilities.String, Format: "uri"}},
+		{Name: "timeout", Type: capabilities.Integer, Default: 60, Minimum: capabilities.Bound(1), Description: "Seconds per URL"},
+		{Name: "resolution", Type: capabilities.String, Default: "1920x1080", Pattern: `^\d+x\d+$`},
+		{Name: "delay", Type: capabilities.Integer, Default: 0, Minimum: capabilities.Bound(0), Description: "Seconds to wait before the screenshot"},
+		{Name: "user_agent", Type: capabilities.String},
+		{Name: "full_page", Type: capabilities.Boolean, Default: false},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+	}
+}
+
+// ParseResults is unsupported: screenshots are files, not output
+func (t *Gowitness) ParseResults(target string, output []byte) ([]models.WebScanResult, error) {
+	return nil, ErrNoRawOutput
+}
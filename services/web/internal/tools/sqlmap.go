@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// sqlmapMethods are the HTTP methods sqlmap scans may use
+var sqlmapMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// Sqlmap tests request parameters for SQL injection with sqlmap
+type Sqlmap struct {
+	runs
+	scanner *scanner.SqlmapScanner
+}
+
+func NewSqlmap(s *scanner.SqlmapScanner) *Sqlmap {
+	return &Sqlmap{scanner: s}
+}
+
+func (t *Sqlmap) Name() string { return "sqlmap" }
+
+func (t *Sqlmap) Validate(body []byte) (*Job, error) {
+	var req models.CreateSqlmapScanRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if req.Name == "" || req.URL == "" {
+		return nil, errors.New("name and url are required")
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("url must be an http(s) URL")
+	}
+
+	req.Method = strings.ToUpper(req.Method)
+	if req.Method != "" && !slices.Contains(sqlmapMethods, req.Method) {
+		return nil, errors.New("method must be one of " + strings.Join(sqlmapMethods, ", "))
+	}
+	if req.Level == 0 {
+		req.Level = 1
+	}
+	if req.Risk == 0 {
+		req.Risk = 1
+	}
+	if req.Level < 1 || req.Level > 5 {
+		return nil, errors.New("level must be between 1 and 5")
+	}
+	if req.Risk < 1 || req.Risk > 3 {
+		return nil, errors.New("risk must be between 1 and 3")
+	}
+	req.Technique = strings.ToUpper(req.Technique)
+	if strings.Trim(req.Technique, "BEUSTQ") != "" {
+		return nil, errors.New("technique must be a subset of BEUSTQ")
+	}
+	if req.Threads < 0 || req.Threads > 10 {
+		return nil, errors.New("threads must be between 1 and 10")
+	}
+	for _, h := range req.Headers {
+		if !strings.Contains(h, ":") || strings.ContainsAny(h, "\r\n") {
+			return nil, errors.New("headers must be \"Name: value\" lines")
+		}
+	}
+
+	return &Job{
+		Name:     req.Name,
+		Target:   req.URL,
+		Targets:  []string{req.URL},
+		Simulate: req.Simulate,
+		Config: map[string]interface{}{
+			"url":        req.URL,
+			"method":     req.Method,
+			"data":       req.Data,
+			"cookie":     req.Cookie,
+			"headers":    req.Headers,
+			"parameters": req.Parameters,
+			"level":      req.Level,
+			"risk":       req.Risk,
+			"technique":  req.Technique,
+			"dbms":       req.DBMS,
+			"threads":    req.Threads,
+			"timeout":    req.Timeout,
+		},
+		options: scanner.SqlmapConfig{
+			URL:        req.URL,
+			Method:     req.Method,
+			Data:       req.Data,
+			Cookie:     req.Cookie,
+			Headers:    req.Headers,
+			Parameters: req.Parameters,
+			Level:      req.Level,
+			Risk:       req.Risk,
+			Technique:  req.Technique,
+			DBMS:       req.DBMS,
+			Threads:    req.Threads,
+			Timeout:    req.Timeout,
+		},
+	}, nil
+}
+
+func (t *Sqlmap) Execute(ctx context.Context, scanID uuid.UUID, job *Job) error {
+	ctx, done := t.track(ctx, scanID)
+	defer done()
+	return t.scanner.ExecuteScan(ctx, scanID, job.options.(scanner.SqlmapConfig))
+}
+
+func (t *Sqlmap) Templates() []models.WebScanTemplate {
+	return []models.WebScanTemplate{
+		{ID: "sqlmap_quick", Name: "Quick SQL Injection Check", Description: "Default level and risk, safe payloads only", Tool: "sqlmap", Category: "injection", Config: map[string]interface{}{"level": 1, "risk": 1}, IsDefault: true},
+		{ID: "sqlmap_thorough", Name: "Thorough SQL Injection Test", Description: "Also tests cookies and headers with more payloads", Tool: "sqlmap", Category: "injection", Config: map[string]interface{}{"level": 3, "risk": 2}, IsDefault: true},
+		{ID: "sqlmap_blind", Name: "Blind SQL Injection", Description: "Boolean and time-based blind techniques only", Tool: "sqlmap", Category: "injection", Config: map[string]interface{}{"level": 2, "risk": 1, "technique": "BT"}, IsDefault: true},
+	}
+}
+
+// Fields describe CreateSqlmapScanRequest
+func (t *Sqlmap) Fields() []capabilities.Field {
+	return []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true},
+		{Name: "url", Type: capabilities.String, Required: true, Format: "uri", Description: "URL with the parameters to test"},
+		{Name: "method", Type: capabilities.String, Enum: sqlmapMethods, Description: "GET, or POST with data, by default"},
+		{Name: "data", Type: capabilities.String, Description: "Request body"},
+		{Name: "cookie", Type: capabilities.String, Description: "Cookie header; tested from level 2"},
+		{Name: "headers", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Extra \"Name: value\" headers"},
+		{Name: "parameters", Type: capabilities.Array, Items: &capabilities.Field{Type: capabilities.String}, Description: "Test only these parameters"},
+		{Name: "level", Type: capabilities.Integer, Default: 1, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(5), Description: "Places and payloads tested"},
+		{Name: "risk", Type: capabilities.Integer, Default: 1, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(3), Description: "3 adds payloads that may modify data"},
+		{Name: "technique", Type: capabilities.String, Pattern: "^[BEUSTQbeustq]*$", Description: "Subset of BEUSTQ"},
+		{Name: "dbms", Type: capabilities.String, Description: "Known back-end DBMS, skips the fingerprint"},
+		{Name: "threads", Type: capabilities.Integer, Default: 1, Minimum: capabilities.Bound(1), Maximum: capabilities.Bound(10)},
+		{Name: "timeout", Type: capabilities.Integer, Default: 30, Minimum: capabilities.Bound(1), Description: "Seconds per request"},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+	}
+}
+
+// ParseResults reads sqlmap's console output, or its log file of the target
+func (t *Sqlmap) ParseResults(target string, output []byte) ([]models.WebScanResult, error) {
+	return scanner.ParseSqlmapOutput(target, output)
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// Testssl audits TLS configurations with testssl.sh
+type Testssl struct {
+	runs
+	scanner *scanner.TestsslScanner
+}
+
+func NewTestssl(s *scanner.TestsslScanner) *Testssl {
+	return &Testssl{scanner: s}
+}
+
+func (t *Testssl) Name() string { return "testssl" }
+
+func (t *Testssl) Validate(body []byte) (*Job, error) {
+	var req models.CreateTestsslScanRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+	if req.Name == "" || req.Target == "" {
+		return nil, errors.New("name and target are required")
+	}
+
+	return &Job{
+		Name:     req.Name,
+		Target:   req.Target,
+		Targets:  []string{req.Target},
+		Simulate: req.Simulate,
+		Config: map[string]interface{}{
+			"target":          req.Target,
+			"protocols":       req.Protocols,
+			"ciphers":         req.Ciphers,
+			"vulnerabilities": req.Vulnerabilities,
+			"headers":         req.Headers,
+			"certificate":     req.Certificate,
+			"full":            req.Full,
+			"fast":            req.Fast,
+			"sni":             req.SNI,
+			"starttls":        req.StartTLS,
+		},
+		options: scanner.TestsslConfig{
+			Target:          req.Target,
+			Protocols:       req.Protocols,
+			Ciphers:         req.Ciphers,
+			Vulnerabilities: req.Vulnerabilities,
+			Headers:         req.Headers,
+			Certificate:     req.Certificate,
+			Full:            req.Full,
+			Fast:            req.Fast,
+			SNI:             req.SNI,
+			StartTLS:        req.StartTLS,
+		},
+	}, nil
+}
+
+func (t *Testssl) Execute(ctx context.Context, scanID uuid.UUID, job *Job) error {
+	ctx, done := t.track(ctx, scanID)
+	defer done()
+	return t.scanner.ExecuteScan(ctx, scanID, job.options.(scanner.TestsslConfig))
+}
+
+func (t *Testssl) Templates() []models.WebScanTemplate {
+	return []models.WebScanTemplate{
+		{ID: "testssl_quick", Name: "Quick SSL Check", Description: "Fast SSL/TLS configuration check", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"protocols": true, "fast": true}, IsDefault: true},
+		{ID: "testssl_full", Name: "Full SSL Audit", Description: "Comprehensive SSL/TLS security audit", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"full": true}, IsDefault: true},
+		{ID: "testssl_vulns", Name: "SSL Vulnerabilities", Description: "Check for SSL/TLS vulnerabilities", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"vulnerabilities": true}, IsDefault: true},
+		{ID: "testssl_ciphers", Name: "Cipher Analysis", Description: "Analyze supported ciphers", Tool: "testssl", Category: "ssl", Config: map[string]interface{}{"ciphers": true}, IsDefault: true},
+	}
+}
+
+// Fields describe CreateTestsslScanRequest
+func (t *Testssl) Fields() []capabilities.Field {
+	return []capabilities.Field{
+		{Name: "name", Type: capabilities.String, Required: true},
+		{Name: "target", Type: capabilities.String, Required: true, Description: "hostname:port"},
+		{Name: "protocols", Type: capabilities.Boolean, Default: false},
+		{Name: "ciphers", Type: capabilities.Boolean, Default: false},
+		{Name: "vulnerabilities", Type: capabilities.Boolean, Default: false},
+		{Name: "headers", Type: capabilities.Boolean, Default: false},
+		{Name: "certificate", Type: capabilities.Boolean, Default: false},
+		{Name: "full", Type: capabilities.Boolean, Default: false},
+		{Name: "fast", Type: capabilities.Boolean, Default: false, Description: "Omit some tests"},
+		{Name: "sni", Type: capabilities.String, Description: "Server Name Indication"},
+		{Name: "starttls", Type: capabilities.String, Enum: []string{"ftp", "smtp", "lmtp", "pop3", "imap", "xmpp", "xmpp-server", "telnet", "ldap", "nntp", "sieve", "postgres", "mysql"}},
+		{Name: "simulate", Type: capabilities.Boolean, Default: false, Description: "Synthetic results, no traffic"},
+	}
+}
+
+// ParseResults reads testssl.sh's JSON output (--jsonfile)
+func (t *Testssl) ParseResults(target string, output []byte) ([]models.WebScanResult, error) {
+	return scanner.ParseTestsslOutput(target, output)
+}
@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/security-scanner/shared/pkg/capabilities"
+	"github.com/security-scanner/web-service/internal/models"
+)
+
+var (
+	// ErrDisabled is returned by Validate for tools turned off in the configuration
+	ErrDisabled = errors.New("tool is disabled")
+	// ErrNoRawOutput is returned by ParseResults for tools without a raw
+	// output format to import
+	ErrNoRawOutput = errors.New("tool has no raw output to import")
+)
+
+// disabledError is an ErrDisabled with the message shown to callers
+type disabledError string
+
+func (e disabledError) Error() string        { return string(e) }
+func (e disabledError) Is(target error) bool { return target == ErrDisabled }
+
+// Tool is a web scanning tool. Adding a tool is implementing Tool and
+// registering it; the /api/webscans handlers create, run, cancel and import
+// scans of every registered tool the same way.
+type Tool interface {
+	// Name is the tool of its scans and the /api/webscans/<name> route
+	Name() string
+	// Validate parses and checks a create request body
+	Validate(body []byte) (*Job, error)
+	// Execute runs a job, recording the status, logs and results of scanID
+	Execute(ctx context.Context, scanID uuid.UUID, job *Job) error
+	// Cancel stops a running scan and reports whether it was running
+	Cancel(scanID uuid.UUID) bool
+	// Templates are the presets offered for the tool
+	Templates() []models.WebScanTemplate
+	// Fields describe the create request body, for GET /api/capabilities
+	Fields() []capabilities.Field
+	// ParseResults converts the tool's raw output for target into results
+	ParseResults(target string, output []byte) ([]models.WebScanResult, error)
+}
+
+// Job is a validated scan request
+type Job struct {
+	Name     string
+	Target   string                 // shown in scan lists
+	Targets  []string               // what simulated scans generate results for
+	Config   map[string]interface{} // stored as the scan's configuration
+	Simulate bool
+	options  interface{} // tool-specific configuration passed to Execute
+}
+
+// displayTarget shows the first of several targets
+func displayTarget(targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	target := targets[0]
+	if len(targets) > 1 {
+		target += " (+" + strconv.Itoa(len(targets)-1) + " more)"
+	}
+	return target
+}
+
+// Registry holds the tools of the service by name
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: map[string]Tool{}}
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Registry) Register(tool Tool) {
+	r.mu.Lock()
+	r.tools[tool.Name()] = tool
+	r.mu.Unlock()
+}
+
+// Get returns the tool called name
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Names lists the registered tools alphabetically
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Templates lists the templates of every tool
+func (r *Registry) Templates() []models.WebScanTemplate {
+	templates := []models.WebScanTemplate{}
+	for _, name := range r.Names() {
+		if tool, ok := r.Get(name); ok {
+			templates = append(templates, tool.Templates()...)
+		}
+	}
+	return templates
+}
+
+// ScanTypes describes the create request of every tool
+func (r *Registry) ScanTypes() []capabilities.ScanType {
+	scanTypes := []capabilities.ScanType{}
+	for _, name := range r.Names() {
+		if tool, ok := r.Get(name); ok {
+			scanTypes = append(scanTypes, capabilities.ScanType{
+				Name:     name,
+				Title:    name,
+				Tool:     name,
+				Endpoint: "POST /api/webscans/" + name,
+				Fields:   tool.Fields(),
+			})
+		}
+	}
+	return scanTypes
+}
+
+// runs tracks the running scans of a tool so they can be cancelled. Tools
+// embed it and wrap Execute with track.
+type runs struct {
+	mu      sync.Mutex
+	cancels map[uuid.UUID]context.CancelFunc
+}
+
+// track returns a context cancelled by Cancel(scanID) and a function to
+// call once the scan is over
+func (r *runs) track(ctx context.Context, scanID uuid.UUID) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	if r.cancels == nil {
+		r.cancels = map[uuid.UUID]context.CancelFunc{}
+	}
+	r.cancels[scanID] = cancel
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.cancels, scanID)
+		r.mu.Unlock()
+		cancel()
+	}
+}
+
+func (r *runs) Cancel(scanID uuid.UUID) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[scanID]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
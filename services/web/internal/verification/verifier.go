@@ -0,0 +1,267 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	shareddb "github.com/security-scanner/shared/pkg/database"
+	"github.com/security-scanner/shared/pkg/origin"
+	"github.com/security-scanner/shared/pkg/runtimeconfig"
+	"github.com/security-scanner/web-service/internal/database"
+	"github.com/security-scanner/web-service/internal/scanner"
+)
+
+// Finding statuses. open and fixed are set by users; the others by the verifier.
+const (
+	StatusOpen          = "open"
+	StatusFixed         = "fixed"
+	StatusVerifying     = "verifying"
+	StatusVerifiedFixed = "verified_fixed"
+	StatusReopened      = "reopened"
+)
+
+var (
+	// ErrNotFound is returned when a finding does not exist
+	ErrNotFound = errors.New("finding not found")
+	// ErrInvalidStatus is returned for statuses users can't set
+	ErrInvalidStatus = errors.New("status must be open or fixed")
+)
+
+const schemaSQL = `
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open';
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMP;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verify_after TIMESTAMP;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verification_scan_id UUID;
+ALTER TABLE vulnerabilities ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_vulnerabilities_verify ON vulnerabilities(verify_after) WHERE status = 'fixed'`
+
+// Status is the remediation state of a finding
+type Status struct {
+	FindingID          uuid.UUID  `json:"finding_id"`
+	Status             string     `json:"status"`
+	Severity           string     `json:"severity"`
+	StatusUpdatedAt    *time.Time `json:"status_updated_at,omitempty"`
+	VerifyAfter        *time.Time `json:"verify_after,omitempty"`
+	VerificationScanID *uuid.UUID `json:"verification_scan_id,omitempty"`
+	VerifiedAt         *time.Time `json:"verified_at,omitempty"`
+}
+
+// Verifier rescans findings of the configured severities after they are
+// marked fixed: the finding's template is re-run against its host once the
+// delay has passed, and the finding becomes verified_fixed or reopened.
+type Verifier struct {
+	db         *database.Database
+	nuclei     *scanner.NucleiScanner
+	limiter    *runtimeconfig.Limiter
+	severities map[string]bool
+	delay      time.Duration
+	leases     *shareddb.Leases
+}
+
+// NewVerifier adds the status columns to vulnerabilities
+func NewVerifier(db *database.Database, nuclei *scanner.NucleiScanner, limiter *runtimeconfig.Limiter, severities []string, delay time.Duration) (*Verifier, error) {
+	ctx := context.Background()
+	if _, err := db.Pool.Exec(ctx, schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to add finding status columns: %w", err)
+	}
+
+	v := &Verifier{db: db, nuclei: nuclei, limiter: limiter, severities: map[string]bool{}, delay: delay}
+	for _, s := range severities {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			v.severities[s] = true
+		}
+	}
+	return v, nil
+}
+
+// SetStatus records a user's status change. Marking a finding of a verified
+// severity as fixed schedules its verification rescan.
+func (v *Verifier) SetStatus(ctx context.Context, id uuid.UUID, status string) (*Status, error) {
+	if status != StatusOpen && status != StatusFixed {
+		return nil, ErrInvalidStatus
+	}
+
+	var severity string
+	err := v.db.Pool.QueryRow(ctx, `SELECT severity FROM vulnerabilities WHERE id = $1`, id).Scan(&severity)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var verifyAfter *time.Time
+	if status == StatusFixed && v.severities[strings.ToLower(severity)] {
+		at := time.Now().Add(v.delay)
+		verifyAfter = &at
+	}
+
+	if _, err := v.db.Pool.Exec(ctx, `
+		UPDATE vulnerabilities
+		SET status = $1, status_updated_at = NOW(), verify_after = $2, verification_scan_id = NULL, verified_at = NULL
+		WHERE id = $3
+	`, status, verifyAfter, id); err != nil {
+		return nil, err
+	}
+	return v.Get(ctx, id)
+}
+
+// Get returns the status of a finding
+func (v *Verifier) Get(ctx context.Context, id uuid.UUID) (*Status, error) {
+	var s Status
+	err := v.db.Pool.QueryRow(ctx, `
+		SELECT id, status, severity, status_updated_at, verify_after, verification_scan_id, verified_at
+		FROM vulnerabilities WHERE id = $1
+	`, id).Scan(&s.FindingID, &s.Status, &s.Severity, &s.StatusUpdatedAt, &s.VerifyAfter, &s.VerificationScanID, &s.VerifiedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SetLeases makes Start run verifications only on the replica holding the
+// job's lease
+func (v *Verifier) SetLeases(leases *shareddb.Leases) {
+	v.leases = leases
+}
+
+// Start checks for due verifications every minute until ctx is cancelled
+func (v *Verifier) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	leader := false
+	for {
+		if v.leases.Acquire(ctx, "web:verification", 3*time.Minute) {
+			if !leader {
+				v.requeueInterrupted(ctx)
+				leader = true
+			}
+			v.runDue(ctx)
+		} else {
+			leader = false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeueInterrupted queues again the findings left in verifying by the
+// previous holder of the lease, a replica that restarted or stopped
+func (v *Verifier) requeueInterrupted(ctx context.Context) {
+	if _, err := v.db.Pool.Exec(ctx, `
+		UPDATE vulnerabilities SET status = 'fixed', verify_after = NOW(), verification_scan_id = NULL
+		WHERE status = 'verifying'
+	`); err != nil {
+		log.Printf("Failed to requeue interrupted verifications: %v", err)
+	}
+}
+
+type dueFinding struct {
+	id         uuid.UUID
+	templateID string
+	host       string
+	matchedAt  string
+}
+
+// runDue claims every due finding and starts its verification scan
+func (v *Verifier) runDue(ctx context.Context) {
+	rows, err := v.db.Pool.Query(ctx, `
+		UPDATE vulnerabilities SET status = 'verifying', status_updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM vulnerabilities
+			WHERE status = 'fixed' AND verify_after <= NOW()
+			ORDER BY verify_after
+			LIMIT 20
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, template_id, host, COALESCE(matched_at, '')
+	`)
+	if err != nil {
+		log.Printf("⚠️ Failed to claim findings for verification: %v", err)
+		return
+	}
+	var due []dueFinding
+	for rows.Next() {
+		var f dueFinding
+		if err := rows.Scan(&f.id, &f.templateID, &f.host, &f.matchedAt); err == nil {
+			due = append(due, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range due {
+		go v.verify(f)
+	}
+}
+
+// verify runs the finding's template as a regular vulnerability scan, so
+// it shows up in the scan list with its logs
+func (v *Verifier) verify(f dueFinding) {
+	ctx := context.Background()
+
+	target := f.host
+	if target == "" {
+		target = f.matchedAt
+	}
+
+	scanID := uuid.New()
+	_, err := v.db.Pool.Exec(ctx, `
+		INSERT INTO vulnerability_scans (id, name, target, status, progress, created_at, templates, configuration, origin, project_id)
+		VALUES ($1, $2, $3, 'pending', 0, NOW(), $4, $5, $6, COALESCE(
+			(SELECT s.project_id FROM vulnerabilities v JOIN vulnerability_scans s ON s.id = v.scan_id WHERE v.id = $7), 'default'))
+	`, scanID, fmt.Sprintf("Verify %s on %s", f.templateID, target), target,
+		[]string{f.templateID}, map[string]interface{}{"verification_of": f.id.String()},
+		origin.Of(origin.Workflow, "fix-verification"), f.id)
+	if err != nil {
+		log.Printf("⚠️ Failed to create verification scan for finding %s: %v", f.id, err)
+		v.retry(ctx, f.id)
+		return
+	}
+	v.db.Pool.Exec(ctx, `UPDATE vulnerabilities SET verification_scan_id = $1 WHERE id = $2`, scanID, f.id)
+	log.Printf("🔁 Verifying fix of finding %s (%s on %s) with scan %s", f.id, f.templateID, target, scanID)
+
+	v.limiter.Acquire(ctx)
+	scanErr := v.nuclei.ExecuteTemplateCheck(ctx, scanID, target, f.templateID)
+	v.limiter.Release()
+
+	var scanStatus string
+	var matches int
+	if err := v.db.Pool.QueryRow(ctx, `
+		SELECT s.status, (SELECT COUNT(*) FROM vulnerabilities WHERE scan_id = s.id AND template_id = $2)
+		FROM vulnerability_scans s WHERE s.id = $1
+	`, scanID, f.templateID).Scan(&scanStatus, &matches); err != nil || scanErr != nil || scanStatus != "completed" {
+		log.Printf("⚠️ Verification scan %s did not complete, retrying finding %s later", scanID, f.id)
+		v.retry(ctx, f.id)
+		return
+	}
+
+	status := StatusVerifiedFixed
+	if matches > 0 {
+		status = StatusReopened
+	}
+	v.db.Pool.Exec(ctx, `
+		UPDATE vulnerabilities SET status = $1, status_updated_at = NOW(), verified_at = NOW(), verify_after = NULL
+		WHERE id = $2 AND status = 'verifying'
+	`, status, f.id)
+	log.Printf("✅ Finding %s is %s", f.id, status)
+}
+
+// retry puts the finding back in the queue for another attempt after the delay
+func (v *Verifier) retry(ctx context.Context, id uuid.UUID) {
+	v.db.Pool.Exec(ctx, `
+		UPDATE vulnerabilities SET status = 'fixed', verify_after = $1 WHERE id = $2 AND status = 'verifying'
+	`, time.Now().Add(v.delay), id)
+}
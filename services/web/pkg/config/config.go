@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 // Config holds all configuration for the web service
@@ -14,6 +15,7 @@ type Config struct {
 	// Nuclei configuration
 	NucleiPath    string
 	TemplatesPath string
+	NoiseProfile  string // noise profile of scans that don't choose one, "none" to keep every finding
 
 	// ffuf configuration
 	FfufPath      string
@@ -26,6 +28,80 @@ type Config struct {
 
 	// testssl.sh configuration
 	TestsslPath string
+
+	// sqlmap configuration
+	SqlmapPath string
+
+	// Default credential checks perform real logins, so they are opt-in
+	CredCheckEnabled bool
+
+	// Event bus configuration (disabled when EventBroker is empty)
+	EventBroker        string // nats or kafka
+	EventBrokerURL     string
+	EventSubjectPrefix string
+	EventTopic         string
+
+	// SIEM syslog output (disabled when SyslogAddress is empty)
+	SyslogAddress      string
+	SyslogNetwork      string // udp, tcp or tls
+	SyslogFormat       string // cef or leef
+	SyslogFieldMapping string // finding_field=siem_key pairs, comma separated
+
+	// Splunk HEC / Microsoft Sentinel connectors, JSON list of per-tenant configs
+	SIEMConnectors string
+
+	// Finding notifications (disabled when NotifyWebhookURL is empty)
+	NotifyWebhookURL string
+	NotifySecret     string
+	NotifyMode       string // immediate, hourly or daily
+	NotifyDigestHour int    // UTC hour of daily digests
+	NotifyDedupDays  int
+	NotifyTags       string // comma-separated asset tags; only their findings are notified
+
+	// Slack and Discord webhooks notified of scans with ChatSeverities findings;
+	// links point to the report at ReportBaseURL (the frontend)
+	SlackWebhookURL   string
+	DiscordWebhookURL string
+	ChatSeverities    string // comma separated
+	ReportBaseURL     string
+
+	// Verification rescans of findings marked fixed
+	VerifySeverities   string // comma separated, empty disables verification
+	VerifyDelayMinutes int
+
+	// Web ports whose opening on a known asset triggers a gowitness scan
+	AutoScreenshotPorts string // comma separated, empty disables the screenshots
+
+	// How often platform_config overrides are polled, in seconds
+	ConfigReloadInterval int
+
+	// Tool sandboxing, JSON map of tool name to profile (disabled when empty)
+	SandboxProfiles string
+	SetprivPath     string
+	BwrapPath       string
+
+	// Transient tool failures (OOM kills, DNS hiccups) are retried until
+	// ToolMaxAttempts runs, ToolRetryDelay seconds apart (doubling, jittered)
+	ToolMaxAttempts int
+	ToolRetryDelay  int
+
+	// Per-scan workspaces; raw outputs are kept for ArtifactRetentionHours
+	ArtifactsPath          string
+	ArtifactRetentionHours int
+
+	// Result fields above their size limit are cut; the full content is kept
+	// as an artifact, or in an S3-compatible bucket when ResultStorage is s3
+	ResultFieldLimits string // field=bytes pairs, comma separated
+	ResultStorage     string // empty or s3
+	ResultS3Endpoint  string // empty for AWS S3
+	ResultS3Region    string
+	ResultS3Bucket    string
+	ResultS3Prefix    string
+	ResultS3AccessKey string
+	ResultS3SecretKey string
+
+	// API requests must be signed by the gateway with this secret (disabled when empty)
+	InternalAuthSecret string
 }
 
 // Load loads configuration from environment variables
@@ -39,6 +115,7 @@ func Load() *Config {
 		// Nuclei
 		NucleiPath:    getEnv("NUCLEI_PATH", "/usr/local/bin/nuclei"),
 		TemplatesPath: getEnv("NUCLEI_TEMPLATES_PATH", "/root/nuclei-templates"),
+		NoiseProfile:  getEnv("NUCLEI_NOISE_PROFILE", "default"),
 
 		// ffuf
 		FfufPath:      getEnv("FFUF_PATH", "/usr/local/bin/ffuf"),
@@ -51,6 +128,75 @@ func Load() *Config {
 
 		// testssl.sh
 		TestsslPath: getEnv("TESTSSL_PATH", "/usr/local/bin/testssl.sh"),
+
+		// sqlmap
+		SqlmapPath: getEnv("SQLMAP_PATH", "/usr/local/bin/sqlmap"),
+
+		// Default credential checks
+		CredCheckEnabled: getEnvBool("CREDCHECK_ENABLED", false),
+
+		// Event bus
+		EventBroker:        getEnv("EVENT_BROKER", ""),
+		EventBrokerURL:     getEnv("EVENT_BROKER_URL", ""),
+		EventSubjectPrefix: getEnv("EVENT_SUBJECT_PREFIX", "scanner"),
+		EventTopic:         getEnv("EVENT_TOPIC", "scanner.events"),
+
+		// SIEM syslog output
+		SyslogAddress:      getEnv("SIEM_SYSLOG_ADDRESS", ""),
+		SyslogNetwork:      getEnv("SIEM_SYSLOG_NETWORK", "udp"),
+		SyslogFormat:       getEnv("SIEM_FORMAT", "cef"),
+		SyslogFieldMapping: getEnv("SIEM_FIELD_MAPPING", ""),
+		SIEMConnectors:     getEnv("SIEM_CONNECTORS", ""),
+
+		// Finding notifications
+		NotifyWebhookURL: getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifySecret:     getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+		NotifyMode:       getEnv("NOTIFY_MODE", "immediate"),
+		NotifyDigestHour: getEnvInt("NOTIFY_DIGEST_HOUR", 8),
+		NotifyDedupDays:  getEnvInt("NOTIFY_DEDUP_DAYS", 7),
+		NotifyTags:       getEnv("NOTIFY_TAGS", ""),
+
+		// Chat notifications
+		SlackWebhookURL:   getEnv("SLACK_WEBHOOK_URL", ""),
+		DiscordWebhookURL: getEnv("DISCORD_WEBHOOK_URL", ""),
+		ChatSeverities:    getEnv("CHAT_NOTIFY_SEVERITIES", "critical,high"),
+		ReportBaseURL:     getEnv("REPORT_BASE_URL", "http://localhost:3000"),
+
+		// Fix verification
+		VerifySeverities:   getEnv("VERIFY_SEVERITIES", "critical"),
+		VerifyDelayMinutes: getEnvInt("VERIFY_DELAY_MINUTES", 60),
+
+		// Screenshots of newly opened web ports
+		AutoScreenshotPorts: getEnv("AUTO_SCREENSHOT_PORTS", "80,443,3000,5000,8000,8008,8080,8081,8443,8888,9000,9090,9443"),
+
+		// Central configuration
+		ConfigReloadInterval: getEnvInt("CONFIG_RELOAD_INTERVAL", 15),
+
+		// Tool sandboxing
+		SandboxProfiles: getEnv("SANDBOX_PROFILES", ""),
+		SetprivPath:     getEnv("SETPRIV_PATH", "/usr/bin/setpriv"),
+		BwrapPath:       getEnv("BWRAP_PATH", "/usr/bin/bwrap"),
+
+		// Tool retries
+		ToolMaxAttempts: getEnvInt("TOOL_MAX_ATTEMPTS", 3),
+		ToolRetryDelay:  getEnvInt("TOOL_RETRY_DELAY", 5),
+
+		// Scan artifacts
+		ArtifactsPath:          getEnv("ARTIFACTS_PATH", "/root/artifacts"),
+		ArtifactRetentionHours: getEnvInt("ARTIFACT_RETENTION_HOURS", 72),
+
+		// Result size limits
+		ResultFieldLimits: getEnv("RESULT_FIELD_LIMITS", ""),
+		ResultStorage:     getEnv("RESULT_STORAGE", ""),
+		ResultS3Endpoint:  getEnv("RESULT_S3_ENDPOINT", ""),
+		ResultS3Region:    getEnv("RESULT_S3_REGION", "us-east-1"),
+		ResultS3Bucket:    getEnv("RESULT_S3_BUCKET", ""),
+		ResultS3Prefix:    getEnv("RESULT_S3_PREFIX", "results"),
+		ResultS3AccessKey: getEnv("RESULT_S3_ACCESS_KEY", ""),
+		ResultS3SecretKey: getEnv("RESULT_S3_SECRET_KEY", ""),
+
+		// Service-to-service auth
+		InternalAuthSecret: getEnv("INTERNAL_AUTH_SECRET", ""),
 	}
 }
 
@@ -60,3 +206,25 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return defaultValue
+		}
+		return boolVal
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return intVal
+	}
+	return defaultValue
+}